@@ -0,0 +1,152 @@
+// scanner-agent 键盘钩子代理，在登录用户的会话中安装键盘钩子并把扫码转交给本机服务
+//
+// 服务以系统服务运行时没有交互式桌面，无法安装键盘钩子，此时服务配置 agent.enable: true、
+// scanner.enable_hook: false，并在用户登录时启动本程序：
+//
+//	scanner-agent [-config configs/config.yaml] [-url ws://127.0.0.1:8080/api/agent/connect] [-token 共享令牌]
+//
+// 连接地址及令牌默认从服务的配置文件读取。扫码参数由服务推送，断线期间的扫码缓存在内存中，重连后补发。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/agent"
+	"userclient/internal/config"
+	"userclient/internal/scanner"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "键盘钩子代理退出: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run 连接服务，收到扫码参数后安装键盘钩子，参数变化时重新安装
+func run(args []string) error {
+	flags := flag.NewFlagSet("scanner-agent", flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "服务的配置文件路径，用于读取端口及共享令牌")
+	url := flags.String("url", "", "服务的代理连接地址，默认 ws://127.0.0.1:<server.port>"+agent.ConnectPath)
+	token := flags.String("token", "", "与服务共享的令牌，默认读取配置文件中的 agent.token")
+	flags.Parse(args)
+
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+
+	hello := agent.Hello{PID: os.Getpid()}
+	hello.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		hello.User = u.Username
+	}
+
+	// 显式指定地址及令牌时不需要读取配置文件
+	if *url == "" || *token == "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			return fmt.Errorf("加载配置失败: %w", err)
+		}
+		if *url == "" {
+			*url = fmt.Sprintf("ws://127.0.0.1:%d%s", cfg.Server.Port, agent.ConnectPath)
+		}
+		if *token == "" {
+			*token = cfg.Agent.Token
+		}
+		hello.Version = cfg.App.Version
+	}
+	if *token == "" {
+		return errors.New("未设置共享令牌，请在服务配置中设置 agent.token 或使用 -token 参数")
+	}
+	if ok, reason := scanner.Available(); !ok {
+		return fmt.Errorf("键盘钩子不可用: %s", reason)
+	}
+
+	client := agent.NewClient(agent.ClientOptions{
+		URL:    *url,
+		Token:  *token,
+		Hello:  hello,
+		Logger: logger,
+	})
+	// 只保留最新的扫码参数
+	configs := make(chan config.ScannerConfig, 1)
+	client.OnConfig(func(cfg config.ScannerConfig) {
+		select {
+		case <-configs:
+		default:
+		}
+		configs <- cfg
+	})
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- client.Run(ctx)
+	}()
+	logger.WithField("url", *url).Info("键盘钩子代理已启动，等待服务推送扫码参数")
+
+	var hook scanner.ScannerSource
+	var hookDone chan struct{}
+	var current config.ScannerConfig
+	stopHook := func() {
+		if hook != nil {
+			hook.Stop()
+			<-hookDone
+			hook = nil
+		}
+	}
+	defer stopHook()
+
+	for {
+		select {
+		case cfg := <-configs:
+			// 服务端通常关闭了本机钩子，由代理安装
+			cfg.EnableHook = true
+			// 只有按键超时、长度范围、终止符变化时直接替换，不重新安装键盘钩子
+			if tunable, ok := hook.(scanner.TunableSource); ok && scanner.SettingsOnly(current, cfg) {
+				if err := tunable.UpdateConfig(cfg); err == nil {
+					current = cfg
+					logger.Info("扫码参数已更新")
+					continue
+				}
+			}
+			stopHook()
+			hook = scanner.NewSource(&cfg, client, logger)
+			hookDone = make(chan struct{})
+			installed := make(chan error, 1)
+			go runHook(hook, installed, hookDone)
+			// 等待安装完成，避免参数连续变化时在安装前调用 Stop
+			if err := <-installed; err != nil {
+				logger.WithError(err).Error("安装键盘钩子失败，等待服务推送新的扫码参数")
+				hook = nil
+				continue
+			}
+			current = cfg
+		case err := <-runErr:
+			return err
+		case <-ctx.Done():
+			logger.Info("收到退出信号")
+			return nil
+		}
+	}
+}
+
+// runHook 安装键盘钩子并运行消息循环，安装与消息循环须在同一 goroutine 中
+func runHook(hook scanner.ScannerSource, installed chan<- error, done chan struct{}) {
+	defer close(done)
+	if err := hook.Install(); err != nil {
+		installed <- err
+		return
+	}
+	installed <- nil
+	hook.Run()
+}
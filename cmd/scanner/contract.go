@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"userclient/internal/contract"
+)
+
+// runContractTest 按当前的结构定义校验对接方提供的样例载荷：scanner contract-test [-schema 名称] <目录>
+//
+// 未指定 -schema 时按样例所在的子目录名（如 ingest/、event/）或文件名前缀（如 ingest-batch.json）
+// 确定结构定义。升级前用对接方的样例跑一遍，即可发现不兼容的字段变更。
+func runContractTest(args []string) error {
+	flags := flag.NewFlagSet("contract-test", flag.ExitOnError)
+	schema := flags.String("schema", "", "全部样例使用的结构定义（"+strings.Join(contract.Names(), "、")+"），默认按子目录名或文件名前缀确定")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("用法: scanner contract-test [-schema 名称] <样例目录>")
+	}
+	dir := flags.Arg(0)
+	if *schema != "" {
+		if _, err := contract.Document(*schema); err != nil {
+			return fmt.Errorf("%w: %s", err, *schema)
+		}
+	}
+
+	var total, failed int
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		total++
+
+		name := *schema
+		if name == "" {
+			name = sampleSchema(rel)
+		}
+		if name == "" {
+			failed++
+			fmt.Printf("FAIL %s: 无法确定结构定义，请放到以结构定义命名的子目录中或使用 -schema\n", rel)
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取样例失败: %w", err)
+		}
+		var violationErr *contract.ViolationError
+		switch err := contract.Validate(name, data); {
+		case errors.As(err, &violationErr):
+			failed++
+			fmt.Printf("FAIL %s (%s)\n", rel, name)
+			for _, v := range violationErr.Violations {
+				fmt.Printf("     %s\n", v)
+			}
+		case err != nil:
+			return err
+		default:
+			fmt.Printf("ok   %s (%s)\n", rel, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if total == 0 {
+		return fmt.Errorf("%s 中没有 .json 样例", dir)
+	}
+	fmt.Printf("结构定义版本 %s，共 %d 个样例，%d 个不符合\n", contract.Version, total, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d 个样例不符合结构定义", failed)
+	}
+	return nil
+}
+
+// sampleSchema 按样例的相对路径确定结构定义：先看所在的各级子目录名，再看文件名前缀
+func sampleSchema(rel string) string {
+	names := contract.Names()
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for _, dir := range parts[:len(parts)-1] {
+		for _, name := range names {
+			if strings.EqualFold(dir, name) {
+				return name
+			}
+		}
+	}
+	base := strings.ToLower(parts[len(parts)-1])
+	for _, name := range names {
+		if strings.HasPrefix(base, name+"-") || strings.HasPrefix(base, name+"_") || strings.HasPrefix(base, name+".") {
+			return name
+		}
+	}
+	return ""
+}
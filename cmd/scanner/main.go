@@ -5,10 +5,48 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata" // Windows 没有系统时区数据库，内嵌以支持 app.timezone
 	"userclient/internal/app"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 && os.Args[1] == "support-bundle" {
+		if err := runSupportBundle(os.Args[2:]); err != nil {
+			fmt.Printf("生成诊断包失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export-state" {
+		if err := runExportState(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "导出工位状态失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-state" {
+		if err := runImportState(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "导入工位状态失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "contract-test" {
+		if err := runContractTest(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "契约测试失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		if err := runQuery(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "查询失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 创建应用程序管理器
 	manager, err := app.New()
 	if err != nil {
@@ -24,6 +62,8 @@ func main() {
 	go func() {
 		if err := manager.Start(); err != nil {
 			manager.GetLogger().WithError(err).Error("应用程序启动失败")
+			// 停止已启动的服务后退出
+			manager.Stop()
 			os.Exit(1)
 		}
 	}()
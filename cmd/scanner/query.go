@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// queryUsage 查询子命令用法
+const queryUsage = `用法: scanner query <子命令> [参数] [-config 路径] [-json] [-tz 时区]
+
+子命令:
+  recent [n]                              最近 n 条扫码记录（默认 20）
+  find <内容> [-limit n]                   按内容、类型或消息搜索扫码记录
+  stats [-today]                          扫码统计，-today 仅统计今天
+  devices                                 设备列表
+  export -from 日期 -to 日期 [-out 文件]    导出日期范围内的扫码记录为CSV（日期格式 2006-01-02，含首尾两天）
+
+时间的显示及按日期的统计使用 -tz 指定的时区（如 Asia/Shanghai），默认为配置中的 app.timezone。
+`
+
+// queryTimeLayout 表格及CSV中的时间格式
+const queryTimeLayout = "2006-01-02 15:04:05"
+
+// queryCommand 查询子命令的公共参数及数据库连接
+type queryCommand struct {
+	flags      *flag.FlagSet
+	configPath *string
+	json       *bool
+	timezone   *string
+	db         *database.DB
+	location   *time.Location
+	logger     *logrus.Logger
+}
+
+// newQueryCommand 创建带公共参数的子命令
+func newQueryCommand(name string) *queryCommand {
+	flags := flag.NewFlagSet("query "+name, flag.ExitOnError)
+	return &queryCommand{
+		flags:      flags,
+		configPath: flags.String("config", "configs/config.yaml", "配置文件路径"),
+		json:       flags.Bool("json", false, "以JSON格式输出"),
+		timezone:   flags.String("tz", "", "显示及统计使用的时区，默认为配置中的 app.timezone"),
+	}
+}
+
+// parse 解析参数，参数与位置参数可交替出现，返回位置参数
+func (q *queryCommand) parse(args []string) []string {
+	var positional []string
+	for {
+		q.flags.Parse(args)
+		args = q.flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// open 以只读方式打开配置中的数据库，服务运行期间也可查询
+func (q *queryCommand) open() error {
+	cfg, err := config.Load(*q.configPath)
+	if err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+	if *q.timezone != "" {
+		q.location, err = config.LoadLocation(*q.timezone)
+	} else {
+		q.location, err = cfg.App.Location()
+	}
+	if err != nil {
+		return err
+	}
+	db, err := database.OpenReadOnly(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	q.db = db
+	q.logger = logrus.New()
+	q.logger.SetOutput(io.Discard)
+	return nil
+}
+
+// close 关闭数据库连接
+func (q *queryCommand) close() {
+	if q.db != nil {
+		q.db.Close()
+	}
+}
+
+// barcodes 只读的条码服务
+func (q *queryCommand) barcodes() *service.BarcodeService {
+	return service.NewBarcodeService(q.db.DB, q.logger)
+}
+
+// runQuery 查询本地数据库：scanner query <recent|find|stats|devices|export> ...
+func runQuery(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Print(queryUsage)
+		return nil
+	}
+
+	name, args := args[0], args[1:]
+	q := newQueryCommand(name)
+	defer q.close()
+
+	switch name {
+	case "recent":
+		return q.recent(args)
+	case "find":
+		return q.find(args)
+	case "stats":
+		return q.stats(args)
+	case "devices":
+		return q.devices(args)
+	case "export":
+		return q.export(args)
+	default:
+		fmt.Print(queryUsage)
+		return fmt.Errorf("未知的查询子命令: %s", name)
+	}
+}
+
+// recent 最近的扫码记录
+func (q *queryCommand) recent(args []string) error {
+	positional := q.parse(args)
+	n := 20
+	if len(positional) > 0 {
+		v, err := strconv.Atoi(positional[0])
+		if err != nil || v <= 0 {
+			return fmt.Errorf("记录数无效: %s", positional[0])
+		}
+		n = v
+	}
+	if err := q.open(); err != nil {
+		return err
+	}
+
+	records, _, err := q.barcodes().GetBarcodeRecords(1, n, service.BarcodeFilter{})
+	if err != nil {
+		return fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+	return q.printRecords(records)
+}
+
+// find 搜索扫码记录
+func (q *queryCommand) find(args []string) error {
+	limit := q.flags.Int("limit", 50, "最多显示的记录数")
+	positional := q.parse(args)
+	if len(positional) == 0 {
+		return errors.New("缺少搜索内容")
+	}
+	if *limit <= 0 {
+		return fmt.Errorf("记录数无效: %d", *limit)
+	}
+	if err := q.open(); err != nil {
+		return err
+	}
+
+	records, total, err := q.barcodes().SearchBarcodes(positional[0], 1, *limit)
+	if err != nil {
+		return fmt.Errorf("搜索扫码记录失败: %w", err)
+	}
+	if err := q.printRecords(records); err != nil {
+		return err
+	}
+	if !*q.json && total > int64(len(records)) {
+		fmt.Printf("\n共 %d 条，仅显示前 %d 条\n", total, len(records))
+	}
+	return nil
+}
+
+// stats 扫码统计
+func (q *queryCommand) stats(args []string) error {
+	today := q.flags.Bool("today", false, "仅统计今天")
+	q.parse(args)
+	if err := q.open(); err != nil {
+		return err
+	}
+
+	now := time.Now().In(q.location)
+	var from time.Time
+	if *today {
+		from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, q.location)
+	}
+	stats, err := q.barcodes().GetRangeStats(from, now.Add(time.Second))
+	if err != nil {
+		return fmt.Errorf("统计扫码记录失败: %w", err)
+	}
+	if *q.json {
+		return writeJSON(os.Stdout, stats)
+	}
+
+	if *today {
+		fmt.Printf("今日扫码: %d（自 %s）\n\n", stats.Total, from.Format(queryTimeLayout))
+	} else {
+		fmt.Printf("全部扫码: %d\n\n", stats.Total)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tCOUNT")
+	for _, row := range stats.ByType {
+		fmt.Fprintf(w, "%s\t%d\n", valueOr(row.Type, "-"), row.Count)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "DEVICE\tCOUNT")
+	for _, row := range stats.ByDevice {
+		name := row.Name
+		if name == "" && row.DeviceID != nil {
+			name = fmt.Sprintf("#%d", *row.DeviceID)
+		}
+		fmt.Fprintf(w, "%s\t%d\n", valueOr(name, "-"), row.Count)
+	}
+	return w.Flush()
+}
+
+// devices 设备列表
+func (q *queryCommand) devices(args []string) error {
+	q.parse(args)
+	if err := q.open(); err != nil {
+		return err
+	}
+
+	var devices []*models.Device
+	page := 1
+	for {
+		batch, total, err := service.NewDeviceService(q.db.DB, "", q.logger).GetDevices(page, 100, "", "", "")
+		if err != nil {
+			return fmt.Errorf("查询设备失败: %w", err)
+		}
+		devices = append(devices, batch...)
+		if len(batch) == 0 || int64(len(devices)) >= total {
+			break
+		}
+		page++
+	}
+	if *q.json {
+		return writeJSON(os.Stdout, devices)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPUBLIC ID\tNAME\tTYPE\tSERIAL\tSTATION\tSTATUS\tACTIVE\tLAST SEEN")
+	for _, device := range devices {
+		lastSeen := "-"
+		if device.LastSeen != nil {
+			lastSeen = device.LastSeen.In(q.location).Format(queryTimeLayout)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\n",
+			device.ID, valueOr(device.PublicID, "-"), device.Name, device.Type,
+			valueOr(device.SerialNo, "-"), valueOr(device.Station, "-"), device.Status, device.IsActive, lastSeen)
+	}
+	return w.Flush()
+}
+
+// export 导出日期范围内的扫码记录
+func (q *queryCommand) export(args []string) error {
+	fromFlag := q.flags.String("from", "", "开始日期（含），如 2024-01-01")
+	toFlag := q.flags.String("to", "", "结束日期（含），默认与开始日期相同")
+	out := q.flags.String("out", "", "输出文件，默认输出到标准输出")
+	q.parse(args)
+
+	if *fromFlag == "" {
+		return errors.New("缺少开始日期 -from")
+	}
+	if *toFlag == "" {
+		*toFlag = *fromFlag
+	}
+	if err := q.open(); err != nil {
+		return err
+	}
+	from, err := time.ParseInLocation("2006-01-02", *fromFlag, q.location)
+	if err != nil {
+		return fmt.Errorf("开始日期无效: %s", *fromFlag)
+	}
+	to, err := time.ParseInLocation("2006-01-02", *toFlag, q.location)
+	if err != nil {
+		return fmt.Errorf("结束日期无效: %s", *toFlag)
+	}
+	if to.Before(from) {
+		return errors.New("结束日期早于开始日期")
+	}
+
+	records, err := q.barcodes().GetBarcodeRecordsBetween(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("创建输出文件失败: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *q.json {
+		err = writeJSON(w, records)
+	} else {
+		err = writeRecordsCSV(w, records, q.location)
+	}
+	if err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	if *out != "" {
+		fmt.Printf("已导出 %d 条扫码记录: %s\n", len(records), *out)
+	}
+	return nil
+}
+
+// printRecords 以表格或JSON输出扫码记录
+func (q *queryCommand) printRecords(records []*models.BarcodeRecord) error {
+	if *q.json {
+		return writeJSON(os.Stdout, records)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tTYPE\tSTATUS\tDEVICE\tCONTENT")
+	for _, record := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			record.ID, record.CreatedAt.In(q.location).Format(queryTimeLayout), valueOr(record.Type, "-"),
+			record.Status, deviceName(record), record.Content)
+	}
+	return w.Flush()
+}
+
+// writeRecordsCSV 以CSV输出扫码记录，时间按 loc 时区输出
+func writeRecordsCSV(w io.Writer, records []*models.BarcodeRecord, loc *time.Location) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "public_id", "created_at", "content", "type", "status", "message", "device_id", "device", "duration_ms"})
+	for _, record := range records {
+		deviceID := ""
+		if record.DeviceID != nil {
+			deviceID = strconv.FormatUint(uint64(*record.DeviceID), 10)
+		}
+		cw.Write([]string{
+			strconv.FormatUint(uint64(record.ID), 10),
+			record.PublicID,
+			record.CreatedAt.In(loc).Format(time.RFC3339),
+			record.Content,
+			record.Type,
+			record.Status,
+			record.Message,
+			deviceID,
+			deviceName(record),
+			strconv.FormatInt(record.DurationMS, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON 以缩进JSON输出
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// deviceName 记录所属设备名称，设备不存在时为空
+func deviceName(record *models.BarcodeRecord) string {
+	if record.Device != nil {
+		return record.Device.Name
+	}
+	return ""
+}
+
+// valueOr 值为空时返回默认值
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
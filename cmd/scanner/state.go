@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/clone"
+	"userclient/internal/config"
+	"userclient/internal/database"
+)
+
+// runExportState 导出工位状态：scanner export-state [-config 路径] [-out 文件] [-include-records]
+func runExportState(args []string) error {
+	flags := flag.NewFlagSet("export-state", flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "配置文件路径")
+	output := flags.String("out", fmt.Sprintf("station-state-%s.tar.gz", time.Now().Format("20060102-150405")), "输出文件")
+	includeRecords := flags.Bool("include-records", false, "附带完整的扫码记录数据库")
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+
+	// 只读打开，服务运行期间也可导出
+	db, err := database.OpenReadOnly(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	manifest, err := clone.Export(f, clone.ExportOptions{
+		ConfigPath:     *configPath,
+		Config:         cfg,
+		DB:             db,
+		IncludeRecords: *includeRecords,
+	})
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("写入输出文件失败: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+
+	fmt.Printf("工位状态已导出: %s\n", *output)
+	fmt.Printf("系统配置 %d 项，设备 %d 台，扫码枪配置档案 %d 个，分类规则 %d 条",
+		manifest.Counts["configurations.json"], manifest.Counts["devices.json"],
+		manifest.Counts["profiles.json"], manifest.Counts["rules.json"])
+	if manifest.IncludeRecords {
+		fmt.Printf("，扫码记录 %d 条", manifest.Counts["records.db"])
+	}
+	fmt.Println()
+	if len(manifest.Secrets) > 0 || len(manifest.StoredSecrets) > 0 {
+		fmt.Printf("以下密钥未导出: %s\n", strings.Join(append(manifest.Secrets, manifest.StoredSecrets...), ", "))
+	}
+	fmt.Println("API令牌未导出（附带扫码记录数据库时除外），需在新工位重新签发")
+	return nil
+}
+
+// runImportState 导入工位状态：scanner import-state [-config 路径] [-dry-run] [-yes] <导出包>
+func runImportState(args []string) error {
+	flags := flag.NewFlagSet("import-state", flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "本机配置文件路径")
+	input := flags.String("in", "", "导出包，也可作为位置参数")
+	dryRun := flags.Bool("dry-run", false, "只列出导入计划，不做任何修改")
+	yes := flags.Bool("yes", false, "本机已存在且内容不同的条目全部覆盖，不逐项确认")
+	flags.Parse(args)
+	if *input == "" && flags.NArg() > 0 {
+		*input = flags.Arg(0)
+	}
+	if *input == "" {
+		return errors.New("缺少导出包，用法: scanner import-state [-config 路径] [-dry-run] [-yes] <导出包>")
+	}
+
+	// 导入会替换配置文件及数据库，须先停止本机服务
+	if cfg, err := config.Load(*configPath); err == nil && fetchStatus(cfg) != nil && !*dryRun {
+		return errors.New("本机服务正在运行，请先停止服务再导入")
+	}
+
+	bundle, err := clone.Open(*input)
+	if err != nil {
+		return err
+	}
+	defer bundle.Close()
+
+	manifest := bundle.Manifest
+	fmt.Printf("导出包: 工位 %s（%s），程序版本 %s，数据库结构版本 %d，导出于 %s\n",
+		valueOr(manifest.Station, "-"), valueOr(manifest.Host, "-"), valueOr(manifest.AppVersion, "-"),
+		manifest.SchemaVersion, manifest.GeneratedAt.Format(queryTimeLayout))
+	if err := bundle.Check(); err != nil {
+		return err
+	}
+	if bundle.NeedsMigration() {
+		fmt.Printf("导出时数据库结构版本 %d 低于本程序的 %d，导入后将执行迁移\n", manifest.SchemaVersion, database.SchemaVersion)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(os.Stderr)
+	opts := clone.ImportOptions{
+		ConfigPath: *configPath,
+		DryRun:     *dryRun,
+		Logger:     logger,
+	}
+	if *yes {
+		opts.Confirm = func(clone.Item) bool { return true }
+	} else {
+		opts.Confirm = newConfirmPrompt(os.Stdin, os.Stdout)
+	}
+
+	result, err := clone.Import(bundle, opts)
+	if err != nil {
+		return err
+	}
+	printImportResult(result)
+	return nil
+}
+
+// newConfirmPrompt 逐项询问是否覆盖本机内容，可选择全部覆盖或全部保留
+func newConfirmPrompt(in io.Reader, out io.Writer) func(clone.Item) bool {
+	reader := bufio.NewReader(in)
+	var all *bool
+	return func(item clone.Item) bool {
+		if all != nil {
+			return *all
+		}
+		for {
+			fmt.Fprintf(out, "%s %s 与本机不同（%s）\n覆盖本机内容？[y]覆盖 [n]保留 [a]全部覆盖 [s]全部保留: ",
+				clone.KindName(item.Kind), item.Key, item.Detail)
+			line, err := reader.ReadString('\n')
+			answer := strings.ToLower(strings.TrimSpace(line))
+			switch {
+			case answer == "y":
+				return true
+			case answer == "n" || (answer == "" && err != nil):
+				// 输入结束时保留本机内容
+				return false
+			case answer == "a" || answer == "s":
+				choice := answer == "a"
+				all = &choice
+				return choice
+			}
+		}
+	}
+}
+
+// printImportResult 输出导入计划或结果
+func printImportResult(result *clone.ImportResult) {
+	actionNames := map[string]string{
+		clone.ActionCreate:    "新增",
+		clone.ActionUpdate:    "覆盖",
+		clone.ActionUnchanged: "相同",
+		clone.ActionSkip:      "保留本机",
+	}
+	if result.DryRun {
+		actionNames[clone.ActionUpdate] = "冲突，导入时确认"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tKEY\tACTION\tDETAIL")
+	for _, item := range result.Items {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", clone.KindName(item.Kind), item.Key, actionNames[item.Action], valueOr(item.Detail, "-"))
+	}
+	w.Flush()
+	fmt.Println()
+
+	if result.AppVersion != "" {
+		fmt.Printf("导出程序版本 %s 与本机不同\n", result.AppVersion)
+	}
+	if result.FromVersion < result.ToVersion {
+		fmt.Printf("数据库结构版本 %d -> %d\n", result.FromVersion, result.ToVersion)
+	}
+	for _, backup := range result.Backups {
+		fmt.Printf("已备份: %s\n", backup)
+	}
+	if len(result.Secrets) > 0 {
+		fmt.Printf("以下密钥未随导出包迁移，请在本机重新填写: %s\n", strings.Join(result.Secrets, ", "))
+	}
+	if result.DryRun {
+		fmt.Println("演练模式，未做任何修改")
+		return
+	}
+	fmt.Println("导入完成")
+	for _, item := range result.Items {
+		if item.Kind == clone.KindConfigFile && (item.Action == clone.ActionCreate || item.Action == clone.ActionUpdate) {
+			fmt.Println("配置文件中的签名密钥（security.jwt_secret）已为本机重新生成")
+		}
+	}
+	fmt.Println("API令牌未随配置迁移，需重新签发（已随扫码记录数据库迁移的除外）")
+}
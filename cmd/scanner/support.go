@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/support"
+)
+
+// runSupportBundle 生成诊断包：scanner support-bundle [-config 路径] [-o 输出文件] [-include-barcodes]
+func runSupportBundle(args []string) error {
+	flags := flag.NewFlagSet("support-bundle", flag.ExitOnError)
+	configPath := flags.String("config", "configs/config.yaml", "配置文件路径")
+	output := flags.String("o", fmt.Sprintf("support-bundle-%s.zip", time.Now().Format("20060102-150405")), "输出文件")
+	includeBarcodes := flags.Bool("include-barcodes", false, "包含扫码内容（默认隐藏）")
+	flags.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return fmt.Errorf("打开数据库失败: %w", err)
+	}
+	defer db.Close()
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	defer f.Close()
+
+	opts := support.Options{
+		IncludeBarcodes: *includeBarcodes,
+		Status:          fetchStatus(cfg),
+		GeneratedBy:     "cli",
+		Logger:          logrus.StandardLogger(),
+	}
+	if err := support.Write(f, cfg, db.DB, opts); err != nil {
+		return err
+	}
+
+	fmt.Printf("诊断包已生成: %s\n", *output)
+	return nil
+}
+
+// fetchStatus 从运行中的服务获取状态快照，服务未运行时返回nil
+func fetchStatus(cfg *config.Config) interface{} {
+	host := cfg.Server.Host
+	if host == "" || host == "0.0.0.0" {
+		host = "localhost"
+	}
+	url := fmt.Sprintf("http://%s/api/status", net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port)))
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil
+	}
+	if cfg.Security.APIKey != "" {
+		req.Header.Set("X-API-Key", cfg.Security.APIKey)
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	var status map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil
+	}
+	return status
+}
@@ -0,0 +1,256 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+	"userclient/internal/websocket"
+)
+
+// 重连退避时间
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// ErrRejected 服务拒绝代理连接（令牌错误或已有新的代理），不再重连
+var ErrRejected = errors.New("服务拒绝代理连接")
+
+// ClientOptions 代理客户端选项
+type ClientOptions struct {
+	URL       string // 服务的代理连接地址，如 ws://127.0.0.1:8080/api/agent/connect
+	Token     string // 与服务共享的令牌（agent.token）
+	Hello     Hello
+	QueueSize int // 断线期间缓存的扫码条数，超出时丢弃最早的
+	Logger    *logrus.Logger
+}
+
+// Client 键盘钩子代理的客户端，实现 scanner.KeyedBarcodeHandler，组装出的扫码经连接转交给服务
+//
+// 断线期间扫码缓存在内存中，重连后按顺序补发；维护模式下服务通知暂停，暂停期间的扫码直接丢弃。
+type Client struct {
+	opts     ClientOptions
+	onConfig func(config.ScannerConfig)
+
+	mu      sync.Mutex
+	pending []Scan
+	paused  bool
+	dropped uint64
+	notify  chan struct{}
+}
+
+// NewClient 创建代理客户端
+func NewClient(opts ClientOptions) *Client {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	return &Client{opts: opts, notify: make(chan struct{}, 1)}
+}
+
+// OnConfig 设置收到扫码参数时的回调，连接后及服务端参数变化时调用
+func (c *Client) OnConfig(fn func(config.ScannerConfig)) {
+	c.onConfig = fn
+}
+
+// HandleBarcode 转交条码
+func (c *Client) HandleBarcode(content string) error {
+	return c.HandleKeyedBarcode(content, 0, nil)
+}
+
+// HandleKeyedBarcode 转交键盘钩子组装出的条码及按键时间信息
+func (c *Client) HandleKeyedBarcode(content string, duration time.Duration, timings []service.KeyTiming) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		c.opts.Logger.WithField("barcode", content).Warn("服务处于维护模式，扫码已忽略")
+		return nil
+	}
+	if len(c.pending) >= c.opts.QueueSize {
+		c.pending = c.pending[1:]
+		c.dropped++
+		c.opts.Logger.WithField("dropped", c.dropped).Warn("与服务断开时间过长，丢弃最早缓存的扫码")
+	}
+	c.pending = append(c.pending, Scan{
+		Content:   content,
+		ScannedAt: time.Now(),
+		Duration:  duration,
+		Timings:   timings,
+	})
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run 连接服务并在断开后自动重连，ctx 取消或服务拒绝连接时返回
+func (c *Client) Run(ctx context.Context) error {
+	backoff := minBackoff
+	for {
+		connected, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrRejected) {
+			return err
+		}
+		if connected {
+			backoff = minBackoff
+		}
+		c.opts.Logger.WithError(err).WithField("retry_in", backoff).Warn("与服务的连接已断开，稍后重连")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// session 建立一次连接并收发消息直到断开，connected 表示服务已接受连接（收到过服务的推送）
+func (c *Client) session(ctx context.Context) (connected bool, err error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.opts.Token)
+	dialer := gorilla.Dialer{HandshakeTimeout: 10 * time.Second}
+	ws, resp, err := dialer.DialContext(ctx, c.opts.URL, header)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return false, fmt.Errorf("%w: HTTP %d", ErrRejected, resp.StatusCode)
+		}
+		return false, fmt.Errorf("连接服务失败: %w", err)
+	}
+	defer ws.Close()
+
+	hello, err := newMessage(TypeHello, c.opts.Hello)
+	if err != nil {
+		return false, err
+	}
+	ws.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := ws.WriteMessage(gorilla.TextMessage, hello); err != nil {
+		// 服务可能已以关闭码拒绝连接，读取关闭帧以决定是否重连
+		var received atomic.Bool
+		ws.SetReadDeadline(time.Now().Add(writeWait))
+		if readErr := c.readLoop(ws, &received); errors.Is(readErr, ErrRejected) {
+			return false, readErr
+		}
+		return false, fmt.Errorf("发送 hello 失败: %w", err)
+	}
+
+	// 读取服务的推送，读到错误后结束发送
+	var received atomic.Bool
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- c.readLoop(ws, &received)
+	}()
+
+	for {
+		if err := c.flush(ws); err != nil {
+			return received.Load(), err
+		}
+		select {
+		case <-c.notify:
+		case err := <-readErr:
+			return received.Load(), err
+		case <-ctx.Done():
+			deadline := time.Now().Add(writeWait)
+			ws.WriteControl(gorilla.CloseMessage, gorilla.FormatCloseMessage(gorilla.CloseNormalClosure, "代理退出"), deadline)
+			return true, nil
+		}
+	}
+}
+
+// readLoop 处理服务推送的扫码参数及暂停/恢复
+func (c *Client) readLoop(ws *gorilla.Conn, received *atomic.Bool) error {
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPingHandler(func(data string) error {
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		return ws.WriteControl(gorilla.PongMessage, []byte(data), time.Now().Add(writeWait))
+	})
+	// 服务拒绝连接时发送关闭帧后立即断开，回复关闭帧失败不能掩盖关闭码
+	ws.SetCloseHandler(func(code int, text string) error {
+		ws.WriteControl(gorilla.CloseMessage, gorilla.FormatCloseMessage(code, ""), time.Now().Add(writeWait))
+		return nil
+	})
+
+	for {
+		var msg Message
+		if err := ws.ReadJSON(&msg); err != nil {
+			var closeErr *gorilla.CloseError
+			if errors.As(err, &closeErr) {
+				switch closeErr.Code {
+				case websocket.CloseUnauthorized, websocket.ClosePolicyViolation, websocket.CloseKicked:
+					return fmt.Errorf("%w: %s", ErrRejected, closeErr.Text)
+				}
+			}
+			return err
+		}
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		received.Store(true)
+
+		switch msg.Type {
+		case TypeConfig:
+			var cfg config.ScannerConfig
+			if err := json.Unmarshal(msg.Data, &cfg); err != nil {
+				c.opts.Logger.WithError(err).Warn("服务推送的扫码参数无效")
+				continue
+			}
+			if c.onConfig != nil {
+				c.onConfig(cfg)
+			}
+		case TypePause, TypeResume:
+			paused := msg.Type == TypePause
+			c.mu.Lock()
+			changed := c.paused != paused
+			c.paused = paused
+			c.mu.Unlock()
+			if changed && paused {
+				c.opts.Logger.Warn("服务进入维护模式，暂停转交扫码")
+			} else if changed {
+				c.opts.Logger.Info("服务退出维护模式，恢复转交扫码")
+			}
+		}
+	}
+}
+
+// flush 按顺序发送缓存的扫码，发送失败的扫码留待重连后补发
+func (c *Client) flush(ws *gorilla.Conn) error {
+	for {
+		c.mu.Lock()
+		if len(c.pending) == 0 {
+			c.mu.Unlock()
+			return nil
+		}
+		scan := c.pending[0]
+		c.mu.Unlock()
+
+		data, err := newMessage(TypeScan, scan)
+		if err != nil {
+			return err
+		}
+		ws.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := ws.WriteMessage(gorilla.TextMessage, data); err != nil {
+			return fmt.Errorf("转交扫码失败: %w", err)
+		}
+
+		c.mu.Lock()
+		// 缓存满时最早的扫码可能已被丢弃，只移除刚发送的这一条
+		if len(c.pending) > 0 && c.pending[0].ScannedAt.Equal(scan.ScannedAt) && c.pending[0].Content == scan.Content {
+			c.pending = c.pending[1:]
+		}
+		c.mu.Unlock()
+	}
+}
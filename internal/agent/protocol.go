@@ -0,0 +1,63 @@
+// Package agent 键盘钩子代理与服务之间的连接
+//
+// 服务以系统服务运行时没有交互式桌面，无法安装低级键盘钩子。此时由登录用户会话中的
+// scanner-agent 安装钩子，经本机 WebSocket 把组装好的扫码转交给服务；服务把扫码参数
+// 及维护模式的暂停/恢复推送给代理。双方以 JSON 文本消息通信：
+//
+//	代理 → 服务: hello（连接后首条消息）、scan
+//	服务 → 代理: config（连接后及扫码参数变化时）、pause、resume
+package agent
+
+import (
+	"encoding/json"
+	"time"
+
+	"userclient/internal/service"
+)
+
+// ConnectPath 代理连接的接口路径
+const ConnectPath = "/api/agent/connect"
+
+// 消息类型
+const (
+	TypeHello  = "hello"
+	TypeScan   = "scan"
+	TypeConfig = "config"
+	TypePause  = "pause"
+	TypeResume = "resume"
+)
+
+// Message 代理与服务之间的消息
+type Message struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Hello 代理连接后发送的自身信息
+type Hello struct {
+	Host    string `json:"host"`
+	User    string `json:"user"`
+	PID     int    `json:"pid"`
+	Version string `json:"version"`
+}
+
+// Scan 代理组装出的一次扫码
+type Scan struct {
+	Content   string              `json:"content"`
+	ScannedAt time.Time           `json:"scanned_at"` // 代理组装出条码的时间，断线期间缓存的扫码按此时间入库
+	Duration  time.Duration       `json:"duration"`   // 扫码耗时（纳秒），未知时为0
+	Timings   []service.KeyTiming `json:"timings,omitempty"`
+}
+
+// newMessage 生成消息，data 为 nil 时不带数据
+func newMessage(msgType string, data interface{}) ([]byte, error) {
+	msg := Message{Type: msgType}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data = raw
+	}
+	return json.Marshal(msg)
+}
@@ -0,0 +1,328 @@
+package agent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+	"userclient/internal/websocket"
+)
+
+// 连接保活参数，代理与服务在同一台机器上，断线应尽快发现
+const (
+	writeWait    = 5 * time.Second
+	pongWait     = 30 * time.Second
+	pingPeriod   = 10 * time.Second
+	helloWait    = 10 * time.Second
+	maxScanBytes = 64 << 10
+)
+
+// Handler 代理转交的扫码的处理接口
+type Handler interface {
+	HandleAgentBarcode(content string, scan service.ScanInfo, timings []service.KeyTiming) error
+}
+
+// Pauser 维护模式状态，维护中代理暂停转交扫码
+type Pauser interface {
+	IsActive() bool
+}
+
+// Status 代理连接状态
+type Status struct {
+	State          string     `json:"state"` // connected、disconnected
+	Paused         bool       `json:"paused"`
+	Host           string     `json:"host,omitempty"`
+	User           string     `json:"user,omitempty"`
+	PID            int        `json:"pid,omitempty"`
+	Version        string     `json:"version,omitempty"`
+	Remote         string     `json:"remote,omitempty"`
+	ConnectedAt    *time.Time `json:"connected_at,omitempty"`
+	DisconnectedAt *time.Time `json:"disconnected_at,omitempty"`
+	LastScanAt     *time.Time `json:"last_scan_at,omitempty"`
+	Scans          uint64     `json:"scans"`       // 本次连接转交的扫码数
+	Connections    uint64     `json:"connections"` // 启动以来的连接次数
+	Rejected       uint64     `json:"rejected"`    // 启动以来认证失败被拒绝的连接数
+}
+
+// Server 接受键盘钩子代理的连接，同一时间只保留一个代理，新连接替换旧连接
+type Server struct {
+	token    string
+	handler  Handler
+	pauser   Pauser
+	logger   *logrus.Logger
+	upgrader gorilla.Upgrader
+
+	mu      sync.Mutex
+	conn    *connection
+	scanner config.ScannerConfig
+	status  Status
+}
+
+// connection 一个代理连接
+type connection struct {
+	ws   *gorilla.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+// NewServer 创建代理连接服务，scanner 为连接后推送给代理的扫码参数
+func NewServer(cfg config.AgentConfig, scanner config.ScannerConfig, handler Handler, pauser Pauser, logger *logrus.Logger) *Server {
+	return &Server{
+		token:   cfg.Token,
+		handler: handler,
+		pauser:  pauser,
+		logger:  logger,
+		upgrader: gorilla.Upgrader{
+			// 代理不是浏览器，不会带 Origin；带 Origin 的请求来自网页，一律拒绝
+			CheckOrigin: func(r *http.Request) bool { return r.Header.Get("Origin") == "" },
+		},
+		scanner: scanner,
+		status:  Status{State: "disconnected"},
+	}
+}
+
+// Authenticate 校验代理提供的共享令牌，失败时计数
+func (s *Server) Authenticate(credential string) bool {
+	if s.token != "" && credential != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(s.token)) == 1 {
+		return true
+	}
+	s.mu.Lock()
+	s.status.Rejected++
+	s.mu.Unlock()
+	return false
+}
+
+// Status 当前代理连接状态
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Paused = s.paused()
+	return status
+}
+
+// Connected 是否有代理在线
+func (s *Server) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+// SetScannerConfig 更新扫码参数并推送给在线的代理，代理以新参数重新安装键盘钩子；参数未变化时返回 false
+func (s *Server) SetScannerConfig(scanner config.ScannerConfig) bool {
+	s.mu.Lock()
+	if reflect.DeepEqual(s.scanner, scanner) {
+		s.mu.Unlock()
+		return false
+	}
+	s.scanner = scanner
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		s.push(conn, TypeConfig, scanner)
+	}
+	return true
+}
+
+// SyncPause 维护模式变化后通知在线的代理暂停或恢复转交扫码
+func (s *Server) SyncPause() {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		s.push(conn, s.pauseType(), nil)
+	}
+}
+
+// Close 断开代理，代理退避后重连
+func (s *Server) Close() {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		conn.close(websocket.CloseShutdown, "服务停止")
+	}
+}
+
+// HandleConnection 处理已通过认证的代理连接，返回时连接已断开
+func (s *Server) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.WithError(err).Warn("键盘钩子代理连接升级失败")
+		return
+	}
+	ws.SetReadLimit(maxScanBytes)
+
+	// 连接后首条消息须为 hello
+	ws.SetReadDeadline(time.Now().Add(helloWait))
+	var msg Message
+	var hello Hello
+	if err := ws.ReadJSON(&msg); err != nil || msg.Type != TypeHello || json.Unmarshal(msg.Data, &hello) != nil {
+		deadline := time.Now().Add(writeWait)
+		ws.WriteControl(gorilla.CloseMessage, gorilla.FormatCloseMessage(websocket.ClosePolicyViolation, "连接后须先发送 hello"), deadline)
+		ws.Close()
+		return
+	}
+
+	conn := &connection{ws: ws, send: make(chan []byte, 64), done: make(chan struct{})}
+	now := time.Now()
+	s.mu.Lock()
+	previous := s.conn
+	s.conn = conn
+	s.status = Status{
+		State:       "connected",
+		Host:        hello.Host,
+		User:        hello.User,
+		PID:         hello.PID,
+		Version:     hello.Version,
+		Remote:      r.RemoteAddr,
+		ConnectedAt: &now,
+		Connections: s.status.Connections + 1,
+		Rejected:    s.status.Rejected,
+	}
+	scanner := s.scanner
+	s.mu.Unlock()
+
+	// 新的代理（如用户重新登录后启动的代理）替换旧连接，旧代理不再重连
+	if previous != nil {
+		previous.close(websocket.CloseKicked, "已有新的代理连接")
+	}
+	s.logger.WithFields(logrus.Fields{
+		"host": hello.Host,
+		"user": hello.User,
+		"pid":  hello.PID,
+	}).Info("键盘钩子代理已连接")
+
+	go s.writePump(conn)
+	s.push(conn, TypeConfig, scanner)
+	s.push(conn, s.pauseType(), nil)
+	s.readPump(conn)
+
+	conn.close(websocket.CloseShutdown, "")
+	s.mu.Lock()
+	if s.conn == conn {
+		s.conn = nil
+		disconnected := time.Now()
+		s.status.State = "disconnected"
+		s.status.DisconnectedAt = &disconnected
+	}
+	s.mu.Unlock()
+	s.logger.WithField("pid", hello.PID).Warn("键盘钩子代理已断开")
+}
+
+// readPump 读取代理转交的扫码，连接断开后返回
+func (s *Server) readPump(conn *connection) {
+	conn.ws.SetReadDeadline(time.Now().Add(pongWait))
+	conn.ws.SetPongHandler(func(string) error {
+		return conn.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg Message
+		if err := conn.ws.ReadJSON(&msg); err != nil {
+			var closeErr *gorilla.CloseError
+			if !errors.As(err, &closeErr) {
+				s.logger.WithError(err).Debug("读取键盘钩子代理消息失败")
+			}
+			return
+		}
+		conn.ws.SetReadDeadline(time.Now().Add(pongWait))
+		if msg.Type != TypeScan {
+			continue
+		}
+
+		var scan Scan
+		if err := json.Unmarshal(msg.Data, &scan); err != nil || scan.Content == "" {
+			s.logger.WithError(err).Warn("键盘钩子代理转交的扫码无效")
+			continue
+		}
+		s.mu.Lock()
+		if s.conn == conn {
+			now := time.Now()
+			s.status.Scans++
+			s.status.LastScanAt = &now
+		}
+		s.mu.Unlock()
+
+		// 处理失败已通过推送的状态告知前端
+		s.handler.HandleAgentBarcode(scan.Content, service.ScanInfo{
+			Duration:  scan.Duration,
+			ScannedAt: scan.ScannedAt,
+		}, scan.Timings)
+	}
+}
+
+// writePump 发送推送给代理的消息及保活 ping
+func (s *Server) writePump(conn *connection) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-conn.send:
+			conn.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.ws.WriteMessage(gorilla.TextMessage, data); err != nil {
+				conn.close(websocket.CloseShutdown, "")
+				return
+			}
+		case <-ticker.C:
+			if err := conn.ws.WriteControl(gorilla.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				conn.close(websocket.CloseShutdown, "")
+				return
+			}
+		case <-conn.done:
+			return
+		}
+	}
+}
+
+// push 向代理推送消息，发送缓冲区已满时断开，代理重连后重新获取参数及暂停状态
+func (s *Server) push(conn *connection, msgType string, data interface{}) {
+	payload, err := newMessage(msgType, data)
+	if err != nil {
+		s.logger.WithError(err).Error("生成键盘钩子代理消息失败")
+		return
+	}
+	select {
+	case conn.send <- payload:
+	case <-conn.done:
+	default:
+		s.logger.Warn("键盘钩子代理接收过慢，断开连接")
+		conn.close(websocket.CloseTooSlow, "发送缓冲区已满")
+	}
+}
+
+// pauseType 当前维护状态对应的消息类型
+func (s *Server) pauseType() string {
+	if s.paused() {
+		return TypePause
+	}
+	return TypeResume
+}
+
+// paused 是否处于维护模式
+func (s *Server) paused() bool {
+	return s.pauser != nil && s.pauser.IsActive()
+}
+
+// close 以指定关闭码关闭连接，可重复调用
+func (c *connection) close(code int, reason string) {
+	c.once.Do(func() {
+		close(c.done)
+		if code != 0 {
+			deadline := time.Now().Add(writeWait)
+			c.ws.WriteControl(gorilla.CloseMessage, gorilla.FormatCloseMessage(code, reason), deadline)
+		}
+		c.ws.Close()
+	})
+}
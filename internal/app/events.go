@@ -0,0 +1,201 @@
+package app
+
+import (
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+	"userclient/internal/service"
+	"userclient/internal/websocket"
+)
+
+// registerEvents 登记各组件推送的事件类型，新增广播时需同步在此登记
+func registerEvents(catalog *websocket.EventCatalog) {
+	startedAt := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	endsAt := startedAt.Add(30 * time.Minute)
+
+	catalog.Register("maintenance", "维护模式开始/结束", service.MaintenanceEvent{
+		Action: "start",
+		State: service.MaintenanceState{
+			Active:    true,
+			Reason:    "更换标签",
+			StartedAt: &startedAt,
+			EndsAt:    &endsAt,
+		},
+	})
+
+	catalog.Register("scanner_capture", "扫码采集暂停/恢复，暂停期间按键原样传给前台程序、不产生扫码记录", service.CaptureEvent{
+		Action: "pause",
+		Actor:  "line-dashboard",
+		State: service.CaptureState{
+			Available: true,
+			Paused:    true,
+			PausedAt:  &startedAt,
+			PausedBy:  "line-dashboard",
+			Reason:    "录入工单备注",
+		},
+	})
+
+	catalog.Register("sequence_gap", "检测到序列号断号", service.SequenceGapEvent{
+		Rule:         "serial",
+		Prefix:       "SN",
+		DeviceID:     1,
+		FromValue:    101,
+		ToValue:      103,
+		MissingCount: 1,
+		Missing:      []string{"SN000102"},
+	})
+
+	deviceID := uint(1)
+	catalog.Register("barcode_image", "扫码记录的条码图片已上传", service.BarcodeImageEvent{
+		RecordID:    42,
+		DeviceID:    &deviceID,
+		ContentType: "image/jpeg",
+		Size:        48213,
+		URL:         "/api/barcodes/42/image",
+	})
+
+	lastScanAt := startedAt.Add(-42 * time.Second)
+	catalog.Register("idle_tick", "距上次扫码时长，按固定间隔推送", service.IdleStatus{
+		Tracking:    true,
+		Shift:       "白班",
+		TaktSeconds: 60,
+		Station:     service.IdleEntry{LastScanAt: &lastScanAt, IdleSeconds: 42},
+		Devices: []service.IdleEntry{
+			{DeviceID: 1, LastScanAt: &lastScanAt, IdleSeconds: 42},
+		},
+	})
+
+	catalog.Register("data_reset", "数据已重置，看板应清空本地缓存的扫码", service.DataResetEvent{
+		Scope:   service.ResetScopeDemoSeed,
+		Counts:  map[string]int64{"barcode_records": 1520, "sequence_states": 3, "demo_records": 8},
+		ResetAt: startedAt,
+	})
+
+	catalog.Register("config_changed", "系统配置已修改，restart_required 为 true 时需重启后生效", service.ConfigChange{
+		Key:             "scanner.min_length",
+		OldValue:        "3",
+		Value:           "4",
+		RestartRequired: false,
+	})
+
+	catalog.Register("device_command", "网络扫码枪命令执行结果，status 为 sent、no_response（如触发后未读到条码）或 failed", service.CommandResult{
+		DeviceID:   1,
+		DeviceName: "出口固定扫码枪",
+		Name:       "trigger",
+		Status:     service.CommandStatusSent,
+		Response:   "SN000102",
+		DurationMS: 184,
+		Actor:      "line-dashboard",
+		At:         startedAt,
+	})
+
+	catalog.Register("scanner_drift", "扫码参数超出安全范围或已恢复（warnings 为空），trigger 为 startup 或 config_change", service.ScannerDriftEvent{
+		Trigger: "config_change",
+		Warnings: []service.ScannerWarning{{
+			Key:     "scanner.min_length",
+			Value:   "1",
+			Source:  service.ScannerSettingFromDatabase,
+			Min:     2,
+			Max:     100,
+			Message: "scanner.min_length=1 超出安全范围 2~100，过小时零散按键会被当作条码",
+		}},
+	})
+
+	catalog.Register("config_rollback", "配置文件热加载失败（校验不通过或生效失败），已回滚到最近可用配置", config.RollbackEvent{
+		Rejected:   config.Version{Version: 3, Checksum: "5f2c1a9e0b7d", LoadedAt: endsAt},
+		RestoredTo: config.Version{Version: 2, Checksum: "a81d3c4f6e20", LoadedAt: startedAt},
+		Rejections: []config.Rejection{{
+			Key:    "server.read_timeout",
+			Value:  "30x",
+			Reason: `error decoding 'server.read_timeout': time: unknown unit "x" in duration "30x"`,
+		}},
+		At: endsAt,
+	})
+
+	catalog.Register("goal_progress", "产量目标进度达到配置的百分比（goals.milestones），completed 为 true 表示已达成", service.GoalProgressEvent{
+		GoalID:    1,
+		Name:      "二线包装",
+		Scope:     "station",
+		Day:       "2024-01-01",
+		Count:     2000,
+		Target:    4000,
+		Percent:   50,
+		Milestone: 50,
+	})
+
+	catalog.Register("day_closing", "生产日日结（action=close，附带汇总）或重新开放（action=reopen），已日结生产日的扫码记录不允许修改", service.DayClosingEvent{
+		Action: service.ClosingActionClose,
+		Day:    "2024-01-01",
+		Closed: true,
+		By:     "admin",
+		At:     endsAt,
+		Summary: &models.DailySummary{
+			Day:        "2024-01-01",
+			Station:    "line-2",
+			From:       startedAt,
+			To:         startedAt.Add(24 * time.Hour),
+			Closed:     true,
+			Total:      3980,
+			Invalid:    12,
+			Duplicates: 35,
+			Breakdown: models.DailyBreakdown{
+				ByType:     []models.NamedCount{{Name: "EAN-13", Count: 3980}},
+				ByDevice:   []models.DeviceTotal{{DeviceID: &deviceID, Name: "一号扫码枪", Count: 3980}},
+				ByOperator: []models.NamedCount{{Name: "A017", Count: 3980}},
+				Goals:      []models.DailyGoal{{GoalID: 1, Name: "二线包装", Target: 4000, Count: 3980, Percent: 99.5}},
+				CarriedOver: []models.CarriedSession{{
+					Rule:      "serial",
+					Prefix:    "SN",
+					DeviceID:  1,
+					LastValue: 104532,
+					Note:      "日结时仍在跟踪，转入 2024-01-02 继续",
+				}},
+			},
+			ClosedBy: "admin",
+			ClosedAt: endsAt,
+		},
+	})
+
+	catalog.Register("alert", "运行告警（如事件文件输出暂停、设备健康评分过低、班次内长时间无扫码、误扫扫码枪设置码、产量目标达成、键盘钩子疑似漏键、热备接管、数据质量退化、采集来源不可用、键盘钩子失效后重新安装）", service.AlertEvent{
+		Source:  "file_sink",
+		Level:   "error",
+		Message: "磁盘已满，文件事件输出已暂停",
+	})
+
+	catalog.Register("standby", "热备角色变化，role 为 primary（主机采集）、standby（待命不采集）或 taking_over（备机已接管）", service.StandbyEvent{
+		Role:     service.StandbyStateTakingOver,
+		Previous: service.StandbyStateStandby,
+		Instance: "line2-spare",
+		Reason:   "主机持续不健康 30s: 主机不可达: dial tcp 192.168.1.10:8080: connect: connection refused",
+		At:       endsAt,
+	})
+
+	catalog.Register("recovery", "启动时发现上次运行非正常退出（断电、强制结束进程）后的恢复结果，同时写入系统日志并在 /api/status 的 recovery 中保留", service.RecoverySummary{
+		Unclean:           true,
+		PreviousStartedAt: &startedAt,
+		LastScanAt:        &endsAt,
+		RecordsRecovered:  1286,
+		SessionsResumed:   2,
+		OutboxPending:     14,
+		MaintenanceActive: true,
+		RecoveredAt:       endsAt,
+	})
+
+	catalog.Register("barcode_replay", "历史扫码记录回放（仅回放任务指定 websocket 输出端时推送），载荷与出站事件相同，带 replay 标记", service.OutboxEnvelope{
+		EventID:   "barcode:01HN8Z5K3QW6D4E2R9T7Y5V3G1",
+		Type:      "barcode",
+		Timestamp: startedAt,
+		Data: models.BarcodeRecord{
+			ID:       42,
+			PublicID: "01HN8Z5K3QW6D4E2R9T7Y5V3G1",
+			Content:  "6901234567892",
+			Length:   13,
+			Type:     "EAN-13",
+			Status:   "success",
+			DeviceID: &deviceID,
+		},
+		Replay:    true,
+		ReplayJob: 3,
+	})
+}
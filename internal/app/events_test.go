@@ -0,0 +1,111 @@
+package app
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/websocket"
+)
+
+// broadcastCalls 通过 Hub 广播事件的方法，第一个参数为事件类型
+var broadcastCalls = map[string]bool{"BroadcastMessage": true, "BroadcastDeviceMessage": true}
+
+// broadcastTypes 扫描模块源码中广播的事件类型，返回类型及首次出现的位置；
+// websocket 包内另外收集直接构造的 Message{Type: ...}
+func broadcastTypes(t *testing.T, root string) map[string]string {
+	t.Helper()
+	fset := token.NewFileSet()
+	types := make(map[string]string)
+	record := func(expr ast.Expr, inHub bool) {
+		lit, ok := expr.(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			// Hub 自身的包装方法转发调用方传入的类型
+			if !inHub {
+				t.Errorf("%s: 事件类型不是字符串常量，无法检查是否已登记", fset.Position(expr.Pos()))
+			}
+			return
+		}
+		value, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, seen := types[value]; !seen {
+			types[value] = fset.Position(lit.Pos()).String()
+		}
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		inHub := filepath.Base(filepath.Dir(path)) == "websocket" && file.Name.Name == "websocket"
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if sel, ok := n.Fun.(*ast.SelectorExpr); ok && broadcastCalls[sel.Sel.Name] && len(n.Args) > 0 {
+					record(n.Args[0], inHub)
+				}
+			case *ast.CompositeLit:
+				if ident, ok := n.Type.(*ast.Ident); ok && inHub && ident.Name == "Message" {
+					for _, elt := range n.Elts {
+						if kv, ok := elt.(*ast.KeyValueExpr); ok {
+							if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Type" {
+								record(kv.Value, inHub)
+							}
+						}
+					}
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types
+}
+
+// TestBroadcastTypesRegistered 源码中广播的每个事件类型都已在事件目录中登记
+func TestBroadcastTypesRegistered(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	hub := websocket.NewHub(&config.WebSocketConfig{}, logger)
+	registerEvents(hub.Catalog())
+
+	types := broadcastTypes(t, filepath.Join("..", ".."))
+	// 防止扫描路径变化后什么也没检查
+	for _, known := range []string{"barcode", "welcome", "alert", "config_changed"} {
+		if _, ok := types[known]; !ok {
+			t.Fatalf("未在源码中找到 %s 事件的广播，扫描结果: %v", known, types)
+		}
+	}
+	for eventType, pos := range types {
+		if !hub.Catalog().Has(eventType) {
+			t.Errorf("%s: 事件类型 %q 未在事件目录中登记", pos, eventType)
+		}
+	}
+}
@@ -2,16 +2,32 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"userclient/internal/agent"
 	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/feedback"
 	"userclient/internal/handlers"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+	"userclient/internal/peer"
 	"userclient/internal/routes"
 	"userclient/internal/scanner"
+	"userclient/internal/scanner/network"
+	"userclient/internal/scanner/serial"
+	"userclient/internal/service"
+	"userclient/internal/sink"
+	"userclient/internal/support"
 	"userclient/internal/websocket"
 )
 
@@ -19,90 +35,1259 @@ import (
 type Manager struct {
 	config          *config.Config
 	logger          *logrus.Logger
-	hook            *scanner.Hook
+	db              *database.DB
+	secondary       *database.DB
+	replication     *service.ReplicationService
+	maintenance     *service.MaintenanceService
+	fileSink        *sink.FileSink
+	outbox          *service.OutboxService
+	replay          *service.ReplayService
+	backfills       *service.BackfillService
+	distinct        *service.DistinctService
+	health          *service.HealthService
+	images          *service.ImageService
+	forwarder       *peer.Forwarder
+	idle            *service.IdleService
+	heartbeat       *service.HeartbeatService
+	counters        *service.CounterService
+	feedback        *feedback.Player // 扫码提示音，仅API模式或平台不支持时为nil
+	vacuum          *service.VacuumService
+	preferences     *service.PreferenceService
+	simulator       *service.SimulatorService
+	history         *service.MetricsHistoryService
+	quality         *service.QualityService
+	recovery        *service.RecoveryService
+	sync            *service.SyncService
+	scannerGuard    *service.ScannerGuard
+	reloader        *config.Reloader
+	hook            scanner.KeyboardSource
+	serial          *serial.Sources
+	network         *network.Source
+	sources         []scanner.ScannerSource // 同时运行的全部采集来源
+	scanQueue       *scanner.Queue          // 采集来源交出的扫码经此缓冲交给条码处理器
+	queueDone       chan struct{}           // 扫码缓冲中的扫码处理完后关闭
+	consuming       bool                    // 已开始处理扫码缓冲，启动失败时为 false，停止时无需等待
+	sourceMu        sync.Mutex
+	sourceErrors    map[string]string // 安装失败的采集来源 -> 错误
+	sourceWG        sync.WaitGroup
+	standby         *service.StandbyService
+	agent           *agent.Server
+	resolveScanner  func(config.ScannerConfig) config.ScannerConfig // 展开扫码枪参数预设
+	scannerMu       sync.Mutex
+	scannerConfig   config.ScannerConfig // 按键采集来源当前使用的扫码参数，运行中修改后与配置文件不同
+	configs         *service.ConfigService
 	hub             *websocket.Hub
 	barcodeHandler  *handlers.BarcodeHandler
+	ingestLimiter   *service.IngestLimiter
+	integrity       *service.IntegrityService
+	operatorStats   *service.OperatorStatsService
 	router          *routes.Router
 	webSocketServer *http.Server
+	startedAt       time.Time
+}
+
+// configPath 配置文件路径
+const configPath = "configs/config.yaml"
+
+// sourceStopTimeout 停止时等待各采集来源的 goroutine 结束的最长时间
+const sourceStopTimeout = 3 * time.Second
+
+// Options 以给定配置创建管理器的选项，全流程测试不读取配置文件时使用
+type Options struct {
+	Config     *config.Config
+	ConfigPath string         // 配置文件路径，热加载及首次运行向导写入配置时使用；为空时不启用二者
+	Logger     *logrus.Logger // 为nil时按默认格式输出到标准错误
+	// Sources 创建附加的采集来源，与键盘钩子、串口及网络扫码枪同时运行，扫码交给 handler（扫码缓冲）
+	Sources func(handler scanner.ScanHandler) []scanner.ScannerSource
 }
 
 // New 创建应用程序管理器实例
 func New() (*Manager, error) {
 	// 加载配置
-	cfg, err := config.Load("configs/config.yaml")
+	cfg, err := config.Load(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("加载配置失败: %w", err)
 	}
+	return NewWithOptions(Options{Config: cfg, ConfigPath: configPath})
+}
+
+// NewWithOptions 以给定配置创建应用程序管理器实例
+func NewWithOptions(opts Options) (*Manager, error) {
+	cfg := opts.Config
 
 	// 初始化日志
-	logger := logrus.New()
-	logger.SetLevel(logrus.InfoLevel)
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrus.New()
+		logger.SetLevel(logrus.InfoLevel)
+		logger.SetFormatter(&logrus.TextFormatter{
+			FullTimestamp: true,
+		})
+	}
+
+	// 保留最近日志用于生成诊断包
+	logBuffer := support.NewLogBuffer(2000)
+	logger.AddHook(logBuffer)
+
+	// 统计分桶、班次及导出使用的时区，数据库中的时间一律为UTC
+	location, err := cfg.App.Location()
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %w", err)
+	}
+
+	// 告警、日结邮件及无法协商语言的请求使用的默认语言；消息目录不完整时缺少的消息退回源语言
+	i18n.SetDefault(cfg.App.Locale)
+	for locale, codes := range i18n.Missing() {
+		logger.WithField("locale", locale).WithField("codes", codes).Warn("消息目录不完整，缺少的消息使用源语言")
+	}
+
+	// 只读模式关闭扫码来源、事件输出及会写入数据库的后台任务
+	if disabled := cfg.ApplyReadOnly(); cfg.App.ReadOnly {
+		logger.WithField("disabled", disabled).Warn("以只读模式运行：数据库只读打开，不接收扫码，修改类接口已禁用")
+	}
+
+	// 初始化数据库
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// 初始化服务
+	configService := service.NewConfigService(db.DB, logger)
+	maintenance := service.NewMaintenanceService(configService, logger)
+	barcodeService := service.NewBarcodeService(db.DB, logger)
+	statsCache := service.NewStatsCache(cfg.API.StatsCacheTTL, location)
+
+	// 功能开关，取值保存在系统配置表中；未设置时已弃用的旧配置项仍生效
+	featureFlags := service.NewFeatureFlags(configService, logger)
+	featureFlags.Deprecated(service.FlagHubCompaction, cfg.WebSocket.Compaction.Enable)
+	if flags := featureFlags.NonDefault(); len(flags) > 0 {
+		fields := logrus.Fields{}
+		for _, flag := range flags {
+			fields[flag.Name] = flag.Enabled
+		}
+		logger.WithFields(fields).Info("以下功能开关不是默认值")
+	}
+
+	tokenService := service.NewTokenService(db.DB, logger)
+	tokenService.SetReadOnly(cfg.App.ReadOnly)
+	sequenceService, err := service.NewSequenceService(db.DB, cfg.Sequence, logger)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化断号检测失败: %w", err)
+	}
+
+	// 本地备份库双写
+	var secondary *database.DB
+	var replicationService *service.ReplicationService
+	if cfg.Database.Secondary.Enable {
+		secondaryCfg := cfg.Database
+		secondaryCfg.DSN = cfg.Database.Secondary.DSN
+		secondary, err = database.New(&secondaryCfg)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化本地备份库失败: %w", err)
+		}
+		if err := secondary.AutoMigrate(); err != nil {
+			secondary.Close()
+			db.Close()
+			return nil, err
+		}
+		replicationService = service.NewReplicationService(db.DB, secondary.DB, cfg.Database.Secondary, logger)
+		barcodeService.SetReplication(replicationService)
+	}
+
+	// 扫码入库后立即失效统计缓存
+	barcodeService.OnRecorded(func(*models.BarcodeRecord) {
+		statsCache.Invalidate()
+	})
+
+	// 持久化计数器：各设备累计、当天及当班扫码数，重启后继续累加；只读模式下不写入
+	var counterService *service.CounterService
+	if !cfg.App.ReadOnly {
+		dayPeriod, shiftPeriod, err := service.CounterPeriods(cfg.Idle.Shifts, location)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化计数器失败: %w", err)
+		}
+		counterService = service.NewCounterService(db.DB, cfg.Counters, logger)
+		counterService.SetPeriod(service.CounterScopeScansDay, dayPeriod)
+		if shiftPeriod != nil {
+			counterService.SetPeriod(service.CounterScopeScansShift, shiftPeriod)
+		}
+		if err := counterService.Load(); err != nil {
+			logger.WithError(err).Warn("恢复计数器失败，计数从0开始")
+		}
+		barcodeService.OnRecorded(counterService.Observe)
+	}
+	barcodeService.OnRecorded(sequenceService.Observe)
+
+	// 每日不同条码数估算
+	distinctService, err := service.NewDistinctService(db.DB, cfg.API.DistinctPrecision, location, logger)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	barcodeService.OnRecorded(distinctService.Observe)
+
+	// 设备健康评分
+	deviceService := service.NewDeviceService(db.DB, cfg.Peers.Station, logger)
+	deviceService.OnChanged(barcodeService.InvalidateDefaultDevice)
+	barcodeService.SetStation(cfg.Peers.Station)
+	barcodeService.SetDevices(deviceService)
+	// 跨设备合并，同一标签被两台设备同时扫到时只保留一条记录
+	var coalescer *service.ScanCoalescer
+	if cfg.Coalesce.Enable {
+		coalescer = service.NewScanCoalescer(cfg.Coalesce, cfg.Peers.Station)
+		coalescer.SetDevices(deviceService)
+		barcodeService.SetCoalescer(coalescer)
+	}
+	if !cfg.App.ReadOnly {
+		if _, err := deviceService.ClaimUnassigned(); err != nil {
+			logger.WithError(err).Warn("认领未分配工位的设备失败")
+		}
+	}
+	var healthService *service.HealthService
+	if cfg.Health.Enable {
+		healthService = service.NewHealthService(db.DB, cfg.Health, logger)
+		barcodeService.OnRecorded(healthService.Observe)
+		barcodeService.OnRejected(healthService.ObserveInvalid)
+		sequenceService.OnGap(healthService.ObserveGap)
+	}
+
+	// 下游系统消费确认，清理旧记录时保留未确认的记录
+	ackService := service.NewAckService(db.DB, cfg.Acks, logger)
+	barcodeService.SetConsumers(cfg.Acks.Consumers)
+
+	// 模拟扫码不计入统计
+	if cfg.Simulator.ExcludeFromStats {
+		barcodeService.SetStatsExcludedSources([]string{models.BarcodeSourceSimulation})
+	}
+
+	// 条码分类规则
+	ruleService := service.NewRuleService(db.DB, barcodeService.Processor(), logger)
+	if err := ruleService.Load(); err != nil {
+		logger.WithError(err).Warn("加载分类规则失败")
+	}
+	if cfg.App.ReadOnly {
+		// 只读数据库不能创建索引，按派生字段查询时退化为全表扫描
+	} else if err := ruleService.EnsureDerivedIndexes(cfg.Classification.IndexedFields); err != nil {
+		logger.WithError(err).Warn("创建派生字段索引失败")
+	}
+
+	// 网址条码可点击的域名白名单，通过配置接口修改后热更新
+	applyLinkAllowList := func(value string) {
+		list, err := service.ParseLinkAllowList(value)
+		if err != nil {
+			logger.WithError(err).Warn("网址域名白名单无效，所有网址按不可点击处理")
+		}
+		barcodeService.Processor().SetLinkAllowList(list)
+	}
+	if config, err := configService.GetConfiguration(service.LinkAllowListKey); err == nil {
+		applyLinkAllowList(config.Value)
+	}
+	configService.OnChange(func(change service.ConfigChange) {
+		if change.Key == service.LinkAllowListKey {
+			applyLinkAllowList(change.Value)
+		}
+	})
+
+	// 产量目标，扫码入库后累加进度
+	var goalService *service.GoalService
+	if cfg.Goals.Enable {
+		goalService, err = service.NewGoalService(db.DB, cfg.Goals, cfg.Idle.Shifts, location, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化产量目标失败: %w", err)
+		}
+		if err := goalService.Load(); err != nil {
+			logger.WithError(err).Warn("加载产量目标失败")
+		}
+		barcodeService.OnRecorded(goalService.Observe)
+	}
+
+	// 数据质量报告，按处理器的校验规则统计不合格扫码，日结导出及邮件中附带当天的报告
+	var qualityService *service.QualityService
+	if cfg.Quality.Enable {
+		qualityService, err = service.NewQualityService(db.DB, barcodeService.Processor(), cfg.Quality, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化数据质量报告失败: %w", err)
+		}
+	}
+
+	// 按操作员的统计，隐私设置同时作用于日结汇总
+	operatorStats := service.NewOperatorStatsService(db.DB, cfg.OperatorStats, cfg.Closing.OperatorField, logger)
+	if err := operatorStats.Load(); err != nil {
+		logger.WithError(err).Warn("核对操作员统计隐私设置失败")
+	}
+
+	// 日结，生产日划分与产量目标相同，已日结生产日的扫码记录不允许修改
+	var closingService *service.ClosingService
+	if cfg.Closing.Enable {
+		closingService, err = service.NewClosingService(db.DB, cfg.Closing, cfg.Idle.Shifts, location, cfg.Peers.Station, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化日结失败: %w", err)
+		}
+		closingService.SetGoals(goalService)
+		closingService.SetSequence(sequenceService)
+		closingService.SetQuality(qualityService)
+		closingService.SetOperatorStats(operatorStats)
+		if err := closingService.Load(); err != nil {
+			logger.WithError(err).Warn("加载日结记录失败")
+		}
+	}
+
+	// 扫码枪配置档案，扫码命中设置码时告警
+	profileService, err := service.NewProfileService(db.DB, cfg.Profiles, logger)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化扫码枪配置档案失败: %w", err)
+	}
+	if err := profileService.Load(); err != nil {
+		logger.WithError(err).Warn("加载扫码枪配置档案失败")
+	}
+	barcodeService.OnRecorded(profileService.Observe)
+
+	// 扫码记录完整性哈希链，入库时封链，数据保留清理前写入检查点
+	var integrityService *service.IntegrityService
+	if cfg.Integrity.Enable {
+		integrityService = service.NewIntegrityService(db.DB, cfg.Integrity, cfg.Peers.Station, logger)
+		barcodeService.SetIntegrity(integrityService)
+	}
+
+	// 演示及培训环境数据重置
+	resetService := service.NewResetService(db.DB, cfg.Admin.ResetTokenTTL, logger)
+	resetService.OnReset(func(event service.DataResetEvent) {
+		statsCache.Invalidate()
+		if event.Scope != service.ResetScopeRecords {
+			if err := sequenceService.Reset("", "", nil); err != nil {
+				logger.WithError(err).Warn("重置序号跟踪状态失败")
+			}
+		}
+		if event.Scope != service.ResetScopeSessions && healthService != nil {
+			healthService.Reset()
+		}
+		if event.Scope != service.ResetScopeSessions {
+			distinctService.Reset()
+		}
+		if event.Scope != service.ResetScopeSessions && replicationService != nil {
+			replicationService.ResetWatermark()
+		}
+		if event.Scope != service.ResetScopeSessions && counterService != nil {
+			for _, scope := range []string{service.CounterScopeScans, service.CounterScopeScansDay, service.CounterScopeScansShift} {
+				if _, err := counterService.Reset(scope, ""); err != nil {
+					logger.WithError(err).Warn("清零扫码计数失败")
+				}
+			}
+		}
 	})
 
+	// 距上次扫码时长监控
+	var idleService *service.IdleService
+	if cfg.Idle.Enable {
+		idleService, err = service.NewIdleService(configService, maintenance, cfg.Idle, location, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化空闲监控失败: %w", err)
+		}
+		idleService.SetCounters(counterService)
+		barcodeService.OnRecorded(idleService.Observe)
+	}
+
+	// 工位心跳
+	var heartbeatService *service.HeartbeatService
+	if cfg.Heartbeat.Enable {
+		heartbeatService = service.NewHeartbeatService(db.DB, cfg.Heartbeat, cfg.Peers.Station, logger)
+		barcodeService.OnRecorded(heartbeatService.Observe)
+	}
+
 	// 初始化WebSocket Hub
 	hub := websocket.NewHub(&cfg.WebSocket, logger)
+	registerEvents(hub.Catalog())
+
+	// 广播序号跨重启连续，按块预留，断线续传的客户端不会把重启后的新消息当作已收到
+	if counterService != nil {
+		if sequence, err := counterService.Sequence(service.CounterScopeHub, service.CounterHubSeq); err != nil {
+			logger.WithError(err).Warn("预留广播序号失败，本次运行序号从1开始")
+		} else {
+			hub.ResumeSeq(uint64(sequence.Start()))
+			hub.OnBroadcast(func(_ string, message *websocket.Message, _ []byte) {
+				sequence.Advance(int64(message.Seq))
+			})
+		}
+	}
+	hub.SetCompaction(func() bool { return featureFlags.Enabled(service.FlagHubCompaction) })
+
+	// 网络扫码枪命令结果广播给前端
+	commandService := service.NewCommandService(db.DB, cfg.Commands, logger)
+	commandService.OnResult(func(result service.CommandResult) {
+		hub.BroadcastMessage("device_command", result)
+	})
+
+	// 系统配置变更通知前端，需重启的配置由前端提示
+	configService.OnChange(func(change service.ConfigChange) {
+		hub.BroadcastMessage("config_changed", change)
+	})
+
+	// 系统配置缓存，配置服务写入时失效；扫码参数风险监控、扫码枪参数预设等反复读取配置表的组件共用
+	configCache := service.NewConfigCache(configService, cfg.Database.ConfigCacheTTL)
+
+	// 扫码枪参数预设展开到副本，m.config 保留配置文件中的值，热加载比较时不会误报 scanner 配置已修改
+	presetService := service.NewScannerPresetService(configCache, logger)
+	resolveScanner := func(base config.ScannerConfig) config.ScannerConfig {
+		return resolveScannerConfig(base, presetService, deviceService, logger)
+	}
+	scannerConfig := resolveScanner(cfg.Scanner)
+
+	// 扫码参数超出安全范围时告警，变更扫码参数后重新检查
+	scannerGuard := service.NewScannerGuard(configCache, scannerConfig, db.DB, logger)
+	scannerGuard.OnWarning(func(event service.ScannerDriftEvent) {
+		hub.BroadcastMessage("scanner_drift", event)
+	})
+	configService.OnChange(func(change service.ConfigChange) {
+		if strings.HasPrefix(change.Key, "scanner.") {
+			scannerGuard.Check("config_change")
+		}
+	})
+
+	// 配置文件热加载，失败回滚时告警
+	var reloader *config.Reloader
+	if cfg.Reload.Enable && opts.ConfigPath != "" {
+		reloader = config.NewReloader(opts.ConfigPath, cfg, logger)
+		reloader.OnRollback(func(event config.RollbackEvent) {
+			hub.BroadcastMessage("config_rollback", event)
+		})
+	}
+
+	// 维护模式状态变更广播给前端
+	maintenance.OnChange(func(event service.MaintenanceEvent) {
+		hub.BroadcastMessage("maintenance", event)
+	})
+
+	// 序列号断号广播给前端
+	sequenceService.OnGap(func(event service.SequenceGapEvent) {
+		var deviceID *uint
+		if event.DeviceID > 0 {
+			deviceID = &event.DeviceID
+		}
+		hub.BroadcastDeviceMessage("sequence_gap", deviceID, event)
+	})
+
+	// 设备健康告警推送给前端
+	if healthService != nil {
+		healthService.OnAlert(func(deviceID uint, event service.AlertEvent) {
+			hub.BroadcastDeviceMessage("alert", &deviceID, event)
+		})
+	}
+
+	// 数据质量退化告警推送给前端
+	if qualityService != nil {
+		qualityService.OnAlert(func(event service.AlertEvent) {
+			hub.BroadcastMessage("alert", event)
+		})
+	}
+
+	// 完整性自检发现断链时告警；数据重置清空记录后哈希链从头开始
+	if integrityService != nil {
+		integrityService.OnAlert(func(event service.AlertEvent) {
+			hub.BroadcastMessage("alert", event)
+		})
+		resetService.OnReset(func(event service.DataResetEvent) {
+			if event.Scope != service.ResetScopeSessions {
+				integrityService.Restart()
+			}
+		})
+	}
+
+	// 误扫设置码告警推送给前端
+	profileService.OnAlert(func(deviceID *uint, event service.AlertEvent) {
+		hub.BroadcastDeviceMessage("alert", deviceID, event)
+	})
+
+	// 目标进度里程碑推送给看板，达成时告警
+	if goalService != nil {
+		goalService.OnProgress(func(event service.GoalProgressEvent) {
+			hub.BroadcastDeviceMessage("goal_progress", event.DeviceID, event)
+		})
+		goalService.OnAlert(func(deviceID *uint, event service.AlertEvent) {
+			hub.BroadcastDeviceMessage("alert", deviceID, event)
+		})
+	}
+
+	// 日结及重新开放推送给看板
+	if closingService != nil {
+		closingService.OnChange(func(event service.DayClosingEvent) {
+			hub.BroadcastMessage("day_closing", event)
+		})
+	}
+
+	// 数据重置后通知看板清空本地状态
+	resetService.OnReset(func(event service.DataResetEvent) {
+		hub.BroadcastMessage("data_reset", event)
+	})
+
+	// 空闲计时推送给看板，班次内长时间无扫码时告警
+	if idleService != nil {
+		idleService.OnTick(func(status service.IdleStatus) {
+			hub.BroadcastMessage("idle_tick", status)
+		})
+		idleService.OnAlert(func(deviceID *uint, event service.AlertEvent) {
+			hub.BroadcastDeviceMessage("alert", deviceID, event)
+		})
+	}
+
+	// 初始化文件事件输出
+	var fileSink *sink.FileSink
+	if cfg.Sinks.File.Enable {
+		fileSink, err = sink.NewFileSink(cfg.Sinks.File, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化文件事件输出失败: %w", err)
+		}
+		fileSink.OnAlert(func(err error) {
+			hub.BroadcastMessage("alert", service.AlertEvent{
+				Source:  "file_sink",
+				Level:   "error",
+				Message: err.Error(),
+			})
+		})
+	}
+
+	// 初始化出站事件日志，扫码事件经由出站日志投递到各输出端
+	var outbox *service.OutboxService
+	if cfg.Sinks.Outbox.Enable {
+		outbox = service.NewOutboxService(db.DB, cfg.Sinks.Outbox, logger)
+		// 调试及测试环境校验出站事件，尽早发现载荷与对外发布的结构定义不一致
+		outbox.SetSchemaCheck(cfg.App.Debug || cfg.IsTest())
+		barcodeService.SetOutbox(outbox)
+	}
+	if fileSink != nil {
+		if outbox != nil {
+			outbox.RegisterSink("file", func(event *models.OutboxEvent) error {
+				return fileSink.Deliver([]byte(event.Payload))
+			})
+		}
+		hub.OnBroadcast(func(msgType string, message *websocket.Message, data []byte) {
+			// 扫码事件已由出站日志投递，避免重复写入
+			if outbox != nil && msgType == "barcode" {
+				return
+			}
+			// 回放事件由回放任务按指定输出端投递
+			if msgType == "barcode_replay" {
+				return
+			}
+			fileSink.Publish(msgType, message, data)
+		})
+	}
+
+	// 历史扫码记录回放，默认只投递到文件输出，指定 websocket 时才推送给前端
+	replayService := service.NewReplayService(db.DB, cfg.Sinks.Replay, logger)
+	replayService.SetSchemaCheck(cfg.App.Debug || cfg.IsTest())
+	if fileSink != nil {
+		replayService.RegisterSink("file", fileSink.Deliver)
+	}
+	replayService.RegisterSink("websocket", func(payload []byte) error {
+		hub.BroadcastMessage("barcode_replay", json.RawMessage(payload))
+		return nil
+	})
+
+	// 迁移新增列的后台回填，服务启动后按批执行；只读模式不写入数据库
+	var backfillService *service.BackfillService
+	if !cfg.App.ReadOnly {
+		backfillService = service.NewBackfillService(db.DB, cfg.Database.Backfill, logger)
+		backfillService.Register(service.DerivedFieldsBackfill(barcodeService.Processor()))
+	}
+
+	// 相邻工位事件转发
+	var forwarder *peer.Forwarder
+	if cfg.Peers.Enable {
+		forwarder, err = peer.NewForwarder(cfg.Peers, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化工位事件转发失败: %w", err)
+		}
+		hub.OnBroadcast(forwarder.Publish)
+	}
 
 	// 创建条码处理器
-	barcodeHandler := handlers.NewBarcodeHandler(hub, logger)
+	barcodeHandler := handlers.NewBarcodeHandler(hub, barcodeService, maintenance, logger)
+	barcodeHandler.SetProcessor(barcodeService.Processor())
+	barcodeHandler.SetFlags(featureFlags)
+	// 推送消息带上工位及设备名称，多个工位共用看板时可区分来源
+	barcodeHandler.SetDevices(deviceService, cfg.Peers.Station)
+	if template, ok := cfg.Messages.Template(); ok {
+		barcodeHandler.SetMessageTemplate(template)
+	}
+
+	// 条码图片存储
+	var imageService *service.ImageService
+	if cfg.Images.Enable {
+		imageService, err = service.NewImageService(db.DB, cfg.Images, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化条码图片存储失败: %w", err)
+		}
+		barcodeHandler.SetImageStore(imageService)
+	}
+
+	// 按键时序诊断，疑似截断比例过高时告警
+	keyTiming := service.NewKeyTimingMonitor(scannerConfig, logger)
+	keyTiming.OnAlert(func(deviceID *uint, event service.AlertEvent) {
+		hub.BroadcastDeviceMessage("alert", deviceID, event)
+	})
+	if featureFlags.Enabled(service.FlagKeyTiming) {
+		barcodeHandler.SetKeyTimingMonitor(keyTiming)
+	}
+
+	// 监控指标登记表，/metrics 与指标快照共用
+	metrics := service.NewMetricsRegistry()
+	scanMetrics := service.NewScanMetrics()
+	barcodeHandler.SetScanMetrics(scanMetrics)
+
+	// 扫码接入限流，网络扫码枪及外部推送过快时限制，按键扫码优先
+	ingestLimiter := service.NewIngestLimiter(cfg.Ingestion, logger)
+	barcodeHandler.SetIngestLimiter(ingestLimiter)
+	var historyService *service.MetricsHistoryService
+	if cfg.MetricsHistory.Enable {
+		historyService = service.NewMetricsHistoryService(db.DB, metrics, cfg.MetricsHistory, logger)
+	}
+
+	// 启动恢复：上次非正常退出时对账会话、维护模式及出站积压，并报告恢复结果
+	var recoveryService *service.RecoveryService
+	if cfg.Recovery.Enable {
+		recoveryService = service.NewRecoveryService(db.DB, configService, maintenance, sequenceService, cfg.Recovery, logger)
+		if outbox != nil {
+			recoveryService.SetOutbox(outbox)
+		}
+	}
+
+	// 热备：备机待命时忽略本机扫码来源的扫码，接管后恢复
+	var standbyService *service.StandbyService
+	if cfg.Standby.Enable {
+		standbyService = service.NewStandbyService(db.DB, cfg.Standby, cfg.Peers.Station, logger)
+		barcodeHandler.SetCaptureGate(standbyService)
+	}
+
+	// 各采集来源交出的扫码先进入有界缓冲，由一个 goroutine 依次交给条码处理器；嵌入用的 pkg/scanner 使用同一缓冲
+	scanQueue := scanner.NewQueue(cfg.Scanner.BufferSize, cfg.Scanner.Overflow)
+
+	// 初始化键盘钩子（Linux下读取 evdev 输入设备），禁用或不可用时以仅API模式运行
+	var sources []scanner.ScannerSource
+	var hook scanner.KeyboardSource
+	if useHook(&scannerConfig, logger) {
+		hook = scanner.NewSource(&scannerConfig, scanQueue, logger)
+		// rawinput 及 evdev 按键盘设备路径把扫码归属到本工位登记的设备
+		hook.SetDeviceResolver(deviceService.ResolveDevicePath)
+		sources = append(sources, hook)
+	}
+
+	// 按设备替换按键超时：设备手动指定的值优先，其次为开启自动调整的设备学到的值；设备变更后重新加载
+	var timeouts *scanner.AdaptiveTimeouts
+	if adaptive, ok := hook.(scanner.AdaptiveSource); ok {
+		timeouts = scanner.NewAdaptiveTimeouts(scannerConfig.AdaptiveTimeout)
+		loadDeviceTimeouts(deviceService, timeouts, logger)
+		deviceService.OnChanged(func() { loadDeviceTimeouts(deviceService, timeouts, logger) })
+		if !cfg.App.ReadOnly {
+			timeouts.OnLearned(func(t scanner.DeviceTimeout) {
+				if err := deviceService.SaveLearnedTimeout(t.DeviceID, t.LearnedMS, t.Samples); err != nil {
+					logger.WithError(err).WithField("device_id", t.DeviceID).Warn("保存设备学到的按键超时失败")
+				}
+			})
+		}
+		adaptive.SetTimeouts(timeouts)
+	}
+
+	// 按键采集暂停/恢复，状态变化推送给前端
+	var captureSwitch service.CaptureSwitch
+	if pausable, ok := hook.(scanner.PausableSource); ok {
+		captureSwitch = pausable
+	}
+	captureService := service.NewCaptureService(captureSwitch, logger)
+	captureService.OnChange(func(event service.CaptureEvent) {
+		hub.BroadcastMessage("scanner_capture", event)
+	})
+
+	// 串口扫码枪，与键盘钩子同时工作
+	var serialSources *serial.Sources
+	if cfg.Serial.Enable && len(cfg.Serial.Ports) > 0 {
+		serialSources = serial.New(cfg.Serial, scanQueue, logger)
+		serialSources.SetDeviceLookup(func(serialNo string) (uint, bool) {
+			device, err := deviceService.GetDeviceBySerialNo(serialNo)
+			if err != nil {
+				return 0, false
+			}
+			return device.ID, true
+		})
+		sources = append(sources, serialSources)
+	}
 
-	// 初始化键盘钩子
-	hook := scanner.NewHook(&cfg.Scanner, barcodeHandler, logger)
+	// 网络扫码枪，与键盘钩子及串口扫码枪同时工作，扫码枪的IP与设备命令地址的主机相同时归属到该设备
+	var networkSource *network.Source
+	if cfg.NetworkScanner.Enable {
+		networkSource = network.New(cfg.NetworkScanner, scanQueue, logger)
+		networkSource.SetDeviceLookup(deviceService.ResolveNetworkHost)
+		networkSource.SetLimiter(ingestLimiter)
+		sources = append(sources, networkSource)
+	}
+	if opts.Sources != nil {
+		sources = append(sources, opts.Sources(scanQueue)...)
+	}
+	// 扫码提示音，操作员看不到屏幕时据此确认扫码是否被接受；仅API模式下没有本机操作员，不播放
+	var feedbackPlayer *feedback.Player
+	if len(sources) > 0 {
+		settings := configService.FeedbackSettings()
+		if beeper, err := feedback.NewSystemBeeper(); err != nil {
+			if settings.Enabled {
+				logger.WithError(err).Warn("扫码提示音不可用")
+			}
+		} else {
+			feedbackPlayer = feedback.NewPlayer(beeper, settings, logger)
+			barcodeHandler.SetFeedback(feedbackPlayer)
+			configService.OnChange(func(change service.ConfigChange) {
+				if service.IsFeedbackKey(change.Key) {
+					feedbackPlayer.SetSettings(configService.FeedbackSettings())
+				}
+			})
+			metrics.Register(func() []service.Metric {
+				stats := feedbackPlayer.Metrics()
+				return []service.Metric{
+					service.CounterMetric("barcode_feedback_played_total", "已播放的扫码提示音数", float64(stats.Played)),
+					service.CounterMetric("barcode_feedback_dropped_total", "连续快速扫码时队列已满而丢弃的提示音数", float64(stats.Dropped)),
+					service.CounterMetric("barcode_feedback_failed_total", "播放失败的提示音数", float64(stats.Failed)),
+				}
+			})
+		}
+	}
+
+	// 仅API模式下没有采集来源，不使用扫码缓冲
+	if len(sources) == 0 {
+		scanQueue = nil
+	}
+
+	// 键盘钩子代理：服务没有交互式桌面时由用户会话中的 scanner-agent 安装钩子并转交扫码，
+	// 维护模式下通知代理暂停
+	var agentServer *agent.Server
+	if cfg.Agent.Enable {
+		agentServer = agent.NewServer(cfg.Agent, scannerConfig, barcodeHandler, maintenance, logger)
+		maintenance.OnChange(func(service.MaintenanceEvent) {
+			agentServer.SyncPause()
+		})
+		if hook != nil {
+			logger.Warn("本机键盘钩子与键盘钩子代理同时启用，同一次扫码可能被记录两次，建议关闭 scanner.enable_hook")
+		}
+	}
+
+	// 外部系统推送扫码结果
+	var ingestService *service.IngestService
+	if cfg.Ingest.Enable {
+		ingestService = service.NewIngestService(db.DB, cfg.Ingest, logger)
+	}
+
+	// SQLite 空闲维护，扫码到达时中止
+	var vacuumService *service.VacuumService
+	if cfg.Database.Maintenance.Enable {
+		vacuumService, err = service.NewVacuumService(db.DB, cfg.Database, location, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化数据库维护失败: %w", err)
+		}
+		barcodeService.OnReceived(vacuumService.Observe)
+	}
+
+	// 看板偏好设置
+	var preferenceService *service.PreferenceService
+	if cfg.Preferences.Enable {
+		preferenceService = service.NewPreferenceService(db.DB, cfg.Preferences, logger)
+	}
+
+	// 按场景文件模拟扫码，经过与外部推送相同的处理
+	var simulatorService *service.SimulatorService
+	if cfg.Simulator.Enable {
+		simulatorService = service.NewSimulatorService(db.DB, cfg.Simulator, logger)
+		simulatorService.SetSink(barcodeHandler.Simulate)
+	}
+
+	// 从中心服务器同步设备、分类规则及系统配置
+	var syncService *service.SyncService
+	if cfg.Central.Sync.Enable {
+		syncService = service.NewSyncService(db.DB, cfg.Central, cfg.Peers.Station, deviceService, ruleService, configService, logger)
+	}
+
+	// 首次运行向导，完成前所有接口仅允许本机访问
+	var setupService *service.SetupService
+	if !cfg.App.Provisioned && opts.ConfigPath != "" {
+		setupService, err = service.NewSetupService(opts.ConfigPath, cfg, logger)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("初始化首次运行向导失败: %w", err)
+		}
+		logger.WithField("url", fmt.Sprintf("http://localhost:%d/setup", cfg.Server.Port)).
+			Warn("尚未完成首次运行配置，请在本机打开向导页面；完成前所有接口仅允许本机访问")
+	}
+
+	// 运行摘要在管理器创建后才能生成，由闭包延迟引用
+	var manager *Manager
 
 	// 创建路由管理器
-	router := routes.New(logger, hub, barcodeHandler)
+	router := routes.New(routes.Dependencies{
+		Config:      cfg,
+		Logger:      logger,
+		Hub:         hub,
+		Handler:     barcodeHandler,
+		DB:          db,
+		Maintenance: maintenance,
+		Capture:     captureService,
+		Ingestion:   ingestLimiter,
+		Integrity:   integrityService,
+		Coalescer:   coalescer,
+		Operators:   operatorStats,
+		Barcodes:    barcodeService,
+		StatsCache:  statsCache,
+		ConfigCache: configCache,
+		FileSink:    fileSink,
+		Outbox:      outbox,
+		Sequence:    sequenceService,
+		Tokens:      tokenService,
+		Devices:     deviceService,
+		Health:      healthService,
+		Images:      imageService,
+		Forwarder:   forwarder,
+		Idle:        idleService,
+		Reset:       resetService,
+		Rules:       ruleService,
+		Profiles:    profileService,
+		Replication: replicationService,
+		Configs:     configService,
+		Summary:     func() string { return manager.Summary().Text() },
+		HookState:   func() string { return manager.hookState() },
+		HookStats:   func() scanner.HookStats { return manager.hookStats() },
+		HookMetrics: func() scanner.HookMetrics { return manager.hookMetrics() },
+		Sources:     func() []scanner.SourceStatus { return manager.sourceStatuses() },
+		ScanQueue:   scanQueue,
+		Timeouts:    timeouts,
+		Commands:    commandService,
+		Heartbeats:  heartbeatService,
+		Counters:    counterService,
+		Logs:        logBuffer,
+		Guard:       scannerGuard,
+		Reloader:    reloader,
+		Goals:       goalService,
+		KeyTiming:   keyTiming,
+		Replay:      replayService,
+		Distinct:    distinctService,
+		Ingest:      ingestService,
+		Setup:       setupService,
+		Sync:        syncService,
+		Vacuum:      vacuumService,
+		Acks:        ackService,
+		Presets:     presetService,
+		Agent:       agentServer,
+		Serial:      serialSources,
+		Network:     networkSource,
+		Standby:     standbyService,
+		Flags:       featureFlags,
+		Preferences: preferenceService,
+		Closing:     closingService,
+		Simulator:   simulatorService,
+		Metrics:     metrics,
+		ScanMetrics: scanMetrics,
+		History:     historyService,
+		Quality:     qualityService,
+		Recovery:    recoveryService,
+		Backfills:   backfillService,
+		Location:    location,
 
-	return &Manager{
+		ScannerConfig: func() config.ScannerConfig { return manager.ScannerConfig() },
+		UpdateScanner: func(update scanner.SettingsUpdate, actor string) (config.ScannerConfig, error) {
+			return manager.UpdateScanner(update, actor)
+		},
+	})
+
+	manager = &Manager{
 		config:         cfg,
 		logger:         logger,
+		db:             db,
+		secondary:      secondary,
+		replication:    replicationService,
+		maintenance:    maintenance,
+		fileSink:       fileSink,
+		outbox:         outbox,
+		replay:         replayService,
+		backfills:      backfillService,
+		distinct:       distinctService,
+		health:         healthService,
+		images:         imageService,
+		forwarder:      forwarder,
+		idle:           idleService,
+		heartbeat:      heartbeatService,
+		counters:       counterService,
+		feedback:       feedbackPlayer,
+		sync:           syncService,
+		vacuum:         vacuumService,
+		preferences:    preferenceService,
+		simulator:      simulatorService,
+		history:        historyService,
+		quality:        qualityService,
+		recovery:       recoveryService,
+		scannerGuard:   scannerGuard,
+		reloader:       reloader,
 		hook:           hook,
+		serial:         serialSources,
+		network:        networkSource,
+		sources:        sources,
+		scanQueue:      scanQueue,
+		queueDone:      make(chan struct{}),
+		sourceErrors:   make(map[string]string),
+		standby:        standbyService,
+		agent:          agentServer,
+		resolveScanner: resolveScanner,
+		scannerConfig:  scannerConfig,
+		configs:        configService,
 		hub:            hub,
 		barcodeHandler: barcodeHandler,
+		ingestLimiter:  ingestLimiter,
+		integrity:      integrityService,
+		operatorStats:  operatorStats,
 		router:         router,
-	}, nil
+	}
+
+	if heartbeatService != nil {
+		heartbeatService.SetComponents(manager.componentStates)
+	}
+
+	if reloader != nil {
+		reloader.SetApply(manager.applyConfig)
+		reloader.OnRollback(manager.recordConfigRollback)
+	}
+
+	// 键盘钩子看门狗重新安装钩子时告警并写入系统日志
+	if watched, ok := hook.(scanner.WatchedSource); ok {
+		watched.OnReinstall(manager.recordHookReinstall)
+	}
+
+	// 在配置管理中修改按键超时、长度范围、终止符后立即推送给按键采集来源
+	configService.OnInvalidate(manager.onScannerConfigWritten)
+
+	// 串口断开及重连写入系统日志
+	if serialSources != nil {
+		serialSources.OnEvent(manager.recordSerialEvent)
+	}
+
+	// 热备角色变化时推送、告警、写入系统日志，并打开或关闭串口及网络扫码枪
+	if standbyService != nil {
+		standbyService.OnChange(manager.onStandbyChange)
+	}
+
+	// 热更新的配置生效后重新输出运行摘要
+	configService.OnChange(func(change service.ConfigChange) {
+		if !change.RestartRequired {
+			manager.logSummary("配置变更后运行摘要")
+		}
+	})
+
+	return manager, nil
 }
 
 // Start 启动应用程序
 func (m *Manager) Start() error {
 	m.logger.Info("启动条码扫描器应用程序")
+	m.startedAt = time.Now()
+
+	// 检查扫码参数是否在安全范围内
+	m.scannerGuard.Check("startup")
+
+	// 监听配置文件变化
+	if m.reloader != nil {
+		if err := m.reloader.Start(); err != nil {
+			m.logger.WithError(err).Warn("启动配置热加载失败")
+		}
+	}
+
+	// 恢复维护模式状态
+	if err := m.maintenance.Load(); err != nil {
+		m.logger.WithError(err).Warn("恢复维护模式状态失败")
+	}
+
+	// 检查上次运行是否非正常退出并对账，须在接收扫码及出站投递之前
+	if m.recovery != nil {
+		m.recoverLastRun()
+	}
+
+	// 定时写入持久化计数器
+	if m.counters != nil {
+		m.counters.Start()
+	}
+
+	// 恢复最后扫码时间并启动空闲计时
+	if m.idle != nil {
+		if err := m.idle.Load(); err != nil {
+			m.logger.WithError(err).Warn("恢复最后扫码时间失败")
+		}
+		m.idle.Start()
+	}
+
+	// 启动文件事件输出
+	if m.fileSink != nil {
+		m.fileSink.Start()
+	}
+
+	// 启动出站事件投递，继续投递上次未完成的事件
+	if m.outbox != nil {
+		m.outbox.Start()
+	}
+
+	// 只读模式不启动会写入数据库或删除文件的后台任务
+	if !m.config.App.ReadOnly {
+		// 启动历史记录回放，继续上次未完成的任务
+		m.replay.Start()
+
+		// 启动迁移新增列的后台回填，继续上次未完成的任务
+		if err := m.backfills.Start(); err != nil {
+			m.logger.WithError(err).Warn("创建后台回填任务失败")
+		}
+
+		// 启动不同条码数草图定时保存
+		m.distinct.Start()
+
+		// 启动设备健康评分快照
+		if m.health != nil {
+			m.health.Start()
+		}
+
+		// 统计图片磁盘占用并启动定期清理
+		if m.images != nil {
+			m.images.Start()
+		}
+	}
+
+	// 启动主库健康检查与对账
+	if m.replication != nil {
+		m.replication.Start()
+	}
+
+	// 启动工位事件转发
+	if m.forwarder != nil {
+		m.forwarder.Start()
+	}
+
+	// 启动工位心跳
+	if m.heartbeat != nil {
+		m.heartbeat.Start()
+	}
+
+	// 启动中心同步
+	if m.sync != nil {
+		m.sync.Start()
+	}
+
+	// 启动数据库空闲维护
+	if m.vacuum != nil {
+		m.vacuum.Start()
+	}
+
+	// 启动过期偏好设置清理
+	if m.preferences != nil {
+		m.preferences.Start()
+	}
+
+	// 启动监控指标快照
+	if m.history != nil {
+		m.history.Start()
+	}
+
+	// 启动数据质量报告
+	if m.quality != nil {
+		m.quality.Start()
+	}
+	if m.integrity != nil {
+		m.integrity.Start()
+	}
 
 	// 启动WebSocket Hub
 	go m.hub.Run()
 
+	// 推送启动恢复结果，之后连接的客户端可通过 /api/status 查看
+	if m.recovery != nil {
+		if summary := m.recovery.Summary(); summary != nil && summary.Unclean {
+			m.hub.BroadcastMessage("recovery", summary)
+		}
+	}
+
 	// 启动HTTP服务器
 	if err := m.startHTTPServer(); err != nil {
 		return fmt.Errorf("启动HTTP服务器失败: %w", err)
 	}
 
-	// 安装键盘钩子
-	if err := m.hook.Install(); err != nil {
-		return fmt.Errorf("安装键盘钩子失败: %w", err)
+	// 加载模拟场景，场景无效时仍可通过接口重新上传
+	if m.simulator != nil {
+		if err := m.simulator.Start(); err != nil {
+			m.logger.WithError(err).Error("加载模拟场景失败")
+		}
+	}
+
+	// 确定热备角色，备机待命时不打开串口及网络扫码枪，避免与主机争用
+	if m.standby != nil {
+		m.standby.Start()
 	}
 
-	m.logger.WithField("port", m.config.Server.Port).Info("应用程序启动成功，开始监听设备")
+	// 仅API模式下不采集扫码，扫码通过接口及键盘钩子代理接入
+	if len(m.sources) == 0 {
+		if m.agent != nil {
+			m.logSummary("应用程序启动成功，等待键盘钩子代理连接")
+			return nil
+		}
+		m.logSummary("应用程序启动成功（仅API模式）")
+		return nil
+	}
 
-	// 运行消息循环
-	m.hook.MessageLoop()
+	if m.feedback != nil {
+		m.feedback.Start()
+	}
+	m.consuming = true
+	go m.consumeScans()
+
+	// 各采集来源在独立的 goroutine 中安装并运行，一个来源安装失败或异常退出不影响其他来源；
+	// 等待全部来源安装完成（含失败）后输出运行摘要
+	capturing := m.standby == nil || m.standby.Capturing()
+	installed := make(chan struct{}, len(m.sources))
+	for _, source := range m.sources {
+		m.sourceWG.Add(1)
+		go m.runSource(source, capturing || !m.standbyGated(source), installed)
+	}
+	for range m.sources {
+		<-installed
+	}
+
+	m.logSummary("应用程序启动成功，开始监听设备")
 	return nil
 }
 
 // Stop 停止应用程序
 func (m *Manager) Stop() error {
-	m.logger.Info("正在停止应用程序...")
+	m.logSummary("正在停止应用程序...")
 
 	// 创建超时上下文
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 卸载键盘钩子
-	if m.hook != nil {
-		m.hook.Uninstall()
+	// 停止全部采集来源（卸载键盘钩子、关闭串口及网络扫码枪连接），等待各来源的 goroutine 结束
+	// 键盘钩子的 Stop 向消息循环线程投递 WM_QUIT，GetMessage 随即返回；个别来源未及时结束时不阻塞退出
+	for _, source := range m.sources {
+		source.Stop()
+	}
+	sourcesDone := make(chan struct{})
+	go func() {
+		m.sourceWG.Wait()
+		close(sourcesDone)
+	}()
+	select {
+	case <-sourcesDone:
+	case <-time.After(sourceStopTimeout):
+		m.logger.WithField("timeout", sourceStopTimeout).Warn("等待采集来源停止超时，继续退出")
+	}
+
+	// 处理缓冲中剩余的扫码，之后才关闭数据库
+	if m.scanQueue != nil {
+		m.scanQueue.Close()
+	}
+	if m.consuming {
+		select {
+		case <-m.queueDone:
+		case <-time.After(sourceStopTimeout):
+			m.logger.WithField("pending", m.scanQueue.Stats().Pending).Warn("等待处理缓冲中的扫码超时，继续退出")
+		}
+	}
+
+	// 停止扫码提示音，须在缓冲中的扫码处理完之后
+	if m.feedback != nil {
+		m.feedback.Close()
+	}
+
+	// 停止热备检查并释放采集租约，另一台工位机无需等待租约过期
+	if m.standby != nil {
+		m.standby.Close()
+	}
+
+	// 停止模拟扫码
+	if m.simulator != nil {
+		m.simulator.Close()
+	}
+
+	// 断开键盘钩子代理，HTTP服务器停止时不会关闭已升级的连接
+	if m.agent != nil {
+		m.agent.Close()
+	}
+
+	// 停止配置热加载
+	if m.reloader != nil {
+		m.reloader.Close()
+	}
+
+	// 停止维护模式定时器，维护中停止时通知客户端不要自动重连
+	inMaintenance := false
+	if m.maintenance != nil {
+		inMaintenance = m.maintenance.IsActive()
+		m.maintenance.Close()
+	}
+
+	// 停止设备健康评分快照
+	if m.health != nil {
+		m.health.Close()
+	}
+
+	// 停止不同条码数统计并保存草图
+	m.distinct.Close()
+
+	// 停止空闲计时并保存最后扫码时间
+	if m.idle != nil {
+		m.idle.Close()
+	}
+
+	// 停止工位心跳并写入最后一条心跳
+	if m.heartbeat != nil {
+		m.heartbeat.Close()
+	}
+
+	// 停止监控指标快照
+	if m.history != nil {
+		m.history.Close()
+	}
+
+	// 停止数据质量报告
+	if m.quality != nil {
+		m.quality.Close()
+	}
+	if m.integrity != nil {
+		m.integrity.Close()
+	}
+
+	// 停止图片清理
+	if m.images != nil {
+		m.images.Close()
+	}
+
+	// 停止工位事件转发
+	if m.forwarder != nil {
+		m.forwarder.Close()
+	}
+
+	// 停止历史记录回放，保存检查点
+	m.replay.Close()
+
+	// 停止后台回填，已提交的批次重启后不再重做
+	if m.backfills != nil {
+		m.backfills.Close()
 	}
 
 	// 关闭WebSocket Hub
 	if m.hub != nil {
-		m.hub.Close()
+		if inMaintenance {
+			m.hub.Shutdown(websocket.CloseMaintenance, "服务因维护停止")
+		} else {
+			m.hub.Close()
+		}
 	}
 
 	// 停止HTTP服务器
@@ -112,30 +1297,122 @@ func (m *Manager) Stop() error {
 		}
 	}
 
+	// 停止出站事件投递
+	if m.outbox != nil {
+		m.outbox.Close()
+	}
+	// 刷新并关闭文件事件输出
+	if m.fileSink != nil {
+		if err := m.fileSink.Close(); err != nil {
+			m.logger.WithError(err).Error("关闭文件事件输出失败")
+		}
+	}
+
+	// 停止主库健康检查与对账
+	if m.replication != nil {
+		m.replication.Close()
+	}
+
+	// 停止中心同步
+	if m.sync != nil {
+		m.sync.Close()
+	}
+
+	// 停止数据库空闲维护
+	if m.vacuum != nil {
+		m.vacuum.Close()
+	}
+
+	// 停止过期偏好设置清理
+	if m.preferences != nil {
+		m.preferences.Close()
+	}
+
+	// 写入剩余的计数器变化，须在停止广播及空闲计时之后
+	if m.counters != nil {
+		m.counters.Close()
+	}
+
+	// 写入正常停止标记，下次启动时不做恢复
+	if m.recovery != nil {
+		if err := m.recovery.MarkStopped(); err != nil {
+			m.logger.WithError(err).Error("写入正常停止标记失败")
+		}
+	}
+
+	// 关闭数据库连接
+	if m.secondary != nil {
+		if err := m.secondary.Close(); err != nil {
+			m.logger.WithError(err).Error("关闭本地备份库失败")
+		}
+	}
+	if m.db != nil {
+		if err := m.db.Close(); err != nil {
+			m.logger.WithError(err).Error("关闭数据库失败")
+		}
+	}
+
 	m.logger.Info("应用程序已停止")
 	return nil
 }
 
-// startHTTPServer 启动HTTP服务器
+// startHTTPServer 启动HTTP服务器，端口在返回前完成监听，端口被占用时返回错误
 func (m *Manager) startHTTPServer() error {
+	listener, port, err := listenHTTP(m.config.Server.Port, m.config.Server.PortFallbackRange)
+	if err != nil {
+		return err
+	}
+	if port != m.config.Server.Port {
+		// 端口为0时由系统分配（全流程测试），不是端口冲突
+		if m.config.Server.Port != 0 {
+			m.logger.WithField("configured_port", m.config.Server.Port).WithField("port", port).
+				Warn("配置的端口已被占用，已改用备用端口")
+		}
+		// 状态接口及运行摘要中显示实际端口
+		m.config.Server.Port = port
+	}
+
 	// 设置路由
 	engine := m.router.Setup()
 
 	m.webSocketServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", m.config.Server.Port),
+		Addr:    fmt.Sprintf(":%d", port),
 		Handler: engine,
 	}
 
 	go func() {
-		m.logger.WithField("port", m.config.Server.Port).Info("启动HTTP服务器")
-		if err := m.webSocketServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			m.logger.WithError(err).Error("HTTP服务器启动失败")
+		m.logger.WithField("port", port).Info("启动HTTP服务器")
+		if err := m.webSocketServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			m.logger.WithError(err).Error("HTTP服务器异常退出")
 		}
 	}()
 
 	return nil
 }
 
+// listenHTTP 监听HTTP端口，被占用时依次尝试后续fallbackRange个端口，返回实际端口；port 为0时由系统分配
+func listenHTTP(port, fallbackRange int) (net.Listener, int, error) {
+	if fallbackRange < 0 {
+		fallbackRange = 0
+	}
+
+	var firstErr error
+	for candidate := port; candidate <= port+fallbackRange; candidate++ {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", candidate))
+		if err == nil {
+			return listener, listener.Addr().(*net.TCPAddr).Port, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if fallbackRange > 0 {
+		return nil, 0, fmt.Errorf("端口 %d~%d 均无法监听，可能已被其他进程占用: %w", port, port+fallbackRange, firstErr)
+	}
+	return nil, 0, fmt.Errorf("端口 %d 无法监听，可能已被其他进程占用（可设置 server.port_fallback_range 尝试后续端口）: %w", port, firstErr)
+}
+
 // GetLogger 获取日志记录器
 func (m *Manager) GetLogger() *logrus.Logger {
 	return m.logger
@@ -145,3 +1422,349 @@ func (m *Manager) GetLogger() *logrus.Logger {
 func (m *Manager) GetConfig() *config.Config {
 	return m.config
 }
+
+// resolveScannerConfig 展开扫码枪参数预设：scanner.preset 优先，其次当前活跃设备的预设
+func resolveScannerConfig(cfg config.ScannerConfig, presets *service.ScannerPresetService, devices *service.DeviceService, logger *logrus.Logger) config.ScannerConfig {
+	name := cfg.Preset
+	if name == "" {
+		if device, err := devices.GetActiveDevice(); err == nil {
+			name = device.Preset
+		}
+	}
+	if name == "" {
+		return cfg
+	}
+
+	explicit := func(key string) bool {
+		return config.InConfigFile("scanner." + key)
+	}
+	if err := presets.Apply(&cfg, name, explicit); err != nil {
+		logger.WithError(err).WithField("preset", name).Warn("应用扫码枪预设失败，使用配置文件中的扫码参数")
+	}
+	return cfg
+}
+
+// openDatabase 打开数据库，只读模式下以只读方式打开且不执行迁移
+func openDatabase(cfg *config.Config, logger *logrus.Logger) (*database.DB, error) {
+	if !cfg.App.ReadOnly {
+		db, err := database.New(&cfg.Database)
+		if err != nil {
+			return nil, fmt.Errorf("初始化数据库失败: %w", err)
+		}
+		if err := db.AutoMigrate(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+
+	db, err := database.OpenReadOnly(&cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("只读打开数据库失败: %w", err)
+	}
+	if version, err := db.MigrationVersion(); err != nil {
+		logger.WithError(err).Warn("读取数据库结构版本失败")
+	} else if version < database.SchemaVersion {
+		logger.WithFields(logrus.Fields{
+			"version":  version,
+			"required": database.SchemaVersion,
+		}).Warn("数据库结构版本较旧，只读模式下不执行迁移，部分接口可能报错")
+	}
+	return db, nil
+}
+
+// useHook 是否使用键盘钩子，配置禁用或当前环境不支持时以仅API模式运行
+func useHook(cfg *config.ScannerConfig, logger *logrus.Logger) bool {
+	if !cfg.EnableHook {
+		logger.Info("键盘钩子已禁用，以仅API模式运行")
+		return false
+	}
+	if ok, reason := scanner.Available(); !ok {
+		logger.WithField("reason", reason).Warn("键盘钩子不可用，以仅API模式运行")
+		return false
+	}
+	return true
+}
+
+// hookState 键盘钩子状态：running、stopped，仅API模式下为 not_configured
+func (m *Manager) hookState() string {
+	switch {
+	case m.hook == nil:
+		return "not_configured"
+	case m.hook.IsRunning():
+		return "running"
+	default:
+		return "stopped"
+	}
+}
+
+// hookStats 键盘钩子计数，仅API模式下为零值
+func (m *Manager) hookStats() scanner.HookStats {
+	if m.hook == nil {
+		return scanner.HookStats{}
+	}
+	return m.hook.Stats()
+}
+
+// hookMetrics 按键采集来源的按键、缓冲及扫码计数，仅API模式下为零值
+func (m *Manager) hookMetrics() scanner.HookMetrics {
+	if m.hook == nil {
+		return scanner.HookMetrics{}
+	}
+	return m.hook.GetMetrics()
+}
+
+// runSource 安装并运行一个采集来源，直到 Stop；install 为 false 时（热备待命）只等待，接管后由 onStandbyChange 安装。
+// 安装结束（含失败）后向 installed 发送一次
+//
+// 键盘钩子的 Install 与 Run 须在同一 goroutine 中调用。安装失败或运行中 panic 时记录错误并告警，不影响其他来源。
+func (m *Manager) runSource(source scanner.ScannerSource, install bool, installed chan<- struct{}) {
+	defer m.sourceWG.Done()
+	signaled := false
+	defer func() {
+		if !signaled {
+			installed <- struct{}{}
+		}
+		if r := recover(); r != nil {
+			err := fmt.Errorf("采集来源异常退出: %v", r)
+			m.logger.WithError(err).WithField("source", source.Name()).Error("采集来源异常退出，其他来源继续运行")
+			m.sourceFailed(source, err)
+		}
+	}()
+
+	if install {
+		if err := source.Install(); err != nil {
+			m.logger.WithError(err).WithField("source", source.Name()).Error("安装采集来源失败，其他来源继续运行")
+			m.sourceFailed(source, err)
+			if source == scanner.ScannerSource(m.hook) {
+				// 键盘钩子的 Run 依赖安装时的线程，安装失败后不再运行
+				return
+			}
+		}
+	}
+	installed <- struct{}{}
+	signaled = true
+	source.Run()
+}
+
+// consumeScans 依次处理采集来源交出的扫码，直到扫码缓冲关闭
+func (m *Manager) consumeScans() {
+	defer close(m.queueDone)
+	for scan := range m.scanQueue.Scans() {
+		if err := scanner.Dispatch(m.barcodeHandler, scan); err != nil {
+			m.logger.WithError(err).WithField("input", scan.Input).Error("处理条码失败")
+		}
+	}
+}
+
+// standbyGated 采集来源是否随热备角色打开及关闭：串口及网络扫码枪独占端口，待命时关闭以便另一台工位机打开；
+// 键盘钩子始终运行，待命时的扫码由条码处理器忽略
+func (m *Manager) standbyGated(source scanner.ScannerSource) bool {
+	return m.standby != nil && source != scanner.ScannerSource(m.hook)
+}
+
+// sourceFailed 记录采集来源的错误并推送告警
+func (m *Manager) sourceFailed(source scanner.ScannerSource, err error) {
+	m.setSourceError(source, err)
+	m.hub.BroadcastMessage("alert", service.AlertEvent{
+		Source:  "scanner",
+		Level:   "error",
+		Message: i18n.Sprintf("alert.capture_source_unavailable", source.Name(), err),
+	})
+}
+
+// setSourceError 记录或清除采集来源的错误
+func (m *Manager) setSourceError(source scanner.ScannerSource, err error) {
+	m.sourceMu.Lock()
+	defer m.sourceMu.Unlock()
+	if err == nil {
+		delete(m.sourceErrors, source.Name())
+		return
+	}
+	m.sourceErrors[source.Name()] = err.Error()
+}
+
+// sourceStatuses 各采集来源的状态：running、stopped、standby（热备待命中未打开）、failed
+func (m *Manager) sourceStatuses() []scanner.SourceStatus {
+	m.sourceMu.Lock()
+	defer m.sourceMu.Unlock()
+	statuses := make([]scanner.SourceStatus, 0, len(m.sources))
+	for _, source := range m.sources {
+		status := scanner.SourceStatus{Name: source.Name(), State: "stopped"}
+		switch errMsg, failed := m.sourceErrors[source.Name()]; {
+		case source.IsRunning():
+			status.State = "running"
+		case failed:
+			status.State, status.Error = "failed", errMsg
+		case m.standbyGated(source) && !m.standby.Capturing():
+			status.State = "standby"
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// componentStates 各组件当前状态，随心跳记录
+func (m *Manager) componentStates() map[string]string {
+	states := map[string]string{
+		"hook":        m.hookState(),
+		"maintenance": "inactive",
+		"database":    service.ReadTargetPrimary,
+		"websocket":   strconv.Itoa(m.hub.GetClientCount()) + " clients",
+	}
+	if m.maintenance != nil && m.maintenance.IsActive() {
+		states["maintenance"] = "active"
+	}
+	for _, source := range m.sourceStatuses() {
+		if m.hook == nil || source.Name != m.hook.Name() {
+			states["source:"+source.Name] = source.State
+		}
+	}
+	if m.agent != nil {
+		states["agent"] = m.agent.Status().State
+	}
+	if m.replication != nil {
+		states["database"] = m.replication.GetStatus().ReadTarget
+	}
+	if m.standby != nil {
+		states["standby"] = m.standby.Status().Role
+	}
+	return states
+}
+
+// recoverLastRun 检查上次运行并对账，非正常退出时写入系统日志
+func (m *Manager) recoverLastRun() {
+	summary, err := m.recovery.Recover()
+	if err != nil {
+		m.logger.WithError(err).Error("启动恢复失败")
+	}
+	if summary == nil || !summary.Unclean {
+		return
+	}
+
+	location, _ := m.config.App.Location()
+	message := summary.Text(location)
+	m.logger.WithFields(logrus.Fields{
+		"records_recovered": summary.RecordsRecovered,
+		"sessions_resumed":  summary.SessionsResumed,
+		"sessions_closed":   summary.SessionsClosed,
+		"outbox_pending":    summary.OutboxPending,
+		"last_scan_at":      summary.LastScanAt,
+	}).Warn(message)
+
+	log := &models.SystemLog{
+		Level:   "warn",
+		Message: message,
+		Module:  "recovery",
+		Action:  "recovery:unclean_shutdown",
+	}
+	if extra, err := json.Marshal(summary); err == nil {
+		log.Extra = string(extra)
+	}
+	if err := m.db.Create(log).Error; err != nil {
+		m.logger.WithError(err).Warn("写入系统日志失败")
+	}
+}
+
+// recordHookReinstall 看门狗重新安装失效的键盘钩子：告警并写入系统日志
+func (m *Manager) recordHookReinstall(event scanner.HookReinstall) {
+	message := i18n.Sprintf("alert.hook_reinstalled", event.Count, event.Reason)
+	level := "warn"
+	if event.Error != "" {
+		message = i18n.Sprintf("alert.hook_reinstall_failed", event.Error)
+		level = "error"
+	}
+	m.hub.BroadcastMessage("alert", service.AlertEvent{
+		Source:  "scanner",
+		Level:   level,
+		Message: message,
+	})
+
+	log := &models.SystemLog{
+		Level:   level,
+		Message: message,
+		Module:  "scanner",
+		Action:  "hook:reinstall",
+	}
+	if extra, err := json.Marshal(event); err == nil {
+		log.Extra = string(extra)
+	}
+	if err := m.db.Create(log).Error; err != nil {
+		m.logger.WithError(err).Warn("写入系统日志失败")
+	}
+}
+
+// recordSerialEvent 串口扫码枪断开及重连写入系统日志
+func (m *Manager) recordSerialEvent(event serial.Event) {
+	extra, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:   "info",
+		Message: fmt.Sprintf("串口扫码枪 %s 已连接", event.Port),
+		Module:  "scanner",
+		Action:  "serial:" + event.Type,
+		Extra:   string(extra),
+	}
+	if event.Type == serial.EventDisconnected {
+		log.Level = "warn"
+		log.Message = fmt.Sprintf("串口扫码枪 %s 不可用，将自动重连: %s", event.Port, event.Error)
+	} else if event.Attempts > 0 {
+		log.Message = fmt.Sprintf("串口扫码枪 %s 已重新连接（断开 %s，重试 %d 次）", event.Port, event.Downtime, event.Attempts)
+	}
+	if err := m.db.Create(log).Error; err != nil {
+		m.logger.WithError(err).Warn("写入系统日志失败")
+	}
+}
+
+// onStandbyChange 热备角色变化：推送 standby 事件并写入系统日志，接管或主机失去租约时告警，
+// 开始采集时打开串口，停止采集时关闭串口以便另一台工位机打开
+func (m *Manager) onStandbyChange(event service.StandbyEvent) {
+	m.hub.BroadcastMessage("standby", event)
+
+	capturing := event.Role != service.StandbyStateStandby
+	for _, source := range m.sources {
+		if !m.standbyGated(source) {
+			continue
+		}
+		if !capturing {
+			source.Uninstall()
+			continue
+		}
+		if err := source.Install(); err != nil {
+			m.logger.WithError(err).WithField("source", source.Name()).Error("接管后打开采集来源失败")
+			m.setSourceError(source, err)
+		} else {
+			m.setSourceError(source, nil)
+		}
+	}
+
+	log := &models.SystemLog{
+		Level:   "info",
+		Message: fmt.Sprintf("热备：%s -> %s，%s", event.Previous, event.Role, event.Reason),
+		Module:  "standby",
+		Action:  "standby:" + event.Role,
+	}
+	var alert string
+	switch {
+	case event.Role == service.StandbyStateTakingOver:
+		alert = i18n.Sprintf("alert.standby_took_over", event.Instance, event.Reason)
+	case event.Previous == service.StandbyStatePrimary:
+		alert = i18n.Sprintf("alert.primary_stopped", event.Instance, event.Reason)
+	}
+	if alert != "" {
+		log.Level = "warn"
+		m.hub.BroadcastMessage("alert", service.AlertEvent{
+			Source:  "standby",
+			Level:   "warning",
+			Message: alert,
+		})
+	}
+	if extra, err := json.Marshal(event); err == nil {
+		log.Extra = string(extra)
+	}
+	if err := m.db.Create(log).Error; err != nil {
+		m.logger.WithError(err).Warn("写入系统日志失败")
+	}
+}
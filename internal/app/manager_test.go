@@ -0,0 +1,44 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"userclient/internal/config"
+	"userclient/internal/scanner"
+)
+
+// TestUseHook 键盘钩子禁用时以仅API模式运行；启用时按当前环境能否采集按键自动选择（Windows服务会话、
+// Linux下无法读取输入设备时不可用）
+func TestUseHook(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	if useHook(&config.ScannerConfig{EnableHook: false}, logger) {
+		t.Fatal("disabled hook selected")
+	}
+	if entry := hook.LastEntry(); entry == nil || entry.Level != logrus.InfoLevel {
+		t.Fatalf("log = %+v", entry)
+	}
+
+	hook.Reset()
+	available, reason := scanner.Available()
+	if got := useHook(&config.ScannerConfig{EnableHook: true}, logger); got != available {
+		t.Fatalf("useHook = %v, want %v (%s)", got, available, reason)
+	}
+	if !available {
+		entry := hook.LastEntry()
+		if entry == nil || entry.Level != logrus.WarnLevel || entry.Data["reason"] != reason {
+			t.Fatalf("log = %+v, want warning with reason %q", entry, reason)
+		}
+	}
+
+	// 仅API模式下没有键盘钩子
+	m := &Manager{}
+	if state := m.hookState(); state != "not_configured" {
+		t.Fatalf("hookState = %s", state)
+	}
+	if metrics := m.hookMetrics(); metrics != (scanner.HookMetrics{}) {
+		t.Fatalf("hookMetrics = %+v", metrics)
+	}
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+	"userclient/internal/scanner"
+)
+
+// applyConfig 热加载的配置生效
+//
+// 日志级别、按请求读取的配置（应用环境、认证、管理操作、图片上传）、推送消息模板、接入限流及操作员统计的隐私设置立即生效，
+// 扫码参数只有按键超时、长度范围、终止符变化时推送给按键采集来源后立即生效，其余配置由各组件在启动时读取，变化时提示重启后生效。
+func (m *Manager) applyConfig(next *config.Config) error {
+	level, err := logrus.ParseLevel(next.Log.Level)
+	if err != nil {
+		return fmt.Errorf("日志级别无效: %w", err)
+	}
+
+	// 与启动时一致地关闭只读模式下的功能，避免误报配置已修改
+	next.ApplyReadOnly()
+	if m.reloadScanner(next.Scanner) {
+		m.config.Scanner = next.Scanner
+	}
+	restart := restartRequiredSections(m.config, next)
+
+	applied := *m.config
+	applied.App = next.App
+	applied.App.ReadOnly = m.config.App.ReadOnly
+	applied.Log = next.Log
+	applied.Security = next.Security
+	applied.Admin = next.Admin
+	applied.Images = next.Images
+	applied.Messages = next.Messages
+	applied.Ingestion = next.Ingestion
+	applied.OperatorStats = next.OperatorStats
+	m.logger.SetLevel(level)
+	*m.config = applied
+	i18n.SetDefault(next.App.Locale)
+	if template, ok := next.Messages.Template(); ok {
+		m.barcodeHandler.SetMessageTemplate(template)
+	}
+	m.ingestLimiter.SetConfig(next.Ingestion)
+	m.operatorStats.SetConfig(next.OperatorStats)
+
+	// 键盘钩子由代理安装时，扫码参数推送给代理后立即生效，服务端的时序诊断等仍需重启
+	if m.agent != nil && m.agent.SetScannerConfig(m.resolveScanner(next.Scanner)) {
+		m.logger.Info("扫码参数已推送给键盘钩子代理")
+	}
+
+	if len(restart) > 0 {
+		m.logger.WithField("sections", restart).Warn("以下配置已修改，需重启后生效")
+	}
+	m.logSummary("配置文件热加载后运行摘要")
+	return nil
+}
+
+// reloadScanner 扫码参数只有按键超时、长度范围、终止符变化时推送给按键采集来源，返回是否已生效
+func (m *Manager) reloadScanner(next config.ScannerConfig) bool {
+	if reflect.DeepEqual(m.config.Scanner, next) {
+		return false
+	}
+	resolved := m.resolveScanner(next)
+	if !scanner.SettingsOnly(m.resolveScanner(m.config.Scanner), resolved) {
+		return false
+	}
+
+	m.scannerMu.Lock()
+	defer m.scannerMu.Unlock()
+	if err := m.pushScanner(resolved); err != nil {
+		m.logger.WithError(err).Warn("扫码参数无效，未应用到按键采集来源")
+		return false
+	}
+	previous := m.scannerConfig
+	m.scannerConfig = resolved
+	m.recordScannerUpdate(previous, resolved, "config_file")
+	return true
+}
+
+// restartRequiredSections 已修改但需要重启才能生效的配置段
+func restartRequiredSections(current, next *config.Config) []string {
+	// 使用备用端口时实际端口与配置值不同，不视为修改
+	server := current.Server
+	if server.Port >= next.Server.Port && server.Port <= next.Server.Port+next.Server.PortFallbackRange {
+		server.Port = next.Server.Port
+	}
+
+	sections := []struct {
+		name      string
+		old, next interface{}
+	}{
+		{"server", server, next.Server},
+		{"database", current.Database, next.Database},
+		{"scanner", current.Scanner, next.Scanner},
+		{"websocket", current.WebSocket, next.WebSocket},
+		{"api", current.API, next.API},
+		{"sinks", current.Sinks, next.Sinks},
+		{"sequence", current.Sequence, next.Sequence},
+		{"health", current.Health, next.Health},
+		{"peers", current.Peers, next.Peers},
+		{"idle", current.Idle, next.Idle},
+		{"classification", current.Classification, next.Classification},
+		{"profiles", current.Profiles, next.Profiles},
+		{"commands", current.Commands, next.Commands},
+		{"heartbeat", current.Heartbeat, next.Heartbeat},
+		{"reload", current.Reload, next.Reload},
+		{"central", current.Central, next.Central},
+		{"acks", current.Acks, next.Acks},
+		{"agent", current.Agent, next.Agent},
+		{"preferences", current.Preferences, next.Preferences},
+		{"closing", current.Closing, next.Closing},
+		{"simulator", current.Simulator, next.Simulator},
+		{"metrics_history", current.MetricsHistory, next.MetricsHistory},
+		{"quality", current.Quality, next.Quality},
+		{"recovery", current.Recovery, next.Recovery},
+		{"serial", current.Serial, next.Serial},
+		{"network_scanner", current.NetworkScanner, next.NetworkScanner},
+		{"standby", current.Standby, next.Standby},
+		{"integrity", current.Integrity, next.Integrity},
+		{"coalesce", current.Coalesce, next.Coalesce},
+	}
+
+	var changed []string
+	if current.App.ReadOnly != next.App.ReadOnly {
+		changed = append(changed, "app.read_only")
+	}
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.next) {
+			changed = append(changed, section.name)
+		}
+	}
+	return changed
+}
+
+// recordConfigRollback 配置回滚写入系统日志
+func (m *Manager) recordConfigRollback(event config.RollbackEvent) {
+	extra, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:   "error",
+		Message: fmt.Sprintf("配置文件第 %d 版被拒绝（%d 项），已回滚到第 %d 版", event.Rejected.Version, len(event.Rejections), event.RestoredTo.Version),
+		Module:  "config",
+		Action:  "config:rollback",
+		Extra:   string(extra),
+	}
+	if err := m.db.Create(log).Error; err != nil {
+		m.logger.WithError(err).Warn("写入系统日志失败")
+	}
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+	"userclient/internal/scanner"
+	"userclient/internal/service"
+)
+
+// scannerSettingKeys 配置管理中可在运行中修改的扫码参数，写入后立即推送给按键采集来源
+var scannerSettingKeys = map[string]bool{
+	"scanner.timeout_ms":  true,
+	"scanner.min_length":  true,
+	"scanner.max_length":  true,
+	"scanner.terminators": true,
+}
+
+// UpdateScanner 运行中修改按键超时、长度范围及终止符，不重新安装键盘钩子
+//
+// 合并后的参数无效时返回 scanner.ErrInvalidSettings，按键采集来源保持原参数。修改只在本次运行中有效，
+// 重启后恢复为配置文件中的值。actor 为操作者，写入系统日志。
+func (m *Manager) UpdateScanner(update scanner.SettingsUpdate, actor string) (config.ScannerConfig, error) {
+	m.scannerMu.Lock()
+	defer m.scannerMu.Unlock()
+
+	next := m.scannerConfig
+	next.Terminators = append([]string(nil), m.scannerConfig.Terminators...)
+	update.Apply(&next)
+	if reflect.DeepEqual(next, m.scannerConfig) {
+		return next, nil
+	}
+	if err := m.pushScanner(next); err != nil {
+		return m.scannerConfig, err
+	}
+	previous := m.scannerConfig
+	m.scannerConfig = next
+	m.recordScannerUpdate(previous, next, actor)
+	return next, nil
+}
+
+// ScannerConfig 当前生效的扫码参数（已展开扫码枪参数预设）
+func (m *Manager) ScannerConfig() config.ScannerConfig {
+	m.scannerMu.Lock()
+	defer m.scannerMu.Unlock()
+	return m.scannerConfig
+}
+
+// pushScanner 校验扫码参数并推送给本机按键采集来源及键盘钩子代理
+func (m *Manager) pushScanner(next config.ScannerConfig) error {
+	if err := scanner.ValidateSettings(next); err != nil {
+		return err
+	}
+	if tunable, ok := m.hook.(scanner.TunableSource); ok {
+		if err := tunable.UpdateConfig(next); err != nil {
+			return err
+		}
+	}
+	if m.agent != nil {
+		m.agent.SetScannerConfig(next)
+	}
+	return nil
+}
+
+// onScannerConfigWritten 配置管理中写入扫码参数后立即生效，删除时恢复为配置文件中的值
+func (m *Manager) onScannerConfigWritten(key string) {
+	if !scannerSettingKeys[key] {
+		return
+	}
+	file := m.resolveScanner(m.config.Scanner)
+	update := scanner.SettingsUpdate{}
+	stored, err := m.configs.GetConfiguration(key)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		m.logger.WithError(err).WithField("key", key).Warn("读取扫码参数失败，未应用到按键采集来源")
+		return
+	}
+
+	var parseErr error
+	switch key {
+	case "scanner.terminators":
+		update.Terminators = file.Terminators
+		if stored != nil {
+			update.Terminators = service.ParseTerminatorList(stored.Value)
+		}
+	default:
+		value := map[string]int{
+			"scanner.timeout_ms": file.TimeoutMS,
+			"scanner.min_length": file.MinLength,
+			"scanner.max_length": file.MaxLength,
+		}[key]
+		if stored != nil {
+			value, parseErr = strconv.Atoi(stored.Value)
+		}
+		switch key {
+		case "scanner.timeout_ms":
+			update.TimeoutMS = &value
+		case "scanner.min_length":
+			update.MinLength = &value
+		case "scanner.max_length":
+			update.MaxLength = &value
+		}
+	}
+	if parseErr != nil {
+		m.logger.WithError(parseErr).WithField("key", key).Warn("扫码参数无法解析，未应用到按键采集来源")
+		return
+	}
+
+	if _, err := m.UpdateScanner(update, "config:"+key); err != nil {
+		if errors.Is(err, scanner.ErrInvalidSettings) {
+			m.logger.WithError(err).WithField("key", key).Warn("扫码参数与其他参数冲突，未应用到按键采集来源")
+			return
+		}
+		m.logger.WithError(err).WithField("key", key).Error("应用扫码参数失败")
+	}
+}
+
+// recordScannerUpdate 运行中修改的扫码参数写入系统日志
+func (m *Manager) recordScannerUpdate(previous, next config.ScannerConfig, actor string) {
+	from := scannerSettingsSnapshot(previous)
+	to := scannerSettingsSnapshot(next)
+	extra, err := json.Marshal(map[string]interface{}{"from": from, "to": to, "actor": actor})
+	if err != nil {
+		return
+	}
+	message := fmt.Sprintf("扫码参数已在运行中修改：按键超时 %dms，长度 %d-%d，终止符 %v",
+		next.TimeoutMS, next.MinLength, next.MaxLength, next.Terminators)
+	log := &models.SystemLog{
+		Level:   "info",
+		Message: message,
+		Module:  "scanner",
+		Action:  "scanner:settings",
+		Extra:   string(extra),
+	}
+	if err := m.db.Create(log).Error; err != nil {
+		m.logger.WithError(err).Warn("写入系统日志失败")
+	}
+	m.logger.WithFields(logrus.Fields{"from": from, "to": to, "actor": actor}).Info(message)
+	m.scannerGuard.Check("config_change")
+}
+
+// scannerSettingsSnapshot 运行中可修改的扫码参数
+func scannerSettingsSnapshot(cfg config.ScannerConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"timeout_ms":  cfg.TimeoutMS,
+		"min_length":  cfg.MinLength,
+		"max_length":  cfg.MaxLength,
+		"terminators": cfg.Terminators,
+	}
+}
+
+// loadDeviceTimeouts 按设备表重新设置各设备的按键超时，未识别出设备的扫码按本工位的活跃设备计
+func loadDeviceTimeouts(devices *service.DeviceService, timeouts *scanner.AdaptiveTimeouts, logger *logrus.Logger) {
+	rows, err := devices.GetTimeoutDevices()
+	if err != nil {
+		logger.WithError(err).Warn("查询设备按键超时失败，沿用之前的设置")
+		return
+	}
+	settings := make([]scanner.DeviceTimeout, 0, len(rows))
+	for _, device := range rows {
+		settings = append(settings, scanner.DeviceTimeout{
+			DeviceID:  device.ID,
+			ManualMS:  device.TimeoutMS,
+			Adaptive:  device.AdaptiveTimeout,
+			LearnedMS: device.LearnedTimeoutMS,
+			Samples:   device.TimeoutSamples,
+		})
+	}
+	var defaultID uint
+	if active, err := devices.GetActiveDevice(); err == nil {
+		defaultID = active.ID
+	}
+	timeouts.Configure(settings, defaultID)
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// Summary 启动/停止摘要，便于在服务日志中确认运行状态
+type Summary struct {
+	Version          string
+	Env              string
+	ReadOnly         bool
+	Station          string
+	HTTP             string
+	WebSocket        string
+	Database         string
+	DSN              string
+	MigrationVersion int
+	SecondaryDSN     string
+	LogOutput        string
+	Sources          []string
+	Sinks            []string
+	Auth             string
+	Devices          int64
+	HookActive       bool
+	Maintenance      bool
+	Recovery         string // 上次非正常退出时的恢复结果，正常停止时为空
+	Uptime           time.Duration
+}
+
+// summaryField 摘要中的一行
+type summaryField struct {
+	key   string
+	value interface{}
+}
+
+// fields 按输出顺序排列的摘要字段
+func (s Summary) fields() []summaryField {
+	fields := []summaryField{
+		{"version", s.Version},
+		{"env", s.Env},
+		{"read_only", s.ReadOnly},
+		{"station", s.Station},
+		{"http", s.HTTP},
+		{"websocket", s.WebSocket},
+		{"database", s.Database},
+		{"dsn", s.DSN},
+		{"migration_version", s.MigrationVersion},
+	}
+	if s.SecondaryDSN != "" {
+		fields = append(fields, summaryField{"secondary_dsn", s.SecondaryDSN})
+	}
+	fields = append(fields,
+		summaryField{"log", s.LogOutput},
+		summaryField{"sources", strings.Join(s.Sources, ",")},
+		summaryField{"sinks", strings.Join(s.Sinks, ",")},
+		summaryField{"auth", s.Auth},
+		summaryField{"devices", s.Devices},
+		summaryField{"hook_active", s.HookActive},
+		summaryField{"maintenance", s.Maintenance},
+	)
+	if s.Recovery != "" {
+		fields = append(fields, summaryField{"recovery", s.Recovery})
+	}
+	if s.Uptime > 0 {
+		fields = append(fields, summaryField{"uptime", s.Uptime.Round(time.Second).String()})
+	}
+	return fields
+}
+
+// Fields 以 logrus 字段输出摘要
+func (s Summary) Fields() logrus.Fields {
+	result := make(logrus.Fields)
+	for _, field := range s.fields() {
+		result[field.key] = field.value
+	}
+	return result
+}
+
+// Text 以纯文本输出摘要，每行一个字段
+func (s Summary) Text() string {
+	var b strings.Builder
+	for _, field := range s.fields() {
+		fmt.Fprintf(&b, "%-18s %v\n", field.key+":", field.value)
+	}
+	return b.String()
+}
+
+// Summary 生成当前运行状态摘要，敏感值已脱敏
+func (m *Manager) Summary() Summary {
+	cfg := m.config
+
+	summary := Summary{
+		Version:     cfg.App.Version,
+		Env:         cfg.App.Env,
+		ReadOnly:    cfg.App.ReadOnly,
+		Station:     cfg.Peers.Station,
+		HTTP:        fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+		WebSocket:   fmt.Sprintf(":%d%s", cfg.Server.Port, cfg.WebSocket.Path),
+		Database:    cfg.Database.Type,
+		DSN:         config.RedactDSN(cfg.Database.DSN),
+		LogOutput:   cfg.Log.Output,
+		Auth:        authMode(cfg.Security.EnableAuth, cfg.Security.APIKey != ""),
+		HookActive:  m.hook != nil && m.hook.IsRunning(),
+		Maintenance: m.maintenance != nil && m.maintenance.IsActive(),
+	}
+	if cfg.Log.Output == "file" {
+		summary.LogOutput = "file:" + cfg.Log.FilePath
+	}
+	if cfg.Database.Secondary.Enable {
+		summary.SecondaryDSN = config.RedactDSN(cfg.Database.Secondary.DSN)
+	}
+	if !m.startedAt.IsZero() {
+		summary.Uptime = time.Since(m.startedAt)
+	}
+	if m.recovery != nil {
+		if recovery := m.recovery.Summary(); recovery != nil && recovery.Unclean {
+			location, _ := cfg.App.Location()
+			summary.Recovery = recovery.Text(location)
+		}
+	}
+
+	if m.db != nil {
+		if version, err := m.db.MigrationVersion(); err == nil {
+			summary.MigrationVersion = version
+		}
+		m.db.Model(&models.Device{}).Where("is_active = ?", true).Count(&summary.Devices)
+	}
+
+	// 扫码来源，只读模式下不接收扫码
+	summary.Sources = []string{"http_submit"}
+	if m.hook != nil {
+		summary.Sources = []string{"keyboard_hook", "http_submit"}
+	}
+	if m.serial != nil {
+		summary.Sources = append(summary.Sources, "serial")
+	}
+	if m.network != nil {
+		summary.Sources = append(summary.Sources, "network:"+cfg.NetworkScanner.Listen)
+	}
+	if cfg.Agent.Enable {
+		summary.Sources = append(summary.Sources, "agent")
+	}
+	if cfg.App.ReadOnly {
+		summary.Sources = []string{"none"}
+	}
+	if cfg.Peers.Enable {
+		summary.Sources = append(summary.Sources, "peers")
+	}
+
+	// 事件输出端
+	summary.Sinks = []string{"websocket"}
+	if cfg.Sinks.Outbox.Enable {
+		summary.Sinks = append(summary.Sinks, "outbox")
+	}
+	if cfg.Sinks.File.Enable {
+		summary.Sinks = append(summary.Sinks, "file:"+cfg.Sinks.File.Dir)
+	}
+	if cfg.Images.Enable {
+		summary.Sinks = append(summary.Sinks, "images:"+cfg.Images.Dir)
+	}
+	if cfg.Peers.Enable {
+		for _, target := range cfg.Peers.Targets {
+			summary.Sinks = append(summary.Sinks, "peer:"+target.Name+"@"+config.RedactURL(target.URL))
+		}
+	}
+	return summary
+}
+
+// logSummary 输出运行状态摘要
+func (m *Manager) logSummary(message string) {
+	m.logger.WithFields(m.Summary().Fields()).Info(message)
+}
+
+// authMode 认证方式
+func authMode(enabled, hasAPIKey bool) string {
+	switch {
+	case !enabled:
+		return "disabled"
+	case hasAPIKey:
+		return "api_key+tokens"
+	default:
+		return "tokens"
+	}
+}
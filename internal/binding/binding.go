@@ -0,0 +1,242 @@
+// Package binding 查询参数绑定
+//
+// 列表及统计接口共用的分页（page、page_size、limit）、时间范围（from、to）及排序（sort）参数，
+// 各接口的取值范围与校验一致；参数无效时返回 *FieldError，由 Respond 统一返回400及参数名。
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 分页默认值
+const (
+	DefaultPageSize = 20  // 未指定 page_size 时的每页条数
+	MaxPageSize     = 100 // page_size 的上限
+)
+
+// dayLayout 日期参数的格式
+const dayLayout = "2006-01-02"
+
+// FieldError 查询参数无效
+type FieldError struct {
+	Field   string // 参数名
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// invalid 创建参数无效错误
+func invalid(field, format string, args ...interface{}) *FieldError {
+	return &FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// Respond 参数无效时返回400，响应中 field 为参数名
+func Respond(c *gin.Context, err error) {
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "field": fieldErr.Field, "message": fieldErr.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+}
+
+// Pagination 分页参数
+type Pagination struct {
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+}
+
+// Offset 跳过的记录数
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// BindPagination 解析 page（从1开始，默认1）及 page_size（1~MaxPageSize，默认 DefaultPageSize）
+func BindPagination(c *gin.Context) (Pagination, error) {
+	page, err := positiveInt(c, "page", 1, 0)
+	if err != nil {
+		return Pagination{}, err
+	}
+	pageSize, err := positiveInt(c, "page_size", DefaultPageSize, MaxPageSize)
+	if err != nil {
+		return Pagination{}, err
+	}
+	return Pagination{Page: page, PageSize: pageSize}, nil
+}
+
+// BindLimit 解析 limit（1~max，默认 def），用于只返回最近若干条的接口
+func BindLimit(c *gin.Context, def, max int) (int, error) {
+	return positiveInt(c, "limit", def, max)
+}
+
+// positiveInt 解析正整数参数，max 为0表示不限上限
+func positiveInt(c *gin.Context, field string, def, max int) (int, error) {
+	value := c.Query(field)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, invalid(field, "应为整数")
+	}
+	if n < 1 {
+		return 0, invalid(field, "不能小于1")
+	}
+	if max > 0 && n > max {
+		return 0, invalid(field, "不能大于%d", max)
+	}
+	return n, nil
+}
+
+// DateRange 时间范围 [From, To)，零值的一端表示不限
+//
+// from、to 接受RFC3339时间或 yyyy-mm-dd 日期，日期按绑定时的时区解释：from 为当天零点，
+// to 为次日零点（包含当天）。
+type DateRange struct {
+	From time.Time
+	To   time.Time
+	loc  *time.Location
+}
+
+// BindDateRange 解析 from/to，未指定的 to 为当前时间，未指定的 from 为 to 之前 span
+func BindDateRange(c *gin.Context, loc *time.Location, span time.Duration) (DateRange, error) {
+	r, err := BindOptionalDateRange(c, loc)
+	if err != nil {
+		return DateRange{}, err
+	}
+	if r.To.IsZero() {
+		r.To = time.Now()
+	}
+	if r.From.IsZero() {
+		r.From = r.To.Add(-span)
+	}
+	// 只指定 from 时与默认的 to 比较
+	if r.From.After(r.To) {
+		return DateRange{}, invalid("from", "不能晚于 to")
+	}
+	return r, nil
+}
+
+// BindOptionalDateRange 解析 from/to，未指定的一端为零值（不限）
+func BindOptionalDateRange(c *gin.Context, loc *time.Location) (DateRange, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	r := DateRange{loc: loc}
+	var err error
+	if r.From, _, err = parseTime(c, "from", loc, false); err != nil {
+		return DateRange{}, err
+	}
+	var toDay bool
+	if r.To, toDay, err = parseTime(c, "to", loc, true); err != nil {
+		return DateRange{}, err
+	}
+	if !r.From.IsZero() && !r.To.IsZero() {
+		last := r.To
+		if toDay {
+			// to 为日期时与当天最后时刻比较，from、to 为同一天时合法
+			last = last.Add(-time.Nanosecond)
+		}
+		if r.From.After(last) {
+			return DateRange{}, invalid("from", "不能晚于 to")
+		}
+	}
+	return r, nil
+}
+
+// BindDayRange 解析按生产日查询的 from/to，返回首尾日期（yyyy-mm-dd，均包含）；
+// 未指定的 to 为 last 所在日期，未指定的 from 为 to 之前共 days 天
+func BindDayRange(c *gin.Context, loc *time.Location, last time.Time, days int) (string, string, error) {
+	r, err := BindOptionalDateRange(c, loc)
+	if err != nil {
+		return "", "", err
+	}
+	if r.To.IsZero() {
+		y, m, d := last.In(r.loc).Date()
+		r.To = time.Date(y, m, d+1, 0, 0, 0, 0, r.loc)
+	}
+	if r.From.IsZero() {
+		r.From = r.To.AddDate(0, 0, -days)
+	}
+	if r.From.After(r.To) {
+		return "", "", invalid("from", "不能晚于 to")
+	}
+	from, to := r.Days()
+	return from, to, nil
+}
+
+// Days 范围覆盖的第一个及最后一个日期（yyyy-mm-dd），用于按生产日查询的接口；须先绑定且两端都已确定
+func (r DateRange) Days() (string, string) {
+	loc := r.loc
+	if loc == nil {
+		loc = time.Local
+	}
+	last := r.To
+	if last.After(r.From) {
+		// To 不包含在范围内
+		last = last.Add(-time.Nanosecond)
+	}
+	return r.From.In(loc).Format(dayLayout), last.In(loc).Format(dayLayout)
+}
+
+// parseTime 解析RFC3339时间或日期，end 为 true 时日期取次日零点；isDay 表示参数为日期
+func parseTime(c *gin.Context, field string, loc *time.Location, end bool) (t time.Time, isDay bool, err error) {
+	value := c.Query(field)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	day, err := time.ParseInLocation(dayLayout, value, loc)
+	if err != nil {
+		return time.Time{}, false, invalid(field, "应为RFC3339时间或 yyyy-mm-dd 日期")
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, true, nil
+}
+
+// Sort 排序参数
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// BindSort 解析 sort（字段名，前缀 - 表示降序，如 -created_at），只接受 allowed 中的字段，未指定时返回 def
+func BindSort(c *gin.Context, def Sort, allowed ...string) (Sort, error) {
+	value := c.Query("sort")
+	if value == "" {
+		return def, nil
+	}
+	sort := Sort{Field: strings.TrimPrefix(value, "-"), Desc: strings.HasPrefix(value, "-")}
+	for _, field := range allowed {
+		if sort.Field == field {
+			return sort, nil
+		}
+	}
+	return Sort{}, invalid("sort", "只能按 %s 排序", strings.Join(allowed, "、"))
+}
+
+// Clause SQL 排序子句，字段已按白名单校验；按 id 补充排序使翻页稳定
+func (s Sort) Clause() string {
+	direction := "ASC"
+	if s.Desc {
+		direction = "DESC"
+	}
+	if s.Field == "id" {
+		return "id " + direction
+	}
+	return s.Field + " " + direction + ", id " + direction
+}
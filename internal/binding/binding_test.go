@@ -0,0 +1,276 @@
+package binding
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testContext 带查询参数的请求上下文
+func testContext(query string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	return c, w
+}
+
+// checkField 参数无效时返回的 *FieldError 指向 field，field 为空表示应无错误
+func checkField(t *testing.T, err error, field string) {
+	t.Helper()
+	if field == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("err = %v, want *FieldError for %s", err, field)
+	}
+	if fieldErr.Field != field {
+		t.Fatalf("invalid field = %s (%v), want %s", fieldErr.Field, err, field)
+	}
+}
+
+func TestBindPagination(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   Pagination
+		offset int
+		field  string // 无效的参数名
+	}{
+		{query: "", want: Pagination{Page: 1, PageSize: DefaultPageSize}},
+		{query: "page=3&page_size=50", want: Pagination{Page: 3, PageSize: 50}, offset: 100},
+		{query: "page=1&page_size=1", want: Pagination{Page: 1, PageSize: 1}},
+		{query: "page=100000", want: Pagination{Page: 100000, PageSize: DefaultPageSize}, offset: 99999 * DefaultPageSize},
+		{query: "page_size=100", want: Pagination{Page: 1, PageSize: MaxPageSize}},
+		{query: "page_size=101", field: "page_size"},
+		{query: "page_size=0", field: "page_size"},
+		{query: "page=0", field: "page"},
+		{query: "page=-1", field: "page"},
+		{query: "page=abc", field: "page"},
+		{query: "page=1.5", field: "page"},
+		{query: "page=2&page_size=x", field: "page_size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := testContext(tt.query)
+			got, err := BindPagination(c)
+			checkField(t, err, tt.field)
+			if tt.field != "" {
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("pagination = %+v, want %+v", got, tt.want)
+			}
+			if got.Offset() != tt.offset {
+				t.Fatalf("offset = %d, want %d", got.Offset(), tt.offset)
+			}
+		})
+	}
+}
+
+func TestBindLimit(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+		field string
+	}{
+		{query: "", want: 10},
+		{query: "limit=1", want: 1},
+		{query: "limit=50", want: 50},
+		{query: "limit=51", field: "limit"},
+		{query: "limit=0", field: "limit"},
+		{query: "limit=ten", field: "limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := testContext(tt.query)
+			got, err := BindLimit(c, 10, 50)
+			checkField(t, err, tt.field)
+			if tt.field == "" && got != tt.want {
+				t.Fatalf("limit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindOptionalDateRange(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, loc) }
+	tests := []struct {
+		name     string
+		query    string
+		from, to time.Time
+		field    string
+	}{
+		{name: "unbounded", query: ""},
+		{name: "from day", query: "from=2026-10-15", from: day(15)},
+		{name: "to day includes the whole day", query: "to=2026-10-15", to: day(16)},
+		{name: "same day", query: "from=2026-10-15&to=2026-10-15", from: day(15), to: day(16)},
+		{name: "day range", query: "from=2026-10-01&to=2026-10-15", from: day(1), to: day(16)},
+		{
+			name:  "rfc3339",
+			query: "from=2026-10-15T01:00:00Z&to=2026-10-15T12:30:00%2B08:00",
+			from:  time.Date(2026, 10, 15, 1, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 10, 15, 4, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "empty instant",
+			query: "from=2026-10-15T01:00:00Z&to=2026-10-15T01:00:00Z",
+			from:  time.Date(2026, 10, 15, 1, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 10, 15, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "rfc3339 from within to day",
+			query: "from=2026-10-15T23:59:59%2B08:00&to=2026-10-15",
+			from:  time.Date(2026, 10, 15, 15, 59, 59, 0, time.UTC),
+			to:    day(16),
+		},
+		{name: "reversed days", query: "from=2026-10-16&to=2026-10-15", field: "from"},
+		{name: "reversed rfc3339", query: "from=2026-10-15T02:00:00Z&to=2026-10-15T01:00:00Z", field: "from"},
+		{name: "from after to day", query: "from=2026-10-16T00:00:00%2B08:00&to=2026-10-15", field: "from"},
+		{name: "invalid from", query: "from=15/10/2026", field: "from"},
+		{name: "invalid to", query: "from=2026-10-15&to=yesterday", field: "to"},
+		{name: "invalid date", query: "to=2026-02-30", field: "to"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testContext(tt.query)
+			got, err := BindOptionalDateRange(c, loc)
+			checkField(t, err, tt.field)
+			if tt.field != "" {
+				return
+			}
+			if !got.From.Equal(tt.from) || !got.To.Equal(tt.to) {
+				t.Fatalf("range = [%v, %v), want [%v, %v)", got.From, got.To, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestBindDateRangeDefaults(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := 24 * time.Hour
+
+	c, _ := testContext("")
+	before := time.Now()
+	r, err := BindDateRange(c, loc, span)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.To.Before(before) || r.To.After(time.Now()) {
+		t.Fatalf("default to = %v, want now", r.To)
+	}
+	if got := r.To.Sub(r.From); got != span {
+		t.Fatalf("default span = %v, want %v", got, span)
+	}
+
+	c, _ = testContext("to=2026-10-15")
+	if r, err = BindDateRange(c, loc, span); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 10, 15, 0, 0, 0, 0, loc); !r.From.Equal(want) {
+		t.Fatalf("from = %v, want one span before to: %v", r.From, want)
+	}
+
+	// 只指定 from 时与默认的 to（当前时间）比较
+	c, _ = testContext("from=" + time.Now().Add(time.Hour).Format(time.RFC3339))
+	_, err = BindDateRange(c, loc, span)
+	checkField(t, err, "from")
+}
+
+func TestBindDayRange(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 上海时间 2026-10-15 23:00
+	last := time.Date(2026, 10, 15, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query    string
+		from, to string
+		field    string
+	}{
+		{query: "", from: "2026-10-09", to: "2026-10-15"},
+		{query: "to=2026-10-01", from: "2026-09-25", to: "2026-10-01"},
+		{query: "from=2026-10-14", from: "2026-10-14", to: "2026-10-15"},
+		{query: "from=2026-10-15&to=2026-10-15", from: "2026-10-15", to: "2026-10-15"},
+		{query: "from=2026-10-20", field: "from"},
+		{query: "from=2026-10-15&to=2026-10-14", field: "from"},
+		{query: "to=10-15", field: "to"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := testContext(tt.query)
+			from, to, err := BindDayRange(c, loc, last, 7)
+			checkField(t, err, tt.field)
+			if tt.field == "" && (from != tt.from || to != tt.to) {
+				t.Fatalf("days = %s..%s, want %s..%s", from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestBindSort(t *testing.T) {
+	def := Sort{Field: "created_at", Desc: true}
+	tests := []struct {
+		query  string
+		want   Sort
+		clause string
+		field  string
+	}{
+		{query: "", want: def, clause: "created_at DESC, id DESC"},
+		{query: "sort=content", want: Sort{Field: "content"}, clause: "content ASC, id ASC"},
+		{query: "sort=-id", want: Sort{Field: "id", Desc: true}, clause: "id DESC"},
+		{query: "sort=password", field: "sort"},
+		{query: "sort=content%20desc", field: "sort"},
+		{query: "sort=--content", field: "sort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := testContext(tt.query)
+			got, err := BindSort(c, def, "id", "created_at", "content")
+			checkField(t, err, tt.field)
+			if tt.field != "" {
+				return
+			}
+			if got != tt.want || got.Clause() != tt.clause {
+				t.Fatalf("sort = %+v (%s), want %+v (%s)", got, got.Clause(), tt.want, tt.clause)
+			}
+		})
+	}
+}
+
+// TestRespond 参数无效时返回400并指出参数名
+func TestRespond(t *testing.T) {
+	c, w := testContext("page_size=1000")
+	_, err := BindPagination(c)
+	Respond(c, err)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var body struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Field != "page_size" || body.Message != "page_size 不能大于100" {
+		t.Fatalf("response = %+v", body)
+	}
+}
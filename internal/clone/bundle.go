@@ -0,0 +1,156 @@
+package clone
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// maxEntryBytes 导出包中除扫码记录数据库外单个文件的大小上限
+const maxEntryBytes = 64 << 20
+
+// Bundle 读取后的导出包，扫码记录数据库解压到临时目录
+type Bundle struct {
+	Manifest       Manifest
+	Config         []byte
+	Configurations []models.Configuration
+	Devices        []models.Device
+	Profiles       []models.ScannerProfile
+	Rules          []models.ClassificationRule
+
+	dir     string
+	records string // 解压后的扫码记录数据库，未附带时为空
+}
+
+// Open 读取导出包，使用后须调用 Close 删除临时文件
+func Open(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开导出包失败: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("导出包格式错误: %w", err)
+	}
+	defer gz.Close()
+
+	dir, err := os.MkdirTemp("", "scanner-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	bundle := &Bundle{dir: dir}
+	if err := bundle.read(tar.NewReader(gz)); err != nil {
+		bundle.Close()
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// read 逐个读取导出包文件，未知文件忽略
+func (b *Bundle) read(archive *tar.Reader) error {
+	seen := make(map[string]bool)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取导出包失败: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		seen[header.Name] = true
+
+		if header.Name == recordsFile {
+			b.records = filepath.Join(b.dir, recordsFile)
+			if err := extract(archive, b.records); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var target interface{}
+		switch header.Name {
+		case manifestFile:
+			target = &b.Manifest
+		case configFile:
+			data, err := io.ReadAll(io.LimitReader(archive, maxEntryBytes))
+			if err != nil {
+				return fmt.Errorf("读取 %s 失败: %w", header.Name, err)
+			}
+			b.Config = data
+			continue
+		case configurationsFile:
+			target = &b.Configurations
+		case devicesFile:
+			target = &b.Devices
+		case profilesFile:
+			target = &b.Profiles
+		case rulesFile:
+			target = &b.Rules
+		default:
+			continue
+		}
+		if err := json.NewDecoder(io.LimitReader(archive, maxEntryBytes)).Decode(target); err != nil {
+			return fmt.Errorf("解析 %s 失败: %w", header.Name, err)
+		}
+	}
+
+	for _, name := range []string{manifestFile, configFile} {
+		if !seen[name] {
+			return fmt.Errorf("导出包不完整: 缺少 %s", name)
+		}
+	}
+	if b.Manifest.IncludeRecords && b.records == "" {
+		return fmt.Errorf("导出包不完整: 缺少 %s", recordsFile)
+	}
+	return nil
+}
+
+// extract 解压文件到临时目录
+func extract(r io.Reader, path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("解压 %s 失败: %w", filepath.Base(path), err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("解压 %s 失败: %w", filepath.Base(path), err)
+	}
+	return f.Close()
+}
+
+// Close 删除解压的临时文件
+func (b *Bundle) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+// Check 检查导出包与本程序是否兼容，导出程序较新时须先升级本机程序
+func (b *Bundle) Check() error {
+	switch {
+	case b.Manifest.FormatVersion == 0:
+		return fmt.Errorf("导出包缺少格式版本，不是有效的工位状态导出包")
+	case b.Manifest.FormatVersion > FormatVersion:
+		return fmt.Errorf("导出包格式版本 %d 高于本程序支持的 %d，请先升级本机程序", b.Manifest.FormatVersion, FormatVersion)
+	case b.Manifest.SchemaVersion > database.SchemaVersion:
+		return fmt.Errorf("导出时数据库结构版本 %d 高于本程序的 %d（导出程序 %s），请先升级本机程序",
+			b.Manifest.SchemaVersion, database.SchemaVersion, b.Manifest.AppVersion)
+	}
+	return nil
+}
+
+// NeedsMigration 导出时数据库结构较旧，导入后须执行迁移
+func (b *Bundle) NeedsMigration() bool {
+	return b.Manifest.SchemaVersion < database.SchemaVersion
+}
@@ -0,0 +1,247 @@
+package clone
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// FormatVersion 导出包格式版本，格式不兼容时递增
+const FormatVersion = 1
+
+// 导出包中的文件
+const (
+	manifestFile       = "manifest.json"
+	configFile         = "config.yaml"
+	configurationsFile = "configurations.json"
+	devicesFile        = "devices.json"
+	profilesFile       = "profiles.json"
+	rulesFile          = "rules.json"
+	recordsFile        = "records.db"
+)
+
+// Manifest 导出包说明
+type Manifest struct {
+	FormatVersion  int            `json:"format_version"`
+	GeneratedAt    time.Time      `json:"generated_at"`
+	Host           string         `json:"host"`           // 导出工位的主机名
+	Station        string         `json:"station"`        // peers.station
+	AppVersion     string         `json:"app_version"`    // 导出工位的程序版本
+	SchemaVersion  int            `json:"schema_version"` // 导出时的数据库结构版本
+	IncludeRecords bool           `json:"include_records"`
+	Counts         map[string]int `json:"counts"`
+	Secrets        []string       `json:"secrets,omitempty"`        // 配置文件中已清空的密钥，导入后须重新填写
+	StoredSecrets  []string       `json:"stored_secrets,omitempty"` // 配置表中已清空的敏感配置，导入时保留本机的值
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	ConfigPath     string
+	Config         *config.Config
+	DB             *database.DB
+	IncludeRecords bool // 附带完整的扫码记录数据库
+}
+
+// Export 导出工位状态为 tar.gz：配置文件、配置表、设备、扫码枪配置档案及分类规则，可选附带扫码记录数据库
+//
+// 配置文件及配置表中的密钥不导出，在新工位重新生成或重新填写；API令牌同样不导出，须在新工位重新签发。
+func Export(w io.Writer, opts ExportOptions) (*Manifest, error) {
+	manifest := &Manifest{
+		FormatVersion:  FormatVersion,
+		GeneratedAt:    time.Now(),
+		Station:        opts.Config.Peers.Station,
+		AppVersion:     opts.Config.App.Version,
+		IncludeRecords: opts.IncludeRecords,
+		Counts:         make(map[string]int),
+	}
+	manifest.Host, _ = os.Hostname()
+
+	version, err := opts.DB.MigrationVersion()
+	if err != nil {
+		return nil, err
+	}
+	manifest.SchemaVersion = version
+
+	raw, err := os.ReadFile(opts.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	stripped, secrets, err := config.RewriteSecrets(raw, func(key, value string) (string, bool) {
+		return "", value != ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest.Secrets = secrets
+
+	var configurations []models.Configuration
+	if err := readRows(opts.DB, &configurations, "key", "系统配置"); err != nil {
+		return nil, err
+	}
+	for i := range configurations {
+		if config.IsSensitiveKey(configurations[i].Key) && configurations[i].Value != "" {
+			configurations[i].Value = ""
+			manifest.StoredSecrets = append(manifest.StoredSecrets, configurations[i].Key)
+		}
+	}
+	var devices []models.Device
+	if err := readRows(opts.DB, &devices, "id", "设备"); err != nil {
+		return nil, err
+	}
+	var profiles []models.ScannerProfile
+	if err := readRows(opts.DB, &profiles, "id", "扫码枪配置档案"); err != nil {
+		return nil, err
+	}
+	var rules []models.ClassificationRule
+	if err := readRows(opts.DB, &rules, "priority, id", "分类规则"); err != nil {
+		return nil, err
+	}
+	manifest.Counts[configurationsFile] = len(configurations)
+	manifest.Counts[devicesFile] = len(devices)
+	manifest.Counts[profilesFile] = len(profiles)
+	manifest.Counts[rulesFile] = len(rules)
+	if opts.IncludeRecords {
+		var count int64
+		if err := opts.DB.Model(&models.BarcodeRecord{}).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("统计扫码记录失败: %w", err)
+		}
+		manifest.Counts[recordsFile] = int(count)
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := &archiveWriter{tar: tar.NewWriter(gz), at: manifest.GeneratedAt}
+
+	// 清单放在最前，导入时先检查版本
+	archive.json(manifestFile, manifest)
+	archive.file(configFile, stripped)
+	archive.json(configurationsFile, configurations)
+	archive.json(devicesFile, devices)
+	archive.json(profilesFile, profiles)
+	archive.json(rulesFile, rules)
+	if opts.IncludeRecords {
+		archive.records(opts.DB, manifest.StoredSecrets)
+	}
+	if archive.err != nil {
+		return nil, archive.err
+	}
+	if err := archive.tar.Close(); err != nil {
+		return nil, fmt.Errorf("写入导出包失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("写入导出包失败: %w", err)
+	}
+	return manifest, nil
+}
+
+// readRows 读取整张表，表不存在（数据库尚未迁移到包含该表的版本）时为空
+func readRows(db *database.DB, rows interface{}, order, name string) error {
+	if !db.Migrator().HasTable(rows) {
+		return nil
+	}
+	if err := db.Order(order).Find(rows).Error; err != nil {
+		return fmt.Errorf("读取%s失败: %w", name, err)
+	}
+	return nil
+}
+
+// archiveWriter 逐个写入导出包文件，出错后忽略后续写入
+type archiveWriter struct {
+	tar *tar.Writer
+	at  time.Time
+	err error
+}
+
+// json 写入JSON文件
+func (a *archiveWriter) json(name string, value interface{}) {
+	if a.err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		a.err = fmt.Errorf("生成 %s 失败: %w", name, err)
+		return
+	}
+	a.file(name, data)
+}
+
+// file 写入文件内容
+func (a *archiveWriter) file(name string, data []byte) {
+	if a.err != nil {
+		return
+	}
+	header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: a.at}
+	if err := a.tar.WriteHeader(header); err != nil {
+		a.err = fmt.Errorf("写入 %s 失败: %w", name, err)
+		return
+	}
+	if _, err := a.tar.Write(data); err != nil {
+		a.err = fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+}
+
+// records 以 VACUUM INTO 生成一致的数据库副本后写入，服务运行期间也可导出；副本中的敏感配置同样清空
+func (a *archiveWriter) records(db *database.DB, storedSecrets []string) {
+	if a.err != nil {
+		return
+	}
+	dir, err := os.MkdirTemp("", "scanner-export-*")
+	if err != nil {
+		a.err = fmt.Errorf("创建临时目录失败: %w", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, recordsFile)
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		a.err = fmt.Errorf("复制扫码记录数据库失败: %w", err)
+		return
+	}
+	if err := clearStoredSecrets(path, storedSecrets); err != nil {
+		a.err = err
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		a.err = fmt.Errorf("读取数据库副本失败: %w", err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		a.err = fmt.Errorf("读取数据库副本失败: %w", err)
+		return
+	}
+	header := &tar.Header{Name: recordsFile, Mode: 0600, Size: info.Size(), ModTime: a.at}
+	if err := a.tar.WriteHeader(header); err != nil {
+		a.err = fmt.Errorf("写入 %s 失败: %w", recordsFile, err)
+		return
+	}
+	if _, err := io.Copy(a.tar, f); err != nil {
+		a.err = fmt.Errorf("写入 %s 失败: %w", recordsFile, err)
+	}
+}
+
+// clearStoredSecrets 清空数据库副本中的敏感配置
+func clearStoredSecrets(path string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	db, err := database.New(&config.DatabaseConfig{DSN: path, LogLevel: "silent", MaxIdleConns: 1, MaxOpenConns: 1})
+	if err != nil {
+		return fmt.Errorf("打开数据库副本失败: %w", err)
+	}
+	defer db.Close()
+	if err := db.Model(&models.Configuration{}).Where("key IN ?", keys).Update("value", "").Error; err != nil {
+		return fmt.Errorf("清空数据库副本中的敏感配置失败: %w", err)
+	}
+	return nil
+}
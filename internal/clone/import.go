@@ -0,0 +1,748 @@
+package clone
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// 导入项的类型
+const (
+	KindConfigFile    = "config_file"
+	KindRecords       = "records"
+	KindConfiguration = "configuration"
+	KindProfile       = "profile"
+	KindDevice        = "device"
+	KindRule          = "rule"
+)
+
+// 导入项的处理方式
+const (
+	ActionCreate    = "create"    // 本机不存在，直接导入
+	ActionUpdate    = "update"    // 本机已存在且内容不同，确认后覆盖
+	ActionUnchanged = "unchanged" // 与本机一致
+	ActionSkip      = "skip"      // 保留本机的内容
+)
+
+// jwtSecretKey 每台工位独立的签名密钥，导入时重新生成
+const jwtSecretKey = "security.jwt_secret"
+
+// Item 导入项
+type Item struct {
+	Kind   string `json:"kind"`
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+
+	apply func(tx *gorm.DB) error
+}
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	ConfigPath string // 本机配置文件路径
+	DryRun     bool   // 只列出导入计划，不做任何修改
+	// Confirm 本机已存在且内容不同时是否覆盖，为nil表示全部保留本机的内容
+	Confirm func(item Item) bool
+	Logger  *logrus.Logger
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	DryRun      bool     `json:"dry_run"`
+	Items       []Item   `json:"items"`
+	Secrets     []string `json:"secrets,omitempty"`     // 须在本机重新填写的配置项
+	FromVersion int      `json:"from_version"`          // 导入前的数据库结构版本
+	ToVersion   int      `json:"to_version"`            // 导入后的数据库结构版本
+	Backups     []string `json:"backups,omitempty"`     // 覆盖前备份的文件
+	AppVersion  string   `json:"app_version,omitempty"` // 导出程序版本与本机不同时记录
+}
+
+// importer 一次导入的状态
+type importer struct {
+	bundle   *Bundle
+	opts     ImportOptions
+	result   *ImportResult
+	suffix   string // 备份文件后缀
+	replaced bool   // 本机数据库已替换为导出包中的数据库
+
+	profileIDs map[uint]uint // 导出包中的配置档案ID -> 本机ID
+}
+
+// Import 将导出包导入本机：配置文件、扫码记录数据库（如附带）、配置表、配置档案、设备及分类规则
+//
+// 导入前须停止本机服务。本机已存在且内容不同的条目经 Confirm 确认后才覆盖，被覆盖的配置文件及数据库先备份。
+// 导出时数据库结构较旧时导入后执行迁移；配置文件中的签名密钥为本机重新生成，其余密钥须重新填写。
+func Import(bundle *Bundle, opts ImportOptions) (*ImportResult, error) {
+	if err := bundle.Check(); err != nil {
+		return nil, err
+	}
+	if opts.Logger == nil {
+		opts.Logger = logrus.StandardLogger()
+	}
+	im := &importer{
+		bundle:     bundle,
+		opts:       opts,
+		result:     &ImportResult{DryRun: opts.DryRun, ToVersion: database.SchemaVersion},
+		suffix:     ".bak-" + time.Now().Format("20060102-150405"),
+		profileIDs: make(map[uint]uint),
+	}
+	for _, key := range bundle.Manifest.Secrets {
+		if key != jwtSecretKey {
+			im.result.Secrets = append(im.result.Secrets, key)
+		}
+	}
+
+	cfg, err := im.importConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	if im.replaced, err = im.importRecords(cfg); err != nil {
+		return nil, err
+	}
+
+	db, err := im.openDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		defer db.Close()
+	}
+
+	if im.replaced {
+		// 配置表等已随数据库恢复，副本中的敏感配置已清空
+		im.result.Secrets = append(im.result.Secrets, bundle.Manifest.StoredSecrets...)
+	}
+	var items []Item
+	for _, plan := range []func(*database.DB) ([]Item, error){im.planProfiles, im.planConfigurations, im.planDevices, im.planRules} {
+		planned, err := plan(db)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, planned...)
+	}
+	items = im.resolve(items)
+	im.result.Items = append(im.result.Items, items...)
+	if opts.DryRun {
+		return im.result, nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if item.apply == nil || item.Action == ActionSkip || item.Action == ActionUnchanged {
+				continue
+			}
+			if err := item.apply(tx); err != nil {
+				return fmt.Errorf("导入%s %s 失败: %w", KindName(item.Kind), item.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	im.audit(db)
+	return im.result, nil
+}
+
+// resolve 逐项确认需要覆盖的条目，演练时不确认
+func (im *importer) resolve(items []Item) []Item {
+	for i := range items {
+		if items[i].Action != ActionUpdate || im.opts.DryRun {
+			continue
+		}
+		if im.opts.Confirm == nil || !im.opts.Confirm(items[i]) {
+			items[i].Action = ActionSkip
+		}
+	}
+	return items
+}
+
+// importConfigFile 导入配置文件并加载导入后生效的配置
+func (im *importer) importConfigFile() (*config.Config, error) {
+	path := im.opts.ConfigPath
+
+	// 签名密钥为本机重新生成，比较时忽略各密钥
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	incoming, _, err := config.RewriteSecrets(im.bundle.Config, func(key, value string) (string, bool) {
+		return secret, key == jwtSecretKey
+	})
+	if err != nil {
+		return nil, fmt.Errorf("导出包中的配置文件无效: %w", err)
+	}
+
+	item := Item{Kind: KindConfigFile, Key: path, Action: ActionCreate}
+	local, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("读取本机配置文件失败: %w", err)
+	default:
+		same, err := sameIgnoringSecrets(local, im.bundle.Config)
+		if err != nil {
+			return nil, err
+		}
+		item.Action = ActionUpdate
+		item.Detail = "本机配置文件与导出包不同，覆盖前备份为 " + filepath.Base(path) + im.suffix
+		if same {
+			item.Action = ActionUnchanged
+			item.Detail = ""
+		}
+	}
+	item = im.resolve([]Item{item})[0]
+	im.result.Items = append(im.result.Items, item)
+
+	use := path
+	switch {
+	case item.Action == ActionSkip || item.Action == ActionUnchanged:
+		// 保留本机配置文件，其中的密钥不需要重新填写
+		im.result.Secrets = nil
+	case im.opts.DryRun:
+		// 演练时从临时文件加载导入后的配置，确定数据库位置
+		use = filepath.Join(im.bundle.dir, configFile)
+		if err := os.WriteFile(use, incoming, 0600); err != nil {
+			return nil, fmt.Errorf("写入临时配置文件失败: %w", err)
+		}
+	default:
+		if item.Action == ActionUpdate {
+			if err := im.backup(path); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeFile(path, incoming); err != nil {
+			return nil, fmt.Errorf("写入配置文件失败: %w", err)
+		}
+	}
+
+	cfg, err := config.Load(use)
+	if err != nil {
+		return nil, fmt.Errorf("加载导入后的配置失败: %w", err)
+	}
+	if cfg.App.Version != im.bundle.Manifest.AppVersion {
+		im.result.AppVersion = im.bundle.Manifest.AppVersion
+	}
+	return cfg, nil
+}
+
+// importRecords 用导出包中的数据库替换本机数据库，返回是否已替换
+func (im *importer) importRecords(cfg *config.Config) (bool, error) {
+	if !im.bundle.Manifest.IncludeRecords {
+		return false, nil
+	}
+	path := database.FilePath(&cfg.Database)
+	item := Item{
+		Kind:   KindRecords,
+		Key:    path,
+		Action: ActionCreate,
+		Detail: fmt.Sprintf("%d 条扫码记录", im.bundle.Manifest.Counts[recordsFile]),
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		count, err := countRecords(&cfg.Database)
+		if err != nil {
+			return false, err
+		}
+		if count > 0 {
+			item.Action = ActionUpdate
+			item.Detail = fmt.Sprintf("本机已有 %d 条扫码记录，替换为导出包中的 %d 条，替换前备份为 %s",
+				count, im.bundle.Manifest.Counts[recordsFile], filepath.Base(path)+im.suffix)
+		}
+	}
+	item = im.resolve([]Item{item})[0]
+	im.result.Items = append(im.result.Items, item)
+	if item.Action == ActionSkip {
+		return false, nil
+	}
+	if im.opts.DryRun {
+		return true, nil
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := im.backup(path); err != nil {
+			return false, err
+		}
+		// WAL文件随备份改名，备份仍可直接打开，也不会被新数据库误用
+		for _, suffix := range []string{"-wal", "-shm"} {
+			err := os.Rename(path+suffix, path+im.suffix+suffix)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return false, fmt.Errorf("备份 %s 失败: %w", filepath.Base(path+suffix), err)
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, fmt.Errorf("创建数据目录失败: %w", err)
+	}
+	if err := copyFile(im.bundle.records, path); err != nil {
+		return false, fmt.Errorf("恢复扫码记录数据库失败: %w", err)
+	}
+	return true, nil
+}
+
+// openDatabase 打开本机数据库并迁移到当前结构版本；演练时只读打开，数据库不存在时返回nil
+func (im *importer) openDatabase(cfg *config.Config) (*database.DB, error) {
+	if im.opts.DryRun {
+		// 将替换数据库时与导出包中的数据库比较
+		dbConfig := cfg.Database
+		if im.replaced {
+			dbConfig.DSN = im.bundle.records
+		}
+		if _, err := os.Stat(database.FilePath(&dbConfig)); err != nil {
+			return nil, nil
+		}
+		db, err := database.OpenReadOnly(&dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		if im.result.FromVersion, err = db.MigrationVersion(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+
+	// 迁移过程不输出逐条SQL
+	dbConfig := cfg.Database
+	dbConfig.LogLevel = "silent"
+	db, err := database.New(&dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %w", err)
+	}
+	if im.result.FromVersion, err = db.MigrationVersion(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if im.result.FromVersion < database.SchemaVersion {
+		im.opts.Logger.WithFields(logrus.Fields{
+			"from": im.result.FromVersion,
+			"to":   database.SchemaVersion,
+		}).Info("导入前迁移数据库结构")
+	}
+	if err := db.AutoMigrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// planConfigurations 配置表按键合并，敏感配置保留本机的值
+func (im *importer) planConfigurations(db *database.DB) ([]Item, error) {
+	local := make(map[string]models.Configuration)
+	if db != nil {
+		var rows []models.Configuration
+		if err := readRows(db, &rows, "id", "本机系统配置"); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			local[row.Key] = row
+		}
+	}
+	stored := make(map[string]bool, len(im.bundle.Manifest.StoredSecrets))
+	for _, key := range im.bundle.Manifest.StoredSecrets {
+		stored[key] = true
+	}
+
+	items := make([]Item, 0, len(im.bundle.Configurations))
+	for _, incoming := range im.bundle.Configurations {
+		incoming := incoming
+		item := Item{Kind: KindConfiguration, Key: incoming.Key}
+		existing, ok := local[incoming.Key]
+		switch {
+		case stored[incoming.Key]:
+			item.Action = ActionSkip
+			item.Detail = "敏感配置未导出，保留本机的值"
+			if im.replaced {
+				item.Detail = "敏感配置未导出，须重新填写"
+			}
+		case !ok:
+			item.Action = ActionCreate
+			item.apply = func(tx *gorm.DB) error {
+				incoming.ID = 0
+				return tx.Create(&incoming).Error
+			}
+		default:
+			changed := changedFields(
+				field{"value", existing.Value, incoming.Value},
+				field{"type", existing.Type, incoming.Type},
+				field{"category", existing.Category, incoming.Category},
+				field{"description", existing.Description, incoming.Description},
+				field{"is_system", existing.IsSystem, incoming.IsSystem},
+			)
+			item.Action, item.Detail = updateAction(changed)
+			item.apply = func(tx *gorm.DB) error {
+				incoming.ID, incoming.CreatedAt = existing.ID, existing.CreatedAt
+				return tx.Save(&incoming).Error
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// planProfiles 扫码枪配置档案按名称合并，记录导出包ID到本机ID的对应关系
+func (im *importer) planProfiles(db *database.DB) ([]Item, error) {
+	local := make(map[string]models.ScannerProfile)
+	if db != nil {
+		var rows []models.ScannerProfile
+		if err := readRows(db, &rows, "id", "本机扫码枪配置档案"); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			local[row.Name] = row
+		}
+	}
+
+	items := make([]Item, 0, len(im.bundle.Profiles))
+	for _, incoming := range im.bundle.Profiles {
+		incoming := incoming
+		bundleID := incoming.ID
+		item := Item{Kind: KindProfile, Key: incoming.Name}
+		existing, ok := local[incoming.Name]
+		if !ok {
+			item.Action = ActionCreate
+			item.apply = func(tx *gorm.DB) error {
+				incoming.ID = 0
+				if err := tx.Create(&incoming).Error; err != nil {
+					return err
+				}
+				im.profileIDs[bundleID] = incoming.ID
+				return nil
+			}
+		} else {
+			im.profileIDs[bundleID] = existing.ID
+			changed := changedFields(
+				field{"vendor", existing.Vendor, incoming.Vendor},
+				field{"model", existing.Model, incoming.Model},
+				field{"description", existing.Description, incoming.Description},
+				field{"codes", existing.Codes, incoming.Codes},
+			)
+			item.Action, item.Detail = updateAction(changed)
+			item.apply = func(tx *gorm.DB) error {
+				incoming.ID, incoming.CreatedAt = existing.ID, existing.CreatedAt
+				return tx.Save(&incoming).Error
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// planDevices 设备按序列号合并，没有序列号时按名称；已应用的配置档案按名称对应到本机
+func (im *importer) planDevices(db *database.DB) ([]Item, error) {
+	local := make(map[string]models.Device)
+	localProfiles := make(map[uint]string)
+	if db != nil {
+		var rows []models.Device
+		if err := readRows(db, &rows, "id", "本机设备"); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			local[deviceKey(row)] = row
+		}
+		var profiles []models.ScannerProfile
+		if err := readRows(db, &profiles, "id", "本机扫码枪配置档案"); err != nil {
+			return nil, err
+		}
+		for _, profile := range profiles {
+			localProfiles[profile.ID] = profile.Name
+		}
+	}
+	bundleProfiles := make(map[uint]string, len(im.bundle.Profiles))
+	for _, profile := range im.bundle.Profiles {
+		bundleProfiles[profile.ID] = profile.Name
+	}
+	profileName := func(names map[uint]string, id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return names[*id]
+	}
+
+	items := make([]Item, 0, len(im.bundle.Devices))
+	for _, incoming := range im.bundle.Devices {
+		incoming := incoming
+		bundleProfileID := incoming.ProfileID
+		// 配置档案在设备之前导入，应用时再换算为本机ID
+		mapProfile := func() {
+			incoming.ProfileID = nil
+			if bundleProfileID != nil {
+				if id, ok := im.profileIDs[*bundleProfileID]; ok {
+					incoming.ProfileID = &id
+				}
+			}
+		}
+
+		item := Item{Kind: KindDevice, Key: deviceKey(incoming)}
+		existing, ok := local[deviceKey(incoming)]
+		if !ok {
+			item.Action = ActionCreate
+			item.apply = func(tx *gorm.DB) error {
+				mapProfile()
+				incoming.ID = 0
+				return database.CreateStationDevice(tx, &incoming)
+			}
+		} else {
+			changed := changedFields(
+				field{"name", existing.Name, incoming.Name},
+				field{"type", existing.Type, incoming.Type},
+				field{"model", existing.Model, incoming.Model},
+				field{"description", existing.Description, incoming.Description},
+				field{"status", existing.Status, incoming.Status},
+				field{"is_active", existing.IsActive, incoming.IsActive},
+				field{"station", existing.Station, incoming.Station},
+				field{"profile", profileName(localProfiles, existing.ProfileID), profileName(bundleProfiles, incoming.ProfileID)},
+				field{"preset", existing.Preset, incoming.Preset},
+				field{"public_id", existing.PublicID, incoming.PublicID},
+				field{"command_addr", existing.CommandAddr, incoming.CommandAddr},
+				field{"command_templates", existing.CommandTemplates, incoming.CommandTemplates},
+				field{"allowed_commands", existing.AllowedCommands, incoming.AllowedCommands},
+			)
+			item.Action, item.Detail = updateAction(changed)
+			item.apply = func(tx *gorm.DB) error {
+				mapProfile()
+				incoming.ID, incoming.CreatedAt, incoming.LastSeen = existing.ID, existing.CreatedAt, existing.LastSeen
+				if incoming.IsActive {
+					// 每个工位至多一个活跃设备，导出包中的活跃设备取代本机同一工位的活跃设备
+					err := tx.Model(&models.Device{}).Where("station = ? AND is_active = ? AND id <> ?", incoming.Station, true, incoming.ID).
+						Update("is_active", false).Error
+					if err != nil {
+						return err
+					}
+				}
+				return tx.Save(&incoming).Error
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// deviceKey 设备的合并键
+func deviceKey(device models.Device) string {
+	if device.SerialNo != "" {
+		return device.SerialNo
+	}
+	return "name:" + device.Name
+}
+
+// planRules 分类规则按名称合并
+func (im *importer) planRules(db *database.DB) ([]Item, error) {
+	local := make(map[string]models.ClassificationRule)
+	if db != nil {
+		var rows []models.ClassificationRule
+		if err := readRows(db, &rows, "id", "本机分类规则"); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			local[row.Name] = row
+		}
+	}
+
+	items := make([]Item, 0, len(im.bundle.Rules))
+	for _, incoming := range im.bundle.Rules {
+		incoming := incoming
+		item := Item{Kind: KindRule, Key: incoming.Name}
+		existing, ok := local[incoming.Name]
+		if !ok {
+			item.Action = ActionCreate
+			item.apply = func(tx *gorm.DB) error {
+				incoming.ID = 0
+				return tx.Create(&incoming).Error
+			}
+		} else {
+			changed := changedFields(
+				field{"pattern", existing.Pattern, incoming.Pattern},
+				field{"type", existing.Type, incoming.Type},
+				field{"fields", existing.Fields, incoming.Fields},
+				field{"priority", existing.Priority, incoming.Priority},
+				field{"enabled", existing.Enabled, incoming.Enabled},
+			)
+			item.Action, item.Detail = updateAction(changed)
+			item.apply = func(tx *gorm.DB) error {
+				incoming.ID, incoming.CreatedAt = existing.ID, existing.CreatedAt
+				return tx.Save(&incoming).Error
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// audit 导入结果写入本机系统日志
+func (im *importer) audit(db *database.DB) {
+	manifest := im.bundle.Manifest
+	counts := make(map[string]int)
+	for _, item := range im.result.Items {
+		counts[item.Action]++
+	}
+	extra, err := json.Marshal(map[string]interface{}{
+		"source_host":    manifest.Host,
+		"source_station": manifest.Station,
+		"source_version": manifest.AppVersion,
+		"generated_at":   manifest.GeneratedAt,
+		"from_version":   im.result.FromVersion,
+		"to_version":     im.result.ToVersion,
+		"actions":        counts,
+		"secrets":        im.result.Secrets,
+		"backups":        im.result.Backups,
+	})
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:   "info",
+		Message: fmt.Sprintf("从工位 %s（%s）的导出包导入工位状态", manifest.Station, manifest.Host),
+		Module:  "state",
+		Action:  "state:import",
+		Extra:   string(extra),
+	}
+	if err := db.Create(log).Error; err != nil {
+		im.opts.Logger.WithError(err).Warn("写入导入记录失败")
+	}
+}
+
+// backup 覆盖前将文件重命名为备份
+func (im *importer) backup(path string) error {
+	target := path + im.suffix
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("备份 %s 失败: %w", filepath.Base(path), err)
+	}
+	im.result.Backups = append(im.result.Backups, target)
+	return nil
+}
+
+// field 比较的字段
+type field struct {
+	name            string
+	local, incoming interface{}
+}
+
+// changedFields 取值不同的字段名，空映射与nil视为相同
+func changedFields(fields ...field) []string {
+	var changed []string
+	for _, f := range fields {
+		if fmt.Sprint(f.local) != fmt.Sprint(f.incoming) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+// updateAction 根据不同的字段确定处理方式
+func updateAction(changed []string) (string, string) {
+	if len(changed) == 0 {
+		return ActionUnchanged, ""
+	}
+	return ActionUpdate, "不同: " + strings.Join(changed, ", ")
+}
+
+// KindName 导入项类型的中文名称
+func KindName(kind string) string {
+	switch kind {
+	case KindConfigFile:
+		return "配置文件"
+	case KindRecords:
+		return "扫码记录数据库"
+	case KindConfiguration:
+		return "系统配置"
+	case KindProfile:
+		return "扫码枪配置档案"
+	case KindDevice:
+		return "设备"
+	case KindRule:
+		return "分类规则"
+	}
+	return kind
+}
+
+// sameIgnoringSecrets 忽略密钥后两个配置文件是否相同
+func sameIgnoringSecrets(a, b []byte) (bool, error) {
+	strip := func(key, value string) (string, bool) { return "", true }
+	a, _, err := config.RewriteSecrets(a, strip)
+	if err != nil {
+		return false, fmt.Errorf("解析本机配置文件失败: %w", err)
+	}
+	b, _, err = config.RewriteSecrets(b, strip)
+	if err != nil {
+		return false, fmt.Errorf("导出包中的配置文件无效: %w", err)
+	}
+	return bytes.Equal(bytes.TrimSpace(a), bytes.TrimSpace(b)), nil
+}
+
+// countRecords 本机数据库中的扫码记录数，表不存在时为0
+func countRecords(cfg *config.DatabaseConfig) (int64, error) {
+	db, err := database.OpenReadOnly(cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if !db.Migrator().HasTable(&models.BarcodeRecord{}) {
+		return 0, nil
+	}
+	var count int64
+	if err := db.Model(&models.BarcodeRecord{}).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("统计本机扫码记录失败: %w", err)
+	}
+	return count, nil
+}
+
+// newSecret 生成随机密钥
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成随机密钥失败: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// writeFile 先写临时文件再替换，写入中途失败不会留下不完整的文件
+func writeFile(path string, data []byte) error {
+	return writeFrom(path, bytes.NewReader(data))
+}
+
+// copyFile 复制文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFrom(dst, in)
+}
+
+// writeFrom 将内容写入临时文件后替换目标文件
+func writeFrom(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".import-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -2,75 +2,252 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 	
 	"github.com/spf13/viper"
+	
+	"userclient/pkg/barcode"
 )
 
 // Config 应用配置结构
 type Config struct {
-	App       AppConfig       `mapstructure:"app"`
-	Server    ServerConfig    `mapstructure:"server"`
-	Database  DatabaseConfig  `mapstructure:"database"`
-	Scanner   ScannerConfig   `mapstructure:"scanner"`
-	WebSocket WebSocketConfig `mapstructure:"websocket"`
-	API       APIConfig       `mapstructure:"api"`
-	Log       LogConfig       `mapstructure:"log"`
-	Security  SecurityConfig  `mapstructure:"security"`
+	App            AppConfig            `mapstructure:"app"`
+	Server         ServerConfig         `mapstructure:"server"`
+	Database       DatabaseConfig       `mapstructure:"database"`
+	Scanner        ScannerConfig        `mapstructure:"scanner"`
+	WebSocket      WebSocketConfig      `mapstructure:"websocket"`
+	API            APIConfig            `mapstructure:"api"`
+	Log            LogConfig            `mapstructure:"log"`
+	Security       SecurityConfig       `mapstructure:"security"`
+	Sinks          SinksConfig          `mapstructure:"sinks"`
+	Sequence       SequenceConfig       `mapstructure:"sequence"`
+	Health         HealthConfig         `mapstructure:"health"`
+	Images         ImagesConfig         `mapstructure:"images"`
+	Peers          PeersConfig          `mapstructure:"peers"`
+	Idle           IdleConfig           `mapstructure:"idle"`
+	Admin          AdminConfig          `mapstructure:"admin"`
+	Classification ClassificationConfig `mapstructure:"classification"`
+	Profiles       ProfilesConfig       `mapstructure:"profiles"`
+	Commands       CommandsConfig       `mapstructure:"commands"`
+	Heartbeat      HeartbeatConfig      `mapstructure:"heartbeat"`
+	Reload         ReloadConfig         `mapstructure:"reload"`
+	Goals          GoalsConfig          `mapstructure:"goals"`
+	StatusPage     StatusPageConfig     `mapstructure:"status_page"`
+	Ingest         IngestConfig         `mapstructure:"ingest"`
+	Central        CentralConfig        `mapstructure:"central"`
+	Acks           AcksConfig           `mapstructure:"acks"`
+	Agent          AgentConfig          `mapstructure:"agent"`
+	Preferences    PreferencesConfig    `mapstructure:"preferences"`
+	Closing        ClosingConfig        `mapstructure:"closing"`
+	Simulator      SimulatorConfig      `mapstructure:"simulator"`
+	MetricsHistory MetricsHistoryConfig `mapstructure:"metrics_history"`
+	Serial         SerialConfig         `mapstructure:"serial"`
+	NetworkScanner NetworkScannerConfig `mapstructure:"network_scanner"`
+	Standby        StandbyConfig        `mapstructure:"standby"`
+	Quality        QualityConfig        `mapstructure:"quality"`
+	Recovery       RecoveryConfig       `mapstructure:"recovery"`
+	Messages       MessagesConfig       `mapstructure:"messages"`
+	Ingestion      IngestionConfig      `mapstructure:"ingestion"`
+	Integrity      IntegrityConfig      `mapstructure:"integrity"`
+	Coalesce       CoalesceConfig       `mapstructure:"coalesce"`
+	OperatorStats  OperatorStatsConfig  `mapstructure:"operator_stats"`
+	Counters       CountersConfig       `mapstructure:"counters"`
 }
 
 // AppConfig 应用配置
 type AppConfig struct {
-	Name    string `mapstructure:"name"`
-	Version string `mapstructure:"version"`
-	Env     string `mapstructure:"env"`
-	Debug   bool   `mapstructure:"debug"`
+	Name     string `mapstructure:"name"`
+	Version  string `mapstructure:"version"`
+	Env      string `mapstructure:"env"`
+	Debug    bool   `mapstructure:"debug"`
+	Timezone string `mapstructure:"timezone"` // 统计分桶、班次、导出及看板使用的时区（IANA名称），为空表示系统时区
+	// Locale 默认语言（zh-CN、en-US）：接口文字及状态页按请求的 ?lang= 或 Accept-Language 选择，无法协商时及告警、日结邮件使用该语言
+	Locale string `mapstructure:"locale"`
+	// Provisioned 是否已完成首次运行配置，为 false 时启用 /api/setup 向导且所有接口仅允许本机访问
+	Provisioned bool `mapstructure:"provisioned"`
+	// ReadOnly 只读模式，用于浏览及导出数据库副本：数据库以只读方式打开，不接收扫码，修改类接口返回403
+	ReadOnly bool `mapstructure:"read_only"`
+}
+
+// Location 显示及统计使用的时区
+func (c AppConfig) Location() (*time.Location, error) {
+	return LoadLocation(c.Timezone)
+}
+
+// LoadLocation 加载时区，为空或 Local 表示系统时区
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("时区无效: %s", name)
+	}
+	return loc, nil
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Host         string        `mapstructure:"host"`
-	Port         int           `mapstructure:"port"`
-	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
-	WriteTimeout time.Duration `mapstructure:"write_timeout"`
-	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	Host              string        `mapstructure:"host"`
+	Port              int           `mapstructure:"port"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	PortFallbackRange int           `mapstructure:"port_fallback_range"` // 端口被占用时依次尝试后续端口的个数，0表示不尝试
 }
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Type            string        `mapstructure:"type"`
-	DSN             string        `mapstructure:"dsn"`
-	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
-	MaxOpenConns    int           `mapstructure:"max_open_conns"`
-	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
-	LogLevel        string        `mapstructure:"log_level"`
+	Type            string                  `mapstructure:"type"`
+	DSN             string                  `mapstructure:"dsn"`
+	MaxIdleConns    int                     `mapstructure:"max_idle_conns"`
+	MaxOpenConns    int                     `mapstructure:"max_open_conns"`
+	ConnMaxLifetime time.Duration           `mapstructure:"conn_max_lifetime"`
+	LogLevel        string                  `mapstructure:"log_level"`
+	Secondary       SecondaryDatabaseConfig `mapstructure:"secondary"`
+	LegacyTimezone  string                  `mapstructure:"legacy_timezone"` // 升级前写入、不含时区信息的时间按此时区解释，为空表示系统时区
+	ConfigCacheTTL  time.Duration           `mapstructure:"config_cache_ttl"` // 系统配置缓存时间，绕过配置服务直接写入配置表的修改最迟在此时间后生效
+	Maintenance     DatabaseMaintenanceConfig `mapstructure:"maintenance"`
+	Backfill        DatabaseBackfillConfig    `mapstructure:"backfill"`
+}
+
+// DatabaseBackfillConfig 后台回填配置
+//
+// 迁移新增的列在启动后由后台任务按批回填，服务无需等待；每批在同一事务中回填并保存检查点，重启后从检查点继续。
+type DatabaseBackfillConfig struct {
+	BatchSize int           `mapstructure:"batch_size"` // 每批回填的行数
+	Interval  time.Duration `mapstructure:"interval"`   // 两批之间的间隔，避免占用扫码写入
+}
+
+// DatabaseMaintenanceConfig SQLite 空闲维护配置
+//
+// 只在安静时段内或距上次扫码超过 idle_after 时执行 PRAGMA optimize 及 incremental_vacuum，
+// 可选执行完整 VACUUM；执行中有扫码到达时中止。非 SQLite 数据库不执行。
+type DatabaseMaintenanceConfig struct {
+	Enable             bool          `mapstructure:"enable"`
+	Interval           time.Duration `mapstructure:"interval"`             // 两次维护的最小间隔
+	CheckInterval      time.Duration `mapstructure:"check_interval"`       // 检查是否满足执行条件的间隔
+	QuietWindows       []ShiftWindow `mapstructure:"quiet_windows"`        // 安静时段（app.timezone 时区，可跨零点）
+	IdleAfter          time.Duration `mapstructure:"idle_after"`           // 距上次扫码超过此时长时也可执行，0表示只在安静时段执行
+	IncrementalPages   int           `mapstructure:"incremental_pages"`    // 每次最多回收的空闲页数，0表示全部
+	FullVacuum         bool          `mapstructure:"full_vacuum"`          // 是否执行完整 VACUUM（锁库，耗时与文件大小相关）
+	FullVacuumInterval time.Duration `mapstructure:"full_vacuum_interval"` // 两次完整 VACUUM 的最小间隔
+}
+
+// SecondaryDatabaseConfig 本地备份库配置
+//
+// 启用后扫码记录同时写入主库与本地备份库：备份库写入失败仅告警；主库不可用时读取切换到备份库，
+// 主库恢复后由对账任务按幂等键（scan_id）将备份库中的记录补写到主库。
+type SecondaryDatabaseConfig struct {
+	Enable            bool          `mapstructure:"enable"`
+	DSN               string        `mapstructure:"dsn"`
+	HealthInterval    time.Duration `mapstructure:"health_interval"`    // 主库健康检查间隔
+	ReconcileInterval time.Duration `mapstructure:"reconcile_interval"` // 对账间隔
+	ReconcileWindow   time.Duration `mapstructure:"reconcile_window"`   // 对账覆盖的时间范围
 }
 
+// 按键采集方式
+const (
+	ScannerBackendHook     = "hook"
+	ScannerBackendRawInput = "rawinput"
+)
+
 // ScannerConfig 扫码枪配置
 type ScannerConfig struct {
-	TimeoutMS  int  `mapstructure:"timeout_ms"`
-	MinLength  int  `mapstructure:"min_length"`
-	MaxLength  int  `mapstructure:"max_length"`
-	EnableHook bool `mapstructure:"enable_hook"`
+	TimeoutMS            int  `mapstructure:"timeout_ms"`
+	MinLength            int  `mapstructure:"min_length"`
+	MaxLength            int  `mapstructure:"max_length"`
+	EnableHook           bool `mapstructure:"enable_hook"`
+	TerminatorCollapseMS int  `mapstructure:"terminator_collapse_ms"` // 连续终止符合并窗口
+	MergeFragments       bool `mapstructure:"merge_fragments"`        // 拼接被终止符拆开的短片段
+	UseSystemLayout      bool `mapstructure:"use_system_layout"`      // 按前台窗口的键盘布局翻译按键（德语、法语等非美式布局），关闭时使用内置的美式布局表
+	IgnoreInjected       bool `mapstructure:"ignore_injected"`        // 忽略软件注入的按键（AutoHotkey、远程桌面、测试工具等）
+	SuppressInput        bool `mapstructure:"suppress_input"`         // 拦截扫码枪按键，不传给前台程序
+	SuppressAfterKeys    int  `mapstructure:"suppress_after_keys"`    // 连续多少个间隔不超过 timeout_ms 的字符键判定为扫码
+	MaxAvgKeyIntervalMS  int  `mapstructure:"max_avg_key_interval_ms"` // 扫码允许的最大平均按键间隔，超过（或录入总耗时超过该值与间隔数之积）视为人工输入并丢弃，0表示不判断
+	
+	// 结束扫码的按键：enter（回车及单独上报的LF）、cr、lf、tab；none 表示不使用终止符，按键停顿超过 timeout_ms 后结束扫码
+	Terminators []string `mapstructure:"terminators"`
+	// 未收到终止符时按键停顿超过 timeout_ms 也结束扫码，用于发送后缀与不发送后缀的扫码枪混用
+	FlushOnTimeout bool `mapstructure:"flush_on_timeout"`
+	// 输入GS1组分隔符（ASCII 29）的按键：ctrl+]、]（扫码枪以 ] 代替）、f1-f12，为空表示不识别组分隔符
+	GroupSeparator []string `mapstructure:"group_separator"`
+	// 条码缓冲超过 max_length 的该倍数仍未收到终止符（扫码枪故障持续输出）时丢弃缓冲，至按键停顿或终止符前的后续字符也丢弃
+	MaxBufferFactor int `mapstructure:"max_buffer_factor"`
+	
+	// 按键采集方式：hook（低级键盘钩子）或 rawinput（Raw Input，可区分产生按键的键盘，扫码归属到登记了该键盘的设备）
+	Backend string `mapstructure:"backend"`
+	// rawinput 下忽略未登记为设备的键盘（如操作员的键盘）的输入
+	IgnoreUnregistered bool `mapstructure:"ignore_unregistered"`
+	// Linux下读取的输入设备，按 evdev 设备名称匹配的通配符（如 "*Barcode*"）；为空时读取所有键盘类设备
+	EvdevDevices []string `mapstructure:"evdev_devices"`
+	
+	// 扫码枪参数预设（如 zebra-ds2208），为空时使用当前活跃设备的预设；本节中显式设置的参数优先于预设
+	Preset string `mapstructure:"preset"`
+
+	// 按键时序诊断：扫码内部出现超过阈值的按键间隔（按钩子上报的按键时间）视为疑似漏键
+	SuspectGapMS       int     `mapstructure:"suspect_gap_ms"`
+	SuspectAlertRate   float64 `mapstructure:"suspect_alert_rate"`   // 最近窗口内疑似截断比例达到该值时告警，0表示不告警
+	SuspectAlertWindow int     `mapstructure:"suspect_alert_window"` // 计算疑似截断比例的扫码次数
+
+	// 键盘钩子看门狗：超过该间隔没有按键时发送探测按键，钩子收不到（被系统因回调超时移除）时重新安装，0表示关闭
+	WatchdogInterval time.Duration `mapstructure:"watchdog_interval"`
+
+	// 采集来源（键盘钩子、串口及网络扫码枪）交出的扫码先进入有界缓冲，再由条码处理器依次处理
+	BufferSize int    `mapstructure:"buffer_size"` // 缓冲的扫码数
+	Overflow   string `mapstructure:"overflow"`    // 缓冲满时：block（采集来源等待）或 drop_oldest（丢弃最早的扫码）
+
+	// 按键超时自动调整，对开启了 adaptive_timeout 的设备生效
+	AdaptiveTimeout AdaptiveTimeoutConfig `mapstructure:"adaptive_timeout"`
+}
+
+// AdaptiveTimeoutConfig 按键超时自动调整：按设备有效扫码的按键间隔分布调整该设备的按键超时
+type AdaptiveTimeoutConfig struct {
+	FloorMS   int     `mapstructure:"floor_ms"`   // 调整后的按键超时下限
+	CeilingMS int     `mapstructure:"ceiling_ms"` // 调整后的按键超时上限
+	MinScans  int     `mapstructure:"min_scans"`  // 观察到的有效扫码达到该数量后才开始调整
+	Window    int     `mapstructure:"window"`     // 统计最近多少个按键间隔
+	Headroom  float64 `mapstructure:"headroom"`   // 按键超时取按键间隔第99百分位数的倍数
 }
 
+// 扫码缓冲满时的处理方式
+const (
+	ScanOverflowBlock      = "block"
+	ScanOverflowDropOldest = "drop_oldest"
+)
+
 // WebSocketConfig WebSocket配置
 type WebSocketConfig struct {
-	Path            string        `mapstructure:"path"`
-	ReadBufferSize  int           `mapstructure:"read_buffer_size"`
-	WriteBufferSize int           `mapstructure:"write_buffer_size"`
-	CheckOrigin     bool          `mapstructure:"check_origin"`
-	PingPeriod      time.Duration `mapstructure:"ping_period"`
-	PongWait        time.Duration `mapstructure:"pong_wait"`
-	WriteWait       time.Duration `mapstructure:"write_wait"`
+	Path            string                    `mapstructure:"path"`
+	ReadBufferSize  int                       `mapstructure:"read_buffer_size"`
+	WriteBufferSize int                       `mapstructure:"write_buffer_size"`
+	CheckOrigin     bool                      `mapstructure:"check_origin"`
+	PingPeriod      time.Duration             `mapstructure:"ping_period"`
+	PongWait        time.Duration             `mapstructure:"pong_wait"`
+	WriteWait       time.Duration             `mapstructure:"write_wait"`
+	ReplaySize      int                       `mapstructure:"replay_size"`      // 断线续传缓存的广播消息条数
+	MaxMessageSize  int                       `mapstructure:"max_message_size"` // 单条推送消息的最大字节数，客户端可在连接时协商更小的值
+	Compaction      WebSocketCompactionConfig `mapstructure:"compaction"`
+}
+
+// WebSocketCompactionConfig 连续相同扫码合并配置
+//
+// 启用后，以 compact=1 连接的客户端（看板等界面）收到连续相同的扫码时只收到计数消息，
+// 其他客户端及数据库仍按每次扫码处理。
+type WebSocketCompactionConfig struct {
+	Enable bool          `mapstructure:"enable"` // 已弃用，由功能开关 hub.compaction 取代，开关未设置时仍生效
+	Window time.Duration `mapstructure:"window"` // 与上一次相同扫码的最大间隔
 }
 
 // APIConfig API配置
 type APIConfig struct {
-	Prefix      string      `mapstructure:"prefix"`
-	EnableCORS  bool        `mapstructure:"enable_cors"`
-	CORSOrigins []string    `mapstructure:"cors_origins"`
-	RateLimit   RateLimit   `mapstructure:"rate_limit"`
+	Prefix            string        `mapstructure:"prefix"`
+	EnableCORS        bool          `mapstructure:"enable_cors"`
+	CORSOrigins       []string      `mapstructure:"cors_origins"`
+	RateLimit         RateLimit     `mapstructure:"rate_limit"`
+	StatsCacheTTL     time.Duration `mapstructure:"stats_cache_ttl"`
+	DistinctPrecision int           `mapstructure:"distinct_precision"` // 不同条码数估算（HyperLogLog）的精度，4-18
 }
 
 // RateLimit 限流配置
@@ -99,6 +276,421 @@ type SecurityConfig struct {
 	APIKey     string        `mapstructure:"api_key"`
 }
 
+// SinksConfig 事件输出配置
+type SinksConfig struct {
+	File   FileSinkConfig `mapstructure:"file"`
+	Outbox OutboxConfig   `mapstructure:"outbox"`
+	Replay ReplayConfig   `mapstructure:"replay"`
+}
+
+// ReplayConfig 历史扫码记录回放配置，回放限速以免影响实时投递
+type ReplayConfig struct {
+	Rate      int `mapstructure:"rate"`       // 每秒最多回放的记录数
+	BatchSize int `mapstructure:"batch_size"` // 每批读取并保存检查点的记录数
+}
+
+// OutboxConfig 出站事件日志配置，扫码记录与事件同事务写入后由各输出端消费
+type OutboxConfig struct {
+	Enable       bool          `mapstructure:"enable"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	Retention    time.Duration `mapstructure:"retention"` // 已投递事件保留时长
+}
+
+// FileSinkConfig NDJSON文件输出配置
+type FileSinkConfig struct {
+	Enable        bool                `mapstructure:"enable"`
+	Dir           string              `mapstructure:"dir"`
+	Rotation      string              `mapstructure:"rotation"` // hourly, daily
+	MaxFiles      int                 `mapstructure:"max_files"`
+	EventTypes    []string            `mapstructure:"event_types"`
+	FlushInterval time.Duration       `mapstructure:"flush_interval"`
+	Format        PayloadFormatConfig `mapstructure:"format"`
+}
+
+// PayloadFormatConfig 输出载荷格式配置
+type PayloadFormatConfig struct {
+	TimestampFormat string `mapstructure:"timestamp_format"` // rfc3339, rfc3339_us, epoch_s, epoch_ms, epoch_us
+	FieldNaming     string `mapstructure:"field_naming"`     // current, camel
+	Flatten         bool   `mapstructure:"flatten"`          // 将data字段展开到外层
+}
+
+// HealthConfig 设备健康评分配置
+type HealthConfig struct {
+	Enable           bool          `mapstructure:"enable"`
+	Window           time.Duration `mapstructure:"window"`            // 滚动统计窗口
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"` // 评分快照间隔
+	MinScans         int64         `mapstructure:"min_scans"`         // 参与告警的最少扫码数
+	SlowScanMS       int64         `mapstructure:"slow_scan_ms"`      // 平均扫码耗时达到此值时该项扣满
+	AlertThreshold   float64       `mapstructure:"alert_threshold"`   // 评分低于此值时告警
+	DropThreshold    float64       `mapstructure:"drop_threshold"`    // 相比上一快照下降超过此值时告警
+}
+
+// ImagesConfig 条码图片存储配置
+type ImagesConfig struct {
+	Enable          bool          `mapstructure:"enable"`
+	Dir             string        `mapstructure:"dir"`
+	MaxSizeKB       int64         `mapstructure:"max_size_kb"`   // 单张图片大小上限
+	AllowedTypes    []string      `mapstructure:"allowed_types"` // 允许的图片类型
+	Retention       time.Duration `mapstructure:"retention"`     // 图片保留时长
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+// PeersConfig 相邻工位事件转发配置
+type PeersConfig struct {
+	Enable     bool          `mapstructure:"enable"`
+	Station    string        `mapstructure:"station"`     // 本工位名称，随转发的事件发送，并区分共用数据库中各工位的活跃设备
+	EventTypes []string      `mapstructure:"event_types"` // 转发的事件类型
+	QueueSize  int           `mapstructure:"queue_size"`  // 每个对端的重试队列长度
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+	Targets    []PeerTarget  `mapstructure:"targets"`
+}
+
+// PeerTarget 转发目标工位
+type PeerTarget struct {
+	Name  string `mapstructure:"name"`
+	URL   string `mapstructure:"url"`
+	Token string `mapstructure:"token"` // 对端签发的 peer:forward 令牌
+}
+
+// ClassificationConfig 条码分类规则配置（规则本身通过 /api/rules 维护）
+type ClassificationConfig struct {
+	IndexedFields []string `mapstructure:"indexed_fields"` // 建立表达式索引的常用派生字段
+}
+
+// ProfilesConfig 扫码枪配置档案设置（档案本身通过 /api/profiles 维护）
+type ProfilesConfig struct {
+	SetupCodePatterns []string `mapstructure:"setup_code_patterns"` // 厂商设置码的正则，扫码命中时告警；档案中登记的设置码始终参与检测
+}
+
+// CommandsConfig 网络扫码枪命令通道配置（命令模板及白名单按设备维护）
+type CommandsConfig struct {
+	Timeout     time.Duration `mapstructure:"timeout"`      // 连接及等待设备响应的超时
+	MaxResponse int           `mapstructure:"max_response"` // 设备响应的最大字节数
+	Concurrency int           `mapstructure:"concurrency"`  // 批量下发命令时的最大并发数
+}
+
+// HeartbeatConfig 工位心跳记录配置
+type HeartbeatConfig struct {
+	Enable     bool          `mapstructure:"enable"`
+	Interval   time.Duration `mapstructure:"interval"`    // 心跳间隔
+	Retention  time.Duration `mapstructure:"retention"`   // 心跳记录保留时长
+	BufferSize int           `mapstructure:"buffer_size"` // 数据库不可用时内存中缓存的心跳数
+}
+
+// ReloadConfig 配置文件热加载配置
+type ReloadConfig struct {
+	Enable   bool          `mapstructure:"enable"`
+	Debounce time.Duration `mapstructure:"debounce"` // 文件变化后等待的时长，编辑器保存时可能连续触发多次
+	Snapshot string        `mapstructure:"snapshot"` // 最近一次可用配置的保存路径
+}
+
+// GoalsConfig 产量目标配置
+type GoalsConfig struct {
+	Enable     bool  `mapstructure:"enable"`
+	Milestones []int `mapstructure:"milestones"` // 推送 goal_progress 的进度百分比
+}
+
+// StatusPageConfig 公开状态页配置
+//
+// 状态页无需认证，供监控系统及车间大屏使用，只能从代码中登记的非敏感字段里选择输出内容。
+type StatusPageConfig struct {
+	Enable           bool          `mapstructure:"enable"`
+	Fields           []string      `mapstructure:"fields"`             // 输出的字段：capturing, recent_scan, maintenance, version
+	RecentScanWindow time.Duration `mapstructure:"recent_scan_window"` // recent_scan 判定最近有扫码的时间范围
+	RateLimit        int           `mapstructure:"rate_limit"`         // 每个IP每分钟的请求数
+}
+
+// IngestConfig 外部系统推送扫码结果（POST /api/ingest）配置
+type IngestConfig struct {
+	Enable   bool          `mapstructure:"enable"`
+	MaxBatch int           `mapstructure:"max_batch"` // 单次请求最多的扫码条数
+	Mapping  IngestMapping `mapstructure:"mapping"`   // 非本系统事件格式的字段映射
+}
+
+// AcksConfig 下游系统消费确认（POST /api/barcodes/ack）配置
+type AcksConfig struct {
+	Consumers []string `mapstructure:"consumers"` // 登记的消费方名称，清理旧记录时保留其未消费的记录
+	MaxBatch  int      `mapstructure:"max_batch"` // 单次确认最多的记录ID数
+}
+
+// AgentConfig 键盘钩子代理（scanner-agent）配置
+//
+// 服务以系统服务运行时没有交互式桌面，由登录用户会话中的代理进程安装键盘钩子，
+// 经本机 WebSocket（/api/agent/connect）把扫码转交给服务。
+type AgentConfig struct {
+	Enable bool   `mapstructure:"enable"`
+	Token  string `mapstructure:"token"` // 代理与服务共享的令牌，代理未提供或不一致时拒绝连接
+}
+
+// PreferencesConfig 看板偏好设置（/api/preferences）配置
+type PreferencesConfig struct {
+	Enable    bool          `mapstructure:"enable"`
+	MaxBytes  int           `mapstructure:"max_bytes"` // 单个客户端偏好设置的最大字节数
+	Retention time.Duration `mapstructure:"retention"` // 超过此时长未更新的偏好设置被清理，0表示不清理
+}
+
+// ClosingConfig 日结配置
+//
+// 日结（POST /api/admin/close-day）生成生产日的汇总并锁定当天的扫码记录，生产日与班次
+// 划分与产量目标相同。
+type ClosingConfig struct {
+	Enable        bool               `mapstructure:"enable"`
+	OperatorField string             `mapstructure:"operator_field"` // 分类规则派生字段中的操作员字段，汇总按此字段统计
+	ExportDir     string             `mapstructure:"export_dir"`     // 日结后将汇总写入此目录（JSON），为空表示不导出
+	Email         ClosingEmailConfig `mapstructure:"email"`
+}
+
+// ClosingEmailConfig 日结汇总邮件配置
+type ClosingEmailConfig struct {
+	Enable   bool     `mapstructure:"enable"`
+	Addr     string   `mapstructure:"addr"`     // SMTP服务器地址（host:port）
+	Username string   `mapstructure:"username"` // 为空表示不认证
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+// SimulatorConfig 模拟扫码配置，按场景文件播放扫码，用于演示及回归测试
+type SimulatorConfig struct {
+	Enable           bool    `mapstructure:"enable"`
+	Scenario         string  `mapstructure:"scenario"`           // 启动时加载的场景文件（YAML或JSON），为空时通过 POST /api/simulator/scenario 上传
+	AutoStart        bool    `mapstructure:"auto_start"`         // 启动时自动播放 scenario
+	Loop             bool    `mapstructure:"loop"`               // 播放完后从头循环
+	Speed            float64 `mapstructure:"speed"`              // 倍速，2 表示间隔缩短为一半
+	ExcludeFromStats bool    `mapstructure:"exclude_from_stats"` // 统计（/api/stats 及时间范围统计）不计入模拟扫码，未启用模拟扫码时同样生效
+}
+
+// MetricsHistoryConfig 监控指标快照配置，没有Prometheus的工位据此查看历史指标
+type MetricsHistoryConfig struct {
+	Enable    bool          `mapstructure:"enable"`
+	Interval  time.Duration `mapstructure:"interval"`  // 采集间隔，不小于1分钟
+	Retention time.Duration `mapstructure:"retention"` // 快照保留时长
+}
+
+// SerialConfig 串口扫码枪配置，用于不模拟键盘输入的RS-232扫码枪
+type SerialConfig struct {
+	Enable       bool               `mapstructure:"enable"`
+	ReconnectMin time.Duration      `mapstructure:"reconnect_min"` // 串口断开或打开失败后首次重试的等待时间，之后每次翻倍
+	ReconnectMax time.Duration      `mapstructure:"reconnect_max"` // 重试等待时间上限
+	Ports        []SerialPortConfig `mapstructure:"ports"`
+}
+
+// SerialPortConfig 一个串口的参数，省略的项使用常见扫码枪的默认值（9600、8N1、回车结束）
+type SerialPortConfig struct {
+	Name       string `mapstructure:"name"`       // 串口名称，如 COM3、/dev/ttyUSB0
+	BaudRate   int    `mapstructure:"baud_rate"`  // 波特率，默认9600
+	DataBits   int    `mapstructure:"data_bits"`  // 数据位（5~8），默认8
+	Parity     string `mapstructure:"parity"`     // 校验：none、odd、even，默认none
+	StopBits   int    `mapstructure:"stop_bits"`  // 停止位：1或2，默认1
+	Terminator string `mapstructure:"terminator"` // 帧结束符：enter（CR或LF）、cr、lf、tab，默认enter
+	Device     string `mapstructure:"device"`     // 设备序列号，扫码归属到该设备；为空时归属默认设备
+}
+
+// NetworkScannerConfig 网络扫码枪配置，用于以TCP客户端模式推送条码的固定式扫码枪
+type NetworkScannerConfig struct {
+	Enable      bool          `mapstructure:"enable"`
+	Listen      string        `mapstructure:"listen"`       // 监听地址，扫码枪连接该端口推送条码
+	Terminator  string        `mapstructure:"terminator"`   // 帧结束符：enter（CR或LF）、cr、lf、tab
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"` // 连接超过此时长没有数据时断开，0表示不断开
+	MaxConns    int           `mapstructure:"max_conns"`    // 同时连接的扫码枪数上限
+}
+
+// 热备角色
+const (
+	StandbyRolePrimary = "primary"
+	StandbyRoleStandby = "standby"
+)
+
+// StandbyConfig 热备配置：同一工位的主备两台工位机，备机启动时不采集扫码，主机持续不健康时自动接管
+type StandbyConfig struct {
+	Enable        bool          `mapstructure:"enable"`
+	Role          string        `mapstructure:"role"`           // 本机角色：primary 或 standby
+	Instance      string        `mapstructure:"instance"`       // 本机标识，写入采集租约以区分两台工位机，为空时使用主机名
+	PrimaryURL    string        `mapstructure:"primary_url"`    // 备机监测的主机地址，如 http://192.168.1.10:8080
+	CheckInterval time.Duration `mapstructure:"check_interval"` // 检查主机健康及续租的间隔
+	Timeout       time.Duration `mapstructure:"timeout"`        // 单次健康检查超时
+	TakeoverAfter time.Duration `mapstructure:"takeover_after"` // 主机持续不健康超过此时长后备机接管
+	RecoverAfter  time.Duration `mapstructure:"recover_after"`  // 主机恢复健康持续此时长后备机交还
+	Lease         bool          `mapstructure:"lease"`          // 两台工位机共用数据库时启用，只有持有采集租约的一方采集扫码
+	LeaseTTL      time.Duration `mapstructure:"lease_ttl"`      // 租约有效期，持有者超过此时长未续租时另一方可以取得
+}
+
+// QualityConfig 数据质量报告配置，按码制规则检查扫码内容（校验位、规范字符集）并与上一窗口对比
+type QualityConfig struct {
+	Enable              bool          `mapstructure:"enable"`
+	Window              time.Duration `mapstructure:"window"`               // 统计窗口，每个窗口结束时生成并保存一份报告
+	BatchSize           int           `mapstructure:"batch_size"`           // 每批读取的扫码记录数
+	Code128Pattern      string        `mapstructure:"code128_pattern"`      // Code 128 条码允许的内容（正则），不匹配时计为含规范外字符
+	MinScans            int64         `mapstructure:"min_scans"`            // 参与退化告警的最少扫码数
+	RegressionThreshold float64       `mapstructure:"regression_threshold"` // 不合格比例相比上一窗口上升超过此百分点时告警
+	Retention           time.Duration `mapstructure:"retention"`            // 报告保留时长，0表示不清理
+}
+
+// 非正常退出后序号跟踪会话的处理方式
+const (
+	RecoverySessionsResume = "resume" // 继续跟踪，按退出前的最后序号检测断号
+	RecoverySessionsClose  = "close"  // 结束会话，下次扫码重新开始跟踪，不把退出期间未扫的序号记为断号
+)
+
+// RecoveryConfig 非正常退出（断电、强制结束进程）后的启动恢复
+type RecoveryConfig struct {
+	Enable         bool   `mapstructure:"enable"`
+	Sessions       string `mapstructure:"sessions"`        // 上次运行中的序号跟踪会话：resume 或 close
+	EndMaintenance bool   `mapstructure:"end_maintenance"` // 结束上次运行遗留的维护模式（同时恢复键盘钩子代理）
+}
+
+// MessagesConfig 扫码推送消息配置，修改后无需重启
+type MessagesConfig struct {
+	Locale    string                           `mapstructure:"locale"`    // 使用的消息模板，对应 templates 中的键（不区分大小写）
+	Templates map[string]MessageTemplateConfig `mapstructure:"templates"` // 语言 -> 消息模板
+}
+
+// MessageTemplateConfig 推送消息模板
+type MessageTemplateConfig struct {
+	Format         string `mapstructure:"format"`          // 占位符：{message}、{station}、{device}、{type}、{content}，为空时只输出识别结果说明
+	UnknownDevice  string `mapstructure:"unknown_device"`  // 扫码未归属设备时 {device} 的取值
+	UnknownStation string `mapstructure:"unknown_station"` // 未配置工位名称时 {station} 的取值
+}
+
+// Template 当前语言的消息模板，未配置该语言时返回 false
+func (c MessagesConfig) Template() (barcode.MessageTemplate, bool) {
+	t, ok := c.Templates[strings.ToLower(c.Locale)]
+	if !ok {
+		return barcode.MessageTemplate{}, false
+	}
+	return barcode.MessageTemplate{
+		Format:         t.Format,
+		UnknownDevice:  t.UnknownDevice,
+		UnknownStation: t.UnknownStation,
+	}, true
+}
+
+// IngestionConfig 扫码接入限流，网络扫码枪或外部推送的速度超过入库速度时限制，按键扫码不受限，修改后无需重启
+type IngestionConfig struct {
+	Enable      bool    `mapstructure:"enable"`
+	GlobalRate  float64 `mapstructure:"global_rate"`  // 全部来源合计每秒允许的扫码数
+	GlobalBurst int     `mapstructure:"global_burst"` // 全部来源合计允许的突发扫码数
+	SourceRate  float64 `mapstructure:"source_rate"`  // 单个来源（一个网络扫码枪、一个推送令牌或IP）每秒允许的扫码数
+	SourceBurst int     `mapstructure:"source_burst"` // 单个来源允许的突发扫码数
+	Reserved    int     `mapstructure:"reserved"`     // 全局突发额度中只供按键扫码使用的个数，网络扫码枪及外部推送不能占用
+}
+
+// IntegrityConfig 扫码记录完整性模式，记录按工位串成哈希链，事后修改或删除记录可被发现
+//
+// 启用后同一进程内的扫码入库串行执行（每条额外一次SHA-256及一次按索引的查询，扫码速率下可忽略），
+// 不影响读取；只对启用后写入的记录生效。
+type IntegrityConfig struct {
+	Enable        bool          `mapstructure:"enable"`
+	CheckInterval time.Duration `mapstructure:"check_interval"` // 自检间隔，0表示不定期自检
+	CheckWindow   time.Duration `mapstructure:"check_window"`   // 每次自检校验最近这段时间内的记录
+}
+
+// MaxCoalesceWindow 跨设备合并窗口的上限，更长的窗口可能把操作员有意的重复扫码合并掉
+const MaxCoalesceWindow = 500 * time.Millisecond
+
+// CoalesceConfig 跨设备合并，同一工位的手持及固定扫码枪对准同一位置时，同一标签会在几十毫秒内被两台设备各扫一次
+//
+// 启用后不同设备在窗口内扫到（处理后）相同的内容合并为一条记录，记录中登记其他设备，只推送及投递一次；
+// 同一设备的重复扫码不合并。窗口须远小于操作员重复扫同一标签的间隔。
+type CoalesceConfig struct {
+	Enable   bool                    `mapstructure:"enable"`
+	Window   time.Duration           `mapstructure:"window"`   // 合并窗口，以先到的扫码为起点
+	Stations []CoalesceStationConfig `mapstructure:"stations"` // 按工位覆盖合并窗口，按扫码设备所属的工位匹配
+}
+
+// CoalesceStationConfig 一个工位的合并窗口
+type CoalesceStationConfig struct {
+	Station string        `mapstructure:"station"`
+	Window  time.Duration `mapstructure:"window"` // 0表示该工位不合并
+}
+
+// 操作员统计的隐私设置
+const (
+	OperatorPrivacyIdentified    = "identified"    // 显示操作员
+	OperatorPrivacyPseudonymized = "pseudonymized" // 以稳定的化名代替操作员
+	OperatorPrivacyDisabled      = "disabled"      // 不提供按操作员的统计
+)
+
+// OperatorStatsConfig 按操作员的效率统计，操作员取自日结的操作员字段（closing.operator_field）
+//
+// 隐私设置同时作用于统计接口及日结汇总（含导出文件及汇总邮件）中的操作员，修改后立即生效并记录审计日志；
+// 已生成的日结汇总不受影响。
+type OperatorStatsConfig struct {
+	IdleGap         time.Duration `mapstructure:"idle_gap"`         // 同一操作员相邻两次扫码间隔超过此时长时，该段不计入在岗时间
+	Privacy         string        `mapstructure:"privacy"`          // identified、pseudonymized 或 disabled
+	PseudonymSecret string        `mapstructure:"pseudonym_secret"` // 生成化名的密钥，同一密钥下同一操作员的化名不变
+}
+
+// CountersConfig 持久化计数器配置，保存各设备累计扫码数、广播序号及最后扫码时间，重启后继续
+type CountersConfig struct {
+	FlushInterval time.Duration `mapstructure:"flush_interval"` // 批量写入间隔，异常退出时计数最多落后这段时间，不会倒退
+	SequenceBlock int64         `mapstructure:"sequence_block"` // 广播序号每次预留的个数，重启后从预留上限继续编号
+}
+
+// IngestMapping 外部JSON的字段路径，以点分隔，数组下标为数字，如 data.scans.0.code
+type IngestMapping struct {
+	Items           string `mapstructure:"items"`            // 批量数组的路径，为空表示请求体本身为对象或数组
+	Content         string `mapstructure:"content"`          // 条码内容，为空表示只接受本系统的事件格式
+	Timestamp       string `mapstructure:"timestamp"`        // 扫码时间，为空表示使用接收时间
+	TimestampFormat string `mapstructure:"timestamp_format"` // rfc3339、unix、unix_ms
+	Device          string `mapstructure:"device"`           // 设备标识（序列号或公开ID），为空表示默认设备
+}
+
+// CentralConfig 中心服务器配置，多工位部署时由首次运行向导加入
+type CentralConfig struct {
+	URL   string            `mapstructure:"url"`   // 中心服务器地址，为空表示独立工位
+	Token string            `mapstructure:"token"` // 中心服务器签发给本工位的令牌，同时用于校验同步包签名
+	Sync  CentralSyncConfig `mapstructure:"sync"`
+}
+
+// CentralSyncConfig 从中心服务器同步设备、分类规则及系统配置
+type CentralSyncConfig struct {
+	Enable         bool          `mapstructure:"enable"`
+	Interval       time.Duration `mapstructure:"interval"`        // 拉取间隔
+	Timeout        time.Duration `mapstructure:"timeout"`         // 单次请求超时
+	ConflictPolicy string        `mapstructure:"conflict_policy"` // 本地修改过的条目: central, local, review
+}
+
+// AdminConfig 管理操作配置
+type AdminConfig struct {
+	AllowResetInProduction bool          `mapstructure:"allow_reset_in_production"` // 生产环境是否允许数据重置
+	ResetTokenTTL          time.Duration `mapstructure:"reset_token_ttl"`           // 重置确认令牌有效期
+}
+
+// IdleConfig 空闲（距上次扫码时长）监控配置
+type IdleConfig struct {
+	Enable       bool          `mapstructure:"enable"`
+	TickInterval time.Duration `mapstructure:"tick_interval"` // idle_tick 广播间隔
+	TaktTime     time.Duration `mapstructure:"takt_time"`     // 生产节拍，超过时标记为超时
+	AlertAfter   time.Duration `mapstructure:"alert_after"`   // 班次内连续无扫码超过此时长告警，0表示不告警
+	Shifts       []ShiftWindow `mapstructure:"shifts"`        // 生产班次，为空表示全天生产
+}
+
+// ShiftWindow 生产班次时间段（app.timezone 时区的时间，可跨零点）
+type ShiftWindow struct {
+	Name  string `mapstructure:"name"`
+	Start string `mapstructure:"start"` // 如 08:00
+	End   string `mapstructure:"end"`   // 如 20:00
+}
+
+// SequenceConfig 序列号断号检测配置
+type SequenceConfig struct {
+	Enable       bool           `mapstructure:"enable"`
+	ResetBarcode string         `mapstructure:"reset_barcode"` // 扫描该控制条码时重置序号
+	Rules        []SequenceRule `mapstructure:"rules"`
+}
+
+// SequenceRule 断号检测规则
+type SequenceRule struct {
+	Name    string `mapstructure:"name"`
+	Type    string `mapstructure:"type"`    // 匹配的条码类型，为空表示不限
+	Pattern string `mapstructure:"pattern"` // 提取前缀与数字序号的正则
+	Window  int    `mapstructure:"window"`  // 允许乱序的扫码次数
+}
+
 // Load 加载配置
 func Load(configPath string) (*Config, error) {
 	viper.SetConfigFile(configPath)
@@ -109,7 +701,7 @@ func Load(configPath string) (*Config, error) {
 	viper.AutomaticEnv()
 	
 	// 设置默认值
-	setDefaults()
+	setDefaults(viper.GetViper())
 	
 	// 读取配置文件
 	if err := viper.ReadInConfig(); err != nil {
@@ -124,66 +716,350 @@ func Load(configPath string) (*Config, error) {
 	return &config, nil
 }
 
+// Defaults 全部使用默认值的配置，不读取配置文件及环境变量
+func Defaults() *Config {
+	v := viper.New()
+	setDefaults(v)
+	
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		// 默认值均为合法的字面量，解析失败属于程序错误
+		panic(fmt.Sprintf("解析默认配置失败: %v", err))
+	}
+	return &config
+}
+
+// InConfigFile 配置文件中是否显式设置了该项，默认值不算
+func InConfigFile(key string) bool {
+	return viper.InConfig(key)
+}
+
 // setDefaults 设置默认值
-func setDefaults() {
+func setDefaults(v *viper.Viper) {
 	// App defaults
-	viper.SetDefault("app.name", "Barcode Scanner Monitor")
-	viper.SetDefault("app.version", "2.0.0")
-	viper.SetDefault("app.env", "development")
-	viper.SetDefault("app.debug", true)
+	v.SetDefault("app.name", "Barcode Scanner Monitor")
+	v.SetDefault("app.version", "2.0.0")
+	v.SetDefault("app.env", "development")
+	v.SetDefault("app.debug", true)
+	v.SetDefault("app.timezone", "")
+	v.SetDefault("app.locale", "zh-CN")
+	v.SetDefault("app.provisioned", true) // 升级前的安装没有此项，视为已完成配置
+	v.SetDefault("app.read_only", false)
 	
 	// Server defaults
-	viper.SetDefault("server.host", "localhost")
-	viper.SetDefault("server.port", 8080)
-	viper.SetDefault("server.read_timeout", "30s")
-	viper.SetDefault("server.write_timeout", "30s")
-	viper.SetDefault("server.idle_timeout", "60s")
+	v.SetDefault("server.host", "localhost")
+	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.port_fallback_range", 0)
+	v.SetDefault("server.read_timeout", "30s")
+	v.SetDefault("server.write_timeout", "30s")
+	v.SetDefault("server.idle_timeout", "60s")
 	
 	// Database defaults
-	viper.SetDefault("database.type", "sqlite")
-	viper.SetDefault("database.dsn", "./data/scanner.db")
-	viper.SetDefault("database.max_idle_conns", 10)
-	viper.SetDefault("database.max_open_conns", 100)
-	viper.SetDefault("database.conn_max_lifetime", "3600s")
-	viper.SetDefault("database.log_level", "info")
+	v.SetDefault("database.type", "sqlite")
+	v.SetDefault("database.dsn", "./data/scanner.db")
+	v.SetDefault("database.max_idle_conns", 10)
+	v.SetDefault("database.max_open_conns", 100)
+	v.SetDefault("database.conn_max_lifetime", "3600s")
+	v.SetDefault("database.log_level", "info")
+	v.SetDefault("database.legacy_timezone", "")
+	v.SetDefault("database.config_cache_ttl", "30s")
+	v.SetDefault("database.secondary.enable", false)
+	v.SetDefault("database.secondary.dsn", "./data/scanner-local.db")
+	v.SetDefault("database.secondary.health_interval", "10s")
+	v.SetDefault("database.secondary.reconcile_interval", "5m")
+	v.SetDefault("database.secondary.reconcile_window", "24h")
+	v.SetDefault("database.backfill.batch_size", 500)
+	v.SetDefault("database.backfill.interval", "200ms")
+	v.SetDefault("database.maintenance.enable", true)
+	v.SetDefault("database.maintenance.interval", "24h")
+	v.SetDefault("database.maintenance.check_interval", "5m")
+	v.SetDefault("database.maintenance.idle_after", "30m")
+	v.SetDefault("database.maintenance.incremental_pages", 0)
+	v.SetDefault("database.maintenance.full_vacuum", false)
+	v.SetDefault("database.maintenance.full_vacuum_interval", "168h")
 	
 	// Scanner defaults
-	viper.SetDefault("scanner.timeout_ms", 100)
-	viper.SetDefault("scanner.min_length", 3)
-	viper.SetDefault("scanner.max_length", 50)
-	viper.SetDefault("scanner.enable_hook", true)
+	v.SetDefault("scanner.timeout_ms", 100)
+	v.SetDefault("scanner.min_length", 3)
+	v.SetDefault("scanner.max_length", 50)
+	v.SetDefault("scanner.enable_hook", true)
+	v.SetDefault("scanner.terminator_collapse_ms", 30)
+	v.SetDefault("scanner.merge_fragments", true)
+	v.SetDefault("scanner.use_system_layout", false)
+	v.SetDefault("scanner.ignore_injected", true)
+	v.SetDefault("scanner.suppress_input", false)
+	v.SetDefault("scanner.suppress_after_keys", 3)
+	v.SetDefault("scanner.max_avg_key_interval_ms", 0)
+	v.SetDefault("scanner.terminators", []string{"enter"})
+	v.SetDefault("scanner.flush_on_timeout", false)
+	v.SetDefault("scanner.group_separator", []string{"ctrl+]"})
+	v.SetDefault("scanner.max_buffer_factor", 2)
+	v.SetDefault("scanner.backend", ScannerBackendHook)
+	v.SetDefault("scanner.ignore_unregistered", true)
+	v.SetDefault("scanner.evdev_devices", []string{})
+	v.SetDefault("scanner.suspect_gap_ms", 50)
+	v.SetDefault("scanner.suspect_alert_rate", 0.05)
+	v.SetDefault("scanner.suspect_alert_window", 100)
+	v.SetDefault("scanner.preset", "")
+	v.SetDefault("scanner.watchdog_interval", "30s")
+	v.SetDefault("scanner.buffer_size", 256)
+	v.SetDefault("scanner.overflow", ScanOverflowBlock)
+	v.SetDefault("scanner.adaptive_timeout.floor_ms", 30)
+	v.SetDefault("scanner.adaptive_timeout.ceiling_ms", 500)
+	v.SetDefault("scanner.adaptive_timeout.min_scans", 20)
+	v.SetDefault("scanner.adaptive_timeout.window", 500)
+	v.SetDefault("scanner.adaptive_timeout.headroom", 2.0)
 	
 	// WebSocket defaults
-	viper.SetDefault("websocket.path", "/ws")
-	viper.SetDefault("websocket.read_buffer_size", 1024)
-	viper.SetDefault("websocket.write_buffer_size", 1024)
-	viper.SetDefault("websocket.check_origin", true)
-	viper.SetDefault("websocket.ping_period", "54s")
-	viper.SetDefault("websocket.pong_wait", "60s")
-	viper.SetDefault("websocket.write_wait", "10s")
+	v.SetDefault("websocket.path", "/ws")
+	v.SetDefault("websocket.read_buffer_size", 1024)
+	v.SetDefault("websocket.write_buffer_size", 1024)
+	v.SetDefault("websocket.check_origin", true)
+	v.SetDefault("websocket.ping_period", "54s")
+	v.SetDefault("websocket.pong_wait", "60s")
+	v.SetDefault("websocket.write_wait", "10s")
+	v.SetDefault("websocket.replay_size", 1000)
+	v.SetDefault("websocket.max_message_size", 65536)
+	v.SetDefault("websocket.compaction.enable", false)
+	v.SetDefault("websocket.compaction.window", "2s")
 	
 	// API defaults
-	viper.SetDefault("api.prefix", "/api/v1")
-	viper.SetDefault("api.enable_cors", true)
-	viper.SetDefault("api.cors_origins", []string{"*"})
-	viper.SetDefault("api.rate_limit.enable", true)
-	viper.SetDefault("api.rate_limit.requests_per_minute", 100)
+	v.SetDefault("api.prefix", "/api/v1")
+	v.SetDefault("api.enable_cors", true)
+	v.SetDefault("api.cors_origins", []string{"*"})
+	v.SetDefault("api.rate_limit.enable", true)
+	v.SetDefault("api.rate_limit.requests_per_minute", 100)
+	v.SetDefault("api.stats_cache_ttl", "5s")
+	v.SetDefault("api.distinct_precision", 14)
 	
 	// Log defaults
-	viper.SetDefault("log.level", "info")
-	viper.SetDefault("log.format", "json")
-	viper.SetDefault("log.output", "stdout")
-	viper.SetDefault("log.file_path", "./logs/app.log")
-	viper.SetDefault("log.max_size", 100)
-	viper.SetDefault("log.max_backups", 3)
-	viper.SetDefault("log.max_age", 28)
-	viper.SetDefault("log.compress", true)
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "json")
+	v.SetDefault("log.output", "stdout")
+	v.SetDefault("log.file_path", "./logs/app.log")
+	v.SetDefault("log.max_size", 100)
+	v.SetDefault("log.max_backups", 3)
+	v.SetDefault("log.max_age", 28)
+	v.SetDefault("log.compress", true)
 	
 	// Security defaults
-	viper.SetDefault("security.enable_auth", false)
-	viper.SetDefault("security.jwt_secret", "your-secret-key")
-	viper.SetDefault("security.jwt_expire", "24h")
-	viper.SetDefault("security.api_key", "your-api-key")
+	v.SetDefault("security.enable_auth", false)
+	v.SetDefault("security.jwt_secret", "your-secret-key")
+	v.SetDefault("security.jwt_expire", "24h")
+	v.SetDefault("security.api_key", "your-api-key")
+	
+	// Sinks defaults
+	v.SetDefault("sinks.file.enable", false)
+	v.SetDefault("sinks.file.dir", "./data/events")
+	v.SetDefault("sinks.file.rotation", "daily")
+	v.SetDefault("sinks.file.max_files", 7)
+	v.SetDefault("sinks.file.event_types", []string{})
+	v.SetDefault("sinks.file.flush_interval", "2s")
+	v.SetDefault("sinks.file.format.timestamp_format", "rfc3339")
+	v.SetDefault("sinks.file.format.field_naming", "current")
+	v.SetDefault("sinks.file.format.flatten", false)
+	v.SetDefault("sinks.outbox.enable", false)
+	v.SetDefault("sinks.outbox.poll_interval", "1s")
+	v.SetDefault("sinks.outbox.batch_size", 100)
+	v.SetDefault("sinks.outbox.max_backoff", "5m")
+	v.SetDefault("sinks.outbox.retention", "24h")
+	v.SetDefault("sinks.replay.rate", 50)
+	v.SetDefault("sinks.replay.batch_size", 100)
+	
+	// Sequence defaults
+	v.SetDefault("sequence.enable", false)
+	v.SetDefault("sequence.reset_barcode", "SEQ-RESET")
+	
+	// Images defaults
+	v.SetDefault("images.enable", false)
+	v.SetDefault("images.dir", "./data/images")
+	v.SetDefault("images.max_size_kb", 5120)
+	v.SetDefault("images.allowed_types", []string{"image/jpeg", "image/png"})
+	v.SetDefault("images.retention", "720h")
+	v.SetDefault("images.cleanup_interval", "1h")
+	
+	// Peers defaults
+	v.SetDefault("peers.enable", false)
+	v.SetDefault("peers.station", "station-1")
+	v.SetDefault("peers.event_types", []string{"barcode"})
+	v.SetDefault("peers.queue_size", 1000)
+	v.SetDefault("peers.max_backoff", "30s")
+	v.SetDefault("peers.timeout", "5s")
+	
+	// Classification defaults
+	v.SetDefault("classification.indexed_fields", []string{})
+	
+	// Profiles defaults
+	v.SetDefault("profiles.setup_code_patterns", []string{})
+	
+	// Commands defaults
+	v.SetDefault("commands.timeout", "3s")
+	v.SetDefault("commands.max_response", 4096)
+	v.SetDefault("commands.concurrency", 8)
+	
+	// Heartbeat defaults
+	v.SetDefault("heartbeat.enable", true)
+	v.SetDefault("heartbeat.interval", "1h")
+	v.SetDefault("heartbeat.retention", "2160h")
+	v.SetDefault("heartbeat.buffer_size", 24)
+	
+	// Goals defaults
+	v.SetDefault("goals.enable", true)
+	v.SetDefault("goals.milestones", []int{25, 50, 75, 100})
+	
+	// Status page defaults
+	v.SetDefault("status_page.enable", true)
+	v.SetDefault("status_page.fields", []string{"capturing", "recent_scan", "version"})
+	v.SetDefault("status_page.recent_scan_window", "10m")
+	v.SetDefault("status_page.rate_limit", 30)
+	
+	// Ingest defaults
+	v.SetDefault("ingest.enable", false)
+	v.SetDefault("ingest.max_batch", 100)
+	v.SetDefault("ingest.mapping.items", "")
+	v.SetDefault("ingest.mapping.content", "")
+	v.SetDefault("ingest.mapping.timestamp", "")
+	v.SetDefault("ingest.mapping.timestamp_format", "rfc3339")
+	v.SetDefault("ingest.mapping.device", "")
+
+	// Acks defaults
+	v.SetDefault("acks.consumers", []string{})
+	v.SetDefault("acks.max_batch", 1000)
+
+	// Central defaults
+	v.SetDefault("central.url", "")
+	v.SetDefault("central.token", "")
+	v.SetDefault("central.sync.enable", false)
+	v.SetDefault("central.sync.interval", "5m")
+	v.SetDefault("central.sync.timeout", "10s")
+	v.SetDefault("central.sync.conflict_policy", "review")
+	
+	// Agent defaults
+	v.SetDefault("agent.enable", false)
+	v.SetDefault("agent.token", "")
+	
+	// Preferences defaults
+	v.SetDefault("preferences.enable", true)
+	v.SetDefault("preferences.max_bytes", 16384)
+	v.SetDefault("preferences.retention", "4320h")
+	
+	// Closing defaults
+	v.SetDefault("closing.enable", true)
+	v.SetDefault("closing.operator_field", "operator")
+	v.SetDefault("closing.export_dir", "")
+	v.SetDefault("closing.email.enable", false)
+	v.SetDefault("closing.email.addr", "")
+	v.SetDefault("closing.email.username", "")
+	v.SetDefault("closing.email.password", "")
+	v.SetDefault("closing.email.from", "")
+	v.SetDefault("closing.email.to", []string{})
+	
+	// Simulator defaults
+	v.SetDefault("simulator.enable", false)
+	v.SetDefault("simulator.scenario", "")
+	v.SetDefault("simulator.auto_start", false)
+	v.SetDefault("simulator.loop", false)
+	v.SetDefault("simulator.speed", 1.0)
+	v.SetDefault("simulator.exclude_from_stats", true)
+	
+	// Metrics history defaults
+	v.SetDefault("metrics_history.enable", true)
+	v.SetDefault("metrics_history.interval", "5m")
+	v.SetDefault("metrics_history.retention", "720h")
+	
+	// Serial defaults
+	v.SetDefault("serial.enable", false)
+	v.SetDefault("serial.reconnect_min", "1s")
+	v.SetDefault("serial.reconnect_max", "30s")
+	
+	// Network scanner defaults
+	v.SetDefault("network_scanner.enable", false)
+	v.SetDefault("network_scanner.listen", ":9100")
+	v.SetDefault("network_scanner.terminator", "enter")
+	v.SetDefault("network_scanner.idle_timeout", "0s")
+	v.SetDefault("network_scanner.max_conns", 16)
+	
+	// Standby defaults
+	v.SetDefault("standby.enable", false)
+	v.SetDefault("standby.role", StandbyRolePrimary)
+	v.SetDefault("standby.instance", "")
+	v.SetDefault("standby.primary_url", "")
+	v.SetDefault("standby.check_interval", "5s")
+	v.SetDefault("standby.timeout", "3s")
+	v.SetDefault("standby.takeover_after", "30s")
+	v.SetDefault("standby.recover_after", "60s")
+	v.SetDefault("standby.lease", false)
+	v.SetDefault("standby.lease_ttl", "20s")
+	
+	// Quality defaults
+	v.SetDefault("quality.enable", true)
+	v.SetDefault("quality.window", "24h")
+	v.SetDefault("quality.batch_size", 500)
+	v.SetDefault("quality.code128_pattern", `^[0-9A-Z.\-]+$`)
+	v.SetDefault("quality.min_scans", 50)
+	v.SetDefault("quality.regression_threshold", 2.0)
+	v.SetDefault("quality.retention", "2160h")
+	
+	// Recovery defaults
+	v.SetDefault("recovery.enable", true)
+	v.SetDefault("recovery.sessions", RecoverySessionsResume)
+	v.SetDefault("recovery.end_maintenance", false)
+	
+	// Messages defaults（viper 的键不区分大小写，语言统一按小写保存）
+	v.SetDefault("messages.locale", "zh-CN")
+	v.SetDefault("messages.templates.zh-cn.format", "{station} {device}：{message}")
+	v.SetDefault("messages.templates.zh-cn.unknown_device", "未登记设备")
+	v.SetDefault("messages.templates.zh-cn.unknown_station", "本工位")
+	
+	// Ingestion defaults
+	v.SetDefault("ingestion.enable", true)
+	v.SetDefault("ingestion.global_rate", 50)
+	v.SetDefault("ingestion.global_burst", 100)
+	v.SetDefault("ingestion.source_rate", 20)
+	v.SetDefault("ingestion.source_burst", 40)
+	v.SetDefault("ingestion.reserved", 20)
+	
+	// Integrity defaults
+	v.SetDefault("integrity.enable", false)
+	v.SetDefault("integrity.check_interval", "1h")
+	v.SetDefault("integrity.check_window", "24h")
+	
+	// Coalesce defaults
+	v.SetDefault("coalesce.enable", false)
+	v.SetDefault("coalesce.window", "80ms")
+	
+	// Operator stats defaults
+	v.SetDefault("operator_stats.idle_gap", "5m")
+	v.SetDefault("operator_stats.privacy", OperatorPrivacyIdentified)
+	v.SetDefault("operator_stats.pseudonym_secret", "")
+	
+	// Counters defaults
+	v.SetDefault("counters.flush_interval", "5s")
+	v.SetDefault("counters.sequence_block", 1000)
+	
+	// Reload defaults
+	v.SetDefault("reload.enable", true)
+	v.SetDefault("reload.debounce", "500ms")
+	v.SetDefault("reload.snapshot", "./data/config.last-good.yaml")
+	
+	// Admin defaults
+	v.SetDefault("admin.allow_reset_in_production", false)
+	v.SetDefault("admin.reset_token_ttl", "2m")
+	
+	// Idle defaults
+	v.SetDefault("idle.enable", true)
+	v.SetDefault("idle.tick_interval", "5s")
+	v.SetDefault("idle.takt_time", "1m")
+	v.SetDefault("idle.alert_after", "10m")
+	
+	// Health defaults
+	v.SetDefault("health.enable", true)
+	v.SetDefault("health.window", "1h")
+	v.SetDefault("health.snapshot_interval", "1h")
+	v.SetDefault("health.min_scans", 20)
+	v.SetDefault("health.slow_scan_ms", 300)
+	v.SetDefault("health.alert_threshold", 60)
+	v.SetDefault("health.drop_threshold", 20)
 }
 
 // GetServerAddr 获取服务器地址
@@ -199,4 +1075,9 @@ func (c *Config) IsDevelopment() bool {
 // IsProduction 是否为生产环境
 func (c *Config) IsProduction() bool {
 	return c.App.Env == "production"
+}
+
+// IsTest 是否为测试环境
+func (c *Config) IsTest() bool {
+	return c.App.Env == "test"
 }
\ No newline at end of file
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// SettingDiff 与默认值不同的配置项
+type SettingDiff struct {
+	Key     string      `json:"key"`
+	Default interface{} `json:"default"` // 无默认值时为null
+	Value   interface{} `json:"value"`
+}
+
+// DefaultSettings 仅由默认值构成的配置项
+func DefaultSettings() map[string]interface{} {
+	v := viper.New()
+	setDefaults(v)
+	return v.AllSettings()
+}
+
+// Diff 当前生效的配置（配置文件、环境变量与默认值合并后）中与默认值不同的项，敏感值已脱敏
+func Diff() []SettingDiff {
+	defaults := flatten("", DefaultSettings(), map[string]interface{}{})
+	effective := flatten("", viper.AllSettings(), map[string]interface{}{})
+
+	diffs := make([]SettingDiff, 0)
+	for key, value := range effective {
+		def, ok := defaults[key]
+		if ok && fmt.Sprint(def) == fmt.Sprint(value) {
+			continue
+		}
+		// 无默认值的空列表等同于未设置
+		if !ok && isEmptySetting(value) {
+			continue
+		}
+		diff := SettingDiff{Key: key, Value: redactSetting(key, value)}
+		if ok {
+			diff.Default = redactSetting(key, def)
+		}
+		diffs = append(diffs, diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Key < diffs[j].Key
+	})
+	return diffs
+}
+
+// flatten 将嵌套配置展开为以点分隔的键，列表整体作为一个值
+func flatten(prefix string, settings map[string]interface{}, result map[string]interface{}) map[string]interface{} {
+	for key, value := range settings {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, result)
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// isEmptySetting 判断配置值是否为空
+func isEmptySetting(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// redactSetting 脱敏配置值，列表及映射中的敏感字段同样处理
+func redactSetting(key string, value interface{}) interface{} {
+	leaf := key[strings.LastIndex(key, ".")+1:]
+	switch v := value.(type) {
+	case string:
+		switch {
+		case v == "":
+			return v
+		case IsSensitiveKey(leaf):
+			return RedactedValue
+		case leaf == "dsn":
+			return RedactDSN(v)
+		case leaf == "url":
+			return RedactURL(v)
+		}
+		return v
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactSetting(key, item)
+		}
+		return items
+	case map[string]interface{}:
+		items := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			items[k] = redactSetting(key+"."+k, item)
+		}
+		return items
+	case map[interface{}]interface{}:
+		items := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			name := fmt.Sprint(k)
+			items[name] = redactSetting(key+"."+name, item)
+		}
+		return items
+	}
+	if IsSensitiveKey(leaf) {
+		return RedactedValue
+	}
+	return value
+}
@@ -0,0 +1,45 @@
+package config
+
+// ApplyReadOnly 只读模式下关闭扫码来源、事件输出及会写入数据库的后台任务，返回被关闭的配置项
+//
+// 只读模式用于查看及导出中心保存的工位数据库副本，数据库同时以只读方式打开，
+// 此处关闭的功能即使误开也无法写入，关闭是为了避免启动后不断报错。
+func (c *Config) ApplyReadOnly() []string {
+	if !c.App.ReadOnly {
+		return nil
+	}
+
+	switches := []struct {
+		key     string
+		enabled *bool
+	}{
+		{"scanner.enable_hook", &c.Scanner.EnableHook},
+		{"ingest.enable", &c.Ingest.Enable},
+		{"agent.enable", &c.Agent.Enable},
+		{"preferences.enable", &c.Preferences.Enable},
+		{"simulator.enable", &c.Simulator.Enable},
+		{"serial.enable", &c.Serial.Enable},
+		{"network_scanner.enable", &c.NetworkScanner.Enable},
+		{"standby.enable", &c.Standby.Enable},
+		{"sinks.file.enable", &c.Sinks.File.Enable},
+		{"sinks.outbox.enable", &c.Sinks.Outbox.Enable},
+		{"peers.enable", &c.Peers.Enable},
+		{"heartbeat.enable", &c.Heartbeat.Enable},
+		{"metrics_history.enable", &c.MetricsHistory.Enable},
+		{"quality.enable", &c.Quality.Enable},
+		{"recovery.enable", &c.Recovery.Enable},
+		{"idle.enable", &c.Idle.Enable},
+		{"database.maintenance.enable", &c.Database.Maintenance.Enable},
+		{"database.secondary.enable", &c.Database.Secondary.Enable},
+		{"central.sync.enable", &c.Central.Sync.Enable},
+	}
+
+	var disabled []string
+	for _, s := range switches {
+		if *s.enabled {
+			*s.enabled = false
+			disabled = append(disabled, s.key)
+		}
+	}
+	return disabled
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue 敏感值的替代文本
+const RedactedValue = "xxxxx"
+
+// dsnPasswordPattern DSN中 key=value 形式的密码
+var dsnPasswordPattern = regexp.MustCompile(`(?i)(password|pwd)=([^\s;&]+)`)
+
+// sensitiveKeys 配置项名称中含有以下片段时视为敏感值
+var sensitiveKeys = []string{"secret", "password", "token", "api_key"}
+
+// IsSensitiveKey 判断配置项是否为敏感值
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, fragment := range sensitiveKeys {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactDSN 隐藏DSN中的密码
+func RedactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		return RedactURL(dsn)
+	}
+	// user:password@tcp(host)/db 形式
+	if at := strings.Index(dsn, "@"); at > 0 {
+		if colon := strings.Index(dsn[:at], ":"); colon >= 0 {
+			dsn = dsn[:colon+1] + RedactedValue + dsn[at:]
+		}
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "${1}="+RedactedValue)
+}
+
+// RedactURL 隐藏URL中的密码及令牌类查询参数
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return RedactedValue
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), RedactedValue)
+		}
+	}
+	query := u.Query()
+	for key := range query {
+		if IsSensitiveKey(key) || strings.EqualFold(key, "key") {
+			query.Set(key, RedactedValue)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,766 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/mapstructure"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"userclient/internal/i18n"
+	"userclient/pkg/barcode"
+)
+
+// decodeKeyPattern 解析错误中的配置键，如 error decoding 'server.read_timeout': ...
+var decodeKeyPattern = regexp.MustCompile(`'([^']+)'`)
+
+// consumerNamePattern 消费方名称规则
+var consumerNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// Version 配置版本
+type Version struct {
+	Version  int       `json:"version"`
+	Checksum string    `json:"checksum"` // 配置文件内容的SHA-256前12位
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// Rejection 被拒绝的配置项
+type Rejection struct {
+	Key    string `json:"key,omitempty"` // 为空表示整个配置文件
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// RollbackEvent 配置回滚事件
+type RollbackEvent struct {
+	Rejected   Version     `json:"rejected"`
+	RestoredTo Version     `json:"restored_to"`
+	Rejections []Rejection `json:"rejections"`
+	At         time.Time   `json:"at"`
+}
+
+// ReloadState 配置热加载状态
+type ReloadState struct {
+	Path         string         `json:"path"`
+	Snapshot     string         `json:"snapshot"`
+	Active       Version        `json:"active"`
+	LastGood     Version        `json:"last_good"`
+	Pending      *Version       `json:"pending,omitempty"` // 正在校验或应用的版本
+	LastRollback *RollbackEvent `json:"last_rollback,omitempty"`
+}
+
+// Parse 解析配置文件内容并校验，返回被拒绝的配置项
+func Parse(data []byte) (*Config, []Rejection) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetEnvPrefix("SCANNER")
+	v.AutomaticEnv()
+	setDefaults(v)
+
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, []Rejection{{Reason: fmt.Sprintf("配置文件格式错误: %v", err)}}
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, decodeRejections(v, err)
+	}
+
+	if rejections := Validate(&cfg); len(rejections) > 0 {
+		return nil, rejections
+	}
+	return &cfg, nil
+}
+
+// decodeRejections 将解析错误拆分为逐个配置项的拒绝原因
+func decodeRejections(v *viper.Viper, err error) []Rejection {
+	var decodeErr *mapstructure.Error
+	if !errors.As(err, &decodeErr) {
+		return []Rejection{{Reason: fmt.Sprintf("解析配置文件失败: %v", err)}}
+	}
+
+	rejections := make([]Rejection, 0, len(decodeErr.Errors))
+	for _, message := range decodeErr.Errors {
+		rejection := Rejection{Reason: message}
+		if match := decodeKeyPattern.FindStringSubmatch(message); match != nil {
+			rejection.Key = match[1]
+			rejection.Value = fmt.Sprint(v.Get(match[1]))
+		}
+		rejections = append(rejections, rejection)
+	}
+	return rejections
+}
+
+// Validate 校验配置取值，返回不合法的配置项
+func Validate(c *Config) []Rejection {
+	var rejections []Rejection
+	reject := func(key string, value interface{}, reason string) {
+		rejections = append(rejections, Rejection{Key: key, Value: fmt.Sprint(value), Reason: reason})
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		reject("server.port", c.Server.Port, "端口必须在1~65535之间")
+	} else if c.Server.PortFallbackRange < 0 || c.Server.Port+c.Server.PortFallbackRange > 65535 {
+		reject("server.port_fallback_range", c.Server.PortFallbackRange, "备用端口超出1~65535")
+	}
+	durations := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"heartbeat.interval", c.Heartbeat.Interval},
+		{"reload.debounce", c.Reload.Debounce},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			reject(d.key, d.value, "时长必须大于0")
+		}
+	}
+	if c.Database.DSN == "" {
+		reject("database.dsn", c.Database.DSN, "数据库地址不能为空")
+	}
+	if c.Scanner.MinLength < 1 {
+		reject("scanner.min_length", c.Scanner.MinLength, "最小长度必须大于0")
+	}
+	if c.Scanner.MaxLength < c.Scanner.MinLength {
+		reject("scanner.max_length", c.Scanner.MaxLength, "最大长度不能小于最小长度")
+	}
+	if c.Scanner.MaxBufferFactor < 1 {
+		reject("scanner.max_buffer_factor", c.Scanner.MaxBufferFactor, "缓冲上限倍数必须大于0")
+	}
+	if len(c.Scanner.Terminators) == 0 {
+		reject("scanner.terminators", c.Scanner.Terminators, "终止符不能为空，扫码枪不发送后缀时设置为 [\"none\"]")
+	}
+	for _, name := range c.Scanner.Terminators {
+		switch name {
+		case "enter", "cr", "lf", "tab":
+		case "none":
+			if len(c.Scanner.Terminators) > 1 {
+				reject("scanner.terminators", c.Scanner.Terminators, "none 不能与其他终止符同时配置")
+			}
+		default:
+			reject("scanner.terminators", name, "终止符须为 enter、cr、lf、tab 或 none")
+		}
+	}
+	for _, name := range c.Scanner.GroupSeparator {
+		if !validGroupSeparator(name) {
+			reject("scanner.group_separator", name, "组分隔符按键须为 ctrl+]、] 或 f1-f12")
+		}
+	}
+	if c.Scanner.SuppressInput && c.Scanner.SuppressAfterKeys < 2 {
+		reject("scanner.suppress_after_keys", c.Scanner.SuppressAfterKeys, "判定为扫码的按键数不能小于2")
+	}
+	// 按键间隔超过 timeout_ms 时缓冲已被丢弃，阈值不小于 timeout_ms 不会生效
+	if c.Scanner.MaxAvgKeyIntervalMS < 0 || c.Scanner.MaxAvgKeyIntervalMS > 0 && c.Scanner.MaxAvgKeyIntervalMS >= c.Scanner.TimeoutMS {
+		reject("scanner.max_avg_key_interval_ms", c.Scanner.MaxAvgKeyIntervalMS, "最大平均按键间隔须小于 timeout_ms，0表示不判断")
+	}
+	if adaptive := c.Scanner.AdaptiveTimeout; adaptive.FloorMS < 10 || adaptive.CeilingMS < adaptive.FloorMS {
+		reject("scanner.adaptive_timeout", adaptive, "floor_ms 不能小于10，ceiling_ms 不能小于 floor_ms")
+	}
+	if adaptive := c.Scanner.AdaptiveTimeout; adaptive.MinScans < 1 || adaptive.Window < 10 || adaptive.Headroom < 1 {
+		reject("scanner.adaptive_timeout", adaptive, "min_scans 须大于0，window 不能小于10，headroom 不能小于1")
+	}
+	if c.Scanner.BufferSize < 1 || c.Scanner.BufferSize > 10000 {
+		reject("scanner.buffer_size", c.Scanner.BufferSize, "扫码缓冲须在1~10000之间")
+	}
+	if c.Scanner.Overflow != ScanOverflowBlock && c.Scanner.Overflow != ScanOverflowDropOldest {
+		reject("scanner.overflow", c.Scanner.Overflow, "须为 block 或 drop_oldest")
+	}
+	switch c.Scanner.Backend {
+	case ScannerBackendHook:
+	case ScannerBackendRawInput:
+		if c.Scanner.SuppressInput {
+			reject("scanner.suppress_input", c.Scanner.SuppressInput, "rawinput 无法拦截按键，不能开启 suppress_input")
+		}
+	default:
+		reject("scanner.backend", c.Scanner.Backend, "按键采集方式须为 hook 或 rawinput")
+	}
+	for _, pattern := range c.Scanner.EvdevDevices {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			reject("scanner.evdev_devices", pattern, "设备名称通配符无效")
+		}
+	}
+	if c.Scanner.SuspectAlertRate < 0 || c.Scanner.SuspectAlertRate > 1 {
+		reject("scanner.suspect_alert_rate", c.Scanner.SuspectAlertRate, "告警比例必须在0~1之间")
+	}
+	if c.Scanner.WatchdogInterval < 0 || c.Scanner.WatchdogInterval > 0 && c.Scanner.WatchdogInterval < 5*time.Second {
+		reject("scanner.watchdog_interval", c.Scanner.WatchdogInterval, "看门狗间隔不能小于5秒，0表示关闭")
+	}
+	if _, err := c.App.Location(); err != nil {
+		reject("app.timezone", c.App.Timezone, "时区无效，应为IANA时区名称，如 Asia/Shanghai")
+	}
+	if _, ok := i18n.Lookup(c.App.Locale); !ok {
+		reject("app.locale", c.App.Locale, "不支持的语言，应为 "+strings.Join(i18n.Supported(), "、"))
+	}
+	if _, err := LoadLocation(c.Database.LegacyTimezone); err != nil {
+		reject("database.legacy_timezone", c.Database.LegacyTimezone, "时区无效，应为IANA时区名称，如 Asia/Shanghai")
+	}
+	if c.WebSocket.MaxMessageSize < 1024 {
+		reject("websocket.max_message_size", c.WebSocket.MaxMessageSize, "消息大小上限不能小于1024字节")
+	}
+	if c.API.DistinctPrecision < 4 || c.API.DistinctPrecision > 18 {
+		reject("api.distinct_precision", c.API.DistinctPrecision, "估算精度必须在4~18之间")
+	}
+	if m := c.Database.Maintenance; m.Enable {
+		if m.Interval <= 0 {
+			reject("database.maintenance.interval", m.Interval, "时长必须大于0")
+		}
+		if m.CheckInterval <= 0 {
+			reject("database.maintenance.check_interval", m.CheckInterval, "时长必须大于0")
+		}
+		if len(m.QuietWindows) == 0 && m.IdleAfter <= 0 {
+			reject("database.maintenance.idle_after", m.IdleAfter, "未配置安静时段时须大于0，否则维护永远不会执行")
+		}
+		for i, window := range m.QuietWindows {
+			if _, err := time.Parse("15:04", window.Start); err != nil {
+				reject(fmt.Sprintf("database.maintenance.quiet_windows[%d].start", i), window.Start, "须为 HH:MM 格式")
+			}
+			if _, err := time.Parse("15:04", window.End); err != nil {
+				reject(fmt.Sprintf("database.maintenance.quiet_windows[%d].end", i), window.End, "须为 HH:MM 格式")
+			}
+		}
+		if m.IncrementalPages < 0 {
+			reject("database.maintenance.incremental_pages", m.IncrementalPages, "不能小于0")
+		}
+		if m.FullVacuum && m.FullVacuumInterval <= 0 {
+			reject("database.maintenance.full_vacuum_interval", m.FullVacuumInterval, "时长必须大于0")
+		}
+	}
+	if c.Database.Backfill.BatchSize < 1 {
+		reject("database.backfill.batch_size", c.Database.Backfill.BatchSize, "不能小于1")
+	}
+	if c.Database.Backfill.Interval < 0 {
+		reject("database.backfill.interval", c.Database.Backfill.Interval, "不能小于0")
+	}
+	if c.Central.URL != "" {
+		if u, err := url.Parse(c.Central.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			reject("central.url", c.Central.URL, "须为 http:// 或 https:// 开头的地址")
+		}
+	}
+	if c.Central.Sync.Enable {
+		if c.Central.URL == "" || c.Central.Token == "" {
+			reject("central.sync.enable", c.Central.Sync.Enable, "启用同步须配置 central.url 及 central.token")
+		}
+		if c.Central.Sync.Interval < 10*time.Second {
+			reject("central.sync.interval", c.Central.Sync.Interval, "同步间隔不能小于10s")
+		}
+		if c.Central.Sync.Timeout <= 0 {
+			reject("central.sync.timeout", c.Central.Sync.Timeout, "时长必须大于0")
+		}
+	}
+	switch c.Central.Sync.ConflictPolicy {
+	case "central", "local", "review":
+	default:
+		reject("central.sync.conflict_policy", c.Central.Sync.ConflictPolicy, "须为 central、local 或 review")
+	}
+	seen := make(map[string]bool, len(c.Acks.Consumers))
+	for _, name := range c.Acks.Consumers {
+		if !consumerNamePattern.MatchString(name) {
+			reject("acks.consumers", name, "消费方名称须为1到64个小写字母、数字、下划线或连字符")
+		} else if seen[name] {
+			reject("acks.consumers", name, "消费方名称重复")
+		}
+		seen[name] = true
+	}
+	if c.Acks.MaxBatch < 1 {
+		reject("acks.max_batch", c.Acks.MaxBatch, "批量条数必须大于0")
+	}
+	if c.Ingest.MaxBatch < 1 {
+		reject("ingest.max_batch", c.Ingest.MaxBatch, "批量条数必须大于0")
+	}
+	switch c.Ingest.Mapping.TimestampFormat {
+	case "rfc3339", "unix", "unix_ms":
+	default:
+		reject("ingest.mapping.timestamp_format", c.Ingest.Mapping.TimestampFormat, "时间格式必须为 rfc3339、unix 或 unix_ms")
+	}
+	if c.Agent.Enable && len(c.Agent.Token) < 16 {
+		reject("agent.token", RedactedValue, "启用键盘钩子代理时须设置至少16个字符的共享令牌")
+	}
+	if c.Preferences.Enable && c.Preferences.MaxBytes < 2 {
+		reject("preferences.max_bytes", c.Preferences.MaxBytes, "偏好设置的最大字节数至少为2")
+	}
+	if c.Preferences.Retention < 0 {
+		reject("preferences.retention", c.Preferences.Retention, "保留时长不能为负数")
+	}
+	if c.Simulator.Speed <= 0 || c.Simulator.Speed > 100 {
+		reject("simulator.speed", c.Simulator.Speed, "倍速须大于0且不超过100")
+	}
+	if c.MetricsHistory.Enable {
+		if c.MetricsHistory.Interval < time.Minute {
+			reject("metrics_history.interval", c.MetricsHistory.Interval, "采集间隔不能小于1分钟")
+		}
+		if c.MetricsHistory.Retention < c.MetricsHistory.Interval {
+			reject("metrics_history.retention", c.MetricsHistory.Retention, "保留时长不能小于采集间隔")
+		}
+	}
+	if c.Recovery.Enable {
+		switch c.Recovery.Sessions {
+		case RecoverySessionsResume, RecoverySessionsClose:
+		default:
+			reject("recovery.sessions", c.Recovery.Sessions, "会话处理方式须为 resume 或 close")
+		}
+	}
+	if _, ok := c.Messages.Template(); !ok {
+		reject("messages.locale", c.Messages.Locale, "messages.templates 中没有该语言的消息模板")
+	}
+	for locale, t := range c.Messages.Templates {
+		if placeholder, ok := barcode.UnknownPlaceholder(t.Format); ok {
+			reject("messages.templates."+locale+".format", t.Format, "不支持的占位符 "+placeholder)
+		}
+	}
+	if c.Ingestion.Enable {
+		if c.Ingestion.GlobalRate <= 0 {
+			reject("ingestion.global_rate", c.Ingestion.GlobalRate, "全局速度须大于0")
+		}
+		if c.Ingestion.SourceRate <= 0 {
+			reject("ingestion.source_rate", c.Ingestion.SourceRate, "单个来源的速度须大于0")
+		}
+		if c.Ingestion.SourceBurst < 1 {
+			reject("ingestion.source_burst", c.Ingestion.SourceBurst, "单个来源的突发额度至少为1")
+		}
+		if c.Ingestion.Reserved < 0 {
+			reject("ingestion.reserved", c.Ingestion.Reserved, "预留额度不能为负数")
+		}
+		// 批量来源取满突发额度后仍须留下预留额度，否则网络扫码枪及外部推送永远无法通过
+		if c.Ingestion.GlobalBurst < c.Ingestion.SourceBurst+c.Ingestion.Reserved {
+			reject("ingestion.global_burst", c.Ingestion.GlobalBurst, "全局突发额度不能小于单个来源的突发额度与预留额度之和")
+		}
+	}
+	if c.Integrity.Enable {
+		if c.Integrity.CheckInterval < 0 {
+			reject("integrity.check_interval", c.Integrity.CheckInterval, "自检间隔不能为负数")
+		}
+		if c.Integrity.CheckInterval > 0 && c.Integrity.CheckWindow <= 0 {
+			reject("integrity.check_window", c.Integrity.CheckWindow, "自检范围须大于0")
+		}
+	}
+	if c.Coalesce.Enable {
+		if c.Coalesce.Window <= 0 || c.Coalesce.Window > MaxCoalesceWindow {
+			reject("coalesce.window", c.Coalesce.Window, fmt.Sprintf("合并窗口须大于0且不超过%s", MaxCoalesceWindow))
+		}
+		stations := make(map[string]bool)
+		for i, station := range c.Coalesce.Stations {
+			key := fmt.Sprintf("coalesce.stations[%d]", i)
+			if station.Station == "" {
+				reject(key+".station", station.Station, "工位名称不能为空")
+			} else if stations[station.Station] {
+				reject(key+".station", station.Station, "工位重复")
+			}
+			stations[station.Station] = true
+			if station.Window < 0 || station.Window > MaxCoalesceWindow {
+				reject(key+".window", station.Window, fmt.Sprintf("合并窗口须在0~%s之间", MaxCoalesceWindow))
+			}
+		}
+	}
+	switch c.OperatorStats.Privacy {
+	case OperatorPrivacyIdentified, OperatorPrivacyDisabled:
+	case OperatorPrivacyPseudonymized:
+		if c.OperatorStats.PseudonymSecret == "" {
+			reject("operator_stats.pseudonym_secret", c.OperatorStats.PseudonymSecret, "使用化名时须设置化名密钥")
+		}
+	default:
+		reject("operator_stats.privacy", c.OperatorStats.Privacy, "须为 identified、pseudonymized 或 disabled")
+	}
+	if c.OperatorStats.IdleGap < time.Minute {
+		reject("operator_stats.idle_gap", c.OperatorStats.IdleGap, "空闲阈值不能小于1分钟")
+	}
+	if c.Quality.Enable {
+		if c.Quality.Window < time.Hour {
+			reject("quality.window", c.Quality.Window, "统计窗口不能小于1小时")
+		}
+		if c.Quality.BatchSize < 1 || c.Quality.BatchSize > 10000 {
+			reject("quality.batch_size", c.Quality.BatchSize, "每批读取的记录数须在1~10000之间")
+		}
+		if _, err := regexp.Compile(c.Quality.Code128Pattern); err != nil {
+			reject("quality.code128_pattern", c.Quality.Code128Pattern, "正则表达式无效: "+err.Error())
+		}
+		if c.Quality.RegressionThreshold <= 0 || c.Quality.RegressionThreshold > 100 {
+			reject("quality.regression_threshold", c.Quality.RegressionThreshold, "退化阈值须大于0且不超过100个百分点")
+		}
+		if c.Quality.Retention != 0 && c.Quality.Retention < c.Quality.Window {
+			reject("quality.retention", c.Quality.Retention, "保留时长不能小于统计窗口")
+		}
+	}
+	if c.Serial.Enable {
+		if c.Serial.ReconnectMin <= 0 {
+			reject("serial.reconnect_min", c.Serial.ReconnectMin, "重试等待时间必须大于0")
+		}
+		if c.Serial.ReconnectMax < c.Serial.ReconnectMin {
+			reject("serial.reconnect_max", c.Serial.ReconnectMax, "重试等待时间上限不能小于首次重试的等待时间")
+		}
+		names := make(map[string]bool)
+		for i, port := range c.Serial.Ports {
+			key := fmt.Sprintf("serial.ports[%d]", i)
+			switch {
+			case port.Name == "":
+				reject(key+".name", port.Name, "串口名称不能为空")
+			case names[strings.ToUpper(port.Name)]:
+				reject(key+".name", port.Name, "串口重复配置")
+			}
+			names[strings.ToUpper(port.Name)] = true
+			if port.BaudRate < 0 {
+				reject(key+".baud_rate", port.BaudRate, "波特率必须大于0")
+			}
+			if port.DataBits != 0 && (port.DataBits < 5 || port.DataBits > 8) {
+				reject(key+".data_bits", port.DataBits, "数据位须为5~8")
+			}
+			switch port.Parity {
+			case "", "none", "odd", "even":
+			default:
+				reject(key+".parity", port.Parity, "校验须为 none、odd 或 even")
+			}
+			if port.StopBits != 0 && port.StopBits != 1 && port.StopBits != 2 {
+				reject(key+".stop_bits", port.StopBits, "停止位须为1或2")
+			}
+			switch port.Terminator {
+			case "", "enter", "cr", "lf", "tab":
+			default:
+				reject(key+".terminator", port.Terminator, "帧结束符须为 enter、cr、lf 或 tab")
+			}
+		}
+	}
+	if c.NetworkScanner.Enable {
+		if _, _, err := net.SplitHostPort(c.NetworkScanner.Listen); err != nil {
+			reject("network_scanner.listen", c.NetworkScanner.Listen, "监听地址须为 host:port 格式，如 :9100")
+		}
+		switch c.NetworkScanner.Terminator {
+		case "enter", "cr", "lf", "tab":
+		default:
+			reject("network_scanner.terminator", c.NetworkScanner.Terminator, "帧结束符须为 enter、cr、lf 或 tab")
+		}
+		if c.NetworkScanner.IdleTimeout < 0 {
+			reject("network_scanner.idle_timeout", c.NetworkScanner.IdleTimeout, "空闲超时不能为负数")
+		}
+		if c.NetworkScanner.MaxConns <= 0 {
+			reject("network_scanner.max_conns", c.NetworkScanner.MaxConns, "连接数上限必须大于0")
+		}
+	}
+	if c.Standby.Enable {
+		switch c.Standby.Role {
+		case StandbyRolePrimary:
+		case StandbyRoleStandby:
+			if u, err := url.Parse(c.Standby.PrimaryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				reject("standby.primary_url", c.Standby.PrimaryURL, "备机须配置 http:// 或 https:// 开头的主机地址")
+			}
+		default:
+			reject("standby.role", c.Standby.Role, "角色须为 primary 或 standby")
+		}
+		if c.Standby.CheckInterval <= 0 {
+			reject("standby.check_interval", c.Standby.CheckInterval, "检查间隔必须大于0")
+		}
+		if c.Standby.Timeout <= 0 {
+			reject("standby.timeout", c.Standby.Timeout, "健康检查超时必须大于0")
+		}
+		if c.Standby.TakeoverAfter < c.Standby.CheckInterval {
+			reject("standby.takeover_after", c.Standby.TakeoverAfter, "接管等待时长不能小于检查间隔")
+		}
+		if c.Standby.RecoverAfter < c.Standby.CheckInterval {
+			reject("standby.recover_after", c.Standby.RecoverAfter, "交还等待时长不能小于检查间隔")
+		}
+		if c.Standby.Lease && c.Standby.LeaseTTL <= c.Standby.CheckInterval {
+			reject("standby.lease_ttl", c.Standby.LeaseTTL, "租约有效期须大于检查间隔，否则每次续租前租约已失效")
+		}
+	}
+	if c.Closing.Email.Enable {
+		if c.Closing.Email.Addr == "" {
+			reject("closing.email.addr", c.Closing.Email.Addr, "启用日结邮件时须设置SMTP服务器地址")
+		}
+		if c.Closing.Email.From == "" || len(c.Closing.Email.To) == 0 {
+			reject("closing.email.to", c.Closing.Email.To, "启用日结邮件时须设置发件人及收件人")
+		}
+	}
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		reject("log.level", c.Log.Level, "日志级别无效")
+	}
+	return rejections
+}
+
+// Reloader 配置文件热加载
+//
+// 配置文件变化后重新解析并校验，校验通过后交给应用方生效；校验失败或生效失败时
+// 恢复到最近一次可用的配置并通知监听者，应用继续以原配置运行。每次生效的配置文件
+// 内容保存到快照文件中。
+type Reloader struct {
+	path     string
+	config   ReloadConfig
+	logger   *logrus.Logger
+	apply    func(*Config) error
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+	wg       sync.WaitGroup
+	reloadMu sync.Mutex // 保证同一时间只有一次热加载
+
+	mu           sync.Mutex
+	version      int
+	seen         string // 最近处理过的配置文件摘要
+	active       Version
+	lastGood     Version
+	good         *Config
+	pending      *Version
+	lastRollback *RollbackEvent
+	listeners    []func(RollbackEvent)
+}
+
+// NewReloader 创建配置热加载，current 为启动时加载的配置
+func NewReloader(path string, current *Config, logger *logrus.Logger) *Reloader {
+	r := &Reloader{
+		path:   path,
+		config: current.Reload,
+		logger: logger,
+		done:   make(chan struct{}),
+		good:   current,
+	}
+	if r.config.Debounce <= 0 {
+		r.config.Debounce = 500 * time.Millisecond
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.WithError(err).Warn("读取配置文件失败")
+	}
+	r.version = 1
+	r.active = Version{Version: r.version, Checksum: checksum(data), LoadedAt: time.Now()}
+	r.lastGood = r.active
+	r.seen = r.active.Checksum
+	if err == nil {
+		r.saveSnapshot(data)
+	}
+	return r
+}
+
+// SetApply 设置配置生效的方法，返回错误时回滚到最近可用配置
+func (r *Reloader) SetApply(apply func(next *Config) error) {
+	r.apply = apply
+}
+
+// OnRollback 注册配置回滚回调
+func (r *Reloader) OnRollback(listener func(event RollbackEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Start 监听配置文件变化
+func (r *Reloader) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建配置文件监听失败: %w", err)
+	}
+	// 监听所在目录，编辑器以重命名方式保存时文件本身会被替换
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("监听配置目录失败: %w", err)
+	}
+	r.watcher = watcher
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		target := filepath.Clean(r.path)
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if timer == nil {
+					timer = time.NewTimer(r.config.Debounce)
+				} else {
+					timer.Reset(r.config.Debounce)
+				}
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				r.Reload()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.logger.WithError(err).Warn("配置文件监听出错")
+			case <-r.done:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+		}
+	}()
+
+	r.logger.WithField("path", r.path).Info("已开始监听配置文件变化")
+	return nil
+}
+
+// Close 停止监听配置文件
+func (r *Reloader) Close() {
+	if r.watcher == nil {
+		return
+	}
+	close(r.done)
+	r.watcher.Close()
+	r.wg.Wait()
+}
+
+// Reload 重新加载配置文件，内容未变化时忽略；失败时回滚并返回回滚事件
+func (r *Reloader) Reload() *RollbackEvent {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		// 编辑器保存过程中文件可能短暂不存在，等待下一次变化
+		r.logger.WithError(err).Warn("读取配置文件失败")
+		return nil
+	}
+
+	r.mu.Lock()
+	sum := checksum(data)
+	if sum == r.seen {
+		r.mu.Unlock()
+		return nil
+	}
+	r.seen = sum
+	r.version++
+	candidate := Version{Version: r.version, Checksum: sum, LoadedAt: time.Now()}
+	r.pending = &candidate
+	good := r.good
+	r.mu.Unlock()
+
+	next, rejections := Parse(data)
+	if len(rejections) == 0 && r.apply != nil {
+		if err := r.apply(next); err != nil {
+			rejections = []Rejection{{Reason: fmt.Sprintf("应用配置失败: %v", err)}}
+			if err := r.apply(good); err != nil {
+				// 无法恢复时当前生效的是部分应用的新配置
+				r.logger.WithError(err).Error("恢复最近可用配置失败")
+				r.mu.Lock()
+				r.active = candidate
+				r.mu.Unlock()
+			}
+		}
+	}
+
+	if len(rejections) > 0 {
+		return r.rollback(candidate, rejections)
+	}
+
+	r.mu.Lock()
+	r.active = candidate
+	r.lastGood = candidate
+	r.good = next
+	r.pending = nil
+	r.mu.Unlock()
+
+	r.saveSnapshot(data)
+	// 同步全局配置，配置差异等功能读取全局配置
+	if err := viper.ReadInConfig(); err != nil {
+		r.logger.WithError(err).Warn("同步全局配置失败")
+	}
+	r.logger.WithField("version", candidate.Version).WithField("checksum", candidate.Checksum).Info("配置文件已热加载")
+	return nil
+}
+
+// rollback 放弃新配置，保持最近可用配置并通知监听者
+func (r *Reloader) rollback(candidate Version, rejections []Rejection) *RollbackEvent {
+	r.mu.Lock()
+	event := RollbackEvent{
+		Rejected:   candidate,
+		RestoredTo: r.lastGood,
+		Rejections: rejections,
+		At:         time.Now(),
+	}
+	r.pending = nil
+	r.lastRollback = &event
+	listeners := make([]func(RollbackEvent), len(r.listeners))
+	copy(listeners, r.listeners)
+	r.mu.Unlock()
+
+	for _, rejection := range rejections {
+		r.logger.WithField("key", rejection.Key).WithField("value", rejection.Value).
+			WithField("version", candidate.Version).Error("配置被拒绝: " + rejection.Reason)
+	}
+	r.logger.WithField("restored_to", event.RestoredTo.Version).Error("配置热加载失败，已回滚到最近可用配置")
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return &event
+}
+
+// State 当前配置版本状态
+func (r *Reloader) State() ReloadState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := ReloadState{
+		Path:         r.path,
+		Snapshot:     r.config.Snapshot,
+		Active:       r.active,
+		LastGood:     r.lastGood,
+		LastRollback: r.lastRollback,
+	}
+	if r.pending != nil {
+		pending := *r.pending
+		state.Pending = &pending
+	}
+	return state
+}
+
+// saveSnapshot 保存最近一次可用的配置文件内容
+func (r *Reloader) saveSnapshot(data []byte) {
+	if r.config.Snapshot == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.config.Snapshot), 0755); err != nil {
+		r.logger.WithError(err).Warn("创建配置快照目录失败")
+		return
+	}
+	tmp := r.config.Snapshot + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		r.logger.WithError(err).Warn("保存配置快照失败")
+		return
+	}
+	if err := os.Rename(tmp, r.config.Snapshot); err != nil {
+		r.logger.WithError(err).Warn("保存配置快照失败")
+	}
+}
+
+// validGroupSeparator 组分隔符按键名称是否有效（ctrl+]、]、f1-f12）
+func validGroupSeparator(name string) bool {
+	switch name = strings.ToLower(name); name {
+	case "ctrl+]", "]":
+		return true
+	}
+	for n := 1; n <= 12; n++ {
+		if name == fmt.Sprintf("f%d", n) {
+			return true
+		}
+	}
+	return false
+}
+
+// checksum 配置文件内容摘要
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
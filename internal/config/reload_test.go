@@ -0,0 +1,187 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// goodConfig 校验通过的配置文件，port 用于区分不同版本
+func goodConfig(port string) string {
+	return "server:\n  port: " + port + "\nreload:\n  snapshot: SNAPSHOT\n"
+}
+
+// newTestReloader 写入初始配置文件并创建热加载，apply 记录每次生效的端口
+func newTestReloader(t *testing.T) (r *Reloader, path, snapshot string, applied *[]int, events *[]RollbackEvent) {
+	t.Helper()
+	dir := t.TempDir()
+	path = filepath.Join(dir, "config.yaml")
+	snapshot = filepath.Join(dir, "snapshots", "last-good.yaml")
+	writeConfig(t, path, snapshot, goodConfig("8080"))
+	data, _ := os.ReadFile(path)
+	current, rejections := Parse(data)
+	if len(rejections) > 0 {
+		t.Fatalf("initial config rejected: %+v", rejections)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	r = NewReloader(path, current, logger)
+	applied = new([]int)
+	events = new([]RollbackEvent)
+	r.OnRollback(func(event RollbackEvent) { *events = append(*events, event) })
+	return r, path, snapshot, applied, events
+}
+
+// writeConfig 覆盖配置文件，SNAPSHOT 替换为快照路径
+func writeConfig(t *testing.T, path, snapshot, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(data, "SNAPSHOT", filepath.ToSlash(snapshot))), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readSnapshot(t *testing.T, snapshot string) string {
+	t.Helper()
+	data, err := os.ReadFile(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestReloadRollback 配置文件格式错误、解析失败或校验不通过时不生效，回滚到最近可用版本并通知，快照保持不变
+func TestReloadRollback(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		key  string // 被拒绝的配置项，为空表示整个文件
+	}{
+		{name: "yaml syntax", data: "server:\n  port: [8080\n"},
+		{name: "decode", data: "server:\n  read_timeout: soon\n", key: "server.read_timeout"},
+		{name: "validation", data: "server:\n  port: 70000\n", key: "server.port"},
+		{name: "cross-field", data: "scanner:\n  min_length: 10\n  max_length: 5\n", key: "scanner.max_length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, path, snapshot, applied, events := newTestReloader(t)
+			r.SetApply(func(next *Config) error {
+				*applied = append(*applied, next.Server.Port)
+				return nil
+			})
+			initial := r.State()
+			good := readSnapshot(t, snapshot)
+
+			writeConfig(t, path, snapshot, tt.data)
+			event := r.Reload()
+			if event == nil {
+				t.Fatal("invalid config was applied")
+			}
+			if len(*applied) != 0 {
+				t.Fatalf("apply called with rejected config: %v", *applied)
+			}
+			if event.RestoredTo != initial.LastGood || event.Rejected.Version != 2 {
+				t.Fatalf("event = %+v, want rejected v2 restored to %+v", event, initial.LastGood)
+			}
+			found := false
+			for _, rejection := range event.Rejections {
+				found = found || rejection.Key == tt.key && rejection.Reason != ""
+			}
+			if !found {
+				t.Fatalf("rejections = %+v, want key %q", event.Rejections, tt.key)
+			}
+			if len(*events) != 1 || (*events)[0].Rejected != event.Rejected {
+				t.Fatalf("listener events = %+v", *events)
+			}
+
+			state := r.State()
+			if state.Active != initial.Active || state.LastGood != initial.LastGood || state.Pending != nil {
+				t.Fatalf("state after rollback = %+v, want active %+v", state, initial.Active)
+			}
+			if state.LastRollback == nil || state.LastRollback.Rejected != event.Rejected {
+				t.Fatalf("last rollback = %+v", state.LastRollback)
+			}
+			if got := readSnapshot(t, snapshot); got != good {
+				t.Fatalf("snapshot overwritten by rejected config:\n%s", got)
+			}
+
+			// 内容未变化不重复处理
+			if again := r.Reload(); again != nil || len(*events) != 1 {
+				t.Fatalf("unchanged file reloaded again: %+v", again)
+			}
+		})
+	}
+}
+
+// TestReloadApplyFailure 生效失败时恢复最近可用配置；恢复也失败时记录部分生效的版本，最近可用版本不变
+func TestReloadApplyFailure(t *testing.T) {
+	r, path, snapshot, applied, events := newTestReloader(t)
+	failOn := map[int]bool{9090: true}
+	r.SetApply(func(next *Config) error {
+		*applied = append(*applied, next.Server.Port)
+		if failOn[next.Server.Port] {
+			return errors.New("port in use")
+		}
+		return nil
+	})
+
+	// 生效失败，以原配置重新生效
+	writeConfig(t, path, snapshot, goodConfig("9090"))
+	event := r.Reload()
+	if event == nil || len(event.Rejections) != 1 || !strings.Contains(event.Rejections[0].Reason, "port in use") {
+		t.Fatalf("event = %+v", event)
+	}
+	if got := *applied; len(got) != 2 || got[0] != 9090 || got[1] != 8080 {
+		t.Fatalf("applied = %v, want [9090 8080]", got)
+	}
+	if state := r.State(); state.Active.Version != 1 || state.LastGood.Version != 1 {
+		t.Fatalf("state = %+v, want active and last good v1", state)
+	}
+	if !strings.Contains(readSnapshot(t, snapshot), "port: 8080") {
+		t.Fatal("snapshot overwritten by failed config")
+	}
+
+	// 成功生效后成为新的最近可用配置
+	writeConfig(t, path, snapshot, goodConfig("8081"))
+	if event := r.Reload(); event != nil {
+		t.Fatalf("valid config rolled back: %+v", event)
+	}
+	state := r.State()
+	if state.Active.Version != 3 || state.LastGood != state.Active {
+		t.Fatalf("state = %+v, want active and last good v3", state)
+	}
+	if !strings.Contains(readSnapshot(t, snapshot), "port: 8081") {
+		t.Fatal("snapshot not updated")
+	}
+
+	// 之后的失败恢复到 8081，而不是启动时的配置
+	*applied = nil
+	writeConfig(t, path, snapshot, goodConfig("9090"))
+	if event := r.Reload(); event == nil || event.RestoredTo.Version != 3 {
+		t.Fatalf("event = %+v, want restored to v3", event)
+	}
+	if got := *applied; len(got) != 2 || got[1] != 8081 {
+		t.Fatalf("applied = %v, want restore to 8081", got)
+	}
+
+	// 恢复也失败：当前生效的是部分应用的新配置
+	failOn[8081] = true
+	*applied = nil
+	writeConfig(t, path, snapshot, goodConfig("9090")+"# v5\n")
+	event = r.Reload()
+	if event == nil || event.Rejected.Version != 5 || event.RestoredTo.Version != 3 {
+		t.Fatalf("event = %+v", event)
+	}
+	state = r.State()
+	if state.Active.Version != 5 || state.LastGood.Version != 3 {
+		t.Fatalf("state = %+v, want active v5 and last good v3", state)
+	}
+	if len(*events) != 3 {
+		t.Fatalf("rollback events = %d, want 3", len(*events))
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretKeys 配置文件中的密钥类配置项，列表元素以 [] 表示
+//
+// 由 Config 的字段生成：名称经 IsSensitiveKey 判定为敏感值的字符串配置项，
+// 与配置差异及日志中隐藏的配置项使用同一规则，新增密钥类配置项无需另行登记。
+var secretKeys = sensitiveConfigKeys(reflect.TypeOf(Config{}), "", make(map[string]bool))
+
+// sensitiveConfigKeys 收集结构体中的密钥类配置项路径
+func sensitiveConfigKeys(t reflect.Type, prefix string, keys map[string]bool) map[string]bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct {
+			ft, path = ft.Elem(), path+"[]"
+		}
+		switch ft.Kind() {
+		case reflect.Struct:
+			sensitiveConfigKeys(ft, path, keys)
+		case reflect.String:
+			if IsSensitiveKey(name) {
+				keys[path] = true
+			}
+		}
+	}
+	return keys
+}
+
+// listIndexPattern 配置项路径中的列表下标
+var listIndexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// secretNode 配置文件中的一个密钥
+type secretNode struct {
+	key  string // 带下标的路径，如 peers.targets[0].token
+	node *yaml.Node
+}
+
+// RewriteSecrets 逐个改写配置文件中的密钥，保留注释及格式
+//
+// rewrite 收到带下标的路径（如 peers.targets[0].token）及原值，返回 false 表示不修改；
+// 返回修改后的内容及被修改的配置项。密钥须为单行标量。
+func RewriteSecrets(data []byte, rewrite func(key, value string) (string, bool)) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if doc.Kind == 0 {
+		return data, nil, nil
+	}
+
+	var secrets []secretNode
+	collectSecrets(doc.Content[0], "", &secrets)
+
+	// 同一行有多个密钥时（如流式列表）从右向左替换，前面的列号不受影响
+	sort.Slice(secrets, func(i, j int) bool {
+		if secrets[i].node.Line != secrets[j].node.Line {
+			return secrets[i].node.Line < secrets[j].node.Line
+		}
+		return secrets[i].node.Column > secrets[j].node.Column
+	})
+
+	lines := strings.Split(string(data), "\n")
+	var changed []string
+	for _, secret := range secrets {
+		value, ok := rewrite(secret.key, secret.node.Value)
+		if !ok {
+			continue
+		}
+		node := secret.node
+		if node.Line < 1 || node.Line > len(lines) {
+			return nil, nil, fmt.Errorf("配置项 %s 位置无效", secret.key)
+		}
+		line := []rune(lines[node.Line-1])
+		start := node.Column - 1
+		if start < 0 || start > len(line) {
+			return nil, nil, fmt.Errorf("配置项 %s 位置无效", secret.key)
+		}
+		end := -1
+		switch node.Style {
+		case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+			end = scalarEnd(line, start)
+		case 0:
+			// 普通标量的原文即取值，流式映射中不能按行尾截取
+			if end = start + len([]rune(node.Value)); end > len(line) {
+				end = -1
+			}
+		}
+		if end < 0 {
+			return nil, nil, fmt.Errorf("配置项 %s 须为单行标量", secret.key)
+		}
+		lines[node.Line-1] = string(line[:start]) + strconv.Quote(value) + string(line[end:])
+		changed = append(changed, secret.key)
+	}
+	sort.Strings(changed)
+	return []byte(strings.Join(lines, "\n")), changed, nil
+}
+
+// collectSecrets 收集节点下的密钥
+func collectSecrets(node *yaml.Node, path string, secrets *[]secretNode) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			collectSecrets(node.Content[i+1], key, secrets)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			collectSecrets(item, fmt.Sprintf("%s[%d]", path, i), secrets)
+		}
+	case yaml.ScalarNode:
+		if secretKeys[listIndexPattern.ReplaceAllString(path, "[]")] {
+			*secrets = append(*secrets, secretNode{key: path, node: node})
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteSecretsStripsSensitiveKeys(t *testing.T) {
+	data := []byte(`security:
+  jwt_secret: "s3cr3t-jwt"
+operator_stats:
+  pseudonym_secret: "hmac-key" # 化名密钥
+peers:
+  targets:
+    - url: http://peer
+      token: peer-token
+admin:
+  reset_token_ttl: 2m
+`)
+	stripped, changed, err := RewriteSecrets(data, func(key, value string) (string, bool) {
+		return "", value != ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"operator_stats.pseudonym_secret", "peers.targets[0].token", "security.jwt_secret"}
+	if strings.Join(changed, ",") != strings.Join(want, ",") {
+		t.Fatalf("changed = %v, want %v", changed, want)
+	}
+	for _, secret := range []string{"s3cr3t-jwt", "hmac-key", "peer-token"} {
+		if strings.Contains(string(stripped), secret) {
+			t.Errorf("secret %q not stripped:\n%s", secret, stripped)
+		}
+	}
+	if !strings.Contains(string(stripped), "reset_token_ttl: 2m") {
+		t.Errorf("non-secret reset_token_ttl was rewritten:\n%s", stripped)
+	}
+	if !strings.Contains(string(stripped), "# 化名密钥") {
+		t.Errorf("comment lost:\n%s", stripped)
+	}
+}
+
+func TestSecretKeysFollowIsSensitiveKey(t *testing.T) {
+	for key := range secretKeys {
+		leaf := key[strings.LastIndex(key, ".")+1:]
+		if !IsSensitiveKey(leaf) {
+			t.Errorf("%s is listed as a secret but IsSensitiveKey(%q) is false", key, leaf)
+		}
+	}
+	for _, key := range []string{"security.jwt_secret", "security.api_key", "central.token", "agent.token",
+		"closing.email.password", "peers.targets[].token", "operator_stats.pseudonym_secret"} {
+		if !secretKeys[key] {
+			t.Errorf("%s missing from secretKeys", key)
+		}
+	}
+	if secretKeys["admin.reset_token_ttl"] {
+		t.Error("duration admin.reset_token_ttl must not be treated as a secret")
+	}
+}
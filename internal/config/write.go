@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WriteValues 按点分隔的键修改配置文件中的取值并写回，保留注释及其余配置项
+//
+// 修改后的内容须通过校验才会写入；先写临时文件再替换，写入中途失败不会损坏原文件。
+func WriteValues(path string, values map[string]interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("配置文件格式错误: 顶层不是映射")
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// 只修改已有的标量时直接替换原文中的取值，注释的对齐及空行都保持不变
+	output, ok := spliceScalars(data, root, keys, values)
+	if !ok {
+		for _, key := range keys {
+			if err := setNode(root, strings.Split(key, "."), values[key]); err != nil {
+				return fmt.Errorf("修改配置项 %s 失败: %w", key, err)
+			}
+		}
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&doc); err != nil {
+			return fmt.Errorf("生成配置文件失败: %w", err)
+		}
+		encoder.Close()
+		output = buf.Bytes()
+	}
+
+	if _, rejections := Parse(output); len(rejections) > 0 {
+		reasons := make([]string, 0, len(rejections))
+		for _, rejection := range rejections {
+			reasons = append(reasons, strings.TrimSpace(rejection.Key+" "+rejection.Reason))
+		}
+		return fmt.Errorf("修改后的配置校验失败: %s", strings.Join(reasons, "; "))
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(output); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("写入配置文件失败: %w", err)
+	}
+	return nil
+}
+
+// setNode 设置映射节点中路径对应的值，中间的映射不存在时创建，原有的注释保留
+func setNode(mapping *yaml.Node, path []string, value interface{}) error {
+	var current *yaml.Node
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == path[0] {
+			current = mapping.Content[i+1]
+			break
+		}
+	}
+
+	if len(path) > 1 {
+		if current == nil {
+			current = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}, current)
+		}
+		if current.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s 不是映射", path[0])
+		}
+		return setNode(current, path[1:], value)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return err
+	}
+	if current == nil {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}, &node)
+		return nil
+	}
+	if current.Kind == yaml.ScalarNode && node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		node.Style = current.Style
+	}
+	node.HeadComment = current.HeadComment
+	node.LineComment = current.LineComment
+	node.FootComment = current.FootComment
+	*current = node
+	return nil
+}
+
+// spliceScalars 在原文中逐行替换已有标量的取值；配置项不存在或不是标量时返回 false
+func spliceScalars(data []byte, root *yaml.Node, keys []string, values map[string]interface{}) ([]byte, bool) {
+	lines := strings.Split(string(data), "\n")
+	edited := make(map[int]bool, len(keys))
+	for _, key := range keys {
+		current := lookupNode(root, strings.Split(key, "."))
+		if current == nil || current.Kind != yaml.ScalarNode || current.Line < 1 || current.Line > len(lines) || edited[current.Line] {
+			return nil, false
+		}
+		var node yaml.Node
+		if err := node.Encode(values[key]); err != nil || node.Kind != yaml.ScalarNode {
+			return nil, false
+		}
+		if node.Tag == "!!str" {
+			node.Style = current.Style
+		}
+		rendered, err := yaml.Marshal(&node)
+		if err != nil {
+			return nil, false
+		}
+		text := strings.TrimSuffix(string(rendered), "\n")
+		if strings.Contains(text, "\n") {
+			return nil, false
+		}
+
+		line := []rune(lines[current.Line-1])
+		start := current.Column - 1
+		if start < 0 || start > len(line) {
+			return nil, false
+		}
+		end := scalarEnd(line, start)
+		if end < 0 {
+			return nil, false
+		}
+		lines[current.Line-1] = string(line[:start]) + text + string(line[end:])
+		edited[current.Line] = true
+	}
+	return []byte(strings.Join(lines, "\n")), true
+}
+
+// lookupNode 按路径查找映射中的节点，不存在时返回 nil
+func lookupNode(mapping *yaml.Node, path []string) *yaml.Node {
+	for len(path) > 0 {
+		if mapping.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value == path[0] {
+				next = mapping.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		mapping, path = next, path[1:]
+	}
+	return mapping
+}
+
+// scalarEnd 返回从 start 开始的单行标量在行内的结束位置，无法确定时返回 -1
+func scalarEnd(line []rune, start int) int {
+	if start == len(line) {
+		return start
+	}
+	switch line[start] {
+	case '"':
+		for i := start + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return -1
+	case '\'':
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+		return -1
+	}
+	end := len(line)
+	for i := start; i+1 < len(line); i++ {
+		if (line[i] == ' ' || line[i] == '\t') && line[i+1] == '#' {
+			end = i
+			break
+		}
+	}
+	for end > start && (line[end-1] == ' ' || line[end-1] == '\t') {
+		end--
+	}
+	return end
+}
@@ -0,0 +1,181 @@
+// Package contract 对外载荷的结构定义（JSON Schema）及校验
+//
+// 出站事件（投递到文件及出站对接的载荷）与外部推送请求体是与对接方约定的契约，
+// 结构定义随事件消息结构版本发布，由 /api/schemas/ 提供，contract-test 子命令据此校验对接方的样例载荷。
+package contract
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"userclient/internal/websocket"
+)
+
+// 结构定义名称
+const (
+	SchemaEvent  = "event"  // 出站事件
+	SchemaIngest = "ingest" // 外部推送请求体
+)
+
+// Version 结构定义版本，与事件消息结构版本一致
+const Version = websocket.EventSchemaVersion
+
+// BasePath 结构定义接口地址
+const BasePath = "/api/schemas/"
+
+// ErrUnknownSchema 结构定义不存在
+var ErrUnknownSchema = errors.New("结构定义不存在")
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+var (
+	loadOnce sync.Once
+	schemas  map[string]map[string]interface{}
+	loadErr  error
+)
+
+// ViolationError 载荷不符合结构定义
+type ViolationError struct {
+	Schema     string
+	Violations []Violation
+}
+
+// Error 实现 error，只列出前几处不符
+func (e *ViolationError) Error() string {
+	const limit = 3
+	parts := make([]string, 0, limit)
+	for i, v := range e.Violations {
+		if i == limit {
+			parts = append(parts, fmt.Sprintf("等 %d 处", len(e.Violations)))
+			break
+		}
+		parts = append(parts, v.String())
+	}
+	return fmt.Sprintf("不符合结构定义 %s: %s", e.Schema, strings.Join(parts, "; "))
+}
+
+// load 解析内嵌的结构定义，数值保留为 json.Number
+func load() (map[string]map[string]interface{}, error) {
+	loadOnce.Do(func() {
+		entries, err := schemaFiles.ReadDir("schemas")
+		if err != nil {
+			loadErr = err
+			return
+		}
+		schemas = make(map[string]map[string]interface{}, len(entries))
+		for _, entry := range entries {
+			data, err := schemaFiles.ReadFile("schemas/" + entry.Name())
+			if err != nil {
+				loadErr = err
+				return
+			}
+			var schema map[string]interface{}
+			if err := decode(data, &schema); err != nil {
+				loadErr = fmt.Errorf("解析结构定义 %s 失败: %w", entry.Name(), err)
+				return
+			}
+			schemas[strings.TrimSuffix(entry.Name(), ".json")] = schema
+		}
+	})
+	return schemas, loadErr
+}
+
+// Names 全部结构定义的名称
+func Names() []string {
+	all, _ := load()
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Document 对外发布的结构定义文档，补充 $id 及版本
+func Document(name string) (map[string]interface{}, error) {
+	all, err := load()
+	if err != nil {
+		return nil, err
+	}
+	schema, ok := all[name]
+	if !ok {
+		return nil, ErrUnknownSchema
+	}
+	doc := make(map[string]interface{}, len(schema)+2)
+	for key, value := range schema {
+		doc[key] = value
+	}
+	doc["$id"] = BasePath + name
+	doc["version"] = Version
+	return doc, nil
+}
+
+// Validate 按结构定义校验JSON文本，不符时返回 *ViolationError
+func Validate(name string, data []byte) error {
+	var value interface{}
+	if err := decode(data, &value); err != nil {
+		return &ViolationError{Schema: name, Violations: []Violation{{Message: "JSON格式错误: " + err.Error()}}}
+	}
+	return ValidateValue(name, value)
+}
+
+// ValidateValue 按结构定义校验已解析的值（数值须为 json.Number），不符时返回 *ViolationError
+func ValidateValue(name string, value interface{}) error {
+	return ValidateDefinition(name, "", value, "")
+}
+
+// ValidateDefinition 按结构定义中 definitions 下的子定义校验值，definition 为空表示整个定义；
+// pointer 为该值在所属文档中的位置，作为不符之处的前缀
+func ValidateDefinition(name, definition string, value interface{}, pointer string) error {
+	all, err := load()
+	if err != nil {
+		return err
+	}
+	root, ok := all[name]
+	if !ok {
+		return ErrUnknownSchema
+	}
+	v := &validator{root: root}
+	var schema interface{} = root
+	if definition != "" {
+		if schema, err = v.resolve("#/definitions/" + definition); err != nil {
+			return err
+		}
+	}
+	if violations := v.validate(schema, value, pointer); len(violations) > 0 {
+		return &ViolationError{Schema: name, Violations: violations}
+	}
+	return nil
+}
+
+// decode 解析JSON，数值保留为 json.Number 以区分整数
+func decode(data []byte, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("JSON之后有多余内容")
+	}
+	return nil
+}
+
+// Pointer 把点分隔的字段路径（如 ingest.mapping 中的 data.items.0）转换为 JSON Pointer
+func Pointer(path string) string {
+	if path == "" {
+		return ""
+	}
+	keys := strings.Split(path, ".")
+	for i, key := range keys {
+		keys[i] = escapePointer(key)
+	}
+	return "/" + strings.Join(keys, "/")
+}
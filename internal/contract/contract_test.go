@@ -0,0 +1,135 @@
+package contract_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"userclient/internal/contract"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// TestSamplesMatchSchemas testdata/samples 下按结构定义分目录存放对接方格式的样例，与 contract-test 子命令的目录布局相同
+func TestSamplesMatchSchemas(t *testing.T) {
+	for _, name := range contract.Names() {
+		paths, err := filepath.Glob(filepath.Join("testdata", "samples", name, "*.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) == 0 {
+			t.Errorf("no samples for schema %s", name)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := contract.Validate(name, data); err != nil {
+				t.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+}
+
+// TestOutboxPayloadMatchesEventSchema 出站事件按实际的序列化方式生成，字段变更未同步到结构定义时失败
+func TestOutboxPayloadMatchesEventSchema(t *testing.T) {
+	deviceID := uint(2)
+	at := time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC)
+	records := []*models.BarcodeRecord{
+		{
+			ID: 1042, Content: "6901234567892", Length: 13, Type: "EAN13", Status: "success",
+			DeviceID: &deviceID, Device: &models.Device{ID: deviceID, Name: "1号线扫码枪"},
+			DurationMS: 48, MinKeyIntervalMS: 2, MaxKeyIntervalMS: 6, AvgKeyIntervalMS: 3.7,
+			Derived: models.StringMap{"gtin": "06901234567892"}, SecondaryDevices: models.UintList{3},
+			Source: "local", Input: "hook", CreatedAt: at, UpdatedAt: at,
+			ScanID: "3f9a1c2e7b5d4e6f8a0b1c2d3e4f5a6b", PublicID: "01JA8Z6Q3K5V7N2M4X9C1D0E8F",
+			ChainStation: "A1", ChainHash: strings.Repeat("a", 64),
+		},
+		{ID: 1, Content: "X", Length: 1, Source: "ingest", CreatedAt: at, PublicID: "01JA8Z6Q3K5V7N2M4X9C1D0E8G"},
+	}
+	for _, record := range records {
+		payload, err := json.Marshal(service.OutboxEnvelope{EventID: "barcode:" + record.PublicID, Type: "barcode", Timestamp: at, Data: record})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := contract.Validate(contract.SchemaEvent, payload); err != nil {
+			t.Errorf("record %d: %v\n%s", record.ID, err, payload)
+		}
+	}
+
+	// 记录的每个JSON字段都须在结构定义中说明
+	doc, err := contract.Document(contract.SchemaEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties := doc["definitions"].(map[string]interface{})["barcode_record"].(map[string]interface{})["properties"].(map[string]interface{})
+	recordType := reflect.TypeOf(models.BarcodeRecord{})
+	for i := 0; i < recordType.NumField(); i++ {
+		tag := strings.Split(recordType.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		if _, ok := properties[tag]; !ok {
+			t.Errorf("barcode record field %q is missing from the event schema", tag)
+		}
+	}
+}
+
+func TestSchemaViolationPointers(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		payload  string
+		pointers []string
+	}{
+		{"event without event_id", contract.SchemaEvent, `{"type":"barcode","timestamp":"2026-10-16T08:30:00Z","data":{}}`, []string{"/event_id", "/data/content"}},
+		{"event bad timestamp", contract.SchemaEvent, `{"event_id":"e","type":"other","timestamp":"yesterday","data":null}`, []string{"/timestamp"}},
+		{"barcode record wrong types", contract.SchemaEvent,
+			`{"event_id":"e","type":"barcode","timestamp":"2026-10-16T08:30:00Z","data":{"id":0,"content":"A","length":1,"type":"","status":"success","source":"cloud","created_at":"2026-10-16T08:30:00Z","public_id":"p"}}`,
+			[]string{"/data/id", "/data/source"}},
+		{"ingest missing content", contract.SchemaIngest, `{"event_id":"1","type":"barcode","data":{}}`, []string{"/data/content"}},
+		{"ingest wrong type", contract.SchemaIngest, `{"event_id":"1","type":"heartbeat","data":{"content":"A"}}`, []string{"/type"}},
+		{"ingest batch item", contract.SchemaIngest, `[{"event_id":"1","type":"barcode","data":{"content":"A"}},{"event_id":"2","type":"barcode","data":{"content":"","scan_id":"` + strings.Repeat("x", 33) + `"}}]`,
+			[]string{"/1/data/content", "/1/data/scan_id"}},
+		{"ingest empty batch", contract.SchemaIngest, `[]`, []string{""}},
+		{"ingest not json", contract.SchemaIngest, `{"event_id":`, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var violationErr *contract.ViolationError
+			if err := contract.Validate(tt.schema, []byte(tt.payload)); !errors.As(err, &violationErr) {
+				t.Fatalf("Validate = %v, want a violation", err)
+			}
+			got := make(map[string]bool)
+			for _, v := range violationErr.Violations {
+				got[v.Pointer] = true
+			}
+			for _, pointer := range tt.pointers {
+				if !got[pointer] {
+					t.Errorf("no violation at %q: %v", pointer, violationErr.Violations)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentsAreVersioned(t *testing.T) {
+	for _, name := range contract.Names() {
+		doc, err := contract.Document(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc["$id"] != contract.BasePath+name || doc["version"] != contract.Version {
+			t.Errorf("%s: $id %v, version %v", name, doc["$id"], doc["version"])
+		}
+	}
+	if _, err := contract.Document("webhook"); !errors.Is(err, contract.ErrUnknownSchema) {
+		t.Errorf("unknown schema: err = %v", err)
+	}
+}
@@ -0,0 +1,347 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation 载荷不符合结构定义之处，Pointer 为出错字段的 JSON Pointer（RFC 6901），根为空串
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+
+	mismatch bool // 该位置的类型不符，anyOf/oneOf 据此优先报告类型相符的分支
+}
+
+// String 以 "指针: 说明" 的形式输出
+func (v Violation) String() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return pointer + ": " + v.Message
+}
+
+// validator 按结构定义校验已解析的JSON值
+//
+// 只实现本仓库的结构定义用到的关键字：type、enum、const、required、properties、
+// additionalProperties、items、minItems、maxItems、minLength、maxLength、minimum、maximum、
+// format（date-time）、$ref（仅限 #/definitions/ 下的本地引用）、allOf、anyOf、oneOf 及 if/then/else。
+type validator struct {
+	root map[string]interface{}
+}
+
+// validate 校验 value，返回全部不符之处
+func (v *validator) validate(schema interface{}, value interface{}, pointer string) []Violation {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		// true 或空定义接受任意值
+		if b, isBool := schema.(bool); isBool && !b {
+			return []Violation{{Pointer: pointer, Message: "不允许出现该字段"}}
+		}
+		return nil
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			return []Violation{{Pointer: pointer, Message: err.Error()}}
+		}
+		return v.validate(target, value, pointer)
+	}
+
+	if types, ok := s["type"]; ok && !matchesType(types, value) {
+		return []Violation{{Pointer: pointer, Message: fmt.Sprintf("类型应为 %s，实际为 %s", typeNames(types), typeOf(value)), mismatch: true}}
+	}
+
+	var violations []Violation
+	if want, ok := s["const"]; ok && !equal(want, value) {
+		violations = append(violations, Violation{Pointer: pointer, Message: fmt.Sprintf("应为 %s", literal(want))})
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, want := range enum {
+			if equal(want, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, 0, len(enum))
+			for _, want := range enum {
+				names = append(names, literal(want))
+			}
+			violations = append(violations, Violation{Pointer: pointer, Message: "应为 " + strings.Join(names, "、") + " 之一"})
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		violations = append(violations, v.validateObject(s, val, pointer)...)
+	case []interface{}:
+		violations = append(violations, v.validateArray(s, val, pointer)...)
+	case string:
+		violations = append(violations, validateString(s, val, pointer)...)
+	case json.Number:
+		violations = append(violations, validateNumber(s, val, pointer)...)
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			violations = append(violations, v.validate(sub, value, pointer)...)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		violations = append(violations, v.validateBranches(anyOf, value, pointer, false)...)
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		violations = append(violations, v.validateBranches(oneOf, value, pointer, true)...)
+	}
+	if cond, ok := s["if"]; ok {
+		if len(v.validate(cond, value, pointer)) == 0 {
+			if then, ok := s["then"]; ok {
+				violations = append(violations, v.validate(then, value, pointer)...)
+			}
+		} else if otherwise, ok := s["else"]; ok {
+			violations = append(violations, v.validate(otherwise, value, pointer)...)
+		}
+	}
+	return violations
+}
+
+// validateObject 校验对象的必填字段及各字段
+func (v *validator) validateObject(s map[string]interface{}, value map[string]interface{}, pointer string) []Violation {
+	var violations []Violation
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, ok := value[key]; !ok {
+				violations = append(violations, Violation{Pointer: pointer + "/" + escapePointer(key), Message: "缺少必填字段"})
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := pointer + "/" + escapePointer(key)
+		if sub, ok := properties[key]; ok {
+			violations = append(violations, v.validate(sub, value[key], child)...)
+		} else if extra, ok := s["additionalProperties"]; ok {
+			violations = append(violations, v.validate(extra, value[key], child)...)
+		}
+	}
+	return violations
+}
+
+// validateArray 校验数组长度及各元素
+func (v *validator) validateArray(s map[string]interface{}, value []interface{}, pointer string) []Violation {
+	var violations []Violation
+	if min, ok := intKeyword(s, "minItems"); ok && len(value) < min {
+		violations = append(violations, Violation{Pointer: pointer, Message: fmt.Sprintf("至少应有 %d 项", min)})
+	}
+	if max, ok := intKeyword(s, "maxItems"); ok && len(value) > max {
+		violations = append(violations, Violation{Pointer: pointer, Message: fmt.Sprintf("最多 %d 项", max)})
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range value {
+			violations = append(violations, v.validate(items, item, pointer+"/"+strconv.Itoa(i))...)
+		}
+	}
+	return violations
+}
+
+// validateBranches 校验 anyOf（至少符合一个）及 oneOf（恰好符合一个）
+//
+// 都不符合时报告最接近的分支：优先类型相符的分支，其次不符之处最少的分支。
+func (v *validator) validateBranches(branches []interface{}, value interface{}, pointer string, exclusive bool) []Violation {
+	var best []Violation
+	bestMismatch := true
+	matched := 0
+	for _, branch := range branches {
+		violations := v.validate(branch, value, pointer)
+		if len(violations) == 0 {
+			matched++
+			continue
+		}
+		mismatch := len(violations) == 1 && violations[0].mismatch && violations[0].Pointer == pointer
+		if best == nil || (bestMismatch && !mismatch) || (bestMismatch == mismatch && len(violations) < len(best)) {
+			best, bestMismatch = violations, mismatch
+		}
+	}
+	switch {
+	case matched == 0:
+		return best
+	case exclusive && matched > 1:
+		return []Violation{{Pointer: pointer, Message: fmt.Sprintf("同时符合 %d 种定义，应恰好符合一种", matched)}}
+	}
+	return nil
+}
+
+// resolve 解析本地引用
+func (v *validator) resolve(ref string) (interface{}, error) {
+	const prefix = "#/definitions/"
+	if !strings.HasPrefix(ref, prefix) {
+		return nil, fmt.Errorf("不支持的引用 %s", ref)
+	}
+	definitions, _ := v.root["definitions"].(map[string]interface{})
+	target, ok := definitions[strings.TrimPrefix(ref, prefix)]
+	if !ok {
+		return nil, fmt.Errorf("引用 %s 不存在", ref)
+	}
+	return target, nil
+}
+
+// validateString 校验字符串长度及格式
+func validateString(s map[string]interface{}, value, pointer string) []Violation {
+	var violations []Violation
+	length := utf8.RuneCountInString(value)
+	if min, ok := intKeyword(s, "minLength"); ok && length < min {
+		if min == 1 {
+			violations = append(violations, Violation{Pointer: pointer, Message: "不能为空"})
+		} else {
+			violations = append(violations, Violation{Pointer: pointer, Message: fmt.Sprintf("长度至少为 %d", min)})
+		}
+	}
+	if max, ok := intKeyword(s, "maxLength"); ok && length > max {
+		violations = append(violations, Violation{Pointer: pointer, Message: fmt.Sprintf("长度超过 %d", max)})
+	}
+	if format, _ := s["format"].(string); format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			violations = append(violations, Violation{Pointer: pointer, Message: "不是 RFC 3339 格式的时间"})
+		}
+	}
+	return violations
+}
+
+// validateNumber 校验数值范围
+func validateNumber(s map[string]interface{}, value json.Number, pointer string) []Violation {
+	f, err := value.Float64()
+	if err != nil {
+		return []Violation{{Pointer: pointer, Message: "数值无效"}}
+	}
+	var violations []Violation
+	if min, ok := s["minimum"].(json.Number); ok {
+		if bound, err := min.Float64(); err == nil && f < bound {
+			violations = append(violations, Violation{Pointer: pointer, Message: "不能小于 " + min.String()})
+		}
+	}
+	if max, ok := s["maximum"].(json.Number); ok {
+		if bound, err := max.Float64(); err == nil && f > bound {
+			violations = append(violations, Violation{Pointer: pointer, Message: "不能大于 " + max.String()})
+		}
+	}
+	return violations
+}
+
+// matchesType 值是否符合 type 关键字（单个类型名或类型名数组）
+func matchesType(types interface{}, value interface{}) bool {
+	switch t := types.(type) {
+	case string:
+		return matchesTypeName(t, value)
+	case []interface{}:
+		for _, name := range t {
+			if s, ok := name.(string); ok && matchesTypeName(s, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matchesTypeName 值是否为指定的JSON类型
+func matchesTypeName(name string, value interface{}) bool {
+	actual := typeOf(value)
+	if name == "number" && actual == "integer" {
+		return true
+	}
+	return name == actual
+}
+
+// typeOf 值的JSON类型名，无小数部分的数值为 integer
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// typeNames type 关键字的可读形式
+func typeNames(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, name := range list {
+			names = append(names, fmt.Sprint(name))
+		}
+		return strings.Join(names, " 或 ")
+	}
+	return fmt.Sprint(types)
+}
+
+// equal 两个JSON值是否相等，数值按大小比较
+func equal(a, b interface{}) bool {
+	if x, ok := a.(json.Number); ok {
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	}
+	left, errLeft := json.Marshal(a)
+	right, errRight := json.Marshal(b)
+	return errLeft == nil && errRight == nil && string(left) == string(right)
+}
+
+// literal 值的JSON文本，用于错误说明
+func literal(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// intKeyword 取整数关键字的值
+func intKeyword(s map[string]interface{}, key string) (int, bool) {
+	n, ok := s[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+// escapePointer 按 RFC 6901 转义 JSON Pointer 中的字段名
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
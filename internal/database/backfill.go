@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// Backfill 迁移新增的列的后台回填
+//
+// 迁移只新增可为空的列，启动时不等待回填；服务启动后由回填任务按批执行，查询须容忍尚未回填的行。
+type Backfill struct {
+	Name        string
+	Description string
+	Model       interface{}
+	// Pending 筛选待回填的行，须为幂等条件：已回填的行不再满足
+	Pending func(tx *gorm.DB) *gorm.DB
+	// Fill 在事务中回填一批行，ids 按升序排列
+	Fill func(tx *gorm.DB, ids []uint) error
+}
+
+// backfills 迁移登记的回填
+var backfills []Backfill
+
+// RegisterBackfill 登记迁移新增的列的回填，在 init 中调用；同名回填只创建一次任务
+func RegisterBackfill(backfill Backfill) {
+	backfills = append(backfills, backfill)
+}
+
+// Backfills 迁移登记的回填
+func Backfills() []Backfill {
+	return append([]Backfill(nil), backfills...)
+}
+
+func init() {
+	// 扫码记录可能有数百万行，公开ID在启动后回填；设备表较小，仍在迁移时补齐
+	RegisterBackfill(Backfill{
+		Name:        "barcode_records.public_id",
+		Description: "为升级前写入的扫码记录补齐公开ID",
+		Model:       &models.BarcodeRecord{},
+		Pending: func(tx *gorm.DB) *gorm.DB {
+			return tx.Unscoped().Where("public_id IS NULL OR public_id = ''")
+		},
+		Fill: func(tx *gorm.DB, ids []uint) error {
+			for _, id := range ids {
+				err := tx.Unscoped().Model(&models.BarcodeRecord{}).Where("id = ?", id).
+					UpdateColumn("public_id", models.NewPublicID()).Error
+				if err != nil {
+					return fmt.Errorf("补齐公开ID失败: %w", err)
+				}
+			}
+			return nil
+		},
+	})
+}
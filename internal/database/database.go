@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,9 +17,48 @@ import (
 	"userclient/internal/models"
 )
 
+// SchemaVersion 数据库结构版本，迁移后写入 user_version，新增或修改模型时递增
+const SchemaVersion = 38
+
 // DB 数据库实例
 type DB struct {
 	*gorm.DB
+	legacy *time.Location // 升级前不含时区信息的时间按此时区解释
+}
+
+// schemaModels 需要迁移的模型
+var schemaModels = []interface{}{
+	&models.BarcodeRecord{},
+	&models.Device{},
+	&models.Configuration{},
+	&models.SystemLog{},
+	&models.SequenceState{},
+	&models.SequenceGap{},
+	&models.APIToken{},
+	&models.OutboxEvent{},
+	&models.OutboxDelivery{},
+	&models.DeviceHealthSnapshot{},
+	&models.BarcodeImage{},
+	&models.ClassificationRule{},
+	&models.ScannerProfile{},
+	&models.Heartbeat{},
+	&models.Goal{},
+	&models.GoalProgress{},
+	&models.ReplayJob{},
+	&models.DistinctSketch{},
+	&models.SyncState{},
+	&models.SyncItem{},
+	&models.SyncConflict{},
+	&models.BarcodeConsumption{},
+	&models.ClientPreference{},
+	&models.DailySummary{},
+	&models.MetricSnapshot{},
+	&models.LeaderLease{},
+	&models.QualityReport{},
+	&models.IntegrityCheckpoint{},
+	&models.BarcodeAnnotation{},
+	&models.BackfillJob{},
+	&models.Counter{},
 }
 
 // New 创建数据库连接
@@ -29,6 +69,11 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 		return nil, fmt.Errorf("创建数据目录失败: %w", err)
 	}
 
+	legacy, err := config.LoadLocation(cfg.LegacyTimezone)
+	if err != nil {
+		return nil, err
+	}
+
 	// 配置GORM日志级别
 	logLevel := getLogLevel(cfg.LogLevel)
 
@@ -39,12 +84,13 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 	}, &gorm.Config{
 		Logger: logger.Default.LogMode(logLevel),
 		NowFunc: func() time.Time {
-			return time.Now().Local()
+			return time.Now().UTC()
 		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("连接数据库失败: %w", err)
 	}
+	useUTC(db)
 
 	// 获取底层sql.DB实例进行连接池配置
 	sqlDB, err := db.DB()
@@ -62,8 +108,60 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 		return nil, fmt.Errorf("数据库连接测试失败: %w", err)
 	}
 
+	// 使用WAL日志模式，命令行工具可在服务运行期间并发读取
+	if err := db.Exec("PRAGMA journal_mode=WAL").Error; err != nil {
+		logrus.WithError(err).Warn("启用WAL日志模式失败")
+	}
+
 	logrus.Info("数据库连接成功")
 
+	return &DB{DB: db, legacy: legacy}, nil
+}
+
+// FilePath DSN对应的数据库文件路径
+func FilePath(cfg *config.DatabaseConfig) string {
+	path := strings.TrimPrefix(cfg.DSN, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// OpenReadOnly 以只读方式打开已有的数据库文件，供命令行工具在服务运行期间查询
+func OpenReadOnly(cfg *config.DatabaseConfig) (*DB, error) {
+	path := FilePath(cfg)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("数据库文件不存在: %s", path)
+		}
+		return nil, fmt.Errorf("访问数据库文件失败: %w", err)
+	}
+
+	// 只读连接不会创建文件或修改结构，服务写入时等待锁释放
+	dsn := "file:" + filepath.ToSlash(path) + "?mode=ro&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Dialector{
+		DriverName: "sqlite",
+		DSN:        dsn,
+	}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("连接数据库失败: %w", err)
+	}
+	useUTC(db)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("获取数据库实例失败: %w", err)
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("数据库连接测试失败: %w", err)
+	}
+
 	return &DB{DB: db}, nil
 }
 
@@ -71,21 +169,176 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 func (db *DB) AutoMigrate() error {
 	logrus.Info("开始数据库迁移...")
 
+	version, err := db.MigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	if err := db.dropStaleStationActiveIndex(); err != nil {
+		return err
+	}
+
 	// 迁移所有模型
-	err := db.DB.AutoMigrate(
-		&models.BarcodeRecord{},
-		&models.Device{},
-		&models.Configuration{},
-		&models.SystemLog{},
-	)
+	err = db.DB.AutoMigrate(schemaModels...)
 	if err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
 	}
 
+	// 扫码记录的公开ID由后台回填任务补齐，见 backfill.go
+	if err := db.backfillPublicIDs(&models.Device{}); err != nil {
+		return err
+	}
+
+	if err := db.ensureConfigurations(); err != nil {
+		return err
+	}
+
+	// 设备按工位区分活跃状态，索引须在清理重复的活跃设备之后创建
+	if version < stationSchemaVersion {
+		if err := db.backfillDeviceStations(); err != nil {
+			return err
+		}
+	}
+	if err := db.ensureStationActiveIndex(); err != nil {
+		return err
+	}
+
+	// 旧版本按工位本地时区写入时间，转换为UTC
+	if version < utcSchemaVersion {
+		if err := db.migrateTimestampsToUTC(schemaModels, db.legacy); err != nil {
+			return err
+		}
+	}
+
+	if err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)).Error; err != nil {
+		return fmt.Errorf("写入数据库结构版本失败: %w", err)
+	}
+
 	logrus.Info("数据库迁移完成")
 	return nil
 }
 
+// backfillPublicIDs 为升级前写入的记录补齐公开ID
+func (db *DB) backfillPublicIDs(model interface{}) error {
+	for {
+		var ids []uint
+		err := db.Unscoped().Model(model).Where("public_id IS NULL OR public_id = ''").
+			Order("id").Limit(500).Pluck("id", &ids).Error
+		if err != nil {
+			return fmt.Errorf("查询待补齐公开ID的记录失败: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		for _, id := range ids {
+			err := db.Unscoped().Model(model).Where("id = ?", id).
+				UpdateColumn("public_id", models.NewPublicID()).Error
+			if err != nil {
+				return fmt.Errorf("补齐公开ID失败: %w", err)
+			}
+		}
+		logrus.WithField("count", len(ids)).Info("已为历史记录补齐公开ID")
+	}
+}
+
+// addedConfigurations 升级后新增的系统配置项，迁移时补齐缺失的条目
+var addedConfigurations = []models.Configuration{
+	{
+		Key:         "barcode.link_allow_list",
+		Value:       "[]",
+		Description: "网址条码可点击的域名白名单（JSON数组，支持 *.example.com 通配）",
+		Type:        "json",
+		Category:    "barcode",
+		IsSystem:    true,
+	},
+	{
+		Key:         "scanner.presets",
+		Value:       "[]",
+		Description: "站点自定义扫码枪参数预设（JSON数组），修改后重启生效",
+		Type:        "json",
+		Category:    "scanner",
+		IsSystem:    true,
+	},
+	{
+		Key:         "feedback.enabled",
+		Value:       "false",
+		Description: "扫码后播放提示音（仅Windows，仅API模式下不播放）",
+		Type:        "bool",
+		Category:    "feedback",
+		IsSystem:    true,
+	},
+	{
+		Key:         "feedback.success_freq",
+		Value:       "2000",
+		Description: "扫码被接受的提示音音调（Hz）",
+		Type:        "int",
+		Category:    "feedback",
+		IsSystem:    true,
+	},
+	{
+		Key:         "feedback.error_freq",
+		Value:       "400",
+		Description: "扫码被拒绝的提示音音调（Hz），时长为接受音的两倍",
+		Type:        "int",
+		Category:    "feedback",
+		IsSystem:    true,
+	},
+	{
+		Key:         "feedback.duration_ms",
+		Value:       "100",
+		Description: "扫码被接受的提示音时长（毫秒）",
+		Type:        "int",
+		Category:    "feedback",
+		IsSystem:    true,
+	},
+	{
+		Key:         "feedback.success_wav",
+		Value:       "",
+		Description: "扫码被接受时播放的WAV文件，为空时使用音调",
+		Type:        "string",
+		Category:    "feedback",
+		IsSystem:    true,
+	},
+	{
+		Key:         "feedback.error_wav",
+		Value:       "",
+		Description: "扫码被拒绝时播放的WAV文件，为空时使用音调",
+		Type:        "string",
+		Category:    "feedback",
+		IsSystem:    true,
+	},
+}
+
+// ensureConfigurations 补齐新增的系统配置项，已存在的不修改
+func (db *DB) ensureConfigurations() error {
+	for _, config := range addedConfigurations {
+		var count int64
+		if err := db.Unscoped().Model(&models.Configuration{}).Where("key = ?", config.Key).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询系统配置失败: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+		config := config
+		if err := db.Create(&config).Error; err != nil {
+			return fmt.Errorf("创建系统配置 %s 失败: %w", config.Key, err)
+		}
+	}
+	return nil
+}
+
+// MigrationVersion 当前数据库的结构版本
+func (db *DB) MigrationVersion() (int, error) {
+	var version int
+	if err := db.Raw("PRAGMA user_version").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("读取数据库结构版本失败: %w", err)
+	}
+	return version, nil
+}
+
+// DefaultDeviceSerialNo 初始化时创建的默认设备的序列号
+const DefaultDeviceSerialNo = "DEFAULT-001"
+
 // seedDevices 初始化设备数据
 func (db *DB) seedDevices() error {
 	// 检查是否已存在设备
@@ -100,7 +353,7 @@ func (db *DB) seedDevices() error {
 		Name:        "默认扫码枪",
 		Type:        "scanner",
 		Model:       "Generic USB Scanner",
-		SerialNo:    "DEFAULT-001",
+		SerialNo:    DefaultDeviceSerialNo,
 		Description: "系统默认扫码枪设备",
 		Status:      "active",
 		IsActive:    true,
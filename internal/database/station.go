@@ -0,0 +1,123 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// stationSchemaVersion 起设备按工位区分活跃状态
+const stationSchemaVersion = 27
+
+// stationActiveIndexDDL 工位活跃设备部分唯一索引，与 SQLite 保存在 sqlite_master 中的定义一致
+const stationActiveIndexDDL = "CREATE UNIQUE INDEX idx_devices_station_active ON devices(station) WHERE is_active AND deleted_at IS NULL"
+
+// backfillDeviceStations 为升级前的设备补齐所属工位
+//
+// 设备表此前没有工位信息，只有心跳中仅出现过一个工位时才能确定设备归属；
+// 多个工位共用数据库时保持未分配，由管理员分配或在只有本工位时启动时认领。
+func (db *DB) backfillDeviceStations() error {
+	var stations []string
+	err := db.Model(&models.Heartbeat{}).Where("station <> ''").Distinct().Limit(2).Pluck("station", &stations).Error
+	if err != nil {
+		return fmt.Errorf("查询心跳工位失败: %w", err)
+	}
+	if len(stations) != 1 {
+		return nil
+	}
+
+	result := db.Unscoped().Model(&models.Device{}).Where("station = '' OR station IS NULL").
+		UpdateColumn("station", stations[0])
+	if result.Error != nil {
+		return fmt.Errorf("补齐设备工位失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		logrus.WithFields(logrus.Fields{
+			"station": stations[0],
+			"count":   result.RowsAffected,
+		}).Info("已为历史设备补齐工位")
+	}
+	return nil
+}
+
+// ensureStationActiveIndex 每个工位至多保留一个活跃设备并创建部分唯一索引
+//
+// 升级前激活设备会取消所有工位的活跃状态，但 is_active 的默认值为 true，新建的设备同样是活跃的，
+// 同一工位可能存在多个活跃设备，创建索引前只保留其中ID最大（最近创建）的一个。
+func (db *DB) ensureStationActiveIndex() error {
+	result := db.Exec(`UPDATE devices SET is_active = false
+		WHERE is_active AND deleted_at IS NULL AND id NOT IN (
+			SELECT MAX(id) FROM devices WHERE is_active AND deleted_at IS NULL GROUP BY station)`)
+	if result.Error != nil {
+		return fmt.Errorf("清理重复的活跃设备失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		logrus.WithField("count", result.RowsAffected).Warn("同一工位存在多个活跃设备，已停用多余的设备")
+	}
+
+	existing, err := db.stationActiveIndex()
+	if err != nil || existing != "" {
+		return err
+	}
+	if err := db.Exec(stationActiveIndexDDL).Error; err != nil {
+		return fmt.Errorf("创建工位活跃设备索引失败: %w", err)
+	}
+	return nil
+}
+
+// dropStaleStationActiveIndex 删除定义与 stationActiveIndexDDL 不同的工位活跃设备索引，须在 gorm 迁移之前调用
+//
+// gorm 的 SQLite 迁移按单行格式解析表上的索引定义，早期写入的换行定义会导致迁移失败；删除后由 ensureStationActiveIndex 重建。
+func (db *DB) dropStaleStationActiveIndex() error {
+	existing, err := db.stationActiveIndex()
+	if err != nil || existing == "" || existing == stationActiveIndexDDL {
+		return err
+	}
+	if err := db.Exec("DROP INDEX idx_devices_station_active").Error; err != nil {
+		return fmt.Errorf("删除工位活跃设备索引失败: %w", err)
+	}
+	return nil
+}
+
+// stationActiveIndex 已存在的工位活跃设备索引的定义，不存在时返回空串
+func (db *DB) stationActiveIndex() (string, error) {
+	var existing []string
+	if err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND name = 'idx_devices_station_active'").Scan(&existing).Error; err != nil {
+		return "", fmt.Errorf("查询工位活跃设备索引失败: %w", err)
+	}
+	if len(existing) == 0 {
+		return "", nil
+	}
+	return existing[0], nil
+}
+
+// CreateStationDevice 在事务中创建设备，所属工位尚无活跃设备时新设备为活跃，否则为非活跃
+//
+// is_active 带 default:true，零值写入时会被替换为 true，与工位活跃设备唯一索引冲突，
+// 因此先让出该工位的活跃名额，创建后将新设备置为非活跃，再恢复原活跃设备。
+func CreateStationDevice(tx *gorm.DB, device *models.Device) error {
+	var current []models.Device
+	if err := tx.Where("station = ? AND is_active = ?", device.Station, true).Limit(1).Find(&current).Error; err != nil {
+		return fmt.Errorf("查询工位活跃设备失败: %w", err)
+	}
+	if len(current) == 0 {
+		device.IsActive = true
+		return tx.Create(device).Error
+	}
+
+	active := tx.Model(&models.Device{}).Where("id = ?", current[0].ID)
+	if err := active.Session(&gorm.Session{}).UpdateColumn("is_active", false).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(device).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Device{}).Where("id = ?", device.ID).UpdateColumn("is_active", false).Error; err != nil {
+		return err
+	}
+	device.IsActive = false
+	return active.Session(&gorm.Session{}).UpdateColumn("is_active", true).Error
+}
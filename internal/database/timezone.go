@@ -0,0 +1,340 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// utcSchemaVersion 起数据库中的时间一律以UTC保存
+const utcSchemaVersion = 19
+
+// utcConnPool 将查询参数中的时间转换为UTC后再交给驱动
+//
+// SQLite 没有时间类型，驱动按 time.Time.String() 的格式写入文本，时间比较实际是字符串比较。
+// 各工位时区不同，统一以UTC写入和比较才能保证范围查询与排序正确；
+// 在连接池上统一转换，调用方无需关心传入时间的时区。
+type utcConnPool struct {
+	gorm.ConnPool
+}
+
+// ExecContext 实现 gorm.ConnPool
+func (p *utcConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.ConnPool.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+// QueryContext 实现 gorm.ConnPool
+func (p *utcConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.ConnPool.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+// QueryRowContext 实现 gorm.ConnPool
+func (p *utcConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.ConnPool.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+// BeginTx 实现 gorm.ConnPoolBeginner，事务内的查询同样转换
+func (p *utcConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	beginner, ok := p.ConnPool.(gorm.TxBeginner)
+	if !ok {
+		return nil, gorm.ErrInvalidTransaction
+	}
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &utcTx{Tx: tx, db: p.sqlDB()}, nil
+}
+
+// GetDBConn 实现 gorm.GetDBConnector
+func (p *utcConnPool) GetDBConn() (*sql.DB, error) {
+	if db := p.sqlDB(); db != nil {
+		return db, nil
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// Ping 供 gorm 检查连接
+func (p *utcConnPool) Ping() error {
+	if db := p.sqlDB(); db != nil {
+		return db.Ping()
+	}
+	return nil
+}
+
+// sqlDB 底层连接池
+func (p *utcConnPool) sqlDB() *sql.DB {
+	db, _ := p.ConnPool.(*sql.DB)
+	return db
+}
+
+// utcTx 转换查询参数时区的事务
+type utcTx struct {
+	*sql.Tx
+	db *sql.DB
+}
+
+// ExecContext 实现 gorm.ConnPool
+func (t *utcTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.ExecContext(ctx, query, utcArgs(args)...)
+}
+
+// QueryContext 实现 gorm.ConnPool
+func (t *utcTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.QueryContext(ctx, query, utcArgs(args)...)
+}
+
+// QueryRowContext 实现 gorm.ConnPool
+func (t *utcTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRowContext(ctx, query, utcArgs(args)...)
+}
+
+// GetDBConn 实现 gorm.GetDBConnector
+func (t *utcTx) GetDBConn() (*sql.DB, error) {
+	return t.db, nil
+}
+
+// utcArgs 将参数中的时间转换为UTC，其他参数原样返回
+func utcArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				utc := v.UTC()
+				args[i] = &utc
+			}
+		}
+	}
+	return args
+}
+
+// useUTC 在连接池上启用UTC转换
+func useUTC(db *gorm.DB) {
+	db.ConnPool = &utcConnPool{ConnPool: db.ConnPool}
+	db.Statement.ConnPool = db.ConnPool
+}
+
+// storedTimeLayouts 历史数据中带时区信息的时间格式
+var storedTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST", // time.Time.String()，驱动默认的写入格式
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+}
+
+// naiveTimeLayouts 不含时区信息的时间格式，按 legacy_timezone 解释
+var naiveTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseStoredTime 解析数据库中以文本保存的时间，naive 表示不含时区信息、按 legacy 解释
+func parseStoredTime(value string, legacy *time.Location) (t time.Time, naive bool, err error) {
+	// time.Time.String() 可能带单调时钟读数，如 "... +0800 CST m=+0.012"
+	if i := strings.Index(value, " m="); i >= 0 {
+		value = value[:i]
+	}
+	for _, layout := range storedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, false, nil
+		}
+	}
+	for _, layout := range naiveTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, legacy); err == nil {
+			return t, true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("无法解析时间: %s", value)
+}
+
+// UTCMigrationKey 记录历史时间转换结果的系统配置项
+const UTCMigrationKey = "database.utc_migration"
+
+// UTCMigration 历史时间转换为UTC的结果
+//
+// 不含时区信息的时间按 LegacyTimezone 解释，升级后发现时区设置有误时据此确认哪些时间需要修正。
+type UTCMigration struct {
+	LegacyTimezone string    `json:"legacy_timezone"`
+	Rows           int       `json:"rows"`    // 改写的记录数
+	Assumed        int       `json:"assumed"` // 按 LegacyTimezone 解释的时间字段数
+	Invalid        int       `json:"invalid"` // 无法解析、保持原值的时间字段数
+	MigratedAt     time.Time `json:"migrated_at"`
+}
+
+// saveUTCMigration 保存转换结果，与系统配置一同导出
+func (db *DB) saveUTCMigration(result UTCMigration) error {
+	value, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("序列化时间转换结果失败: %w", err)
+	}
+	entry := models.Configuration{
+		Key:         UTCMigrationKey,
+		Value:       string(value),
+		Description: "升级时历史时间转换为UTC的结果，不含时区信息的时间按 legacy_timezone 解释，仅供核对",
+		Type:        "json",
+		Category:    "database",
+		IsSystem:    true,
+	}
+	err = db.Unscoped().Where(models.Configuration{Key: UTCMigrationKey}).
+		Assign(map[string]interface{}{"value": entry.Value, "deleted_at": nil}).
+		FirstOrCreate(&entry).Error
+	if err != nil {
+		return fmt.Errorf("保存时间转换结果失败: %w", err)
+	}
+	return nil
+}
+
+// UTCMigration 升级时历史时间的转换结果，未转换过历史时间时返回 nil
+func (db *DB) UTCMigration() (*UTCMigration, error) {
+	var entry models.Configuration
+	err := db.Where("key = ?", UTCMigrationKey).Limit(1).Find(&entry).Error
+	if err != nil {
+		return nil, fmt.Errorf("读取时间转换结果失败: %w", err)
+	}
+	if entry.ID == 0 {
+		return nil, nil
+	}
+	var result UTCMigration
+	if err := json.Unmarshal([]byte(entry.Value), &result); err != nil {
+		return nil, fmt.Errorf("解析时间转换结果失败: %w", err)
+	}
+	return &result, nil
+}
+
+// migrateTimestampsToUTC 将升级前按工位本地时区写入的时间改写为UTC
+//
+// 带偏移量的时间（驱动默认写入的格式）可精确换算；不含时区信息的时间按 legacy
+// 解释，这是一个假设，转换结果保存在 UTCMigrationKey 配置项中供核对。无法解析的值保持不变并告警。
+func (db *DB) migrateTimestampsToUTC(models []interface{}, legacy *time.Location) error {
+	timeType := reflect.TypeOf(time.Time{})
+	var converted, assumed, invalid int
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db.DB}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("解析模型失败: %w", err)
+		}
+		primary := stmt.Schema.PrioritizedPrimaryField
+		if primary == nil {
+			continue
+		}
+		var columns []string
+		for _, field := range stmt.Schema.Fields {
+			t := field.FieldType
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if field.DBName != "" && (t == timeType || t == reflect.TypeOf(gorm.DeletedAt{})) {
+				columns = append(columns, field.DBName)
+			}
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		table := stmt.Schema.Table
+		key := stmt.Quote(primary.DBName)
+		selects := []string{key}
+		for _, column := range columns {
+			selects = append(selects, fmt.Sprintf("CAST(%s AS TEXT)", stmt.Quote(column)))
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s > ? ORDER BY %s LIMIT 500",
+			strings.Join(selects, ", "), stmt.Quote(table), key, key)
+
+		var last int64
+		for {
+			rows, err := db.Raw(query, last).Rows()
+			if err != nil {
+				return fmt.Errorf("读取 %s 的时间字段失败: %w", table, err)
+			}
+			updates := make(map[int64]map[string]interface{})
+			n := 0
+			for rows.Next() {
+				values := make([]sql.NullString, len(columns))
+				dest := []interface{}{&last}
+				for i := range values {
+					dest = append(dest, &values[i])
+				}
+				if err := rows.Scan(dest...); err != nil {
+					rows.Close()
+					return fmt.Errorf("读取 %s 的时间字段失败: %w", table, err)
+				}
+				n++
+				for i, value := range values {
+					if !value.Valid || value.String == "" || strings.HasSuffix(value.String, "+0000 UTC") {
+						continue
+					}
+					t, naive, err := parseStoredTime(value.String, legacy)
+					if err != nil {
+						invalid++
+						logrus.WithField("table", table).WithField("id", last).WithField("column", columns[i]).
+							WithError(err).Warn("时间字段无法转换为UTC，保持原值")
+						continue
+					}
+					if naive {
+						assumed++
+					}
+					if updates[last] == nil {
+						updates[last] = make(map[string]interface{})
+					}
+					updates[last][columns[i]] = t
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("读取 %s 的时间字段失败: %w", table, err)
+			}
+
+			err = db.Transaction(func(tx *gorm.DB) error {
+				for id, columns := range updates {
+					if err := tx.Table(table).Where(key+" = ?", id).UpdateColumns(columns).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("转换 %s 的时间字段失败: %w", table, err)
+			}
+			converted += len(updates)
+			if n < 500 {
+				break
+			}
+		}
+	}
+
+	if converted == 0 && invalid == 0 {
+		return nil
+	}
+	if err := db.saveUTCMigration(UTCMigration{
+		LegacyTimezone: legacy.String(),
+		Rows:           converted,
+		Assumed:        assumed,
+		Invalid:        invalid,
+		MigratedAt:     time.Now(),
+	}); err != nil {
+		return err
+	}
+	entry := logrus.WithFields(logrus.Fields{
+		"rows":            converted,
+		"assumed":         assumed,
+		"legacy_timezone": legacy.String(),
+	})
+	if assumed > 0 || invalid > 0 {
+		entry.WithField("invalid", invalid).Warn("历史时间已转换为UTC，部分时间不含时区信息，已按 database.legacy_timezone 解释")
+	} else {
+		entry.Info("历史时间已转换为UTC")
+	}
+	return nil
+}
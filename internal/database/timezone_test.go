@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// TestUTCMigrationRecorded 升级时不含时区信息的历史时间按 legacy_timezone 解释，转换结果保存为系统配置项
+func TestUTCMigrationRecorded(t *testing.T) {
+	cfg := &config.DatabaseConfig{
+		DSN:            filepath.Join(t.TempDir(), "legacy.db"),
+		LogLevel:       "silent",
+		MaxIdleConns:   1,
+		MaxOpenConns:   1,
+		LegacyTimezone: "Asia/Shanghai",
+	}
+	db, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := db.UTCMigration(); err != nil || result != nil {
+		t.Fatalf("新建的数据库不应有时间转换结果: %+v, %v", result, err)
+	}
+
+	// 模拟升级前的数据库：时间按工位本地时间写入且不含时区信息
+	record := models.BarcodeRecord{PublicID: models.NewPublicID(), Content: "LEGACY1", Length: 7, Status: "valid"}
+	if err := db.Create(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec("UPDATE barcode_records SET created_at = ?, updated_at = ? WHERE id = ?",
+		"2024-03-01 08:30:00", "2024-03-01 08:30:00", record.ID).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", utcSchemaVersion-1)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	var migrated models.BarcodeRecord
+	if err := db.First(&migrated, record.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 1, 0, 30, 0, 0, time.UTC); !migrated.CreatedAt.Equal(want) {
+		t.Fatalf("转换后的扫码时间 = %v，want %v", migrated.CreatedAt.UTC(), want)
+	}
+
+	result, err := db.UTCMigration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil {
+		t.Fatal("未保存时间转换结果")
+	}
+	if result.LegacyTimezone != "Asia/Shanghai" || result.Rows != 1 || result.Assumed != 2 || result.Invalid != 0 {
+		t.Fatalf("时间转换结果 = %+v", result)
+	}
+	if result.MigratedAt.IsZero() {
+		t.Fatal("未记录转换时间")
+	}
+	var entry models.Configuration
+	if err := db.Where("key = ?", UTCMigrationKey).First(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !entry.IsSystem || entry.Category != "database" || entry.Type != "json" {
+		t.Fatalf("时间转换结果配置项 = %+v", entry)
+	}
+}
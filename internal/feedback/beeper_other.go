@@ -0,0 +1,8 @@
+//go:build !windows
+
+package feedback
+
+// NewSystemBeeper 当前平台不支持提示音
+func NewSystemBeeper() (Beeper, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package feedback
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// PlaySound 标志
+const (
+	sndSync      = 0x0000
+	sndNoDefault = 0x0002
+	sndFilename  = 0x00020000
+)
+
+var (
+	kernel32  = syscall.NewLazyDLL("kernel32.dll")
+	winmm     = syscall.NewLazyDLL("winmm.dll")
+	beep      = kernel32.NewProc("Beep")
+	playSound = winmm.NewProc("PlaySoundW")
+)
+
+// systemBeeper 调用系统 Beep 及 PlaySound，两者都会阻塞到播放结束，由 Player 的 goroutine 调用
+type systemBeeper struct{}
+
+// NewSystemBeeper 创建当前平台的提示音设备
+func NewSystemBeeper() (Beeper, error) {
+	if err := beep.Find(); err != nil {
+		return nil, fmt.Errorf("加载 Beep 失败: %w", err)
+	}
+	return systemBeeper{}, nil
+}
+
+// Beep 以指定音调发声，音调须在 37 到 32767 Hz 之间
+func (systemBeeper) Beep(freq int, duration time.Duration) error {
+	ret, _, err := beep.Call(uintptr(freq), uintptr(duration.Milliseconds()))
+	if ret == 0 {
+		return fmt.Errorf("Beep 调用失败: %w", err)
+	}
+	return nil
+}
+
+// PlayWAV 播放WAV文件，文件不存在时不播放系统默认声音
+func (systemBeeper) PlayWAV(path string) error {
+	if err := playSound.Find(); err != nil {
+		return fmt.Errorf("加载 PlaySound 失败: %w", err)
+	}
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	ret, _, err := playSound.Call(uintptr(unsafe.Pointer(name)), 0, sndFilename|sndSync|sndNoDefault)
+	if ret == 0 {
+		return fmt.Errorf("播放 %s 失败: %w", path, err)
+	}
+	return nil
+}
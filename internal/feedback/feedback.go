@@ -0,0 +1,161 @@
+// Package feedback 扫码结果的提示音，让看不到屏幕的操作员立即知道扫码是否被接受
+//
+// 提示音在独立的 goroutine 中播放，调用方只把结果放入队列，不会阻塞扫码处理及键盘钩子回调；
+// 队列已满（连续快速扫码）时丢弃新的提示音。
+package feedback
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrUnsupported 当前平台不支持提示音
+var ErrUnsupported = errors.New("提示音仅支持Windows")
+
+// queueSize 等待播放的提示音数
+const queueSize = 4
+
+// Beeper 发出提示音，Windows下为系统 Beep 及 PlaySound，测试中使用 Recorder
+type Beeper interface {
+	Beep(freq int, duration time.Duration) error
+	PlayWAV(path string) error
+}
+
+// Settings 提示音设置，取自系统配置 feedback.*，可在运行中修改
+type Settings struct {
+	Enabled     bool
+	SuccessFreq int           // 扫码被接受的音调（Hz）
+	ErrorFreq   int           // 扫码被拒绝的音调（Hz），拒绝音时长为接受音的两倍
+	Duration    time.Duration // 接受音时长
+	SuccessWAV  string        // 扫码被接受时播放的WAV文件，为空时使用 SuccessFreq
+	ErrorWAV    string        // 扫码被拒绝时播放的WAV文件，为空时使用 ErrorFreq
+}
+
+// Metrics 提示音计数
+type Metrics struct {
+	Played  uint64 `json:"played"`
+	Dropped uint64 `json:"dropped"` // 队列已满而丢弃的提示音
+	Failed  uint64 `json:"failed"`  // 播放失败的提示音
+}
+
+// Player 异步播放扫码结果提示音
+type Player struct {
+	beeper   Beeper
+	logger   *logrus.Logger
+	settings atomic.Pointer[Settings]
+	queue    chan bool // true 为接受，false 为拒绝
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	played  uint64 // 以下为原子计数
+	dropped uint64
+	failed  uint64
+}
+
+// NewPlayer 创建提示音播放器
+func NewPlayer(beeper Beeper, settings Settings, logger *logrus.Logger) *Player {
+	p := &Player{
+		beeper: beeper,
+		logger: logger,
+		queue:  make(chan bool, queueSize),
+		done:   make(chan struct{}),
+	}
+	p.SetSettings(settings)
+	return p
+}
+
+// SetSettings 更新提示音设置，可在运行中调用
+func (p *Player) SetSettings(settings Settings) {
+	if settings.Duration <= 0 {
+		settings.Duration = 150 * time.Millisecond
+	}
+	p.settings.Store(&settings)
+}
+
+// Settings 当前的提示音设置
+func (p *Player) Settings() Settings {
+	return *p.settings.Load()
+}
+
+// Accepted 扫码被接受
+func (p *Player) Accepted() {
+	p.signal(true)
+}
+
+// Rejected 扫码被拒绝（格式无效、入库失败或维护模式中）
+func (p *Player) Rejected() {
+	p.signal(false)
+}
+
+// Metrics 获取提示音计数
+func (p *Player) Metrics() Metrics {
+	return Metrics{
+		Played:  atomic.LoadUint64(&p.played),
+		Dropped: atomic.LoadUint64(&p.dropped),
+		Failed:  atomic.LoadUint64(&p.failed),
+	}
+}
+
+// Start 启动播放
+func (p *Player) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			select {
+			case accepted := <-p.queue:
+				p.play(accepted)
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止播放，队列中未播放的提示音丢弃
+func (p *Player) Close() {
+	close(p.done)
+	p.wg.Wait()
+}
+
+// signal 放入播放队列，不等待播放
+func (p *Player) signal(accepted bool) {
+	if !p.settings.Load().Enabled {
+		return
+	}
+	select {
+	case p.queue <- accepted:
+	default:
+		atomic.AddUint64(&p.dropped, 1)
+	}
+}
+
+// play 播放一次提示音，设置了WAV文件时优先播放文件
+func (p *Player) play(accepted bool) {
+	settings := p.settings.Load()
+	if !settings.Enabled {
+		return
+	}
+
+	wav, freq, duration := settings.SuccessWAV, settings.SuccessFreq, settings.Duration
+	if !accepted {
+		wav, freq, duration = settings.ErrorWAV, settings.ErrorFreq, 2*settings.Duration
+	}
+
+	var err error
+	if wav != "" {
+		err = p.beeper.PlayWAV(wav)
+	} else {
+		err = p.beeper.Beep(freq, duration)
+	}
+	if err != nil {
+		atomic.AddUint64(&p.failed, 1)
+		p.logger.WithError(err).WithField("accepted", accepted).Debug("播放扫码提示音失败")
+		return
+	}
+	atomic.AddUint64(&p.played, 1)
+}
@@ -0,0 +1,57 @@
+package feedback
+
+import (
+	"sync"
+	"time"
+)
+
+// Sound 一次提示音，Freq 为0时表示播放 WAV
+type Sound struct {
+	Freq     int
+	Duration time.Duration
+	WAV      string
+}
+
+// Recorder 只记录不发声的提示音设备，用于测试及没有声卡的环境
+type Recorder struct {
+	mu     sync.Mutex
+	sounds []Sound
+	err    error
+}
+
+// SetError 之后的播放返回 err，为nil时恢复正常
+func (r *Recorder) SetError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+// Beep 记录一次音调
+func (r *Recorder) Beep(freq int, duration time.Duration) error {
+	return r.record(Sound{Freq: freq, Duration: duration})
+}
+
+// PlayWAV 记录一次WAV播放
+func (r *Recorder) PlayWAV(path string) error {
+	return r.record(Sound{WAV: path})
+}
+
+// Sounds 已记录的提示音
+func (r *Recorder) Sounds() []Sound {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sounds := make([]Sound, len(r.sounds))
+	copy(sounds, r.sounds)
+	return sounds
+}
+
+// record 记录提示音，设置了错误时不记录
+func (r *Recorder) record(sound Sound) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.sounds = append(r.sounds, sound)
+	return nil
+}
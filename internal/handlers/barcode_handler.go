@@ -1,35 +1,385 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"userclient/internal/models"
+	"userclient/internal/scanner"
+	"userclient/internal/service"
 	"userclient/internal/websocket"
 	"userclient/pkg/barcode"
 
 	"github.com/sirupsen/logrus"
 )
 
+// ErrMaintenance 维护模式中扫码被忽略
+var ErrMaintenance = errors.New("维护模式中，扫码已忽略")
+
+// MaintenanceChecker 维护模式检查接口
+type MaintenanceChecker interface {
+	IsActive() bool
+	RecordIgnoredScan()
+}
+
+// CaptureGate 热备采集开关，备机待命时本机扫码来源（键盘钩子、串口、键盘钩子代理）的扫码被忽略
+type CaptureGate interface {
+	Capturing() bool
+}
+
+// FlagReader 功能开关读取接口
+type FlagReader interface {
+	Enabled(flag *service.FeatureFlag) bool
+}
+
+// DeviceDirectory 按设备ID查找设备名称及所属工位，用于推送消息
+type DeviceDirectory interface {
+	Identity(id uint) (service.DeviceIdentity, bool)
+}
+
+// ScanFeedback 本机扫码结果的提示音，须立即返回，不能等待播放结束
+type ScanFeedback interface {
+	Accepted()
+	Rejected()
+}
+
+// BarcodeRecorder 条码记录接口
+type BarcodeRecorder interface {
+	RecordScan(content string, scan service.ScanInfo) (*models.BarcodeRecord, error)
+}
+
+// ImageStore 条码图片存储接口
+type ImageStore interface {
+	SaveImage(recordID uint, contentType string, r io.Reader) (*models.BarcodeImage, error)
+}
+
+// ImageUpload 随扫码提交的图片
+type ImageUpload struct {
+	ContentType string
+	Reader      io.Reader
+}
+
 // BarcodeHandler 条码处理器
 type BarcodeHandler struct {
-	hub    *websocket.Hub
-	logger *logrus.Logger
+	hub         *websocket.Hub
+	processor   *barcode.Processor
+	recorder    BarcodeRecorder
+	maintenance MaintenanceChecker
+	images      ImageStore
+	keyTiming   *service.KeyTimingMonitor
+	metrics     *service.ScanMetrics
+	capture     CaptureGate
+	limiter     *service.IngestLimiter
+	flags       FlagReader
+	feedback    ScanFeedback
+	ordering    deviceLocks // 同一设备的入库与推送顺序一致
+	logger      *logrus.Logger
+
+	// 推送消息中的工位及设备，模板可热加载
+	devices  DeviceDirectory
+	station  string
+	template atomic.Pointer[barcode.MessageTemplate]
 }
 
 // NewBarcodeHandler 创建新的条码处理器
-func NewBarcodeHandler(hub *websocket.Hub, logger *logrus.Logger) *BarcodeHandler {
+func NewBarcodeHandler(hub *websocket.Hub, recorder BarcodeRecorder, maintenance MaintenanceChecker, logger *logrus.Logger) *BarcodeHandler {
 	return &BarcodeHandler{
-		hub:    hub,
-		logger: logger,
+		hub:         hub,
+		processor:   barcode.NewProcessor(),
+		recorder:    recorder,
+		maintenance: maintenance,
+		logger:      logger,
 	}
 }
 
+// SetProcessor 设置条码处理器，与入库使用同一处理器以共享分类规则及网址白名单
+func (h *BarcodeHandler) SetProcessor(processor *barcode.Processor) {
+	h.processor = processor
+}
+
+// SetImageStore 设置条码图片存储
+func (h *BarcodeHandler) SetImageStore(images ImageStore) {
+	h.images = images
+}
+
+// SetKeyTimingMonitor 设置按键时序监控
+func (h *BarcodeHandler) SetKeyTimingMonitor(monitor *service.KeyTimingMonitor) {
+	h.keyTiming = monitor
+}
+
+// SetScanMetrics 设置扫码处理指标
+func (h *BarcodeHandler) SetScanMetrics(metrics *service.ScanMetrics) {
+	h.metrics = metrics
+}
+
+// SetCaptureGate 设置热备采集开关
+func (h *BarcodeHandler) SetCaptureGate(gate CaptureGate) {
+	h.capture = gate
+}
+
+// SetIngestLimiter 设置扫码接入限流，按键扫码不受限但占用全局额度，使网络扫码枪及外部推送让出容量
+func (h *BarcodeHandler) SetIngestLimiter(limiter *service.IngestLimiter) {
+	h.limiter = limiter
+}
+
+// SetDevices 设置设备查找及本工位名称，推送消息据此填写扫码设备及所属工位
+func (h *BarcodeHandler) SetDevices(devices DeviceDirectory, station string) {
+	h.devices = devices
+	h.station = station
+}
+
+// SetMessageTemplate 设置推送消息模板，可在运行中调用（配置热加载）
+func (h *BarcodeHandler) SetMessageTemplate(template barcode.MessageTemplate) {
+	h.template.Store(&template)
+}
+
+// SetFlags 设置功能开关
+func (h *BarcodeHandler) SetFlags(flags FlagReader) {
+	h.flags = flags
+}
+
+// SetFeedback 设置本机扫码的提示音，接口提交、外部推送、键盘钩子代理及模拟扫码不提示
+func (h *BarcodeHandler) SetFeedback(feedback ScanFeedback) {
+	h.feedback = feedback
+}
+
 // HandleBarcode 处理条码
 func (h *BarcodeHandler) HandleBarcode(content string) error {
-	h.logger.WithField("barcode", content).Info("检测到条码")
+	return h.HandleTimedBarcode(content, 0)
+}
+
+// HandleTimedBarcode 处理条码，duration为扫码耗时（未知时为0）
+func (h *BarcodeHandler) HandleTimedBarcode(content string, duration time.Duration) error {
+	if h.standingBy(content) {
+		return nil
+	}
+	// 处理失败已通过推送的状态告知前端
+	_, err := h.process(content, service.ScanInfo{Duration: duration}, nil)
+	h.signal(err)
+	return nil
+}
+
+// HandleKeyedBarcode 处理键盘钩子组装出的条码，timings为各按键的时间信息，
+// 按键时序异常的扫码标记为疑似截断
+func (h *BarcodeHandler) HandleKeyedBarcode(content string, duration time.Duration, timings []service.KeyTiming) error {
+	if h.standingBy(content) {
+		return nil
+	}
+	scan := service.ScanInfo{Duration: duration}
+	h.observeTiming(content, timings, &scan)
+	_, err := h.process(content, scan, nil)
+	h.signal(err)
+	return nil
+}
+
+// HandleDeviceBarcode 处理 Raw Input 识别出扫码设备的条码，扫码归属到该设备
+func (h *BarcodeHandler) HandleDeviceBarcode(content string, deviceID uint, duration time.Duration, timings []service.KeyTiming) error {
+	if h.standingBy(content) {
+		return nil
+	}
+	scan := service.ScanInfo{Duration: duration, DeviceID: &deviceID}
+	h.observeTiming(content, timings, &scan)
+	_, err := h.process(content, scan, nil)
+	h.signal(err)
+	return nil
+}
+
+// HandleScan 处理采集来源交出的扫码，记录采集来源标识；识别出扫码设备时归属到该设备，有按键时间信息时检查按键时序
+func (h *BarcodeHandler) HandleScan(s scanner.Scan) error {
+	if h.standingBy(s.Barcode) {
+		return nil
+	}
+	scan := service.ScanInfo{Duration: s.Duration, Input: s.Input}
+	// 按键来源带有按键时间信息，网络扫码枪已在读取时限流
+	if h.limiter != nil && s.Timings != nil {
+		h.limiter.Priority(s.Input)
+	}
+	if s.DeviceID > 0 {
+		scan.DeviceID = &s.DeviceID
+	}
+	h.observeTiming(s.Barcode, s.Timings, &scan)
+	_, err := h.process(s.Barcode, scan, nil)
+	h.signal(err)
+	return nil
+}
+
+// HandleAgentBarcode 处理键盘钩子代理转交的条码，与本机钩子一样检查按键时序
+func (h *BarcodeHandler) HandleAgentBarcode(content string, scan service.ScanInfo, timings []service.KeyTiming) error {
+	if h.standingBy(content) {
+		return nil
+	}
+	scan.Source = models.BarcodeSourceAgent
+	scan.Input = models.BarcodeSourceAgent
+	if h.limiter != nil {
+		h.limiter.Priority(scan.Input)
+	}
+	h.observeTiming(content, timings, &scan)
+	h.process(content, scan, nil)
+	return nil
+}
+
+// Submit 处理通过接口提交的扫码结果及图片（可为nil），返回推送给前端的数据
+func (h *BarcodeHandler) Submit(content string, image *ImageUpload) (*barcode.BarcodeData, error) {
+	return h.process(content, service.ScanInfo{}, image)
+}
 
-	// 创建条码处理器来获取详细信息
-	processor := barcode.NewProcessor()
-	barcodeData := processor.ProcessBarcode(content)
+// Simulate 处理按场景播放的模拟扫码，与外部推送的扫码一样经过校验、分类、入库及推送；与其他设备合并的扫码不算失败
+func (h *BarcodeHandler) Simulate(content string, scan service.ScanInfo) error {
+	scan.Source = models.BarcodeSourceSimulation
+	_, err := h.process(content, scan, nil)
+	if errors.Is(err, service.ErrScanCoalesced) {
+		return nil
+	}
+	return err
+}
+
+// Ingest 处理外部系统推送的扫码结果，经过与本机扫码相同的校验、分类、入库及推送；
+// 与其他设备合并时返回合并到的记录及 service.ErrScanCoalesced
+func (h *BarcodeHandler) Ingest(content string, scan service.ScanInfo) (*barcode.BarcodeData, error) {
+	return h.process(content, scan, nil)
+}
+
+// observeTiming 统计按键间隔，启用按键时序监控时同时判断是否疑似截断；非键盘来源（timings 为nil）不填写
+func (h *BarcodeHandler) observeTiming(content string, timings []service.KeyTiming, scan *service.ScanInfo) {
+	if timings == nil {
+		return
+	}
+	if h.keyTiming == nil {
+		if intervals, ok := service.MeasureKeyIntervals(timings); ok {
+			scan.Intervals = &intervals
+		}
+		return
+	}
+	if timing := h.keyTiming.Observe(content, timings); timing != nil {
+		scan.SuspectTruncation = timing.Suspect
+		scan.Intervals = &timing.KeyIntervals
+	}
+}
+
+// signal 按处理结果播放本机扫码的提示音；与其他设备合并的扫码已被接受，同样提示
+func (h *BarcodeHandler) signal(err error) {
+	if h.feedback == nil {
+		return
+	}
+	if err != nil && !errors.Is(err, service.ErrScanCoalesced) {
+		h.feedback.Rejected()
+		return
+	}
+	h.feedback.Accepted()
+}
+
+// standingBy 热备待命中忽略本机扫码来源的扫码，接口提交及外部推送的扫码不受影响
+func (h *BarcodeHandler) standingBy(content string) bool {
+	if h.capture == nil || h.capture.Capturing() {
+		return false
+	}
+	h.logger.WithField("barcode", content).Warn("热备待命中，忽略本机扫码")
+	return true
+}
+
+// process 记录并推送扫码结果
+func (h *BarcodeHandler) process(content string, scan service.ScanInfo, image *ImageUpload) (*barcode.BarcodeData, error) {
+	// 维护模式下忽略扫码，仅计数
+	if h.maintenance != nil && h.maintenance.IsActive() {
+		h.maintenance.RecordIgnoredScan()
+		h.logger.WithField("barcode", content).Warn("维护模式中，忽略扫码")
+		return nil, ErrMaintenance
+	}
+
+	if h.logger.IsLevelEnabled(logrus.DebugLevel) {
+		h.logger.WithField("barcode", content).Debug("检测到条码")
+	}
+
+	// 入库到推送期间持有设备锁，同一设备的扫码按到达顺序入库及推送（功能开关 pipeline.device_ordering）
+	if h.flags == nil || h.flags.Enabled(service.FlagDeviceOrdering) {
+		defer h.ordering.lock(scan.DeviceID)()
+	}
+	started := time.Now()
+
+	// 获取条码详细信息
+	barcodeData := h.processor.ProcessBarcode(content)
+	barcodeData.SuspectTruncation = scan.SuspectTruncation
+	barcodeData.DurationMS = scan.Duration.Milliseconds()
+	if scan.Intervals != nil {
+		barcodeData.MinKeyIntervalMS = scan.Intervals.Min.Milliseconds()
+		barcodeData.MaxKeyIntervalMS = scan.Intervals.Max.Milliseconds()
+		barcodeData.AvgKeyIntervalMS = float64(scan.Intervals.Avg.Microseconds()) / 1000
+	}
+	barcodeData.Source = scan.Source
+	barcodeData.Input = scan.Input
+	barcodeData.Simulation = scan.Source == models.BarcodeSourceSimulation
+	// 入库、推送及输出端使用同一扫码时间（微秒精度）
+	scan.ScannedAt = models.ScanTime(scan.ScannedAt)
+	barcodeData.Timestamp = scan.ScannedAt
+
+	// 保存扫码记录
+	var recordErr, imageErr error
+	if h.recorder != nil {
+		record, err := h.recorder.RecordScan(content, scan)
+		if errors.Is(err, service.ErrScanCoalesced) {
+			// 先到的扫码已推送及投递，合并的扫码不再推送
+			barcodeData.RecordID = record.ID
+			barcodeData.PublicID = record.PublicID
+			barcodeData.DeviceID = scan.DeviceID
+			barcodeData.Type = record.Type
+			barcodeData.Derived = record.Derived
+			return barcodeData, err
+		}
+		if err != nil {
+			h.logger.WithError(err).Warn("保存扫码记录失败")
+			barcodeData.Status = "error"
+			barcodeData.Message = err.Error()
+			recordErr = err
+		} else {
+			barcodeData.RecordID = record.ID
+			barcodeData.PublicID = record.PublicID
+			barcodeData.DeviceID = record.DeviceID
+			barcodeData.Type = record.Type
+			barcodeData.Derived = record.Derived
+		}
+	}
+
+	h.identify(barcodeData)
+
+	// 保存随扫码提交的图片
+	if image != nil && barcodeData.RecordID > 0 {
+		if h.images == nil {
+			imageErr = fmt.Errorf("未启用条码图片存储")
+		} else if _, err := h.images.SaveImage(barcodeData.RecordID, image.ContentType, image.Reader); err != nil {
+			h.logger.WithError(err).Warn("保存条码图片失败")
+			imageErr = err
+		} else {
+			barcodeData.HasImage = true
+		}
+	}
 
 	// 推送到前端
 	h.hub.BroadcastBarcode(barcodeData)
-	return nil
+	if h.metrics != nil {
+		h.metrics.Observe(time.Since(started), recordErr != nil)
+	}
+
+	if recordErr != nil {
+		return barcodeData, recordErr
+	}
+	return barcodeData, imageErr
+}
+
+// identify 填写扫码设备名称及所属工位，并按模板生成消息；入库失败时消息为错误原因，不套用模板
+func (h *BarcodeHandler) identify(data *barcode.BarcodeData) {
+	data.Station = h.station
+	if data.DeviceID != nil && h.devices != nil {
+		if identity, ok := h.devices.Identity(*data.DeviceID); ok {
+			data.DeviceName = identity.Name
+			if identity.Station != "" {
+				data.Station = identity.Station
+			}
+		}
+	}
+	if template := h.template.Load(); template != nil && data.Status == "success" {
+		data.Message = template.Render(data)
+	}
 }
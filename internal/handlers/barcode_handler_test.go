@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/service"
+	"userclient/internal/websocket"
+)
+
+// benchHandler 创建运行中的 Hub 及处理器，日志丢弃输出；withStore 为 true 时入库到临时 SQLite 数据库
+func benchHandler(b *testing.B, withStore bool) *BarcodeHandler {
+	b.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	hub := websocket.NewHub(&config.WebSocketConfig{}, logger)
+	go hub.Run()
+	b.Cleanup(hub.Close)
+	if !withStore {
+		return NewBarcodeHandler(hub, nil, nil, logger)
+	}
+
+	db, err := database.New(&config.DatabaseConfig{DSN: filepath.Join(b.TempDir(), "bench.db"), LogLevel: "silent", MaxIdleConns: 1, MaxOpenConns: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(); err != nil {
+		b.Fatal(err)
+	}
+	barcodes := service.NewBarcodeService(db.DB, logger)
+	barcodes.SetDevices(service.NewDeviceService(db.DB, "", logger))
+	return NewBarcodeHandler(hub, barcodes, nil, logger)
+}
+
+// benchmarkHandleBarcode 每次处理内容不同的扫码，避免重复扫码检测影响结果
+func benchmarkHandleBarcode(b *testing.B, withStore bool) {
+	handler := benchHandler(b, withStore)
+	contents := make([]string, b.N)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("SN%010d", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := handler.HandleBarcode(contents[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkHandleBarcode 扫码热路径：识别、入库及广播
+func BenchmarkHandleBarcode(b *testing.B) {
+	benchmarkHandleBarcode(b, true)
+}
+
+// BenchmarkHandleBarcodeNoStore 不入库时的识别及广播
+func BenchmarkHandleBarcodeNoStore(b *testing.B) {
+	benchmarkHandleBarcode(b, false)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import "sync"
+
+// deviceLocks 按设备串行处理扫码
+//
+// 顺序约定：同一设备的扫码按到达处理器的顺序入库（记录ID递增）、写入出站事件（事件ID递增）
+// 并广播（推送序号递增），不同设备之间可并发处理。键盘钩子在组装出完整条码后同步调用处理器，
+// 因此到达顺序即扫码顺序；外部系统并发推送同一设备的扫码时以到达顺序为准。
+type deviceLocks struct {
+	mu    sync.Mutex
+	locks map[uint]*sync.Mutex
+}
+
+// lock 锁定设备并返回解锁函数，deviceID 为nil表示默认设备
+func (l *deviceLocks) lock(deviceID *uint) func() {
+	var key uint
+	if deviceID != nil {
+		key = *deviceID
+	}
+
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[uint]*sync.Mutex)
+	}
+	device, ok := l.locks[key]
+	if !ok {
+		device = &sync.Mutex{}
+		l.locks[key] = device
+	}
+	l.mu.Unlock()
+
+	device.Lock()
+	return device.Unlock
+}
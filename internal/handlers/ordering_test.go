@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+	"userclient/internal/service"
+	"userclient/internal/websocket"
+	"userclient/pkg/barcode"
+)
+
+// orderingRecorder 按调用顺序分配记录ID，入库耗时随机，held 中的设备在入库时阻塞直到通道关闭
+type orderingRecorder struct {
+	next    atomic.Uint64
+	held    map[uint]chan struct{}
+	entered chan uint // 进入入库的设备
+}
+
+func (r *orderingRecorder) RecordScan(content string, scan service.ScanInfo) (*models.BarcodeRecord, error) {
+	id := r.next.Add(1)
+	if r.entered != nil {
+		r.entered <- *scan.DeviceID
+	}
+	if release, ok := r.held[*scan.DeviceID]; ok {
+		<-release
+	}
+	time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+	return &models.BarcodeRecord{ID: uint(id), Content: content, DeviceID: scan.DeviceID, Type: "CODE128"}, nil
+}
+
+// broadcastLog 按推送序号记录各设备广播的扫码
+type broadcastLog struct {
+	mu      sync.Mutex
+	records map[uint][]uint
+	seqs    map[uint][]uint64
+}
+
+// newOrderingHandler 创建使用 recorder 的处理器，返回按序号记录广播的日志
+func newOrderingHandler(recorder BarcodeRecorder) (*BarcodeHandler, *broadcastLog) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	hub := websocket.NewHub(&config.WebSocketConfig{}, logger)
+	log := &broadcastLog{records: make(map[uint][]uint), seqs: make(map[uint][]uint64)}
+	// 未运行 Hub，广播通道满后消息被丢弃，回调仍按序号调用
+	hub.OnBroadcast(func(msgType string, message *websocket.Message, data []byte) {
+		scan, ok := message.Data.(*barcode.BarcodeData)
+		if msgType != "barcode" || !ok {
+			return
+		}
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		log.records[*scan.DeviceID] = append(log.records[*scan.DeviceID], scan.RecordID)
+		log.seqs[*scan.DeviceID] = append(log.seqs[*scan.DeviceID], message.Seq)
+	})
+	return NewBarcodeHandler(hub, recorder, nil, logger), log
+}
+
+func (l *broadcastLog) device(id uint) ([]uint, []uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]uint(nil), l.records[id]...), append([]uint64(nil), l.seqs[id]...)
+}
+
+// TestDeviceOrderingStress 每台设备多个来源并发推送扫码，同一设备的记录ID按推送序号递增，扫码不丢失
+func TestDeviceOrderingStress(t *testing.T) {
+	const devices, submitters, scans = 4, 4, 50
+	handler, log := newOrderingHandler(&orderingRecorder{})
+
+	var wg sync.WaitGroup
+	for d := uint(1); d <= devices; d++ {
+		for s := 0; s < submitters; s++ {
+			wg.Add(1)
+			go func(deviceID uint, submitter int) {
+				defer wg.Done()
+				for i := 0; i < scans; i++ {
+					id := deviceID
+					content := fmt.Sprintf("D%d-S%d-%04d", deviceID, submitter, i)
+					if _, err := handler.Ingest(content, service.ScanInfo{DeviceID: &id}); err != nil {
+						t.Errorf("ingest %s: %v", content, err)
+						return
+					}
+				}
+			}(d, s)
+		}
+	}
+	wg.Wait()
+
+	for d := uint(1); d <= devices; d++ {
+		records, seqs := log.device(d)
+		if len(records) != submitters*scans {
+			t.Fatalf("device %d: %d broadcasts, want %d", d, len(records), submitters*scans)
+		}
+		for i := 1; i < len(records); i++ {
+			if seqs[i] <= seqs[i-1] || records[i] <= records[i-1] {
+				t.Fatalf("device %d: record %d (seq %d) broadcast after record %d (seq %d)",
+					d, records[i], seqs[i], records[i-1], seqs[i-1])
+			}
+		}
+	}
+}
+
+// TestDeviceOrderingIndependent 一台设备入库阻塞时，该设备后到的扫码等待，其他设备不受影响
+func TestDeviceOrderingIndependent(t *testing.T) {
+	release := make(chan struct{})
+	recorder := &orderingRecorder{held: map[uint]chan struct{}{1: release}, entered: make(chan uint, 4)}
+	handler, log := newOrderingHandler(recorder)
+	ingest := func(deviceID uint, content string) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := handler.Ingest(content, service.ScanInfo{DeviceID: &deviceID})
+			done <- err
+		}()
+		return done
+	}
+
+	first := ingest(1, "D1-FIRST")
+	if got := <-recorder.entered; got != 1 {
+		t.Fatalf("entered device %d, want 1", got)
+	}
+	second := ingest(1, "D1-SECOND")
+
+	// 其他设备不等待
+	select {
+	case err := <-ingest(2, "D2-ONLY"):
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("device 2 blocked by device 1")
+	}
+	if got := <-recorder.entered; got != 2 {
+		t.Fatalf("entered device %d, want 2 before the second device 1 scan", got)
+	}
+
+	// 同一设备后到的扫码在前一次推送前不入库
+	select {
+	case got := <-recorder.entered:
+		t.Fatalf("device %d entered while device 1 was held", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	for _, done := range []<-chan error{first, second} {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if records, _ := log.device(1); len(records) != 2 || records[0] >= records[1] {
+		t.Fatalf("device 1 records = %v, want two in ID order", records)
+	}
+}
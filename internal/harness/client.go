@@ -0,0 +1,81 @@
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+)
+
+// Client 模拟看板的WebSocket客户端
+type Client struct {
+	conn *gorilla.Conn
+}
+
+// ClientMessage 客户端收到的消息
+type ClientMessage struct {
+	Type string          `json:"type"`
+	Seq  uint64          `json:"seq"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Connect 连接WebSocket，query 为附加的查询参数（如 "since=3"），可为空
+func (h *Harness) Connect(query string) (*Client, error) {
+	url := "ws" + strings.TrimPrefix(h.URL, "http") + "/ws"
+	if query != "" {
+		url += "?" + query
+	}
+	conn, _, err := gorilla.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("连接WebSocket失败: %w", err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Next 读取下一条消息
+func (c *Client) Next(timeout time.Duration) (*ClientMessage, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	var message ClientMessage
+	if err := c.conn.ReadJSON(&message); err != nil {
+		return nil, fmt.Errorf("读取WebSocket消息失败: %w", err)
+	}
+	return &message, nil
+}
+
+// WaitFor 读取消息直到收到指定类型的消息，跳过其他类型
+func (c *Client) WaitFor(msgType string, timeout time.Duration) (*ClientMessage, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("等待 %s 消息超时", msgType)
+		}
+		message, err := c.Next(remaining)
+		if err != nil {
+			return nil, err
+		}
+		if message.Type == msgType {
+			return message, nil
+		}
+	}
+}
+
+// CloseCode 等待服务端关闭连接，返回关闭码
+func (c *Client) CloseCode(timeout time.Duration) (int, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			if closeErr, ok := err.(*gorilla.CloseError); ok {
+				return closeErr.Code, nil
+			}
+			return 0, err
+		}
+	}
+}
+
+// Close 断开连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
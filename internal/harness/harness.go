@@ -0,0 +1,409 @@
+// Package harness 不依赖键盘钩子的全流程测试环境
+//
+// 以内存数据库启动应用程序管理器，装配与正式运行相同；键盘钩子关闭，改为注册由脚本驱动的模拟采集来源，
+// 扫码经扫码缓冲交给条码处理器，与键盘钩子交出的扫码走同一路径。提供数据库记录、推送事件、
+// 监控指标及状态接口的检查方法，不依赖Windows。
+package harness
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/app"
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+	"userclient/internal/scanner"
+)
+
+// instances 内存数据库序号，每个测试环境使用独立的数据库
+var instances int64
+
+// Options 测试环境选项
+type Options struct {
+	Logger    *logrus.Logger
+	Configure func(cfg *config.Config) // 在管理器创建前修改默认配置
+	APIOnly   bool                     // 不注册模拟采集来源，以仅API模式运行，扫码只能经接口推送
+}
+
+// Event 捕获的推送事件
+type Event struct {
+	Type string
+	Seq  uint64
+	Data json.RawMessage
+}
+
+// Harness 全流程测试环境
+type Harness struct {
+	Config  *config.Config
+	Logger  *logrus.Logger
+	Manager *app.Manager
+	DB      *database.DB // 测试自己的数据库连接，管理器停止后仍可查询
+	URL     string       // HTTP服务地址
+
+	source  *scriptSource
+	monitor *Client   // 捕获推送事件的WebSocket客户端
+	keep    *sql.Conn // 保持内存数据库，管理器关闭数据库后记录仍在
+
+	mu      sync.Mutex
+	events  []Event
+	waiters []chan struct{}
+	stopped bool
+	closed  bool
+}
+
+// New 使用默认配置及内存数据库启动应用程序管理器
+func New(opts Options) (*Harness, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrus.New()
+		logger.SetOutput(io.Discard)
+	}
+
+	cfg, rejections := config.Parse(nil)
+	if len(rejections) > 0 {
+		return nil, fmt.Errorf("默认配置无效: %v", rejections)
+	}
+	cfg.App.Provisioned = true
+	cfg.Server.Port = 0
+	cfg.Server.PortFallbackRange = 0
+	cfg.Scanner.EnableHook = false
+	cfg.Database.DSN = fmt.Sprintf("file:harness%d?mode=memory&cache=shared", atomic.AddInt64(&instances, 1))
+	cfg.Database.LogLevel = "silent"
+	cfg.Database.Secondary.Enable = false
+	cfg.Peers.Enable = false
+	cfg.Sinks.File.Enable = false
+	if opts.Configure != nil {
+		opts.Configure(cfg)
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	keep, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接内存数据库失败: %w", err)
+	}
+
+	h := &Harness{Config: cfg, Logger: logger, DB: db, keep: keep}
+	appOpts := app.Options{Config: cfg, Logger: logger}
+	if !opts.APIOnly {
+		appOpts.Sources = func(handler scanner.ScanHandler) []scanner.ScannerSource {
+			h.source = newScriptSource(handler)
+			return []scanner.ScannerSource{h.source}
+		}
+	}
+	h.Manager, err = app.NewWithOptions(appOpts)
+	if err != nil {
+		h.closeDB()
+		return nil, err
+	}
+	if err := h.Manager.Start(); err != nil {
+		h.Manager.Stop()
+		h.closeDB()
+		return nil, err
+	}
+	h.URL = fmt.Sprintf("http://127.0.0.1:%d", cfg.Server.Port)
+
+	// 收到欢迎消息说明已加入广播，之后的推送事件都能捕获；启用认证时以管理员密钥连接
+	monitorQuery := ""
+	if cfg.Security.EnableAuth {
+		monitorQuery = "token=" + url.QueryEscape(cfg.Security.APIKey)
+	}
+	h.monitor, err = h.Connect(monitorQuery)
+	if err == nil {
+		_, err = h.monitor.WaitFor("welcome", 5*time.Second)
+	}
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+	go h.capture()
+	return h, nil
+}
+
+// capture 记录推送事件并唤醒等待者，连接关闭后返回
+func (h *Harness) capture() {
+	for {
+		var message ClientMessage
+		h.monitor.conn.SetReadDeadline(time.Time{})
+		if err := h.monitor.conn.ReadJSON(&message); err != nil {
+			return
+		}
+		h.mu.Lock()
+		h.events = append(h.events, Event{Type: message.Type, Seq: message.Seq, Data: message.Data})
+		for _, waiter := range h.waiters {
+			close(waiter)
+		}
+		h.waiters = nil
+		h.mu.Unlock()
+	}
+}
+
+// Play 按脚本模拟扫码，扫码交给扫码缓冲后即返回，不等待入库
+func (h *Harness) Play(script Script) error {
+	if h.source == nil {
+		return errors.New("仅API模式下没有模拟采集来源")
+	}
+	for _, scan := range script {
+		if scan.Delay > 0 {
+			time.Sleep(scan.Delay)
+		}
+		if err := h.source.deliver(scan); err != nil {
+			return fmt.Errorf("模拟扫码 %s 失败: %w", scan.Content, err)
+		}
+	}
+	return nil
+}
+
+// Pause 通过接口进入维护模式，期间扫码被忽略
+func (h *Harness) Pause(reason string) error {
+	status, err := h.PostJSON("/api/maintenance/start", map[string]string{"reason": reason}, nil)
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("进入维护模式返回 %d", status)
+	}
+	return err
+}
+
+// Resume 通过接口退出维护模式
+func (h *Harness) Resume() error {
+	status, err := h.PostJSON("/api/maintenance/end", nil, nil)
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("退出维护模式返回 %d", status)
+	}
+	return err
+}
+
+// Events 已捕获的推送事件，msgType为空时返回全部
+func (h *Harness) Events(msgType string) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var events []Event
+	for _, event := range h.events {
+		if msgType == "" || event.Type == msgType {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// WaitForEvents 等待捕获到至少count个指定类型的事件
+func (h *Harness) WaitForEvents(msgType string, count int, timeout time.Duration) ([]Event, error) {
+	deadline := time.After(timeout)
+	for {
+		h.mu.Lock()
+		wake := make(chan struct{})
+		h.waiters = append(h.waiters, wake)
+		h.mu.Unlock()
+
+		if events := h.Events(msgType); len(events) >= count {
+			return events, nil
+		}
+		select {
+		case <-wake:
+		case <-deadline:
+			return h.Events(msgType), fmt.Errorf("等待 %d 个 %s 事件超时，已收到 %d 个", count, msgType, len(h.Events(msgType)))
+		}
+	}
+}
+
+// Poll 每隔10ms检查一次，直到 check 返回 true、出错或超时
+func (h *Harness) Poll(timeout time.Duration, check func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := check()
+		if err != nil || ok {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("等待 %v 后条件仍不满足", timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Records 数据库中的扫码记录，按写入顺序
+func (h *Harness) Records() ([]*models.BarcodeRecord, error) {
+	var records []*models.BarcodeRecord
+	if err := h.DB.Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// CountRecords 指定状态的扫码记录数，status为空时统计全部
+func (h *Harness) CountRecords(status string) (int64, error) {
+	query := h.DB.Model(&models.BarcodeRecord{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("统计扫码记录失败: %w", err)
+	}
+	return count, nil
+}
+
+// Metric 从 /metrics 读取指标，有多个样本（标签不同）时返回各样本之和；指标不存在时返回错误
+func (h *Harness) Metric(name string) (float64, error) {
+	req, err := http.NewRequest(http.MethodGet, h.URL+"/metrics", nil)
+	if err != nil {
+		return 0, err
+	}
+	if key := h.adminKey(); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求 /metrics 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var sum float64
+	found := false
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		line := lines.Text()
+		if !strings.HasPrefix(line, name) {
+			continue
+		}
+		series, value, ok := strings.Cut(line, " ")
+		if !ok || (series != name && !strings.HasPrefix(series, name+"{")) {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("指标 %s 的值无效: %w", name, err)
+		}
+		sum += v
+		found = true
+	}
+	if err := lines.Err(); err != nil {
+		return 0, fmt.Errorf("读取 /metrics 失败: %w", err)
+	}
+	if !found {
+		return 0, fmt.Errorf("指标 %s 不存在", name)
+	}
+	return sum, nil
+}
+
+// GetJSON 请求HTTP接口并解析响应，返回状态码；启用认证时以管理员密钥请求
+func (h *Harness) GetJSON(path string, out interface{}) (int, error) {
+	return h.RequestJSON(http.MethodGet, path, h.adminKey(), nil, out)
+}
+
+// PostJSON 以JSON请求体（可为nil）调用HTTP接口并解析响应，返回状态码；启用认证时以管理员密钥请求
+func (h *Harness) PostJSON(path string, body, out interface{}) (int, error) {
+	return h.RequestJSON(http.MethodPost, path, h.adminKey(), body, out)
+}
+
+// RequestJSON 以指定的认证凭据（为空时不认证）及JSON请求体（可为nil）调用HTTP接口并解析响应，返回状态码
+func (h *Harness) RequestJSON(method, path, credential string, body, out interface{}) (int, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, h.URL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if credential != "" {
+		req.Header.Set("Authorization", "Bearer "+credential)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求 %s 失败: %w", path, err)
+	}
+	return decodeResponse(path, resp, out)
+}
+
+// adminKey 启用认证时的管理员密钥
+func (h *Harness) adminKey() string {
+	if !h.Config.Security.EnableAuth {
+		return ""
+	}
+	return h.Config.Security.APIKey
+}
+
+// decodeResponse 解析响应并关闭，out 为nil时丢弃响应体
+func decodeResponse(path string, resp *http.Response, out interface{}) (int, error) {
+	defer resp.Body.Close()
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("解析 %s 响应失败: %w", path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// Stop 停止应用程序管理器：停止采集来源，处理完扫码缓冲中的扫码后关闭数据库；可重复调用
+//
+// 停止后仍可通过 Records、CountRecords 查询管理器写入的记录。
+func (h *Harness) Stop() error {
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		return nil
+	}
+	h.stopped = true
+	h.mu.Unlock()
+	return h.Manager.Stop()
+}
+
+// Close 停止管理器并关闭测试自己的数据库连接
+func (h *Harness) Close() error {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closed = true
+	h.mu.Unlock()
+
+	err := h.Stop()
+	if h.monitor != nil {
+		h.monitor.Close()
+	}
+	if closeErr := h.closeDB(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// closeDB 关闭测试自己的数据库连接，内存数据库随之释放
+func (h *Harness) closeDB() error {
+	h.keep.Close()
+	return h.DB.Close()
+}
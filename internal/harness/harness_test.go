@@ -0,0 +1,807 @@
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+	"userclient/internal/websocket"
+)
+
+const waitTimeout = 5 * time.Second
+
+func TestMain(m *testing.M) {
+	// 数据库连接及迁移使用全局日志
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// start 启动测试环境，测试结束时关闭
+func start(t *testing.T) *Harness {
+	t.Helper()
+	h, err := New(Options{})
+	if err != nil {
+		t.Fatalf("启动测试环境失败: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+// play 解析并播放扫码脚本
+func play(t *testing.T, h *Harness, text string) {
+	t.Helper()
+	script, err := ParseScript(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Play(script); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// barcodeEvents 等待 count 个扫码推送并解析
+func barcodeEvents(t *testing.T, h *Harness, count int) []map[string]interface{} {
+	t.Helper()
+	events, err := h.WaitForEvents("barcode", count, waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		if err := json.Unmarshal(event.Data, &data[i]); err != nil {
+			t.Fatalf("解析扫码推送失败: %v", err)
+		}
+	}
+	return data
+}
+
+// metric 读取指标，不存在时测试失败
+func metric(t *testing.T, h *Harness, name string) float64 {
+	t.Helper()
+	value, err := h.Metric(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestValidScan(t *testing.T) {
+	h := start(t)
+	play(t, h, "0s 35ms 6901234567892\n")
+
+	events := barcodeEvents(t, h, 1)
+	if events[0]["content"] != "6901234567892" || events[0]["status"] != "success" {
+		t.Fatalf("推送 = %v", events[0])
+	}
+	if events[0]["record_id"] == nil {
+		t.Error("推送缺少 record_id")
+	}
+
+	records, err := h.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Content != "6901234567892" || records[0].Input != ScriptInput {
+		t.Fatalf("扫码记录 = %+v", records)
+	}
+	if got := metric(t, h, "barcode_scans_total"); got != 1 {
+		t.Errorf("barcode_scans_total = %v, want 1", got)
+	}
+
+	var scanner struct {
+		Sources []struct {
+			Name  string `json:"name"`
+			State string `json:"state"`
+		} `json:"sources"`
+	}
+	if status, err := h.GetJSON("/api/scanner/status", &scanner); err != nil || status != 200 {
+		t.Fatalf("GET /api/scanner/status = %d, %v", status, err)
+	}
+	if len(scanner.Sources) != 1 || scanner.Sources[0].Name != ScriptInput || scanner.Sources[0].State != "running" {
+		t.Errorf("扫码状态 = %+v", scanner)
+	}
+}
+
+func TestInvalidScan(t *testing.T) {
+	h := start(t)
+	play(t, h, "0s BAD*CODE\n")
+
+	events := barcodeEvents(t, h, 1)
+	if events[0]["status"] != "error" || !strings.Contains(events[0]["message"].(string), "条码格式无效") {
+		t.Fatalf("推送 = %v", events[0])
+	}
+	if count, err := h.CountRecords(""); err != nil || count != 0 {
+		t.Fatalf("扫码记录数 = %d, %v, want 0", count, err)
+	}
+	if got := metric(t, h, "barcode_scan_errors_total"); got != 1 {
+		t.Errorf("barcode_scan_errors_total = %v, want 1", got)
+	}
+}
+
+func TestDuplicateScan(t *testing.T) {
+	h := start(t)
+	play(t, h, `
+0s   SN000101
+50ms SN000101
+`)
+
+	events := barcodeEvents(t, h, 2)
+	if events[0]["record_id"] == events[1]["record_id"] {
+		t.Fatalf("重复扫码应各自入库，推送 = %v", events)
+	}
+	records, err := h.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Content != records[1].Content {
+		t.Fatalf("扫码记录 = %+v", records)
+	}
+
+	var stats struct {
+		TotalCount int64 `json:"total_count"`
+	}
+	if status, err := h.GetJSON("/api/stats", &stats); err != nil || status != 200 {
+		t.Fatalf("GET /api/stats = %d, %v", status, err)
+	}
+	if stats.TotalCount != 2 {
+		t.Errorf("total_count = %d, want 2", stats.TotalCount)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	h := start(t)
+	if err := h.Pause("换线"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.WaitForEvents("maintenance", 1, waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if got := metric(t, h, "barcode_maintenance_active"); got != 1 {
+		t.Errorf("barcode_maintenance_active = %v, want 1", got)
+	}
+
+	play(t, h, "0s SN000201\n")
+	err := h.Poll(waitTimeout, func() (bool, error) {
+		var state struct {
+			IgnoredScans int64 `json:"ignored_scans"`
+		}
+		_, err := h.GetJSON("/api/maintenance", &state)
+		return state.IgnoredScans == 1, err
+	})
+	if err != nil {
+		t.Fatalf("维护模式下的扫码未被忽略: %v", err)
+	}
+
+	if err := h.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	play(t, h, "0s SN000202\n")
+	events := barcodeEvents(t, h, 1)
+	if events[0]["content"] != "SN000202" {
+		t.Fatalf("推送 = %v", events[0])
+	}
+	records, err := h.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Content != "SN000202" {
+		t.Fatalf("扫码记录 = %+v", records)
+	}
+	if got := metric(t, h, "barcode_maintenance_active"); got != 0 {
+		t.Errorf("barcode_maintenance_active = %v, want 0", got)
+	}
+}
+
+func TestShutdownFlush(t *testing.T) {
+	h := start(t)
+	var script Script
+	for i := 0; i < 200; i++ {
+		script = append(script, Scan{Content: fmt.Sprintf("SN%06d", i)})
+	}
+	if err := h.Play(script); err != nil {
+		t.Fatal(err)
+	}
+
+	// 停止时扫码缓冲中尚未处理的扫码全部入库后才关闭数据库
+	if err := h.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := h.CountRecords(""); err != nil || count != int64(len(script)) {
+		t.Fatalf("停止后扫码记录数 = %d, %v, want %d", count, err, len(script))
+	}
+	if err := h.Play(script[:1]); err == nil {
+		t.Error("停止后仍可模拟扫码")
+	}
+}
+
+// TestSameMillisecondBurst 同一毫秒内推送的扫码：入库、推送保留微秒，按时间分页时以ID兜底，翻页不重复不遗漏
+func TestSameMillisecondBurst(t *testing.T) {
+	h, err := New(Options{Configure: func(cfg *config.Config) {
+		cfg.Ingest.Enable = true
+		cfg.Integrity.Enable = true
+	}})
+	if err != nil {
+		t.Fatalf("启动测试环境失败: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	// 偶数条时间完全相同，奇数条在同一毫秒内相差若干微秒
+	base := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	const count = 20
+	want := make(map[string]time.Time, count)
+	var batch []map[string]interface{}
+	for i := 0; i < count; i++ {
+		at := base
+		if i%2 == 1 {
+			at = base.Add(time.Duration(i) * time.Microsecond)
+		}
+		content := fmt.Sprintf("SN%06d", 300+i)
+		want[content] = at
+		batch = append(batch, map[string]interface{}{
+			"event_id": fmt.Sprintf("burst-%d", i),
+			"type":     "barcode",
+			"data":     map[string]interface{}{"content": content, "created_at": at.Format(time.RFC3339Nano)},
+		})
+	}
+	var result struct {
+		Summary map[string]int `json:"summary"`
+	}
+	if status, err := h.PostJSON("/api/ingest", batch, &result); err != nil || status != 200 || result.Summary["created"] != count {
+		t.Fatalf("POST /api/ingest = %d, %v, %v", status, err, result.Summary)
+	}
+
+	// 推送的扫码时间与入库时间一致，不截断到毫秒
+	for _, event := range barcodeEvents(t, h, count) {
+		content := event["content"].(string)
+		at, err := time.Parse(time.RFC3339Nano, event["timestamp"].(string))
+		if err != nil || !at.Equal(want[content]) {
+			t.Errorf("%s 推送的扫码时间 = %v, %v, want %v", content, event["timestamp"], err, want[content])
+		}
+	}
+
+	type row struct {
+		ID        uint      `json:"id"`
+		Content   string    `json:"content"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	var rows []row
+	for page := 1; page <= 3; page++ {
+		var resp struct {
+			Data  []row `json:"data"`
+			Total int   `json:"total"`
+		}
+		path := fmt.Sprintf("/api/barcodes?sort=created_at&page_size=7&page=%d", page)
+		if status, err := h.GetJSON(path, &resp); err != nil || status != 200 || resp.Total != count {
+			t.Fatalf("GET %s = %d, %v, total %d", path, status, err, resp.Total)
+		}
+		rows = append(rows, resp.Data...)
+	}
+	if len(rows) != count {
+		t.Fatalf("分页共 %d 条, want %d", len(rows), count)
+	}
+	seen := make(map[uint]bool, count)
+	for i, r := range rows {
+		if seen[r.ID] {
+			t.Fatalf("记录 %d 在分页中重复出现", r.ID)
+		}
+		seen[r.ID] = true
+		if !r.CreatedAt.Equal(want[r.Content]) {
+			t.Errorf("%s 入库时间 = %v, want %v", r.Content, r.CreatedAt, want[r.Content])
+		}
+		if i > 0 {
+			prev := rows[i-1]
+			if r.CreatedAt.Before(prev.CreatedAt) || r.CreatedAt.Equal(prev.CreatedAt) && r.ID < prev.ID {
+				t.Fatalf("分页顺序错误: %+v 排在 %+v 之后", r, prev)
+			}
+		}
+	}
+
+	var verify struct {
+		Data struct {
+			Valid   bool `json:"valid"`
+			Checked int  `json:"checked"`
+		} `json:"data"`
+	}
+	if status, err := h.PostJSON("/api/admin/verify-integrity", nil, &verify); err != nil || status != 200 || !verify.Data.Valid || verify.Data.Checked != count {
+		t.Fatalf("POST /api/admin/verify-integrity = %d, %v, %+v", status, err, verify.Data)
+	}
+}
+
+// TestTokenScopes 受限令牌的权限范围：只读令牌不能写入、不能订阅；设备令牌在REST查询及WebSocket推送中只能看到范围内的设备
+func TestTokenScopes(t *testing.T) {
+	h, err := New(Options{Configure: func(cfg *config.Config) {
+		cfg.Security.EnableAuth = true
+		cfg.Security.APIKey = "harness-admin-key"
+		cfg.Ingest.Enable = true
+	}})
+	if err != nil {
+		t.Fatalf("启动测试环境失败: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	lineA := &models.Device{Name: "A线扫码枪", SerialNo: "SCAN-A", Station: "line-a"}
+	lineB := &models.Device{Name: "B线扫码枪", SerialNo: "SCAN-B", Station: "line-b"}
+	for _, device := range []*models.Device{lineA, lineB} {
+		if err := h.DB.Create(device).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	createToken := func(scopes []string, devices []uint) string {
+		t.Helper()
+		var resp struct {
+			Token string `json:"token"`
+		}
+		body := map[string]interface{}{"name": fmt.Sprintf("%v%v", scopes, devices), "scopes": scopes, "devices": devices}
+		if status, err := h.PostJSON("/api/tokens", body, &resp); err != nil || status != 201 {
+			t.Fatalf("创建令牌 = %d, %v", status, err)
+		}
+		return resp.Token
+	}
+	readAll := createToken([]string{models.TokenScopeRead}, nil)
+	readA := createToken([]string{models.TokenScopeRead}, []uint{lineA.ID})
+	subscribeA := createToken([]string{models.TokenScopeWSSubscribe}, []uint{lineA.ID})
+
+	ingest := func(serial, content string) {
+		t.Helper()
+		event := map[string]interface{}{
+			"event_id": content,
+			"type":     "barcode",
+			"data":     map[string]interface{}{"content": content, "device": map[string]interface{}{"serial_no": serial}},
+		}
+		if status, err := h.PostJSON("/api/ingest", event, nil); err != nil || status != 201 && status != 200 {
+			t.Fatalf("推送 %s = %d, %v", content, status, err)
+		}
+	}
+
+	// REST
+	rest := []struct {
+		name       string
+		method     string
+		path       string
+		credential string
+		status     int
+	}{
+		{"未认证", "GET", "/api/barcodes", "", 401},
+		{"只读令牌查询", "GET", "/api/barcodes", readAll, 200},
+		{"只读令牌写入", "POST", "/api/barcodes/ack", readAll, 403},
+		{"只读令牌访问管理接口", "GET", "/api/admin/flags", readAll, 403},
+		{"只读令牌推送扫码", "POST", "/api/ingest", readAll, 403},
+		{"设备令牌查询范围内设备", "GET", fmt.Sprintf("/api/barcodes?device_id=%d", lineA.ID), readA, 200},
+		{"设备令牌查询范围外设备", "GET", fmt.Sprintf("/api/barcodes?device_id=%d", lineB.ID), readA, 403},
+		{"订阅令牌查询", "GET", "/api/barcodes", subscribeA, 403},
+	}
+	for _, tt := range rest {
+		status, err := h.RequestJSON(tt.method, tt.path, tt.credential, nil, nil)
+		if err != nil || status != tt.status {
+			t.Errorf("%s: %s %s = %d, %v, want %d", tt.name, tt.method, tt.path, status, err, tt.status)
+		}
+	}
+
+	// WebSocket：无订阅权限、订阅范围外的设备时以策略关闭码拒绝
+	rejected := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{"未认证", "", websocket.CloseUnauthorized},
+		{"只读令牌", "token=" + readAll, websocket.ClosePolicyViolation},
+		{"订阅范围外设备", fmt.Sprintf("token=%s&devices=%d", subscribeA, lineB.ID), websocket.ClosePolicyViolation},
+	}
+	for _, tt := range rejected {
+		client, err := h.Connect(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		code, err := client.CloseCode(waitTimeout)
+		client.Close()
+		if err != nil || code != tt.code {
+			t.Errorf("%s: 关闭码 = %d, %v, want %d", tt.name, code, err, tt.code)
+		}
+	}
+
+	client, err := h.Connect("token=" + subscribeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.WaitFor("welcome", waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	ingest("SCAN-B", "SN000401")
+	ingest("SCAN-A", "SN000402")
+	message, err := client.WaitFor("barcode", waitTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pushed struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(message.Data, &pushed); err != nil || pushed.Content != "SN000402" {
+		t.Fatalf("设备令牌收到的推送 = %s, %v, want 仅 SCAN-A 的 SN000402", message.Data, err)
+	}
+
+	// 设备令牌不指定设备时只查到范围内设备的记录
+	var records struct {
+		Data []struct {
+			Content string `json:"content"`
+		} `json:"data"`
+	}
+	if status, err := h.RequestJSON("GET", "/api/barcodes", readA, nil, &records); err != nil || status != 200 {
+		t.Fatalf("GET /api/barcodes = %d, %v", status, err)
+	}
+	if len(records.Data) != 1 || records.Data[0].Content != "SN000402" {
+		t.Fatalf("设备令牌查到的记录 = %+v", records.Data)
+	}
+}
+
+// TestReadOnlyServesQueries 只读模式下打开已有的数据库副本：统计、列表、导出及WebSocket订阅照常可用
+func TestReadOnlyServesQueries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "station.db")
+	db, err := database.New(&config.DatabaseConfig{DSN: path, LogLevel: "silent", MaxIdleConns: 1, MaxOpenConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		content := fmt.Sprintf("SN%06d", 500+i)
+		record := &models.BarcodeRecord{Content: content, Length: len(content), Type: "code128", Status: "success", PublicID: models.NewPublicID()}
+		if err := db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	h, err := New(Options{Configure: func(cfg *config.Config) {
+		cfg.App.ReadOnly = true
+		cfg.Database.DSN = path
+	}})
+	if err != nil {
+		t.Fatalf("启动测试环境失败: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	var status struct {
+		ReadOnly bool `json:"read_only"`
+	}
+	if code, err := h.GetJSON("/api/status", &status); err != nil || code != 200 || !status.ReadOnly {
+		t.Fatalf("GET /api/status = %d, %v, read_only %v", code, err, status.ReadOnly)
+	}
+	var stats struct {
+		TotalCount int64 `json:"total_count"`
+	}
+	if code, err := h.GetJSON("/api/stats", &stats); err != nil || code != 200 || stats.TotalCount != 3 {
+		t.Fatalf("GET /api/stats = %d, %v, total_count %d", code, err, stats.TotalCount)
+	}
+	var list struct {
+		Data  []map[string]interface{} `json:"data"`
+		Total int64                    `json:"total"`
+	}
+	if code, err := h.GetJSON("/api/barcodes?page_size=2&page=2", &list); err != nil || code != 200 || list.Total != 3 || len(list.Data) != 1 {
+		t.Fatalf("GET /api/barcodes = %d, %v, total %d, %d 条", code, err, list.Total, len(list.Data))
+	}
+	// 导出：诊断包包含扫码记录
+	if code, err := h.GetJSON("/api/admin/support-bundle?include_barcodes=true", nil); err != nil || code != 200 {
+		t.Fatalf("GET /api/admin/support-bundle = %d, %v", code, err)
+	}
+
+	// 订阅：能加入广播并收到欢迎消息
+	client, err := h.Connect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.WaitFor("welcome", waitTimeout); err != nil {
+		t.Fatalf("只读模式下订阅失败: %v", err)
+	}
+
+	// 修改类接口仍被拒绝，数据库未被修改
+	if code, err := h.PostJSON("/api/barcodes/submit", map[string]string{"barcode": "SN000599"}, nil); err != nil || code != 403 {
+		t.Fatalf("POST /api/barcodes/submit = %d, %v, want 403", code, err)
+	}
+	if count, err := h.CountRecords(""); err != nil || count != 3 {
+		t.Fatalf("扫码记录数 = %d, %v, want 3", count, err)
+	}
+}
+
+// TestStartPortOccupied 端口被占用时启动失败并说明原因；配置了备用端口时跳过被占用的端口，对外显示实际端口
+func TestStartPortOccupied(t *testing.T) {
+	occupied, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer occupied.Close()
+	port := occupied.Addr().(*net.TCPAddr).Port
+
+	began := time.Now()
+	_, err = New(Options{Configure: func(cfg *config.Config) { cfg.Server.Port = port }})
+	// 启动失败时尚未开始处理扫码缓冲，停止时不应等待缓冲处理超时
+	if elapsed := time.Since(began); elapsed > time.Second {
+		t.Errorf("启动失败后停止耗时 %v", elapsed)
+	}
+	if err == nil {
+		t.Fatalf("端口 %d 已被占用，启动应失败", port)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprint(port)) || !strings.Contains(err.Error(), "port_fallback_range") {
+		t.Fatalf("启动失败的原因 = %v", err)
+	}
+	// 启动失败不影响占用端口的进程
+	if conn, err := net.Dial("tcp", occupied.Addr().String()); err != nil {
+		t.Fatalf("占用端口的监听被关闭: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	// 后续一个端口也被占用，改用第二个备用端口
+	next, err := net.Listen("tcp", fmt.Sprintf(":%d", port+1))
+	if err != nil {
+		t.Skipf("端口 %d 不可用: %v", port+1, err)
+	}
+	defer next.Close()
+	h, err := New(Options{Configure: func(cfg *config.Config) {
+		cfg.Server.Port = port
+		cfg.Server.PortFallbackRange = 3
+	}})
+	if err != nil {
+		t.Fatalf("配置备用端口后启动失败: %v", err)
+	}
+	defer h.Close()
+	if h.Config.Server.Port <= port+1 || h.Config.Server.Port > port+3 {
+		t.Fatalf("实际端口 = %d，want %d~%d", h.Config.Server.Port, port+2, port+3)
+	}
+	if code, err := h.GetJSON("/api/status", nil); err != nil || code != 200 {
+		t.Fatalf("GET /api/status = %d, %v", code, err)
+	}
+}
+
+// TestConfigDeleteRecreateRestoreAPI 删除配置后以同一键保存返回409并说明恢复方式，以 restore=true 重新提交后恢复原配置
+func TestConfigDeleteRecreateRestoreAPI(t *testing.T) {
+	h := start(t)
+	path := "/api/configs/dashboard.title"
+	body := map[string]interface{}{"value": "一号线", "category": "ui"}
+	if status, err := h.RequestJSON("PUT", path, h.adminKey(), body, nil); err != nil || status != 200 {
+		t.Fatalf("create: %d %v", status, err)
+	}
+	if status, err := h.RequestJSON("DELETE", path, h.adminKey(), nil, nil); err != nil || status != 200 {
+		t.Fatalf("delete: %d %v", status, err)
+	}
+
+	var conflict struct {
+		Kind      string `json:"kind"`
+		Field     string `json:"field"`
+		DeletedID uint   `json:"deleted_id"`
+		Restore   string `json:"restore"`
+	}
+	body["value"] = "二号线"
+	if status, err := h.RequestJSON("PUT", path, h.adminKey(), body, &conflict); err != nil || status != 409 {
+		t.Fatalf("recreate: %d %v", status, err)
+	}
+	if conflict.Kind != "configuration" || conflict.Field != "key" || conflict.DeletedID == 0 || !strings.Contains(conflict.Restore, path+"/restore") {
+		t.Fatalf("conflict response = %+v", conflict)
+	}
+
+	body["restore"] = true
+	if status, err := h.RequestJSON("PUT", path, h.adminKey(), body, nil); err != nil || status != 200 {
+		t.Fatalf("recreate with restore: %d %v", status, err)
+	}
+	var restored struct {
+		Data []models.Configuration `json:"data"`
+	}
+	if status, err := h.GetJSON("/api/configs?category=ui", &restored); err != nil || status != 200 {
+		t.Fatalf("list: %d %v", status, err)
+	}
+	if len(restored.Data) != 1 || restored.Data[0].ID != conflict.DeletedID || restored.Data[0].Value != "二号线" {
+		t.Fatalf("configs = %+v, want config %d with the new value", restored.Data, conflict.DeletedID)
+	}
+
+	// 未删除的配置不能恢复
+	if status, _ := h.PostJSON(path+"/restore", nil, nil); status != 404 {
+		t.Fatalf("restore of a live config: status %d, want 404", status)
+	}
+}
+
+// TestCoalesceSimulator 模拟扫码按时间播放：不同设备在合并窗口内扫到同一内容合并为一条记录，只推送一次；
+// 超出窗口、同一设备重复扫码、关闭合并的工位及默认设备的扫码各自入库
+func TestCoalesceSimulator(t *testing.T) {
+	h, err := New(Options{Configure: func(cfg *config.Config) {
+		cfg.Simulator.Enable = true
+		cfg.Coalesce = config.CoalesceConfig{
+			Enable:   true,
+			Window:   80 * time.Millisecond,
+			Stations: []config.CoalesceStationConfig{{Station: "lane-2", Window: 0}},
+		}
+	}})
+	if err != nil {
+		t.Fatalf("启动测试环境失败: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	devices := make(map[string]uint)
+	for _, device := range []models.Device{
+		{Name: "固定-1", SerialNo: "FX-1", Station: "lane-1"},
+		{Name: "手持-1", SerialNo: "HS-1", Station: "lane-1", IsActive: true},
+		{Name: "固定-2", SerialNo: "FX-2", Station: "lane-2"},
+		{Name: "手持-2", SerialNo: "HS-2", Station: "lane-2", IsActive: true},
+	} {
+		active := device.IsActive
+		if err := h.DB.Create(&device).Error; err != nil {
+			t.Fatal(err)
+		}
+		// IsActive 的零值按默认值写入，每个工位至多一个活跃设备，先写入的停用后再写入活跃设备
+		if !active {
+			if err := h.DB.Model(&device).Update("is_active", false).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		devices[device.SerialNo] = device.ID
+	}
+
+	scenario := []map[string]string{
+		// 窗口内两台设备扫到同一标签
+		{"content": "SN000201", "delay": "0", "device": "HS-1"},
+		{"content": "SN000201", "delay": "10ms", "device": "FX-1"},
+		// 超出窗口
+		{"content": "SN000202", "delay": "300ms", "device": "HS-1"},
+		{"content": "SN000202", "delay": "200ms", "device": "FX-1"},
+		// 同一设备重复扫码
+		{"content": "SN000203", "delay": "300ms", "device": "HS-1"},
+		{"content": "SN000203", "delay": "10ms", "device": "HS-1"},
+		// lane-2 关闭合并
+		{"content": "SN000204", "delay": "300ms", "device": "HS-2"},
+		{"content": "SN000204", "delay": "10ms", "device": "FX-2"},
+		// 默认设备
+		{"content": "SN000205", "delay": "300ms"},
+		{"content": "SN000205", "delay": "10ms"},
+	}
+	if status, err := h.PostJSON("/api/simulator/scenario?name=coalesce", scenario, nil); err != nil || status != 200 {
+		t.Fatalf("上传场景 = %d, %v", status, err)
+	}
+	if status, err := h.PostJSON("/api/simulator/start", nil, nil); err != nil || status != 200 {
+		t.Fatalf("开始模拟扫码 = %d, %v", status, err)
+	}
+	var simulation struct {
+		Data struct {
+			Running bool  `json:"running"`
+			Emitted int64 `json:"emitted"`
+			Failed  int64 `json:"failed"`
+		} `json:"data"`
+	}
+	err = h.Poll(waitTimeout, func() (bool, error) {
+		_, err := h.GetJSON("/api/simulator/status", &simulation)
+		return !simulation.Data.Running && simulation.Data.Emitted == int64(len(scenario)), err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simulation.Data.Failed != 0 {
+		t.Fatalf("模拟扫码失败 %d 次", simulation.Data.Failed)
+	}
+
+	barcodeEvents(t, h, 9)
+	time.Sleep(100 * time.Millisecond)
+	if events := h.Events("barcode"); len(events) != 9 {
+		t.Fatalf("扫码推送 %d 次，want 9（合并的扫码只推送一次）", len(events))
+	}
+
+	records, err := h.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	perContent := make(map[string][]*models.BarcodeRecord)
+	for _, record := range records {
+		perContent[record.Content] = append(perContent[record.Content], record)
+	}
+	merged := perContent["SN000201"]
+	if len(merged) != 1 || merged[0].DeviceID == nil || *merged[0].DeviceID != devices["HS-1"] ||
+		len(merged[0].SecondaryDevices) != 1 || merged[0].SecondaryDevices[0] != devices["FX-1"] {
+		t.Fatalf("窗口内的扫码未合并: %+v", merged)
+	}
+	for _, content := range []string{"SN000202", "SN000203", "SN000204", "SN000205"} {
+		if got := perContent[content]; len(got) != 2 || len(got[0].SecondaryDevices)+len(got[1].SecondaryDevices) != 0 {
+			t.Errorf("%s 记录 = %+v, want 2 separate records", content, got)
+		}
+	}
+	if got := metric(t, h, "barcode_scans_coalesced_total"); got != 1 {
+		t.Errorf("barcode_scans_coalesced_total = %v, want 1", got)
+	}
+}
+
+// TestAPIOnlyMode 键盘钩子关闭且没有采集来源时以仅API模式运行：就绪检查不考虑键盘钩子，
+// 扫码接口报告 not_configured 而不是错误，扫码经接口推送照常入库
+func TestAPIOnlyMode(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	h, err := New(Options{
+		Logger:  logger,
+		APIOnly: true,
+		Configure: func(cfg *config.Config) {
+			cfg.Ingest.Enable = true
+		},
+	})
+	if err != nil {
+		t.Fatalf("启动测试环境失败: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	logged := func(message string) bool {
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == message {
+				return true
+			}
+		}
+		return false
+	}
+	if !logged("键盘钩子已禁用，以仅API模式运行") || !logged("应用程序启动成功（仅API模式）") {
+		t.Fatal("启动日志未说明以仅API模式运行")
+	}
+
+	var ready struct {
+		Status  string   `json:"status"`
+		Reasons []string `json:"reasons"`
+	}
+	if status, err := h.GetJSON("/readyz", &ready); err != nil || status != 200 || ready.Status != "ready" {
+		t.Fatalf("GET /readyz = %d, %v, %+v", status, err, ready)
+	}
+
+	var system struct {
+		Scanner map[string]interface{} `json:"scanner"`
+	}
+	if status, err := h.GetJSON("/api/status", &system); err != nil || status != 200 {
+		t.Fatalf("GET /api/status = %d, %v", status, err)
+	}
+	if system.Scanner["status"] != "not_configured" || system.Scanner["hook"] != "not_configured" || system.Scanner["metrics"] != nil {
+		t.Fatalf("系统状态 scanner = %v", system.Scanner)
+	}
+
+	var scannerStatus map[string]interface{}
+	if status, err := h.GetJSON("/api/scanner/status", &scannerStatus); err != nil || status != 200 {
+		t.Fatalf("GET /api/scanner/status = %d, %v", status, err)
+	}
+	if scannerStatus["status"] != "not_configured" || scannerStatus["hook"] != "not_configured" ||
+		scannerStatus["hook_stats"] != nil || scannerStatus["buffer"] != nil {
+		t.Fatalf("扫码状态 = %v", scannerStatus)
+	}
+
+	for _, path := range []string{"/api/scanner/stats", "/api/scanner/config"} {
+		var body struct {
+			Status string `json:"status"`
+		}
+		if status, err := h.GetJSON(path, &body); err != nil || status != 200 || body.Status != "not_configured" {
+			t.Fatalf("GET %s = %d, %v, %+v", path, status, err, body)
+		}
+	}
+	if status, err := h.RequestJSON("PUT", "/api/scanner/config", h.adminKey(), map[string]int{"timeout_ms": 80}, nil); err != nil || status != 409 {
+		t.Fatalf("PUT /api/scanner/config = %d, %v, want 409", status, err)
+	}
+
+	// 没有模拟采集来源，扫码只能经接口推送
+	if err := h.Play(Script{{Content: "6901234567892"}}); err == nil {
+		t.Fatal("仅API模式下模拟扫码成功")
+	}
+	event := map[string]interface{}{
+		"event_id": "api-only-1",
+		"type":     "barcode",
+		"data":     map[string]interface{}{"content": "6901234567892"},
+	}
+	if status, err := h.PostJSON("/api/ingest", event, nil); err != nil || status != 201 && status != 200 {
+		t.Fatalf("POST /api/ingest = %d, %v", status, err)
+	}
+	if events := barcodeEvents(t, h, 1); events[0]["content"] != "6901234567892" {
+		t.Fatalf("推送 = %v", events[0])
+	}
+	if count, err := h.CountRecords(""); err != nil || count != 1 {
+		t.Fatalf("扫码记录数 = %d, %v, want 1", count, err)
+	}
+}
@@ -0,0 +1,61 @@
+package harness
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Scan 一次模拟扫码
+type Scan struct {
+	Delay    time.Duration // 距上一次扫码的间隔
+	Duration time.Duration // 扫码耗时（首个按键到最后一个按键）
+	Content  string
+}
+
+// Script 模拟扫码脚本
+type Script []Scan
+
+// ParseScript 解析扫码脚本，每行一次扫码：
+//
+//	<间隔> [耗时] <条码内容>
+//
+// 如 "200ms 6901234567892" 或 "1s 35ms SN000101"；空行及 # 开头的行忽略。
+func ParseScript(r io.Reader) (Script, error) {
+	var script Script
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("第 %d 行格式错误，应为 <间隔> [耗时] <条码内容>", line)
+		}
+		delay, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行间隔无效: %w", line, err)
+		}
+
+		scan := Scan{Delay: delay, Content: fields[1]}
+		if len(fields) > 2 {
+			duration, err := time.ParseDuration(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行耗时无效: %w", line, err)
+			}
+			scan.Duration = duration
+			scan.Content = strings.Join(fields[2:], " ")
+		}
+		script = append(script, scan)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取扫码脚本失败: %w", err)
+	}
+	return script, nil
+}
@@ -0,0 +1,80 @@
+package harness
+
+import (
+	"errors"
+	"sync"
+
+	"userclient/internal/scanner"
+)
+
+// ScriptInput 脚本扫码的采集来源标识，扫码记录的 input 为此值
+const ScriptInput = "script"
+
+// errSourceStopped 模拟采集来源已停止
+var errSourceStopped = errors.New("模拟采集来源已停止")
+
+// scriptSource 由扫码脚本驱动的采集来源，与键盘钩子一样把扫码交给扫码缓冲
+type scriptSource struct {
+	handler scanner.ScanHandler
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+}
+
+// newScriptSource 创建模拟采集来源
+func newScriptSource(handler scanner.ScanHandler) *scriptSource {
+	return &scriptSource{handler: handler, stop: make(chan struct{})}
+}
+
+// Name 实现 ScannerSource
+func (s *scriptSource) Name() string {
+	return ScriptInput
+}
+
+// Install 实现 ScannerSource
+func (s *scriptSource) Install() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = true
+	return nil
+}
+
+// Uninstall 实现 ScannerSource
+func (s *scriptSource) Uninstall() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = false
+}
+
+// Run 实现 ScannerSource，阻塞直到 Stop
+func (s *scriptSource) Run() {
+	<-s.stop
+}
+
+// Stop 实现 ScannerSource，可重复调用
+func (s *scriptSource) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.running = false
+}
+
+// IsRunning 实现 ScannerSource
+func (s *scriptSource) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// deliver 交出一次扫码，来源已停止时返回错误
+func (s *scriptSource) deliver(scan Scan) error {
+	if !s.IsRunning() {
+		return errSourceStopped
+	}
+	return s.handler.HandleScan(scanner.Scan{Barcode: scan.Content, Input: ScriptInput, Duration: scan.Duration})
+}
@@ -0,0 +1,331 @@
+package i18n
+
+// 英语（美国）消息目录
+func init() {
+	register(&Catalog{
+		Locale:    EnUS,
+		Name:      "English (US)",
+		DateTime:  "01/02/2006 3:04:05 PM",
+		Date:      "01/02/2006",
+		Decimal:   ".",
+		Thousands: ",",
+		Messages: map[string]string{
+			"alert.capture_source_unavailable": "Capture source %s is unavailable: %v",
+			"alert.device_idle":                "No scans from device %d for %s",
+			"alert.goal_reached":               "Production goal \"%s\" reached: %s completed %d/%d",
+			"alert.health_below_threshold":     "Device %d health score dropped to %.0f, below the threshold of %.0f",
+			"alert.health_dropped":             "Device %d health score dropped from %.0f to %.0f",
+			"alert.hook_reinstall_failed":      "The keyboard hook stopped working and could not be reinstalled; retrying: %s",
+			"alert.hook_reinstalled":           "The keyboard hook stopped working and was reinstalled (attempt %d): %s",
+			"alert.integrity_failed":           "Scan record integrity check failed: record %d %s",
+			"alert.key_timing_suspect":         "%[2].0f%% of the last %[1]d scans had abnormal key timing; the keyboard hook may be missing keys under high system load",
+			"alert.primary_stopped":            "Primary %s has stopped capturing scans: %s",
+			"alert.quality_degraded":           "Data quality degraded: %s",
+			"alert.setup_code_scanned":         "A scanner setup code (%s) was scanned into the data stream, probably by mistake: %s",
+			"alert.standby_took_over":          "Standby %s has taken over scan capture: %s",
+			"alert.station_idle":               "No scans at this station for %s",
+
+			"api.admin_key_saved_keep_it_safe":             "Admin key saved; keep it safe",
+			"api.administrator_privileges_required":        "Administrator privileges required",
+			"api.annotation_added":                         "Annotation added",
+			"api.backfill_job_not_found":                   "Backfill job not found",
+			"api.backfill_job_paused":                      "Backfill job paused",
+			"api.backfill_job_requeued":                    "Backfill job requeued",
+			"api.backfill_job_state_does_not_allow_this":   "Backfill job state does not allow this operation",
+			"api.background_backfill_is_not_enabled":       "Background backfill is not enabled",
+			"api.bad_request_parameters":                   "Bad request parameters",
+			"api.barcode_image_storage_is_not_enabled":     "Barcode image storage is not enabled",
+			"api.central_server_settings_saved":            "Central server settings saved",
+			"api.central_sync_failed":                      "Central sync failed",
+			"api.central_sync_is_not_enabled":              "Central sync is not enabled",
+			"api.classification_rule_created":              "Classification rule created",
+			"api.classification_rule_deleted":              "Classification rule deleted",
+			"api.classification_rule_not_found":            "Classification rule not found",
+			"api.classification_rule_updated":              "Classification rule updated",
+			"api.client_disconnected":                      "Client disconnected",
+			"api.client_not_found":                         "Client not found",
+			"api.command_is_not_in_the_device_allow_list":  "Command is not in the device allow list",
+			"api.complete_the_setup_steps_in_order":        "Complete the setup steps in order",
+			"api.configuration_deleted":                    "Configuration deleted",
+			"api.configuration_hot_reload_is_not_enabled":  "Configuration hot reload is not enabled",
+			"api.configuration_not_found":                  "Configuration not found",
+			"api.configuration_profile_created":            "Configuration profile created",
+			"api.configuration_profile_deleted":            "Configuration profile deleted",
+			"api.configuration_profile_not_found":          "Configuration profile not found",
+			"api.configuration_profile_updated":            "Configuration profile updated",
+			"api.configuration_restored":                   "Configuration restored",
+			"api.configuration_saved":                      "Configuration saved",
+			"api.configuration_value_is_outside_the_safe":  "Configuration value is outside the safe range",
+			"api.conflicts_with_a_deleted_record":          "Conflicts with a deleted record",
+			"api.connection_test_failed":                   "Connection test failed",
+			"api.consumer_not_registered":                  "Consumer not registered",
+			"api.consumption_acknowledged":                 "Consumption acknowledged",
+			"api.content_from_the_central_server_failed":   "Content from the central server failed validation",
+			"api.counter_can_only_increase":                "This counter can only increase and cannot be reset",
+			"api.counters_reset":                           "Counters reset",
+			"api.counters_rolled_over":                     "Counters rolled over",
+			"api.dashboard_preferences_are_not_enabled":    "Dashboard preferences are not enabled",
+			"api.data_quality_reports_are_not_enabled":     "Data quality reports are not enabled",
+			"api.data_reset":                               "Data reset",
+			"api.data_reset_failed":                        "Data reset failed",
+			"api.data_reset_is_not_allowed_in_production":  "Data reset is not allowed in production",
+			"api.database_connection_test_succeeded":       "Database connection test succeeded",
+			"api.day_closed":                               "Day closed",
+			"api.day_closing_is_not_enabled":               "Day closing is not enabled",
+			"api.deleted_device_not_found":                 "Deleted device not found",
+			"api.device_command_settings_updated":          "Device command settings updated",
+			"api.device_configuration_profile_updated":     "Device configuration profile updated",
+			"api.device_has_no_command_channel_configured": "Device has no command channel configured",
+			"api.device_health_scoring_is_not_enabled":     "Device health scoring is not enabled",
+			"api.device_id_is_invalid":                     "device_id is invalid",
+			"api.device_information_saved":                 "Device information saved",
+			"api.device_key_timeout_updated":               "Device key timeout updated",
+			"api.device_name_is_already_in_use":            "Device name is already in use",
+			"api.device_not_found":                         "Device not found",
+			"api.device_or_configuration_profile_not":      "Device or configuration profile not found",
+			"api.device_path_is_already_in_use":            "Device path is already in use",
+			"api.device_path_updated":                      "Device path updated",
+			"api.device_restored":                          "Device restored",
+			"api.device_scanner_preset_updated_takes":      "Device scanner preset updated; takes effect after a restart",
+			"api.device_scoped_tokens_cannot_acknowledge":  "Device-scoped tokens cannot acknowledge consumption",
+			"api.device_scoped_tokens_cannot_view_per":     "Device-scoped tokens cannot view per-operator statistics",
+			"api.device_scoped_tokens_cannot_view_station": "Device-scoped tokens cannot view station-wide reports",
+			"api.device_scoped_tokens_must_specify_device": "Device-scoped tokens must specify device_id",
+			"api.device_station_updated":                   "Device station updated",
+			"api.distinct_barcode_counting_is_not_enabled": "Distinct barcode counting is not enabled",
+			"api.duration_must_be_a_positive_duration_e_g": "duration must be a positive duration, e.g. 30m",
+			"api.event_received":                           "Event received",
+			"api.expected_format_2006_01_02":               "Expected format 2006-01-02",
+			"api.expires_in_must_be_a_positive_duration_e": "expires_in must be a positive duration, e.g. 720h",
+			"api.external_ingest_is_not_enabled":           "External ingest is not enabled",
+			"api.failed_to_acknowledge_consumption":        "Failed to acknowledge consumption",
+			"api.failed_to_add_annotation":                 "Failed to add annotation",
+			"api.failed_to_assign_device_station":          "Failed to assign device station",
+			"api.failed_to_build_diagnostics_bundle":       "Failed to build diagnostics bundle",
+			"api.failed_to_change_feature_flag":            "Failed to change feature flag",
+			"api.failed_to_change_scanner_settings":        "Failed to change scanner settings",
+			"api.failed_to_clear_learned_key_timeout":      "Failed to clear learned key timeout",
+			"api.failed_to_compute_data_quality_report":    "Failed to compute data quality report",
+			"api.failed_to_compute_operator_productivity":  "Failed to compute operator productivity",
+			"api.failed_to_compute_station_availability":   "Failed to compute station availability",
+			"api.failed_to_delete_scan_record":             "Failed to delete scan record",
+			"api.failed_to_evaluate_barcode":               "Failed to evaluate barcode",
+			"api.failed_to_get_annotations":                "Failed to get annotations",
+			"api.failed_to_get_backfill_jobs":              "Failed to get backfill jobs",
+			"api.failed_to_get_consumer_backlog":           "Failed to get consumer backlog",
+			"api.failed_to_get_device":                     "Failed to get device",
+			"api.failed_to_get_statistics":                 "Failed to get statistics",
+			"api.failed_to_get_sync_conflicts":             "Failed to get sync conflicts",
+			"api.failed_to_ingest_scan":                    "Failed to ingest scan",
+			"api.failed_to_load_schema":                    "Failed to load schema",
+			"api.failed_to_process_backfill_job":           "Failed to process backfill job",
+			"api.failed_to_process_configuration_profile":  "Failed to process configuration profile",
+			"api.failed_to_process_day_closing":            "Failed to process day closing",
+			"api.failed_to_process_preference":             "Failed to process preference",
+			"api.failed_to_process_production_goal":        "Failed to process production goal",
+			"api.failed_to_process_replay_job":             "Failed to process replay job",
+			"api.failed_to_process_scan_simulation":        "Failed to process scan simulation",
+			"api.failed_to_process_sync_conflict":          "Failed to process sync conflict",
+			"api.failed_to_query_barcode_image":            "Failed to query barcode image",
+			"api.failed_to_query_classification_rules":     "Failed to query classification rules",
+			"api.failed_to_query_configuration":            "Failed to query configuration",
+			"api.failed_to_query_configuration_profiles":   "Failed to query configuration profiles",
+			"api.failed_to_query_data_quality_report":      "Failed to query data quality report",
+			"api.failed_to_query_device":                   "Failed to query device",
+			"api.failed_to_query_device_list":              "Failed to query device list",
+			"api.failed_to_query_goal_progress":            "Failed to query goal progress",
+			"api.failed_to_query_health_score_trend":       "Failed to query health score trend",
+			"api.failed_to_query_heartbeats":               "Failed to query heartbeats",
+			"api.failed_to_query_metric_snapshots":         "Failed to query metric snapshots",
+			"api.failed_to_query_production_goals":         "Failed to query production goals",
+			"api.failed_to_query_scan_records":             "Failed to query scan records",
+			"api.failed_to_query_sequence_gaps":            "Failed to query sequence gaps",
+			"api.failed_to_query_tokens":                   "Failed to query tokens",
+			"api.failed_to_read_image":                     "Failed to read image",
+			"api.failed_to_read_request_body":              "Failed to read request body",
+			"api.failed_to_recompute_distinct_barcode":     "Failed to recompute distinct barcode counts",
+			"api.failed_to_register_device_path":           "Failed to register device path",
+			"api.failed_to_reset_sequence":                 "Failed to reset sequence",
+			"api.failed_to_restore_device":                 "Failed to restore device",
+			"api.failed_to_save_barcode_image":             "Failed to save barcode image",
+			"api.failed_to_save_classification_rules":      "Failed to save classification rules",
+			"api.failed_to_save_configuration":             "Failed to save configuration",
+			"api.failed_to_save_scan_record":               "Failed to save scan record",
+			"api.failed_to_send_device_command":            "Failed to send device command",
+			"api.failed_to_switch_scan_capture_state":      "Failed to switch scan capture state",
+			"api.failed_to_update_device_configuration":    "Failed to update device configuration profile",
+			"api.failed_to_update_device_key_timeout":      "Failed to update device key timeout",
+			"api.failed_to_update_device_scanner_preset":   "Failed to update device scanner preset",
+			"api.failed_to_verify_scan_record_integrity":   "Failed to verify scan record integrity",
+			"api.failed_to_write_counters":                 "Failed to write counters",
+			"api.feature_flag_not_found":                   "Feature flag not found",
+			"api.field_mapping_failed":                     "Field mapping failed",
+			"api.first_run_setup_completed":                "First-run setup completed",
+			"api.first_run_setup_completed_restart_the":    "First-run setup completed; restart the service to apply the configuration",
+			"api.first_run_setup_failed":                   "First-run setup failed",
+			"api.first_run_setup_is_not_complete_only":     "First-run setup is not complete; only local access is allowed",
+			"api.hours_must_be_an_integer_between_1_and":   "hours must be an integer between 1 and 720",
+			"api.ignored_an_event_sent_by_this_station":    "Ignored an event sent by this station",
+			"api.image_exceeds_the_size_limit":             "Image exceeds the size limit",
+			"api.image_file_not_found":                     "Image file not found",
+			"api.image_saved":                              "Image saved",
+			"api.ingest_body_does_not_match_the_schema":    "Ingest body does not match the schema",
+			"api.ingest_rate_exceeds_the_limit_please":     "Ingest rate exceeds the limit; please retry later",
+			"api.integrity_mode_is_not_enabled":            "Integrity mode is not enabled",
+			"api.internal_server_error":                    "Internal server error",
+			"api.invalid_acknowledgement_request":          "Invalid acknowledgement request",
+			"api.invalid_annotation":                       "Invalid annotation",
+			"api.invalid_classification_rule":              "Invalid classification rule",
+			"api.invalid_client_id":                        "Invalid client ID",
+			"api.invalid_close_code":                       "Invalid close code",
+			"api.invalid_command":                          "Invalid command",
+			"api.invalid_configuration":                    "Invalid configuration",
+			"api.invalid_configuration_profile":            "Invalid configuration profile",
+			"api.invalid_configuration_profile_id":         "Invalid configuration profile ID",
+			"api.invalid_configuration_value":              "Invalid configuration value",
+			"api.invalid_date":                             "Invalid date",
+			"api.invalid_day_closing_request":              "Invalid day closing request",
+			"api.invalid_derived_field_name":               "Invalid derived field name",
+			"api.invalid_device_id":                        "Invalid device ID",
+			"api.invalid_devices_parameter":                "Invalid devices parameter",
+			"api.invalid_expiry":                           "Invalid expiry",
+			"api.invalid_goal_id":                          "Invalid goal ID",
+			"api.invalid_ingest_payload":                   "Invalid ingest payload",
+			"api.invalid_key_timeout":                      "Invalid key timeout",
+			"api.invalid_maintenance_duration":             "Invalid maintenance duration",
+			"api.invalid_max_message_size_parameter":       "Invalid max_message_size parameter",
+			"api.invalid_payload_format_parameters":        "Invalid payload format parameters",
+			"api.invalid_preference":                       "Invalid preference",
+			"api.invalid_production_goal":                  "Invalid production goal",
+			"api.invalid_record_id":                        "Invalid record ID",
+			"api.invalid_replay_job_id":                    "Invalid replay job ID",
+			"api.invalid_replay_request":                   "Invalid replay request",
+			"api.invalid_request_parameters":               "Invalid request parameters",
+			"api.invalid_rule_id":                          "Invalid rule ID",
+			"api.invalid_scanner_settings":                 "Invalid scanner settings",
+			"api.invalid_simulation_scenario":              "Invalid simulation scenario",
+			"api.invalid_since_parameter":                  "Invalid since parameter",
+			"api.invalid_sync_conflict_id":                 "Invalid sync conflict ID",
+			"api.invalid_time_zone":                        "Invalid time zone",
+			"api.invalid_token_id":                         "Invalid token ID",
+			"api.keyboard_hook_agent_is_not_enabled":       "Keyboard hook agent is not enabled",
+			"api.learned_key_timeout_cleared":              "Learned key timeout cleared",
+			"api.maintenance_mode_entered":                 "Maintenance mode entered",
+			"api.maintenance_mode_exited":                  "Maintenance mode exited",
+			"api.make_sure_web_rules_tester_html_exists":   "Make sure web/rules-tester.html exists",
+			"api.make_sure_web_setup_html_exists":          "Make sure web/setup.html exists",
+			"api.make_sure_web_test_socket_html_exists":    "Make sure web/test-socket.html exists",
+			"api.metric_not_found":                         "Metric not found",
+			"api.metric_snapshots_are_not_enabled":         "Metric snapshots are not enabled",
+			"api.missing_barcode_content":                  "Missing barcode content",
+			"api.missing_image":                            "Missing image",
+			"api.missing_source_station_or_event_type":     "Missing source station or event type",
+			"api.no_closing_summary_for_this_production":   "No closing summary for this production day yet",
+			"api.no_keyboard_capture_source":               "No keyboard capture source",
+			"api.no_scanner_settings_can_be_changed_in":    "No scanner settings can be changed in API-only mode",
+			"api.no_scenario_loaded":                       "No scenario loaded",
+			"api.open_setup_on_this_machine_to_complete":   "Open /setup on this machine to complete setup",
+			"api.per_operator_statistics_are_disabled":     "Per-operator statistics are disabled",
+			"api.persistent_counters_are_not_enabled":      "Persistent counters are not enabled",
+			"api.preference_deleted":                       "Preference deleted",
+			"api.preference_exceeds_the_size_limit":        "Preference exceeds the size limit",
+			"api.preference_not_found":                     "Preference not found",
+			"api.preference_saved":                         "Preference saved",
+			"api.production_day_is_not_closed":             "Production day is not closed",
+			"api.production_day_reopened":                  "Production day reopened",
+			"api.production_goal_created":                  "Production goal created",
+			"api.production_goal_deleted":                  "Production goal deleted",
+			"api.production_goal_not_found":                "Production goal not found",
+			"api.production_goal_updated":                  "Production goal updated",
+			"api.production_goals_are_not_enabled":         "Production goals are not enabled",
+			"api.query_range_is_too_large":                 "Query range is too large",
+			"api.read_only_mode":                           "Read-only mode",
+			"api.replay_job_cancelled":                     "Replay job cancelled",
+			"api.replay_job_created":                       "Replay job created",
+			"api.replay_job_not_found":                     "Replay job not found",
+			"api.replay_job_requeued":                      "Replay job requeued",
+			"api.replay_job_state_does_not_allow_this":     "Replay job state does not allow this operation",
+			"api.request_a_new_confirmation_token":         "Request a new confirmation token",
+			"api.request_body_too_large":                   "Request body too large",
+			"api.rules_tester_page_file_not_found":         "Rules tester page file not found",
+			"api.running_in_read_only_mode_app_read_only":  "Running in read-only mode (app.read_only); data cannot be modified",
+			"api.runtime_summary_unavailable":              "Runtime summary unavailable",
+			"api.scan_capture_paused":                      "Scan capture paused",
+			"api.scan_capture_resumed":                     "Scan capture resumed",
+			"api.scan_received":                            "Scan received",
+			"api.scan_record_deleted":                      "Scan record deleted",
+			"api.scan_record_not_found":                    "Scan record not found",
+			"api.scan_record_saved":                        "Scan record saved",
+			"api.scan_record_saved_but_saving_the_image":   "Scan record saved, but saving the image failed",
+			"api.scan_records_cleared":                     "Scan records cleared",
+			"api.scan_simulation_is_not_enabled":           "Scan simulation is not enabled",
+			"api.scan_simulation_started":                  "Scan simulation started",
+			"api.scan_simulation_state_does_not_allow":     "Scan simulation state does not allow this operation",
+			"api.scan_simulation_stopped":                  "Scan simulation stopped",
+			"api.scanner_preset_not_found":                 "Scanner preset not found",
+			"api.scanner_settings_applied_they_revert_to":  "Scanner settings applied; they revert to the configuration file after a restart",
+			"api.scenario_loaded":                          "Scenario loaded",
+			"api.schema_not_found":                         "Schema not found",
+			"api.sequence_reset":                           "Sequence reset",
+			"api.setup_page_file_not_found":                "Setup page file not found",
+			"api.some_records_failed_to_acknowledge":       "Some records failed to acknowledge",
+			"api.station_heartbeat_is_not_enabled":         "Station heartbeat is not enabled",
+			"api.station_information_saved":                "Station information saved",
+			"api.sync_conflict_not_found":                  "Sync conflict not found",
+			"api.sync_conflict_resolved":                   "Sync conflict resolved",
+			"api.system_configuration_is_protected":        "System configuration is protected",
+			"api.test_page_file_not_found":                 "Test page file not found",
+			"api.the_production_day_of_this_record_is":     "The production day of this record is closed",
+			"api.this_production_day_is_already_closed":    "This production day is already closed",
+			"api.this_record_has_no_image":                 "This record has no image",
+			"api.to_reset_anyway_set_admin_allow_reset_in": "To reset anyway, set admin.allow_reset_in_production",
+			"api.token_created_store_it_safely_the":        "Token created. Store it safely; the plaintext will not be shown again",
+			"api.token_has_no_write_permission":            "Token has no write permission",
+			"api.token_is_not_allowed_to_access_this":      "Token is not allowed to access this device",
+			"api.token_revoked":                            "Token revoked",
+			"api.too_many_requests":                        "Too many requests",
+			"api.unable_to_pause_scan_capture":             "Unable to pause scan capture",
+			"api.unsupported_image_type":                   "Unsupported image type",
+
+			"mail.closing.by_device":       "By device",
+			"mail.closing.by_operator":     "By operator",
+			"mail.closing.by_type":         "By type",
+			"mail.closing.carried":         "%s/%s (device %d, last sequence %d): %s",
+			"mail.closing.carried_over":    "Carried over to the next production day",
+			"mail.closing.closed":          "Closed by: %s %s",
+			"mail.closing.day":             "Production day: %s (%s ~ %s)",
+			"mail.closing.duplicates":      "Duplicates: %s",
+			"mail.closing.goal":            "%s: %s/%s (%s%%)",
+			"mail.closing.goals":           "Production goals",
+			"mail.closing.invalid":         "Invalid: %s",
+			"mail.closing.no_device":       "No device",
+			"mail.closing.note":            "Note: %s",
+			"mail.closing.operator":        "%s: %s (%s%% valid), active %s, %s per hour",
+			"mail.closing.operators":       "Operator productivity",
+			"mail.closing.station":         "Station: %s",
+			"mail.closing.subject":         "Daily closing summary %s %s",
+			"mail.closing.total":           "Total scans: %s",
+			"mail.quality.check_digit":     ", %s check digit failures",
+			"mail.quality.device":          "Device %s",
+			"mail.quality.device_line":     "%s: %s/%s malformed (%s%%)",
+			"mail.quality.spec_violations": ", %s with non-conforming characters",
+			"mail.quality.summary":         "Data quality: %s/%s malformed (%s%%)",
+			"mail.quality.type":            "%s: %s, %s%% valid",
+
+			"status.capturing":       "Scan capture",
+			"status.capturing_no":    "Not capturing",
+			"status.capturing_yes":   "Capturing",
+			"status.maintenance":     "Maintenance",
+			"status.maintenance_no":  "No",
+			"status.maintenance_yes": "In maintenance",
+			"status.recent_scan":     "Recent scans",
+			"status.recent_scan_no":  "None",
+			"status.recent_scan_yes": "Yes",
+			"status.service":         "Service",
+			"status.service_no":      "Down",
+			"status.service_yes":     "Running",
+			"status.title":           "Scanner Station Status",
+			"status.version":         "Version",
+		},
+	})
+}
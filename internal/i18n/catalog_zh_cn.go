@@ -0,0 +1,331 @@
+package i18n
+
+// 简体中文消息目录（源语言）：api.* 为接口返回的 error、message 原文，其他语言按此翻译
+func init() {
+	register(&Catalog{
+		Locale:    ZhCN,
+		Name:      "简体中文",
+		DateTime:  "2006-01-02 15:04:05",
+		Date:      "2006-01-02",
+		Decimal:   ".",
+		Thousands: "",
+		Messages: map[string]string{
+			"alert.capture_source_unavailable": "采集来源 %s 不可用：%v",
+			"alert.device_idle":                "设备 %d 已连续 %s 无扫码",
+			"alert.goal_reached":               "产量目标「%s」已达成：%s 完成 %d/%d",
+			"alert.health_below_threshold":     "设备 %d 健康评分降至 %.0f，低于阈值 %.0f",
+			"alert.health_dropped":             "设备 %d 健康评分由 %.0f 降至 %.0f",
+			"alert.hook_reinstall_failed":      "键盘钩子已失效，重新安装失败，将自动重试：%s",
+			"alert.hook_reinstalled":           "键盘钩子已失效并自动重新安装（第 %d 次）：%s",
+			"alert.integrity_failed":           "扫码记录完整性校验失败：记录 %d %s",
+			"alert.key_timing_suspect":         "最近 %d 次扫码中 %.0f%% 按键时序异常，键盘钩子可能因系统负载过高而漏键",
+			"alert.primary_stopped":            "主机 %s 已停止采集扫码：%s",
+			"alert.quality_degraded":           "数据质量退化: %s",
+			"alert.setup_code_scanned":         "扫描到扫码枪设置码（%s），疑似误扫入数据流: %s",
+			"alert.standby_took_over":          "备机 %s 已接管扫码采集：%s",
+			"alert.station_idle":               "工位已连续 %s 无扫码",
+
+			"api.admin_key_saved_keep_it_safe":             "管理员密钥已保存，请妥善保管",
+			"api.administrator_privileges_required":        "需要管理员权限",
+			"api.annotation_added":                         "批注已添加",
+			"api.backfill_job_not_found":                   "回填任务不存在",
+			"api.backfill_job_paused":                      "回填任务已暂停",
+			"api.backfill_job_requeued":                    "回填任务已重新排队",
+			"api.backfill_job_state_does_not_allow_this":   "回填任务状态不允许该操作",
+			"api.background_backfill_is_not_enabled":       "后台回填未启用",
+			"api.bad_request_parameters":                   "请求参数错误",
+			"api.barcode_image_storage_is_not_enabled":     "未启用条码图片存储",
+			"api.central_server_settings_saved":            "中心服务器设置已保存",
+			"api.central_sync_failed":                      "中心同步失败",
+			"api.central_sync_is_not_enabled":              "中心同步未启用",
+			"api.classification_rule_created":              "分类规则已创建",
+			"api.classification_rule_deleted":              "分类规则已删除",
+			"api.classification_rule_not_found":            "分类规则不存在",
+			"api.classification_rule_updated":              "分类规则已更新",
+			"api.client_disconnected":                      "客户端已断开",
+			"api.client_not_found":                         "客户端不存在",
+			"api.command_is_not_in_the_device_allow_list":  "命令不在设备白名单中",
+			"api.complete_the_setup_steps_in_order":        "请按顺序完成配置步骤",
+			"api.configuration_deleted":                    "配置已删除",
+			"api.configuration_hot_reload_is_not_enabled":  "配置热加载未启用",
+			"api.configuration_not_found":                  "配置不存在",
+			"api.configuration_profile_created":            "配置档案已创建",
+			"api.configuration_profile_deleted":            "配置档案已删除",
+			"api.configuration_profile_not_found":          "配置档案不存在",
+			"api.configuration_profile_updated":            "配置档案已更新",
+			"api.configuration_restored":                   "配置已恢复",
+			"api.configuration_saved":                      "配置已保存",
+			"api.configuration_value_is_outside_the_safe":  "配置值超出安全范围",
+			"api.conflicts_with_a_deleted_record":          "与已删除的记录冲突",
+			"api.connection_test_failed":                   "连接测试失败",
+			"api.consumer_not_registered":                  "消费方未登记",
+			"api.consumption_acknowledged":                 "已确认消费",
+			"api.content_from_the_central_server_failed":   "中心内容未通过校验",
+			"api.counter_can_only_increase":                "该计数器只能递增，不允许清零",
+			"api.counters_reset":                           "计数器已清零",
+			"api.counters_rolled_over":                     "计数器已轮转",
+			"api.dashboard_preferences_are_not_enabled":    "看板偏好设置未启用",
+			"api.data_quality_reports_are_not_enabled":     "数据质量报告未启用",
+			"api.data_reset":                               "数据已重置",
+			"api.data_reset_failed":                        "数据重置失败",
+			"api.data_reset_is_not_allowed_in_production":  "生产环境禁止数据重置",
+			"api.database_connection_test_succeeded":       "数据库连接测试成功",
+			"api.day_closed":                               "日结完成",
+			"api.day_closing_is_not_enabled":               "日结未启用",
+			"api.deleted_device_not_found":                 "已删除的设备不存在",
+			"api.device_command_settings_updated":          "设备命令设置已更新",
+			"api.device_configuration_profile_updated":     "设备配置档案已更新",
+			"api.device_has_no_command_channel_configured": "设备未配置命令通道",
+			"api.device_health_scoring_is_not_enabled":     "设备健康评分未启用",
+			"api.device_id_is_invalid":                     "device_id 无效",
+			"api.device_information_saved":                 "设备信息已保存",
+			"api.device_key_timeout_updated":               "设备按键超时已更新",
+			"api.device_name_is_already_in_use":            "设备名称已被使用",
+			"api.device_not_found":                         "设备不存在",
+			"api.device_or_configuration_profile_not":      "设备或配置档案不存在",
+			"api.device_path_is_already_in_use":            "设备路径已被使用",
+			"api.device_path_updated":                      "设备路径已更新",
+			"api.device_restored":                          "设备已恢复",
+			"api.device_scanner_preset_updated_takes":      "设备扫码枪预设已更新，重启后生效",
+			"api.device_scoped_tokens_cannot_acknowledge":  "限定设备的令牌不能确认消费",
+			"api.device_scoped_tokens_cannot_view_per":     "限定设备的令牌不能查看按操作员的统计",
+			"api.device_scoped_tokens_cannot_view_station": "限定设备的令牌不能查看全工位报告",
+			"api.device_scoped_tokens_must_specify_device": "限定设备的令牌须指定 device_id",
+			"api.device_station_updated":                   "设备工位已更新",
+			"api.distinct_barcode_counting_is_not_enabled": "不同条码数统计未启用",
+			"api.duration_must_be_a_positive_duration_e_g": "duration 应为正的时长，如 30m",
+			"api.event_received":                           "事件已接收",
+			"api.expected_format_2006_01_02":               "格式应为 2006-01-02",
+			"api.expires_in_must_be_a_positive_duration_e": "expires_in 应为正的时长，如 720h",
+			"api.external_ingest_is_not_enabled":           "外部推送未启用",
+			"api.failed_to_acknowledge_consumption":        "确认消费失败",
+			"api.failed_to_add_annotation":                 "添加批注失败",
+			"api.failed_to_assign_device_station":          "分配设备工位失败",
+			"api.failed_to_build_diagnostics_bundle":       "生成诊断包失败",
+			"api.failed_to_change_feature_flag":            "修改功能开关失败",
+			"api.failed_to_change_scanner_settings":        "修改扫码参数失败",
+			"api.failed_to_clear_learned_key_timeout":      "清除设备学到的按键超时失败",
+			"api.failed_to_compute_data_quality_report":    "计算数据质量报告失败",
+			"api.failed_to_compute_operator_productivity":  "统计操作员效率失败",
+			"api.failed_to_compute_station_availability":   "计算工位可用性失败",
+			"api.failed_to_delete_scan_record":             "删除扫码记录失败",
+			"api.failed_to_evaluate_barcode":               "判定条码失败",
+			"api.failed_to_get_annotations":                "获取批注失败",
+			"api.failed_to_get_backfill_jobs":              "获取回填任务失败",
+			"api.failed_to_get_consumer_backlog":           "获取消费积压失败",
+			"api.failed_to_get_device":                     "获取设备失败",
+			"api.failed_to_get_statistics":                 "获取统计信息失败",
+			"api.failed_to_get_sync_conflicts":             "获取同步冲突失败",
+			"api.failed_to_ingest_scan":                    "推送扫码失败",
+			"api.failed_to_load_schema":                    "加载结构定义失败",
+			"api.failed_to_process_backfill_job":           "处理回填任务失败",
+			"api.failed_to_process_configuration_profile":  "处理配置档案失败",
+			"api.failed_to_process_day_closing":            "处理日结失败",
+			"api.failed_to_process_preference":             "处理偏好设置失败",
+			"api.failed_to_process_production_goal":        "处理产量目标失败",
+			"api.failed_to_process_replay_job":             "处理回放任务失败",
+			"api.failed_to_process_scan_simulation":        "处理模拟扫码失败",
+			"api.failed_to_process_sync_conflict":          "处理同步冲突失败",
+			"api.failed_to_query_barcode_image":            "查询条码图片失败",
+			"api.failed_to_query_classification_rules":     "查询分类规则失败",
+			"api.failed_to_query_configuration":            "查询配置失败",
+			"api.failed_to_query_configuration_profiles":   "查询配置档案失败",
+			"api.failed_to_query_data_quality_report":      "查询数据质量报告失败",
+			"api.failed_to_query_device":                   "查询设备失败",
+			"api.failed_to_query_device_list":              "查询设备列表失败",
+			"api.failed_to_query_goal_progress":            "查询目标进度失败",
+			"api.failed_to_query_health_score_trend":       "查询健康评分趋势失败",
+			"api.failed_to_query_heartbeats":               "查询心跳记录失败",
+			"api.failed_to_query_metric_snapshots":         "查询监控指标快照失败",
+			"api.failed_to_query_production_goals":         "查询产量目标失败",
+			"api.failed_to_query_scan_records":             "查询扫码记录失败",
+			"api.failed_to_query_sequence_gaps":            "查询断号记录失败",
+			"api.failed_to_query_tokens":                   "查询令牌失败",
+			"api.failed_to_read_image":                     "读取图片失败",
+			"api.failed_to_read_request_body":              "读取请求体失败",
+			"api.failed_to_recompute_distinct_barcode":     "重算不同条码数失败",
+			"api.failed_to_register_device_path":           "登记设备路径失败",
+			"api.failed_to_reset_sequence":                 "重置序号失败",
+			"api.failed_to_restore_device":                 "恢复设备失败",
+			"api.failed_to_save_barcode_image":             "保存条码图片失败",
+			"api.failed_to_save_classification_rules":      "保存分类规则失败",
+			"api.failed_to_save_configuration":             "保存配置失败",
+			"api.failed_to_save_scan_record":               "保存扫码记录失败",
+			"api.failed_to_send_device_command":            "下发设备命令失败",
+			"api.failed_to_switch_scan_capture_state":      "切换扫码采集状态失败",
+			"api.failed_to_update_device_configuration":    "更新设备配置档案失败",
+			"api.failed_to_update_device_key_timeout":      "更新设备按键超时失败",
+			"api.failed_to_update_device_scanner_preset":   "更新设备扫码枪预设失败",
+			"api.failed_to_verify_scan_record_integrity":   "校验扫码记录完整性失败",
+			"api.failed_to_write_counters":                 "写入计数器失败",
+			"api.feature_flag_not_found":                   "功能开关不存在",
+			"api.field_mapping_failed":                     "字段映射失败",
+			"api.first_run_setup_completed":                "首次运行配置已完成",
+			"api.first_run_setup_completed_restart_the":    "首次运行配置已完成，请重启服务使配置生效",
+			"api.first_run_setup_failed":                   "首次运行配置失败",
+			"api.first_run_setup_is_not_complete_only":     "尚未完成首次运行配置，仅允许本机访问",
+			"api.hours_must_be_an_integer_between_1_and":   "hours 应为1到720之间的整数",
+			"api.ignored_an_event_sent_by_this_station":    "已忽略本工位发出的事件",
+			"api.image_exceeds_the_size_limit":             "图片超过大小限制",
+			"api.image_file_not_found":                     "图片文件不存在",
+			"api.image_saved":                              "图片已保存",
+			"api.ingest_body_does_not_match_the_schema":    "推送内容不符合结构定义",
+			"api.ingest_rate_exceeds_the_limit_please":     "推送速度超过接入限制，请稍后重试",
+			"api.integrity_mode_is_not_enabled":            "完整性模式未启用",
+			"api.internal_server_error":                    "服务器内部错误",
+			"api.invalid_acknowledgement_request":          "确认请求无效",
+			"api.invalid_annotation":                       "批注无效",
+			"api.invalid_classification_rule":              "分类规则无效",
+			"api.invalid_client_id":                        "无效的客户端ID",
+			"api.invalid_close_code":                       "关闭码无效",
+			"api.invalid_command":                          "命令无效",
+			"api.invalid_configuration":                    "配置无效",
+			"api.invalid_configuration_profile":            "配置档案无效",
+			"api.invalid_configuration_profile_id":         "配置档案ID无效",
+			"api.invalid_configuration_value":              "配置值无效",
+			"api.invalid_date":                             "日期无效",
+			"api.invalid_day_closing_request":              "日结请求无效",
+			"api.invalid_derived_field_name":               "派生字段名无效",
+			"api.invalid_device_id":                        "设备ID无效",
+			"api.invalid_devices_parameter":                "devices 参数无效",
+			"api.invalid_expiry":                           "有效期无效",
+			"api.invalid_goal_id":                          "目标ID无效",
+			"api.invalid_ingest_payload":                   "推送内容无效",
+			"api.invalid_key_timeout":                      "按键超时无效",
+			"api.invalid_maintenance_duration":             "维护时长无效",
+			"api.invalid_max_message_size_parameter":       "max_message_size 参数无效",
+			"api.invalid_payload_format_parameters":        "载荷格式参数无效",
+			"api.invalid_preference":                       "偏好设置无效",
+			"api.invalid_production_goal":                  "产量目标无效",
+			"api.invalid_record_id":                        "记录ID无效",
+			"api.invalid_replay_job_id":                    "回放任务ID无效",
+			"api.invalid_replay_request":                   "回放请求无效",
+			"api.invalid_request_parameters":               "请求参数无效",
+			"api.invalid_rule_id":                          "规则ID无效",
+			"api.invalid_scanner_settings":                 "扫码参数无效",
+			"api.invalid_simulation_scenario":              "模拟场景无效",
+			"api.invalid_since_parameter":                  "since 参数无效",
+			"api.invalid_sync_conflict_id":                 "同步冲突ID无效",
+			"api.invalid_time_zone":                        "时区无效",
+			"api.invalid_token_id":                         "令牌ID无效",
+			"api.keyboard_hook_agent_is_not_enabled":       "键盘钩子代理未启用",
+			"api.learned_key_timeout_cleared":              "设备学到的按键超时已清除",
+			"api.maintenance_mode_entered":                 "已进入维护模式",
+			"api.maintenance_mode_exited":                  "已退出维护模式",
+			"api.make_sure_web_rules_tester_html_exists":   "请确保 web/rules-tester.html 文件存在",
+			"api.make_sure_web_setup_html_exists":          "请确保 web/setup.html 文件存在",
+			"api.make_sure_web_test_socket_html_exists":    "请确保 web/test-socket.html 文件存在",
+			"api.metric_not_found":                         "指标不存在",
+			"api.metric_snapshots_are_not_enabled":         "监控指标快照未启用",
+			"api.missing_barcode_content":                  "缺少条码内容",
+			"api.missing_image":                            "缺少图片",
+			"api.missing_source_station_or_event_type":     "缺少来源工位或事件类型",
+			"api.no_closing_summary_for_this_production":   "该生产日尚无日结汇总",
+			"api.no_keyboard_capture_source":               "没有按键采集来源",
+			"api.no_scanner_settings_can_be_changed_in":    "仅API模式下没有可修改的扫码参数",
+			"api.no_scenario_loaded":                       "尚未加载场景",
+			"api.open_setup_on_this_machine_to_complete":   "请在本机打开 /setup 完成配置",
+			"api.per_operator_statistics_are_disabled":     "按操作员的统计已关闭",
+			"api.persistent_counters_are_not_enabled":      "持久化计数器未启用",
+			"api.preference_deleted":                       "偏好设置已删除",
+			"api.preference_exceeds_the_size_limit":        "偏好设置超过大小限制",
+			"api.preference_not_found":                     "偏好设置不存在",
+			"api.preference_saved":                         "偏好设置已保存",
+			"api.production_day_is_not_closed":             "生产日尚未日结",
+			"api.production_day_reopened":                  "生产日已重新开放",
+			"api.production_goal_created":                  "产量目标已创建",
+			"api.production_goal_deleted":                  "产量目标已删除",
+			"api.production_goal_not_found":                "产量目标不存在",
+			"api.production_goal_updated":                  "产量目标已更新",
+			"api.production_goals_are_not_enabled":         "产量目标未启用",
+			"api.query_range_is_too_large":                 "查询范围过大",
+			"api.read_only_mode":                           "只读模式",
+			"api.replay_job_cancelled":                     "回放任务已取消",
+			"api.replay_job_created":                       "回放任务已创建",
+			"api.replay_job_not_found":                     "回放任务不存在",
+			"api.replay_job_requeued":                      "回放任务已重新排队",
+			"api.replay_job_state_does_not_allow_this":     "回放任务状态不允许该操作",
+			"api.request_a_new_confirmation_token":         "请重新获取确认令牌",
+			"api.request_body_too_large":                   "请求体过大",
+			"api.rules_tester_page_file_not_found":         "规则测试页面文件不存在",
+			"api.running_in_read_only_mode_app_read_only":  "当前以只读模式运行（app.read_only），不允许修改数据",
+			"api.runtime_summary_unavailable":              "运行摘要不可用",
+			"api.scan_capture_paused":                      "已暂停扫码采集",
+			"api.scan_capture_resumed":                     "已恢复扫码采集",
+			"api.scan_received":                            "扫码已接收",
+			"api.scan_record_deleted":                      "扫码记录已删除",
+			"api.scan_record_not_found":                    "扫码记录不存在",
+			"api.scan_record_saved":                        "扫码记录已保存",
+			"api.scan_record_saved_but_saving_the_image":   "扫码记录已保存，图片保存失败",
+			"api.scan_records_cleared":                     "扫码记录已清空",
+			"api.scan_simulation_is_not_enabled":           "模拟扫码未启用",
+			"api.scan_simulation_started":                  "模拟扫码已开始",
+			"api.scan_simulation_state_does_not_allow":     "模拟扫码状态不允许该操作",
+			"api.scan_simulation_stopped":                  "模拟扫码已停止",
+			"api.scanner_preset_not_found":                 "扫码枪预设不存在",
+			"api.scanner_settings_applied_they_revert_to":  "扫码参数已生效，重启后恢复为配置文件中的值",
+			"api.scenario_loaded":                          "场景已加载",
+			"api.schema_not_found":                         "结构定义不存在",
+			"api.sequence_reset":                           "序号已重置",
+			"api.setup_page_file_not_found":                "向导页面文件不存在",
+			"api.some_records_failed_to_acknowledge":       "部分记录确认失败",
+			"api.station_heartbeat_is_not_enabled":         "工位心跳未启用",
+			"api.station_information_saved":                "工位信息已保存",
+			"api.sync_conflict_not_found":                  "同步冲突不存在",
+			"api.sync_conflict_resolved":                   "同步冲突已处理",
+			"api.system_configuration_is_protected":        "系统配置受保护",
+			"api.test_page_file_not_found":                 "测试页面文件不存在",
+			"api.the_production_day_of_this_record_is":     "该记录所属生产日已日结",
+			"api.this_production_day_is_already_closed":    "该生产日已日结",
+			"api.this_record_has_no_image":                 "该记录没有图片",
+			"api.to_reset_anyway_set_admin_allow_reset_in": "如确需重置，请设置 admin.allow_reset_in_production",
+			"api.token_created_store_it_safely_the":        "令牌创建成功，请妥善保存，明文不会再次显示",
+			"api.token_has_no_write_permission":            "令牌无写权限",
+			"api.token_is_not_allowed_to_access_this":      "令牌无权访问该设备",
+			"api.token_revoked":                            "令牌已吊销",
+			"api.too_many_requests":                        "请求过于频繁",
+			"api.unable_to_pause_scan_capture":             "无法暂停扫码采集",
+			"api.unsupported_image_type":                   "不支持的图片类型",
+
+			"mail.closing.by_device":       "按设备",
+			"mail.closing.by_operator":     "按操作员",
+			"mail.closing.by_type":         "按类型",
+			"mail.closing.carried":         "%s/%s（设备 %d，最后序号 %d）: %s",
+			"mail.closing.carried_over":    "转入下一生产日",
+			"mail.closing.closed":          "日结: %s %s",
+			"mail.closing.day":             "生产日: %s（%s ~ %s）",
+			"mail.closing.duplicates":      "重复: %s",
+			"mail.closing.goal":            "%s: %s/%s（%s%%）",
+			"mail.closing.goals":           "产量目标",
+			"mail.closing.invalid":         "无效: %s",
+			"mail.closing.no_device":       "未关联设备",
+			"mail.closing.note":            "备注: %s",
+			"mail.closing.operator":        "%s: %s（有效 %s%%），在岗 %s，每小时 %s",
+			"mail.closing.operators":       "操作员效率",
+			"mail.closing.station":         "工位: %s",
+			"mail.closing.subject":         "日结汇总 %s %s",
+			"mail.closing.total":           "扫码总数: %s",
+			"mail.quality.check_digit":     "，校验位错误 %s",
+			"mail.quality.device":          "设备 %s",
+			"mail.quality.device_line":     "%s: 不合格 %s/%s（%s%%）",
+			"mail.quality.spec_violations": "，含规范外字符 %s",
+			"mail.quality.summary":         "数据质量: 不合格 %s/%s（%s%%）",
+			"mail.quality.type":            "%s: %s，合格率 %s%%",
+
+			"status.capturing":       "扫码采集",
+			"status.capturing_no":    "未采集",
+			"status.capturing_yes":   "采集中",
+			"status.maintenance":     "维护模式",
+			"status.maintenance_no":  "否",
+			"status.maintenance_yes": "维护中",
+			"status.recent_scan":     "最近扫码",
+			"status.recent_scan_no":  "无",
+			"status.recent_scan_yes": "有",
+			"status.service":         "服务",
+			"status.service_no":      "异常",
+			"status.service_yes":     "运行中",
+			"status.title":           "扫码工位状态",
+			"status.version":         "版本",
+		},
+	})
+}
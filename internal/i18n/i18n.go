@@ -0,0 +1,259 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 支持的语言，每种语言对应一个消息目录文件（catalog_*.go）
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+)
+
+// Source 源语言：服务端代码中直接写出的文字（接口的 error、message 等）为该语言，其他语言按消息目录翻译
+const Source = ZhCN
+
+// Catalog 一种语言的消息目录及日期、数字格式
+//
+// 新增语言只需新增一个目录文件，在 init 中调用 register；目录须包含源语言目录中的全部消息代码，缺少的见 Missing。
+type Catalog struct {
+	Locale    string
+	Name      string            // 语言名称（以该语言书写）
+	Messages  map[string]string // 消息代码 -> 文字，可含 fmt 占位符
+	DateTime  string            // 日期时间格式（time 布局）
+	Date      string            // 日期格式
+	Decimal   string            // 小数点
+	Thousands string            // 千位分隔符，为空表示不分组
+}
+
+var (
+	catalogs = make(map[string]*Catalog) // 小写语言标签 -> 目录
+
+	// sourceIndex 源语言文字 -> 消息代码，首次翻译时建立
+	sourceOnce  sync.Once
+	sourceIndex map[string]string
+
+	defaultLocale atomic.Value // string
+)
+
+// register 登记语言的消息目录，在目录文件的 init 中调用
+func register(c *Catalog) {
+	catalogs[strings.ToLower(c.Locale)] = c
+}
+
+// Supported 支持的语言，按标签排序
+func Supported() []string {
+	locales := make([]string, 0, len(catalogs))
+	for _, c := range catalogs {
+		locales = append(locales, c.Locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Lookup 按语言标签（不区分大小写，可用 _ 分隔）查找目录，只有语言没有地区时（如 en）匹配该语言的第一个目录
+func Lookup(tag string) (*Catalog, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return nil, false
+	}
+	if c, ok := catalogs[tag]; ok {
+		return c, true
+	}
+	language := tag
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		language = tag[:i]
+	}
+	for _, locale := range Supported() {
+		if strings.HasPrefix(strings.ToLower(locale), language+"-") {
+			return catalogs[strings.ToLower(locale)], true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate 按 Accept-Language 选择支持的语言，按权重从高到低匹配，都不支持时返回 false
+func Negotiate(acceptLanguage string) (string, bool) {
+	type candidate struct {
+		tag    string
+		weight float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		weight := 1.0
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil {
+					weight = v
+				}
+			}
+		}
+		if fields[0] != "" && fields[0] != "*" && weight > 0 {
+			candidates = append(candidates, candidate{tag: fields[0], weight: weight})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].weight > candidates[j].weight })
+	for _, c := range candidates {
+		if catalog, ok := Lookup(c.tag); ok {
+			return catalog.Locale, true
+		}
+	}
+	return "", false
+}
+
+// SetDefault 设置默认语言（app.locale），用于没有请求上下文的文字（告警、日结邮件）及无法协商语言的请求
+func SetDefault(locale string) {
+	if c, ok := Lookup(locale); ok {
+		defaultLocale.Store(c.Locale)
+	}
+}
+
+// Default 默认语言，未设置时为源语言
+func Default() string {
+	if locale, ok := defaultLocale.Load().(string); ok {
+		return locale
+	}
+	return Source
+}
+
+// For 语言的目录，不支持的语言使用默认语言
+func For(locale string) *Catalog {
+	if c, ok := Lookup(locale); ok {
+		return c
+	}
+	c, _ := Lookup(Default())
+	return c
+}
+
+// T 按语言输出消息，目录中缺少时依次使用源语言及消息代码本身
+func T(locale, code string, args ...interface{}) string {
+	return For(locale).T(code, args...)
+}
+
+// Sprintf 按默认语言输出消息
+func Sprintf(code string, args ...interface{}) string {
+	return T(Default(), code, args...)
+}
+
+// Translate 把源语言文字翻译为指定语言，不是目录中的文字（如错误详情）时返回 false
+func Translate(locale, text string) (string, bool) {
+	sourceOnce.Do(func() {
+		sourceIndex = make(map[string]string)
+		if source, ok := catalogs[strings.ToLower(Source)]; ok {
+			for code, message := range source.Messages {
+				if !strings.Contains(message, "%") {
+					sourceIndex[message] = code
+				}
+			}
+		}
+	})
+	code, ok := sourceIndex[text]
+	if !ok {
+		return text, false
+	}
+	return T(locale, code), true
+}
+
+// Missing 各语言目录中缺少（或多出）的消息代码，与源语言目录比较；目录完整时返回空
+func Missing() map[string][]string {
+	source, ok := catalogs[strings.ToLower(Source)]
+	if !ok {
+		return map[string][]string{Source: {"(目录不存在)"}}
+	}
+	missing := make(map[string][]string)
+	for _, c := range catalogs {
+		if c == source {
+			continue
+		}
+		for code := range source.Messages {
+			if _, ok := c.Messages[code]; !ok {
+				missing[c.Locale] = append(missing[c.Locale], code)
+			}
+		}
+		for code := range c.Messages {
+			if _, ok := source.Messages[code]; !ok {
+				missing[c.Locale] = append(missing[c.Locale], code+"（源语言中没有）")
+			}
+		}
+		sort.Strings(missing[c.Locale])
+	}
+	return missing
+}
+
+// T 按目录输出消息，缺少时依次使用源语言及消息代码本身
+func (c *Catalog) T(code string, args ...interface{}) string {
+	message, ok := c.Messages[code]
+	if !ok {
+		if source, found := catalogs[strings.ToLower(Source)]; found {
+			message, ok = source.Messages[code]
+		}
+	}
+	if !ok {
+		return code
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// FormatDateTime 按语言格式输出日期时间
+func (c *Catalog) FormatDateTime(t time.Time) string {
+	return t.Format(c.DateTime)
+}
+
+// FormatDate 按语言格式输出日期
+func (c *Catalog) FormatDate(t time.Time) string {
+	return t.Format(c.Date)
+}
+
+// FormatInt 按语言的千位分隔符输出整数
+func (c *Catalog) FormatInt(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	return sign + c.group(digits)
+}
+
+// FormatFloat 按语言的小数点及千位分隔符输出小数，prec 为小数位数
+func (c *Catalog) FormatFloat(f float64, prec int) string {
+	s := strconv.FormatFloat(f, 'f', prec, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+	s = sign + c.group(whole)
+	if hasFraction {
+		s += c.Decimal + fraction
+	}
+	return s
+}
+
+// group 整数部分按千位分组
+func (c *Catalog) group(digits string) string {
+	if c.Thousands == "" || len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(c.Thousands)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
@@ -0,0 +1,190 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestCatalogsComplete 每种语言的目录都包含源语言目录中的全部消息代码，且没有多出的代码
+func TestCatalogsComplete(t *testing.T) {
+	if len(Supported()) < 2 {
+		t.Fatalf("supported locales = %v", Supported())
+	}
+	for locale, codes := range Missing() {
+		for _, code := range codes {
+			t.Errorf("%s: %s", locale, code)
+		}
+	}
+	for _, tag := range Supported() {
+		c, ok := Lookup(tag)
+		if !ok {
+			t.Fatalf("supported locale %s has no catalog", tag)
+		}
+		if c.Name == "" || c.DateTime == "" || c.Date == "" || c.Decimal == "" {
+			t.Errorf("%s: name or formats not set", tag)
+		}
+		for code, message := range c.Messages {
+			if strings.TrimSpace(message) == "" {
+				t.Errorf("%s: %s is empty", tag, code)
+			}
+		}
+	}
+}
+
+// verbPattern fmt 占位符，可带参数下标
+var verbPattern = regexp.MustCompile(`%(?:\[(\d+)\])?[-+# 0]*\d*(?:\.\d*)?([a-zA-Z%])`)
+
+// verbs 消息中各参数对应的占位符，按参数序号（从1开始）
+func verbs(message string) map[int]string {
+	args := make(map[int]string)
+	next := 1
+	for _, m := range verbPattern.FindAllStringSubmatch(message, -1) {
+		if m[2] == "%" {
+			continue
+		}
+		if m[1] != "" {
+			next, _ = strconv.Atoi(m[1])
+		}
+		args[next] = m[2]
+		next++
+	}
+	return args
+}
+
+// TestCatalogPlaceholdersMatch 译文的占位符与源语言一致（可用 %[n] 调整顺序），否则按该语言输出时参数错位
+func TestCatalogPlaceholdersMatch(t *testing.T) {
+	source, _ := Lookup(Source)
+	for _, tag := range Supported() {
+		c, _ := Lookup(tag)
+		for code, message := range c.Messages {
+			original, ok := source.Messages[code]
+			if !ok {
+				continue // 多出的代码由 TestCatalogsComplete 报告
+			}
+			want := verbs(original)
+			got := verbs(message)
+			if len(got) != len(want) {
+				t.Errorf("%s %s: placeholders %v, source has %v", tag, code, got, want)
+				continue
+			}
+			for i, verb := range want {
+				if got[i] != verb {
+					t.Errorf("%s %s: argument %d formatted with %%%s, source uses %%%s", tag, code, i, got[i], verb)
+				}
+			}
+		}
+	}
+}
+
+// TestTranslateIsUnambiguous 源语言中文字相同的消息在各语言中的译文也须相同，否则 Translate 的结果不确定
+func TestTranslateIsUnambiguous(t *testing.T) {
+	source, _ := Lookup(Source)
+	byText := make(map[string][]string)
+	for code, message := range source.Messages {
+		if !strings.Contains(message, "%") {
+			byText[message] = append(byText[message], code)
+		}
+	}
+	for _, tag := range Supported() {
+		c, _ := Lookup(tag)
+		for text, codes := range byText {
+			for _, code := range codes[1:] {
+				if c.Messages[code] != c.Messages[codes[0]] {
+					t.Errorf("%s: %q is %s and %s with different translations", tag, text, codes[0], code)
+				}
+			}
+		}
+	}
+	if got, ok := Translate(EnUS, source.Messages["api.annotation_added"]); !ok || got != "Annotation added" {
+		t.Errorf("Translate = %q, %v", got, ok)
+	}
+}
+
+// codePattern 消息代码
+var codePattern = regexp.MustCompile(`^(alert|api|mail|status)\.[a-z0-9_]+(\.[a-z0-9_]+)*$`)
+
+// translators 以消息代码为第一个参数的函数：目录的 T、i18n.T/Sprintf，及汇总邮件、状态页中按代码输出一行的局部函数；
+// flag 另外按 code_yes、code_no 输出
+var translators = map[string][]string{
+	"T":       {""},
+	"Sprintf": {""},
+	"line":    {""},
+	"section": {""},
+	"flag":    {"", "_yes", "_no"},
+}
+
+// calledName 被调用函数的名称，如 catalog.T 为 T
+func calledName(call *ast.CallExpr) string {
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		return fn.Name
+	case *ast.SelectorExpr:
+		return fn.Sel.Name
+	}
+	return ""
+}
+
+// TestCodesUsedInSourceExist 代码中以字符串写出的消息代码都在源语言目录中
+func TestCodesUsedInSourceExist(t *testing.T) {
+	source, _ := Lookup(Source)
+	root := filepath.Join("..", "..")
+	fset := token.NewFileSet()
+	checked := 0
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name := entry.Name(); path != root && (name == "i18n" || name == "testdata" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) == 0 {
+				return true
+			}
+			suffixes, ok := translators[calledName(call)]
+			if !ok {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			code, err := strconv.Unquote(lit.Value)
+			if err != nil || !codePattern.MatchString(code) {
+				return true
+			}
+			checked++
+			for _, suffix := range suffixes {
+				if _, ok := source.Messages[code+suffix]; !ok {
+					t.Errorf("%s: message code %q is not in the %s catalog", fset.Position(lit.Pos()), code+suffix, Source)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked == 0 {
+		t.Fatal("no message codes found in the source tree")
+	}
+}
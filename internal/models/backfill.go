@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// 回填任务状态
+const (
+	BackfillStatusPending   = "pending"   // 等待前一个任务完成
+	BackfillStatusRunning   = "running"   // 回填中
+	BackfillStatusPaused    = "paused"    // 已暂停，重启后保持暂停，继续后从检查点开始
+	BackfillStatusCompleted = "completed" // 已完成
+	BackfillStatusFailed    = "failed"    // 回填失败，可从检查点继续
+)
+
+// BackfillJob 迁移登记的后台回填任务
+//
+// 按行ID顺序回填 ID 不大于 MaxID 的待回填行（之后写入的行由写入方填好），LastID 为已回填的最后一行，
+// 每批回填与检查点在同一事务中保存，重启或继续时从其之后开始。同名任务只创建一次。
+type BackfillJob struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	Name        string     `json:"name" gorm:"size:100;not null;uniqueIndex"`
+	Description string     `json:"description" gorm:"size:255"`
+	Status      string     `json:"status" gorm:"size:20;not null;index"`
+	MaxID       uint       `json:"max_id"`  // 登记时表中最大的行ID
+	LastID      uint       `json:"last_id"` // 检查点
+	Total       int64      `json:"total"`   // 登记时待回填的行数
+	Processed   int64      `json:"processed"`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (BackfillJob) TableName() string {
+	return "backfill_jobs"
+}
@@ -7,17 +7,32 @@ import (
 
 // BarcodeRecord 扫码记录模型
 type BarcodeRecord struct {
-	ID        uint           `json:"id" gorm:"primarykey"`
-	Content   string         `json:"content" gorm:"not null;index" validate:"required,min=1,max=100"`
-	Length    int            `json:"length" gorm:"not null"`
-	Type      string         `json:"type" gorm:"size:50;index"`
-	Status    string         `json:"status" gorm:"size:20;default:success"`
-	Message   string         `json:"message" gorm:"size:255"`
-	DeviceID  *uint          `json:"device_id" gorm:"index"`
-	Device    *Device        `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primarykey"`
+	Content           string         `json:"content" gorm:"not null;index" validate:"required,min=1,max=100"`
+	Length            int            `json:"length" gorm:"not null"`
+	Type              string         `json:"type" gorm:"size:50;index"`
+	Status            string         `json:"status" gorm:"size:20;default:success"`
+	Message           string         `json:"message" gorm:"size:255"`
+	DeviceID          *uint          `json:"device_id" gorm:"index"`
+	Device            *Device        `json:"device,omitempty" gorm:"foreignKey:DeviceID"`
+	DurationMS        int64          `json:"duration_ms"`                               // 扫码耗时（首个按键到最后一个按键），未知时为0
+	SuspectTruncation bool           `json:"suspect_truncation"`                        // 按键时序异常，条码可能因漏键被截断
+	MinKeyIntervalMS  int64          `json:"min_key_interval_ms"`                       // 最小按键间隔，非键盘来源或按键时间未知时为0
+	MaxKeyIntervalMS  int64          `json:"max_key_interval_ms"`                       // 最大按键间隔
+	AvgKeyIntervalMS  float64        `json:"avg_key_interval_ms"`                       // 平均按键间隔，按现场扫码枪的实际节奏调整 timeout_ms 时参考
+	Derived           StringMap      `json:"derived,omitempty" gorm:"type:json"`        // 分类规则提取的派生字段
+	SecondaryDevices  UintList       `json:"secondary_devices,omitempty" gorm:"type:json"` // 跨设备合并时同时扫到该内容的其他设备
+	Source            string         `json:"source" gorm:"size:20;index;default:local"` // 扫码来源：local（本机钩子及接口）、ingest（外部系统推送）、agent（键盘钩子代理）
+	Input             string         `json:"input,omitempty" gorm:"size:64"`           // 采集来源标识：hook、rawinput、evdev、serial:<串口>、network:<扫码枪IP>、agent，接口提交及外部推送为空
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
+	ScanID            string         `json:"scan_id" gorm:"size:32;uniqueIndex:idx_barcode_records_scan_id,where:scan_id <> ''"`       // 幂等键，主库与本地备份库对账时据此去重
+	PublicID          string         `json:"public_id" gorm:"size:26;uniqueIndex:idx_barcode_records_public_id,where:public_id <> ''"` // 对外公开ID（ULID），跨工位唯一且按时间有序
+	ChainStation      string         `json:"chain_station,omitempty" gorm:"size:100;index"` // 完整性哈希链所属工位，按写入记录的工位各自成链
+	ChainPrev         string         `json:"chain_prev,omitempty" gorm:"size:64;index"`     // 链上前一条记录的哈希，链的第一条为空
+	ChainHash         string         `json:"chain_hash,omitempty" gorm:"size:64"`           // 前一条哈希与本条记录字段的SHA-256，未启用完整性模式时写入的记录为空
+	ChainVersion      int            `json:"chain_version,omitempty" gorm:"not null;default:0"` // 链哈希的计算版本，0为按毫秒计入时间的旧版本
 }
 
 // TableName 指定表名
@@ -25,6 +40,14 @@ func (BarcodeRecord) TableName() string {
 	return "barcode_records"
 }
 
+// 扫码来源
+const (
+	BarcodeSourceLocal      = "local"      // 本机键盘钩子、接口提交及网络扫码枪
+	BarcodeSourceIngest     = "ingest"     // 外部系统通过 /api/ingest 推送
+	BarcodeSourceAgent      = "agent"      // 用户会话中的键盘钩子代理（scanner-agent）转交
+	BarcodeSourceSimulation = "simulation" // 模拟扫码（按场景文件播放，用于演示及回归测试）
+)
+
 // Device 设备模型
 type Device struct {
 	ID          uint           `json:"id" gorm:"primarykey"`
@@ -34,11 +57,27 @@ type Device struct {
 	SerialNo    string         `json:"serial_no" gorm:"size:100;uniqueIndex"`
 	Description string         `json:"description" gorm:"size:255"`
 	Status      string         `json:"status" gorm:"size:20;default:active"`
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	IsActive    bool           `json:"is_active" gorm:"default:true"` // 每个工位至多一个活跃设备，由 idx_devices_station_active 保证
+	Station     string         `json:"station" gorm:"size:100;index"` // 所属工位，多个工位共用数据库时区分各自的活跃设备
+	DevicePath  string         `json:"device_path" gorm:"size:500"`   // 键盘设备路径（Raw Input），按键采集方式为 rawinput 时用于识别扫码来自哪个设备
 	LastSeen    *time.Time     `json:"last_seen"`
+	ProfileID   *uint          `json:"profile_id" gorm:"index"` // 已应用的扫码枪配置档案
+	Preset      string         `json:"preset" gorm:"size:50"`   // 扫码参数预设，为当前活跃设备且未配置 scanner.preset 时用于键盘钩子
+	PublicID    string         `json:"public_id" gorm:"size:26;uniqueIndex:idx_devices_public_id,where:public_id <> ''"` // 对外公开ID（ULID）
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 网络扫码枪命令通道
+	CommandAddr      string     `json:"command_addr" gorm:"size:100"`                 // 设备TCP地址（host:port），为空表示不支持命令
+	CommandTemplates StringMap  `json:"command_templates,omitempty" gorm:"type:json"` // 命令名 -> 命令模板，{参数名} 为参数占位符
+	AllowedCommands  StringList `json:"allowed_commands,omitempty" gorm:"type:json"`  // 允许下发的命令白名单
+
+	// 按键超时，仅对本机按键采集来源（键盘钩子、rawinput、evdev）生效
+	TimeoutMS        int  `json:"timeout_ms"`         // 手动指定的按键超时（毫秒），0表示使用 scanner.timeout_ms；优先于自动调整
+	AdaptiveTimeout  bool `json:"adaptive_timeout"`   // 按有效扫码的按键间隔自动调整按键超时
+	LearnedTimeoutMS int  `json:"learned_timeout_ms"` // 自动调整学到的按键超时，0表示尚未学到
+	TimeoutSamples   int  `json:"timeout_samples"`    // 自动调整已观察的有效扫码数
 	
 	// 关联关系
 	BarcodeRecords []BarcodeRecord `json:"barcode_records,omitempty" gorm:"foreignKey:DeviceID"`
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NamedCount 按名称统计的扫码数
+type NamedCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// OperatorProductivity 一名操作员的扫码效率，操作员按隐私设置可能为化名
+type OperatorProductivity struct {
+	Operator      string    `json:"operator"`
+	Total         int64     `json:"total"`
+	Valid         int64     `json:"valid"`
+	Invalid       int64     `json:"invalid"`
+	ValidRatio    float64   `json:"valid_ratio"`
+	InvalidRatio  float64   `json:"invalid_ratio"`
+	ActiveSeconds int64     `json:"active_seconds"` // 在岗时间，超过空闲阈值的扫码间隔不计入
+	ScansPerHour  float64   `json:"scans_per_hour"` // 按在岗时间计算，在岗时间为0时为0
+	FirstScan     time.Time `json:"first_scan"`
+	LastScan      time.Time `json:"last_scan"`
+}
+
+// DeviceTotal 按设备统计的扫码数
+type DeviceTotal struct {
+	DeviceID *uint  `json:"device_id"`
+	Name     string `json:"name"`
+	Count    int64  `json:"count"`
+}
+
+// DailyGoal 产量目标在生产日的达成情况
+type DailyGoal struct {
+	GoalID    uint    `json:"goal_id"`
+	Name      string  `json:"name"`
+	Target    int64   `json:"target"`
+	Count     int64   `json:"count"`
+	Percent   float64 `json:"percent"`
+	Completed bool    `json:"completed"`
+}
+
+// CarriedSession 日结时仍在进行、转入下一生产日的会话（序列号跟踪）
+type CarriedSession struct {
+	Rule      string `json:"rule"`
+	Prefix    string `json:"prefix"`
+	DeviceID  uint   `json:"device_id"`
+	LastValue int64  `json:"last_value"`
+	Pending   int    `json:"pending"` // 等待乱序补扫的缺失序号数
+	Note      string `json:"note"`
+}
+
+// DailyBreakdown 日结汇总的明细
+type DailyBreakdown struct {
+	ByType      []NamedCount     `json:"by_type"`
+	ByDevice    []DeviceTotal    `json:"by_device"`
+	ByOperator  []NamedCount     `json:"by_operator"` // 按分类规则提取的操作员字段统计，未提取到操作员的记录不计入
+	Goals       []DailyGoal      `json:"goals"`
+	CarriedOver []CarriedSession `json:"carried_over"`
+
+	Operators []OperatorProductivity `json:"operators,omitempty"` // 按操作员的效率，隐私设置关闭统计时为空
+}
+
+// Value 实现 driver.Valuer
+func (b DailyBreakdown) Value() (driver.Value, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (b *DailyBreakdown) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*b = DailyBreakdown{}
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析 %T 为 DailyBreakdown", value)
+	}
+	if len(data) == 0 {
+		*b = DailyBreakdown{}
+		return nil
+	}
+	return json.Unmarshal(data, b)
+}
+
+// DailySummary 生产日的日结汇总
+//
+// 日结后该生产日 [From, To) 内的扫码记录不允许修改（上传图片、删除），管理员可强制修改并记录审计日志。
+// 重新开放后 Closed 为 false，再次日结时重新生成汇总。
+type DailySummary struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	Day        string         `json:"day" gorm:"size:10;not null;uniqueIndex"` // 生产日（工位时区）
+	Station    string         `json:"station" gorm:"size:100"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Closed     bool           `json:"closed" gorm:"index"`
+	Total      int64          `json:"total"`
+	Invalid    int64          `json:"invalid"`    // 处理失败的扫码数
+	Duplicates int64          `json:"duplicates"` // 当天重复扫码的次数（首次之后的扫码）
+	Breakdown  DailyBreakdown `json:"breakdown" gorm:"type:text"`
+	Note       string         `json:"note" gorm:"size:255"`
+	ClosedBy   string         `json:"closed_by" gorm:"size:100"`
+	ClosedAt   time.Time      `json:"closed_at"`
+	ReopenedBy string         `json:"reopened_by,omitempty" gorm:"size:100"`
+	ReopenedAt *time.Time     `json:"reopened_at,omitempty"`
+	ExportPath string         `json:"export_path,omitempty" gorm:"size:255"` // 自动导出的汇总文件
+	EmailedAt  *time.Time     `json:"emailed_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (DailySummary) TableName() string {
+	return "daily_summaries"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// BarcodeConsumption 下游系统（如ERP）已消费的扫码记录，同一消费方对同一记录只登记一次
+type BarcodeConsumption struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	Consumer   string    `json:"consumer" gorm:"size:64;not null;uniqueIndex:idx_barcode_consumptions_consumer_record"`
+	RecordID   uint      `json:"record_id" gorm:"not null;uniqueIndex:idx_barcode_consumptions_consumer_record;index"`
+	ConsumedAt time.Time `json:"consumed_at"`
+}
+
+// TableName 指定表名
+func (BarcodeConsumption) TableName() string {
+	return "barcode_consumptions"
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Counter 持久化计数器，按作用域及名称区分
+//
+// 计数在内存中累加、定期批量写入。每次清零或轮转 Epoch 加1，写入时只接受不小于库中批次的值，
+// 同一批次内取较大值，因此延迟到达的写入不会让计数倒退，也不会覆盖清零。
+type Counter struct {
+	ID        uint       `json:"-" gorm:"primarykey"`
+	Scope     string     `json:"scope" gorm:"size:50;not null;uniqueIndex:idx_counter_key"`
+	Name      string     `json:"name" gorm:"size:100;not null;uniqueIndex:idx_counter_key"`
+	Value     int64      `json:"value"`
+	Epoch     int64      `json:"epoch"`                 // 清零及轮转的次数
+	Period    string     `json:"period" gorm:"size:50"` // 按周期轮转的计数器当前所属周期，如 2026-10-16 或 2026-10-16/早班
+	LastValue int64      `json:"last_value"`            // 上次清零或轮转前的值
+	RolledAt  *time.Time `json:"rolled_at,omitempty"`   // 上次清零或轮转的时间
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Counter) TableName() string {
+	return "counters"
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DistinctSketch 某个生产日不同条码数量的 HyperLogLog 草图
+type DistinctSketch struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Day       string    `json:"day" gorm:"size:10;not null;uniqueIndex:idx_distinct_sketch_key"`
+	DeviceID  uint      `json:"device_id" gorm:"not null;default:0;uniqueIndex:idx_distinct_sketch_key"` // 0 表示全工位
+	Registers []byte    `json:"-"`
+	Estimate  int64     `json:"estimate"` // 保存时的估算值
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (DistinctSketch) TableName() string {
+	return "distinct_sketches"
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// 目标统计范围
+const (
+	GoalScopeStation = "station" // 本工位全部扫码
+	GoalScopeDevice  = "device"  // 指定设备
+	GoalScopeType    = "type"    // 指定条码类型
+	GoalScopeShift   = "shift"   // 指定生产班次
+)
+
+// 目标重复方式
+const (
+	GoalRecurrenceOnce     = "once"     // 仅 Date 当天
+	GoalRecurrenceDaily    = "daily"    // 每天
+	GoalRecurrenceWeekdays = "weekdays" // 周一至周五
+)
+
+// Goal 扫码产量目标
+type Goal struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	Name        string    `json:"name" gorm:"size:100;not null"`
+	Scope       string    `json:"scope" gorm:"size:20;not null"`    // station, device, type, shift
+	DeviceID    *uint     `json:"device_id,omitempty" gorm:"index"` // scope=device
+	BarcodeType string    `json:"barcode_type,omitempty" gorm:"size:50"`
+	Shift       string    `json:"shift,omitempty" gorm:"size:50"` // scope=shift，对应 idle.shifts 中的班次名
+	Target      int64     `json:"target" gorm:"not null"`
+	Recurrence  string    `json:"recurrence" gorm:"size:20;default:daily"` // once, daily, weekdays
+	Date        string    `json:"date,omitempty" gorm:"size:10"`           // recurrence=once 时的生产日，如 2024-01-01
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Goal) TableName() string {
+	return "goals"
+}
+
+// GoalProgress 目标在某个生产日的进度
+type GoalProgress struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	GoalID      uint       `json:"goal_id" gorm:"not null;uniqueIndex:idx_goal_progress_goal_day"`
+	Day         string     `json:"day" gorm:"size:10;not null;uniqueIndex:idx_goal_progress_goal_day"`
+	Count       int64      `json:"count"`
+	Milestone   int        `json:"milestone"`              // 已通知的最高进度百分比
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // 达成目标的时间
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (GoalProgress) TableName() string {
+	return "goal_progress"
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// DeviceHealthSnapshot 设备健康评分快照
+type DeviceHealthSnapshot struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	DeviceID       uint      `json:"device_id" gorm:"not null;index:idx_health_snapshot_device"`
+	Score          float64   `json:"score"`
+	Scans          int64     `json:"scans"`
+	InvalidRatio   float64   `json:"invalid_ratio"`
+	DuplicateRatio float64   `json:"duplicate_ratio"`
+	GapRate        float64   `json:"gap_rate"`
+	AvgScanMS      float64   `json:"avg_scan_ms"`
+	CreatedAt      time.Time `json:"created_at" gorm:"index:idx_health_snapshot_device"`
+}
+
+// TableName 指定表名
+func (DeviceHealthSnapshot) TableName() string {
+	return "device_health_snapshots"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Heartbeat 工位心跳记录，用于区分停机（缺少心跳）与空闲（有心跳但无扫码）
+type Heartbeat struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	Station    string    `json:"station" gorm:"size:100;index:idx_heartbeat_station_at"`
+	Since      time.Time `json:"since"`                                    // 本周期开始时间（上次心跳或启动时间）
+	At         time.Time `json:"at" gorm:"index:idx_heartbeat_station_at"` // 心跳时间
+	Scans      int64     `json:"scans"`                                    // 本周期内的扫码数
+	Components StringMap `json:"components" gorm:"type:json"`              // 组件名 -> 状态
+	Delayed    bool      `json:"delayed"`                                  // 数据库不可用期间缓存、恢复后补写
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (Heartbeat) TableName() string {
+	return "heartbeats"
+}
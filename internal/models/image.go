@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// BarcodeImage 扫码记录关联的条码图片
+type BarcodeImage struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	RecordID    uint      `json:"record_id" gorm:"not null;uniqueIndex"`
+	Path        string    `json:"-" gorm:"size:255;not null"` // 相对于图片存储目录的路径
+	ContentType string    `json:"content_type" gorm:"size:50"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (BarcodeImage) TableName() string {
+	return "barcode_images"
+}
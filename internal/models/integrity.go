@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// 完整性检查点的来源
+const (
+	CheckpointReasonRetention = "retention" // 按保留期限清理旧记录前
+)
+
+// IntegrityCheckpoint 完整性哈希链的检查点
+//
+// 清理旧记录前记下被清理部分在链上最后一条记录的哈希，之后的记录可从检查点开始校验，
+// 检查点之前的记录被物理删除后剩余的链仍然可以验证。
+type IntegrityCheckpoint struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Station   string    `json:"station" gorm:"size:100;index:idx_integrity_checkpoints_station_record,priority:1"`
+	RecordID  uint      `json:"record_id" gorm:"index:idx_integrity_checkpoints_station_record,priority:2"` // 链上被清理的一段的最后一条记录
+	Hash      string    `json:"hash" gorm:"size:64;not null"`                                               // 该记录的链哈希
+	Reason    string    `json:"reason" gorm:"size:20"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (IntegrityCheckpoint) TableName() string {
+	return "integrity_checkpoints"
+}
+
+// BarcodeAnnotation 扫码记录的批注
+//
+// 批注单独追加保存，不修改扫码记录本身，启用完整性模式时记录的哈希保持不变。
+type BarcodeAnnotation struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	RecordID  uint      `json:"record_id" gorm:"not null;index"`
+	Note      string    `json:"note" gorm:"size:500;not null"`
+	Author    string    `json:"author" gorm:"size:100"` // 令牌名称，管理员为 admin
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (BarcodeAnnotation) TableName() string {
+	return "barcode_annotations"
+}
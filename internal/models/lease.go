@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// LeaderLease 采集租约，主备两台工位机共用数据库时只有持有者采集扫码
+type LeaderLease struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	Name       string    `json:"name" gorm:"size:100;uniqueIndex"` // 租约名称，按工位区分
+	Holder     string    `json:"holder" gorm:"size:200"`           // 持有者（工位机标识）
+	AcquiredAt time.Time `json:"acquired_at"`                      // 当前持有者取得租约的时间
+	ExpiresAt  time.Time `json:"expires_at"`                       // 持有者未续租时在此时间后失效
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (LeaderLease) TableName() string {
+	return "leader_leases"
+}
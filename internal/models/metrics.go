@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// MetricSnapshot 监控指标快照，每次采集每个样本一行
+type MetricSnapshot struct {
+	ID     uint      `json:"id" gorm:"primarykey"`
+	Metric string    `json:"metric" gorm:"size:100;not null;index:idx_metric_snapshot_metric_at"`
+	Type   string    `json:"type" gorm:"size:10"`     // gauge 或 counter
+	Labels StringMap `json:"labels" gorm:"type:json"` // 样本标签，无标签时为NULL
+	Value  float64   `json:"value"`
+	At     time.Time `json:"at" gorm:"index:idx_metric_snapshot_metric_at;index"` // 采集时间
+}
+
+// TableName 指定表名
+func (MetricSnapshot) TableName() string {
+	return "metrics_snapshots"
+}
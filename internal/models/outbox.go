@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// 出站投递状态
+const (
+	OutboxStatusPending   = "pending"
+	OutboxStatusDelivered = "delivered"
+)
+
+// OutboxEvent 出站事件，与业务数据在同一事务中写入
+type OutboxEvent struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	EventKey  string    `json:"event_key" gorm:"size:100;not null;uniqueIndex"` // 幂等键，下游据此去重
+	EventType string    `json:"event_type" gorm:"size:50;not null"`
+	Payload   string    `json:"payload" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// OutboxDelivery 出站事件在单个输出端的投递状态
+type OutboxDelivery struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	EventID       uint       `json:"event_id" gorm:"not null;uniqueIndex:idx_outbox_delivery_key"`
+	Sink          string     `json:"sink" gorm:"size:50;not null;uniqueIndex:idx_outbox_delivery_key;index:idx_outbox_delivery_status"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:'pending';index:idx_outbox_delivery_status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (OutboxDelivery) TableName() string {
+	return "outbox_deliveries"
+}
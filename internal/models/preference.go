@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ClientPreference 看板客户端的偏好设置（布局、设备过滤及显示选项），以客户端标识区分
+//
+// Data 为客户端自行定义的 JSON，服务端只校验格式及大小，不解析内容。
+type ClientPreference struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	ClientKey string    `json:"client_key" gorm:"size:64;not null;uniqueIndex"`
+	Data      string    `json:"-" gorm:"type:text"`
+	Size      int       `json:"size"`                       // Data 的字节数
+	UpdatedBy string    `json:"updated_by" gorm:"size:100"` // 最后修改者（管理员或令牌名称）
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (ClientPreference) TableName() string {
+	return "client_preferences"
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ProfileCode 扫码枪设置码
+type ProfileCode struct {
+	Value string `json:"value"` // 设置码内容
+	Label string `json:"label"` // 说明，如“启用Code128”
+}
+
+// ProfileCodes 以JSON存储的设置码列表，按应用顺序排列
+type ProfileCodes []ProfileCode
+
+// Value 实现 driver.Valuer
+func (c ProfileCodes) Value() (driver.Value, error) {
+	if c == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (c *ProfileCodes) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析 %T 为 ProfileCodes", value)
+	}
+	if len(data) == 0 {
+		*c = nil
+		return nil
+	}
+	return json.Unmarshal(data, c)
+}
+
+// ScannerProfile 扫码枪配置档案，记录某型号设备依次扫描的设置码，更换设备时据此重新配置
+type ScannerProfile struct {
+	ID          uint         `json:"id" gorm:"primarykey"`
+	Name        string       `json:"name" gorm:"size:100;not null;uniqueIndex"`
+	Vendor      string       `json:"vendor" gorm:"size:100"`
+	Model       string       `json:"model" gorm:"size:100"` // 适用的设备型号
+	Description string       `json:"description" gorm:"size:255"`
+	Codes       ProfileCodes `json:"codes" gorm:"type:json"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ScannerProfile) TableName() string {
+	return "scanner_profiles"
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// crockfordAlphabet ULID使用的Crockford Base32字母表
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// PublicIDLength ULID字符串长度
+const PublicIDLength = 26
+
+// IDGenerator 对外公开ID生成器
+type IDGenerator interface {
+	NewID() string
+}
+
+// ULIDGenerator 生成ULID（48位毫秒时间戳 + 80位随机数），同一毫秒内随机部分递增以保证有序
+type ULIDGenerator struct {
+	Now     func() time.Time
+	Entropy io.Reader
+
+	mu       sync.Mutex
+	lastMS   uint64
+	lastRand [10]byte
+}
+
+// NewULIDGenerator 创建使用当前时间及加密随机数的ULID生成器
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{Now: time.Now, Entropy: rand.Reader}
+}
+
+// NewID 生成ULID
+func (g *ULIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.Now().UnixMilli())
+	if ms == g.lastMS {
+		// 同一毫秒内随机部分加一
+		for i := len(g.lastRand) - 1; i >= 0; i-- {
+			g.lastRand[i]++
+			if g.lastRand[i] != 0 {
+				break
+			}
+		}
+	} else {
+		if _, err := io.ReadFull(g.Entropy, g.lastRand[:]); err != nil {
+			panic("读取随机数失败: " + err.Error())
+		}
+		g.lastMS = ms
+	}
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	copy(id[6:], g.lastRand[:])
+	return encodeULID(id)
+}
+
+// encodeULID 将128位ID编码为26个Crockford Base32字符
+func encodeULID(id [16]byte) string {
+	out := make([]byte, PublicIDLength)
+	// 128位按5位一组编码，首字符只使用高3位
+	var acc uint64
+	bits := 2 // 补齐到130位
+	j := 0
+	for _, b := range id {
+		acc = acc<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[j] = crockfordAlphabet[(acc>>uint(bits))&0x1f]
+			j++
+		}
+	}
+	return string(out)
+}
+
+// IsPublicID 判断字符串是否为ULID格式的公开ID
+func IsPublicID(value string) bool {
+	if len(value) != PublicIDLength || value[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z') || c == 'I' || c == 'L' || c == 'O' || c == 'U' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	idGeneratorMu sync.RWMutex
+	idGenerator   IDGenerator = NewULIDGenerator()
+)
+
+// SetIDGenerator 替换公开ID生成器，用于生成可预测的ID
+func SetIDGenerator(generator IDGenerator) {
+	idGeneratorMu.Lock()
+	defer idGeneratorMu.Unlock()
+	idGenerator = generator
+}
+
+// NewPublicID 生成公开ID
+func NewPublicID() string {
+	idGeneratorMu.RLock()
+	defer idGeneratorMu.RUnlock()
+	return idGenerator.NewID()
+}
+
+// BeforeCreate 写入前生成公开ID，已有公开ID（如同步、导入的记录）时保留
+func (r *BarcodeRecord) BeforeCreate(tx *gorm.DB) error {
+	if r.PublicID == "" {
+		r.PublicID = NewPublicID()
+	}
+	return nil
+}
+
+// BeforeCreate 写入前生成公开ID，已有公开ID时保留
+func (d *Device) BeforeCreate(tx *gorm.DB) error {
+	if d.PublicID == "" {
+		d.PublicID = NewPublicID()
+	}
+	return nil
+}
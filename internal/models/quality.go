@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// QualityTypeStats 一种条码类型的数据质量
+type QualityTypeStats struct {
+	Type               string  `json:"type"`
+	Total              int64   `json:"total"`
+	CheckDigitChecked  int64   `json:"check_digit_checked"`  // 校验了校验位的扫码数（GS1码制）
+	CheckDigitFailures int64   `json:"check_digit_failures"` // 校验位错误的扫码数
+	SpecViolations     int64   `json:"spec_violations"`      // 含规范外字符的扫码数（Code 128）
+	Malformed          int64   `json:"malformed"`            // 校验位错误、含规范外字符或格式无效的扫码数
+	ValidRate          float64 `json:"valid_rate"`           // 合格扫码的百分比
+}
+
+// QualityDeviceStats 一台设备的数据质量
+type QualityDeviceStats struct {
+	DeviceID      *uint   `json:"device_id"`
+	Name          string  `json:"name"`
+	Total         int64   `json:"total"`
+	Malformed     int64   `json:"malformed"`
+	MalformedRate float64 `json:"malformed_rate"` // 不合格扫码的百分比
+}
+
+// QualityBreakdown 数据质量报告的明细
+type QualityBreakdown struct {
+	ByType   []QualityTypeStats   `json:"by_type"`
+	ByDevice []QualityDeviceStats `json:"by_device"` // 按不合格扫码数从多到少排序
+}
+
+// Value 实现 driver.Valuer
+func (b QualityBreakdown) Value() (driver.Value, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (b *QualityBreakdown) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*b = QualityBreakdown{}
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析 %T 为 QualityBreakdown", value)
+	}
+	if len(data) == 0 {
+		*b = QualityBreakdown{}
+		return nil
+	}
+	return json.Unmarshal(data, b)
+}
+
+// QualityReport 时间范围 [From, To) 内扫码的数据质量报告
+//
+// 定时任务每个统计窗口保存一份全工位的报告（DeviceID 为nil），按需查询的报告不保存。
+type QualityReport struct {
+	ID            uint             `json:"id,omitempty" gorm:"primarykey"`
+	From          time.Time        `json:"from"`
+	To            time.Time        `json:"to" gorm:"index"`
+	DeviceID      *uint            `json:"device_id,omitempty"`
+	Total         int64            `json:"total"`
+	Malformed     int64            `json:"malformed"`
+	MalformedRate float64          `json:"malformed_rate"` // 不合格扫码的百分比
+	Breakdown     QualityBreakdown `json:"breakdown" gorm:"type:text"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// TableName 指定表名
+func (QualityReport) TableName() string {
+	return "quality_reports"
+}
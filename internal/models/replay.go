@@ -0,0 +1,81 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 回放任务状态
+const (
+	ReplayStatusPending   = "pending"   // 等待前一个任务完成
+	ReplayStatusRunning   = "running"   // 回放中
+	ReplayStatusCompleted = "completed" // 已完成
+	ReplayStatusFailed    = "failed"    // 投递失败，可从检查点继续
+	ReplayStatusCancelled = "cancelled" // 已取消，可从检查点继续
+)
+
+// ReplayFilter 回放的扫码记录筛选条件
+type ReplayFilter struct {
+	DeviceIDs []uint `json:"device_ids,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+// Value 实现 driver.Valuer
+func (f ReplayFilter) Value() (driver.Value, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (f *ReplayFilter) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*f = ReplayFilter{}
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("无法解析 %T 为 ReplayFilter", value)
+	}
+	if len(data) == 0 {
+		*f = ReplayFilter{}
+		return nil
+	}
+	return json.Unmarshal(data, f)
+}
+
+// ReplayJob 历史扫码记录回放任务
+//
+// 按记录ID顺序回放 [From, To) 内的扫码记录，LastRecordID 为已投递到全部输出端的最后一条记录，
+// 重启或继续时从其之后开始。
+type ReplayJob struct {
+	ID           uint         `json:"id" gorm:"primarykey"`
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	Sinks        StringList   `json:"sinks" gorm:"type:text"`
+	Filter       ReplayFilter `json:"filters" gorm:"type:text"`
+	Status       string       `json:"status" gorm:"size:20;not null;index"`
+	Total        int64        `json:"total"`
+	Processed    int64        `json:"processed"`
+	LastRecordID uint         `json:"last_record_id"` // 检查点
+	Error        string       `json:"error,omitempty" gorm:"type:text"`
+	CreatedBy    string       `json:"created_by,omitempty" gorm:"size:100"`
+	StartedAt    *time.Time   `json:"started_at,omitempty"`
+	FinishedAt   *time.Time   `json:"finished_at,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ReplayJob) TableName() string {
+	return "replay_jobs"
+}
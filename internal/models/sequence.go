@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// SequenceState 序列号断号检测的最后序号状态
+type SequenceState struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Rule      string    `json:"rule" gorm:"size:100;not null;uniqueIndex:idx_sequence_state_key"`
+	Prefix    string    `json:"prefix" gorm:"size:100;not null;uniqueIndex:idx_sequence_state_key"`
+	DeviceID  uint      `json:"device_id" gorm:"not null;default:0;uniqueIndex:idx_sequence_state_key"`
+	LastValue int64     `json:"last_value"`
+	Width     int       `json:"width"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SequenceState) TableName() string {
+	return "sequence_states"
+}
+
+// SequenceGap 检测到的序列号断号记录
+type SequenceGap struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	Rule         string    `json:"rule" gorm:"size:100;index"`
+	Prefix       string    `json:"prefix" gorm:"size:100;index"`
+	DeviceID     uint      `json:"device_id" gorm:"index"`
+	FromValue    int64     `json:"from_value"`
+	ToValue      int64     `json:"to_value"`
+	MissingCount int64     `json:"missing_count"`
+	Missing      string    `json:"missing" gorm:"type:text"` // JSON数组，最多保留前100个
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (SequenceGap) TableName() string {
+	return "sequence_gaps"
+}
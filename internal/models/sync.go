@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// 同步条目类型
+const (
+	SyncKindDevice = "device" // 设备，按序列号匹配
+	SyncKindRule   = "rule"   // 分类规则，按名称匹配
+	SyncKindConfig = "config" // 系统配置，按配置键匹配
+)
+
+// 同步冲突状态
+const (
+	SyncConflictPending = "pending" // 待人工处理
+	SyncConflictCentral = "central" // 已采用中心内容
+	SyncConflictLocal   = "local"   // 已保留本地内容
+)
+
+// SyncState 中心同步状态，只有一行
+type SyncState struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Version   int64     `json:"version"` // 已完整应用的同步包版本
+	AppliedAt time.Time `json:"applied_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SyncState) TableName() string {
+	return "sync_states"
+}
+
+// SyncItem 由中心同步管理的条目，Hash 为最近一次应用（或确认）的中心内容摘要
+//
+// 本地内容的摘要与 Hash 不同即视为本地修改过，中心内容再次变化时按冲突策略处理。
+type SyncItem struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	Kind      string    `json:"kind" gorm:"size:20;not null;uniqueIndex:idx_sync_items_kind_key"`
+	Key       string    `json:"key" gorm:"size:100;not null;uniqueIndex:idx_sync_items_kind_key"`
+	Hash      string    `json:"hash" gorm:"size:64"`
+	Version   int64     `json:"version"` // 最近一次应用时的同步包版本
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SyncItem) TableName() string {
+	return "sync_items"
+}
+
+// SyncConflict 中心内容与本地修改的冲突，conflict_policy 为 review 时登记
+type SyncConflict struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	Kind       string     `json:"kind" gorm:"size:20;not null;index:idx_sync_conflicts_kind_key"`
+	Key        string     `json:"key" gorm:"size:100;not null;index:idx_sync_conflicts_kind_key"`
+	Version    int64      `json:"version"`                  // 产生冲突的同步包版本
+	Central    string     `json:"central" gorm:"type:text"` // 中心内容（JSON）
+	Local      string     `json:"local" gorm:"type:text"`   // 登记时的本地内容（JSON）
+	Status     string     `json:"status" gorm:"size:20;not null;index"`
+	ResolvedBy string     `json:"resolved_by,omitempty" gorm:"size:100"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SyncConflict) TableName() string {
+	return "sync_conflicts"
+}
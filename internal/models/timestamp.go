@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TimestampPrecision 扫码时间的精度
+//
+// 库中的 created_at、WebSocket 推送及各输出端中的扫码时间都截断到微秒，同一扫码在各处的时间相同；
+// 同一微秒内的多次扫码按记录ID（扫码记录的 id、推送载荷的 record_id）排序。
+const TimestampPrecision = time.Microsecond
+
+// ScanTime 截断到 TimestampPrecision 的扫码时间，为零时使用当前时间
+func ScanTime(t time.Time) time.Time {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.Truncate(TimestampPrecision)
+}
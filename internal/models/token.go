@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API令牌权限范围
+const (
+	TokenScopeRead        = "read"         // 只读REST接口
+	TokenScopeWSSubscribe = "ws:subscribe" // WebSocket订阅
+	TokenScopePeerForward = "peer:forward" // 接收相邻工位转发的事件
+	TokenScopeIngest      = "ingest"       // 外部系统推送扫码结果
+	TokenScopePreferences = "preferences"  // 保存看板偏好设置
+)
+
+// APIToken 限定权限的API令牌（用于看板等只读终端）
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	TokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Prefix     string     `json:"prefix" gorm:"size:16"`
+	Scopes     string     `json:"scopes" gorm:"size:255"`  // 逗号分隔
+	Devices    string     `json:"devices" gorm:"size:255"` // 逗号分隔的设备ID，为空表示不限
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// HasScope 是否包含指定权限
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range strings.Split(t.Scopes, ",") {
+		if strings.TrimSpace(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceScope 令牌允许访问的设备，nil表示不限
+func (t *APIToken) DeviceScope() map[uint]bool {
+	if strings.TrimSpace(t.Devices) == "" {
+		return nil
+	}
+
+	scope := make(map[uint]bool)
+	for _, s := range strings.Split(t.Devices, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32); err == nil {
+			scope[uint(id)] = true
+		}
+	}
+	return scope
+}
@@ -0,0 +1,271 @@
+package peer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/websocket"
+)
+
+// EventsPath 对端接收转发事件的接口路径
+const EventsPath = "/api/peers/events"
+
+// Event 工位间转发的事件
+type Event struct {
+	Origin string          `json:"origin"`
+	Type   string          `json:"type"`
+	Time   time.Time       `json:"time"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Status 单个对端的转发状态
+type Status struct {
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Healthy     bool       `json:"healthy"`
+	Queued      int        `json:"queued"`
+	Sent        int64      `json:"sent"`
+	Failures    int64      `json:"failures"`
+	Dropped     int64      `json:"dropped"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// target 转发目标及其重试队列
+type target struct {
+	config config.PeerTarget
+	queue  chan []byte
+
+	sent     int64
+	failures int64
+	dropped  int64
+
+	mu          sync.RWMutex
+	healthy     bool
+	lastSuccess *time.Time
+	lastError   string
+}
+
+// Forwarder 将本地事件转发给相邻工位
+//
+// 每个对端有独立的有界队列，发送失败时按退避间隔重试同一事件以保持顺序，
+// 队列满时丢弃新事件。转发来的事件（带来源工位）不会再次转发，避免环路。
+type Forwarder struct {
+	config  config.PeersConfig
+	logger  *logrus.Logger
+	types   map[string]bool
+	client  *http.Client
+	targets []*target
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewForwarder 创建事件转发器
+func NewForwarder(cfg config.PeersConfig, logger *logrus.Logger) (*Forwarder, error) {
+	if strings.TrimSpace(cfg.Station) == "" {
+		return nil, fmt.Errorf("工位名称不能为空")
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	types := make(map[string]bool, len(cfg.EventTypes))
+	for _, t := range cfg.EventTypes {
+		types[t] = true
+	}
+
+	targets := make([]*target, 0, len(cfg.Targets))
+	for _, t := range cfg.Targets {
+		if t.URL == "" {
+			return nil, fmt.Errorf("对端 %s 的地址不能为空", t.Name)
+		}
+		if t.Name == "" {
+			t.Name = t.URL
+		}
+		t.URL = strings.TrimRight(t.URL, "/")
+		targets = append(targets, &target{
+			config: t,
+			queue:  make(chan []byte, cfg.QueueSize),
+		})
+	}
+
+	return &Forwarder{
+		config:  cfg,
+		logger:  logger,
+		types:   types,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		targets: targets,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Station 本工位名称
+func (f *Forwarder) Station() string {
+	return f.config.Station
+}
+
+// Publish 广播回调，将选定类型的本地事件放入各对端队列
+func (f *Forwarder) Publish(msgType string, message *websocket.Message, data []byte) {
+	if message.Origin != "" || !f.types[msgType] {
+		return
+	}
+
+	payload, err := json.Marshal(message.Data)
+	if err != nil {
+		f.logger.WithError(err).WithField("type", msgType).Warn("序列化转发事件失败")
+		return
+	}
+	body, err := json.Marshal(Event{
+		Origin: f.config.Station,
+		Type:   msgType,
+		Time:   message.Time,
+		Data:   payload,
+	})
+	if err != nil {
+		f.logger.WithError(err).WithField("type", msgType).Warn("序列化转发事件失败")
+		return
+	}
+
+	for _, t := range f.targets {
+		select {
+		case t.queue <- body:
+		default:
+			atomic.AddInt64(&t.dropped, 1)
+		}
+	}
+}
+
+// Start 启动各对端的发送协程
+func (f *Forwarder) Start() {
+	for _, t := range f.targets {
+		f.wg.Add(1)
+		go f.run(t)
+	}
+	f.logger.WithField("station", f.config.Station).WithField("peers", len(f.targets)).Info("工位事件转发已启动")
+}
+
+// Close 停止转发，未发送的事件将被丢弃
+func (f *Forwarder) Close() {
+	close(f.done)
+	f.wg.Wait()
+}
+
+// GetStatus 获取各对端的转发状态
+func (f *Forwarder) GetStatus() []Status {
+	statuses := make([]Status, 0, len(f.targets))
+	for _, t := range f.targets {
+		t.mu.RLock()
+		statuses = append(statuses, Status{
+			Name:        t.config.Name,
+			URL:         t.config.URL,
+			Healthy:     t.healthy,
+			Queued:      len(t.queue),
+			Sent:        atomic.LoadInt64(&t.sent),
+			Failures:    atomic.LoadInt64(&t.failures),
+			Dropped:     atomic.LoadInt64(&t.dropped),
+			LastSuccess: t.lastSuccess,
+			LastError:   t.lastError,
+		})
+		t.mu.RUnlock()
+	}
+	return statuses
+}
+
+// run 对端发送循环，失败时退避后重试同一事件
+func (f *Forwarder) run(t *target) {
+	defer f.wg.Done()
+
+	for {
+		var body []byte
+		select {
+		case body = <-t.queue:
+		case <-f.done:
+			return
+		}
+
+		backoff := time.Second
+		for {
+			err := f.send(t, body)
+			if err == nil {
+				break
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-f.done:
+				return
+			}
+			if backoff *= 2; backoff > f.config.MaxBackoff {
+				backoff = f.config.MaxBackoff
+			}
+		}
+	}
+}
+
+// send 发送单个事件并记录对端状态
+func (f *Forwarder) send(t *target, body []byte) error {
+	err := f.post(t, body)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		atomic.AddInt64(&t.failures, 1)
+		if t.healthy || t.lastError == "" {
+			f.logger.WithError(err).WithField("peer", t.config.Name).Warn("转发事件到对端失败，稍后重试")
+		}
+		t.healthy = false
+		t.lastError = err.Error()
+		return err
+	}
+
+	atomic.AddInt64(&t.sent, 1)
+	if !t.healthy && t.lastError != "" {
+		f.logger.WithField("peer", t.config.Name).Info("对端转发已恢复")
+	}
+	now := time.Now()
+	t.healthy = true
+	t.lastSuccess = &now
+	t.lastError = ""
+	return nil
+}
+
+// post 向对端提交事件
+func (f *Forwarder) post(t *target, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, t.config.URL+EventsPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建转发请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.config.Token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("对端返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
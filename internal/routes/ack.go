@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/service"
+)
+
+// ackBarcodes 下游系统确认已消费的扫码记录，按 ids 逐条确认或按 up_to_id 确认该ID及之前的记录
+//
+// 重复确认不报错；按 ids 确认时逐条返回结果，部分记录失败时返回207。
+func (r *Router) ackBarcodes(c *gin.Context) {
+	var req struct {
+		Consumer string `json:"consumer" binding:"required"`
+		IDs      []uint `json:"ids"`
+		UpToID   uint   `json:"up_to_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	// 受限令牌只能查看部分设备的记录，不允许按范围确认其他设备的记录
+	if p := getPrincipal(c); p != nil && p.Devices != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "限定设备的令牌不能确认消费"})
+		return
+	}
+
+	result, err := r.acks.Ack(req.Consumer, req.IDs, req.UpToID)
+	switch {
+	case errors.Is(err, service.ErrUnknownConsumer):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "消费方未登记", "message": err.Error()})
+		return
+	case errors.Is(err, service.ErrInvalidAck):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "确认请求无效", "message": err.Error()})
+		return
+	case err != nil:
+		r.logger.WithError(err).Error("确认消费失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "确认消费失败", "message": err.Error()})
+		return
+	}
+
+	if result.Failed > 0 {
+		c.JSON(http.StatusMultiStatus, gin.H{"message": "部分记录确认失败", "data": result})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已确认消费", "data": result})
+}
+
+// getConsumerLag 各登记消费方的积压（未确认记录数及最早未确认记录的时间）
+func (r *Router) getConsumerLag(c *gin.Context) {
+	lags, err := r.acks.GetLag()
+	if err != nil {
+		r.logger.WithError(err).Error("获取消费积压失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取消费积压失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": lags, "total": len(lags)})
+}
@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/models"
+	"userclient/internal/service"
+	"userclient/internal/support"
+)
+
+// resetRequest 数据重置请求
+type resetRequest struct {
+	Scope   string `json:"scope" binding:"required"`   // records, sessions, all-data, demo-seed
+	Confirm string `json:"confirm" binding:"required"` // GET /api/admin/reset/token 签发的确认令牌
+}
+
+// getResetToken 签发数据重置确认令牌
+func (r *Router) getResetToken(c *gin.Context) {
+	if !r.resetAllowed(c) {
+		return
+	}
+
+	token, expiresAt, err := r.reset.IssueToken(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"scope":      c.Query("scope"),
+		"confirm":    token,
+		"expires_at": expiresAt,
+	})
+}
+
+// resetData 清空数据（保留设备与配置），可选写入演示数据
+func (r *Router) resetData(c *gin.Context) {
+	if !r.resetAllowed(c) {
+		return
+	}
+
+	var req resetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	actor := "admin"
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		actor = p.Token.Name
+	}
+	event, err := r.reset.Reset(req.Scope, req.Confirm, service.ResetAudit{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Actor:     actor,
+	})
+	if errors.Is(err, service.ErrResetConfirmation) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "message": "请重新获取确认令牌"})
+		return
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("数据重置失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "数据重置失败", "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "数据已重置", "data": event})
+}
+
+// resetAllowed 生产环境默认禁止数据重置
+func (r *Router) resetAllowed(c *gin.Context) bool {
+	if r.config.IsProduction() && !r.config.Admin.AllowResetInProduction {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "生产环境禁止数据重置",
+			"message": "如确需重置，请设置 admin.allow_reset_in_production",
+		})
+		return false
+	}
+	return true
+}
+
+// getSupportBundle 生成诊断包zip，include_barcodes=true 时包含扫码内容
+func (r *Router) getSupportBundle(c *gin.Context) {
+	includeBarcodes, _ := strconv.ParseBool(c.Query("include_barcodes"))
+
+	actor := "admin"
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		actor = p.Token.Name
+	}
+	opts := support.Options{
+		IncludeBarcodes: includeBarcodes,
+		Status:          r.statusSnapshot(),
+		GeneratedBy:     actor,
+		Logger:          r.logger,
+	}
+	if r.logs != nil {
+		opts.Logs = r.logs.Lines()
+	}
+
+	var buf bytes.Buffer
+	if err := support.Write(&buf, r.config, r.db.DB, opts); err != nil {
+		r.logger.WithError(err).Error("生成诊断包失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成诊断包失败", "message": err.Error()})
+		return
+	}
+
+	// 诊断包可能包含运行信息及扫码内容，记录审计日志
+	extra, _ := json.Marshal(map[string]interface{}{"actor": actor, "include_barcodes": includeBarcodes, "size": buf.Len()})
+	log := &models.SystemLog{
+		Level:     "info",
+		Message:   "下载诊断包",
+		Module:    "admin",
+		Action:    "support_bundle",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Extra:     string(extra),
+	}
+	if err := r.db.Create(log).Error; err != nil {
+		r.logger.WithError(err).Warn("写入审计日志失败")
+	}
+	r.logger.WithField("actor", actor).WithField("include_barcodes", includeBarcodes).Info("已生成诊断包")
+
+	filename := fmt.Sprintf("support-bundle-%s.zip", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// getConfigState 配置文件热加载状态：当前生效、最近可用及正在应用的版本
+func (r *Router) getConfigState(c *gin.Context) {
+	if r.reloader == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "配置热加载未启用"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": r.reloader.State()})
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// agentEnabled 未启用键盘钩子代理时返回404
+func (r *Router) agentEnabled(c *gin.Context) {
+	if r.agent == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "键盘钩子代理未启用"})
+		return
+	}
+	c.Next()
+}
+
+// agentAuth 代理只能从本机连接且须提供 agent.token 共享令牌，与是否启用接口认证无关
+func (r *Router) agentAuth(c *gin.Context) {
+	if !isLocalRequest(c) {
+		r.abortAuth(c, http.StatusForbidden, "键盘钩子代理仅允许本机连接")
+		return
+	}
+	if !r.agent.Authenticate(extractCredential(c)) {
+		r.logger.WithField("remote", c.Request.RemoteAddr).Warn("拒绝未通过认证的键盘钩子代理")
+		r.abortAuth(c, http.StatusUnauthorized, "键盘钩子代理令牌无效")
+		return
+	}
+	c.Next()
+}
+
+// handleAgent 处理键盘钩子代理连接，代理断开后返回
+func (r *Router) handleAgent(c *gin.Context) {
+	r.agent.HandleConnection(c.Writer, c.Request)
+}
@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/models"
+	"userclient/internal/service"
+	"userclient/internal/websocket"
+)
+
+// principalKey 认证主体在上下文中的键
+const principalKey = "auth_principal"
+
+// principal 认证主体
+type principal struct {
+	Admin   bool
+	Token   *models.APIToken
+	Devices map[uint]bool // 允许访问的设备，nil表示不限
+}
+
+// createTokenRequest 创建令牌请求
+type createTokenRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	Devices   []uint   `json:"devices"`
+	ExpiresIn string   `json:"expires_in"` // 有效期，如 "720h"，为空表示永不过期
+}
+
+// authMiddleware 认证中间件，未启用认证时放行
+func (r *Router) authMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.config.Security.EnableAuth {
+			c.Set(principalKey, &principal{Admin: true})
+			c.Next()
+			return
+		}
+
+		credential := extractCredential(c)
+		if credential == "" {
+			r.abortAuth(c, http.StatusUnauthorized, "未提供认证信息")
+			return
+		}
+
+		// 管理员密钥
+		if r.config.Security.APIKey != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(r.config.Security.APIKey)) == 1 {
+			c.Set(principalKey, &principal{Admin: true})
+			c.Next()
+			return
+		}
+
+		token, err := r.tokens.Authenticate(credential)
+		if err != nil {
+			r.abortAuth(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		// 受限令牌只能访问只读接口
+		if scope == models.TokenScopeRead && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "令牌无写权限"})
+			return
+		}
+		if !token.HasScope(scope) {
+			r.abortAuth(c, http.StatusForbidden, "令牌无此权限: "+scope)
+			return
+		}
+
+		p := &principal{Token: token, Devices: token.DeviceScope()}
+
+		// 请求中指定的设备必须在令牌范围内
+		if p.Devices != nil {
+			if value := c.Query("device_id"); value != "" {
+				id, err := strconv.ParseUint(value, 10, 32)
+				if err != nil || !p.Devices[uint(id)] {
+					r.abortAuth(c, http.StatusForbidden, "令牌无权访问该设备")
+					return
+				}
+			}
+		}
+
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+// abortAuth 拒绝请求；WebSocket升级请求以关闭码拒绝，浏览器客户端可据此停止重连
+func (r *Router) abortAuth(c *gin.Context, status int, message string) {
+	if websocket.IsUpgrade(c.Request) {
+		code := websocket.CloseUnauthorized
+		if status == http.StatusForbidden {
+			code = websocket.ClosePolicyViolation
+		}
+		r.hub.Reject(c.Writer, c.Request, code, message)
+		c.Abort()
+		return
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": message})
+}
+
+// requireAdmin 仅允许管理员访问
+func (r *Router) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p := getPrincipal(c); p == nil || !p.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// getPrincipal 获取当前请求的认证主体
+func getPrincipal(c *gin.Context) *principal {
+	if value, ok := c.Get(principalKey); ok {
+		if p, ok := value.(*principal); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// canAccessDevice 当前请求是否可访问指定设备
+func canAccessDevice(c *gin.Context, deviceID uint) bool {
+	p := getPrincipal(c)
+	return p == nil || p.Devices == nil || p.Devices[deviceID]
+}
+
+// extractCredential 从请求头或查询参数中提取凭证（WebSocket无法自定义请求头）
+func extractCredential(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	return c.Query("token")
+}
+
+// createToken 创建受限令牌
+func (r *Router) createToken(c *gin.Context) {
+	var req createTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	var expiresIn time.Duration
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "有效期无效", "message": "expires_in 应为正的时长，如 720h"})
+			return
+		}
+		expiresIn = d
+	}
+
+	plaintext, token, err := r.tokens.CreateToken(service.CreateTokenRequest{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Devices:   req.Devices,
+		ExpiresIn: expiresIn,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "令牌创建成功，请妥善保存，明文不会再次显示",
+		"token":   plaintext,
+		"data":    token,
+	})
+}
+
+// getTokens 获取令牌列表
+func (r *Router) getTokens(c *gin.Context) {
+	tokens, err := r.tokens.GetTokens()
+	if err != nil {
+		r.logger.WithError(err).Error("查询令牌失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tokens, "total": len(tokens)})
+}
+
+// revokeToken 吊销令牌
+func (r *Router) revokeToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "令牌ID无效"})
+		return
+	}
+
+	if err := r.tokens.RevokeToken(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "令牌已吊销"})
+}
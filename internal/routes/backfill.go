@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/service"
+)
+
+// backfillsEnabled 后台回填未启用（只读模式）时返回404
+func (r *Router) backfillsEnabled(c *gin.Context) {
+	if r.backfills == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "后台回填未启用"})
+		return
+	}
+	c.Next()
+}
+
+// getBackfills 获取后台回填任务及进度
+func (r *Router) getBackfills(c *gin.Context) {
+	list, err := r.backfills.List()
+	if err != nil {
+		r.logger.WithError(err).Error("获取回填任务失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取回填任务失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// pauseBackfill 暂停回填任务，运行中的任务在当前批次提交后停止，重启后保持暂停
+func (r *Router) pauseBackfill(c *gin.Context) {
+	job, err := r.backfills.Pause(c.Param("name"))
+	if err != nil {
+		r.respondBackfillError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "回填任务已暂停", "data": job})
+}
+
+// resumeBackfill 从检查点继续已暂停或失败的回填任务
+func (r *Router) resumeBackfill(c *gin.Context) {
+	job, err := r.backfills.Resume(c.Param("name"))
+	if err != nil {
+		r.respondBackfillError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "回填任务已重新排队", "data": job})
+}
+
+// getBackfillStatus 获取后台回填的进度及预计完成时间
+func (r *Router) getBackfillStatus() interface{} {
+	if r.backfills == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.backfills.Status()
+}
+
+// respondBackfillError 根据错误类型返回响应
+func (r *Router) respondBackfillError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrBackfillNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "回填任务不存在", "message": err.Error()})
+	case errors.Is(err, service.ErrBackfillState):
+		c.JSON(http.StatusConflict, gin.H{"error": "回填任务状态不允许该操作", "message": err.Error()})
+	default:
+		r.logger.WithError(err).Error("处理回填任务失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理回填任务失败", "message": err.Error()})
+	}
+}
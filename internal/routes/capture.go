@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/service"
+)
+
+// pauseCaptureRequest 暂停按键采集请求
+type pauseCaptureRequest struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// getCapture 获取按键采集的暂停状态
+func (r *Router) getCapture(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": r.capture.GetState()})
+}
+
+// pauseCapture 暂停按键采集，键盘钩子保持安装，按键原样传给前台程序
+func (r *Router) pauseCapture(c *gin.Context) {
+	var req pauseCaptureRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+			return
+		}
+	}
+
+	state, err := r.capture.Pause(captureActor(c), req.Reason)
+	if err != nil {
+		r.respondCaptureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已暂停扫码采集", "data": state})
+}
+
+// resumeCapture 恢复按键采集
+func (r *Router) resumeCapture(c *gin.Context) {
+	state, err := r.capture.Resume(captureActor(c))
+	if err != nil {
+		r.respondCaptureError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已恢复扫码采集", "data": state})
+}
+
+// captureActor 暂停/恢复的操作者，令牌认证时为令牌名称
+func captureActor(c *gin.Context) string {
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		return p.Token.Name
+	}
+	return "admin"
+}
+
+// respondCaptureError 根据错误类型返回响应
+func (r *Router) respondCaptureError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrCaptureUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": "无法暂停扫码采集", "message": err.Error()})
+		return
+	}
+	r.logger.WithError(err).Error("切换扫码采集状态失败")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "切换扫码采集状态失败", "message": err.Error()})
+}
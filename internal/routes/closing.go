@@ -0,0 +1,173 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/binding"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// closeDayRequest 日结请求
+type closeDayRequest struct {
+	Day  string `json:"day"`  // 生产日，为空表示当前生产日
+	Note string `json:"note"` // 备注，写入汇总
+}
+
+// reopenDayRequest 重新开放已日结的生产日请求
+type reopenDayRequest struct {
+	Day    string `json:"day" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+// closingEnabled 未启用日结时返回404
+func (r *Router) closingEnabled(c *gin.Context) {
+	if r.closing == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "日结未启用"})
+		return
+	}
+	c.Next()
+}
+
+// closeDay 日结生产日，同一生产日重复日结返回已有的汇总
+func (r *Router) closeDay(c *gin.Context) {
+	var req closeDayRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+			return
+		}
+	}
+
+	summary, created, err := r.closing.CloseDay(req.Day, req.Note, closingActor(c))
+	if err != nil {
+		r.respondClosingError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"message": "该生产日已日结", "data": summary})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "日结完成", "data": summary})
+}
+
+// reopenDay 重新开放已日结的生产日
+func (r *Router) reopenDay(c *gin.Context) {
+	var req reopenDayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	summary, err := r.closing.ReopenDay(req.Day, req.Reason, closingActor(c))
+	if err != nil {
+		r.respondClosingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "生产日已重新开放", "data": summary})
+}
+
+// getDailySummaries 获取日结汇总，默认最近30天
+func (r *Router) getDailySummaries(c *gin.Context) {
+	today, _ := time.ParseInLocation("2006-01-02", r.closing.Today(), r.location)
+	from, to, err := binding.BindDayRange(c, r.location, today, 30)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+
+	summaries, err := r.closing.GetSummaries(from, to)
+	if err != nil {
+		r.respondClosingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": summaries, "total": len(summaries), "from": from, "to": to})
+}
+
+// getDailySummary 获取生产日的日结汇总，day 为 today 表示当前生产日
+func (r *Router) getDailySummary(c *gin.Context) {
+	summary, err := r.closing.GetSummary(c.Param("day"))
+	if err != nil {
+		r.respondClosingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
+
+// deleteBarcode 删除单条扫码记录
+func (r *Router) deleteBarcode(c *gin.Context) {
+	record, ok := r.loadRecord(c)
+	if !ok {
+		return
+	}
+	if !r.checkDayLock(c, record, "delete") {
+		return
+	}
+
+	if err := r.barcodes.DeleteBarcodeRecord(record.ID); err != nil {
+		r.logger.WithError(err).Error("删除扫码记录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除扫码记录失败"})
+		return
+	}
+	r.statsCache.Invalidate()
+	c.JSON(http.StatusOK, gin.H{"message": "扫码记录已删除"})
+}
+
+// checkDayLock 记录所属生产日已日结时拒绝修改，管理员附带 override=true 时放行并记录审计日志；
+// 拒绝时已写入响应，返回 false
+func (r *Router) checkDayLock(c *gin.Context, record *models.BarcodeRecord, operation string) bool {
+	if r.closing == nil {
+		return true
+	}
+	day, locked := r.closing.LockedDay(record.CreatedAt)
+	if !locked {
+		return true
+	}
+
+	override, _ := strconv.ParseBool(c.Query("override"))
+	if p := getPrincipal(c); override && p != nil && p.Admin {
+		r.closing.AuditOverride(operation, record.ID, day, closingActor(c))
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "该记录所属生产日已日结",
+		"message": fmt.Sprintf("生产日 %s 已日结，记录不允许修改；管理员可附带 override=true 强制修改，或重新开放该生产日", day),
+		"day":     day,
+	})
+	return false
+}
+
+// closingActor 当前请求的日结操作者
+func closingActor(c *gin.Context) service.ClosingActor {
+	actor := service.ClosingActor{
+		Name:      "admin",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		actor.Name = p.Token.Name
+	}
+	return actor
+}
+
+// respondClosingError 根据错误类型返回响应
+func (r *Router) respondClosingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidClosing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "日结请求无效", "message": err.Error()})
+	case errors.Is(err, service.ErrDayNotClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "生产日尚未日结", "message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "该生产日尚无日结汇总"})
+	default:
+		r.logger.WithError(err).Error("处理日结失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理日结失败", "message": err.Error()})
+	}
+}
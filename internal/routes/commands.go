@@ -0,0 +1,120 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/service"
+)
+
+// bulkCommandRequest 批量下发命令请求
+type bulkCommandRequest struct {
+	DeviceIDs []uint `json:"device_ids" binding:"required,min=1"`
+	service.DeviceCommand
+}
+
+// sendDeviceCommand 向网络扫码枪下发命令，返回设备原始响应
+func (r *Router) sendDeviceCommand(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+	if !canAccessDevice(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "令牌无权访问该设备"})
+		return
+	}
+
+	var req service.DeviceCommand
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	result, err := r.commands.Execute(id, req, commandActor(c))
+	if err != nil {
+		r.respondCommandError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Status == service.CommandStatusFailed {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, gin.H{"data": result})
+}
+
+// sendBulkCommand 向多台网络扫码枪并发下发同一命令
+func (r *Router) sendBulkCommand(c *gin.Context) {
+	var req bulkCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	for _, id := range req.DeviceIDs {
+		if !canAccessDevice(c, id) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "令牌无权访问该设备", "device_id": id})
+			return
+		}
+	}
+
+	results := r.commands.ExecuteAll(req.DeviceIDs, req.DeviceCommand, commandActor(c))
+	summary := make(map[string]int)
+	for _, result := range results {
+		summary[result.Status]++
+	}
+	c.JSON(http.StatusOK, gin.H{"data": results, "summary": summary})
+}
+
+// setDeviceCommands 设置设备的命令地址、模板及白名单
+func (r *Router) setDeviceCommands(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+
+	var req service.CommandSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	device, err := r.commands.SetCommands(id, req)
+	if err != nil {
+		r.respondCommandError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备命令设置已更新", "data": device})
+}
+
+// commandActor 当前请求的命令操作者
+func commandActor(c *gin.Context) service.CommandActor {
+	actor := service.CommandActor{
+		Name:      "admin",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		actor.Name = p.Token.Name
+	}
+	return actor
+}
+
+// respondCommandError 根据错误类型返回响应
+func (r *Router) respondCommandError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+	case errors.Is(err, service.ErrCommandNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": "命令不在设备白名单中", "message": err.Error()})
+	case errors.Is(err, service.ErrCommandUnsupported):
+		c.JSON(http.StatusConflict, gin.H{"error": "设备未配置命令通道", "message": err.Error()})
+	case errors.Is(err, service.ErrInvalidCommand):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "命令无效", "message": err.Error()})
+	default:
+		r.logger.WithError(err).Error("下发设备命令失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "下发设备命令失败", "message": err.Error()})
+	}
+}
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/service"
+)
+
+// configRequest 设置配置请求
+type configRequest struct {
+	Value       string `json:"value"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	Force       bool   `json:"force"`   // 确认保存超出安全范围的扫码参数
+	Restore     bool   `json:"restore"` // 键属于已删除的配置时恢复该配置
+}
+
+// getConfigs 获取配置列表
+func (r *Router) getConfigs(c *gin.Context) {
+	configs, err := r.configs.GetConfigurations(c.Query("category"))
+	if err != nil {
+		r.logger.WithError(err).Error("查询配置失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询配置失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": configs})
+}
+
+// setConfig 设置配置，系统配置需要管理员权限并通过校验
+func (r *Router) setConfig(c *gin.Context) {
+	var req configRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	key := c.Param("key")
+	actor := configActor(c)
+	actor.Force = req.Force
+	actor.Restore = req.Restore
+	change, err := r.configs.SetConfigurationAs(actor, key, req.Value, req.Category, req.Description)
+	if err != nil {
+		r.respondConfigError(c, err)
+		return
+	}
+
+	resp := gin.H{"message": "配置已保存"}
+	if change != nil {
+		resp["restart_required"] = change.RestartRequired
+		if change.RestartRequired {
+			resp["message"] = "配置已保存，需重启后生效"
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// deleteConfig 删除配置，系统配置始终拒绝
+func (r *Router) deleteConfig(c *gin.Context) {
+	config, err := r.configs.GetConfiguration(c.Param("key"))
+	if err != nil {
+		r.respondConfigError(c, err)
+		return
+	}
+
+	if err := r.configs.DeleteConfigurationAs(configActor(c), config.ID); err != nil {
+		r.respondConfigError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "配置已删除"})
+}
+
+// restoreConfig 恢复已删除的配置
+func (r *Router) restoreConfig(c *gin.Context) {
+	config, err := r.configs.RestoreConfiguration(c.Param("key"))
+	if err != nil {
+		r.respondConfigError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "配置已恢复", "data": config})
+}
+
+// configActor 当前请求的配置操作者
+func configActor(c *gin.Context) service.ConfigActor {
+	actor := service.ConfigActor{
+		Name:      "admin",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if p := getPrincipal(c); p != nil {
+		actor.Admin = p.Admin
+		if p.Token != nil {
+			actor.Name = p.Token.Name
+		}
+	}
+	return actor
+}
+
+// respondConfigError 根据错误类型返回响应
+func (r *Router) respondConfigError(c *gin.Context, err error) {
+	var deleted *service.DeletedConflictError
+	switch {
+	case errors.As(err, &deleted):
+		c.JSON(http.StatusConflict, deletedConflictResponse(deleted,
+			"以 restore=true 重新提交，或 POST /api/configs/"+deleted.Value+"/restore 恢复原配置"))
+	case errors.Is(err, service.ErrConfigProtected):
+		c.JSON(http.StatusForbidden, gin.H{"error": "系统配置受保护", "message": err.Error()})
+	case errors.Is(err, service.ErrInvalidConfigValue):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置值无效", "message": err.Error()})
+	case errors.Is(err, service.ErrConfigOutOfRange):
+		c.JSON(http.StatusConflict, gin.H{"error": "配置值超出安全范围", "message": err.Error() + "，确认无误后请以 force=true 重新提交"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "配置不存在"})
+	default:
+		r.logger.WithError(err).Error("保存配置失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存配置失败", "message": err.Error()})
+	}
+}
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/service"
+)
+
+// resetCountersRequest 清零计数器请求
+type resetCountersRequest struct {
+	Scope string `json:"scope" binding:"required"`
+	Name  string `json:"name"` // 为空表示作用域内的全部计数器
+}
+
+// rolloverCountersRequest 轮转计数器请求
+type rolloverCountersRequest struct {
+	Scope string `json:"scope" binding:"required"`
+}
+
+// countersEnabled 持久化计数器未启用（只读模式）时返回404
+func (r *Router) countersEnabled(c *gin.Context) {
+	if r.counters == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "持久化计数器未启用"})
+		return
+	}
+	c.Next()
+}
+
+// getCounters 获取计数器当前的值（含尚未写入数据库的变化），scope 为空表示全部
+func (r *Router) getCounters(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": r.counters.List(c.Query("scope"))})
+}
+
+// resetCounters 清零计数器，清零前的值保存在 last_value
+func (r *Router) resetCounters(c *gin.Context) {
+	var req resetCountersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	rolled, err := r.counters.Reset(req.Scope, req.Name)
+	r.respondCounterRollover(c, rolled, err, "计数器已清零")
+}
+
+// rolloverCounters 立即轮转作用域内的计数器（如提前换班），按周期轮转的计数器进入当前周期
+func (r *Router) rolloverCounters(c *gin.Context) {
+	var req rolloverCountersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	rolled, err := r.counters.Rollover(req.Scope)
+	r.respondCounterRollover(c, rolled, err, "计数器已轮转")
+}
+
+// respondCounterRollover 输出清零或轮转结果
+func (r *Router) respondCounterRollover(c *gin.Context, rolled []service.CounterRollover, err error, message string) {
+	if errors.Is(err, service.ErrCounterNotResettable) {
+		c.JSON(http.StatusConflict, gin.H{"error": "该计数器只能递增，不允许清零"})
+		return
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("写入计数器失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "写入计数器失败"})
+		return
+	}
+	if rolled == nil {
+		rolled = []service.CounterRollover{}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "data": rolled})
+}
@@ -0,0 +1,272 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/binding"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// deviceWithHealth 附带健康评分的设备信息
+type deviceWithHealth struct {
+	*models.Device
+	Health *service.DeviceHealth `json:"health,omitempty"`
+}
+
+// deviceSortFields 设备列表可排序的字段
+var deviceSortFields = []string{"created_at", "id", "name", "status", "station", "last_seen"}
+
+// getDevices 获取所有工位的设备列表，附带当前健康评分，station 参数按工位过滤
+func (r *Router) getDevices(c *gin.Context) {
+	page, err := binding.BindPagination(c)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	sort, err := binding.BindSort(c, binding.Sort{Field: "created_at", Desc: true}, deviceSortFields...)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+
+	devices, total, err := r.devices.GetDevices(page.Page, page.PageSize, c.Query("status"), c.Query("station"), sort.Clause())
+	if err != nil {
+		r.logger.WithError(err).Error("查询设备列表失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询设备列表失败"})
+		return
+	}
+
+	data := make([]deviceWithHealth, 0, len(devices))
+	for _, device := range devices {
+		if !canAccessDevice(c, device.ID) {
+			total--
+			continue
+		}
+		item := deviceWithHealth{Device: device}
+		if r.health != nil {
+			health := r.health.GetHealth(device.ID)
+			item.Health = &health
+		}
+		data = append(data, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      data,
+		"total":     total,
+		"page":      page.Page,
+		"page_size": page.PageSize,
+		"station":   r.devices.Station(),
+	})
+}
+
+// setDeviceStationRequest 分配设备工位请求
+type setDeviceStationRequest struct {
+	Station string `json:"station" binding:"max=100"`
+}
+
+// setDeviceStation 将设备分配到指定工位，为空表示未分配；目标工位已有活跃设备时该设备改为非活跃
+func (r *Router) setDeviceStation(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+
+	var req setDeviceStationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	device, err := r.devices.AssignStation(id, strings.TrimSpace(req.Station))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+			return
+		}
+		r.logger.WithError(err).Error("分配设备工位失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "分配设备工位失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备工位已更新", "data": device})
+}
+
+// setDevicePathRequest 登记键盘设备路径请求
+type setDevicePathRequest struct {
+	DevicePath string `json:"device_path" binding:"max=500"`
+}
+
+// setDevicePath 登记设备的键盘设备路径（见扫码枪状态的 hook_stats.keyboards），为空表示取消登记
+func (r *Router) setDevicePath(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+
+	var req setDevicePathRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	device, err := r.devices.SetDevicePath(id, strings.TrimSpace(req.DevicePath))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+		case errors.Is(err, service.ErrDevicePathTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "设备路径已被使用", "message": err.Error()})
+		default:
+			r.logger.WithError(err).Error("登记设备路径失败")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "登记设备路径失败", "message": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备路径已更新", "data": device})
+}
+
+// setDeviceTimeoutRequest 设置设备按键超时请求，省略的项保持不变
+type setDeviceTimeoutRequest struct {
+	TimeoutMS *int  `json:"timeout_ms"` // 手动指定的按键超时，0表示使用 scanner.timeout_ms；优先于自动调整
+	Adaptive  *bool `json:"adaptive"`   // 按有效扫码的按键间隔自动调整按键超时
+}
+
+// setDeviceTimeout 设置设备的按键超时，本机按键采集来源从下一个按键起生效；当前生效的值见扫码状态的 timeouts
+func (r *Router) setDeviceTimeout(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+
+	var req setDeviceTimeoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	device, err := r.devices.SetDeviceTimeout(id, req.TimeoutMS, req.Adaptive)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+		case errors.Is(err, service.ErrInvalidDeviceTimeout):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "按键超时无效", "message": err.Error()})
+		default:
+			r.logger.WithError(err).Error("更新设备按键超时失败")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新设备按键超时失败", "message": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备按键超时已更新", "data": device})
+}
+
+// resetDeviceTimeout 清除设备自动调整学到的按键超时，更换扫码枪后重新学习；手动指定的按键超时不变
+func (r *Router) resetDeviceTimeout(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+
+	device, err := r.devices.ResetLearnedTimeout(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+			return
+		}
+		r.logger.WithError(err).Error("清除设备学到的按键超时失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "清除设备学到的按键超时失败", "message": err.Error()})
+		return
+	}
+	r.timeouts.Reset(id)
+	c.JSON(http.StatusOK, gin.H{"message": "设备学到的按键超时已清除", "data": device})
+}
+
+// restoreDevice 恢复已删除的设备，id 为冲突响应中的 deleted_id
+func (r *Router) restoreDevice(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "设备ID无效"})
+		return
+	}
+
+	device, err := r.devices.RestoreDevice(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "已删除的设备不存在"})
+			return
+		}
+		if errors.Is(err, service.ErrDeviceNameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "设备名称已被使用", "message": err.Error()})
+			return
+		}
+		r.logger.WithError(err).Error("恢复设备失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复设备失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备已恢复", "data": device})
+}
+
+// deletedConflictResponse 与已删除记录冲突的响应，附带该记录的信息及恢复方式
+func deletedConflictResponse(err *service.DeletedConflictError, restore string) gin.H {
+	return gin.H{
+		"error":      "与已删除的记录冲突",
+		"message":    err.Error(),
+		"kind":       err.Kind,
+		"field":      err.Field,
+		"deleted_id": err.ID,
+		"deleted_at": err.DeletedAt,
+		"restore":    restore,
+	}
+}
+
+// getDeviceHealth 获取设备健康评分及趋势，hours指定趋势时长（默认24小时）
+func (r *Router) getDeviceHealth(c *gin.Context) {
+	if r.health == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "设备健康评分未启用"})
+		return
+	}
+
+	deviceID, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+	if !canAccessDevice(c, deviceID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "令牌无权访问该设备"})
+		return
+	}
+
+	if _, err := r.devices.GetDevice(deviceID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+			return
+		}
+		r.logger.WithError(err).Error("查询设备失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询设备失败"})
+		return
+	}
+
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 || hours > 24*30 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours 应为1到720之间的整数"})
+		return
+	}
+
+	trend, err := r.health.GetTrend(deviceID, time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		r.logger.WithError(err).Error("查询健康评分趋势失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询健康评分趋势失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current": r.health.GetHealth(deviceID),
+		"trend":   trend,
+	})
+}
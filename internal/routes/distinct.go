@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recountDistinct 精确统计生产日的不同条码数量并与估算值比较，查询较慢，仅按需调用
+func (r *Router) recountDistinct(c *gin.Context) {
+	if r.distinct == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "不同条码数统计未启用"})
+		return
+	}
+
+	day := c.Query("day")
+	if day != "" {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "日期无效", "message": "格式应为 2006-01-02"})
+			return
+		}
+	}
+	var deviceID *uint
+	if value := c.Query("device_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "设备ID无效"})
+			return
+		}
+		device := uint(id)
+		deviceID = &device
+	}
+
+	result, err := r.distinct.Recount(day, deviceID)
+	if err != nil {
+		r.logger.WithError(err).Error("重算不同条码数失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重算不同条码数失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
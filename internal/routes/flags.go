@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/service"
+)
+
+// setFlagRequest 设置功能开关请求
+type setFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// getFlags 获取全部功能开关的当前值、默认值及是否需重启生效
+func (r *Router) getFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": r.flags.States()})
+}
+
+// setFlag 设置功能开关，运行时可切换的开关立即生效，其余重启后生效
+func (r *Router) setFlag(c *gin.Context) {
+	var req setFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	state, err := r.flags.Set(configActor(c), c.Param("name"), *req.Enabled)
+	if err != nil {
+		r.respondFlagError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": flagMessage(state, "功能开关已修改"), "data": state})
+}
+
+// resetFlag 删除功能开关的设置值，恢复为默认值（或配置文件中已弃用的旧配置项）
+func (r *Router) resetFlag(c *gin.Context) {
+	state, err := r.flags.Reset(configActor(c), c.Param("name"))
+	if err != nil {
+		r.respondFlagError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": flagMessage(state, "功能开关已重置"), "data": state})
+}
+
+// flagMessage 需重启生效时在提示中注明
+func flagMessage(state *service.FlagState, message string) string {
+	if state.PendingRestart {
+		return message + "，重启后生效"
+	}
+	return message
+}
+
+// respondFlagError 功能开关错误响应
+func (r *Router) respondFlagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownFlag):
+		c.JSON(http.StatusNotFound, gin.H{"error": "功能开关不存在", "message": err.Error()})
+	case errors.Is(err, service.ErrConfigProtected):
+		c.JSON(http.StatusForbidden, gin.H{"error": "系统配置受保护", "message": err.Error()})
+	case errors.Is(err, service.ErrInvalidConfigValue):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置值无效", "message": err.Error()})
+	default:
+		r.logger.WithError(err).Error("修改功能开关失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改功能开关失败", "message": err.Error()})
+	}
+}
+
+// getFlagStatus 值不是默认值（或待重启生效）的功能开关
+func (r *Router) getFlagStatus() interface{} {
+	flags := r.flags.NonDefault()
+	if flags == nil {
+		flags = []service.FlagState{}
+	}
+	return gin.H{"non_default": flags}
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/binding"
+)
+
+// resetSequenceRequest 重置序号请求
+type resetSequenceRequest struct {
+	Rule     string `json:"rule"`
+	Prefix   string `json:"prefix"`
+	DeviceID *uint  `json:"device_id"`
+}
+
+// getGaps 获取序列号断号记录
+func (r *Router) getGaps(c *gin.Context) {
+	page, err := binding.BindPagination(c)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+
+	var deviceID *uint
+	if value := c.Query("device_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "device_id 无效"})
+			return
+		}
+		v := uint(id)
+		deviceID = &v
+	}
+
+	gaps, total, err := r.sequence.GetGaps(page.Page, page.PageSize, c.Query("prefix"), deviceID)
+	if err != nil {
+		r.logger.WithError(err).Error("查询断号记录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询断号记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      gaps,
+		"total":     total,
+		"page":      page.Page,
+		"page_size": page.PageSize,
+	})
+}
+
+// resetSequence 声明合法的序号重置（如更换标签卷）
+func (r *Router) resetSequence(c *gin.Context) {
+	var req resetSequenceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+			return
+		}
+	}
+
+	if err := r.sequence.Reset(req.Rule, req.Prefix, req.DeviceID); err != nil {
+		r.logger.WithError(err).Error("重置序号失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置序号失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "序号已重置"})
+}
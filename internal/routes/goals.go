@@ -0,0 +1,184 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/binding"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// goalRequest 创建或更新产量目标请求
+type goalRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Scope       string `json:"scope" binding:"required"` // station, device, type, shift
+	DeviceID    *uint  `json:"device_id"`
+	BarcodeType string `json:"barcode_type"`
+	Shift       string `json:"shift"`
+	Target      int64  `json:"target" binding:"required"`
+	Recurrence  string `json:"recurrence"` // once, daily, weekdays，默认 daily
+	Date        string `json:"date"`       // recurrence=once 时的生产日
+	Enabled     *bool  `json:"enabled"`    // 默认启用
+}
+
+// toModel 转换为目标模型
+func (req *goalRequest) toModel() *models.Goal {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return &models.Goal{
+		Name:        req.Name,
+		Scope:       req.Scope,
+		DeviceID:    req.DeviceID,
+		BarcodeType: req.BarcodeType,
+		Shift:       req.Shift,
+		Target:      req.Target,
+		Recurrence:  req.Recurrence,
+		Date:        req.Date,
+		Enabled:     enabled,
+	}
+}
+
+// goalsEnabled 未启用产量目标时返回404
+func (r *Router) goalsEnabled(c *gin.Context) {
+	if r.goals == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "产量目标未启用"})
+		return
+	}
+	c.Next()
+}
+
+// getGoals 获取产量目标及当前生产日的进度
+func (r *Router) getGoals(c *gin.Context) {
+	loc, ok := r.requestLocation(c)
+	if !ok {
+		return
+	}
+	goals, err := r.goals.GetGoals()
+	if err != nil {
+		r.logger.WithError(err).Error("查询产量目标失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询产量目标失败"})
+		return
+	}
+	progress, err := r.goals.GetProgress(c.Query("day"), loc)
+	if err != nil {
+		r.logger.WithError(err).Error("查询目标进度失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目标进度失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": goals, "progress": progress})
+}
+
+// getGoal 获取产量目标
+func (r *Router) getGoal(c *gin.Context) {
+	id, ok := parseGoalID(c)
+	if !ok {
+		return
+	}
+	goal, err := r.goals.GetGoal(id)
+	if err != nil {
+		r.respondGoalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": goal})
+}
+
+// createGoal 创建产量目标
+func (r *Router) createGoal(c *gin.Context) {
+	var req goalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	goal := req.toModel()
+	if err := r.goals.CreateGoal(goal); err != nil {
+		r.respondGoalError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "产量目标已创建", "data": goal})
+}
+
+// updateGoal 更新产量目标
+func (r *Router) updateGoal(c *gin.Context) {
+	id, ok := parseGoalID(c)
+	if !ok {
+		return
+	}
+
+	var req goalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	goal, err := r.goals.UpdateGoal(id, req.toModel())
+	if err != nil {
+		r.respondGoalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "产量目标已更新", "data": goal})
+}
+
+// deleteGoal 删除产量目标
+func (r *Router) deleteGoal(c *gin.Context) {
+	id, ok := parseGoalID(c)
+	if !ok {
+		return
+	}
+	if err := r.goals.DeleteGoal(id); err != nil {
+		r.respondGoalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "产量目标已删除"})
+}
+
+// getGoalStats 各目标每个生产日的达成情况，默认最近7天
+func (r *Router) getGoalStats(c *gin.Context) {
+	loc, ok := r.requestLocation(c)
+	if !ok {
+		return
+	}
+	from, to, err := binding.BindDayRange(c, loc, time.Now(), 7)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+
+	stats, err := r.goals.GetAttainment(from, to, loc)
+	if err != nil {
+		r.respondGoalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": stats, "from": from, "to": to})
+}
+
+// parseGoalID 解析路径中的目标ID，无效时已写入响应
+func parseGoalID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "目标ID无效"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondGoalError 根据错误类型返回响应
+func (r *Router) respondGoalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidGoal):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "产量目标无效", "message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "产量目标不存在"})
+	default:
+		r.logger.WithError(err).Error("处理产量目标失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理产量目标失败", "message": err.Error()})
+	}
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/binding"
+)
+
+// getHeartbeats 查询工位心跳及可用性时段，from/to 为RFC3339时间或日期（默认最近24小时）
+func (r *Router) getHeartbeats(c *gin.Context) {
+	if r.heartbeats == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工位心跳未启用"})
+		return
+	}
+
+	period, err := binding.BindDateRange(c, r.location, 24*time.Hour)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	from, to := period.From, period.To
+
+	heartbeats, err := r.heartbeats.GetHeartbeats(from, to)
+	if err != nil {
+		r.logger.WithError(err).Error("查询心跳记录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询心跳记录失败"})
+		return
+	}
+	availability, err := r.heartbeats.GetAvailability(from, to)
+	if err != nil {
+		r.logger.WithError(err).Error("计算工位可用性失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "计算工位可用性失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         heartbeats,
+		"availability": availability,
+	})
+}
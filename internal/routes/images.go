@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/handlers"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// multipartOverhead 多部分表单除图片外允许的额外字节数
+const multipartOverhead = 64 * 1024
+
+// uploadBarcodeImage 上传扫码记录的条码图片，支持multipart（image字段）或直接以图片作为请求体
+func (r *Router) uploadBarcodeImage(c *gin.Context) {
+	if r.images == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用条码图片存储"})
+		return
+	}
+
+	record, ok := r.loadRecord(c)
+	if !ok {
+		return
+	}
+	if !r.checkDayLock(c, record, "image") {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, r.config.Images.MaxSizeKB*1024+multipartOverhead)
+	upload, closer, err := r.readImageUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取图片失败", "message": err.Error()})
+		return
+	}
+	if upload == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少图片"})
+		return
+	}
+	defer closer.Close()
+
+	image, err := r.images.SaveImage(record.ID, upload.ContentType, upload.Reader)
+	if err != nil {
+		r.respondImageError(c, err)
+		return
+	}
+
+	r.hub.BroadcastDeviceMessage("barcode_image", record.DeviceID, service.BarcodeImageEvent{
+		RecordID:    record.ID,
+		DeviceID:    record.DeviceID,
+		ContentType: image.ContentType,
+		Size:        image.Size,
+		URL:         imageURL(record.ID),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "图片已保存",
+		"data":    image,
+		"url":     imageURL(record.ID),
+	})
+}
+
+// submitBarcode 通过multipart表单提交扫码结果（content字段）及可选的图片（image字段）
+func (r *Router) submitBarcode(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, r.config.Images.MaxSizeKB*1024+multipartOverhead)
+
+	content := c.PostForm("content")
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少条码内容"})
+		return
+	}
+
+	upload, closer, err := r.readImageUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取图片失败", "message": err.Error()})
+		return
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	if upload != nil && r.images == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未启用条码图片存储"})
+		return
+	}
+
+	data, err := r.handler.Submit(content, upload)
+	switch {
+	case err == handlers.ErrMaintenance:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case data == nil || data.RecordID == 0:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "保存扫码记录失败", "message": errorMessage(err), "data": data})
+	case err != nil:
+		// 记录已保存但图片失败
+		c.JSON(http.StatusCreated, gin.H{"message": "扫码记录已保存，图片保存失败", "warning": err.Error(), "data": data})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"message": "扫码记录已保存", "data": data})
+	}
+}
+
+// getBarcodeImage 获取扫码记录的条码图片
+func (r *Router) getBarcodeImage(c *gin.Context) {
+	if r.images == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用条码图片存储"})
+		return
+	}
+
+	record, ok := r.loadRecord(c)
+	if !ok {
+		return
+	}
+
+	image, err := r.images.GetImage(record.ID)
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该记录没有图片"})
+		return
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("查询条码图片失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询条码图片失败"})
+		return
+	}
+
+	file, err := os.Open(r.images.FullPath(image))
+	if err != nil {
+		r.logger.WithError(err).WithField("record_id", record.ID).Warn("打开条码图片失败")
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片文件不存在"})
+		return
+	}
+	defer file.Close()
+
+	// 图片替换时路径会变化，以路径作为ETag
+	c.Header("Content-Type", image.ContentType)
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("ETag", fmt.Sprintf("\"%d-%d\"", image.ID, image.CreatedAt.UnixNano()))
+	http.ServeContent(c.Writer, c.Request, "", image.CreatedAt, file)
+}
+
+// loadRecord 根据路径参数加载扫码记录并检查设备权限
+func (r *Router) loadRecord(c *gin.Context) (*models.BarcodeRecord, bool) {
+	record, ok := r.findRecord(c)
+	if !ok {
+		return nil, false
+	}
+
+	if record.DeviceID != nil && !canAccessDevice(c, *record.DeviceID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "令牌无权访问该设备"})
+		return nil, false
+	}
+	return record, true
+}
+
+// readImageUpload 读取请求中的图片，multipart请求取image字段，其他请求以请求体作为图片
+func (r *Router) readImageUpload(c *gin.Context) (*handlers.ImageUpload, io.Closer, error) {
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("image")
+		if err == http.ErrMissingFile {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		return &handlers.ImageUpload{ContentType: partContentType(header), Reader: file}, file, nil
+	}
+
+	if c.Request.ContentLength == 0 {
+		return nil, nil, nil
+	}
+	return &handlers.ImageUpload{ContentType: c.ContentType(), Reader: c.Request.Body}, c.Request.Body, nil
+}
+
+// respondImageError 根据错误类型返回图片保存失败的响应
+func (r *Router) respondImageError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrImageTooLarge), errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "图片超过大小限制", "message": err.Error()})
+	case errors.Is(err, service.ErrImageTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "不支持的图片类型", "message": err.Error()})
+	default:
+		r.logger.WithError(err).Error("保存条码图片失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存条码图片失败"})
+	}
+}
+
+// partContentType 表单文件声明的类型
+func partContentType(header *multipart.FileHeader) string {
+	return header.Header.Get("Content-Type")
+}
+
+// imageURL 记录图片的访问地址
+func imageURL(recordID uint) string {
+	return fmt.Sprintf("/api/barcodes/%d/image", recordID)
+}
+
+// errorMessage 错误信息，nil时为空
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
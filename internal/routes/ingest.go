@@ -0,0 +1,192 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/contract"
+	"userclient/internal/handlers"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// maxIngestBodySize 推送请求体的最大字节数
+const maxIngestBodySize = 1 << 20
+
+// ingestResult 单条推送扫码的处理结果
+type ingestResult struct {
+	Index      int                  `json:"index"`
+	Status     string               `json:"status"` // created, duplicate, coalesced（已合并到其他设备同时扫到的记录）, failed
+	RecordID   uint                 `json:"record_id,omitempty"`
+	PublicID   string               `json:"public_id,omitempty"`
+	Type       string               `json:"type,omitempty"`
+	DeviceID   *uint                `json:"device_id,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	Path       string               `json:"path,omitempty"`       // 映射失败的字段路径
+	Violations []contract.Violation `json:"violations,omitempty"` // 不符合结构定义之处
+	code       int
+}
+
+// ingestEnabled 未启用外部推送时返回404
+func (r *Router) ingestEnabled(c *gin.Context) {
+	if r.ingest == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "外部推送未启用"})
+		return
+	}
+	c.Next()
+}
+
+// ingestScans 接收外部系统推送的扫码结果，单条返回该条的状态码，批量逐条返回结果
+func (r *Router) ingestScans(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIngestBodySize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "请求体过大", "message": err.Error()})
+		return
+	}
+
+	items, batch, err := r.ingest.Parse(body)
+	var mappingErr *service.IngestMappingError
+	var violationErr *contract.ViolationError
+	switch {
+	case errors.As(err, &violationErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "推送内容不符合结构定义", "message": err.Error(), "violations": violationErr.Violations})
+		return
+	case errors.As(err, &mappingErr):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "字段映射失败", "message": err.Error(), "path": mappingErr.Path})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "推送内容无效", "message": err.Error()})
+		return
+	}
+
+	p := getPrincipal(c)
+	if r.ingestion != nil {
+		if wait, ok := r.ingestion.Allow(ingestSource(c, p), len(items)); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁", "message": "推送速度超过接入限制，请稍后重试"})
+			return
+		}
+	}
+
+	results := make([]ingestResult, 0, len(items))
+	for i, parsed := range items {
+		result := r.ingestItem(p, parsed)
+		result.Index = i
+		results = append(results, result)
+	}
+
+	if !batch {
+		result := results[0]
+		if result.code >= http.StatusBadRequest {
+			c.JSON(result.code, gin.H{"error": "推送扫码失败", "message": result.Error, "path": result.Path, "violations": result.Violations, "data": result})
+			return
+		}
+		c.JSON(result.code, gin.H{"message": "扫码已接收", "data": result})
+		return
+	}
+
+	summary := map[string]int{"created": 0, "duplicate": 0, "coalesced": 0, "failed": 0}
+	for _, result := range results {
+		summary[result.Status]++
+	}
+	// 全部失败时以422返回，便于调用方发现映射配置错误
+	status := http.StatusOK
+	if summary["failed"] == len(results) {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"data": results, "summary": summary})
+}
+
+// ingestSource 推送方在接入限流中的来源标识，令牌认证时按令牌区分，否则按连接的对端地址；
+// 不采用 X-Forwarded-For，避免推送方修改请求头逃过本来源的限流
+func ingestSource(c *gin.Context, p *principal) string {
+	if p != nil && p.Token != nil {
+		return "ingest:" + p.Token.Name
+	}
+	return "ingest:" + remoteIP(c)
+}
+
+// ingestItem 处理一条推送的扫码，经过与本机扫码相同的校验、分类、入库及推送
+func (r *Router) ingestItem(p *principal, parsed service.IngestParsed) ingestResult {
+	failed := func(code int, err error) ingestResult {
+		result := ingestResult{Status: "failed", Error: err.Error(), code: code}
+		var mappingErr *service.IngestMappingError
+		if errors.As(err, &mappingErr) {
+			result.Path = mappingErr.Path
+		}
+		var violationErr *contract.ViolationError
+		if errors.As(err, &violationErr) {
+			result.Violations = violationErr.Violations
+		}
+		return result
+	}
+	if parsed.Err != nil {
+		return failed(http.StatusUnprocessableEntity, parsed.Err)
+	}
+	item := parsed.Item
+
+	scan := service.ScanInfo{
+		Source:    models.BarcodeSourceIngest,
+		ScannedAt: item.ScannedAt,
+		ScanID:    item.ScanID,
+	}
+	if item.Device != "" {
+		device, err := r.ingest.ResolveDevice(item.Device)
+		if errors.Is(err, service.ErrInvalidIngest) {
+			return failed(http.StatusUnprocessableEntity, err)
+		}
+		if err != nil {
+			r.logger.WithError(err).Error("查询推送扫码的设备失败")
+			return failed(http.StatusInternalServerError, err)
+		}
+		scan.DeviceID = &device.ID
+	}
+	// 限定设备的令牌只能推送这些设备的扫码
+	if p != nil && p.Devices != nil && (scan.DeviceID == nil || !p.Devices[*scan.DeviceID]) {
+		return failed(http.StatusForbidden, errors.New("令牌无权推送该设备的扫码"))
+	}
+
+	if item.ScanID != "" {
+		exists, err := r.ingest.Exists(item.ScanID)
+		if err != nil {
+			r.logger.WithError(err).Error("查询推送扫码是否重复失败")
+			return failed(http.StatusInternalServerError, err)
+		}
+		if exists {
+			return ingestResult{Status: "duplicate", DeviceID: scan.DeviceID, code: http.StatusOK}
+		}
+	}
+
+	data, err := r.handler.Ingest(item.Content, scan)
+	switch {
+	case err == handlers.ErrMaintenance:
+		return failed(http.StatusServiceUnavailable, err)
+	case errors.Is(err, service.ErrScanCoalesced):
+		return ingestResult{
+			Status:   "coalesced",
+			RecordID: data.RecordID,
+			PublicID: data.PublicID,
+			Type:     data.Type,
+			DeviceID: data.DeviceID,
+			code:     http.StatusOK,
+		}
+	case data == nil || data.RecordID == 0:
+		if err == nil {
+			err = errors.New("保存扫码记录失败")
+		}
+		return failed(http.StatusUnprocessableEntity, err)
+	}
+	return ingestResult{
+		Status:   "created",
+		RecordID: data.RecordID,
+		PublicID: data.PublicID,
+		Type:     data.Type,
+		DeviceID: data.DeviceID,
+		code:     http.StatusCreated,
+	}
+}
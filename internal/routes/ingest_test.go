@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIngestSourceIgnoresForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for i := 0; i < 3; i++ {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/ingest", nil)
+		c.Request.RemoteAddr = "203.0.113.7:40000"
+		c.Request.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		if got := ingestSource(c, nil); got != "ingest:203.0.113.7" {
+			t.Fatalf("ingestSource = %q, want ingest:203.0.113.7", got)
+		}
+	}
+}
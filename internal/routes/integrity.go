@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/binding"
+	"userclient/internal/service"
+)
+
+// integrityDefaultSpan 未指定 from 时校验的时间范围
+const integrityDefaultSpan = 24 * time.Hour
+
+// annotationRequest 添加批注请求
+type annotationRequest struct {
+	Note string `json:"note" binding:"required"`
+}
+
+// integrityEnabled 未启用完整性模式时返回404
+func (r *Router) integrityEnabled(c *gin.Context) {
+	if r.integrity == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "完整性模式未启用"})
+		return
+	}
+	c.Next()
+}
+
+// verifyIntegrity 校验时间范围内本工位扫码记录的哈希链，返回第一处断开的位置
+func (r *Router) verifyIntegrity(c *gin.Context) {
+	dates, err := binding.BindDateRange(c, r.location, integrityDefaultSpan)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	report, err := r.integrity.Verify(dates.From, dates.To)
+	if err != nil {
+		r.logger.WithError(err).Error("校验扫码记录完整性失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "校验扫码记录完整性失败", "message": err.Error()})
+		return
+	}
+	if !report.Valid {
+		r.logger.WithField("broken", report.Broken).Warn("扫码记录完整性校验未通过")
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// getIntegrityStatus 获取完整性模式及最近一次自检的结果
+func (r *Router) getIntegrityStatus() interface{} {
+	if r.integrity == nil {
+		return gin.H{"enabled": false}
+	}
+	return gin.H{"enabled": true, "last_check": r.integrity.LastReport()}
+}
+
+// getAnnotations 获取扫码记录的批注
+func (r *Router) getAnnotations(c *gin.Context) {
+	record, ok := r.loadRecord(c)
+	if !ok {
+		return
+	}
+	annotations, err := r.barcodes.GetAnnotations(record.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取批注失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": annotations})
+}
+
+// addAnnotation 为扫码记录追加批注，批注单独保存，不修改记录本身
+func (r *Router) addAnnotation(c *gin.Context) {
+	record, ok := r.loadRecord(c)
+	if !ok {
+		return
+	}
+	var req annotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	annotation, err := r.barcodes.AddAnnotation(record.ID, req.Note, annotationAuthor(c))
+	switch {
+	case errors.Is(err, service.ErrInvalidAnnotation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "批注无效", "message": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加批注失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "批注已添加", "data": annotation})
+}
+
+// annotationAuthor 批注的作者，令牌认证时为令牌名称
+func annotationAuthor(c *gin.Context) string {
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		return p.Token.Name
+	}
+	return "admin"
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/i18n"
+)
+
+// localeKey 请求语言在上下文中的键
+const localeKey = "locale"
+
+// localizedFields JSON 响应中按消息目录翻译的顶层字段
+var localizedFields = []string{"error", "message"}
+
+// localeMiddleware 确定请求语言并写入 Content-Language
+//
+// 依次按 ?lang=、Accept-Language、app.locale 选择支持的语言。处理函数中的文字均为源语言，
+// 非源语言的请求在响应写出前把 JSON 响应顶层 error、message 中的源语言文字按消息目录翻译，
+// 不在目录中的文字（如错误详情）原样输出；新增语言只需新增消息目录，处理函数无需修改。
+func (r *Router) localeMiddleware(c *gin.Context) {
+	locale := requestLocale(c)
+	c.Set(localeKey, locale)
+	c.Header("Content-Language", locale)
+	if locale == i18n.Source {
+		c.Next()
+		return
+	}
+
+	writer := &localizedWriter{ResponseWriter: c.Writer, locale: locale}
+	c.Writer = writer
+	c.Next()
+	writer.flush()
+	c.Writer = writer.ResponseWriter
+}
+
+// requestLocale 请求的语言：?lang= 优先，其次 Accept-Language，都不支持时使用默认语言
+func requestLocale(c *gin.Context) string {
+	if catalog, ok := i18n.Lookup(c.Query("lang")); ok {
+		return catalog.Locale
+	}
+	if locale, ok := i18n.Negotiate(c.GetHeader("Accept-Language")); ok {
+		return locale
+	}
+	return i18n.Default()
+}
+
+// localeOf 中间件确定的请求语言
+func localeOf(c *gin.Context) string {
+	if locale := c.GetString(localeKey); locale != "" {
+		return locale
+	}
+	return i18n.Default()
+}
+
+// localizedWriter 暂存 JSON 响应，处理结束后翻译再写出；其他类型的响应（页面、文件、WebSocket、事件流）直接写出
+type localizedWriter struct {
+	gin.ResponseWriter
+	locale    string
+	decided   bool
+	buffering bool
+	buf       bytes.Buffer
+}
+
+// Write 首次写入时按 Content-Type 决定是否暂存
+func (w *localizedWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffering = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+	if !w.buffering {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buf.Write(data)
+}
+
+// WriteString 同 Write
+func (w *localizedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written 已暂存的响应视为已写出，避免后续处理再写入默认响应
+func (w *localizedWriter) Written() bool {
+	return w.buffering || w.ResponseWriter.Written()
+}
+
+// flush 翻译并写出暂存的 JSON 响应
+func (w *localizedWriter) flush() {
+	if !w.buffering {
+		return
+	}
+	data := w.buf.Bytes()
+	if translated, ok := translateJSON(w.locale, data); ok {
+		data = translated
+		w.Header().Del("Content-Length")
+	}
+	w.ResponseWriter.Write(data)
+}
+
+// translateJSON 翻译 JSON 对象顶层 error、message 中的源语言文字，没有可翻译的文字时返回 false
+func translateJSON(locale string, data []byte) ([]byte, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, false
+	}
+	changed := false
+	for _, key := range localizedFields {
+		var text string
+		if raw, ok := fields[key]; !ok || json.Unmarshal(raw, &text) != nil {
+			continue
+		}
+		translated, ok := i18n.Translate(locale, text)
+		if !ok {
+			continue
+		}
+		raw, err := json.Marshal(translated)
+		if err != nil {
+			continue
+		}
+		fields[key] = raw
+		changed = true
+	}
+	if !changed {
+		return nil, false
+	}
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startMaintenanceRequest 进入维护模式请求
+type startMaintenanceRequest struct {
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"` // 自动结束时长，如 "30m"，为空表示需手动结束
+}
+
+// getMaintenance 获取维护模式状态
+func (r *Router) getMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, r.maintenance.GetState())
+}
+
+// startMaintenance 进入维护模式
+func (r *Router) startMaintenance(c *gin.Context) {
+	var req startMaintenanceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+			return
+		}
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "维护时长无效", "message": "duration 应为正的时长，如 30m"})
+			return
+		}
+		duration = d
+	}
+
+	state, err := r.maintenance.Start(req.Reason, duration)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "data": state})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已进入维护模式", "data": state})
+}
+
+// endMaintenance 结束维护模式
+func (r *Router) endMaintenance(c *gin.Context) {
+	state, err := r.maintenance.End()
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "data": state})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出维护模式", "data": state})
+}
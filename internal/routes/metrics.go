@@ -0,0 +1,221 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/binding"
+	"userclient/internal/service"
+)
+
+// getMetrics 以Prometheus文本格式输出监控指标
+func (r *Router) getMetrics(c *gin.Context) {
+	var b strings.Builder
+	writeMetrics(&b, r.metrics.Gather())
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// registerMetrics 向登记表注册各组件的监控指标，/metrics 与指标快照共用
+func (r *Router) registerMetrics() {
+	r.metrics.Register(func() []service.Metric {
+		hubMetrics := r.hub.Metrics()
+		maintenance := 0.0
+		if r.maintenance.IsActive() {
+			maintenance = 1
+		}
+		return []service.Metric{
+			service.GaugeMetric("barcode_websocket_clients", "当前WebSocket连接数", float64(r.hub.GetClientCount())),
+			service.GaugeMetric("barcode_websocket_queue_depth", "广播通道中等待分发的消息数", float64(r.hub.QueueDepth())),
+			service.CounterMetric("barcode_websocket_marshal_errors_total", "序列化失败未能广播的消息数", float64(hubMetrics.MarshalErrors)),
+			service.CounterMetric("barcode_websocket_truncated_total", "超出客户端大小上限而精简发送的消息数", float64(hubMetrics.Truncated)),
+			service.CounterMetric("barcode_websocket_dropped_total", "精简后仍超出客户端大小上限而未发送的消息数", float64(hubMetrics.Dropped)),
+			service.CounterMetric("barcode_websocket_queue_full_total", "广播通道已满而丢弃的消息数", float64(hubMetrics.QueueFull)),
+			service.GaugeMetric("barcode_maintenance_active", "是否处于维护模式", maintenance),
+		}
+	})
+	if r.scanMetrics != nil {
+		r.metrics.Register(r.scanMetrics.Collect)
+	}
+	if r.db != nil {
+		r.metrics.Register(r.collectDBPoolMetrics)
+	}
+	if r.configCache != nil {
+		r.metrics.Register(func() []service.Metric {
+			metrics := r.configCache.GetMetrics()
+			return []service.Metric{
+				service.CounterMetric("barcode_config_cache_hits_total", "系统配置缓存命中次数", float64(metrics.Hits)),
+				service.CounterMetric("barcode_config_cache_misses_total", "系统配置缓存未命中、查询数据库的次数", float64(metrics.Misses)),
+				service.CounterMetric("barcode_config_cache_stale_total", "缓存到期后发现配置已被绕过配置服务修改的次数", float64(metrics.Stale)),
+				service.GaugeMetric("barcode_config_cache_entries", "系统配置缓存的条目数", float64(metrics.Entries)),
+			}
+		})
+	}
+	if r.idle != nil {
+		r.metrics.Register(func() []service.Metric {
+			return idleMetrics(r.idle.GetStatus())
+		})
+	}
+	if r.ingestion != nil {
+		r.metrics.Register(func() []service.Metric {
+			return ingestionMetrics(r.ingestion.Status())
+		})
+	}
+	if r.scanQueue != nil {
+		r.metrics.Register(r.scanQueue.Collect)
+	}
+	if r.coalescer != nil {
+		r.metrics.Register(r.coalescer.Collect)
+	}
+}
+
+// collectDBPoolMetrics 数据库连接池指标
+func (r *Router) collectDBPoolMetrics() []service.Metric {
+	sqlDB, err := r.db.DB.DB()
+	if err != nil {
+		return nil
+	}
+	stats := sqlDB.Stats()
+	return []service.Metric{
+		service.GaugeMetric("barcode_db_open_connections", "数据库连接池中的连接数", float64(stats.OpenConnections)),
+		service.GaugeMetric("barcode_db_in_use_connections", "正在使用的数据库连接数", float64(stats.InUse)),
+		service.GaugeMetric("barcode_db_idle_connections", "空闲的数据库连接数", float64(stats.Idle)),
+		service.CounterMetric("barcode_db_wait_total", "等待空闲连接的次数", float64(stats.WaitCount)),
+		service.CounterMetric("barcode_db_wait_seconds_total", "等待空闲连接的累计时长（秒）", stats.WaitDuration.Seconds()),
+	}
+}
+
+// idleMetrics 距上次扫码时长指标，device="station" 表示整个工位
+func idleMetrics(status service.IdleStatus) []service.Metric {
+	tracking := 0.0
+	if status.Tracking {
+		tracking = 1
+	}
+
+	entries := append([]service.IdleEntry{status.Station}, status.Devices...)
+	idle := service.Metric{Name: "barcode_idle_seconds", Help: "距上次扫码的时长（秒），暂停计时时为0", Type: service.MetricTypeGauge}
+	lastScan := service.Metric{Name: "barcode_last_scan_timestamp_seconds", Help: "最后一次扫码的Unix时间戳", Type: service.MetricTypeGauge}
+	for _, entry := range entries {
+		idle.Samples = append(idle.Samples, service.MetricSample{Labels: idleLabels(entry), Value: entry.IdleSeconds})
+		if entry.LastScanAt != nil {
+			lastScan.Samples = append(lastScan.Samples, service.MetricSample{Labels: idleLabels(entry), Value: float64(entry.LastScanAt.UnixMilli()) / 1000})
+		}
+	}
+	return []service.Metric{
+		service.GaugeMetric("barcode_idle_tracking", "是否处于空闲计时中（班次内且非维护模式）", tracking),
+		idle,
+		lastScan,
+	}
+}
+
+// idleLabels 空闲指标的标签
+func idleLabels(entry service.IdleEntry) map[string]string {
+	if entry.DeviceID == 0 {
+		return map[string]string{"device": "station"}
+	}
+	return map[string]string{"device": strconv.FormatUint(uint64(entry.DeviceID), 10)}
+}
+
+// ingestionMetrics 扫码接入限流指标，按来源输出是否正在限流及延迟、拒绝的扫码数
+func ingestionMetrics(status service.IngestLimiterStatus) []service.Metric {
+	throttled := service.Metric{Name: "barcode_ingestion_throttled", Help: "扫码来源是否正在被限流", Type: service.MetricTypeGauge}
+	delayed := service.Metric{Name: "barcode_ingestion_delayed_total", Help: "等待额度后才处理的扫码数（网络扫码枪）", Type: service.MetricTypeCounter}
+	rejected := service.Metric{Name: "barcode_ingestion_rejected_total", Help: "超过接入速度限制以429拒绝的扫码数（外部推送）", Type: service.MetricTypeCounter}
+	for _, source := range status.Sources {
+		if source.Priority {
+			continue
+		}
+		labels := map[string]string{"source": source.Source}
+		value := 0.0
+		if source.Throttled {
+			value = 1
+		}
+		throttled.Samples = append(throttled.Samples, service.MetricSample{Labels: labels, Value: value})
+		delayed.Samples = append(delayed.Samples, service.MetricSample{Labels: labels, Value: float64(source.Delayed)})
+		rejected.Samples = append(rejected.Samples, service.MetricSample{Labels: labels, Value: float64(source.Rejected)})
+	}
+	return []service.Metric{
+		service.GaugeMetric("barcode_ingestion_global_tokens", "全局剩余的接入额度", status.GlobalTokens),
+		service.CounterMetric("barcode_ingestion_admitted_total", "通过接入限流的扫码数", float64(status.Admitted)),
+		throttled,
+		delayed,
+		rejected,
+	}
+}
+
+// writeMetrics 按Prometheus文本格式输出指标
+func writeMetrics(b *strings.Builder, metrics []service.Metric) {
+	for _, metric := range metrics {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", metric.Name, metric.Help, metric.Name, metric.Type)
+		for _, sample := range metric.Samples {
+			writeSample(b, metric.Name, sample.Labels, sample.Value)
+		}
+	}
+}
+
+// writeSample 输出一个样本，标签值按Prometheus规则转义
+func writeSample(b *strings.Builder, name string, labels map[string]string, value float64) {
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		first := true
+		for key, val := range labels {
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			fmt.Fprintf(b, "%s=%q", key, val)
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(b, " %s\n", strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// historyEnabled 未启用监控指标快照时返回404
+func (r *Router) historyEnabled(c *gin.Context) {
+	if r.history == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "监控指标快照未启用"})
+		return
+	}
+	c.Next()
+}
+
+// getMetricsHistory 查询监控指标快照，metric 为指标名称（省略时列出可查询的指标），
+// from/to 为RFC3339时间或日期（默认最近24小时）
+func (r *Router) getMetricsHistory(c *gin.Context) {
+	metric := c.Query("metric")
+	if metric == "" {
+		metrics := r.history.Metrics()
+		data := make([]gin.H, 0, len(metrics))
+		for _, m := range metrics {
+			data = append(data, gin.H{"name": m.Name, "type": m.Type, "help": m.Help})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": data})
+		return
+	}
+
+	period, err := binding.BindDateRange(c, r.location, 24*time.Hour)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	from, to := period.From, period.To
+
+	history, err := r.history.History(metric, from, to)
+	switch {
+	case errors.Is(err, service.ErrUnknownMetric):
+		c.JSON(http.StatusNotFound, gin.H{"error": "指标不存在", "message": err.Error()})
+	case errors.Is(err, service.ErrMetricRangeTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "查询范围过大", "message": err.Error()})
+	case err != nil:
+		r.logger.WithError(err).Error("查询监控指标快照失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询监控指标快照失败"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"data": history})
+	}
+}
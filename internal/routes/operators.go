@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/binding"
+	"userclient/internal/service"
+)
+
+// getOperatorStats 按操作员统计时间范围内的扫码数、有效/无效占比及在岗时间，from/to 为RFC3339时间或日期（默认最近24小时）
+//
+// 隐私设置为使用化名时返回化名，关闭时返回403
+func (r *Router) getOperatorStats(c *gin.Context) {
+	// 按操作员的统计涵盖全部设备，限定设备的令牌不能查看
+	if p := getPrincipal(c); p != nil && p.Devices != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "限定设备的令牌不能查看按操作员的统计"})
+		return
+	}
+	period, err := binding.BindDateRange(c, r.location, 24*time.Hour)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+
+	report, err := r.operators.Report(period.From, period.To)
+	if errors.Is(err, service.ErrOperatorStatsDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "按操作员的统计已关闭", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("统计操作员效率失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计操作员效率失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
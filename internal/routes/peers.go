@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/peer"
+)
+
+// receivePeerEvent 接收相邻工位转发的事件并在本地广播，不再继续转发
+func (r *Router) receivePeerEvent(c *gin.Context) {
+	var event peer.Event
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	if event.Origin == "" || event.Type == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少来源工位或事件类型"})
+		return
+	}
+
+	// 本工位发出的事件绕回时直接丢弃
+	if r.forwarder != nil && event.Origin == r.forwarder.Station() {
+		c.JSON(http.StatusOK, gin.H{"message": "已忽略本工位发出的事件"})
+		return
+	}
+
+	r.hub.BroadcastForwarded(event.Type, event.Origin, event.Time, event.Data)
+	c.JSON(http.StatusAccepted, gin.H{"message": "事件已接收"})
+}
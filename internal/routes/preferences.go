@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/service"
+)
+
+// preferencesEnabled 未启用看板偏好设置时返回404
+func (r *Router) preferencesEnabled(c *gin.Context) {
+	if r.preferences == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "看板偏好设置未启用"})
+		return
+	}
+	c.Next()
+}
+
+// getPreference 获取客户端的偏好设置，尚未保存时返回404，客户端使用默认设置
+func (r *Router) getPreference(c *gin.Context) {
+	preference, err := r.preferences.Get(c.Param("client_key"))
+	if err != nil {
+		r.respondPreferenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"client_key": preference.ClientKey,
+		"data":       json.RawMessage(preference.Data),
+		"updated_at": preference.UpdatedAt,
+		"updated_by": preference.UpdatedBy,
+	})
+}
+
+// putPreference 保存客户端的偏好设置，请求体为任意JSON
+func (r *Router) putPreference(c *gin.Context) {
+	// 多读一个字节以区分恰好达到上限与超出上限
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(r.config.Preferences.MaxBytes)+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败", "message": err.Error()})
+		return
+	}
+
+	preference, created, err := r.preferences.Put(c.Param("client_key"), data, preferenceActor(c))
+	if err != nil {
+		r.respondPreferenceError(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"message": "偏好设置已保存", "data": preference})
+}
+
+// getPreferences 获取所有客户端的偏好设置（不含内容）
+func (r *Router) getPreferences(c *gin.Context) {
+	preferences, err := r.preferences.List()
+	if err != nil {
+		r.respondPreferenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": preferences, "total": len(preferences)})
+}
+
+// deletePreference 删除客户端的偏好设置
+func (r *Router) deletePreference(c *gin.Context) {
+	if err := r.preferences.Delete(c.Param("client_key"), preferenceActor(c)); err != nil {
+		r.respondPreferenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "偏好设置已删除"})
+}
+
+// preferenceActor 当前请求的操作者
+func preferenceActor(c *gin.Context) service.PreferenceActor {
+	actor := service.PreferenceActor{
+		Name:      "admin",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		actor.Name = p.Token.Name
+	}
+	return actor
+}
+
+// respondPreferenceError 根据错误类型返回响应
+func (r *Router) respondPreferenceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidPreference):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "偏好设置无效", "message": err.Error()})
+	case errors.Is(err, service.ErrPreferenceTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "偏好设置超过大小限制", "message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "偏好设置不存在"})
+	default:
+		r.logger.WithError(err).Error("处理偏好设置失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理偏好设置失败", "message": err.Error()})
+	}
+}
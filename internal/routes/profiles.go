@@ -0,0 +1,197 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// profileRequest 创建或更新配置档案请求
+type profileRequest struct {
+	Name        string               `json:"name" binding:"required"`
+	Vendor      string               `json:"vendor"`
+	Model       string               `json:"model"`
+	Description string               `json:"description"`
+	Codes       []models.ProfileCode `json:"codes" binding:"required"` // 按扫描顺序排列
+}
+
+// toModel 转换为配置档案模型
+func (req *profileRequest) toModel() *models.ScannerProfile {
+	return &models.ScannerProfile{
+		Name:        req.Name,
+		Vendor:      req.Vendor,
+		Model:       req.Model,
+		Description: req.Description,
+		Codes:       req.Codes,
+	}
+}
+
+// assignProfileRequest 设备指定配置档案请求
+type assignProfileRequest struct {
+	ProfileID *uint `json:"profile_id"` // 为null表示解除
+}
+
+// getProfiles 获取配置档案列表
+func (r *Router) getProfiles(c *gin.Context) {
+	profiles, err := r.profiles.GetProfiles()
+	if err != nil {
+		r.logger.WithError(err).Error("查询配置档案失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询配置档案失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": profiles})
+}
+
+// getProfile 获取配置档案及已应用的设备
+func (r *Router) getProfile(c *gin.Context) {
+	id, ok := parseProfileID(c)
+	if !ok {
+		return
+	}
+
+	profile, err := r.profiles.GetProfile(id)
+	if err != nil {
+		r.respondProfileError(c, err)
+		return
+	}
+	devices, err := r.profiles.GetProfileDevices(id)
+	if err != nil {
+		r.respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": profile, "devices": devices})
+}
+
+// createProfile 创建配置档案
+func (r *Router) createProfile(c *gin.Context) {
+	var req profileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	profile := req.toModel()
+	if err := r.profiles.CreateProfile(profile); err != nil {
+		r.respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "配置档案已创建", "data": profile})
+}
+
+// updateProfile 更新配置档案
+func (r *Router) updateProfile(c *gin.Context) {
+	id, ok := parseProfileID(c)
+	if !ok {
+		return
+	}
+
+	var req profileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	profile, err := r.profiles.UpdateProfile(id, req.toModel())
+	if err != nil {
+		r.respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "配置档案已更新", "data": profile})
+}
+
+// deleteProfile 删除配置档案
+func (r *Router) deleteProfile(c *gin.Context) {
+	id, ok := parseProfileID(c)
+	if !ok {
+		return
+	}
+
+	if err := r.profiles.DeleteProfile(id); err != nil {
+		r.respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "配置档案已删除"})
+}
+
+// getProfileSheet 导出配置档案打印页，format为text（默认）或html
+func (r *Router) getProfileSheet(c *gin.Context) {
+	id, ok := parseProfileID(c)
+	if !ok {
+		return
+	}
+
+	profile, err := r.profiles.GetProfile(id)
+	if err != nil {
+		r.respondProfileError(c, err)
+		return
+	}
+
+	format := c.DefaultQuery("format", service.SheetFormatText)
+	var buf bytes.Buffer
+	if err := r.profiles.WriteSheet(&buf, profile, format); err != nil {
+		r.respondProfileError(c, err)
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if format == service.SheetFormatHTML {
+		contentType = "text/html; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// assignDeviceProfile 为设备指定配置档案
+func (r *Router) assignDeviceProfile(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+
+	var req assignProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	device, err := r.profiles.AssignProfile(id, req.ProfileID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "设备或配置档案不存在"})
+			return
+		}
+		r.logger.WithError(err).Error("更新设备配置档案失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新设备配置档案失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备配置档案已更新", "data": device})
+}
+
+// parseProfileID 解析路径中的档案ID，无效时已写入响应
+func parseProfileID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置档案ID无效"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondProfileError 根据错误类型返回响应
+func (r *Router) respondProfileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidProfile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置档案无效", "message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "配置档案不存在"})
+	default:
+		r.logger.WithError(err).Error("处理配置档案失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理配置档案失败", "message": err.Error()})
+	}
+}
@@ -0,0 +1,74 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// markDeprecatedID 路径中使用整数ID时提示调用方改用公开ID
+func markDeprecatedID(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	c.Header("Warning", `299 - "整数ID已弃用，请改用public_id"`)
+}
+
+// findRecord 根据路径参数查找扫码记录，支持公开ID及（已弃用的）整数ID，失败时已写入响应
+func (r *Router) findRecord(c *gin.Context) (*models.BarcodeRecord, bool) {
+	value := c.Param("id")
+
+	var record *models.BarcodeRecord
+	var err error
+	if models.IsPublicID(value) {
+		record, err = r.barcodes.GetBarcodeRecordByPublicID(value)
+	} else {
+		id, perr := strconv.ParseUint(value, 10, 32)
+		if perr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "记录ID无效"})
+			return nil, false
+		}
+		markDeprecatedID(c)
+		record, err = r.barcodes.GetBarcodeRecord(uint(id))
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "扫码记录不存在"})
+		return nil, false
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("查询扫码记录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询扫码记录失败"})
+		return nil, false
+	}
+	return record, true
+}
+
+// parseDeviceID 解析路径中的设备ID，公开ID转换为整数ID，失败时已写入响应
+func (r *Router) parseDeviceID(c *gin.Context) (uint, bool) {
+	value := c.Param("id")
+	if !models.IsPublicID(value) {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "设备ID无效"})
+			return 0, false
+		}
+		markDeprecatedID(c)
+		return uint(id), true
+	}
+
+	device, err := r.devices.GetDeviceByPublicID(value)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+		return 0, false
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("查询设备失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询设备失败"})
+		return 0, false
+	}
+	return device.ID, true
+}
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/binding"
+)
+
+// qualityEnabled 未启用数据质量报告时返回404
+func (r *Router) qualityEnabled(c *gin.Context) {
+	if r.quality == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "数据质量报告未启用"})
+		return
+	}
+	c.Next()
+}
+
+// getQualityReport 计算时间范围内扫码的数据质量，from/to 为RFC3339时间或日期（默认最近24小时），
+// device_id 指定时只统计该设备
+func (r *Router) getQualityReport(c *gin.Context) {
+	period, err := binding.BindDateRange(c, r.location, 24*time.Hour)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	from, to := period.From, period.To
+
+	var deviceID *uint
+	if value := c.Query("device_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "device_id 无效"})
+			return
+		}
+		v := uint(id)
+		deviceID = &v
+	}
+	// 受限令牌只能查看部分设备，必须指定设备（是否在令牌范围内已由认证中间件检查）
+	if p := getPrincipal(c); p != nil && p.Devices != nil && deviceID == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "限定设备的令牌须指定 device_id"})
+		return
+	}
+
+	report, err := r.quality.Report(from, to, deviceID)
+	if err != nil {
+		r.logger.WithError(err).Error("计算数据质量报告失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "计算数据质量报告失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// getQualityReports 定时保存的全工位报告，按窗口从新到旧
+func (r *Router) getQualityReports(c *gin.Context) {
+	if p := getPrincipal(c); p != nil && p.Devices != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "限定设备的令牌不能查看全工位报告"})
+		return
+	}
+	limit, err := binding.BindLimit(c, 30, 365)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+
+	reports, err := r.quality.Reports(limit)
+	if err != nil {
+		r.logger.WithError(err).Error("查询数据质量报告失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询数据质量报告失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": reports})
+}
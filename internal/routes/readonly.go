@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyAllowed 只读模式下仍允许的非查询方法路由（方法 + 路由模板），须确认处理函数不写入数据库、不改变运行状态
+var readOnlyAllowed = map[string]bool{
+	http.MethodPost + " /api/rules/evaluate": true, // 判定条码，不创建扫码记录；草稿规则不保存
+}
+
+// readOnlyGuard 只读模式下拒绝所有修改类请求，查询接口、静态页面及 readOnlyAllowed 中的路由不受影响
+func (r *Router) readOnlyGuard(c *gin.Context) {
+	if !r.config.App.ReadOnly {
+		c.Next()
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	if readOnlyAllowed[c.Request.Method+" "+c.FullPath()] {
+		c.Next()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "只读模式", "message": "当前以只读模式运行（app.read_only），不允许修改数据"})
+}
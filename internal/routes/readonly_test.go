@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// routePath 把路由模板中的参数替换为具体值
+func routePath(template string) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "1"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// TestReadOnlyBlocksMutatingRoutes 只读模式下每个修改类路由都返回403，只有 readOnlyAllowed 中的路由交给处理函数
+func TestReadOnlyBlocksMutatingRoutes(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.App.Provisioned = true
+	cfg.App.ReadOnly = true
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	engine := New(Dependencies{Config: cfg, Logger: logger}).Setup()
+
+	registered := make(map[string]bool)
+	blocked := 0
+	for _, route := range engine.Routes() {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		switch route.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			continue
+		}
+
+		req := httptest.NewRequest(route.Method, routePath(route.Path), strings.NewReader(""))
+		req.RemoteAddr = "203.0.113.7:40000"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		if readOnlyAllowed[key] {
+			if w.Code == http.StatusForbidden {
+				t.Errorf("%s is allowed in read-only mode but got 403: %s", key, w.Body)
+			}
+			continue
+		}
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != http.StatusForbidden || body.Error != "只读模式" {
+			t.Errorf("%s: got %d %s, want the read-only 403", key, w.Code, w.Body)
+		}
+		blocked++
+	}
+	if blocked == 0 {
+		t.Fatal("no mutating routes registered")
+	}
+	for key := range readOnlyAllowed {
+		if !registered[key] {
+			t.Errorf("readOnlyAllowed lists %s, which is not a registered route", key)
+		}
+	}
+}
+
+// TestReadOnlyPassesQueries 只读模式下查询路由（包括统计、列表、导出及WebSocket订阅）不经只读拦截，完整流程见 harness.TestReadOnlyServesQueries
+func TestReadOnlyPassesQueries(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.App.Provisioned = true
+	cfg.App.ReadOnly = true
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	router := New(Dependencies{Config: cfg, Logger: logger})
+	engine := router.Setup()
+
+	queries := 0
+	for _, route := range engine.Routes() {
+		if route.Method != http.MethodGet {
+			continue
+		}
+		queries++
+		req := httptest.NewRequest(route.Method, routePath(route.Path), nil)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		router.readOnlyGuard(c)
+		if c.IsAborted() {
+			t.Errorf("GET %s is blocked in read-only mode: %d %s", route.Path, w.Code, w.Body)
+		}
+	}
+	if queries == 0 {
+		t.Fatal("no query routes registered")
+	}
+	for _, path := range []string{"/api/stats", "/api/barcodes", "/api/admin/support-bundle", "/ws"} {
+		found := false
+		for _, route := range engine.Routes() {
+			found = found || route.Method == http.MethodGet && route.Path == path
+		}
+		if !found {
+			t.Errorf("GET %s is not registered", path)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/binding"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// replayRequest 历史扫码记录回放请求
+type replayRequest struct {
+	From    time.Time           `json:"from" binding:"required"`  // RFC3339，含
+	To      time.Time           `json:"to" binding:"required"`    // RFC3339，不含
+	Sinks   []string            `json:"sinks" binding:"required"` // file, websocket
+	Filters models.ReplayFilter `json:"filters"`
+}
+
+// createReplay 创建回放任务，已有任务运行时排队
+func (r *Router) createReplay(c *gin.Context) {
+	var req replayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	actor := "admin"
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		actor = p.Token.Name
+	}
+	job, err := r.replay.Submit(service.ReplayRequest{
+		From:      req.From,
+		To:        req.To,
+		Sinks:     req.Sinks,
+		Filter:    req.Filters,
+		CreatedBy: actor,
+	})
+	if err != nil {
+		r.respondReplayError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "回放任务已创建", "data": job})
+}
+
+// getReplays 最近的回放任务及可回放的输出端
+func (r *Router) getReplays(c *gin.Context) {
+	limit, err := binding.BindLimit(c, 20, 100)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	jobs, err := r.replay.ListJobs(limit)
+	if err != nil {
+		r.respondReplayError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": jobs, "sinks": r.replay.Sinks()})
+}
+
+// getReplay 回放任务状态及进度
+func (r *Router) getReplay(c *gin.Context) {
+	id, ok := parseReplayID(c)
+	if !ok {
+		return
+	}
+	job, err := r.replay.GetJob(id)
+	if err != nil {
+		r.respondReplayError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// cancelReplay 取消排队或运行中的回放任务
+func (r *Router) cancelReplay(c *gin.Context) {
+	id, ok := parseReplayID(c)
+	if !ok {
+		return
+	}
+	job, err := r.replay.Cancel(id)
+	if err != nil {
+		r.respondReplayError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "回放任务已取消", "data": job})
+}
+
+// resumeReplay 从检查点继续失败或已取消的回放任务
+func (r *Router) resumeReplay(c *gin.Context) {
+	id, ok := parseReplayID(c)
+	if !ok {
+		return
+	}
+	job, err := r.replay.Resume(id)
+	if err != nil {
+		r.respondReplayError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "回放任务已重新排队", "data": job})
+}
+
+// parseReplayID 解析路径中的回放任务ID，无效时已写入响应
+func parseReplayID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "回放任务ID无效"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondReplayError 根据错误类型返回响应
+func (r *Router) respondReplayError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidReplay):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "回放请求无效", "message": err.Error()})
+	case errors.Is(err, service.ErrReplayState):
+		c.JSON(http.StatusConflict, gin.H{"error": "回放任务状态不允许该操作", "message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "回放任务不存在"})
+	default:
+		r.logger.WithError(err).Error("处理回放任务失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理回放任务失败", "message": err.Error()})
+	}
+}
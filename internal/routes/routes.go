@@ -4,33 +4,270 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"userclient/internal/agent"
+	"userclient/internal/binding"
+	"userclient/internal/config"
+	"userclient/internal/database"
 	"userclient/internal/handlers"
+	"userclient/internal/models"
+	"userclient/internal/peer"
+	"userclient/internal/scanner"
+	"userclient/internal/scanner/network"
+	"userclient/internal/scanner/serial"
+	"userclient/internal/service"
+	"userclient/internal/sink"
+	"userclient/internal/support"
 	"userclient/internal/websocket"
+	"userclient/pkg/barcode"
+	"userclient/pkg/payload"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
+// Dependencies 路由依赖的组件
+type Dependencies struct {
+	Config      *config.Config
+	Logger      *logrus.Logger
+	Hub         *websocket.Hub
+	Handler     *handlers.BarcodeHandler
+	DB          *database.DB
+	Maintenance *service.MaintenanceService
+	Capture     *service.CaptureService   // 按键采集暂停/恢复
+	Ingestion   *service.IngestLimiter    // 扫码接入限流，为nil时外部推送不限流
+	Integrity   *service.IntegrityService // 完整性哈希链，未启用时为nil
+	Coalescer   *service.ScanCoalescer    // 跨设备合并，未启用时为nil
+	Operators   *service.OperatorStatsService
+	Barcodes    *service.BarcodeService
+	StatsCache  *service.StatsCache
+	ConfigCache *service.ConfigCache // 系统配置缓存，为nil时不输出缓存指标
+	FileSink    *sink.FileSink
+	Outbox      *service.OutboxService
+	Sequence    *service.SequenceService
+	Tokens      *service.TokenService
+	Devices     *service.DeviceService
+	Health      *service.HealthService
+	Images      *service.ImageService
+	Forwarder   *peer.Forwarder
+	Idle        *service.IdleService
+	Reset       *service.ResetService
+	Rules       *service.RuleService
+	Profiles    *service.ProfileService
+	Replication *service.ReplicationService
+	Configs     *service.ConfigService
+	Summary     func() string // 运行状态摘要（纯文本）
+	Commands    *service.CommandService
+	Heartbeats  *service.HeartbeatService
+	Counters    *service.CounterService // 持久化计数器，只读模式下为nil
+	Logs        *support.LogBuffer      // 最近日志，用于生成诊断包
+	Guard       *service.ScannerGuard
+	Reloader    *config.Reloader
+	Goals       *service.GoalService
+	KeyTiming   *service.KeyTimingMonitor
+	Replay      *service.ReplayService
+	Distinct    *service.DistinctService
+	Ingest      *service.IngestService
+	Setup       *service.SetupService  // 首次运行向导，已完成配置时为nil
+	Sync        *service.SyncService   // 中心同步，未启用时为nil
+	Vacuum      *service.VacuumService // 数据库空闲维护，未启用时为nil
+	Acks        *service.AckService
+	Presets     *service.ScannerPresetService
+	Agent       *agent.Server              // 键盘钩子代理，未启用时为nil
+	Serial      *serial.Sources            // 串口扫码枪，未启用时为nil
+	Network     *network.Source            // 网络扫码枪，未启用时为nil
+	Standby     *service.StandbyService    // 热备，未启用时为nil
+	Flags       *service.FeatureFlags      // 功能开关
+	Preferences *service.PreferenceService // 看板偏好设置，未启用时为nil
+	Closing     *service.ClosingService    // 日结，未启用时为nil
+	Simulator   *service.SimulatorService  // 模拟扫码，未启用时为nil
+	Metrics     *service.MetricsRegistry   // 监控指标登记表，为nil时创建；/metrics 与指标快照共用
+	ScanMetrics *service.ScanMetrics
+	History     *service.MetricsHistoryService // 监控指标快照，未启用时为nil
+	Quality     *service.QualityService        // 数据质量报告，未启用时为nil
+	Recovery    *service.RecoveryService       // 启动恢复，未启用时为nil
+	Backfills   *service.BackfillService       // 后台回填，只读模式下为nil
+	Location    *time.Location                 // 显示及统计使用的时区，为nil表示系统时区
+	HookState   func() string                  // 键盘钩子状态：running、stopped、not_configured，为nil表示未配置
+	HookStats   func() scanner.HookStats
+	HookMetrics func() scanner.HookMetrics    // 按键采集来源的按键、缓冲及扫码计数
+	Sources     func() []scanner.SourceStatus // 各采集来源的状态，为nil表示没有采集来源
+	ScanQueue   *scanner.Queue                // 采集来源的扫码缓冲，仅API模式下为nil
+	Timeouts    *scanner.AdaptiveTimeouts     // 各设备的按键超时，没有本机按键采集来源时为nil
+
+	// ScannerConfig 按键采集来源当前使用的扫码参数，UpdateScanner 运行中修改按键超时、长度范围及终止符
+	ScannerConfig func() config.ScannerConfig
+	UpdateScanner func(update scanner.SettingsUpdate, actor string) (config.ScannerConfig, error)
+}
+
 // Router 路由管理器
 type Router struct {
-	engine  *gin.Engine
-	logger  *logrus.Logger
-	hub     *websocket.Hub
-	handler *handlers.BarcodeHandler
+	engine      *gin.Engine
+	config      *config.Config
+	logger      *logrus.Logger
+	hub         *websocket.Hub
+	handler     *handlers.BarcodeHandler
+	db          *database.DB
+	maintenance *service.MaintenanceService
+	capture     *service.CaptureService
+	ingestion   *service.IngestLimiter
+	integrity   *service.IntegrityService
+	coalescer   *service.ScanCoalescer
+	operators   *service.OperatorStatsService
+	barcodes    *service.BarcodeService
+	statsCache  *service.StatsCache
+	configCache *service.ConfigCache
+	fileSink    *sink.FileSink
+	outbox      *service.OutboxService
+	sequence    *service.SequenceService
+	tokens      *service.TokenService
+	devices     *service.DeviceService
+	health      *service.HealthService
+	images      *service.ImageService
+	forwarder   *peer.Forwarder
+	idle        *service.IdleService
+	reset       *service.ResetService
+	rules       *service.RuleService
+	profiles    *service.ProfileService
+	replication *service.ReplicationService
+	configs     *service.ConfigService
+	summary     func() string
+	commands    *service.CommandService
+	heartbeats  *service.HeartbeatService
+	counters    *service.CounterService
+	logs        *support.LogBuffer
+	guard       *service.ScannerGuard
+	timeouts    *scanner.AdaptiveTimeouts
+	reloader    *config.Reloader
+	goals       *service.GoalService
+	keyTiming   *service.KeyTimingMonitor
+	replay      *service.ReplayService
+	distinct    *service.DistinctService
+	ingest      *service.IngestService
+	setup       *service.SetupService
+	sync        *service.SyncService
+	vacuum      *service.VacuumService
+	acks        *service.AckService
+	presets     *service.ScannerPresetService
+	agent       *agent.Server
+	serial      *serial.Sources
+	network     *network.Source
+	standby     *service.StandbyService
+	flags       *service.FeatureFlags
+	preferences *service.PreferenceService
+	closing     *service.ClosingService
+	simulator   *service.SimulatorService
+	metrics     *service.MetricsRegistry
+	scanMetrics *service.ScanMetrics
+	history     *service.MetricsHistoryService
+	quality     *service.QualityService
+	recovery    *service.RecoveryService
+	backfills   *service.BackfillService
+	hookState   func() string
+	hookStats   func() scanner.HookStats
+	hookMetrics func() scanner.HookMetrics
+	scanQueue   *scanner.Queue
+	sources     func() []scanner.SourceStatus
+
+	scannerConfig func() config.ScannerConfig
+	updateScanner func(update scanner.SettingsUpdate, actor string) (config.ScannerConfig, error)
+
+	statusFields []string       // 状态页输出的字段
+	location     *time.Location // 显示及统计使用的时区
 }
 
 // New 创建新的路由管理器
-func New(logger *logrus.Logger, hub *websocket.Hub, handler *handlers.BarcodeHandler) *Router {
+func New(deps Dependencies) *Router {
 	// 设置Gin为发布模式
 	gin.SetMode(gin.ReleaseMode)
 
-	return &Router{
-		engine:  gin.New(),
-		logger:  logger,
-		hub:     hub,
-		handler: handler,
+	location := deps.Location
+	if location == nil {
+		location = time.Local
+	}
+
+	metrics := deps.Metrics
+	if metrics == nil {
+		metrics = service.NewMetricsRegistry()
 	}
+
+	r := &Router{
+		engine:      gin.New(),
+		config:      deps.Config,
+		logger:      deps.Logger,
+		hub:         deps.Hub,
+		handler:     deps.Handler,
+		db:          deps.DB,
+		maintenance: deps.Maintenance,
+		capture:     deps.Capture,
+		ingestion:   deps.Ingestion,
+		integrity:   deps.Integrity,
+		coalescer:   deps.Coalescer,
+		operators:   deps.Operators,
+		barcodes:    deps.Barcodes,
+		statsCache:  deps.StatsCache,
+		configCache: deps.ConfigCache,
+		fileSink:    deps.FileSink,
+		outbox:      deps.Outbox,
+		sequence:    deps.Sequence,
+		tokens:      deps.Tokens,
+		devices:     deps.Devices,
+		health:      deps.Health,
+		images:      deps.Images,
+		forwarder:   deps.Forwarder,
+		idle:        deps.Idle,
+		reset:       deps.Reset,
+		rules:       deps.Rules,
+		profiles:    deps.Profiles,
+		replication: deps.Replication,
+		configs:     deps.Configs,
+		summary:     deps.Summary,
+		commands:    deps.Commands,
+		heartbeats:  deps.Heartbeats,
+		counters:    deps.Counters,
+		logs:        deps.Logs,
+		guard:       deps.Guard,
+		timeouts:    deps.Timeouts,
+		reloader:    deps.Reloader,
+		goals:       deps.Goals,
+		keyTiming:   deps.KeyTiming,
+		replay:      deps.Replay,
+		distinct:    deps.Distinct,
+		ingest:      deps.Ingest,
+		setup:       deps.Setup,
+		sync:        deps.Sync,
+		vacuum:      deps.Vacuum,
+		acks:        deps.Acks,
+		presets:     deps.Presets,
+		agent:       deps.Agent,
+		serial:      deps.Serial,
+		network:     deps.Network,
+		standby:     deps.Standby,
+		flags:       deps.Flags,
+		preferences: deps.Preferences,
+		closing:     deps.Closing,
+		simulator:   deps.Simulator,
+		metrics:     metrics,
+		scanMetrics: deps.ScanMetrics,
+		history:     deps.History,
+		quality:     deps.Quality,
+		recovery:    deps.Recovery,
+		backfills:   deps.Backfills,
+		hookState:   deps.HookState,
+		hookStats:   deps.HookStats,
+		hookMetrics: deps.HookMetrics,
+		scanQueue:   deps.ScanQueue,
+		sources:     deps.Sources,
+		location:    location,
+
+		scannerConfig: deps.ScannerConfig,
+		updateScanner: deps.UpdateScanner,
+	}
+	r.registerMetrics()
+	return r
 }
 
 // Setup 设置路由
@@ -38,6 +275,9 @@ func (r *Router) Setup() *gin.Engine {
 	// 添加中间件
 	r.engine.Use(r.loggerMiddleware())
 	r.engine.Use(gin.Recovery())
+	r.engine.Use(r.localeMiddleware)
+	r.engine.Use(r.setupLockdown)
+	r.engine.Use(r.readOnlyGuard)
 
 	// 设置路由
 	r.setupRoutes()
@@ -50,29 +290,237 @@ func (r *Router) setupRoutes() {
 	// 根路径 - 提供测试页面
 	r.engine.GET("/", r.serveTestPage)
 
+	// 首次运行向导页面
+	r.engine.GET("/setup", r.setupEnabled, r.serveSetupPage)
+
+	// 分类规则测试页面
+	r.engine.GET("/rules-tester", r.serveRulesTesterPage)
+
 	// WebSocket端点
-	r.engine.GET("/ws", r.handleWebSocket)
+	r.engine.GET("/ws", r.authMiddleware(models.TokenScopeWSSubscribe), r.handleWebSocket)
+
+	// 就绪检查
+	r.engine.GET("/readyz", r.readinessCheck)
+
+	// Prometheus监控指标
+	r.engine.GET("/metrics", r.authMiddleware(models.TokenScopeRead), r.getMetrics)
+
+	// 公开状态页（无需认证，按IP限流）
+	if r.config.StatusPage.Enable {
+		r.statusFields = r.registeredStatusFields()
+		limiter := newIPRateLimiter(r.config.StatusPage.RateLimit, time.Minute)
+		r.engine.GET("/statuspage", limiter.middleware(), r.getStatusPage)
+		r.engine.GET("/statuspage.html", limiter.middleware(), r.getStatusPageHTML)
+	}
 
 	// API路由组 - 简单的API，不需要版本控制
 	api := r.engine.Group("/api")
 	{
-		// 健康检查
+		// 健康检查（无需认证，须在认证中间件之前注册）
 		api.GET("/health", r.healthCheck)
 
+		// 相邻工位转发的事件（使用 peer:forward 令牌）
+		api.POST("/peers/events", r.authMiddleware(models.TokenScopePeerForward), r.receivePeerEvent)
+
+		// 外部系统推送的扫码结果（使用 ingest 令牌）
+		api.POST("/ingest", r.authMiddleware(models.TokenScopeIngest), r.ingestEnabled, r.ingestScans)
+
+		// 键盘钩子代理（使用 agent.token 共享令牌，仅允许本机连接）
+		api.GET("/agent/connect", r.agentEnabled, r.agentAuth, r.handleAgent)
+
+		// 看板偏好设置（读取使用 read 令牌，保存使用 preferences 令牌）
+		preferences := api.Group("/preferences", r.preferencesEnabled)
+		preferences.GET("/:client_key", r.authMiddleware(models.TokenScopeRead), r.getPreference)
+		preferences.PUT("/:client_key", r.authMiddleware(models.TokenScopePreferences), r.putPreference)
+
+		// 首次运行向导（尚无管理员密钥，仅允许本机访问）
+		setup := api.Group("/setup", r.setupEnabled)
+		setup.GET("", r.getSetup)
+		setup.PUT("/station", r.setSetupStation)
+		setup.PUT("/admin", r.setSetupAdmin)
+		setup.PUT("/database", r.setSetupDatabase)
+		setup.PUT("/device", r.setSetupDevice)
+		setup.PUT("/central", r.setSetupCentral)
+		setup.POST("/complete", r.completeSetup)
+
+		api.Use(r.authMiddleware(models.TokenScopeRead))
+
 		// 系统状态
 		api.GET("/status", r.getStatus)
+		api.GET("/status/summary", r.getStatusSummary)
+		api.GET("/scanner/status", r.getScannerStatus)
+		api.GET("/scanner/stats", r.getScannerStats)
+		api.GET("/scanner/presets", r.getScannerPresets)
+		api.GET("/scanner/config", r.getScannerConfig)
+		api.PUT("/scanner/config", r.requireAdmin(), r.putScannerConfig)
+		api.GET("/scanner/capture", r.getCapture)
+		api.POST("/scanner/pause", r.pauseCapture)
+		api.POST("/scanner/resume", r.resumeCapture)
+
+		// 工位心跳（区分停机与空闲）
+		api.GET("/heartbeats", r.getHeartbeats)
+
+		// 持久化计数器（清零及轮转需管理员权限）
+		counters := api.Group("/counters", r.countersEnabled)
+		counters.GET("", r.getCounters)
+		counters.POST("/reset", r.requireAdmin(), r.resetCounters)
+		counters.POST("/rollover", r.requireAdmin(), r.rolloverCounters)
+		api.GET("/metrics/history", r.historyEnabled, r.getMetricsHistory)
+
+		// 数据质量报告
+		api.GET("/quality/report", r.qualityEnabled, r.getQualityReport)   // 按时间范围及设备计算数据质量
+		api.GET("/quality/reports", r.qualityEnabled, r.getQualityReports) // 定时保存的报告
 
 		// 条码相关API
-		api.GET("/barcodes", r.getBarcodes)      // 获取扫码记录
-		api.DELETE("/barcodes", r.clearBarcodes) // 清空扫码记录
+		api.GET("/barcodes", r.getBarcodes)                    // 获取扫码记录
+		api.DELETE("/barcodes", r.clearBarcodes)               // 清空扫码记录
+		api.POST("/barcodes/submit", r.submitBarcode)          // 提交扫码结果及图片
+		api.POST("/barcodes/ack", r.ackBarcodes)               // 下游系统确认已消费的记录
+		api.GET("/barcodes/:id", r.getBarcode)                 // 获取单条扫码记录
+		api.DELETE("/barcodes/:id", r.deleteBarcode)           // 删除单条扫码记录（已日结的生产日需管理员强制）
+		api.POST("/barcodes/:id/image", r.uploadBarcodeImage)  // 上传条码图片
+		api.GET("/barcodes/:id/image", r.getBarcodeImage)      // 获取条码图片
+		api.GET("/barcodes/:id/annotations", r.getAnnotations) // 获取批注
+		api.POST("/barcodes/:id/annotations", r.addAnnotation) // 追加批注，不修改记录本身
 
 		// 统计信息
 		api.GET("/stats", r.getStats)
+		api.GET("/stats/operators", r.getOperatorStats) // 按操作员的效率，受隐私设置约束
+		api.GET("/consumers", r.getConsumerLag)         // 下游消费方积压
+
+		// 日结汇总
+		api.GET("/daily-summaries", r.closingEnabled, r.getDailySummaries)
+		api.GET("/daily-summaries/:day", r.closingEnabled, r.getDailySummary)
+
+		// 模拟扫码（加载场景及启停需管理员权限）
+		simulator := api.Group("/simulator", r.simulatorEnabled)
+		simulator.GET("/status", r.getSimulatorStatus)
+		simulator.GET("/scenario", r.getSimulatorScenario)
+		simulator.POST("/scenario", r.requireAdmin(), r.uploadSimulatorScenario)
+		simulator.POST("/start", r.requireAdmin(), r.startSimulator)
+		simulator.POST("/stop", r.requireAdmin(), r.stopSimulator)
+
+		// 分类规则（修改需管理员权限）
+		api.GET("/rules", r.getRules)
+		api.POST("/rules/evaluate", r.evaluateRules)
+		api.POST("/rules", r.requireAdmin(), r.createRule)
+		api.PUT("/rules/:id", r.requireAdmin(), r.updateRule)
+		api.DELETE("/rules/:id", r.requireAdmin(), r.deleteRule)
+
+		// 系统配置（IsSystem 配置项不可删除，修改需管理员权限）
+		api.GET("/configs", r.getConfigs)
+		api.PUT("/configs/:key", r.setConfig)
+		api.DELETE("/configs/:key", r.deleteConfig)
+		api.POST("/configs/:key/restore", r.restoreConfig)
+
+		// WebSocket客户端列表（含心跳往返时延）
+		api.GET("/websocket/clients", r.getWebSocketClients)
+		api.POST("/websocket/clients/:id/close", r.requireAdmin(), r.closeWebSocketClient)
+
+		// 事件目录
+		api.GET("/events/catalog", r.getEventCatalog)
+
+		// 出站事件及外部推送的结构定义
+		api.GET("/schemas/", r.getSchemas)
+		api.GET("/schemas/:name", r.getSchema)
+
+		// 维护模式
+		api.GET("/maintenance", r.getMaintenance)
+		api.POST("/maintenance/start", r.startMaintenance)
+		api.POST("/maintenance/end", r.endMaintenance)
+
+		// 设备及健康评分
+		api.GET("/devices", r.getDevices)
+		api.GET("/devices/:id/health", r.getDeviceHealth)
+		api.PUT("/devices/:id/profile", r.requireAdmin(), r.assignDeviceProfile)
+		api.PUT("/devices/:id/preset", r.requireAdmin(), r.setDevicePreset)
+		api.PUT("/devices/:id/station", r.requireAdmin(), r.setDeviceStation)
+		api.PUT("/devices/:id/path", r.requireAdmin(), r.setDevicePath)
+		api.PUT("/devices/:id/timeout", r.requireAdmin(), r.setDeviceTimeout)
+		api.POST("/devices/:id/timeout/reset", r.requireAdmin(), r.resetDeviceTimeout)
+		api.POST("/devices/:id/restore", r.requireAdmin(), r.restoreDevice)
+		api.PUT("/devices/:id/commands", r.requireAdmin(), r.setDeviceCommands)
+		api.POST("/devices/:id/command", r.requireAdmin(), r.sendDeviceCommand)
+		api.POST("/devices/commands", r.requireAdmin(), r.sendBulkCommand)
+
+		// 扫码枪配置档案（修改需管理员权限）
+		api.GET("/profiles", r.getProfiles)
+		api.GET("/profiles/:id", r.getProfile)
+		api.GET("/profiles/:id/sheet", r.getProfileSheet)
+		api.POST("/profiles", r.requireAdmin(), r.createProfile)
+		api.PUT("/profiles/:id", r.requireAdmin(), r.updateProfile)
+		api.DELETE("/profiles/:id", r.requireAdmin(), r.deleteProfile)
+
+		// 产量目标（修改需管理员权限）
+		goals := api.Group("/goals", r.goalsEnabled)
+		goals.GET("", r.getGoals)
+		goals.GET("/stats", r.getGoalStats)
+		goals.GET("/:id", r.getGoal)
+		goals.POST("", r.requireAdmin(), r.createGoal)
+		goals.PUT("/:id", r.requireAdmin(), r.updateGoal)
+		goals.DELETE("/:id", r.requireAdmin(), r.deleteGoal)
+
+		// 序列号断号
+		api.GET("/gaps", r.getGaps)
+		api.POST("/gaps/reset", r.resetSequence)
+
+		// 受限令牌管理（仅管理员）
+		tokens := api.Group("/tokens", r.requireAdmin())
+		tokens.POST("", r.createToken)
+		tokens.GET("", r.getTokens)
+		tokens.DELETE("/:id", r.revokeToken)
+
+		// 演示及培训环境数据重置（仅管理员）
+		admin := api.Group("/admin", r.requireAdmin())
+		admin.GET("/reset/token", r.getResetToken)
+		admin.POST("/reset", r.resetData)
+		admin.GET("/support-bundle", r.getSupportBundle)
+		admin.GET("/config/state", r.getConfigState)
+		admin.POST("/verify-integrity", r.integrityEnabled, r.verifyIntegrity)
+
+		// 功能开关（仅管理员）
+		admin.GET("/flags", r.getFlags)
+		admin.PUT("/flags/:name", r.setFlag)
+		admin.DELETE("/flags/:name", r.resetFlag)
+
+		// 历史扫码记录回放（仅管理员）
+		admin.POST("/replay", r.createReplay)
+		admin.GET("/replay", r.getReplays)
+		admin.GET("/replay/:id", r.getReplay)
+		admin.POST("/replay/:id/cancel", r.cancelReplay)
+		admin.POST("/replay/:id/resume", r.resumeReplay)
+
+		// 迁移新增列的后台回填（仅管理员）
+		admin.GET("/backfills", r.backfillsEnabled, r.getBackfills)
+		admin.POST("/backfills/:name/pause", r.backfillsEnabled, r.pauseBackfill)
+		admin.POST("/backfills/:name/resume", r.backfillsEnabled, r.resumeBackfill)
+
+		// 不同条码数精确重算，用于核对估算值（仅管理员）
+		admin.GET("/stats/distinct/recount", r.recountDistinct)
+
+		// 中心同步（仅管理员）
+		admin.POST("/sync", r.syncEnabled, r.runSync)
+		admin.GET("/sync/conflicts", r.syncEnabled, r.getSyncConflicts)
+		admin.POST("/sync/conflicts/:id/resolve", r.syncEnabled, r.resolveSyncConflict)
+
+		// 日结及重新开放（仅管理员）
+		admin.POST("/close-day", r.closingEnabled, r.closeDay)
+		admin.POST("/reopen-day", r.closingEnabled, r.reopenDay)
+
+		// 看板偏好设置管理（仅管理员）
+		admin.GET("/preferences", r.preferencesEnabled, r.getPreferences)
+		admin.DELETE("/preferences/:client_key", r.preferencesEnabled, r.deletePreference)
 	}
 }
 
 // serveTestPage 提供测试页面
 func (r *Router) serveTestPage(c *gin.Context) {
+	// 尚未完成首次运行配置时进入向导
+	if r.setup != nil && !r.config.App.Provisioned {
+		c.Redirect(http.StatusFound, "/setup")
+		return
+	}
+
 	// 获取工作目录
 	wd, err := os.Getwd()
 	if err != nil {
@@ -101,7 +549,72 @@ func (r *Router) serveTestPage(c *gin.Context) {
 
 // handleWebSocket 处理WebSocket连接
 func (r *Router) handleWebSocket(c *gin.Context) {
-	r.hub.HandleWebSocket(c.Writer, c.Request)
+	// 客户端订阅的设备过滤，与令牌的设备范围取交集
+	var devices map[uint]bool
+	if value := c.Query("devices"); value != "" {
+		devices = make(map[uint]bool)
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "devices 参数无效"})
+				return
+			}
+			devices[uint(id)] = true
+		}
+	}
+
+	if p := getPrincipal(c); p != nil && p.Devices != nil {
+		if devices == nil {
+			devices = p.Devices
+		} else {
+			for id := range devices {
+				if !p.Devices[id] {
+					delete(devices, id)
+				}
+			}
+			if len(devices) == 0 {
+				r.hub.Reject(c.Writer, c.Request, websocket.ClosePolicyViolation, "令牌无权订阅所请求的设备")
+				return
+			}
+		}
+	}
+
+	// 客户端协商的载荷格式
+	format, err := payload.ParseFormat(c.Query("timestamp_format"), c.Query("field_naming"), c.Query("flatten") == "true" || c.Query("flatten") == "1")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "载荷格式参数无效", "message": err.Error()})
+		return
+	}
+
+	// 断线重连时从该序号之后续传
+	var since *uint64
+	if value := c.Query("since"); value != "" {
+		seq, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since 参数无效"})
+			return
+		}
+		since = &seq
+	}
+
+	// 客户端可接收的单条消息大小，超出时服务端精简后发送
+	var maxMessageSize int
+	if value := c.Query("max_message_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < websocket.MinMessageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_message_size 参数无效", "message": "须为不小于 " + strconv.Itoa(websocket.MinMessageSize) + " 的字节数"})
+			return
+		}
+		maxMessageSize = size
+	}
+
+	r.hub.HandleWebSocket(c.Writer, c.Request, websocket.ClientOptions{
+		Devices:        devices,
+		Format:         format,
+		Since:          since,
+		Compact:        c.Query("compact") == "true" || c.Query("compact") == "1",
+		MaxMessageSize: maxMessageSize,
+	})
 }
 
 // healthCheck 健康检查
@@ -119,33 +632,367 @@ func (r *Router) healthCheck(c *gin.Context) {
 	})
 }
 
+// readinessCheck 就绪检查，维护模式视为降级而非失败
+func (r *Router) readinessCheck(c *gin.Context) {
+	if err := r.db.Health(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "not_ready",
+			"reasons": []string{"database: " + err.Error()},
+		})
+		return
+	}
+
+	// 仅API模式下不检查键盘钩子
+	var reasons []string
+	if r.maintenance.IsActive() {
+		reasons = append(reasons, "maintenance")
+	}
+	if r.capture.IsPaused() {
+		reasons = append(reasons, "capture: paused")
+	}
+	if r.integrity != nil {
+		if report := r.integrity.LastReport(); report != nil && !report.Valid {
+			reasons = append(reasons, "integrity: broken")
+		}
+	}
+	if r.scannerHookState() == "stopped" {
+		reasons = append(reasons, "hook: stopped")
+	}
+	if r.scannerStatus() == "agent_disconnected" {
+		reasons = append(reasons, "agent: disconnected")
+	}
+	if len(reasons) > 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  "degraded",
+			"reasons": reasons,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
 // getStatus 获取系统状态
 func (r *Router) getStatus(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusOK, r.statusSnapshot())
+}
+
+// statusSnapshot 系统状态快照，供状态接口及诊断包使用
+func (r *Router) statusSnapshot() gin.H {
+	scannerStatus := r.scannerStatus()
+	scannerState := gin.H{
+		"status":  scannerStatus,
+		"hook":    r.scannerHookState(),
+		"capture": r.capture.GetState(),
+	}
+	// 看门狗重新安装键盘钩子的次数，持续增长说明按键回调处理过慢
+	if r.hookStats != nil && r.scannerHookState() != "not_configured" {
+		stats := r.hookStats()
+		scannerState["reinstalls"] = stats.Reinstalls
+		scannerState["last_reinstall_at"] = stats.LastReinstallAt
+	}
+	// 按键采集来源的按键、缓冲及扫码计数，可据此判断按键是否到达以及缓冲为何被丢弃
+	if r.hookMetrics != nil && r.scannerHookState() != "not_configured" {
+		scannerState["metrics"] = r.hookMetrics()
+	}
+
+	return gin.H{
 		"websocket": gin.H{
 			"connected_clients": r.hub.GetClientCount(),
 			"status":            "running",
 		},
-		"scanner": gin.H{
-			"status": "listening",
-		},
+		"scanner": scannerState,
 		"server": gin.H{
-			"status": "running",
+			"status":     "running",
+			"port":       r.config.Server.Port,
+			"timezone":   r.location.String(),
+			"utc_offset": utcOffset(r.location),
 		},
+		"maintenance":  r.maintenance.GetState(),
+		"stats_cache":  r.statsCache.GetMetrics(),
+		"config_cache": r.getConfigCacheStatus(),
+		"sinks":        r.getSinkStatus(),
+		"disk":         r.getDiskStatus(),
+		"peers":        r.getPeerStatus(),
+		"idle":         r.getIdleStatus(),
+		"replication":  r.getReplicationStatus(),
+		"setup":        r.getSetupStatus(),
+		"sync":         r.getSyncStatus(),
+		"vacuum":       r.getVacuumStatus(),
+		"read_only":    r.config.App.ReadOnly,
+		"standby":      r.getStandbyStatus(),
+		"flags":        r.getFlagStatus(),
+		"recovery":     r.getRecoveryStatus(),
+		"ingestion":    r.getIngestionStatus(),
+		"integrity":    r.getIntegrityStatus(),
+		"coalesce":     r.getCoalesceStatus(),
+		"backfills":    r.getBackfillStatus(),
+	}
+}
+
+// getIngestionStatus 获取扫码接入限流状态，包括各来源是否正在被限流及拒绝的扫码数
+func (r *Router) getIngestionStatus() interface{} {
+	if r.ingestion == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.ingestion.Status()
+}
+
+// getCoalesceStatus 获取跨设备合并的窗口及已合并的扫码数
+func (r *Router) getCoalesceStatus() interface{} {
+	if r.coalescer == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.coalescer.Status()
+}
+
+// getConfigCacheStatus 获取系统配置缓存的命中率及旧值读取次数
+func (r *Router) getConfigCacheStatus() interface{} {
+	if r.configCache == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.configCache.GetMetrics()
+}
+
+// getRecoveryStatus 获取本次启动的恢复结果，unclean 为 true 表示上次运行非正常退出
+func (r *Router) getRecoveryStatus() interface{} {
+	if r.recovery == nil {
+		return gin.H{"enabled": false}
+	}
+	if summary := r.recovery.Summary(); summary != nil {
+		return summary
+	}
+	return gin.H{"enabled": true}
+}
+
+// getStandbyStatus 获取热备角色（primary、standby、taking_over）及主机健康、采集租约
+func (r *Router) getStandbyStatus() interface{} {
+	if r.standby == nil {
+		return gin.H{"enabled": false, "role": service.StandbyStatePrimary}
+	}
+	return r.standby.Status()
+}
+
+// getSetupStatus 获取首次运行配置状态
+func (r *Router) getSetupStatus() interface{} {
+	if r.setup == nil {
+		return gin.H{"provisioned": r.config.App.Provisioned}
+	}
+	return r.setup.Status()
+}
+
+// getStatusSummary 以纯文本输出运行状态摘要，内容与启动日志一致
+func (r *Router) getStatusSummary(c *gin.Context) {
+	if r.summary == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "运行摘要不可用"})
+		return
+	}
+	c.String(http.StatusOK, r.summary())
+}
+
+// getWebSocketClients 获取WebSocket客户端列表
+func (r *Router) getWebSocketClients(c *gin.Context) {
+	clients := r.hub.GetClients()
+	c.JSON(http.StatusOK, gin.H{
+		"data":  clients,
+		"total": len(clients),
 	})
 }
 
-// getBarcodes 获取扫码记录
+// closeWebSocketClient 以指定关闭码断开WebSocket客户端，默认 CloseKicked
+func (r *Router) closeWebSocketClient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的客户端ID"})
+		return
+	}
+
+	var req struct {
+		Code   int    `json:"code"`
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误", "message": err.Error()})
+			return
+		}
+	}
+	if req.Code == 0 {
+		req.Code = websocket.CloseKicked
+	}
+	if req.Code < 1000 || req.Code > 4999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "关闭码无效"})
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "被管理员断开"
+	}
+
+	if !r.hub.CloseClient(id, req.Code, req.Reason) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "客户端不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "客户端已断开"})
+}
+
+// getEventCatalog 获取事件目录
+func (r *Router) getEventCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, r.hub.Catalog().Document())
+}
+
+// getSinkStatus 获取事件输出状态
+func (r *Router) getSinkStatus() gin.H {
+	fileStatus := sink.FileSinkStatus{}
+	if r.fileSink != nil {
+		fileStatus = r.fileSink.GetStatus()
+	}
+	outboxMetrics := service.OutboxMetrics{}
+	if r.outbox != nil {
+		outboxMetrics = r.outbox.GetMetrics()
+	}
+	return gin.H{
+		"file":   fileStatus,
+		"outbox": outboxMetrics,
+	}
+}
+
+// getPeerStatus 获取相邻工位转发状态
+func (r *Router) getPeerStatus() gin.H {
+	if r.forwarder == nil {
+		return gin.H{"enabled": false}
+	}
+	return gin.H{
+		"enabled": true,
+		"station": r.forwarder.Station(),
+		"targets": r.forwarder.GetStatus(),
+	}
+}
+
+// getIdleStatus 获取距上次扫码时长
+func (r *Router) getIdleStatus() interface{} {
+	if r.idle == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.idle.GetStatus()
+}
+
+// getVacuumStatus 获取数据库空闲维护状态
+func (r *Router) getVacuumStatus() interface{} {
+	if r.vacuum == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.vacuum.GetStatus()
+}
+
+// getReplicationStatus 获取本地备份库复制状态
+func (r *Router) getReplicationStatus() interface{} {
+	if r.replication == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.replication.GetStatus()
+}
+
+// getDiskStatus 获取磁盘占用状态
+func (r *Router) getDiskStatus() gin.H {
+	imageStatus := service.ImageStoreStatus{}
+	if r.images != nil {
+		imageStatus = r.images.GetStatus()
+	}
+	return gin.H{
+		"images": imageStatus,
+	}
+}
+
+// getBarcodes 获取扫码记录，支持 device_id、type、unconsumed_by 及 derived.<字段>=<值> 过滤
 func (r *Router) getBarcodes(c *gin.Context) {
-	// 这里应该从数据库或缓存中获取扫码记录
-	// 目前返回示例数据
+	page, err := binding.BindPagination(c)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	loc, ok := r.requestLocation(c)
+	if !ok {
+		return
+	}
+	period, err := binding.BindOptionalDateRange(c, loc)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+	sort, err := binding.BindSort(c, binding.Sort{Field: "created_at", Desc: true}, barcodeSortFields...)
+	if err != nil {
+		binding.Respond(c, err)
+		return
+	}
+
+	filter := service.BarcodeFilter{
+		Type:         c.Query("type"),
+		UnconsumedBy: c.Query("unconsumed_by"),
+		From:         period.From,
+		To:           period.To,
+		Order:        sort.Clause(),
+	}
+	if filter.UnconsumedBy != "" && !r.acks.IsRegistered(filter.UnconsumedBy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "消费方未登记", "message": filter.UnconsumedBy})
+		return
+	}
+	if value := c.Query("device_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "device_id 无效"})
+			return
+		}
+		if !canAccessDevice(c, uint(id)) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "令牌无权访问该设备"})
+			return
+		}
+		filter.DeviceIDs = []uint{uint(id)}
+	} else if p := getPrincipal(c); p != nil && p.Devices != nil {
+		// 受限令牌仅能查看允许的设备
+		for id := range p.Devices {
+			filter.DeviceIDs = append(filter.DeviceIDs, id)
+		}
+	}
+
+	for key, values := range c.Request.URL.Query() {
+		field, ok := strings.CutPrefix(key, "derived.")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if !barcode.ValidFieldName(field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "派生字段名无效", "message": field})
+			return
+		}
+		if filter.Derived == nil {
+			filter.Derived = make(map[string]string)
+		}
+		filter.Derived[field] = values[0]
+	}
+
+	records, total, err := r.barcodes.GetBarcodeRecords(page.Page, page.PageSize, filter)
+	if err != nil {
+		r.logger.WithError(err).Error("查询扫码记录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询扫码记录失败"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data":    []gin.H{},
-		"total":   0,
-		"message": "暂无扫码记录",
+		"data":      records,
+		"total":     total,
+		"page":      page.Page,
+		"page_size": page.PageSize,
 	})
 }
 
+// getBarcode 获取单条扫码记录，推送的消息被截断时由此获取完整数据
+func (r *Router) getBarcode(c *gin.Context) {
+	record, ok := r.loadRecord(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": record})
+}
+
 // clearBarcodes 清空扫码记录
 func (r *Router) clearBarcodes(c *gin.Context) {
 	// 这里应该清空数据库中的扫码记录
@@ -157,17 +1004,105 @@ func (r *Router) clearBarcodes(c *gin.Context) {
 
 // getStats 获取统计信息
 func (r *Router) getStats(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"total_scans":       0,
-		"connected_clients": r.hub.GetClientCount(),
-		"uptime":            "0s",
-		"last_scan":         nil,
+	key := c.Request.URL.Query().Encode()
+	loc, ok := r.requestLocation(c)
+	if !ok {
+		return
+	}
+
+	// 数据未变化时返回304，先于统计计算判断
+	etag, lastModified := r.statsCache.Validator(key)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if statsNotModified(c, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	stats, err := r.statsCache.Get(key, func() (interface{}, error) {
+		stats, err := r.barcodes.GetBarcodeStats(loc)
+		if err != nil || r.distinct == nil {
+			return stats, err
+		}
+		// 今日不同条码数为近似值，按工位时区的自然日统计，不受 tz 参数影响
+		stats["distinct_today"], err = r.distinct.Estimates("")
+		return stats, err
 	})
+	if err != nil {
+		r.logger.WithError(err).Error("获取统计信息失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取统计信息失败"})
+		return
+	}
+
+	// Last-Modified 只有秒级精度，向上取整到秒且该秒已过去时才输出；
+	// 否则同一秒内稍后的扫码与之无法区分，客户端只能使用 ETag
+	if modified := ceilSecond(lastModified); !time.Now().Before(modified) {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// statsNotModified 条件请求的数据是否未变化：有 If-None-Match 时只比较 ETag，否则比较 If-Modified-Since
+func statsNotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	return err == nil && !lastModified.After(since)
+}
+
+// ceilSecond 向上取整到秒
+func ceilSecond(t time.Time) time.Time {
+	if truncated := t.Truncate(time.Second); truncated.Before(t) {
+		return truncated.Add(time.Second)
+	}
+	return t
+}
+
+// requestLocation 按天/小时统计使用的时区，tz 参数可覆盖配置的显示时区，无效时已写入响应
+func (r *Router) requestLocation(c *gin.Context) (*time.Location, bool) {
+	name := c.Query("tz")
+	if name == "" {
+		return r.location, true
+	}
+	loc, err := config.LoadLocation(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "时区无效", "message": err.Error()})
+		return nil, false
+	}
+	return loc, true
+}
+
+// utcOffset 时区当前相对UTC的偏移秒数
+func utcOffset(loc *time.Location) int {
+	_, offset := time.Now().In(loc).Zone()
+	return offset
+}
+
+// barcodeSortFields 扫码记录列表可排序的字段
+var barcodeSortFields = []string{"created_at", "id", "content", "type", "device_id"}
+
+// quietPaths 不记录请求日志的路径
+var quietPaths = map[string]bool{
+	"/statuspage":      true,
+	"/statuspage.html": true,
 }
 
 // loggerMiddleware 日志中间件
 func (r *Router) loggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 状态页由监控系统频繁轮询，不记录请求日志
+		if quietPaths[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
 		// 记录请求信息
 		r.logger.WithFields(logrus.Fields{
 			"method": c.Request.Method,
@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// ruleRequest 创建或更新分类规则请求
+type ruleRequest struct {
+	Name     string            `json:"name" binding:"required"`
+	Pattern  string            `json:"pattern" binding:"required"`
+	Type     string            `json:"type"`
+	Fields   map[string]string `json:"fields"` // 捕获组名 -> 派生字段名
+	Priority int               `json:"priority"`
+	Enabled  *bool             `json:"enabled"` // 默认启用
+}
+
+// toModel 转换为规则模型
+func (req *ruleRequest) toModel() *models.ClassificationRule {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return &models.ClassificationRule{
+		Name:     req.Name,
+		Pattern:  req.Pattern,
+		Type:     req.Type,
+		Fields:   req.Fields,
+		Priority: req.Priority,
+		Enabled:  enabled,
+	}
+}
+
+// evaluateRulesRequest 规则测试请求
+type evaluateRulesRequest struct {
+	Content string        `json:"content" binding:"required"`
+	Rules   []ruleRequest `json:"rules" binding:"omitempty,dive"` // 草稿规则，省略时使用已保存的规则
+}
+
+// getRules 获取分类规则列表
+func (r *Router) getRules(c *gin.Context) {
+	rules, err := r.rules.GetRules()
+	if err != nil {
+		r.logger.WithError(err).Error("查询分类规则失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询分类规则失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// createRule 创建分类规则，正则无效时返回编译错误
+func (r *Router) createRule(c *gin.Context) {
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	rule := req.toModel()
+	if err := r.rules.CreateRule(rule); err != nil {
+		r.respondRuleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "分类规则已创建", "data": rule})
+}
+
+// updateRule 更新分类规则
+func (r *Router) updateRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID无效"})
+		return
+	}
+
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	rule, err := r.rules.UpdateRule(uint(id), req.toModel())
+	if err != nil {
+		r.respondRuleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "分类规则已更新", "data": rule})
+}
+
+// deleteRule 删除分类规则
+func (r *Router) deleteRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "规则ID无效"})
+		return
+	}
+
+	if err := r.rules.DeleteRule(uint(id)); err != nil {
+		r.respondRuleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "分类规则已删除"})
+}
+
+// evaluateRules 按当前的校验及分类逻辑判定条码，返回完整的判定过程，不创建扫码记录
+func (r *Router) evaluateRules(c *gin.Context) {
+	var req evaluateRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	var drafts []*models.ClassificationRule
+	if req.Rules != nil {
+		drafts = make([]*models.ClassificationRule, 0, len(req.Rules))
+		for i := range req.Rules {
+			drafts = append(drafts, req.Rules[i].toModel())
+		}
+	}
+	eval, err := r.rules.Evaluate(req.Content, drafts)
+	if err != nil {
+		r.logger.WithError(err).Error("判定条码失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "判定条码失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": eval})
+}
+
+// serveRulesTesterPage 提供分类规则测试页面
+func (r *Router) serveRulesTesterPage(c *gin.Context) {
+	wd, err := os.Getwd()
+	if err != nil {
+		r.logger.WithError(err).Error("获取工作目录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+
+	htmlPath := filepath.Join(wd, "web", "rules-tester.html")
+	if _, err := os.Stat(htmlPath); os.IsNotExist(err) {
+		r.logger.WithField("path", htmlPath).Error("规则测试页面文件不存在")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "规则测试页面文件不存在",
+			"message": "请确保 web/rules-tester.html 文件存在",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.File(htmlPath)
+}
+
+// respondRuleError 根据错误类型返回响应
+func (r *Router) respondRuleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "分类规则无效", "message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "分类规则不存在"})
+	default:
+		r.logger.WithError(err).Error("保存分类规则失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分类规则失败", "message": err.Error()})
+	}
+}
@@ -0,0 +1,210 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/scanner"
+	"userclient/internal/service"
+)
+
+// scannerHookState 键盘钩子状态，仅API模式下为 not_configured
+func (r *Router) scannerHookState() string {
+	if r.hookState == nil {
+		return "not_configured"
+	}
+	return r.hookState()
+}
+
+// scannerStatus 扫码状态：listening、maintenance，仅API模式且没有串口及网络扫码枪时为 not_configured，
+// 等待键盘钩子代理连接时为 agent_disconnected，热备待命时为 standby
+func (r *Router) scannerStatus() string {
+	switch {
+	case r.scannerHookState() == "not_configured" && r.agent == nil && r.serial == nil && r.network == nil:
+		return "not_configured"
+	case r.scannerHookState() == "not_configured" && r.agent != nil && !r.agent.Connected():
+		return "agent_disconnected"
+	case r.standby != nil && !r.standby.Capturing():
+		return "standby"
+	case r.maintenance.IsActive():
+		return "maintenance"
+	default:
+		return "listening"
+	}
+}
+
+// getScannerStatus 获取扫码状态、各采集来源的状态、扫码缓冲、键盘钩子计数（如忽略的注入按键数）、键盘钩子代理、串口及网络扫码枪的连接状态、
+// 各设备生效的按键超时（含自动调整的样本数）、当前生效的扫码参数及超出安全范围的提示
+func (r *Router) getScannerStatus(c *gin.Context) {
+	resp := gin.H{"status": r.scannerStatus(), "hook": r.scannerHookState(), "capture": r.capture.GetState()}
+	if r.hookStats != nil && r.scannerHookState() != "not_configured" {
+		resp["hook_stats"] = r.hookStats()
+	}
+	if r.sources != nil {
+		resp["sources"] = r.sources()
+	}
+	if r.scanQueue != nil {
+		resp["buffer"] = r.scanQueue.Stats()
+	}
+	if r.agent != nil {
+		resp["agent"] = r.agent.Status()
+	}
+	if r.serial != nil {
+		resp["serial"] = r.serial.Status()
+	}
+	if r.network != nil {
+		resp["network"] = r.network.Status()
+	}
+	if r.timeouts != nil {
+		resp["timeouts"] = r.timeouts.Status(time.Duration(r.scannerConfig().TimeoutMS) * time.Millisecond)
+	}
+	if r.guard != nil {
+		resp["settings"] = r.guard.Settings()
+		resp["warnings"] = r.guard.Evaluate()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// getScannerStats 获取键盘钩子按键时序诊断统计
+func (r *Router) getScannerStats(c *gin.Context) {
+	if r.keyTiming == nil || r.scannerHookState() == "not_configured" {
+		c.JSON(http.StatusOK, gin.H{"status": "not_configured", "data": service.KeyTimingStats{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": r.scannerHookState(), "data": r.keyTiming.Stats()})
+}
+
+// scannerConfigRequest 运行中修改扫码参数的请求，省略的项保持不变
+type scannerConfigRequest struct {
+	scanner.SettingsUpdate
+	Force bool `json:"force"` // 确认使用超出安全范围的按键超时或长度范围
+}
+
+// scannerConfigView 运行中可修改的扫码参数
+func scannerConfigView(cfg config.ScannerConfig) gin.H {
+	return gin.H{
+		"timeout_ms":  cfg.TimeoutMS,
+		"min_length":  cfg.MinLength,
+		"max_length":  cfg.MaxLength,
+		"terminators": cfg.Terminators,
+	}
+}
+
+// scannerTunable 是否有可在运行中修改扫码参数的按键采集来源（本机键盘钩子或键盘钩子代理）
+func (r *Router) scannerTunable() bool {
+	return r.updateScanner != nil && (r.scannerHookState() != "not_configured" || r.agent != nil)
+}
+
+// getScannerConfig 获取按键采集来源当前使用的按键超时、长度范围及终止符
+func (r *Router) getScannerConfig(c *gin.Context) {
+	if !r.scannerTunable() {
+		c.JSON(http.StatusOK, gin.H{"status": "not_configured"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": r.scannerHookState(), "data": scannerConfigView(r.scannerConfig())})
+}
+
+// putScannerConfig 运行中修改按键超时、长度范围及终止符，下一个按键起生效，不重新安装键盘钩子
+//
+// 参数无效时返回400，按键采集来源保持原参数；超出安全范围时需以 force=true 确认。修改只在本次运行中有效，
+// 需要保留时同时修改配置文件（热加载时同样立即生效）或在配置管理中修改 scanner.timeout_ms 等配置项。
+func (r *Router) putScannerConfig(c *gin.Context) {
+	if !r.scannerTunable() {
+		c.JSON(http.StatusConflict, gin.H{"error": "没有按键采集来源", "message": "仅API模式下没有可修改的扫码参数"})
+		return
+	}
+	var req scannerConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	next := r.scannerConfig()
+	req.SettingsUpdate.Apply(&next)
+	if err := scanner.ValidateSettings(next); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "扫码参数无效", "message": err.Error()})
+		return
+	}
+	if !req.Force {
+		for _, setting := range []struct {
+			key   string
+			value *int
+		}{
+			{"scanner.timeout_ms", req.TimeoutMS},
+			{"scanner.min_length", req.MinLength},
+			{"scanner.max_length", req.MaxLength},
+		} {
+			if setting.value == nil {
+				continue
+			}
+			if warning := service.CheckScannerSetting(setting.key, strconv.Itoa(*setting.value)); warning != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "配置值超出安全范围", "message": warning.Message + "，确认无误后请以 force=true 重新提交"})
+				return
+			}
+		}
+	}
+
+	applied, err := r.updateScanner(req.SettingsUpdate, configActor(c).Name)
+	if errors.Is(err, scanner.ErrInvalidSettings) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "扫码参数无效", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("修改扫码参数失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改扫码参数失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "扫码参数已生效，重启后恢复为配置文件中的值", "data": scannerConfigView(applied)})
+}
+
+// getScannerPresets 获取扫码枪参数预设及其参数值，active 为启动时应用到键盘钩子的预设
+func (r *Router) getScannerPresets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": r.presets.List(), "active": r.presets.Active()})
+}
+
+// setDevicePresetRequest 设置设备扫码枪预设请求
+type setDevicePresetRequest struct {
+	Preset string `json:"preset"` // 为空表示不使用预设
+}
+
+// setDevicePreset 为设备指定扫码枪参数预设，重启后对活跃设备的键盘钩子生效
+func (r *Router) setDevicePreset(c *gin.Context) {
+	id, ok := r.parseDeviceID(c)
+	if !ok {
+		return
+	}
+
+	var req setDevicePresetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	if req.Preset != "" {
+		if _, err := r.presets.Get(req.Preset); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "扫码枪预设不存在", "message": err.Error()})
+			return
+		}
+	}
+
+	if err := r.devices.UpdateDevice(id, map[string]interface{}{"preset": req.Preset}); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "设备不存在"})
+			return
+		}
+		r.logger.WithError(err).Error("更新设备扫码枪预设失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新设备扫码枪预设失败", "message": err.Error()})
+		return
+	}
+	device, err := r.devices.GetDevice(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取设备失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备扫码枪预设已更新，重启后生效", "data": device})
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/contract"
+)
+
+// getSchemas 结构定义列表
+func (r *Router) getSchemas(c *gin.Context) {
+	names := contract.Names()
+	schemas := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		schemas = append(schemas, gin.H{"name": name, "url": contract.BasePath + name})
+	}
+	c.JSON(http.StatusOK, gin.H{"version": contract.Version, "schemas": schemas})
+}
+
+// getSchema 获取结构定义，名称可带 .json 后缀
+func (r *Router) getSchema(c *gin.Context) {
+	doc, err := contract.Document(strings.TrimSuffix(c.Param("name"), ".json"))
+	if errors.Is(err, contract.ErrUnknownSchema) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "结构定义不存在"})
+		return
+	}
+	if err != nil {
+		r.logger.WithError(err).Error("加载结构定义失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加载结构定义失败"})
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
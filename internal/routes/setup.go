@@ -0,0 +1,193 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/service"
+)
+
+// setupLockdown 未完成首次运行配置时所有接口仅允许本机访问
+//
+// 按连接的对端地址判断，不信任 X-Forwarded-For 等请求头。
+func (r *Router) setupLockdown(c *gin.Context) {
+	if r.config.App.Provisioned {
+		c.Next()
+		return
+	}
+	if !isLocalRequest(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "尚未完成首次运行配置，仅允许本机访问", "message": "请在本机打开 /setup 完成配置"})
+		return
+	}
+	c.Next()
+}
+
+// isLocalRequest 请求是否来自本机，按连接的对端地址判断
+func isLocalRequest(c *gin.Context) bool {
+	ip := net.ParseIP(remoteIP(c))
+	return ip != nil && ip.IsLoopback()
+}
+
+// remoteIP 连接的对端地址（不含端口），不信任 X-Forwarded-For 等可由客户端伪造的请求头
+func remoteIP(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return c.Request.RemoteAddr
+	}
+	return host
+}
+
+// setupEnabled 已完成首次运行配置时返回404
+func (r *Router) setupEnabled(c *gin.Context) {
+	if r.setup == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "首次运行配置已完成"})
+		return
+	}
+	c.Next()
+}
+
+// getSetup 获取首次运行向导状态
+func (r *Router) getSetup(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": r.setup.Status()})
+}
+
+// setSetupStation 设置工位名称及时区
+func (r *Router) setSetupStation(c *gin.Context) {
+	var req service.SetupStation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	if err := r.setup.SetStation(req); err != nil {
+		r.respondSetupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "工位信息已保存", "data": r.setup.Status()})
+}
+
+// setSetupAdmin 设置管理员密钥，未提供时生成随机密钥并只在本次响应中返回
+func (r *Router) setSetupAdmin(c *gin.Context) {
+	var req struct {
+		APIKey string `json:"api_key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	apiKey, err := r.setup.SetAdmin(req.APIKey)
+	if err != nil {
+		r.respondSetupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "管理员密钥已保存，请妥善保管", "api_key": apiKey, "data": r.setup.Status()})
+}
+
+// setSetupDatabase 测试并保存数据库连接
+func (r *Router) setSetupDatabase(c *gin.Context) {
+	var req struct {
+		Type string `json:"type"`
+		DSN  string `json:"dsn"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	result, err := r.setup.SetDatabase(req.Type, req.DSN)
+	if err != nil {
+		r.respondSetupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "数据库连接测试成功", "test": result, "data": r.setup.Status()})
+}
+
+// setSetupDevice 设置首个设备
+func (r *Router) setSetupDevice(c *gin.Context) {
+	var req service.SetupDevice
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	if err := r.setup.SetDevice(req); err != nil {
+		r.respondSetupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设备信息已保存", "data": r.setup.Status()})
+}
+
+// setSetupCentral 测试并保存中心服务器，skip 为 true 时作为独立工位运行
+func (r *Router) setSetupCentral(c *gin.Context) {
+	var req struct {
+		URL   string `json:"url"`
+		Token string `json:"token"`
+		Skip  bool   `json:"skip"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+	if err := r.setup.SetCentral(req.URL, req.Token, req.Skip); err != nil {
+		r.respondSetupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "中心服务器设置已保存", "data": r.setup.Status()})
+}
+
+// completeSetup 完成首次运行配置，写入配置文件
+func (r *Router) completeSetup(c *gin.Context) {
+	result, err := r.setup.Complete()
+	if err != nil {
+		r.respondSetupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "首次运行配置已完成，请重启服务使配置生效", "data": result})
+}
+
+// respondSetupError 根据错误类型返回向导步骤失败的响应
+func (r *Router) respondSetupError(c *gin.Context, err error) {
+	var deleted *service.DeletedConflictError
+	switch {
+	case errors.As(err, &deleted):
+		c.JSON(http.StatusConflict, deletedConflictResponse(deleted,
+			fmt.Sprintf("POST /api/devices/%d/restore 恢复该设备后重新提交", deleted.ID)))
+	case errors.Is(err, service.ErrSetupCompleted):
+		c.JSON(http.StatusConflict, gin.H{"error": "首次运行配置已完成", "message": err.Error()})
+	case errors.Is(err, service.ErrSetupOrder):
+		c.JSON(http.StatusConflict, gin.H{"error": "请按顺序完成配置步骤", "message": err.Error(), "data": r.setup.Status()})
+	case errors.Is(err, service.ErrInvalidSetup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "配置无效", "message": err.Error()})
+	case errors.Is(err, service.ErrSetupConnection):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "连接测试失败", "message": err.Error()})
+	default:
+		r.logger.WithError(err).Error("首次运行配置失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "首次运行配置失败", "message": err.Error()})
+	}
+}
+
+// serveSetupPage 提供首次运行向导页面
+func (r *Router) serveSetupPage(c *gin.Context) {
+	wd, err := os.Getwd()
+	if err != nil {
+		r.logger.WithError(err).Error("获取工作目录失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+
+	htmlPath := filepath.Join(wd, "web", "setup.html")
+	if _, err := os.Stat(htmlPath); os.IsNotExist(err) {
+		r.logger.WithField("path", htmlPath).Error("向导页面文件不存在")
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "向导页面文件不存在",
+			"message": "请确保 web/setup.html 文件存在",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.File(htmlPath)
+}
@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/service"
+)
+
+// maxScenarioBodySize 上传场景的最大字节数
+const maxScenarioBodySize = 1 << 20
+
+// startSimulatorRequest 开始模拟扫码请求，省略的项使用配置
+type startSimulatorRequest struct {
+	Loop  *bool    `json:"loop"`
+	Speed *float64 `json:"speed"`
+}
+
+// simulatorEnabled 未启用模拟扫码时返回404
+func (r *Router) simulatorEnabled(c *gin.Context) {
+	if r.simulator == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "模拟扫码未启用"})
+		return
+	}
+	c.Next()
+}
+
+// getSimulatorStatus 模拟扫码的运行状态
+func (r *Router) getSimulatorStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": r.simulator.Status()})
+}
+
+// getSimulatorScenario 已加载的场景
+func (r *Router) getSimulatorScenario(c *gin.Context) {
+	scenario := r.simulator.Scenario()
+	if scenario == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "尚未加载场景"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": scenario})
+}
+
+// uploadSimulatorScenario 上传场景（YAML或JSON），替换已加载的场景，name 参数为场景名称
+func (r *Router) uploadSimulatorScenario(c *gin.Context) {
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxScenarioBodySize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "请求体过大", "message": err.Error()})
+		return
+	}
+
+	scenario, err := r.simulator.Load(c.DefaultQuery("name", "upload"), data)
+	if err != nil {
+		r.respondSimulatorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "场景已加载", "data": scenario})
+}
+
+// startSimulator 从头开始播放已加载的场景
+func (r *Router) startSimulator(c *gin.Context) {
+	var req startSimulatorRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+			return
+		}
+	}
+
+	status, err := r.simulator.Run(service.SimulationOptions{Loop: req.Loop, Speed: req.Speed})
+	if err != nil {
+		r.respondSimulatorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "模拟扫码已开始", "data": status})
+}
+
+// stopSimulator 停止播放
+func (r *Router) stopSimulator(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"message": "模拟扫码已停止", "data": r.simulator.Stop()})
+}
+
+// respondSimulatorError 根据错误类型返回响应，场景解析错误附带行号
+func (r *Router) respondSimulatorError(c *gin.Context, err error) {
+	var scenarioErr *service.ScenarioError
+	switch {
+	case errors.As(err, &scenarioErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "模拟场景无效", "message": err.Error(), "line": scenarioErr.Line})
+	case errors.Is(err, service.ErrInvalidScenario):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "模拟场景无效", "message": err.Error()})
+	case errors.Is(err, service.ErrSimulatorState):
+		c.JSON(http.StatusConflict, gin.H{"error": "模拟扫码状态不允许该操作", "message": err.Error()})
+	default:
+		r.logger.WithError(err).Error("处理模拟扫码失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理模拟扫码失败", "message": err.Error()})
+	}
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func conditionalContext(header, value string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	if header != "" {
+		c.Request.Header.Set(header, value)
+	}
+	return c
+}
+
+func TestStatsNotModifiedETag(t *testing.T) {
+	etag := `"3-17f0-0badf00d"`
+	cases := []struct {
+		match string
+		want  bool
+	}{
+		{etag, true},
+		{"W/" + etag, true},
+		{`"other", ` + etag, true},
+		{"*", true},
+		{`"2-17f0-0badf00d"`, false},
+	}
+	for _, tc := range cases {
+		if got := statsNotModified(conditionalContext("If-None-Match", tc.match), etag, time.Now()); got != tc.want {
+			t.Errorf("If-None-Match %s: got %v, want %v", tc.match, got, tc.want)
+		}
+	}
+
+	// If-None-Match 优先，ETag 不同时忽略 If-Modified-Since
+	c := conditionalContext("If-None-Match", `"stale"`)
+	c.Request.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if statsNotModified(c, etag, time.Now()) {
+		t.Error("If-Modified-Since overrode a mismatching If-None-Match")
+	}
+}
+
+func TestStatsNotModifiedSameSecondScan(t *testing.T) {
+	// 客户端收到的 Last-Modified 为上次修改时间向上取整到秒
+	first := time.Date(2026, 3, 1, 8, 0, 0, 200*int(time.Millisecond), time.UTC)
+	since := ceilSecond(first).Format(http.TimeFormat)
+
+	if !statsNotModified(conditionalContext("If-Modified-Since", since), "", first) {
+		t.Error("unchanged data not reported as not modified")
+	}
+	// 响应在该秒结束后才会带 Last-Modified，之后的扫码一定晚于它
+	later := ceilSecond(first).Add(300 * time.Millisecond)
+	if statsNotModified(conditionalContext("If-Modified-Since", since), "", later) {
+		t.Error("scan after the response was reported as not modified")
+	}
+}
+
+func TestCeilSecond(t *testing.T) {
+	whole := time.Date(2026, 3, 1, 8, 0, 5, 0, time.UTC)
+	if got := ceilSecond(whole); !got.Equal(whole) {
+		t.Errorf("ceilSecond(%v) = %v", whole, got)
+	}
+	if got := ceilSecond(whole.Add(time.Nanosecond)); !got.Equal(whole.Add(time.Second)) {
+		t.Errorf("ceilSecond rounds down: %v", got)
+	}
+}
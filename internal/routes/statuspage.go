@@ -0,0 +1,215 @@
+package routes
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"userclient/internal/i18n"
+)
+
+// statusPageFields 状态页可选输出的字段
+//
+// 状态页无需认证，字段只能是布尔值或版本号，不得包含扫码内容、设备名称或计数，
+// 新增字段前须确认不会泄露这些信息。
+var statusPageFields = map[string]bool{
+	"capturing":   true, // 键盘钩子运行中且不在维护模式
+	"recent_scan": true, // recent_scan_window 内有扫码
+	"maintenance": true, // 是否处于维护模式
+	"version":     true, // 程序版本
+}
+
+// statusPage 公开状态页内容
+type statusPage struct {
+	Up          bool   `json:"up"`
+	Capturing   *bool  `json:"capturing,omitempty"`
+	RecentScan  *bool  `json:"recent_scan,omitempty"`
+	Maintenance *bool  `json:"maintenance,omitempty"`
+	Version     string `json:"version,omitempty"`
+}
+
+// statusPageTemplate 车间大屏使用的状态页，每30秒刷新，文字按请求语言输出
+var statusPageTemplate = template.Must(template.New("statuspage").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="30">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; background: #111; color: #eee; margin: 0; padding: 4vh 4vw; }
+h1 { font-size: 5vh; margin: 0 0 4vh; }
+.item { display: flex; justify-content: space-between; font-size: 4vh; padding: 2vh 0; border-bottom: 1px solid #333; }
+.ok { color: #4caf50; } .bad { color: #f44336; } .muted { color: #999; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Items}}<div class="item"><span>{{.Label}}</span><span class="{{.Class}}">{{.Text}}</span></div>
+{{end}}</body>
+</html>
+`))
+
+// buildStatusPage 按配置的字段生成状态页内容
+func (r *Router) buildStatusPage() statusPage {
+	cfg := r.config.StatusPage
+	page := statusPage{Up: r.db.Health() == nil}
+
+	for _, field := range r.statusFields {
+		switch field {
+		case "capturing":
+			hooked := r.scannerHookState() == "running" || (r.agent != nil && r.agent.Connected())
+			capturing := hooked && !r.maintenance.IsActive()
+			page.Capturing = &capturing
+		case "recent_scan":
+			recent := false
+			if page.Up {
+				if at, err := r.barcodes.GetLastScanTime(); err == nil && at != nil {
+					recent = time.Since(*at) <= cfg.RecentScanWindow
+				}
+			}
+			page.RecentScan = &recent
+		case "maintenance":
+			maintenance := r.maintenance.IsActive()
+			page.Maintenance = &maintenance
+		case "version":
+			page.Version = r.config.App.Version
+		}
+	}
+	return page
+}
+
+// getStatusPage 公开状态页（无需认证），服务异常时返回503便于监控系统判断
+func (r *Router) getStatusPage(c *gin.Context) {
+	page := r.buildStatusPage()
+	status := http.StatusOK
+	if !page.Up {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, page)
+}
+
+// statusPageItem 状态页HTML中的一行
+type statusPageItem struct {
+	Label string
+	Text  string
+	Class string // ok, bad, muted
+}
+
+// statusPageView 状态页HTML的内容
+type statusPageView struct {
+	Lang  string
+	Title string
+	Items []statusPageItem
+}
+
+// items 转换为HTML中逐行显示的内容，文字按 catalog 的语言输出
+func (p statusPage) items(catalog *i18n.Catalog) []statusPageItem {
+	// flag 按消息代码 code（标签）、code_yes、code_no 输出一行
+	flag := func(code string, value, good bool) statusPageItem {
+		item := statusPageItem{Label: catalog.T(code), Text: catalog.T(code + "_no"), Class: "bad"}
+		if value {
+			item.Text = catalog.T(code + "_yes")
+		}
+		if value == good {
+			item.Class = "ok"
+		}
+		return item
+	}
+
+	items := []statusPageItem{flag("status.service", p.Up, true)}
+	if p.Capturing != nil {
+		items = append(items, flag("status.capturing", *p.Capturing, true))
+	}
+	if p.RecentScan != nil {
+		items = append(items, flag("status.recent_scan", *p.RecentScan, true))
+	}
+	if p.Maintenance != nil {
+		items = append(items, flag("status.maintenance", *p.Maintenance, false))
+	}
+	if p.Version != "" {
+		items = append(items, statusPageItem{Label: catalog.T("status.version"), Text: p.Version, Class: "muted"})
+	}
+	return items
+}
+
+// getStatusPageHTML 公开状态页的HTML版本
+func (r *Router) getStatusPageHTML(c *gin.Context) {
+	page := r.buildStatusPage()
+	status := http.StatusOK
+	if !page.Up {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	catalog := i18n.For(localeOf(c))
+	view := statusPageView{Lang: catalog.Locale, Title: catalog.T("status.title"), Items: page.items(catalog)}
+	if err := statusPageTemplate.Execute(c.Writer, view); err != nil {
+		r.logger.WithError(err).Warn("渲染状态页失败")
+	}
+}
+
+// registeredStatusFields 配置的状态页字段中已登记的部分，未登记的字段忽略
+func (r *Router) registeredStatusFields() []string {
+	fields := make([]string, 0, len(r.config.StatusPage.Fields))
+	for _, field := range r.config.StatusPage.Fields {
+		if !statusPageFields[field] {
+			r.logger.WithField("field", field).Warn("状态页字段未登记，已忽略")
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// ipRateLimiter 按客户端IP的固定窗口限流
+//
+// 按连接的对端地址计数，不信任 X-Forwarded-For，更换请求头无法绕过限制。
+type ipRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// newIPRateLimiter 创建限流器，limit为每个窗口内每个IP的请求数
+func newIPRateLimiter(limit int, window time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{
+		limit:  limit,
+		window: window,
+		counts: make(map[string]int),
+	}
+}
+
+// allow 记录一次请求，超出限制时返回false
+func (l *ipRateLimiter) allow(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		l.counts = make(map[string]int)
+	}
+	if l.counts[ip] >= l.limit {
+		return false
+	}
+	l.counts[ip]++
+	return true
+}
+
+// middleware 超出限制时返回429
+func (l *ipRateLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.limit > 0 && !l.allow(remoteIP(c), time.Now()) {
+			c.Header("Retry-After", strconv.Itoa(int(l.window.Seconds())))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIPRateLimiterIgnoresForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	limiter := newIPRateLimiter(3, time.Minute)
+	engine.GET("/statuspage", limiter.middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	statuses := make([]int, 0, 5)
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/statuspage", nil)
+		req.RemoteAddr = "203.0.113.7:40000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		statuses = append(statuses, w.Code)
+	}
+	want := []int{200, 200, 200, 429, 429}
+	if fmt.Sprint(statuses) != fmt.Sprint(want) {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+
+	// 其他对端地址不受影响
+	req := httptest.NewRequest(http.MethodGet, "/statuspage", nil)
+	req.RemoteAddr = "203.0.113.8:40000"
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("other client got %d", w.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"userclient/internal/service"
+)
+
+// syncEnabled 未启用中心同步时返回404
+func (r *Router) syncEnabled(c *gin.Context) {
+	if r.sync == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "中心同步未启用"})
+		return
+	}
+	c.Next()
+}
+
+// runSync 立即从中心服务器拉取并应用同步包
+func (r *Router) runSync(c *gin.Context) {
+	result, err := r.sync.Run()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "中心同步失败", "message": err.Error(), "data": result})
+		return
+	}
+	message := "已应用同步包"
+	if result.Skipped {
+		message = "同步包版本未变化"
+	} else if len(result.Errors) > 0 {
+		message = "部分条目应用失败，下次同步时重试"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "data": result, "status": r.sync.GetStatus()})
+}
+
+// getSyncConflicts 获取同步冲突，可按 status 过滤（pending, central, local）
+func (r *Router) getSyncConflicts(c *gin.Context) {
+	conflicts, err := r.sync.GetConflicts(c.Query("status"))
+	if err != nil {
+		r.logger.WithError(err).Error("获取同步冲突失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取同步冲突失败", "message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": conflicts, "total": len(conflicts)})
+}
+
+// resolveSyncConflict 处理同步冲突，use 为 central（采用中心内容）或 local（保留本地内容）
+func (r *Router) resolveSyncConflict(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "同步冲突ID无效"})
+		return
+	}
+	var req struct {
+		Use string `json:"use" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+		return
+	}
+
+	actor := "admin"
+	if p := getPrincipal(c); p != nil && p.Token != nil {
+		actor = p.Token.Name
+	}
+	conflict, err := r.sync.ResolveConflict(uint(id), req.Use, actor)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "同步冲突已处理", "data": conflict})
+	case errors.Is(err, service.ErrInvalidSyncResolution):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数无效", "message": err.Error()})
+	case errors.Is(err, service.ErrSyncConflictResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": "同步冲突已处理", "message": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "同步冲突不存在"})
+	case errors.Is(err, service.ErrInvalidRule), errors.Is(err, service.ErrInvalidConfigValue),
+		errors.Is(err, service.ErrConfigOutOfRange), errors.Is(err, service.ErrConfigProtected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "中心内容未通过校验", "message": err.Error()})
+	default:
+		r.logger.WithError(err).Error("处理同步冲突失败")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理同步冲突失败", "message": err.Error()})
+	}
+}
+
+// getSyncStatus 获取中心同步状态
+func (r *Router) getSyncStatus() interface{} {
+	if r.sync == nil {
+		return gin.H{"enabled": false}
+	}
+	return r.sync.GetStatus()
+}
@@ -0,0 +1,306 @@
+package scanner
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+// 按键超时自动调整的常量
+const (
+	adaptivePercentile   = 0.99                 // 按键超时参照的按键间隔百分位
+	adaptiveRound        = 5 * time.Millisecond // 学到的按键超时按此粒度向上取整
+	adaptiveMinStep      = 5 * time.Millisecond // 新值与当前值相差不足此值（或不足当前值的1/adaptiveBand）时不调整，避免来回抖动
+	adaptiveBand         = 5                    // 见 adaptiveMinStep
+	adaptivePersistEvery = 50                   // 学到的值不变时，每观察这么多次扫码持久化一次样本数
+)
+
+// 设备按键超时的来源
+const (
+	TimeoutSourceDefault  = "default"  // 使用 scanner.timeout_ms
+	TimeoutSourceManual   = "manual"   // 设备手动指定
+	TimeoutSourceAdaptive = "adaptive" // 自动调整学到的值
+	TimeoutSourceLearning = "learning" // 已开启自动调整，样本不足，暂用 scanner.timeout_ms
+)
+
+// DeviceTimeout 设备的按键超时设置及自动调整的学习结果，与设备表的同名列对应
+type DeviceTimeout struct {
+	DeviceID  uint `json:"device_id"`
+	ManualMS  int  `json:"timeout_ms"`         // 手动指定的按键超时，0表示未指定
+	Adaptive  bool `json:"adaptive"`           // 是否自动调整
+	LearnedMS int  `json:"learned_timeout_ms"` // 学到的按键超时，0表示尚未学到
+	Samples   int  `json:"samples"`            // 已观察的有效扫码数
+}
+
+// DeviceTimeoutStatus 设备当前生效的按键超时
+type DeviceTimeoutStatus struct {
+	DeviceTimeout
+	EffectiveMS int    `json:"effective_timeout_ms"`
+	Source      string `json:"source"`           // default、manual、adaptive、learning
+	P99MS       int    `json:"p99_interval_ms"`  // 最近窗口内按键间隔的第99百分位数，本次运行尚无样本时为0
+	Intervals   int    `json:"window_intervals"` // 最近窗口内的按键间隔数
+	Default     bool   `json:"default_device"`   // 是否为活跃设备（未识别出设备的扫码归属该设备）
+}
+
+// AdaptiveTimeouts 各设备的按键超时，供按键采集来源按设备替换扫码参数中的按键超时
+//
+// 手动指定的按键超时始终优先；开启自动调整的设备按有效扫码（已交给条码处理器且未被拒绝）的按键间隔学习，
+// 按键超时取最近窗口内按键间隔第99百分位数的 headroom 倍，限制在 floor_ms 与 ceiling_ms 之间。
+// 间隔超过当前按键超时的按键不会组装成扫码，所以新值明显高于当前值时立即升高；降低则每满一个窗口的间隔
+// 判断一次，期间的最大新值仍明显低于当前值才降低，偶发的慢按键进出窗口不会使学到的值来回变化。
+// 间隔分布稳定后学到的值也就稳定。未识别出设备的扫码（低级键盘钩子）按活跃设备计。
+// 为nil时各方法不做任何事，按键超时使用 scanner.timeout_ms。
+type AdaptiveTimeouts struct {
+	config config.AdaptiveTimeoutConfig
+
+	mu        sync.Mutex
+	devices   map[uint]*timeoutLearner
+	defaultID uint
+	onLearned func(DeviceTimeout)
+}
+
+// timeoutLearner 单个设备的学习状态
+type timeoutLearner struct {
+	DeviceTimeout
+	intervals []time.Duration // 最近的按键间隔，环形缓冲
+	next      int
+
+	// 上次调整后累计的按键间隔数及其间的最大新值，每满一个窗口判断一次是否降低
+	blockIntervals int
+	blockPeak      time.Duration
+
+	// 按基础参数派生的扫码参数，基础参数或生效的按键超时变化时重新生成
+	base    *Settings
+	derived *Settings
+}
+
+// NewAdaptiveTimeouts 创建各设备的按键超时
+func NewAdaptiveTimeouts(cfg config.AdaptiveTimeoutConfig) *AdaptiveTimeouts {
+	if cfg.Window < 10 {
+		cfg.Window = 500
+	}
+	if cfg.MinScans < 1 {
+		cfg.MinScans = 20
+	}
+	if cfg.Headroom < 1 {
+		cfg.Headroom = 2
+	}
+	return &AdaptiveTimeouts{config: cfg, devices: make(map[uint]*timeoutLearner)}
+}
+
+// OnLearned 注册学到新值或样本数需要持久化时的回调，在观察扫码的 goroutine 中调用
+func (t *AdaptiveTimeouts) OnLearned(listener func(DeviceTimeout)) {
+	t.onLearned = listener
+}
+
+// Configure 按设备表重新设置各设备，未列出的设备恢复为 scanner.timeout_ms；defaultID 为活跃设备，0表示没有
+//
+// 本次运行中已观察的样本及学到的值保留，设备表中的学习结果只用于新出现的设备（如启动时）。
+func (t *AdaptiveTimeouts) Configure(devices []DeviceTimeout, defaultID uint) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	next := make(map[uint]*timeoutLearner, len(devices))
+	for _, device := range devices {
+		learner, ok := t.devices[device.DeviceID]
+		if !ok {
+			learner = &timeoutLearner{DeviceTimeout: device}
+		}
+		learner.ManualMS, learner.Adaptive = device.ManualMS, device.Adaptive
+		learner.derived = nil
+		next[device.DeviceID] = learner
+	}
+	t.devices = next
+	t.defaultID = defaultID
+}
+
+// Reset 清除设备的学习结果及本次运行观察的样本，更换扫码枪后使用
+func (t *AdaptiveTimeouts) Reset(deviceID uint) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if learner, ok := t.devices[deviceID]; ok {
+		learner.LearnedMS, learner.Samples = 0, 0
+		learner.intervals, learner.next = nil, 0
+		learner.derived = nil
+	}
+}
+
+// Settings 设备使用的扫码参数：按键超时替换为该设备生效的值，其余参数与 base 相同；deviceID 为0表示活跃设备
+func (t *AdaptiveTimeouts) Settings(base *Settings, deviceID uint) *Settings {
+	if t == nil {
+		return base
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	learner := t.learner(deviceID)
+	if learner == nil {
+		return base
+	}
+	timeout, _ := learner.effective(base.Timeout, t.config.MinScans)
+	if timeout == base.Timeout {
+		return base
+	}
+	if learner.base != base || learner.derived == nil || learner.derived.Timeout != timeout {
+		derived := *base
+		derived.Timeout = timeout
+		learner.base, learner.derived = base, &derived
+	}
+	return learner.derived
+}
+
+// Observe 记录设备一次有效扫码的按键间隔，deviceID 为0表示活跃设备；未开启自动调整的设备忽略
+func (t *AdaptiveTimeouts) Observe(deviceID uint, timings []service.KeyTiming) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	learner := t.learner(deviceID)
+	if learner == nil || !learner.Adaptive {
+		t.mu.Unlock()
+		return
+	}
+	changed := learner.observe(timings, t.config)
+	persist := changed || learner.Samples%adaptivePersistEvery == 0
+	snapshot := learner.DeviceTimeout
+	listener := t.onLearned
+	t.mu.Unlock()
+
+	if persist && listener != nil {
+		listener(snapshot)
+	}
+}
+
+// Status 各设备当前生效的按键超时，base 为 scanner.timeout_ms
+func (t *AdaptiveTimeouts) Status(base time.Duration) []DeviceTimeoutStatus {
+	if t == nil {
+		return []DeviceTimeoutStatus{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]DeviceTimeoutStatus, 0, len(t.devices))
+	for _, learner := range t.devices {
+		timeout, source := learner.effective(base, t.config.MinScans)
+		result = append(result, DeviceTimeoutStatus{
+			DeviceTimeout: learner.DeviceTimeout,
+			EffectiveMS:   int(timeout / time.Millisecond),
+			Source:        source,
+			P99MS:         int(learner.percentile(adaptivePercentile) / time.Millisecond),
+			Intervals:     len(learner.intervals),
+			Default:       learner.DeviceID == t.defaultID,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].DeviceID < result[j].DeviceID })
+	return result
+}
+
+// learner 按设备ID取学习状态，0表示活跃设备；须持有 mu
+func (t *AdaptiveTimeouts) learner(deviceID uint) *timeoutLearner {
+	if deviceID == 0 {
+		deviceID = t.defaultID
+	}
+	return t.devices[deviceID]
+}
+
+// effective 生效的按键超时及其来源
+func (l *timeoutLearner) effective(base time.Duration, minScans int) (time.Duration, string) {
+	switch {
+	case l.ManualMS > 0:
+		return time.Duration(l.ManualMS) * time.Millisecond, TimeoutSourceManual
+	case l.Adaptive && l.LearnedMS > 0 && l.Samples >= minScans:
+		return time.Duration(l.LearnedMS) * time.Millisecond, TimeoutSourceAdaptive
+	case l.Adaptive:
+		return base, TimeoutSourceLearning
+	}
+	return base, TimeoutSourceDefault
+}
+
+// observe 记录一次扫码的按键间隔并重新计算，学到的值变化时返回 true
+func (l *timeoutLearner) observe(timings []service.KeyTiming, cfg config.AdaptiveTimeoutConfig) bool {
+	added := 0
+	for i := 1; i < len(timings); i++ {
+		if timings[i].Tick == 0 || timings[i-1].Tick == 0 {
+			continue
+		}
+		// 按键时间为毫秒计数的低32位，回绕后差值仍正确
+		interval := time.Duration(timings[i].Tick-timings[i-1].Tick) * time.Millisecond
+		if len(l.intervals) < cfg.Window {
+			l.intervals = append(l.intervals, interval)
+		} else {
+			l.intervals[l.next] = interval
+			l.next = (l.next + 1) % cfg.Window
+		}
+		added++
+	}
+	if added == 0 {
+		return false
+	}
+	l.Samples++
+	if l.Samples < cfg.MinScans {
+		return false
+	}
+
+	candidate := time.Duration(float64(l.percentile(adaptivePercentile)) * cfg.Headroom)
+	candidate = (candidate + adaptiveRound - 1) / adaptiveRound * adaptiveRound
+	if floor := time.Duration(cfg.FloorMS) * time.Millisecond; candidate < floor {
+		candidate = floor
+	}
+	if ceiling := time.Duration(cfg.CeilingMS) * time.Millisecond; ceiling > 0 && candidate > ceiling {
+		candidate = ceiling
+	}
+
+	current := time.Duration(l.LearnedMS) * time.Millisecond
+	if current > 0 {
+		step := current / adaptiveBand
+		if step < adaptiveMinStep {
+			step = adaptiveMinStep
+		}
+		if candidate < current+step {
+			// 降低须等整个窗口的间隔都更新一遍，取期间的最大新值，仍明显低于当前值才降低
+			l.blockIntervals += added
+			if candidate > l.blockPeak {
+				l.blockPeak = candidate
+			}
+			if l.blockIntervals < cfg.Window {
+				return false
+			}
+			candidate = l.blockPeak
+			l.blockIntervals, l.blockPeak = 0, 0
+			if candidate > current-step {
+				return false
+			}
+		}
+	}
+	l.blockIntervals, l.blockPeak = 0, 0
+	l.LearnedMS = int(candidate / time.Millisecond)
+	l.derived = nil
+	return true
+}
+
+// percentile 最近窗口内按键间隔的百分位数，没有样本时为0
+func (l *timeoutLearner) percentile(p float64) time.Duration {
+	if len(l.intervals) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), l.intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
+
+// AdaptiveSource 可按设备调整按键超时的按键采集来源（键盘钩子、rawinput、evdev）
+type AdaptiveSource interface {
+	// SetTimeouts 设置各设备的按键超时，须在 Install 前调用
+	SetTimeouts(timeouts *AdaptiveTimeouts)
+}
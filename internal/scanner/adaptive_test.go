@@ -0,0 +1,253 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+// loadTrace 读取 testdata/traces 下录制的按键时间，每行一次扫码，# 开头的行为说明
+func loadTrace(t *testing.T, name string) [][]service.KeyTiming {
+	t.Helper()
+	file, err := os.Open(filepath.Join("testdata", "traces", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var scans [][]service.KeyTiming
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var timings []service.KeyTiming
+		for _, field := range strings.Fields(line) {
+			tick, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			timings = append(timings, service.KeyTiming{Tick: uint32(tick)})
+		}
+		scans = append(scans, timings)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) == 0 {
+		t.Fatalf("%s: empty trace", name)
+	}
+	return scans
+}
+
+// maxInterval 一次扫码中最长的按键间隔
+func maxInterval(timings []service.KeyTiming) time.Duration {
+	var longest time.Duration
+	for i := 1; i < len(timings); i++ {
+		if gap := time.Duration(timings[i].Tick-timings[i-1].Tick) * time.Millisecond; gap > longest {
+			longest = gap
+		}
+	}
+	return longest
+}
+
+// replay 按录制顺序循环回放 scans 次扫码，返回每次扫码后设备生效的按键超时
+//
+// 与按键采集来源一样，按键间隔超过当前生效按键超时的扫码会被拆散、不是有效扫码，不计入学习。
+func replay(timeouts *AdaptiveTimeouts, base *Settings, deviceID uint, trace [][]service.KeyTiming, scans int) []time.Duration {
+	effective := make([]time.Duration, 0, scans)
+	for i := 0; i < scans; i++ {
+		timings := trace[i%len(trace)]
+		if maxInterval(timings) <= timeouts.Settings(base, deviceID).Timeout {
+			timeouts.Observe(deviceID, timings)
+		}
+		effective = append(effective, timeouts.Settings(base, deviceID).Timeout)
+	}
+	return effective
+}
+
+// changes 按键超时变化的次数及最后一次变化的位置
+func changes(effective []time.Duration) (count, last int) {
+	for i := 1; i < len(effective); i++ {
+		if effective[i] != effective[i-1] {
+			count, last = count+1, i
+		}
+	}
+	return count, last
+}
+
+// withinBand 两个收敛值相差不超过较大值的1/adaptiveBand：回放路径不同时，相差在此范围内的值都是稳定点
+func withinBand(a, b time.Duration) bool {
+	if a < b {
+		a, b = b, a
+	}
+	step := a / adaptiveBand
+	if step < adaptiveMinStep {
+		step = adaptiveMinStep
+	}
+	return a-b <= step
+}
+
+// testAdaptive 使用默认学习参数（配置文件中的值）的按键超时，deviceID 1 开启自动调整
+func testAdaptive(devices ...DeviceTimeout) (*AdaptiveTimeouts, *Settings) {
+	timeouts := NewAdaptiveTimeouts(config.AdaptiveTimeoutConfig{FloorMS: 30, CeilingMS: 500, MinScans: 20, Window: 500, Headroom: 2})
+	if len(devices) == 0 {
+		devices = []DeviceTimeout{{DeviceID: 1, Adaptive: true}}
+	}
+	timeouts.Configure(devices, 1)
+	base := NewSettings(&config.ScannerConfig{TimeoutMS: 100, MinLength: 3, MaxLength: 64, MaxBufferFactor: 2, Terminators: []string{"enter"}})
+	return timeouts, base
+}
+
+// TestAdaptiveTimeoutConvergence 回放录制的按键时间：学到的值在样本足够后收敛并保持不变，
+// 落在上下限之内，录制的扫码几乎都不会因按键超时被拆散
+func TestAdaptiveTimeoutConvergence(t *testing.T) {
+	tests := []struct {
+		trace    string
+		min, max time.Duration // 收敛值的合理范围
+	}{
+		// 按键间隔不超过10毫秒，两倍余量仍低于下限
+		{trace: "usb-wired.txt", min: 30 * time.Millisecond, max: 30 * time.Millisecond},
+		// 偶发的长间隔为55-75毫秒
+		{trace: "bluetooth.txt", min: 110 * time.Millisecond, max: 150 * time.Millisecond},
+		// 部分按键间隔超过 scanner.timeout_ms（100毫秒），先升高再收敛
+		{trace: "bluetooth-slow.txt", min: 220 * time.Millisecond, max: 320 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.trace, func(t *testing.T) {
+			trace := loadTrace(t, tt.trace)
+			timeouts, base := testAdaptive()
+			var persisted []DeviceTimeout
+			timeouts.OnLearned(func(learned DeviceTimeout) { persisted = append(persisted, learned) })
+
+			const scans = 3000
+			effective := replay(timeouts, base, 1, trace, scans)
+
+			status := timeouts.Status(base.Timeout)
+			if len(status) != 1 {
+				t.Fatalf("status = %+v", status)
+			}
+			for i := 0; i < 19; i++ {
+				if effective[i] != base.Timeout {
+					t.Fatalf("scan %d: timeout %v before min_scans, want %v", i+1, effective[i], base.Timeout)
+				}
+			}
+
+			final := effective[len(effective)-1]
+			if final < tt.min || final > tt.max {
+				t.Fatalf("converged to %v, want within [%v, %v]", final, tt.min, tt.max)
+			}
+			count, last := changes(effective)
+			if count > 4 || last > scans/3 {
+				t.Fatalf("timeout changed %d times, last at scan %d of %d; want settled in the first third", count, last+1, scans)
+			}
+
+			// 学到的值下录制的扫码至多1%被拆散
+			truncated := 0
+			for _, timings := range trace {
+				if maxInterval(timings) > final {
+					truncated++
+				}
+			}
+			if truncated*100 > len(trace) {
+				t.Fatalf("%d of %d recorded scans exceed %v", truncated, len(trace), final)
+			}
+
+			if status[0].Source != TimeoutSourceAdaptive || status[0].EffectiveMS != int(final/time.Millisecond) || status[0].Intervals != 500 {
+				t.Fatalf("status = %+v", status[0])
+			}
+			if len(persisted) == 0 || persisted[len(persisted)-1].LearnedMS != status[0].LearnedMS {
+				t.Fatalf("persisted = %+v, want last learned %d", persisted, status[0].LearnedMS)
+			}
+
+			// 从录制的另一处开始回放，收敛到相近的值
+			rotated := append(append([][]service.KeyTiming(nil), trace[len(trace)/2:]...), trace[:len(trace)/2]...)
+			again, _ := testAdaptive()
+			if got := replay(again, base, 1, rotated, scans); !withinBand(got[len(got)-1], final) {
+				t.Fatalf("rotated trace converged to %v, want about %v", got[len(got)-1], final)
+			}
+		})
+	}
+}
+
+// TestAdaptiveTimeoutStartsFromStored 启动时从设备表读到的学习结果与录制的扫码不符（如更换扫码枪后未重置）时，
+// 过高的值在一个窗口后降低，过低的值立即升高，最终与从头学习收敛到相近的值
+func TestAdaptiveTimeoutStartsFromStored(t *testing.T) {
+	trace := loadTrace(t, "bluetooth.txt")
+	fresh, base := testAdaptive()
+	effective := replay(fresh, base, 1, trace, 3000)
+	want := effective[len(effective)-1]
+
+	for _, stored := range []int{500, 40} {
+		t.Run(strconv.Itoa(stored), func(t *testing.T) {
+			timeouts, base := testAdaptive(DeviceTimeout{DeviceID: 1, Adaptive: true, LearnedMS: stored, Samples: 1000})
+			effective := replay(timeouts, base, 1, trace, 3000)
+			if got := effective[len(effective)-1]; !withinBand(got, want) {
+				t.Fatalf("converged to %v, want about %v", got, want)
+			}
+			if _, last := changes(effective); last > 1000 {
+				t.Fatalf("last change at scan %d", last+1)
+			}
+		})
+	}
+}
+
+// TestAdaptiveTimeoutReplaced 有线扫码枪换成蓝牙扫码枪后，未被拆散的扫码使学到的值升高；
+// 重置后重新学习，样本不足时使用 scanner.timeout_ms
+func TestAdaptiveTimeoutReplaced(t *testing.T) {
+	usb, bluetooth := loadTrace(t, "usb-wired.txt"), loadTrace(t, "bluetooth.txt")
+	timeouts, base := testAdaptive()
+	if got := replay(timeouts, base, 1, usb, 1000); got[len(got)-1] != 30*time.Millisecond {
+		t.Fatalf("usb converged to %v", got[len(got)-1])
+	}
+
+	// 不重置：30毫秒下仍有部分蓝牙扫码完整组装，升高立即生效
+	effective := replay(timeouts, base, 1, bluetooth, 3000)
+	if got := effective[len(effective)-1]; got < 110*time.Millisecond || got > 150*time.Millisecond {
+		t.Fatalf("bluetooth without reset converged to %v", got)
+	}
+
+	timeouts.Reset(1)
+	status := timeouts.Status(base.Timeout)[0]
+	if status.LearnedMS != 0 || status.Samples != 0 || status.Intervals != 0 || status.Source != TimeoutSourceLearning || status.EffectiveMS != 100 {
+		t.Fatalf("status after reset = %+v", status)
+	}
+	effective = replay(timeouts, base, 1, bluetooth, 3000)
+	if got := effective[len(effective)-1]; got < 110*time.Millisecond || got > 150*time.Millisecond {
+		t.Fatalf("bluetooth after reset converged to %v", got)
+	}
+}
+
+// TestAdaptiveTimeoutManual 手动指定的按键超时始终优先，自动调整在后台继续学习；取消手动值后使用学到的值
+func TestAdaptiveTimeoutManual(t *testing.T) {
+	trace := loadTrace(t, "bluetooth.txt")
+	timeouts, base := testAdaptive(DeviceTimeout{DeviceID: 1, Adaptive: true, ManualMS: 250}, DeviceTimeout{DeviceID: 2})
+	for _, timeout := range replay(timeouts, base, 0, trace, 2000) {
+		if timeout != 250*time.Millisecond {
+			t.Fatalf("timeout %v, want manual 250ms", timeout)
+		}
+	}
+	status := timeouts.Status(base.Timeout)
+	if status[0].Source != TimeoutSourceManual || !status[0].Default || status[0].LearnedMS == 0 {
+		t.Fatalf("manual device status = %+v", status[0])
+	}
+	learned := status[0].LearnedMS
+
+	// 未开启自动调整的设备不学习
+	replay(timeouts, base, 2, trace, 100)
+	if status := timeouts.Status(base.Timeout)[1]; status.Source != TimeoutSourceDefault || status.Samples != 0 || status.EffectiveMS != 100 {
+		t.Fatalf("non-adaptive device status = %+v", status)
+	}
+
+	timeouts.Configure([]DeviceTimeout{{DeviceID: 1, Adaptive: true}, {DeviceID: 2}}, 1)
+	if got := timeouts.Settings(base, 1).Timeout; got != time.Duration(learned)*time.Millisecond {
+		t.Fatalf("timeout after clearing manual = %v, want learned %dms", got, learned)
+	}
+}
@@ -0,0 +1,209 @@
+package scanner
+
+import (
+	"strings"
+	"time"
+
+	"userclient/internal/service"
+)
+
+// Assembler 条码组装器，将按键序列组装为完整条码
+//
+// 处理以下扫码枪特性：
+//   - 连续终止符（CR+LF被转换为两次回车）在合并窗口内视为一次
+//   - 被终止符拆开的短片段（两段都短于最小长度且间隔在按键超时内）重新拼接
+//   - 不使用终止符的扫码枪，由调用方在按键停顿后调用 Expire 结束扫码
+//   - 扫码枪故障持续输出而不发送终止符时，缓冲超过上限即丢弃，至按键停顿或终止符前的后续字符也丢弃
+type Assembler struct {
+	settings       *Settings
+	buffer         strings.Builder
+	firstKeyTime   time.Time
+	lastKeyTime    time.Time
+	lastTerminator time.Time
+	fragment       string
+	fragmentStart  time.Time
+	fragmentTime   time.Time
+	lastDuration   time.Duration
+	timings        []service.KeyTiming // 缓冲中各按键的时间信息
+	fragTimings    []service.KeyTiming
+	lastTimings    []service.KeyTiming
+	counters       *hookCounters
+
+	overflowed bool // 缓冲已超过上限，丢弃至按键停顿或终止符
+}
+
+// NewAssembler 创建条码组装器，counters 为所属采集来源的计数，为nil时不计数
+func NewAssembler(settings *Settings, counters *hookCounters) *Assembler {
+	if counters == nil {
+		counters = &hookCounters{}
+	}
+	return &Assembler{settings: settings, counters: counters}
+}
+
+// Use 之后的按键按新参数组装，缓冲中的内容保留
+func (a *Assembler) Use(settings *Settings) {
+	a.settings = settings
+}
+
+// Timeout 当前的按键超时
+func (a *Assembler) Timeout() time.Duration {
+	return a.settings.Timeout
+}
+
+// AddChar 追加字符，按键间隔超时则丢弃之前的缓冲
+func (a *Assembler) AddChar(ch byte, at time.Time) {
+	a.AddKey(ch, at, service.KeyTiming{})
+}
+
+// AddKey 追加字符及钩子上报的按键时间信息
+//
+// 缓冲超过上限时丢弃缓冲并返回其开头部分（供调用方告警），之后的字符不再缓冲，直到按键停顿或收到终止符；
+// 上限不小于 max_length，长度在有效范围内的条码不会被拆开。
+func (a *Assembler) AddKey(ch byte, at time.Time, timing service.KeyTiming) (overflow string, overflowed bool) {
+	if a.overflowed {
+		stalled := at.Sub(a.lastKeyTime) > a.settings.Timeout
+		a.lastKeyTime = at
+		if !stalled {
+			return "", false
+		}
+		a.overflowed = false
+	}
+	if a.buffer.Len() > 0 && at.Sub(a.lastKeyTime) > a.settings.Timeout {
+		a.buffer.Reset()
+		a.counters.resets.Add(1)
+	}
+	if a.buffer.Len() == 0 {
+		a.firstKeyTime = at
+		a.timings = a.timings[:0]
+	}
+	a.lastKeyTime = at
+	a.buffer.WriteByte(ch)
+	a.timings = append(a.timings, timing)
+	a.counters.chars.Add(1)
+
+	if a.buffer.Len() > a.settings.BufferCap {
+		overflow = preview(a.buffer.String())
+		a.buffer.Reset()
+		a.timings = a.timings[:0]
+		a.overflowed = true
+		a.counters.overflows.Add(1)
+		return overflow, true
+	}
+	return "", false
+}
+
+// Terminate 处理终止符，返回组装出的有效条码
+func (a *Assembler) Terminate(at time.Time) (string, bool) {
+	content := a.buffer.String()
+	firstKeyTime := a.firstKeyTime
+	a.buffer.Reset()
+	// 故障输出的终止符只结束丢弃，不与之前的短片段拼接
+	if a.overflowed {
+		a.overflowed = false
+		a.lastTerminator = at
+		a.dropFragment()
+		return "", false
+	}
+
+	previous := a.lastTerminator
+	a.lastTerminator = at
+
+	// 空缓冲或缓冲已超时
+	if content == "" || at.Sub(a.lastKeyTime) > a.settings.Timeout {
+		if content != "" {
+			a.counters.resets.Add(1)
+		}
+		// 合并窗口内的重复终止符直接忽略；否则视为独立的回车，丢弃暂存片段
+		if previous.IsZero() || at.Sub(previous) > a.settings.CollapseWindow {
+			a.dropFragment()
+		}
+		return "", false
+	}
+
+	// 与上一个短片段拼接
+	if a.fragment != "" {
+		if len(content) < a.settings.MinLength && firstKeyTime.Sub(a.fragmentTime) <= a.settings.Timeout {
+			if merged := a.fragment + content; a.valid(merged) {
+				a.fragment = ""
+				a.lastDuration = a.lastKeyTime.Sub(a.fragmentStart)
+				a.lastTimings = append(append([]service.KeyTiming(nil), a.fragTimings...), a.timings...)
+				return merged, true
+			}
+		}
+		a.dropFragment()
+	}
+
+	if a.valid(content) {
+		a.lastDuration = a.lastKeyTime.Sub(firstKeyTime)
+		a.lastTimings = append([]service.KeyTiming(nil), a.timings...)
+		return content, true
+	}
+
+	switch {
+	case len(content) > a.settings.MaxLength:
+		a.counters.tooLong.Add(1)
+	case a.settings.MergeFragments:
+		// 过短的片段先暂存，等待可能的后半段
+		a.fragment = content
+		a.fragmentStart = firstKeyTime
+		a.fragmentTime = at
+		a.fragTimings = append(a.fragTimings[:0], a.timings...)
+	default:
+		a.counters.tooShort.Add(1)
+	}
+	return "", false
+}
+
+// dropFragment 丢弃未能拼接的暂存片段，计为过短
+func (a *Assembler) dropFragment() {
+	if a.fragment != "" {
+		a.fragment = ""
+		a.counters.tooShort.Add(1)
+	}
+}
+
+// Expire 不使用终止符时按停顿结束扫码：最后一个按键之后超过按键超时，按在最后一个按键处终止处理
+func (a *Assembler) Expire(at time.Time) (string, bool) {
+	if a.buffer.Len() == 0 || at.Sub(a.lastKeyTime) < a.settings.Timeout {
+		return "", false
+	}
+	return a.Terminate(a.lastKeyTime)
+}
+
+// Pending 缓冲中是否有尚未结束的扫码
+func (a *Assembler) Pending() bool {
+	return a.buffer.Len() > 0
+}
+
+// Duration 最近一次组装出的条码从首个按键到最后一个按键的耗时
+func (a *Assembler) Duration() time.Duration {
+	return a.lastDuration
+}
+
+// Timings 最近一次组装出的条码各按键的时间信息
+func (a *Assembler) Timings() []service.KeyTiming {
+	return a.lastTimings
+}
+
+// Reset 清空缓冲和暂存片段
+func (a *Assembler) Reset() {
+	a.buffer.Reset()
+	a.fragment = ""
+	a.overflowed = false
+}
+
+// overflowPreviewLength 超过缓冲上限告警时记录的缓冲开头长度
+const overflowPreviewLength = 32
+
+// preview 缓冲的开头部分
+func preview(content string) string {
+	if len(content) <= overflowPreviewLength {
+		return content
+	}
+	return content[:overflowPreviewLength] + "..."
+}
+
+// valid 长度是否在有效范围内
+func (a *Assembler) valid(content string) bool {
+	return len(content) >= a.settings.MinLength && len(content) <= a.settings.MaxLength
+}
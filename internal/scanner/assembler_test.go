@@ -0,0 +1,228 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+// newTestAssembler 按 max_length 及缓冲上限倍数创建组装器，按键超时50ms
+func newTestAssembler(maxLength, factor int) (*Assembler, *hookCounters) {
+	cfg := config.ScannerConfig{TimeoutMS: 50, MinLength: 3, MaxLength: maxLength, MaxBufferFactor: factor, Terminators: []string{"enter"}}
+	counters := &hookCounters{}
+	return NewAssembler(NewSettings(&cfg), counters), counters
+}
+
+// TestAssemblerRunawayStream 扫码枪故障持续输出10000个字符而不发送终止符：缓冲在超过上限时丢弃一次并告警，
+// 之后的字符不再缓冲，终止符只结束丢弃；随后的正常扫码不受影响
+func TestAssemblerRunawayStream(t *testing.T) {
+	a, counters := newTestAssembler(50, 2)
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	const stream = 10000
+
+	overflows := 0
+	for i := 0; i < stream; i++ {
+		at = at.Add(time.Millisecond)
+		preview, overflowed := a.AddKey(byte('A'+i%26), at, service.KeyTiming{})
+		if !overflowed {
+			continue
+		}
+		overflows++
+		if i != 100 {
+			t.Errorf("overflow at key %d, want at key 100 (cap 2x max_length)", i)
+		}
+		if want := strings.Repeat("ABCDEFGHIJKLMNOPQRSTUVWXYZ", 2)[:overflowPreviewLength] + "..."; preview != want {
+			t.Errorf("preview = %q, want %q", preview, want)
+		}
+		if a.Pending() {
+			t.Error("buffer kept after overflow")
+		}
+	}
+	if overflows != 1 {
+		t.Fatalf("overflowed %d times, want once for the whole stream", overflows)
+	}
+	if a.Pending() {
+		t.Fatal("characters after the overflow were buffered")
+	}
+	metrics := counters.snapshot(0)
+	if metrics.BufferOverflows != 1 || metrics.CharsBuffered != 101 {
+		t.Errorf("metrics = %+v, want 1 overflow and 101 buffered chars", metrics)
+	}
+
+	// 故障输出的终止符只结束丢弃
+	at = at.Add(time.Millisecond)
+	if barcode, ok := a.Terminate(at); ok {
+		t.Fatalf("terminator after runaway stream produced %q", barcode)
+	}
+
+	// 随后的正常扫码
+	for _, ch := range []byte("6901234567892") {
+		at = at.Add(time.Millisecond)
+		a.AddChar(ch, at)
+	}
+	if barcode, ok := a.Terminate(at.Add(time.Millisecond)); !ok || barcode != "6901234567892" {
+		t.Fatalf("scan after runaway stream = %q, %v", barcode, ok)
+	}
+}
+
+// TestAssemblerOverflowEndsOnStall 超过上限后按键停顿即结束丢弃，不需要终止符
+func TestAssemblerOverflowEndsOnStall(t *testing.T) {
+	a, _ := newTestAssembler(20, 2)
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		at = at.Add(time.Millisecond)
+		a.AddChar('9', at)
+	}
+
+	at = at.Add(100 * time.Millisecond)
+	for _, ch := range []byte("SN000101") {
+		a.AddChar(ch, at)
+		at = at.Add(time.Millisecond)
+	}
+	if barcode, ok := a.Terminate(at); !ok || barcode != "SN000101" {
+		t.Fatalf("scan after stall = %q, %v", barcode, ok)
+	}
+}
+
+// TestAssemblerCapKeepsLongBarcodes 长度为 max_length 的条码不会被缓冲上限拆开，即使倍数为1
+func TestAssemblerCapKeepsLongBarcodes(t *testing.T) {
+	a, counters := newTestAssembler(50, 1)
+	content := strings.Repeat("0123456789", 5)
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < len(content); i++ {
+		at = at.Add(time.Millisecond)
+		if _, overflowed := a.AddKey(content[i], at, service.KeyTiming{}); overflowed {
+			t.Fatalf("overflowed at %d of a %d-char barcode", i, len(content))
+		}
+	}
+	if barcode, ok := a.Terminate(at); !ok || barcode != content {
+		t.Fatalf("barcode = %q, %v", barcode, ok)
+	}
+	if n := counters.overflows.Load(); n != 0 {
+		t.Errorf("overflows = %d, want 0", n)
+	}
+}
+
+// traceStep 录制的按键时序片段：首个按键在上一事件之后 gapMS 毫秒，其余按键间隔2ms，"\n" 为终止符
+type traceStep struct {
+	gapMS int
+	keys  string
+}
+
+// replayTrace 按录制的时序回放到组装器，返回组装出的条码
+func replayTrace(a *Assembler, trace []traceStep) []string {
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	var barcodes []string
+	for _, step := range trace {
+		at = at.Add(time.Duration(step.gapMS-2) * time.Millisecond)
+		for i := 0; i < len(step.keys); i++ {
+			at = at.Add(2 * time.Millisecond)
+			if step.keys[i] != '\n' {
+				a.AddChar(step.keys[i], at)
+				continue
+			}
+			if barcode, ok := a.Terminate(at); ok {
+				barcodes = append(barcodes, barcode)
+			}
+		}
+	}
+	return barcodes
+}
+
+// TestAssemblerTimingTraces 扫码枪实测的时序：CR+LF 的第二个终止符在合并窗口内不产生空扫码，
+// 被重复回车拆开的短片段在按键超时内拼接，超出窗口或超时则按过短丢弃
+func TestAssemblerTimingTraces(t *testing.T) {
+	tests := []struct {
+		name     string
+		merge    bool
+		trace    []traceStep
+		want     []string
+		tooShort uint64
+	}{
+		{
+			name:  "cr lf",
+			merge: true,
+			trace: []traceStep{{0, "6901234567892\n"}, {3, "\n"}},
+			want:  []string{"6901234567892"},
+		},
+		{
+			name:  "consecutive cr lf scans",
+			merge: true,
+			trace: []traceStep{{0, "SN000101\n"}, {3, "\n"}, {200, "SN000102\n"}, {3, "\n"}},
+			want:  []string{"SN000101", "SN000102"},
+		},
+		{
+			name:  "split fragment",
+			merge: true,
+			trace: []traceStep{{0, "ABC\n"}, {10, "DE\n"}},
+			want:  []string{"ABCDE"},
+		},
+		{
+			name:  "split fragment with cr lf",
+			merge: true,
+			trace: []traceStep{{0, "ABC\n"}, {3, "\n"}, {10, "DE\n"}, {3, "\n"}},
+			want:  []string{"ABCDE"},
+		},
+		{
+			name:     "fragment after key timeout",
+			merge:    true,
+			trace:    []traceStep{{0, "ABC\n"}, {80, "DE\n"}},
+			tooShort: 1, // DE 暂存，等待可能的后半段
+		},
+		{
+			name:     "lone enter after collapse window drops fragment",
+			merge:    true,
+			trace:    []traceStep{{0, "ABC\n"}, {40, "\n"}, {5, "DE\n"}},
+			tooShort: 1,
+		},
+		{
+			name:     "long second part is not merged",
+			merge:    true,
+			trace:    []traceStep{{0, "ABC\n"}, {10, "DEFGH\n"}},
+			want:     []string{"DEFGH"},
+			tooShort: 1,
+		},
+		{
+			name:     "merge disabled",
+			merge:    false,
+			trace:    []traceStep{{0, "ABC\n"}, {10, "DE\n"}},
+			tooShort: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.ScannerConfig{TimeoutMS: 50, MinLength: 5, MaxLength: 50, MaxBufferFactor: 2, TerminatorCollapseMS: 30, MergeFragments: tt.merge, Terminators: []string{"enter"}}
+			counters := &hookCounters{}
+			a := NewAssembler(NewSettings(&cfg), counters)
+			got := replayTrace(a, tt.trace)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("barcodes = %q, want %q", got, tt.want)
+			}
+			if n := counters.tooShort.Load(); n != tt.tooShort {
+				t.Errorf("too short = %d, want %d", n, tt.tooShort)
+			}
+			if a.Pending() {
+				t.Error("keys left in buffer")
+			}
+		})
+	}
+}
+
+// TestAssemblerMergedDuration 拼接出的条码耗时从前一片段的首个按键算起，按键时间信息包含两段
+func TestAssemblerMergedDuration(t *testing.T) {
+	cfg := config.ScannerConfig{TimeoutMS: 50, MinLength: 5, MaxLength: 50, MaxBufferFactor: 2, TerminatorCollapseMS: 30, MergeFragments: true, Terminators: []string{"enter"}}
+	a := NewAssembler(NewSettings(&cfg), nil)
+	if got := replayTrace(a, []traceStep{{0, "ABC\n"}, {10, "DE\n"}}); len(got) != 1 {
+		t.Fatalf("barcodes = %q", got)
+	}
+	// ABC 4ms，终止符后10ms按下 D，再2ms按下 E
+	if got, want := a.Duration(), 18*time.Millisecond; got != want {
+		t.Errorf("duration = %v, want %v", got, want)
+	}
+	if n := len(a.Timings()); n != 5 {
+		t.Errorf("timings = %d, want 5", n)
+	}
+}
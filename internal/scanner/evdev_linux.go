@@ -0,0 +1,636 @@
+//go:build linux
+
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+	"userclient/pkg/barcode"
+)
+
+// ErrUnsupported 当前平台不支持按键采集
+var ErrUnsupported = errors.New("按键采集仅支持Windows及Linux")
+
+// evdev 常量（linux/input.h、linux/input-event-codes.h）
+const (
+	evKey = 0x01
+
+	keyEsc        = 1
+	keyTab        = 15
+	keyEnter      = 28
+	keyLeftCtrl   = 29
+	keyLeftShift  = 42
+	keyRightShift = 54
+	keyLeftAlt    = 56
+	keyKPEnter    = 96
+	keyRightCtrl  = 97
+	keyRightAlt   = 100
+	keyA          = 30
+	key1          = 2
+	keyMax        = 0x2ff
+
+	keyReleased = 0
+	keyPressed  = 1 // 2 为按住自动重复，忽略
+)
+
+// evdevInputDir 输入设备目录
+const evdevInputDir = "/dev/input"
+
+// evdevRescanInterval 检查新插入的输入设备的间隔
+const evdevRescanInterval = 2 * time.Second
+
+// inputEvent 内核上报的输入事件（struct input_event），时间戳为 struct timeval，32位与64位平台大小不同
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// evdevChars 美式键盘布局下按键对应的字符，[0] 未按 Shift，[1] 按住 Shift
+var evdevChars = map[uint16][2]byte{
+	2: {'1', '!'}, 3: {'2', '@'}, 4: {'3', '#'}, 5: {'4', '$'}, 6: {'5', '%'},
+	7: {'6', '^'}, 8: {'7', '&'}, 9: {'8', '*'}, 10: {'9', '('}, 11: {'0', ')'},
+	12: {'-', '_'}, 13: {'=', '+'},
+	16: {'q', 'Q'}, 17: {'w', 'W'}, 18: {'e', 'E'}, 19: {'r', 'R'}, 20: {'t', 'T'},
+	21: {'y', 'Y'}, 22: {'u', 'U'}, 23: {'i', 'I'}, 24: {'o', 'O'}, 25: {'p', 'P'},
+	26: {'[', '{'}, 27: {']', '}'},
+	30: {'a', 'A'}, 31: {'s', 'S'}, 32: {'d', 'D'}, 33: {'f', 'F'}, 34: {'g', 'G'},
+	35: {'h', 'H'}, 36: {'j', 'J'}, 37: {'k', 'K'}, 38: {'l', 'L'},
+	39: {';', ':'}, 40: {'\'', '"'}, 41: {'`', '~'}, 43: {'\\', '|'},
+	44: {'z', 'Z'}, 45: {'x', 'X'}, 46: {'c', 'C'}, 47: {'v', 'V'}, 48: {'b', 'B'},
+	49: {'n', 'N'}, 50: {'m', 'M'},
+	51: {',', '<'}, 52: {'.', '>'}, 53: {'/', '?'},
+	55: {'*', '*'}, 57: {' ', ' '},
+	// 小键盘
+	71: {'7', '7'}, 72: {'8', '8'}, 73: {'9', '9'}, 74: {'-', '-'},
+	75: {'4', '4'}, 76: {'5', '5'}, 77: {'6', '6'}, 78: {'+', '+'},
+	79: {'1', '1'}, 80: {'2', '2'}, 81: {'3', '3'}, 82: {'0', '0'}, 83: {'.', '.'},
+	98: {'/', '/'},
+}
+
+// evdevTerminators 按键对应的终止符虚拟键码，与键盘钩子共用终止符配置
+var evdevTerminators = map[uint16]uint32{
+	keyEnter:   VK_RETURN,
+	keyKPEnter: VK_RETURN,
+	keyTab:     VK_TAB,
+}
+
+// evdevSeparators 可配置为组分隔符的按键对应的虚拟键码，与键盘钩子共用组分隔符配置
+var evdevSeparators = map[uint16]uint32{
+	27: VK_OEM_6, // 右方括号
+	59: VK_F1, 60: VK_F1 + 1, 61: VK_F1 + 2, 62: VK_F1 + 3, 63: VK_F1 + 4,
+	64: VK_F1 + 5, 65: VK_F1 + 6, 66: VK_F1 + 7, 67: VK_F1 + 8, 68: VK_F1 + 9,
+	87: VK_F1 + 10, 88: VK_F1 + 11,
+}
+
+// Available 当前进程能否读取输入设备
+//
+// 没有输入设备时视为可用（扫码枪可能稍后插入）；有输入设备但都无法打开时通常是运行用户不在 input 组。
+func Available() (bool, string) {
+	paths, err := filepath.Glob(filepath.Join(evdevInputDir, "event*"))
+	if err != nil || len(paths) == 0 {
+		if _, err := os.Stat(evdevInputDir); err != nil {
+			return false, "没有 " + evdevInputDir + " 目录"
+		}
+		return true, ""
+	}
+	for _, path := range paths {
+		if f, err := os.Open(path); err == nil {
+			f.Close()
+			return true, ""
+		}
+	}
+	return false, "没有读取 " + evdevInputDir + "/event* 的权限，请将运行用户加入 input 组"
+}
+
+// NewSource 创建当前平台的按键采集来源，Linux下读取 evdev 输入设备
+func NewSource(cfg *config.ScannerConfig, handler BarcodeHandler, logger *logrus.Logger) KeyboardSource {
+	return NewEvdevSource(cfg, handler, logger)
+}
+
+// EvdevSource 读取 Linux evdev 输入设备的按键
+//
+// 每个输入设备在独立的 goroutine 中读取并单独组装，扫码枪与操作员键盘的按键不会混在一起；
+// 设备的稳定路径（/dev/input/by-id 下的链接，没有时为 /dev/input/eventN）登记到设备的 device_path 后，
+// 该设备的扫码归属到登记的设备。按键按美式布局翻译，终止符、按键超时及停顿结束扫码与键盘钩子相同，
+// 可通过 UpdateConfig 在运行中修改。新插入的设备在下一次检查时自动打开，拔出的设备自动关闭。
+type EvdevSource struct {
+	config   *config.ScannerConfig
+	settings atomic.Pointer[Settings] // 运行中可替换的扫码参数
+	handler  BarcodeHandler
+	logger   *logrus.Logger
+	resolver DeviceResolver
+	timeouts *AdaptiveTimeouts // 按设备替换按键超时，为nil时使用 timeout_ms
+	typing   *TypingFilter     // 按按键节奏丢弃人工输入
+	running  atomic.Bool
+	paused   atomic.Bool  // 暂停采集，按键不组装
+	metrics  hookCounters // 按键、缓冲及扫码计数，各设备共用
+
+	mu      sync.Mutex
+	devices map[string]*evdevDevice // 设备节点 -> 已打开的设备
+	done    chan struct{}           // Stop 后关闭
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// NewEvdevSource 创建 evdev 按键采集来源
+func NewEvdevSource(cfg *config.ScannerConfig, handler BarcodeHandler, logger *logrus.Logger) *EvdevSource {
+	s := &EvdevSource{
+		config:  cfg,
+		handler: handler,
+		logger:  logger,
+		typing:  NewTypingFilter(cfg.MaxAvgKeyIntervalMS),
+		devices: make(map[string]*evdevDevice),
+		done:    make(chan struct{}),
+	}
+	s.settings.Store(NewSettings(cfg))
+	return s
+}
+
+// UpdateConfig 替换按键超时、长度范围、终止符等扫码参数，已打开的设备从下一个按键起生效
+func (s *EvdevSource) UpdateConfig(cfg config.ScannerConfig) error {
+	if err := ValidateSettings(cfg); err != nil {
+		return err
+	}
+	s.settings.Store(NewSettings(&cfg))
+	s.typing.SetMaxAvg(cfg.MaxAvgKeyIntervalMS)
+	return nil
+}
+
+// Name 采集来源标识
+func (s *EvdevSource) Name() string {
+	return "evdev"
+}
+
+// SetDeviceResolver 设置按设备路径查找设备的函数，须在 Install 前调用；未设置时扫码都归属默认设备
+func (s *EvdevSource) SetDeviceResolver(resolver DeviceResolver) {
+	s.resolver = resolver
+}
+
+// SetTimeouts 设置各设备的按键超时，须在 Install 前调用
+func (s *EvdevSource) SetTimeouts(timeouts *AdaptiveTimeouts) {
+	s.timeouts = timeouts
+}
+
+// Install 打开匹配的输入设备并开始读取
+func (s *EvdevSource) Install() error {
+	if !s.config.EnableHook {
+		s.logger.Info("键盘钩子已禁用")
+		return nil
+	}
+	if _, err := os.Stat(evdevInputDir); err != nil {
+		return fmt.Errorf("输入设备目录不可用: %w", err)
+	}
+	s.running.Store(true)
+	s.scan()
+
+	fields := logrus.Fields{"devices": len(s.Stats().Keyboards)}
+	if len(s.config.EvdevDevices) > 0 {
+		fields["match"] = strings.Join(s.config.EvdevDevices, ", ")
+	}
+	s.logger.WithFields(fields).Info("已开始读取输入设备，等待扫码枪输入...")
+	if s.config.SuppressInput {
+		s.logger.Warn("Linux下不支持拦截扫码按键，suppress_input 不生效")
+	}
+	return nil
+}
+
+// Uninstall 关闭所有输入设备，可在任意 goroutine 中调用
+func (s *EvdevSource) Uninstall() {
+	if !s.running.Swap(false) {
+		return
+	}
+	s.mu.Lock()
+	for _, device := range s.devices {
+		device.file.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.logger.Info("输入设备读取已停止")
+}
+
+// Run 定期检查新插入的输入设备，Stop 后返回
+func (s *EvdevSource) Run() {
+	ticker := time.NewTicker(evdevRescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.running.Load() {
+				s.scan()
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Stop 关闭所有输入设备并结束 Run，可在任意 goroutine 中调用
+func (s *EvdevSource) Stop() {
+	s.Uninstall()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.done)
+	}
+}
+
+// IsRunning 是否正在读取输入设备
+func (s *EvdevSource) IsRunning() bool {
+	return s.running.Load()
+}
+
+// Pause 暂停采集，设备保持打开，按键不组装；可在任意 goroutine 中调用
+//
+// 未独占输入设备，暂停前后按键都照常传给前台程序。
+func (s *EvdevSource) Pause() {
+	if !s.paused.CompareAndSwap(false, true) {
+		return
+	}
+	s.logger.Info("已暂停扫码采集")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, device := range s.devices {
+		device.stopIdleTimer()
+		device.mu.Lock()
+		device.assembler.Reset()
+		device.mu.Unlock()
+	}
+}
+
+// Resume 恢复采集；可在任意 goroutine 中调用
+func (s *EvdevSource) Resume() {
+	if s.paused.CompareAndSwap(true, false) {
+		s.logger.Info("已恢复扫码采集")
+	}
+}
+
+// Paused 是否已暂停采集
+func (s *EvdevSource) Paused() bool {
+	return s.paused.Load()
+}
+
+// Stats 各输入设备的按键数，可在任意 goroutine 中调用
+func (s *EvdevSource) Stats() HookStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keyboards := make([]KeyboardStats, 0, len(s.devices))
+	for _, device := range s.devices {
+		keyboards = append(keyboards, KeyboardStats{
+			Path:     device.path,
+			Name:     device.name,
+			DeviceID: device.deviceID,
+			Keys:     device.keys.Load(),
+		})
+	}
+	sort.Slice(keyboards, func(i, j int) bool { return keyboards[i].Path < keyboards[j].Path })
+	return HookStats{RejectedTyping: s.typing.Rejected(), Keyboards: keyboards}
+}
+
+// GetMetrics 按键、缓冲及扫码计数，可在任意 goroutine 中调用；evdev 没有注入按键
+func (s *EvdevSource) GetMetrics() HookMetrics {
+	return s.metrics.snapshot(0)
+}
+
+// scan 打开尚未打开且匹配配置的键盘类输入设备
+func (s *EvdevSource) scan() {
+	nodes, err := filepath.Glob(filepath.Join(evdevInputDir, "event*"))
+	if err != nil {
+		return
+	}
+	var stable map[string]string
+	for _, node := range nodes {
+		s.mu.Lock()
+		_, opened := s.devices[node]
+		s.mu.Unlock()
+		if opened {
+			continue
+		}
+
+		device, err := s.open(node)
+		if err != nil {
+			s.logger.WithError(err).WithField("node", node).Debug("跳过输入设备")
+			continue
+		}
+		if device == nil {
+			continue
+		}
+		if stable == nil {
+			stable = stablePaths()
+		}
+		if path, ok := stable[node]; ok {
+			device.path = path
+		}
+		if s.resolver != nil {
+			if id, ok := s.resolver(device.path); ok {
+				device.deviceID = id
+			}
+		}
+
+		s.mu.Lock()
+		if !s.running.Load() {
+			s.mu.Unlock()
+			device.file.Close()
+			return
+		}
+		s.devices[node] = device
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		s.logger.WithFields(logrus.Fields{"name": device.name, "path": device.path, "device_id": device.deviceID}).Info("已打开输入设备")
+		go func() {
+			defer s.wg.Done()
+			s.read(device)
+		}()
+	}
+}
+
+// open 打开输入设备，不是键盘类设备或名称不匹配时返回 nil
+func (s *EvdevSource) open(node string) (*evdevDevice, error) {
+	file, err := os.Open(node)
+	if err != nil {
+		return nil, err
+	}
+	name, keyboard, err := deviceInfo(file)
+	if err != nil || !keyboard || !s.matches(name) {
+		file.Close()
+		return nil, err
+	}
+
+	return &evdevDevice{
+		node:      node,
+		path:      node,
+		name:      name,
+		file:      file,
+		assembler: NewAssembler(s.settings.Load(), &s.metrics),
+	}, nil
+}
+
+// matches 设备名称是否匹配 evdev_devices，未配置时匹配所有设备
+func (s *EvdevSource) matches(name string) bool {
+	if len(s.config.EvdevDevices) == 0 {
+		return true
+	}
+	for _, pattern := range s.config.EvdevDevices {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// read 读取输入设备直到出错（拔出）或被关闭
+func (s *EvdevSource) read(device *evdevDevice) {
+	defer func() {
+		device.stopIdleTimer()
+		s.mu.Lock()
+		delete(s.devices, device.node)
+		s.mu.Unlock()
+	}()
+
+	size := int(unsafe.Sizeof(inputEvent{}))
+	buf := make([]byte, size*64)
+	for {
+		n, err := device.file.Read(buf)
+		if err != nil {
+			if s.running.Load() && !errors.Is(err, os.ErrClosed) {
+				s.logger.WithError(err).WithFields(logrus.Fields{"name": device.name, "path": device.path}).Warn("输入设备已断开")
+			}
+			device.file.Close()
+			return
+		}
+		for offset := 0; offset+size <= n; offset += size {
+			event := *(*inputEvent)(unsafe.Pointer(&buf[offset]))
+			if event.Type == evKey {
+				s.key(device, event)
+			}
+		}
+	}
+}
+
+// key 处理按键事件
+func (s *EvdevSource) key(device *evdevDevice, event inputEvent) {
+	switch event.Code {
+	case keyLeftShift, keyRightShift:
+		device.shift = event.Value != keyReleased
+		return
+	case keyLeftCtrl, keyRightCtrl:
+		device.ctrl = event.Value != keyReleased
+		return
+	case keyLeftAlt, keyRightAlt:
+		device.alt = event.Value != keyReleased
+		return
+	}
+	if event.Value != keyPressed {
+		return
+	}
+	device.keys.Add(1)
+	s.metrics.keys.Add(1)
+	if s.paused.Load() {
+		return
+	}
+
+	// 以内核记录的按键时间组装，与读取的延迟无关；时间信息取毫秒的低32位，按键间隔按差值计算
+	at := time.Unix(int64(event.Time.Sec), int64(event.Time.Usec)*1000)
+	timing := service.KeyTiming{Tick: uint32(at.UnixMilli())}
+	settings := s.timeouts.Settings(s.settings.Load(), device.deviceID)
+
+	if vkCode, ok := evdevTerminators[event.Code]; ok && settings.Terminators[vkCode] {
+		s.finalize(device, at, false)
+		return
+	}
+	var ch byte
+	if vkCode, ok := evdevSeparators[event.Code]; ok && settings.GroupSeparators.Match(vkCode, device.shift, device.ctrl, device.alt) {
+		ch = barcode.GroupSeparator
+	} else {
+		chars, ok := evdevChars[event.Code]
+		if !ok || device.ctrl || device.alt {
+			return
+		}
+		ch = chars[0]
+		if device.shift {
+			ch = chars[1]
+		}
+	}
+
+	device.mu.Lock()
+	device.assembler.Use(settings)
+	overflow, overflowed := device.assembler.AddKey(ch, at, timing)
+	device.mu.Unlock()
+	if overflowed {
+		s.logger.WithFields(logrus.Fields{"path": device.path, "limit": settings.BufferCap, "max_length": settings.MaxLength, "preview": overflow}).Warn("条码缓冲超过上限仍未收到终止符，已丢弃，扫码枪或线缆可能故障")
+	}
+	if settings.Idle {
+		device.resetIdleTimer(settings.Timeout, func() { s.finalizeIdle(device) })
+	}
+}
+
+// finalize 结束设备的扫码并处理组装出的条码，idle 表示未收到终止符、按停顿结束
+func (s *EvdevSource) finalize(device *evdevDevice, at time.Time, idle bool) {
+	// 组装结果在锁内取出，处理条码时不持有锁；按当前的长度范围校验
+	device.mu.Lock()
+	device.assembler.Use(s.timeouts.Settings(s.settings.Load(), device.deviceID))
+	var barcode string
+	var ok bool
+	if idle {
+		barcode, ok = device.assembler.Expire(at)
+	} else {
+		barcode, ok = device.assembler.Terminate(at)
+	}
+	duration, timings := device.assembler.Duration(), device.assembler.Timings()
+	device.mu.Unlock()
+	if !ok || s.handler == nil {
+		return
+	}
+	if reason, human := s.typing.Check(barcode, duration, timings); human {
+		s.metrics.invalid.Add(1)
+		s.logger.WithFields(logrus.Fields{"path": device.path, "length": len(barcode), "reason": reason}).Debug("按键节奏像人工输入，丢弃缓冲")
+		return
+	}
+
+	if err := Dispatch(s.handler, Scan{Barcode: barcode, Input: s.Name(), DeviceID: device.deviceID, Duration: duration, Timings: timings}); err != nil {
+		s.metrics.invalid.Add(1)
+		s.logger.WithError(err).WithField("path", device.path).Error("处理条码失败")
+		return
+	}
+	s.metrics.delivered.Add(1)
+	s.timeouts.Observe(device.deviceID, timings)
+}
+
+// finalizeIdle 按键停顿后结束扫码，在定时器的 goroutine 中调用
+func (s *EvdevSource) finalizeIdle(device *evdevDevice) {
+	s.finalize(device, time.Now(), true)
+	device.mu.Lock()
+	pending := device.assembler.Pending()
+	device.mu.Unlock()
+	if pending {
+		// 定时器到期后又有新按键，重新计时
+		device.resetIdleTimer(s.timeouts.Settings(s.settings.Load(), device.deviceID).Timeout, func() { s.finalizeIdle(device) })
+	}
+}
+
+// evdevDevice 已打开的输入设备，修饰键状态只在读取的 goroutine 中访问，组装器由 mu 保护
+type evdevDevice struct {
+	node     string // /dev/input/eventN
+	path     string // 稳定路径，用于匹配设备的 device_path
+	name     string
+	deviceID uint
+	file     *os.File
+	keys     atomic.Uint64
+
+	shift bool
+	ctrl  bool // Ctrl 或 Alt 按下时组合键不是扫码输入（组分隔符 Ctrl+] 除外）
+	alt   bool
+
+	mu        sync.Mutex
+	assembler *Assembler
+	idleTimer *time.Timer
+}
+
+// resetIdleTimer 每次按键重新计时，停顿超过按键超时后结束扫码
+func (d *evdevDevice) resetIdleTimer(timeout time.Duration, expire func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// 多等1毫秒，Expire 要求距最后一个按键超过按键超时
+	timeout += time.Millisecond
+	if d.idleTimer == nil {
+		d.idleTimer = time.AfterFunc(timeout, expire)
+		return
+	}
+	d.idleTimer.Reset(timeout)
+}
+
+// stopIdleTimer 设备关闭时停止计时
+func (d *evdevDevice) stopIdleTimer() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.idleTimer != nil {
+		d.idleTimer.Stop()
+	}
+}
+
+// deviceInfo 读取设备名称，并判断是否为键盘类设备（有字母键及数字键）
+func deviceInfo(file *os.File) (name string, keyboard bool, err error) {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return "", false, err
+	}
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		nameBuf := make([]byte, 256)
+		if ioctlErr = ioctl(fd, eviocgname(len(nameBuf)), unsafe.Pointer(&nameBuf[0])); ioctlErr != nil {
+			return
+		}
+		name = strings.TrimRight(string(nameBuf), "\x00")
+
+		keys := make([]byte, keyMax/8+1)
+		if ioctlErr = ioctl(fd, eviocgbit(evKey, len(keys)), unsafe.Pointer(&keys[0])); ioctlErr != nil {
+			return
+		}
+		has := func(code int) bool { return keys[code/8]&(1<<(code%8)) != 0 }
+		keyboard = has(keyA) && has(key1) && has(keyEnter) && has(keyEsc)
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return name, keyboard, ioctlErr
+}
+
+// ioctl 读取设备信息
+func ioctl(fd uintptr, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// eviocgname EVIOCGNAME(len)
+func eviocgname(size int) uintptr {
+	return iocRead('E', 0x06, size)
+}
+
+// eviocgbit EVIOCGBIT(ev, len)
+func eviocgbit(ev, size int) uintptr {
+	return iocRead('E', 0x20+ev, size)
+}
+
+// iocRead _IOC(_IOC_READ, type, nr, size)
+func iocRead(typ, nr, size int) uintptr {
+	const iocRead = 2
+	return uintptr(iocRead)<<30 | uintptr(size)<<16 | uintptr(typ)<<8 | uintptr(nr)
+}
+
+// stablePaths 设备节点对应的 /dev/input/by-id 下的链接，USB设备重新插拔或重启后链接不变
+func stablePaths() map[string]string {
+	paths := make(map[string]string)
+	links, _ := filepath.Glob(filepath.Join(evdevInputDir, "by-id", "*"))
+	sort.Strings(links)
+	for _, link := range links {
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		// 同一设备可能有多个链接（如 -event-kbd 与 -if01-event-kbd），取第一个
+		if _, ok := paths[target]; !ok {
+			paths[target] = link
+		}
+	}
+	return paths
+}
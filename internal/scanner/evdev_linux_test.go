@@ -0,0 +1,361 @@
+package scanner
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/pkg/barcode"
+)
+
+// evdevKeyOf 字符对应的主键盘区按键及是否须按住 Shift
+func evdevKeyOf(t *testing.T, ch byte) (code uint16, shift bool) {
+	t.Helper()
+	found := false
+	for c, chars := range evdevChars {
+		if c >= 71 { // 小键盘
+			continue
+		}
+		for i, candidate := range chars {
+			if candidate == ch && (!found || c < code) {
+				code, shift, found = c, i == 1, true
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("no key for %q", ch)
+	}
+	return code, shift
+}
+
+// evdevTyper 按扫码枪的键盘输出向 EvdevSource 发送按键事件，每个按键间隔5毫秒
+type evdevTyper struct {
+	t      *testing.T
+	source *EvdevSource
+	device *evdevDevice
+	at     time.Time
+}
+
+func (k *evdevTyper) event(code uint16, value int32) {
+	k.source.key(k.device, inputEvent{Time: syscall.NsecToTimeval(k.at.UnixNano()), Type: evKey, Code: code, Value: value})
+}
+
+// press 按下并松开按键，modifiers 在按键前按下、之后松开
+func (k *evdevTyper) press(code uint16, modifiers ...uint16) {
+	for _, modifier := range modifiers {
+		k.event(modifier, keyPressed)
+	}
+	k.at = k.at.Add(5 * time.Millisecond)
+	k.event(code, keyPressed)
+	k.event(code, keyReleased)
+	for _, modifier := range modifiers {
+		k.event(modifier, keyReleased)
+	}
+}
+
+// text 逐字符输入，大写字母及上档符号按住 Shift
+func (k *evdevTyper) text(s string) {
+	for i := 0; i < len(s); i++ {
+		code, shift := evdevKeyOf(k.t, s[i])
+		if shift {
+			k.press(code, keyLeftShift)
+		} else {
+			k.press(code)
+		}
+	}
+}
+
+// TestEvdevGroupSeparator 按扫码枪发送的按键序列重组GS1条码：配置的组分隔符按键写入 0x1D，
+// 其他组合按原字符处理；重组出的条码通过校验，JSON 中转义为 \u001d
+func TestEvdevGroupSeparator(t *testing.T) {
+	const (
+		keyRightBrace = 27 // ]
+		keyF8         = 66
+	)
+	// (01)09501101530003 (10)ABC123 <GS> (21)SER42：批号为可变长度字段，以组分隔符结束
+	const gs1 = "0109501101530003" + "10ABC123" + "\x1d" + "21SER42"
+
+	tests := []struct {
+		name       string
+		separators []string
+		separator  func(k *evdevTyper)
+		want       string
+	}{
+		{
+			name:       "ctrl+]",
+			separators: []string{"ctrl+]"},
+			separator:  func(k *evdevTyper) { k.press(keyRightBrace, keyLeftCtrl) },
+			want:       gs1,
+		},
+		{
+			name:       "right ctrl",
+			separators: []string{"ctrl+]"},
+			separator:  func(k *evdevTyper) { k.press(keyRightBrace, keyRightCtrl) },
+			want:       gs1,
+		},
+		{
+			name:       "f8",
+			separators: []string{"F8"},
+			separator:  func(k *evdevTyper) { k.press(keyF8) },
+			want:       gs1,
+		},
+		{
+			name:       "] stand-in",
+			separators: []string{"]"},
+			separator:  func(k *evdevTyper) { k.press(keyRightBrace) },
+			want:       gs1,
+		},
+		{
+			name:       "plain ] not configured",
+			separators: []string{"ctrl+]"},
+			separator:  func(k *evdevTyper) { k.press(keyRightBrace) },
+			want:       strings.Replace(gs1, "\x1d", "]", 1),
+		},
+		{
+			name:       "shift+] is a brace",
+			separators: []string{"]"},
+			separator:  func(k *evdevTyper) { k.press(keyRightBrace, keyLeftShift) },
+			want:       strings.Replace(gs1, "\x1d", "}", 1),
+		},
+		{
+			name:       "alt+] dropped",
+			separators: []string{"ctrl+]"},
+			separator:  func(k *evdevTyper) { k.press(keyRightBrace, keyLeftAlt) },
+			want:       strings.Replace(gs1, "\x1d", "", 1),
+		},
+		{
+			name:       "ctrl+] not configured",
+			separators: []string{"f8"},
+			separator:  func(k *evdevTyper) { k.press(keyRightBrace, keyLeftCtrl) },
+			want:       strings.Replace(gs1, "\x1d", "", 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			cfg := config.ScannerConfig{
+				TimeoutMS:       50,
+				MinLength:       3,
+				MaxLength:       64,
+				MaxBufferFactor: 2,
+				Terminators:     []string{"enter"},
+				GroupSeparator:  tt.separators,
+			}
+			handler := &recordingHandler{}
+			s := NewEvdevSource(&cfg, handler, logger)
+			device := &evdevDevice{node: "/dev/input/event0", path: "/dev/input/event0", assembler: NewAssembler(s.settings.Load(), &s.metrics)}
+			k := &evdevTyper{t: t, source: s, device: device, at: time.Unix(1700000000, 0)}
+
+			before, after, _ := strings.Cut(gs1, "\x1d")
+			k.text(before)
+			tt.separator(k)
+			k.text(after)
+			k.press(keyEnter)
+
+			if len(handler.barcodes) != 1 || handler.barcodes[0] != tt.want {
+				t.Fatalf("barcodes = %q, want %q", handler.barcodes, tt.want)
+			}
+		})
+	}
+
+	// 重组出的GS1条码保留组分隔符，通过校验，JSON 输出转义后可原样还原
+	processor := barcode.NewProcessor()
+	if valid, message := processor.ValidateBarcode(gs1); !valid {
+		t.Fatalf("ValidateBarcode(%q) = %s", gs1, message)
+	}
+	data := processor.ProcessBarcode(gs1)
+	if data.Content != gs1 {
+		t.Fatalf("content = %q, want %q", data.Content, gs1)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(encoded), `10ABC123\u001d21SER42`) {
+		t.Fatalf("json = %s, want separator escaped as \\u001d", encoded)
+	}
+	var decoded barcode.BarcodeData
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Content != gs1 {
+		t.Fatalf("decoded content = %q, want %q", decoded.Content, gs1)
+	}
+}
+
+// TestEvdevConcurrentStatus 设备读取 goroutine 持续收到按键的同时，HTTP 处理器及其他 goroutine 轮询 IsRunning、
+// 计数及设备状态，并暂停、恢复、修改扫码参数，最后两个 goroutine 同时 Stop；以 -race 运行时检查数据竞争
+func TestEvdevConcurrentStatus(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.ScannerConfig{TimeoutMS: 50, MinLength: 6, MaxLength: 6, MaxBufferFactor: 2, Terminators: []string{"enter"}}
+	handler := &recordingHandler{}
+	s := NewEvdevSource(&cfg, handler, logger)
+
+	// 以管道代替输入设备节点，按键事件经 read 交给 key，与读取真实设备相同
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	device := &evdevDevice{node: "/dev/input/event0", path: "/dev/input/event0", name: "test scanner", file: r, assembler: NewAssembler(s.settings.Load(), &s.metrics)}
+	s.running.Store(true)
+	s.mu.Lock()
+	s.devices[device.node] = device
+	s.wg.Add(1)
+	s.mu.Unlock()
+	go func() {
+		defer s.wg.Done()
+		s.read(device)
+	}()
+
+	codes := []string{"SN0001", "SN0002", "AB1234", "ZX9876"}
+	valid := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		valid[code] = true
+	}
+
+	// 扫码枪：每个条码后一个回车，设备关闭后写入失败即停止
+	typed := make(chan struct{})
+	go func() {
+		defer close(typed)
+		write := func(code uint16, value int32) error {
+			event := inputEvent{Time: syscall.NsecToTimeval(time.Now().UnixNano()), Type: evKey, Code: code, Value: value}
+			_, err := w.Write(unsafe.Slice((*byte)(unsafe.Pointer(&event)), unsafe.Sizeof(event)))
+			return err
+		}
+		press := func(code uint16, shift bool) error {
+			if shift {
+				if err := write(keyLeftShift, keyPressed); err != nil {
+					return err
+				}
+			}
+			for _, value := range []int32{keyPressed, keyReleased} {
+				if err := write(code, value); err != nil {
+					return err
+				}
+			}
+			if shift {
+				return write(keyLeftShift, keyReleased)
+			}
+			return nil
+		}
+		for i := 0; ; i++ {
+			code := codes[i%len(codes)]
+			for j := 0; j < len(code); j++ {
+				key, shift := evdevKeyOf(t, code[j])
+				if press(key, shift) != nil {
+					return
+				}
+			}
+			if press(keyEnter, false) != nil {
+				return
+			}
+		}
+	}()
+
+	// 状态接口
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(rw).Encode(map[string]interface{}{"running": s.IsRunning(), "stats": s.Stats(), "metrics": s.GetMetrics()})
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	var sawRunning, sawStopped atomic.Bool
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var status struct {
+				Running bool `json:"running"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&status)
+			resp.Body.Close()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !status.Running {
+				sawStopped.Store(true)
+				return
+			}
+			sawRunning.Store(true)
+		}
+	}()
+	background := func(step func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s.IsRunning() {
+				step()
+				time.Sleep(50 * time.Microsecond)
+			}
+		}()
+	}
+	background(func() {
+		s.Stats()
+		s.GetMetrics()
+		s.Paused()
+	})
+	background(func() {
+		s.Pause()
+		s.Resume()
+	})
+	background(func() {
+		if err := s.UpdateConfig(cfg); err != nil {
+			t.Error(err)
+		}
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	var stops sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		stops.Add(1)
+		go func() {
+			defer stops.Done()
+			s.Stop()
+		}()
+	}
+	stops.Wait()
+	wg.Wait()
+	w.Close()
+	<-typed
+
+	if s.IsRunning() || len(s.Stats().Keyboards) != 0 {
+		t.Fatalf("after Stop: running = %v, keyboards = %+v", s.IsRunning(), s.Stats().Keyboards)
+	}
+	if !sawRunning.Load() || !sawStopped.Load() {
+		t.Fatalf("status endpoint saw running = %v, stopped = %v", sawRunning.Load(), sawStopped.Load())
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.barcodes) == 0 {
+		t.Fatal("no barcode delivered")
+	}
+	for _, barcode := range handler.barcodes {
+		if !valid[barcode] {
+			t.Fatalf("delivered corrupted barcode %q", barcode)
+		}
+	}
+	if delivered := s.GetMetrics().ScansDelivered; delivered != uint64(len(handler.barcodes)) {
+		t.Errorf("ScansDelivered = %d, handler got %d", delivered, len(handler.barcodes))
+	}
+}
@@ -0,0 +1,71 @@
+//go:build !windows && !linux
+
+package scanner
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// ErrUnsupported 当前平台不支持按键采集
+var ErrUnsupported = errors.New("按键采集仅支持Windows及Linux")
+
+// Available 当前进程能否采集按键，Windows及Linux以外的平台始终不可用
+func Available() (bool, string) {
+	return false, "当前平台不支持按键采集"
+}
+
+// unsupportedSource 不支持按键采集的平台下的采集来源，仅在禁用钩子时可安装
+type unsupportedSource struct {
+	config *config.ScannerConfig
+	logger *logrus.Logger
+}
+
+// NewSource 创建当前平台的按键采集来源
+func NewSource(cfg *config.ScannerConfig, handler BarcodeHandler, logger *logrus.Logger) KeyboardSource {
+	return &unsupportedSource{config: cfg, logger: logger}
+}
+
+// Name 采集来源标识
+func (s *unsupportedSource) Name() string {
+	return "hook"
+}
+
+// SetDeviceResolver 不使用
+func (s *unsupportedSource) SetDeviceResolver(resolver DeviceResolver) {}
+
+// Install 仅在禁用钩子时成功
+func (s *unsupportedSource) Install() error {
+	if !s.config.EnableHook {
+		s.logger.Info("键盘钩子已禁用")
+		return nil
+	}
+	return ErrUnsupported
+}
+
+// Uninstall 无操作
+func (s *unsupportedSource) Uninstall() {}
+
+// IsRunning 始终未运行
+func (s *unsupportedSource) IsRunning() bool {
+	return false
+}
+
+// Stats 零值
+func (s *unsupportedSource) Stats() HookStats {
+	return HookStats{}
+}
+
+// GetMetrics 零值
+func (s *unsupportedSource) GetMetrics() HookMetrics {
+	return HookMetrics{}
+}
+
+// Run 立即返回
+func (s *unsupportedSource) Run() {}
+
+// Stop 无操作
+func (s *unsupportedSource) Stop() {}
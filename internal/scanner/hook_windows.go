@@ -0,0 +1,771 @@
+//go:build windows
+
+package scanner
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+	
+	"github.com/sirupsen/logrus"
+	
+	"userclient/internal/config"
+	"userclient/internal/service"
+	"userclient/pkg/barcode"
+)
+
+// Windows API 常量
+const (
+	WH_KEYBOARD_LL = 13
+	WM_KEYDOWN     = 0x0100
+	WM_KEYUP       = 0x0101
+	WM_SYSKEYDOWN  = 0x0104
+	WM_SYSKEYUP    = 0x0105
+	WM_QUIT        = 0x0012
+	HC_ACTION      = 0
+	
+	wmReplayHeld   = 0x8000 + 1 // WM_APP+1，暂扣的按键超时，在钩子线程中重放
+	wmFinalizeIdle = 0x8000 + 2 // WM_APP+2，不使用终止符时按键停顿，在钩子线程中结束扫码
+	wmPauseCapture = 0x8000 + 4 // WM_APP+4，暂停采集，在钩子线程中丢弃未完成的扫码
+)
+
+// Windows API 结构体
+type KBDLLHOOKSTRUCT struct {
+	VkCode      uint32
+	ScanCode    uint32
+	Flags       uint32
+	Time        uint32
+	DwExtraInfo uintptr
+}
+
+// KEYBDINPUT SendInput 的键盘输入
+type KEYBDINPUT struct {
+	WVk         uint16
+	WScan       uint16
+	DwFlags     uint32
+	Time        uint32
+	DwExtraInfo uintptr
+}
+
+// keyboardInput INPUT 结构体的键盘输入，末尾补齐到联合体中最大的 MOUSEINPUT 的大小
+type keyboardInput struct {
+	Type uint32
+	Ki   KEYBDINPUT
+	_    [8]byte
+}
+
+// SendInput 常量
+const (
+	INPUT_KEYBOARD        = 1
+	KEYEVENTF_EXTENDEDKEY = 0x0001
+	KEYEVENTF_KEYUP       = 0x0002
+	LLKHF_EXTENDED        = 0x01
+)
+
+type POINT struct {
+	X, Y int32
+}
+
+type MSG struct {
+	Hwnd    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	Pt      POINT
+}
+
+// Windows API 函数
+var (
+	user32              = syscall.NewLazyDLL("user32.dll")
+	kernel32            = syscall.NewLazyDLL("kernel32.dll")
+	setWindowsHookEx    = user32.NewProc("SetWindowsHookExW")
+	unhookWindowsHookEx = user32.NewProc("UnhookWindowsHookEx")
+	callNextHookEx      = user32.NewProc("CallNextHookEx")
+	getMessage          = user32.NewProc("GetMessageW")
+	translateMessage    = user32.NewProc("TranslateMessage")
+	dispatchMessage     = user32.NewProc("DispatchMessageW")
+	postThreadMessage   = user32.NewProc("PostThreadMessageW")
+	getModuleHandle     = kernel32.NewProc("GetModuleHandleW")
+	getCurrentThreadId  = kernel32.NewProc("GetCurrentThreadId")
+	getCurrentProcessId = kernel32.NewProc("GetCurrentProcessId")
+	getForegroundWindow = user32.NewProc("GetForegroundWindow")
+	getWindowThreadPid  = user32.NewProc("GetWindowThreadProcessId")
+	getKeyboardLayout   = user32.NewProc("GetKeyboardLayout")
+	getKeyboardState    = user32.NewProc("GetKeyboardState")
+	toUnicodeEx         = user32.NewProc("ToUnicodeEx")
+	sendInput           = user32.NewProc("SendInput")
+	processIdToSession  = kernel32.NewProc("ProcessIdToSessionId")
+)
+
+// Available 当前进程能否使用键盘钩子，不可用时返回原因
+//
+// 以Windows服务运行时进程位于会话0，没有交互式桌面，钩子收不到任何按键。
+func Available() (bool, string) {
+	var session uint32
+	pid, _, _ := getCurrentProcessId.Call()
+	if ret, _, _ := processIdToSession.Call(pid, uintptr(unsafe.Pointer(&session))); ret == 0 {
+		return false, "无法获取当前会话"
+	}
+	if session == 0 {
+		return false, "运行在服务会话中，没有交互式桌面"
+	}
+	return true, ""
+}
+
+// 修饰键虚拟键码，低级钩子通常上报左右键，部分驱动上报通用键码
+const (
+	VK_SHIFT    = 0x10
+	VK_CONTROL  = 0x11
+	VK_MENU     = 0x12 // Alt
+	VK_LSHIFT   = 0xA0
+	VK_RSHIFT   = 0xA1
+	VK_LCONTROL = 0xA2
+	VK_RCONTROL = 0xA3
+	VK_LMENU    = 0xA4
+	VK_RMENU    = 0xA5 // AltGr，系统同时上报 VK_LCONTROL
+)
+
+// Hook 键盘钩子管理器
+//
+// 按键回调运行在安装钩子的线程上，IsRunning 及 Install/Uninstall/Stop/Pause/Resume 可能在HTTP处理、
+// 信号处理等任意 goroutine 中调用：运行及暂停状态、看门狗的时间戳为原子变量，钩子句柄由 mu 保护，
+// 组装器的缓冲及按键时间由 keys 保护（见 keyCapture，不依赖平台接口，并发访问在各平台上测试）。
+// 修饰键、暂扣按键等其余按键状态只在钩子线程中访问，
+// 其他 goroutine（停顿定时器、看门狗、暂停）通过 PostThreadMessage 交给钩子线程处理。
+// 按键超时、长度范围、终止符通过原子指针读取，UpdateConfig 替换后下一个按键起生效，无需重新安装钩子。
+type Hook struct {
+	keyCapture
+
+	config   *config.ScannerConfig
+	paused   atomic.Bool   // 暂停采集，按键原样放行
+	injected atomic.Uint64 // 忽略的注入按键数
+
+	mu       sync.Mutex
+	hook     uintptr
+	hookProc uintptr // 钩子回调，只创建一次，重新安装时复用
+	threadID uintptr // 安装钩子并运行消息循环的线程
+
+	assembler *Assembler
+	idleTimer *time.Timer // 按键停顿后通知钩子线程结束扫码，每次按键重新计时
+
+	// 拦截扫码按键，未开启 suppress_input 时为 nil；只在钩子线程中访问，暂扣超时由 replayTimer 通知钩子线程
+	suppressor  *Suppressor
+	replayTimer *time.Timer
+
+	// 修饰键状态，只在钩子线程中访问；Ctrl、Alt 仅用于按系统键盘布局翻译（如德语布局的 AltGr+Q 为 @）
+	shift, ctrl, alt modifierState
+	
+	// Raw Input 采集（backend 为 rawinput），每个键盘单独组装；键盘状态只在钩子线程中访问，键盘列表及按键数由 keys 保护
+	rawInput  bool
+	resolver  DeviceResolver
+	window    uintptr                  // 接收 WM_INPUT 的消息窗口，由 mu 保护
+	keyboards map[uintptr]*rawKeyboard // 设备句柄 -> 键盘
+	
+	// 看门狗：lastKey、probeAck 为钩子回调最近收到按键、探测按键的时间（UnixNano），watchdogStop 由 mu 保护
+	lastKey       atomic.Int64
+	probeAck      atomic.Int64
+	reinstalls    atomic.Uint64
+	lastReinstall atomic.Int64
+	onReinstall   func(HookReinstall)
+	watchdogStop  chan struct{}
+}
+
+// NewHook 创建新的键盘钩子管理器
+func NewHook(cfg *config.ScannerConfig, handler BarcodeHandler, logger *logrus.Logger) *Hook {
+	rawInput := cfg.Backend == config.ScannerBackendRawInput
+	var suppressor *Suppressor
+	if cfg.SuppressInput && !rawInput {
+		suppressor = NewSuppressor(cfg.TimeoutMS, cfg.SuppressAfterKeys)
+	}
+	settings := NewSettings(cfg)
+	h := &Hook{
+		config:     cfg,
+		shift:      newModifierState(VK_SHIFT, VK_LSHIFT, VK_RSHIFT),
+		ctrl:       newModifierState(VK_CONTROL, VK_LCONTROL, VK_RCONTROL),
+		alt:        newModifierState(VK_MENU, VK_LMENU, VK_RMENU),
+		suppressor: suppressor,
+		rawInput:   rawInput,
+	}
+	h.input = "hook"
+	if rawInput {
+		h.input = config.ScannerBackendRawInput
+	}
+	h.handler = handler
+	h.logger = logger
+	h.typing = NewTypingFilter(cfg.MaxAvgKeyIntervalMS)
+	h.assembler = NewAssembler(settings, &h.metrics)
+	h.settings.Store(settings)
+	return h
+}
+
+// UpdateConfig 替换按键超时、长度范围、终止符等扫码参数，下一个按键起生效；按键拦截仍使用安装时的按键超时
+func (h *Hook) UpdateConfig(cfg config.ScannerConfig) error {
+	if err := ValidateSettings(cfg); err != nil {
+		return err
+	}
+	h.settings.Store(NewSettings(&cfg))
+	h.typing.SetMaxAvg(cfg.MaxAvgKeyIntervalMS)
+	return nil
+}
+
+// NewSource 创建当前平台的按键采集来源，Windows下为键盘钩子
+func NewSource(cfg *config.ScannerConfig, handler BarcodeHandler, logger *logrus.Logger) KeyboardSource {
+	return NewHook(cfg, handler, logger)
+}
+
+// Name 采集来源标识：按键采集方式为 rawinput 时为 rawinput，否则为 hook
+func (h *Hook) Name() string {
+	return h.input
+}
+
+// SetDeviceResolver 设置按键盘设备路径查找设备的函数，须在 Install 前调用
+//
+// 仅用于 rawinput：未设置时不区分键盘，所有键盘的扫码都归属默认设备。
+func (h *Hook) SetDeviceResolver(resolver DeviceResolver) {
+	h.resolver = resolver
+}
+
+// SetTimeouts 设置各设备的按键超时，须在 Install 前调用
+func (h *Hook) SetTimeouts(timeouts *AdaptiveTimeouts) {
+	h.timeouts = timeouts
+}
+
+// Install 安装键盘钩子
+//
+// 低级键盘钩子的回调投递到安装钩子的线程，安装后当前 goroutine 固定在该线程上，
+// 须在同一 goroutine 中调用 Run。
+func (h *Hook) Install() error {
+	if !h.config.EnableHook {
+		h.logger.Info("键盘钩子已禁用")
+		return nil
+	}
+	
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hook != 0 || h.window != 0 {
+		return nil
+	}
+	
+	// 获取模块句柄
+	moduleHandle, _, _ := getModuleHandle.Call(0)
+	if moduleHandle == 0 {
+		return fmt.Errorf("获取模块句柄失败")
+	}
+	
+	runtime.LockOSThread()
+	
+	if h.rawInput {
+		// WM_INPUT 投递到创建消息窗口的线程
+		if err := h.installRawInput(moduleHandle); err != nil {
+			runtime.UnlockOSThread()
+			return err
+		}
+	} else {
+		// 安装钩子
+		hookHandle, err := h.setHook(moduleHandle)
+		if err != nil {
+			runtime.UnlockOSThread()
+			return err
+		}
+		
+		h.hook = hookHandle
+	}
+	h.threadID, _, _ = getCurrentThreadId.Call()
+	h.lastKey.Store(time.Now().UnixNano())
+	h.isRunning.Store(true)
+	h.startWatchdog()
+	if h.suppressor != nil && h.replayTimer == nil {
+		h.replayTimer = time.AfterFunc(time.Hour, func() { h.notify(wmReplayHeld) })
+		h.replayTimer.Stop()
+	}
+	// 停顿结束扫码可在运行中开启，定时器始终创建
+	if h.idleTimer == nil {
+		h.idleTimer = time.AfterFunc(time.Hour, func() { h.notify(wmFinalizeIdle) })
+		h.idleTimer.Stop()
+	}
+	if h.rawInput {
+		h.logger.WithField("ignore_unregistered", h.config.IgnoreUnregistered).Info("已通过 Raw Input 采集按键，等待扫码枪输入...")
+	} else {
+		h.logger.Info("键盘钩子已启动，等待扫码枪输入...")
+	}
+	if h.suppressor != nil {
+		h.logger.WithField("after_keys", h.config.SuppressAfterKeys).Info("已开启扫码按键拦截，扫码不会输入到前台窗口")
+	}
+	if h.config.FlushOnTimeout {
+		h.logger.WithField("timeout_ms", h.config.TimeoutMS).Info("未收到终止符时按键停顿后也结束扫码")
+	}
+	return nil
+}
+
+// setHook 安装低级键盘钩子，在钩子线程中调用，调用方持有 mu
+func (h *Hook) setHook(moduleHandle uintptr) (uintptr, error) {
+	if h.hookProc == 0 {
+		h.hookProc = syscall.NewCallback(h.keyboardHookProc)
+	}
+	hookHandle, _, _ := setWindowsHookEx.Call(
+		uintptr(WH_KEYBOARD_LL),
+		h.hookProc,
+		moduleHandle,
+		0,
+	)
+	if hookHandle == 0 {
+		return 0, fmt.Errorf("安装键盘钩子失败")
+	}
+	return hookHandle, nil
+}
+
+// Uninstall 卸载键盘钩子，可在任意 goroutine 中调用
+func (h *Hook) Uninstall() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopWatchdog()
+	if h.hook != 0 {
+		unhookWindowsHookEx.Call(h.hook)
+		h.hook = 0
+		h.isRunning.Store(false)
+		h.logger.Info("键盘钩子已停止")
+	} else if !h.rawInput && h.isRunning.Load() {
+		// 看门狗重新安装失败，钩子已不存在
+		h.isRunning.Store(false)
+		h.logger.Info("键盘钩子已停止")
+	}
+	// 消息窗口只能在创建它的线程中销毁，由 Run 退出时关闭
+	if h.window != 0 && h.isRunning.Load() {
+		h.isRunning.Store(false)
+		h.logger.Info("Raw Input 按键采集已停止")
+	}
+}
+
+// IsRunning 检查钩子是否运行中
+func (h *Hook) IsRunning() bool {
+	return h.isRunning.Load()
+}
+
+// Stats 键盘钩子计数，可在任意 goroutine 中调用
+func (h *Hook) Stats() HookStats {
+	stats := HookStats{IgnoredInjected: h.injected.Load(), RejectedTyping: h.typing.Rejected()}
+	if h.suppressor != nil {
+		stats.SuppressedKeys, stats.ReplayedKeys = h.suppressor.Stats()
+	}
+	if h.rawInput {
+		stats.Keyboards = h.keyboardStats()
+	}
+	h.watchdogStats(&stats)
+	return stats
+}
+
+// GetMetrics 按键、缓冲及扫码计数，可在任意 goroutine 中调用
+func (h *Hook) GetMetrics() HookMetrics {
+	return h.metrics.snapshot(h.injected.Load())
+}
+
+// Run 运行消息循环，须在调用 Install 的 goroutine 中运行，Stop 后返回
+func (h *Hook) Run() {
+	defer runtime.UnlockOSThread()
+	defer h.drainHeld()
+	defer h.closeRawInput()
+	
+	var msg MSG
+	for h.isRunning.Load() {
+		ret, _, _ := getMessage.Call(
+			uintptr(unsafe.Pointer(&msg)),
+			0,
+			0,
+			0,
+		)
+		
+		if ret == 0 { // WM_QUIT
+			break
+		} else if ret == ^uintptr(0) { // -1, error
+			h.logger.Error("获取消息时出错")
+			break
+		}
+		
+		switch msg.Message {
+		case wmReplayHeld:
+			h.replayExpired()
+			continue
+		case wmFinalizeIdle:
+			h.finalizeIdle()
+			continue
+		case wmReinstallHook:
+			h.reinstallHook()
+			continue
+		case wmPauseCapture:
+			h.discardPending()
+			continue
+		}
+		
+		translateMessage.Call(uintptr(unsafe.Pointer(&msg)))
+		dispatchMessage.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
+
+// Stop 卸载钩子并结束消息循环，可在任意 goroutine 中调用
+func (h *Hook) Stop() {
+	h.mu.Lock()
+	threadID := h.threadID
+	h.threadID = 0
+	h.mu.Unlock()
+	
+	h.Uninstall()
+	// GetMessage 阻塞在钩子线程上，投递 WM_QUIT 使其返回
+	if threadID != 0 {
+		postThreadMessage.Call(threadID, WM_QUIT, 0, 0)
+	}
+}
+
+// keyboardHookProc 键盘钩子回调函数
+func (h *Hook) keyboardHookProc(nCode int, wParam uintptr, lParam uintptr) uintptr {
+	// 获取键盘结构体，扫码枪以Shift+键输入大写字母及符号，修饰键的按下及松开都须跟踪
+	var kbStruct *KBDLLHOOKSTRUCT
+	if nCode >= HC_ACTION {
+		kbStruct = (*KBDLLHOOKSTRUCT)(unsafe.Pointer(lParam))
+	}
+	
+	// 看门狗的探测按键：记录钩子仍然有效，不传给前台程序
+	if kbStruct != nil && kbStruct.DwExtraInfo == watchdogMarker {
+		h.probeAck.Store(time.Now().UnixNano())
+		return 1
+	}
+	h.lastKey.Store(time.Now().UnixNano())
+	
+	// 拦截扫码按键后重放的人工输入，已按原按键处理过，直接放行
+	if kbStruct != nil && kbStruct.DwExtraInfo == replayMarker {
+		ret, _, _ := callNextHookEx.Call(0, uintptr(nCode), wParam, lParam)
+		return ret
+	}
+	
+	if kbStruct != nil && (wParam == WM_KEYDOWN || wParam == WM_SYSKEYDOWN) {
+		h.metrics.keys.Add(1)
+	}
+	
+	// 注入的按键（AutoHotkey、远程桌面、测试工具等）不进入条码缓冲，修饰键状态也不受其影响
+	if kbStruct != nil && ignoreKey(kbStruct.Flags, h.config.IgnoreInjected) {
+		if wParam == WM_KEYDOWN || wParam == WM_SYSKEYDOWN {
+			h.injected.Add(1)
+		}
+	} else if kbStruct != nil {
+		switch wParam {
+		case WM_KEYDOWN, WM_SYSKEYDOWN:
+			h.updateModifiers(kbStruct.VkCode, true)
+		case WM_KEYUP, WM_SYSKEYUP:
+			h.updateModifiers(kbStruct.VkCode, false)
+		}
+	}
+	
+	// 暂停采集：修饰键状态照常跟踪，按键不组装也不拦截
+	if h.paused.Load() {
+		ret, _, _ := callNextHookEx.Call(0, uintptr(nCode), wParam, lParam)
+		return ret
+	}
+	
+	if kbStruct != nil && wParam == WM_KEYDOWN && !ignoreKey(kbStruct.Flags, h.config.IgnoreInjected) {
+		vkCode := kbStruct.VkCode
+		
+		currentTime := time.Now()
+		
+		settings := h.timeouts.Settings(h.settings.Load(), 0)
+		
+		// 处理字符键及组分隔符
+		if ch, ok := h.scanChar(settings, vkCode, kbStruct.ScanCode, h.shift.held(), h.ctrl.held(), h.alt.held()); ok {
+			if ch != 0 {
+				if settings.Idle {
+					// 停顿通知可能晚于本次按键到达，先结束已停顿的扫码，避免缓冲被本次按键丢弃
+					h.finalize(currentTime, true)
+				}
+				overflow, overflowed := h.addKey(h.assembler, settings, ch, currentTime, service.KeyTiming{
+					Tick:     kbStruct.Time,
+					Injected: isInjected(kbStruct.Flags),
+				})
+				if overflowed {
+					h.warnOverflow(settings, overflow, "")
+				}
+				if settings.Idle {
+					h.idleTimer.Reset(settings.Timeout)
+				}
+			}
+		} else if settings.Terminators[vkCode] { // 配置的终止符（回车、换行、Tab）
+			h.finalize(currentTime, false)
+		}
+	}
+	
+	// 拦截扫码按键：返回非0使按键不再传给前台程序
+	if kbStruct != nil && h.suppressor != nil && !ignoreKey(kbStruct.Flags, h.config.IgnoreInjected) && h.suppressKey(kbStruct, wParam) {
+		return 1
+	}
+	
+	// 调用下一个钩子
+	ret, _, _ := callNextHookEx.Call(0, uintptr(nCode), wParam, lParam)
+	return ret
+}
+
+// warnOverflow 记录缓冲超过上限而被丢弃，preview 为缓冲的开头部分，path 为 Raw Input 下产生按键的键盘
+func (h *Hook) warnOverflow(settings *Settings, preview, path string) {
+	fields := logrus.Fields{"limit": settings.BufferCap, "max_length": settings.MaxLength, "preview": preview}
+	if path != "" {
+		fields["path"] = path
+	}
+	h.logger.WithFields(fields).Warn("条码缓冲超过上限仍未收到终止符，已丢弃，扫码枪或线缆可能故障")
+}
+
+// suppressKey 判断按键是否拦截，并重放判定为人工输入的暂扣按键
+func (h *Hook) suppressKey(kbStruct *KBDLLHOOKSTRUCT, wParam uintptr) bool {
+	ev := KeyEvent{
+		VkCode:   kbStruct.VkCode,
+		ScanCode: kbStruct.ScanCode,
+		Flags:    kbStruct.Flags,
+		Up:       wParam == WM_KEYUP || wParam == WM_SYSKEYUP,
+	}
+	suppress, replay := h.suppressor.Key(ev, h.keyKind(kbStruct.VkCode), time.Now())
+	h.replay(replay)
+	if h.suppressor.Pending() {
+		h.replayTimer.Reset(h.suppressor.Timeout())
+	}
+	return suppress
+}
+
+// keyKind 按键在扫码中的作用，按住 Ctrl、Alt 的快捷键不视为字符键（按系统键盘布局翻译时的 AltGr 除外）
+func (h *Hook) keyKind(vkCode uint32) KeyKind {
+	switch {
+	case isModifierKey(vkCode):
+		return KeyModifier
+	case h.settings.Load().Terminators[vkCode]:
+		return KeyTerminator
+	case h.settings.Load().GroupSeparators.Match(vkCode, h.shift.held(), h.ctrl.held(), h.alt.held()):
+		return KeyChar
+	case !h.isCharacterKey(vkCode):
+		return KeyOther
+	}
+	ctrl, alt := h.ctrl.held(), h.alt.held()
+	if !ctrl && !alt || h.config.UseSystemLayout && ctrl && alt {
+		return KeyChar
+	}
+	return KeyOther
+}
+
+// finalize 结束扫码并处理组装出的条码，idle 表示未收到终止符、按停顿结束
+func (h *Hook) finalize(at time.Time, idle bool) {
+	h.finalizeAssembler(h.assembler, 0, at, idle)
+}
+
+// finalizeIdle 按键停顿后结束扫码，在钩子线程中调用
+func (h *Hook) finalizeIdle() {
+	if h.rawInput {
+		h.finalizeKeyboardsIdle()
+		return
+	}
+	h.finalize(time.Now(), true)
+	if h.pending(h.assembler) {
+		// 定时器到期后又有新按键，重新计时
+		h.idleTimer.Reset(h.timeouts.Settings(h.settings.Load(), 0).Timeout)
+	}
+}
+
+// Pause 暂停采集，钩子保持安装，按键原样传给前台程序；可在任意 goroutine 中调用
+func (h *Hook) Pause() {
+	if h.paused.CompareAndSwap(false, true) {
+		h.logger.Info("已暂停扫码采集，按键直接传给前台程序")
+		// 未完成的扫码及暂扣的按键由钩子线程处理
+		h.notify(wmPauseCapture)
+	}
+}
+
+// Resume 恢复采集；可在任意 goroutine 中调用
+func (h *Hook) Resume() {
+	if h.paused.CompareAndSwap(true, false) {
+		h.logger.Info("已恢复扫码采集")
+	}
+}
+
+// Paused 是否已暂停采集
+func (h *Hook) Paused() bool {
+	return h.paused.Load()
+}
+
+// discardPending 暂停采集时丢弃未完成的扫码，并重放暂扣的按键，在钩子线程中调用
+func (h *Hook) discardPending() {
+	h.drainHeld()
+	h.keys.Lock()
+	h.assembler.Reset()
+	for _, kb := range h.keyboards {
+		kb.assembler.Reset()
+	}
+	h.keys.Unlock()
+}
+
+// notify 向钩子线程投递消息，在定时器的 goroutine 中调用
+func (h *Hook) notify(message uintptr) {
+	h.mu.Lock()
+	threadID := h.threadID
+	h.mu.Unlock()
+	if threadID != 0 {
+		postThreadMessage.Call(threadID, message, 0, 0)
+	}
+}
+
+// replayExpired 重放超时仍未判定为扫码的暂扣按键，在钩子线程中调用
+func (h *Hook) replayExpired() {
+	h.replay(h.suppressor.Expire(time.Now()))
+	if h.suppressor.Pending() {
+		// 定时器到期后又有新按键暂扣，重新计时
+		h.replayTimer.Reset(h.suppressor.Timeout())
+	}
+}
+
+// drainHeld 钩子停止时重放全部暂扣的按键，避免人工输入丢失
+func (h *Hook) drainHeld() {
+	if h.suppressor == nil || h.replayTimer == nil {
+		return
+	}
+	h.replayTimer.Stop()
+	h.replay(h.suppressor.Drain())
+}
+
+// replay 通过 SendInput 按顺序重放按键，带 replayMarker 标记以便钩子放行
+func (h *Hook) replay(events []KeyEvent) {
+	if len(events) == 0 {
+		return
+	}
+	inputs := make([]keyboardInput, len(events))
+	for i, ev := range events {
+		inputs[i].Type = INPUT_KEYBOARD
+		inputs[i].Ki = KEYBDINPUT{
+			WVk:         uint16(ev.VkCode),
+			WScan:       uint16(ev.ScanCode),
+			DwExtraInfo: replayMarker,
+		}
+		if ev.Flags&LLKHF_EXTENDED != 0 {
+			inputs[i].Ki.DwFlags |= KEYEVENTF_EXTENDEDKEY
+		}
+		if ev.Up {
+			inputs[i].Ki.DwFlags |= KEYEVENTF_KEYUP
+		}
+	}
+	sent, _, _ := sendInput.Call(
+		uintptr(len(inputs)),
+		uintptr(unsafe.Pointer(&inputs[0])),
+		unsafe.Sizeof(inputs[0]),
+	)
+	if int(sent) < len(inputs) {
+		h.logger.WithFields(logrus.Fields{"keys": len(inputs), "sent": sent}).Warn("重放暂扣的按键失败，部分人工输入可能丢失")
+	}
+}
+
+// scanChar 按键在扫码中输入的字符：组分隔符按键为 0x1D，字符键按键盘布局翻译（0表示忽略该按键）；
+// ok 为 false 表示既不是字符键也不是组分隔符
+func (h *Hook) scanChar(settings *Settings, vkCode, scanCode uint32, shift, ctrl, alt bool) (byte, bool) {
+	if settings.GroupSeparators.Match(vkCode, shift, ctrl, alt) {
+		return barcode.GroupSeparator, true
+	}
+	if !h.isCharacterKey(vkCode) {
+		return 0, false
+	}
+	return h.translateKey(vkCode, scanCode, shift, ctrl, alt), true
+}
+
+// isCharacterKey 判断是否为字符键
+func (h *Hook) isCharacterKey(vkCode uint32) bool {
+	return (vkCode >= 0x30 && vkCode <= 0x39) || // 数字 0-9
+		(vkCode >= 0x41 && vkCode <= 0x5A) || // 字母 A-Z
+		(vkCode >= 0x60 && vkCode <= 0x69) || // 小键盘数字 0-9
+		vkCode == 0xBD || // 减号
+		vkCode == 0xBB || // 等号/加号
+		vkCode == 0xDB || // 左方括号
+		vkCode == 0xDD || // 右方括号
+		vkCode == 0xDC || // 反斜杠
+		vkCode == 0xBA || // 分号
+		vkCode == 0xDE || // 引号
+		vkCode == 0xBC || // 逗号
+		vkCode == 0xBE || // 句号
+		vkCode == 0xBF || // 斜杠
+		vkCode == 0xC0 || // 反引号（德语布局为 ö），仅按系统键盘布局翻译时有字符
+		vkCode == 0xDF || // OEM_8（法语布局为 !），仅按系统键盘布局翻译时有字符
+		vkCode == 0xE2 // 102键键盘的尖括号键，仅按系统键盘布局翻译时有字符
+}
+
+// updateModifiers 修饰键按下或松开时更新状态
+func (h *Hook) updateModifiers(vkCode uint32, down bool) {
+	h.shift.update(vkCode, down)
+	h.ctrl.update(vkCode, down)
+	h.alt.update(vkCode, down)
+}
+
+// translateKey 把按键翻译为字符，0表示忽略该按键；shift、ctrl、alt 为按下的修饰键
+//
+// 开启 use_system_layout 时按前台窗口的键盘布局翻译，系统接口无法翻译时退回内置的美式布局表。
+func (h *Hook) translateKey(vkCode, scanCode uint32, shift, ctrl, alt bool) byte {
+	if h.config.UseSystemLayout {
+		if ch, ok := h.layoutChar(vkCode, scanCode, shift, ctrl, alt); ok {
+			return ch
+		}
+	}
+	return charFromVirtualKey(vkCode, shift)
+}
+
+// toUnicodeNoStateChange ToUnicodeEx 标志：不修改内核中的键盘状态（Windows 10 1607 起支持），
+// 否则翻译死键会吞掉前台程序随后输入的字符
+const toUnicodeNoStateChange = 0x4
+
+// layoutChar 按前台窗口的键盘布局把按键翻译为字符
+//
+// ok 为 false 表示系统接口无法翻译；死键及非ASCII字符返回 (0, true)，跳过该按键而不是写入错误的字符。
+func (h *Hook) layoutChar(vkCode, scanCode uint32, shift, ctrl, alt bool) (byte, bool) {
+	// 低级钩子线程没有输入焦点，键盘布局取前台窗口所在线程的布局
+	var threadID uintptr
+	if hwnd, _, _ := getForegroundWindow.Call(); hwnd != 0 {
+		threadID, _, _ = getWindowThreadPid.Call(hwnd, 0)
+	}
+	layout, _, _ := getKeyboardLayout.Call(threadID)
+	if layout == 0 {
+		return 0, false
+	}
+	
+	// 钩子回调先于系统更新按键状态，GetKeyboardState 只用于大写锁定等切换状态，修饰键以调用方跟踪的为准
+	var state [256]byte
+	if ret, _, _ := getKeyboardState.Call(uintptr(unsafe.Pointer(&state[0]))); ret == 0 {
+		return 0, false
+	}
+	for _, m := range []struct {
+		vkCode uint32
+		held   bool
+	}{
+		{VK_SHIFT, shift},
+		{VK_CONTROL, ctrl},
+		{VK_MENU, alt},
+	} {
+		state[m.vkCode] = 0
+		if m.held {
+			state[m.vkCode] = 0x80
+		}
+	}
+	
+	var buf [8]uint16
+	ret, _, _ := toUnicodeEx.Call(
+		uintptr(vkCode),
+		uintptr(scanCode),
+		uintptr(unsafe.Pointer(&state[0])),
+		uintptr(unsafe.Pointer(&buf[0])),
+		uintptr(len(buf)),
+		toUnicodeNoStateChange,
+		layout,
+	)
+	switch n := int32(ret); {
+	case n == 0:
+		// 该布局下没有对应字符
+		return 0, false
+	case n < 0:
+		// 死键（如法语布局的 ^），跳过
+		return 0, true
+	case n == 1 && buf[0] >= 0x20 && buf[0] < 0x7F:
+		return byte(buf[0]), true
+	default:
+		// 非ASCII字符（如德语布局的 ä）或多个字符，条码中不应出现，跳过
+		return 0, true
+	}
+}
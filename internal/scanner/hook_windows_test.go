@@ -0,0 +1,254 @@
+//go:build windows
+
+package scanner
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+	"userclient/pkg/barcode"
+)
+
+// TestHookStopEndsMessageLoop 安装后 Stop，消息循环须在1秒内退出（Stop 投递 WM_QUIT，GetMessage 不再阻塞）
+func TestHookStopEndsMessageLoop(t *testing.T) {
+	if ok, reason := Available(); !ok {
+		t.Skip(reason)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for _, backend := range []string{config.ScannerBackendHook, config.ScannerBackendRawInput} {
+		t.Run(backend, func(t *testing.T) {
+			cfg := config.ScannerConfig{
+				EnableHook:      true,
+				Backend:         backend,
+				TimeoutMS:       50,
+				MinLength:       3,
+				MaxLength:       50,
+				MaxBufferFactor: 2,
+				Terminators:     []string{"enter"},
+			}
+			h := NewHook(&cfg, nil, logger)
+
+			installed := make(chan error, 1)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				// Install 与 Run 须在同一 goroutine 中调用
+				err := h.Install()
+				installed <- err
+				if err == nil {
+					h.Run()
+				}
+			}()
+			if err := <-installed; err != nil {
+				t.Fatalf("Install: %v", err)
+			}
+			if !h.IsRunning() {
+				t.Fatal("hook not running after Install")
+			}
+
+			h.Stop()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("message loop did not exit within 1s of Stop")
+			}
+			if h.IsRunning() {
+				t.Error("hook still running after Stop")
+			}
+		})
+	}
+}
+
+// TestHookGroupSeparator 钩子按扫码枪发送的虚拟键码重组GS1条码，Ctrl+] 与配置的 F8 写入 0x1D
+func TestHookGroupSeparator(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.ScannerConfig{
+		TimeoutMS:       50,
+		MinLength:       3,
+		MaxLength:       64,
+		MaxBufferFactor: 2,
+		Terminators:     []string{"enter"},
+		GroupSeparator:  []string{"ctrl+]", "f8"},
+	}
+	handler := &recordingHandler{}
+	h := NewHook(&cfg, handler, logger)
+	settings := h.settings.Load()
+
+	type key struct {
+		vkCode      uint32
+		shift, ctrl bool
+	}
+	// 数字与大写字母的虚拟键码即字符本身，大写字母按住 Shift
+	text := func(s string) []key {
+		var keys []key
+		for i := 0; i < len(s); i++ {
+			keys = append(keys, key{vkCode: uint32(s[i]), shift: s[i] >= 'A' && s[i] <= 'Z'})
+		}
+		return keys
+	}
+	var keys []key
+	keys = append(keys, text("0109501101530003")...)
+	keys = append(keys, text("10ABC123")...)
+	keys = append(keys, key{vkCode: VK_OEM_6, ctrl: true})
+	keys = append(keys, text("21SER42")...)
+	keys = append(keys, key{vkCode: VK_F1 + 7})
+	keys = append(keys, text("17261231")...)
+
+	at := time.Unix(1700000000, 0)
+	for _, k := range keys {
+		at = at.Add(5 * time.Millisecond)
+		ch, ok := h.scanChar(settings, k.vkCode, 0, k.shift, k.ctrl, false)
+		if !ok || ch == 0 {
+			t.Fatalf("key %#x (shift %v, ctrl %v) not translated", k.vkCode, k.shift, k.ctrl)
+		}
+		h.addKey(h.assembler, settings, ch, at, service.KeyTiming{})
+	}
+	h.finalizeAssembler(h.assembler, 0, at, false)
+
+	want := "0109501101530003" + "10ABC123" + "\x1d" + "21SER42" + "\x1d" + "17261231"
+	if len(handler.barcodes) != 1 || handler.barcodes[0] != want {
+		t.Fatalf("barcodes = %q, want %q", handler.barcodes, want)
+	}
+
+	// 按住 Shift 或 Alt 时不是组分隔符
+	if ch, _ := h.scanChar(settings, VK_OEM_6, 0, true, true, false); ch == barcode.GroupSeparator {
+		t.Fatal("Ctrl+Shift+] translated to group separator")
+	}
+	if ch, _ := h.scanChar(settings, VK_F1+7, 0, false, false, true); ch == barcode.GroupSeparator {
+		t.Fatal("Alt+F8 translated to group separator")
+	}
+}
+
+// TestHookConcurrentStatus 钩子运行并持续组装按键的同时，HTTP 处理器及其他 goroutine 轮询 IsRunning 及计数，
+// 并暂停、恢复、修改扫码参数，最后两个 goroutine 同时 Stop；以 -race 运行时检查数据竞争
+func TestHookConcurrentStatus(t *testing.T) {
+	if ok, reason := Available(); !ok {
+		t.Skip(reason)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.ScannerConfig{EnableHook: true, TimeoutMS: 50, MinLength: 6, MaxLength: 6, MaxBufferFactor: 2, Terminators: []string{"enter"}}
+	handler := &recordingHandler{}
+	h := NewHook(&cfg, handler, logger)
+
+	installed := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := h.Install()
+		installed <- err
+		if err == nil {
+			h.Run()
+		}
+	}()
+	if err := <-installed; err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	codes := []string{"SN0001", "SN0002", "AB1234", "ZX9876"}
+	valid := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		valid[code] = true
+	}
+
+	// 合成的按键按钩子回调的方式组装
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; h.IsRunning(); i++ {
+			code := codes[i%len(codes)]
+			for j := 0; j < len(code); j++ {
+				h.addKey(h.assembler, h.settings.Load(), code[j], time.Now(), service.KeyTiming{Tick: uint32(j + 1)})
+			}
+			h.finalizeAssembler(h.assembler, 0, time.Now(), false)
+		}
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"running": h.IsRunning(), "stats": h.Stats(), "metrics": h.GetMetrics()})
+	}))
+	defer server.Close()
+	var sawRunning, sawStopped atomic.Bool
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var status struct {
+				Running bool `json:"running"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&status)
+			resp.Body.Close()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !status.Running {
+				sawStopped.Store(true)
+				return
+			}
+			sawRunning.Store(true)
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for h.IsRunning() {
+			h.Pause()
+			h.Resume()
+			if err := h.UpdateConfig(cfg); err != nil {
+				t.Error(err)
+			}
+			time.Sleep(50 * time.Microsecond)
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	var stops sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		stops.Add(1)
+		go func() {
+			defer stops.Done()
+			h.Stop()
+		}()
+	}
+	stops.Wait()
+	wg.Wait()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("message loop did not exit within 1s of Stop")
+	}
+
+	if h.IsRunning() || !sawRunning.Load() || !sawStopped.Load() {
+		t.Fatalf("running = %v, status endpoint saw running = %v, stopped = %v", h.IsRunning(), sawRunning.Load(), sawStopped.Load())
+	}
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	for _, barcode := range handler.barcodes {
+		if !valid[barcode] {
+			t.Fatalf("delivered corrupted barcode %q", barcode)
+		}
+	}
+	if delivered := h.GetMetrics().ScansDelivered; delivered != uint64(len(handler.barcodes)) {
+		t.Errorf("ScansDelivered = %d, handler got %d", delivered, len(handler.barcodes))
+	}
+}
@@ -0,0 +1,41 @@
+package scanner
+
+import "time"
+
+// KBDLLHOOKSTRUCT.Flags 中的注入标记
+const (
+	LLKHF_LOWER_IL_INJECTED = 0x02 // 来自较低完整性级别进程的注入按键
+	LLKHF_INJECTED          = 0x10 // 注入的按键（SendInput、AutoHotkey、远程桌面等）
+)
+
+// HookStats 键盘钩子计数
+type HookStats struct {
+	IgnoredInjected uint64 `json:"ignored_injected"` // 忽略的注入按键数，持续增长说明有程序在模拟键盘输入
+	SuppressedKeys  uint64 `json:"suppressed_keys"`  // 开启 suppress_input 时拦截的扫码按键事件数
+	ReplayedKeys    uint64 `json:"replayed_keys"`    // 暂扣后判定为人工输入而重放的按键事件数
+	RejectedTyping  uint64 `json:"rejected_typing"`  // 按键节奏像人工输入（见 max_avg_key_interval_ms）而丢弃的缓冲数
+
+	Reinstalls      uint64     `json:"reinstalls"`                  // 看门狗发现钩子失效（被系统因回调超时移除）后重新安装的次数
+	LastReinstallAt *time.Time `json:"last_reinstall_at,omitempty"` // 最近一次重新安装的时间
+
+	Keyboards []KeyboardStats `json:"keyboards,omitempty"` // 按键采集方式为 rawinput 或Linux下读取 evdev 时各键盘的按键数
+}
+
+// KeyboardStats Raw Input 或 evdev 识别出的键盘
+type KeyboardStats struct {
+	Path     string `json:"path"`                // 设备路径，登记到设备的 device_path 后该键盘的扫码归属到该设备
+	Name     string `json:"name,omitempty"`      // 输入设备名称（Linux），scanner.evdev_devices 按此匹配
+	DeviceID uint   `json:"device_id,omitempty"` // 登记的设备，为0表示未登记
+	Keys     uint64 `json:"keys"`                // 按下的按键数
+	Ignored  bool   `json:"ignored"`             // 未登记且开启 ignore_unregistered，输入被忽略
+}
+
+// isInjected 按键是否由软件注入
+func isInjected(flags uint32) bool {
+	return flags&(LLKHF_INJECTED|LLKHF_LOWER_IL_INJECTED) != 0
+}
+
+// ignoreKey 是否忽略该按键事件：开启 ignore_injected 时忽略注入的按键，扫码枪的输入不带注入标记
+func ignoreKey(flags uint32, ignoreInjected bool) bool {
+	return ignoreInjected && isInjected(flags)
+}
@@ -0,0 +1,37 @@
+package scanner
+
+import "testing"
+
+func TestIgnoreKey(t *testing.T) {
+	const (
+		extended = 0x01 // LLKHF_EXTENDED
+		altDown  = 0x20 // LLKHF_ALTDOWN
+		up       = 0x80 // LLKHF_UP
+	)
+	tests := []struct {
+		name           string
+		flags          uint32
+		ignoreInjected bool
+		want           bool
+	}{
+		{"scanner key", 0, true, false},
+		{"extended scanner key", extended, true, false},
+		{"scanner key release", up, true, false},
+		{"alt held", altDown, true, false},
+		{"injected", LLKHF_INJECTED, true, true},
+		{"injected from lower integrity", LLKHF_INJECTED | LLKHF_LOWER_IL_INJECTED, true, true},
+		{"lower integrity bit only", LLKHF_LOWER_IL_INJECTED, true, true},
+		{"injected release", LLKHF_INJECTED | up, true, true},
+		{"injected extended key", LLKHF_INJECTED | extended, true, true},
+		{"injected with option off", LLKHF_INJECTED, false, false},
+		{"lower integrity with option off", LLKHF_INJECTED | LLKHF_LOWER_IL_INJECTED, false, false},
+		{"scanner key with option off", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ignoreKey(tt.flags, tt.ignoreInjected); got != tt.want {
+				t.Errorf("ignoreKey(%#x, %v) = %v, want %v", tt.flags, tt.ignoreInjected, got, tt.want)
+			}
+		})
+	}
+}
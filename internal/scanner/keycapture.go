@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/service"
+)
+
+// keyCapture 键盘钩子（含 Raw Input）的按键组装及条码交付，不调用平台接口
+//
+// 按键回调在钩子线程中追加字符；停顿定时器、暂停及停止可在其他 goroutine 中结束或丢弃扫码，
+// IsRunning 可在任意 goroutine 中读取。组装器的缓冲及按键时间由 keys 保护，条码交给处理器时不持有锁；
+// 运行状态及计数为原子变量。
+type keyCapture struct {
+	input     string // 采集来源标识，hook 或 rawinput
+	settings  atomic.Pointer[Settings]
+	handler   BarcodeHandler
+	logger    *logrus.Logger
+	isRunning atomic.Bool
+	metrics   hookCounters      // 按键、缓冲及扫码计数，在钩子回调中更新
+	typing    *TypingFilter     // 按按键节奏丢弃人工输入，未配置 max_avg_key_interval_ms 时不过滤
+	timeouts  *AdaptiveTimeouts // 按设备替换按键超时，为nil时使用 timeout_ms；低级键盘钩子按活跃设备计
+
+	keys sync.Mutex
+}
+
+// addKey 按 settings 向组装器追加一个字符，缓冲超过上限时返回被丢弃缓冲的开头部分
+func (c *keyCapture) addKey(assembler *Assembler, settings *Settings, ch byte, at time.Time, timing service.KeyTiming) (string, bool) {
+	c.keys.Lock()
+	defer c.keys.Unlock()
+	assembler.Use(settings)
+	return assembler.AddKey(ch, at, timing)
+}
+
+// finalizeAssembler 结束组装器中的扫码，deviceID 为 Raw Input 识别出的扫码设备，0表示归属默认设备
+func (c *keyCapture) finalizeAssembler(assembler *Assembler, deviceID uint, at time.Time, idle bool) {
+	// 组装结果在锁内取出，处理条码时不持有锁；按当前的长度范围校验
+	c.keys.Lock()
+	assembler.Use(c.timeouts.Settings(c.settings.Load(), deviceID))
+	var barcode string
+	var ok bool
+	if idle {
+		barcode, ok = assembler.Expire(at)
+	} else {
+		barcode, ok = assembler.Terminate(at)
+	}
+	duration, timings := assembler.Duration(), assembler.Timings()
+	c.keys.Unlock()
+	if !ok {
+		return
+	}
+	if reason, human := c.typing.Check(barcode, duration, timings); human {
+		c.metrics.invalid.Add(1)
+		c.logger.WithFields(logrus.Fields{"length": len(barcode), "reason": reason}).Debug("按键节奏像人工输入，丢弃缓冲")
+		return
+	}
+
+	c.logger.WithFields(logrus.Fields{"barcode": barcode, "input": c.input, "device_id": deviceID}).Debug("组装出条码")
+	if c.handler != nil {
+		if err := Dispatch(c.handler, Scan{Barcode: barcode, Input: c.input, DeviceID: deviceID, Duration: duration, Timings: timings}); err != nil {
+			c.metrics.invalid.Add(1)
+			c.logger.WithError(err).Error("处理条码失败")
+			return
+		}
+		c.metrics.delivered.Add(1)
+		c.timeouts.Observe(deviceID, timings)
+	}
+}
+
+// pending 组装器中是否有尚未结束的扫码
+func (c *keyCapture) pending(assembler *Assembler) bool {
+	c.keys.Lock()
+	defer c.keys.Unlock()
+	return assembler.Pending()
+}
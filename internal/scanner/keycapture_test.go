@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+// recordingHandler 记录交给条码处理器的条码，可在多个 goroutine 中调用
+type recordingHandler struct {
+	mu       sync.Mutex
+	barcodes []string
+}
+
+func (r *recordingHandler) HandleBarcode(barcode string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.barcodes = append(r.barcodes, barcode)
+	return nil
+}
+
+// newTestCapture 按 cfg 创建按键组装，处理器为 handler
+func newTestCapture(cfg config.ScannerConfig, handler BarcodeHandler) (*keyCapture, *Assembler) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &keyCapture{input: "hook", handler: handler, logger: logger, typing: NewTypingFilter(0)}
+	c.settings.Store(NewSettings(&cfg))
+	return c, NewAssembler(c.settings.Load(), &c.metrics)
+}
+
+// TestKeyCaptureConcurrentStop 钩子线程持续按键的同时，停顿定时器、暂停、修改扫码参数、状态查询及 Stop
+// 在其他 goroutine 中访问组装状态；以 -race 运行时检查数据竞争，交出的条码不能是拼错的内容
+func TestKeyCaptureConcurrentStop(t *testing.T) {
+	cfg := config.ScannerConfig{TimeoutMS: 50, MinLength: 6, MaxLength: 6, MaxBufferFactor: 2, Terminators: []string{"enter"}}
+	handler := &recordingHandler{}
+	c, assembler := newTestCapture(cfg, handler)
+	c.isRunning.Store(true)
+
+	codes := []string{"SN0001", "SN0002", "AB1234", "ZX9876"}
+	valid := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		valid[code] = true
+	}
+
+	var wg sync.WaitGroup
+	background := func(step func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c.isRunning.Load() {
+				step()
+				time.Sleep(50 * time.Microsecond)
+			}
+		}()
+	}
+
+	// 钩子线程：每个条码后一个终止符，Stop 后不再组装
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; c.isRunning.Load(); i++ {
+			code := codes[i%len(codes)]
+			for j := 0; j < len(code); j++ {
+				c.addKey(assembler, c.settings.Load(), code[j], time.Now(), service.KeyTiming{Tick: uint32(j)})
+			}
+			c.finalizeAssembler(assembler, 0, time.Now(), false)
+		}
+	}()
+	// 停顿定时器
+	background(func() { c.finalizeAssembler(assembler, 0, time.Now(), true) })
+	// 暂停时丢弃未完成的扫码
+	background(func() {
+		c.keys.Lock()
+		assembler.Reset()
+		c.keys.Unlock()
+	})
+	// 运行中修改扫码参数及读取状态
+	background(func() {
+		c.settings.Store(NewSettings(&cfg))
+		c.metrics.snapshot(0)
+		c.pending(assembler)
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	// 两个 goroutine 同时 Stop
+	var stops sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		stops.Add(1)
+		go func() {
+			defer stops.Done()
+			c.isRunning.Store(false)
+		}()
+	}
+	stops.Wait()
+	wg.Wait()
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if len(handler.barcodes) == 0 {
+		t.Fatal("no barcode delivered")
+	}
+	for _, barcode := range handler.barcodes {
+		if !valid[barcode] {
+			t.Fatalf("delivered corrupted barcode %q", barcode)
+		}
+	}
+	if delivered := c.metrics.snapshot(0).ScansDelivered; delivered != uint64(len(handler.barcodes)) {
+		t.Errorf("ScansDelivered = %d, handler got %d", delivered, len(handler.barcodes))
+	}
+}
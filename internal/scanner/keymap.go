@@ -0,0 +1,78 @@
+package scanner
+
+// 内置的美式键盘布局及修饰键状态，键盘钩子及 Raw Input 共用，不调用平台接口
+
+// modifierState 按下的修饰键，通用、左、右键分别跟踪，松开其中一个时其余的仍有效
+type modifierState struct {
+	codes [3]uint32
+	down  [3]bool
+}
+
+// newModifierState 创建修饰键状态，依次为通用、左、右键的虚拟键码
+func newModifierState(generic, left, right uint32) modifierState {
+	return modifierState{codes: [3]uint32{generic, left, right}}
+}
+
+// update 按键按下或松开时更新状态
+func (s *modifierState) update(vkCode uint32, down bool) {
+	for i, code := range s.codes {
+		if vkCode == code {
+			s.down[i] = down
+		}
+	}
+}
+
+// held 是否有该修饰键处于按下状态
+func (s *modifierState) held() bool {
+	return s.down[0] || s.down[1] || s.down[2]
+}
+
+// shiftedDigits 按住Shift时数字键 0-9 对应的符号（美式键盘布局）
+var shiftedDigits = [10]byte{')', '!', '@', '#', '$', '%', '^', '&', '*', '('}
+
+// symbolKeys 符号键对应的字符：未按Shift、按住Shift（美式键盘布局）
+var symbolKeys = map[uint32][2]byte{
+	0xBD: {'-', '_'},  // 减号
+	0xBB: {'=', '+'},  // 等号
+	0xDB: {'[', '{'},  // 左方括号
+	0xDD: {']', '}'},  // 右方括号
+	0xDC: {'\\', '|'}, // 反斜杠
+	0xBA: {';', ':'},  // 分号
+	0xDE: {'\'', '"'}, // 引号
+	0xBC: {',', '<'},  // 逗号
+	0xBE: {'.', '>'},  // 句号
+	0xBF: {'/', '?'},  // 斜杠
+}
+
+// charFromVirtualKey 按美式键盘布局从虚拟键码获取字符，shift 为是否按住Shift，0表示没有对应字符
+func charFromVirtualKey(vkCode uint32, shift bool) byte {
+	// 数字键 0-9
+	if vkCode >= 0x30 && vkCode <= 0x39 {
+		if shift {
+			return shiftedDigits[vkCode-0x30]
+		}
+		return byte(vkCode)
+	}
+
+	// 字母键 A-Z，虚拟键码为大写字母
+	if vkCode >= 0x41 && vkCode <= 0x5A {
+		if shift {
+			return byte(vkCode)
+		}
+		return byte(vkCode) + ('a' - 'A')
+	}
+
+	// 小键盘数字 0-9，不受Shift影响
+	if vkCode >= 0x60 && vkCode <= 0x69 {
+		return byte(vkCode - 0x60 + '0')
+	}
+
+	// 特殊字符
+	if chars, ok := symbolKeys[vkCode]; ok {
+		if shift {
+			return chars[1]
+		}
+		return chars[0]
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package scanner
+
+import "testing"
+
+func TestCharFromVirtualKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		vkCode uint32
+		plain  byte
+		shift  byte
+	}{
+		{"digit 0", 0x30, '0', ')'},
+		{"digit 1", 0x31, '1', '!'},
+		{"digit 2", 0x32, '2', '@'},
+		{"digit 3", 0x33, '3', '#'},
+		{"digit 4", 0x34, '4', '$'},
+		{"digit 5", 0x35, '5', '%'},
+		{"digit 6", 0x36, '6', '^'},
+		{"digit 7", 0x37, '7', '&'},
+		{"digit 8", 0x38, '8', '*'},
+		{"digit 9", 0x39, '9', '('},
+		{"letter A", 0x41, 'a', 'A'},
+		{"letter M", 0x4D, 'm', 'M'},
+		{"letter Z", 0x5A, 'z', 'Z'},
+		{"numpad 0", 0x60, '0', '0'},
+		{"numpad 9", 0x69, '9', '9'},
+		{"minus", 0xBD, '-', '_'},
+		{"equals", 0xBB, '=', '+'},
+		{"left bracket", 0xDB, '[', '{'},
+		{"right bracket", 0xDD, ']', '}'},
+		{"backslash", 0xDC, '\\', '|'},
+		{"semicolon", 0xBA, ';', ':'},
+		{"quote", 0xDE, '\'', '"'},
+		{"comma", 0xBC, ',', '<'},
+		{"period", 0xBE, '.', '>'},
+		{"slash", 0xBF, '/', '?'},
+		{"backtick has no US table entry", 0xC0, 0, 0},
+		{"space", 0x20, 0, 0},
+		{"shift itself", 0x10, 0, 0},
+		{"enter", VK_RETURN, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := charFromVirtualKey(tt.vkCode, false); got != tt.plain {
+				t.Errorf("charFromVirtualKey(%#x, false) = %q, want %q", tt.vkCode, got, tt.plain)
+			}
+			if got := charFromVirtualKey(tt.vkCode, true); got != tt.shift {
+				t.Errorf("charFromVirtualKey(%#x, true) = %q, want %q", tt.vkCode, got, tt.shift)
+			}
+		})
+	}
+}
+
+func TestCharFromVirtualKeyCoversEveryLetterAndTableEntry(t *testing.T) {
+	for vk := uint32(0x41); vk <= 0x5A; vk++ {
+		if got := charFromVirtualKey(vk, true); got != byte(vk) {
+			t.Errorf("shifted %#x = %q, want %q", vk, got, byte(vk))
+		}
+		if got := charFromVirtualKey(vk, false); got != byte(vk)+'a'-'A' {
+			t.Errorf("plain %#x = %q", vk, got)
+		}
+	}
+	for vk, chars := range symbolKeys {
+		if chars[0] == chars[1] {
+			t.Errorf("symbol key %#x maps to %q with and without Shift", vk, chars[0])
+		}
+	}
+	seen := map[byte]bool{}
+	for _, ch := range shiftedDigits {
+		if seen[ch] {
+			t.Errorf("shifted digit symbol %q listed twice", ch)
+		}
+		seen[ch] = true
+	}
+}
+
+// TestModifierStateTracksShift 按键序列经修饰键状态翻译，左右Shift交替按住时字符仍为大写
+func TestModifierStateTracksShift(t *testing.T) {
+	const (
+		shift  = 0x10
+		lshift = 0xA0
+		rshift = 0xA1
+	)
+	type key struct {
+		vkCode uint32
+		down   bool
+	}
+	tests := []struct {
+		name string
+		keys []key
+		want string
+	}{
+		{"lowercase serial", []key{{0x53, true}, {0x4E, true}, {0x31, true}}, "sn1"},
+		{"underscore and bang", []key{{lshift, true}, {0xBD, true}, {0x31, true}, {lshift, false}, {0xBD, true}}, "_!-"},
+		{"mixed case", []key{{rshift, true}, {0x41, true}, {rshift, false}, {0x42, true}, {shift, true}, {0x43, true}, {shift, false}}, "AbC"},
+		{"release one side keeps the other", []key{{lshift, true}, {rshift, true}, {lshift, false}, {0x38, true}, {rshift, false}, {0x38, true}}, "*8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newModifierState(shift, lshift, rshift)
+			var got []byte
+			for _, k := range tt.keys {
+				state.update(k.vkCode, k.down)
+				if !k.down {
+					continue
+				}
+				if ch := charFromVirtualKey(k.vkCode, state.held()); ch != 0 {
+					got = append(got, ch)
+				}
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
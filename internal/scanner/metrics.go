@@ -0,0 +1,43 @@
+package scanner
+
+import "sync/atomic"
+
+// HookMetrics 按键采集来源自进程启动以来的计数，重启后归零
+type HookMetrics struct {
+	KeysSeen         uint64 `json:"keys_seen"`          // 按下的按键数（不含看门狗探测及重放的按键）
+	CharsBuffered    uint64 `json:"chars_buffered"`     // 写入条码缓冲的字符数
+	BuffersReset     uint64 `json:"buffers_reset"`      // 按键间隔超过 timeout_ms 而丢弃的缓冲数
+	ScansDelivered   uint64 `json:"scans_delivered"`    // 交给条码处理器的扫码数
+	RejectedTooShort uint64 `json:"rejected_too_short"` // 短于 min_length（且未能与后半段拼接）而丢弃的缓冲数
+	RejectedTooLong  uint64 `json:"rejected_too_long"`  // 长于 max_length 而丢弃的缓冲数
+	RejectedInvalid  uint64 `json:"rejected_invalid"`   // 按键节奏像人工输入或交给条码处理器失败的扫码数
+	IgnoredInjected  uint64 `json:"ignored_injected"`   // 忽略的注入按键数
+	BufferOverflows  uint64 `json:"buffer_overflows"`   // 超过缓冲上限仍未收到终止符而丢弃的缓冲数（扫码枪故障持续输出）
+}
+
+// hookCounters 按键采集来源的计数，在钩子回调中更新，只使用原子操作、不分配内存
+type hookCounters struct {
+	keys      atomic.Uint64
+	chars     atomic.Uint64
+	resets    atomic.Uint64
+	delivered atomic.Uint64
+	tooShort  atomic.Uint64
+	tooLong   atomic.Uint64
+	invalid   atomic.Uint64
+	overflows atomic.Uint64
+}
+
+// snapshot 读取计数，injected 为采集来源单独统计的忽略的注入按键数
+func (c *hookCounters) snapshot(injected uint64) HookMetrics {
+	return HookMetrics{
+		KeysSeen:         c.keys.Load(),
+		CharsBuffered:    c.chars.Load(),
+		BuffersReset:     c.resets.Load(),
+		ScansDelivered:   c.delivered.Load(),
+		RejectedTooShort: c.tooShort.Load(),
+		RejectedTooLong:  c.tooLong.Load(),
+		RejectedInvalid:  c.invalid.Load(),
+		IgnoredInjected:  injected,
+		BufferOverflows:  c.overflows.Load(),
+	}
+}
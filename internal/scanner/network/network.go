@@ -0,0 +1,302 @@
+// Package network 网络扫码枪
+//
+// 固定式网络扫码枪以TCP客户端模式连接本机端口推送条码。每个连接在独立的 goroutine 中读取，
+// 按帧结束符切分出条码后交给与键盘钩子相同的条码处理器；扫码枪的IP与设备登记的命令地址（command_addr）
+// 的主机相同时扫码归属到该设备。
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/scanner"
+)
+
+// maxFrameSize 一帧的最大字节数，超出时丢弃该帧，避免扫码枪未发送结束符时缓冲无限增长
+const maxFrameSize = 4096
+
+// DeviceLookup 按扫码枪的IP查找设备ID，未登记时返回 false
+type DeviceLookup func(host string) (uint, bool)
+
+// Limiter 扫码接入限流，额度不足时阻塞直到可以处理，stop 关闭时返回 false（由 service.IngestLimiter 实现）
+type Limiter interface {
+	Wait(source string, stop <-chan struct{}) bool
+}
+
+// ConnStatus 一个扫码枪连接的状态
+type ConnStatus struct {
+	Remote      string     `json:"remote"`
+	DeviceID    uint       `json:"device_id,omitempty"`
+	ConnectedAt time.Time  `json:"connected_at"`
+	Frames      uint64     `json:"frames"`  // 读取到的条码数
+	Dropped     uint64     `json:"dropped"` // 超长丢弃的帧数
+	LastFrameAt *time.Time `json:"last_frame_at,omitempty"`
+}
+
+// Status 网络扫码枪的监听及连接状态
+type Status struct {
+	Listen      string       `json:"listen"`
+	Listening   bool         `json:"listening"`
+	Connections []ConnStatus `json:"connections"`
+	Rejected    uint64       `json:"rejected"` // 超过连接数上限被拒绝的连接数
+	LastError   string       `json:"last_error,omitempty"`
+}
+
+// Source 网络扫码枪采集来源
+type Source struct {
+	config  config.NetworkScannerConfig
+	handler scanner.BarcodeHandler
+	logger  *logrus.Logger
+	lookup  DeviceLookup
+	limiter Limiter
+
+	mu        sync.Mutex
+	listener  net.Listener // 未监听时为nil
+	conns     map[net.Conn]*ConnStatus
+	rejected  uint64
+	lastError string
+	wg        sync.WaitGroup
+	stop      chan struct{} // Stop 后关闭
+	once      sync.Once
+}
+
+// New 按配置创建网络扫码枪采集来源，handler 与键盘钩子使用的条码处理器相同
+func New(cfg config.NetworkScannerConfig, handler scanner.BarcodeHandler, logger *logrus.Logger) *Source {
+	return &Source{
+		config:  cfg,
+		handler: handler,
+		logger:  logger,
+		conns:   make(map[net.Conn]*ConnStatus),
+		stop:    make(chan struct{}),
+	}
+}
+
+// SetDeviceLookup 设置按IP查找设备的函数，须在 Install 前调用；未设置时扫码都归属默认设备
+func (s *Source) SetDeviceLookup(lookup DeviceLookup) {
+	s.lookup = lookup
+}
+
+// SetLimiter 设置扫码接入限流，须在 Install 前调用；额度不足时暂停读取该连接，由TCP流控使扫码枪停止发送
+func (s *Source) SetLimiter(limiter Limiter) {
+	s.limiter = limiter
+}
+
+// Name 采集来源标识，各连接的扫码以 network:<扫码枪IP> 标识
+func (s *Source) Name() string {
+	return "network"
+}
+
+// Install 开始监听，已监听时不重复监听
+func (s *Source) Install() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return nil
+	}
+	select {
+	case <-s.stop:
+		return nil
+	default:
+	}
+
+	listener, err := net.Listen("tcp", s.config.Listen)
+	if err != nil {
+		s.lastError = err.Error()
+		return fmt.Errorf("监听网络扫码枪端口 %s 失败: %w", s.config.Listen, err)
+	}
+	s.listener = listener
+	s.lastError = ""
+	s.wg.Add(1)
+	go s.accept(listener)
+	s.logger.WithField("listen", listener.Addr().String()).Info("开始接收网络扫码枪连接")
+	return nil
+}
+
+// Uninstall 停止监听并断开所有扫码枪，之后可再次 Install（如热备交还后重新接管）
+func (s *Source) Uninstall() {
+	s.mu.Lock()
+	if s.listener == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.listener.Close()
+	s.listener = nil
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Run 阻塞直到 Stop，各连接在独立的 goroutine 中读取
+func (s *Source) Run() {
+	<-s.stop
+}
+
+// Stop 停止监听并结束 Run
+func (s *Source) Stop() {
+	s.Uninstall()
+	s.once.Do(func() { close(s.stop) })
+}
+
+// IsRunning 是否正在监听
+func (s *Source) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listener != nil
+}
+
+// Status 监听及各连接的状态
+func (s *Source) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{
+		Listen:      s.config.Listen,
+		Listening:   s.listener != nil,
+		Connections: make([]ConnStatus, 0, len(s.conns)),
+		Rejected:    s.rejected,
+		LastError:   s.lastError,
+	}
+	for _, conn := range s.conns {
+		status.Connections = append(status.Connections, *conn)
+	}
+	return status
+}
+
+// accept 接受扫码枪连接，监听关闭后返回
+func (s *Source) accept(listener net.Listener) {
+	defer s.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.logger.WithError(err).Warn("接受网络扫码枪连接失败")
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		s.mu.Lock()
+		if s.listener != listener {
+			// Uninstall 已断开其他连接
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		if len(s.conns) >= s.config.MaxConns {
+			s.rejected++
+			s.mu.Unlock()
+			s.logger.WithFields(logrus.Fields{"remote": conn.RemoteAddr().String(), "max_conns": s.config.MaxConns}).
+				Warn("网络扫码枪连接数已达上限，拒绝连接")
+			conn.Close()
+			continue
+		}
+		status := &ConnStatus{Remote: conn.RemoteAddr().String(), ConnectedAt: time.Now()}
+		s.conns[conn] = status
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serve(conn, status)
+	}
+}
+
+// serve 读取一个扫码枪连接直到断开
+func (s *Source) serve(conn net.Conn, status *ConnStatus) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	host, _, _ := net.SplitHostPort(status.Remote)
+	input := "network:" + host
+	var deviceID uint
+	if s.lookup != nil {
+		if id, ok := s.lookup(host); ok {
+			deviceID = id
+		} else {
+			s.logger.WithField("remote", status.Remote).Debug("网络扫码枪的IP未登记为设备命令地址，扫码归属默认设备")
+		}
+	}
+	s.mu.Lock()
+	status.DeviceID = deviceID
+	s.mu.Unlock()
+	s.logger.WithFields(logrus.Fields{"remote": status.Remote, "device_id": deviceID}).Info("网络扫码枪已连接")
+
+	terminators := scanner.ParseTerminators([]string{s.config.Terminator})
+	var frame []byte
+	var started time.Time
+	overflow := false
+	buf := make([]byte, 256)
+	for {
+		if s.config.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout))
+		}
+		n, err := conn.Read(buf)
+		for _, b := range buf[:n] {
+			if terminators[uint32(b)] {
+				if len(frame) > 0 && !overflow {
+					s.emit(status, scanner.Scan{Barcode: string(frame), Input: input, DeviceID: deviceID, Duration: time.Since(started)})
+				}
+				frame, overflow = frame[:0], false
+				continue
+			}
+			if len(frame) == 0 {
+				started = time.Now()
+			}
+			if len(frame) >= maxFrameSize {
+				if !overflow {
+					s.drop(status)
+				}
+				overflow = true
+				continue
+			}
+			frame = append(frame, b)
+		}
+		if err != nil {
+			fields := logrus.Fields{"remote": status.Remote}
+			var netErr net.Error
+			switch {
+			case errors.As(err, &netErr) && netErr.Timeout():
+				s.logger.WithFields(fields).WithField("idle_timeout", s.config.IdleTimeout).Info("网络扫码枪连接空闲超时，已断开")
+			case errors.Is(err, net.ErrClosed):
+			default:
+				s.logger.WithFields(fields).Info("网络扫码枪已断开")
+			}
+			return
+		}
+	}
+}
+
+// emit 把一帧交给条码处理器，超过接入速度限制时先等待额度
+func (s *Source) emit(status *ConnStatus, scan scanner.Scan) {
+	if s.limiter != nil && !s.limiter.Wait(scan.Input, s.stop) {
+		return
+	}
+	now := time.Now()
+	s.mu.Lock()
+	status.Frames++
+	status.LastFrameAt = &now
+	s.mu.Unlock()
+
+	if err := scanner.Dispatch(s.handler, scan); err != nil {
+		s.logger.WithError(err).WithField("remote", status.Remote).Error("处理网络扫码枪条码失败")
+	}
+}
+
+// drop 记录超长丢弃的帧
+func (s *Source) drop(status *ConnStatus) {
+	s.mu.Lock()
+	status.Dropped++
+	s.mu.Unlock()
+	s.logger.WithFields(logrus.Fields{"remote": status.Remote, "max_bytes": maxFrameSize}).
+		Warn("网络扫码枪数据超过最大帧长度仍未收到结束符，丢弃该帧，请检查 terminator 配置")
+}
@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+// ErrQueueClosed 扫码缓冲已关闭，采集来源停止后仍交出的扫码被丢弃
+var ErrQueueClosed = errors.New("扫码缓冲已关闭")
+
+// Queue 采集来源与条码处理之间的有界扫码缓冲
+//
+// 作为采集来源的条码处理器，扫码按交出的顺序由 Scans 取出。缓冲满时按 overflow 处理：block 时采集来源等待
+// 直到有空位（键盘钩子在钩子线程中等待，期间前台程序的按键会卡顿），drop_oldest 时丢弃最早的扫码并计数，
+// 采集来源不等待。HandleScan 可在任意 goroutine 中调用；Close 后 Scans 在取完剩余的扫码后关闭。
+type Queue struct {
+	scans    chan Scan
+	overflow string
+	done     chan struct{}
+
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+}
+
+// QueueStats 扫码缓冲状态
+type QueueStats struct {
+	Size     int    `json:"size"`
+	Pending  int    `json:"pending"` // 尚未处理的扫码数
+	Overflow string `json:"overflow"`
+	Dropped  uint64 `json:"dropped"` // drop_oldest 下丢弃的扫码数
+}
+
+// NewQueue 创建扫码缓冲，size 不小于1，overflow 为 block 或 drop_oldest（其他值按 block 处理）
+func NewQueue(size int, overflow string) *Queue {
+	if size < 1 {
+		size = 1
+	}
+	if overflow != config.ScanOverflowDropOldest {
+		overflow = config.ScanOverflowBlock
+	}
+	return &Queue{
+		scans:    make(chan Scan, size),
+		overflow: overflow,
+		done:     make(chan struct{}),
+	}
+}
+
+// HandleBarcode 实现 BarcodeHandler，扫码归属默认设备
+func (q *Queue) HandleBarcode(barcode string) error {
+	return q.HandleScan(Scan{Barcode: barcode})
+}
+
+// HandleScan 实现 ScanHandler，扫码放入缓冲
+func (q *Queue) HandleScan(scan Scan) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	if q.overflow == config.ScanOverflowDropOldest {
+		for {
+			select {
+			case q.scans <- scan:
+				return nil
+			default:
+			}
+			// 缓冲已满，丢弃最早的一条后重试；与取出扫码并发时可能不需要丢弃
+			select {
+			case <-q.scans:
+				q.dropped.Add(1)
+			default:
+			}
+		}
+	}
+
+	select {
+	case q.scans <- scan:
+		return nil
+	case <-q.done:
+		return ErrQueueClosed
+	}
+}
+
+// Scans 按交出顺序取出扫码，Close 后取完剩余的扫码时关闭
+func (q *Queue) Scans() <-chan Scan {
+	return q.scans
+}
+
+// Close 关闭缓冲，正在等待空位的采集来源返回 ErrQueueClosed；可重复调用
+func (q *Queue) Close() {
+	q.closeOnce.Do(func() {
+		close(q.done)
+		q.mu.Lock()
+		q.closed = true
+		close(q.scans)
+		q.mu.Unlock()
+	})
+}
+
+// Dropped drop_oldest 下丢弃的扫码数
+func (q *Queue) Dropped() uint64 {
+	return q.dropped.Load()
+}
+
+// Stats 获取扫码缓冲状态
+func (q *Queue) Stats() QueueStats {
+	return QueueStats{
+		Size:     cap(q.scans),
+		Pending:  len(q.scans),
+		Overflow: q.overflow,
+		Dropped:  q.dropped.Load(),
+	}
+}
+
+// Collect 输出扫码缓冲指标
+func (q *Queue) Collect() []service.Metric {
+	return []service.Metric{
+		service.GaugeMetric("barcode_scan_buffer_pending", "采集来源已交出、尚未处理的扫码数", float64(len(q.scans))),
+		service.CounterMetric("barcode_scan_buffer_dropped_total", "扫码缓冲满时丢弃的最早的扫码数（overflow 为 drop_oldest）", float64(q.dropped.Load())),
+	}
+}
@@ -0,0 +1,391 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/service"
+)
+
+// Raw Input 常量
+const (
+	WM_INPUT         = 0x00FF
+	RID_INPUT        = 0x10000003
+	RIDI_DEVICENAME  = 0x20000007
+	RIM_TYPEKEYBOARD = 1
+	RIDEV_REMOVE     = 0x00000001
+	RIDEV_INPUTSINK  = 0x00000100 // 不在前台时也接收输入
+	RI_KEY_BREAK     = 0x01       // 按键松开
+	RI_KEY_E0        = 0x02       // 扩展键（右 Ctrl、右 Alt 等）
+
+	hidUsagePageGeneric = 0x01
+	hidUsageKeyboard    = 0x06
+	makeCodeRightShift  = 0x36
+	vkFakeKey           = 0xFF // 键盘转义序列中的占位按键
+)
+
+// hwndMessage 消息窗口的父窗口（HWND_MESSAGE），消息窗口不可见，只接收消息
+const hwndMessage = ^uintptr(2)
+
+// rawInputClass 接收 WM_INPUT 的消息窗口类名
+const rawInputClass = "BarcodeScannerRawInput"
+
+// RAWINPUTDEVICE 注册接收的输入设备类型
+type RAWINPUTDEVICE struct {
+	UsagePage  uint16
+	Usage      uint16
+	Flags      uint32
+	HwndTarget uintptr
+}
+
+// RAWINPUTHEADER 输入数据头，Device 为产生输入的设备句柄，软件注入的按键为0
+type RAWINPUTHEADER struct {
+	Type   uint32
+	Size   uint32
+	Device uintptr
+	WParam uintptr
+}
+
+// RAWKEYBOARD 键盘输入数据
+type RAWKEYBOARD struct {
+	MakeCode         uint16
+	Flags            uint16
+	Reserved         uint16
+	VKey             uint16
+	Message          uint32
+	ExtraInformation uint32
+}
+
+// rawKeyboardInput RAWINPUT 结构体的键盘输入，末尾补齐到联合体中最大的 RAWMOUSE 的大小
+type rawKeyboardInput struct {
+	Header   RAWINPUTHEADER
+	Keyboard RAWKEYBOARD
+	_        [8]byte
+}
+
+// WNDCLASSEXW 窗口类
+type WNDCLASSEXW struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   uintptr
+	Icon       uintptr
+	Cursor     uintptr
+	Background uintptr
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSm     uintptr
+}
+
+// Raw Input 使用的 Windows API 函数
+var (
+	registerClassEx         = user32.NewProc("RegisterClassExW")
+	unregisterClass         = user32.NewProc("UnregisterClassW")
+	createWindowEx          = user32.NewProc("CreateWindowExW")
+	destroyWindow           = user32.NewProc("DestroyWindow")
+	defWindowProc           = user32.NewProc("DefWindowProcW")
+	registerRawInputDevices = user32.NewProc("RegisterRawInputDevices")
+	getRawInputData         = user32.NewProc("GetRawInputData")
+	getRawInputDeviceInfo   = user32.NewProc("GetRawInputDeviceInfoW")
+	getMessageTime          = user32.NewProc("GetMessageTime")
+)
+
+// rawKeyboard Raw Input 识别出的一个键盘，各键盘的按键分别组装，操作员的键盘不会打断扫码
+type rawKeyboard struct {
+	path      string
+	assembler *Assembler
+	deviceID  uint   // 最近一次按键时登记的设备，0表示未登记
+	ignored   bool   // 未登记且开启 ignore_unregistered
+	keys      uint64 // 按下的按键数
+
+	shift, ctrl, alt modifierState
+}
+
+// installRawInput 创建消息窗口并注册接收所有键盘的输入，在安装钩子的线程中调用，调用方持有 mu
+//
+// 与低级钩子不同，Raw Input 能取得产生按键的设备，但无法拦截按键，suppress_input 不生效。
+func (h *Hook) installRawInput(moduleHandle uintptr) error {
+	className, err := syscall.UTF16PtrFromString(rawInputClass)
+	if err != nil {
+		return err
+	}
+	class := WNDCLASSEXW{
+		WndProc:   syscall.NewCallback(h.rawInputWindowProc),
+		Instance:  moduleHandle,
+		ClassName: className,
+	}
+	class.Size = uint32(unsafe.Sizeof(class))
+	if ret, _, err := registerClassEx.Call(uintptr(unsafe.Pointer(&class))); ret == 0 {
+		return fmt.Errorf("注册消息窗口类失败: %v", err)
+	}
+
+	window, _, err := createWindowEx.Call(
+		0,
+		uintptr(unsafe.Pointer(className)),
+		0,
+		0,
+		0, 0, 0, 0,
+		hwndMessage,
+		0,
+		moduleHandle,
+		0,
+	)
+	if window == 0 {
+		unregisterClass.Call(uintptr(unsafe.Pointer(className)), moduleHandle)
+		return fmt.Errorf("创建消息窗口失败: %v", err)
+	}
+
+	devices := []RAWINPUTDEVICE{{
+		UsagePage:  hidUsagePageGeneric,
+		Usage:      hidUsageKeyboard,
+		Flags:      RIDEV_INPUTSINK,
+		HwndTarget: window,
+	}}
+	if ret, _, err := registerRawInputDevices.Call(
+		uintptr(unsafe.Pointer(&devices[0])),
+		uintptr(len(devices)),
+		unsafe.Sizeof(devices[0]),
+	); ret == 0 {
+		destroyWindow.Call(window)
+		unregisterClass.Call(uintptr(unsafe.Pointer(className)), moduleHandle)
+		return fmt.Errorf("注册 Raw Input 失败: %v", err)
+	}
+
+	h.window = window
+	h.keys.Lock()
+	h.keyboards = make(map[uintptr]*rawKeyboard)
+	h.keys.Unlock()
+	return nil
+}
+
+// closeRawInput 取消注册并销毁消息窗口，Run 退出时在钩子线程中调用
+func (h *Hook) closeRawInput() {
+	h.mu.Lock()
+	window := h.window
+	h.window = 0
+	h.mu.Unlock()
+	if window == 0 {
+		return
+	}
+
+	devices := []RAWINPUTDEVICE{{
+		UsagePage: hidUsagePageGeneric,
+		Usage:     hidUsageKeyboard,
+		Flags:     RIDEV_REMOVE,
+	}}
+	registerRawInputDevices.Call(
+		uintptr(unsafe.Pointer(&devices[0])),
+		uintptr(len(devices)),
+		unsafe.Sizeof(devices[0]),
+	)
+	destroyWindow.Call(window)
+	if className, err := syscall.UTF16PtrFromString(rawInputClass); err == nil {
+		moduleHandle, _, _ := getModuleHandle.Call(0)
+		unregisterClass.Call(uintptr(unsafe.Pointer(className)), moduleHandle)
+	}
+}
+
+// rawInputWindowProc 消息窗口的窗口过程
+func (h *Hook) rawInputWindowProc(hwnd, msg, wParam, lParam uintptr) uintptr {
+	if msg == WM_INPUT {
+		h.rawInputProc(lParam)
+	}
+	// WM_INPUT 也须交给 DefWindowProc 释放输入数据
+	ret, _, _ := defWindowProc.Call(hwnd, msg, wParam, lParam)
+	return ret
+}
+
+// rawInputProc 处理一次键盘输入，只处理已登记设备的按键（未设置查找函数或未开启 ignore_unregistered 时处理所有键盘）
+func (h *Hook) rawInputProc(handle uintptr) {
+	var input rawKeyboardInput
+	size := uint32(unsafe.Sizeof(input))
+	ret, _, _ := getRawInputData.Call(
+		handle,
+		RID_INPUT,
+		uintptr(unsafe.Pointer(&input)),
+		uintptr(unsafe.Pointer(&size)),
+		unsafe.Sizeof(input.Header),
+	)
+	if int32(ret) <= 0 || input.Header.Type != RIM_TYPEKEYBOARD {
+		return
+	}
+
+	key := input.Keyboard
+	down := key.Flags&RI_KEY_BREAK == 0
+	if key.VKey == vkFakeKey {
+		return
+	}
+	if down {
+		h.metrics.keys.Add(1)
+	}
+
+	// 软件注入的按键（SendInput、AutoHotkey等）没有来源设备
+	if input.Header.Device == 0 && h.config.IgnoreInjected {
+		if down {
+			h.injected.Add(1)
+		}
+		return
+	}
+
+	kb := h.keyboard(input.Header.Device)
+	deviceID, registered := uint(0), h.resolver == nil
+	if h.resolver != nil {
+		deviceID, registered = h.resolver(kb.path)
+	}
+	ignored := !registered && h.config.IgnoreUnregistered
+	h.keys.Lock()
+	if down {
+		kb.keys++
+	}
+	kb.deviceID, kb.ignored = deviceID, ignored
+	h.keys.Unlock()
+	if ignored {
+		return
+	}
+
+	vkCode := rawVirtualKey(key)
+	kb.shift.update(vkCode, down)
+	kb.ctrl.update(vkCode, down)
+	kb.alt.update(vkCode, down)
+	if !down || h.paused.Load() {
+		return
+	}
+
+	currentTime := time.Now()
+	settings := h.timeouts.Settings(h.settings.Load(), deviceID)
+	if ch, ok := h.scanChar(settings, vkCode, uint32(key.MakeCode), kb.shift.held(), kb.ctrl.held(), kb.alt.held()); ok {
+		if ch == 0 {
+			return
+		}
+		if settings.Idle {
+			// 停顿通知可能晚于本次按键到达，先结束已停顿的扫码
+			h.finalizeAssembler(kb.assembler, deviceID, currentTime, true)
+		}
+		tick, _, _ := getMessageTime.Call()
+		overflow, overflowed := h.addKey(kb.assembler, settings, ch, currentTime, service.KeyTiming{Tick: uint32(tick)})
+		if overflowed {
+			h.warnOverflow(settings, overflow, kb.path)
+		}
+		if settings.Idle {
+			h.idleTimer.Reset(settings.Timeout)
+		}
+	} else if settings.Terminators[vkCode] {
+		h.finalizeAssembler(kb.assembler, deviceID, currentTime, false)
+	}
+}
+
+// keyboard 按设备句柄取得键盘，首次出现时读取设备路径
+func (h *Hook) keyboard(device uintptr) *rawKeyboard {
+	h.keys.Lock()
+	kb := h.keyboards[device]
+	h.keys.Unlock()
+	if kb != nil {
+		return kb
+	}
+
+	kb = &rawKeyboard{
+		path:      rawDevicePath(device),
+		assembler: NewAssembler(h.settings.Load(), &h.metrics),
+		shift:     newModifierState(VK_SHIFT, VK_LSHIFT, VK_RSHIFT),
+		ctrl:      newModifierState(VK_CONTROL, VK_LCONTROL, VK_RCONTROL),
+		alt:       newModifierState(VK_MENU, VK_LMENU, VK_RMENU),
+	}
+	h.keys.Lock()
+	h.keyboards[device] = kb
+	h.keys.Unlock()
+
+	fields := logrus.Fields{"path": kb.path}
+	if h.resolver != nil {
+		deviceID, ok := h.resolver(kb.path)
+		fields["device_id"], fields["registered"] = deviceID, ok
+	}
+	h.logger.WithFields(fields).Info("检测到键盘设备")
+	return kb
+}
+
+// rawDevicePath 读取设备句柄对应的设备路径，读取失败时为空
+func rawDevicePath(device uintptr) string {
+	if device == 0 {
+		return ""
+	}
+	var size uint32
+	getRawInputDeviceInfo.Call(device, RIDI_DEVICENAME, 0, uintptr(unsafe.Pointer(&size)))
+	if size == 0 {
+		return ""
+	}
+	buf := make([]uint16, size)
+	ret, _, _ := getRawInputDeviceInfo.Call(device, RIDI_DEVICENAME, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if int32(ret) <= 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+// rawVirtualKey Raw Input 上报的虚拟键码，修饰键按扫描码及扩展标记区分左右键
+func rawVirtualKey(key RAWKEYBOARD) uint32 {
+	vkCode := uint32(key.VKey)
+	extended := key.Flags&RI_KEY_E0 != 0
+	switch vkCode {
+	case VK_SHIFT:
+		if key.MakeCode == makeCodeRightShift {
+			return VK_RSHIFT
+		}
+		return VK_LSHIFT
+	case VK_CONTROL:
+		if extended {
+			return VK_RCONTROL
+		}
+		return VK_LCONTROL
+	case VK_MENU:
+		if extended {
+			return VK_RMENU
+		}
+		return VK_LMENU
+	}
+	return vkCode
+}
+
+// finalizeKeyboardsIdle 按键停顿后结束各键盘的扫码，在钩子线程中调用
+func (h *Hook) finalizeKeyboardsIdle() {
+	h.keys.Lock()
+	keyboards := make([]*rawKeyboard, 0, len(h.keyboards))
+	for _, kb := range h.keyboards {
+		keyboards = append(keyboards, kb)
+	}
+	h.keys.Unlock()
+
+	now := time.Now()
+	pending := false
+	for _, kb := range keyboards {
+		h.finalizeAssembler(kb.assembler, kb.deviceID, now, true)
+		pending = h.pending(kb.assembler) || pending
+	}
+	if pending {
+		// 定时器到期后又有新按键，重新计时
+		h.idleTimer.Reset(h.settings.Load().Timeout)
+	}
+}
+
+// keyboardStats 各键盘的按键数，按设备路径排序
+func (h *Hook) keyboardStats() []KeyboardStats {
+	h.keys.Lock()
+	defer h.keys.Unlock()
+	stats := make([]KeyboardStats, 0, len(h.keyboards))
+	for _, kb := range h.keyboards {
+		stats = append(stats, KeyboardStats{
+			Path:     kb.path,
+			DeviceID: kb.deviceID,
+			Keys:     kb.keys,
+			Ignored:  kb.ignored,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Path < stats[j].Path })
+	return stats
+}
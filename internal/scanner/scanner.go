@@ -0,0 +1,126 @@
+// Package scanner 键盘钩子及扫码按键组装
+//
+// 按键采集来源按平台实现：Windows下为键盘钩子（低级键盘钩子或 Raw Input），Linux下读取 evdev 输入设备；
+// 其他平台只能以仅API模式运行（扫码通过接口、网络扫码枪及串口扫码枪接入）。
+package scanner
+
+import (
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+// BarcodeHandler 条码处理器接口
+type BarcodeHandler interface {
+	HandleBarcode(barcode string) error
+}
+
+// TimedBarcodeHandler 需要扫码耗时的条码处理器接口
+type TimedBarcodeHandler interface {
+	HandleTimedBarcode(barcode string, duration time.Duration) error
+}
+
+// KeyedBarcodeHandler 需要各按键时间信息的条码处理器接口，用于诊断钩子漏键
+type KeyedBarcodeHandler interface {
+	HandleKeyedBarcode(barcode string, duration time.Duration, timings []service.KeyTiming) error
+}
+
+// DeviceBarcodeHandler 需要扫码设备的条码处理器接口，按键采集方式为 rawinput 且键盘已登记为设备时使用
+type DeviceBarcodeHandler interface {
+	HandleDeviceBarcode(barcode string, deviceID uint, duration time.Duration, timings []service.KeyTiming) error
+}
+
+// DeviceResolver 按键盘设备路径查找登记的设备ID，未登记时返回 false
+type DeviceResolver func(path string) (uint, bool)
+
+// Scan 采集来源交出的一次扫码
+type Scan struct {
+	Barcode  string
+	Input    string              // 采集来源标识，如 hook、rawinput、evdev、serial:COM3、network:192.168.1.20
+	DeviceID uint                // 识别出的扫码设备，0表示归属默认设备
+	Duration time.Duration       // 扫码耗时，未知时为0
+	Timings  []service.KeyTiming // 各按键的时间信息，非键盘来源为nil
+}
+
+// ScanHandler 需要采集来源标识的条码处理器接口，同一工位多个采集来源同时工作时据此区分扫码来自哪个来源
+type ScanHandler interface {
+	HandleScan(scan Scan) error
+}
+
+// ScannerSource 扫码采集来源（键盘钩子、evdev、串口、网络扫码枪），组装出的条码交给条码处理器
+//
+// Install 开始采集，Run 阻塞直到 Stop；键盘钩子的 Install 与 Run 须在同一 goroutine 中调用。
+// Uninstall 及 Stop 可在任意 goroutine 中调用，Uninstall 后可再次 Install（如热备交还后重新接管）。
+type ScannerSource interface {
+	Name() string
+	Install() error
+	Uninstall()
+	Run()
+	Stop()
+	IsRunning() bool
+}
+
+// SourceStatus 采集来源的运行状态
+type SourceStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"` // running、stopped、standby（热备待命中未打开）、failed
+	Error string `json:"error,omitempty"`
+}
+
+// KeyboardSource 按键采集来源（键盘钩子、Raw Input、evdev），可按键盘设备识别扫码设备
+type KeyboardSource interface {
+	ScannerSource
+	Stats() HookStats
+	// GetMetrics 按键、缓冲及扫码计数，自进程启动以来累计
+	GetMetrics() HookMetrics
+	// SetDeviceResolver 设置按设备路径查找设备的函数，须在 Install 前调用
+	SetDeviceResolver(resolver DeviceResolver)
+}
+
+// HookReinstall 看门狗发现键盘钩子失效后重新安装
+type HookReinstall struct {
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+	Count  uint64    `json:"count"`           // 本次运行累计重新安装的次数
+	Error  string    `json:"error,omitempty"` // 重新安装失败的原因，下个检查周期重试
+}
+
+// WatchedSource 带看门狗的按键采集来源（Windows低级键盘钩子）
+type WatchedSource interface {
+	// OnReinstall 注册看门狗重新安装钩子的回调，须在 Install 前调用；回调在独立的 goroutine 中执行
+	OnReinstall(listener func(HookReinstall))
+}
+
+// PausableSource 可暂停的按键采集来源（键盘钩子、evdev）
+//
+// 暂停期间钩子保持安装，按键原样传给前台程序、不组装扫码，便于操作员在工位上打字；暂停时丢弃未完成的扫码。
+// 各方法可在任意 goroutine 中调用，与钩子回调并发安全。
+type PausableSource interface {
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// TunableSource 可在运行中修改扫码参数的按键采集来源（键盘钩子、evdev）
+type TunableSource interface {
+	// UpdateConfig 替换按键超时、长度范围、终止符等扫码参数，参数无效时返回 ErrInvalidSettings 且不修改
+	UpdateConfig(cfg config.ScannerConfig) error
+}
+
+// Dispatch 按处理器支持的接口交出扫码
+func Dispatch(handler BarcodeHandler, scan Scan) error {
+	if tagged, ok := handler.(ScanHandler); ok {
+		return tagged.HandleScan(scan)
+	}
+	if device, ok := handler.(DeviceBarcodeHandler); ok && scan.DeviceID > 0 {
+		return device.HandleDeviceBarcode(scan.Barcode, scan.DeviceID, scan.Duration, scan.Timings)
+	}
+	if keyed, ok := handler.(KeyedBarcodeHandler); ok && scan.Timings != nil {
+		return keyed.HandleKeyedBarcode(scan.Barcode, scan.Duration, scan.Timings)
+	}
+	if timed, ok := handler.(TimedBarcodeHandler); ok {
+		return timed.HandleTimedBarcode(scan.Barcode, scan.Duration)
+	}
+	return handler.HandleBarcode(scan.Barcode)
+}
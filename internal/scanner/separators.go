@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 组分隔符按键的虚拟键码
+const (
+	VK_F1          = 0x70
+	VK_OEM_6       = 0xDD // 右方括号
+	functionKeyMax = 12
+)
+
+// separatorKey 输入组分隔符的按键及须按住的 Ctrl
+type separatorKey struct {
+	vkCode uint32
+	ctrl   bool
+}
+
+// GroupSeparators 输入GS1组分隔符（ASCII 29）的按键
+//
+// GS1 DataMatrix、GS1-128 标签经键盘模式输出时，扫码枪通常以 Ctrl+] 发送组分隔符，部分型号可配置为
+// 功能键（如 F8）或以 ] 代替；匹配的按键写入 0x1D，按住 Shift 或 Alt 时不匹配。
+type GroupSeparators map[separatorKey]bool
+
+// ParseGroupSeparators 按名称（ctrl+]、]、f1-f12）解析组分隔符按键，未知名称忽略
+func ParseGroupSeparators(names []string) GroupSeparators {
+	separators := make(GroupSeparators)
+	for _, name := range names {
+		if key, ok := groupSeparatorKey(name); ok {
+			separators[key] = true
+		}
+	}
+	return separators
+}
+
+// Match 按键是否输入组分隔符
+func (g GroupSeparators) Match(vkCode uint32, shift, ctrl, alt bool) bool {
+	return !shift && !alt && g[separatorKey{vkCode: vkCode, ctrl: ctrl}]
+}
+
+// groupSeparatorKey 组分隔符按键名称对应的按键
+func groupSeparatorKey(name string) (separatorKey, bool) {
+	switch name = strings.ToLower(name); name {
+	case "ctrl+]":
+		return separatorKey{vkCode: VK_OEM_6, ctrl: true}, true
+	case "]":
+		return separatorKey{vkCode: VK_OEM_6}, true
+	}
+	for n := 1; n <= functionKeyMax; n++ {
+		if name == "f"+strconv.Itoa(n) {
+			return separatorKey{vkCode: VK_F1 + uint32(n-1)}, true
+		}
+	}
+	return separatorKey{}, false
+}
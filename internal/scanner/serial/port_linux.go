@@ -0,0 +1,77 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"userclient/internal/config"
+)
+
+// baudRates 支持的波特率
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+// dataBits 数据位对应的 CSIZE
+var dataBits = map[int]uint32{5: unix.CS5, 6: unix.CS6, 7: unix.CS7, 8: unix.CS8}
+
+// openPort 以原始模式打开并配置串口，读取没有数据时最多等待 readTimeout
+func openPort(cfg config.SerialPortConfig) (port, error) {
+	rate, ok := baudRates[cfg.BaudRate]
+	if !ok {
+		return nil, portError("设置串口参数", cfg.Name, fmt.Errorf("不支持的波特率 %d", cfg.BaudRate))
+	}
+
+	fd, err := unix.Open(cfg.Name, unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, portError("打开串口", cfg.Name, err)
+	}
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		unix.Close(fd)
+		return nil, portError("读取串口参数", cfg.Name, err)
+	}
+
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF | unix.IXANY
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CBAUD | unix.CRTSCTS
+	t.Cflag |= unix.CREAD | unix.CLOCAL | dataBits[cfg.DataBits] | rate
+	switch cfg.Parity {
+	case "odd":
+		t.Cflag |= unix.PARENB | unix.PARODD
+	case "even":
+		t.Cflag |= unix.PARENB
+	}
+	if cfg.StopBits == 2 {
+		t.Cflag |= unix.CSTOPB
+	}
+	t.Ispeed, t.Ospeed = rate, rate
+	t.Cc[unix.VMIN] = 0
+	t.Cc[unix.VTIME] = uint8(readTimeout.Milliseconds() / 100)
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, t); err != nil {
+		unix.Close(fd)
+		return nil, portError("设置串口参数", cfg.Name, err)
+	}
+	// 丢弃打开前缓冲的数据，避免重连后处理断开前的残帧
+	unix.IoctlSetInt(fd, unix.TCFLSH, unix.TCIFLUSH)
+	return os.NewFile(uintptr(fd), cfg.Name), nil
+}
+
+// checkPort 读取超时与串口拔出都返回0字节，设备文件消失时视为断开
+func checkPort(name string) error {
+	if _, err := os.Stat(name); err != nil {
+		return portError("读取串口", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows && !linux
+
+package serial
+
+import "userclient/internal/config"
+
+// openPort 当前平台不支持串口
+func openPort(cfg config.SerialPortConfig) (port, error) {
+	return nil, ErrUnsupported
+}
+
+// checkPort 当前平台不支持串口
+func checkPort(name string) error {
+	return ErrUnsupported
+}
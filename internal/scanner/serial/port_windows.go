@@ -0,0 +1,141 @@
+package serial
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"userclient/internal/config"
+)
+
+// dcb 串口参数（DCB 结构体），Flags 为位域
+type dcb struct {
+	DCBlength  uint32
+	BaudRate   uint32
+	Flags      uint32
+	wReserved  uint16
+	XonLim     uint16
+	XoffLim    uint16
+	ByteSize   byte
+	Parity     byte
+	StopBits   byte
+	XonChar    byte
+	XoffChar   byte
+	ErrorChar  byte
+	EofChar    byte
+	EvtChar    byte
+	wReserved1 uint16
+}
+
+// commTimeouts 读写超时（COMMTIMEOUTS 结构体）
+type commTimeouts struct {
+	ReadIntervalTimeout         uint32
+	ReadTotalTimeoutMultiplier  uint32
+	ReadTotalTimeoutConstant    uint32
+	WriteTotalTimeoutMultiplier uint32
+	WriteTotalTimeoutConstant   uint32
+}
+
+// DCB.Flags 位
+const (
+	dcbBinary     = 0x0001
+	dcbParity     = 0x0002
+	dcbDtrEnable  = 0x0010 // fDtrControl = DTR_CONTROL_ENABLE
+	dcbRtsEnable  = 0x1000 // fRtsControl = RTS_CONTROL_ENABLE
+	maxDword      = 0xFFFFFFFF
+	twoStopBits   = 2
+	oddParity     = 1
+	evenParity    = 2
+	purgeRxClear  = 0x0008
+	purgeRxAbort  = 0x0002
+	accessDefault = syscall.GENERIC_READ | syscall.GENERIC_WRITE
+)
+
+var (
+	kernel32        = syscall.NewLazyDLL("kernel32.dll")
+	getCommState    = kernel32.NewProc("GetCommState")
+	setCommState    = kernel32.NewProc("SetCommState")
+	setCommTimeouts = kernel32.NewProc("SetCommTimeouts")
+	purgeComm       = kernel32.NewProc("PurgeComm")
+)
+
+// windowsPort Windows串口
+type windowsPort struct {
+	handle syscall.Handle
+}
+
+// openPort 打开并配置串口，COM10 及以上须使用 \\.\ 前缀，这里统一加上
+func openPort(cfg config.SerialPortConfig) (port, error) {
+	path := cfg.Name
+	if !strings.HasPrefix(path, `\\.\`) {
+		path = `\\.\` + path
+	}
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, portError("打开串口", cfg.Name, err)
+	}
+	handle, err := syscall.CreateFile(name, accessDefault, 0, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, portError("打开串口", cfg.Name, err)
+	}
+
+	var state dcb
+	state.DCBlength = uint32(unsafe.Sizeof(state))
+	if ret, _, err := getCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&state))); ret == 0 {
+		syscall.CloseHandle(handle)
+		return nil, portError("读取串口参数", cfg.Name, err)
+	}
+	state.BaudRate = uint32(cfg.BaudRate)
+	state.ByteSize = byte(cfg.DataBits)
+	state.Flags = dcbBinary | dcbDtrEnable | dcbRtsEnable
+	state.Parity = 0
+	switch cfg.Parity {
+	case "odd":
+		state.Parity = oddParity
+		state.Flags |= dcbParity
+	case "even":
+		state.Parity = evenParity
+		state.Flags |= dcbParity
+	}
+	state.StopBits = 0
+	if cfg.StopBits == 2 {
+		state.StopBits = twoStopBits
+	}
+	if ret, _, err := setCommState.Call(uintptr(handle), uintptr(unsafe.Pointer(&state))); ret == 0 {
+		syscall.CloseHandle(handle)
+		return nil, portError("设置串口参数", cfg.Name, err)
+	}
+
+	// 有数据时立即返回，没有数据时最多等待 readTimeout
+	timeouts := commTimeouts{
+		ReadIntervalTimeout:        maxDword,
+		ReadTotalTimeoutMultiplier: maxDword,
+		ReadTotalTimeoutConstant:   uint32(readTimeout.Milliseconds()),
+	}
+	if ret, _, err := setCommTimeouts.Call(uintptr(handle), uintptr(unsafe.Pointer(&timeouts))); ret == 0 {
+		syscall.CloseHandle(handle)
+		return nil, portError("设置串口超时", cfg.Name, err)
+	}
+	// 丢弃打开前缓冲的数据，避免重连后处理断开前的残帧
+	purgeComm.Call(uintptr(handle), purgeRxAbort|purgeRxClear)
+	return &windowsPort{handle: handle}, nil
+}
+
+// Read 读取串口，超时没有数据时返回 (0, nil)，串口拔出时返回错误
+func (p *windowsPort) Read(buf []byte) (int, error) {
+	var n uint32
+	if err := syscall.ReadFile(p.handle, buf, &n, nil); err != nil {
+		return int(n), err
+	}
+	return int(n), nil
+}
+
+// Close 关闭串口
+func (p *windowsPort) Close() error {
+	return syscall.CloseHandle(p.handle)
+}
+
+// checkPort 串口拔出时 ReadFile 返回错误，无需额外检查
+func checkPort(name string) error {
+	return nil
+}
@@ -0,0 +1,394 @@
+// Package serial 串口扫码枪
+//
+// RS-232及USB虚拟串口扫码枪不模拟键盘输入，键盘钩子收不到。每个串口在独立的 goroutine 中读取，
+// 按帧结束符切分出条码后交给与键盘钩子相同的条码处理器；串口断开（如拔出USB转串口线）或打开失败时
+// 按指数退避自动重连。串口仅支持Windows及Linux。
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/scanner"
+)
+
+// ErrUnsupported 当前平台不支持串口
+var ErrUnsupported = errors.New("串口扫码枪仅支持Windows及Linux")
+
+// maxFrameSize 一帧的最大字节数，超出时丢弃该帧，避免扫码枪未发送结束符时缓冲无限增长
+const maxFrameSize = 4096
+
+// readTimeout 一次读取的最长等待时间，停止时最多等待该时长
+const readTimeout = 500 * time.Millisecond
+
+// 串口事件类型
+const (
+	EventConnected    = "connected"    // 打开成功（含断开后重连成功）
+	EventDisconnected = "disconnected" // 读取出错或打开失败，之后按退避时间重试
+)
+
+// Event 串口连接状态变化，每次断开只通知一次，重试失败不重复通知
+type Event struct {
+	Type     string        `json:"type"`
+	Port     string        `json:"port"`
+	Error    string        `json:"error,omitempty"`
+	Attempts int           `json:"attempts,omitempty"` // 重连成功前失败的次数
+	Downtime time.Duration `json:"downtime,omitempty"` // 重连成功前断开的时长
+}
+
+// DeviceLookup 按设备序列号查找设备ID，未登记时返回 false
+type DeviceLookup func(serialNo string) (uint, bool)
+
+// PortStatus 串口状态
+type PortStatus struct {
+	Port        string     `json:"port"`
+	Device      string     `json:"device,omitempty"`
+	Connected   bool       `json:"connected"`
+	Frames      uint64     `json:"frames"`  // 读取到的条码数
+	Dropped     uint64     `json:"dropped"` // 超长丢弃的帧数
+	LastError   string     `json:"last_error,omitempty"`
+	LastFrameAt *time.Time `json:"last_frame_at,omitempty"`
+}
+
+// port 打开的串口，Read 在 readTimeout 内没有数据时返回 (0, nil) 或 (0, io.EOF)
+type port interface {
+	io.ReadCloser
+}
+
+// Sources 所有配置的串口，作为一个采集来源与键盘钩子等其他来源同时工作
+type Sources struct {
+	readers []*Reader
+	mu      sync.Mutex
+	done    chan struct{} // 未打开时为nil
+	wg      sync.WaitGroup
+	stop    chan struct{} // Stop 后关闭
+	once    sync.Once
+}
+
+// New 按配置创建串口扫码枪，handler 与键盘钩子使用的条码处理器相同
+func New(cfg config.SerialConfig, handler scanner.BarcodeHandler, logger *logrus.Logger) *Sources {
+	s := &Sources{stop: make(chan struct{})}
+	for _, portConfig := range cfg.Ports {
+		s.readers = append(s.readers, newReader(normalize(portConfig), cfg, handler, logger))
+	}
+	return s
+}
+
+// SetDeviceLookup 设置按序列号查找设备的函数，须在 Install 前调用
+func (s *Sources) SetDeviceLookup(lookup DeviceLookup) {
+	for _, r := range s.readers {
+		r.lookup = lookup
+	}
+}
+
+// OnEvent 注册串口连接状态变化的回调，须在 Install 前调用
+func (s *Sources) OnEvent(listener func(Event)) {
+	for _, r := range s.readers {
+		r.listeners = append(r.listeners, listener)
+	}
+}
+
+// Name 采集来源标识，各串口的扫码以 serial:<串口名称> 标识
+func (s *Sources) Name() string {
+	return "serial"
+}
+
+// Install 同时打开所有串口并开始读取，已打开时不重复打开；打开失败的串口在后台重试，不返回错误
+func (s *Sources) Install() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done != nil {
+		return nil
+	}
+	select {
+	case <-s.stop:
+		return nil
+	default:
+	}
+	s.done = make(chan struct{})
+	for _, r := range s.readers {
+		s.wg.Add(1)
+		go func(r *Reader, done <-chan struct{}) {
+			defer s.wg.Done()
+			r.run(done)
+		}(r, s.done)
+	}
+	return nil
+}
+
+// Uninstall 停止读取并关闭所有串口，之后可再次 Install（如热备交还后重新接管）
+func (s *Sources) Uninstall() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done == nil {
+		return
+	}
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+}
+
+// Run 阻塞直到 Stop，各串口在独立的 goroutine 中读取
+func (s *Sources) Run() {
+	<-s.stop
+}
+
+// Stop 关闭所有串口并结束 Run
+func (s *Sources) Stop() {
+	s.Uninstall()
+	s.once.Do(func() { close(s.stop) })
+}
+
+// IsRunning 串口是否已打开（不表示各串口均已连接，连接状态见 Status）
+func (s *Sources) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done != nil
+}
+
+// Status 各串口的状态
+func (s *Sources) Status() []PortStatus {
+	statuses := make([]PortStatus, 0, len(s.readers))
+	for _, r := range s.readers {
+		statuses = append(statuses, r.Status())
+	}
+	return statuses
+}
+
+// normalize 省略的参数使用默认值
+func normalize(cfg config.SerialPortConfig) config.SerialPortConfig {
+	if cfg.BaudRate == 0 {
+		cfg.BaudRate = 9600
+	}
+	if cfg.DataBits == 0 {
+		cfg.DataBits = 8
+	}
+	if cfg.Parity == "" {
+		cfg.Parity = "none"
+	}
+	if cfg.StopBits == 0 {
+		cfg.StopBits = 1
+	}
+	if cfg.Terminator == "" {
+		cfg.Terminator = "enter"
+	}
+	return cfg
+}
+
+// terminatorBytes 帧结束符对应的字节，enter 同时接受CR及LF，CR+LF 之间的空帧忽略
+var terminatorBytes = map[string][]byte{
+	"enter": {'\r', '\n'},
+	"cr":    {'\r'},
+	"lf":    {'\n'},
+	"tab":   {'\t'},
+}
+
+// Reader 读取一个串口
+type Reader struct {
+	config       config.SerialPortConfig
+	reconnectMin time.Duration
+	reconnectMax time.Duration
+	handler      scanner.BarcodeHandler
+	lookup       DeviceLookup
+	listeners    []func(Event)
+	logger       *logrus.Logger
+	terminators  [256]bool
+
+	mu     sync.Mutex
+	status PortStatus
+}
+
+// newReader 创建串口读取器
+func newReader(cfg config.SerialPortConfig, serial config.SerialConfig, handler scanner.BarcodeHandler, logger *logrus.Logger) *Reader {
+	r := &Reader{
+		config:       cfg,
+		reconnectMin: serial.ReconnectMin,
+		reconnectMax: serial.ReconnectMax,
+		handler:      handler,
+		logger:       logger,
+		status:       PortStatus{Port: cfg.Name, Device: cfg.Device},
+	}
+	for _, b := range terminatorBytes[cfg.Terminator] {
+		r.terminators[b] = true
+	}
+	return r
+}
+
+// Status 串口状态
+func (r *Reader) Status() PortStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// run 打开串口并读取，断开或打开失败后按指数退避重试，done 关闭后返回
+func (r *Reader) run(done <-chan struct{}) {
+	backoff := r.reconnectMin
+	var down time.Time
+	attempts := 0
+	for {
+		p, err := openPort(r.config)
+		if err == nil {
+			r.connected(attempts, down)
+			backoff, attempts = r.reconnectMin, 0
+			err = r.read(p, done)
+			p.Close()
+			if err == nil {
+				r.setConnected(false, "")
+				return
+			}
+		}
+		if errors.Is(err, ErrUnsupported) {
+			r.logger.WithField("port", r.config.Name).Error(err.Error())
+			r.setConnected(false, err.Error())
+			return
+		}
+
+		if attempts == 0 {
+			down = time.Now()
+			r.disconnected(err)
+		}
+		attempts++
+		r.logger.WithError(err).WithFields(logrus.Fields{
+			"port":    r.config.Name,
+			"attempt": attempts,
+			"retry":   backoff,
+		}).Debug("串口不可用，稍后重试")
+
+		select {
+		case <-done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > r.reconnectMax {
+			backoff = r.reconnectMax
+		}
+	}
+}
+
+// read 读取直到出错或 done 关闭，done 关闭时返回 nil
+func (r *Reader) read(p port, done <-chan struct{}) error {
+	var frame []byte
+	var started time.Time
+	overflow := false
+	buf := make([]byte, 256)
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		n, err := p.Read(buf)
+		for _, b := range buf[:n] {
+			if r.terminators[b] {
+				if len(frame) > 0 && !overflow {
+					r.emit(string(frame), time.Since(started))
+				}
+				frame, overflow = frame[:0], false
+				continue
+			}
+			if len(frame) == 0 {
+				started = time.Now()
+			}
+			if len(frame) >= maxFrameSize {
+				if !overflow {
+					r.drop()
+				}
+				overflow = true
+				continue
+			}
+			frame = append(frame, b)
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if n == 0 {
+			// 读取超时，检查串口是否已拔出
+			if err := checkPort(r.config.Name); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// emit 把一帧交给条码处理器，配置了设备序列号且已登记时扫码归属到该设备
+func (r *Reader) emit(barcode string, duration time.Duration) {
+	now := time.Now()
+	r.mu.Lock()
+	r.status.Frames++
+	r.status.LastFrameAt = &now
+	r.mu.Unlock()
+
+	var deviceID uint
+	if r.config.Device != "" && r.lookup != nil {
+		id, ok := r.lookup(r.config.Device)
+		if !ok {
+			r.logger.WithFields(logrus.Fields{"port": r.config.Name, "device": r.config.Device}).Warn("串口配置的设备序列号未登记，扫码归属默认设备")
+		}
+		deviceID = id
+	}
+
+	scan := scanner.Scan{Barcode: barcode, Input: "serial:" + r.config.Name, DeviceID: deviceID, Duration: duration}
+	if err := scanner.Dispatch(r.handler, scan); err != nil {
+		r.logger.WithError(err).WithField("port", r.config.Name).Error("处理串口条码失败")
+	}
+}
+
+// drop 记录超长丢弃的帧
+func (r *Reader) drop() {
+	r.mu.Lock()
+	r.status.Dropped++
+	r.mu.Unlock()
+	r.logger.WithFields(logrus.Fields{"port": r.config.Name, "max_bytes": maxFrameSize}).
+		Warn("串口数据超过最大帧长度仍未收到结束符，丢弃该帧，请检查 terminator 配置")
+}
+
+// connected 打开成功，断开后重连成功时通知
+func (r *Reader) connected(attempts int, down time.Time) {
+	r.setConnected(true, "")
+	fields := logrus.Fields{"port": r.config.Name, "baud_rate": r.config.BaudRate}
+	event := Event{Type: EventConnected, Port: r.config.Name}
+	if attempts > 0 {
+		event.Attempts = attempts
+		event.Downtime = time.Since(down).Round(time.Second)
+		fields["attempts"], fields["downtime"] = event.Attempts, event.Downtime
+	}
+	r.logger.WithFields(fields).Info("串口扫码枪已连接")
+	r.notify(event)
+}
+
+// disconnected 断开或首次打开失败时通知
+func (r *Reader) disconnected(err error) {
+	r.setConnected(false, err.Error())
+	r.logger.WithError(err).WithField("port", r.config.Name).Warn("串口扫码枪不可用，将自动重连")
+	r.notify(Event{Type: EventDisconnected, Port: r.config.Name, Error: err.Error()})
+}
+
+// setConnected 更新连接状态
+func (r *Reader) setConnected(connected bool, lastError string) {
+	r.mu.Lock()
+	r.status.Connected = connected
+	if lastError != "" {
+		r.status.LastError = lastError
+	}
+	r.mu.Unlock()
+}
+
+// notify 通知串口连接状态变化
+func (r *Reader) notify(event Event) {
+	for _, listener := range r.listeners {
+		listener(event)
+	}
+}
+
+// portError 打开或配置串口失败的错误
+func portError(action, name string, err error) error {
+	return fmt.Errorf("%s %s 失败: %w", action, name, err)
+}
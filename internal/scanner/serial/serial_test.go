@@ -0,0 +1,69 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// chunkPort 依次返回预设的数据块，读完后返回 errUnplugged
+type chunkPort struct {
+	chunks []string
+}
+
+var errUnplugged = errors.New("unplugged")
+
+func (p *chunkPort) Read(buf []byte) (int, error) {
+	if len(p.chunks) == 0 {
+		return 0, errUnplugged
+	}
+	n := copy(buf, p.chunks[0])
+	p.chunks = p.chunks[1:]
+	return n, nil
+}
+
+func (p *chunkPort) Close() error { return nil }
+
+// frameHandler 记录收到的帧
+type frameHandler struct {
+	frames []string
+}
+
+func (h *frameHandler) HandleBarcode(barcode string) error {
+	h.frames = append(h.frames, barcode)
+	return nil
+}
+
+// TestReadTabTerminator 以 Tab 结束的帧：跨读取分块的帧完整拼接，连续的 Tab 不产生空帧，回车作为帧内容
+func TestReadTabTerminator(t *testing.T) {
+	tests := []struct {
+		terminator string
+		chunks     []string
+		want       []string
+	}{
+		{terminator: "tab", chunks: []string{"SN00", "01\tSN0002\t\t", "AB\r1\t"}, want: []string{"SN0001", "SN0002", "AB\r1"}},
+		{terminator: "enter", chunks: []string{"SN0001\tX\r\n", "SN0002\n"}, want: []string{"SN0001\tX", "SN0002"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.terminator, func(t *testing.T) {
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := &frameHandler{}
+			r := newReader(config.SerialPortConfig{Name: "COM9", Terminator: tt.terminator}, config.SerialConfig{}, handler, logger)
+			if err := r.read(&chunkPort{chunks: tt.chunks}, make(chan struct{})); !errors.Is(err, errUnplugged) {
+				t.Fatalf("read err = %v", err)
+			}
+			if !reflect.DeepEqual(handler.frames, tt.want) {
+				t.Fatalf("frames = %q, want %q", handler.frames, tt.want)
+			}
+			if status := r.Status(); status.Frames != uint64(len(tt.want)) {
+				t.Fatalf("status frames = %d, want %d", status.Frames, len(tt.want))
+			}
+		})
+	}
+}
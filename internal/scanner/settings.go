@@ -0,0 +1,119 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"userclient/internal/config"
+)
+
+// ErrInvalidSettings 扫码参数无效，未应用到按键采集来源
+var ErrInvalidSettings = errors.New("扫码参数无效")
+
+// Settings 按键采集来源在运行中可替换的扫码参数
+//
+// 按键采集来源通过原子指针读取，替换后下一个按键起按新参数组装，正在组装的缓冲保留；缩小 max_length 后
+// 已超长的缓冲在结束时按新范围校验而被丢弃。其余参数（采集方式、按键拦截、键盘布局等）须重启后生效。
+type Settings struct {
+	Timeout        time.Duration
+	CollapseWindow time.Duration
+	MinLength      int
+	MaxLength      int
+	MergeFragments bool
+	Terminators    Terminators
+	Idle           bool // 按键停顿后结束扫码（不使用终止符或开启 flush_on_timeout）
+	BufferCap      int  // 缓冲上限，超过时视为扫码枪故障持续输出，不小于 MaxLength
+
+	GroupSeparators GroupSeparators // 输入GS1组分隔符的按键，须重启后生效
+}
+
+// NewSettings 按扫码配置生成参数
+func NewSettings(cfg *config.ScannerConfig) *Settings {
+	terminators := ParseTerminators(cfg.Terminators)
+	return &Settings{
+		Timeout:        time.Duration(cfg.TimeoutMS) * time.Millisecond,
+		CollapseWindow: time.Duration(cfg.TerminatorCollapseMS) * time.Millisecond,
+		MinLength:      cfg.MinLength,
+		MaxLength:      cfg.MaxLength,
+		MergeFragments: cfg.MergeFragments,
+		Terminators:    terminators,
+		Idle:           terminators.IdleFinalize(cfg.FlushOnTimeout),
+		BufferCap:      cfg.MaxLength * cfg.MaxBufferFactor,
+
+		GroupSeparators: ParseGroupSeparators(cfg.GroupSeparator),
+	}
+}
+
+// ValidateSettings 检查运行中可修改的扫码参数（按键超时、长度范围、终止符）
+func ValidateSettings(cfg config.ScannerConfig) error {
+	if cfg.TimeoutMS <= 0 {
+		return fmt.Errorf("%w: timeout_ms 须大于0", ErrInvalidSettings)
+	}
+	if cfg.MinLength < 1 {
+		return fmt.Errorf("%w: min_length 须大于0", ErrInvalidSettings)
+	}
+	if cfg.MaxLength < cfg.MinLength {
+		return fmt.Errorf("%w: max_length 不能小于 min_length", ErrInvalidSettings)
+	}
+	if cfg.MaxBufferFactor < 1 {
+		return fmt.Errorf("%w: max_buffer_factor 须大于0", ErrInvalidSettings)
+	}
+	if cfg.MaxAvgKeyIntervalMS > 0 && cfg.MaxAvgKeyIntervalMS >= cfg.TimeoutMS {
+		return fmt.Errorf("%w: timeout_ms 须大于 max_avg_key_interval_ms（%d）", ErrInvalidSettings, cfg.MaxAvgKeyIntervalMS)
+	}
+	if len(cfg.Terminators) == 0 {
+		return fmt.Errorf("%w: 终止符不能为空，扫码枪不发送后缀时设置为 [\"none\"]", ErrInvalidSettings)
+	}
+	for _, name := range cfg.Terminators {
+		if name == TerminatorNone {
+			if len(cfg.Terminators) > 1 {
+				return fmt.Errorf("%w: none 不能与其他终止符同时配置", ErrInvalidSettings)
+			}
+			continue
+		}
+		if _, ok := terminatorKeys[name]; !ok {
+			return fmt.Errorf("%w: 终止符须为 enter、cr、lf、tab 或 none", ErrInvalidSettings)
+		}
+	}
+	return nil
+}
+
+// SettingsUpdate 运行中修改的扫码参数，省略的项保持不变
+type SettingsUpdate struct {
+	TimeoutMS   *int     `json:"timeout_ms"`
+	MinLength   *int     `json:"min_length"`
+	MaxLength   *int     `json:"max_length"`
+	Terminators []string `json:"terminators"`
+}
+
+// Apply 把修改的项写入扫码配置
+func (u SettingsUpdate) Apply(cfg *config.ScannerConfig) {
+	if u.TimeoutMS != nil {
+		cfg.TimeoutMS = *u.TimeoutMS
+	}
+	if u.MinLength != nil {
+		cfg.MinLength = *u.MinLength
+	}
+	if u.MaxLength != nil {
+		cfg.MaxLength = *u.MaxLength
+	}
+	if u.Terminators != nil {
+		cfg.Terminators = append([]string(nil), u.Terminators...)
+	}
+}
+
+// SettingsOnly 两份扫码配置是否只有运行中可修改的扫码参数（含 max_buffer_factor）不同，是时无需重新安装键盘钩子
+//
+// 开启按键拦截时拦截器按启动时的按键超时判断扫码，timeout_ms 的变化仍须重新安装。
+func SettingsOnly(current, next config.ScannerConfig) bool {
+	if !current.SuppressInput || !next.SuppressInput {
+		current.TimeoutMS, next.TimeoutMS = 0, 0
+	}
+	current.MinLength, next.MinLength = 0, 0
+	current.MaxLength, next.MaxLength = 0, 0
+	current.MaxBufferFactor, next.MaxBufferFactor = 0, 0
+	current.Terminators, next.Terminators = nil, nil
+	return reflect.DeepEqual(current, next)
+}
@@ -0,0 +1,219 @@
+package scanner
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// replayMarker 重放按键的 dwExtraInfo 标记（"SCAN"），钩子据此识别自己重放的按键并直接放行
+const replayMarker = 0x5343414E
+
+// KeyEvent 钩子收到的一次按键事件，暂扣后原样重放
+type KeyEvent struct {
+	VkCode   uint32
+	ScanCode uint32
+	Flags    uint32 // KBDLLHOOKSTRUCT.Flags，重放时保留扩展键标记
+	Up       bool   // 按键松开
+}
+
+// KeyKind 按键在扫码中的作用
+type KeyKind int
+
+const (
+	KeyOther      KeyKind = iota // 其他按键（退格、方向键、功能键等）
+	KeyChar                      // 产生条码字符的按键
+	KeyTerminator                // 终止符（回车、换行）
+	KeyModifier                  // 修饰键（Shift、Ctrl、Alt）
+)
+
+// Suppressor 判断按键是否属于扫码并拦截，使扫码枪输入不进入前台程序
+//
+// 是否为扫码必须在每个按键到达时决定，而扫码尚未结束：字符键先暂扣，连续 threshold 个字符键
+// 的间隔都不超过 timeout 时判定为扫码，丢弃暂扣的按键并拦截本次扫码的后续按键（含终止符）；
+// 超时没有后续按键或间隔过长时，按原顺序重放暂扣的按键，人工输入不会丢失，只是延迟至多 timeout。
+//
+// 暂扣期间的修饰键、松开等事件一并暂扣以保持顺序；判定为扫码时暂扣的修饰键照常重放，
+// 避免前台程序的 Shift 状态错乱。按住 Ctrl、Alt 的快捷键不计入字符键，不会被暂扣。
+//
+// 除 Stats 外 Suppressor 不是并发安全的，只能在钩子线程中使用。
+type Suppressor struct {
+	timeout   time.Duration
+	threshold int
+
+	held     []KeyEvent
+	count    int // 连续快速字符键数
+	bursting bool
+	last     time.Time
+	dropped  map[uint32]bool // 已拦截按下、松开也须拦截的按键
+
+	suppressed, replayed atomic.Uint64
+}
+
+// NewSuppressor 创建按键拦截器，threshold 小于2时按2处理
+func NewSuppressor(timeoutMS, threshold int) *Suppressor {
+	if threshold < 2 {
+		threshold = 2
+	}
+	return &Suppressor{
+		timeout:   time.Duration(timeoutMS) * time.Millisecond,
+		threshold: threshold,
+		dropped:   make(map[uint32]bool),
+	}
+}
+
+// Key 处理一次按键事件，suppress 表示不传给前台程序；replay 为须按顺序重放的按键，
+// 可能包含本次按键（此时 suppress 为 true）
+func (s *Suppressor) Key(ev KeyEvent, kind KeyKind, now time.Time) (suppress bool, replay []KeyEvent) {
+	if ev.Up {
+		return s.release(ev, kind)
+	}
+
+	switch kind {
+	case KeyChar:
+		return s.char(ev, now)
+	case KeyModifier:
+		return s.hold(ev)
+	}
+
+	// 终止符、其他按键：扫码中的终止符一并拦截并结束本次扫码
+	fast := now.Sub(s.last) <= s.timeout
+	s.last = now
+	s.count = 0
+	if kind == KeyTerminator && s.bursting && fast {
+		s.bursting = false
+		s.dropped[ev.VkCode] = true
+		s.suppressed.Add(1)
+		return true, nil
+	}
+	s.bursting = false
+	if len(s.held) > 0 {
+		// 暂扣的字符键之后是人工按下的其他按键（如退格），连同本次按键按顺序重放
+		return true, s.flush(ev)
+	}
+	return false, nil
+}
+
+// char 处理字符键按下
+func (s *Suppressor) char(ev KeyEvent, now time.Time) (bool, []KeyEvent) {
+	fast := !s.last.IsZero() && now.Sub(s.last) <= s.timeout
+	s.last = now
+
+	if s.bursting && fast {
+		s.dropped[ev.VkCode] = true
+		s.suppressed.Add(1)
+		return true, nil
+	}
+
+	var replay []KeyEvent
+	if !fast {
+		// 间隔过长，之前暂扣的按键是人工输入，先于本次按键重放
+		s.bursting = false
+		s.count = 0
+		replay = s.flush()
+	}
+
+	s.count++
+	s.held = append(s.held, ev)
+	if s.count >= s.threshold {
+		s.bursting = true
+		replay = append(replay, s.confirm()...)
+	}
+	return true, replay
+}
+
+// release 处理按键松开
+func (s *Suppressor) release(ev KeyEvent, kind KeyKind) (bool, []KeyEvent) {
+	if kind != KeyModifier && s.dropped[ev.VkCode] {
+		delete(s.dropped, ev.VkCode)
+		s.suppressed.Add(1)
+		return true, nil
+	}
+	return s.hold(ev)
+}
+
+// hold 有暂扣的按键时暂扣本次事件以保持顺序，否则放行
+func (s *Suppressor) hold(ev KeyEvent) (bool, []KeyEvent) {
+	if len(s.held) == 0 {
+		return false, nil
+	}
+	s.held = append(s.held, ev)
+	return true, nil
+}
+
+// confirm 判定为扫码：丢弃暂扣的字符键，修饰键及按下已放行的按键的松开事件照常重放
+func (s *Suppressor) confirm() []KeyEvent {
+	down := make(map[uint32]bool)
+	var replay []KeyEvent
+	for _, ev := range s.held {
+		switch {
+		case isModifierKey(ev.VkCode):
+			replay = append(replay, ev)
+		case !ev.Up:
+			down[ev.VkCode] = true
+			s.suppressed.Add(1)
+		case down[ev.VkCode]:
+			delete(down, ev.VkCode)
+			s.suppressed.Add(1)
+		default:
+			replay = append(replay, ev)
+		}
+	}
+	// 尚未松开的字符键，松开时一并拦截
+	for vkCode := range down {
+		s.dropped[vkCode] = true
+	}
+	s.held = s.held[:0]
+	s.replayed.Add(uint64(len(replay)))
+	return replay
+}
+
+// Expire 最后一个按键之后超过 timeout 没有新按键时，返回须重放的暂扣按键
+func (s *Suppressor) Expire(now time.Time) []KeyEvent {
+	if len(s.held) == 0 || now.Sub(s.last) <= s.timeout {
+		return nil
+	}
+	s.count = 0
+	return s.flush()
+}
+
+// Drain 取出全部暂扣的按键用于重放，钩子停止时调用
+func (s *Suppressor) Drain() []KeyEvent {
+	s.count = 0
+	s.bursting = false
+	return s.flush()
+}
+
+// Pending 是否有暂扣的按键，调用方据此安排 Expire
+func (s *Suppressor) Pending() bool {
+	return len(s.held) > 0
+}
+
+// Timeout 暂扣按键的最长时间
+func (s *Suppressor) Timeout() time.Duration {
+	return s.timeout
+}
+
+// Stats 已拦截、已重放的按键事件数，可在任意 goroutine 中调用
+func (s *Suppressor) Stats() (suppressed, replayed uint64) {
+	return s.suppressed.Load(), s.replayed.Load()
+}
+
+// flush 取出暂扣的按键及附加的按键用于重放
+func (s *Suppressor) flush(extra ...KeyEvent) []KeyEvent {
+	if len(s.held) == 0 && len(extra) == 0 {
+		return nil
+	}
+	replay := append(append([]KeyEvent(nil), s.held...), extra...)
+	s.held = s.held[:0]
+	s.replayed.Add(uint64(len(replay)))
+	return replay
+}
+
+// isModifierKey 是否为修饰键（Shift、Ctrl、Alt 的通用及左右键码）
+func isModifierKey(vkCode uint32) bool {
+	switch vkCode {
+	case 0x10, 0x11, 0x12, 0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5:
+		return true
+	}
+	return false
+}
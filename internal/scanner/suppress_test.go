@@ -0,0 +1,182 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	vkShift     = 0xA0
+	vkBackspace = 0x08
+)
+
+func down(vkCode uint32) KeyEvent { return KeyEvent{VkCode: vkCode} }
+func up(vkCode uint32) KeyEvent   { return KeyEvent{VkCode: vkCode, Up: true} }
+
+// keyNames 非字符键的名称
+var keyNames = map[uint32]string{vkShift: "Shift", vkBackspace: "Back", VK_RETURN: "Enter"}
+
+// vkCodes 事件的按键名称，松开的事件前加 ^
+func vkCodes(events []KeyEvent) []string {
+	var out []string
+	for _, ev := range events {
+		s, ok := keyNames[ev.VkCode]
+		if !ok {
+			s = string(rune(ev.VkCode))
+		}
+		if ev.Up {
+			s = "^" + s
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// TestSuppressorIntervalBoundary 按键间隔等于 timeout 仍计为连续，超过 timeout 即视为人工输入并重放暂扣的按键
+func TestSuppressorIntervalBoundary(t *testing.T) {
+	const timeout = 30 * time.Millisecond
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	s := NewSuppressor(30, 3)
+	for i, vk := range []uint32{'A', 'B', 'C'} {
+		suppress, replay := s.Key(down(vk), KeyChar, at.Add(time.Duration(i)*timeout))
+		if !suppress || len(replay) != 0 {
+			t.Fatalf("key %c: suppress = %v, replay = %v", vk, suppress, vkCodes(replay))
+		}
+	}
+	if s.Pending() {
+		t.Fatal("burst at exactly timeout intervals was not confirmed")
+	}
+	if suppressed, _ := s.Stats(); suppressed != 3 {
+		t.Fatalf("suppressed = %d, want 3", suppressed)
+	}
+
+	s = NewSuppressor(30, 3)
+	s.Key(down('A'), KeyChar, at)
+	s.Key(down('B'), KeyChar, at.Add(timeout))
+	suppress, replay := s.Key(down('C'), KeyChar, at.Add(2*timeout+time.Nanosecond))
+	if !suppress || !reflect.DeepEqual(vkCodes(replay), []string{"A", "B"}) {
+		t.Fatalf("slow third key: suppress = %v, replay = %v, want A B replayed", suppress, vkCodes(replay))
+	}
+	if !s.Pending() {
+		t.Fatal("slow key should start a new hold")
+	}
+}
+
+// TestSuppressorThreshold 少于 threshold 个字符键时一直暂扣，超时后按原顺序重放；第 threshold 个字符键时判定为扫码
+func TestSuppressorThreshold(t *testing.T) {
+	const timeout = 30 * time.Millisecond
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	s := NewSuppressor(30, 4)
+
+	keys := []KeyEvent{down(vkShift), down('A'), up(vkShift), down('B'), up('A'), down('C')}
+	kinds := []KeyKind{KeyModifier, KeyChar, KeyModifier, KeyChar, KeyChar, KeyChar}
+	for i, ev := range keys {
+		at = at.Add(time.Millisecond)
+		suppress, replay := s.Key(ev, kinds[i], at)
+		if i == 0 {
+			if suppress {
+				t.Fatal("modifier without held keys was suppressed")
+			}
+			continue
+		}
+		if !suppress || len(replay) != 0 {
+			t.Fatalf("event %d: suppress = %v, replay = %v", i, suppress, vkCodes(replay))
+		}
+	}
+
+	// 第 threshold-1 个字符键后停顿：恰好 timeout 时继续等待，超过后重放
+	if replay := s.Expire(at.Add(timeout)); replay != nil {
+		t.Fatalf("expired at exactly timeout: %v", vkCodes(replay))
+	}
+	replay := s.Expire(at.Add(timeout + time.Nanosecond))
+	if want := []string{"A", "^Shift", "B", "^A", "C"}; !reflect.DeepEqual(vkCodes(replay), want) {
+		t.Fatalf("replay = %v, want %v", vkCodes(replay), want)
+	}
+	if s.Pending() {
+		t.Fatal("keys held after expire")
+	}
+
+	// threshold 个连续字符键：暂扣的字符丢弃，修饰键及已放行按键的松开照常重放
+	s = NewSuppressor(30, 4)
+	at = at.Add(time.Second)
+	s.Key(down('X'), KeyChar, at)
+	s.Key(down(vkShift), KeyModifier, at)
+	var replayed []KeyEvent
+	for _, vk := range []uint32{'A', 'B', 'C'} {
+		at = at.Add(time.Millisecond)
+		_, replay := s.Key(down(vk), KeyChar, at)
+		replayed = append(replayed, replay...)
+	}
+	if want := []string{"Shift"}; !reflect.DeepEqual(vkCodes(replayed), want) {
+		t.Fatalf("confirm replay = %v, want shift only", vkCodes(replayed))
+	}
+	// 被丢弃的按键松开时也拦截
+	for _, vk := range []uint32{'X', 'A', 'B', 'C'} {
+		if suppress, _ := s.Key(up(vk), KeyChar, at); !suppress {
+			t.Fatalf("release of dropped %c passed through", vk)
+		}
+	}
+	if suppress, _ := s.Key(up('Z'), KeyChar, at); suppress {
+		t.Fatal("release of a key that was never held was suppressed")
+	}
+}
+
+// TestSuppressorTerminatorBoundary 扫码中 timeout 内的终止符一并拦截，超过 timeout 的回车是人工按键
+func TestSuppressorTerminatorBoundary(t *testing.T) {
+	const timeout = 30 * time.Millisecond
+	for _, tt := range []struct {
+		gap      time.Duration
+		suppress bool
+	}{
+		{gap: timeout, suppress: true},
+		{gap: timeout + time.Nanosecond, suppress: false},
+	} {
+		at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+		s := NewSuppressor(30, 2)
+		s.Key(down('A'), KeyChar, at)
+		s.Key(down('B'), KeyChar, at.Add(time.Millisecond))
+		at = at.Add(time.Millisecond + tt.gap)
+		if suppress, _ := s.Key(down(VK_RETURN), KeyTerminator, at); suppress != tt.suppress {
+			t.Fatalf("gap %v: terminator suppress = %v, want %v", tt.gap, suppress, tt.suppress)
+		}
+		if suppress, _ := s.Key(up(VK_RETURN), KeyTerminator, at); suppress != tt.suppress {
+			t.Fatalf("gap %v: terminator release suppress = %v", tt.gap, suppress)
+		}
+		// 终止符结束本次扫码，之后的单个字符键重新暂扣等待判定
+		if suppress, replay := s.Key(down('C'), KeyChar, at.Add(time.Millisecond)); !suppress || len(replay) != 0 || !s.Pending() {
+			t.Fatalf("gap %v: key after terminator suppress = %v, pending = %v", tt.gap, suppress, s.Pending())
+		}
+	}
+}
+
+// TestSuppressorOtherKeyReplays 暂扣期间按下其他按键（如退格）时暂扣的按键连同该按键按顺序重放
+func TestSuppressorOtherKeyReplays(t *testing.T) {
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	s := NewSuppressor(30, 3)
+	s.Key(down('A'), KeyChar, at)
+	suppress, replay := s.Key(down(vkBackspace), KeyOther, at.Add(time.Millisecond))
+	if !suppress || !reflect.DeepEqual(vkCodes(replay), []string{"A", "Back"}) {
+		t.Fatalf("suppress = %v, replay = %v", suppress, vkCodes(replay))
+	}
+	if suppress, _ := s.Key(down(vkBackspace), KeyOther, at.Add(2*time.Millisecond)); suppress {
+		t.Fatal("other key without held keys was suppressed")
+	}
+	if _, replayed := s.Stats(); replayed != 2 {
+		t.Fatalf("replayed = %d, want 2", replayed)
+	}
+}
+
+// TestSuppressorMinimumThreshold threshold 小于2时按2处理，单个字符键不会被判定为扫码
+func TestSuppressorMinimumThreshold(t *testing.T) {
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	s := NewSuppressor(30, 0)
+	s.Key(down('A'), KeyChar, at)
+	if !s.Pending() {
+		t.Fatal("single key confirmed as a scan")
+	}
+	if replay := s.Drain(); !reflect.DeepEqual(vkCodes(replay), []string{"A"}) {
+		t.Fatalf("drain = %v", vkCodes(replay))
+	}
+}
@@ -0,0 +1,42 @@
+package scanner
+
+// 终止符虚拟键码
+const (
+	VK_TAB    = 0x09
+	VK_LF     = 0x0A // 部分扫码枪驱动将LF作为独立按键上报
+	VK_RETURN = 0x0D
+)
+
+// TerminatorNone 不使用终止符，按键停顿超过 timeout_ms 后结束扫码
+const TerminatorNone = "none"
+
+// terminatorKeys 终止符名称对应的虚拟键码
+var terminatorKeys = map[string][]uint32{
+	"enter": {VK_RETURN, VK_LF}, // 回车，兼容单独上报LF的扫码枪
+	"cr":    {VK_RETURN},
+	"lf":    {VK_LF},
+	"tab":   {VK_TAB},
+}
+
+// Terminators 结束扫码的按键
+type Terminators map[uint32]bool
+
+// ParseTerminators 按名称（enter、cr、lf、tab）解析终止符，未知名称忽略；
+// 返回空集合表示按停顿结束扫码（none），未配置时使用回车
+func ParseTerminators(names []string) Terminators {
+	if len(names) == 0 {
+		names = []string{"enter"}
+	}
+	terminators := make(Terminators)
+	for _, name := range names {
+		for _, vkCode := range terminatorKeys[name] {
+			terminators[vkCode] = true
+		}
+	}
+	return terminators
+}
+
+// IdleFinalize 是否按停顿结束扫码，flushOnTimeout 为配置的 scanner.flush_on_timeout
+func (t Terminators) IdleFinalize(flushOnTimeout bool) bool {
+	return len(t) == 0 || flushOnTimeout
+}
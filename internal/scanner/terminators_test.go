@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+func TestParseTerminators(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  Terminators
+		idle  bool
+	}{
+		{names: nil, want: Terminators{VK_RETURN: true, VK_LF: true}},
+		{names: []string{"enter"}, want: Terminators{VK_RETURN: true, VK_LF: true}},
+		{names: []string{"tab"}, want: Terminators{VK_TAB: true}},
+		{names: []string{"tab", "enter"}, want: Terminators{VK_TAB: true, VK_RETURN: true, VK_LF: true}},
+		{names: []string{"cr", "lf"}, want: Terminators{VK_RETURN: true, VK_LF: true}},
+		{names: []string{TerminatorNone}, want: Terminators{}, idle: true},
+		{names: []string{"esc"}, want: Terminators{}, idle: true},
+	}
+	for _, tt := range tests {
+		got := ParseTerminators(tt.names)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTerminators(%q) = %v, want %v", tt.names, got, tt.want)
+		}
+		if got.IdleFinalize(false) != tt.idle {
+			t.Errorf("ParseTerminators(%q).IdleFinalize(false) = %v, want %v", tt.names, !tt.idle, tt.idle)
+		}
+		if !got.IdleFinalize(true) {
+			t.Errorf("ParseTerminators(%q).IdleFinalize(true) = false", tt.names)
+		}
+	}
+}
+
+// typeKeys 按键盘钩子的处理方式输入：配置的终止符结束扫码，其他虚拟键码（如未配置的回车）忽略，字符追加到缓冲
+func typeKeys(c *keyCapture, assembler *Assembler, at time.Time, keys string) time.Time {
+	for i := 0; i < len(keys); i++ {
+		at = at.Add(2 * time.Millisecond)
+		settings := c.settings.Load()
+		switch vkCode := uint32(keys[i]); {
+		case settings.Terminators[vkCode]:
+			c.finalizeAssembler(assembler, 0, at, false)
+		case vkCode == VK_TAB || vkCode == VK_RETURN || vkCode == VK_LF:
+		default:
+			c.addKey(assembler, settings, keys[i], at, service.KeyTiming{})
+		}
+	}
+	return at
+}
+
+// TestTabTerminator 以 Tab 结束扫码的扫码枪（表单逐字段录入）：Tab 结束扫码，未配置时回车不结束扫码；
+// 同时配置 Tab 和回车时两者都结束扫码
+func TestTabTerminator(t *testing.T) {
+	tests := []struct {
+		name        string
+		terminators []string
+		keys        string
+		want        []string
+	}{
+		{name: "tab", terminators: []string{"tab"}, keys: "SN0001\tSN0002\t", want: []string{"SN0001", "SN0002"}},
+		{name: "enter ignored", terminators: []string{"tab"}, keys: "SN0001\rSN0002\t", want: []string{"SN0001SN0002"}},
+		{name: "tab ignored by enter", terminators: []string{"enter"}, keys: "SN0001\tSN0002\r", want: []string{"SN0001SN0002"}},
+		{name: "tab and enter", terminators: []string{"tab", "enter"}, keys: "SN0001\tSN0002\r\n", want: []string{"SN0001", "SN0002"}},
+		{name: "consecutive tabs collapse", terminators: []string{"tab"}, keys: "SN0001\t\tSN0002\t", want: []string{"SN0001", "SN0002"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.ScannerConfig{TimeoutMS: 50, MinLength: 3, MaxLength: 20, MaxBufferFactor: 2, TerminatorCollapseMS: 30, Terminators: tt.terminators}
+			handler := &recordingHandler{}
+			c, assembler := newTestCapture(cfg, handler)
+			typeKeys(c, assembler, time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC), tt.keys)
+			if !reflect.DeepEqual(handler.barcodes, tt.want) {
+				t.Fatalf("barcodes = %q, want %q", handler.barcodes, tt.want)
+			}
+			if assembler.Pending() {
+				t.Fatal("keys left in buffer")
+			}
+			if n := c.metrics.tooShort.Load(); n != 0 {
+				t.Fatalf("too short = %d, consecutive terminators produced empty scans", n)
+			}
+		})
+	}
+}
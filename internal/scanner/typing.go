@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"userclient/internal/service"
+)
+
+// TypingFilter 按按键节奏区分人工输入与扫码
+//
+// 扫码枪输出的按键间隔通常只有几毫秒，人工打字通常在80毫秒以上；操作员在按键超时内打完一个短单词并回车时，
+// 组装器会把它当作扫码。平均按键间隔（按钩子上报的按键时间）超过阈值，或首个按键到最后一个按键的耗时
+// 超过阈值与按键间隔数之积时，视为人工输入并丢弃。阈值为0时不过滤。可在任意 goroutine 中调用。
+type TypingFilter struct {
+	maxAvg   atomic.Int64 // time.Duration
+	rejected atomic.Uint64
+}
+
+// NewTypingFilter 创建人工输入过滤，maxAvgMS 为扫码允许的最大平均按键间隔（毫秒），0表示不过滤
+func NewTypingFilter(maxAvgMS int) *TypingFilter {
+	f := &TypingFilter{}
+	f.SetMaxAvg(maxAvgMS)
+	return f
+}
+
+// SetMaxAvg 更新允许的最大平均按键间隔（毫秒），0表示不过滤
+func (f *TypingFilter) SetMaxAvg(maxAvgMS int) {
+	if f == nil {
+		return
+	}
+	f.maxAvg.Store(int64(time.Duration(maxAvgMS) * time.Millisecond))
+}
+
+// Check 判断组装出的缓冲是否为人工输入，是时计数并返回原因
+func (f *TypingFilter) Check(content string, duration time.Duration, timings []service.KeyTiming) (string, bool) {
+	if f == nil || len(content) < 2 {
+		return "", false
+	}
+	maxAvg := time.Duration(f.maxAvg.Load())
+	if maxAvg <= 0 {
+		return "", false
+	}
+	reason := ""
+	if intervals, ok := service.MeasureKeyIntervals(timings); ok && intervals.Avg > maxAvg {
+		reason = fmt.Sprintf("平均按键间隔 %s 超过 %s", intervals.Avg, maxAvg)
+	} else if limit := maxAvg * time.Duration(len(content)-1); duration > limit {
+		reason = fmt.Sprintf("%d 个字符录入耗时 %s 超过 %s", len(content), duration, limit)
+	}
+	if reason == "" {
+		return "", false
+	}
+	f.rejected.Add(1)
+	return reason, true
+}
+
+// Rejected 判定为人工输入而丢弃的缓冲数
+func (f *TypingFilter) Rejected() uint64 {
+	if f == nil {
+		return 0
+	}
+	return f.rejected.Load()
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"userclient/internal/config"
+	"userclient/internal/service"
+)
+
+// evenKeys count 个按键，相邻按键间隔 gap，返回各按键时间及首个按键到最后一个按键的耗时
+func evenKeys(count int, gap time.Duration) ([]service.KeyTiming, time.Duration) {
+	timings := make([]service.KeyTiming, count)
+	for i := range timings {
+		timings[i] = service.KeyTiming{Tick: 1000 + uint32(i)*uint32(gap.Milliseconds())}
+	}
+	return timings, time.Duration(count-1) * gap
+}
+
+func TestTypingFilter(t *testing.T) {
+	ms := time.Millisecond
+	fast, fastDuration := evenKeys(13, 4*ms)
+	slow, slowDuration := evenKeys(5, 120*ms)
+	edge, edgeDuration := evenKeys(6, 30*ms)
+	tests := []struct {
+		name     string
+		maxAvgMS int
+		content  string
+		duration time.Duration
+		timings  []service.KeyTiming
+		human    bool
+		reason   string // 原因中应包含的内容
+	}{
+		{name: "scanner", maxAvgMS: 30, content: "6901234567892", duration: fastDuration, timings: fast},
+		{name: "typed word", maxAvgMS: 30, content: "hello", duration: slowDuration, timings: slow, human: true, reason: "平均按键间隔 120ms"},
+		{name: "at threshold", maxAvgMS: 30, content: "SN0001", duration: edgeDuration, timings: edge},
+		// 没有按键时间（如 evdev 以外的来源）时按总耗时判断
+		{name: "slow without ticks", maxAvgMS: 30, content: "hello", duration: 480 * ms, human: true, reason: "5 个字符录入耗时"},
+		{name: "fast without ticks", maxAvgMS: 30, content: "hello", duration: 60 * ms},
+		{name: "unknown ticks", maxAvgMS: 30, content: "hello", duration: 480 * ms, timings: make([]service.KeyTiming, 5), human: true},
+		// 平均间隔未超过阈值，但中间停顿使总耗时超出
+		{name: "paused mid scan", maxAvgMS: 30, content: "hello", duration: 200 * ms, timings: slow[:1], human: true},
+		{name: "single key", maxAvgMS: 30, content: "x", duration: time.Second},
+		{name: "disabled", maxAvgMS: 0, content: "hello", duration: slowDuration, timings: slow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewTypingFilter(tt.maxAvgMS)
+			reason, human := f.Check(tt.content, tt.duration, tt.timings)
+			if human != tt.human || !strings.Contains(reason, tt.reason) {
+				t.Fatalf("Check = %q, %v, want human %v with reason containing %q", reason, human, tt.human, tt.reason)
+			}
+			var want uint64
+			if tt.human {
+				want = 1
+			}
+			if f.Rejected() != want {
+				t.Fatalf("rejected = %d, want %d", f.Rejected(), want)
+			}
+		})
+	}
+
+	// 运行中修改阈值
+	f := NewTypingFilter(0)
+	f.SetMaxAvg(100)
+	if _, human := f.Check("hello", slowDuration, slow); !human {
+		t.Fatal("threshold update not applied")
+	}
+	var nilFilter *TypingFilter
+	if _, human := nilFilter.Check("hello", slowDuration, slow); human || nilFilter.Rejected() != 0 {
+		t.Fatal("nil filter rejected input")
+	}
+}
+
+// TestKeyCaptureRejectsTyping 按键组装按钩子上报的按键时间丢弃人工输入并计数、记录原因，扫码枪的按键照常交出
+func TestKeyCaptureRejectsTyping(t *testing.T) {
+	cfg := config.ScannerConfig{TimeoutMS: 500, MinLength: 3, MaxLength: 20, MaxBufferFactor: 2, Terminators: []string{"enter"}, MaxAvgKeyIntervalMS: 30}
+	handler := &recordingHandler{}
+	c, assembler := newTestCapture(cfg, handler)
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	c.logger = logger
+	c.typing = NewTypingFilter(cfg.MaxAvgKeyIntervalMS)
+	c.isRunning.Store(true)
+
+	start := time.Now()
+	typeBuffer := func(content string, gap time.Duration) {
+		at := start
+		for i := 0; i < len(content); i++ {
+			c.addKey(assembler, c.settings.Load(), content[i], at, service.KeyTiming{Tick: uint32(at.Sub(start).Milliseconds()) + 1})
+			at = at.Add(gap)
+		}
+		c.finalizeAssembler(assembler, 0, at, false)
+		start = at.Add(time.Second)
+	}
+
+	typeBuffer("6901234567892", 3*time.Millisecond)
+	typeBuffer("hello", 150*time.Millisecond)
+	typeBuffer("SN000101", 8*time.Millisecond)
+
+	if got := strings.Join(handler.barcodes, ","); got != "6901234567892,SN000101" {
+		t.Fatalf("delivered = %s, want only the scanner input", got)
+	}
+	if c.typing.Rejected() != 1 {
+		t.Fatalf("rejected typing = %d, want 1", c.typing.Rejected())
+	}
+	found := false
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == logrus.DebugLevel && entry.Message == "按键节奏像人工输入，丢弃缓冲" {
+			reason, _ := entry.Data["reason"].(string)
+			found = strings.Contains(reason, "平均按键间隔 150ms")
+		}
+	}
+	if !found {
+		t.Fatal("rejection reason not logged")
+	}
+}
@@ -0,0 +1,180 @@
+//go:build windows
+
+package scanner
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// 看门狗
+//
+// 低级键盘钩子的回调超过 LowLevelHooksTimeout（注册表，默认约数秒）未返回时，系统会静默移除钩子，
+// 程序照常运行但再也收不到按键。看门狗在超过检查间隔没有按键时通过 SendInput 发送一个带标记的探测按键，
+// 钩子收到后记录并拦截；探测按键未送达时在钩子线程中重新安装钩子。
+const (
+	// watchdogMarker 探测按键的 dwExtraInfo 标记（"WDOG"），钩子据此识别并拦截
+	watchdogMarker = 0x57444F47
+	// watchdogProbeVK 探测使用的虚拟键码 VK_F24，键盘上没有该键；只发送松开事件，钩子失效时前台程序收到也没有影响
+	watchdogProbeVK = 0x87
+	// watchdogProbeWait 等待探测按键送达钩子的时间
+	watchdogProbeWait = time.Second
+
+	wmReinstallHook = 0x8000 + 3 // WM_APP+3，看门狗发现钩子失效，在钩子线程中重新安装
+)
+
+// OnReinstall 注册看门狗重新安装钩子的回调，须在 Install 前调用
+func (h *Hook) OnReinstall(listener func(HookReinstall)) {
+	h.onReinstall = listener
+}
+
+// startWatchdog 启动看门狗，调用方持有 mu；rawinput 不受钩子超时影响，不启动
+func (h *Hook) startWatchdog() {
+	if h.rawInput || h.config.WatchdogInterval <= 0 || h.watchdogStop != nil {
+		return
+	}
+	h.watchdogStop = make(chan struct{})
+	go h.watchdog(h.config.WatchdogInterval, h.watchdogStop)
+}
+
+// stopWatchdog 停止看门狗，调用方持有 mu
+func (h *Hook) stopWatchdog() {
+	if h.watchdogStop != nil {
+		close(h.watchdogStop)
+		h.watchdogStop = nil
+	}
+}
+
+// watchdog 定期检查钩子是否仍然有效，stop 关闭后返回
+//
+// 锁屏、安全桌面或前台为更高权限的程序时探测按键可能被系统拦下，重新安装后仍未送达时暂停重新安装，
+// 直到再次收到按键或探测成功，避免反复重新安装刷屏系统日志。
+func (h *Hook) watchdog(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reinstalled := false // 上个周期已重新安装
+	suspended := false   // 重新安装后探测仍未送达，暂停重新安装
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if time.Since(time.Unix(0, h.lastKey.Load())) < interval {
+			reinstalled, suspended = false, false
+			continue
+		}
+		delivered, sent := h.probe(stop)
+		lost := h.hookLost()
+		switch {
+		case !sent:
+			// SendInput 被拦下（锁屏、安全桌面等），无法判断钩子状态
+			continue
+		case delivered:
+			if suspended {
+				h.logger.Info("键盘钩子探测恢复正常，看门狗继续检查")
+			}
+			reinstalled, suspended = false, false
+			continue
+		case lost:
+			// 上次重新安装失败，重试
+		case suspended:
+			continue
+		case reinstalled:
+			suspended = true
+			h.logger.Warn("重新安装键盘钩子后探测按键仍未送达（可能处于锁屏或前台为管理员权限的程序），暂停重新安装直到收到按键")
+			continue
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		reinstalled = true
+		h.notify(wmReinstallHook)
+	}
+}
+
+// probe 发送探测按键并等待钩子收到，sent 为 false 表示探测按键未能发出
+func (h *Hook) probe(stop <-chan struct{}) (delivered, sent bool) {
+	sentAt := time.Now().UnixNano()
+	input := keyboardInput{
+		Type: INPUT_KEYBOARD,
+		Ki: KEYBDINPUT{
+			WVk:         watchdogProbeVK,
+			DwFlags:     KEYEVENTF_KEYUP,
+			DwExtraInfo: watchdogMarker,
+		},
+	}
+	n, _, _ := sendInput.Call(1, uintptr(unsafe.Pointer(&input)), unsafe.Sizeof(input))
+	if n == 0 {
+		return false, false
+	}
+
+	timer := time.NewTimer(watchdogProbeWait)
+	defer timer.Stop()
+	select {
+	case <-stop:
+		return true, true
+	case <-timer.C:
+	}
+	return h.probeAck.Load() >= sentAt, true
+}
+
+// reinstallHook 卸载失效的钩子并重新安装，在钩子线程中调用
+func (h *Hook) reinstallHook() {
+	h.mu.Lock()
+	if !h.isRunning.Load() {
+		// 看门狗通知到达前已卸载
+		h.mu.Unlock()
+		return
+	}
+	if h.hook != 0 {
+		unhookWindowsHookEx.Call(h.hook)
+		h.hook = 0
+	}
+	var err error
+	if moduleHandle, _, _ := getModuleHandle.Call(0); moduleHandle == 0 {
+		err = fmt.Errorf("获取模块句柄失败")
+	} else {
+		h.hook, err = h.setHook(moduleHandle)
+	}
+	h.mu.Unlock()
+
+	now := time.Now()
+	h.lastReinstall.Store(now.UnixNano())
+	event := HookReinstall{
+		At:     now,
+		Reason: fmt.Sprintf("超过 %s 未收到按键且探测按键未送达钩子，钩子可能因回调超时被系统移除", h.config.WatchdogInterval),
+		Count:  h.reinstalls.Add(1),
+	}
+	if err != nil {
+		event.Error = err.Error()
+		h.logger.WithError(err).Error("重新安装键盘钩子失败，下个检查周期重试")
+	} else {
+		h.logger.WithField("count", event.Count).Warn("键盘钩子已失效，已重新安装")
+	}
+	if h.onReinstall != nil {
+		go h.onReinstall(event)
+	}
+}
+
+// hookLost 重新安装失败，钩子仍在运行状态但已不存在
+func (h *Hook) hookLost() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hook == 0 && h.isRunning.Load()
+}
+
+// watchdogStats 看门狗重新安装的次数及最近一次的时间
+func (h *Hook) watchdogStats(stats *HookStats) {
+	stats.Reinstalls = h.reinstalls.Load()
+	if at := h.lastReinstall.Load(); at != 0 {
+		t := time.Unix(0, at)
+		stats.LastReinstallAt = &t
+	}
+}
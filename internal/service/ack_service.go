@@ -0,0 +1,248 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 单条记录的确认结果
+const (
+	AckStatusAcked     = "acked"         // 本次确认
+	AckStatusDuplicate = "already_acked" // 此前已确认，重复确认不报错
+	AckStatusNotFound  = "not_found"     // 记录不存在或已删除
+	AckStatusFailed    = "failed"        // 写入失败，可重试
+)
+
+// unconsumedCondition 未被指定消费方确认的扫码记录
+const unconsumedCondition = "NOT EXISTS (SELECT 1 FROM barcode_consumptions WHERE barcode_consumptions.consumer = ? AND barcode_consumptions.record_id = barcode_records.id)"
+
+// consumedCondition 已被指定消费方确认的扫码记录
+const consumedCondition = "EXISTS (SELECT 1 FROM barcode_consumptions WHERE barcode_consumptions.consumer = ? AND barcode_consumptions.record_id = barcode_records.id)"
+
+var (
+	// ErrUnknownConsumer 消费方未在 acks.consumers 中登记
+	ErrUnknownConsumer = errors.New("消费方未登记")
+	// ErrInvalidAck 确认请求无效
+	ErrInvalidAck = errors.New("确认请求无效")
+)
+
+// AckItem 按ID确认时单条记录的结果
+type AckItem struct {
+	ID     uint   `json:"id"`
+	Status string `json:"status"` // acked, already_acked, not_found, failed
+	Error  string `json:"error,omitempty"`
+}
+
+// AckResult 一次确认的结果
+type AckResult struct {
+	Consumer string    `json:"consumer"`
+	Acked    int64     `json:"acked"`              // 本次新确认的记录数
+	Already  int64     `json:"already_acked"`      // 此前已确认的记录数（仅按ID确认时统计）
+	Failed   int       `json:"failed"`             // 未能确认的记录数（不存在或写入失败）
+	UpToID   uint      `json:"up_to_id,omitempty"` // 按范围确认时的最大记录ID
+	Items    []AckItem `json:"items,omitempty"`    // 按ID确认时逐条的结果
+	AckedAt  time.Time `json:"acked_at"`
+}
+
+// ConsumerLag 消费方的积压情况
+type ConsumerLag struct {
+	Consumer           string     `json:"consumer"`
+	Unconsumed         int64      `json:"unconsumed"`                     // 未确认的记录数
+	OldestUnconsumedAt *time.Time `json:"oldest_unconsumed_at,omitempty"` // 最早的未确认记录的扫码时间
+	OldestAgeSeconds   int64      `json:"oldest_age_seconds"`             // 最早的未确认记录距今的秒数，无积压时为0
+	LastAckAt          *time.Time `json:"last_ack_at,omitempty"`
+}
+
+// AckService 下游系统（如ERP）消费确认
+//
+// 消费方通过 REST 拉取扫码记录后按ID或截至某个ID确认，已确认的记录可通过
+// unconsumed_by 过滤掉。确认是幂等的，重复确认返回 already_acked。
+type AckService struct {
+	db        *gorm.DB
+	config    config.AcksConfig
+	consumers map[string]bool
+	logger    *logrus.Logger
+}
+
+// NewAckService 创建消费确认服务
+func NewAckService(db *gorm.DB, cfg config.AcksConfig, logger *logrus.Logger) *AckService {
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 1000
+	}
+	consumers := make(map[string]bool, len(cfg.Consumers))
+	for _, name := range cfg.Consumers {
+		consumers[name] = true
+	}
+	return &AckService{
+		db:        db,
+		config:    cfg,
+		consumers: consumers,
+		logger:    logger,
+	}
+}
+
+// IsRegistered 消费方是否已登记
+func (s *AckService) IsRegistered(consumer string) bool {
+	return s.consumers[consumer]
+}
+
+// Ack 确认消费方已消费的记录，ids 与 upToID 二选一；upToID 确认该ID及之前的所有记录
+func (s *AckService) Ack(consumer string, ids []uint, upToID uint) (*AckResult, error) {
+	if !s.IsRegistered(consumer) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConsumer, consumer)
+	}
+	switch {
+	case len(ids) > 0 && upToID > 0:
+		return nil, fmt.Errorf("%w: ids 与 up_to_id 只能指定一个", ErrInvalidAck)
+	case len(ids) == 0 && upToID == 0:
+		return nil, fmt.Errorf("%w: 须指定 ids 或 up_to_id", ErrInvalidAck)
+	case len(ids) > s.config.MaxBatch:
+		return nil, fmt.Errorf("%w: 单次最多确认 %d 条", ErrInvalidAck, s.config.MaxBatch)
+	}
+
+	result := &AckResult{Consumer: consumer, AckedAt: time.Now()}
+	var err error
+	if upToID > 0 {
+		err = s.ackUpTo(result, upToID)
+	} else {
+		err = s.ackIDs(result, ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("consumer", consumer).
+		WithField("acked", result.Acked).
+		WithField("already_acked", result.Already).
+		WithField("failed", result.Failed).
+		Info("下游系统确认消费")
+	return result, nil
+}
+
+// ackUpTo 确认截至 upToID 的所有未确认记录
+func (s *AckService) ackUpTo(result *AckResult, upToID uint) error {
+	exec := s.db.Exec("INSERT INTO barcode_consumptions (consumer, record_id, consumed_at) "+
+		"SELECT ?, id, ? FROM barcode_records WHERE id <= ? AND deleted_at IS NULL AND "+unconsumedCondition,
+		result.Consumer, result.AckedAt, upToID, result.Consumer)
+	if exec.Error != nil {
+		return fmt.Errorf("确认消费失败: %w", exec.Error)
+	}
+	result.Acked = exec.RowsAffected
+	result.UpToID = upToID
+	return nil
+}
+
+// ackIDs 逐条确认，单条失败不影响其他记录
+func (s *AckService) ackIDs(result *AckResult, ids []uint) error {
+	ids = uniqueIDs(ids)
+
+	var existing []uint
+	if err := s.db.Model(&models.BarcodeRecord{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		return fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+	var consumed []uint
+	if err := s.db.Model(&models.BarcodeConsumption{}).
+		Where("consumer = ? AND record_id IN ?", result.Consumer, ids).
+		Pluck("record_id", &consumed).Error; err != nil {
+		return fmt.Errorf("查询确认记录失败: %w", err)
+	}
+	found := idSet(existing)
+	already := idSet(consumed)
+
+	result.Items = make([]AckItem, 0, len(ids))
+	for _, id := range ids {
+		item := AckItem{ID: id}
+		switch {
+		case !found[id]:
+			item.Status = AckStatusNotFound
+			result.Failed++
+		case already[id]:
+			item.Status = AckStatusDuplicate
+			result.Already++
+		default:
+			consumption := &models.BarcodeConsumption{Consumer: result.Consumer, RecordID: id, ConsumedAt: result.AckedAt}
+			if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(consumption).Error; err != nil {
+				s.logger.WithError(err).WithField("record_id", id).Warn("写入消费确认失败")
+				item.Status = AckStatusFailed
+				item.Error = err.Error()
+				result.Failed++
+			} else {
+				item.Status = AckStatusAcked
+				result.Acked++
+			}
+		}
+		result.Items = append(result.Items, item)
+	}
+	return nil
+}
+
+// GetLag 各登记消费方的积压情况，按名称排序
+func (s *AckService) GetLag() ([]ConsumerLag, error) {
+	names := make([]string, 0, len(s.consumers))
+	for name := range s.consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	lags := make([]ConsumerLag, 0, len(names))
+	for _, name := range names {
+		lag := ConsumerLag{Consumer: name}
+		pending := s.db.Model(&models.BarcodeRecord{}).Where(unconsumedCondition, name)
+		if err := pending.Count(&lag.Unconsumed).Error; err != nil {
+			return nil, fmt.Errorf("统计未确认记录失败: %w", err)
+		}
+		if lag.Unconsumed > 0 {
+			var oldest []models.BarcodeRecord
+			if err := s.db.Select("created_at").Where(unconsumedCondition, name).
+				Order("created_at").Limit(1).Find(&oldest).Error; err != nil {
+				return nil, fmt.Errorf("查询最早未确认记录失败: %w", err)
+			}
+			if len(oldest) > 0 {
+				lag.OldestUnconsumedAt = &oldest[0].CreatedAt
+				lag.OldestAgeSeconds = int64(now.Sub(oldest[0].CreatedAt).Seconds())
+			}
+		}
+
+		var last []models.BarcodeConsumption
+		if err := s.db.Where("consumer = ?", name).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return nil, fmt.Errorf("查询最近确认时间失败: %w", err)
+		}
+		if len(last) > 0 {
+			lag.LastAckAt = &last[0].ConsumedAt
+		}
+		lags = append(lags, lag)
+	}
+	return lags, nil
+}
+
+// uniqueIDs 去重并保持顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// idSet ID集合
+func idSet(ids []uint) map[uint]bool {
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
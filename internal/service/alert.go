@@ -0,0 +1,8 @@
+package service
+
+// AlertEvent 运行告警事件
+type AlertEvent struct {
+	Source  string `json:"source"`
+	Level   string `json:"level"` // warning, error
+	Message string `json:"message"`
+}
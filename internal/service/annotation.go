@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"userclient/internal/models"
+)
+
+// maxAnnotationLength 批注的最大字符数
+const maxAnnotationLength = 500
+
+// ErrInvalidAnnotation 批注内容为空或过长
+var ErrInvalidAnnotation = errors.New("批注无效")
+
+// AddAnnotation 为扫码记录追加一条批注，记录本身不修改（完整性模式下记录的哈希保持不变）
+func (s *BarcodeService) AddAnnotation(recordID uint, note, author string) (*models.BarcodeAnnotation, error) {
+	note = strings.TrimSpace(note)
+	switch {
+	case note == "":
+		return nil, fmt.Errorf("%w: 内容不能为空", ErrInvalidAnnotation)
+	case utf8.RuneCountInString(note) > maxAnnotationLength:
+		return nil, fmt.Errorf("%w: 内容不能超过 %d 个字符", ErrInvalidAnnotation, maxAnnotationLength)
+	}
+	annotation := &models.BarcodeAnnotation{RecordID: recordID, Note: note, Author: author}
+	if err := s.db.Create(annotation).Error; err != nil {
+		return nil, fmt.Errorf("保存批注失败: %w", err)
+	}
+	s.logger.WithField("record_id", recordID).WithField("author", author).Info("扫码记录已添加批注")
+	return annotation, nil
+}
+
+// GetAnnotations 获取扫码记录的批注，按添加时间升序
+func (s *BarcodeService) GetAnnotations(recordID uint) ([]models.BarcodeAnnotation, error) {
+	var annotations []models.BarcodeAnnotation
+	if err := s.reader().Where("record_id = ?", recordID).Order("id").Find(&annotations).Error; err != nil {
+		return nil, fmt.Errorf("查询批注失败: %w", err)
+	}
+	return annotations, nil
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+	"userclient/pkg/barcode"
+)
+
+var (
+	// ErrBackfillNotFound 没有该名称的回填任务
+	ErrBackfillNotFound = errors.New("回填任务不存在")
+	// ErrBackfillState 回填任务当前状态不允许该操作
+	ErrBackfillState = errors.New("回填任务状态不允许该操作")
+)
+
+// errBackfillPaused 回填任务被暂停
+var errBackfillPaused = errors.New("回填已暂停")
+
+// errBackfillStopped 服务停止，任务保持运行状态以便重启后继续
+var errBackfillStopped = errors.New("回填服务已停止")
+
+// BackfillProgress 回填任务及其进度，预计完成时间按本次运行的回填速度估算
+type BackfillProgress struct {
+	models.BackfillJob
+	Progress      float64    `json:"progress"`                  // 已回填比例，0~1
+	RowsPerSecond float64    `json:"rows_per_second,omitempty"` // 本次运行的回填速度，仅运行中的任务
+	ETA           *time.Time `json:"eta,omitempty"`             // 预计完成时间，仅运行中的任务
+}
+
+// BackfillStatus 后台回填状态
+type BackfillStatus struct {
+	Running string             `json:"running,omitempty"` // 运行中的任务
+	Jobs    []BackfillProgress `json:"jobs"`
+}
+
+// BackfillService 迁移新增的列的后台回填
+//
+// 启动时为登记的回填创建任务（同名任务只创建一次），按创建顺序依次执行：每批回填与检查点在同一事务中提交，
+// 两批之间等待 interval 以免占用扫码写入；暂停、失败或重启后从检查点继续，已提交的批次不会重做。
+type BackfillService struct {
+	db     *gorm.DB
+	config config.DatabaseBackfillConfig
+	logger *logrus.Logger
+
+	mu         sync.Mutex
+	backfills  map[string]database.Backfill
+	order      []string
+	current    string
+	pause      chan struct{}
+	runStarted time.Time
+	runFrom    int64 // 本次运行开始时已回填的行数
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBackfillService 创建后台回填服务，登记迁移中的回填
+func NewBackfillService(db *gorm.DB, cfg config.DatabaseBackfillConfig, logger *logrus.Logger) *BackfillService {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	s := &BackfillService{
+		db:        db,
+		config:    cfg,
+		logger:    logger,
+		backfills: make(map[string]database.Backfill),
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	for _, backfill := range database.Backfills() {
+		s.Register(backfill)
+	}
+	return s
+}
+
+// Register 登记回填，需在Start之前调用
+func (s *BackfillService) Register(backfill database.Backfill) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.backfills[backfill.Name]; !ok {
+		s.order = append(s.order, backfill.Name)
+	}
+	s.backfills[backfill.Name] = backfill
+}
+
+// Start 为新登记的回填创建任务并启动回填协程，继续上次未完成的任务；创建失败的任务在下次启动时重试
+func (s *BackfillService) Start() error {
+	s.mu.Lock()
+	names := append([]string(nil), s.order...)
+	s.mu.Unlock()
+	var errs []error
+	for _, name := range names {
+		if err := s.ensureJob(s.backfills[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	s.notify()
+	return errors.Join(errs...)
+}
+
+// Close 停止回填，正在运行的任务保留检查点，重启后继续
+func (s *BackfillService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// ensureJob 回填没有任务时创建，范围为当前表中的行；没有待回填的行时直接完成
+func (s *BackfillService) ensureJob(backfill database.Backfill) error {
+	var count int64
+	if err := s.db.Model(&models.BackfillJob{}).Where("name = ?", backfill.Name).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询回填任务失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var maxID uint
+	if err := s.db.Unscoped().Model(backfill.Model).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return fmt.Errorf("查询 %s 的行数失败: %w", backfill.Name, err)
+	}
+	var total int64
+	if err := backfill.Pending(s.db.Model(backfill.Model)).Where("id <= ?", maxID).Count(&total).Error; err != nil {
+		return fmt.Errorf("统计 %s 待回填的行失败: %w", backfill.Name, err)
+	}
+
+	job := &models.BackfillJob{
+		Name:        backfill.Name,
+		Description: backfill.Description,
+		Status:      models.BackfillStatusPending,
+		MaxID:       maxID,
+		Total:       total,
+	}
+	if total == 0 {
+		now := time.Now()
+		job.Status = models.BackfillStatusCompleted
+		job.FinishedAt = &now
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		return fmt.Errorf("创建回填任务失败: %w", err)
+	}
+	if total > 0 {
+		s.logger.WithField("backfill", backfill.Name).WithField("rows", total).Info("已创建后台回填任务")
+	}
+	return nil
+}
+
+// List 全部回填任务及进度
+func (s *BackfillService) List() ([]BackfillProgress, error) {
+	var jobs []models.BackfillJob
+	if err := s.db.Order("id").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	current, started, from := s.current, s.runStarted, s.runFrom
+	s.mu.Unlock()
+
+	list := make([]BackfillProgress, 0, len(jobs))
+	for _, job := range jobs {
+		progress := BackfillProgress{BackfillJob: job, Progress: 1}
+		if job.Total > 0 && job.Status != models.BackfillStatusCompleted {
+			progress.Progress = float64(job.Processed) / float64(job.Total)
+		}
+		if job.Name == current && job.Status == models.BackfillStatusRunning {
+			elapsed := time.Since(started).Seconds()
+			if done := job.Processed - from; done > 0 && elapsed > 0 {
+				progress.RowsPerSecond = float64(done) / elapsed
+				if remaining := job.Total - job.Processed; remaining > 0 {
+					eta := time.Now().Add(time.Duration(float64(remaining) / progress.RowsPerSecond * float64(time.Second)))
+					progress.ETA = &eta
+				}
+			}
+		}
+		list = append(list, progress)
+	}
+	return list, nil
+}
+
+// Status 后台回填状态，供状态接口使用
+func (s *BackfillService) Status() BackfillStatus {
+	status := BackfillStatus{Jobs: []BackfillProgress{}}
+	list, err := s.List()
+	if err != nil {
+		s.logger.WithError(err).Warn("读取回填任务失败")
+		return status
+	}
+	s.mu.Lock()
+	status.Running = s.current
+	s.mu.Unlock()
+	status.Jobs = list
+	return status
+}
+
+// Pause 暂停排队或运行中的任务，运行中的任务在当前批次提交后停止
+func (s *BackfillService) Pause(name string) (*models.BackfillJob, error) {
+	// 持有锁期间回填协程不会取走排队的任务
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.getJob(name)
+	if err != nil {
+		return nil, err
+	}
+	if s.current == name && s.pause != nil {
+		close(s.pause)
+		s.pause = nil
+		s.logger.WithField("backfill", name).Info("正在暂停回填任务")
+		return job, nil
+	}
+	if job.Status != models.BackfillStatusPending && job.Status != models.BackfillStatusRunning {
+		return nil, fmt.Errorf("%w: 任务状态为 %s", ErrBackfillState, job.Status)
+	}
+	job.Status = models.BackfillStatusPaused
+	if err := s.db.Save(job).Error; err != nil {
+		return nil, fmt.Errorf("保存回填任务失败: %w", err)
+	}
+	return job, nil
+}
+
+// Resume 从检查点继续已暂停或失败的任务，重新排队
+func (s *BackfillService) Resume(name string) (*models.BackfillJob, error) {
+	job, err := s.getJob(name)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.BackfillStatusPaused && job.Status != models.BackfillStatusFailed {
+		return nil, fmt.Errorf("%w: 任务状态为 %s", ErrBackfillState, job.Status)
+	}
+
+	job.Status = models.BackfillStatusPending
+	job.Error = ""
+	if err := s.db.Save(job).Error; err != nil {
+		return nil, fmt.Errorf("保存回填任务失败: %w", err)
+	}
+	s.logger.WithField("backfill", name).WithField("last_id", job.LastID).Info("回填任务已重新排队")
+	s.notify()
+	return job, nil
+}
+
+// getJob 按名称获取回填任务
+func (s *BackfillService) getJob(name string) (*models.BackfillJob, error) {
+	var job models.BackfillJob
+	if err := s.db.Where("name = ?", name).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrBackfillNotFound, name)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// notify 唤醒回填协程
+func (s *BackfillService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 依次执行排队的任务
+func (s *BackfillService) run() {
+	defer s.wg.Done()
+
+	for {
+		for s.runNext() {
+		}
+
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// runNext 执行最早的待运行任务，没有任务或服务停止时返回false
+func (s *BackfillService) runNext() bool {
+	pause := make(chan struct{})
+	s.mu.Lock()
+	var jobs []models.BackfillJob
+	err := s.db.Where("status IN ? AND name IN ?",
+		[]string{models.BackfillStatusRunning, models.BackfillStatusPending}, s.order).
+		Order("id").
+		Limit(1).
+		Find(&jobs).Error
+	var backfill database.Backfill
+	if err == nil && len(jobs) > 0 {
+		backfill = s.backfills[jobs[0].Name]
+		s.current = jobs[0].Name
+		s.pause = pause
+		s.runStarted = time.Now()
+		s.runFrom = jobs[0].Processed
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.WithError(err).Warn("读取回填任务失败")
+		return false
+	}
+	if len(jobs) == 0 {
+		return false
+	}
+	job := jobs[0]
+	defer func() {
+		s.mu.Lock()
+		s.current = ""
+		s.pause = nil
+		s.mu.Unlock()
+	}()
+
+	err = s.execute(&job, backfill, pause)
+	switch {
+	case errors.Is(err, errBackfillStopped):
+		s.logger.WithField("backfill", job.Name).WithField("processed", job.Processed).Info("回填服务停止，任务将在重启后继续")
+		return false
+	case errors.Is(err, errBackfillPaused):
+		job.Status = models.BackfillStatusPaused
+		s.logger.WithField("backfill", job.Name).WithField("processed", job.Processed).Info("回填任务已暂停")
+	case err != nil:
+		job.Status = models.BackfillStatusFailed
+		job.Error = err.Error()
+		s.logger.WithError(err).WithField("backfill", job.Name).WithField("last_id", job.LastID).Warn("回填任务失败")
+	default:
+		now := time.Now()
+		job.Status = models.BackfillStatusCompleted
+		job.FinishedAt = &now
+		s.logger.WithField("backfill", job.Name).WithField("processed", job.Processed).Info("回填任务已完成")
+	}
+	if err := s.db.Save(&job).Error; err != nil {
+		s.logger.WithError(err).WithField("backfill", job.Name).Error("保存回填任务失败")
+	}
+	return true
+}
+
+// execute 从检查点开始按批回填，每批回填与检查点在同一事务中提交
+func (s *BackfillService) execute(job *models.BackfillJob, backfill database.Backfill, pause <-chan struct{}) error {
+	if job.StartedAt == nil {
+		now := time.Now()
+		job.StartedAt = &now
+	}
+	job.Status = models.BackfillStatusRunning
+	if err := s.db.Save(job).Error; err != nil {
+		return fmt.Errorf("保存回填任务失败: %w", err)
+	}
+
+	for {
+		var ids []uint
+		err := backfill.Pending(s.db.Model(backfill.Model)).
+			Where("id > ? AND id <= ?", job.LastID, job.MaxID).
+			Order("id").
+			Limit(s.config.BatchSize).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return fmt.Errorf("读取待回填的行失败: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		lastID, processed := ids[len(ids)-1], job.Processed+int64(len(ids))
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if err := backfill.Fill(tx, ids); err != nil {
+				return err
+			}
+			return tx.Model(job).Updates(map[string]interface{}{
+				"last_id":   lastID,
+				"processed": processed,
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("回填第 %d~%d 行失败: %w", ids[0], lastID, err)
+		}
+		job.LastID, job.Processed = lastID, processed
+
+		select {
+		case <-time.After(s.config.Interval):
+		case <-pause:
+			return errBackfillPaused
+		case <-s.done:
+			return errBackfillStopped
+		}
+	}
+}
+
+// DerivedFieldsBackfill 按当前分类规则为升级前写入、没有派生字段的扫码记录提取派生字段
+//
+// 只补齐派生字段，不修改类型；规则未提取到字段的记录保持为空。
+func DerivedFieldsBackfill(processor *barcode.Processor) database.Backfill {
+	return database.Backfill{
+		Name:        "barcode_records.derived",
+		Description: "按分类规则为升级前写入的扫码记录提取派生字段",
+		Model:       &models.BarcodeRecord{},
+		Pending: func(tx *gorm.DB) *gorm.DB {
+			return tx.Unscoped().Where("derived IS NULL")
+		},
+		Fill: func(tx *gorm.DB, ids []uint) error {
+			var records []*models.BarcodeRecord
+			if err := tx.Unscoped().Select("id", "content").Where("id IN ?", ids).Find(&records).Error; err != nil {
+				return fmt.Errorf("读取扫码记录失败: %w", err)
+			}
+			for _, record := range records {
+				derived := processor.ProcessBarcode(record.Content).Derived
+				if len(derived) == 0 {
+					continue
+				}
+				err := tx.Unscoped().Model(&models.BarcodeRecord{}).Where("id = ?", record.ID).
+					UpdateColumn("derived", models.StringMap(derived)).Error
+				if err != nil {
+					return fmt.Errorf("保存派生字段失败: %w", err)
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+const (
+	// backfillChildEnv 设置时测试进程作为回填中途被强制结束的子进程运行，值为数据库路径
+	backfillChildEnv = "BACKFILL_TEST_CHILD_DB"
+	backfillRows     = 100000
+	backfillBatch    = 1000
+	backfillKillAt   = 50000 // 子进程在这一行之后的批次中途被结束
+)
+
+// backfillRow 模拟迁移新增、待回填的列；Fills 记录每行被回填的次数
+type backfillRow struct {
+	ID     uint
+	Value  int
+	Filled *int
+	Fills  int
+}
+
+func (backfillRow) TableName() string {
+	return "backfill_rows"
+}
+
+// testBackfill 回填 filled 列，fill 在每批回填写入后、提交前调用
+func testBackfill(fill func(tx *gorm.DB, ids []uint)) database.Backfill {
+	return database.Backfill{
+		Name:        "backfill_rows.filled",
+		Description: "测试回填",
+		Model:       &backfillRow{},
+		Pending: func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("filled IS NULL")
+		},
+		Fill: func(tx *gorm.DB, ids []uint) error {
+			err := tx.Model(&backfillRow{}).Where("id IN ?", ids).
+				Updates(map[string]interface{}{"filled": gorm.Expr("value * 2"), "fills": gorm.Expr("fills + 1")}).Error
+			if err == nil && fill != nil {
+				fill(tx, ids)
+			}
+			return err
+		},
+	}
+}
+
+// openBackfillDB 打开数据库并创建回填测试表
+func openBackfillDB(t *testing.T, path string) *database.DB {
+	t.Helper()
+	db := openTestDB(t, path)
+	if err := db.DB.AutoMigrate(&backfillRow{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// startBackfills 创建并启动回填服务，interval 为两批之间的间隔
+func startBackfills(t *testing.T, db *database.DB, interval time.Duration, backfill database.Backfill) *BackfillService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	backfills := NewBackfillService(db.DB, config.DatabaseBackfillConfig{BatchSize: backfillBatch, Interval: interval}, logger)
+	backfills.Register(backfill)
+	if err := backfills.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return backfills
+}
+
+// backfillJob 回填测试表的任务
+func backfillJob(t *testing.T, db *database.DB) models.BackfillJob {
+	t.Helper()
+	var job models.BackfillJob
+	if err := db.Where("name = ?", "backfill_rows.filled").First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+// backfillChild 子进程：开始回填，在 backfillKillAt 之后的批次写入后、提交前通知父进程并等待被结束
+func backfillChild(t *testing.T, path string) {
+	db := openBackfillDB(t, path)
+	var once sync.Once
+	startBackfills(t, db, 0, testBackfill(func(tx *gorm.DB, ids []uint) {
+		if ids[0] > backfillKillAt {
+			once.Do(func() { fmt.Println("ready") })
+			select {}
+		}
+	}))
+	select {}
+}
+
+// TestBackfillRestart 10万行的回填在批次中途被强制结束（kill -9）后重启：未提交的批次回滚，
+// 从检查点继续，已提交的批次不重做，每行恰好回填一次
+func TestBackfillRestart(t *testing.T) {
+	if path := os.Getenv(backfillChildEnv); path != "" {
+		backfillChild(t, path)
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "backfill.db")
+	db := openBackfillDB(t, path)
+	err := db.Exec(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO backfill_rows (id, value, fills) SELECT i, i, 0 FROM n`, backfillRows).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestBackfillRestart$")
+	cmd.Env = append(os.Environ(), backfillChildEnv+"="+path)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan bool, 1)
+	go func() {
+		lines := bufio.NewScanner(stdout)
+		for lines.Scan() {
+			if lines.Text() == "ready" {
+				ready <- true
+				return
+			}
+		}
+		ready <- false
+	}()
+	var ok bool
+	select {
+	case ok = <-ready:
+	case <-time.After(time.Minute):
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	if !ok {
+		t.Fatal("child exited before reaching the kill point")
+	}
+
+	db = openBackfillDB(t, path)
+	defer db.Close()
+	job := backfillJob(t, db)
+	if job.Status != models.BackfillStatusRunning || job.Total != backfillRows || job.MaxID != backfillRows ||
+		job.LastID != backfillKillAt || job.Processed != backfillKillAt {
+		t.Fatalf("job after kill = %+v, want running with checkpoint at %d", job, backfillKillAt)
+	}
+	var filled int64
+	db.Model(&backfillRow{}).Where("filled IS NOT NULL").Count(&filled)
+	if filled != backfillKillAt {
+		t.Fatalf("filled rows after kill = %d, want the uncommitted batch rolled back", filled)
+	}
+
+	var mu sync.Mutex
+	var firstID uint
+	backfills := startBackfills(t, db, 0, testBackfill(func(tx *gorm.DB, ids []uint) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstID == 0 {
+			firstID = ids[0]
+		}
+	}))
+	defer backfills.Close()
+	deadline := time.Now().Add(time.Minute)
+	for job = backfillJob(t, db); job.Status != models.BackfillStatusCompleted; job = backfillJob(t, db) {
+		if time.Now().After(deadline) {
+			t.Fatalf("backfill not completed after restart: %+v", job)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if firstID != backfillKillAt+1 {
+		t.Fatalf("restart began at row %d, want %d", firstID, backfillKillAt+1)
+	}
+	if job.Processed != backfillRows || job.LastID != backfillRows || job.FinishedAt == nil {
+		t.Fatalf("job = %+v", job)
+	}
+	var wrong int64
+	db.Model(&backfillRow{}).Where("fills != 1 OR filled IS NULL OR filled != value * 2").Count(&wrong)
+	if wrong != 0 {
+		t.Fatalf("%d rows not filled exactly once", wrong)
+	}
+	status := backfills.Status()
+	for _, progress := range status.Jobs {
+		if progress.Name == job.Name && progress.Progress != 1 {
+			t.Fatalf("progress = %+v", progress)
+		}
+	}
+}
+
+// TestBackfillPauseResume 暂停后停止服务，重启后保持暂停；继续后从检查点完成
+func TestBackfillPauseResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backfill.db")
+	db := openBackfillDB(t, path)
+	defer db.Close()
+	err := db.Exec(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO backfill_rows (id, value, fills) SELECT i, i, 0 FROM n`, 5*backfillBatch).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二批提交后的间隔中暂停（暂停需查询数据库，须等该批事务提交）
+	committing := make(chan struct{}, 1)
+	backfills := startBackfills(t, db, 200*time.Millisecond, testBackfill(func(tx *gorm.DB, ids []uint) {
+		if ids[0] == backfillBatch+1 {
+			committing <- struct{}{}
+		}
+	}))
+	<-committing
+	if _, err := backfills.Pause("backfill_rows.filled"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	job := backfillJob(t, db)
+	for ; job.Status != models.BackfillStatusPaused; job = backfillJob(t, db) {
+		if time.Now().After(deadline) {
+			t.Fatalf("job not paused: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	backfills.Close()
+	if job.LastID != 2*backfillBatch || job.Processed != 2*backfillBatch {
+		t.Fatalf("paused job = %+v, want checkpoint after the second batch", job)
+	}
+
+	backfills = startBackfills(t, db, 0, testBackfill(nil))
+	defer backfills.Close()
+	time.Sleep(50 * time.Millisecond)
+	if job = backfillJob(t, db); job.Status != models.BackfillStatusPaused || job.Processed != 2*backfillBatch {
+		t.Fatalf("paused job ran after restart: %+v", job)
+	}
+	if _, err := backfills.Resume("backfill_rows.filled"); err != nil {
+		t.Fatal(err)
+	}
+	for job = backfillJob(t, db); job.Status != models.BackfillStatusCompleted; job = backfillJob(t, db) {
+		if time.Now().After(deadline) {
+			t.Fatalf("job not completed after resume: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var wrong int64
+	db.Model(&backfillRow{}).Where("fills != 1").Count(&wrong)
+	if wrong != 0 || job.Processed != 5*backfillBatch {
+		t.Fatalf("%d rows not filled exactly once, job = %+v", wrong, job)
+	}
+	if _, err := backfills.Resume("backfill_rows.filled"); err == nil {
+		t.Fatal("completed job resumed")
+	}
+}
@@ -1,8 +1,11 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 	
 	"github.com/sirupsen/logrus"
@@ -17,8 +20,26 @@ type BarcodeService struct {
 	db        *gorm.DB
 	processor *barcode.Processor
 	logger    *logrus.Logger
+	outbox    *OutboxService
+	replica   *ReplicationService
+	integrity *IntegrityService
+	coalescer *ScanCoalescer
+	devices   *DeviceService
+	listeners []func(*models.BarcodeRecord)
+	rejected  []func(deviceID uint, content string)
+	received  []func()
+	consumers []string // 登记的下游消费方，清理旧记录时保留其未确认的记录
+	excluded  []string // 不计入统计的扫码来源
+	station   string   // 本工位名称，默认设备取本工位的活跃设备
+	
+	defaultMu      sync.Mutex // 保护默认设备缓存
+	defaultDevice  uint
+	defaultCheckAt time.Time
 }
 
+// defaultDeviceTTL 默认设备的缓存时间，避免每次扫码都查询设备表
+const defaultDeviceTTL = 5 * time.Second
+
 // NewBarcodeService 创建条码服务
 func NewBarcodeService(db *gorm.DB, logger *logrus.Logger) *BarcodeService {
 	return &BarcodeService{
@@ -28,14 +49,103 @@ func NewBarcodeService(db *gorm.DB, logger *logrus.Logger) *BarcodeService {
 	}
 }
 
+// Processor 条码处理器（用于加载分类规则）
+func (s *BarcodeService) Processor() *barcode.Processor {
+	return s.processor
+}
+
+// SetOutbox 设置出站事件日志，扫码记录将与出站事件在同一事务中写入
+func (s *BarcodeService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// SetReplication 设置本地备份库双写，主库不可用时读写切换到备份库
+func (s *BarcodeService) SetReplication(replica *ReplicationService) {
+	s.replica = replica
+}
+
+// SetIntegrity 设置完整性哈希链，之后写入的记录加入本工位的链
+func (s *BarcodeService) SetIntegrity(integrity *IntegrityService) {
+	s.integrity = integrity
+}
+
+// SetCoalescer 设置跨设备合并，指定了设备的扫码与其他设备刚扫到的同一内容合并
+func (s *BarcodeService) SetCoalescer(coalescer *ScanCoalescer) {
+	s.coalescer = coalescer
+}
+
+// SetDevices 设置设备服务，扫码入库时同时更新所属设备的最后活跃时间
+func (s *BarcodeService) SetDevices(devices *DeviceService) {
+	s.devices = devices
+}
+
+// SetConsumers 设置登记的下游消费方
+func (s *BarcodeService) SetConsumers(consumers []string) {
+	s.consumers = consumers
+}
+
+// SetStation 设置本工位名称，未指定设备的扫码记录归入本工位的活跃设备
+func (s *BarcodeService) SetStation(station string) {
+	s.station = station
+}
+
+// SetStatsExcludedSources 设置不计入统计的扫码来源（如模拟扫码）
+func (s *BarcodeService) SetStatsExcludedSources(sources []string) {
+	s.excluded = sources
+}
+
+// OnRecorded 注册条码记录入库后的回调
+func (s *BarcodeService) OnRecorded(listener func(*models.BarcodeRecord)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// OnReceived 注册条码通过校验、写入数据库之前的回调，用于让出占用数据库的维护任务
+func (s *BarcodeService) OnReceived(listener func()) {
+	s.received = append(s.received, listener)
+}
+
+// OnRejected 注册条码格式无效被拒绝时的回调
+func (s *BarcodeService) OnRejected(listener func(deviceID uint, content string)) {
+	s.rejected = append(s.rejected, listener)
+}
+
 // HandleBarcode 处理扫描到的条码
 func (s *BarcodeService) HandleBarcode(content string) error {
-	s.logger.WithField("barcode", content).Info("开始处理条码")
-	
+	_, err := s.RecordBarcode(content)
+	return err
+}
+
+// RecordBarcode 验证并保存扫描到的条码，返回入库的记录
+func (s *BarcodeService) RecordBarcode(content string) (*models.BarcodeRecord, error) {
+	return s.RecordScan(content, ScanInfo{})
+}
+
+// ScanInfo 扫码时采集的输入信息
+type ScanInfo struct {
+	Duration          time.Duration // 扫码耗时，未知时为0
+	SuspectTruncation bool          // 按键时序异常，条码可能被截断
+	Intervals         *KeyIntervals // 按键间隔统计，非键盘来源或按键时间未知时为nil
+	Source            string        // 扫码来源，为空表示 local
+	Input             string        // 采集来源标识，如 hook、serial:COM3，接口提交及外部推送为空
+	ScannedAt         time.Time     // 扫码时间，为零表示入库时间；入库时截断到微秒
+	DeviceID          *uint         // 外部系统指定的设备，为nil表示默认设备
+	ScanID            string        // 外部系统提供的幂等键，为空时生成
+}
+
+// RecordScan 验证并保存扫描到的条码及扫码时采集的输入信息
+//
+// 扫码合并到其他设备刚扫到的同一内容时返回该记录及 ErrScanCoalesced，不触发入库回调及出站事件。
+func (s *BarcodeService) RecordScan(content string, scan ScanInfo) (*models.BarcodeRecord, error) {
 	// 验证条码格式
 	if valid, msg := s.processor.ValidateBarcode(content); !valid {
 		s.logger.WithField("barcode", content).WithField("reason", msg).Warn("条码格式无效")
-		return fmt.Errorf("条码格式无效: %s", msg)
+		if len(s.rejected) > 0 {
+			deviceID := s.getDefaultDeviceID()
+			for _, listener := range s.rejected {
+				listener(deviceID, content)
+			}
+		}
+		return nil, fmt.Errorf("条码格式无效: %s", msg)
 	}
 	
 	// 处理条码数据
@@ -43,47 +153,178 @@ func (s *BarcodeService) HandleBarcode(content string) error {
 	
 	// 保存到数据库
 	record := &models.BarcodeRecord{
-		Content: barcodeData.Content,
-		Length:  barcodeData.Length,
-		Type:    barcodeData.Type,
-		Status:  barcodeData.Status,
-		Message: barcodeData.Message,
+		Content:           barcodeData.Content,
+		Length:            barcodeData.Length,
+		Type:              barcodeData.Type,
+		Status:            barcodeData.Status,
+		Message:           barcodeData.Message,
+		DurationMS:        scan.Duration.Milliseconds(),
+		SuspectTruncation: scan.SuspectTruncation,
+		Derived:           barcodeData.Derived,
+		Source:            scan.Source,
+		Input:             scan.Input,
+		CreatedAt:         models.ScanTime(scan.ScannedAt),
+		ScanID:            scan.ScanID,
+	}
+	if scan.Intervals != nil {
+		record.MinKeyIntervalMS = scan.Intervals.Min.Milliseconds()
+		record.MaxKeyIntervalMS = scan.Intervals.Max.Milliseconds()
+		record.AvgKeyIntervalMS = float64(scan.Intervals.Avg.Microseconds()) / 1000
+	}
+	if record.Source == "" {
+		record.Source = models.BarcodeSourceLocal
+	}
+	if record.ScanID == "" {
+		record.ScanID = newScanID()
 	}
 	
 	// 尝试关联设备
-	if deviceID := s.getDefaultDeviceID(); deviceID > 0 {
+	if scan.DeviceID != nil {
+		record.DeviceID = scan.DeviceID
+	} else if deviceID := s.getDefaultDeviceID(); deviceID > 0 {
 		record.DeviceID = &deviceID
 	}
 	
-	if err := s.db.Create(record).Error; err != nil {
+	for _, listener := range s.received {
+		listener()
+	}
+	
+	// 跨设备合并，只合并指定了设备的扫码
+	settle := func(*models.BarcodeRecord) {}
+	if s.coalescer != nil && scan.DeviceID != nil {
+		primary, secondary, release := s.coalescer.Claim(record.Content, *scan.DeviceID)
+		if primary != nil {
+			return s.mergeScan(primary, secondary, *scan.DeviceID)
+		}
+		settle = release
+	}
+	
+	var seal, touched func(committed bool)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 链首在同一事务中读取及写入，事务回滚时不前移
+		if s.integrity != nil {
+			var err error
+			if seal, err = s.integrity.Seal(tx, record); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		if s.devices != nil && record.DeviceID != nil {
+			// 最后活跃时间只用于在线统计，写入失败不影响扫码入库
+			var err error
+			if touched, err = s.devices.TouchLastSeen(tx, *record.DeviceID, record.CreatedAt); err != nil {
+				s.logger.WithError(err).WithField("device_id", *record.DeviceID).Warn("更新设备最后活跃时间失败")
+			}
+		}
+		if s.outbox == nil {
+			return nil
+		}
+		return s.outbox.Enqueue(tx, "barcode", "barcode:"+record.PublicID, record)
+	})
+	if seal != nil {
+		seal(err == nil)
+	}
+	if touched != nil {
+		touched(err == nil)
+	}
+	if err != nil && s.replica != nil {
+		// 主库不可用时仅写入本地备份库，主库恢复后由对账任务补写
+		if fallback, ferr := s.replica.Fallback(record); fallback {
+			err = ferr
+		}
+	} else if s.replica != nil {
+		s.replica.Mirror(record)
+	}
+	if err != nil {
+		settle(nil)
 		s.logger.WithError(err).Error("保存条码记录失败")
-		return fmt.Errorf("保存条码记录失败: %w", err)
+		return nil, fmt.Errorf("保存条码记录失败: %w", err)
 	}
+	settle(record)
 	
-	s.logger.WithField("record_id", record.ID).Info("条码记录已保存")
+	s.logger.WithFields(logrus.Fields{"barcode": record.Content, "type": record.Type, "record_id": record.ID}).Info("条码记录已保存")
+	
+	for _, listener := range s.listeners {
+		listener(record)
+	}
 	
 	// 执行业务逻辑
 	if err := s.executeBusinessLogic(barcodeData); err != nil {
 		s.logger.WithError(err).Warn("执行业务逻辑失败")
 	}
 	
-	return nil
+	return record, nil
+}
+
+// mergeScan 把设备登记到先到的扫码记录，返回合并后的记录及 ErrScanCoalesced
+func (s *BarcodeService) mergeScan(primary *models.BarcodeRecord, secondary []uint, deviceID uint) (*models.BarcodeRecord, error) {
+	merged := *primary
+	merged.SecondaryDevices = secondary
+	if err := s.db.Model(&models.BarcodeRecord{}).Where("id = ?", primary.ID).
+		Update("secondary_devices", merged.SecondaryDevices).Error; err != nil {
+		// 登记失败不影响合并，推送及投递仍只有一次
+		s.logger.WithError(err).WithField("record_id", primary.ID).Warn("登记合并的扫码设备失败")
+	}
+	if s.devices != nil {
+		if err := s.devices.UpdateDeviceLastSeen(deviceID); err != nil {
+			s.logger.WithError(err).WithField("device_id", deviceID).Warn("更新设备最后活跃时间失败")
+		}
+	}
+	s.logger.WithFields(logrus.Fields{
+		"barcode":   primary.Content,
+		"record_id": primary.ID,
+		"device_id": deviceID,
+	}).Info("不同设备同时扫到同一内容，已合并到一条记录")
+	return &merged, ErrScanCoalesced
+}
+
+// BarcodeFilter 扫码记录查询条件
+type BarcodeFilter struct {
+	DeviceIDs    []uint            // 为空表示不限
+	Type         string
+	Derived      map[string]string // 派生字段 -> 值
+	UnconsumedBy string            // 只返回该消费方尚未确认的记录
+	From         time.Time         // 扫码时间下限（含），零值表示不限
+	To           time.Time         // 扫码时间上限（不含），零值表示不限
+	Order        string            // 排序子句，为空时按扫码时间倒序
 }
 
 // GetBarcodeRecords 获取条码记录列表
-func (s *BarcodeService) GetBarcodeRecords(page, pageSize int, deviceID *uint, barcodeType string) ([]*models.BarcodeRecord, int64, error) {
+func (s *BarcodeService) GetBarcodeRecords(page, pageSize int, filter BarcodeFilter) ([]*models.BarcodeRecord, int64, error) {
 	var records []*models.BarcodeRecord
 	var total int64
 	
-	query := s.db.Model(&models.BarcodeRecord{}).Preload("Device")
+	query := s.reader().Model(&models.BarcodeRecord{}).Preload("Device")
 	
 	// 添加过滤条件
-	if deviceID != nil {
-		query = query.Where("device_id = ?", *deviceID)
+	if len(filter.DeviceIDs) > 0 {
+		query = query.Where("device_id IN ?", filter.DeviceIDs)
+	}
+	
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
 	}
 	
-	if barcodeType != "" {
-		query = query.Where("type = ?", barcodeType)
+	// 派生字段按JSON路径过滤，表达式与 EnsureDerivedIndexes 创建的索引一致
+	for field, value := range filter.Derived {
+		if !barcode.ValidFieldName(field) {
+			return nil, 0, fmt.Errorf("派生字段名无效: %s", field)
+		}
+		query = query.Where(derivedFieldExpr(field)+" = ?", value)
+	}
+	
+	if filter.UnconsumedBy != "" {
+		query = query.Where(unconsumedCondition, filter.UnconsumedBy)
+	}
+	
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+	
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To.UTC())
 	}
 	
 	// 获取总数
@@ -91,9 +332,14 @@ func (s *BarcodeService) GetBarcodeRecords(page, pageSize int, deviceID *uint, b
 		return nil, 0, err
 	}
 	
+	order := filter.Order
+	if order == "" {
+		order = "created_at DESC, id DESC"
+	}
+	
 	// 分页查询
 	offset := (page - 1) * pageSize
-	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
+	if err := query.Order(order).Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
 		return nil, 0, err
 	}
 	
@@ -103,32 +349,55 @@ func (s *BarcodeService) GetBarcodeRecords(page, pageSize int, deviceID *uint, b
 // GetBarcodeRecord 获取单个条码记录
 func (s *BarcodeService) GetBarcodeRecord(id uint) (*models.BarcodeRecord, error) {
 	var record models.BarcodeRecord
-	if err := s.db.Preload("Device").First(&record, id).Error; err != nil {
+	if err := s.reader().Preload("Device").First(&record, id).Error; err != nil {
 		return nil, err
 	}
 	return &record, nil
 }
 
+// GetBarcodeRecordByPublicID 根据公开ID获取条码记录
+func (s *BarcodeService) GetBarcodeRecordByPublicID(publicID string) (*models.BarcodeRecord, error) {
+	var record models.BarcodeRecord
+	if err := s.reader().Preload("Device").Where("public_id = ?", publicID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetLastScanTime 获取最近一次扫码的时间，没有记录时返回nil
+func (s *BarcodeService) GetLastScanTime() (*time.Time, error) {
+	var records []models.BarcodeRecord
+	if err := s.reader().Select("created_at").Order("id DESC").Limit(1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0].CreatedAt, nil
+}
+
 // DeleteBarcodeRecord 删除条码记录
 func (s *BarcodeService) DeleteBarcodeRecord(id uint) error {
 	return s.db.Delete(&models.BarcodeRecord{}, id).Error
 }
 
-// GetBarcodeStats 获取条码统计信息
-func (s *BarcodeService) GetBarcodeStats() (map[string]interface{}, error) {
+// GetBarcodeStats 获取条码统计信息，"今日"及按天统计以 loc 时区的自然日为界
+func (s *BarcodeService) GetBarcodeStats(loc *time.Location) (map[string]interface{}, error) {
 	stats := make(map[string]interface{})
+	db := s.statsReader()
 	
 	// 总条码数
 	var totalCount int64
-	if err := s.db.Model(&models.BarcodeRecord{}).Count(&totalCount).Error; err != nil {
+	if err := db.Model(&models.BarcodeRecord{}).Count(&totalCount).Error; err != nil {
 		return nil, err
 	}
 	stats["total_count"] = totalCount
 	
 	// 今日条码数
-	today := time.Now().Truncate(24 * time.Hour)
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
 	var todayCount int64
-	if err := s.db.Model(&models.BarcodeRecord{}).Where("created_at >= ?", today).Count(&todayCount).Error; err != nil {
+	if err := db.Model(&models.BarcodeRecord{}).Where("created_at >= ?", today).Count(&todayCount).Error; err != nil {
 		return nil, err
 	}
 	stats["today_count"] = todayCount
@@ -138,40 +407,83 @@ func (s *BarcodeService) GetBarcodeStats() (map[string]interface{}, error) {
 		Type  string `json:"type"`
 		Count int64  `json:"count"`
 	}
-	if err := s.db.Model(&models.BarcodeRecord{}).Select("type, count(*) as count").Group("type").Find(&typeStats).Error; err != nil {
+	if err := db.Model(&models.BarcodeRecord{}).Select("type, count(*) as count").Group("type").Find(&typeStats).Error; err != nil {
 		return nil, err
 	}
 	stats["type_stats"] = typeStats
 	
-	// 最近7天统计
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-	var recentStats []struct {
+	// 按来源统计，外部系统推送的扫码与本机扫码分开
+	type sourceCount struct {
+		Source string `json:"source"`
+		Count  int64  `json:"count"`
+		Today  int64  `json:"today"`
+	}
+	var sourceStats []sourceCount
+	if err := db.Model(&models.BarcodeRecord{}).
+		Select("source, count(*) as count, sum(case when created_at >= ? then 1 else 0 end) as today", today).
+		Group("source").Find(&sourceStats).Error; err != nil {
+		return nil, err
+	}
+	stats["source_stats"] = sourceStats
+	
+	// 最近7天统计，逐天按时区的零点划分，夏令时切换日为23或25小时
+	type dayCount struct {
 		Date  string `json:"date"`
 		Count int64  `json:"count"`
 	}
-	if err := s.db.Model(&models.BarcodeRecord{}).
-		Select("DATE(created_at) as date, count(*) as count").
-		Where("created_at >= ?", sevenDaysAgo).
-		Group("DATE(created_at)").
-		Order("date").
-		Find(&recentStats).Error; err != nil {
-		return nil, err
+	recentStats := make([]dayCount, 0, 8)
+	for i := -7; i <= 0; i++ {
+		start := time.Date(now.Year(), now.Month(), now.Day()+i, 0, 0, 0, 0, loc)
+		end := time.Date(now.Year(), now.Month(), now.Day()+i+1, 0, 0, 0, 0, loc)
+		var count int64
+		if err := db.Model(&models.BarcodeRecord{}).
+			Where("created_at >= ? AND created_at < ?", start, end).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			recentStats = append(recentStats, dayCount{Date: start.Format("2006-01-02"), Count: count})
+		}
 	}
 	stats["recent_stats"] = recentStats
+	stats["timezone"] = loc.String()
 	
 	return stats, nil
 }
 
-// CleanupOldRecords 清理旧记录
-func (s *BarcodeService) CleanupOldRecords(days int) (int64, error) {
+// CleanupOldRecords 清理旧记录，force 为 false 时保留登记的消费方尚未确认的记录
+func (s *BarcodeService) CleanupOldRecords(days int, force bool) (int64, error) {
 	cutoffDate := time.Now().AddDate(0, 0, -days)
 	
-	result := s.db.Where("created_at < ?", cutoffDate).Delete(&models.BarcodeRecord{})
-	if result.Error != nil {
-		return 0, result.Error
+	var result *gorm.DB
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("created_at < ?", cutoffDate)
+		if !force {
+			for _, consumer := range s.consumers {
+				query = query.Where(consumedCondition, consumer)
+			}
+		}
+		if result = query.Delete(&models.BarcodeRecord{}); result.Error != nil {
+			return result.Error
+		}
+		// 被清理的部分在哈希链上留下检查点，之后物理删除这些记录时剩余的链仍可校验
+		if s.integrity != nil {
+			return s.integrity.Anchor(tx, cutoffDate, models.CheckpointReasonRetention)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 	
-	s.logger.WithField("deleted_count", result.RowsAffected).WithField("cutoff_date", cutoffDate).Info("清理旧条码记录")
+	entry := s.logger.WithField("deleted_count", result.RowsAffected).WithField("cutoff_date", cutoffDate)
+	if !force && len(s.consumers) > 0 {
+		var retained int64
+		if err := s.db.Model(&models.BarcodeRecord{}).Where("created_at < ?", cutoffDate).Count(&retained).Error; err == nil && retained > 0 {
+			entry = entry.WithField("retained_count", retained)
+		}
+	}
+	entry.Info("清理旧条码记录")
 	return result.RowsAffected, nil
 }
 
@@ -180,7 +492,7 @@ func (s *BarcodeService) SearchBarcodes(keyword string, page, pageSize int) ([]*
 	var records []*models.BarcodeRecord
 	var total int64
 	
-	query := s.db.Model(&models.BarcodeRecord{}).Preload("Device")
+	query := s.reader().Model(&models.BarcodeRecord{}).Preload("Device")
 	
 	if keyword != "" {
 		keyword = "%" + keyword + "%"
@@ -194,20 +506,124 @@ func (s *BarcodeService) SearchBarcodes(keyword string, page, pageSize int) ([]*
 	
 	// 分页查询
 	offset := (page - 1) * pageSize
-	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
 		return nil, 0, err
 	}
 	
 	return records, total, nil
 }
 
-// getDefaultDeviceID 获取默认设备ID
+// GetBarcodeRecordsBetween 获取时间范围 [from, to) 内的条码记录，按时间升序
+func (s *BarcodeService) GetBarcodeRecordsBetween(from, to time.Time) ([]*models.BarcodeRecord, error) {
+	var records []*models.BarcodeRecord
+	if err := s.reader().Preload("Device").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at, id").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// RangeStats 时间范围内的扫码统计
+type RangeStats struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Total    int64         `json:"total"`
+	ByType   []TypeCount   `json:"by_type"`
+	ByDevice []DeviceCount `json:"by_device"`
+}
+
+// TypeCount 按条码类型统计的扫码数
+type TypeCount struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// DeviceCount 按设备统计的扫码数
+type DeviceCount struct {
+	DeviceID *uint  `json:"device_id"`
+	Name     string `json:"name"`
+	Count    int64  `json:"count"`
+}
+
+// GetRangeStats 统计时间范围 [from, to) 内的扫码数
+func (s *BarcodeService) GetRangeStats(from, to time.Time) (*RangeStats, error) {
+	db := s.statsReader()
+	stats := &RangeStats{From: from, To: to}
+	
+	scope := func() *gorm.DB {
+		return db.Model(&models.BarcodeRecord{}).Where("barcode_records.created_at >= ? AND barcode_records.created_at < ?", from, to)
+	}
+	
+	if err := scope().Count(&stats.Total).Error; err != nil {
+		return nil, err
+	}
+	
+	if err := scope().Select("type, count(*) as count").Group("type").Order("count DESC").Find(&stats.ByType).Error; err != nil {
+		return nil, err
+	}
+	
+	if err := scope().
+		Select("barcode_records.device_id as device_id, devices.name as name, count(*) as count").
+		Joins("LEFT JOIN devices ON devices.id = barcode_records.device_id").
+		Group("barcode_records.device_id, devices.name").
+		Order("count DESC").
+		Find(&stats.ByDevice).Error; err != nil {
+		return nil, err
+	}
+	
+	return stats, nil
+}
+
+// getDefaultDeviceID 获取默认设备ID，查询结果缓存 defaultDeviceTTL
 func (s *BarcodeService) getDefaultDeviceID() uint {
+	s.defaultMu.Lock()
+	defer s.defaultMu.Unlock()
+	if time.Since(s.defaultCheckAt) < defaultDeviceTTL {
+		return s.defaultDevice
+	}
+	
 	var device models.Device
-	if err := s.db.Where("is_active = ? AND status = ?", true, "active").First(&device).Error; err != nil {
-		return 0
+	if err := s.reader().Where("station = ? AND is_active = ? AND status = ?", s.station, true, "active").First(&device).Error; err != nil {
+		device.ID = 0
+	}
+	s.defaultDevice = device.ID
+	s.defaultCheckAt = time.Now()
+	return s.defaultDevice
+}
+
+// InvalidateDefaultDevice 设备启用、停用或更新后清除默认设备缓存
+func (s *BarcodeService) InvalidateDefaultDevice() {
+	s.defaultMu.Lock()
+	s.defaultCheckAt = time.Time{}
+	s.defaultMu.Unlock()
+}
+
+// reader 读取使用的数据库，主库不可用时为本地备份库
+func (s *BarcodeService) reader() *gorm.DB {
+	if s.replica != nil {
+		return s.replica.Reader()
 	}
-	return device.ID
+	return s.db
+}
+
+// statsReader 统计使用的查询，排除不计入统计的扫码来源
+func (s *BarcodeService) statsReader() *gorm.DB {
+	db := s.reader()
+	if len(s.excluded) == 0 {
+		return db
+	}
+	return db.Where("barcode_records.source NOT IN ?", s.excluded).Session(&gorm.Session{})
+}
+
+// newScanID 生成扫码记录的幂等键
+func newScanID() string {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(raw)
 }
 
 // executeBusinessLogic 执行业务逻辑
@@ -229,35 +645,45 @@ func (s *BarcodeService) executeBusinessLogic(barcodeData *barcode.BarcodeData)
 
 // handleProductBarcode 处理产品条码
 func (s *BarcodeService) handleProductBarcode(barcodeData *barcode.BarcodeData) error {
-	s.logger.WithField("barcode", barcodeData.Content).Info("处理产品条码")
+	if s.logger.IsLevelEnabled(logrus.DebugLevel) {
+		s.logger.WithField("barcode", barcodeData.Content).Debug("处理产品条码")
+	}
 	// 这里可以添加产品查询、库存检查等逻辑
 	return nil
 }
 
 // handleLotBarcode 处理批次条码
 func (s *BarcodeService) handleLotBarcode(barcodeData *barcode.BarcodeData) error {
-	s.logger.WithField("barcode", barcodeData.Content).Info("处理批次条码")
+	if s.logger.IsLevelEnabled(logrus.DebugLevel) {
+		s.logger.WithField("barcode", barcodeData.Content).Debug("处理批次条码")
+	}
 	// 这里可以添加批次追踪、质量检查等逻辑
 	return nil
 }
 
 // handleSerialBarcode 处理序列号条码
 func (s *BarcodeService) handleSerialBarcode(barcodeData *barcode.BarcodeData) error {
-	s.logger.WithField("barcode", barcodeData.Content).Info("处理序列号条码")
+	if s.logger.IsLevelEnabled(logrus.DebugLevel) {
+		s.logger.WithField("barcode", barcodeData.Content).Debug("处理序列号条码")
+	}
 	// 这里可以添加序列号验证、设备注册等逻辑
 	return nil
 }
 
 // handleStandardBarcode 处理标准条码
 func (s *BarcodeService) handleStandardBarcode(barcodeData *barcode.BarcodeData) error {
-	s.logger.WithField("barcode", barcodeData.Content).Info("处理标准条码")
+	if s.logger.IsLevelEnabled(logrus.DebugLevel) {
+		s.logger.WithField("barcode", barcodeData.Content).Debug("处理标准条码")
+	}
 	// 这里可以添加商品查询、价格检查等逻辑
 	return nil
 }
 
 // handleGenericBarcode 处理通用条码
 func (s *BarcodeService) handleGenericBarcode(barcodeData *barcode.BarcodeData) error {
-	s.logger.WithField("barcode", barcodeData.Content).Info("处理通用条码")
+	if s.logger.IsLevelEnabled(logrus.DebugLevel) {
+		s.logger.WithField("barcode", barcodeData.Content).Debug("处理通用条码")
+	}
 	// 这里可以添加通用处理逻辑
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrCaptureUnavailable 没有可暂停的按键采集来源（仅API模式或平台不支持）
+var ErrCaptureUnavailable = errors.New("没有可暂停的按键采集来源")
+
+// CaptureSwitch 可暂停的按键采集来源，由 scanner.PausableSource 实现
+type CaptureSwitch interface {
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// CaptureState 按键采集的暂停状态
+type CaptureState struct {
+	Available bool       `json:"available"` // 有可暂停的按键采集来源
+	Paused    bool       `json:"paused"`
+	PausedAt  *time.Time `json:"paused_at,omitempty"`
+	PausedBy  string     `json:"paused_by,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// CaptureEvent 按键采集暂停/恢复事件
+type CaptureEvent struct {
+	Action string       `json:"action"` // pause, resume
+	Actor  string       `json:"actor"`
+	State  CaptureState `json:"state"`
+}
+
+// CaptureService 按键采集暂停/恢复
+//
+// 暂停期间键盘钩子保持安装，按键原样传给前台程序，不产生扫码记录；状态只在本次运行内有效，重启后恢复采集。
+// 串口及网络扫码枪不受影响。
+type CaptureService struct {
+	source    CaptureSwitch
+	logger    *logrus.Logger
+	mu        sync.Mutex
+	state     CaptureState
+	listeners []func(CaptureEvent)
+}
+
+// NewCaptureService 创建按键采集暂停服务，source 为nil表示没有可暂停的采集来源
+func NewCaptureService(source CaptureSwitch, logger *logrus.Logger) *CaptureService {
+	return &CaptureService{
+		source: source,
+		logger: logger,
+		state:  CaptureState{Available: source != nil},
+	}
+}
+
+// OnChange 注册状态变更回调
+func (s *CaptureService) OnChange(listener func(CaptureEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Pause 暂停按键采集，已暂停时返回当前状态
+func (s *CaptureService) Pause(actor, reason string) (CaptureState, error) {
+	if s.source == nil {
+		return s.GetState(), ErrCaptureUnavailable
+	}
+
+	s.mu.Lock()
+	if s.state.Paused {
+		state := s.state
+		s.mu.Unlock()
+		return state, nil
+	}
+	s.source.Pause()
+	now := time.Now()
+	s.state = CaptureState{Available: true, Paused: true, PausedAt: &now, PausedBy: actor, Reason: reason}
+	state := s.state
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	s.logger.WithFields(logrus.Fields{"actor": actor, "reason": reason}).Warn("扫码采集已暂停")
+	s.notify(listeners, CaptureEvent{Action: "pause", Actor: actor, State: state})
+	return state, nil
+}
+
+// Resume 恢复按键采集，未暂停时返回当前状态
+func (s *CaptureService) Resume(actor string) (CaptureState, error) {
+	if s.source == nil {
+		return s.GetState(), ErrCaptureUnavailable
+	}
+
+	s.mu.Lock()
+	if !s.state.Paused {
+		state := s.state
+		s.mu.Unlock()
+		return state, nil
+	}
+	s.source.Resume()
+	s.state = CaptureState{Available: true}
+	state := s.state
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	s.logger.WithField("actor", actor).Info("扫码采集已恢复")
+	s.notify(listeners, CaptureEvent{Action: "resume", Actor: actor, State: state})
+	return state, nil
+}
+
+// GetState 获取当前状态
+func (s *CaptureService) GetState() CaptureState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// IsPaused 是否已暂停采集
+func (s *CaptureService) IsPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Paused
+}
+
+// notify 通知状态变更
+func (s *CaptureService) notify(listeners []func(CaptureEvent), event CaptureEvent) {
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
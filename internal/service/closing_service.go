@@ -0,0 +1,734 @@
+package service
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+)
+
+// closingMailTimeout 发送日结汇总邮件的超时
+const closingMailTimeout = 10 * time.Second
+
+var (
+	// ErrInvalidClosing 日结请求无效
+	ErrInvalidClosing = errors.New("日结请求无效")
+	// ErrDayNotClosed 生产日尚未日结
+	ErrDayNotClosed = errors.New("生产日尚未日结")
+)
+
+// 日结事件动作
+const (
+	ClosingActionClose  = "close"
+	ClosingActionReopen = "reopen"
+)
+
+// ClosingActor 日结操作者，用于审计
+type ClosingActor struct {
+	Name      string
+	IP        string
+	UserAgent string
+}
+
+// DayClosingEvent 生产日日结或重新开放事件
+type DayClosingEvent struct {
+	Action  string               `json:"action"` // close, reopen
+	Day     string               `json:"day"`
+	Closed  bool                 `json:"closed"`
+	By      string               `json:"by"`
+	At      time.Time            `json:"at"`
+	Summary *models.DailySummary `json:"summary,omitempty"`
+}
+
+// closedDay 已日结生产日的时间范围
+type closedDay struct {
+	from, to time.Time
+}
+
+// ClosingService 日结
+//
+// 日结生成生产日的汇总（按类型、设备、操作员的扫码数，无效及重复扫码数，产量目标达成情况）
+// 并锁定当天的扫码记录；仍在进行的序列号跟踪会话转入下一生产日并在汇总中注明。同一生产日
+// 重复日结返回已有的汇总，重新开放后再次日结时重新生成。生产日与班次划分与产量目标相同。
+type ClosingService struct {
+	db        *gorm.DB
+	config    config.ClosingConfig
+	station   string
+	dayOffset int // 生产日起点，当天零点后的分钟数
+	location  *time.Location
+	goals     *GoalService
+	sequence  *SequenceService
+	quality   *QualityService
+	operators *OperatorStatsService
+	logger    *logrus.Logger
+
+	mu        sync.RWMutex
+	closed    map[string]closedDay
+	listeners []func(DayClosingEvent)
+}
+
+// NewClosingService 创建日结服务，shifts 为生产班次配置，loc 为工位时区
+func NewClosingService(db *gorm.DB, cfg config.ClosingConfig, shifts []config.ShiftWindow, loc *time.Location, station string, logger *logrus.Logger) (*ClosingService, error) {
+	windows, err := parseShifts(shifts)
+	if err != nil {
+		return nil, err
+	}
+	return &ClosingService{
+		db:        db,
+		config:    cfg,
+		station:   station,
+		dayOffset: productionDayOffset(windows),
+		location:  loc,
+		logger:    logger,
+		closed:    make(map[string]closedDay),
+	}, nil
+}
+
+// SetGoals 设置产量目标服务，汇总中包含目标达成情况
+func (s *ClosingService) SetGoals(goals *GoalService) {
+	s.goals = goals
+}
+
+// SetOperatorStats 设置操作员统计服务，汇总中的操作员按其隐私设置处理并包含按操作员的效率
+func (s *ClosingService) SetOperatorStats(operators *OperatorStatsService) {
+	s.operators = operators
+}
+
+// SetSequence 设置序列号断号检测服务，仍在进行的跟踪会话转入下一生产日
+func (s *ClosingService) SetSequence(sequence *SequenceService) {
+	s.sequence = sequence
+}
+
+// SetQuality 设置数据质量报告服务，导出及邮件中附带生产日的数据质量报告
+func (s *ClosingService) SetQuality(quality *QualityService) {
+	s.quality = quality
+}
+
+// OnChange 注册日结及重新开放的回调
+func (s *ClosingService) OnChange(listener func(DayClosingEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Load 加载已日结的生产日
+func (s *ClosingService) Load() error {
+	var summaries []*models.DailySummary
+	if err := s.db.Select("day", "from", "to").Where("closed = ?", true).Find(&summaries).Error; err != nil {
+		return fmt.Errorf("加载日结记录失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, summary := range summaries {
+		s.closed[summary.Day] = closedDay{from: summary.From, to: summary.To}
+	}
+	return nil
+}
+
+// Today 当前生产日
+func (s *ClosingService) Today() string {
+	local := time.Now().In(s.location)
+	if local.Hour()*60+local.Minute() < s.dayOffset {
+		local = local.AddDate(0, 0, -1)
+	}
+	return local.Format(dayLayout)
+}
+
+// LockedDay 扫码时间所属的生产日已日结时返回该生产日
+func (s *ClosingService) LockedDay(at time.Time) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for day, bounds := range s.closed {
+		if !at.Before(bounds.from) && at.Before(bounds.to) {
+			return day, true
+		}
+	}
+	return "", false
+}
+
+// CloseDay 日结生产日，day 为空表示当前生产日；已日结时返回已有的汇总，created 为 false
+func (s *ClosingService) CloseDay(day, note string, actor ClosingActor) (summary *models.DailySummary, created bool, err error) {
+	if day == "" {
+		day = s.Today()
+	}
+	from, to, err := s.bounds(day)
+	if err != nil {
+		return nil, false, err
+	}
+	if day > s.Today() {
+		return nil, false, fmt.Errorf("%w: 不能日结未来的生产日 %s", ErrInvalidClosing, day)
+	}
+
+	var existing models.DailySummary
+	err = s.db.Where("day = ?", day).First(&existing).Error
+	switch {
+	case err == nil && existing.Closed:
+		return &existing, false, nil
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, false, fmt.Errorf("查询日结记录失败: %w", err)
+	}
+
+	summary, err = s.summarize(day, from, to)
+	if err != nil {
+		return nil, false, err
+	}
+	summary.Note = note
+	summary.ClosedBy = actor.Name
+	summary.ClosedAt = time.Now()
+	if existing.ID != 0 {
+		// 重新开放后再次日结，保留重新开放的记录
+		summary.ID = existing.ID
+		summary.CreatedAt = existing.CreatedAt
+		summary.ReopenedBy = existing.ReopenedBy
+		summary.ReopenedAt = existing.ReopenedAt
+	}
+	if err := s.db.Save(summary).Error; err != nil {
+		return nil, false, fmt.Errorf("保存日结汇总失败: %w", err)
+	}
+
+	s.mu.Lock()
+	s.closed[day] = closedDay{from: from, to: to}
+	s.mu.Unlock()
+
+	s.deliver(summary)
+	s.audit("closing:close", fmt.Sprintf("生产日 %s 已日结", day), day, actor, map[string]interface{}{"total": summary.Total, "note": note})
+	s.logger.WithFields(logrus.Fields{
+		"day":   day,
+		"total": summary.Total,
+		"actor": actor.Name,
+	}).Info("生产日已日结")
+	s.notify(DayClosingEvent{Action: ClosingActionClose, Day: day, Closed: true, By: actor.Name, At: summary.ClosedAt, Summary: summary})
+	return summary, true, nil
+}
+
+// ReopenDay 重新开放已日结的生产日，当天的扫码记录恢复可修改
+func (s *ClosingService) ReopenDay(day, reason string, actor ClosingActor) (*models.DailySummary, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("%w: 须填写重新开放的原因", ErrInvalidClosing)
+	}
+	if _, _, err := s.bounds(day); err != nil {
+		return nil, err
+	}
+
+	var summary models.DailySummary
+	if err := s.db.Where("day = ?", day).First(&summary).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrDayNotClosed, day)
+		}
+		return nil, fmt.Errorf("查询日结记录失败: %w", err)
+	}
+	if !summary.Closed {
+		return nil, fmt.Errorf("%w: %s", ErrDayNotClosed, day)
+	}
+
+	now := time.Now()
+	summary.Closed = false
+	summary.ReopenedBy = actor.Name
+	summary.ReopenedAt = &now
+	if err := s.db.Save(&summary).Error; err != nil {
+		return nil, fmt.Errorf("保存日结记录失败: %w", err)
+	}
+
+	s.mu.Lock()
+	delete(s.closed, day)
+	s.mu.Unlock()
+
+	s.audit("closing:reopen", fmt.Sprintf("生产日 %s 已重新开放", day), day, actor, map[string]interface{}{"reason": reason})
+	s.logger.WithFields(logrus.Fields{
+		"day":    day,
+		"actor":  actor.Name,
+		"reason": reason,
+	}).Warn("已日结的生产日被重新开放")
+	s.notify(DayClosingEvent{Action: ClosingActionReopen, Day: day, Closed: false, By: actor.Name, At: now})
+	return &summary, nil
+}
+
+// GetSummary 获取生产日的日结汇总，day 为 today 表示当前生产日
+func (s *ClosingService) GetSummary(day string) (*models.DailySummary, error) {
+	if day == "today" {
+		day = s.Today()
+	}
+	if _, _, err := s.bounds(day); err != nil {
+		return nil, err
+	}
+	var summary models.DailySummary
+	if err := s.db.Where("day = ?", day).First(&summary).Error; err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetSummaries 获取日期范围内（含首尾）的日结汇总，按生产日倒序
+func (s *ClosingService) GetSummaries(from, to string) ([]*models.DailySummary, error) {
+	var summaries []*models.DailySummary
+	if err := s.db.Where("day >= ? AND day <= ?", from, to).Order("day DESC").Find(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("查询日结汇总失败: %w", err)
+	}
+	return summaries, nil
+}
+
+// AuditOverride 记录管理员强制修改已日结生产日的扫码记录
+func (s *ClosingService) AuditOverride(action string, recordID uint, day string, actor ClosingActor) {
+	s.audit("closing:override", fmt.Sprintf("强制修改已日结生产日 %s 的扫码记录", day), day, actor, map[string]interface{}{
+		"record_id": recordID,
+		"operation": action,
+	})
+	s.logger.WithFields(logrus.Fields{
+		"day":       day,
+		"record_id": recordID,
+		"operation": action,
+		"actor":     actor.Name,
+	}).Warn("管理员强制修改已日结的扫码记录")
+}
+
+// bounds 生产日的时间范围 [from, to)，按日历日期计算，夏令时切换日同样准确
+func (s *ClosingService) bounds(day string) (time.Time, time.Time, error) {
+	date, err := time.ParseInLocation(dayLayout, day, s.location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 生产日格式应为 2006-01-02", ErrInvalidClosing)
+	}
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, s.dayOffset, 0, 0, s.location)
+	to := time.Date(date.Year(), date.Month(), date.Day()+1, 0, s.dayOffset, 0, 0, s.location)
+	return from, to, nil
+}
+
+// summarize 统计生产日的扫码记录生成汇总
+func (s *ClosingService) summarize(day string, from, to time.Time) (*models.DailySummary, error) {
+	var records []*models.BarcodeRecord
+	if err := s.db.Select("content", "type", "status", "device_id", "derived").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+
+	summary := &models.DailySummary{
+		Day:     day,
+		Station: s.station,
+		From:    from,
+		To:      to,
+		Closed:  true,
+		Total:   int64(len(records)),
+	}
+	seen := make(map[string]bool, len(records))
+	byType := make(map[string]int64)
+	byOperator := make(map[string]int64)
+	byDevice := make(map[uint]int64)
+	var unassigned int64
+	for _, record := range records {
+		if record.Status != "success" {
+			summary.Invalid++
+		}
+		if seen[record.Content] {
+			summary.Duplicates++
+		}
+		seen[record.Content] = true
+		byType[record.Type]++
+		if operator := record.Derived[s.config.OperatorField]; s.config.OperatorField != "" && operator != "" {
+			byOperator[operator]++
+		}
+		if record.DeviceID != nil {
+			byDevice[*record.DeviceID]++
+		} else {
+			unassigned++
+		}
+	}
+
+	breakdown := &summary.Breakdown
+	breakdown.ByType = sortedCounts(byType)
+	breakdown.ByOperator = sortedCounts(byOperator)
+	if s.operators != nil {
+		breakdown.ByOperator = s.operators.MaskCounts(breakdown.ByOperator)
+		report, err := s.operators.Report(from, to)
+		if err != nil && !errors.Is(err, ErrOperatorStatsDisabled) {
+			return nil, err
+		}
+		if report != nil {
+			breakdown.Operators = report.Operators
+		}
+	}
+	breakdown.Goals = []models.DailyGoal{}
+	breakdown.CarriedOver = []models.CarriedSession{}
+	devices, err := s.deviceTotals(byDevice, unassigned)
+	if err != nil {
+		return nil, err
+	}
+	breakdown.ByDevice = devices
+
+	if s.goals != nil {
+		attainments, err := s.goals.GetAttainment(day, day, s.location)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attainments {
+			breakdown.Goals = append(breakdown.Goals, models.DailyGoal{
+				GoalID:    a.GoalID,
+				Name:      a.Name,
+				Target:    a.Target,
+				Count:     a.Count,
+				Percent:   a.Percent,
+				Completed: a.Completed,
+			})
+		}
+	}
+
+	// 序列号跟踪不随生产日重置，当天推进过的会话转入下一生产日继续跟踪
+	if s.sequence != nil {
+		sessions, err := s.sequence.ActiveSessions(from, to)
+		if err != nil {
+			return nil, err
+		}
+		next := to.Format(dayLayout)
+		for _, session := range sessions {
+			note := fmt.Sprintf("日结时仍在跟踪，转入 %s 继续", next)
+			if session.Pending > 0 {
+				note = fmt.Sprintf("日结时仍有 %d 个缺失序号等待补扫，转入 %s 继续", session.Pending, next)
+			}
+			breakdown.CarriedOver = append(breakdown.CarriedOver, models.CarriedSession{
+				Rule:      session.Rule,
+				Prefix:    session.Prefix,
+				DeviceID:  session.DeviceID,
+				LastValue: session.LastValue,
+				Pending:   session.Pending,
+				Note:      note,
+			})
+		}
+	}
+	return summary, nil
+}
+
+// deviceTotals 按设备统计的扫码数附带设备名称，按扫码数倒序
+func (s *ClosingService) deviceTotals(counts map[uint]int64, unassigned int64) ([]models.DeviceTotal, error) {
+	ids := make([]uint, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	names := make(map[uint]string, len(ids))
+	if len(ids) > 0 {
+		var devices []*models.Device
+		if err := s.db.Unscoped().Select("id", "name").Where("id IN ?", ids).Find(&devices).Error; err != nil {
+			return nil, fmt.Errorf("查询设备失败: %w", err)
+		}
+		for _, device := range devices {
+			names[device.ID] = device.Name
+		}
+	}
+
+	totals := make([]models.DeviceTotal, 0, len(ids)+1)
+	for _, id := range ids {
+		id := id
+		totals = append(totals, models.DeviceTotal{DeviceID: &id, Name: names[id], Count: counts[id]})
+	}
+	if unassigned > 0 {
+		totals = append(totals, models.DeviceTotal{Count: unassigned})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Count > totals[j].Count
+	})
+	return totals, nil
+}
+
+// sortedCounts 按扫码数倒序、名称升序排列
+func sortedCounts(counts map[string]int64) []models.NamedCount {
+	result := make([]models.NamedCount, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, models.NamedCount{Name: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// deliver 按配置导出汇总文件及发送邮件，失败只记录日志，不影响日结
+func (s *ClosingService) deliver(summary *models.DailySummary) {
+	if s.config.ExportDir == "" && !s.config.Email.Enable {
+		return
+	}
+
+	// 数据质量报告计算失败时照常导出及发送汇总，只是不附带报告
+	var quality *models.QualityReport
+	if s.quality != nil {
+		report, err := s.quality.Report(summary.From, summary.To, nil)
+		if err != nil {
+			s.logger.WithError(err).WithField("day", summary.Day).Warn("生成生产日的数据质量报告失败")
+		} else {
+			quality = report
+		}
+	}
+
+	changed := false
+	if s.config.ExportDir != "" {
+		path, err := s.export(summary, quality)
+		if err != nil {
+			s.logger.WithError(err).WithField("day", summary.Day).Error("导出日结汇总失败")
+		} else {
+			summary.ExportPath = path
+			changed = true
+		}
+	}
+	if s.config.Email.Enable {
+		if err := s.sendMail(summary, quality); err != nil {
+			s.logger.WithError(err).WithField("day", summary.Day).Error("发送日结汇总邮件失败")
+		} else {
+			now := time.Now()
+			summary.EmailedAt = &now
+			changed = true
+		}
+	}
+	if changed {
+		if err := s.db.Model(summary).Select("export_path", "emailed_at").Updates(summary).Error; err != nil {
+			s.logger.WithError(err).Warn("保存日结汇总的导出状态失败")
+		}
+	}
+}
+
+// export 将汇总写入导出目录，同一生产日再次日结时覆盖；quality 非nil时另写一份数据质量报告
+func (s *ClosingService) export(summary *models.DailySummary, quality *models.QualityReport) (string, error) {
+	if err := os.MkdirAll(s.config.ExportDir, 0755); err != nil {
+		return "", fmt.Errorf("创建导出目录失败: %w", err)
+	}
+	path := filepath.Join(s.config.ExportDir, fmt.Sprintf("daily-summary-%s.json", summary.Day))
+	if err := writeJSONFile(path, summary); err != nil {
+		return "", fmt.Errorf("写入汇总文件失败: %w", err)
+	}
+	if quality != nil {
+		qualityPath := filepath.Join(s.config.ExportDir, fmt.Sprintf("daily-quality-%s.json", summary.Day))
+		if err := writeJSONFile(qualityPath, quality); err != nil {
+			return "", fmt.Errorf("写入数据质量报告失败: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// writeJSONFile 先写临时文件再改名，避免读取方读到写了一半的文件
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// sendMail 以纯文本邮件发送汇总，quality 非nil时附带数据质量报告
+func (s *ClosingService) sendMail(summary *models.DailySummary, quality *models.QualityReport) error {
+	cfg := s.config.Email
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("SMTP服务器地址无效: %w", err)
+	}
+	conn, err := net.DialTimeout("tcp", cfg.Addr, closingMailTimeout)
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(closingMailTimeout))
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("启用TLS失败: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("收件人 %s 被拒绝: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(closingMail(cfg, summary, quality, s.location, i18n.For(i18n.Default()))); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// closingMail 生成汇总邮件，文字按 catalog 的语言输出，时间按工位时区显示
+func closingMail(cfg config.ClosingEmailConfig, summary *models.DailySummary, quality *models.QualityReport, loc *time.Location, catalog *i18n.Catalog) []byte {
+	var b strings.Builder
+	subject := catalog.T("mail.closing.subject", summary.Station, summary.Day)
+	fmt.Fprintf(&b, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Content-Language: %s\r\n", catalog.Locale)
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	count := func(n int64) string { return catalog.FormatInt(n) }
+	percent := func(f float64) string { return catalog.FormatFloat(f, 1) }
+	line := func(code string, args ...interface{}) {
+		b.WriteString(catalog.T(code, args...))
+		b.WriteString("\r\n")
+	}
+
+	line("mail.closing.station", summary.Station)
+	line("mail.closing.day", summary.Day,
+		catalog.FormatDateTime(summary.From.In(loc)), catalog.FormatDateTime(summary.To.In(loc)))
+	line("mail.closing.closed", summary.ClosedBy, catalog.FormatDateTime(summary.ClosedAt.In(loc)))
+	if summary.Note != "" {
+		line("mail.closing.note", summary.Note)
+	}
+	b.WriteString("\r\n")
+	line("mail.closing.total", count(summary.Total))
+	line("mail.closing.invalid", count(summary.Invalid))
+	line("mail.closing.duplicates", count(summary.Duplicates))
+
+	section := func(code string, counts []models.NamedCount) {
+		if len(counts) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\r\n%s:\r\n", catalog.T(code))
+		for _, c := range counts {
+			fmt.Fprintf(&b, "  %s: %s\r\n", c.Name, count(c.Count))
+		}
+	}
+	section("mail.closing.by_type", summary.Breakdown.ByType)
+	devices := make([]models.NamedCount, 0, len(summary.Breakdown.ByDevice))
+	for _, d := range summary.Breakdown.ByDevice {
+		name := d.Name
+		if d.DeviceID == nil {
+			name = catalog.T("mail.closing.no_device")
+		}
+		devices = append(devices, models.NamedCount{Name: name, Count: d.Count})
+	}
+	section("mail.closing.by_device", devices)
+	section("mail.closing.by_operator", summary.Breakdown.ByOperator)
+	if len(summary.Breakdown.Operators) > 0 {
+		fmt.Fprintf(&b, "\r\n%s:\r\n", catalog.T("mail.closing.operators"))
+		for _, o := range summary.Breakdown.Operators {
+			b.WriteString("  ")
+			line("mail.closing.operator", o.Operator, count(o.Total), percent(o.ValidRatio*100),
+				time.Duration(o.ActiveSeconds)*time.Second, percent(o.ScansPerHour))
+		}
+	}
+
+	if len(summary.Breakdown.Goals) > 0 {
+		fmt.Fprintf(&b, "\r\n%s:\r\n", catalog.T("mail.closing.goals"))
+		for _, g := range summary.Breakdown.Goals {
+			b.WriteString("  ")
+			line("mail.closing.goal", g.Name, count(g.Count), count(g.Target), percent(g.Percent))
+		}
+	}
+	if len(summary.Breakdown.CarriedOver) > 0 {
+		fmt.Fprintf(&b, "\r\n%s:\r\n", catalog.T("mail.closing.carried_over"))
+		for _, c := range summary.Breakdown.CarriedOver {
+			b.WriteString("  ")
+			line("mail.closing.carried", c.Rule, c.Prefix, c.DeviceID, c.LastValue, c.Note)
+		}
+	}
+	if quality != nil {
+		writeQualityMail(&b, quality, catalog)
+	}
+	return []byte(b.String())
+}
+
+// writeQualityMail 汇总邮件中的数据质量报告
+func writeQualityMail(b *strings.Builder, quality *models.QualityReport, catalog *i18n.Catalog) {
+	count := func(n int64) string { return catalog.FormatInt(n) }
+	percent := func(f float64) string { return catalog.FormatFloat(f, 1) }
+	b.WriteString("\r\n")
+	b.WriteString(catalog.T("mail.quality.summary", count(quality.Malformed), count(quality.Total), percent(quality.MalformedRate)))
+	b.WriteString("\r\n")
+	for _, t := range quality.Breakdown.ByType {
+		b.WriteString("  ")
+		b.WriteString(catalog.T("mail.quality.type", t.Type, count(t.Total), percent(t.ValidRate)))
+		if t.CheckDigitChecked > 0 {
+			b.WriteString(catalog.T("mail.quality.check_digit", count(t.CheckDigitFailures)))
+		}
+		if t.SpecViolations > 0 {
+			b.WriteString(catalog.T("mail.quality.spec_violations", count(t.SpecViolations)))
+		}
+		b.WriteString("\r\n")
+	}
+	for _, d := range quality.Breakdown.ByDevice {
+		if d.Malformed == 0 {
+			break
+		}
+		name := catalog.T("mail.quality.device", d.Name)
+		if d.DeviceID == nil {
+			name = d.Name
+		}
+		b.WriteString("  ")
+		b.WriteString(catalog.T("mail.quality.device_line", name, count(d.Malformed), count(d.Total), percent(d.MalformedRate)))
+		b.WriteString("\r\n")
+	}
+}
+
+// notify 通知日结及重新开放
+func (s *ClosingService) notify(event DayClosingEvent) {
+	s.mu.RLock()
+	listeners := make([]func(DayClosingEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// audit 写入日结审计日志
+func (s *ClosingService) audit(action, message, day string, actor ClosingActor, fields map[string]interface{}) {
+	fields["day"] = day
+	fields["actor"] = actor.Name
+	extra, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:     "info",
+		Message:   message,
+		Module:    "closing",
+		Action:    action,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		Extra:     string(extra),
+	}
+	if action != "closing:close" {
+		log.Level = "warning"
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入审计日志失败")
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// coalesceMaxWait 合并的扫码等待先到的扫码入库的最长时间，超时后单独入库
+const coalesceMaxWait = 2 * time.Second
+
+// ErrScanCoalesced 扫码已合并到其他设备刚扫到的同一内容的记录，未单独入库、推送及投递
+var ErrScanCoalesced = errors.New("扫码已与其他设备的同一扫码合并")
+
+// DeviceIdentities 按设备ID查找设备名称及所属工位
+type DeviceIdentities interface {
+	Identity(id uint) (DeviceIdentity, bool)
+}
+
+// coalesceEntry 窗口内先到的一次扫码
+type coalesceEntry struct {
+	deviceID  uint
+	at        time.Time
+	window    time.Duration
+	done      chan struct{}         // 先到的扫码入库结束后关闭
+	record    *models.BarcodeRecord // 入库失败时为nil
+	secondary []uint                // 已合并进来的其他设备
+}
+
+// ScanCoalescer 跨设备合并
+//
+// 以扫码设备所属工位及处理后的内容为键，记录窗口内先到的扫码；其他设备在窗口内扫到相同内容时，
+// 等先到的扫码入库后把设备登记到该记录，不再单独入库。只合并指定了设备的扫码（按设备识别的按键来源、
+// 网络扫码枪、外部推送及模拟扫码），归入默认设备的扫码无法区分设备，不合并。
+type ScanCoalescer struct {
+	window   time.Duration
+	stations map[string]time.Duration
+	station  string
+
+	mu        sync.Mutex
+	devices   DeviceIdentities
+	entries   map[string]*coalesceEntry
+	coalesced atomic.Uint64
+	now       func() time.Time
+}
+
+// CoalesceStatus 跨设备合并状态
+type CoalesceStatus struct {
+	Enabled   bool             `json:"enabled"`
+	WindowMS  int64            `json:"window_ms"`
+	Stations  map[string]int64 `json:"stations,omitempty"` // 按工位覆盖的窗口（毫秒）
+	Coalesced uint64           `json:"coalesced"`          // 已合并的扫码数
+}
+
+// NewScanCoalescer 创建跨设备合并，station 为本工位名称，设备未分配工位时按本工位的窗口合并
+func NewScanCoalescer(cfg config.CoalesceConfig, station string) *ScanCoalescer {
+	c := &ScanCoalescer{
+		window:   cfg.Window,
+		stations: make(map[string]time.Duration, len(cfg.Stations)),
+		station:  station,
+		entries:  make(map[string]*coalesceEntry),
+		now:      time.Now,
+	}
+	for _, s := range cfg.Stations {
+		c.stations[s.Station] = s.Window
+	}
+	return c
+}
+
+// SetDevices 设置设备查找，按扫码设备所属的工位选择合并窗口
+func (c *ScanCoalescer) SetDevices(devices DeviceIdentities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.devices = devices
+}
+
+// Claim 登记一次扫码
+//
+// 窗口内已有其他设备扫到相同内容时等待其入库，返回该记录及合并后的其他设备列表；否则返回的 settle
+// 须在入库结束后调用（失败时传nil），之后窗口内其他设备的同一扫码合并到该记录。
+func (c *ScanCoalescer) Claim(content string, deviceID uint) (*models.BarcodeRecord, []uint, func(*models.BarcodeRecord)) {
+	station, window := c.stationWindow(deviceID)
+	if window <= 0 {
+		return nil, nil, func(*models.BarcodeRecord) {}
+	}
+	key := station + "\x00" + content
+
+	c.mu.Lock()
+	now := c.now()
+	c.prune(now)
+	if entry, ok := c.entries[key]; ok && entry.deviceID != deviceID && now.Sub(entry.at) <= entry.window {
+		c.mu.Unlock()
+		if record, secondary := c.join(entry, deviceID); record != nil {
+			return record, secondary, nil
+		}
+		// 先到的扫码入库失败或迟迟未完成，本次扫码单独入库
+		c.mu.Lock()
+		now = c.now()
+	}
+	entry := &coalesceEntry{deviceID: deviceID, at: now, window: window, done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	return nil, nil, func(record *models.BarcodeRecord) {
+		c.mu.Lock()
+		entry.record = record
+		c.mu.Unlock()
+		close(entry.done)
+	}
+}
+
+// join 等待先到的扫码入库并登记本设备，先到的扫码入库失败或超时返回nil
+func (c *ScanCoalescer) join(entry *coalesceEntry, deviceID uint) (*models.BarcodeRecord, []uint) {
+	timer := time.NewTimer(coalesceMaxWait)
+	defer timer.Stop()
+	select {
+	case <-entry.done:
+	case <-timer.C:
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry.record == nil {
+		return nil, nil
+	}
+	for _, id := range entry.secondary {
+		if id == deviceID {
+			return entry.record, append([]uint(nil), entry.secondary...)
+		}
+	}
+	entry.secondary = append(entry.secondary, deviceID)
+	c.coalesced.Add(1)
+	return entry.record, append([]uint(nil), entry.secondary...)
+}
+
+// stationWindow 扫码设备所属工位及其合并窗口
+func (c *ScanCoalescer) stationWindow(deviceID uint) (string, time.Duration) {
+	c.mu.Lock()
+	devices := c.devices
+	c.mu.Unlock()
+
+	station := c.station
+	if devices != nil {
+		if identity, ok := devices.Identity(deviceID); ok && identity.Station != "" {
+			station = identity.Station
+		}
+	}
+	if window, ok := c.stations[station]; ok {
+		return station, window
+	}
+	return station, c.window
+}
+
+// prune 清理已过窗口且入库结束的扫码
+func (c *ScanCoalescer) prune(now time.Time) {
+	for key, entry := range c.entries {
+		if now.Sub(entry.at) <= entry.window {
+			continue
+		}
+		select {
+		case <-entry.done:
+			delete(c.entries, key)
+		default:
+		}
+	}
+}
+
+// Status 获取跨设备合并状态
+func (c *ScanCoalescer) Status() CoalesceStatus {
+	status := CoalesceStatus{
+		Enabled:   true,
+		WindowMS:  c.window.Milliseconds(),
+		Coalesced: c.coalesced.Load(),
+	}
+	if len(c.stations) > 0 {
+		status.Stations = make(map[string]int64, len(c.stations))
+		for station, window := range c.stations {
+			status.Stations[station] = window.Milliseconds()
+		}
+	}
+	return status
+}
+
+// Collect 输出跨设备合并指标
+func (c *ScanCoalescer) Collect() []Metric {
+	return []Metric{
+		CounterMetric("barcode_scans_coalesced_total", "不同设备在合并窗口内扫到同一内容而合并到一条记录的扫码数", float64(c.coalesced.Load())),
+	}
+}
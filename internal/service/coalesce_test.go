@@ -0,0 +1,125 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// stationDevices 按设备ID返回所属工位
+type stationDevices map[uint]string
+
+func (d stationDevices) Identity(id uint) (DeviceIdentity, bool) {
+	station, ok := d[id]
+	return DeviceIdentity{Station: station}, ok
+}
+
+// newTestCoalescer 使用可控时钟的跨设备合并，设备1、2属于 lane-1，设备3、4属于关闭合并的 lane-2
+func newTestCoalescer(window time.Duration) (*ScanCoalescer, *time.Time) {
+	c := NewScanCoalescer(config.CoalesceConfig{
+		Enable:   true,
+		Window:   window,
+		Stations: []config.CoalesceStationConfig{{Station: "lane-2", Window: 0}},
+	}, "lane-1")
+	c.SetDevices(stationDevices{1: "lane-1", 2: "lane-1", 3: "lane-2", 4: "lane-2"})
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+// TestScanCoalescerWindow 合并窗口以先到的扫码为起点，窗口边界上的扫码合并，超出窗口或同一设备不合并
+func TestScanCoalescerWindow(t *testing.T) {
+	window := 80 * time.Millisecond
+	tests := []struct {
+		name   string
+		second uint          // 第二次扫码的设备，第一次为设备1
+		gap    time.Duration // 两次扫码的间隔
+		merged bool
+	}{
+		{name: "simultaneous", second: 2, merged: true},
+		{name: "within window", second: 2, gap: 30 * time.Millisecond, merged: true},
+		{name: "window boundary", second: 2, gap: window, merged: true},
+		{name: "past window", second: 2, gap: window + time.Millisecond},
+		{name: "same device", second: 1, gap: 10 * time.Millisecond},
+		{name: "other station", second: 3, gap: 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, now := newTestCoalescer(window)
+			primary, _, settle := c.Claim("SN000101", 1)
+			if primary != nil {
+				t.Fatal("first scan coalesced")
+			}
+			record := &models.BarcodeRecord{ID: 7, Content: "SN000101"}
+			settle(record)
+
+			*now = now.Add(tt.gap)
+			got, secondary, _ := c.Claim("SN000101", tt.second)
+			if merged := got != nil; merged != tt.merged {
+				t.Fatalf("merged = %v, want %v", merged, tt.merged)
+			}
+			if tt.merged && (got != record || len(secondary) != 1 || secondary[0] != tt.second) {
+				t.Fatalf("merged into %+v with secondary %v", got, secondary)
+			}
+			var want uint64
+			if tt.merged {
+				want = 1
+			}
+			if c.Status().Coalesced != want {
+				t.Fatalf("coalesced = %d, want %d", c.Status().Coalesced, want)
+			}
+		})
+	}
+}
+
+// TestScanCoalescerDisabledStation 关闭合并的工位中不同设备的同一扫码各自入库
+func TestScanCoalescerDisabledStation(t *testing.T) {
+	c, _ := newTestCoalescer(80 * time.Millisecond)
+	_, _, settle := c.Claim("SN000101", 3)
+	settle(&models.BarcodeRecord{ID: 1})
+	if got, _, _ := c.Claim("SN000101", 4); got != nil {
+		t.Fatalf("lane-2 scan coalesced into %+v", got)
+	}
+}
+
+// TestScanCoalescerWaitsForPrimary 先到的扫码入库期间到达的扫码等待其入库后合并；先到的扫码入库失败时单独入库
+func TestScanCoalescerWaitsForPrimary(t *testing.T) {
+	for _, failed := range []bool{false, true} {
+		c, _ := newTestCoalescer(80 * time.Millisecond)
+		_, _, settle := c.Claim("SN000101", 1)
+
+		var wg sync.WaitGroup
+		var got *models.BarcodeRecord
+		var release func(*models.BarcodeRecord)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, _, release = c.Claim("SN000101", 2)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		record := &models.BarcodeRecord{ID: 7}
+		if failed {
+			record = nil
+		}
+		settle(record)
+		wg.Wait()
+
+		if failed {
+			if got != nil || release == nil {
+				t.Fatalf("after failed primary: merged into %+v", got)
+			}
+			// 第二次扫码成为窗口内先到的扫码
+			release(&models.BarcodeRecord{ID: 8})
+			if again, _, _ := c.Claim("SN000101", 1); again == nil || again.ID != 8 {
+				t.Fatalf("third scan merged into %+v, want record 8", again)
+			}
+			continue
+		}
+		if got != record {
+			t.Fatalf("merged into %+v, want the primary record", got)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 命令执行结果
+const (
+	CommandStatusSent       = "sent"        // 已下发且收到设备响应
+	CommandStatusNoResponse = "no_response" // 已下发但超时未收到响应（如触发后未读到条码）
+	CommandStatusFailed     = "failed"      // 连接或发送失败
+	CommandStatusRejected   = "rejected"    // 设备不存在、不支持或命令不在白名单中，未下发
+)
+
+// commandIdleGap 收到首段响应后等待后续数据的时长
+const commandIdleGap = 100 * time.Millisecond
+
+var (
+	// ErrCommandUnsupported 设备未配置命令通道
+	ErrCommandUnsupported = errors.New("设备不支持命令")
+	// ErrCommandNotAllowed 命令不在设备白名单中
+	ErrCommandNotAllowed = errors.New("命令不在设备白名单中")
+	// ErrInvalidCommand 命令或参数无效
+	ErrInvalidCommand = errors.New("命令无效")
+)
+
+// commandPlaceholder 命令模板中的参数占位符
+var commandPlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// DeviceCommand 下发给网络扫码枪的命令
+type DeviceCommand struct {
+	Name string            `json:"name" binding:"required"`
+	Args map[string]string `json:"args"`
+}
+
+// CommandActor 命令操作者，用于审计
+type CommandActor struct {
+	Name      string
+	IP        string
+	UserAgent string
+}
+
+// CommandResult 命令执行结果
+type CommandResult struct {
+	DeviceID   uint      `json:"device_id"`
+	DeviceName string    `json:"device_name"`
+	Name       string    `json:"name"`
+	Status     string    `json:"status"` // sent, no_response, failed, rejected
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Actor      string    `json:"actor"`
+	At         time.Time `json:"at"`
+}
+
+// CommandSettings 设备命令通道设置
+type CommandSettings struct {
+	Addr      string            `json:"command_addr"`
+	Templates map[string]string `json:"command_templates"`
+	Allowed   []string          `json:"allowed_commands"`
+}
+
+// CommandService 网络扫码枪命令通道
+//
+// 固定式网络扫码枪通过TCP接收命令（触发读码、蜂鸣、关闭激光等）。命令按设备上登记的
+// 模板渲染后发送到设备地址，在超时内读取原始响应；只有白名单中的命令允许下发，
+// 每次下发均写入审计日志并通知监听者。
+type CommandService struct {
+	db     *gorm.DB
+	config config.CommandsConfig
+	logger *logrus.Logger
+
+	mu        sync.RWMutex
+	listeners []func(result CommandResult)
+}
+
+// NewCommandService 创建命令通道服务
+func NewCommandService(db *gorm.DB, cfg config.CommandsConfig, logger *logrus.Logger) *CommandService {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 3 * time.Second
+	}
+	if cfg.MaxResponse <= 0 {
+		cfg.MaxResponse = 4096
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 8
+	}
+
+	return &CommandService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// OnResult 注册命令执行结果回调
+func (s *CommandService) OnResult(listener func(result CommandResult)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// SetCommands 设置设备的命令地址、模板及白名单
+func (s *CommandService) SetCommands(deviceID uint, settings CommandSettings) (*models.Device, error) {
+	if settings.Addr != "" {
+		if _, _, err := net.SplitHostPort(settings.Addr); err != nil {
+			return nil, fmt.Errorf("%w: 设备地址须为 host:port 格式", ErrInvalidCommand)
+		}
+	}
+	for _, name := range settings.Allowed {
+		if _, ok := settings.Templates[name]; !ok {
+			return nil, fmt.Errorf("%w: 白名单中的命令 %s 没有模板", ErrInvalidCommand, name)
+		}
+	}
+
+	var device models.Device
+	if err := s.db.First(&device, deviceID).Error; err != nil {
+		return nil, err
+	}
+	device.CommandAddr = settings.Addr
+	device.CommandTemplates = models.StringMap(settings.Templates)
+	device.AllowedCommands = models.StringList(settings.Allowed)
+
+	updates := map[string]interface{}{
+		"command_addr":      device.CommandAddr,
+		"command_templates": device.CommandTemplates,
+		"allowed_commands":  device.AllowedCommands,
+	}
+	if err := s.db.Model(&device).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新设备命令设置失败: %w", err)
+	}
+
+	s.logger.WithField("device_id", deviceID).WithField("allowed", settings.Allowed).Info("设备命令设置已更新")
+	return &device, nil
+}
+
+// Execute 向设备下发命令，设备不存在、不支持或命令不在白名单时返回错误；
+// 连接、发送失败及超时无响应记录在结果中
+func (s *CommandService) Execute(deviceID uint, command DeviceCommand, actor CommandActor) (*CommandResult, error) {
+	var device models.Device
+	if err := s.db.First(&device, deviceID).Error; err != nil {
+		return nil, err
+	}
+
+	payload, err := s.render(&device, command)
+	if err != nil {
+		s.audit(&CommandResult{
+			DeviceID:   device.ID,
+			DeviceName: device.Name,
+			Name:       command.Name,
+			Status:     CommandStatusRejected,
+			Error:      err.Error(),
+			Actor:      actor.Name,
+			At:         time.Now(),
+		}, actor)
+		return nil, err
+	}
+
+	result := s.send(&device, command.Name, payload)
+	result.Actor = actor.Name
+	s.audit(result, actor)
+	s.notify(*result)
+	return result, nil
+}
+
+// ExecuteAll 向多台设备并发下发同一命令，单台设备的错误记录在其结果中
+func (s *CommandService) ExecuteAll(deviceIDs []uint, command DeviceCommand, actor CommandActor) []CommandResult {
+	results := make([]CommandResult, len(deviceIDs))
+	sem := make(chan struct{}, s.config.Concurrency)
+	var wg sync.WaitGroup
+
+	for i, deviceID := range deviceIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, deviceID uint) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := s.Execute(deviceID, command, actor)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = fmt.Errorf("设备不存在")
+			}
+			if err != nil {
+				results[i] = CommandResult{
+					DeviceID: deviceID,
+					Name:     command.Name,
+					Status:   CommandStatusRejected,
+					Error:    err.Error(),
+					Actor:    actor.Name,
+					At:       time.Now(),
+				}
+				return
+			}
+			results[i] = *result
+		}(i, deviceID)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// render 校验白名单并按模板渲染命令
+func (s *CommandService) render(device *models.Device, command DeviceCommand) ([]byte, error) {
+	if device.CommandAddr == "" {
+		return nil, ErrCommandUnsupported
+	}
+	if !device.AllowedCommands.Contains(command.Name) {
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotAllowed, command.Name)
+	}
+	template, ok := device.CommandTemplates[command.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: 命令 %s 没有模板", ErrInvalidCommand, command.Name)
+	}
+
+	// 参数只允许可打印字符，避免通过参数拼接出额外命令
+	for name, value := range command.Args {
+		for _, r := range value {
+			if !unicode.IsPrint(r) {
+				return nil, fmt.Errorf("%w: 参数 %s 含有控制字符", ErrInvalidCommand, name)
+			}
+		}
+	}
+
+	var missing []string
+	rendered := commandPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		value, ok := command.Args[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: 缺少参数 %s", ErrInvalidCommand, strings.Join(missing, ", "))
+	}
+	return []byte(rendered), nil
+}
+
+// send 连接设备发送命令并读取原始响应
+func (s *CommandService) send(device *models.Device, name string, payload []byte) *CommandResult {
+	start := time.Now()
+	result := &CommandResult{
+		DeviceID:   device.ID,
+		DeviceName: device.Name,
+		Name:       name,
+		At:         start,
+	}
+	defer func() {
+		result.DurationMS = time.Since(start).Milliseconds()
+	}()
+
+	conn, err := net.DialTimeout("tcp", device.CommandAddr, s.config.Timeout)
+	if err != nil {
+		result.Status = CommandStatusFailed
+		result.Error = fmt.Sprintf("连接设备失败: %v", err)
+		return result
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(s.config.Timeout))
+	if _, err := conn.Write(payload); err != nil {
+		result.Status = CommandStatusFailed
+		result.Error = fmt.Sprintf("发送命令失败: %v", err)
+		return result
+	}
+
+	response, err := s.readResponse(conn)
+	result.Response = string(response)
+	if len(response) > 0 {
+		result.Status = CommandStatusSent
+		return result
+	}
+	result.Status = CommandStatusNoResponse
+	if err != nil && !isTimeout(err) && err != io.EOF {
+		result.Status = CommandStatusFailed
+		result.Error = fmt.Sprintf("读取响应失败: %v", err)
+	}
+	return result
+}
+
+// readResponse 读取设备响应，收到首段数据后短暂等待剩余数据
+func (s *CommandService) readResponse(conn net.Conn) ([]byte, error) {
+	response := make([]byte, 0, 256)
+	buf := make([]byte, 512)
+	for len(response) < s.config.MaxResponse {
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if err != nil {
+			if len(response) > 0 && isTimeout(err) {
+				err = nil
+			}
+			return truncate(response, s.config.MaxResponse), err
+		}
+		conn.SetReadDeadline(time.Now().Add(commandIdleGap))
+	}
+	return truncate(response, s.config.MaxResponse), nil
+}
+
+// audit 写入命令审计日志
+func (s *CommandService) audit(result *CommandResult, actor CommandActor) {
+	entry := s.logger.WithField("device_id", result.DeviceID).WithField("command", result.Name).WithField("actor", actor.Name)
+	level, message := "info", fmt.Sprintf("下发命令 %s 到设备 %s: %s", result.Name, result.DeviceName, result.Status)
+	switch {
+	case result.Status == CommandStatusRejected:
+		level, message = "warning", fmt.Sprintf("拒绝下发命令 %s 到设备 %s: %s", result.Name, result.DeviceName, result.Error)
+		entry.Warn(message)
+	case result.Status == CommandStatusFailed:
+		level = "warning"
+		entry.WithField("error", result.Error).Warn(message)
+	default:
+		entry.Info(message)
+	}
+
+	extra, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:     level,
+		Message:   message,
+		Module:    "device",
+		Action:    "device:command",
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		Extra:     string(extra),
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入审计日志失败")
+	}
+}
+
+// notify 通知命令执行结果
+func (s *CommandService) notify(result CommandResult) {
+	s.mu.RLock()
+	listeners := make([]func(CommandResult), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(result)
+	}
+}
+
+// isTimeout 判断是否为网络超时
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// truncate 截断到最大长度
+func truncate(data []byte, max int) []byte {
+	if len(data) > max {
+		return data[:max]
+	}
+	return data
+}
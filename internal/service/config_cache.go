@@ -0,0 +1,187 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// ConfigReader 按键读取系统配置，ConfigService 与 ConfigCache 都实现
+type ConfigReader interface {
+	GetConfiguration(key string) (*models.Configuration, error)
+}
+
+// ConfigCache 系统配置缓存，按键缓存配置表中的值（包括不存在的键）
+//
+// 通过配置服务写入时按通知立即失效；克隆导入、中心同步等直接写入配置表的修改在 TTL 到期后
+// 重新查询时生效，此时发现值已变化计入 stale。未命中时查询数据库，返回值与 ConfigService.GetConfiguration 相同。
+type ConfigCache struct {
+	configs       *ConfigService
+	ttl           time.Duration
+	mu            sync.RWMutex
+	entries       map[string]*configCacheEntry
+	generation    uint64
+	hits          int64
+	misses        int64
+	stale         int64
+	invalidations int64
+}
+
+// configCacheEntry 缓存条目，config 为nil表示配置不存在
+type configCacheEntry struct {
+	config    *models.Configuration
+	expiresAt time.Time
+}
+
+// ConfigCacheMetrics 配置缓存指标
+type ConfigCacheMetrics struct {
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Stale         int64   `json:"stale"` // TTL 到期重新查询时发现值已被绕过配置服务修改的次数，此前的命中读到的是旧值
+	Invalidations int64   `json:"invalidations"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Entries       int     `json:"entries"`
+	TTL           string  `json:"ttl"`
+}
+
+// NewConfigCache 创建系统配置缓存，并在配置服务写入配置时失效
+func NewConfigCache(configs *ConfigService, ttl time.Duration) *ConfigCache {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	c := &ConfigCache{
+		configs: configs,
+		ttl:     ttl,
+		entries: make(map[string]*configCacheEntry),
+	}
+	configs.OnInvalidate(c.Invalidate)
+	return c
+}
+
+// GetConfiguration 获取单个配置，配置不存在时返回 gorm.ErrRecordNotFound；返回的是副本，可以修改
+func (c *ConfigCache) GetConfiguration(key string) (*models.Configuration, error) {
+	c.mu.RLock()
+	entry, cached := c.entries[key]
+	generation := c.generation
+	c.mu.RUnlock()
+	if cached && time.Now().Before(entry.expiresAt) {
+		atomic.AddInt64(&c.hits, 1)
+		return entry.result()
+	}
+
+	atomic.AddInt64(&c.misses, 1)
+	config, err := c.configs.GetConfiguration(key)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		// 查询失败不缓存，下次重新查询
+		return nil, err
+	}
+	if cached && !sameConfigValue(entry.config, config) {
+		atomic.AddInt64(&c.stale, 1)
+	}
+
+	fresh := &configCacheEntry{config: config, expiresAt: time.Now().Add(c.ttl)}
+	c.mu.Lock()
+	// 查询期间发生失效则不写入缓存，避免保存旧值
+	if generation == c.generation {
+		c.entries[key] = fresh
+	}
+	c.mu.Unlock()
+	return fresh.result()
+}
+
+// String 配置值，不存在或查询失败时返回 def
+func (c *ConfigCache) String(key, def string) string {
+	config, err := c.GetConfiguration(key)
+	if err != nil {
+		return def
+	}
+	return config.Value
+}
+
+// Int 整数配置值，不存在、查询失败或无法解析时返回 def
+func (c *ConfigCache) Int(key string, def int) int {
+	value, err := strconv.Atoi(c.String(key, ""))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Bool 布尔配置值，不存在、查询失败或无法解析时返回 def
+func (c *ConfigCache) Bool(key string, def bool) bool {
+	value, err := strconv.ParseBool(c.String(key, ""))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Duration 时长配置值（如 30s、5m），不存在、查询失败或无法解析时返回 def
+func (c *ConfigCache) Duration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(c.String(key, ""))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// Invalidate 使配置失效，key 为空时清空缓存
+func (c *ConfigCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key == "" {
+		c.entries = make(map[string]*configCacheEntry)
+	} else {
+		delete(c.entries, key)
+	}
+	c.generation++
+	atomic.AddInt64(&c.invalidations, 1)
+}
+
+// GetMetrics 获取缓存指标
+func (c *ConfigCache) GetMetrics() ConfigCacheMetrics {
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	hits := atomic.LoadInt64(&c.hits)
+	misses := atomic.LoadInt64(&c.misses)
+
+	var ratio float64
+	if total := hits + misses; total > 0 {
+		ratio = float64(hits) / float64(total)
+	}
+
+	return ConfigCacheMetrics{
+		Hits:          hits,
+		Misses:        misses,
+		Stale:         atomic.LoadInt64(&c.stale),
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+		HitRatio:      ratio,
+		Entries:       entries,
+		TTL:           c.ttl.String(),
+	}
+}
+
+// result 条目对应的查询结果
+func (e *configCacheEntry) result() (*models.Configuration, error) {
+	if e.config == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	config := *e.config
+	return &config, nil
+}
+
+// sameConfigValue 两次查询结果的值是否相同
+func sameConfigValue(a, b *models.Configuration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Value == b.Value
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// TestConfigCacheInvalidation 经配置服务的每条写入路径修改配置后，缓存立即返回新值
+func TestConfigCacheInvalidation(t *testing.T) {
+	admin := ConfigActor{Admin: true, Name: "admin"}
+	tests := []struct {
+		name  string
+		key   string
+		write func(t *testing.T, configs *ConfigService) error
+		want  string // 写入后的值，为空表示配置不存在
+	}{
+		{
+			name: "set",
+			key:  "ui.theme",
+			write: func(t *testing.T, configs *ConfigService) error {
+				_, err := configs.SetConfigurationAs(admin, "ui.theme", "dark", "ui", "")
+				return err
+			},
+			want: "dark",
+		},
+		{
+			name: "update by id",
+			key:  "ui.theme",
+			write: func(t *testing.T, configs *ConfigService) error {
+				entry, err := configs.GetConfiguration("ui.theme")
+				if err != nil {
+					return err
+				}
+				_, err = configs.UpdateConfigurationAs(admin, entry.ID, map[string]interface{}{"value": "dark"})
+				return err
+			},
+			want: "dark",
+		},
+		{
+			name: "delete",
+			key:  "ui.theme",
+			write: func(t *testing.T, configs *ConfigService) error {
+				entry, err := configs.GetConfiguration("ui.theme")
+				if err != nil {
+					return err
+				}
+				return configs.DeleteConfigurationAs(admin, entry.ID)
+			},
+		},
+		{
+			name: "restore",
+			key:  "ui.deleted",
+			write: func(t *testing.T, configs *ConfigService) error {
+				_, err := configs.RestoreConfiguration("ui.deleted")
+				return err
+			},
+			want: "kept",
+		},
+		{
+			name: "batch",
+			key:  "ui.theme",
+			write: func(t *testing.T, configs *ConfigService) error {
+				return configs.BatchSetConfigurations([]models.Configuration{{Key: "ui.theme", Value: "dark", Category: "ui"}})
+			},
+			want: "dark",
+		},
+		{
+			name: "import",
+			key:  "ui.imported",
+			write: func(t *testing.T, configs *ConfigService) error {
+				return configs.ImportConfigurations([]*models.Configuration{{Key: "ui.imported", Value: "yes", Category: "ui"}}, false)
+			},
+			want: "yes",
+		},
+		{
+			name: "reset category",
+			key:  "log.level",
+			write: func(t *testing.T, configs *ConfigService) error {
+				return configs.ResetConfigurations("log")
+			},
+			want: "info",
+		},
+		{
+			name: "set system",
+			key:  FeedbackDurationKey,
+			write: func(t *testing.T, configs *ConfigService) error {
+				_, err := configs.SetSystemConfigurationAs(admin, models.Configuration{Key: FeedbackDurationKey, Value: "200", Type: "int", Category: "feedback"})
+				return err
+			},
+			want: "200",
+		},
+		{
+			name: "reset system",
+			key:  FeedbackDurationKey,
+			write: func(t *testing.T, configs *ConfigService) error {
+				_, err := configs.ResetSystemConfigurationAs(admin, FeedbackDurationKey)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, db := newTestConfigs(t)
+			for _, entry := range []models.Configuration{
+				{Key: "ui.theme", Value: "light", Category: "ui"},
+				{Key: "ui.deleted", Value: "kept", Category: "ui"},
+				{Key: "log.level", Value: "debug", Category: "log"},
+				{Key: FeedbackDurationKey, Value: "150", Type: "int", Category: "feedback", IsSystem: true},
+			} {
+				err := db.Unscoped().Where(models.Configuration{Key: entry.Key}).
+					Assign(map[string]interface{}{"value": entry.Value, "is_system": entry.IsSystem, "deleted_at": nil}).
+					FirstOrCreate(&entry).Error
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := db.Where("key = ?", "ui.deleted").Delete(&models.Configuration{}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			cache := NewConfigCache(configs, time.Hour)
+			before := cache.String(tt.key, "")
+			cache.String(tt.key, "")
+			if metrics := cache.GetMetrics(); metrics.Hits != 1 || metrics.Misses != 1 {
+				t.Fatalf("metrics before write = %+v, want 1 hit and 1 miss", metrics)
+			}
+
+			if err := tt.write(t, configs); err != nil {
+				t.Fatal(err)
+			}
+			got, err := cache.GetConfiguration(tt.key)
+			switch {
+			case tt.want == "":
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					t.Fatalf("after write: %+v, %v, want not found", got, err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case got.Value != tt.want:
+				t.Fatalf("after write = %q (was %q), want %q", got.Value, before, tt.want)
+			}
+			metrics := cache.GetMetrics()
+			if metrics.Misses != 2 || metrics.Invalidations == 0 || metrics.Stale != 0 {
+				t.Fatalf("metrics after write = %+v, want a second miss and no stale reads", metrics)
+			}
+		})
+	}
+}
+
+// TestConfigCacheBypassWrite 绕过配置服务直接写入配置表（克隆导入、中心同步）的修改在 TTL 到期后生效并计入 stale；
+// 缓存的不存在的键同样到期后重新查询
+func TestConfigCacheBypassWrite(t *testing.T) {
+	configs, db := newTestConfigs(t)
+	systemEntry(t, db, "scanner.timeout_ms", "100")
+	ttl := 100 * time.Millisecond
+	cache := NewConfigCache(configs, ttl)
+
+	if got := cache.Int("scanner.timeout_ms", 0); got != 100 {
+		t.Fatalf("initial = %d", got)
+	}
+	if got := cache.Bool("ui.missing", true); got != true {
+		t.Fatalf("missing key = %v, want default", got)
+	}
+	written := time.Now()
+	if err := db.Model(&models.Configuration{}).Where("key = ?", "scanner.timeout_ms").Update("value", "250").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Configuration{Key: "ui.missing", Value: "false", Category: "ui"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// TTL 内读到旧值
+	if got := cache.Int("scanner.timeout_ms", 0); got != 100 && time.Since(written) < ttl {
+		t.Fatalf("within ttl = %d, want cached 100", got)
+	}
+	if got := cache.Bool("ui.missing", true); got != true && time.Since(written) < ttl {
+		t.Fatal("within ttl the missing key was queried again")
+	}
+
+	time.Sleep(ttl)
+	if got := cache.Int("scanner.timeout_ms", 0); got != 250 {
+		t.Fatalf("after ttl = %d, want 250", got)
+	}
+	if got := cache.Bool("ui.missing", true); got != false {
+		t.Fatal("after ttl the created key is still missing")
+	}
+	if metrics := cache.GetMetrics(); metrics.Stale != 2 || metrics.Entries != 2 {
+		t.Fatalf("metrics = %+v, want 2 stale reads", metrics)
+	}
+
+	// 手动失效后立即生效，不计入 stale
+	if err := db.Model(&models.Configuration{}).Where("key = ?", "scanner.timeout_ms").Update("value", "300").Error; err != nil {
+		t.Fatal(err)
+	}
+	cache.Invalidate("")
+	if got := cache.Int("scanner.timeout_ms", 0); got != 300 {
+		t.Fatalf("after invalidate = %d, want 300", got)
+	}
+	if metrics := cache.GetMetrics(); metrics.Stale != 2 {
+		t.Fatalf("stale = %d after invalidate, want 2", metrics.Stale)
+	}
+}
+
+// TestConfigCacheReturnsCopy 修改返回的配置不影响缓存
+func TestConfigCacheReturnsCopy(t *testing.T) {
+	configs, db := newTestConfigs(t)
+	systemEntry(t, db, "scanner.timeout_ms", "100")
+	cache := NewConfigCache(configs, time.Hour)
+	entry, err := cache.GetConfiguration("scanner.timeout_ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Value = "999"
+	if got := cache.String("scanner.timeout_ms", ""); got != "100" {
+		t.Fatalf("cached value = %q, want 100", got)
+	}
+}
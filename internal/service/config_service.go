@@ -1,7 +1,10 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 	
 	"github.com/sirupsen/logrus"
@@ -10,10 +13,42 @@ import (
 	"userclient/internal/models"
 )
 
+// ErrConfigProtected 系统配置不允许删除，修改需要管理员权限
+var ErrConfigProtected = errors.New("系统配置受保护")
+
+// ErrInvalidConfigValue 系统配置值未通过校验
+var ErrInvalidConfigValue = errors.New("配置值无效")
+
+// ErrConfigOutOfRange 扫码参数超出安全范围，需确认后强制保存
+var ErrConfigOutOfRange = errors.New("配置值超出安全范围")
+
+// ConfigActor 配置修改的操作者，用于权限检查与审计
+type ConfigActor struct {
+	Admin     bool
+	Name      string
+	IP        string
+	UserAgent string
+	Force     bool // 确认保存超出安全范围的扫码参数
+	Restore   bool // 键属于已删除的配置时恢复该配置并写入新值
+}
+
+// ConfigChange 系统配置变更通知
+type ConfigChange struct {
+	Key             string `json:"key"`
+	OldValue        string `json:"old_value"`
+	Value           string `json:"value"`
+	RestartRequired bool   `json:"restart_required"` // 需重启才能生效
+	Reset           bool   `json:"reset,omitempty"`  // 设置值已删除，恢复为默认值，此时 Value 为空
+}
+
 // ConfigService 配置服务
+//
+// IsSystem 配置项不允许删除，修改需要管理员权限并通过该键登记的校验，违规操作写入审计日志。
 type ConfigService struct {
-	db     *gorm.DB
-	logger *logrus.Logger
+	db           *gorm.DB
+	logger       *logrus.Logger
+	listeners    []func(ConfigChange)
+	invalidators []func(key string)
 }
 
 // NewConfigService 创建配置服务
@@ -24,6 +59,23 @@ func NewConfigService(db *gorm.DB, logger *logrus.Logger) *ConfigService {
 	}
 }
 
+// OnChange 注册系统配置变更回调，用于热更新或提示重启
+func (s *ConfigService) OnChange(listener func(ConfigChange)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// OnInvalidate 注册配置写入回调：任意键新增、修改、删除或恢复后以该键调用，批量写入后以空键调用，用于配置缓存失效
+func (s *ConfigService) OnInvalidate(listener func(key string)) {
+	s.invalidators = append(s.invalidators, listener)
+}
+
+// invalidate 通知配置已写入，key 为空表示可能涉及所有键
+func (s *ConfigService) invalidate(key string) {
+	for _, listener := range s.invalidators {
+		listener(key)
+	}
+}
+
 // GetConfigurations 获取配置列表
 func (s *ConfigService) GetConfigurations(category string) ([]*models.Configuration, error) {
 	var configs []*models.Configuration
@@ -59,17 +111,44 @@ func (s *ConfigService) GetConfigurationByID(id uint) (*models.Configuration, er
 	return &config, nil
 }
 
-// SetConfiguration 设置配置
+// SetConfiguration 设置配置（内部调用，不具备管理员权限，不能修改系统配置；已删除的键直接恢复）
 func (s *ConfigService) SetConfiguration(key, value, category, description string) error {
+	_, err := s.SetConfigurationAs(ConfigActor{Restore: true}, key, value, category, description)
+	return err
+}
+
+// SetConfigurationAs 以指定操作者设置配置，修改系统配置时返回变更信息
+func (s *ConfigService) SetConfigurationAs(actor ConfigActor, key, value, category, description string) (*ConfigChange, error) {
 	var config models.Configuration
 	
 	// 查找现有配置
 	err := s.db.Where("key = ?", key).First(&config).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
-		return fmt.Errorf("查询配置失败: %w", err)
+		return nil, fmt.Errorf("查询配置失败: %w", err)
+	}
+	
+	if err == gorm.ErrRecordNotFound {
+		// 已删除的配置仍占用键名，未确认恢复时返回冲突
+		deleted, findErr := s.findDeleted(s.db, key)
+		if findErr != nil {
+			return nil, findErr
+		}
+		if deleted != nil {
+			if !actor.Restore {
+				return nil, deletedConfigConflict(deleted)
+			}
+			if err := s.restore(s.db, deleted); err != nil {
+				return nil, err
+			}
+			config, err = *deleted, nil
+		}
 	}
 	
 	if err == gorm.ErrRecordNotFound {
+		if err := s.checkGuardrail(actor, key, value); err != nil {
+			return nil, err
+		}
+		
 		// 创建新配置
 		config = models.Configuration{
 			Key:         key,
@@ -80,11 +159,19 @@ func (s *ConfigService) SetConfiguration(key, value, category, description strin
 		
 		if err := s.db.Create(&config).Error; err != nil {
 			s.logger.WithError(err).Error("创建配置失败")
-			return fmt.Errorf("创建配置失败: %w", err)
+			return nil, fmt.Errorf("创建配置失败: %w", err)
 		}
 		
+		s.invalidate(key)
 		s.logger.WithField("key", key).WithField("value", value).Info("配置创建成功")
 	} else {
+		// 系统配置需要管理员权限并通过校验
+		if config.IsSystem {
+			if err := s.checkSystemChange(actor, &config, value); err != nil {
+				return nil, err
+			}
+		}
+		
 		// 更新现有配置
 		updates := map[string]interface{}{
 			"value":      value,
@@ -99,58 +186,232 @@ func (s *ConfigService) SetConfiguration(key, value, category, description strin
 			updates["description"] = description
 		}
 		
+		oldValue := config.Value
 		if err := s.db.Model(&config).Updates(updates).Error; err != nil {
 			s.logger.WithError(err).Error("更新配置失败")
-			return fmt.Errorf("更新配置失败: %w", err)
+			return nil, fmt.Errorf("更新配置失败: %w", err)
 		}
 		
+		s.invalidate(key)
 		s.logger.WithField("key", key).WithField("value", value).Info("配置更新成功")
+		if config.IsSystem {
+			return s.notifyChange(key, oldValue, value), nil
+		}
 	}
 	
-	return nil
+	return nil, nil
 }
 
-// UpdateConfiguration 更新配置
+// UpdateConfiguration 更新配置（内部调用，不能修改系统配置）
 func (s *ConfigService) UpdateConfiguration(id uint, updates map[string]interface{}) error {
+	_, err := s.UpdateConfigurationAs(ConfigActor{}, id, updates)
+	return err
+}
+
+// UpdateConfigurationAs 以指定操作者更新配置，系统配置不允许改名或取消保护
+func (s *ConfigService) UpdateConfigurationAs(actor ConfigActor, id uint, updates map[string]interface{}) (*ConfigChange, error) {
 	// 检查配置是否存在
 	var config models.Configuration
 	if err := s.db.First(&config, id).Error; err != nil {
-		return fmt.Errorf("配置不存在: %w", err)
+		return nil, fmt.Errorf("配置不存在: %w", err)
+	}
+	
+	var newValue *string
+	if config.IsSystem {
+		if !actor.Admin {
+			s.auditDenied(actor, "update", config.Key, "需要管理员权限")
+			return nil, fmt.Errorf("%w: 修改 %s 需要管理员权限", ErrConfigProtected, config.Key)
+		}
+		for _, field := range []string{"key", "is_system", "type"} {
+			if _, ok := updates[field]; ok {
+				s.auditDenied(actor, "update", config.Key, "不允许修改系统配置的 "+field)
+				return nil, fmt.Errorf("%w: 不允许修改 %s 的 %s", ErrConfigProtected, config.Key, field)
+			}
+		}
+		if v, ok := updates["value"]; ok {
+			value := fmt.Sprint(v)
+			if err := s.checkSystemChange(actor, &config, value); err != nil {
+				return nil, err
+			}
+			newValue = &value
+		}
 	}
 	
 	// 如果更新键名，检查是否重复
 	if newKey, ok := updates["key"]; ok {
 		var existingConfig models.Configuration
 		if err := s.db.Where("key = ? AND id != ?", newKey, id).First(&existingConfig).Error; err == nil {
-			return fmt.Errorf("配置键 '%s' 已存在", newKey)
+			return nil, fmt.Errorf("配置键 '%s' 已存在", newKey)
 		}
 	}
 	
 	// 更新最后修改时间
 	updates["updated_at"] = time.Now()
 	
+	oldValue := config.Value
 	if err := s.db.Model(&config).Updates(updates).Error; err != nil {
 		s.logger.WithError(err).Error("更新配置失败")
-		return fmt.Errorf("更新配置失败: %w", err)
+		return nil, fmt.Errorf("更新配置失败: %w", err)
 	}
 	
+	if _, renamed := updates["key"]; renamed {
+		s.invalidate("")
+	} else {
+		s.invalidate(config.Key)
+	}
 	s.logger.WithField("config_id", id).WithField("key", config.Key).Info("配置更新成功")
+	if newValue != nil {
+		return s.notifyChange(config.Key, oldValue, *newValue), nil
+	}
+	return nil, nil
+}
+
+// SetSystemConfigurationAs 以指定操作者设置代码中登记的系统配置（如功能开关），键不存在或已重置时创建或恢复为系统配置
+func (s *ConfigService) SetSystemConfigurationAs(actor ConfigActor, entry models.Configuration) (*ConfigChange, error) {
+	entry.IsSystem = true
+	if err := s.checkSystemChange(actor, &entry, entry.Value); err != nil {
+		return nil, err
+	}
+	
+	oldValue := ""
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var config models.Configuration
+		err := tx.Unscoped().Where("key = ?", entry.Key).First(&config).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&entry).Error
+		}
+		if err != nil {
+			return err
+		}
+		if !config.DeletedAt.Valid {
+			oldValue = config.Value
+		}
+		return tx.Unscoped().Model(&config).Updates(map[string]interface{}{
+			"value":      entry.Value,
+			"is_system":  true,
+			"deleted_at": nil,
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("保存系统配置失败")
+		return nil, fmt.Errorf("保存系统配置失败: %w", err)
+	}
+	
+	s.invalidate(entry.Key)
+	s.logger.WithField("key", entry.Key).WithField("value", entry.Value).WithField("actor", actor.Name).Info("系统配置已修改")
+	return s.notifyChange(entry.Key, oldValue, entry.Value), nil
+}
+
+// ResetSystemConfigurationAs 以指定操作者删除系统配置的设置值，恢复为代码中的默认值；未设置时返回 nil
+//
+// 系统配置不允许通过 DeleteConfigurationAs 删除，只有值可为空的系统配置（如功能开关）经此重置，需要管理员权限并写入审计日志。
+func (s *ConfigService) ResetSystemConfigurationAs(actor ConfigActor, key string) (*ConfigChange, error) {
+	if !actor.Admin {
+		s.auditDenied(actor, "reset", key, "需要管理员权限")
+		return nil, fmt.Errorf("%w: 重置 %s 需要管理员权限", ErrConfigProtected, key)
+	}
+	
+	var config models.Configuration
+	err := s.db.Where("key = ?", key).First(&config).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询配置失败: %w", err)
+	}
+	if !config.IsSystem {
+		return nil, fmt.Errorf("%w: %s 不是系统配置", ErrConfigProtected, key)
+	}
+	if err := s.db.Delete(&config).Error; err != nil {
+		s.logger.WithError(err).Error("重置系统配置失败")
+		return nil, fmt.Errorf("重置系统配置失败: %w", err)
+	}
+	
+	s.invalidate(key)
+	s.auditReset(actor, &config)
+	return s.publish(&ConfigChange{
+		Key:             key,
+		OldValue:        config.Value,
+		RestartRequired: systemConfigKeys[key].restart,
+		Reset:           true,
+	}), nil
+}
+
+// RestoreConfiguration 恢复已删除的配置
+func (s *ConfigService) RestoreConfiguration(key string) (*models.Configuration, error) {
+	deleted, err := s.findDeleted(s.db, key)
+	if err != nil {
+		return nil, err
+	}
+	if deleted == nil {
+		return nil, fmt.Errorf("已删除的配置不存在: %w", gorm.ErrRecordNotFound)
+	}
+	if err := s.restore(s.db, deleted); err != nil {
+		return nil, err
+	}
+	s.invalidate(key)
+	return deleted, nil
+}
+
+// findDeleted 查找键名相同的已删除配置，不存在时返回 nil
+func (s *ConfigService) findDeleted(tx *gorm.DB, key string) (*models.Configuration, error) {
+	var deleted []models.Configuration
+	if err := tx.Unscoped().Where("key = ? AND deleted_at IS NOT NULL", key).Limit(1).Find(&deleted).Error; err != nil {
+		return nil, fmt.Errorf("查询已删除配置失败: %w", err)
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+	return &deleted[0], nil
+}
+
+// restore 清除配置的删除标记
+func (s *ConfigService) restore(tx *gorm.DB, config *models.Configuration) error {
+	if err := tx.Unscoped().Model(config).UpdateColumn("deleted_at", nil).Error; err != nil {
+		s.logger.WithError(err).Error("恢复配置失败")
+		return fmt.Errorf("恢复配置失败: %w", err)
+	}
+	config.DeletedAt = gorm.DeletedAt{}
+	s.logger.WithField("key", config.Key).Info("配置已恢复")
 	return nil
 }
 
-// DeleteConfiguration 删除配置
+// deletedConfigConflict 键名属于已删除配置的冲突错误
+func deletedConfigConflict(config *models.Configuration) error {
+	return &DeletedConflictError{
+		Kind:      "configuration",
+		Field:     "key",
+		Value:     config.Key,
+		ID:        config.ID,
+		DeletedAt: config.DeletedAt.Time,
+	}
+}
+
+// DeleteConfiguration 删除配置（内部调用）
 func (s *ConfigService) DeleteConfiguration(id uint) error {
+	return s.DeleteConfigurationAs(ConfigActor{}, id)
+}
+
+// DeleteConfigurationAs 以指定操作者删除配置，系统配置始终拒绝删除
+func (s *ConfigService) DeleteConfigurationAs(actor ConfigActor, id uint) error {
 	// 检查配置是否存在
 	var config models.Configuration
 	if err := s.db.First(&config, id).Error; err != nil {
 		return fmt.Errorf("配置不存在: %w", err)
 	}
 	
+	if config.IsSystem {
+		s.auditDenied(actor, "delete", config.Key, "系统配置不允许删除")
+		return fmt.Errorf("%w: %s 不允许删除", ErrConfigProtected, config.Key)
+	}
+	
 	if err := s.db.Delete(&config).Error; err != nil {
 		s.logger.WithError(err).Error("删除配置失败")
 		return fmt.Errorf("删除配置失败: %w", err)
 	}
 	
+	s.invalidate(config.Key)
 	s.logger.WithField("config_id", id).WithField("key", config.Key).Info("配置删除成功")
 	return nil
 }
@@ -190,7 +451,7 @@ func (s *ConfigService) GetAllConfigurations() (map[string]map[string]string, er
 	return result, nil
 }
 
-// BatchSetConfigurations 批量设置配置
+// BatchSetConfigurations 批量设置配置，已删除的键恢复后写入新值
 func (s *ConfigService) BatchSetConfigurations(configs []models.Configuration) error {
 	tx := s.db.Begin()
 	defer func() {
@@ -208,12 +469,30 @@ func (s *ConfigService) BatchSetConfigurations(configs []models.Configuration) e
 			return fmt.Errorf("查询配置失败: %w", err)
 		}
 		
+		if err == gorm.ErrRecordNotFound {
+			// 已删除的配置恢复后按现有配置处理
+			deleted, findErr := s.findDeleted(tx, config.Key)
+			if findErr == nil && deleted != nil {
+				findErr = s.restore(tx, deleted)
+				existingConfig, err = *deleted, nil
+			}
+			if findErr != nil {
+				tx.Rollback()
+				return findErr
+			}
+		}
+		
 		if err == gorm.ErrRecordNotFound {
 			// 创建新配置
 			if err := tx.Create(&config).Error; err != nil {
 				tx.Rollback()
 				return fmt.Errorf("创建配置失败: %w", err)
 			}
+		} else if existingConfig.IsSystem && existingConfig.Value != config.Value {
+			// 批量设置不具备管理员权限，系统配置须通过 SetConfigurationAs 修改
+			s.auditDenied(ConfigActor{}, "batch", existingConfig.Key, "批量设置不能修改系统配置")
+			tx.Rollback()
+			return fmt.Errorf("%w: 批量设置不能修改 %s", ErrConfigProtected, existingConfig.Key)
 		} else {
 			// 更新现有配置
 			updates := map[string]interface{}{
@@ -234,6 +513,7 @@ func (s *ConfigService) BatchSetConfigurations(configs []models.Configuration) e
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
 	
+	s.invalidate("")
 	s.logger.WithField("count", len(configs)).Info("批量设置配置成功")
 	return nil
 }
@@ -288,7 +568,7 @@ func (s *ConfigService) ExportConfigurations(category string) ([]*models.Configu
 	return configs, nil
 }
 
-// ImportConfigurations 导入配置
+// ImportConfigurations 导入配置，已删除的键恢复后写入导入的值（不受 overwrite 限制）
 func (s *ConfigService) ImportConfigurations(configs []*models.Configuration, overwrite bool) error {
 	tx := s.db.Begin()
 	defer func() {
@@ -299,6 +579,7 @@ func (s *ConfigService) ImportConfigurations(configs []*models.Configuration, ov
 	
 	for _, config := range configs {
 		var existingConfig models.Configuration
+		restored := false
 		err := tx.Where("key = ?", config.Key).First(&existingConfig).Error
 		
 		if err != nil && err != gorm.ErrRecordNotFound {
@@ -306,13 +587,30 @@ func (s *ConfigService) ImportConfigurations(configs []*models.Configuration, ov
 			return fmt.Errorf("查询配置失败: %w", err)
 		}
 		
+		if err == gorm.ErrRecordNotFound {
+			// 已删除的配置恢复后按现有配置处理
+			deleted, findErr := s.findDeleted(tx, config.Key)
+			if findErr == nil && deleted != nil {
+				findErr = s.restore(tx, deleted)
+				existingConfig, err, restored = *deleted, nil, true
+			}
+			if findErr != nil {
+				tx.Rollback()
+				return findErr
+			}
+		}
+		
 		if err == gorm.ErrRecordNotFound {
 			// 创建新配置
 			if err := tx.Create(config).Error; err != nil {
 				tx.Rollback()
 				return fmt.Errorf("创建配置失败: %w", err)
 			}
-		} else if overwrite {
+		} else if (overwrite || restored) && existingConfig.IsSystem && existingConfig.Value != config.Value {
+			s.auditDenied(ConfigActor{}, "import", existingConfig.Key, "导入不能覆盖系统配置")
+			tx.Rollback()
+			return fmt.Errorf("%w: 导入不能覆盖 %s", ErrConfigProtected, existingConfig.Key)
+		} else if overwrite || restored {
 			// 覆盖现有配置
 			updates := map[string]interface{}{
 				"value":       config.Value,
@@ -333,6 +631,7 @@ func (s *ConfigService) ImportConfigurations(configs []*models.Configuration, ov
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
 	
+	s.invalidate("")
 	s.logger.WithField("count", len(configs)).WithField("overwrite", overwrite).Info("导入配置成功")
 	return nil
 }
@@ -387,10 +686,147 @@ func (s *ConfigService) ResetConfigurations(category string) error {
 		return fmt.Errorf("提交事务失败: %w", err)
 	}
 	
+	s.invalidate("")
 	s.logger.WithField("category", category).Info("重置配置成功")
 	return nil
 }
 
+// checkSystemChange 检查系统配置修改：需要管理员权限且通过该键的校验，拒绝时写入审计日志
+func (s *ConfigService) checkSystemChange(actor ConfigActor, config *models.Configuration, value string) error {
+	if !actor.Admin {
+		s.auditDenied(actor, "update", config.Key, "需要管理员权限")
+		return fmt.Errorf("%w: 修改 %s 需要管理员权限", ErrConfigProtected, config.Key)
+	}
+	
+	err := ValidateSystemConfig(config.Key, config.Type, value)
+	if err == nil {
+		err = s.checkLengthBounds(config.Key, value)
+	}
+	if err != nil {
+		s.auditDenied(actor, "update", config.Key, fmt.Sprintf("值 %q 无效: %v", value, err))
+		return fmt.Errorf("%w: %s %v", ErrInvalidConfigValue, config.Key, err)
+	}
+	return s.checkGuardrail(actor, config.Key, value)
+}
+
+// checkGuardrail 扫码参数超出安全范围时需要确认（Force）才能保存
+func (s *ConfigService) checkGuardrail(actor ConfigActor, key, value string) error {
+	warning := CheckScannerSetting(key, value)
+	if warning == nil {
+		return nil
+	}
+	if !actor.Force {
+		s.auditDenied(actor, "update", key, warning.Message)
+		return fmt.Errorf("%w: %s", ErrConfigOutOfRange, warning.Message)
+	}
+	s.logger.WithField("key", key).WithField("value", value).WithField("actor", actor.Name).Warn("已强制保存超出安全范围的扫码参数")
+	return nil
+}
+
+// checkLengthBounds 条码最小长度不能大于最大长度
+func (s *ConfigService) checkLengthBounds(key, value string) error {
+	other, minSide := "", false
+	switch key {
+	case "scanner.min_length":
+		other, minSide = "scanner.max_length", true
+	case "scanner.max_length":
+		other = "scanner.min_length"
+	default:
+		return nil
+	}
+	
+	current, err := s.GetConfiguration(other)
+	if err != nil {
+		return nil
+	}
+	n, err1 := strconv.Atoi(value)
+	bound, err2 := strconv.Atoi(current.Value)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+	if minSide && n > bound {
+		return fmt.Errorf("不能大于 %s（%d）", other, bound)
+	}
+	if !minSide && n < bound {
+		return fmt.Errorf("不能小于 %s（%d）", other, bound)
+	}
+	return nil
+}
+
+// notifyChange 通知系统配置变更
+func (s *ConfigService) notifyChange(key, oldValue, value string) *ConfigChange {
+	return s.publish(&ConfigChange{
+		Key:             key,
+		OldValue:        oldValue,
+		Value:           value,
+		RestartRequired: systemConfigKeys[key].restart,
+	})
+}
+
+// publish 调用系统配置变更回调
+func (s *ConfigService) publish(change *ConfigChange) *ConfigChange {
+	if change.RestartRequired {
+		s.logger.WithField("key", change.Key).Warn("系统配置已修改，需重启后生效")
+	}
+	for _, listener := range s.listeners {
+		listener(*change)
+	}
+	return change
+}
+
+// auditDenied 记录被拒绝的系统配置操作
+func (s *ConfigService) auditDenied(actor ConfigActor, op, key, reason string) {
+	s.logger.WithField("key", key).WithField("op", op).WithField("ip", actor.IP).Warn("拒绝修改系统配置: " + reason)
+	
+	extra, err := json.Marshal(map[string]interface{}{"key": key, "op": op, "reason": reason, "actor": actor.Name, "admin": actor.Admin})
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:     "warning",
+		Message:   fmt.Sprintf("拒绝%s系统配置 %s: %s", configOpNames[op], key, reason),
+		Module:    "config",
+		Action:    "config:denied:" + op,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		Extra:     string(extra),
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入审计日志失败")
+	}
+}
+
+// auditReset 记录系统配置的重置
+func (s *ConfigService) auditReset(actor ConfigActor, config *models.Configuration) {
+	s.logger.WithField("key", config.Key).WithField("actor", actor.Name).Info("系统配置已重置")
+	
+	extra, err := json.Marshal(map[string]interface{}{"key": config.Key, "old_value": config.Value, "actor": actor.Name})
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:     "info",
+		Message:   fmt.Sprintf("重置系统配置 %s（原值 %s）", config.Key, config.Value),
+		Module:    "config",
+		Action:    "config:reset",
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		Extra:     string(extra),
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入审计日志失败")
+	}
+}
+
+// configOpNames 审计日志中的操作名称
+var configOpNames = map[string]string{
+	"update": "修改",
+	"delete": "删除",
+	"batch":  "批量设置",
+	"import": "导入",
+	"reset":  "重置",
+}
+
 // getDefaultConfigurations 获取默认配置
 func (s *ConfigService) getDefaultConfigurations() []models.Configuration {
 	return []models.Configuration{
@@ -408,5 +844,13 @@ func (s *ConfigService) getDefaultConfigurations() []models.Configuration {
 		{Key: "log.file_enabled", Value: "true", Category: "log", Description: "启用文件日志"},
 		{Key: "security.rate_limit", Value: "100", Category: "security", Description: "API速率限制（每分钟请求数）"},
 		{Key: "security.jwt_secret", Value: "your-secret-key", Category: "security", Description: "JWT密钥"},
+		{Key: LinkAllowListKey, Value: "[]", Type: "json", Category: "barcode", Description: "网址条码可点击的域名白名单（JSON数组，支持 *.example.com 通配）", IsSystem: true},
+		{Key: ScannerPresetsKey, Value: "[]", Type: "json", Category: "scanner", Description: "站点自定义扫码枪参数预设（JSON数组），修改后重启生效", IsSystem: true},
+		{Key: FeedbackEnabledKey, Value: "false", Type: "bool", Category: "feedback", Description: "扫码后播放提示音（仅Windows，仅API模式下不播放）", IsSystem: true},
+		{Key: FeedbackSuccessFreqKey, Value: "2000", Type: "int", Category: "feedback", Description: "扫码被接受的提示音音调（Hz）", IsSystem: true},
+		{Key: FeedbackErrorFreqKey, Value: "400", Type: "int", Category: "feedback", Description: "扫码被拒绝的提示音音调（Hz），时长为接受音的两倍", IsSystem: true},
+		{Key: FeedbackDurationKey, Value: "100", Type: "int", Category: "feedback", Description: "扫码被接受的提示音时长（毫秒）", IsSystem: true},
+		{Key: FeedbackSuccessWAVKey, Value: "", Type: "string", Category: "feedback", Description: "扫码被接受时播放的WAV文件，为空时使用音调", IsSystem: true},
+		{Key: FeedbackErrorWAVKey, Value: "", Type: "string", Category: "feedback", Description: "扫码被拒绝时播放的WAV文件，为空时使用音调", IsSystem: true},
 	}
 }
\ No newline at end of file
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// newTestConfigs 创建使用临时数据库的配置服务
+func newTestConfigs(t *testing.T) (*ConfigService, *database.DB) {
+	t.Helper()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "configs.db"))
+	t.Cleanup(func() { db.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewConfigService(db.DB, logger), db
+}
+
+// systemEntry 写入或覆盖一条系统配置，返回其ID
+func systemEntry(t *testing.T, db *database.DB, key, value string) uint {
+	t.Helper()
+	entry := models.Configuration{Key: key, Value: value, Type: "string", Category: "test", IsSystem: true}
+	err := db.Unscoped().Where(models.Configuration{Key: key}).
+		Assign(map[string]interface{}{"value": value, "is_system": true, "deleted_at": nil}).
+		FirstOrCreate(&entry).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry.ID
+}
+
+// deniedAudits 审计日志中被拒绝的配置修改，按写入顺序返回 extra 中的键与操作
+func deniedAudits(t *testing.T, db *database.DB) []map[string]interface{} {
+	t.Helper()
+	var logs []models.SystemLog
+	if err := db.Where("module = ? AND action LIKE ?", "config", "config:denied:%").Order("id").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	var audits []map[string]interface{}
+	for _, log := range logs {
+		var extra map[string]interface{}
+		if err := json.Unmarshal([]byte(log.Extra), &extra); err != nil {
+			t.Fatal(err)
+		}
+		extra["action"] = log.Action
+		audits = append(audits, extra)
+	}
+	return audits
+}
+
+// systemConfigCases systemConfigKeys 中每个键的合法值与校验不通过的值
+var systemConfigCases = map[string]struct {
+	valid   string
+	invalid []string
+}{
+	"scanner.timeout_ms":        {valid: "100", invalid: []string{"9", "10001", "abc"}},
+	"scanner.timeout":           {valid: "5000", invalid: []string{"9", "60001"}},
+	"scanner.min_length":        {valid: "5", invalid: []string{"0", "x", "51"}}, // 51 大于 scanner.max_length
+	"scanner.max_length":        {valid: "60", invalid: []string{"1001", "2"}},   // 2 小于 scanner.min_length
+	"scanner.auto_clear":        {valid: "true", invalid: []string{"yes"}},
+	"scanner.terminators":       {valid: "enter,tab", invalid: []string{"", " , ", "none,enter", "esc"}},
+	"websocket.port":            {valid: "8081", invalid: []string{"0", "65536"}},
+	"websocket.max_connections": {valid: "500", invalid: []string{"0", "10001"}},
+	"api.port":                  {valid: "8080", invalid: []string{"-1", "http"}},
+	"api.cors_enabled":          {valid: "false", invalid: []string{"off"}},
+	"database.max_idle_conns":   {valid: "10", invalid: []string{"0"}},
+	"database.max_open_conns":   {valid: "20", invalid: []string{"1001"}},
+	"log.level":                 {valid: "debug", invalid: []string{"trace", "INFO"}},
+	"log.file_enabled":          {valid: "true", invalid: []string{"enabled"}},
+	"security.rate_limit":       {valid: "0", invalid: []string{"-1", "100001"}},
+	"security.jwt_secret":       {valid: "0123456789abcdef", invalid: []string{"short"}},
+	"system.auto_cleanup_days":  {valid: "90", invalid: []string{"-1", "3651"}},
+	LinkAllowListKey: {
+		valid:   `["example.com","*.corp.example"]`,
+		invalid: []string{`"example.com"`, `["https://example.com/path"]`, `["ex*.com"]`, `[""]`},
+	},
+	ScannerPresetsKey: {
+		valid: `[{"name":"line-1","settings":{"timeout_ms":50,"min_length":3,"max_length":64}}]`,
+		invalid: []string{
+			`{}`,
+			`[{"name":"Line 1","settings":{"timeout_ms":50,"min_length":3,"max_length":64}}]`,
+			`[{"name":"generic-fast","settings":{"timeout_ms":50,"min_length":3,"max_length":64}}]`,
+			`[{"name":"line-2","settings":{"timeout_ms":0,"min_length":3,"max_length":64}}]`,
+			`[{"name":"line-3","settings":{"timeout_ms":50,"min_length":8,"max_length":4}}]`,
+		},
+	},
+	FeedbackEnabledKey:     {valid: "true", invalid: []string{"on"}},
+	FeedbackSuccessFreqKey: {valid: "2000", invalid: []string{"36", "32768"}},
+	FeedbackErrorFreqKey:   {valid: "400", invalid: []string{"0"}},
+	FeedbackDurationKey:    {valid: "100", invalid: []string{"5", "2001"}},
+	FeedbackSuccessWAVKey:  {valid: `C:\sounds\ok.WAV`, invalid: []string{"ok.mp3"}},
+	FeedbackErrorWAVKey:    {valid: "", invalid: []string{"/usr/share/sounds/error"}},
+}
+
+func init() {
+	// 功能开关在注册时登记校验规则
+	for _, flag := range featureFlags {
+		systemConfigCases[FlagConfigPrefix+flag.Name] = struct {
+			valid   string
+			invalid []string
+		}{valid: "false", invalid: []string{"", "disabled"}}
+	}
+}
+
+// TestSystemConfigCasesCoverKeys 登记了校验规则的系统配置项都有测试用例
+func TestSystemConfigCasesCoverKeys(t *testing.T) {
+	for key := range systemConfigKeys {
+		if _, ok := systemConfigCases[key]; !ok {
+			t.Errorf("系统配置 %s 没有校验用例", key)
+		}
+	}
+	for key := range systemConfigCases {
+		if _, ok := systemConfigKeys[key]; !ok {
+			t.Errorf("用例中的 %s 未登记校验规则", key)
+		}
+	}
+}
+
+// TestSystemConfigValidation 逐项检查系统配置：非管理员及无效值被拒绝、值不变并写入审计日志，合法值保存后通知变更
+func TestSystemConfigValidation(t *testing.T) {
+	admin := ConfigActor{Admin: true, Name: "admin", IP: "10.0.0.1"}
+	for key, tt := range systemConfigCases {
+		t.Run(key, func(t *testing.T) {
+			configs, db := newTestConfigs(t)
+			var changes []ConfigChange
+			configs.OnChange(func(change ConfigChange) { changes = append(changes, change) })
+			systemEntry(t, db, "scanner.min_length", "3")
+			systemEntry(t, db, "scanner.max_length", "50")
+			const initial = "initial"
+			id := systemEntry(t, db, key, initial)
+			var want []map[string]interface{}
+			unchanged := func() {
+				t.Helper()
+				entry, err := configs.GetConfiguration(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if entry.Value != initial {
+					t.Fatalf("被拒绝的修改已保存: %q", entry.Value)
+				}
+			}
+
+			// 非管理员
+			if _, err := configs.SetConfigurationAs(ConfigActor{Name: "operator"}, key, tt.valid, "", ""); !errors.Is(err, ErrConfigProtected) {
+				t.Fatalf("非管理员修改 err = %v, want ErrConfigProtected", err)
+			}
+			unchanged()
+			want = append(want, map[string]interface{}{"action": "config:denied:update", "key": key, "actor": "operator", "admin": false})
+
+			// 无效值：按键设置与按ID更新两条路径都校验
+			for _, value := range tt.invalid {
+				if _, err := configs.SetConfigurationAs(admin, key, value, "", ""); !errors.Is(err, ErrInvalidConfigValue) {
+					t.Fatalf("设置 %q err = %v, want ErrInvalidConfigValue", value, err)
+				}
+				if _, err := configs.UpdateConfigurationAs(admin, id, map[string]interface{}{"value": value}); !errors.Is(err, ErrInvalidConfigValue) {
+					t.Fatalf("更新 %q err = %v, want ErrInvalidConfigValue", value, err)
+				}
+				unchanged()
+				audit := map[string]interface{}{"action": "config:denied:update", "key": key, "actor": "admin", "admin": true}
+				want = append(want, audit, audit)
+			}
+			if len(changes) != 0 {
+				t.Fatalf("被拒绝的修改发出了变更通知: %+v", changes)
+			}
+
+			audits := deniedAudits(t, db)
+			if len(audits) != len(want) {
+				t.Fatalf("审计日志 %d 条，want %d: %v", len(audits), len(want), audits)
+			}
+			for i, audit := range audits {
+				for field, value := range want[i] {
+					if audit[field] != value {
+						t.Fatalf("第 %d 条审计日志 %s = %v，want %v: %v", i+1, field, audit[field], value, audit)
+					}
+				}
+				if reason, _ := audit["reason"].(string); reason == "" {
+					t.Fatalf("第 %d 条审计日志缺少原因: %v", i+1, audit)
+				}
+			}
+
+			// 合法值
+			change, err := configs.SetConfigurationAs(admin, key, tt.valid, "", "")
+			if err != nil {
+				t.Fatalf("设置 %q: %v", tt.valid, err)
+			}
+			wantChange := ConfigChange{Key: key, OldValue: initial, Value: tt.valid, RestartRequired: systemConfigKeys[key].restart}
+			if change == nil || *change != wantChange {
+				t.Fatalf("变更 = %+v, want %+v", change, wantChange)
+			}
+			if len(changes) != 1 || changes[0] != wantChange {
+				t.Fatalf("变更通知 = %+v", changes)
+			}
+			if got := len(deniedAudits(t, db)); got != len(want) {
+				t.Fatalf("合法修改写入了拒绝审计日志: %d 条", got)
+			}
+		})
+	}
+}
+
+// TestUnregisteredSystemConfigByType 未登记校验规则的系统配置按配置类型校验
+func TestUnregisteredSystemConfigByType(t *testing.T) {
+	tests := []struct {
+		valueType string
+		valid     string
+		invalid   string
+	}{
+		{valueType: "int", valid: "42", invalid: "4.2"},
+		{valueType: "bool", valid: "false", invalid: "nope"},
+		{valueType: "json", valid: `{"a":1}`, invalid: `{a:1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.valueType, func(t *testing.T) {
+			configs, db := newTestConfigs(t)
+			key := "custom." + tt.valueType
+			entry := models.Configuration{Key: key, Value: tt.valid, Type: tt.valueType, IsSystem: true}
+			if err := db.Create(&entry).Error; err != nil {
+				t.Fatal(err)
+			}
+			admin := ConfigActor{Admin: true, Name: "admin"}
+			if _, err := configs.SetConfigurationAs(admin, key, tt.invalid, "", ""); !errors.Is(err, ErrInvalidConfigValue) {
+				t.Fatalf("设置 %q err = %v, want ErrInvalidConfigValue", tt.invalid, err)
+			}
+			if audits := deniedAudits(t, db); len(audits) != 1 || audits[0]["key"] != key {
+				t.Fatalf("审计日志 = %v", audits)
+			}
+			if _, err := configs.SetConfigurationAs(admin, key, tt.valid, "", ""); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
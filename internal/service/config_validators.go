@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"userclient/pkg/barcode"
+)
+
+// LinkAllowListKey 扫码网址可点击的域名白名单（JSON字符串数组），修改后热更新
+const LinkAllowListKey = "barcode.link_allow_list"
+
+// ConfigValidator 配置值校验函数
+type ConfigValidator func(value string) error
+
+// systemConfigKey 受保护的系统配置项
+type systemConfigKey struct {
+	validate ConfigValidator
+	restart  bool // 修改后需重启才能生效，否则通过 OnChange 热更新
+}
+
+// systemConfigKeys 已登记校验规则的系统配置项，新增运行时读取的系统配置时需同步在此登记
+var systemConfigKeys = map[string]systemConfigKey{
+	"scanner.timeout_ms":        {validate: intRange(10, 10000)},
+	"scanner.timeout":           {validate: intRange(10, 60000)},
+	"scanner.min_length":        {validate: intRange(1, 1000)},
+	"scanner.max_length":        {validate: intRange(1, 1000)},
+	"scanner.auto_clear":        {validate: boolValue},
+	"scanner.terminators":       {validate: terminatorList},
+	"websocket.port":            {validate: port, restart: true},
+	"websocket.max_connections": {validate: intRange(1, 10000), restart: true},
+	"api.port":                  {validate: port, restart: true},
+	"api.cors_enabled":          {validate: boolValue, restart: true},
+	"database.max_idle_conns":   {validate: intRange(1, 1000), restart: true},
+	"database.max_open_conns":   {validate: intRange(1, 1000), restart: true},
+	"log.level":                 {validate: oneOf("debug", "info", "warn", "error")},
+	"log.file_enabled":          {validate: boolValue, restart: true},
+	"security.rate_limit":       {validate: intRange(0, 100000)},
+	"security.jwt_secret":       {validate: minLength(16), restart: true},
+	"system.auto_cleanup_days":  {validate: intRange(0, 3650)},
+	LinkAllowListKey:            {validate: linkAllowList},
+	ScannerPresetsKey:           {validate: scannerPresets, restart: true},
+	FeedbackEnabledKey:          {validate: boolValue},
+	FeedbackSuccessFreqKey:      {validate: intRange(37, 32767)},
+	FeedbackErrorFreqKey:        {validate: intRange(37, 32767)},
+	FeedbackDurationKey:         {validate: intRange(10, 2000)},
+	FeedbackSuccessWAVKey:       {validate: wavPath},
+	FeedbackErrorWAVKey:         {validate: wavPath},
+}
+
+// ValidateSystemConfig 校验系统配置值，未登记的键按配置类型校验
+func ValidateSystemConfig(key, valueType, value string) error {
+	if rule, ok := systemConfigKeys[key]; ok {
+		return rule.validate(value)
+	}
+	return validateByType(valueType, value)
+}
+
+// validateByType 按配置类型（string, int, bool, json）校验
+func validateByType(valueType, value string) error {
+	switch valueType {
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("须为整数")
+		}
+	case "bool":
+		return boolValue(value)
+	case "json":
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("须为合法的JSON")
+		}
+	}
+	return nil
+}
+
+// intRange 整数范围校验
+func intRange(min, max int) ConfigValidator {
+	return func(value string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("须为整数")
+		}
+		if n < min || n > max {
+			return fmt.Errorf("须在 %d 到 %d 之间", min, max)
+		}
+		return nil
+	}
+}
+
+// port 端口号校验
+func port(value string) error {
+	return intRange(1, 65535)(value)
+}
+
+// boolValue 布尔值校验
+func boolValue(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("须为 true 或 false")
+	}
+	return nil
+}
+
+// oneOf 枚举值校验
+func oneOf(values ...string) ConfigValidator {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("须为 %s 之一", strings.Join(values, ", "))
+	}
+}
+
+// minLength 最小长度校验
+func minLength(n int) ConfigValidator {
+	return func(value string) error {
+		if len(value) < n {
+			return fmt.Errorf("长度不能少于 %d", n)
+		}
+		return nil
+	}
+}
+
+// linkAllowList 域名白名单校验
+func linkAllowList(value string) error {
+	_, err := ParseLinkAllowList(value)
+	return err
+}
+
+// ParseLinkAllowList 解析域名白名单配置值（JSON字符串数组，支持 *.example.com 通配）
+func ParseLinkAllowList(value string) (*barcode.LinkAllowList, error) {
+	var entries []string
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, fmt.Errorf("须为域名组成的JSON数组")
+	}
+	return barcode.ParseLinkAllowList(entries)
+}
+
+// terminatorList 扫码终止符校验（逗号分隔的 enter、cr、lf、tab，或单独的 none）
+func terminatorList(value string) error {
+	names := ParseTerminatorList(value)
+	if len(names) == 0 {
+		return fmt.Errorf("不能为空，扫码枪不发送后缀时设置为 none")
+	}
+	for _, name := range names {
+		switch name {
+		case "enter", "cr", "lf", "tab":
+		case "none":
+			if len(names) > 1 {
+				return fmt.Errorf("none 不能与其他终止符同时配置")
+			}
+		default:
+			return fmt.Errorf("须为 enter、cr、lf、tab 或 none")
+		}
+	}
+	return nil
+}
+
+// ParseTerminatorList 解析逗号分隔的终止符配置值，忽略空项
+func ParseTerminatorList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// scannerPresets 站点自定义扫码枪预设校验
+func scannerPresets(value string) error {
+	_, err := ParseScannerPresets(value)
+	return err
+}
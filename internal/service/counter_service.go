@@ -0,0 +1,537 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 持久化计数器的作用域
+const (
+	CounterScopeScans      = "scans"       // 累计扫码数：total 及 device:<id>
+	CounterScopeScansDay   = "scans_day"   // 当天扫码数，按生产日轮转
+	CounterScopeScansShift = "scans_shift" // 当班扫码数，按班次轮转，未配置班次时不使用
+	CounterScopeIdle       = "idle"        // 最后扫码时间（Unix毫秒）：station 及 device:<id>
+	CounterScopeHub        = "hub"         // WebSocket 广播序号的预留上限
+)
+
+// 计数器名称
+const (
+	CounterTotal   = "total"
+	CounterStation = "station"
+	CounterHubSeq  = "seq"
+)
+
+// ErrCounterNotResettable 计数器只能递增，不允许清零（如广播序号）
+var ErrCounterNotResettable = errors.New("该计数器只能递增，不允许清零")
+
+// DeviceCounterName 设备计数器的名称
+func DeviceCounterName(deviceID uint) string {
+	return "device:" + strconv.FormatUint(uint64(deviceID), 10)
+}
+
+// ParseDeviceCounterName 解析设备计数器的名称，不是设备计数器时返回 false
+func ParseDeviceCounterName(name string) (uint, bool) {
+	value, ok := strings.CutPrefix(name, "device:")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// CounterRollover 一个计数器的清零或轮转结果
+type CounterRollover struct {
+	Scope  string `json:"scope"`
+	Name   string `json:"name"`
+	Value  int64  `json:"value"`  // 清零前的值
+	Period string `json:"period"` // 新的周期
+}
+
+// counterKey 计数器键
+type counterKey struct {
+	scope string
+	name  string
+}
+
+// counterEntry 内存中的计数器，dirty 表示有尚未写入的变化
+type counterEntry struct {
+	value     int64
+	epoch     int64
+	period    string
+	lastValue int64
+	rolledAt  *time.Time
+	updatedAt time.Time
+	dirty     bool
+}
+
+// CounterService 持久化计数器
+//
+// 计数在内存中累加，每隔 counters.flush_interval 把有变化的计数器批量写入 counters 表，
+// 异常退出时计数最多落后一个写入间隔。写入按批次（Epoch）取较大值，延迟的写入不会让计数倒退；
+// 清零及轮转使批次加1并立即写入。按周期轮转的作用域由 SetPeriod 登记，周期变化时整体轮转，
+// 重启时跨过周期的计数器在加载时轮转。
+type CounterService struct {
+	db      *gorm.DB
+	config  config.CountersConfig
+	logger  *logrus.Logger
+	flushMu sync.Mutex // 串行化写入，保证同一计数器的写入按内存中的顺序到达
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu       sync.Mutex
+	counters map[counterKey]*counterEntry
+	periods  map[string]func(time.Time) string
+}
+
+// NewCounterService 创建持久化计数器服务
+func NewCounterService(db *gorm.DB, cfg config.CountersConfig, logger *logrus.Logger) *CounterService {
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.SequenceBlock <= 0 {
+		cfg.SequenceBlock = 1000
+	}
+	return &CounterService{
+		db:       db,
+		config:   cfg,
+		logger:   logger,
+		done:     make(chan struct{}),
+		counters: make(map[counterKey]*counterEntry),
+		periods:  make(map[string]func(time.Time) string),
+	}
+}
+
+// SetPeriod 登记按周期轮转的作用域，period 返回时间所属的周期，返回空表示不轮转（如不在班次内）；须在 Load 之前调用
+func (s *CounterService) SetPeriod(scope string, period func(time.Time) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.periods[scope] = period
+}
+
+// Load 从数据库恢复计数器，停机期间跨过周期的计数器随即轮转
+func (s *CounterService) Load() error {
+	var rows []*models.Counter
+	if err := s.db.Find(&rows).Error; err != nil {
+		return fmt.Errorf("读取计数器失败: %w", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for _, row := range rows {
+		s.counters[counterKey{scope: row.Scope, name: row.Name}] = &counterEntry{
+			value:     row.Value,
+			epoch:     row.Epoch,
+			period:    row.Period,
+			lastValue: row.LastValue,
+			rolledAt:  row.RolledAt,
+			updatedAt: row.UpdatedAt,
+		}
+	}
+	rolled := s.rollPeriods(now)
+	s.mu.Unlock()
+
+	if len(rolled) > 0 {
+		s.logger.WithField("counters", len(rolled)).Info("计数器已按周期轮转")
+		return s.Flush()
+	}
+	return nil
+}
+
+// Start 启动定时写入及周期轮转
+func (s *CounterService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.Lock()
+				rolled := s.rollPeriods(time.Now())
+				s.mu.Unlock()
+				if len(rolled) > 0 {
+					s.logger.WithField("counters", len(rolled)).Info("计数器已按周期轮转")
+				}
+				if err := s.Flush(); err != nil {
+					s.logger.WithError(err).Warn("写入计数器失败，下次重试")
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时写入并写入剩余的变化
+func (s *CounterService) Close() {
+	close(s.done)
+	s.wg.Wait()
+
+	if err := s.Flush(); err != nil {
+		s.logger.WithError(err).Warn("写入计数器失败")
+	}
+}
+
+// Observe 累计一次有效扫码
+func (s *CounterService) Observe(record *models.BarcodeRecord) {
+	names := []string{CounterTotal}
+	if record.DeviceID != nil {
+		names = append(names, DeviceCounterName(*record.DeviceID))
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, scope := range []string{CounterScopeScans, CounterScopeScansDay, CounterScopeScansShift} {
+		if scope == CounterScopeScansShift && s.periods[scope] == nil {
+			continue
+		}
+		for _, name := range names {
+			entry := s.entry(counterKey{scope: scope, name: name}, now)
+			entry.value++
+			entry.updatedAt = now
+			entry.dirty = true
+		}
+	}
+}
+
+// Add 累加计数器，返回累加后的值
+func (s *CounterService) Add(scope, name string, delta int64) int64 {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(counterKey{scope: scope, name: name}, now)
+	entry.value += delta
+	entry.updatedAt = now
+	entry.dirty = true
+	return entry.value
+}
+
+// Advance 把计数器推进到 value，小于当前值时不变；用于最后扫码时间、序号等只增不减的值
+func (s *CounterService) Advance(scope, name string, value int64) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(counterKey{scope: scope, name: name}, now)
+	if value > entry.value {
+		entry.value = value
+		entry.updatedAt = now
+		entry.dirty = true
+	}
+}
+
+// Get 计数器当前的值，不存在时为0
+func (s *CounterService) Get(scope, name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.counters[counterKey{scope: scope, name: name}]; ok {
+		return entry.value
+	}
+	return 0
+}
+
+// List 计数器当前的值（含尚未写入的变化），scope 为空表示全部，按作用域及名称排序
+func (s *CounterService) List(scope string) []*models.Counter {
+	s.mu.Lock()
+	counters := make([]*models.Counter, 0, len(s.counters))
+	for key, entry := range s.counters {
+		if scope != "" && key.scope != scope {
+			continue
+		}
+		counters = append(counters, entry.model(key))
+	}
+	s.mu.Unlock()
+
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Scope != counters[j].Scope {
+			return counters[i].Scope < counters[j].Scope
+		}
+		return counters[i].Name < counters[j].Name
+	})
+	return counters
+}
+
+// Reset 清零计数器并立即写入，name 为空表示作用域内的全部计数器；清零前的值保存在 last_value
+func (s *CounterService) Reset(scope, name string) ([]CounterRollover, error) {
+	if scope == CounterScopeHub {
+		return nil, ErrCounterNotResettable
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	var rolled []CounterRollover
+	for key, entry := range s.counters {
+		if key.scope != scope || (name != "" && key.name != name) {
+			continue
+		}
+		rolled = append(rolled, entry.roll(key, entry.period, now))
+	}
+	s.mu.Unlock()
+
+	if len(rolled) == 0 {
+		return rolled, nil
+	}
+	s.logger.WithField("scope", scope).WithField("name", name).WithField("counters", len(rolled)).Info("计数器已清零")
+	return rolled, s.Flush()
+}
+
+// Rollover 立即轮转按周期轮转的作用域（如提前换班），计数器进入当前周期；周期未登记时同 Reset
+func (s *CounterService) Rollover(scope string) ([]CounterRollover, error) {
+	if scope == CounterScopeHub {
+		return nil, ErrCounterNotResettable
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	var period string
+	if fn := s.periods[scope]; fn != nil {
+		period = fn(now)
+	}
+	var rolled []CounterRollover
+	for key, entry := range s.counters {
+		if key.scope != scope {
+			continue
+		}
+		next := period
+		if next == "" {
+			next = entry.period
+		}
+		rolled = append(rolled, entry.roll(key, next, now))
+	}
+	s.mu.Unlock()
+
+	if len(rolled) == 0 {
+		return rolled, nil
+	}
+	s.logger.WithField("scope", scope).WithField("counters", len(rolled)).Info("计数器已轮转")
+	return rolled, s.Flush()
+}
+
+// Flush 把有变化的计数器写入数据库，失败时保留变化等待下次写入
+//
+// 库中批次较新的行不会被覆盖；批次相同时取较大值，因此计数不会因写入乱序或重复而倒退。
+func (s *CounterService) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	rows := make([]*models.Counter, 0)
+	for key, entry := range s.counters {
+		if !entry.dirty {
+			continue
+		}
+		entry.dirty = false
+		rows = append(rows, entry.model(key))
+	}
+	s.mu.Unlock()
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "scope"}, {Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"value":      gorm.Expr("CASE WHEN counters.epoch = excluded.epoch THEN MAX(counters.value, excluded.value) ELSE excluded.value END"),
+			"epoch":      gorm.Expr("excluded.epoch"),
+			"period":     gorm.Expr("excluded.period"),
+			"last_value": gorm.Expr("excluded.last_value"),
+			"rolled_at":  gorm.Expr("excluded.rolled_at"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{gorm.Expr("counters.epoch <= excluded.epoch")}},
+	}).CreateInBatches(rows, 100).Error
+	if err != nil {
+		s.mu.Lock()
+		for _, row := range rows {
+			if entry, ok := s.counters[counterKey{scope: row.Scope, name: row.Name}]; ok {
+				entry.dirty = true
+			}
+		}
+		s.mu.Unlock()
+		return fmt.Errorf("写入计数器失败: %w", err)
+	}
+	return nil
+}
+
+// entry 获取计数器，不存在时创建；所在作用域的周期已变化时先轮转（调用方持有锁）
+func (s *CounterService) entry(key counterKey, now time.Time) *counterEntry {
+	var period string
+	if fn := s.periods[key.scope]; fn != nil {
+		period = fn(now)
+	}
+
+	entry, ok := s.counters[key]
+	if !ok {
+		entry = &counterEntry{period: period}
+		s.counters[key] = entry
+		return entry
+	}
+	if period != "" && entry.period != period {
+		entry.roll(key, period, now)
+	}
+	return entry
+}
+
+// rollPeriods 轮转周期已变化的计数器（调用方持有锁）
+func (s *CounterService) rollPeriods(now time.Time) []CounterRollover {
+	current := make(map[string]string, len(s.periods))
+	for scope, fn := range s.periods {
+		current[scope] = fn(now)
+	}
+
+	var rolled []CounterRollover
+	for key, entry := range s.counters {
+		period := current[key.scope]
+		if period == "" || entry.period == period {
+			continue
+		}
+		rolled = append(rolled, entry.roll(key, period, now))
+	}
+	return rolled
+}
+
+// roll 清零并进入下一批次
+func (e *counterEntry) roll(key counterKey, period string, now time.Time) CounterRollover {
+	rollover := CounterRollover{Scope: key.scope, Name: key.name, Value: e.value, Period: period}
+	rolledAt := now
+	e.lastValue = e.value
+	e.value = 0
+	e.epoch++
+	e.period = period
+	e.rolledAt = &rolledAt
+	e.updatedAt = now
+	e.dirty = true
+	return rollover
+}
+
+// model 转换为数据库模型
+func (e *counterEntry) model(key counterKey) *models.Counter {
+	return &models.Counter{
+		Scope:     key.scope,
+		Name:      key.name,
+		Value:     e.value,
+		Epoch:     e.epoch,
+		Period:    e.period,
+		LastValue: e.lastValue,
+		RolledAt:  e.rolledAt,
+		UpdatedAt: e.updatedAt,
+	}
+}
+
+// CounterSequence 按块预留的持久化序号
+//
+// 数据库中只保存已预留的上限，重启后从上限之后继续编号，异常退出时跳过未用完的部分而不会重复。
+// 用到预留量的一半时预留下一块，随定时写入保存；用到已同步写入的上限时同步写入。
+type CounterSequence struct {
+	counters *CounterService
+	scope    string
+	name     string
+	block    int64
+	start    int64
+
+	mu       sync.Mutex
+	ceiling  int64
+	reserved int64 // 已同步写入的上限，序号达到此值前无需等待写入
+}
+
+// Sequence 创建按块预留的序号并同步写入第一块预留，须在 Load 之后调用
+func (s *CounterService) Sequence(scope, name string) (*CounterSequence, error) {
+	start := s.Get(scope, name)
+	sequence := &CounterSequence{
+		counters: s,
+		scope:    scope,
+		name:     name,
+		block:    s.config.SequenceBlock,
+		start:    start,
+		ceiling:  start + s.config.SequenceBlock,
+	}
+	sequence.reserved = sequence.ceiling
+	s.Advance(scope, name, sequence.ceiling)
+	if err := s.Flush(); err != nil {
+		return nil, err
+	}
+	return sequence, nil
+}
+
+// Start 上次运行预留的上限，本次运行的序号从其后开始
+func (q *CounterSequence) Start() int64 {
+	return q.start
+}
+
+// Advance 记录已使用的序号
+func (q *CounterSequence) Advance(value int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if value+q.block/2 > q.ceiling {
+		q.ceiling = value + q.block
+		q.counters.Advance(q.scope, q.name, q.ceiling)
+	}
+	// 定时写入是否已保存新的预留无从得知，用到同步写入的上限时须同步写入
+	if value >= q.reserved {
+		if err := q.counters.Flush(); err != nil {
+			q.counters.logger.WithError(err).Warn("预留序号失败，异常退出后重启可能重复编号")
+			return
+		}
+		q.reserved = q.ceiling
+	}
+}
+
+// CounterPeriods 计数器按生产日及班次轮转的周期函数，生产日划分与产量目标相同；未配置班次时 shift 为nil
+func CounterPeriods(windows []config.ShiftWindow, loc *time.Location) (day, shift func(time.Time) string, err error) {
+	shifts, err := parseShifts(windows)
+	if err != nil {
+		return nil, nil, err
+	}
+	offset := productionDayOffset(shifts)
+
+	day = func(at time.Time) string {
+		local := at.In(loc)
+		if local.Hour()*60+local.Minute() < offset {
+			local = time.Date(local.Year(), local.Month(), local.Day()-1, 12, 0, 0, 0, loc)
+		}
+		return local.Format(dayLayout)
+	}
+	if len(shifts) == 0 {
+		return day, nil, nil
+	}
+
+	shift = func(at time.Time) string {
+		local := at.In(loc)
+		minute := local.Hour()*60 + local.Minute()
+		for _, window := range shifts {
+			if !window.contains(minute) {
+				continue
+			}
+			// 跨零点班次零点后的部分属于前一天开始的班次
+			start := local
+			if window.start > window.end && minute < window.end {
+				start = local.AddDate(0, 0, -1)
+			}
+			return start.Format(dayLayout) + "/" + window.name
+		}
+		return ""
+	}
+	return day, shift, nil
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// openTestDB 打开并迁移测试数据库文件，同一路径再次打开相当于进程重启后的连接
+func openTestDB(t *testing.T, path string) *database.DB {
+	t.Helper()
+	db, err := database.New(&config.DatabaseConfig{DSN: path, LogLevel: "silent", MaxIdleConns: 1, MaxOpenConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// startCounters 模拟一次进程启动：创建计数器服务并从数据库恢复；不启动定时写入，由测试调用 Flush 模拟写入间隔到达
+func startCounters(t *testing.T, db *database.DB, periods map[string]func(time.Time) string) *CounterService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	counters := NewCounterService(db.DB, config.CountersConfig{FlushInterval: time.Hour, SequenceBlock: 10}, logger)
+	for scope, period := range periods {
+		counters.SetPeriod(scope, period)
+	}
+	if err := counters.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return counters
+}
+
+func TestCounterServiceMonotonicAcrossKill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.db")
+	device := uint(3)
+	record := &models.BarcodeRecord{DeviceID: &device}
+
+	var lastSeen, lastIdle int64
+	var usedSeq int64
+	for run := 0; run < 5; run++ {
+		db := openTestDB(t, path)
+		counters := startCounters(t, db, nil)
+
+		// 重启后的计数不小于上次已写入的值，最多落后一个写入间隔
+		total := counters.Get(CounterScopeScans, CounterTotal)
+		if total < lastSeen {
+			t.Fatalf("run %d: total went backwards from %d to %d", run, lastSeen, total)
+		}
+		if idle := counters.Get(CounterScopeIdle, CounterStation); idle < lastIdle {
+			t.Fatalf("run %d: idle went backwards from %d to %d", run, lastIdle, idle)
+		}
+		sequence, err := counters.Sequence(CounterScopeHub, CounterHubSeq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sequence.Start() < usedSeq {
+			t.Fatalf("run %d: sequence restarts at %d, already used %d", run, sequence.Start(), usedSeq)
+		}
+
+		// 写入间隔内的扫码
+		for i := 0; i < 7; i++ {
+			counters.Observe(record)
+		}
+		counters.Advance(CounterScopeIdle, CounterStation, int64(1000+run*10))
+		if err := counters.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		lastSeen = counters.Get(CounterScopeScans, CounterTotal)
+		lastIdle = counters.Get(CounterScopeIdle, CounterStation)
+
+		// 广播序号超过预留上限时同步写入
+		for seq := sequence.Start() + 1; seq <= sequence.Start()+25; seq++ {
+			sequence.Advance(seq)
+			usedSeq = seq
+		}
+
+		// 下次写入前被终止：这些变化丢失，不调用 Close
+		for i := 0; i < 3; i++ {
+			counters.Observe(record)
+		}
+		counters.Advance(CounterScopeIdle, CounterStation, int64(1000+run*10+5))
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := openTestDB(t, path)
+	defer db.Close()
+	counters := startCounters(t, db, nil)
+	if got := counters.Get(CounterScopeScans, CounterTotal); got != 5*7 {
+		t.Errorf("total = %d, want %d flushed scans", got, 5*7)
+	}
+	if got := counters.Get(CounterScopeScans, DeviceCounterName(device)); got != 5*7 {
+		t.Errorf("device count = %d, want %d", got, 5*7)
+	}
+}
+
+func TestCounterServiceStaleFlushDoesNotRegress(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "counters.db"))
+	defer db.Close()
+	counters := startCounters(t, db, nil)
+
+	counters.Add(CounterScopeScans, CounterTotal, 10)
+	if err := counters.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一批次较小的值（如另一进程延迟到达的写入）不会让计数倒退
+	stale := startCounters(t, db, nil)
+	stale.Add(CounterScopeScans, CounterTotal, -6)
+	if err := stale.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	var row models.Counter
+	if err := db.Where("scope = ? AND name = ?", CounterScopeScans, CounterTotal).First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.Value != 10 {
+		t.Fatalf("stale flush lowered the counter to %d", row.Value)
+	}
+
+	// 清零后的批次较新，旧批次的写入不会覆盖清零
+	if _, err := counters.Reset(CounterScopeScans, CounterTotal); err != nil {
+		t.Fatal(err)
+	}
+	stale.Add(CounterScopeScans, CounterTotal, 100)
+	if err := stale.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Where("scope = ? AND name = ?", CounterScopeScans, CounterTotal).First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.Value != 0 || row.LastValue != 10 || row.Epoch != 1 {
+		t.Fatalf("after reset row = value %d, last %d, epoch %d; want 0, 10, 1", row.Value, row.LastValue, row.Epoch)
+	}
+}
+
+func TestCounterServiceRollover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.db")
+	db := openTestDB(t, path)
+	period := "2026-10-15"
+	periods := map[string]func(time.Time) string{
+		CounterScopeScansDay:   func(time.Time) string { return period },
+		CounterScopeScansShift: func(time.Time) string { return period + "/早班" },
+	}
+	counters := startCounters(t, db, periods)
+	record := &models.BarcodeRecord{}
+	for i := 0; i < 4; i++ {
+		counters.Observe(record)
+	}
+
+	// 进入新的生产日：下一次扫码前当天计数轮转，累计计数不受影响
+	period = "2026-10-16"
+	counters.Observe(record)
+	if got := counters.Get(CounterScopeScansDay, CounterTotal); got != 1 {
+		t.Errorf("day count after rollover = %d, want 1", got)
+	}
+	if got := counters.Get(CounterScopeScans, CounterTotal); got != 5 {
+		t.Errorf("total = %d, want 5", got)
+	}
+	for _, counter := range counters.List(CounterScopeScansDay) {
+		if counter.Period != "2026-10-16" || counter.LastValue != 4 || counter.Epoch != 1 || counter.RolledAt == nil {
+			t.Errorf("rolled counter = %+v", counter)
+		}
+	}
+
+	// 提前换班
+	rolled, err := counters.Rollover(CounterScopeScansShift)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rolled) != 1 || rolled[0].Value != 1 || rolled[0].Period != "2026-10-16/早班" {
+		t.Errorf("shift rollover = %+v", rolled)
+	}
+	if got := counters.Get(CounterScopeScansShift, CounterTotal); got != 0 {
+		t.Errorf("shift count after rollover = %d", got)
+	}
+
+	// 广播序号不允许清零
+	if _, err := counters.Reset(CounterScopeHub, ""); !errors.Is(err, ErrCounterNotResettable) {
+		t.Errorf("reset hub sequence: err = %v", err)
+	}
+	counters.Close()
+	db.Close()
+
+	// 停机期间跨过生产日，加载时即轮转
+	period = "2026-10-17"
+	db = openTestDB(t, path)
+	defer db.Close()
+	counters = startCounters(t, db, periods)
+	if got := counters.Get(CounterScopeScansDay, CounterTotal); got != 0 {
+		t.Errorf("day count after restart on a new day = %d, want 0", got)
+	}
+	if got := counters.Get(CounterScopeScans, CounterTotal); got != 5 {
+		t.Errorf("total after restart = %d, want 5", got)
+	}
+	var row models.Counter
+	if err := db.Where("scope = ? AND name = ?", CounterScopeScansDay, CounterTotal).First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.Period != "2026-10-17" || row.LastValue != 1 || row.Epoch != 2 {
+		t.Errorf("rollover on load was not persisted: %+v", row)
+	}
+}
+
+// dstShifts 白班 06:00–18:00、夜班跨零点到 06:00，生产日从 06:00 开始
+var dstShifts = []config.ShiftWindow{
+	{Name: "day", Start: "06:00", End: "18:00"},
+	{Name: "night", Start: "18:00", End: "06:00"},
+}
+
+// dstCases America/New_York 2026 年夏令时切换前后的扫码时间（UTC）及所属生产日和班次：
+// 3月8日 02:00 跳到 03:00，11月1日 02:00 回到 01:00，01:30 出现两次
+var dstCases = []struct {
+	name  string
+	at    time.Time
+	day   string
+	shift string
+}{
+	{"spring: 01:59 EST", time.Date(2026, 3, 8, 6, 59, 0, 0, time.UTC), "2026-03-07", "night"},
+	{"spring: 03:00 EDT right after the jump", time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), "2026-03-07", "night"},
+	{"spring: 05:59 EDT", time.Date(2026, 3, 8, 9, 59, 0, 0, time.UTC), "2026-03-07", "night"},
+	{"spring: 06:00 EDT day starts", time.Date(2026, 3, 8, 10, 0, 0, 0, time.UTC), "2026-03-08", "day"},
+	{"spring: 18:00 EDT night starts", time.Date(2026, 3, 8, 22, 0, 0, 0, time.UTC), "2026-03-08", "night"},
+	{"fall: first 01:30 EDT", time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), "2026-10-31", "night"},
+	{"fall: second 01:30 EST", time.Date(2026, 11, 1, 6, 30, 0, 0, time.UTC), "2026-10-31", "night"},
+	{"fall: 05:59 EST", time.Date(2026, 11, 1, 10, 59, 0, 0, time.UTC), "2026-10-31", "night"},
+	{"fall: 06:00 EST day starts", time.Date(2026, 11, 1, 11, 0, 0, 0, time.UTC), "2026-11-01", "day"},
+	{"fall: 18:00 EST night starts", time.Date(2026, 11, 1, 23, 0, 0, 0, time.UTC), "2026-11-01", "night"},
+}
+
+func TestCounterPeriodsDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, shift, err := CounterPeriods(dstShifts, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range dstCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := day(tt.at); got != tt.day {
+				t.Errorf("day(%v) = %s, want %s", tt.at.In(loc), got, tt.day)
+			}
+			if got, want := shift(tt.at), tt.day+"/"+tt.shift; got != want {
+				t.Errorf("shift(%v) = %s, want %s", tt.at.In(loc), got, want)
+			}
+		})
+	}
+}
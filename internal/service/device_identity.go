@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// DeviceIdentity 设备名称及所属工位，用于推送消息
+type DeviceIdentity struct {
+	Name    string
+	Station string // 设备未分配工位时为空
+}
+
+// Identity 按ID查找设备名称及所属工位，结果缓存到设备变更；已删除的设备仍返回原名称，设备不存在或查询失败时返回 false
+func (s *DeviceService) Identity(id uint) (DeviceIdentity, bool) {
+	s.paths.Lock()
+	identity, cached := s.identities[id]
+	gen := s.pathGen
+	s.paths.Unlock()
+	if cached {
+		if identity == nil {
+			return DeviceIdentity{}, false
+		}
+		return *identity, true
+	}
+
+	var device models.Device
+	err := s.db.Unscoped().Select("id, name, station").First(&device, id).Error
+	switch {
+	case err == nil:
+		identity = &DeviceIdentity{Name: device.Name, Station: device.Station}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		identity = nil
+	default:
+		// 查询失败不缓存，下次重新查询
+		s.logger.WithError(err).Warn("查询设备名称失败")
+		return DeviceIdentity{}, false
+	}
+
+	s.paths.Lock()
+	if s.pathGen == gen {
+		if s.identities == nil {
+			s.identities = make(map[uint]*DeviceIdentity)
+		}
+		s.identities[id] = identity
+	}
+	s.paths.Unlock()
+	if identity == nil {
+		return DeviceIdentity{}, false
+	}
+	return *identity, true
+}
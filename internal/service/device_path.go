@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"userclient/internal/models"
+)
+
+// ErrDevicePathTaken 键盘设备路径已登记到本工位的其他设备
+var ErrDevicePathTaken = errors.New("设备路径已被使用")
+
+// minSerialMatchLength 按序列号匹配设备路径时序列号的最小长度，过短的序列号容易误匹配
+const minSerialMatchLength = 4
+
+// ResolveDevicePath 查找登记了该键盘设备路径的本工位设备
+//
+// 优先匹配设备的 device_path（不区分大小写），其次匹配设备路径中包含的序列号（USB设备的实例路径通常含序列号）。
+// 结果按路径缓存，设备变更后重新查询；按键采集线程每次扫码都会调用，未登记的路径同样缓存。
+func (s *DeviceService) ResolveDevicePath(path string) (uint, bool) {
+	key := strings.ToUpper(path)
+	s.paths.Lock()
+	id, ok := s.pathCache[key]
+	gen := s.pathGen
+	s.paths.Unlock()
+	if ok {
+		return id, id > 0
+	}
+
+	var devices []models.Device
+	if err := s.db.Select("id, serial_no, device_path").Where("station = ?", s.station).Find(&devices).Error; err != nil {
+		s.logger.WithError(err).Warn("查询键盘设备路径失败")
+		return 0, false
+	}
+	id = matchDevicePath(devices, key)
+
+	s.paths.Lock()
+	if s.pathGen == gen {
+		if s.pathCache == nil {
+			s.pathCache = make(map[string]uint)
+		}
+		s.pathCache[key] = id
+	}
+	s.paths.Unlock()
+	return id, id > 0
+}
+
+// matchDevicePath 匹配大写的设备路径，未匹配时返回0
+func matchDevicePath(devices []models.Device, path string) uint {
+	for _, device := range devices {
+		if device.DevicePath != "" && strings.ToUpper(device.DevicePath) == path {
+			return device.ID
+		}
+	}
+	for _, device := range devices {
+		if len(device.SerialNo) >= minSerialMatchLength && strings.Contains(path, strings.ToUpper(device.SerialNo)) {
+			return device.ID
+		}
+	}
+	return 0
+}
+
+// ResolveNetworkHost 查找命令地址（command_addr）的主机为 host 的本工位设备，网络扫码枪按连接的来源IP归属设备
+//
+// 命令地址通过命令通道接口修改，不经过设备变更通知，因此不缓存；网络扫码枪每次扫码查询一次。
+func (s *DeviceService) ResolveNetworkHost(host string) (uint, bool) {
+	var devices []models.Device
+	if err := s.db.Select("id, command_addr").Where("station = ? AND command_addr <> ''", s.station).Find(&devices).Error; err != nil {
+		s.logger.WithError(err).Warn("查询设备命令地址失败")
+		return 0, false
+	}
+	for _, device := range devices {
+		if addrHost, _, err := net.SplitHostPort(device.CommandAddr); err == nil && strings.EqualFold(addrHost, host) {
+			return device.ID, true
+		}
+	}
+	return 0, false
+}
+
+// SetDevicePath 登记设备的键盘设备路径，为空表示取消登记；同一工位的设备路径不能重复
+func (s *DeviceService) SetDevicePath(id uint, path string) (*models.Device, error) {
+	device, err := s.GetDevice(id)
+	if err != nil {
+		return nil, fmt.Errorf("设备不存在: %w", err)
+	}
+
+	if path != "" {
+		var existing []models.Device
+		err := s.db.Where("station = ? AND id <> ? AND UPPER(device_path) = ?", device.Station, id, strings.ToUpper(path)).
+			Limit(1).Find(&existing).Error
+		if err != nil {
+			return nil, fmt.Errorf("查询设备路径失败: %w", err)
+		}
+		if len(existing) > 0 {
+			return nil, fmt.Errorf("%w: 已登记到设备 %d（%s）", ErrDevicePathTaken, existing[0].ID, existing[0].Name)
+		}
+	}
+
+	updates := map[string]interface{}{"device_path": path, "updated_at": time.Now()}
+	if err := s.db.Model(device).Updates(updates).Error; err != nil {
+		s.logger.WithError(err).Error("登记设备路径失败")
+		return nil, fmt.Errorf("登记设备路径失败: %w", err)
+	}
+
+	s.logger.WithField("device_id", id).WithField("device_path", path).Info("设备路径已更新")
+	s.notifyChanged()
+	return s.GetDevice(id)
+}
@@ -1,31 +1,76 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 	
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	
+	"userclient/internal/database"
 	"userclient/internal/models"
 )
 
+// ErrDeviceNameTaken 设备名称已被其他设备使用
+var ErrDeviceNameTaken = errors.New("设备名称已被使用")
+
 // DeviceService 设备服务
+//
+// 多个工位可共用一个数据库，活跃设备按工位区分：激活只影响设备所属工位，
+// 查询活跃设备时只查本工位。
 type DeviceService struct {
-	db     *gorm.DB
-	logger *logrus.Logger
+	db      *gorm.DB
+	station string // 本工位名称
+	logger  *logrus.Logger
+	changed []func()
+	
+	paths      sync.Mutex
+	pathCache  map[string]uint             // 键盘设备路径 -> 设备ID，0表示未登记；设备变更时清空
+	identities map[uint]*DeviceIdentity    // 设备ID -> 名称及工位，nil表示设备不存在；设备变更时清空
+	pathGen    uint64                      // 清空缓存的次数，查询期间设备有变更时不写入缓存
+	
+	seenMu sync.Mutex
+	seenAt map[uint]time.Time // 设备ID -> 上次写入最后活跃时间的时刻
 }
 
-// NewDeviceService 创建设备服务
-func NewDeviceService(db *gorm.DB, logger *logrus.Logger) *DeviceService {
+// lastSeenInterval 同一设备写入最后活跃时间的最小间隔，连续扫码时不必每次都更新设备表
+const lastSeenInterval = 5 * time.Second
+
+// NewDeviceService 创建设备服务，station 为本工位名称
+func NewDeviceService(db *gorm.DB, station string, logger *logrus.Logger) *DeviceService {
 	return &DeviceService{
-		db:     db,
-		logger: logger,
+		db:      db,
+		station: station,
+		logger:  logger,
+	}
+}
+
+// Station 本工位名称
+func (s *DeviceService) Station() string {
+	return s.station
+}
+
+// OnChanged 注册设备创建、更新、删除、激活或停用后的回调
+func (s *DeviceService) OnChanged(listener func()) {
+	s.changed = append(s.changed, listener)
+}
+
+// notifyChanged 通知设备已变更
+func (s *DeviceService) notifyChanged() {
+	s.paths.Lock()
+	s.pathCache = nil
+	s.identities = nil
+	s.pathGen++
+	s.paths.Unlock()
+	for _, listener := range s.changed {
+		listener()
 	}
 }
 
-// GetDevices 获取设备列表
-func (s *DeviceService) GetDevices(page, pageSize int, status string) ([]*models.Device, int64, error) {
+// GetDevices 获取设备列表，包含所有工位的设备；order 为排序子句，为空时按创建时间倒序
+func (s *DeviceService) GetDevices(page, pageSize int, status, station, order string) ([]*models.Device, int64, error) {
 	var devices []*models.Device
 	var total int64
 	
@@ -36,14 +81,23 @@ func (s *DeviceService) GetDevices(page, pageSize int, status string) ([]*models
 		query = query.Where("status = ?", status)
 	}
 	
+	// 添加工位过滤
+	if station != "" {
+		query = query.Where("station = ?", station)
+	}
+	
 	// 获取总数
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 	
+	if order == "" {
+		order = "created_at DESC"
+	}
+	
 	// 分页查询
 	offset := (page - 1) * pageSize
-	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&devices).Error; err != nil {
+	if err := query.Order(order).Offset(offset).Limit(pageSize).Find(&devices).Error; err != nil {
 		return nil, 0, err
 	}
 	
@@ -59,6 +113,15 @@ func (s *DeviceService) GetDevice(id uint) (*models.Device, error) {
 	return &device, nil
 }
 
+// GetDeviceByPublicID 根据公开ID获取设备
+func (s *DeviceService) GetDeviceByPublicID(publicID string) (*models.Device, error) {
+	var device models.Device
+	if err := s.db.Where("public_id = ?", publicID).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
 // GetDeviceByName 根据名称获取设备
 func (s *DeviceService) GetDeviceByName(name string) (*models.Device, error) {
 	var device models.Device
@@ -68,7 +131,16 @@ func (s *DeviceService) GetDeviceByName(name string) (*models.Device, error) {
 	return &device, nil
 }
 
-// CreateDevice 创建设备
+// GetDeviceBySerialNo 根据序列号获取设备
+func (s *DeviceService) GetDeviceBySerialNo(serialNo string) (*models.Device, error) {
+	var device models.Device
+	if err := s.db.Where("serial_no = ?", serialNo).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// CreateDevice 创建设备，未指定工位时归入本工位；所属工位尚无活跃设备时设为活跃
 func (s *DeviceService) CreateDevice(device *models.Device) error {
 	// 检查设备名称是否已存在
 	var existingDevice models.Device
@@ -76,6 +148,23 @@ func (s *DeviceService) CreateDevice(device *models.Device) error {
 		return fmt.Errorf("设备名称 '%s' 已存在", device.Name)
 	}
 	
+	// 已删除的设备仍占用序列号，需恢复而不是新建
+	if device.SerialNo != "" {
+		var deleted []models.Device
+		if err := s.db.Unscoped().Where("serial_no = ? AND deleted_at IS NOT NULL", device.SerialNo).Limit(1).Find(&deleted).Error; err != nil {
+			return fmt.Errorf("查询已删除设备失败: %w", err)
+		}
+		if len(deleted) > 0 {
+			return &DeletedConflictError{
+				Kind:      "device",
+				Field:     "serial_no",
+				Value:     device.SerialNo,
+				ID:        deleted[0].ID,
+				DeletedAt: deleted[0].DeletedAt.Time,
+			}
+		}
+	}
+	
 	// 设置默认值
 	if device.Status == "" {
 		device.Status = "active"
@@ -85,18 +174,19 @@ func (s *DeviceService) CreateDevice(device *models.Device) error {
 		device.Type = "scanner"
 	}
 	
-	// 如果是第一个设备，设置为活跃状态
-	var count int64
-	if err := s.db.Model(&models.Device{}).Count(&count).Error; err == nil && count == 0 {
-		device.IsActive = true
+	if device.Station == "" {
+		device.Station = s.station
 	}
 	
-	if err := s.db.Create(device).Error; err != nil {
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return database.CreateStationDevice(tx, device)
+	}); err != nil {
 		s.logger.WithError(err).Error("创建设备失败")
 		return fmt.Errorf("创建设备失败: %w", err)
 	}
 	
 	s.logger.WithField("device_id", device.ID).WithField("device_name", device.Name).Info("设备创建成功")
+	s.notifyChanged()
 	return nil
 }
 
@@ -125,6 +215,7 @@ func (s *DeviceService) UpdateDevice(id uint, updates map[string]interface{}) er
 	}
 	
 	s.logger.WithField("device_id", id).Info("设备更新成功")
+	s.notifyChanged()
 	return nil
 }
 
@@ -152,33 +243,83 @@ func (s *DeviceService) DeleteDevice(id uint) error {
 	}
 	
 	s.logger.WithField("device_id", id).WithField("device_name", device.Name).Info("设备删除成功")
+	s.notifyChanged()
 	return nil
 }
 
-// ActivateDevice 激活设备
-func (s *DeviceService) ActivateDevice(id uint) error {
-	// 先将所有设备设置为非活跃状态
-	if err := s.db.Model(&models.Device{}).Where("is_active = ?", true).Update("is_active", false).Error; err != nil {
-		return fmt.Errorf("取消其他设备激活状态失败: %w", err)
+// RestoreDevice 恢复已删除的设备
+//
+// 名称已被其他设备使用时拒绝恢复；所属工位已有活跃设备时恢复为非活跃。
+func (s *DeviceService) RestoreDevice(id uint) (*models.Device, error) {
+	var device models.Device
+	if err := s.db.Unscoped().Where("deleted_at IS NOT NULL").First(&device, id).Error; err != nil {
+		return nil, fmt.Errorf("已删除的设备不存在: %w", err)
 	}
 	
-	// 激活指定设备
-	result := s.db.Model(&models.Device{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"is_active":  true,
-		"status":     "active",
-		"updated_at": time.Now(),
+	var existingDevice models.Device
+	if err := s.db.Where("name = ?", device.Name).First(&existingDevice).Error; err == nil {
+		return nil, fmt.Errorf("%w: '%s' 已被设备 %d 使用，请先修改后再恢复", ErrDeviceNameTaken, device.Name, existingDevice.ID)
+	}
+	
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		restored := tx.Unscoped().Model(&models.Device{}).Where("id = ?", id)
+		if device.IsActive {
+			var active int64
+			if err := tx.Model(&models.Device{}).Where("station = ? AND is_active = ?", device.Station, true).Count(&active).Error; err != nil {
+				return err
+			}
+			if active > 0 {
+				if err := restored.Session(&gorm.Session{}).UpdateColumn("is_active", false).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return restored.Session(&gorm.Session{}).UpdateColumns(map[string]interface{}{
+			"deleted_at": nil,
+			"updated_at": time.Now(),
+		}).Error
 	})
+	if err != nil {
+		s.logger.WithError(err).Error("恢复设备失败")
+		return nil, fmt.Errorf("恢复设备失败: %w", err)
+	}
 	
-	if result.Error != nil {
-		s.logger.WithError(result.Error).Error("激活设备失败")
-		return fmt.Errorf("激活设备失败: %w", result.Error)
+	s.logger.WithField("device_id", id).WithField("device_name", device.Name).Info("设备已恢复")
+	s.notifyChanged()
+	return s.GetDevice(id)
+}
+
+// ActivateDevice 激活设备，只取消同一工位其他设备的激活状态
+func (s *DeviceService) ActivateDevice(id uint) error {
+	var device models.Device
+	if err := s.db.First(&device, id).Error; err != nil {
+		return fmt.Errorf("设备不存在: %w", err)
 	}
 	
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("设备不存在")
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 先将同一工位的其他设备设置为非活跃状态
+		if err := tx.Model(&models.Device{}).Where("station = ? AND is_active = ? AND id <> ?", device.Station, true, id).
+			Update("is_active", false).Error; err != nil {
+			return fmt.Errorf("取消其他设备激活状态失败: %w", err)
+		}
+		
+		// 激活指定设备
+		if err := tx.Model(&models.Device{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"is_active":  true,
+			"status":     "active",
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("激活设备失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("激活设备失败")
+		return err
 	}
 	
-	s.logger.WithField("device_id", id).Info("设备激活成功")
+	s.logger.WithField("device_id", id).WithField("station", device.Station).Info("设备激活成功")
+	s.notifyChanged()
 	return nil
 }
 
@@ -200,21 +341,142 @@ func (s *DeviceService) DeactivateDevice(id uint) error {
 	}
 	
 	s.logger.WithField("device_id", id).Info("设备停用成功")
+	s.notifyChanged()
 	return nil
 }
 
-// GetActiveDevice 获取当前活跃设备
+// GetActiveDevice 获取本工位当前活跃设备
 func (s *DeviceService) GetActiveDevice() (*models.Device, error) {
 	var device models.Device
-	if err := s.db.Where("is_active = ? AND status = ?", true, "active").First(&device).Error; err != nil {
+	if err := s.db.Where("station = ? AND is_active = ? AND status = ?", s.station, true, "active").First(&device).Error; err != nil {
 		return nil, err
 	}
 	return &device, nil
 }
 
-// UpdateDeviceLastSeen 更新设备最后活跃时间
+// AssignStation 将设备分配到指定工位；设备为活跃设备且目标工位已有活跃设备时，设备改为非活跃
+func (s *DeviceService) AssignStation(id uint, station string) (*models.Device, error) {
+	var device models.Device
+	if err := s.db.First(&device, id).Error; err != nil {
+		return nil, fmt.Errorf("设备不存在: %w", err)
+	}
+	if device.Station == station {
+		return &device, nil
+	}
+	
+	updates := map[string]interface{}{"station": station, "updated_at": time.Now()}
+	if device.IsActive {
+		var count int64
+		if err := s.db.Model(&models.Device{}).Where("station = ? AND is_active = ?", station, true).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("查询工位活跃设备失败: %w", err)
+		}
+		if count > 0 {
+			updates["is_active"] = false
+		}
+	}
+	if err := s.db.Model(&device).Updates(updates).Error; err != nil {
+		s.logger.WithError(err).Error("分配设备工位失败")
+		return nil, fmt.Errorf("分配设备工位失败: %w", err)
+	}
+	
+	s.logger.WithFields(logrus.Fields{
+		"device_id": id,
+		"from":      device.Station,
+		"to":        station,
+	}).Info("设备工位已变更")
+	s.notifyChanged()
+	return s.GetDevice(id)
+}
+
+// ClaimUnassigned 将未分配工位的设备归入本工位
+//
+// 升级前的设备没有工位信息，数据库中没有其他工位的设备或心跳时才认领，
+// 多个工位共用数据库时保持未分配，由管理员逐个分配。
+func (s *DeviceService) ClaimUnassigned() (int64, error) {
+	var others int64
+	if err := s.db.Model(&models.Device{}).Where("station <> '' AND station <> ?", s.station).Count(&others).Error; err != nil {
+		return 0, fmt.Errorf("查询其他工位的设备失败: %w", err)
+	}
+	if others == 0 {
+		if err := s.db.Model(&models.Heartbeat{}).Where("station <> '' AND station <> ?", s.station).Count(&others).Error; err != nil {
+			return 0, fmt.Errorf("查询其他工位的心跳失败: %w", err)
+		}
+	}
+	if others > 0 {
+		return 0, nil
+	}
+	
+	var claimed int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 本工位已有活跃设备时，认领的设备一律非活跃
+		var active int64
+		if err := tx.Model(&models.Device{}).Where("station = ? AND is_active = ?", s.station, true).Count(&active).Error; err != nil {
+			return err
+		}
+		unassigned := tx.Unscoped().Model(&models.Device{}).Where("station = ''")
+		if active > 0 {
+			if err := unassigned.Session(&gorm.Session{}).UpdateColumn("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		result := unassigned.Session(&gorm.Session{}).UpdateColumn("station", s.station)
+		claimed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("认领未分配工位的设备失败: %w", err)
+	}
+	if claimed > 0 {
+		s.logger.WithField("station", s.station).WithField("count", claimed).Info("已将未分配工位的设备归入本工位")
+		s.notifyChanged()
+	}
+	return claimed, nil
+}
+
+// UpdateDeviceLastSeen 更新设备最后活跃时间，同一设备 lastSeenInterval 内只写入一次
 func (s *DeviceService) UpdateDeviceLastSeen(id uint) error {
-	return s.db.Model(&models.Device{}).Where("id = ?", id).Update("last_seen_at", time.Now()).Error
+	done, err := s.TouchLastSeen(s.db, id, time.Now())
+	done(err == nil)
+	return err
+}
+
+// TouchLastSeen 在 tx 中把设备最后活跃时间更新为 at，扫码入库时与记录在同一事务中写入；
+// 事务结束后须调用返回的 done，committed 为事务是否已提交
+//
+// 距上次写入不足 lastSeenInterval 时跳过；at 早于已记录的时间（如补传的历史扫码）时不回退。
+// 只有已提交且推进了最后活跃时间的写入才计入间隔，事务回滚或补传的历史扫码不会使之后的扫码被跳过。
+// 只更新该列，不改变设备的 updated_at。
+func (s *DeviceService) TouchLastSeen(tx *gorm.DB, id uint, at time.Time) (done func(committed bool), err error) {
+	done = func(bool) {}
+	if id == 0 {
+		return done, nil
+	}
+	now := time.Now()
+	s.seenMu.Lock()
+	last, ok := s.seenAt[id]
+	s.seenMu.Unlock()
+	if ok && now.Sub(last) < lastSeenInterval {
+		return done, nil
+	}
+
+	result := tx.Model(&models.Device{}).Where("id = ? AND (last_seen IS NULL OR last_seen < ?)", id, at).
+		UpdateColumn("last_seen", at)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return done, result.Error
+	}
+	return func(committed bool) {
+		if !committed {
+			return
+		}
+		s.seenMu.Lock()
+		defer s.seenMu.Unlock()
+		if s.seenAt == nil {
+			s.seenAt = make(map[uint]time.Time)
+		}
+		if now.After(s.seenAt[id]) {
+			s.seenAt[id] = now
+		}
+	}, nil
 }
 
 // GetDeviceStats 获取设备统计信息
@@ -238,7 +500,7 @@ func (s *DeviceService) GetDeviceStats() (map[string]interface{}, error) {
 	// 在线设备数（最近5分钟有活动）
 	fiveMinutesAgo := time.Now().Add(-5 * time.Minute)
 	var onlineCount int64
-	if err := s.db.Model(&models.Device{}).Where("last_seen_at > ?", fiveMinutesAgo).Count(&onlineCount).Error; err != nil {
+	if err := s.db.Model(&models.Device{}).Where("last_seen > ?", fiveMinutesAgo).Count(&onlineCount).Error; err != nil {
 		return nil, err
 	}
 	stats["online_count"] = onlineCount
@@ -285,7 +547,7 @@ func (s *DeviceService) SearchDevices(keyword string, page, pageSize int) ([]*mo
 	
 	// 分页查询
 	offset := (page - 1) * pageSize
-	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&devices).Error; err != nil {
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&devices).Error; err != nil {
 		return nil, 0, err
 	}
 	
@@ -297,7 +559,7 @@ func (s *DeviceService) CleanupInactiveDevices(days int) (int64, error) {
 	cutoffDate := time.Now().AddDate(0, 0, -days)
 	
 	// 只清理非活跃状态且长时间未见的设备
-	result := s.db.Where("is_active = ? AND status = ? AND (last_seen_at < ? OR last_seen_at IS NULL)", 
+	result := s.db.Where("is_active = ? AND status = ? AND (last_seen < ? OR last_seen IS NULL)", 
 		false, "inactive", cutoffDate).Delete(&models.Device{})
 	
 	if result.Error != nil {
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// newTestDevices 创建设备服务及一台尚无活跃时间的设备
+func newTestDevices(t *testing.T) (*DeviceService, *database.DB, uint) {
+	t.Helper()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "devices.db"))
+	t.Cleanup(func() { db.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	device := &models.Device{Name: "1号线扫码枪", SerialNo: "SN-LINE-1", Station: "A1"}
+	if err := db.Create(device).Error; err != nil {
+		t.Fatal(err)
+	}
+	return NewDeviceService(db.DB, "A1", logger), db, device.ID
+}
+
+// scanIn 模拟扫码入库：在事务中写入最后活跃时间，fail 时事务回滚
+func scanIn(t *testing.T, devices *DeviceService, db *database.DB, id uint, at time.Time, fail bool) {
+	t.Helper()
+	var done func(bool)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if done, err = devices.TouchLastSeen(tx, id, at); err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("写入出站事件失败")
+		}
+		return nil
+	})
+	if err != nil && !fail {
+		t.Fatal(err)
+	}
+	done(err == nil)
+}
+
+// onlineCount GetDeviceStats 中最近5分钟有活动的设备数
+func onlineCount(t *testing.T, devices *DeviceService) int64 {
+	t.Helper()
+	stats, err := devices.GetDeviceStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats["online_count"].(int64)
+}
+
+func lastSeen(t *testing.T, db *database.DB, id uint) *time.Time {
+	t.Helper()
+	var device models.Device
+	if err := db.First(&device, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return device.LastSeen
+}
+
+func TestDeviceOnlineCountFollowsScans(t *testing.T) {
+	devices, db, id := newTestDevices(t)
+	if n := onlineCount(t, devices); n != 0 {
+		t.Fatalf("online_count before any scan = %d", n)
+	}
+
+	scanIn(t, devices, db, id, time.Now(), false)
+	if n := onlineCount(t, devices); n != 1 {
+		t.Fatalf("online_count after a scan = %d, want 1", n)
+	}
+
+	// 间隔内的连续扫码不再写入
+	first := lastSeen(t, db, id)
+	scanIn(t, devices, db, id, time.Now().Add(time.Second), false)
+	if got := lastSeen(t, db, id); !got.Equal(*first) {
+		t.Errorf("burst scan rewrote last_seen: %v -> %v", first, got)
+	}
+
+	// 超过5分钟没有扫码
+	stale := time.Now().Add(-10 * time.Minute)
+	if err := db.Model(&models.Device{}).Where("id = ?", id).UpdateColumn("last_seen", stale).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n := onlineCount(t, devices); n != 0 {
+		t.Errorf("online_count with a stale device = %d, want 0", n)
+	}
+}
+
+// TestTouchLastSeenRolledBack 事务回滚时不计入写入间隔，随后的扫码立即写入
+func TestTouchLastSeenRolledBack(t *testing.T) {
+	devices, db, id := newTestDevices(t)
+
+	scanIn(t, devices, db, id, time.Now(), true)
+	if got := lastSeen(t, db, id); got != nil {
+		t.Fatalf("rolled back scan left last_seen = %v", got)
+	}
+	if n := onlineCount(t, devices); n != 0 {
+		t.Fatalf("online_count after a rolled back scan = %d", n)
+	}
+
+	scanIn(t, devices, db, id, time.Now(), false)
+	if n := onlineCount(t, devices); n != 1 {
+		t.Fatalf("scan after a rollback was skipped: online_count = %d", n)
+	}
+}
+
+// TestTouchLastSeenHistoricalScan 补传的历史扫码不回退最后活跃时间，也不使之后的实时扫码被跳过
+func TestTouchLastSeenHistoricalScan(t *testing.T) {
+	devices, db, id := newTestDevices(t)
+	stale := time.Now().Add(-10 * time.Minute)
+	if err := db.Model(&models.Device{}).Where("id = ?", id).UpdateColumn("last_seen", stale).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	scanIn(t, devices, db, id, stale.Add(-time.Hour), false)
+	if got := lastSeen(t, db, id); !got.Equal(stale) {
+		t.Fatalf("historical scan moved last_seen to %v", got)
+	}
+
+	scanIn(t, devices, db, id, time.Now(), false)
+	if n := onlineCount(t, devices); n != 1 {
+		t.Fatalf("live scan after a historical one was skipped: online_count = %d", n)
+	}
+}
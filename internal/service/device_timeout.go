@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"userclient/internal/models"
+)
+
+// ErrInvalidDeviceTimeout 设备的按键超时超出允许范围
+var ErrInvalidDeviceTimeout = errors.New("按键超时须在10~10000毫秒之间，0表示使用 scanner.timeout_ms")
+
+// SetDeviceTimeout 设置设备手动指定的按键超时（0表示取消）及是否自动调整，省略的项保持不变
+func (s *DeviceService) SetDeviceTimeout(id uint, timeoutMS *int, adaptive *bool) (*models.Device, error) {
+	if timeoutMS != nil && *timeoutMS != 0 && (*timeoutMS < 10 || *timeoutMS > 10000) {
+		return nil, ErrInvalidDeviceTimeout
+	}
+	device, err := s.GetDevice(id)
+	if err != nil {
+		return nil, fmt.Errorf("设备不存在: %w", err)
+	}
+
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	if timeoutMS != nil {
+		updates["timeout_ms"] = *timeoutMS
+	}
+	if adaptive != nil {
+		updates["adaptive_timeout"] = *adaptive
+	}
+	if err := s.db.Model(device).Updates(updates).Error; err != nil {
+		s.logger.WithError(err).Error("更新设备按键超时失败")
+		return nil, fmt.Errorf("更新设备按键超时失败: %w", err)
+	}
+
+	s.logger.WithField("device_id", id).WithField("updates", updates).Info("设备按键超时已更新")
+	s.notifyChanged()
+	return s.GetDevice(id)
+}
+
+// ResetLearnedTimeout 清除设备自动调整学到的按键超时及样本数，更换扫码枪后重新学习
+func (s *DeviceService) ResetLearnedTimeout(id uint) (*models.Device, error) {
+	device, err := s.GetDevice(id)
+	if err != nil {
+		return nil, fmt.Errorf("设备不存在: %w", err)
+	}
+	updates := map[string]interface{}{"learned_timeout_ms": 0, "timeout_samples": 0, "updated_at": time.Now()}
+	if err := s.db.Model(device).Updates(updates).Error; err != nil {
+		s.logger.WithError(err).Error("清除设备学到的按键超时失败")
+		return nil, fmt.Errorf("清除设备学到的按键超时失败: %w", err)
+	}
+	s.logger.WithField("device_id", id).Info("设备学到的按键超时已清除")
+	return s.GetDevice(id)
+}
+
+// SaveLearnedTimeout 保存自动调整学到的按键超时及样本数，由按键采集线程调用，不触发设备变更通知
+func (s *DeviceService) SaveLearnedTimeout(id uint, learnedMS, samples int) error {
+	return s.db.Model(&models.Device{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"learned_timeout_ms": learnedMS, "timeout_samples": samples}).Error
+}
+
+// GetTimeoutDevices 本工位手动指定了按键超时或开启了自动调整的设备
+func (s *DeviceService) GetTimeoutDevices() ([]models.Device, error) {
+	var devices []models.Device
+	err := s.db.Select("id, timeout_ms, adaptive_timeout, learned_timeout_ms, timeout_samples").
+		Where("station = ? AND (timeout_ms > 0 OR adaptive_timeout = ?)", s.station, true).
+		Find(&devices).Error
+	return devices, err
+}
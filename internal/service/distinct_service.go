@@ -0,0 +1,320 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"userclient/internal/models"
+	"userclient/pkg/hll"
+)
+
+// distinctFlushInterval 草图保存间隔，重启最多丢失该间隔内的更新
+const distinctFlushInterval = 30 * time.Second
+
+// distinctKey 草图键，deviceID 为0表示全工位
+type distinctKey struct {
+	day      string
+	deviceID uint
+}
+
+// distinctSketch 内存中的草图
+type distinctSketch struct {
+	sketch *hll.Sketch
+	dirty  bool
+}
+
+// DistinctEstimates 某个生产日不同条码数量的估算
+type DistinctEstimates struct {
+	Day           string                   `json:"day"`
+	Timezone      string                   `json:"timezone"`
+	Total         uint64                   `json:"total"`
+	ByDevice      []DistinctDeviceEstimate `json:"by_device"`
+	Approximate   bool                     `json:"approximate"`
+	StandardError float64                  `json:"standard_error"` // 相对标准误差（1σ）
+	Note          string                   `json:"note"`
+}
+
+// DistinctDeviceEstimate 单个设备的不同条码数量估算
+type DistinctDeviceEstimate struct {
+	DeviceID uint   `json:"device_id"`
+	Estimate uint64 `json:"estimate"`
+}
+
+// DistinctRecount 精确重算结果
+type DistinctRecount struct {
+	Day          string  `json:"day"`
+	DeviceID     *uint   `json:"device_id,omitempty"`
+	Exact        int64   `json:"exact"`
+	Estimate     uint64  `json:"estimate"`
+	ErrorPercent float64 `json:"error_percent"` // (估算-精确)/精确
+	DurationMS   int64   `json:"duration_ms"`
+}
+
+// DistinctService 每日不同条码数量的近似统计
+//
+// COUNT(DISTINCT content) 在大量记录上太慢，无法随看板刷新执行。扫码入库时更新当天
+// 全工位及各设备的 HyperLogLog 草图，内存中只保留当天的草图，定时保存到数据库，重启后
+// 从数据库加载继续累加。生产日按工位时区（app.timezone）的自然日划分。
+type DistinctService struct {
+	db        *gorm.DB
+	precision uint8
+	location  *time.Location
+	logger    *logrus.Logger
+
+	mu       sync.Mutex
+	sketches map[distinctKey]*distinctSketch
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDistinctService 创建不同条码数量统计服务，loc 为划分自然日的工位时区
+func NewDistinctService(db *gorm.DB, precision int, loc *time.Location, logger *logrus.Logger) (*DistinctService, error) {
+	if precision < hll.MinPrecision || precision > hll.MaxPrecision {
+		return nil, fmt.Errorf("不同条码数估算精度须在 %d 到 %d 之间", hll.MinPrecision, hll.MaxPrecision)
+	}
+	return &DistinctService{
+		db:        db,
+		precision: uint8(precision),
+		location:  loc,
+		logger:    logger,
+		sketches:  make(map[distinctKey]*distinctSketch),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start 启动定时保存
+func (s *DistinctService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(distinctFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.flush()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时保存并保存未保存的草图
+func (s *DistinctService) Close() {
+	close(s.done)
+	s.wg.Wait()
+	s.flush()
+}
+
+// Observe 扫码入库后更新扫码时间所属自然日的草图
+func (s *DistinctService) Observe(record *models.BarcodeRecord) {
+	if record.Content == "" {
+		return
+	}
+	at := record.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	day := at.In(s.location).Format(dayLayout)
+
+	keys := []distinctKey{{day: day}}
+	if record.DeviceID != nil && *record.DeviceID != 0 {
+		keys = append(keys, distinctKey{day: day, deviceID: *record.DeviceID})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		sketch, err := s.sketch(key)
+		if err != nil {
+			s.logger.WithError(err).WithField("day", day).Warn("加载不同条码数草图失败")
+			continue
+		}
+		if sketch.sketch.AddString(record.Content) {
+			sketch.dirty = true
+		}
+	}
+}
+
+// sketch 获取内存中的草图，不在内存中时从数据库加载或新建，调用方须持有锁
+func (s *DistinctService) sketch(key distinctKey) (*distinctSketch, error) {
+	if sketch, ok := s.sketches[key]; ok {
+		return sketch, nil
+	}
+
+	sketch, _ := hll.New(s.precision)
+	var rows []models.DistinctSketch
+	if err := s.db.Where("day = ? AND device_id = ?", key.day, key.deviceID).Limit(1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		var stored hll.Sketch
+		if err := stored.UnmarshalBinary(rows[0].Registers); err != nil {
+			return nil, fmt.Errorf("解析草图失败: %w", err)
+		}
+		// 精度修改后旧草图无法合并，当天重新计数
+		if err := sketch.Merge(&stored); err != nil {
+			s.logger.WithError(err).WithField("day", key.day).Warn("草图精度已变更，当天不同条码数重新计数")
+		}
+	}
+
+	entry := &distinctSketch{sketch: sketch}
+	s.sketches[key] = entry
+	return entry, nil
+}
+
+// flush 保存有变化的草图，并从内存中移除非当天的草图
+func (s *DistinctService) flush() {
+	today := time.Now().In(s.location).Format(dayLayout)
+
+	s.mu.Lock()
+	var rows []models.DistinctSketch
+	for key, entry := range s.sketches {
+		if entry.dirty {
+			registers, _ := entry.sketch.MarshalBinary()
+			rows = append(rows, models.DistinctSketch{
+				Day:       key.day,
+				DeviceID:  key.deviceID,
+				Registers: registers,
+				Estimate:  int64(entry.sketch.Estimate()),
+			})
+			entry.dirty = false
+		}
+		if key.day != today {
+			delete(s.sketches, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(rows) == 0 {
+		return
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "day"}, {Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"registers", "estimate", "updated_at"}),
+	}).Create(&rows).Error
+	if err != nil {
+		s.logger.WithError(err).Warn("保存不同条码数草图失败")
+		// 保留在内存中，下次重试
+		s.mu.Lock()
+		for _, row := range rows {
+			key := distinctKey{day: row.Day, deviceID: row.DeviceID}
+			if entry, ok := s.sketches[key]; ok {
+				entry.dirty = true
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Reset 清空内存中的草图，数据重置后调用
+func (s *DistinctService) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sketches = make(map[distinctKey]*distinctSketch)
+}
+
+// Estimates 生产日的不同条码数量估算，day为空表示今天
+func (s *DistinctService) Estimates(day string) (*DistinctEstimates, error) {
+	if day == "" {
+		day = time.Now().In(s.location).Format(dayLayout)
+	}
+
+	// 数据库中保存的估算值，内存中的草图更新
+	var rows []models.DistinctSketch
+	if err := s.db.Select("device_id", "estimate").Where("day = ?", day).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询不同条码数草图失败: %w", err)
+	}
+	estimates := make(map[uint]uint64, len(rows))
+	for _, row := range rows {
+		estimates[row.DeviceID] = uint64(row.Estimate)
+	}
+	s.mu.Lock()
+	for key, entry := range s.sketches {
+		if key.day == day {
+			estimates[key.deviceID] = entry.sketch.Estimate()
+		}
+	}
+	s.mu.Unlock()
+
+	standardError := hll.StandardError(s.precision)
+	result := &DistinctEstimates{
+		Day:           day,
+		Timezone:      s.location.String(),
+		Total:         estimates[0],
+		ByDevice:      make([]DistinctDeviceEstimate, 0, len(estimates)),
+		Approximate:   true,
+		StandardError: standardError,
+		Note: fmt.Sprintf("HyperLogLog 估算值，约68%%的情况下相对误差在 ±%.2f%% 以内，按 %s 时区的自然日统计；精确值可通过 /api/admin/stats/distinct/recount 重算",
+			standardError*100, s.location.String()),
+	}
+	for deviceID, estimate := range estimates {
+		if deviceID != 0 {
+			result.ByDevice = append(result.ByDevice, DistinctDeviceEstimate{DeviceID: deviceID, Estimate: estimate})
+		}
+	}
+	sort.Slice(result.ByDevice, func(i, j int) bool {
+		return result.ByDevice[i].DeviceID < result.ByDevice[j].DeviceID
+	})
+	return result, nil
+}
+
+// Recount 用 COUNT(DISTINCT) 精确统计生产日的不同条码数量并与估算值比较，用于核对
+//
+// 查询需扫描当天全部记录，仅供管理员按需调用。
+func (s *DistinctService) Recount(day string, deviceID *uint) (*DistinctRecount, error) {
+	if day == "" {
+		day = time.Now().In(s.location).Format(dayLayout)
+	}
+	date, err := time.ParseInLocation(dayLayout, day, s.location)
+	if err != nil {
+		return nil, fmt.Errorf("日期无效: %s", day)
+	}
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, s.location)
+	end := time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, s.location)
+
+	began := time.Now()
+	query := s.db.Model(&models.BarcodeRecord{}).
+		Where("created_at >= ? AND created_at < ? AND content <> ''", start, end)
+	if deviceID != nil {
+		query = query.Where("device_id = ?", *deviceID)
+	}
+	var exact int64
+	if err := query.Select("COUNT(DISTINCT content)").Scan(&exact).Error; err != nil {
+		return nil, fmt.Errorf("统计不同条码数失败: %w", err)
+	}
+	result := &DistinctRecount{
+		Day:        day,
+		DeviceID:   deviceID,
+		Exact:      exact,
+		DurationMS: time.Since(began).Milliseconds(),
+	}
+
+	estimates, err := s.Estimates(day)
+	if err != nil {
+		return nil, err
+	}
+	result.Estimate = estimates.Total
+	if deviceID != nil {
+		result.Estimate = 0
+		for _, device := range estimates.ByDevice {
+			if device.DeviceID == *deviceID {
+				result.Estimate = device.Estimate
+			}
+		}
+	}
+	if exact > 0 {
+		result.ErrorPercent = (float64(result.Estimate) - float64(exact)) * 100 / float64(exact)
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/database"
+	"userclient/internal/models"
+	"userclient/pkg/hll"
+)
+
+// distinctDay 测试数据所在的生产日，固定日期避免测试跨越零点
+const distinctDay = "2026-10-15"
+
+func newTestDistinct(t *testing.T, db *database.DB, precision int) *DistinctService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s, err := NewDistinctService(db.DB, precision, time.UTC, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// checkErrorBound 估算值的相对误差不超过精度对应标准误差的3倍
+//
+// 条码的哈希是确定的，同一组条码每次估算结果相同，3σ 的界限不会随机失败。
+func checkErrorBound(t *testing.T, precision int, estimate uint64, exact int) {
+	t.Helper()
+	bound := 3 * hll.StandardError(uint8(precision))
+	if relative := math.Abs(float64(estimate)-float64(exact)) / float64(exact); relative > bound {
+		t.Errorf("precision %d: estimate %d for %d distinct barcodes, relative error %.4f exceeds %.4f", precision, estimate, exact, relative, bound)
+	}
+}
+
+// TestDistinctEstimateWithinErrorBound 不同精度与数量下估算误差在标准误差范围内，重复扫码不影响估算
+func TestDistinctEstimateWithinErrorBound(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "distinct.db"))
+	defer db.Close()
+	at := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+
+	for _, precision := range []int{10, 14, 16} {
+		for _, distinct := range []int{100, 5000, 50000} {
+			t.Run(fmt.Sprintf("p%d/n%d", precision, distinct), func(t *testing.T) {
+				s := newTestDistinct(t, db, precision)
+				// 每个条码扫两次
+				for repeat := 0; repeat < 2; repeat++ {
+					for i := 0; i < distinct; i++ {
+						s.Observe(&models.BarcodeRecord{Content: fmt.Sprintf("P%d-SN%08d", precision, i), CreatedAt: at})
+					}
+				}
+				estimates, err := s.Estimates(distinctDay)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if estimates.StandardError != hll.StandardError(uint8(precision)) {
+					t.Errorf("standard error = %v, want %v", estimates.StandardError, hll.StandardError(uint8(precision)))
+				}
+				checkErrorBound(t, precision, estimates.Total, distinct)
+			})
+		}
+	}
+}
+
+// TestDistinctRecount 精确重算与估算一致，按设备的草图同样在误差范围内，重启后从数据库继续累加
+func TestDistinctRecount(t *testing.T) {
+	const precision = 14 // api.distinct_precision 默认值
+	db := openTestDB(t, filepath.Join(t.TempDir(), "distinct.db"))
+	defer db.Close()
+
+	var devices []uint
+	for _, serial := range []string{"SN-DISTINCT-A", "SN-DISTINCT-B"} {
+		device := &models.Device{Name: serial, SerialNo: serial, Station: serial}
+		if err := db.Create(device).Error; err != nil {
+			t.Fatal(err)
+		}
+		devices = append(devices, device.ID)
+	}
+
+	// 设备A扫 0-1999，设备B扫 1000-2999，重叠部分计入全工位一次
+	at := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	var records []models.BarcodeRecord
+	for d, deviceID := range devices {
+		deviceID := deviceID
+		for i := d * 1000; i < d*1000+2000; i++ {
+			content := fmt.Sprintf("SN%08d", i)
+			records = append(records, models.BarcodeRecord{
+				Content: content, Length: len(content), DeviceID: &deviceID,
+				CreatedAt: at.Add(time.Duration(len(records)) * time.Second),
+			})
+		}
+	}
+	// 只需条码内容与扫码时间，跳过生成公开ID等钩子加快写入
+	if err := db.Session(&gorm.Session{SkipHooks: true}).CreateInBatches(records, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestDistinct(t, db, precision)
+	half := len(records) / 2
+	for i := range records[:half] {
+		s.Observe(&records[i])
+	}
+	// 重启：保存草图后由新实例从数据库加载继续累加
+	s.Start()
+	s.Close()
+	s = newTestDistinct(t, db, precision)
+	for i := range records[half:] {
+		s.Observe(&records[half+i])
+	}
+
+	total, err := s.Recount(distinctDay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total.Exact != 3000 {
+		t.Fatalf("exact distinct = %d, want 3000", total.Exact)
+	}
+	checkErrorBound(t, precision, total.Estimate, int(total.Exact))
+	if want := (float64(total.Estimate) - 3000) * 100 / 3000; total.ErrorPercent != want {
+		t.Errorf("error percent = %v, want %v", total.ErrorPercent, want)
+	}
+
+	for _, deviceID := range devices {
+		recount, err := s.Recount(distinctDay, &deviceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recount.Exact != 2000 {
+			t.Fatalf("device %d exact distinct = %d, want 2000", deviceID, recount.Exact)
+		}
+		checkErrorBound(t, precision, recount.Estimate, int(recount.Exact))
+	}
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/models"
+)
+
+// FlagConfigPrefix 功能开关在系统配置表中的键前缀
+const FlagConfigPrefix = "flags."
+
+// ErrUnknownFlag 功能开关未登记
+var ErrUnknownFlag = errors.New("功能开关不存在")
+
+// 功能开关取值来源
+const (
+	FlagSourceDefault  = "default"  // 代码中登记的默认值
+	FlagSourceConfig   = "config"   // 配置文件中已弃用的旧配置项
+	FlagSourceDatabase = "database" // 通过接口或系统配置修改的值
+)
+
+// FeatureFlag 功能开关，在代码中登记，取值保存在系统配置表中（键为 flags.<名称>）
+//
+// 改变行为的新功能通过功能开关逐步启用，使用方通过 FeatureFlags.Enabled 读取，不再各自新增配置项。
+type FeatureFlag struct {
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	Default         bool   `json:"default"`
+	RequiresRestart bool   `json:"requires_restart"`     // 修改后需重启才能生效，运行期间保持启动时的值
+	Deprecates      string `json:"deprecates,omitempty"` // 被本开关取代的配置文件项
+}
+
+// featureFlags 已登记的功能开关，按登记顺序
+var featureFlags []*FeatureFlag
+
+// registerFlag 登记功能开关，同时登记为按布尔值校验的系统配置
+func registerFlag(flag FeatureFlag) *FeatureFlag {
+	f := &flag
+	featureFlags = append(featureFlags, f)
+	systemConfigKeys[FlagConfigPrefix+f.Name] = systemConfigKey{validate: boolValue, restart: f.RequiresRestart}
+	return f
+}
+
+// 已登记的功能开关
+var (
+	// FlagHubCompaction 连续相同扫码合并为计数消息，取代 websocket.compaction.enable
+	FlagHubCompaction = registerFlag(FeatureFlag{
+		Name:        "hub.compaction",
+		Description: "以 compact=1 连接的客户端收到连续相同的扫码时只收到计数消息",
+		Deprecates:  "websocket.compaction.enable",
+	})
+	// FlagDeviceOrdering 同一设备的扫码按到达顺序入库及推送
+	FlagDeviceOrdering = registerFlag(FeatureFlag{
+		Name:        "pipeline.device_ordering",
+		Description: "同一设备的扫码按到达顺序串行入库及推送，关闭后同一设备的扫码可并发处理",
+		Default:     true,
+	})
+	// FlagKeyTiming 键盘钩子按键时序诊断
+	FlagKeyTiming = registerFlag(FeatureFlag{
+		Name:            "pipeline.key_timing",
+		Description:     "按键时序异常的扫码标记为疑似截断，疑似截断比例过高时告警",
+		Default:         true,
+		RequiresRestart: true,
+	})
+)
+
+// FlagState 功能开关的当前状态
+type FlagState struct {
+	FeatureFlag
+	Enabled        bool   `json:"enabled"`         // 当前生效的值
+	Configured     *bool  `json:"configured"`      // 数据库中设置的值，未设置时为nil
+	Source         string `json:"source"`          // 生效值的来源：default、config、database
+	PendingRestart bool   `json:"pending_restart"` // 已修改，重启后生效
+}
+
+// FeatureFlags 功能开关的取值
+//
+// 取值优先级：系统配置表中的值 > 配置文件中已弃用的旧配置项 > 登记的默认值。
+// 需重启生效的开关在运行期间保持启动时的值，修改后在状态中标记待重启。
+// 设置及重置经配置服务写入，与通过 /api/configs 修改一样检查权限、写入审计日志并使配置缓存失效。
+type FeatureFlags struct {
+	configs *ConfigService
+	logger  *logrus.Logger
+
+	mu         sync.RWMutex
+	configured map[string]bool // 系统配置表中的值
+	legacy     map[string]bool // 已弃用的旧配置项的值
+	startup    map[string]bool // 需重启生效的开关在启动时的值
+}
+
+// NewFeatureFlags 创建功能开关，从系统配置表加载已设置的值，之后随配置服务的变更通知更新
+func NewFeatureFlags(configs *ConfigService, logger *logrus.Logger) *FeatureFlags {
+	f := &FeatureFlags{
+		configs:    configs,
+		logger:     logger,
+		configured: make(map[string]bool),
+		legacy:     make(map[string]bool),
+		startup:    make(map[string]bool),
+	}
+	if err := f.load(); err != nil {
+		logger.WithError(err).Warn("加载功能开关失败，使用默认值")
+	}
+	configs.OnChange(f.Apply)
+	return f
+}
+
+// Flags 已登记的功能开关
+func Flags() []*FeatureFlag {
+	return append([]*FeatureFlag(nil), featureFlags...)
+}
+
+// LookupFlag 按名称查找功能开关
+func LookupFlag(name string) (*FeatureFlag, bool) {
+	for _, flag := range featureFlags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return nil, false
+}
+
+// load 加载系统配置表中的值，并记录需重启生效的开关的启动值
+func (f *FeatureFlags) load() error {
+	var configs []models.Configuration
+	if err := f.configs.db.Where("key LIKE ?", FlagConfigPrefix+"%").Find(&configs).Error; err != nil {
+		return fmt.Errorf("查询功能开关失败: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, config := range configs {
+		name := strings.TrimPrefix(config.Key, FlagConfigPrefix)
+		if _, ok := LookupFlag(name); !ok {
+			f.logger.WithField("key", config.Key).Warn("系统配置中的功能开关未登记，已忽略")
+			continue
+		}
+		enabled, err := strconv.ParseBool(config.Value)
+		if err != nil {
+			f.logger.WithField("key", config.Key).WithField("value", config.Value).Warn("功能开关的值无效，使用默认值")
+			continue
+		}
+		f.configured[name] = enabled
+	}
+	for _, flag := range featureFlags {
+		if flag.RequiresRestart {
+			f.startup[flag.Name] = f.currentLocked(flag)
+		}
+	}
+	return nil
+}
+
+// Deprecated 配置文件中已弃用的旧配置项，值与开关默认值不同且数据库中未设置时以旧配置项为准，并提示改用功能开关
+func (f *FeatureFlags) Deprecated(flag *FeatureFlag, value bool) {
+	if value == flag.Default {
+		return
+	}
+	f.logger.WithFields(logrus.Fields{"key": flag.Deprecates, "flag": flag.Name}).
+		Warn("配置项已弃用，请改用功能开关（PUT /api/admin/flags/" + flag.Name + "）")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.legacy[flag.Name] = value
+	if flag.RequiresRestart {
+		f.startup[flag.Name] = f.currentLocked(flag)
+	}
+}
+
+// Enabled 功能开关是否启用
+func (f *FeatureFlags) Enabled(flag *FeatureFlag) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if enabled, ok := f.startup[flag.Name]; ok {
+		return enabled
+	}
+	return f.currentLocked(flag)
+}
+
+// currentLocked 按优先级计算的最新值，需持有锁
+func (f *FeatureFlags) currentLocked(flag *FeatureFlag) bool {
+	value, _ := f.valueLocked(flag)
+	return value
+}
+
+// valueLocked 按优先级计算的最新值及来源，需持有锁
+func (f *FeatureFlags) valueLocked(flag *FeatureFlag) (bool, string) {
+	if enabled, ok := f.configured[flag.Name]; ok {
+		return enabled, FlagSourceDatabase
+	}
+	if enabled, ok := f.legacy[flag.Name]; ok {
+		return enabled, FlagSourceConfig
+	}
+	return flag.Default, FlagSourceDefault
+}
+
+// States 全部功能开关的当前状态
+func (f *FeatureFlags) States() []FlagState {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	states := make([]FlagState, 0, len(featureFlags))
+	for _, flag := range featureFlags {
+		states = append(states, f.stateLocked(flag))
+	}
+	return states
+}
+
+// NonDefault 当前值与默认值不同或修改后待重启生效的功能开关，按名称排序
+func (f *FeatureFlags) NonDefault() []FlagState {
+	var states []FlagState
+	for _, state := range f.States() {
+		if state.Enabled != state.Default || state.PendingRestart {
+			states = append(states, state)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// stateLocked 功能开关的状态，需持有锁
+func (f *FeatureFlags) stateLocked(flag *FeatureFlag) FlagState {
+	latest, source := f.valueLocked(flag)
+	state := FlagState{FeatureFlag: *flag, Enabled: latest, Source: source}
+	if enabled, ok := f.configured[flag.Name]; ok {
+		state.Configured = &enabled
+	}
+	if enabled, ok := f.startup[flag.Name]; ok {
+		state.Enabled = enabled
+		state.PendingRestart = enabled != latest
+	}
+	return state
+}
+
+// Set 以指定操作者设置功能开关并写入系统配置表，运行时可切换的开关立即生效
+func (f *FeatureFlags) Set(actor ConfigActor, name string, enabled bool) (*FlagState, error) {
+	flag, ok := LookupFlag(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+
+	_, err := f.configs.SetSystemConfigurationAs(actor, models.Configuration{
+		Key:         FlagConfigPrefix + name,
+		Value:       strconv.FormatBool(enabled),
+		Description: flag.Description,
+		Type:        "bool",
+		Category:    "flags",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	state := f.state(flag)
+	f.logger.WithFields(logrus.Fields{"flag": name, "enabled": enabled, "pending_restart": state.PendingRestart}).Info("功能开关已修改")
+	return &state, nil
+}
+
+// Reset 以指定操作者删除系统配置表中的值，恢复为配置文件或默认值
+func (f *FeatureFlags) Reset(actor ConfigActor, name string) (*FlagState, error) {
+	flag, ok := LookupFlag(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	if _, err := f.configs.ResetSystemConfigurationAs(actor, FlagConfigPrefix+name); err != nil {
+		return nil, err
+	}
+
+	state := f.state(flag)
+	f.logger.WithFields(logrus.Fields{"flag": name, "enabled": state.Enabled, "pending_restart": state.PendingRestart}).Info("功能开关已重置")
+	return &state, nil
+}
+
+// Apply 系统配置中的功能开关被修改或重置（包括通过 /api/configs 修改）时更新取值，非功能开关的键忽略
+func (f *FeatureFlags) Apply(change ConfigChange) {
+	name := strings.TrimPrefix(change.Key, FlagConfigPrefix)
+	if name == change.Key {
+		return
+	}
+	if _, ok := LookupFlag(name); !ok {
+		return
+	}
+	if change.Reset {
+		f.mu.Lock()
+		delete(f.configured, name)
+		f.mu.Unlock()
+		return
+	}
+	enabled, err := strconv.ParseBool(change.Value)
+	if err != nil {
+		return
+	}
+	f.mu.Lock()
+	f.configured[name] = enabled
+	f.mu.Unlock()
+}
+
+// state 功能开关的状态
+func (f *FeatureFlags) state(flag *FeatureFlag) FlagState {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.stateLocked(flag)
+}
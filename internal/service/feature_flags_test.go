@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// newTestFlags 创建使用临时数据库的配置服务及功能开关，changes 收到配置服务的变更通知
+func newTestFlags(t *testing.T) (*FeatureFlags, *ConfigService, *database.DB, *[]ConfigChange) {
+	t.Helper()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "flags.db"))
+	t.Cleanup(func() { db.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	configs := NewConfigService(db.DB, logger)
+	var changes []ConfigChange
+	configs.OnChange(func(change ConfigChange) { changes = append(changes, change) })
+	return NewFeatureFlags(configs, logger), configs, db, &changes
+}
+
+// auditActions 审计日志中配置模块的操作
+func auditActions(t *testing.T, db *database.DB) []string {
+	t.Helper()
+	var actions []string
+	if err := db.Model(&models.SystemLog{}).Where("module = ?", "config").Order("id").Pluck("action", &actions).Error; err != nil {
+		t.Fatal(err)
+	}
+	return actions
+}
+
+// TestFlagSetThroughConfigService 设置功能开关经配置服务写入系统配置：通知变更、配置缓存失效，非管理员被拒绝并审计
+func TestFlagSetThroughConfigService(t *testing.T) {
+	flags, configs, db, changes := newTestFlags(t)
+	cache := NewConfigCache(configs, time.Hour)
+	key := FlagConfigPrefix + FlagDeviceOrdering.Name
+	if cache.Bool(key, true) != true {
+		t.Fatal("未设置时缓存应返回默认值")
+	}
+
+	if _, err := flags.Set(ConfigActor{Name: "operator"}, FlagDeviceOrdering.Name, false); !errors.Is(err, ErrConfigProtected) {
+		t.Fatalf("非管理员设置 err = %v, want ErrConfigProtected", err)
+	}
+	if got := auditActions(t, db); len(got) != 1 || got[0] != "config:denied:update" {
+		t.Fatalf("审计日志 = %v", got)
+	}
+	if !flags.Enabled(FlagDeviceOrdering) {
+		t.Fatal("被拒绝的设置已生效")
+	}
+
+	state, err := flags.Set(ConfigActor{Admin: true, Name: "admin"}, FlagDeviceOrdering.Name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Enabled || flags.Enabled(FlagDeviceOrdering) {
+		t.Fatal("运行时可切换的开关应立即生效")
+	}
+	config, err := configs.GetConfiguration(key)
+	if err != nil || !config.IsSystem || config.Value != "false" || config.Type != "bool" {
+		t.Fatalf("系统配置 = %+v, %v", config, err)
+	}
+	if cache.Bool(key, true) != false {
+		t.Fatal("设置后配置缓存未失效")
+	}
+	if len(*changes) != 1 || (*changes)[0].Key != key || (*changes)[0].Value != "false" {
+		t.Fatalf("变更通知 = %+v", *changes)
+	}
+
+	// 通过 /api/configs 修改同一键时功能开关随之更新
+	if _, err := configs.SetConfigurationAs(ConfigActor{Admin: true}, key, "true", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if !flags.Enabled(FlagDeviceOrdering) {
+		t.Fatal("通过配置接口修改后开关未更新")
+	}
+}
+
+// TestFlagReset 重置经配置服务单独的重置路径：需要管理员、写入审计日志，之后可再次设置；系统配置仍不能直接删除
+func TestFlagReset(t *testing.T) {
+	flags, configs, db, changes := newTestFlags(t)
+	cache := NewConfigCache(configs, time.Hour)
+	key := FlagConfigPrefix + FlagHubCompaction.Name
+	admin := ConfigActor{Admin: true, Name: "admin"}
+	if _, err := flags.Set(admin, FlagHubCompaction.Name, true); err != nil {
+		t.Fatal(err)
+	}
+	config, err := configs.GetConfiguration(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := configs.DeleteConfigurationAs(admin, config.ID); !errors.Is(err, ErrConfigProtected) {
+		t.Fatalf("删除功能开关的系统配置 err = %v, want ErrConfigProtected", err)
+	}
+	if cache.Bool(key, false) != true {
+		t.Fatal("缓存应为已设置的值")
+	}
+
+	if _, err := flags.Reset(ConfigActor{Name: "operator"}, FlagHubCompaction.Name); !errors.Is(err, ErrConfigProtected) {
+		t.Fatalf("非管理员重置 err = %v, want ErrConfigProtected", err)
+	}
+	state, err := flags.Reset(admin, FlagHubCompaction.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Enabled || state.Source != FlagSourceDefault || state.Configured != nil {
+		t.Fatalf("重置后状态 = %+v", state)
+	}
+	if _, err := configs.GetConfiguration(key); err == nil {
+		t.Fatal("重置后系统配置仍存在")
+	}
+	if cache.Bool(key, false) != false {
+		t.Fatal("重置后配置缓存未失效")
+	}
+	last := (*changes)[len(*changes)-1]
+	if !last.Reset || last.Key != key || last.OldValue != "true" {
+		t.Fatalf("重置通知 = %+v", last)
+	}
+	want := []string{"config:denied:delete", "config:denied:reset", "config:reset"}
+	if got := auditActions(t, db); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("审计日志 = %v, want %v", got, want)
+	}
+
+	// 未设置时重置不报错；重置后再次设置恢复已删除的记录
+	if _, err := flags.Reset(admin, FlagHubCompaction.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := flags.Set(admin, FlagHubCompaction.Name, true); err != nil {
+		t.Fatal(err)
+	}
+	if !flags.Enabled(FlagHubCompaction) || cache.Bool(key, false) != true {
+		t.Fatal("重置后再次设置未生效")
+	}
+}
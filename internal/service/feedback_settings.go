@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"userclient/internal/feedback"
+)
+
+// 扫码提示音的系统配置项，修改后热更新
+const (
+	FeedbackEnabledKey     = "feedback.enabled"
+	FeedbackSuccessFreqKey = "feedback.success_freq"
+	FeedbackErrorFreqKey   = "feedback.error_freq"
+	FeedbackDurationKey    = "feedback.duration_ms"
+	FeedbackSuccessWAVKey  = "feedback.success_wav"
+	FeedbackErrorWAVKey    = "feedback.error_wav"
+)
+
+// 扫码提示音的默认设置
+const (
+	defaultFeedbackSuccessFreq = 2000
+	defaultFeedbackErrorFreq   = 400
+	defaultFeedbackDurationMS  = 100
+)
+
+// IsFeedbackKey 是否为扫码提示音的配置项
+func IsFeedbackKey(key string) bool {
+	return strings.HasPrefix(key, "feedback.")
+}
+
+// FeedbackSettings 读取扫码提示音设置，缺少或无效的配置项使用默认值
+func (s *ConfigService) FeedbackSettings() feedback.Settings {
+	value := func(key string) string {
+		config, err := s.GetConfiguration(key)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(config.Value)
+	}
+	number := func(key string, def int) int {
+		n, err := strconv.Atoi(value(key))
+		if err != nil {
+			return def
+		}
+		return n
+	}
+
+	enabled, _ := strconv.ParseBool(value(FeedbackEnabledKey))
+	return feedback.Settings{
+		Enabled:     enabled,
+		SuccessFreq: number(FeedbackSuccessFreqKey, defaultFeedbackSuccessFreq),
+		ErrorFreq:   number(FeedbackErrorFreqKey, defaultFeedbackErrorFreq),
+		Duration:    time.Duration(number(FeedbackDurationKey, defaultFeedbackDurationMS)) * time.Millisecond,
+		SuccessWAV:  value(FeedbackSuccessWAVKey),
+		ErrorWAV:    value(FeedbackErrorWAVKey),
+	}
+}
+
+// wavPath WAV文件路径校验，为空表示使用音调
+func wavPath(value string) error {
+	value = strings.TrimSpace(value)
+	if value != "" && !strings.HasSuffix(strings.ToLower(value), ".wav") {
+		return fmt.Errorf("须为 .wav 文件路径或为空")
+	}
+	return nil
+}
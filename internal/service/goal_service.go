@@ -0,0 +1,522 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+)
+
+// ErrInvalidGoal 产量目标校验失败
+var ErrInvalidGoal = errors.New("产量目标无效")
+
+// dayLayout 生产日格式
+const dayLayout = "2006-01-02"
+
+// GoalProgressEvent 目标进度达到里程碑
+type GoalProgressEvent struct {
+	GoalID    uint    `json:"goal_id"`
+	Name      string  `json:"name"`
+	Scope     string  `json:"scope"`
+	DeviceID  *uint   `json:"device_id,omitempty"`
+	Day       string  `json:"day"`
+	Count     int64   `json:"count"`
+	Target    int64   `json:"target"`
+	Percent   float64 `json:"percent"`
+	Milestone int     `json:"milestone"` // 本次达到的进度百分比
+	Completed bool    `json:"completed"`
+}
+
+// GoalAttainment 目标某个生产日的达成情况
+type GoalAttainment struct {
+	GoalID      uint       `json:"goal_id"`
+	Name        string     `json:"name"`
+	Scope       string     `json:"scope"`
+	Day         string     `json:"day"`
+	Target      int64      `json:"target"`
+	Count       int64      `json:"count"`
+	Percent     float64    `json:"percent"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// GoalService 产量目标
+//
+// 每次扫码入库后累加匹配目标在该扫码所属生产日的进度，进度跨过配置的百分比时推送
+// goal_progress，达成时告警。生产日以跨零点班次的结束时间为界，夜班零点后的扫码
+// 计入前一天；进度按扫码时间而不是入库时间归属，补录的历史扫码计入对应的生产日。
+// 生产日与班次均按工位时区（app.timezone）划分。
+type GoalService struct {
+	db         *gorm.DB
+	milestones []int
+	shifts     []shiftWindow
+	dayOffset  int // 生产日起点，当天零点后的分钟数
+	location   *time.Location
+	logger     *logrus.Logger
+
+	mu        sync.Mutex
+	goals     []*models.Goal // 已启用的目标
+	listeners []func(GoalProgressEvent)
+	alerts    []func(deviceID *uint, event AlertEvent)
+}
+
+// NewGoalService 创建产量目标服务，shifts 为生产班次配置，loc 为工位时区
+func NewGoalService(db *gorm.DB, cfg config.GoalsConfig, shifts []config.ShiftWindow, loc *time.Location, logger *logrus.Logger) (*GoalService, error) {
+	windows, err := parseShifts(shifts)
+	if err != nil {
+		return nil, err
+	}
+
+	milestones := make([]int, 0, len(cfg.Milestones)+1)
+	for _, milestone := range cfg.Milestones {
+		if milestone > 0 && milestone <= 100 {
+			milestones = append(milestones, milestone)
+		}
+	}
+	// 达成目标总是通知
+	milestones = append(milestones, 100)
+	sort.Ints(milestones)
+
+	return &GoalService{
+		db:         db,
+		milestones: milestones,
+		shifts:     windows,
+		dayOffset:  productionDayOffset(windows),
+		location:   loc,
+		logger:     logger,
+	}, nil
+}
+
+// OnProgress 注册进度里程碑回调
+func (s *GoalService) OnProgress(listener func(event GoalProgressEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// OnAlert 注册目标达成回调
+func (s *GoalService) OnAlert(listener func(deviceID *uint, event AlertEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, listener)
+}
+
+// Load 加载已启用的目标
+func (s *GoalService) Load() error {
+	var goals []*models.Goal
+	if err := s.db.Where("enabled = ?", true).Order("id").Find(&goals).Error; err != nil {
+		return fmt.Errorf("查询产量目标失败: %w", err)
+	}
+	s.mu.Lock()
+	s.goals = goals
+	s.mu.Unlock()
+	return nil
+}
+
+// GetGoals 获取全部目标
+func (s *GoalService) GetGoals() ([]*models.Goal, error) {
+	var goals []*models.Goal
+	if err := s.db.Order("id").Find(&goals).Error; err != nil {
+		return nil, fmt.Errorf("查询产量目标失败: %w", err)
+	}
+	return goals, nil
+}
+
+// GetGoal 获取目标
+func (s *GoalService) GetGoal(id uint) (*models.Goal, error) {
+	var goal models.Goal
+	if err := s.db.First(&goal, id).Error; err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// CreateGoal 校验并保存目标
+func (s *GoalService) CreateGoal(goal *models.Goal) error {
+	if err := s.validate(goal); err != nil {
+		return err
+	}
+	if err := s.db.Create(goal).Error; err != nil {
+		return fmt.Errorf("保存产量目标失败: %w", err)
+	}
+	s.logger.WithField("goal", goal.Name).Info("产量目标已创建")
+	return s.Load()
+}
+
+// UpdateGoal 校验并更新目标，已记录的进度保留
+func (s *GoalService) UpdateGoal(id uint, goal *models.Goal) (*models.Goal, error) {
+	if err := s.validate(goal); err != nil {
+		return nil, err
+	}
+
+	var existing models.Goal
+	if err := s.db.First(&existing, id).Error; err != nil {
+		return nil, err
+	}
+	goal.ID = existing.ID
+	goal.CreatedAt = existing.CreatedAt
+	if err := s.db.Save(goal).Error; err != nil {
+		return nil, fmt.Errorf("更新产量目标失败: %w", err)
+	}
+
+	s.logger.WithField("goal", goal.Name).Info("产量目标已更新")
+	return goal, s.Load()
+}
+
+// DeleteGoal 删除目标及其进度
+func (s *GoalService) DeleteGoal(id uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Goal{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("goal_id = ?", id).Delete(&models.GoalProgress{}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("删除产量目标失败: %w", err)
+	}
+	return s.Load()
+}
+
+// Observe 扫码入库后累加匹配目标的进度
+func (s *GoalService) Observe(record *models.BarcodeRecord) {
+	at := record.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	day := s.ProductionDay(at)
+	shift := s.shiftAt(at, s.location)
+
+	var events []GoalProgressEvent
+	s.mu.Lock()
+	for _, goal := range s.goals {
+		if !goalAppliesOn(goal, day) || !goalMatches(goal, record, shift) {
+			continue
+		}
+		event, err := s.advance(goal, day, at)
+		if err != nil {
+			s.logger.WithError(err).WithField("goal", goal.Name).Warn("更新目标进度失败")
+			continue
+		}
+		if event != nil {
+			events = append(events, *event)
+		}
+	}
+	listeners := make([]func(GoalProgressEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	alerts := make([]func(*uint, AlertEvent), len(s.alerts))
+	copy(alerts, s.alerts)
+	s.mu.Unlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+		if !event.Completed {
+			continue
+		}
+		alert := AlertEvent{
+			Source:  "goal",
+			Level:   "info",
+			Message: i18n.Sprintf("alert.goal_reached", event.Name, event.Day, event.Count, event.Target),
+		}
+		for _, listener := range alerts {
+			listener(event.DeviceID, alert)
+		}
+	}
+}
+
+// advance 目标进度加一，跨过里程碑时返回进度事件
+func (s *GoalService) advance(goal *models.Goal, day string, at time.Time) (*GoalProgressEvent, error) {
+	progress := models.GoalProgress{GoalID: goal.ID, Day: day}
+	if err := s.db.Where("goal_id = ? AND day = ?", goal.ID, day).FirstOrCreate(&progress).Error; err != nil {
+		return nil, err
+	}
+
+	progress.Count++
+	percent := float64(progress.Count) * 100 / float64(goal.Target)
+	reached := 0
+	for _, milestone := range s.milestones {
+		if percent >= float64(milestone) && milestone > progress.Milestone {
+			reached = milestone
+		}
+	}
+	completed := progress.CompletedAt == nil && progress.Count >= goal.Target
+	if reached > 0 {
+		progress.Milestone = reached
+	}
+	if completed {
+		progress.CompletedAt = &at
+	}
+	if err := s.db.Save(&progress).Error; err != nil {
+		return nil, err
+	}
+
+	if reached == 0 && !completed {
+		return nil, nil
+	}
+	return &GoalProgressEvent{
+		GoalID:    goal.ID,
+		Name:      goal.Name,
+		Scope:     goal.Scope,
+		DeviceID:  goal.DeviceID,
+		Day:       day,
+		Count:     progress.Count,
+		Target:    goal.Target,
+		Percent:   percent,
+		Milestone: progress.Milestone,
+		Completed: completed,
+	}, nil
+}
+
+// GetProgress 已启用目标在指定生产日的实时进度，day为空表示当前生产日
+//
+// 累加的进度按工位时区划分生产日，loc 与工位时区不同时改为按 loc 统计扫码记录。
+func (s *GoalService) GetProgress(day string, loc *time.Location) ([]GoalAttainment, error) {
+	if loc == nil {
+		loc = s.location
+	}
+	if day == "" {
+		day = s.productionDay(time.Now(), loc)
+	}
+	if loc.String() != s.location.String() {
+		return s.GetAttainment(day, day, loc)
+	}
+
+	s.mu.Lock()
+	goals := s.goals
+	s.mu.Unlock()
+
+	var rows []*models.GoalProgress
+	if err := s.db.Where("day = ?", day).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询目标进度失败: %w", err)
+	}
+	progress := make(map[uint]*models.GoalProgress, len(rows))
+	for _, row := range rows {
+		progress[row.GoalID] = row
+	}
+
+	result := make([]GoalAttainment, 0, len(goals))
+	for _, goal := range goals {
+		if !goalAppliesOn(goal, day) {
+			continue
+		}
+		var count int64
+		var completedAt *time.Time
+		if row, ok := progress[goal.ID]; ok {
+			count = row.Count
+			completedAt = row.CompletedAt
+		}
+		result = append(result, attainment(goal, day, count, completedAt))
+	}
+	return result, nil
+}
+
+// GetAttainment 按扫码记录统计各目标在日期范围内（含首尾）每个生产日的达成情况
+//
+// 直接统计扫码记录而不是累加的进度，补录或对账写入的记录同样计入。生产日与班次按 loc
+// 划分，为nil时使用工位时区。
+func (s *GoalService) GetAttainment(from, to string, loc *time.Location) ([]GoalAttainment, error) {
+	if loc == nil {
+		loc = s.location
+	}
+	start, err := time.ParseInLocation(dayLayout, from, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 开始日期无效", ErrInvalidGoal)
+	}
+	end, err := time.ParseInLocation(dayLayout, to, loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 结束日期无效", ErrInvalidGoal)
+	}
+	if end.Before(start) || end.Sub(start) > 366*24*time.Hour {
+		return nil, fmt.Errorf("%w: 日期范围无效（最多一年）", ErrInvalidGoal)
+	}
+
+	goals, err := s.GetGoals()
+	if err != nil {
+		return nil, err
+	}
+
+	// 扫码按生产日、班次汇总后逐个目标匹配；按日历日期计算边界，夏令时切换日同样准确
+	rangeStart := time.Date(start.Year(), start.Month(), start.Day(), 0, s.dayOffset, 0, 0, loc)
+	rangeEnd := time.Date(end.Year(), end.Month(), end.Day()+1, 0, s.dayOffset, 0, 0, loc)
+	var records []*models.BarcodeRecord
+	err = s.db.Select("device_id", "type", "created_at").
+		Where("created_at >= ? AND created_at < ?", rangeStart, rangeEnd).
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+	counts := make(map[string]map[uint]int64)
+	for _, record := range records {
+		day := s.productionDay(record.CreatedAt, loc)
+		shift := s.shiftAt(record.CreatedAt, loc)
+		for _, goal := range goals {
+			if goalMatches(goal, record, shift) {
+				if counts[day] == nil {
+					counts[day] = make(map[uint]int64)
+				}
+				counts[day][goal.ID]++
+			}
+		}
+	}
+
+	var rows []*models.GoalProgress
+	if err := s.db.Where("day >= ? AND day <= ?", from, to).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询目标进度失败: %w", err)
+	}
+	completedAt := make(map[string]*time.Time, len(rows))
+	for _, row := range rows {
+		completedAt[fmt.Sprintf("%d/%s", row.GoalID, row.Day)] = row.CompletedAt
+	}
+
+	result := make([]GoalAttainment, 0)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		day := d.Format(dayLayout)
+		for _, goal := range goals {
+			if !goalAppliesOn(goal, day) {
+				continue
+			}
+			result = append(result, attainment(goal, day, counts[day][goal.ID], completedAt[fmt.Sprintf("%d/%s", goal.ID, day)]))
+		}
+	}
+	return result, nil
+}
+
+// ProductionDay 扫码时间在工位时区所属的生产日
+func (s *GoalService) ProductionDay(at time.Time) string {
+	return s.productionDay(at, s.location)
+}
+
+// productionDay 扫码时间在 loc 时区所属的生产日
+func (s *GoalService) productionDay(at time.Time, loc *time.Location) string {
+	local := at.In(loc)
+	if local.Hour()*60+local.Minute() < s.dayOffset {
+		local = time.Date(local.Year(), local.Month(), local.Day()-1, 12, 0, 0, 0, loc)
+	}
+	return local.Format(dayLayout)
+}
+
+// productionDayOffset 生产日起点（当天零点后的分钟数），从最晚结束的跨零点班次结束时开始
+func productionDayOffset(windows []shiftWindow) int {
+	var offset int
+	for _, shift := range windows {
+		if shift.end < shift.start && shift.end > offset {
+			offset = shift.end
+		}
+	}
+	return offset
+}
+
+// shiftAt 时间在 loc 时区所在的班次名，不在任何班次内时为空
+func (s *GoalService) shiftAt(at time.Time, loc *time.Location) string {
+	at = at.In(loc)
+	minute := at.Hour()*60 + at.Minute()
+	for _, shift := range s.shifts {
+		if shift.contains(minute) {
+			return shift.name
+		}
+	}
+	return ""
+}
+
+// validate 校验目标
+func (s *GoalService) validate(goal *models.Goal) error {
+	if goal.Name == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalidGoal)
+	}
+	if goal.Target <= 0 {
+		return fmt.Errorf("%w: 目标数量必须大于0", ErrInvalidGoal)
+	}
+
+	switch goal.Scope {
+	case models.GoalScopeStation:
+	case models.GoalScopeDevice:
+		if goal.DeviceID == nil {
+			return fmt.Errorf("%w: 按设备统计时须指定 device_id", ErrInvalidGoal)
+		}
+	case models.GoalScopeType:
+		if goal.BarcodeType == "" {
+			return fmt.Errorf("%w: 按条码类型统计时须指定 barcode_type", ErrInvalidGoal)
+		}
+	case models.GoalScopeShift:
+		found := false
+		for _, shift := range s.shifts {
+			found = found || shift.name == goal.Shift
+		}
+		if !found {
+			return fmt.Errorf("%w: 班次 %q 未在 idle.shifts 中配置", ErrInvalidGoal, goal.Shift)
+		}
+	default:
+		return fmt.Errorf("%w: 统计范围须为 station、device、type 或 shift", ErrInvalidGoal)
+	}
+
+	switch goal.Recurrence {
+	case "":
+		goal.Recurrence = models.GoalRecurrenceDaily
+	case models.GoalRecurrenceDaily, models.GoalRecurrenceWeekdays:
+	case models.GoalRecurrenceOnce:
+		if _, err := time.Parse(dayLayout, goal.Date); err != nil {
+			return fmt.Errorf("%w: 单次目标须指定生产日 date（如 2024-01-01）", ErrInvalidGoal)
+		}
+	default:
+		return fmt.Errorf("%w: 重复方式须为 once、daily 或 weekdays", ErrInvalidGoal)
+	}
+	return nil
+}
+
+// goalAppliesOn 目标是否适用于该生产日
+func goalAppliesOn(goal *models.Goal, day string) bool {
+	switch goal.Recurrence {
+	case models.GoalRecurrenceOnce:
+		return goal.Date == day
+	case models.GoalRecurrenceWeekdays:
+		t, err := time.Parse(dayLayout, day)
+		return err == nil && t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+	default:
+		return true
+	}
+}
+
+// goalMatches 扫码是否计入目标
+func goalMatches(goal *models.Goal, record *models.BarcodeRecord, shift string) bool {
+	switch goal.Scope {
+	case models.GoalScopeDevice:
+		return record.DeviceID != nil && goal.DeviceID != nil && *record.DeviceID == *goal.DeviceID
+	case models.GoalScopeType:
+		return record.Type == goal.BarcodeType
+	case models.GoalScopeShift:
+		return shift != "" && shift == goal.Shift
+	default:
+		return true
+	}
+}
+
+// attainment 汇总目标达成情况
+func attainment(goal *models.Goal, day string, count int64, completedAt *time.Time) GoalAttainment {
+	return GoalAttainment{
+		GoalID:      goal.ID,
+		Name:        goal.Name,
+		Scope:       goal.Scope,
+		Day:         day,
+		Target:      goal.Target,
+		Count:       count,
+		Percent:     float64(count) * 100 / float64(goal.Target),
+		Completed:   count >= goal.Target,
+		CompletedAt: completedAt,
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// TestGoalProductionDayDST 夏令时切换当天，目标进度按与计数器相同的生产日和班次归属扫码
+func TestGoalProductionDayDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	goals, err := NewGoalService(nil, config.GoalsConfig{}, dstShifts, loc, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range dstCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := goals.ProductionDay(tt.at); got != tt.day {
+				t.Errorf("ProductionDay(%v) = %s, want %s", tt.at.In(loc), got, tt.day)
+			}
+			if got := goals.shiftAt(tt.at, loc); got != tt.shift {
+				t.Errorf("shiftAt(%v) = %s, want %s", tt.at.In(loc), got, tt.shift)
+			}
+		})
+	}
+}
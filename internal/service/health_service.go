@@ -0,0 +1,412 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+)
+
+// 健康评分各项的满分扣分
+const (
+	healthInvalidWeight   = 40.0
+	healthDuplicateWeight = 20.0
+	healthGapWeight       = 20.0
+	healthSlowWeight      = 20.0
+
+	// 各比率达到以下值时该项扣满
+	healthInvalidLimit   = 0.2
+	healthDuplicateLimit = 0.2
+	healthGapLimit       = 0.05
+
+	// healthRecoverMargin 评分回升超过阈值此幅度后才允许再次告警
+	healthRecoverMargin = 5.0
+)
+
+// 健康评分等级
+const (
+	HealthBadgeGood     = "good"
+	HealthBadgeWarning  = "warning"
+	HealthBadgeCritical = "critical"
+	HealthBadgeUnknown  = "unknown"
+)
+
+// DeviceHealth 设备当前健康评分
+type DeviceHealth struct {
+	DeviceID       uint    `json:"device_id"`
+	Score          float64 `json:"score"`
+	Badge          string  `json:"badge"`
+	Scans          int64   `json:"scans"`
+	InvalidRatio   float64 `json:"invalid_ratio"`
+	DuplicateRatio float64 `json:"duplicate_ratio"`
+	GapRate        float64 `json:"gap_rate"`
+	AvgScanMS      float64 `json:"avg_scan_ms"`
+	Window         string  `json:"window"`
+}
+
+// healthBucket 每分钟的统计桶
+type healthBucket struct {
+	minute        int64
+	scans         int64
+	invalid       int64
+	duplicates    int64
+	gaps          int64
+	durationSum   int64
+	durationCount int64
+}
+
+// add 累加另一个桶，sign为-1时扣除
+func (b *healthBucket) add(other *healthBucket, sign int64) {
+	b.scans += sign * other.scans
+	b.invalid += sign * other.invalid
+	b.duplicates += sign * other.duplicates
+	b.gaps += sign * other.gaps
+	b.durationSum += sign * other.durationSum
+	b.durationCount += sign * other.durationCount
+}
+
+// deviceHealthTracker 单个设备的滚动统计，窗口内的合计随桶进出增量维护
+type deviceHealthTracker struct {
+	buckets      []healthBucket
+	totals       healthBucket
+	lastContent  string
+	alerted      bool
+	lastSnapshot *float64
+}
+
+// HealthService 设备健康评分服务
+type HealthService struct {
+	db        *gorm.DB
+	config    config.HealthConfig
+	logger    *logrus.Logger
+	mu        sync.Mutex
+	trackers  map[uint]*deviceHealthTracker
+	listeners []func(deviceID uint, event AlertEvent)
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewHealthService 创建设备健康评分服务
+func NewHealthService(db *gorm.DB, cfg config.HealthConfig, logger *logrus.Logger) *HealthService {
+	if cfg.Window < time.Minute {
+		cfg.Window = time.Hour
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = time.Hour
+	}
+	if cfg.SlowScanMS <= 0 {
+		cfg.SlowScanMS = 300
+	}
+	return &HealthService{
+		db:       db,
+		config:   cfg,
+		logger:   logger,
+		trackers: make(map[uint]*deviceHealthTracker),
+		done:     make(chan struct{}),
+	}
+}
+
+// OnAlert 注册健康告警回调
+func (s *HealthService) OnAlert(listener func(deviceID uint, event AlertEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Observe 记录一次有效扫码
+func (s *HealthService) Observe(record *models.BarcodeRecord) {
+	if record.DeviceID == nil {
+		return
+	}
+	s.update(*record.DeviceID, func(tracker *deviceHealthTracker, bucket *healthBucket) {
+		bucket.scans++
+		if record.Content == tracker.lastContent {
+			bucket.duplicates++
+		}
+		tracker.lastContent = record.Content
+		if record.DurationMS > 0 {
+			bucket.durationSum += record.DurationMS
+			bucket.durationCount++
+		}
+	})
+}
+
+// ObserveInvalid 记录一次无效扫码
+func (s *HealthService) ObserveInvalid(deviceID uint, content string) {
+	s.update(deviceID, func(_ *deviceHealthTracker, bucket *healthBucket) {
+		bucket.invalid++
+	})
+}
+
+// ObserveGap 记录一次序列号断号
+func (s *HealthService) ObserveGap(event SequenceGapEvent) {
+	s.update(event.DeviceID, func(_ *deviceHealthTracker, bucket *healthBucket) {
+		bucket.gaps++
+	})
+}
+
+// GetHealth 获取设备当前健康评分
+func (s *HealthService) GetHealth(deviceID uint) DeviceHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracker, ok := s.trackers[deviceID]
+	if !ok {
+		return s.evaluate(deviceID, nil)
+	}
+	s.expire(tracker, time.Now())
+	return s.evaluate(deviceID, tracker)
+}
+
+// GetTrend 获取设备自since以来的评分快照
+func (s *HealthService) GetTrend(deviceID uint, since time.Time) ([]*models.DeviceHealthSnapshot, error) {
+	var snapshots []*models.DeviceHealthSnapshot
+	err := s.db.Where("device_id = ? AND created_at >= ?", deviceID, since).
+		Order("created_at").
+		Find(&snapshots).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询健康评分快照失败: %w", err)
+	}
+	return snapshots, nil
+}
+
+// Reset 清空内存中的滚动统计（数据重置后调用）
+func (s *HealthService) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trackers = make(map[uint]*deviceHealthTracker)
+}
+
+// Start 启动定时快照
+func (s *HealthService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.SnapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.snapshot()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时快照
+func (s *HealthService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// update 在当前分钟的桶中累加统计并检查评分阈值
+func (s *HealthService) update(deviceID uint, apply func(tracker *deviceHealthTracker, bucket *healthBucket)) {
+	if deviceID == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	tracker, ok := s.trackers[deviceID]
+	if !ok {
+		tracker = &deviceHealthTracker{
+			buckets: make([]healthBucket, int(s.config.Window/time.Minute)),
+		}
+		s.trackers[deviceID] = tracker
+	}
+
+	now := time.Now()
+	s.expire(tracker, now)
+
+	minute := now.Unix() / 60
+	bucket := &tracker.buckets[minute%int64(len(tracker.buckets))]
+	bucket.minute = minute
+
+	var delta healthBucket
+	apply(tracker, &delta)
+	bucket.add(&delta, 1)
+	tracker.totals.add(&delta, 1)
+
+	health := s.evaluate(deviceID, tracker)
+	var alert *AlertEvent
+	switch {
+	case health.Scans < s.config.MinScans:
+	case !tracker.alerted && health.Score < s.config.AlertThreshold:
+		tracker.alerted = true
+		alert = &AlertEvent{
+			Source:  "device_health",
+			Level:   "warning",
+			Message: i18n.Sprintf("alert.health_below_threshold", deviceID, health.Score, s.config.AlertThreshold),
+		}
+	case tracker.alerted && health.Score >= s.config.AlertThreshold+healthRecoverMargin:
+		tracker.alerted = false
+	}
+	s.mu.Unlock()
+
+	if alert != nil {
+		s.logger.WithField("device_id", deviceID).WithField("score", health.Score).Warn("设备健康评分低于阈值")
+		s.notify(deviceID, *alert)
+	}
+}
+
+// expire 扣除已滑出窗口的桶
+func (s *HealthService) expire(tracker *deviceHealthTracker, now time.Time) {
+	oldest := now.Unix()/60 - int64(len(tracker.buckets)) + 1
+	for i := range tracker.buckets {
+		bucket := &tracker.buckets[i]
+		if bucket.minute != 0 && bucket.minute < oldest {
+			tracker.totals.add(bucket, -1)
+			*bucket = healthBucket{}
+		}
+	}
+}
+
+// evaluate 根据窗口合计计算评分，调用方需持有锁
+func (s *HealthService) evaluate(deviceID uint, tracker *deviceHealthTracker) DeviceHealth {
+	health := DeviceHealth{
+		DeviceID: deviceID,
+		Score:    100,
+		Badge:    HealthBadgeUnknown,
+		Window:   s.config.Window.String(),
+	}
+	if tracker == nil {
+		return health
+	}
+
+	totals := tracker.totals
+	health.Scans = totals.scans
+	if attempts := totals.scans + totals.invalid; attempts > 0 {
+		health.InvalidRatio = float64(totals.invalid) / float64(attempts)
+	}
+	if totals.scans > 0 {
+		health.DuplicateRatio = float64(totals.duplicates) / float64(totals.scans)
+		health.GapRate = float64(totals.gaps) / float64(totals.scans)
+	}
+	if totals.durationCount > 0 {
+		health.AvgScanMS = float64(totals.durationSum) / float64(totals.durationCount)
+	}
+	if totals.scans+totals.invalid == 0 {
+		return health
+	}
+
+	// 平均耗时在慢速阈值一半以内不扣分，达到阈值时扣满
+	slow := float64(s.config.SlowScanMS)
+	slowPenalty := (health.AvgScanMS - slow/2) / (slow / 2)
+
+	score := 100 -
+		healthInvalidWeight*clampRatio(health.InvalidRatio/healthInvalidLimit) -
+		healthDuplicateWeight*clampRatio(health.DuplicateRatio/healthDuplicateLimit) -
+		healthGapWeight*clampRatio(health.GapRate/healthGapLimit) -
+		healthSlowWeight*clampRatio(slowPenalty)
+	health.Score = math.Round(score*10) / 10
+
+	switch {
+	case health.Score < s.config.AlertThreshold:
+		health.Badge = HealthBadgeCritical
+	case health.Score < 80:
+		health.Badge = HealthBadgeWarning
+	default:
+		health.Badge = HealthBadgeGood
+	}
+	return health
+}
+
+// snapshot 保存各设备的评分快照，并检查相比上一快照的骤降
+func (s *HealthService) snapshot() {
+	now := time.Now()
+
+	s.mu.Lock()
+	healths := make([]DeviceHealth, 0, len(s.trackers))
+	trackers := make([]*deviceHealthTracker, 0, len(s.trackers))
+	for deviceID, tracker := range s.trackers {
+		s.expire(tracker, now)
+		healths = append(healths, s.evaluate(deviceID, tracker))
+		trackers = append(trackers, tracker)
+	}
+	s.mu.Unlock()
+
+	for i, health := range healths {
+		tracker := trackers[i]
+		if health.Badge == HealthBadgeUnknown {
+			continue
+		}
+
+		previous := s.previousScore(health.DeviceID, tracker)
+		snapshot := &models.DeviceHealthSnapshot{
+			DeviceID:       health.DeviceID,
+			Score:          health.Score,
+			Scans:          health.Scans,
+			InvalidRatio:   health.InvalidRatio,
+			DuplicateRatio: health.DuplicateRatio,
+			GapRate:        health.GapRate,
+			AvgScanMS:      health.AvgScanMS,
+		}
+		if err := s.db.Create(snapshot).Error; err != nil {
+			s.logger.WithError(err).WithField("device_id", health.DeviceID).Warn("保存健康评分快照失败")
+			continue
+		}
+
+		s.mu.Lock()
+		score := health.Score
+		tracker.lastSnapshot = &score
+		s.mu.Unlock()
+
+		if previous != nil && health.Scans >= s.config.MinScans && *previous-health.Score >= s.config.DropThreshold {
+			s.logger.WithField("device_id", health.DeviceID).WithField("score", health.Score).Warn("设备健康评分骤降")
+			s.notify(health.DeviceID, AlertEvent{
+				Source:  "device_health",
+				Level:   "warning",
+				Message: i18n.Sprintf("alert.health_dropped", health.DeviceID, *previous, health.Score),
+			})
+		}
+	}
+}
+
+// previousScore 上一快照的评分，内存中没有时从数据库读取
+func (s *HealthService) previousScore(deviceID uint, tracker *deviceHealthTracker) *float64 {
+	s.mu.Lock()
+	previous := tracker.lastSnapshot
+	s.mu.Unlock()
+	if previous != nil {
+		return previous
+	}
+
+	var last models.DeviceHealthSnapshot
+	if err := s.db.Where("device_id = ?", deviceID).Order("created_at DESC").First(&last).Error; err != nil {
+		return nil
+	}
+	return &last.Score
+}
+
+// notify 通知健康告警
+func (s *HealthService) notify(deviceID uint, event AlertEvent) {
+	s.mu.Lock()
+	listeners := make([]func(uint, AlertEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(deviceID, event)
+	}
+}
+
+// clampRatio 将比例限制在[0, 1]
+func clampRatio(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
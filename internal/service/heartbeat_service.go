@@ -0,0 +1,257 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 工位可用性状态
+const (
+	AvailabilityActive = "active" // 有心跳且有扫码
+	AvailabilityIdle   = "idle"   // 有心跳但无扫码
+	AvailabilityDown   = "down"   // 缺少心跳，工位未运行
+)
+
+// heartbeatSlack 相邻心跳周期之间允许的空隙，超过视为停机
+const heartbeatSlack = time.Minute
+
+// AvailabilitySegment 一段连续的可用性状态
+type AvailabilitySegment struct {
+	State string    `json:"state"` // active, idle, down
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Scans int64     `json:"scans"`
+}
+
+// Availability 时间范围内的工位可用性
+type Availability struct {
+	From     time.Time             `json:"from"`
+	To       time.Time             `json:"to"`
+	Segments []AvailabilitySegment `json:"segments"`
+	Seconds  map[string]float64    `json:"seconds"` // 各状态累计秒数
+}
+
+// HeartbeatService 工位心跳记录
+//
+// 每隔固定间隔写入一条心跳（组件状态及本周期扫码数），报表中缺少心跳的时段为停机，
+// 有心跳但无扫码的时段为空闲。数据库不可用时心跳暂存在内存中，恢复后按顺序补写。
+type HeartbeatService struct {
+	db         *gorm.DB
+	config     config.HeartbeatConfig
+	station    string
+	logger     *logrus.Logger
+	scans      int64
+	components func() map[string]string
+	done       chan struct{}
+	wg         sync.WaitGroup
+
+	mu      sync.Mutex
+	since   time.Time
+	pending []*models.Heartbeat
+	dropped int64
+}
+
+// NewHeartbeatService 创建心跳服务
+func NewHeartbeatService(db *gorm.DB, cfg config.HeartbeatConfig, station string, logger *logrus.Logger) *HeartbeatService {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 24
+	}
+
+	return &HeartbeatService{
+		db:      db,
+		config:  cfg,
+		station: station,
+		logger:  logger,
+		done:    make(chan struct{}),
+		since:   time.Now(),
+	}
+}
+
+// SetComponents 设置组件状态来源，心跳时调用
+func (s *HeartbeatService) SetComponents(components func() map[string]string) {
+	s.components = components
+}
+
+// Observe 累计本周期扫码数
+func (s *HeartbeatService) Observe(*models.BarcodeRecord) {
+	atomic.AddInt64(&s.scans, 1)
+}
+
+// Start 启动定时心跳
+func (s *HeartbeatService) Start() {
+	s.mu.Lock()
+	s.since = time.Now()
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.beat()
+				s.cleanup()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时心跳，并写入最后一条心跳以记录停止时间
+func (s *HeartbeatService) Close() {
+	close(s.done)
+	s.wg.Wait()
+	s.beat()
+}
+
+// GetHeartbeats 查询时间范围内的心跳记录
+func (s *HeartbeatService) GetHeartbeats(from, to time.Time) ([]*models.Heartbeat, error) {
+	var heartbeats []*models.Heartbeat
+	err := s.db.Where("station = ? AND at > ? AND since < ?", s.station, from, to).
+		Order("at, id").Find(&heartbeats).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询心跳记录失败: %w", err)
+	}
+	return heartbeats, nil
+}
+
+// GetAvailability 根据心跳划分时间范围内的运行、空闲与停机时段
+func (s *HeartbeatService) GetAvailability(from, to time.Time) (*Availability, error) {
+	heartbeats, err := s.GetHeartbeats(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// 暂存未写入的心跳及当前周期视为运行中
+	now := time.Now()
+	s.mu.Lock()
+	heartbeats = append(heartbeats, s.pending...)
+	heartbeats = append(heartbeats, &models.Heartbeat{Since: s.since, At: now, Scans: atomic.LoadInt64(&s.scans)})
+	s.mu.Unlock()
+	sort.Slice(heartbeats, func(i, j int) bool {
+		return heartbeats[i].At.Before(heartbeats[j].At)
+	})
+
+	if to.After(now) {
+		to = now
+	}
+	return buildAvailability(heartbeats, from, to), nil
+}
+
+// beat 写入一条心跳，数据库不可用时暂存
+func (s *HeartbeatService) beat() {
+	now := time.Now()
+	heartbeat := &models.Heartbeat{
+		Station: s.station,
+		At:      now,
+		Scans:   atomic.SwapInt64(&s.scans, 0),
+	}
+	if s.components != nil {
+		heartbeat.Components = models.StringMap(s.components())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	heartbeat.Since = s.since
+	s.since = now
+	s.pending = append(s.pending, heartbeat)
+	if overflow := len(s.pending) - s.config.BufferSize; overflow > 0 {
+		s.pending = s.pending[overflow:]
+		s.dropped += int64(overflow)
+		s.logger.WithField("dropped", s.dropped).Warn("心跳缓存已满，丢弃最早的心跳")
+	}
+
+	for len(s.pending) > 0 {
+		next := s.pending[0]
+		next.Delayed = next != heartbeat
+		if err := s.db.Create(next).Error; err != nil {
+			s.logger.WithError(err).WithField("buffered", len(s.pending)).Warn("写入心跳失败，已暂存待数据库恢复后补写")
+			return
+		}
+		s.pending = s.pending[1:]
+	}
+}
+
+// cleanup 删除超过保留时长的心跳
+func (s *HeartbeatService) cleanup() {
+	if s.config.Retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-s.config.Retention)
+	if err := s.db.Where("at < ?", cutoff).Delete(&models.Heartbeat{}).Error; err != nil {
+		s.logger.WithError(err).Warn("清理过期心跳失败")
+	}
+}
+
+// buildAvailability 按心跳覆盖的周期划分时段，周期之间的空隙为停机，相邻的同状态时段合并
+func buildAvailability(heartbeats []*models.Heartbeat, from, to time.Time) *Availability {
+	availability := &Availability{
+		From:     from,
+		To:       to,
+		Segments: []AvailabilitySegment{},
+		Seconds:  map[string]float64{AvailabilityActive: 0, AvailabilityIdle: 0, AvailabilityDown: 0},
+	}
+
+	add := func(state string, start, end time.Time, scans int64) {
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			return
+		}
+		availability.Seconds[state] += end.Sub(start).Seconds()
+		if n := len(availability.Segments); n > 0 {
+			last := &availability.Segments[n-1]
+			if last.State == state && !start.After(last.End) {
+				last.End = end
+				last.Scans += scans
+				return
+			}
+		}
+		availability.Segments = append(availability.Segments, AvailabilitySegment{State: state, Start: start, End: end, Scans: scans})
+	}
+
+	covered := from
+	for _, heartbeat := range heartbeats {
+		if heartbeat.Since.Sub(covered) > heartbeatSlack {
+			add(AvailabilityDown, covered, heartbeat.Since, 0)
+		}
+		state := AvailabilityIdle
+		if heartbeat.Scans > 0 {
+			state = AvailabilityActive
+		}
+		start := heartbeat.Since
+		if start.Before(covered) {
+			start = covered
+		}
+		add(state, start, heartbeat.At, heartbeat.Scans)
+		if heartbeat.At.After(covered) {
+			covered = heartbeat.At
+		}
+	}
+	if to.Sub(covered) > heartbeatSlack {
+		add(AvailabilityDown, covered, to, 0)
+	}
+	return availability
+}
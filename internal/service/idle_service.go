@@ -0,0 +1,409 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+)
+
+// idleStateKey 最后扫码时间在配置表中的键
+const idleStateKey = "idle.last_scan"
+
+// 暂停空闲计时的原因
+const (
+	IdlePausedMaintenance = "maintenance"
+	IdlePausedOffShift    = "off_shift"
+)
+
+// IdleEntry 设备或工位的空闲状态
+type IdleEntry struct {
+	DeviceID    uint       `json:"device_id,omitempty"` // 为0表示整个工位
+	LastScanAt  *time.Time `json:"last_scan_at,omitempty"`
+	IdleSeconds float64    `json:"idle_seconds"`
+	Overdue     bool       `json:"overdue"` // 空闲时长超过生产节拍
+}
+
+// IdleStatus 空闲监控状态，同时作为 idle_tick 事件推送
+type IdleStatus struct {
+	Tracking    bool        `json:"tracking"`
+	PausedBy    string      `json:"paused_by,omitempty"` // maintenance, off_shift
+	Shift       string      `json:"shift,omitempty"`
+	TaktSeconds float64     `json:"takt_seconds"`
+	Station     IdleEntry   `json:"station"`
+	Devices     []IdleEntry `json:"devices"`
+}
+
+// idleState 持久化的最后扫码时间
+type idleState struct {
+	Station *time.Time          `json:"station,omitempty"`
+	Devices map[uint]*time.Time `json:"devices"`
+}
+
+// shiftWindow 解析后的生产班次，以当天分钟数表示
+type shiftWindow struct {
+	name       string
+	start, end int
+}
+
+// contains 判断分钟数是否在班次内，结束早于开始时视为跨零点
+func (w shiftWindow) contains(minute int) bool {
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// IdleService 距上次扫码时长监控
+//
+// 维护模式中或不在生产班次内时暂停计时，恢复计时后空闲时长从恢复时刻起算，
+// 避免夜间停产后一开班就告警。
+type IdleService struct {
+	configService *ConfigService
+	maintenance   *MaintenanceService
+	counters      *CounterService // 最后扫码时间随计数器定期写入，为nil时只在关闭时保存
+	config        config.IdleConfig
+	logger        *logrus.Logger
+	shifts        []shiftWindow
+	location      *time.Location // 班次时间所在的时区
+	done          chan struct{}
+	wg            sync.WaitGroup
+
+	mu             sync.RWMutex
+	state          idleState
+	tracking       bool
+	resumedAt      time.Time
+	stationAlerted bool
+	alerted        map[uint]bool
+	tickListeners  []func(IdleStatus)
+	alertListeners []func(deviceID *uint, event AlertEvent)
+}
+
+// NewIdleService 创建空闲监控服务，班次时间按 loc 时区解释
+func NewIdleService(configService *ConfigService, maintenance *MaintenanceService, cfg config.IdleConfig, loc *time.Location, logger *logrus.Logger) (*IdleService, error) {
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = 5 * time.Second
+	}
+
+	shifts, err := parseShifts(cfg.Shifts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdleService{
+		configService: configService,
+		maintenance:   maintenance,
+		config:        cfg,
+		logger:        logger,
+		shifts:        shifts,
+		location:      loc,
+		done:          make(chan struct{}),
+		state:         idleState{Devices: make(map[uint]*time.Time)},
+		tracking:      true,
+		alerted:       make(map[uint]bool),
+	}, nil
+}
+
+// SetCounters 设置持久化计数器，最后扫码时间随计数器定期写入，异常退出后重启也能恢复；须在 Load 之前调用
+func (s *IdleService) SetCounters(counters *CounterService) {
+	s.counters = counters
+}
+
+// OnTick 注册 idle_tick 回调
+func (s *IdleService) OnTick(listener func(IdleStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickListeners = append(s.tickListeners, listener)
+}
+
+// OnAlert 注册空闲告警回调，deviceID为nil表示整个工位
+func (s *IdleService) OnAlert(listener func(deviceID *uint, event AlertEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alertListeners = append(s.alertListeners, listener)
+}
+
+// Load 从数据库恢复上次关闭前的最后扫码时间，计数器中的时间较新时以计数器为准
+func (s *IdleService) Load() error {
+	state := idleState{Devices: make(map[uint]*time.Time)}
+	config, err := s.configService.GetConfiguration(idleStateKey)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("读取最后扫码时间失败: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal([]byte(config.Value), &state); err != nil {
+			return fmt.Errorf("解析最后扫码时间失败: %w", err)
+		}
+		if state.Devices == nil {
+			state.Devices = make(map[uint]*time.Time)
+		}
+	}
+
+	if s.counters != nil {
+		for _, counter := range s.counters.List(CounterScopeIdle) {
+			at := time.UnixMilli(counter.Value)
+			if counter.Name == CounterStation {
+				state.Station = laterScan(state.Station, at)
+			} else if deviceID, ok := ParseDeviceCounterName(counter.Name); ok {
+				state.Devices[deviceID] = laterScan(state.Devices[deviceID], at)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+	return nil
+}
+
+// Observe 记录一次有效扫码
+func (s *IdleService) Observe(record *models.BarcodeRecord) {
+	at := record.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Station = &at
+	s.stationAlerted = false
+	if record.DeviceID != nil {
+		s.state.Devices[*record.DeviceID] = &at
+		delete(s.alerted, *record.DeviceID)
+	}
+
+	if s.counters != nil {
+		s.counters.Advance(CounterScopeIdle, CounterStation, at.UnixMilli())
+		if record.DeviceID != nil {
+			s.counters.Advance(CounterScopeIdle, DeviceCounterName(*record.DeviceID), at.UnixMilli())
+		}
+	}
+}
+
+// laterScan 两个最后扫码时间中较晚的一个
+func laterScan(current *time.Time, at time.Time) *time.Time {
+	if current != nil && !at.After(*current) {
+		return current
+	}
+	return &at
+}
+
+// GetStatus 获取当前空闲状态
+func (s *IdleService) GetStatus() IdleStatus {
+	now := time.Now()
+	pausedBy, shift := s.pauseReason(now)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status(now, pausedBy, shift)
+}
+
+// Start 启动定时广播与告警检查
+func (s *IdleService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.TickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.tick()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时广播并保存最后扫码时间
+func (s *IdleService) Close() {
+	close(s.done)
+	s.wg.Wait()
+
+	if err := s.save(); err != nil {
+		s.logger.WithError(err).Warn("保存最后扫码时间失败")
+	}
+}
+
+// tick 推送空闲状态并检查告警
+func (s *IdleService) tick() {
+	now := time.Now()
+	pausedBy, shift := s.pauseReason(now)
+
+	s.mu.Lock()
+	tracking := pausedBy == ""
+	if tracking && !s.tracking {
+		// 恢复计时，之前的空闲时间不计入
+		s.resumedAt = now
+		s.stationAlerted = false
+		s.alerted = make(map[uint]bool)
+	}
+	s.tracking = tracking
+	status := s.status(now, pausedBy, shift)
+
+	var alerts []idleAlert
+	if tracking && s.config.AlertAfter > 0 {
+		alerts = s.checkAlerts(status)
+	}
+	tickListeners := make([]func(IdleStatus), len(s.tickListeners))
+	copy(tickListeners, s.tickListeners)
+	alertListeners := make([]func(*uint, AlertEvent), len(s.alertListeners))
+	copy(alertListeners, s.alertListeners)
+	s.mu.Unlock()
+
+	for _, listener := range tickListeners {
+		listener(status)
+	}
+	for _, alert := range alerts {
+		s.logger.Warn(alert.event.Message)
+		for _, listener := range alertListeners {
+			listener(alert.deviceID, alert.event)
+		}
+	}
+}
+
+// idleAlert 待发送的空闲告警
+type idleAlert struct {
+	deviceID *uint
+	event    AlertEvent
+}
+
+// checkAlerts 检查超过告警时长的设备与工位，每次空闲只告警一次（调用方持有写锁）
+func (s *IdleService) checkAlerts(status IdleStatus) []idleAlert {
+	limit := s.config.AlertAfter.Seconds()
+	var alerts []idleAlert
+
+	if status.Station.IdleSeconds >= limit && !s.stationAlerted {
+		s.stationAlerted = true
+		alerts = append(alerts, idleAlert{event: AlertEvent{
+			Source:  "idle",
+			Level:   "warning",
+			Message: i18n.Sprintf("alert.station_idle", formatIdle(status.Station.IdleSeconds)),
+		}})
+	}
+	for _, entry := range status.Devices {
+		if entry.IdleSeconds < limit || s.alerted[entry.DeviceID] {
+			continue
+		}
+		s.alerted[entry.DeviceID] = true
+		deviceID := entry.DeviceID
+		alerts = append(alerts, idleAlert{deviceID: &deviceID, event: AlertEvent{
+			Source:  "idle",
+			Level:   "warning",
+			Message: i18n.Sprintf("alert.device_idle", deviceID, formatIdle(entry.IdleSeconds)),
+		}})
+	}
+	return alerts
+}
+
+// status 生成空闲状态（调用方持有读锁）
+func (s *IdleService) status(now time.Time, pausedBy, shift string) IdleStatus {
+	status := IdleStatus{
+		Tracking:    pausedBy == "",
+		PausedBy:    pausedBy,
+		Shift:       shift,
+		TaktSeconds: s.config.TaktTime.Seconds(),
+		Station:     s.entry(0, s.state.Station, now, pausedBy == ""),
+		Devices:     make([]IdleEntry, 0, len(s.state.Devices)),
+	}
+	for deviceID, lastScan := range s.state.Devices {
+		status.Devices = append(status.Devices, s.entry(deviceID, lastScan, now, status.Tracking))
+	}
+	sort.Slice(status.Devices, func(i, j int) bool {
+		return status.Devices[i].DeviceID < status.Devices[j].DeviceID
+	})
+	return status
+}
+
+// entry 计算单个设备或工位的空闲时长，暂停计时时为0
+func (s *IdleService) entry(deviceID uint, lastScan *time.Time, now time.Time, tracking bool) IdleEntry {
+	entry := IdleEntry{DeviceID: deviceID, LastScanAt: lastScan}
+	if !tracking {
+		return entry
+	}
+
+	since := s.resumedAt
+	if lastScan != nil && lastScan.After(since) {
+		since = *lastScan
+	}
+	if since.IsZero() {
+		return entry
+	}
+
+	entry.IdleSeconds = now.Sub(since).Seconds()
+	entry.Overdue = s.config.TaktTime > 0 && entry.IdleSeconds > s.config.TaktTime.Seconds()
+	return entry
+}
+
+// pauseReason 判断是否暂停计时，返回暂停原因与当前班次
+func (s *IdleService) pauseReason(now time.Time) (string, string) {
+	if s.maintenance != nil && s.maintenance.IsActive() {
+		return IdlePausedMaintenance, ""
+	}
+	if len(s.shifts) == 0 {
+		return "", ""
+	}
+
+	local := now.In(s.location)
+	minute := local.Hour()*60 + local.Minute()
+	for _, shift := range s.shifts {
+		if shift.contains(minute) {
+			return "", shift.name
+		}
+	}
+	return IdlePausedOffShift, ""
+}
+
+// save 持久化最后扫码时间
+func (s *IdleService) save() error {
+	s.mu.RLock()
+	data, err := json.Marshal(s.state)
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("序列化最后扫码时间失败: %w", err)
+	}
+	return s.configService.SetConfiguration(idleStateKey, string(data), "system", "各设备最后扫码时间")
+}
+
+// parseShifts 解析生产班次配置
+func parseShifts(windows []config.ShiftWindow) ([]shiftWindow, error) {
+	shifts := make([]shiftWindow, 0, len(windows))
+	for _, shift := range windows {
+		start, err := parseClock(shift.Start)
+		if err != nil {
+			return nil, fmt.Errorf("班次 %s 开始时间无效: %w", shift.Name, err)
+		}
+		end, err := parseClock(shift.End)
+		if err != nil {
+			return nil, fmt.Errorf("班次 %s 结束时间无效: %w", shift.Name, err)
+		}
+		shifts = append(shifts, shiftWindow{name: shift.Name, start: start, end: end})
+	}
+	return shifts, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间为当天分钟数
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// formatIdle 格式化空闲时长
+func formatIdle(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
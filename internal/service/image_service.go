@@ -0,0 +1,349 @@
+package service
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// orphanGracePeriod 新写入的文件在此时长内不视为孤立文件，避免与正在保存的图片冲突
+const orphanGracePeriod = time.Minute
+
+// 图片校验错误
+var (
+	ErrImageTooLarge       = errors.New("图片超过大小限制")
+	ErrImageTypeNotAllowed = errors.New("不支持的图片类型")
+)
+
+// imageExtensions 图片类型对应的文件扩展名
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+// ImageStoreStatus 图片存储状态
+type ImageStoreStatus struct {
+	Enabled     bool       `json:"enabled"`
+	Dir         string     `json:"dir,omitempty"`
+	Files       int64      `json:"files"`
+	Bytes       int64      `json:"bytes"`
+	LastCleanup *time.Time `json:"last_cleanup,omitempty"`
+}
+
+// ImageService 条码图片存储服务
+type ImageService struct {
+	db      *gorm.DB
+	config  config.ImagesConfig
+	logger  *logrus.Logger
+	allowed map[string]bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu          sync.RWMutex
+	files       int64
+	bytes       int64
+	lastCleanup *time.Time
+}
+
+// NewImageService 创建条码图片存储服务
+func NewImageService(db *gorm.DB, cfg config.ImagesConfig, logger *logrus.Logger) (*ImageService, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("图片存储目录不能为空")
+	}
+	if cfg.MaxSizeKB <= 0 {
+		cfg.MaxSizeKB = 5120
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = time.Hour
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建图片存储目录失败: %w", err)
+	}
+
+	allowed := make(map[string]bool, len(cfg.AllowedTypes))
+	for _, t := range cfg.AllowedTypes {
+		if _, ok := imageExtensions[t]; !ok {
+			return nil, fmt.Errorf("不支持的图片类型: %s", t)
+		}
+		allowed[t] = true
+	}
+
+	return &ImageService{
+		db:      db,
+		config:  cfg,
+		logger:  logger,
+		allowed: allowed,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// SaveImage 保存记录的条码图片，已有图片时替换
+//
+// 图片类型根据内容识别，声明的contentType仅用于交叉校验。
+func (s *ImageService) SaveImage(recordID uint, contentType string, r io.Reader) (*models.BarcodeImage, error) {
+	reader := bufio.NewReaderSize(io.LimitReader(r, s.config.MaxSizeKB*1024+1), 512)
+	head, err := reader.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("读取图片失败: %w", err)
+	}
+	if len(head) == 0 {
+		return nil, fmt.Errorf("图片内容为空")
+	}
+
+	detected := http.DetectContentType(head)
+	if !s.allowed[detected] {
+		return nil, fmt.Errorf("%w: %s", ErrImageTypeNotAllowed, detected)
+	}
+	if declared := strings.TrimSpace(strings.Split(contentType, ";")[0]); declared != "" &&
+		declared != "application/octet-stream" && declared != detected {
+		return nil, fmt.Errorf("%w: 声明为 %s，实际为 %s", ErrImageTypeNotAllowed, declared, detected)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("生成图片文件名失败: %w", err)
+	}
+	now := time.Now()
+	relPath := filepath.Join(now.Format("20060102"), fmt.Sprintf("%d-%s%s", recordID, hex.EncodeToString(suffix), imageExtensions[detected]))
+	fullPath := filepath.Join(s.config.Dir, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, fmt.Errorf("创建图片目录失败: %w", err)
+	}
+
+	// 先写入临时文件，完整写入后再改名，避免留下半截文件
+	tmpPath := fullPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("创建图片文件失败: %w", err)
+	}
+	size, err := io.Copy(file, reader)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && size > s.config.MaxSizeKB*1024 {
+		err = ErrImageTooLarge
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, fullPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		if errors.Is(err, ErrImageTooLarge) {
+			return nil, fmt.Errorf("%w（上限 %d KB）", ErrImageTooLarge, s.config.MaxSizeKB)
+		}
+		return nil, fmt.Errorf("保存图片文件失败: %w", err)
+	}
+
+	previous, _ := s.GetImage(recordID)
+
+	image := &models.BarcodeImage{
+		RecordID:    recordID,
+		Path:        filepath.ToSlash(relPath),
+		ContentType: detected,
+		Size:        size,
+		CreatedAt:   now,
+	}
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "record_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"path", "content_type", "size", "created_at"}),
+	}).Create(image).Error
+	if err != nil {
+		os.Remove(fullPath)
+		return nil, fmt.Errorf("保存图片记录失败: %w", err)
+	}
+
+	s.mu.Lock()
+	s.files++
+	s.bytes += size
+	s.mu.Unlock()
+
+	if previous != nil {
+		s.removeFile(previous)
+	}
+
+	s.logger.WithField("record_id", recordID).WithField("size", size).Info("条码图片已保存")
+	return image, nil
+}
+
+// GetImage 获取记录的图片信息
+func (s *ImageService) GetImage(recordID uint) (*models.BarcodeImage, error) {
+	var image models.BarcodeImage
+	if err := s.db.Where("record_id = ?", recordID).First(&image).Error; err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// FullPath 图片文件的完整路径
+func (s *ImageService) FullPath(image *models.BarcodeImage) string {
+	return filepath.Join(s.config.Dir, filepath.FromSlash(image.Path))
+}
+
+// GetStatus 获取图片存储状态（磁盘占用在启动和每次清理时重新统计）
+func (s *ImageService) GetStatus() ImageStoreStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return ImageStoreStatus{
+		Enabled:     true,
+		Dir:         s.config.Dir,
+		Files:       s.files,
+		Bytes:       s.bytes,
+		LastCleanup: s.lastCleanup,
+	}
+}
+
+// Start 统计磁盘占用并启动定期清理
+func (s *ImageService) Start() {
+	s.cleanup()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.CleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.cleanup()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定期清理
+func (s *ImageService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// cleanup 删除过期图片、记录已删除的图片以及磁盘上的孤立文件，并重新统计磁盘占用
+func (s *ImageService) cleanup() {
+	var expired []*models.BarcodeImage
+	query := s.db.Where("record_id NOT IN (?)", s.db.Model(&models.BarcodeRecord{}).Select("id"))
+	if s.config.Retention > 0 {
+		query = query.Or("created_at < ?", time.Now().Add(-s.config.Retention))
+	}
+	if err := query.Find(&expired).Error; err != nil {
+		s.logger.WithError(err).Warn("查询待清理图片失败")
+	}
+	for _, image := range expired {
+		if err := s.db.Delete(image).Error; err != nil {
+			s.logger.WithError(err).WithField("record_id", image.RecordID).Warn("删除图片记录失败")
+			continue
+		}
+		s.removeFile(image)
+	}
+
+	var paths []string
+	if err := s.db.Model(&models.BarcodeImage{}).Pluck("path", &paths).Error; err != nil {
+		s.logger.WithError(err).Warn("查询图片记录失败，跳过孤立文件清理")
+		return
+	}
+	known := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		known[path] = true
+	}
+
+	var files, bytes, orphans int64
+	cutoff := time.Now().Add(-orphanGracePeriod)
+	err := filepath.Walk(s.config.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.config.Dir, path)
+		if err != nil {
+			return nil
+		}
+		if !known[filepath.ToSlash(rel)] && info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err == nil {
+				orphans++
+				return nil
+			}
+		}
+		files++
+		bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Warn("扫描图片目录失败")
+	}
+	s.removeEmptyDirs()
+
+	now := time.Now()
+	s.mu.Lock()
+	s.files = files
+	s.bytes = bytes
+	s.lastCleanup = &now
+	s.mu.Unlock()
+
+	if len(expired) > 0 || orphans > 0 {
+		s.logger.WithField("expired", len(expired)).WithField("orphans", orphans).Info("已清理条码图片")
+	}
+}
+
+// removeFile 删除图片文件
+func (s *ImageService) removeFile(image *models.BarcodeImage) {
+	path := s.FullPath(image)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		s.logger.WithError(err).WithField("path", path).Warn("删除图片文件失败")
+		return
+	}
+
+	s.mu.Lock()
+	s.files--
+	s.bytes -= info.Size()
+	s.mu.Unlock()
+}
+
+// removeEmptyDirs 删除空的日期目录
+func (s *ImageService) removeEmptyDirs() {
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.config.Dir, entry.Name())
+		if children, err := os.ReadDir(dir); err == nil && len(children) == 0 {
+			os.Remove(dir)
+		}
+	}
+}
+
+// BarcodeImageEvent 条码图片上传事件
+type BarcodeImageEvent struct {
+	RecordID    uint   `json:"record_id"`
+	DeviceID    *uint  `json:"device_id,omitempty"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
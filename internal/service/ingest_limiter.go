@@ -0,0 +1,308 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// ingestSourceIdle 来源超过此时长没有扫码且额度已满时清理其限流状态，外部推送按令牌或IP区分来源，避免条目无限增长
+const ingestSourceIdle = 10 * time.Minute
+
+// ingestWaitStep 网络扫码枪等待额度时单次休眠的上限，便于及时响应停止及配置变化
+const ingestWaitStep = 500 * time.Millisecond
+
+// tokenBucket 令牌桶，rate 为每秒补充的令牌数，最多积累 burst 个
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// refill 按经过的时间补充令牌
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// delay 令牌数达到 need 还需等待的时间
+func (b *tokenBucket) delay(need float64) time.Duration {
+	if b.tokens >= need {
+		return 0
+	}
+	if b.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// IngestSourceStatus 一个扫码来源的限流状态
+type IngestSourceStatus struct {
+	Source    string     `json:"source"`
+	Priority  bool       `json:"priority"`  // 按键来源，不受限流，优先使用预留额度
+	Throttled bool       `json:"throttled"` // 当前正在被限流（网络扫码枪暂停读取或最近一次推送被拒绝）
+	Admitted  uint64     `json:"admitted"`
+	Delayed   uint64     `json:"delayed"`  // 等待额度后才处理的扫码数（网络扫码枪）
+	Rejected  uint64     `json:"rejected"` // 以429拒绝的扫码数（外部推送）
+	LastSeen  time.Time  `json:"last_seen"`
+	Since     *time.Time `json:"throttled_since,omitempty"`
+}
+
+// IngestLimiterStatus 扫码接入限流状态
+type IngestLimiterStatus struct {
+	Enabled      bool                 `json:"enabled"`
+	GlobalTokens float64              `json:"global_tokens"` // 全局剩余额度
+	Reserved     int                  `json:"reserved"`      // 为按键扫码预留的额度
+	Admitted     uint64               `json:"admitted"`
+	Delayed      uint64               `json:"delayed"`
+	Rejected     uint64               `json:"rejected"`
+	Sources      []IngestSourceStatus `json:"sources"`
+}
+
+// ingestSource 一个来源的令牌桶及计数
+type ingestSource struct {
+	bucket tokenBucket
+	status IngestSourceStatus
+}
+
+// IngestLimiter 扫码接入限流
+//
+// 网络扫码枪及外部推送的扫码先取全局额度及本来源的额度，全局额度中保留 reserved 个只供按键扫码使用；
+// 额度不足时外部推送以429拒绝，网络扫码枪暂停读取连接（TCP流控使扫码枪停止发送），入库速度跟不上时
+// 不会在内存中积压。按键扫码（键盘钩子、Raw Input、evdev、钩子代理）无法让操作员重扫，始终放行，
+// 只消耗全局额度使批量来源让出容量。
+type IngestLimiter struct {
+	logger *logrus.Logger
+
+	mu       sync.Mutex
+	config   config.IngestionConfig
+	global   tokenBucket
+	sources  map[string]*ingestSource
+	admitted uint64
+	delayed  uint64
+	rejected uint64
+	pruned   time.Time
+	now      func() time.Time
+}
+
+// NewIngestLimiter 创建扫码接入限流
+func NewIngestLimiter(cfg config.IngestionConfig, logger *logrus.Logger) *IngestLimiter {
+	l := &IngestLimiter{
+		logger:  logger,
+		sources: make(map[string]*ingestSource),
+		now:     time.Now,
+	}
+	l.SetConfig(cfg)
+	return l
+}
+
+// SetConfig 更新限流配置，可在运行中调用（配置热加载），各来源的额度按新配置重新计算
+func (l *IngestLimiter) SetConfig(cfg config.IngestionConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.config = cfg
+	l.global = tokenBucket{rate: cfg.GlobalRate, burst: float64(cfg.GlobalBurst), tokens: float64(cfg.GlobalBurst), last: now}
+	for _, source := range l.sources {
+		source.bucket = tokenBucket{rate: cfg.SourceRate, burst: float64(cfg.SourceBurst), tokens: float64(cfg.SourceBurst), last: now}
+		source.status.Throttled = false
+		source.status.Since = nil
+	}
+}
+
+// Allow 外部推送取 n 条扫码的额度，不足时返回需等待的时间及 false
+//
+// 一次批量推送的条数超过单个来源的突发额度时按突发额度计，避免大批量永远无法通过。
+func (l *IngestLimiter) Allow(source string, n int) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.config.Enable {
+		return 0, true
+	}
+	now := l.now()
+	entry := l.source(source, false, now)
+	wait, ok := l.take(entry, n, now)
+	if ok {
+		entry.status.Admitted += uint64(n)
+		l.admitted += uint64(n)
+		l.release(entry)
+		return 0, true
+	}
+	entry.status.Rejected += uint64(n)
+	l.rejected += uint64(n)
+	if !entry.status.Throttled {
+		l.throttle(entry, now)
+	}
+	return wait, false
+}
+
+// Wait 网络扫码枪取一条扫码的额度，不足时阻塞等待（期间不读取连接）；stop 关闭时返回 false
+func (l *IngestLimiter) Wait(source string, stop <-chan struct{}) bool {
+	delayed := false
+	for {
+		l.mu.Lock()
+		if !l.config.Enable {
+			l.mu.Unlock()
+			return true
+		}
+		now := l.now()
+		entry := l.source(source, false, now)
+		wait, ok := l.take(entry, 1, now)
+		if ok {
+			entry.status.Admitted++
+			l.admitted++
+			l.release(entry)
+			l.mu.Unlock()
+			return true
+		}
+		if !delayed {
+			delayed = true
+			entry.status.Delayed++
+			l.delayed++
+			if !entry.status.Throttled {
+				l.throttle(entry, now)
+			}
+		}
+		l.mu.Unlock()
+
+		if wait > ingestWaitStep {
+			wait = ingestWaitStep
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// Priority 记录一次按键扫码，始终放行，有全局额度时消耗一个（可以使用预留额度）
+func (l *IngestLimiter) Priority(source string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.config.Enable {
+		return
+	}
+	now := l.now()
+	entry := l.source(source, true, now)
+	l.global.refill(now)
+	if l.global.tokens >= 1 {
+		l.global.tokens--
+	}
+	entry.status.Admitted++
+	l.admitted++
+}
+
+// Status 获取限流状态，来源按标识排序
+func (l *IngestLimiter) Status() IngestLimiterStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.global.refill(now)
+	status := IngestLimiterStatus{
+		Enabled:      l.config.Enable,
+		GlobalTokens: math.Floor(l.global.tokens),
+		Reserved:     l.config.Reserved,
+		Admitted:     l.admitted,
+		Delayed:      l.delayed,
+		Rejected:     l.rejected,
+		Sources:      make([]IngestSourceStatus, 0, len(l.sources)),
+	}
+	for _, entry := range l.sources {
+		// 被拒绝的推送方之后不再推送时，额度恢复即视为结束限流
+		if entry.status.Throttled {
+			entry.bucket.refill(now)
+			if entry.bucket.delay(1) == 0 && l.global.delay(1+float64(l.config.Reserved)) == 0 {
+				l.release(entry)
+			}
+		}
+		status.Sources = append(status.Sources, entry.status)
+	}
+	sort.Slice(status.Sources, func(i, j int) bool { return status.Sources[i].Source < status.Sources[j].Source })
+	return status
+}
+
+// source 获取来源的限流状态，不存在时创建；顺便清理长时间空闲的来源
+func (l *IngestLimiter) source(name string, priority bool, now time.Time) *ingestSource {
+	if now.Sub(l.pruned) >= ingestSourceIdle {
+		l.prune(now)
+	}
+	entry, ok := l.sources[name]
+	if !ok {
+		entry = &ingestSource{
+			bucket: tokenBucket{rate: l.config.SourceRate, burst: float64(l.config.SourceBurst), tokens: float64(l.config.SourceBurst), last: now},
+			status: IngestSourceStatus{Source: name, Priority: priority},
+		}
+		l.sources[name] = entry
+	}
+	entry.status.LastSeen = now
+	return entry
+}
+
+// take 从来源及全局额度中取 n 个，批量来源须给按键扫码留下预留额度；任一不足时都不扣减
+func (l *IngestLimiter) take(entry *ingestSource, n int, now time.Time) (time.Duration, bool) {
+	need := math.Min(float64(n), entry.bucket.burst)
+	reserved := float64(l.config.Reserved)
+	entry.bucket.refill(now)
+	l.global.refill(now)
+	wait := entry.bucket.delay(need)
+	if globalWait := l.global.delay(need + reserved); globalWait > wait {
+		wait = globalWait
+	}
+	if wait > 0 {
+		return wait, false
+	}
+	entry.bucket.tokens -= need
+	l.global.tokens -= need
+	return 0, true
+}
+
+// throttle 来源开始被限流
+func (l *IngestLimiter) throttle(entry *ingestSource, now time.Time) {
+	since := now
+	entry.status.Throttled = true
+	entry.status.Since = &since
+	l.logger.WithFields(logrus.Fields{
+		"source":        entry.status.Source,
+		"global_tokens": math.Floor(l.global.tokens),
+		"reserved":      l.config.Reserved,
+	}).Warn("扫码来源超过接入速度限制，开始限流")
+}
+
+// release 来源取得额度后结束限流状态
+func (l *IngestLimiter) release(entry *ingestSource) {
+	if !entry.status.Throttled {
+		return
+	}
+	l.logger.WithFields(logrus.Fields{
+		"source":   entry.status.Source,
+		"delayed":  entry.status.Delayed,
+		"rejected": entry.status.Rejected,
+	}).Info("扫码来源已恢复正常接入")
+	entry.status.Throttled = false
+	entry.status.Since = nil
+}
+
+// prune 清理空闲且额度已满的来源，按键来源始终保留
+func (l *IngestLimiter) prune(now time.Time) {
+	l.pruned = now
+	for name, entry := range l.sources {
+		if entry.status.Priority || now.Sub(entry.status.LastSeen) < ingestSourceIdle {
+			continue
+		}
+		entry.bucket.refill(now)
+		if entry.bucket.tokens >= entry.bucket.burst {
+			delete(l.sources, name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// TestIngestLimiterOverload 以10倍于全局速度的推送持续30分钟（模拟时钟），推送方不断更换来源：
+// 放行数不超过配置的速度，预留额度不被批量来源占用，来源状态及堆内存保持有界
+func TestIngestLimiterOverload(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.IngestionConfig{Enable: true, GlobalRate: 100, GlobalBurst: 100, SourceRate: 20, SourceBurst: 20, Reserved: 10}
+	l := NewIngestLimiter(cfg, logger)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return clock }
+
+	const (
+		duration   = 30 * time.Minute
+		offered    = 10 * 100 // 每秒推送条数，全局速度的10倍
+		perMinute  = 50       // 每分钟新出现的来源数
+		step       = time.Second / offered
+		maxSources = perMinute * int(2*ingestSourceIdle/time.Minute+1)
+	)
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	var admitted, rejected, peak int
+	start := clock
+	for i := 0; clock.Sub(start) < duration; i++ {
+		minute := int(clock.Sub(start) / time.Minute)
+		source := fmt.Sprintf("ingest:10.%d.%d.%d", minute/256, minute%256, i%perMinute)
+		if _, ok := l.Allow(source, 1); ok {
+			admitted++
+		} else {
+			rejected++
+		}
+		if i%offered == 0 {
+			l.Priority("hook")
+		}
+		if n := len(l.sources); n > peak {
+			peak = n
+		}
+		clock = clock.Add(step)
+	}
+
+	runtime.GC()
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+
+	limit := cfg.GlobalBurst + int(cfg.GlobalRate*duration.Seconds())
+	if admitted > limit {
+		t.Errorf("admitted %d scans, more than the global limit %d", admitted, limit)
+	}
+	if total := admitted + rejected; rejected < total*8/10 {
+		t.Errorf("rejected %d of %d scans under 10x overload", rejected, total)
+	}
+	if peak > maxSources {
+		t.Errorf("tracked %d sources, want at most %d", peak, maxSources)
+	}
+	status := l.Status()
+	if status.GlobalTokens < float64(cfg.Reserved)-1 {
+		t.Errorf("bulk sources used the reserved capacity: %v tokens left, reserved %d", status.GlobalTokens, cfg.Reserved)
+	}
+	if status.Admitted != uint64(admitted)+uint64(duration/time.Second) || status.Rejected != uint64(rejected) {
+		t.Errorf("status counts admitted=%d rejected=%d, want %d and %d", status.Admitted, status.Rejected, admitted+int(duration/time.Second), rejected)
+	}
+	if growth := int64(after.HeapAlloc) - int64(before.HeapAlloc); growth > 8<<20 {
+		t.Errorf("heap grew by %d bytes under overload", growth)
+	}
+}
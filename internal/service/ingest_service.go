@@ -0,0 +1,300 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/contract"
+	"userclient/internal/models"
+)
+
+// ErrInvalidIngest 推送的请求体无法解析
+var ErrInvalidIngest = errors.New("推送内容无效")
+
+// IngestMappingError 字段映射失败，Path 为缺失或类型不符的字段路径
+type IngestMappingError struct {
+	Path   string
+	Reason string
+}
+
+// Error 实现 error
+func (e *IngestMappingError) Error() string {
+	return fmt.Sprintf("字段 %s %s", e.Path, e.Reason)
+}
+
+// IngestItem 从推送内容中解析出的一条扫码
+type IngestItem struct {
+	Content   string
+	ScannedAt time.Time // 为零表示使用接收时间
+	Device    string    // 设备序列号或公开ID，为空表示默认设备
+	ScanID    string    // 本系统事件格式中的幂等键
+}
+
+// IngestParsed 单条扫码的解析结果，Err 非nil时 Item 无效
+type IngestParsed struct {
+	Item IngestItem
+	Err  error
+}
+
+// IngestService 外部系统推送扫码结果的解析
+//
+// 请求体为本系统的出站事件格式（OutboxEnvelope，type 为 barcode）时直接取 data 中的字段，
+// 否则按 ingest.mapping 配置的字段路径解析外部JSON。请求体为数组或配置了 items 路径时为批量推送。
+// 本系统格式的请求体按结构定义 contract.SchemaIngest 校验：未配置映射时整个请求体须符合定义，
+// 否则逐条校验其中本系统格式的扫码。
+type IngestService struct {
+	db     *gorm.DB
+	config config.IngestConfig
+	logger *logrus.Logger
+}
+
+// NewIngestService 创建推送解析服务
+func NewIngestService(db *gorm.DB, cfg config.IngestConfig, logger *logrus.Logger) *IngestService {
+	return &IngestService{db: db, config: cfg, logger: logger}
+}
+
+// Parse 解析请求体，batch 表示是否为批量推送；请求体整体无效时返回错误
+func (s *IngestService) Parse(body []byte) (items []IngestParsed, batch bool, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var root interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, false, fmt.Errorf("%w: JSON格式错误: %v", ErrInvalidIngest, err)
+	}
+
+	// 未配置映射时只接受本系统格式，不符合结构定义即整体拒绝
+	if s.config.Mapping.Content == "" {
+		if err := contract.ValidateValue(contract.SchemaIngest, root); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var values []interface{}
+	prefix := ""
+	if path := s.config.Mapping.Items; path != "" && !isEnvelope(root) {
+		value, ok := lookupPath(root, path)
+		if !ok {
+			return nil, false, &IngestMappingError{Path: path, Reason: "不存在"}
+		}
+		if values, ok = value.([]interface{}); !ok {
+			return nil, false, &IngestMappingError{Path: path, Reason: "不是数组"}
+		}
+		batch, prefix = true, contract.Pointer(path)
+	} else if array, ok := root.([]interface{}); ok {
+		values, batch = array, true
+	} else {
+		values = []interface{}{root}
+	}
+
+	if len(values) == 0 {
+		return nil, batch, fmt.Errorf("%w: 没有扫码记录", ErrInvalidIngest)
+	}
+	if len(values) > s.config.MaxBatch {
+		return nil, batch, fmt.Errorf("%w: 单次最多推送 %d 条，实际 %d 条", ErrInvalidIngest, s.config.MaxBatch, len(values))
+	}
+
+	items = make([]IngestParsed, 0, len(values))
+	for i, value := range values {
+		pointer := prefix
+		if batch {
+			pointer += "/" + strconv.Itoa(i)
+		}
+		item, err := s.parseItem(value, pointer)
+		items = append(items, IngestParsed{Item: item, Err: err})
+	}
+	return items, batch, nil
+}
+
+// parseItem 解析单条扫码，pointer 为该条在请求体中的位置
+func (s *IngestService) parseItem(value interface{}, pointer string) (IngestItem, error) {
+	if isEnvelope(value) {
+		if err := contract.ValidateDefinition(contract.SchemaIngest, "scan_event", value, pointer); err != nil {
+			return IngestItem{}, err
+		}
+		return parseEnvelope(value)
+	}
+
+	mapping := s.config.Mapping
+	if mapping.Content == "" {
+		return IngestItem{}, &IngestMappingError{Path: "type", Reason: "缺失，未配置 ingest.mapping 时只接受本系统的事件格式"}
+	}
+
+	var item IngestItem
+	content, err := stringAt(value, mapping.Content, true)
+	if err != nil {
+		return item, err
+	}
+	item.Content = content
+
+	if mapping.Timestamp != "" {
+		raw, ok := lookupPath(value, mapping.Timestamp)
+		if !ok || raw == nil {
+			return item, &IngestMappingError{Path: mapping.Timestamp, Reason: "不存在"}
+		}
+		if item.ScannedAt, err = parseIngestTime(raw, mapping.TimestampFormat); err != nil {
+			return item, &IngestMappingError{Path: mapping.Timestamp, Reason: err.Error()}
+		}
+	}
+	if mapping.Device != "" {
+		if item.Device, err = stringAt(value, mapping.Device, false); err != nil {
+			return item, err
+		}
+	}
+	return item, nil
+}
+
+// isEnvelope 是否为本系统的出站事件格式
+func isEnvelope(value interface{}) bool {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, hasEventID := object["event_id"]
+	_, hasData := object["data"]
+	return hasEventID && hasData
+}
+
+// parseEnvelope 解析本系统的出站事件，data 为扫码记录
+func parseEnvelope(value interface{}) (IngestItem, error) {
+	var item IngestItem
+	if kind, _ := lookupPath(value, "type"); kind != "barcode" {
+		return item, &IngestMappingError{Path: "type", Reason: "须为 barcode"}
+	}
+	content, err := stringAt(value, "data.content", true)
+	if err != nil {
+		return item, err
+	}
+	item.Content = content
+
+	// 扫码时间优先取记录的创建时间
+	for _, path := range []string{"data.created_at", "timestamp"} {
+		if raw, ok := lookupPath(value, path); ok && raw != nil {
+			if item.ScannedAt, err = parseIngestTime(raw, "rfc3339"); err != nil {
+				return item, &IngestMappingError{Path: path, Reason: err.Error()}
+			}
+			break
+		}
+	}
+	if item.ScanID, err = stringAt(value, "data.scan_id", false); err != nil {
+		return item, err
+	}
+	if len(item.ScanID) > 32 {
+		return item, &IngestMappingError{Path: "data.scan_id", Reason: "长度超过32"}
+	}
+	if item.Device, err = stringAt(value, "data.device.serial_no", false); err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+// lookupPath 按点分隔的路径取值，数组下标为数字
+func lookupPath(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// stringAt 取路径上的字符串，数字按原文转换；required 时缺失或为空返回映射错误
+func stringAt(value interface{}, path string, required bool) (string, error) {
+	raw, ok := lookupPath(value, path)
+	if !ok || raw == nil {
+		if required {
+			return "", &IngestMappingError{Path: path, Reason: "不存在"}
+		}
+		return "", nil
+	}
+	var text string
+	switch v := raw.(type) {
+	case string:
+		text = strings.TrimSpace(v)
+	case json.Number:
+		text = v.String()
+	default:
+		return "", &IngestMappingError{Path: path, Reason: "不是字符串或数字"}
+	}
+	if text == "" && required {
+		return "", &IngestMappingError{Path: path, Reason: "为空"}
+	}
+	return text, nil
+}
+
+// parseIngestTime 按格式解析时间：rfc3339 为字符串，unix、unix_ms 为秒或毫秒时间戳
+func parseIngestTime(raw interface{}, format string) (time.Time, error) {
+	switch format {
+	case "unix", "unix_ms":
+		var n int64
+		var err error
+		switch v := raw.(type) {
+		case json.Number:
+			n, err = v.Int64()
+		case string:
+			n, err = strconv.ParseInt(v, 10, 64)
+		default:
+			err = errors.New("类型错误")
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("不是有效的 %s 时间戳", format)
+		}
+		if format == "unix" {
+			return time.Unix(n, 0), nil
+		}
+		return time.UnixMilli(n), nil
+	default:
+		text, ok := raw.(string)
+		if !ok {
+			return time.Time{}, errors.New("不是 RFC3339 格式的字符串")
+		}
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return time.Time{}, errors.New("不是 RFC3339 格式的时间，如 2024-01-01T08:00:00+08:00")
+		}
+		return t, nil
+	}
+}
+
+// ResolveDevice 按序列号或公开ID查找设备
+func (s *IngestService) ResolveDevice(identifier string) (*models.Device, error) {
+	var devices []models.Device
+	err := s.db.Where("serial_no = ? OR public_id = ?", identifier, identifier).Limit(1).Find(&devices).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("%w: 设备 %s 不存在", ErrInvalidIngest, identifier)
+	}
+	return &devices[0], nil
+}
+
+// Exists 该幂等键的扫码是否已入库
+func (s *IngestService) Exists(scanID string) (bool, error) {
+	var count int64
+	if err := s.db.Unscoped().Model(&models.BarcodeRecord{}).Where("scan_id = ?", scanID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+)
+
+// integrityBatchSize 校验时每批读取的记录数
+const integrityBatchSize = 500
+
+// IntegrityBreak 哈希链上第一处断开的位置
+type IntegrityBreak struct {
+	RecordID  uint      `json:"record_id"`
+	PublicID  string    `json:"public_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Reason    string    `json:"reason"`
+	Expected  string    `json:"expected"` // 按链推算出的哈希
+	Actual    string    `json:"actual"`   // 记录中保存的哈希
+}
+
+// IntegrityReport 一次完整性校验的结果
+type IntegrityReport struct {
+	Station    string          `json:"station"`
+	From       time.Time       `json:"from"`
+	To         time.Time       `json:"to"`
+	Checked    int             `json:"checked"`  // 校验的记录数（含软删除的记录）
+	Unsealed   int64           `json:"unsealed"` // 范围内不在哈希链上的记录数（启用前写入、演示数据、主库故障期间写入备份库）
+	Valid      bool            `json:"valid"`
+	Broken     *IntegrityBreak `json:"broken,omitempty"`
+	CheckedAt  time.Time       `json:"checked_at"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// chainVersion 新写入记录的链哈希版本
+//
+// 版本0（升级前写入的记录）按Unix毫秒计入时间，同一毫秒内的扫码时间相同；版本1起按Unix微秒计入，
+// 与入库、推送及输出端的扫码时间精度一致，并把版本号计入哈希。校验时按记录保存的版本计算，旧链无需重算。
+const chainVersion = 1
+
+// chainPayload 参与哈希的记录字段，按固定字段顺序序列化为JSON
+type chainPayload struct {
+	Version   int               `json:"v,omitempty"` // 版本0不输出，与旧版本的序列化结果相同
+	Prev      string            `json:"prev"`
+	Station   string            `json:"station"`
+	PublicID  string            `json:"public_id"`
+	ScanID    string            `json:"scan_id"`
+	Content   string            `json:"content"`
+	Length    int               `json:"length"`
+	Type      string            `json:"type"`
+	Status    string            `json:"status"`
+	Message   string            `json:"message"`
+	DeviceID  *uint             `json:"device_id"`
+	Derived   map[string]string `json:"derived"`
+	Source    string            `json:"source"`
+	Input     string            `json:"input"`
+	CreatedAt int64             `json:"created_at"` // Unix微秒（版本0为毫秒），不受数据库时间精度及时区影响
+}
+
+// IntegrityService 扫码记录完整性哈希链
+//
+// 每条记录保存前一条记录的哈希及 SHA-256(前一条哈希 + 记录字段的规范JSON)，按写入记录的工位各自成链，
+// 链的顺序即记录ID的顺序。写入时在事务中加锁直到事务结束，保证同一进程内链的顺序与ID一致。
+// 软删除不修改参与哈希的字段，校验时包括软删除的记录；批注另行保存，不修改记录。
+type IntegrityService struct {
+	db      *gorm.DB
+	config  config.IntegrityConfig
+	station string
+	logger  *logrus.Logger
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	chainMu sync.Mutex // 写入记录时持有
+	head    string     // 本工位链上最后一条记录的哈希
+	loaded  bool
+
+	mu        sync.Mutex
+	last      *IntegrityReport
+	alerted   uint // 已告警的断开位置，同一处不重复告警
+	listeners []func(AlertEvent)
+}
+
+// NewIntegrityService 创建完整性哈希链服务
+func NewIntegrityService(db *gorm.DB, cfg config.IntegrityConfig, station string, logger *logrus.Logger) *IntegrityService {
+	return &IntegrityService{
+		db:      db,
+		config:  cfg,
+		station: station,
+		logger:  logger,
+		done:    make(chan struct{}),
+	}
+}
+
+// OnAlert 注册自检失败告警的回调
+func (s *IntegrityService) OnAlert(listener func(AlertEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Seal 在写入记录的事务 tx 中为记录填写链字段并加锁，须在事务中写入任何数据之前调用；
+// 返回的函数须在事务结束后调用，committed 为 false 时清除链字段，链首保持不变
+//
+// 写入失败的记录不加入链（如改写到备份库，主库恢复后补写），以免之后的记录与其共用前一条哈希。
+func (s *IntegrityService) Seal(tx *gorm.DB, record *models.BarcodeRecord) (func(committed bool), error) {
+	s.chainMu.Lock()
+	if !s.loaded {
+		head, err := s.loadHead(tx)
+		if err != nil {
+			s.chainMu.Unlock()
+			return nil, err
+		}
+		s.head, s.loaded = head, true
+	}
+
+	// 公开ID及时间参与哈希，须在写入前确定；时间与推送使用同一精度（扫码处理时已截断）
+	if record.PublicID == "" {
+		record.PublicID = models.NewPublicID()
+	}
+	record.CreatedAt = models.ScanTime(record.CreatedAt)
+	record.ChainVersion = chainVersion
+	record.ChainStation = s.station
+	record.ChainPrev = s.head
+	record.ChainHash = chainHash(record)
+
+	return func(committed bool) {
+		if committed {
+			s.head = record.ChainHash
+		} else {
+			record.ChainStation, record.ChainPrev, record.ChainHash, record.ChainVersion = "", "", "", 0
+		}
+		s.chainMu.Unlock()
+	}, nil
+}
+
+// Restart 数据重置后从链首重新开始
+func (s *IntegrityService) Restart() {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+	s.head, s.loaded = "", false
+}
+
+// loadHead 读取本工位链上最后一条记录的哈希，全部记录已清理时取最后的检查点
+func (s *IntegrityService) loadHead(db *gorm.DB) (string, error) {
+	var record models.BarcodeRecord
+	err := db.Unscoped().Select("id", "chain_hash").
+		Where("chain_station = ? AND chain_hash <> ''", s.station).
+		Order("id DESC").Limit(1).Find(&record).Error
+	if err != nil {
+		return "", fmt.Errorf("读取完整性哈希链失败: %w", err)
+	}
+	var checkpoint models.IntegrityCheckpoint
+	if err := db.Where("station = ?", s.station).Order("record_id DESC").Limit(1).Find(&checkpoint).Error; err != nil {
+		return "", fmt.Errorf("读取完整性检查点失败: %w", err)
+	}
+	if checkpoint.ID > 0 && checkpoint.RecordID > record.ID {
+		return checkpoint.Hash, nil
+	}
+	return record.ChainHash, nil
+}
+
+// Anchor 保留期清理软删除 before 之前的记录后，为被删除部分在链上的每一段末尾写入检查点
+//
+// 段末尾指链上后一条记录未被删除（或尚未写入）的已删除记录，之后物理删除这些记录时，
+// 后一条记录及新写入的记录接在检查点上，剩余的链仍可校验。须与软删除在同一事务中调用。
+func (s *IntegrityService) Anchor(tx *gorm.DB, before time.Time, reason string) error {
+	var tails []models.BarcodeRecord
+	if err := tx.Unscoped().Select("id", "chain_station", "chain_hash").
+		Where("deleted_at IS NOT NULL AND chain_hash <> '' AND created_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM barcode_records AS n WHERE n.chain_prev = barcode_records.chain_hash AND n.deleted_at IS NOT NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM integrity_checkpoints AS c WHERE c.station = barcode_records.chain_station AND c.record_id = barcode_records.id)").
+		Order("id").Find(&tails).Error; err != nil {
+		return fmt.Errorf("查询完整性哈希链失败: %w", err)
+	}
+	for _, tail := range tails {
+		checkpoint := &models.IntegrityCheckpoint{Station: tail.ChainStation, RecordID: tail.ID, Hash: tail.ChainHash, Reason: reason}
+		if err := tx.Create(checkpoint).Error; err != nil {
+			return fmt.Errorf("写入完整性检查点失败: %w", err)
+		}
+	}
+	if len(tails) > 0 {
+		s.logger.WithFields(logrus.Fields{"checkpoints": len(tails), "reason": reason}).Info("已写入完整性检查点")
+	}
+	return nil
+}
+
+// Verify 校验本工位在 [from, to) 内写入的记录，返回第一处断开的位置
+//
+// 从范围内第一条记录在链上的前一条（或其后的检查点）开始，按ID顺序逐条重算哈希：
+// 记录保存的前一条哈希与链不符说明之前的记录被删除或修改，重算的哈希与保存的不符说明记录入库后被修改。
+func (s *IntegrityService) Verify(from, to time.Time) (*IntegrityReport, error) {
+	started := time.Now()
+	report := &IntegrityReport{Station: s.station, From: from, To: to, Valid: true, CheckedAt: started}
+	defer func() { report.DurationMS = time.Since(started).Milliseconds() }()
+
+	if err := s.db.Unscoped().Model(&models.BarcodeRecord{}).
+		Where("(chain_hash IS NULL OR chain_hash = '') AND created_at >= ? AND created_at < ?", from, to).
+		Count(&report.Unsealed).Error; err != nil {
+		return nil, fmt.Errorf("统计未加入哈希链的记录失败: %w", err)
+	}
+
+	var bounds struct{ First, Last *uint } // 范围内没有记录时为NULL
+	if err := s.db.Unscoped().Model(&models.BarcodeRecord{}).
+		Select("MIN(id) AS first, MAX(id) AS last").
+		Where("chain_station = ? AND chain_hash <> '' AND created_at >= ? AND created_at < ?", s.station, from, to).
+		Scan(&bounds).Error; err != nil {
+		return nil, fmt.Errorf("查询完整性哈希链失败: %w", err)
+	}
+	if bounds.First == nil {
+		return report, nil
+	}
+	first, last := *bounds.First, *bounds.Last
+
+	prev, err := s.prevHash(first)
+	if err != nil {
+		return nil, err
+	}
+	// 保留期清理时尚未确认消费的记录不删除，清理后链上会有缺口，缺口之后的记录接在检查点上
+	var checkpoints []models.IntegrityCheckpoint
+	if err := s.db.Where("station = ? AND record_id > ? AND record_id < ?", s.station, first, last).
+		Order("record_id").Find(&checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("读取完整性检查点失败: %w", err)
+	}
+
+	var records []models.BarcodeRecord
+	cursor := first - 1
+	for cursor < last {
+		records = records[:0]
+		if err := s.db.Unscoped().
+			Where("chain_station = ? AND chain_hash <> '' AND id > ? AND id <= ?", s.station, cursor, last).
+			Order("id").Limit(integrityBatchSize).Find(&records).Error; err != nil {
+			return nil, fmt.Errorf("读取扫码记录失败: %w", err)
+		}
+		if len(records) == 0 {
+			break
+		}
+		for i := range records {
+			record := &records[i]
+			for len(checkpoints) > 0 && checkpoints[0].RecordID < record.ID {
+				if checkpoints[0].RecordID > cursor {
+					prev = checkpoints[0].Hash
+				}
+				checkpoints = checkpoints[1:]
+			}
+			if broken := checkLink(record, prev); broken != nil {
+				report.Valid = false
+				report.Broken = broken
+				return report, nil
+			}
+			prev = record.ChainHash
+			cursor = record.ID
+			report.Checked++
+		}
+	}
+	return report, nil
+}
+
+// prevHash 链上 id 之前一条记录的哈希；该记录之后有检查点（之前的记录已清理）时取检查点，链首为空
+func (s *IntegrityService) prevHash(id uint) (string, error) {
+	var record models.BarcodeRecord
+	if err := s.db.Unscoped().Select("id", "chain_hash").
+		Where("chain_station = ? AND chain_hash <> '' AND id < ?", s.station, id).
+		Order("id DESC").Limit(1).Find(&record).Error; err != nil {
+		return "", fmt.Errorf("读取完整性哈希链失败: %w", err)
+	}
+	var checkpoint models.IntegrityCheckpoint
+	if err := s.db.Where("station = ? AND record_id < ?", s.station, id).
+		Order("record_id DESC").Limit(1).Find(&checkpoint).Error; err != nil {
+		return "", fmt.Errorf("读取完整性检查点失败: %w", err)
+	}
+	if checkpoint.ID > 0 && checkpoint.RecordID > record.ID {
+		return checkpoint.Hash, nil
+	}
+	return record.ChainHash, nil
+}
+
+// checkLink 校验一条记录与链上前一条哈希的衔接，通过时返回nil
+func checkLink(record *models.BarcodeRecord, prev string) *IntegrityBreak {
+	broken := &IntegrityBreak{RecordID: record.ID, PublicID: record.PublicID, CreatedAt: record.CreatedAt}
+	if record.ChainPrev != prev {
+		broken.Reason = "前一条记录哈希不符，之前的记录被删除、插入或修改"
+		broken.Expected, broken.Actual = prev, record.ChainPrev
+		return broken
+	}
+	if hash := chainHash(record); hash != record.ChainHash {
+		broken.Reason = "记录内容与哈希不符，入库后被修改"
+		broken.Expected, broken.Actual = hash, record.ChainHash
+		return broken
+	}
+	return nil
+}
+
+// chainHash 按记录保存的版本计算链哈希，record.ChainPrev 为前一条记录的哈希
+func chainHash(record *models.BarcodeRecord) string {
+	payload := chainPayload{
+		Version:   record.ChainVersion,
+		Prev:      record.ChainPrev,
+		Station:   record.ChainStation,
+		PublicID:  record.PublicID,
+		ScanID:    record.ScanID,
+		Content:   record.Content,
+		Length:    record.Length,
+		Type:      record.Type,
+		Status:    record.Status,
+		Message:   record.Message,
+		DeviceID:  record.DeviceID,
+		Source:    record.Source,
+		Input:     record.Input,
+		CreatedAt: record.CreatedAt.UnixMicro(),
+	}
+	if record.ChainVersion == 0 {
+		payload.CreatedAt = record.CreatedAt.UnixMilli()
+	}
+	// 空的派生字段读回时可能为nil或空映射，统一为nil
+	if len(record.Derived) > 0 {
+		payload.Derived = record.Derived
+	}
+	data, _ := json.Marshal(payload)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Start 启动定期自检，check_interval 为0时不自检
+func (s *IntegrityService) Start() {
+	if s.config.CheckInterval <= 0 {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.CheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.selfCheck(now)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定期自检
+func (s *IntegrityService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// selfCheck 校验最近 check_window 内的记录，发现断开时告警，同一处断开只告警一次
+func (s *IntegrityService) selfCheck(now time.Time) {
+	report, err := s.Verify(now.Add(-s.config.CheckWindow), now)
+	if err != nil {
+		s.logger.WithError(err).Error("完整性自检失败")
+		return
+	}
+
+	s.mu.Lock()
+	s.last = report
+	var alert *AlertEvent
+	switch {
+	case report.Broken != nil && report.Broken.RecordID != s.alerted:
+		s.alerted = report.Broken.RecordID
+		alert = &AlertEvent{
+			Source:  "integrity",
+			Level:   "warning",
+			Message: i18n.Sprintf("alert.integrity_failed", report.Broken.RecordID, report.Broken.Reason),
+		}
+	case report.Broken == nil:
+		s.alerted = 0
+	}
+	listeners := make([]func(AlertEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.Unlock()
+
+	if alert == nil {
+		s.logger.WithFields(logrus.Fields{"checked": report.Checked, "unsealed": report.Unsealed, "valid": report.Valid}).Debug("完整性自检完成")
+		return
+	}
+	s.logger.WithField("broken", report.Broken).Error(alert.Message)
+	for _, listener := range listeners {
+		listener(*alert)
+	}
+}
+
+// LastReport 最近一次自检的结果，尚未自检时返回nil
+func (s *IntegrityService) LastReport() *IntegrityReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// newTestIntegrity 创建使用临时数据库的完整性哈希链服务，不启动定期自检
+func newTestIntegrity(t *testing.T) (*IntegrityService, *database.DB) {
+	t.Helper()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "integrity.db"))
+	t.Cleanup(func() { db.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewIntegrityService(db.DB, config.IntegrityConfig{Enable: true}, "station-1", logger), db
+}
+
+// sealRecord 与扫码入库相同：加入哈希链并在事务中写入，事务结束后释放链
+func sealRecord(t *testing.T, s *IntegrityService, db *database.DB, content string, at time.Time) *models.BarcodeRecord {
+	t.Helper()
+	record := &models.BarcodeRecord{Content: content, Length: len(content), Type: "code128", Status: "valid", Source: models.BarcodeSourceLocal, CreatedAt: at}
+	var seal func(committed bool)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if seal, err = s.Seal(tx, record); err != nil {
+			return err
+		}
+		return tx.Create(record).Error
+	})
+	if seal != nil {
+		seal(err == nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func verifyAll(t *testing.T, s *IntegrityService) *IntegrityReport {
+	t.Helper()
+	report, err := s.Verify(time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// TestIntegritySameMillisecond 同一毫秒内的扫码按微秒计入哈希，修改同一毫秒内的时间也能校验出来
+func TestIntegritySameMillisecond(t *testing.T) {
+	s, db := newTestIntegrity(t)
+	base := time.Now().Truncate(time.Millisecond).Add(-time.Minute)
+	first := sealRecord(t, s, db, "A001", base.Add(100*time.Microsecond))
+	second := sealRecord(t, s, db, "A002", base.Add(101*time.Microsecond))
+
+	if !second.CreatedAt.Equal(base.Add(101 * time.Microsecond)) {
+		t.Fatalf("写入的扫码时间 = %v，应保留微秒 %v", second.CreatedAt, base.Add(101*time.Microsecond))
+	}
+	if first.ChainVersion != chainVersion || second.ChainVersion != chainVersion {
+		t.Fatalf("链哈希版本 = %d/%d，应为 %d", first.ChainVersion, second.ChainVersion, chainVersion)
+	}
+	if report := verifyAll(t, s); !report.Valid || report.Checked != 2 {
+		t.Fatalf("校验结果 = %+v，应通过且校验2条", report)
+	}
+
+	// 改动1微秒，仍在同一毫秒内
+	if err := db.Model(first).UpdateColumn("created_at", base.Add(102*time.Microsecond)).Error; err != nil {
+		t.Fatal(err)
+	}
+	report := verifyAll(t, s)
+	if report.Valid || report.Broken == nil || report.Broken.RecordID != first.ID {
+		t.Fatalf("校验结果 = %+v，应在记录 %d 处断开", report, first.ID)
+	}
+	if report.Broken.Reason != "记录内容与哈希不符，入库后被修改" {
+		t.Fatalf("断开原因 = %q", report.Broken.Reason)
+	}
+}
+
+// TestIntegrityLegacyChain 升级前按毫秒计算的链（版本0）无需重算，新记录接在其后仍可校验
+func TestIntegrityLegacyChain(t *testing.T) {
+	s, db := newTestIntegrity(t)
+	at := time.Now().Add(-time.Minute)
+	legacy := &models.BarcodeRecord{
+		PublicID: models.NewPublicID(), Content: "OLD1", Length: 4, Type: "code128", Status: "valid", Source: models.BarcodeSourceLocal,
+		CreatedAt: models.ScanTime(at), ChainStation: "station-1",
+	}
+	legacy.ChainHash = chainHash(legacy)
+	if err := db.Create(legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	next := sealRecord(t, s, db, "NEW1", at.Add(time.Second))
+	if next.ChainPrev != legacy.ChainHash {
+		t.Fatalf("新记录的前一条哈希 = %q，应接在旧链 %q 后", next.ChainPrev, legacy.ChainHash)
+	}
+	if report := verifyAll(t, s); !report.Valid || report.Checked != 2 {
+		t.Fatalf("校验结果 = %+v，旧链与新记录应一起通过", report)
+	}
+
+	// 旧记录按毫秒计入，版本号被改为1时哈希不符
+	if err := db.Model(legacy).UpdateColumn("chain_version", chainVersion).Error; err != nil {
+		t.Fatal(err)
+	}
+	if report := verifyAll(t, s); report.Valid || report.Broken.RecordID != legacy.ID {
+		t.Fatalf("校验结果 = %+v，修改链哈希版本应被校验出来", report)
+	}
+}
+
+// TestIntegrityTamper 入库后修改、删除或调换记录都在被改动的位置校验出来，软删除不影响校验
+func TestIntegrityTamper(t *testing.T) {
+	const (
+		reasonLink    = "前一条记录哈希不符，之前的记录被删除、插入或修改"
+		reasonContent = "记录内容与哈希不符，入库后被修改"
+	)
+	tests := []struct {
+		name   string
+		tamper func(db *gorm.DB, records []*models.BarcodeRecord) error
+		broken int // 断开位置在 records 中的下标，-1表示校验通过
+		reason string
+	}{
+		{
+			name:   "未修改",
+			tamper: func(*gorm.DB, []*models.BarcodeRecord) error { return nil },
+			broken: -1,
+		},
+		{
+			name: "软删除",
+			tamper: func(db *gorm.DB, records []*models.BarcodeRecord) error {
+				return db.Delete(records[1]).Error
+			},
+			broken: -1,
+		},
+		{
+			name: "修改内容",
+			tamper: func(db *gorm.DB, records []*models.BarcodeRecord) error {
+				return db.Model(records[2]).UpdateColumn("content", "C999").Error
+			},
+			broken: 2,
+			reason: reasonContent,
+		},
+		{
+			name: "修改状态",
+			tamper: func(db *gorm.DB, records []*models.BarcodeRecord) error {
+				return db.Model(records[1]).UpdateColumn("status", "error").Error
+			},
+			broken: 1,
+			reason: reasonContent,
+		},
+		{
+			name: "物理删除",
+			tamper: func(db *gorm.DB, records []*models.BarcodeRecord) error {
+				return db.Unscoped().Delete(records[1]).Error
+			},
+			broken: 2,
+			reason: reasonLink,
+		},
+		{
+			name: "调换顺序",
+			tamper: func(db *gorm.DB, records []*models.BarcodeRecord) error {
+				// 交换两条记录的ID，原第二条的位置上是原第三条，链的顺序与ID不再一致
+				a, b := records[1].ID, records[2].ID
+				return db.Transaction(func(tx *gorm.DB) error {
+					for _, swap := range [][2]uint{{a, 0}, {b, a}, {0, b}} {
+						if err := tx.Exec("UPDATE barcode_records SET id = ? WHERE id = ?", swap[1], swap[0]).Error; err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+			},
+			broken: 1,
+			reason: reasonLink,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, db := newTestIntegrity(t)
+			at := time.Now().Add(-time.Minute)
+			var records []*models.BarcodeRecord
+			for i, content := range []string{"C001", "C002", "C003", "C004"} {
+				records = append(records, sealRecord(t, s, db, content, at.Add(time.Duration(i)*time.Second)))
+			}
+			if err := tt.tamper(db.DB, records); err != nil {
+				t.Fatal(err)
+			}
+
+			report := verifyAll(t, s)
+			if tt.broken < 0 {
+				if !report.Valid {
+					t.Fatalf("校验结果 = %+v，应通过", report.Broken)
+				}
+				return
+			}
+			if report.Valid {
+				t.Fatal("改动未被校验出来")
+			}
+			if report.Broken.RecordID != records[tt.broken].ID || report.Broken.Reason != tt.reason {
+				t.Fatalf("断开位置 = %d（%s），want %d（%s）", report.Broken.RecordID, report.Broken.Reason, records[tt.broken].ID, tt.reason)
+			}
+		})
+	}
+}
+
+// TestIntegritySealRollback 写入失败的事务不前移链首，之后的记录接在最后一条已提交的记录上
+func TestIntegritySealRollback(t *testing.T) {
+	s, db := newTestIntegrity(t)
+	at := time.Now().Add(-time.Minute)
+	first := sealRecord(t, s, db, "R001", at)
+
+	failed := &models.BarcodeRecord{Content: "R002", Length: 4, Source: models.BarcodeSourceLocal, CreatedAt: at.Add(time.Second)}
+	var seal func(committed bool)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if seal, err = s.Seal(tx, failed); err != nil {
+			return err
+		}
+		if err := tx.Create(failed).Error; err != nil {
+			return err
+		}
+		return errors.New("模拟写入投递队列失败")
+	})
+	seal(err == nil)
+	if err == nil {
+		t.Fatal("事务应失败")
+	}
+	if failed.ChainHash != "" || failed.ChainPrev != "" || failed.ChainVersion != 0 {
+		t.Fatalf("回滚的记录仍有链字段: %+v", failed)
+	}
+
+	next := sealRecord(t, s, db, "R003", at.Add(2*time.Second))
+	if next.ChainPrev != first.ChainHash {
+		t.Fatalf("回滚后新记录的前一条哈希 = %q，want %q", next.ChainPrev, first.ChainHash)
+	}
+	if report := verifyAll(t, s); !report.Valid || report.Checked != 2 {
+		t.Fatalf("校验结果 = %+v，回滚的记录不应留在链上", report)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+)
+
+// KeyTiming 键盘钩子上报的单个按键时间信息
+type KeyTiming struct {
+	Tick     uint32 // KBDLLHOOKSTRUCT.Time，系统启动后的毫秒数，0表示未知
+	Injected bool   // Flags 中带注入标记（LLKHF_INJECTED/LLKHF_LOWER_IL_INJECTED）
+}
+
+// KeyIntervals 单次扫码的按键间隔统计，反映扫码枪实际的输出节奏，可据此调整 timeout_ms
+type KeyIntervals struct {
+	Keys     int
+	Min      time.Duration // 最小按键间隔
+	Max      time.Duration // 最大按键间隔
+	Avg      time.Duration // 平均按键间隔
+	Median   time.Duration // 除最大间隔外其余按键间隔的中位数
+	Duration time.Duration // 首个按键到最后一个按键
+}
+
+// ScanTiming 单次扫码的按键时序分析结果
+type ScanTiming struct {
+	KeyIntervals
+	Injected int  // 带注入标记的按键数
+	Suspect  bool // 疑似漏键，条码可能被截断
+	Reason   string
+}
+
+// KeyTimingStats 按键时序诊断统计
+type KeyTimingStats struct {
+	Scans             int64      `json:"scans"`               // 带按键时间的扫码数
+	SuspectScans      int64      `json:"suspect_scans"`       // 疑似截断的扫码数
+	InjectedScans     int64      `json:"injected_scans"`      // 含注入按键的扫码数
+	LargestGapMS      int64      `json:"largest_gap_ms"`      // 单次扫码内出现过的最大按键间隔
+	SuspectGapMS      int        `json:"suspect_gap_ms"`      // 判定漏键的按键间隔阈值
+	WindowScans       int        `json:"window_scans"`        // 最近窗口内的扫码数
+	WindowSuspects    int        `json:"window_suspects"`     // 最近窗口内疑似截断的扫码数
+	SuspectRate       float64    `json:"suspect_rate"`        // 最近窗口内的疑似截断比例
+	AlertRate         float64    `json:"alert_rate"`          // 告警阈值，0表示不告警
+	Alerting          bool       `json:"alerting"`            // 疑似截断比例是否超过告警阈值
+	LastSuspectAt     *time.Time `json:"last_suspect_at"`     // 最近一次疑似截断的时间
+	LastSuspectReason string     `json:"last_suspect_reason"` // 最近一次疑似截断的原因
+}
+
+// KeyTimingMonitor 键盘钩子按键时序监控
+//
+// CPU 占用过高时低级键盘钩子可能超时，系统会跳过钩子直接投递按键，表现为扫码内部出现
+// 远大于扫码枪正常节奏的按键间隔。按 KBDLLHOOKSTRUCT.Time 计算间隔，
+// 在整体很快的扫码中出现超过阈值的间隔，或按键带注入标记时视为疑似截断。
+type KeyTimingMonitor struct {
+	config config.ScannerConfig
+	logger *logrus.Logger
+
+	mu        sync.Mutex
+	stats     KeyTimingStats
+	window    []bool // 最近扫码是否疑似截断，环形缓冲
+	next      int
+	alerting  bool
+	listeners []func(deviceID *uint, event AlertEvent)
+}
+
+// NewKeyTimingMonitor 创建按键时序监控
+func NewKeyTimingMonitor(cfg config.ScannerConfig, logger *logrus.Logger) *KeyTimingMonitor {
+	if cfg.SuspectGapMS <= 0 {
+		cfg.SuspectGapMS = 50
+	}
+	if cfg.SuspectAlertWindow <= 0 {
+		cfg.SuspectAlertWindow = 100
+	}
+	return &KeyTimingMonitor{
+		config: cfg,
+		logger: logger,
+		window: make([]bool, 0, cfg.SuspectAlertWindow),
+		stats: KeyTimingStats{
+			SuspectGapMS: cfg.SuspectGapMS,
+			AlertRate:    cfg.SuspectAlertRate,
+		},
+	}
+}
+
+// OnAlert 注册疑似截断比例告警回调
+func (m *KeyTimingMonitor) OnAlert(listener func(deviceID *uint, event AlertEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// Observe 分析一次扫码的按键时序并计入统计，没有按键时间时返回nil
+func (m *KeyTimingMonitor) Observe(content string, timings []KeyTiming) *ScanTiming {
+	timing := AnalyzeKeyTimings(timings, time.Duration(m.config.SuspectGapMS)*time.Millisecond)
+	if timing == nil {
+		return nil
+	}
+	if timing.Suspect {
+		m.logger.WithField("barcode", content).WithField("reason", timing.Reason).Warn("扫码按键时序异常，条码可能被截断")
+	}
+
+	m.mu.Lock()
+	m.stats.Scans++
+	if timing.Suspect {
+		now := time.Now()
+		m.stats.SuspectScans++
+		m.stats.LastSuspectAt = &now
+		m.stats.LastSuspectReason = timing.Reason
+	}
+	if timing.Injected > 0 {
+		m.stats.InjectedScans++
+	}
+	if gap := timing.Max.Milliseconds(); gap > m.stats.LargestGapMS {
+		m.stats.LargestGapMS = gap
+	}
+
+	if len(m.window) < cap(m.window) {
+		m.window = append(m.window, timing.Suspect)
+	} else {
+		m.window[m.next] = timing.Suspect
+		m.next = (m.next + 1) % len(m.window)
+	}
+
+	var alert *AlertEvent
+	if m.config.SuspectAlertRate > 0 && len(m.window) >= cap(m.window)/2 {
+		rate, _ := m.windowRate()
+		switch {
+		case !m.alerting && rate >= m.config.SuspectAlertRate:
+			m.alerting = true
+			alert = &AlertEvent{
+				Source:  "key_timing",
+				Level:   "warning",
+				Message: i18n.Sprintf("alert.key_timing_suspect", len(m.window), rate*100),
+			}
+		case m.alerting && rate < m.config.SuspectAlertRate/2:
+			// 比例回落到阈值一半以下才重新告警，避免在阈值附近反复告警
+			m.alerting = false
+		}
+	}
+	listeners := make([]func(*uint, AlertEvent), len(m.listeners))
+	copy(listeners, m.listeners)
+	m.mu.Unlock()
+
+	if alert != nil {
+		m.logger.Warn(alert.Message)
+		for _, listener := range listeners {
+			listener(nil, *alert)
+		}
+	}
+	return timing
+}
+
+// Stats 获取按键时序诊断统计
+func (m *KeyTimingMonitor) Stats() KeyTimingStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	stats.SuspectRate, stats.WindowSuspects = m.windowRate()
+	stats.WindowScans = len(m.window)
+	stats.Alerting = m.alerting
+	return stats
+}
+
+// windowRate 最近窗口内的疑似截断比例及次数，调用方需持有锁
+func (m *KeyTimingMonitor) windowRate() (float64, int) {
+	if len(m.window) == 0 {
+		return 0, 0
+	}
+	suspects := 0
+	for _, suspect := range m.window {
+		if suspect {
+			suspects++
+		}
+	}
+	return float64(suspects) / float64(len(m.window)), suspects
+}
+
+// MeasureKeyIntervals 计算按键间隔统计，缺少按键时间时返回 false；只有一个按键时各间隔为0
+func MeasureKeyIntervals(timings []KeyTiming) (KeyIntervals, bool) {
+	intervals := KeyIntervals{Keys: len(timings)}
+	if len(timings) == 0 {
+		return intervals, false
+	}
+	for _, key := range timings {
+		if key.Tick == 0 {
+			return intervals, false
+		}
+	}
+	if len(timings) == 1 {
+		return intervals, true
+	}
+
+	gaps := make([]time.Duration, 0, len(timings)-1)
+	for i := 1; i < len(timings); i++ {
+		// 按无符号差值计算，系统运行约49.7天后计数回绕不影响结果
+		gap := time.Duration(timings[i].Tick-timings[i-1].Tick) * time.Millisecond
+		gaps = append(gaps, gap)
+		intervals.Duration += gap
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	intervals.Min = gaps[0]
+	intervals.Max = gaps[len(gaps)-1]
+	intervals.Avg = intervals.Duration / time.Duration(len(gaps))
+	if rest := len(gaps) - 1; rest > 0 {
+		intervals.Median = gaps[rest/2]
+	}
+	return intervals, true
+}
+
+// AnalyzeKeyTimings 分析按键时序，缺少按键时间时返回nil
+//
+// 存在带注入标记的按键，或最大按键间隔超过阈值而其余间隔的中位数不超过阈值一半时判定为疑似截断。
+func AnalyzeKeyTimings(timings []KeyTiming, threshold time.Duration) *ScanTiming {
+	intervals, ok := MeasureKeyIntervals(timings)
+	if !ok {
+		return nil
+	}
+	timing := &ScanTiming{KeyIntervals: intervals}
+	for _, key := range timings {
+		if key.Injected {
+			timing.Injected++
+		}
+	}
+
+	switch {
+	case timing.Injected > 0:
+		timing.Suspect = true
+		timing.Reason = fmt.Sprintf("%d 个按键带注入标记", timing.Injected)
+	case timing.Keys >= 3 && timing.Max > threshold && timing.Median*2 <= threshold:
+		timing.Suspect = true
+		timing.Reason = fmt.Sprintf("按键间隔 %dms 超过阈值 %dms（中位数 %dms）",
+			timing.Max.Milliseconds(), threshold.Milliseconds(), timing.Median.Milliseconds())
+	}
+	return timing
+}
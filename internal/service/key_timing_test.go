@@ -0,0 +1,144 @@
+package service
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// keyTicks 从 start 开始依次间隔 gaps 毫秒的按键时间
+func keyTicks(start uint32, gaps ...uint32) []KeyTiming {
+	timings := []KeyTiming{{Tick: start}}
+	for _, gap := range gaps {
+		start += gap
+		timings = append(timings, KeyTiming{Tick: start})
+	}
+	return timings
+}
+
+func TestMeasureKeyIntervals(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		timings []KeyTiming
+		ok      bool
+		want    KeyIntervals
+	}{
+		{name: "no keys", ok: false},
+		{name: "unknown tick", timings: []KeyTiming{{Tick: 100}, {Tick: 0}, {Tick: 120}}, ok: false, want: KeyIntervals{Keys: 3}},
+		{name: "single key", timings: keyTicks(100), ok: true, want: KeyIntervals{Keys: 1}},
+		{name: "two keys", timings: keyTicks(100, 8), ok: true, want: KeyIntervals{Keys: 2, Min: 8 * ms, Max: 8 * ms, Avg: 8 * ms, Duration: 8 * ms}},
+		{
+			name:    "steady scanner",
+			timings: keyTicks(1000, 4, 4, 4, 4),
+			ok:      true,
+			want:    KeyIntervals{Keys: 5, Min: 4 * ms, Max: 4 * ms, Avg: 4 * ms, Median: 4 * ms, Duration: 16 * ms},
+		},
+		{
+			// 中位数不含最大间隔：其余间隔 2,3,5 的中位数为 3
+			name:    "one long gap",
+			timings: keyTicks(1000, 5, 2, 90, 3),
+			ok:      true,
+			want:    KeyIntervals{Keys: 5, Min: 2 * ms, Max: 90 * ms, Avg: 25 * ms, Median: 3 * ms, Duration: 100 * ms},
+		},
+		{
+			// 系统启动约49.7天后计数回绕
+			name:    "tick wraparound",
+			timings: keyTicks(^uint32(0)-5, 4, 4, 4),
+			ok:      true,
+			want:    KeyIntervals{Keys: 4, Min: 4 * ms, Max: 4 * ms, Avg: 4 * ms, Median: 4 * ms, Duration: 12 * ms},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := MeasureKeyIntervals(tt.timings)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("MeasureKeyIntervals = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAnalyzeKeyTimings(t *testing.T) {
+	threshold := 50 * time.Millisecond
+	tests := []struct {
+		name     string
+		timings  []KeyTiming
+		suspect  bool
+		injected int
+	}{
+		{name: "steady scanner", timings: keyTicks(1000, 4, 5, 4, 6, 4)},
+		{name: "gap in fast scan", timings: keyTicks(1000, 4, 5, 120, 4, 6), suspect: true},
+		// 整体较慢（人工输入）的间隔不判定为截断
+		{name: "slow typing", timings: keyTicks(1000, 40, 120, 60, 80)},
+		{name: "gap with two keys", timings: keyTicks(1000, 120)},
+		{name: "gap at threshold", timings: keyTicks(1000, 4, 50, 4)},
+		{
+			name:     "injected keys",
+			timings:  []KeyTiming{{Tick: 1000}, {Tick: 1004, Injected: true}, {Tick: 1008, Injected: true}},
+			suspect:  true,
+			injected: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timing := AnalyzeKeyTimings(tt.timings, threshold)
+			if timing == nil {
+				t.Fatal("timing = nil")
+			}
+			if timing.Suspect != tt.suspect || timing.Injected != tt.injected || (timing.Reason != "") != tt.suspect {
+				t.Fatalf("timing = %+v, want suspect %v with %d injected keys", timing, tt.suspect, tt.injected)
+			}
+		})
+	}
+	if timing := AnalyzeKeyTimings([]KeyTiming{{Tick: 0}}, threshold); timing != nil {
+		t.Fatalf("unknown ticks analyzed: %+v", timing)
+	}
+}
+
+// TestKeyTimingMonitorAlert 最近窗口内疑似截断比例达到阈值时告警一次，回落到阈值一半以下后才再次告警
+func TestKeyTimingMonitorAlert(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	monitor := NewKeyTimingMonitor(config.ScannerConfig{SuspectGapMS: 50, SuspectAlertRate: 0.5, SuspectAlertWindow: 4}, logger)
+	var alerts []AlertEvent
+	monitor.OnAlert(func(deviceID *uint, event AlertEvent) { alerts = append(alerts, event) })
+
+	good := keyTicks(1000, 4, 4, 4)
+	bad := keyTicks(1000, 4, 200, 4)
+	if timing := monitor.Observe("A", nil); timing != nil {
+		t.Fatalf("scan without ticks counted: %+v", timing)
+	}
+	for i, timings := range [][]KeyTiming{bad, good, bad, bad, bad} {
+		monitor.Observe("A", timings)
+		if i == 2 && len(alerts) != 1 {
+			t.Fatalf("alerts after reaching the rate = %d, want 1", len(alerts))
+		}
+	}
+	if len(alerts) != 1 || alerts[0].Source != "key_timing" {
+		t.Fatalf("alerts = %+v, want one key_timing alert", alerts)
+	}
+
+	stats := monitor.Stats()
+	if stats.Scans != 5 || stats.SuspectScans != 4 || stats.WindowScans != 4 || stats.WindowSuspects != 3 ||
+		!stats.Alerting || stats.LargestGapMS != 200 || stats.LastSuspectAt == nil {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	// 回落到阈值一半以下后解除，再次达到阈值时重新告警
+	for i := 0; i < 4; i++ {
+		monitor.Observe("A", good)
+	}
+	if monitor.Stats().Alerting {
+		t.Fatal("alert not cleared")
+	}
+	monitor.Observe("A", bad)
+	monitor.Observe("A", bad)
+	if len(alerts) != 2 {
+		t.Fatalf("alerts = %d, want a second alert", len(alerts))
+	}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maintenanceStateKey 维护模式状态在配置表中的键
+const maintenanceStateKey = "maintenance.state"
+
+// ignoredSaveDelay 维护期间被忽略的扫码计数延迟合并保存，避免每次扫码都写一次配置表
+const ignoredSaveDelay = 5 * time.Second
+
+// MaintenanceState 维护模式状态
+type MaintenanceState struct {
+	Active       bool       `json:"active"`
+	Reason       string     `json:"reason,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	IgnoredScans int64      `json:"ignored_scans"`
+}
+
+// MaintenanceEvent 维护模式状态变更事件
+type MaintenanceEvent struct {
+	Action string           `json:"action"` // start, end
+	State  MaintenanceState `json:"state"`
+}
+
+// MaintenanceService 维护模式服务
+type MaintenanceService struct {
+	configService *ConfigService
+	logger        *logrus.Logger
+	mu            sync.RWMutex
+	state         MaintenanceState
+	generation    uint64 // 状态每次变更加一
+	timer         *time.Timer
+	pending       *time.Timer // 尚未保存的忽略扫码计数
+	listeners     []func(MaintenanceEvent)
+
+	saveMu sync.Mutex // 保存时持有，保证按变更顺序写入
+	saved  uint64     // 已保存状态的 generation
+}
+
+// NewMaintenanceService 创建维护模式服务
+func NewMaintenanceService(configService *ConfigService, logger *logrus.Logger) *MaintenanceService {
+	return &MaintenanceService{
+		configService: configService,
+		logger:        logger,
+	}
+}
+
+// OnChange 注册状态变更回调
+func (s *MaintenanceService) OnChange(listener func(MaintenanceEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Load 从数据库恢复维护模式状态
+func (s *MaintenanceService) Load() error {
+	config, err := s.configService.GetConfiguration(maintenanceStateKey)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取维护模式状态失败: %w", err)
+	}
+
+	var state MaintenanceState
+	if err := json.Unmarshal([]byte(config.Value), &state); err != nil {
+		return fmt.Errorf("解析维护模式状态失败: %w", err)
+	}
+
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+
+	if !state.Active {
+		return nil
+	}
+
+	// 自动结束时间已过，直接结束维护
+	if state.EndsAt != nil {
+		remaining := time.Until(*state.EndsAt)
+		if remaining <= 0 {
+			_, err := s.End()
+			return err
+		}
+		s.scheduleEnd(remaining)
+	}
+
+	s.logger.WithField("reason", state.Reason).Warn("已恢复维护模式，扫码数据将被忽略")
+	return nil
+}
+
+// Start 进入维护模式，duration为0表示需手动结束
+func (s *MaintenanceService) Start(reason string, duration time.Duration) (MaintenanceState, error) {
+	if duration < 0 {
+		return MaintenanceState{}, fmt.Errorf("维护时长不能为负数")
+	}
+
+	s.mu.Lock()
+	if s.state.Active {
+		state := s.state
+		s.mu.Unlock()
+		return state, fmt.Errorf("已处于维护模式")
+	}
+
+	previous := s.state
+	now := time.Now()
+	s.state = MaintenanceState{
+		Active:    true,
+		Reason:    reason,
+		StartedAt: &now,
+	}
+	if duration > 0 {
+		endsAt := now.Add(duration)
+		s.state.EndsAt = &endsAt
+	}
+	s.generation++
+	state := s.state
+	s.mu.Unlock()
+
+	if err := s.persist(); err != nil {
+		// 未保存时不进入维护模式，以免重启后状态与之前不一致
+		s.mu.Lock()
+		s.state = previous
+		s.generation++
+		s.mu.Unlock()
+		return previous, err
+	}
+	if duration > 0 {
+		s.scheduleEnd(duration)
+	}
+
+	s.logger.WithField("reason", reason).WithField("duration", duration.String()).Warn("进入维护模式")
+	s.notify(MaintenanceEvent{Action: "start", State: state})
+	return state, nil
+}
+
+// End 结束维护模式
+func (s *MaintenanceService) End() (MaintenanceState, error) {
+	s.mu.Lock()
+	if !s.state.Active {
+		state := s.state
+		s.mu.Unlock()
+		return state, fmt.Errorf("当前不在维护模式")
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if s.pending != nil {
+		s.pending.Stop()
+		s.pending = nil
+	}
+
+	now := time.Now()
+	s.state.Active = false
+	s.state.EndedAt = &now
+	s.generation++
+	state := s.state
+	s.mu.Unlock()
+
+	if err := s.persist(); err != nil {
+		return state, err
+	}
+
+	s.logger.WithField("ignored_scans", state.IgnoredScans).Info("退出维护模式")
+	s.notify(MaintenanceEvent{Action: "end", State: state})
+	return state, nil
+}
+
+// IsActive 是否处于维护模式
+func (s *MaintenanceService) IsActive() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state.Active
+}
+
+// GetState 获取当前维护模式状态
+func (s *MaintenanceService) GetState() MaintenanceState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// RecordIgnoredScan 记录维护期间被忽略的扫码，计数在 ignoredSaveDelay 后与期间的其他扫码一起保存
+func (s *MaintenanceService) RecordIgnoredScan() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.IgnoredScans++
+	s.generation++
+	if s.pending == nil {
+		s.pending = time.AfterFunc(ignoredSaveDelay, s.flushIgnored)
+	}
+}
+
+// flushIgnored 保存延迟合并的忽略扫码计数
+func (s *MaintenanceService) flushIgnored() {
+	s.mu.Lock()
+	s.pending = nil
+	s.mu.Unlock()
+	if err := s.persist(); err != nil {
+		s.logger.WithError(err).Warn("保存维护期间扫码计数失败")
+	}
+}
+
+// Close 停止定时器并保存尚未保存的忽略扫码计数
+func (s *MaintenanceService) Close() {
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	flush := s.pending != nil && s.pending.Stop()
+	s.pending = nil
+	s.mu.Unlock()
+
+	if flush {
+		if err := s.persist(); err != nil {
+			s.logger.WithError(err).Warn("保存维护期间扫码计数失败")
+		}
+	}
+}
+
+// scheduleEnd 安排自动结束维护
+func (s *MaintenanceService) scheduleEnd(after time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(after, func() {
+		if _, err := s.End(); err != nil {
+			s.logger.WithError(err).Warn("自动结束维护模式失败")
+		}
+	})
+}
+
+// persist 保存当前的维护模式状态
+//
+// 保存按顺序进行且每次写入保存时的最新状态，已保存更新的状态时跳过，
+// 避免并发保存时旧的状态（如结束前的 Active:true）覆盖新的状态。
+func (s *MaintenanceService) persist() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.RLock()
+	state, generation := s.state, s.generation
+	s.mu.RUnlock()
+	if generation <= s.saved {
+		return nil
+	}
+	if err := s.save(state); err != nil {
+		return err
+	}
+	s.saved = generation
+	return nil
+}
+
+// save 持久化维护模式状态
+func (s *MaintenanceService) save(state MaintenanceState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("序列化维护模式状态失败: %w", err)
+	}
+	if err := s.configService.SetConfiguration(maintenanceStateKey, string(data), "system", "维护模式状态"); err != nil {
+		return fmt.Errorf("保存维护模式状态失败: %w", err)
+	}
+	return nil
+}
+
+// notify 通知状态变更
+func (s *MaintenanceService) notify(event MaintenanceEvent) {
+	s.mu.RLock()
+	listeners := make([]func(MaintenanceEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
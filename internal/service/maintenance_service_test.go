@@ -0,0 +1,97 @@
+package service
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/database"
+)
+
+// startMaintenance 模拟一次进程启动：创建维护模式服务并从数据库恢复状态
+func startMaintenance(t *testing.T, db *database.DB) *MaintenanceService {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	maintenance := NewMaintenanceService(NewConfigService(db.DB, logger), logger)
+	if err := maintenance.Load(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(maintenance.Close)
+	return maintenance
+}
+
+// TestMaintenanceIgnoredScansDeferred 忽略的扫码不逐条保存，关闭时保存合并后的计数
+func TestMaintenanceIgnoredScansDeferred(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "maintenance.db"))
+	defer db.Close()
+	maintenance := startMaintenance(t, db)
+	if _, err := maintenance.Start("换线", 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		maintenance.RecordIgnoredScan()
+	}
+	if state := startMaintenance(t, db).GetState(); state.IgnoredScans != 0 {
+		t.Fatalf("合并保存前已保存计数 %d", state.IgnoredScans)
+	}
+
+	maintenance.Close()
+	state := startMaintenance(t, db).GetState()
+	if !state.Active || state.IgnoredScans != 3 {
+		t.Fatalf("关闭后保存的状态 = %+v，want 维护中且忽略3次", state)
+	}
+}
+
+// TestMaintenanceEndRace 结束维护与忽略扫码的计数同时进行时，结束后的状态不被旧状态覆盖
+func TestMaintenanceEndRace(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "maintenance.db"))
+	defer db.Close()
+	maintenance := startMaintenance(t, db)
+	if _, err := maintenance.Start("换线", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				maintenance.RecordIgnoredScan()
+				maintenance.flushIgnored()
+			}
+		}()
+	}
+	if _, err := maintenance.End(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	maintenance.Close()
+
+	want := maintenance.GetState()
+	got := startMaintenance(t, db).GetState()
+	if got.Active || got.IgnoredScans != want.IgnoredScans {
+		t.Fatalf("保存的状态 = %+v，want 已结束且忽略 %d 次", got, want.IgnoredScans)
+	}
+}
+
+// TestMaintenanceStartSaveFailure 保存失败时不进入维护模式
+func TestMaintenanceStartSaveFailure(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "maintenance.db"))
+	maintenance := startMaintenance(t, db)
+	db.Close()
+
+	if _, err := maintenance.Start("换线", 0); err == nil {
+		t.Fatal("数据库已关闭，进入维护模式应失败")
+	}
+	if maintenance.IsActive() {
+		t.Fatal("保存失败后仍处于维护模式")
+	}
+	if _, err := maintenance.End(); err == nil {
+		t.Fatal("未进入维护模式时结束应失败")
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// maxMetricHistoryPoints 一次查询返回的最大快照数
+const maxMetricHistoryPoints = 20000
+
+var (
+	// ErrUnknownMetric 指标不存在
+	ErrUnknownMetric = errors.New("指标不存在")
+	// ErrMetricRangeTooLarge 查询范围内的快照过多
+	ErrMetricRangeTooLarge = errors.New("查询范围内的快照过多，请缩小时间范围")
+)
+
+// MetricPoint 一次快照的值
+type MetricPoint struct {
+	At    time.Time `json:"at"`
+	Value float64   `json:"value"`
+	Rate  *float64  `json:"rate,omitempty"` // 计数器相对上一次快照的每秒增量，进程重启导致计数归零时按从0开始计算
+}
+
+// MetricSeries 同一组标签的快照序列
+type MetricSeries struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Points []MetricPoint     `json:"points"`
+}
+
+// MetricHistory 指标在时间范围内的快照
+type MetricHistory struct {
+	Metric string         `json:"metric"`
+	Type   string         `json:"type"`
+	Help   string         `json:"help,omitempty"`
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Series []MetricSeries `json:"series"`
+}
+
+// MetricsHistoryService 定期将监控指标快照写入数据库
+//
+// 快照从 /metrics 使用的同一登记表采集，没有Prometheus的工位也能在现场排查时查看历史指标。
+// 每次采集只读取内存中的计数并批量写入一次，按分钟采集的开销可以忽略。
+type MetricsHistoryService struct {
+	db       *gorm.DB
+	registry *MetricsRegistry
+	config   config.MetricsHistoryConfig
+	logger   *logrus.Logger
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewMetricsHistoryService 创建监控指标快照服务
+func NewMetricsHistoryService(db *gorm.DB, registry *MetricsRegistry, cfg config.MetricsHistoryConfig, logger *logrus.Logger) *MetricsHistoryService {
+	if cfg.Interval < time.Minute {
+		cfg.Interval = time.Minute
+	}
+	return &MetricsHistoryService{
+		db:       db,
+		registry: registry,
+		config:   cfg,
+		logger:   logger,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start 启动定时采集
+func (s *MetricsHistoryService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if _, err := s.Snapshot(now); err != nil {
+					s.logger.WithError(err).Warn("写入监控指标快照失败")
+				}
+				s.cleanup(now)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时采集
+func (s *MetricsHistoryService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Snapshot 采集全部指标并写入一次快照，返回写入的样本数
+func (s *MetricsHistoryService) Snapshot(at time.Time) (int, error) {
+	var rows []models.MetricSnapshot
+	for _, metric := range s.registry.Gather() {
+		for _, sample := range metric.Samples {
+			rows = append(rows, models.MetricSnapshot{
+				Metric: metric.Name,
+				Type:   metric.Type,
+				Labels: models.StringMap(sample.Labels),
+				Value:  sample.Value,
+				At:     at,
+			})
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if err := s.db.CreateInBatches(rows, 200).Error; err != nil {
+		return 0, fmt.Errorf("写入监控指标快照失败: %w", err)
+	}
+	return len(rows), nil
+}
+
+// cleanup 删除超过保留时长的快照
+func (s *MetricsHistoryService) cleanup(now time.Time) {
+	if s.config.Retention <= 0 {
+		return
+	}
+	if err := s.db.Where("at < ?", now.Add(-s.config.Retention)).Delete(&models.MetricSnapshot{}).Error; err != nil {
+		s.logger.WithError(err).Warn("清理过期监控指标快照失败")
+	}
+}
+
+// Metrics 可查询的指标名称及说明，按名称排序
+func (s *MetricsHistoryService) Metrics() []Metric {
+	metrics := s.registry.Gather()
+	for i := range metrics {
+		metrics[i].Samples = nil
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
+
+// History 查询指标在 [from, to] 内的快照，按标签分组；计数器附带每秒增量
+func (s *MetricsHistoryService) History(metric string, from, to time.Time) (*MetricHistory, error) {
+	history := &MetricHistory{Metric: metric, From: from, To: to, Series: []MetricSeries{}}
+	for _, known := range s.registry.Gather() {
+		if known.Name == metric {
+			history.Type, history.Help = known.Type, known.Help
+			break
+		}
+	}
+
+	query := s.db.Model(&models.MetricSnapshot{}).Where("metric = ? AND at >= ? AND at <= ?", metric, from, to)
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询监控指标快照失败: %w", err)
+	}
+	if count > maxMetricHistoryPoints {
+		return nil, fmt.Errorf("%w: %d 条，上限 %d 条", ErrMetricRangeTooLarge, count, maxMetricHistoryPoints)
+	}
+
+	var rows []models.MetricSnapshot
+	if err := query.Order("at, id").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询监控指标快照失败: %w", err)
+	}
+	if len(rows) == 0 && history.Type == "" {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMetric, metric)
+	}
+	if history.Type == "" {
+		// 已不再采集的指标，按快照中记录的类型处理
+		history.Type = rows[0].Type
+	}
+
+	index := make(map[string]int)
+	for _, row := range rows {
+		key := labelKey(row.Labels)
+		i, ok := index[key]
+		if !ok {
+			i = len(history.Series)
+			index[key] = i
+			history.Series = append(history.Series, MetricSeries{Labels: row.Labels, Points: []MetricPoint{}})
+		}
+		series := &history.Series[i]
+		point := MetricPoint{At: row.At, Value: row.Value}
+		if history.Type == MetricTypeCounter && len(series.Points) > 0 {
+			point.Rate = counterRate(series.Points[len(series.Points)-1], point)
+		}
+		series.Points = append(series.Points, point)
+	}
+	return history, nil
+}
+
+// counterRate 计数器两次快照之间的每秒增量
+func counterRate(previous, current MetricPoint) *float64 {
+	seconds := current.At.Sub(previous.At).Seconds()
+	if seconds <= 0 {
+		return nil
+	}
+	increase := current.Value - previous.Value
+	if increase < 0 {
+		// 计数器归零（进程重启），本次快照的值即为重启后的增量
+		increase = current.Value
+	}
+	rate := increase / seconds
+	return &rate
+}
+
+// labelKey 标签按键排序后的分组键
+func labelKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%q,", key, labels[key])
+	}
+	return b.String()
+}
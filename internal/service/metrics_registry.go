@@ -0,0 +1,140 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 指标类型
+const (
+	MetricTypeGauge   = "gauge"
+	MetricTypeCounter = "counter"
+)
+
+// Metric 一个指标及其样本
+type Metric struct {
+	Name    string
+	Help    string
+	Type    string // gauge 或 counter
+	Samples []MetricSample
+}
+
+// MetricSample 指标的一个样本
+type MetricSample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// GaugeMetric 单值仪表指标
+func GaugeMetric(name, help string, value float64) Metric {
+	return Metric{Name: name, Help: help, Type: MetricTypeGauge, Samples: []MetricSample{{Value: value}}}
+}
+
+// CounterMetric 单值计数器指标
+func CounterMetric(name, help string, value float64) Metric {
+	return Metric{Name: name, Help: help, Type: MetricTypeCounter, Samples: []MetricSample{{Value: value}}}
+}
+
+// MetricsRegistry 监控指标登记表
+//
+// /metrics 与指标快照都从这里采集，两者的数值来自同一组采集函数，不会不一致。
+// 采集函数应只读取内存中的计数，不查询数据库，快照任务按分钟采集时开销可以忽略。
+type MetricsRegistry struct {
+	mu         sync.Mutex
+	collectors []func() []Metric
+}
+
+// NewMetricsRegistry 创建监控指标登记表
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{}
+}
+
+// Register 注册采集函数，按注册顺序输出
+func (r *MetricsRegistry) Register(collect func() []Metric) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, collect)
+	r.mu.Unlock()
+}
+
+// Gather 采集全部指标
+func (r *MetricsRegistry) Gather() []Metric {
+	r.mu.Lock()
+	collectors := append([]func() []Metric(nil), r.collectors...)
+	r.mu.Unlock()
+
+	var metrics []Metric
+	for _, collect := range collectors {
+		metrics = append(metrics, collect()...)
+	}
+	return metrics
+}
+
+// scanLatencyWindow 计算扫码处理耗时分位数的最近样本数
+const scanLatencyWindow = 512
+
+// ScanMetrics 扫码处理计数及耗时，耗时分位数按最近 scanLatencyWindow 次扫码计算
+type ScanMetrics struct {
+	total  atomic.Uint64
+	failed atomic.Uint64
+
+	mu      sync.Mutex
+	latency [scanLatencyWindow]time.Duration
+	next    int
+	count   int
+}
+
+// NewScanMetrics 创建扫码处理指标
+func NewScanMetrics() *ScanMetrics {
+	return &ScanMetrics{}
+}
+
+// Observe 记录一次扫码的处理耗时（入库到推送），failed 表示入库失败
+func (m *ScanMetrics) Observe(latency time.Duration, failed bool) {
+	m.total.Add(1)
+	if failed {
+		m.failed.Add(1)
+	}
+	m.mu.Lock()
+	m.latency[m.next] = latency
+	m.next = (m.next + 1) % scanLatencyWindow
+	if m.count < scanLatencyWindow {
+		m.count++
+	}
+	m.mu.Unlock()
+}
+
+// Totals 已处理及入库失败的扫码数
+func (m *ScanMetrics) Totals() (total, failed uint64) {
+	return m.total.Load(), m.failed.Load()
+}
+
+// Percentile 最近扫码处理耗时的分位数（q 为0~1），尚无扫码时为0
+func (m *ScanMetrics) Percentile(q float64) time.Duration {
+	m.mu.Lock()
+	samples := append([]time.Duration(nil), m.latency[:m.count]...)
+	m.mu.Unlock()
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	index := int(q*float64(len(samples))+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(samples) {
+		index = len(samples) - 1
+	}
+	return samples[index]
+}
+
+// Collect 输出扫码处理指标
+func (m *ScanMetrics) Collect() []Metric {
+	total, failed := m.Totals()
+	return []Metric{
+		CounterMetric("barcode_scans_total", "已处理的扫码数（维护模式下忽略的除外）", float64(total)),
+		CounterMetric("barcode_scan_errors_total", "入库失败的扫码数", float64(failed)),
+		GaugeMetric("barcode_scan_latency_p95_seconds", "最近扫码从入库到推送耗时的95分位（秒）", m.Percentile(0.95).Seconds()),
+	}
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+	"userclient/pkg/barcode"
+)
+
+// operatorPrivacyAction 隐私设置变化的审计日志动作
+const operatorPrivacyAction = "privacy:operator_stats"
+
+// ErrOperatorStatsDisabled 隐私设置关闭了按操作员的统计
+var ErrOperatorStatsDisabled = errors.New("按操作员的统计已按隐私设置关闭")
+
+// OperatorReport 时间范围内按操作员的效率统计
+type OperatorReport struct {
+	From      time.Time                     `json:"from"`
+	To        time.Time                     `json:"to"`
+	Privacy   string                        `json:"privacy"`
+	IdleGap   string                        `json:"idle_gap"`
+	Operators []models.OperatorProductivity `json:"operators"`
+}
+
+// operatorPrivacyState 审计日志中记录的隐私设置，不含化名密钥本身
+type operatorPrivacyState struct {
+	Privacy string `json:"privacy"`
+	Secret  string `json:"secret_fingerprint,omitempty"` // 化名密钥的指纹，用于发现密钥更换
+}
+
+// OperatorStatsService 按操作员的效率统计
+//
+// 操作员取自分类规则提取的派生字段，未提取到操作员的记录不计入。在岗时间为同一操作员相邻两次扫码间隔之和，
+// 超过空闲阈值的间隔不计入。化名为以密钥对操作员做 HMAC-SHA256 的前缀，同一密钥下稳定，无法反推操作员。
+type OperatorStatsService struct {
+	db     *gorm.DB
+	field  string
+	logger *logrus.Logger
+
+	mu     sync.RWMutex
+	config config.OperatorStatsConfig
+}
+
+// NewOperatorStatsService 创建操作员统计服务，field 为派生字段中的操作员字段
+func NewOperatorStatsService(db *gorm.DB, cfg config.OperatorStatsConfig, field string, logger *logrus.Logger) *OperatorStatsService {
+	return &OperatorStatsService{
+		db:     db,
+		field:  field,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Load 与审计日志中最近一次记录的隐私设置比较，启动前在配置文件中修改过时补记审计日志
+func (s *OperatorStatsService) Load() error {
+	var last models.SystemLog
+	err := s.db.Where("action = ?", operatorPrivacyAction).Order("id DESC").First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询隐私设置审计日志失败: %w", err)
+	}
+
+	s.mu.RLock()
+	current := s.state(s.config)
+	s.mu.RUnlock()
+
+	var previous struct {
+		To operatorPrivacyState `json:"to"`
+	}
+	if err == nil {
+		_ = json.Unmarshal([]byte(last.Extra), &previous)
+	}
+	if err == nil && previous.To == current {
+		return nil
+	}
+	s.audit(previous.To, current, "startup")
+	return nil
+}
+
+// SetConfig 更新统计配置（配置文件热加载），隐私设置或化名密钥变化时记录审计日志
+func (s *OperatorStatsService) SetConfig(cfg config.OperatorStatsConfig) {
+	s.mu.Lock()
+	previous := s.state(s.config)
+	s.config = cfg
+	s.mu.Unlock()
+
+	if current := s.state(cfg); current != previous {
+		s.audit(previous, current, "config_reload")
+	}
+}
+
+// Privacy 当前的隐私设置
+func (s *OperatorStatsService) Privacy() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.Privacy
+}
+
+// Report 统计时间范围内按操作员的扫码数、有效/无效占比及在岗时间，按扫码数从多到少排列
+func (s *OperatorStatsService) Report(from, to time.Time) (*OperatorReport, error) {
+	s.mu.RLock()
+	cfg := s.config
+	s.mu.RUnlock()
+	if cfg.Privacy == config.OperatorPrivacyDisabled {
+		return nil, ErrOperatorStatsDisabled
+	}
+	report := &OperatorReport{
+		From:      from,
+		To:        to,
+		Privacy:   cfg.Privacy,
+		IdleGap:   cfg.IdleGap.String(),
+		Operators: []models.OperatorProductivity{},
+	}
+	// 未配置操作员字段时没有可统计的操作员
+	if s.field == "" {
+		return report, nil
+	}
+	if !barcode.ValidFieldName(s.field) {
+		return nil, fmt.Errorf("操作员字段无效: %s", s.field)
+	}
+
+	type scan struct {
+		at    time.Time
+		valid bool
+	}
+	scans := make(map[string][]scan)
+	var batch []*models.BarcodeRecord
+	err := s.db.Select("id", "created_at", "status", "derived").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Where(derivedFieldExpr(s.field)+" IS NOT NULL").
+		FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+			for _, record := range batch {
+				operator := record.Derived[s.field]
+				if operator == "" {
+					continue
+				}
+				scans[operator] = append(scans[operator], scan{at: record.CreatedAt, valid: record.Status == "success"})
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询扫码记录失败: %w", err)
+	}
+
+	for operator, list := range scans {
+		sort.Slice(list, func(i, j int) bool { return list[i].at.Before(list[j].at) })
+		times := make([]time.Time, len(list))
+		stats := models.OperatorProductivity{
+			Operator:  operatorName(cfg, operator),
+			Total:     int64(len(list)),
+			FirstScan: list[0].at,
+			LastScan:  list[len(list)-1].at,
+		}
+		for i, sc := range list {
+			times[i] = sc.at
+			if sc.valid {
+				stats.Valid++
+			} else {
+				stats.Invalid++
+			}
+		}
+		stats.ValidRatio = float64(stats.Valid) / float64(stats.Total)
+		stats.InvalidRatio = float64(stats.Invalid) / float64(stats.Total)
+		active := ActiveTime(times, cfg.IdleGap)
+		stats.ActiveSeconds = int64(active / time.Second)
+		if active > 0 {
+			stats.ScansPerHour = float64(stats.Total) / active.Hours()
+		}
+		report.Operators = append(report.Operators, stats)
+	}
+	sort.Slice(report.Operators, func(i, j int) bool {
+		a, b := report.Operators[i], report.Operators[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Operator < b.Operator
+	})
+	return report, nil
+}
+
+// MaskCounts 按隐私设置处理按操作员的计数：使用化名时替换操作员，关闭统计时返回空列表
+func (s *OperatorStatsService) MaskCounts(counts []models.NamedCount) []models.NamedCount {
+	s.mu.RLock()
+	cfg := s.config
+	s.mu.RUnlock()
+	switch cfg.Privacy {
+	case config.OperatorPrivacyDisabled:
+		return []models.NamedCount{}
+	case config.OperatorPrivacyPseudonymized:
+		masked := make([]models.NamedCount, len(counts))
+		for i, c := range counts {
+			masked[i] = models.NamedCount{Name: Pseudonym(cfg.PseudonymSecret, c.Name), Count: c.Count}
+		}
+		return masked
+	}
+	return counts
+}
+
+// ActiveTime 在岗时间：按时间先后排列的扫码中相邻两次的间隔之和，超过 idleGap 的间隔不计入
+func ActiveTime(times []time.Time, idleGap time.Duration) time.Duration {
+	var active time.Duration
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap > 0 && gap <= idleGap {
+			active += gap
+		}
+	}
+	return active
+}
+
+// Pseudonym 操作员的化名，同一密钥下同一操作员的化名不变
+func Pseudonym(secret, operator string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(operator))
+	return "OP-" + strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:8])
+}
+
+// operatorName 按隐私设置显示的操作员
+func operatorName(cfg config.OperatorStatsConfig, operator string) string {
+	if cfg.Privacy == config.OperatorPrivacyPseudonymized {
+		return Pseudonym(cfg.PseudonymSecret, operator)
+	}
+	return operator
+}
+
+// state 审计日志中记录的隐私设置，未使用化名时不记录密钥指纹
+func (s *OperatorStatsService) state(cfg config.OperatorStatsConfig) operatorPrivacyState {
+	state := operatorPrivacyState{Privacy: cfg.Privacy}
+	if cfg.Privacy == config.OperatorPrivacyPseudonymized {
+		sum := sha256.Sum256([]byte(cfg.PseudonymSecret))
+		state.Secret = hex.EncodeToString(sum[:])[:12]
+	}
+	return state
+}
+
+// audit 隐私设置变化写入审计日志，source 为 startup（启动时发现配置文件已修改）或 config_reload
+func (s *OperatorStatsService) audit(from, to operatorPrivacyState, source string) {
+	extra, err := json.Marshal(map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"source": source,
+	})
+	if err != nil {
+		return
+	}
+	message := fmt.Sprintf("操作员统计隐私设置由 %s 改为 %s", privacyLabel(from.Privacy), privacyLabel(to.Privacy))
+	if from.Privacy == to.Privacy {
+		message = "操作员统计的化名密钥已更换，化名随之改变"
+	}
+	log := &models.SystemLog{
+		Level:   "warning",
+		Message: message,
+		Module:  "privacy",
+		Action:  operatorPrivacyAction,
+		Extra:   string(extra),
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入审计日志失败")
+		return
+	}
+	s.logger.WithFields(logrus.Fields{"from": from.Privacy, "to": to.Privacy, "source": source}).Warn(message)
+}
+
+// privacyLabel 审计日志中显示的隐私设置，首次启动时之前的设置为空
+func privacyLabel(privacy string) string {
+	switch privacy {
+	case config.OperatorPrivacyIdentified:
+		return "显示操作员"
+	case config.OperatorPrivacyPseudonymized:
+		return "使用化名"
+	case config.OperatorPrivacyDisabled:
+		return "关闭"
+	}
+	return "未记录"
+}
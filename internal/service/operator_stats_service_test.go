@@ -0,0 +1,243 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+func TestActiveTime(t *testing.T) {
+	base := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	at := func(minutes ...float64) []time.Time {
+		times := make([]time.Time, len(minutes))
+		for i, m := range minutes {
+			times[i] = base.Add(time.Duration(m * float64(time.Minute)))
+		}
+		return times
+	}
+	idle := 5 * time.Minute
+	tests := []struct {
+		name  string
+		times []time.Time
+		want  time.Duration
+	}{
+		{name: "no scans"},
+		{name: "single scan", times: at(0)},
+		{name: "steady", times: at(0, 1, 2, 3.5), want: 210 * time.Second},
+		// 午休的间隔不计入，前后两段分别计算
+		{name: "idle gap excluded", times: at(0, 2, 4, 64, 65), want: 5 * time.Minute},
+		{name: "gap at threshold counted", times: at(0, 5), want: 5 * time.Minute},
+		{name: "gap just over threshold", times: at(0, 5.01)},
+		{name: "same instant", times: at(1, 1, 1, 2), want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ActiveTime(tt.times, idle); got != tt.want {
+				t.Fatalf("ActiveTime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPseudonym(t *testing.T) {
+	pattern := regexp.MustCompile(`^OP-[0-9A-F]{8}$`)
+	alice := Pseudonym("site-secret", "alice")
+	if !pattern.MatchString(alice) {
+		t.Fatalf("pseudonym = %q, want OP- and 8 hex digits", alice)
+	}
+	// 同一密钥下稳定
+	if again := Pseudonym("site-secret", "alice"); again != alice {
+		t.Fatalf("pseudonym changed: %q -> %q", alice, again)
+	}
+	if bob := Pseudonym("site-secret", "bob"); bob == alice {
+		t.Fatal("different operators share a pseudonym")
+	}
+	// 更换密钥后化名改变，不能由另一个站点的化名对应到操作员
+	if other := Pseudonym("other-secret", "alice"); other == alice {
+		t.Fatal("pseudonym does not depend on the secret")
+	}
+}
+
+// newTestOperatorStats 写入按操作员的扫码记录并创建统计服务
+func newTestOperatorStats(t *testing.T, cfg config.OperatorStatsConfig) (*OperatorStatsService, *database.DB, time.Time) {
+	t.Helper()
+	db := openTestDB(t, filepath.Join(t.TempDir(), "operators.db"))
+	t.Cleanup(func() { db.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	base := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	scans := []struct {
+		minute   int
+		operator string
+		status   string
+	}{
+		{0, "alice", "success"},
+		{1, "alice", "success"},
+		{2, "alice", "error"},
+		{3, "alice", "success"},
+		{60, "alice", "success"}, // 空闲后重新开始
+		{61, "alice", "success"},
+		{0, "bob", "success"},
+		{10, "bob", "error"},
+		{5, "", "success"}, // 未提取到操作员
+	}
+	for i, scan := range scans {
+		record := &models.BarcodeRecord{
+			ScanID:    fmt.Sprintf("scan-%d", i),
+			Content:   "SN000101",
+			Status:    scan.status,
+			CreatedAt: base.Add(time.Duration(scan.minute) * time.Minute),
+		}
+		if scan.operator != "" {
+			record.Derived = models.StringMap{"operator": scan.operator}
+		}
+		if err := db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewOperatorStatsService(db.DB, cfg, "operator", logger), db, base
+}
+
+// TestOperatorReport 按操作员统计扫码数、有效/无效占比及在岗时间，使用化名时不出现操作员，关闭时拒绝统计
+func TestOperatorReport(t *testing.T) {
+	cfg := config.OperatorStatsConfig{IdleGap: 5 * time.Minute, Privacy: config.OperatorPrivacyIdentified, PseudonymSecret: "site-secret"}
+	stats, _, base := newTestOperatorStats(t, cfg)
+
+	report, err := stats.Report(base, base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Operators) != 2 {
+		t.Fatalf("operators = %+v, want alice and bob", report.Operators)
+	}
+	alice, bob := report.Operators[0], report.Operators[1]
+	if alice.Operator != "alice" || alice.Total != 6 || alice.Valid != 5 || alice.Invalid != 1 ||
+		alice.ActiveSeconds != 240 || alice.ScansPerHour != 90 {
+		t.Fatalf("alice = %+v", alice)
+	}
+	if !alice.FirstScan.Equal(base) || !alice.LastScan.Equal(base.Add(61*time.Minute)) {
+		t.Fatalf("alice scans = %v .. %v", alice.FirstScan, alice.LastScan)
+	}
+	// 两次扫码间隔超过空闲阈值，在岗时间为0时不计算每小时扫码数
+	if bob.Operator != "bob" || bob.Total != 2 || bob.InvalidRatio != 0.5 || bob.ActiveSeconds != 0 || bob.ScansPerHour != 0 {
+		t.Fatalf("bob = %+v", bob)
+	}
+
+	// 时间范围外的扫码不计入
+	report, err = stats.Report(base.Add(30*time.Minute), base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Operators) != 1 || report.Operators[0].Total != 2 || report.Operators[0].ActiveSeconds != 60 {
+		t.Fatalf("operators after 08:30 = %+v", report.Operators)
+	}
+
+	cfg.Privacy = config.OperatorPrivacyPseudonymized
+	stats.SetConfig(cfg)
+	report, err = stats.Report(base, base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Privacy != config.OperatorPrivacyPseudonymized || report.Operators[0].Operator != Pseudonym("site-secret", "alice") ||
+		report.Operators[1].Operator != Pseudonym("site-secret", "bob") || report.Operators[0].Total != 6 {
+		t.Fatalf("pseudonymized operators = %+v", report.Operators)
+	}
+	data, _ := json.Marshal(report)
+	if regexp.MustCompile(`alice|bob`).Match(data) {
+		t.Fatalf("pseudonymized report exposes operators: %s", data)
+	}
+	masked := stats.MaskCounts([]models.NamedCount{{Name: "alice", Count: 6}})
+	if len(masked) != 1 || masked[0].Name != Pseudonym("site-secret", "alice") || masked[0].Count != 6 {
+		t.Fatalf("masked counts = %+v", masked)
+	}
+
+	cfg.Privacy = config.OperatorPrivacyDisabled
+	stats.SetConfig(cfg)
+	if _, err := stats.Report(base, base.Add(2*time.Hour)); !errors.Is(err, ErrOperatorStatsDisabled) {
+		t.Fatalf("disabled report err = %v", err)
+	}
+	if masked := stats.MaskCounts([]models.NamedCount{{Name: "alice", Count: 6}}); len(masked) != 0 {
+		t.Fatalf("disabled masked counts = %+v", masked)
+	}
+}
+
+// TestOperatorPrivacyAudit 隐私设置及化名密钥变化写入审计日志，日志中只有密钥指纹；未变化时不重复记录
+func TestOperatorPrivacyAudit(t *testing.T) {
+	cfg := config.OperatorStatsConfig{IdleGap: 5 * time.Minute, Privacy: config.OperatorPrivacyIdentified}
+	stats, db, _ := newTestOperatorStats(t, cfg)
+	audits := func() []models.SystemLog {
+		t.Helper()
+		var logs []models.SystemLog
+		if err := db.Where("action = ?", operatorPrivacyAction).Order("id").Find(&logs).Error; err != nil {
+			t.Fatal(err)
+		}
+		return logs
+	}
+
+	// 首次启动记录当前设置，之后设置不变的启动不再记录
+	if err := stats.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if logs := audits(); len(logs) != 1 || logs[0].Module != "privacy" {
+		t.Fatalf("audits after startup = %+v", logs)
+	}
+
+	stats.SetConfig(cfg)
+	cfg.Privacy = config.OperatorPrivacyPseudonymized
+	cfg.PseudonymSecret = "site-secret"
+	stats.SetConfig(cfg)
+	cfg.PseudonymSecret = "rotated-secret"
+	stats.SetConfig(cfg)
+	cfg.IdleGap = time.Minute // 与隐私无关的修改不记录
+	stats.SetConfig(cfg)
+
+	logs := audits()
+	if len(logs) != 3 {
+		t.Fatalf("audits = %d, want startup, privacy change and secret rotation", len(logs))
+	}
+	var extra struct {
+		From   operatorPrivacyState `json:"from"`
+		To     operatorPrivacyState `json:"to"`
+		Source string               `json:"source"`
+	}
+	if err := json.Unmarshal([]byte(logs[1].Extra), &extra); err != nil {
+		t.Fatal(err)
+	}
+	if extra.From.Privacy != config.OperatorPrivacyIdentified || extra.To.Privacy != config.OperatorPrivacyPseudonymized ||
+		extra.To.Secret == "" || extra.Source != "config_reload" {
+		t.Fatalf("privacy change audit = %+v", extra)
+	}
+	if logs[2].Message != "操作员统计的化名密钥已更换，化名随之改变" {
+		t.Fatalf("secret rotation audit = %q", logs[2].Message)
+	}
+	for _, log := range logs {
+		if regexp.MustCompile(`site-secret|rotated-secret`).MatchString(log.Extra + log.Message) {
+			t.Fatalf("audit log exposes the secret: %+v", log)
+		}
+	}
+
+	// 停止期间在配置文件中修改的设置在启动时补记
+	restarted := NewOperatorStatsService(db.DB, config.OperatorStatsConfig{Privacy: config.OperatorPrivacyDisabled}, "operator", stats.logger)
+	if err := restarted.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if logs := audits(); len(logs) != 4 || !strings.Contains(logs[3].Extra, `"startup"`) {
+		t.Fatalf("audits after restart = %+v", logs)
+	}
+}
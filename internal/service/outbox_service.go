@@ -0,0 +1,404 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/contract"
+	"userclient/internal/models"
+)
+
+// outboxCleanupInterval 已投递事件清理间隔
+const outboxCleanupInterval = 10 * time.Minute
+
+// OutboxDeliverer 输出端投递函数，返回nil表示投递成功
+type OutboxDeliverer func(event *models.OutboxEvent) error
+
+// OutboxEnvelope 出站事件载荷，event_id为幂等键，下游据此去重
+type OutboxEnvelope struct {
+	EventID   string      `json:"event_id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+	Replay    bool        `json:"replay,omitempty"`     // 历史记录回放，非实时数据
+	ReplayJob uint        `json:"replay_job,omitempty"` // 回放任务ID
+}
+
+// OutboxSinkMetrics 单个输出端的投递指标
+type OutboxSinkMetrics struct {
+	Sink          string `json:"sink"`
+	Pending       int64  `json:"pending"`
+	OldestPending string `json:"oldest_pending_age,omitempty"`
+	Delivered     int64  `json:"delivered"`
+	Failures      int64  `json:"failures"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+// OutboxMetrics 出站事件日志指标
+type OutboxMetrics struct {
+	Enabled bool                `json:"enabled"`
+	Sinks   []OutboxSinkMetrics `json:"sinks"`
+}
+
+// outboxSink 已注册的输出端
+type outboxSink struct {
+	name      string
+	deliver   OutboxDeliverer
+	delivered int64
+	failures  int64
+	lastError atomic.Value
+}
+
+// OutboxService 出站事件日志服务
+//
+// 扫码记录与出站事件在同一事务中提交，各输出端的投递状态独立记录。
+// 投递成功后才标记为已投递，重启后从未投递的事件继续，
+// 因此进程在投递与标记之间崩溃时事件可能重复投递，下游需按event_id去重。
+type OutboxService struct {
+	db     *gorm.DB
+	config config.OutboxConfig
+	logger *logrus.Logger
+	mu     sync.RWMutex
+	sinks  []*outboxSink
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	schemaCheck bool
+}
+
+// NewOutboxService 创建出站事件日志服务
+func NewOutboxService(db *gorm.DB, cfg config.OutboxConfig, logger *logrus.Logger) *OutboxService {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	return &OutboxService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+}
+
+// RegisterSink 注册输出端，需在Start之前调用
+func (s *OutboxService) RegisterSink(name string, deliver OutboxDeliverer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sinks = append(s.sinks, &outboxSink{name: name, deliver: deliver})
+}
+
+// SetSchemaCheck 按结构定义校验写入的出站事件，用于调试及测试环境，不符时只记录错误日志
+func (s *OutboxService) SetSchemaCheck(enabled bool) {
+	s.schemaCheck = enabled
+}
+
+// Enqueue 在事务tx中写入出站事件及各输出端的投递记录
+func (s *OutboxService) Enqueue(tx *gorm.DB, eventType, key string, data interface{}) error {
+	s.mu.RLock()
+	sinks := s.sinks
+	s.mu.RUnlock()
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(OutboxEnvelope{
+		EventID:   key,
+		Type:      eventType,
+		Timestamp: now,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化出站事件失败: %w", err)
+	}
+	if s.schemaCheck {
+		checkEventSchema(s.logger, key, payload)
+	}
+
+	event := &models.OutboxEvent{
+		EventKey:  key,
+		EventType: eventType,
+		Payload:   string(payload),
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return fmt.Errorf("写入出站事件失败: %w", err)
+	}
+
+	deliveries := make([]models.OutboxDelivery, 0, len(sinks))
+	for _, sink := range sinks {
+		deliveries = append(deliveries, models.OutboxDelivery{
+			EventID:       event.ID,
+			Sink:          sink.name,
+			Status:        models.OutboxStatusPending,
+			NextAttemptAt: now,
+		})
+	}
+	if err := tx.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("写入出站投递记录失败: %w", err)
+	}
+	return nil
+}
+
+// checkEventSchema 校验出站事件是否符合对外发布的结构定义，不符说明载荷字段的变更未同步到结构定义
+func checkEventSchema(logger *logrus.Logger, eventID string, payload []byte) {
+	if err := contract.Validate(contract.SchemaEvent, payload); err != nil {
+		logger.WithError(err).WithField("event_id", eventID).Error("出站事件不符合结构定义")
+	}
+}
+
+// Start 启动各输出端的投递协程和清理协程
+func (s *OutboxService) Start() {
+	s.mu.RLock()
+	sinks := s.sinks
+	s.mu.RUnlock()
+
+	for _, sink := range sinks {
+		s.wg.Add(1)
+		go s.dispatch(sink)
+	}
+
+	s.wg.Add(1)
+	go s.runCleanup()
+
+	s.logger.WithField("sinks", len(sinks)).Info("出站事件投递已启动")
+}
+
+// Close 停止投递，正在进行的批次投递完当前事件后退出
+func (s *OutboxService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// RetryPending 取消全部未投递事件的退避等待，启动后立即重新投递，返回未投递的记录数；需在 Start 之前调用
+func (s *OutboxService) RetryPending() (int64, error) {
+	result := s.db.Model(&models.OutboxDelivery{}).
+		Where("status = ?", models.OutboxStatusPending).
+		Update("next_attempt_at", time.Now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("重置出站投递退避失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetMetrics 获取投递指标
+func (s *OutboxService) GetMetrics() OutboxMetrics {
+	s.mu.RLock()
+	sinks := s.sinks
+	s.mu.RUnlock()
+
+	metrics := OutboxMetrics{Enabled: true, Sinks: make([]OutboxSinkMetrics, 0, len(sinks))}
+	for _, sink := range sinks {
+		item := OutboxSinkMetrics{
+			Sink:      sink.name,
+			Delivered: atomic.LoadInt64(&sink.delivered),
+			Failures:  atomic.LoadInt64(&sink.failures),
+		}
+		if lastError, ok := sink.lastError.Load().(string); ok {
+			item.LastError = lastError
+		}
+
+		query := s.db.Model(&models.OutboxDelivery{}).
+			Where("sink = ? AND status = ?", sink.name, models.OutboxStatusPending)
+		if err := query.Count(&item.Pending).Error; err != nil {
+			s.logger.WithError(err).Warn("统计出站积压失败")
+		}
+		if item.Pending > 0 {
+			var oldest models.OutboxDelivery
+			if err := query.Order("id").First(&oldest).Error; err == nil {
+				item.OldestPending = time.Since(oldest.CreatedAt).Truncate(time.Second).String()
+			}
+		}
+
+		metrics.Sinks = append(metrics.Sinks, item)
+	}
+	return metrics
+}
+
+// dispatch 单个输出端的投递循环
+func (s *OutboxService) dispatch(sink *outboxSink) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// 整批投递成功时立即继续，直到积压清空
+		for {
+			n, err := s.dispatchBatch(sink)
+			if err != nil || n < s.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// dispatchBatch 按事件顺序投递一批事件，遇到失败即停止以保持顺序
+func (s *OutboxService) dispatchBatch(sink *outboxSink) (int, error) {
+	var deliveries []models.OutboxDelivery
+	err := s.db.Where("sink = ? AND status = ?", sink.name, models.OutboxStatusPending).
+		Order("event_id").
+		Limit(s.config.BatchSize).
+		Find(&deliveries).Error
+	if err != nil {
+		s.logger.WithError(err).WithField("sink", sink.name).Warn("读取待投递事件失败")
+		return 0, err
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.EventID)
+	}
+	var events []models.OutboxEvent
+	if err := s.db.Where("id IN ?", ids).Find(&events).Error; err != nil {
+		s.logger.WithError(err).WithField("sink", sink.name).Warn("读取出站事件失败")
+		return 0, err
+	}
+	byID := make(map[uint]*models.OutboxEvent, len(events))
+	for i := range events {
+		byID[events[i].ID] = &events[i]
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		select {
+		case <-s.done:
+			return i, fmt.Errorf("出站事件投递已停止")
+		default:
+		}
+
+		// 处于退避期，等待下次轮询
+		if time.Now().Before(delivery.NextAttemptAt) {
+			return i, fmt.Errorf("出站事件投递退避中")
+		}
+
+		event, ok := byID[delivery.EventID]
+		if !ok {
+			// 事件已被清理，无需再投递
+			s.markDelivered(sink, delivery)
+			continue
+		}
+
+		if err := sink.deliver(event); err != nil {
+			s.markFailed(sink, delivery, err)
+			return i, err
+		}
+		s.markDelivered(sink, delivery)
+	}
+	return len(deliveries), nil
+}
+
+// markDelivered 标记投递成功
+func (s *OutboxService) markDelivered(sink *outboxSink, delivery *models.OutboxDelivery) {
+	now := time.Now()
+	err := s.db.Model(delivery).Updates(map[string]interface{}{
+		"status":       models.OutboxStatusDelivered,
+		"attempts":     delivery.Attempts + 1,
+		"last_error":   "",
+		"delivered_at": &now,
+	}).Error
+	if err != nil {
+		// 标记失败时事件会在下次轮询时重复投递，由下游按幂等键去重
+		s.logger.WithError(err).WithField("sink", sink.name).Warn("标记出站事件已投递失败")
+		return
+	}
+	atomic.AddInt64(&sink.delivered, 1)
+}
+
+// markFailed 记录投递失败并安排退避重试
+func (s *OutboxService) markFailed(sink *outboxSink, delivery *models.OutboxDelivery, cause error) {
+	atomic.AddInt64(&sink.failures, 1)
+	sink.lastError.Store(cause.Error())
+
+	attempts := delivery.Attempts + 1
+	next := time.Now().Add(s.backoff(attempts))
+	err := s.db.Model(delivery).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"last_error":      cause.Error(),
+		"next_attempt_at": next,
+	}).Error
+	if err != nil {
+		s.logger.WithError(err).WithField("sink", sink.name).Warn("记录出站投递失败状态失败")
+	}
+
+	s.logger.WithError(cause).
+		WithField("sink", sink.name).
+		WithField("event_id", delivery.EventID).
+		WithField("attempts", attempts).
+		Warn("出站事件投递失败，稍后重试")
+}
+
+// backoff 第attempts次失败后的重试间隔
+func (s *OutboxService) backoff(attempts int) time.Duration {
+	if attempts > 20 {
+		attempts = 20
+	}
+	delay := time.Second << uint(attempts-1)
+	if delay > s.config.MaxBackoff {
+		delay = s.config.MaxBackoff
+	}
+	return delay
+}
+
+// runCleanup 定期清理已投递的事件
+func (s *OutboxService) runCleanup() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(outboxCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.cleanup(); err != nil {
+				s.logger.WithError(err).Warn("清理出站事件失败")
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// cleanup 删除超过保留时长且所有输出端均已投递的事件
+func (s *OutboxService) cleanup() error {
+	cutoff := time.Now().Add(-s.config.Retention)
+
+	if err := s.db.Where("status = ? AND delivered_at < ?", models.OutboxStatusDelivered, cutoff).
+		Delete(&models.OutboxDelivery{}).Error; err != nil {
+		return fmt.Errorf("删除已投递记录失败: %w", err)
+	}
+
+	remaining := s.db.Model(&models.OutboxDelivery{}).Select("event_id")
+	result := s.db.Where("created_at < ? AND id NOT IN (?)", cutoff, remaining).Delete(&models.OutboxEvent{})
+	if result.Error != nil {
+		return fmt.Errorf("删除出站事件失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		s.logger.WithField("count", result.RowsAffected).Info("已清理出站事件")
+	}
+	return nil
+}
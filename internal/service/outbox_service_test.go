@@ -0,0 +1,86 @@
+package service
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// TestOutboxRedeliversUnackedAfterRestart 输出端已收到但未标记为已投递的事件（进程在投递与标记之间退出），重启后恰好重投一次
+func TestOutboxRedeliversUnackedAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := config.OutboxConfig{PollInterval: 10 * time.Millisecond}
+
+	// 第一次运行：输出端收到事件后数据库连接断开，标记已投递失败，相当于投递后立即崩溃
+	db := openTestDB(t, path)
+	first := NewOutboxService(db.DB, cfg, logger)
+	received := make(chan string, 1)
+	first.RegisterSink("file", func(event *models.OutboxEvent) error {
+		db.Close()
+		received <- event.EventKey
+		return nil
+	})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return first.Enqueue(tx, "barcode", "barcode:R001", map[string]string{"content": "R001"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Start()
+	select {
+	case key := <-received:
+		if key != "barcode:R001" {
+			t.Fatalf("第一次投递 = %s", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("第一次运行未投递事件")
+	}
+	first.Close()
+
+	// 重启：未标记的事件重投一次，之后的轮询不再投递
+	reopened := openTestDB(t, path)
+	defer reopened.Close()
+	second := NewOutboxService(reopened.DB, cfg, logger)
+	var mu sync.Mutex
+	var redelivered []string
+	second.RegisterSink("file", func(event *models.OutboxEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		redelivered = append(redelivered, event.EventKey)
+		return nil
+	})
+	second.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(redelivered)
+		mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(cfg.PollInterval)
+	}
+	time.Sleep(20 * cfg.PollInterval)
+	second.Close()
+
+	if len(redelivered) != 1 || redelivered[0] != "barcode:R001" {
+		t.Fatalf("重启后投递 = %v, want 恰好一次 barcode:R001", redelivered)
+	}
+	var delivery models.OutboxDelivery
+	if err := reopened.Where("sink = ?", "file").First(&delivery).Error; err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != models.OutboxStatusDelivered || delivery.Attempts != 1 {
+		t.Fatalf("投递记录 = %+v, want 已投递且记录1次", delivery)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// preferenceCleanupInterval 清理过期偏好设置的间隔
+const preferenceCleanupInterval = time.Hour
+
+// clientKeyPattern 客户端标识：字母、数字及 - _ .，最长64个字符
+var clientKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+var (
+	// ErrInvalidPreference 客户端标识或偏好设置内容无效
+	ErrInvalidPreference = errors.New("偏好设置无效")
+	// ErrPreferenceTooLarge 偏好设置超过大小限制
+	ErrPreferenceTooLarge = errors.New("偏好设置超过大小限制")
+)
+
+// PreferenceActor 修改偏好设置的操作者，用于审计
+type PreferenceActor struct {
+	Name      string
+	IP        string
+	UserAgent string
+}
+
+// PreferenceService 看板客户端偏好设置
+//
+// 偏好设置为客户端自行定义的 JSON，服务端只校验格式及大小。修改及删除记录审计日志
+// （操作者及时间，不含内容），超过保留时长未更新的偏好设置定期清理。
+type PreferenceService struct {
+	db     *gorm.DB
+	config config.PreferencesConfig
+	logger *logrus.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewPreferenceService 创建偏好设置服务
+func NewPreferenceService(db *gorm.DB, cfg config.PreferencesConfig, logger *logrus.Logger) *PreferenceService {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 16 << 10
+	}
+	return &PreferenceService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start 启动过期偏好设置的定期清理
+func (s *PreferenceService) Start() {
+	if s.config.Retention <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		s.cleanup()
+		ticker := time.NewTicker(preferenceCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.cleanup()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定期清理
+func (s *PreferenceService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Get 获取客户端的偏好设置
+func (s *PreferenceService) Get(clientKey string) (*models.ClientPreference, error) {
+	if !clientKeyPattern.MatchString(clientKey) {
+		return nil, fmt.Errorf("%w: 客户端标识只能包含字母、数字及 - _ .，最长64个字符", ErrInvalidPreference)
+	}
+	var preference models.ClientPreference
+	if err := s.db.Where("client_key = ?", clientKey).First(&preference).Error; err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// Put 保存客户端的偏好设置，内容未变化时不写入；created 表示新建
+func (s *PreferenceService) Put(clientKey string, data []byte, actor PreferenceActor) (preference *models.ClientPreference, created bool, err error) {
+	if !clientKeyPattern.MatchString(clientKey) {
+		return nil, false, fmt.Errorf("%w: 客户端标识只能包含字母、数字及 - _ .，最长64个字符", ErrInvalidPreference)
+	}
+	if len(data) > s.config.MaxBytes {
+		return nil, false, fmt.Errorf("%w: 最多 %d 字节", ErrPreferenceTooLarge, s.config.MaxBytes)
+	}
+	if !json.Valid(data) {
+		return nil, false, fmt.Errorf("%w: 内容不是有效的JSON", ErrInvalidPreference)
+	}
+
+	preference = &models.ClientPreference{}
+	err = s.db.Where("client_key = ?", clientKey).First(preference).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		preference = &models.ClientPreference{
+			ClientKey: clientKey,
+			Data:      string(data),
+			Size:      len(data),
+			UpdatedBy: actor.Name,
+		}
+		if err := s.db.Create(preference).Error; err != nil {
+			return nil, false, fmt.Errorf("保存偏好设置失败: %w", err)
+		}
+		created = true
+	case err != nil:
+		return nil, false, fmt.Errorf("查询偏好设置失败: %w", err)
+	case preference.Data == string(data):
+		return preference, false, nil
+	default:
+		preference.Data = string(data)
+		preference.Size = len(data)
+		preference.UpdatedBy = actor.Name
+		if err := s.db.Save(preference).Error; err != nil {
+			return nil, false, fmt.Errorf("保存偏好设置失败: %w", err)
+		}
+	}
+
+	s.audit("preferences:update", "更新看板偏好设置", preference, actor)
+	return preference, created, nil
+}
+
+// List 获取所有客户端的偏好设置（不含内容），按更新时间倒序
+func (s *PreferenceService) List() ([]*models.ClientPreference, error) {
+	var preferences []*models.ClientPreference
+	if err := s.db.Omit("data").Order("updated_at DESC, id DESC").Find(&preferences).Error; err != nil {
+		return nil, fmt.Errorf("查询偏好设置失败: %w", err)
+	}
+	return preferences, nil
+}
+
+// Delete 删除客户端的偏好设置，客户端下次加载时使用默认设置
+func (s *PreferenceService) Delete(clientKey string, actor PreferenceActor) error {
+	preference, err := s.Get(clientKey)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(preference).Error; err != nil {
+		return fmt.Errorf("删除偏好设置失败: %w", err)
+	}
+	s.audit("preferences:delete", "删除看板偏好设置", preference, actor)
+	return nil
+}
+
+// cleanup 删除超过保留时长未更新的偏好设置
+func (s *PreferenceService) cleanup() {
+	cutoff := time.Now().Add(-s.config.Retention)
+	result := s.db.Where("updated_at < ?", cutoff).Delete(&models.ClientPreference{})
+	if result.Error != nil {
+		s.logger.WithError(result.Error).Warn("清理过期偏好设置失败")
+		return
+	}
+	if result.RowsAffected > 0 {
+		s.logger.WithField("count", result.RowsAffected).Info("已清理过期的看板偏好设置")
+	}
+}
+
+// audit 记录偏好设置的修改，只记录操作者及大小，不记录内容
+func (s *PreferenceService) audit(action, message string, preference *models.ClientPreference, actor PreferenceActor) {
+	extra, err := json.Marshal(map[string]interface{}{
+		"client_key": preference.ClientKey,
+		"actor":      actor.Name,
+		"size":       preference.Size,
+	})
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:     "info",
+		Message:   message,
+		Module:    "preferences",
+		Action:    action,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		Extra:     string(extra),
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入审计日志失败")
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+)
+
+// 配置档案打印页格式
+const (
+	SheetFormatText = "text"
+	SheetFormatHTML = "html"
+)
+
+// ErrInvalidProfile 配置档案校验失败
+var ErrInvalidProfile = errors.New("配置档案无效")
+
+// setupCode 已登记的设置码来源
+type setupCode struct {
+	profile string
+	label   string
+}
+
+// ProfileService 扫码枪配置档案服务
+//
+// 档案记录某型号扫码枪依次扫描的设置码，更换设备时按打印页重新配置；
+// 扫码内容命中已登记的设置码或厂商设置码规则时告警，提示设置码被误扫入数据流。
+type ProfileService struct {
+	db       *gorm.DB
+	logger   *logrus.Logger
+	patterns []*regexp.Regexp
+
+	mu        sync.RWMutex
+	known     map[string]setupCode
+	listeners []func(deviceID *uint, event AlertEvent)
+}
+
+// NewProfileService 创建配置档案服务
+func NewProfileService(db *gorm.DB, cfg config.ProfilesConfig, logger *logrus.Logger) (*ProfileService, error) {
+	patterns := make([]*regexp.Regexp, 0, len(cfg.SetupCodePatterns))
+	for _, pattern := range cfg.SetupCodePatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("设置码规则 %s 无效: %w", pattern, err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	return &ProfileService{
+		db:       db,
+		logger:   logger,
+		patterns: patterns,
+		known:    make(map[string]setupCode),
+	}, nil
+}
+
+// OnAlert 注册误扫设置码告警回调
+func (s *ProfileService) OnAlert(listener func(deviceID *uint, event AlertEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Load 加载所有档案中登记的设置码，用于误扫检测
+func (s *ProfileService) Load() error {
+	profiles, err := s.GetProfiles()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]setupCode)
+	for _, profile := range profiles {
+		for _, code := range profile.Codes {
+			known[code.Value] = setupCode{profile: profile.Name, label: code.Label}
+		}
+	}
+
+	s.mu.Lock()
+	s.known = known
+	s.mu.Unlock()
+	return nil
+}
+
+// Observe 检查扫码内容是否为设置码
+func (s *ProfileService) Observe(record *models.BarcodeRecord) {
+	description, ok := s.DetectSetupCode(record.Content)
+	if !ok {
+		return
+	}
+
+	event := AlertEvent{
+		Source:  "setup_code",
+		Level:   "warning",
+		Message: i18n.Sprintf("alert.setup_code_scanned", description, record.Content),
+	}
+	s.logger.WithField("barcode", record.Content).WithField("setup_code", description).Warn("扫描到扫码枪设置码")
+
+	s.mu.RLock()
+	listeners := make([]func(*uint, AlertEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(record.DeviceID, event)
+	}
+}
+
+// DetectSetupCode 判断内容是否为设置码，返回设置码说明
+func (s *ProfileService) DetectSetupCode(content string) (string, bool) {
+	s.mu.RLock()
+	code, ok := s.known[content]
+	s.mu.RUnlock()
+	if ok {
+		if code.label != "" {
+			return fmt.Sprintf("%s / %s", code.profile, code.label), true
+		}
+		return code.profile, true
+	}
+
+	for _, re := range s.patterns {
+		if re.MatchString(content) {
+			return "匹配规则 " + re.String(), true
+		}
+	}
+	return "", false
+}
+
+// GetProfiles 获取全部配置档案
+func (s *ProfileService) GetProfiles() ([]*models.ScannerProfile, error) {
+	var profiles []*models.ScannerProfile
+	if err := s.db.Order("name").Find(&profiles).Error; err != nil {
+		return nil, fmt.Errorf("查询配置档案失败: %w", err)
+	}
+	return profiles, nil
+}
+
+// GetProfile 获取配置档案
+func (s *ProfileService) GetProfile(id uint) (*models.ScannerProfile, error) {
+	var profile models.ScannerProfile
+	if err := s.db.First(&profile, id).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetProfileDevices 获取应用了配置档案的设备
+func (s *ProfileService) GetProfileDevices(id uint) ([]*models.Device, error) {
+	var devices []*models.Device
+	if err := s.db.Where("profile_id = ?", id).Order("id").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("查询设备失败: %w", err)
+	}
+	return devices, nil
+}
+
+// CreateProfile 校验并保存配置档案
+func (s *ProfileService) CreateProfile(profile *models.ScannerProfile) error {
+	if err := validateProfile(profile); err != nil {
+		return err
+	}
+	if err := s.db.Create(profile).Error; err != nil {
+		return fmt.Errorf("保存配置档案失败: %w", err)
+	}
+	s.logger.WithField("profile", profile.Name).Info("配置档案已创建")
+	return s.Load()
+}
+
+// UpdateProfile 校验并更新配置档案
+func (s *ProfileService) UpdateProfile(id uint, profile *models.ScannerProfile) (*models.ScannerProfile, error) {
+	if err := validateProfile(profile); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.GetProfile(id)
+	if err != nil {
+		return nil, err
+	}
+	existing.Name = profile.Name
+	existing.Vendor = profile.Vendor
+	existing.Model = profile.Model
+	existing.Description = profile.Description
+	existing.Codes = profile.Codes
+	if err := s.db.Save(existing).Error; err != nil {
+		return nil, fmt.Errorf("更新配置档案失败: %w", err)
+	}
+
+	s.logger.WithField("profile", existing.Name).Info("配置档案已更新")
+	return existing, s.Load()
+}
+
+// DeleteProfile 删除配置档案，并解除设备上的关联
+func (s *ProfileService) DeleteProfile(id uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Device{}).Where("profile_id = ?", id).Update("profile_id", nil).Error; err != nil {
+			return fmt.Errorf("解除设备关联失败: %w", err)
+		}
+		result := tx.Delete(&models.ScannerProfile{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("删除配置档案失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.Load()
+}
+
+// AssignProfile 为设备指定配置档案，profileID为nil表示解除
+func (s *ProfileService) AssignProfile(deviceID uint, profileID *uint) (*models.Device, error) {
+	var device models.Device
+	if err := s.db.First(&device, deviceID).Error; err != nil {
+		return nil, err
+	}
+	if profileID != nil {
+		if _, err := s.GetProfile(*profileID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Model(&device).Update("profile_id", profileID).Error; err != nil {
+		return nil, fmt.Errorf("更新设备配置档案失败: %w", err)
+	}
+	device.ProfileID = profileID
+
+	s.logger.WithField("device_id", deviceID).WithField("profile_id", profileID).Info("设备配置档案已更新")
+	return &device, nil
+}
+
+// WriteSheet 输出配置档案打印页，按顺序列出设置码供更换设备时扫描
+func (s *ProfileService) WriteSheet(w io.Writer, profile *models.ScannerProfile, format string) error {
+	devices, err := s.GetProfileDevices(profile.ID)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case SheetFormatHTML:
+		return sheetTemplate.Execute(w, struct {
+			Profile *models.ScannerProfile
+			Devices []*models.Device
+		}{profile, devices})
+	case SheetFormatText, "":
+		return writeTextSheet(w, profile, devices)
+	default:
+		return fmt.Errorf("%w: 不支持的打印格式 %s", ErrInvalidProfile, format)
+	}
+}
+
+// writeTextSheet 输出纯文本打印页
+func writeTextSheet(w io.Writer, profile *models.ScannerProfile, devices []*models.Device) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "扫码枪配置档案: %s\n", profile.Name)
+	fmt.Fprintf(tw, "厂商: %s\t型号: %s\n", profile.Vendor, profile.Model)
+	if profile.Description != "" {
+		fmt.Fprintf(tw, "说明: %s\n", profile.Description)
+	}
+	if len(devices) > 0 {
+		names := make([]string, 0, len(devices))
+		for _, device := range devices {
+			names = append(names, device.Name)
+		}
+		fmt.Fprintf(tw, "已应用设备: %s\n", strings.Join(names, ", "))
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "按以下顺序扫描设置码:")
+	for i, code := range profile.Codes {
+		fmt.Fprintf(tw, "%d.\t%s\t%s\n", i+1, code.Label, code.Value)
+	}
+	return tw.Flush()
+}
+
+// validateProfile 校验配置档案
+func validateProfile(profile *models.ScannerProfile) error {
+	if strings.TrimSpace(profile.Name) == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalidProfile)
+	}
+	if len(profile.Codes) == 0 {
+		return fmt.Errorf("%w: 至少需要一个设置码", ErrInvalidProfile)
+	}
+	for i, code := range profile.Codes {
+		if code.Value == "" {
+			return fmt.Errorf("%w: 第 %d 个设置码内容为空", ErrInvalidProfile, i+1)
+		}
+	}
+	return nil
+}
+
+// sheetTemplate 配置档案HTML打印页
+var sheetTemplate = template.Must(template.New("sheet").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="UTF-8">
+<title>扫码枪配置档案 - {{.Profile.Name}}</title>
+<style>
+  body { font-family: sans-serif; margin: 24px; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { border: 1px solid #999; padding: 12px; text-align: left; }
+  td.code { font-family: monospace; font-size: 20px; letter-spacing: 2px; }
+  tr { page-break-inside: avoid; }
+</style>
+</head>
+<body>
+<h1>扫码枪配置档案: {{.Profile.Name}}</h1>
+<p>厂商: {{.Profile.Vendor}}　型号: {{.Profile.Model}}</p>
+{{if .Profile.Description}}<p>说明: {{.Profile.Description}}</p>{{end}}
+{{if .Devices}}<p>已应用设备: {{range $i, $d := .Devices}}{{if $i}}, {{end}}{{$d.Name}}{{end}}</p>{{end}}
+<p>按以下顺序扫描设置码:</p>
+<table>
+<tr><th>#</th><th>说明</th><th>设置码</th></tr>
+{{range $i, $c := .Profile.Codes}}<tr><td>{{inc $i}}</td><td>{{$c.Label}}</td><td class="code">{{$c.Value}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
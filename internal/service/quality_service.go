@@ -0,0 +1,375 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/i18n"
+	"userclient/internal/models"
+	"userclient/pkg/barcode"
+)
+
+// qualityTickInterval 检查统计窗口是否结束的间隔
+const qualityTickInterval = time.Minute
+
+// qualityCode128 按规范字符集检查的条码类型
+const qualityCode128 = "Code 128"
+
+// qualityRecord 计算数据质量时读取的扫码记录字段
+type qualityRecord struct {
+	ID       uint
+	Content  string
+	Type     string
+	DeviceID *uint
+}
+
+// QualityService 数据质量报告
+//
+// 按处理器的校验规则检查时间范围内的扫码：GS1码制（EAN-8、UPC-A、EAN-13、ITF-14）的校验位、
+// Code 128 是否只含规范内的字符、条码格式是否有效，按类型及设备统计不合格的扫码。扫码记录按批读取，
+// 统计窗口再长也不会一次载入内存。每个统计窗口结束时保存一份全工位的报告，不合格比例（全工位、
+// 各类型、各设备）相比上一窗口上升超过阈值时告警。模拟扫码不计入。
+type QualityService struct {
+	db        *gorm.DB
+	config    config.QualityConfig
+	processor *barcode.Processor
+	code128   *regexp.Regexp
+	logger    *logrus.Logger
+	done      chan struct{}
+	wg        sync.WaitGroup
+
+	mu        sync.Mutex
+	listeners []func(AlertEvent)
+}
+
+// NewQualityService 创建数据质量报告服务，code128_pattern 已在配置校验时检查
+func NewQualityService(db *gorm.DB, processor *barcode.Processor, cfg config.QualityConfig, logger *logrus.Logger) (*QualityService, error) {
+	code128, err := regexp.Compile(cfg.Code128Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Code 128 规范字符集无效: %w", err)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	return &QualityService{
+		db:        db,
+		config:    cfg,
+		processor: processor,
+		code128:   code128,
+		logger:    logger,
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// OnAlert 注册数据质量退化告警的回调
+func (s *QualityService) OnAlert(listener func(AlertEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Start 启动定时任务，每个统计窗口结束时生成报告
+func (s *QualityService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(qualityTickInterval)
+		defer ticker.Stop()
+
+		s.tick(time.Now())
+		for {
+			select {
+			case now := <-ticker.C:
+				s.tick(now)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时任务
+func (s *QualityService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// tick 上一窗口已结束时生成并保存报告，清理过期报告
+//
+// 窗口首尾相接，停机期间错过的窗口不补算，重启后从当前时间往前一个窗口开始。
+func (s *QualityService) tick(now time.Time) {
+	last, err := s.latest()
+	if err != nil {
+		s.logger.WithError(err).Warn("查询上一份数据质量报告失败")
+		return
+	}
+
+	from := now.Add(-s.config.Window)
+	if last != nil {
+		if now.Before(last.To.Add(s.config.Window)) {
+			return
+		}
+		if last.To.After(from) {
+			from = last.To
+		}
+	}
+	to := from.Add(s.config.Window)
+
+	report, err := s.Report(from, to, nil)
+	if err != nil {
+		s.logger.WithError(err).Warn("生成数据质量报告失败")
+		return
+	}
+	if err := s.db.Create(report).Error; err != nil {
+		s.logger.WithError(err).Warn("保存数据质量报告失败")
+		return
+	}
+	s.logger.WithFields(logrus.Fields{
+		"from":           report.From,
+		"to":             report.To,
+		"total":          report.Total,
+		"malformed_rate": report.MalformedRate,
+	}).Info("已生成数据质量报告")
+
+	if last != nil && last.To.Equal(report.From) {
+		s.compare(last, report)
+	}
+	s.cleanup(now)
+}
+
+// latest 最近一份保存的报告，没有时返回nil
+func (s *QualityService) latest() (*models.QualityReport, error) {
+	var report models.QualityReport
+	err := s.db.Where("device_id IS NULL").Order("\"to\" DESC").First(&report).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Report 计算 [from, to) 内扫码的数据质量，deviceID 非nil时只统计该设备，结果不保存
+func (s *QualityService) Report(from, to time.Time, deviceID *uint) (*models.QualityReport, error) {
+	report := &models.QualityReport{From: from, To: to, DeviceID: deviceID}
+	types := make(map[string]*models.QualityTypeStats)
+	devices := make(map[uint]*models.QualityDeviceStats)
+	var unassigned *models.QualityDeviceStats
+
+	query := s.db.Model(&models.BarcodeRecord{}).
+		Select("id, content, type, device_id").
+		Where("created_at >= ? AND created_at < ? AND source <> ?", from.UTC(), to.UTC(), models.BarcodeSourceSimulation)
+	if deviceID != nil {
+		query = query.Where("device_id = ?", *deviceID)
+	}
+
+	var batch []qualityRecord
+	err := query.FindInBatches(&batch, s.config.BatchSize, func(tx *gorm.DB, _ int) error {
+		for _, record := range batch {
+			malformed := s.check(record, types)
+			report.Total++
+
+			var device *models.QualityDeviceStats
+			if record.DeviceID == nil {
+				if unassigned == nil {
+					unassigned = &models.QualityDeviceStats{Name: "未关联设备"}
+				}
+				device = unassigned
+			} else {
+				device = devices[*record.DeviceID]
+				if device == nil {
+					id := *record.DeviceID
+					device = &models.QualityDeviceStats{DeviceID: &id}
+					devices[id] = device
+				}
+			}
+			device.Total++
+			if malformed {
+				report.Malformed++
+				device.Malformed++
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("读取扫码记录失败: %w", err)
+	}
+
+	report.MalformedRate = percent(report.Malformed, report.Total)
+	report.Breakdown.ByType = make([]models.QualityTypeStats, 0, len(types))
+	for _, stats := range types {
+		stats.ValidRate = percent(stats.Total-stats.Malformed, stats.Total)
+		report.Breakdown.ByType = append(report.Breakdown.ByType, *stats)
+	}
+	sort.Slice(report.Breakdown.ByType, func(i, j int) bool {
+		a, b := report.Breakdown.ByType[i], report.Breakdown.ByType[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Type < b.Type
+	})
+
+	s.nameDevices(devices)
+	report.Breakdown.ByDevice = make([]models.QualityDeviceStats, 0, len(devices)+1)
+	for _, stats := range devices {
+		report.Breakdown.ByDevice = append(report.Breakdown.ByDevice, *stats)
+	}
+	if unassigned != nil {
+		report.Breakdown.ByDevice = append(report.Breakdown.ByDevice, *unassigned)
+	}
+	for i := range report.Breakdown.ByDevice {
+		stats := &report.Breakdown.ByDevice[i]
+		stats.MalformedRate = percent(stats.Malformed, stats.Total)
+	}
+	sort.Slice(report.Breakdown.ByDevice, func(i, j int) bool {
+		a, b := report.Breakdown.ByDevice[i], report.Breakdown.ByDevice[j]
+		if a.Malformed != b.Malformed {
+			return a.Malformed > b.Malformed
+		}
+		return a.Total > b.Total
+	})
+	return report, nil
+}
+
+// check 按码制规则检查一条扫码，计入所属类型的统计，返回是否不合格
+func (s *QualityService) check(record qualityRecord, types map[string]*models.QualityTypeStats) bool {
+	stats := types[record.Type]
+	if stats == nil {
+		stats = &models.QualityTypeStats{Type: record.Type}
+		types[record.Type] = stats
+	}
+	stats.Total++
+
+	malformed := false
+	if valid, applicable := s.processor.ValidateCheckDigit(record.Content); applicable {
+		stats.CheckDigitChecked++
+		if !valid {
+			stats.CheckDigitFailures++
+			malformed = true
+		}
+	}
+	if record.Type == qualityCode128 && !s.code128.MatchString(record.Content) {
+		stats.SpecViolations++
+		malformed = true
+	}
+	if valid, _ := s.processor.ValidateBarcode(record.Content); !valid {
+		malformed = true
+	}
+	if malformed {
+		stats.Malformed++
+	}
+	return malformed
+}
+
+// nameDevices 填充设备名称，已删除的设备同样显示名称
+func (s *QualityService) nameDevices(devices map[uint]*models.QualityDeviceStats) {
+	if len(devices) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(devices))
+	for id := range devices {
+		ids = append(ids, id)
+	}
+	var rows []models.Device
+	if err := s.db.Unscoped().Select("id, name").Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		s.logger.WithError(err).Warn("查询设备名称失败")
+		return
+	}
+	for _, row := range rows {
+		devices[row.ID].Name = row.Name
+	}
+}
+
+// compare 与上一窗口的报告对比，不合格比例上升超过阈值时告警
+func (s *QualityService) compare(previous, current *models.QualityReport) {
+	threshold := s.config.RegressionThreshold
+	regressed := func(before, after float64, total int64) bool {
+		return total >= s.config.MinScans && after-before >= threshold
+	}
+
+	var messages []string
+	if regressed(previous.MalformedRate, current.MalformedRate, current.Total) {
+		messages = append(messages, fmt.Sprintf("不合格扫码比例由 %.1f%% 升至 %.1f%%", previous.MalformedRate, current.MalformedRate))
+	}
+
+	before := make(map[string]models.QualityTypeStats)
+	for _, stats := range previous.Breakdown.ByType {
+		before[stats.Type] = stats
+	}
+	for _, stats := range current.Breakdown.ByType {
+		prev, ok := before[stats.Type]
+		if ok && regressed(100-prev.ValidRate, 100-stats.ValidRate, stats.Total) {
+			messages = append(messages, fmt.Sprintf("%s 合格率由 %.1f%% 降至 %.1f%%", stats.Type, prev.ValidRate, stats.ValidRate))
+		}
+	}
+
+	beforeDevices := make(map[uint]models.QualityDeviceStats)
+	for _, stats := range previous.Breakdown.ByDevice {
+		if stats.DeviceID != nil {
+			beforeDevices[*stats.DeviceID] = stats
+		}
+	}
+	for _, stats := range current.Breakdown.ByDevice {
+		if stats.DeviceID == nil {
+			continue
+		}
+		prev, ok := beforeDevices[*stats.DeviceID]
+		if ok && regressed(prev.MalformedRate, stats.MalformedRate, stats.Total) {
+			messages = append(messages, fmt.Sprintf("设备 %s 不合格扫码比例由 %.1f%% 升至 %.1f%%", stats.Name, prev.MalformedRate, stats.MalformedRate))
+		}
+	}
+
+	for _, message := range messages {
+		s.logger.WithField("to", current.To).Warn("数据质量退化: " + message)
+		s.notify(AlertEvent{Source: "data_quality", Level: "warning", Message: i18n.Sprintf("alert.quality_degraded", message)})
+	}
+}
+
+// cleanup 删除超过保留时长的报告
+func (s *QualityService) cleanup(now time.Time) {
+	if s.config.Retention <= 0 {
+		return
+	}
+	if err := s.db.Where("\"to\" < ?", now.Add(-s.config.Retention)).Delete(&models.QualityReport{}).Error; err != nil {
+		s.logger.WithError(err).Warn("清理过期数据质量报告失败")
+	}
+}
+
+// Reports 保存的报告，按窗口从新到旧，最多 limit 份
+func (s *QualityService) Reports(limit int) ([]models.QualityReport, error) {
+	reports := []models.QualityReport{}
+	if err := s.db.Where("device_id IS NULL").Order("\"to\" DESC").Limit(limit).Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("查询数据质量报告失败: %w", err)
+	}
+	return reports, nil
+}
+
+// notify 通知数据质量告警
+func (s *QualityService) notify(event AlertEvent) {
+	s.mu.Lock()
+	listeners := make([]func(AlertEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.Unlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// percent 百分比，保留一位小数，total 为0时为0
+func percent(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
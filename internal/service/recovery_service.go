@@ -0,0 +1,242 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// runStateKey 运行状态在配置表中的键
+const runStateKey = "recovery.run_state"
+
+// RunState 运行状态，启动时写入 running=true，正常停止时写入 false；启动时仍为 true 表示上次非正常退出
+type RunState struct {
+	Running   bool       `json:"running"`
+	PID       int        `json:"pid"`
+	StartedAt time.Time  `json:"started_at"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+}
+
+// RecoverySummary 启动恢复的结果
+type RecoverySummary struct {
+	Unclean           bool       `json:"unclean"`                       // 上次运行非正常退出（断电、强制结束进程）
+	PreviousStartedAt *time.Time `json:"previous_started_at,omitempty"` // 非正常退出的那次运行的启动时间
+	LastScanAt        *time.Time `json:"last_scan_at,omitempty"`        // 最后一条已入库扫码的时间，之后的扫码可能已丢失
+	RecordsRecovered  int64      `json:"records_recovered"`             // 上次运行期间已入库、重启后完整保留的扫码数
+	SessionsResumed   int        `json:"sessions_resumed"`              // 继续跟踪的序号会话数
+	SessionsClosed    int64      `json:"sessions_closed"`               // 结束的序号会话数
+	OutboxPending     int64      `json:"outbox_pending"`                // 立即重新投递的出站积压
+	MaintenanceActive bool       `json:"maintenance_active"`            // 上次运行遗留了维护模式
+	MaintenanceEnded  bool       `json:"maintenance_ended"`             // 已按配置结束遗留的维护模式
+	RecoveredAt       time.Time  `json:"recovered_at"`
+}
+
+// Text 一行文字说明，用于启动摘要及系统日志，时间按 loc 显示
+func (s RecoverySummary) Text(loc *time.Location) string {
+	if !s.Unclean {
+		return "上次运行正常停止"
+	}
+	parts := []string{"上次运行非正常退出"}
+	if s.PreviousStartedAt != nil {
+		parts = append(parts, fmt.Sprintf("该次运行于 %s 启动，期间入库 %d 条扫码", s.PreviousStartedAt.In(loc).Format(time.RFC3339), s.RecordsRecovered))
+	}
+	if s.LastScanAt != nil {
+		parts = append(parts, "最后入库的扫码时间 "+s.LastScanAt.In(loc).Format(time.RFC3339))
+	} else {
+		parts = append(parts, "没有已入库的扫码")
+	}
+	if s.SessionsResumed > 0 {
+		parts = append(parts, fmt.Sprintf("继续 %d 个序号跟踪会话", s.SessionsResumed))
+	}
+	if s.SessionsClosed > 0 {
+		parts = append(parts, fmt.Sprintf("结束 %d 个序号跟踪会话", s.SessionsClosed))
+	}
+	if s.OutboxPending > 0 {
+		parts = append(parts, fmt.Sprintf("重新投递 %d 条出站积压", s.OutboxPending))
+	}
+	switch {
+	case s.MaintenanceEnded:
+		parts = append(parts, "已结束遗留的维护模式")
+	case s.MaintenanceActive:
+		parts = append(parts, "仍处于维护模式")
+	}
+	return strings.Join(parts, "，")
+}
+
+// RecoveryService 启动恢复
+//
+// 启动时在配置表中写入运行标记，正常停止时清除；启动时标记仍在表示上次运行非正常退出（断电、强制结束进程），
+// 此时按配置继续或结束上次运行中的序号跟踪会话、处理遗留的维护模式、取消出站积压的退避立即重新投递，
+// 并统计上次运行期间已入库的扫码及最后入库的扫码时间，便于操作员确认丢失范围。
+type RecoveryService struct {
+	db            *gorm.DB
+	configService *ConfigService
+	maintenance   *MaintenanceService
+	sequence      *SequenceService
+	outbox        *OutboxService
+	config        config.RecoveryConfig
+	logger        *logrus.Logger
+
+	mu      sync.RWMutex
+	state   RunState
+	summary *RecoverySummary
+}
+
+// NewRecoveryService 创建启动恢复服务
+func NewRecoveryService(db *gorm.DB, configService *ConfigService, maintenance *MaintenanceService, sequence *SequenceService, cfg config.RecoveryConfig, logger *logrus.Logger) *RecoveryService {
+	return &RecoveryService{
+		db:            db,
+		configService: configService,
+		maintenance:   maintenance,
+		sequence:      sequence,
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// SetOutbox 设置出站事件日志，非正常退出后取消积压的退避立即重新投递
+func (s *RecoveryService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// Recover 检查上次运行是否正常停止，非正常退出时对账，随后写入本次运行标记
+//
+// 须在恢复维护模式状态之后、开始接收扫码及出站投递之前调用。对账中的单项失败只记录日志，不影响启动。
+func (s *RecoveryService) Recover() (*RecoverySummary, error) {
+	previous, err := s.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RecoverySummary{RecoveredAt: time.Now()}
+	if previous != nil && previous.Running {
+		summary.Unclean = true
+		startedAt := previous.StartedAt
+		summary.PreviousStartedAt = &startedAt
+		s.reconcile(summary)
+	}
+
+	s.mu.Lock()
+	s.summary = summary
+	s.state = RunState{Running: true, PID: os.Getpid(), StartedAt: time.Now()}
+	state := s.state
+	s.mu.Unlock()
+	if err := s.saveState(state); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// MarkStopped 写入正常停止标记，下次启动时不做恢复
+func (s *RecoveryService) MarkStopped() error {
+	s.mu.Lock()
+	if !s.state.Running {
+		// 未写入运行标记（如启动中途失败），保留上次的状态
+		s.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	s.state.Running = false
+	s.state.StoppedAt = &now
+	state := s.state
+	s.mu.Unlock()
+	return s.saveState(state)
+}
+
+// Summary 本次启动的恢复结果，尚未检查时为nil
+func (s *RecoveryService) Summary() *RecoverySummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.summary
+}
+
+// reconcile 统计上次运行期间的扫码并对账会话、维护模式及出站积压
+func (s *RecoveryService) reconcile(summary *RecoverySummary) {
+	since := summary.PreviousStartedAt.UTC()
+
+	var last models.BarcodeRecord
+	err := s.db.Select("created_at").Order("created_at DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		s.logger.WithError(err).Warn("查询最后入库的扫码失败")
+	} else if !last.CreatedAt.IsZero() {
+		summary.LastScanAt = &last.CreatedAt
+	}
+	if err := s.db.Model(&models.BarcodeRecord{}).Where("created_at >= ?", since).Count(&summary.RecordsRecovered).Error; err != nil {
+		s.logger.WithError(err).Warn("统计上次运行期间的扫码失败")
+	}
+
+	// 序号跟踪会话：继续时保留最后序号，结束时删除，下次扫码重新开始跟踪
+	if s.sequence != nil {
+		if s.config.Sessions == config.RecoverySessionsClose {
+			closed, err := s.sequence.CloseSessions(since)
+			if err != nil {
+				s.logger.WithError(err).Warn("结束序号跟踪会话失败")
+			}
+			summary.SessionsClosed = closed
+		} else {
+			sessions, err := s.sequence.ActiveSessions(since, time.Now().UTC())
+			if err != nil {
+				s.logger.WithError(err).Warn("查询序号跟踪会话失败")
+			}
+			summary.SessionsResumed = len(sessions)
+		}
+	}
+
+	// 维护模式状态已从配置表恢复，遗留的维护模式按配置结束
+	if s.maintenance != nil && s.maintenance.IsActive() {
+		summary.MaintenanceActive = true
+		if s.config.EndMaintenance {
+			if _, err := s.maintenance.End(); err != nil {
+				s.logger.WithError(err).Warn("结束遗留的维护模式失败")
+			} else {
+				summary.MaintenanceEnded = true
+			}
+		}
+	}
+
+	if s.outbox != nil {
+		pending, err := s.outbox.RetryPending()
+		if err != nil {
+			s.logger.WithError(err).Warn("重新投递出站积压失败")
+		}
+		summary.OutboxPending = pending
+	}
+}
+
+// loadState 读取上次写入的运行状态，没有记录时返回nil
+func (s *RecoveryService) loadState() (*RunState, error) {
+	stored, err := s.configService.GetConfiguration(runStateKey)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取运行状态失败: %w", err)
+	}
+	var state RunState
+	if err := json.Unmarshal([]byte(stored.Value), &state); err != nil {
+		return nil, fmt.Errorf("解析运行状态失败: %w", err)
+	}
+	return &state, nil
+}
+
+// saveState 持久化运行状态
+func (s *RecoveryService) saveState(state RunState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("序列化运行状态失败: %w", err)
+	}
+	if err := s.configService.SetConfiguration(runStateKey, string(data), "system", "运行状态（非正常退出检测）"); err != nil {
+		return fmt.Errorf("保存运行状态失败: %w", err)
+	}
+	return nil
+}
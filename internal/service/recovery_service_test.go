@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// recoveryChildEnv 设置时测试进程作为被强制结束的子进程运行，值为数据库路径
+const recoveryChildEnv = "RECOVERY_TEST_CHILD_DB"
+
+// childScans 子进程在被强制结束前入库的扫码
+var childScans = []string{"SN000101", "SN000102", "SN000103"}
+
+// startRecovery 模拟一次进程启动：创建启动恢复服务及其依赖并执行恢复
+func startRecovery(t *testing.T, db *database.DB, cfg config.RecoveryConfig) (*RecoveryService, *RecoverySummary, *MaintenanceService) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	configs := NewConfigService(db.DB, logger)
+	maintenance := NewMaintenanceService(configs, logger)
+	if err := maintenance.Load(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(maintenance.Close)
+	sequence, err := NewSequenceService(db.DB, config.SequenceConfig{
+		Enable: true,
+		Rules:  []config.SequenceRule{{Name: "serial", Pattern: `^(SN)(\d+)$`}},
+	}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovery := NewRecoveryService(db.DB, configs, maintenance, sequence, cfg, logger)
+	recovery.SetOutbox(NewOutboxService(db.DB, config.OutboxConfig{}, logger))
+	summary, err := recovery.Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recovery, summary, maintenance
+}
+
+// runKilledChild 在子进程中启动并入库扫码、开启维护模式，随后强制结束（kill -9），不执行任何停止流程
+func runKilledChild(t *testing.T, path string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRecoveryAfterKill$")
+	cmd.Env = append(os.Environ(), recoveryChildEnv+"="+path)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if scanner.Text() == "ready" {
+				ready <- nil
+				return
+			}
+		}
+		ready <- fmt.Errorf("child exited before ready: %v", scanner.Err())
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(30 * time.Second):
+		err = fmt.Errorf("child not ready")
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recoveryChild 子进程：正常启动后入库扫码、推进序号会话、开启维护模式并留下出站积压，然后等待被结束
+func recoveryChild(t *testing.T, path string) {
+	db := openTestDB(t, path)
+	_, _, maintenance := startRecovery(t, db, config.RecoveryConfig{Enable: true})
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	sequence, _ := NewSequenceService(db.DB, config.SequenceConfig{
+		Enable: true,
+		Rules:  []config.SequenceRule{{Name: "serial", Pattern: `^(SN)(\d+)$`}},
+	}, logger)
+	for _, content := range childScans {
+		record := &models.BarcodeRecord{ScanID: "scan-" + content, Content: content, Length: len(content), Type: "CODE128", Status: "success"}
+		if err := db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+		sequence.Observe(record)
+	}
+	if _, err := maintenance.Start("更换标签", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.OutboxDelivery{EventID: 1, Sink: "webhook", Status: models.OutboxStatusPending, NextAttemptAt: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("ready")
+	select {}
+}
+
+// TestRecoveryAfterKill 进程被强制结束（kill -9）后再次启动：识别为非正常退出，统计该次运行已入库的扫码，
+// 按配置继续或结束序号会话、处理遗留的维护模式并立即重新投递出站积压；正常停止后的启动不做恢复
+func TestRecoveryAfterKill(t *testing.T) {
+	if path := os.Getenv(recoveryChildEnv); path != "" {
+		recoveryChild(t, path)
+		return
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.RecoveryConfig
+	}{
+		{name: "resume", cfg: config.RecoveryConfig{Enable: true, Sessions: config.RecoverySessionsResume}},
+		{name: "close and end maintenance", cfg: config.RecoveryConfig{Enable: true, Sessions: config.RecoverySessionsClose, EndMaintenance: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "recovery.db")
+			// 更早的运行留下的扫码及序号会话不计入
+			db := openTestDB(t, path)
+			earlier := time.Now().Add(-time.Hour)
+			if err := db.Create(&models.BarcodeRecord{ScanID: "scan-SN000001", Content: "SN000001", Length: 8, Status: "success", CreatedAt: earlier}).Error; err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Create(&models.SequenceState{Rule: "serial", Prefix: "SN", DeviceID: 9, LastValue: 1, Width: 6, CreatedAt: earlier, UpdatedAt: earlier}).Error; err != nil {
+				t.Fatal(err)
+			}
+			db.Close()
+
+			runKilledChild(t, path)
+
+			db = openTestDB(t, path)
+			defer db.Close()
+			recovery, summary, maintenance := startRecovery(t, db, tt.cfg)
+			if !summary.Unclean || summary.PreviousStartedAt == nil {
+				t.Fatalf("summary = %+v, want unclean shutdown", summary)
+			}
+			if summary.RecordsRecovered != int64(len(childScans)) {
+				t.Errorf("records recovered = %d, want %d", summary.RecordsRecovered, len(childScans))
+			}
+			var last models.BarcodeRecord
+			db.Where("content = ?", childScans[len(childScans)-1]).First(&last)
+			if summary.LastScanAt == nil || !summary.LastScanAt.Equal(last.CreatedAt) {
+				t.Errorf("last scan at = %v, want %v", summary.LastScanAt, last.CreatedAt)
+			}
+			if summary.OutboxPending != 1 {
+				t.Errorf("outbox pending = %d, want 1", summary.OutboxPending)
+			}
+			var delivery models.OutboxDelivery
+			db.First(&delivery)
+			if delivery.NextAttemptAt.After(time.Now()) {
+				t.Errorf("outbox backoff not cancelled: next attempt %v", delivery.NextAttemptAt)
+			}
+			if !summary.MaintenanceActive {
+				t.Error("leftover maintenance mode not reported")
+			}
+
+			var sessions int64
+			db.Model(&models.SequenceState{}).Count(&sessions)
+			switch tt.cfg.Sessions {
+			case config.RecoverySessionsClose:
+				if summary.SessionsClosed != 1 || sessions != 1 {
+					t.Errorf("sessions closed = %d, remaining = %d, want 1 closed and the earlier one kept", summary.SessionsClosed, sessions)
+				}
+				if !summary.MaintenanceEnded || maintenance.IsActive() {
+					t.Error("leftover maintenance mode not ended")
+				}
+			default:
+				if summary.SessionsResumed != 1 || sessions != 2 {
+					t.Errorf("sessions resumed = %d, remaining = %d, want 1 resumed", summary.SessionsResumed, sessions)
+				}
+				if summary.MaintenanceEnded || !maintenance.IsActive() {
+					t.Error("maintenance mode ended without end_maintenance")
+				}
+				if text := summary.Text(time.UTC); !strings.Contains(text, "仍处于维护模式") || !strings.Contains(text, "期间入库 3 条扫码") {
+					t.Errorf("text = %s", text)
+				}
+			}
+
+			// 正常停止后再次启动不做恢复
+			if err := recovery.MarkStopped(); err != nil {
+				t.Fatal(err)
+			}
+			if _, summary, _ = startRecovery(t, db, tt.cfg); summary.Unclean {
+				t.Fatalf("summary after clean stop = %+v", summary)
+			}
+		})
+	}
+}
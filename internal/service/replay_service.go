@@ -0,0 +1,442 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+var (
+	// ErrInvalidReplay 回放请求无效
+	ErrInvalidReplay = errors.New("回放请求无效")
+	// ErrReplayState 回放任务当前状态不允许该操作
+	ErrReplayState = errors.New("回放任务状态不允许该操作")
+)
+
+// errReplayCancelled 回放任务被取消
+var errReplayCancelled = errors.New("回放已取消")
+
+// errReplayStopped 服务停止，任务保持运行状态以便重启后继续
+var errReplayStopped = errors.New("回放服务已停止")
+
+// ReplayDeliverer 回放输出端投递函数，payload 为带 replay 标记的出站事件载荷
+type ReplayDeliverer func(payload []byte) error
+
+// ReplayRequest 回放请求
+type ReplayRequest struct {
+	From      time.Time
+	To        time.Time
+	Sinks     []string
+	Filter    models.ReplayFilter
+	CreatedBy string
+}
+
+// ReplayService 历史扫码记录回放服务
+//
+// 按记录ID顺序将时间范围内的扫码记录重新投递到指定输出端，供新接入的下游补数。
+// 载荷与实时扫码事件相同（event_id 不变，下游可按其去重），另带 replay 标记。
+// 同一时间只运行一个任务，其余任务排队；每批投递后保存检查点，
+// 投递失败、取消或重启后可从检查点继续。
+type ReplayService struct {
+	db     *gorm.DB
+	config config.ReplayConfig
+	logger *logrus.Logger
+
+	mu        sync.Mutex
+	sinks     map[string]ReplayDeliverer
+	currentID uint
+	cancel    chan struct{}
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	schemaCheck bool
+}
+
+// NewReplayService 创建回放服务
+func NewReplayService(db *gorm.DB, cfg config.ReplayConfig, logger *logrus.Logger) *ReplayService {
+	if cfg.Rate <= 0 {
+		cfg.Rate = 50
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &ReplayService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+		sinks:  make(map[string]ReplayDeliverer),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// SetSchemaCheck 按结构定义校验回放事件，用于调试及测试环境，不符时只记录错误日志
+func (s *ReplayService) SetSchemaCheck(enabled bool) {
+	s.schemaCheck = enabled
+}
+
+// RegisterSink 注册可回放的输出端，需在Start之前调用
+func (s *ReplayService) RegisterSink(name string, deliver ReplayDeliverer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sinks[name] = deliver
+}
+
+// Sinks 可回放的输出端名称
+func (s *ReplayService) Sinks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.sinks))
+	for name := range s.sinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start 启动回放协程，继续上次未完成的任务
+func (s *ReplayService) Start() {
+	s.wg.Add(1)
+	go s.run()
+	s.notify()
+}
+
+// Close 停止回放，正在运行的任务保存检查点后退出
+func (s *ReplayService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Submit 创建回放任务，已有任务运行时排队等待
+func (s *ReplayService) Submit(req ReplayRequest) (*models.ReplayJob, error) {
+	if req.From.IsZero() || req.To.IsZero() || !req.To.After(req.From) {
+		return nil, fmt.Errorf("%w: 结束时间须晚于开始时间", ErrInvalidReplay)
+	}
+	if len(req.Sinks) == 0 {
+		return nil, fmt.Errorf("%w: 未指定输出端", ErrInvalidReplay)
+	}
+
+	sinks := make(models.StringList, 0, len(req.Sinks))
+	s.mu.Lock()
+	for _, name := range req.Sinks {
+		if _, ok := s.sinks[name]; !ok {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("%w: 输出端 %s 未启用", ErrInvalidReplay, name)
+		}
+		if !sinks.Contains(name) {
+			sinks = append(sinks, name)
+		}
+	}
+	s.mu.Unlock()
+
+	job := &models.ReplayJob{
+		From:      req.From,
+		To:        req.To,
+		Sinks:     sinks,
+		Filter:    req.Filter,
+		Status:    models.ReplayStatusPending,
+		CreatedBy: req.CreatedBy,
+	}
+	if err := s.recordQuery(job).Count(&job.Total).Error; err != nil {
+		return nil, fmt.Errorf("统计回放记录失败: %w", err)
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("创建回放任务失败: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"job":   job.ID,
+		"from":  job.From,
+		"to":    job.To,
+		"sinks": job.Sinks,
+		"total": job.Total,
+	}).Info("已创建回放任务")
+	s.notify()
+	return job, nil
+}
+
+// GetJob 获取回放任务
+func (s *ReplayService) GetJob(id uint) (*models.ReplayJob, error) {
+	var job models.ReplayJob
+	if err := s.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs 最近的回放任务
+func (s *ReplayService) ListJobs(limit int) ([]*models.ReplayJob, error) {
+	var jobs []*models.ReplayJob
+	if err := s.db.Order("id DESC").Limit(limit).Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Cancel 取消排队或运行中的任务，检查点保留，可继续
+func (s *ReplayService) Cancel(id uint) (*models.ReplayJob, error) {
+	// 持有锁期间回放协程不会取走排队的任务
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, err := s.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.currentID == id && s.cancel != nil {
+		close(s.cancel)
+		s.cancel = nil
+		s.logger.WithField("job", id).Info("正在取消回放任务")
+		return job, nil
+	}
+	if job.Status != models.ReplayStatusPending && job.Status != models.ReplayStatusRunning {
+		return nil, fmt.Errorf("%w: 任务状态为 %s", ErrReplayState, job.Status)
+	}
+	now := time.Now()
+	job.Status = models.ReplayStatusCancelled
+	job.FinishedAt = &now
+	if err := s.db.Save(job).Error; err != nil {
+		return nil, fmt.Errorf("保存回放任务失败: %w", err)
+	}
+	return job, nil
+}
+
+// Resume 从检查点继续失败或已取消的任务，重新排队
+func (s *ReplayService) Resume(id uint) (*models.ReplayJob, error) {
+	job, err := s.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ReplayStatusFailed && job.Status != models.ReplayStatusCancelled {
+		return nil, fmt.Errorf("%w: 任务状态为 %s", ErrReplayState, job.Status)
+	}
+
+	job.Status = models.ReplayStatusPending
+	job.Error = ""
+	job.FinishedAt = nil
+	if err := s.db.Save(job).Error; err != nil {
+		return nil, fmt.Errorf("保存回放任务失败: %w", err)
+	}
+	s.logger.WithField("job", id).WithField("last_record_id", job.LastRecordID).Info("回放任务已重新排队")
+	s.notify()
+	return job, nil
+}
+
+// notify 唤醒回放协程
+func (s *ReplayService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 依次执行排队的任务
+func (s *ReplayService) run() {
+	defer s.wg.Done()
+
+	for {
+		for s.runNext() {
+		}
+
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// runNext 执行最早的待运行任务，没有任务或服务停止时返回false
+func (s *ReplayService) runNext() bool {
+	cancel := make(chan struct{})
+	s.mu.Lock()
+	var jobs []models.ReplayJob
+	err := s.db.Where("status IN ?", []string{models.ReplayStatusRunning, models.ReplayStatusPending}).
+		Order("id").
+		Limit(1).
+		Find(&jobs).Error
+	if err == nil && len(jobs) > 0 {
+		s.currentID = jobs[0].ID
+		s.cancel = cancel
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.WithError(err).Warn("读取回放任务失败")
+		return false
+	}
+	if len(jobs) == 0 {
+		return false
+	}
+	job := jobs[0]
+	defer func() {
+		s.mu.Lock()
+		s.currentID = 0
+		s.cancel = nil
+		s.mu.Unlock()
+	}()
+
+	err = s.execute(&job, cancel)
+	now := time.Now()
+	switch {
+	case errors.Is(err, errReplayStopped):
+		s.logger.WithField("job", job.ID).WithField("processed", job.Processed).Info("回放服务停止，任务将在重启后继续")
+		return false
+	case errors.Is(err, errReplayCancelled):
+		job.Status = models.ReplayStatusCancelled
+		s.logger.WithField("job", job.ID).WithField("processed", job.Processed).Info("回放任务已取消")
+	case err != nil:
+		job.Status = models.ReplayStatusFailed
+		job.Error = err.Error()
+		s.logger.WithError(err).WithField("job", job.ID).WithField("last_record_id", job.LastRecordID).Warn("回放任务失败")
+	default:
+		job.Status = models.ReplayStatusCompleted
+		s.logger.WithField("job", job.ID).WithField("processed", job.Processed).Info("回放任务已完成")
+	}
+	job.FinishedAt = &now
+	if err := s.db.Save(&job).Error; err != nil {
+		s.logger.WithError(err).WithField("job", job.ID).Error("保存回放任务失败")
+	}
+	return true
+}
+
+// execute 从检查点开始按批回放，每批完成后保存检查点
+func (s *ReplayService) execute(job *models.ReplayJob, cancel <-chan struct{}) error {
+	sinks, err := s.jobSinks(job)
+	if err != nil {
+		return err
+	}
+
+	if job.StartedAt == nil {
+		now := time.Now()
+		job.StartedAt = &now
+	}
+	job.Status = models.ReplayStatusRunning
+	if err := s.db.Save(job).Error; err != nil {
+		return fmt.Errorf("保存回放任务失败: %w", err)
+	}
+
+	limiter := time.NewTicker(time.Second / time.Duration(s.config.Rate))
+	defer limiter.Stop()
+
+	for {
+		var records []*models.BarcodeRecord
+		err := s.recordQuery(job).
+			Where("id > ?", job.LastRecordID).
+			Order("id").
+			Limit(s.config.BatchSize).
+			Find(&records).Error
+		if err != nil {
+			return fmt.Errorf("读取回放记录失败: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		for _, record := range records {
+			select {
+			case <-limiter.C:
+			case <-cancel:
+				return s.checkpoint(job, errReplayCancelled)
+			case <-s.done:
+				return s.checkpoint(job, errReplayStopped)
+			}
+
+			if err := s.deliver(job, record, sinks); err != nil {
+				return s.checkpoint(job, err)
+			}
+			job.LastRecordID = record.ID
+			job.Processed++
+		}
+		if err := s.checkpoint(job, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// deliver 将一条记录投递到任务的各输出端
+func (s *ReplayService) deliver(job *models.ReplayJob, record *models.BarcodeRecord, sinks map[string]ReplayDeliverer) error {
+	// 公开ID尚未回填的历史记录按记录ID生成事件ID
+	eventID := "barcode:" + record.PublicID
+	if record.PublicID == "" {
+		eventID = fmt.Sprintf("barcode:record-%d", record.ID)
+	}
+	payload, err := json.Marshal(OutboxEnvelope{
+		EventID:   eventID,
+		Type:      "barcode",
+		Timestamp: record.CreatedAt,
+		Data:      record,
+		Replay:    true,
+		ReplayJob: job.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化回放事件失败: %w", err)
+	}
+	if s.schemaCheck {
+		checkEventSchema(s.logger, eventID, payload)
+	}
+
+	// 按任务中的顺序投递，部分输出端成功后失败时，继续回放会重复投递到已成功的输出端
+	for _, name := range job.Sinks {
+		if err := sinks[name](payload); err != nil {
+			return fmt.Errorf("投递到 %s 失败（记录 %d）: %w", name, record.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkpoint 保存回放进度，返回 cause 或保存失败的错误
+func (s *ReplayService) checkpoint(job *models.ReplayJob, cause error) error {
+	err := s.db.Model(job).Updates(map[string]interface{}{
+		"processed":      job.Processed,
+		"last_record_id": job.LastRecordID,
+	}).Error
+	if cause != nil {
+		return cause
+	}
+	if err != nil {
+		return fmt.Errorf("保存回放检查点失败: %w", err)
+	}
+	return nil
+}
+
+// jobSinks 任务使用的输出端，输出端已停用时返回错误
+func (s *ReplayService) jobSinks(job *models.ReplayJob) (map[string]ReplayDeliverer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sinks := make(map[string]ReplayDeliverer, len(job.Sinks))
+	for _, name := range job.Sinks {
+		deliver, ok := s.sinks[name]
+		if !ok {
+			return nil, fmt.Errorf("输出端 %s 未启用", name)
+		}
+		sinks[name] = deliver
+	}
+	return sinks, nil
+}
+
+// recordQuery 任务时间范围及筛选条件对应的扫码记录查询
+func (s *ReplayService) recordQuery(job *models.ReplayJob) *gorm.DB {
+	query := s.db.Model(&models.BarcodeRecord{}).
+		Where("created_at >= ? AND created_at < ?", job.From, job.To)
+	if len(job.Filter.DeviceIDs) > 0 {
+		query = query.Where("device_id IN ?", job.Filter.DeviceIDs)
+	}
+	if job.Filter.Type != "" {
+		query = query.Where("type = ?", job.Filter.Type)
+	}
+	if job.Filter.Status != "" {
+		query = query.Where("status = ?", job.Filter.Status)
+	}
+	return query
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 读取目标
+const (
+	ReadTargetPrimary   = "primary"
+	ReadTargetSecondary = "secondary"
+)
+
+// reconcileBatchSize 对账时每批比对的记录数
+const reconcileBatchSize = 500
+
+// ReplicationStatus 主库与本地备份库的复制状态
+type ReplicationStatus struct {
+	ReadTarget      string     `json:"read_target"` // primary, secondary
+	PrimaryHealthy  bool       `json:"primary_healthy"`
+	Pending         int64      `json:"pending"`     // 备份库中尚未补写到主库的记录数
+	LagSeconds      float64    `json:"lag_seconds"` // 最早一条未补写记录距今时长
+	MirrorFailures  int64      `json:"mirror_failures"`
+	LastReconcileAt *time.Time `json:"last_reconcile_at,omitempty"`
+	Backfilled      int64      `json:"backfilled"` // 累计补写到主库的记录数
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// ReplicationService 扫码记录双写及故障切换
+//
+// 扫码记录先写主库，再以相同的 scan_id 写入本地备份库，备份库写入失败仅告警；
+// 主库不可用时记录只写入备份库，读取切换到备份库。主库恢复后对账任务将备份库中
+// 主库缺失的记录补写回主库，scan_id 上的唯一索引保证重复补写不会产生重复记录。
+type ReplicationService struct {
+	primary   *gorm.DB
+	secondary *gorm.DB
+	config    config.SecondaryDatabaseConfig
+	logger    *logrus.Logger
+	wake      chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+
+	mu            sync.RWMutex
+	healthy       bool
+	pending       int64
+	oldestPending *time.Time
+	floor         time.Time
+	status        ReplicationStatus
+}
+
+// NewReplicationService 创建双写服务
+func NewReplicationService(primary, secondary *gorm.DB, cfg config.SecondaryDatabaseConfig, logger *logrus.Logger) *ReplicationService {
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = 10 * time.Second
+	}
+	if cfg.ReconcileInterval <= 0 {
+		cfg.ReconcileInterval = 5 * time.Minute
+	}
+	if cfg.ReconcileWindow <= 0 {
+		cfg.ReconcileWindow = 24 * time.Hour
+	}
+
+	return &ReplicationService{
+		primary:   primary,
+		secondary: secondary,
+		config:    cfg,
+		logger:    logger,
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		healthy:   true,
+	}
+}
+
+// Reader 当前读取使用的数据库，主库不可用时为备份库
+func (s *ReplicationService) Reader() *gorm.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.healthy {
+		return s.primary
+	}
+	return s.secondary
+}
+
+// Mirror 主库写入成功后将记录写入备份库，失败仅告警
+func (s *ReplicationService) Mirror(record *models.BarcodeRecord) {
+	if err := s.insert(s.secondary, record); err != nil {
+		s.mu.Lock()
+		s.status.MirrorFailures++
+		s.status.LastError = err.Error()
+		s.mu.Unlock()
+		s.logger.WithError(err).WithField("scan_id", record.ScanID).Warn("写入本地备份库失败")
+	}
+}
+
+// Fallback 主库写入失败时判断是否切换到备份库，主库仍可用时返回false（错误与主库可用性无关）
+func (s *ReplicationService) Fallback(record *models.BarcodeRecord) (bool, error) {
+	if s.checkPrimary() {
+		return false, nil
+	}
+
+	// 主库事务已回滚，ID由备份库重新分配
+	record.ID = 0
+	if err := s.secondary.Create(record).Error; err != nil {
+		return true, fmt.Errorf("写入本地备份库失败: %w", err)
+	}
+
+	s.mu.Lock()
+	s.pending++
+	if s.oldestPending == nil {
+		at := record.CreatedAt
+		s.oldestPending = &at
+	}
+	s.mu.Unlock()
+
+	s.logger.WithField("scan_id", record.ScanID).Warn("主库不可用，扫码记录已写入本地备份库")
+	return true, nil
+}
+
+// ResetWatermark 数据重置后调用，对账不再补写重置之前的记录
+func (s *ReplicationService) ResetWatermark() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.floor = time.Now()
+	s.pending = 0
+	s.oldestPending = nil
+}
+
+// GetStatus 获取复制状态
+func (s *ReplicationService) GetStatus() ReplicationStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := s.status
+	status.PrimaryHealthy = s.healthy
+	status.ReadTarget = ReadTargetPrimary
+	if !s.healthy {
+		status.ReadTarget = ReadTargetSecondary
+	}
+	status.Pending = s.pending
+	if s.oldestPending != nil {
+		status.LagSeconds = time.Since(*s.oldestPending).Seconds()
+	}
+	return status
+}
+
+// Start 启动主库健康检查与定时对账，启动时先对账一次
+func (s *ReplicationService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		health := time.NewTicker(s.config.HealthInterval)
+		defer health.Stop()
+		reconcile := time.NewTicker(s.config.ReconcileInterval)
+		defer reconcile.Stop()
+
+		s.runReconcile()
+		for {
+			select {
+			case <-health.C:
+				s.checkPrimary()
+			case <-reconcile.C:
+				s.runReconcile()
+			case <-s.wake:
+				s.runReconcile()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止健康检查与对账
+func (s *ReplicationService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Reconcile 将备份库中主库缺失的记录补写到主库，返回补写条数
+func (s *ReplicationService) Reconcile() (int64, error) {
+	if !s.checkPrimary() {
+		return 0, fmt.Errorf("主库不可用")
+	}
+
+	s.mu.RLock()
+	since := time.Now().Add(-s.config.ReconcileWindow)
+	if s.floor.After(since) {
+		since = s.floor
+	}
+	s.mu.RUnlock()
+
+	var backfilled, pending int64
+	var oldest *time.Time
+	var batch []*models.BarcodeRecord
+	result := s.secondary.Where("created_at >= ? AND scan_id <> ''", since).Order("id").
+		FindInBatches(&batch, reconcileBatchSize, func(tx *gorm.DB, _ int) error {
+			scanIDs := make([]string, 0, len(batch))
+			for _, record := range batch {
+				scanIDs = append(scanIDs, record.ScanID)
+			}
+
+			// 主库中已删除的记录同样视为已存在，避免把用户删除的记录补写回去
+			var existing []string
+			if err := s.primary.Unscoped().Model(&models.BarcodeRecord{}).Where("scan_id IN ?", scanIDs).Pluck("scan_id", &existing).Error; err != nil {
+				return fmt.Errorf("查询主库记录失败: %w", err)
+			}
+			found := make(map[string]bool, len(existing))
+			for _, scanID := range existing {
+				found[scanID] = true
+			}
+
+			for _, record := range batch {
+				if found[record.ScanID] {
+					continue
+				}
+				if err := s.insert(s.primary, record); err != nil {
+					pending++
+					if oldest == nil || record.CreatedAt.Before(*oldest) {
+						at := record.CreatedAt
+						oldest = &at
+					}
+					s.logger.WithError(err).WithField("scan_id", record.ScanID).Warn("补写主库失败")
+					continue
+				}
+				backfilled++
+			}
+			return nil
+		})
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastReconcileAt = &now
+	s.status.Backfilled += backfilled
+	if result.Error != nil {
+		s.status.LastError = result.Error.Error()
+		return backfilled, fmt.Errorf("对账失败: %w", result.Error)
+	}
+	s.pending = pending
+	s.oldestPending = oldest
+	return backfilled, nil
+}
+
+// runReconcile 执行对账并记录结果
+func (s *ReplicationService) runReconcile() {
+	backfilled, err := s.Reconcile()
+	if err != nil {
+		s.logger.WithError(err).Debug("跳过本次对账")
+		return
+	}
+	if backfilled > 0 {
+		s.logger.WithField("backfilled", backfilled).Info("已将本地备份库中的记录补写到主库")
+	}
+}
+
+// checkPrimary 检查主库连接，状态变化时切换读取目标，主库恢复后立即触发对账
+func (s *ReplicationService) checkPrimary() bool {
+	healthy := ping(s.primary, s.config.HealthInterval) == nil
+
+	s.mu.Lock()
+	changed := healthy != s.healthy
+	s.healthy = healthy
+	s.mu.Unlock()
+
+	if !changed {
+		return healthy
+	}
+	if healthy {
+		s.logger.Info("主库已恢复，读取切换回主库")
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	} else {
+		s.logger.Warn("主库不可用，读取切换到本地备份库")
+	}
+	return healthy
+}
+
+// insert 以幂等方式写入记录副本，scan_id 已存在时忽略
+func (s *ReplicationService) insert(db *gorm.DB, record *models.BarcodeRecord) error {
+	// 两个库的自增ID互不相关，副本由目标库重新分配ID
+	copied := *record
+	copied.ID = 0
+	copied.Device = nil
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&copied).Error
+}
+
+// ping 检查数据库连接
+func ping(db *gorm.DB, timeout time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+	"userclient/pkg/barcode"
+)
+
+// 数据重置范围
+const (
+	ResetScopeRecords  = "records"   // 扫码记录及其派生数据（图片、断号、健康快照、出站事件、消费确认）
+	ResetScopeSessions = "sessions"  // 运行中的会话状态（序列号跟踪状态）
+	ResetScopeAllData  = "all-data"  // records + sessions
+	ResetScopeDemoSeed = "demo-seed" // all-data 后写入演示数据
+)
+
+// ErrResetConfirmation 确认令牌无效或已过期
+var ErrResetConfirmation = errors.New("确认令牌无效或已过期")
+
+// demoBarcodes 演示数据使用的条码
+var demoBarcodes = []string{
+	"6901234567892",
+	"PRD-A1001",
+	"PRD-A1002",
+	"LOT20240101",
+	"SN000101",
+	"SN000102",
+	"SN000103",
+	"036000291452",
+}
+
+// ResetAudit 重置操作的审计信息
+type ResetAudit struct {
+	IP        string
+	UserAgent string
+	Actor     string
+}
+
+// DataResetEvent 数据重置事件
+type DataResetEvent struct {
+	Scope   string           `json:"scope"`
+	Counts  map[string]int64 `json:"counts"`
+	ResetAt time.Time        `json:"reset_at"`
+}
+
+// resetChallenge 已签发的确认令牌
+type resetChallenge struct {
+	scope     string
+	expiresAt time.Time
+}
+
+// ResetService 演示及培训环境的数据重置服务
+//
+// 重置前需先获取与范围绑定的一次性确认令牌，删除与演示数据写入在同一事务中完成，
+// 设备与配置不受影响。
+type ResetService struct {
+	db         *gorm.DB
+	logger     *logrus.Logger
+	tokenTTL   time.Duration
+	processor  *barcode.Processor
+	mu         sync.Mutex
+	challenges map[string]resetChallenge
+	listeners  []func(DataResetEvent)
+}
+
+// NewResetService 创建数据重置服务
+func NewResetService(db *gorm.DB, tokenTTL time.Duration, logger *logrus.Logger) *ResetService {
+	if tokenTTL <= 0 {
+		tokenTTL = 2 * time.Minute
+	}
+	return &ResetService{
+		db:         db,
+		logger:     logger,
+		tokenTTL:   tokenTTL,
+		processor:  barcode.NewProcessor(),
+		challenges: make(map[string]resetChallenge),
+	}
+}
+
+// OnReset 注册重置完成回调
+func (s *ResetService) OnReset(listener func(DataResetEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// IssueToken 签发指定范围的一次性确认令牌
+func (s *ResetService) IssueToken(scope string) (string, time.Time, error) {
+	if !validResetScope(scope) {
+		return "", time.Time{}, fmt.Errorf("不支持的重置范围: %s", scope)
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, fmt.Errorf("生成确认令牌失败: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().Add(s.tokenTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, challenge := range s.challenges {
+		if now.After(challenge.expiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+	s.challenges[token] = resetChallenge{scope: scope, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// Reset 校验确认令牌并在单个事务中执行重置，返回各表删除或写入的行数
+func (s *ResetService) Reset(scope, token string, audit ResetAudit) (*DataResetEvent, error) {
+	if !validResetScope(scope) {
+		return nil, fmt.Errorf("不支持的重置范围: %s", scope)
+	}
+	if !s.consumeToken(scope, token) {
+		return nil, ErrResetConfirmation
+	}
+
+	counts := make(map[string]int64)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if scope != ResetScopeSessions {
+			if err := s.clearRecords(tx, counts); err != nil {
+				return err
+			}
+		}
+		if scope != ResetScopeRecords {
+			if err := deleteAll(tx, &models.SequenceState{}, "sequence_states", counts); err != nil {
+				return err
+			}
+		}
+		if scope == ResetScopeDemoSeed {
+			if err := s.seedDemo(tx, counts); err != nil {
+				return err
+			}
+		}
+		return s.writeAudit(tx, scope, counts, audit)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("数据重置失败: %w", err)
+	}
+
+	event := &DataResetEvent{Scope: scope, Counts: counts, ResetAt: time.Now()}
+	s.logger.WithField("scope", scope).WithField("counts", counts).WithField("ip", audit.IP).Warn("数据已重置")
+
+	s.mu.Lock()
+	listeners := make([]func(DataResetEvent), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.Unlock()
+	for _, listener := range listeners {
+		listener(*event)
+	}
+	return event, nil
+}
+
+// consumeToken 校验并作废确认令牌
+func (s *ResetService) consumeToken(scope, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[token]
+	if !ok {
+		return false
+	}
+	delete(s.challenges, token)
+	return challenge.scope == scope && time.Now().Before(challenge.expiresAt)
+}
+
+// clearRecords 删除扫码记录及其派生数据（图片文件由图片清理任务回收）
+func (s *ResetService) clearRecords(tx *gorm.DB, counts map[string]int64) error {
+	tables := []struct {
+		model interface{}
+		name  string
+	}{
+		{&models.BarcodeImage{}, "barcode_images"},
+		{&models.OutboxDelivery{}, "outbox_deliveries"},
+		{&models.OutboxEvent{}, "outbox_events"},
+		{&models.SequenceGap{}, "sequence_gaps"},
+		{&models.DeviceHealthSnapshot{}, "device_health_snapshots"},
+		{&models.GoalProgress{}, "goal_progress"},
+		{&models.DistinctSketch{}, "distinct_sketches"},
+		{&models.BarcodeConsumption{}, "barcode_consumptions"},
+		{&models.BarcodeAnnotation{}, "barcode_annotations"},
+		{&models.IntegrityCheckpoint{}, "integrity_checkpoints"},
+		{&models.BarcodeRecord{}, "barcode_records"},
+	}
+	for _, table := range tables {
+		if err := deleteAll(tx, table.model, table.name, counts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedDemo 写入演示扫码记录
+func (s *ResetService) seedDemo(tx *gorm.DB, counts map[string]int64) error {
+	var deviceID *uint
+	var device models.Device
+	if err := tx.Where("is_active = ?", true).Order("id").First(&device).Error; err == nil {
+		deviceID = &device.ID
+	}
+
+	start := time.Now().Add(-time.Duration(len(demoBarcodes)) * time.Minute)
+	records := make([]*models.BarcodeRecord, 0, len(demoBarcodes))
+	for i, content := range demoBarcodes {
+		data := s.processor.ProcessBarcode(content)
+		at := start.Add(time.Duration(i) * time.Minute)
+		records = append(records, &models.BarcodeRecord{
+			Content:    data.Content,
+			Length:     data.Length,
+			Type:       data.Type,
+			Status:     "success",
+			Message:    data.Message,
+			DeviceID:   deviceID,
+			DurationMS: 80,
+			CreatedAt:  at,
+			UpdatedAt:  at,
+		})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return fmt.Errorf("写入演示数据失败: %w", err)
+	}
+	counts["demo_records"] = int64(len(records))
+	return nil
+}
+
+// writeAudit 在同一事务中写入审计日志
+func (s *ResetService) writeAudit(tx *gorm.DB, scope string, counts map[string]int64, audit ResetAudit) error {
+	extra, err := json.Marshal(map[string]interface{}{"scope": scope, "counts": counts, "actor": audit.Actor})
+	if err != nil {
+		return fmt.Errorf("序列化审计信息失败: %w", err)
+	}
+	log := &models.SystemLog{
+		Level:     "warning",
+		Message:   fmt.Sprintf("数据重置（%s）", scope),
+		Module:    "admin",
+		Action:    "reset:" + scope,
+		IP:        audit.IP,
+		UserAgent: audit.UserAgent,
+		Extra:     string(extra),
+	}
+	if err := tx.Create(log).Error; err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	return nil
+}
+
+// deleteAll 物理删除表中所有行并记录行数
+func deleteAll(tx *gorm.DB, model interface{}, name string, counts map[string]int64) error {
+	result := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model)
+	if result.Error != nil {
+		return fmt.Errorf("清空 %s 失败: %w", name, result.Error)
+	}
+	counts[name] = result.RowsAffected
+	return nil
+}
+
+// validResetScope 是否为支持的重置范围
+func validResetScope(scope string) bool {
+	switch scope {
+	case ResetScopeRecords, ResetScopeSessions, ResetScopeAllData, ResetScopeDemoSeed:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"userclient/internal/models"
+	"userclient/pkg/barcode"
+)
+
+// RuleTraceVersion 规则测试判定过程的格式版本，字段含义变化时递增；用户反馈分类错误时附上的判定过程按此版本解读
+const RuleTraceVersion = 1
+
+// 单条分类规则的判定结果
+const (
+	RuleResultMatched  = "matched"  // 正则匹配，条码按该规则分类
+	RuleResultNoMatch  = "no_match" // 正则不匹配
+	RuleResultShadowed = "shadowed" // 正则匹配，但顺序在前的规则已先匹配
+	RuleResultDisabled = "disabled" // 未启用，不参与匹配
+	RuleResultInvalid  = "invalid"  // 无法编译，加载时跳过
+	RuleResultSkipped  = "skipped"  // 条码格式无效，不进行分类
+)
+
+// RuleTraceEntry 单条分类规则的判定
+type RuleTraceEntry struct {
+	Order    int               `json:"order"`        // 匹配顺序（priority、id 升序），从1开始
+	ID       uint              `json:"id,omitempty"` // 草稿规则为0
+	Name     string            `json:"name"`
+	Priority int               `json:"priority"`
+	Pattern  string            `json:"pattern"`
+	Type     string            `json:"type,omitempty"`
+	Result   string            `json:"result"` // matched、no_match、shadowed、disabled、invalid、skipped
+	Reason   string            `json:"reason"`
+	Derived  map[string]string `json:"derived,omitempty"` // 正则匹配时提取的派生字段
+}
+
+// RuleEvalResult 条码入库时的分类结果
+type RuleEvalResult struct {
+	Type        string            `json:"type"`
+	Status      string            `json:"status"`
+	Message     string            `json:"message"`
+	Derived     map[string]string `json:"derived,omitempty"`
+	Link        string            `json:"link,omitempty"`
+	LinkAllowed *bool             `json:"link_allowed,omitempty"`
+}
+
+// CheckDigitTrace GS1码制校验位的检查结果，校验位错误的扫码仍会入库，质量报告计为不合格
+type CheckDigitTrace struct {
+	Applicable bool   `json:"applicable"` // 是否为 EAN-8、UPC-A、EAN-13、ITF-14
+	Valid      bool   `json:"valid"`
+	Reason     string `json:"reason"`
+}
+
+// RepeatTrace 同一内容此前的扫码，重复扫码不拦截，仍会入库
+type RepeatTrace struct {
+	Previous      int64      `json:"previous_scans"`            // 已有的同内容扫码记录数
+	LastRecordID  uint       `json:"last_record_id,omitempty"`  // 最近一条同内容记录
+	LastScannedAt *time.Time `json:"last_scanned_at,omitempty"` // 最近一条同内容记录的扫码时间
+	Action        string     `json:"action"`                    // record：入库；reject：格式无效，不入库
+	Reason        string     `json:"reason"`
+}
+
+// RuleEvaluation 规则测试的完整判定过程，不创建扫码记录
+type RuleEvaluation struct {
+	Version      int              `json:"trace_version"`
+	Content      string           `json:"content"`
+	Draft        bool             `json:"draft"` // 按请求中的草稿规则判定，而非已保存的规则
+	Valid        bool             `json:"valid"`
+	Validation   string           `json:"validation_message"`
+	DetectedType string           `json:"detected_type"` // 内置识别的类型，分类规则可覆盖
+	Rules        []RuleTraceEntry `json:"rules"`
+	MatchedRule  string           `json:"matched_rule"`     // 生效的规则名称，为空表示没有规则匹配
+	Result       *RuleEvalResult  `json:"result,omitempty"` // 格式无效时为nil
+	CheckDigit   CheckDigitTrace  `json:"check_digit"`
+	Repeat       RepeatTrace      `json:"repeat"`
+}
+
+// Evaluate 按当前的校验及分类逻辑判定条码，返回每一步的结果，不创建扫码记录
+//
+// drafts 不为nil时按这些草稿规则判定（按 priority 及给出的顺序匹配），用于保存前试验规则；
+// 为nil时使用已保存的规则，与 Load 加载到条码处理器的顺序一致。
+func (s *RuleService) Evaluate(content string, drafts []*models.ClassificationRule) (*RuleEvaluation, error) {
+	rules := drafts
+	if drafts == nil {
+		var err error
+		if rules, err = s.GetRules(); err != nil {
+			return nil, err
+		}
+	} else {
+		sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority < rules[j].Priority })
+	}
+
+	eval := &RuleEvaluation{
+		Version:      RuleTraceVersion,
+		Content:      content,
+		Draft:        drafts != nil,
+		DetectedType: s.processor.GetBarcodeType(content),
+		Rules:        make([]RuleTraceEntry, 0, len(rules)),
+	}
+	eval.Valid, eval.Validation = s.processor.ValidateBarcode(content)
+
+	compiled := make([]*barcode.Rule, 0, len(rules))
+	for i, rule := range rules {
+		entry := RuleTraceEntry{
+			Order:    i + 1,
+			ID:       rule.ID,
+			Name:     rule.Name,
+			Priority: rule.Priority,
+			Pattern:  rule.Pattern,
+			Type:     rule.Type,
+		}
+		c, err := barcode.CompileRule(rule.Name, rule.Type, rule.Pattern, rule.Fields)
+		switch {
+		case !rule.Enabled:
+			entry.Result, entry.Reason = RuleResultDisabled, "规则未启用"
+		case err != nil:
+			entry.Result, entry.Reason = RuleResultInvalid, err.Error()
+		case !eval.Valid:
+			compiled = append(compiled, c)
+			entry.Result, entry.Reason = RuleResultSkipped, "条码格式无效，不入库也不分类"
+		default:
+			compiled = append(compiled, c)
+			derived, ok := c.Match(content)
+			switch {
+			case !ok:
+				entry.Result, entry.Reason = RuleResultNoMatch, "正则表达式不匹配"
+			case eval.MatchedRule != "":
+				entry.Result, entry.Reason = RuleResultShadowed, fmt.Sprintf("正则表达式匹配，但规则 %s 已先匹配", eval.MatchedRule)
+				entry.Derived = derived
+			default:
+				entry.Result, entry.Reason = RuleResultMatched, "正则表达式匹配，第一条匹配的规则生效"
+				entry.Derived = derived
+				eval.MatchedRule = rule.Name
+			}
+		}
+		eval.Rules = append(eval.Rules, entry)
+	}
+
+	if eval.Valid {
+		data := s.processor.ProcessWithRules(content, compiled)
+		eval.Result = &RuleEvalResult{
+			Type:        data.Type,
+			Status:      data.Status,
+			Message:     data.Message,
+			Derived:     data.Derived,
+			Link:        data.Link,
+			LinkAllowed: data.LinkAllowed,
+		}
+	}
+
+	valid, applicable := s.processor.ValidateCheckDigit(content)
+	eval.CheckDigit = CheckDigitTrace{Applicable: applicable, Valid: valid}
+	switch {
+	case !applicable:
+		eval.CheckDigit.Reason = "不是 EAN-8、UPC-A、EAN-13、ITF-14，不检查校验位"
+	case valid:
+		eval.CheckDigit.Reason = "校验位正确"
+	default:
+		eval.CheckDigit.Reason = "校验位错误，扫码仍会入库，质量报告计为不合格"
+	}
+
+	if err := s.evaluateRepeat(eval); err != nil {
+		return nil, err
+	}
+	return eval, nil
+}
+
+// evaluateRepeat 查询同一内容此前的扫码
+func (s *RuleService) evaluateRepeat(eval *RuleEvaluation) error {
+	if !eval.Valid {
+		eval.Repeat = RepeatTrace{Action: "reject", Reason: "条码格式无效，不入库"}
+		return nil
+	}
+	repeat := RepeatTrace{Action: "record"}
+	query := s.db.Model(&models.BarcodeRecord{}).Where("content = ?", eval.Content)
+	if err := query.Count(&repeat.Previous).Error; err != nil {
+		return fmt.Errorf("查询同内容扫码失败: %w", err)
+	}
+	if repeat.Previous == 0 {
+		repeat.Reason = "此前没有同内容的扫码，入库"
+		eval.Repeat = repeat
+		return nil
+	}
+	var last models.BarcodeRecord
+	if err := s.db.Where("content = ?", eval.Content).Order("created_at DESC, id DESC").First(&last).Error; err != nil {
+		return fmt.Errorf("查询同内容扫码失败: %w", err)
+	}
+	repeat.LastRecordID = last.ID
+	repeat.LastScannedAt = &last.CreatedAt
+	repeat.Reason = "重复扫码不拦截，仍会入库，计入重复扫码统计"
+	eval.Repeat = repeat
+	return nil
+}
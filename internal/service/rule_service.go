@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+	"userclient/pkg/barcode"
+)
+
+// ErrInvalidRule 分类规则校验失败（如正则无法编译）
+var ErrInvalidRule = errors.New("分类规则无效")
+
+// RuleService 条码分类规则服务，规则变更后立即重新加载到条码处理器
+type RuleService struct {
+	db        *gorm.DB
+	processor *barcode.Processor
+	logger    *logrus.Logger
+}
+
+// NewRuleService 创建分类规则服务
+func NewRuleService(db *gorm.DB, processor *barcode.Processor, logger *logrus.Logger) *RuleService {
+	return &RuleService{
+		db:        db,
+		processor: processor,
+		logger:    logger,
+	}
+}
+
+// Load 编译已启用的规则并加载到条码处理器，无法编译的规则跳过
+func (s *RuleService) Load() error {
+	var rules []*models.ClassificationRule
+	if err := s.db.Where("enabled = ?", true).Order("priority, id").Find(&rules).Error; err != nil {
+		return fmt.Errorf("查询分类规则失败: %w", err)
+	}
+
+	compiled := make([]*barcode.Rule, 0, len(rules))
+	for _, rule := range rules {
+		c, err := barcode.CompileRule(rule.Name, rule.Type, rule.Pattern, rule.Fields)
+		if err != nil {
+			s.logger.WithError(err).WithField("rule", rule.Name).Warn("分类规则无效，已跳过")
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+	s.processor.SetRules(compiled)
+	return nil
+}
+
+// GetRules 获取全部分类规则
+func (s *RuleService) GetRules() ([]*models.ClassificationRule, error) {
+	var rules []*models.ClassificationRule
+	if err := s.db.Order("priority, id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询分类规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateRule 校验并保存分类规则
+func (s *RuleService) CreateRule(rule *models.ClassificationRule) error {
+	if err := validateRule(rule); err != nil {
+		return err
+	}
+	if err := s.db.Create(rule).Error; err != nil {
+		return fmt.Errorf("保存分类规则失败: %w", err)
+	}
+	s.logger.WithField("rule", rule.Name).Info("分类规则已创建")
+	return s.Load()
+}
+
+// UpdateRule 校验并更新分类规则
+func (s *RuleService) UpdateRule(id uint, rule *models.ClassificationRule) (*models.ClassificationRule, error) {
+	if err := validateRule(rule); err != nil {
+		return nil, err
+	}
+
+	var existing models.ClassificationRule
+	if err := s.db.First(&existing, id).Error; err != nil {
+		return nil, err
+	}
+	existing.Name = rule.Name
+	existing.Pattern = rule.Pattern
+	existing.Type = rule.Type
+	existing.Fields = rule.Fields
+	existing.Priority = rule.Priority
+	existing.Enabled = rule.Enabled
+	if err := s.db.Save(&existing).Error; err != nil {
+		return nil, fmt.Errorf("更新分类规则失败: %w", err)
+	}
+
+	s.logger.WithField("rule", existing.Name).Info("分类规则已更新")
+	return &existing, s.Load()
+}
+
+// DeleteRule 删除分类规则
+func (s *RuleService) DeleteRule(id uint) error {
+	result := s.db.Delete(&models.ClassificationRule{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除分类规则失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return s.Load()
+}
+
+// EnsureDerivedIndexes 为常用派生字段创建表达式索引
+//
+// 派生字段存于 barcode_records.derived（JSON），按字段过滤时使用
+// json_extract(derived, '$.<字段>')；SQLite仅在查询表达式与索引表达式完全一致时使用索引，
+// 因此过滤条件统一由 derivedFieldExpr 生成。未建索引的字段仍可过滤，但需全表扫描。
+func (s *RuleService) EnsureDerivedIndexes(fields []string) error {
+	for _, field := range fields {
+		if !barcode.ValidFieldName(field) {
+			return fmt.Errorf("派生字段名无效: %s", field)
+		}
+		sql := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_barcode_records_derived_%s ON barcode_records (%s)", field, derivedFieldExpr(field))
+		if err := s.db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("创建派生字段 %s 索引失败: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// validateRule 校验规则，正则编译错误原样返回给调用方
+func validateRule(rule *models.ClassificationRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalidRule)
+	}
+	if _, err := barcode.CompileRule(rule.Name, rule.Type, rule.Pattern, rule.Fields); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	return nil
+}
+
+// derivedFieldExpr 派生字段的SQL表达式，字段名须已通过 barcode.ValidFieldName 校验
+func derivedFieldExpr(field string) string {
+	return fmt.Sprintf("json_extract(derived, '$.%s')", field)
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 扫码参数来源
+const (
+	ScannerSettingFromDatabase = "database"    // 配置表中的系统配置
+	ScannerSettingFromFile     = "config_file" // 配置文件
+)
+
+// scannerGuardrail 扫码参数的安全范围
+type scannerGuardrail struct {
+	key      string
+	min, max int
+	risk     string // 超出范围时的后果
+}
+
+// scannerGuardrails 扫码参数安全范围，写在代码中而不是配置里，避免被误改的配置再次绕过
+var scannerGuardrails = []scannerGuardrail{
+	{key: "scanner.min_length", min: 2, max: 100, risk: "过小时零散按键会被当作条码"},
+	{key: "scanner.max_length", min: 4, max: 1024, risk: "过小时正常条码被拒收，过大时长时间的键盘输入被当作条码"},
+	{key: "scanner.timeout_ms", min: 20, max: 500, risk: "过小时扫码被拆成多段，过大时人工输入被当作扫码"},
+}
+
+// ScannerWarning 扫码参数超出安全范围的提示
+type ScannerWarning struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Source  string `json:"source,omitempty"` // database, config_file
+	Min     int    `json:"min"`
+	Max     int    `json:"max"`
+	Message string `json:"message"`
+}
+
+// ScannerSetting 当前生效的扫码参数
+type ScannerSetting struct {
+	Value  string `json:"value"`
+	Source string `json:"source"` // database, config_file
+}
+
+// ScannerDriftEvent 扫码参数风险变化事件
+type ScannerDriftEvent struct {
+	Trigger  string           `json:"trigger"` // startup, config_change
+	Warnings []ScannerWarning `json:"warnings"`
+}
+
+// CheckScannerSetting 检查扫码参数是否在安全范围内，无法解析或未登记的键返回nil
+func CheckScannerSetting(key, value string) *ScannerWarning {
+	for _, guardrail := range scannerGuardrails {
+		if guardrail.key != key {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || (n >= guardrail.min && n <= guardrail.max) {
+			return nil
+		}
+		return &ScannerWarning{
+			Key:     key,
+			Value:   value,
+			Min:     guardrail.min,
+			Max:     guardrail.max,
+			Message: fmt.Sprintf("%s=%s 超出安全范围 %d~%d，%s", key, value, guardrail.min, guardrail.max, guardrail.risk),
+		}
+	}
+	return nil
+}
+
+// ScannerGuard 扫码参数风险监控
+//
+// 启动时及扫码参数变更后检查当前生效的参数（配置表优先，其次配置文件），
+// 超出安全范围时告警；风险变化时写入系统日志并通知监听者。
+type ScannerGuard struct {
+	configService ConfigReader
+	config        config.ScannerConfig
+	db            *gorm.DB
+	logger        *logrus.Logger
+
+	mu        sync.Mutex
+	last      []ScannerWarning
+	listeners []func(ScannerDriftEvent)
+}
+
+// NewScannerGuard 创建扫码参数风险监控
+func NewScannerGuard(configService ConfigReader, cfg config.ScannerConfig, db *gorm.DB, logger *logrus.Logger) *ScannerGuard {
+	return &ScannerGuard{
+		configService: configService,
+		config:        cfg,
+		db:            db,
+		logger:        logger,
+	}
+}
+
+// OnWarning 注册风险变化回调
+func (g *ScannerGuard) OnWarning(listener func(event ScannerDriftEvent)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.listeners = append(g.listeners, listener)
+}
+
+// Settings 当前生效的扫码参数
+func (g *ScannerGuard) Settings() map[string]ScannerSetting {
+	fileValues := map[string]int{
+		"scanner.min_length": g.config.MinLength,
+		"scanner.max_length": g.config.MaxLength,
+		"scanner.timeout_ms": g.config.TimeoutMS,
+	}
+
+	settings := make(map[string]ScannerSetting, len(fileValues))
+	for key, value := range fileValues {
+		setting := ScannerSetting{Value: strconv.Itoa(value), Source: ScannerSettingFromFile}
+		if stored, err := g.configService.GetConfiguration(key); err == nil {
+			setting = ScannerSetting{Value: stored.Value, Source: ScannerSettingFromDatabase}
+		}
+		settings[key] = setting
+	}
+	return settings
+}
+
+// Evaluate 检查当前生效的扫码参数，返回超出安全范围的项
+func (g *ScannerGuard) Evaluate() []ScannerWarning {
+	settings := g.Settings()
+	warnings := make([]ScannerWarning, 0)
+	for _, guardrail := range scannerGuardrails {
+		setting, ok := settings[guardrail.key]
+		if !ok {
+			continue
+		}
+		if warning := CheckScannerSetting(guardrail.key, setting.Value); warning != nil {
+			warning.Source = setting.Source
+			warnings = append(warnings, *warning)
+		}
+	}
+	return warnings
+}
+
+// Check 检查扫码参数并输出告警，风险与上次检查不同时记录系统日志并通知
+func (g *ScannerGuard) Check(trigger string) []ScannerWarning {
+	warnings := g.Evaluate()
+	for _, warning := range warnings {
+		g.logger.WithField("key", warning.Key).WithField("value", warning.Value).WithField("source", warning.Source).Warn(warning.Message)
+	}
+
+	g.mu.Lock()
+	changed := !reflect.DeepEqual(warnings, g.last) && (len(warnings) > 0 || len(g.last) > 0)
+	g.last = warnings
+	listeners := make([]func(ScannerDriftEvent), len(g.listeners))
+	copy(listeners, g.listeners)
+	g.mu.Unlock()
+
+	if !changed {
+		return warnings
+	}
+
+	event := ScannerDriftEvent{Trigger: trigger, Warnings: warnings}
+	g.persist(event)
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return warnings
+}
+
+// persist 将风险变化写入系统日志
+func (g *ScannerGuard) persist(event ScannerDriftEvent) {
+	extra, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:   "warning",
+		Message: fmt.Sprintf("扫码参数有 %d 项超出安全范围", len(event.Warnings)),
+		Module:  "scanner",
+		Action:  "scanner:drift",
+		Extra:   string(extra),
+	}
+	if len(event.Warnings) == 0 {
+		log.Level = "info"
+		log.Message = "扫码参数已恢复到安全范围内"
+	}
+	if err := g.db.Create(log).Error; err != nil {
+		g.logger.WithError(err).Warn("写入系统日志失败")
+	}
+}
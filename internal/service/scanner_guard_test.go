@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+func TestCheckScannerSetting(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		warning bool
+	}{
+		{"scanner.min_length", "2", false},
+		{"scanner.min_length", "100", false},
+		{"scanner.min_length", "1", true},
+		{"scanner.min_length", "101", true},
+		{"scanner.max_length", "4", false},
+		{"scanner.max_length", "1024", false},
+		{"scanner.max_length", "3", true},
+		{"scanner.max_length", "1025", true},
+		{"scanner.timeout_ms", "20", false},
+		{"scanner.timeout_ms", "500", false},
+		{"scanner.timeout_ms", "19", true},
+		{"scanner.timeout_ms", "501", true},
+		{"scanner.timeout_ms", "-5", true},
+		// 无法解析的值由校验规则拒绝，不在此提示
+		{"scanner.timeout_ms", "fast", false},
+		{"scanner.timeout", "1", false},
+		{"log.level", "999", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			warning := CheckScannerSetting(tt.key, tt.value)
+			if (warning != nil) != tt.warning {
+				t.Fatalf("warning = %+v, want %v", warning, tt.warning)
+			}
+			if warning == nil {
+				return
+			}
+			if warning.Key != tt.key || warning.Value != tt.value || warning.Min >= warning.Max || warning.Message == "" {
+				t.Fatalf("warning = %+v", warning)
+			}
+		})
+	}
+}
+
+// TestGuardrailRequiresForce 超出安全范围的扫码参数须确认后才能保存，未确认时拒绝并审计；新建与按ID更新同样检查
+func TestGuardrailRequiresForce(t *testing.T) {
+	tests := []struct {
+		name  string
+		save  func(configs *ConfigService, actor ConfigActor) error
+		value string // 保存后配置表中的值
+	}{
+		{
+			name: "set existing",
+			save: func(configs *ConfigService, actor ConfigActor) error {
+				_, err := configs.SetConfigurationAs(actor, "scanner.timeout_ms", "800", "", "")
+				return err
+			},
+			value: "800",
+		},
+		{
+			name: "update by id",
+			save: func(configs *ConfigService, actor ConfigActor) error {
+				entry, err := configs.GetConfiguration("scanner.timeout_ms")
+				if err != nil {
+					return err
+				}
+				_, err = configs.UpdateConfigurationAs(actor, entry.ID, map[string]interface{}{"value": "10"})
+				return err
+			},
+			value: "10",
+		},
+		{
+			name: "create",
+			save: func(configs *ConfigService, actor ConfigActor) error {
+				if err := configs.db.Unscoped().Where("key = ?", "scanner.min_length").Delete(&models.Configuration{}).Error; err != nil {
+					return err
+				}
+				_, err := configs.SetConfigurationAs(actor, "scanner.min_length", "1", "scanner", "")
+				return err
+			},
+			value: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, db := newTestConfigs(t)
+			systemEntry(t, db, "scanner.timeout_ms", "100")
+			systemEntry(t, db, "scanner.min_length", "3")
+
+			admin := ConfigActor{Admin: true, Name: "admin"}
+			if err := tt.save(configs, admin); !errors.Is(err, ErrConfigOutOfRange) {
+				t.Fatalf("未确认保存 err = %v, want ErrConfigOutOfRange", err)
+			}
+			audits := deniedAudits(t, db)
+			if len(audits) != 1 || audits[0]["reason"] == "" {
+				t.Fatalf("审计日志 = %v", audits)
+			}
+
+			admin.Force = true
+			if err := tt.save(configs, admin); err != nil {
+				t.Fatalf("确认后保存: %v", err)
+			}
+			var stored []string
+			if err := db.Model(&models.Configuration{}).Where("key LIKE ?", "scanner.%").Pluck("value", &stored).Error; err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, value := range stored {
+				found = found || value == tt.value
+			}
+			if !found {
+				t.Fatalf("确认后未保存 %s: %v", tt.value, stored)
+			}
+			if got := len(deniedAudits(t, db)); got != 1 {
+				t.Fatalf("确认后的保存写入了拒绝审计日志: %d 条", got)
+			}
+		})
+	}
+}
+
+// TestScannerGuardDrift 生效参数（配置表优先，其次配置文件）超出或恢复安全范围时记录系统日志并通知，未变化时不重复记录
+func TestScannerGuardDrift(t *testing.T) {
+	configs, db := newTestConfigs(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	guard := NewScannerGuard(configs, config.ScannerConfig{MinLength: 1, MaxLength: 50, TimeoutMS: 100}, db.DB, logger)
+	var events []ScannerDriftEvent
+	guard.OnWarning(func(event ScannerDriftEvent) { events = append(events, event) })
+
+	driftLogs := func() []models.SystemLog {
+		t.Helper()
+		var logs []models.SystemLog
+		if err := db.Where("action = ?", "scanner:drift").Order("id").Find(&logs).Error; err != nil {
+			t.Fatal(err)
+		}
+		return logs
+	}
+	describe := func(warnings []ScannerWarning) string {
+		var s string
+		for _, w := range warnings {
+			s += fmt.Sprintf("%s=%s(%s) ", w.Key, w.Value, w.Source)
+		}
+		return s
+	}
+
+	// 配置文件中的最小长度超出范围
+	warnings := guard.Check("startup")
+	if got := describe(warnings); got != "scanner.min_length=1(config_file) " {
+		t.Fatalf("startup warnings = %s", got)
+	}
+	if len(events) != 1 || events[0].Trigger != "startup" || len(driftLogs()) != 1 {
+		t.Fatalf("events = %+v, logs = %d", events, len(driftLogs()))
+	}
+
+	// 配置表中的值优先；再加一项超出范围
+	systemEntry(t, db, "scanner.min_length", "3")
+	systemEntry(t, db, "scanner.timeout_ms", "900")
+	warnings = guard.Check("config_change")
+	if got := describe(warnings); got != "scanner.timeout_ms=900(database) " {
+		t.Fatalf("config_change warnings = %s", got)
+	}
+	if len(events) != 2 || len(driftLogs()) != 2 || driftLogs()[1].Level != "warning" {
+		t.Fatalf("events = %d, logs = %+v", len(events), driftLogs())
+	}
+
+	// 未变化不重复记录
+	guard.Check("config_change")
+	if len(events) != 2 || len(driftLogs()) != 2 {
+		t.Fatalf("unchanged check recorded again: events = %d, logs = %d", len(events), len(driftLogs()))
+	}
+
+	// 恢复后记录一次
+	systemEntry(t, db, "scanner.timeout_ms", "100")
+	if warnings = guard.Check("config_change"); len(warnings) != 0 {
+		t.Fatalf("warnings after restore = %s", describe(warnings))
+	}
+	logs := driftLogs()
+	if len(events) != 3 || len(events[2].Warnings) != 0 || len(logs) != 3 || logs[2].Level != "info" {
+		t.Fatalf("restore: events = %+v, logs = %+v", events, logs)
+	}
+	guard.Check("config_change")
+	if len(events) != 3 || len(driftLogs()) != 3 {
+		t.Fatal("safe settings recorded again")
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+)
+
+// ScannerPresetsKey 站点自定义扫码枪参数预设（JSON数组），修改后重启生效
+const ScannerPresetsKey = "scanner.presets"
+
+// 预设来源
+const (
+	ScannerPresetBuiltin = "builtin" // 随程序发布
+	ScannerPresetSite    = "site"    // 配置表 scanner.presets 中的站点自定义预设
+)
+
+// ErrUnknownPreset 扫码枪预设不存在
+var ErrUnknownPreset = errors.New("扫码枪预设不存在")
+
+// presetNamePattern 预设名称：小写字母、数字、- 及 _
+var presetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// ScannerPresetSettings 预设展开的扫码参数，与 scanner 配置节中的同名参数对应
+type ScannerPresetSettings struct {
+	TimeoutMS            int  `json:"timeout_ms"`
+	MinLength            int  `json:"min_length"`
+	MaxLength            int  `json:"max_length"`
+	TerminatorCollapseMS int  `json:"terminator_collapse_ms"`
+	MergeFragments       bool `json:"merge_fragments"`
+	SuspectGapMS         int  `json:"suspect_gap_ms"`
+}
+
+// ScannerPreset 扫码枪参数预设
+type ScannerPreset struct {
+	Name        string                `json:"name"`
+	Vendor      string                `json:"vendor,omitempty"`
+	Model       string                `json:"model,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Source      string                `json:"source"` // builtin, site
+	Settings    ScannerPresetSettings `json:"settings"`
+}
+
+// builtinScannerPresets 内置预设，适用于各型号出厂默认的USB键盘模式，可作为现场调优的起点
+var builtinScannerPresets = []ScannerPreset{
+	{
+		Name:        "zebra-ds2208",
+		Vendor:      "Zebra",
+		Model:       "DS2208",
+		Description: "USB键盘模式，按键间隔短且稳定",
+		Settings:    ScannerPresetSettings{TimeoutMS: 50, MinLength: 3, MaxLength: 128, TerminatorCollapseMS: 20, MergeFragments: true, SuspectGapMS: 30},
+	},
+	{
+		Name:        "honeywell-voyager",
+		Vendor:      "Honeywell",
+		Model:       "Voyager 1250g/1450g",
+		Description: "USB键盘模式，后缀为CR+LF时连续回车需合并",
+		Settings:    ScannerPresetSettings{TimeoutMS: 60, MinLength: 3, MaxLength: 128, TerminatorCollapseMS: 40, MergeFragments: true, SuspectGapMS: 40},
+	},
+	{
+		Name:        "datalogic-quickscan",
+		Vendor:      "Datalogic",
+		Model:       "QuickScan QD2430",
+		Description: "USB键盘模式，长条码偶有较长的按键间隔",
+		Settings:    ScannerPresetSettings{TimeoutMS: 80, MinLength: 3, MaxLength: 128, TerminatorCollapseMS: 30, MergeFragments: true, SuspectGapMS: 60},
+	},
+	{
+		Name:        "generic-fast",
+		Description: "通用快速扫码枪：按键间隔短，不拼接片段，减少人工输入被误判为扫码",
+		Settings:    ScannerPresetSettings{TimeoutMS: 40, MinLength: 3, MaxLength: 50, TerminatorCollapseMS: 20, MergeFragments: false, SuspectGapMS: 25},
+	},
+	{
+		Name:        "generic-slow",
+		Description: "通用慢速扫码枪（无线底座、蓝牙等）：按键间隔长且不稳定",
+		Settings:    ScannerPresetSettings{TimeoutMS: 200, MinLength: 3, MaxLength: 50, TerminatorCollapseMS: 60, MergeFragments: true, SuspectGapMS: 120},
+	},
+}
+
+// validate 校验预设参数
+func (s ScannerPresetSettings) validate() error {
+	switch {
+	case s.TimeoutMS <= 0:
+		return fmt.Errorf("timeout_ms 必须大于0")
+	case s.MinLength <= 0:
+		return fmt.Errorf("min_length 必须大于0")
+	case s.MaxLength < s.MinLength:
+		return fmt.Errorf("max_length 不能小于 min_length")
+	case s.TerminatorCollapseMS < 0:
+		return fmt.Errorf("terminator_collapse_ms 不能小于0")
+	case s.SuspectGapMS < 0:
+		return fmt.Errorf("suspect_gap_ms 不能小于0")
+	}
+	return nil
+}
+
+// ParseScannerPresets 解析站点自定义预设配置值，名称不能与内置预设重复
+func ParseScannerPresets(value string) ([]ScannerPreset, error) {
+	var presets []ScannerPreset
+	if err := json.Unmarshal([]byte(value), &presets); err != nil {
+		return nil, fmt.Errorf("须为预设组成的JSON数组: %w", err)
+	}
+
+	seen := make(map[string]bool, len(presets))
+	for _, preset := range builtinScannerPresets {
+		seen[preset.Name] = true
+	}
+	for i := range presets {
+		preset := &presets[i]
+		preset.Name = strings.TrimSpace(preset.Name)
+		if !presetNamePattern.MatchString(preset.Name) {
+			return nil, fmt.Errorf("预设名称 %q 无效，只能包含小写字母、数字、- 及 _", preset.Name)
+		}
+		if seen[preset.Name] {
+			return nil, fmt.Errorf("预设名称 %s 重复或与内置预设冲突", preset.Name)
+		}
+		seen[preset.Name] = true
+		if err := preset.Settings.validate(); err != nil {
+			return nil, fmt.Errorf("预设 %s 无效: %w", preset.Name, err)
+		}
+		preset.Source = ScannerPresetSite
+	}
+	return presets, nil
+}
+
+// ScannerPresetService 扫码枪参数预设
+//
+// 预设按型号给出键盘钩子的按键超时、长度范围、终止符合并等参数，展开到 scanner 配置节，
+// 配置文件中显式设置的参数优先于预设。站点可在配置表 scanner.presets 中补充自定义预设。
+type ScannerPresetService struct {
+	configService ConfigReader
+	logger        *logrus.Logger
+
+	mu     sync.RWMutex
+	active string // 启动时应用到键盘钩子的预设
+}
+
+// NewScannerPresetService 创建扫码枪参数预设服务
+func NewScannerPresetService(configService ConfigReader, logger *logrus.Logger) *ScannerPresetService {
+	return &ScannerPresetService{
+		configService: configService,
+		logger:        logger,
+	}
+}
+
+// List 内置预设及站点自定义预设，站点预设无效时只返回内置预设
+func (s *ScannerPresetService) List() []ScannerPreset {
+	presets := make([]ScannerPreset, 0, len(builtinScannerPresets))
+	for _, preset := range builtinScannerPresets {
+		preset.Source = ScannerPresetBuiltin
+		presets = append(presets, preset)
+	}
+
+	stored, err := s.configService.GetConfiguration(ScannerPresetsKey)
+	if err != nil {
+		return presets
+	}
+	site, err := ParseScannerPresets(stored.Value)
+	if err != nil {
+		s.logger.WithError(err).Warn("站点自定义扫码枪预设无效，已忽略")
+		return presets
+	}
+	return append(presets, site...)
+}
+
+// Get 按名称获取预设
+func (s *ScannerPresetService) Get(name string) (*ScannerPreset, error) {
+	for _, preset := range s.List() {
+		if preset.Name == name {
+			return &preset, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPreset, name)
+}
+
+// Active 启动时应用到键盘钩子的预设，未使用预设时为空
+func (s *ScannerPresetService) Active() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// Apply 将预设展开到扫码参数，explicit 返回true的参数（如配置文件中显式设置的）保留原值
+func (s *ScannerPresetService) Apply(cfg *config.ScannerConfig, name string, explicit func(key string) bool) error {
+	preset, err := s.Get(name)
+	if err != nil {
+		return err
+	}
+
+	var overridden []string
+	set := func(key string, apply func()) {
+		if explicit != nil && explicit(key) {
+			overridden = append(overridden, key)
+			return
+		}
+		apply()
+	}
+	settings := preset.Settings
+	set("timeout_ms", func() { cfg.TimeoutMS = settings.TimeoutMS })
+	set("min_length", func() { cfg.MinLength = settings.MinLength })
+	set("max_length", func() { cfg.MaxLength = settings.MaxLength })
+	set("terminator_collapse_ms", func() { cfg.TerminatorCollapseMS = settings.TerminatorCollapseMS })
+	set("merge_fragments", func() { cfg.MergeFragments = settings.MergeFragments })
+	set("suspect_gap_ms", func() { cfg.SuspectGapMS = settings.SuspectGapMS })
+	sort.Strings(overridden)
+
+	s.mu.Lock()
+	s.active = preset.Name
+	s.mu.Unlock()
+
+	s.logger.WithFields(logrus.Fields{
+		"preset":                 preset.Name,
+		"source":                 preset.Source,
+		"overridden":             overridden,
+		"timeout_ms":             cfg.TimeoutMS,
+		"min_length":             cfg.MinLength,
+		"max_length":             cfg.MaxLength,
+		"terminator_collapse_ms": cfg.TerminatorCollapseMS,
+		"merge_fragments":        cfg.MergeFragments,
+		"suspect_gap_ms":         cfg.SuspectGapMS,
+	}).Info("已应用扫码枪预设")
+	return nil
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+const (
+	// maxListedMissing 断号记录中最多列出的缺失序号数量
+	maxListedMissing = 100
+	// maxPendingMissing 等待乱序补扫的最大缺失数量，超出时立即上报
+	maxPendingMissing = 1000
+)
+
+// SequenceGapEvent 断号事件
+type SequenceGapEvent struct {
+	Rule         string   `json:"rule"`
+	Prefix       string   `json:"prefix"`
+	DeviceID     uint     `json:"device_id"`
+	FromValue    int64    `json:"from_value"`
+	ToValue      int64    `json:"to_value"`
+	MissingCount int64    `json:"missing_count"`
+	Missing      []string `json:"missing"`
+}
+
+// sequenceRule 编译后的断号检测规则
+type sequenceRule struct {
+	config.SequenceRule
+	regex *regexp.Regexp
+}
+
+// sequenceKey 序号跟踪键
+type sequenceKey struct {
+	rule     string
+	prefix   string
+	deviceID uint
+}
+
+// sequenceTracker 单个键的跟踪状态
+type sequenceTracker struct {
+	lastValue int64
+	width     int
+	scans     int64
+	pending   map[int64]int64 // 缺失序号 -> 允许补扫的截止扫码次数
+}
+
+// SequenceService 序列号断号检测服务
+type SequenceService struct {
+	db        *gorm.DB
+	config    config.SequenceConfig
+	rules     []*sequenceRule
+	logger    *logrus.Logger
+	mu        sync.Mutex
+	trackers  map[sequenceKey]*sequenceTracker
+	listeners []func(SequenceGapEvent)
+}
+
+// NewSequenceService 创建序列号断号检测服务
+func NewSequenceService(db *gorm.DB, cfg config.SequenceConfig, logger *logrus.Logger) (*SequenceService, error) {
+	rules := make([]*sequenceRule, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("断号检测规则 '%s' 的正则无效: %w", rule.Name, err)
+		}
+		if regex.NumSubexp() < 2 {
+			return nil, fmt.Errorf("断号检测规则 '%s' 的正则需包含前缀和序号两个分组", rule.Name)
+		}
+		if rule.Window < 0 {
+			return nil, fmt.Errorf("断号检测规则 '%s' 的乱序窗口不能为负数", rule.Name)
+		}
+		rules = append(rules, &sequenceRule{SequenceRule: rule, regex: regex})
+	}
+
+	return &SequenceService{
+		db:       db,
+		config:   cfg,
+		rules:    rules,
+		logger:   logger,
+		trackers: make(map[sequenceKey]*sequenceTracker),
+	}, nil
+}
+
+// OnGap 注册断号回调
+func (s *SequenceService) OnGap(listener func(SequenceGapEvent)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// Observe 检查新入库的条码记录是否产生断号
+func (s *SequenceService) Observe(record *models.BarcodeRecord) {
+	if !s.config.Enable {
+		return
+	}
+
+	var deviceID uint
+	if record.DeviceID != nil {
+		deviceID = *record.DeviceID
+	}
+
+	// 控制条码：重置该设备的所有序号
+	if s.config.ResetBarcode != "" && record.Content == s.config.ResetBarcode {
+		if err := s.Reset("", "", &deviceID); err != nil {
+			s.logger.WithError(err).Error("重置序号失败")
+		}
+		return
+	}
+
+	for _, rule := range s.rules {
+		if rule.Type != "" && rule.Type != record.Type {
+			continue
+		}
+		match := rule.regex.FindStringSubmatch(record.Content)
+		if match == nil {
+			continue
+		}
+
+		prefix, digits := match[1], match[2]
+		value, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		s.track(rule, sequenceKey{rule: rule.Name, prefix: prefix, deviceID: deviceID}, value, len(digits))
+		return
+	}
+}
+
+// Reset 重置序号状态（换卷等合法重置），参数为空表示不限
+func (s *SequenceService) Reset(rule, prefix string, deviceID *uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.trackers {
+		if (rule == "" || key.rule == rule) && (prefix == "" || key.prefix == prefix) && (deviceID == nil || key.deviceID == *deviceID) {
+			delete(s.trackers, key)
+		}
+	}
+
+	query := s.db.Where("1 = 1")
+	if rule != "" {
+		query = query.Where("rule = ?", rule)
+	}
+	if prefix != "" {
+		query = query.Where("prefix = ?", prefix)
+	}
+	if deviceID != nil {
+		query = query.Where("device_id = ?", *deviceID)
+	}
+	if err := query.Delete(&models.SequenceState{}).Error; err != nil {
+		return fmt.Errorf("删除序号状态失败: %w", err)
+	}
+
+	s.logger.WithField("rule", rule).WithField("prefix", prefix).Info("序号已重置")
+	return nil
+}
+
+// SequenceSession 序号跟踪会话，即某规则、前缀及设备的最后序号
+type SequenceSession struct {
+	Rule      string    `json:"rule"`
+	Prefix    string    `json:"prefix"`
+	DeviceID  uint      `json:"device_id"`
+	LastValue int64     `json:"last_value"`
+	Pending   int       `json:"pending"` // 等待乱序补扫的缺失序号数
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ActiveSessions 时间范围 [from, to) 内推进过序号的跟踪会话
+func (s *SequenceService) ActiveSessions(from, to time.Time) ([]SequenceSession, error) {
+	var states []*models.SequenceState
+	if err := s.db.Where("updated_at >= ? AND updated_at < ?", from, to).
+		Order("rule, prefix, device_id").Find(&states).Error; err != nil {
+		return nil, fmt.Errorf("查询序号状态失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make([]SequenceSession, 0, len(states))
+	for _, state := range states {
+		session := SequenceSession{
+			Rule:      state.Rule,
+			Prefix:    state.Prefix,
+			DeviceID:  state.DeviceID,
+			LastValue: state.LastValue,
+			UpdatedAt: state.UpdatedAt,
+		}
+		if tracker, ok := s.trackers[sequenceKey{rule: state.Rule, prefix: state.Prefix, deviceID: state.DeviceID}]; ok {
+			session.Pending = len(tracker.pending)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// CloseSessions 结束 since 之后推进过序号的跟踪会话，下次扫码重新开始跟踪，返回结束的会话数；须在开始接收扫码前调用
+func (s *SequenceService) CloseSessions(since time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.db.Where("updated_at >= ?", since).Delete(&models.SequenceState{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("结束序号跟踪会话失败: %w", result.Error)
+	}
+	// 内存中的跟踪状态按需从数据库加载，清空后重新加载
+	s.trackers = make(map[sequenceKey]*sequenceTracker)
+	return result.RowsAffected, nil
+}
+
+// GetGaps 分页查询断号记录
+func (s *SequenceService) GetGaps(page, pageSize int, prefix string, deviceID *uint) ([]*models.SequenceGap, int64, error) {
+	var gaps []*models.SequenceGap
+	var total int64
+
+	query := s.db.Model(&models.SequenceGap{})
+	if prefix != "" {
+		query = query.Where("prefix = ?", prefix)
+	}
+	if deviceID != nil {
+		query = query.Where("device_id = ?", *deviceID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&gaps).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return gaps, total, nil
+}
+
+// track 更新序号并检测断号
+func (s *SequenceService) track(rule *sequenceRule, key sequenceKey, value int64, width int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracker, err := s.loadTracker(key)
+	if err != nil {
+		s.logger.WithError(err).Error("读取序号状态失败")
+		return
+	}
+
+	// 首次出现，只记录基准
+	if tracker == nil {
+		tracker = &sequenceTracker{lastValue: value, width: width, pending: make(map[int64]int64)}
+		s.trackers[key] = tracker
+		s.saveTracker(key, tracker)
+		return
+	}
+
+	tracker.scans++
+
+	// 补扫了待确认的缺失序号
+	delete(tracker.pending, value)
+
+	if gapSize := value - tracker.lastValue - 1; gapSize > 0 {
+		// 无乱序窗口或跳号过大时立即上报，否则等待窗口内补扫
+		if rule.Window == 0 || gapSize > maxPendingMissing {
+			missing := make([]int64, 0, maxListedMissing)
+			for v := tracker.lastValue + 1; v < value && len(missing) < maxListedMissing; v++ {
+				missing = append(missing, v)
+			}
+			s.reportGap(key, tracker, tracker.lastValue, value, gapSize, missing)
+		} else {
+			for missing := tracker.lastValue + 1; missing < value; missing++ {
+				tracker.pending[missing] = tracker.scans + int64(rule.Window)
+			}
+		}
+	}
+
+	if value > tracker.lastValue {
+		tracker.lastValue = value
+		tracker.width = width
+		s.saveTracker(key, tracker)
+	}
+
+	// 超出乱序窗口仍未补扫的序号判定为断号
+	s.flushExpired(key, tracker)
+}
+
+// flushExpired 上报超出乱序窗口的缺失序号
+func (s *SequenceService) flushExpired(key sequenceKey, tracker *sequenceTracker) {
+	var expired []int64
+	for missing, deadline := range tracker.pending {
+		if tracker.scans > deadline {
+			expired = append(expired, missing)
+			delete(tracker.pending, missing)
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i] < expired[j] })
+	count := int64(len(expired))
+	from, to := expired[0]-1, expired[len(expired)-1]+1
+	if len(expired) > maxListedMissing {
+		expired = expired[:maxListedMissing]
+	}
+	s.reportGap(key, tracker, from, to, count, expired)
+}
+
+// reportGap 记录并广播断号
+func (s *SequenceService) reportGap(key sequenceKey, tracker *sequenceTracker, from, to, count int64, values []int64) {
+	missing := make([]string, 0, len(values))
+	for _, v := range values {
+		missing = append(missing, fmt.Sprintf("%s%0*d", key.prefix, tracker.width, v))
+	}
+
+	data, _ := json.Marshal(missing)
+	gap := &models.SequenceGap{
+		Rule:         key.rule,
+		Prefix:       key.prefix,
+		DeviceID:     key.deviceID,
+		FromValue:    from,
+		ToValue:      to,
+		MissingCount: count,
+		Missing:      string(data),
+	}
+	if err := s.db.Create(gap).Error; err != nil {
+		s.logger.WithError(err).Error("保存断号记录失败")
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"rule":          key.rule,
+		"prefix":        key.prefix,
+		"device_id":     key.deviceID,
+		"missing_count": count,
+	}).Warn("检测到序列号断号")
+
+	event := SequenceGapEvent{
+		Rule:         key.rule,
+		Prefix:       key.prefix,
+		DeviceID:     key.deviceID,
+		FromValue:    from,
+		ToValue:      to,
+		MissingCount: count,
+		Missing:      missing,
+	}
+	for _, listener := range s.listeners {
+		listener(event)
+	}
+}
+
+// loadTracker 获取跟踪状态，内存中不存在时从数据库恢复
+func (s *SequenceService) loadTracker(key sequenceKey) (*sequenceTracker, error) {
+	if tracker, ok := s.trackers[key]; ok {
+		return tracker, nil
+	}
+
+	var state models.SequenceState
+	err := s.db.Where("rule = ? AND prefix = ? AND device_id = ?", key.rule, key.prefix, key.deviceID).First(&state).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := &sequenceTracker{lastValue: state.LastValue, width: state.Width, pending: make(map[int64]int64)}
+	s.trackers[key] = tracker
+	return tracker, nil
+}
+
+// saveTracker 持久化最后序号，避免重启后误报断号
+func (s *SequenceService) saveTracker(key sequenceKey, tracker *sequenceTracker) {
+	state := models.SequenceState{
+		Rule:      key.rule,
+		Prefix:    key.prefix,
+		DeviceID:  key.deviceID,
+		LastValue: tracker.lastValue,
+		Width:     tracker.width,
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "rule"}, {Name: "prefix"}, {Name: "device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_value", "width", "updated_at"}),
+	}).Create(&state).Error
+	if err != nil {
+		s.logger.WithError(err).Error("保存序号状态失败")
+	}
+}
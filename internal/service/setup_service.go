@@ -0,0 +1,521 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// 首次运行向导的步骤，按顺序完成
+const (
+	SetupStepStation  = "station"  // 工位名称及时区
+	SetupStepAdmin    = "admin"    // 管理员密钥
+	SetupStepDatabase = "database" // 数据库类型及连接
+	SetupStepDevice   = "device"   // 首个设备
+	SetupStepCentral  = "central"  // 加入中心服务器（可跳过）
+	SetupStepComplete = "complete"
+)
+
+// setupSteps 向导步骤顺序
+var setupSteps = []string{SetupStepStation, SetupStepAdmin, SetupStepDatabase, SetupStepDevice, SetupStepCentral}
+
+// minAdminKeyLength 管理员密钥的最小长度
+const minAdminKeyLength = 16
+
+// setupStateFile 向导进度文件名，与配置文件在同一目录
+const setupStateFile = "setup-state.json"
+
+var (
+	// ErrSetupCompleted 首次运行配置已完成
+	ErrSetupCompleted = errors.New("首次运行配置已完成")
+	// ErrSetupOrder 前面的步骤尚未完成
+	ErrSetupOrder = errors.New("请先完成前面的步骤")
+	// ErrInvalidSetup 向导步骤的取值无效
+	ErrInvalidSetup = errors.New("配置无效")
+	// ErrSetupConnection 数据库或中心服务器连接测试失败
+	ErrSetupConnection = errors.New("连接测试失败")
+)
+
+// SetupStation 工位信息
+type SetupStation struct {
+	Name     string `json:"name"`
+	Timezone string `json:"timezone"` // IANA时区名称，为空表示系统时区
+}
+
+// SetupAdmin 管理员密钥（security.api_key）
+type SetupAdmin struct {
+	APIKey string `json:"api_key"`
+}
+
+// SetupDatabase 数据库连接
+type SetupDatabase struct {
+	Type     string    `json:"type"`
+	DSN      string    `json:"dsn"`
+	TestedAt time.Time `json:"tested_at"`
+}
+
+// SetupDevice 首个设备
+type SetupDevice struct {
+	Name     string `json:"name"`
+	SerialNo string `json:"serial_no"`
+	Model    string `json:"model"`
+}
+
+// SetupCentral 中心服务器，Skipped 表示作为独立工位运行
+type SetupCentral struct {
+	URL      string     `json:"url,omitempty"`
+	Token    string     `json:"token,omitempty"`
+	Skipped  bool       `json:"skipped"`
+	TestedAt *time.Time `json:"tested_at,omitempty"`
+}
+
+// SetupState 向导进度，每完成一步保存到文件，重启后继续
+type SetupState struct {
+	Station   *SetupStation  `json:"station,omitempty"`
+	Admin     *SetupAdmin    `json:"admin,omitempty"`
+	Database  *SetupDatabase `json:"database,omitempty"`
+	Device    *SetupDevice   `json:"device,omitempty"`
+	Central   *SetupCentral  `json:"central,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// SetupStatus 向导状态，密钥及令牌不返回
+type SetupStatus struct {
+	Provisioned bool           `json:"provisioned"`
+	Next        string         `json:"next"` // 下一个待完成的步骤，全部完成时为 complete
+	Done        []string       `json:"done"`
+	Station     *SetupStation  `json:"station,omitempty"`
+	Admin       bool           `json:"admin"` // 是否已设置管理员密钥
+	Database    *SetupDatabase `json:"database,omitempty"`
+	Device      *SetupDevice   `json:"device,omitempty"`
+	Central     *SetupCentral  `json:"central,omitempty"`
+}
+
+// SetupDatabaseTest 数据库连接测试结果
+type SetupDatabaseTest struct {
+	LatencyMS int64 `json:"latency_ms"`
+	Existing  bool  `json:"existing"` // 已有本系统的数据表
+	Records   int64 `json:"records"`  // 已有的扫码记录数
+}
+
+// SetupResult 完成向导的结果
+type SetupResult struct {
+	DeviceID        uint   `json:"device_id"`
+	ConfigPath      string `json:"config_path"`
+	RestartRequired bool   `json:"restart_required"`
+}
+
+// SetupService 首次运行向导
+//
+// 全新安装时配置文件中 app.provisioned 为 false，向导依次设置工位、管理员密钥、数据库、
+// 首个设备及中心服务器，每步校验后保存进度；完成时在所选数据库中登记设备，将各项写入
+// 配置文件并置 app.provisioned 为 true。
+type SetupService struct {
+	configPath string
+	statePath  string
+	config     *config.Config
+	logger     *logrus.Logger
+	client     *http.Client
+
+	mu        sync.Mutex
+	state     SetupState
+	completed bool
+}
+
+// NewSetupService 创建首次运行向导，读取上次保存的进度
+func NewSetupService(configPath string, cfg *config.Config, logger *logrus.Logger) (*SetupService, error) {
+	s := &SetupService{
+		configPath: configPath,
+		statePath:  filepath.Join(filepath.Dir(configPath), setupStateFile),
+		config:     cfg,
+		logger:     logger,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+
+	data, err := os.ReadFile(s.statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取首次运行向导进度失败: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("解析首次运行向导进度失败: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Status 获取向导状态
+func (s *SetupService) Status() SetupStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := SetupStatus{
+		Provisioned: s.completed,
+		Next:        SetupStepComplete,
+		Done:        make([]string, 0, len(setupSteps)),
+		Station:     s.state.Station,
+		Admin:       s.state.Admin != nil,
+		Database:    s.state.Database,
+		Device:      s.state.Device,
+	}
+	for _, step := range setupSteps {
+		if s.done(step) {
+			status.Done = append(status.Done, step)
+		} else if status.Next == SetupStepComplete {
+			status.Next = step
+		}
+	}
+	if s.state.Central != nil {
+		central := *s.state.Central
+		central.Token = ""
+		status.Central = &central
+	}
+	return status
+}
+
+// SetStation 设置工位名称及时区
+func (s *SetupService) SetStation(station SetupStation) error {
+	station.Name = strings.TrimSpace(station.Name)
+	station.Timezone = strings.TrimSpace(station.Timezone)
+	if station.Name == "" || len(station.Name) > 100 {
+		return fmt.Errorf("%w: 工位名称不能为空且不超过100个字符", ErrInvalidSetup)
+	}
+	if _, err := config.LoadLocation(station.Timezone); err != nil {
+		return fmt.Errorf("%w: 时区无效，应为IANA时区名称，如 Asia/Shanghai", ErrInvalidSetup)
+	}
+
+	return s.update(SetupStepStation, func(state *SetupState) {
+		state.Station = &station
+	})
+}
+
+// SetAdmin 设置管理员密钥，为空时生成随机密钥；返回的密钥只在此时可见
+func (s *SetupService) SetAdmin(apiKey string) (string, error) {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("生成管理员密钥失败: %w", err)
+		}
+		apiKey = hex.EncodeToString(buf)
+	}
+	if len(apiKey) < minAdminKeyLength {
+		return "", fmt.Errorf("%w: 管理员密钥不能少于 %d 个字符", ErrInvalidSetup, minAdminKeyLength)
+	}
+
+	err := s.update(SetupStepAdmin, func(state *SetupState) {
+		state.Admin = &SetupAdmin{APIKey: apiKey}
+	})
+	if err != nil {
+		return "", err
+	}
+	return apiKey, nil
+}
+
+// SetDatabase 测试数据库连接，成功后保存
+func (s *SetupService) SetDatabase(dbType, dsn string) (*SetupDatabaseTest, error) {
+	dbType = strings.TrimSpace(dbType)
+	dsn = strings.TrimSpace(dsn)
+	if dbType == "" {
+		dbType = "sqlite"
+	}
+	if dbType != "sqlite" {
+		return nil, fmt.Errorf("%w: 当前版本仅支持 sqlite 数据库", ErrInvalidSetup)
+	}
+	if dsn == "" {
+		return nil, fmt.Errorf("%w: 数据库地址不能为空", ErrInvalidSetup)
+	}
+	if err := s.check(SetupStepDatabase); err != nil {
+		return nil, err
+	}
+
+	result, err := s.testDatabase(dbType, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.update(SetupStepDatabase, func(state *SetupState) {
+		state.Database = &SetupDatabase{Type: dbType, DSN: dsn, TestedAt: time.Now()}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// testDatabase 打开数据库并查询已有的扫码记录数
+func (s *SetupService) testDatabase(dbType, dsn string) (*SetupDatabaseTest, error) {
+	dbConfig := s.config.Database
+	dbConfig.Type = dbType
+	dbConfig.DSN = dsn
+
+	began := time.Now()
+	db, err := database.New(&dbConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSetupConnection, err)
+	}
+	defer db.Close()
+
+	result := &SetupDatabaseTest{LatencyMS: time.Since(began).Milliseconds()}
+	if db.Migrator().HasTable(&models.BarcodeRecord{}) {
+		result.Existing = true
+		if err := db.Model(&models.BarcodeRecord{}).Count(&result.Records).Error; err != nil {
+			return nil, fmt.Errorf("%w: 查询扫码记录失败: %v", ErrSetupConnection, err)
+		}
+	}
+	return result, nil
+}
+
+// SetDevice 设置首个设备，完成向导时登记到所选数据库
+func (s *SetupService) SetDevice(device SetupDevice) error {
+	device.Name = strings.TrimSpace(device.Name)
+	device.SerialNo = strings.TrimSpace(device.SerialNo)
+	device.Model = strings.TrimSpace(device.Model)
+	if device.Name == "" || len(device.Name) > 100 {
+		return fmt.Errorf("%w: 设备名称不能为空且不超过100个字符", ErrInvalidSetup)
+	}
+	if len(device.SerialNo) > 100 || len(device.Model) > 100 {
+		return fmt.Errorf("%w: 序列号及型号不能超过100个字符", ErrInvalidSetup)
+	}
+
+	return s.update(SetupStepDevice, func(state *SetupState) {
+		state.Device = &device
+	})
+}
+
+// SetCentral 测试中心服务器连接及令牌，成功后保存；skip 为 true 时作为独立工位运行
+func (s *SetupService) SetCentral(centralURL, token string, skip bool) error {
+	if skip {
+		return s.update(SetupStepCentral, func(state *SetupState) {
+			state.Central = &SetupCentral{Skipped: true}
+		})
+	}
+
+	centralURL = strings.TrimRight(strings.TrimSpace(centralURL), "/")
+	token = strings.TrimSpace(token)
+	u, err := url.Parse(centralURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: 中心服务器地址须为 http:// 或 https:// 开头", ErrInvalidSetup)
+	}
+	if token == "" {
+		return fmt.Errorf("%w: 令牌不能为空", ErrInvalidSetup)
+	}
+	if err := s.check(SetupStepCentral); err != nil {
+		return err
+	}
+
+	if err := s.testCentral(centralURL, token); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.update(SetupStepCentral, func(state *SetupState) {
+		state.Central = &SetupCentral{URL: centralURL, Token: token, TestedAt: &now}
+	})
+}
+
+// testCentral 以令牌请求中心服务器的状态接口
+func (s *SetupService) testCentral(centralURL, token string) error {
+	req, err := http.NewRequest(http.MethodGet, centralURL+"/api/status", nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSetupConnection, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: 无法连接中心服务器: %v", ErrSetupConnection, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: 中心服务器拒绝了令牌（HTTP %d）", ErrSetupConnection, resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("%w: 中心服务器返回 HTTP %d", ErrSetupConnection, resp.StatusCode)
+	}
+	return nil
+}
+
+// Complete 完成向导：在所选数据库中登记首个设备，写入配置文件并置 app.provisioned 为 true
+//
+// 数据库、时区等配置在重启后生效；启用配置热加载时认证配置立即生效。
+func (s *SetupService) Complete() (*SetupResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.completed {
+		return nil, ErrSetupCompleted
+	}
+	for _, step := range setupSteps {
+		if !s.done(step) {
+			return nil, fmt.Errorf("%w: %s", ErrSetupOrder, step)
+		}
+	}
+	state := s.state
+
+	deviceID, err := s.registerDevice(state.Database, state.Device, state.Station.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{
+		"app.timezone":         state.Station.Timezone,
+		"app.provisioned":      true,
+		"peers.station":        state.Station.Name,
+		"security.enable_auth": true,
+		"security.api_key":     state.Admin.APIKey,
+		"database.type":        state.Database.Type,
+		"database.dsn":         state.Database.DSN,
+		"central.url":          state.Central.URL,
+		"central.token":        state.Central.Token,
+	}
+	if err := config.WriteValues(s.configPath, values); err != nil {
+		return nil, err
+	}
+
+	s.completed = true
+	if err := os.Remove(s.statePath); err != nil && !os.IsNotExist(err) {
+		s.logger.WithError(err).Warn("删除首次运行向导进度文件失败")
+	}
+	s.logger.WithField("station", state.Station.Name).WithField("device_id", deviceID).
+		Info("首次运行配置已完成，重启后生效")
+
+	return &SetupResult{DeviceID: deviceID, ConfigPath: s.configPath, RestartRequired: true}, nil
+}
+
+// registerDevice 在所选数据库中登记首个设备
+//
+// 新数据库迁移时会自动创建默认设备，此时以向导中的设备信息替换它，而不是新增一个。
+// 设备归入向导中设置的工位。
+func (s *SetupService) registerDevice(dbState *SetupDatabase, device *SetupDevice, station string) (uint, error) {
+	dbConfig := s.config.Database
+	dbConfig.Type = dbState.Type
+	dbConfig.DSN = dbState.DSN
+
+	db, err := database.New(&dbConfig)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrSetupConnection, err)
+	}
+	defer db.Close()
+	if err := db.AutoMigrate(); err != nil {
+		return 0, err
+	}
+
+	devices := NewDeviceService(db.DB, station, s.logger)
+	updates := map[string]interface{}{"name": device.Name, "model": device.Model}
+
+	// 已登记过该序列号的设备（如重新安装后沿用原数据库）时更新
+	if device.SerialNo != "" {
+		var existing []models.Device
+		if err := db.Where("serial_no = ?", device.SerialNo).Limit(1).Find(&existing).Error; err != nil {
+			return 0, fmt.Errorf("查询设备失败: %w", err)
+		}
+		if len(existing) > 0 {
+			return existing[0].ID, updateSetupDevice(devices, existing[0].ID, updates, station)
+		}
+		updates["serial_no"] = device.SerialNo
+	}
+
+	var current []models.Device
+	if err := db.Limit(2).Find(&current).Error; err != nil {
+		return 0, fmt.Errorf("查询设备失败: %w", err)
+	}
+	if len(current) == 1 && current[0].SerialNo == database.DefaultDeviceSerialNo {
+		return current[0].ID, updateSetupDevice(devices, current[0].ID, updates, station)
+	}
+
+	created := &models.Device{Name: device.Name, Model: device.Model, SerialNo: device.SerialNo}
+	if err := devices.CreateDevice(created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+// updateSetupDevice 更新已登记的设备并归入指定工位
+func updateSetupDevice(devices *DeviceService, id uint, updates map[string]interface{}, station string) error {
+	if err := devices.UpdateDevice(id, updates); err != nil {
+		return err
+	}
+	_, err := devices.AssignStation(id, station)
+	return err
+}
+
+// check 检查前面的步骤是否已完成
+func (s *SetupService) check(step string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkLocked(step)
+}
+
+// checkLocked 检查前面的步骤是否已完成，调用方须持有锁
+func (s *SetupService) checkLocked(step string) error {
+	if s.completed {
+		return ErrSetupCompleted
+	}
+	for _, previous := range setupSteps {
+		if previous == step {
+			return nil
+		}
+		if !s.done(previous) {
+			return fmt.Errorf("%w: %s", ErrSetupOrder, previous)
+		}
+	}
+	return nil
+}
+
+// update 修改进度并保存到文件
+func (s *SetupService) update(step string, apply func(*SetupState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkLocked(step); err != nil {
+		return err
+	}
+
+	next := s.state
+	apply(&next)
+	next.UpdatedAt = time.Now()
+
+	// 进度中含管理员密钥及令牌，仅当前用户可读
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return fmt.Errorf("保存首次运行向导进度失败: %w", err)
+	}
+	if err := os.WriteFile(s.statePath, data, 0600); err != nil {
+		return fmt.Errorf("保存首次运行向导进度失败: %w", err)
+	}
+	s.state = next
+	return nil
+}
+
+// done 步骤是否已完成，调用方须持有锁
+func (s *SetupService) done(step string) bool {
+	switch step {
+	case SetupStepStation:
+		return s.state.Station != nil
+	case SetupStepAdmin:
+		return s.state.Admin != nil
+	case SetupStepDatabase:
+		return s.state.Database != nil
+	case SetupStepDevice:
+		return s.state.Device != nil
+	case SetupStepCentral:
+		return s.state.Central != nil
+	}
+	return false
+}
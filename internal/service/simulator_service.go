@@ -0,0 +1,440 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// maxSimulationSteps 场景的最大扫码条数
+const maxSimulationSteps = 10000
+
+var (
+	// ErrInvalidScenario 模拟场景无效
+	ErrInvalidScenario = errors.New("模拟场景无效")
+	// ErrSimulatorState 模拟扫码当前状态不允许该操作
+	ErrSimulatorState = errors.New("模拟扫码状态不允许该操作")
+)
+
+// yamlLinePattern 从 YAML 语法错误中提取行号
+var yamlLinePattern = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// ScenarioError 模拟场景的解析错误，Line 为出错的行号（从1开始，未知时为0）
+type ScenarioError struct {
+	Line    int
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *ScenarioError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("第 %d 行: %s", e.Line, e.Message)
+	}
+	return e.Message
+}
+
+// Unwrap 使 errors.Is(err, ErrInvalidScenario) 成立
+func (e *ScenarioError) Unwrap() error {
+	return ErrInvalidScenario
+}
+
+// SimulationStep 模拟场景中的一次扫码
+type SimulationStep struct {
+	Content  string        `json:"content"`
+	Delay    time.Duration `json:"-"`                // 与上一次扫码的间隔（按1倍速）
+	DelayMS  int64         `json:"delay_ms"`         // 与 Delay 相同，单位毫秒
+	Device   string        `json:"device,omitempty"` // 设备序列号或公开ID，为空表示默认设备
+	Line     int           `json:"line"`             // 在场景文件中的行号
+	deviceID *uint
+}
+
+// SimulationScenario 已加载的模拟场景
+type SimulationScenario struct {
+	Name       string           `json:"name"`
+	Steps      []SimulationStep `json:"steps"`
+	DurationMS int64            `json:"duration_ms"` // 按1倍速播放一遍的时长
+	LoadedAt   time.Time        `json:"loaded_at"`
+}
+
+// SimulationOptions 启动模拟扫码的参数，为nil的项使用配置
+type SimulationOptions struct {
+	Loop  *bool
+	Speed *float64
+}
+
+// SimulationStatus 模拟扫码的运行状态
+type SimulationStatus struct {
+	Running   bool       `json:"running"`
+	Scenario  string     `json:"scenario,omitempty"`
+	Steps     int        `json:"steps"`
+	Position  int        `json:"position"` // 下一次扫码在场景中的序号（从0开始）
+	Loops     int        `json:"loops"`    // 已完整播放的遍数
+	Loop      bool       `json:"loop"`
+	Speed     float64    `json:"speed"`
+	Emitted   int64      `json:"emitted"` // 本次运行已模拟的扫码数
+	Failed    int64      `json:"failed"`  // 其中处理失败（格式无效等）的扫码数
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// SimulationSink 模拟扫码的处理函数，与外部推送的扫码一样经过校验、分类、入库及推送
+type SimulationSink func(content string, scan ScanInfo) error
+
+// SimulatorService 按场景文件模拟扫码，用于演示及回归测试
+//
+// 场景为扫码列表（YAML或JSON），每条包含内容、与上一次扫码的间隔及设备，按顺序播放，
+// 可循环及按倍速播放。模拟的扫码来源为 simulation，推送的事件带 simulation 标记，
+// 可通过 simulator.exclude_from_stats 从统计中排除。
+type SimulatorService struct {
+	db     *gorm.DB
+	config config.SimulatorConfig
+	logger *logrus.Logger
+
+	mu       sync.Mutex
+	sink     SimulationSink
+	scenario *SimulationScenario
+	status   SimulationStatus
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSimulatorService 创建模拟扫码服务
+func NewSimulatorService(db *gorm.DB, cfg config.SimulatorConfig, logger *logrus.Logger) *SimulatorService {
+	if cfg.Speed <= 0 {
+		cfg.Speed = 1
+	}
+	return &SimulatorService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+		status: SimulationStatus{Loop: cfg.Loop, Speed: cfg.Speed},
+	}
+}
+
+// SetSink 设置模拟扫码的处理函数
+func (s *SimulatorService) SetSink(sink SimulationSink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sink = sink
+}
+
+// Start 加载配置的场景文件，开启 auto_start 时开始播放
+func (s *SimulatorService) Start() error {
+	if s.config.Scenario == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.config.Scenario)
+	if err != nil {
+		return fmt.Errorf("读取模拟场景失败: %w", err)
+	}
+	scenario, err := s.Load(filepath.Base(s.config.Scenario), data)
+	if err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{"scenario": scenario.Name, "steps": len(scenario.Steps)}).Info("已加载模拟场景")
+
+	if s.config.AutoStart {
+		if _, err := s.Run(SimulationOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close 停止播放
+func (s *SimulatorService) Close() {
+	s.Stop()
+}
+
+// Load 解析并加载场景，替换已加载的场景；播放中不允许替换
+func (s *SimulatorService) Load(name string, data []byte) (*SimulationScenario, error) {
+	steps, err := ParseSimulationScenario(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveDevices(steps); err != nil {
+		return nil, err
+	}
+
+	scenario := &SimulationScenario{Name: name, Steps: steps, LoadedAt: time.Now()}
+	for _, step := range steps {
+		scenario.DurationMS += step.DelayMS
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Running {
+		return nil, fmt.Errorf("%w: 播放中不能替换场景，请先停止", ErrSimulatorState)
+	}
+	s.scenario = scenario
+	s.status.Scenario = name
+	s.status.Steps = len(steps)
+	s.status.Position = 0
+	return scenario, nil
+}
+
+// Scenario 已加载的场景，未加载时返回nil
+func (s *SimulatorService) Scenario() *SimulationScenario {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scenario
+}
+
+// Run 从头开始播放已加载的场景
+func (s *SimulatorService) Run(opts SimulationOptions) (SimulationStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.scenario == nil:
+		return s.status, fmt.Errorf("%w: 尚未加载场景", ErrSimulatorState)
+	case s.status.Running:
+		return s.status, fmt.Errorf("%w: 模拟扫码已在运行", ErrSimulatorState)
+	case s.sink == nil:
+		return s.status, fmt.Errorf("%w: 未设置扫码处理", ErrSimulatorState)
+	}
+
+	loop, speed := s.config.Loop, s.config.Speed
+	if opts.Loop != nil {
+		loop = *opts.Loop
+	}
+	if loop && s.scenario.DurationMS == 0 {
+		return s.status, fmt.Errorf("%w: 循环播放的场景总间隔须大于0", ErrInvalidScenario)
+	}
+	if opts.Speed != nil {
+		if *opts.Speed <= 0 || *opts.Speed > 100 {
+			return s.status, fmt.Errorf("%w: 倍速须大于0且不超过100", ErrInvalidScenario)
+		}
+		speed = *opts.Speed
+	}
+
+	now := time.Now()
+	s.status = SimulationStatus{
+		Running:   true,
+		Scenario:  s.scenario.Name,
+		Steps:     len(s.scenario.Steps),
+		Loop:      loop,
+		Speed:     speed,
+		StartedAt: &now,
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go s.play(s.scenario, loop, speed, s.stop)
+
+	s.logger.WithFields(logrus.Fields{"scenario": s.scenario.Name, "loop": loop, "speed": speed}).Info("模拟扫码已开始")
+	return s.status, nil
+}
+
+// Stop 停止播放，未运行时直接返回当前状态
+func (s *SimulatorService) Stop() SimulationStatus {
+	s.mu.Lock()
+	stop := s.stop
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		s.wg.Wait()
+	}
+	return s.Status()
+}
+
+// Status 当前运行状态
+func (s *SimulatorService) Status() SimulationStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// play 按顺序播放场景，stop 关闭或不循环时播放完一遍后结束
+func (s *SimulatorService) play(scenario *SimulationScenario, loop bool, speed float64, stop chan struct{}) {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	stopped := false
+	for !stopped {
+		for i, step := range scenario.Steps {
+			timer.Reset(time.Duration(float64(step.Delay) / speed))
+			select {
+			case <-stop:
+				stopped = true
+			case <-timer.C:
+			}
+			if stopped {
+				break
+			}
+			s.emit(i, step)
+		}
+		if stopped {
+			break
+		}
+
+		s.mu.Lock()
+		s.status.Loops++
+		s.status.Position = 0
+		s.mu.Unlock()
+		if !loop {
+			break
+		}
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.StoppedAt = &now
+	if s.stop == stop {
+		s.stop = nil
+	}
+	status := s.status
+	s.mu.Unlock()
+	s.logger.WithFields(logrus.Fields{"emitted": status.Emitted, "failed": status.Failed, "loops": status.Loops}).Info("模拟扫码已结束")
+}
+
+// emit 模拟一次扫码
+func (s *SimulatorService) emit(index int, step SimulationStep) {
+	s.mu.Lock()
+	sink := s.sink
+	s.mu.Unlock()
+
+	err := sink(step.Content, ScanInfo{Source: models.BarcodeSourceSimulation, DeviceID: step.deviceID})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Emitted++
+	s.status.Position = index + 1
+	if err != nil {
+		s.status.Failed++
+		s.status.LastError = fmt.Sprintf("第 %d 行: %v", step.Line, err)
+	}
+}
+
+// resolveDevices 查询场景中引用的设备，设备不存在时返回带行号的错误
+func (s *SimulatorService) resolveDevices(steps []SimulationStep) error {
+	devices := make(map[string]uint)
+	for i := range steps {
+		identifier := steps[i].Device
+		if identifier == "" {
+			continue
+		}
+		id, ok := devices[identifier]
+		if !ok {
+			var found []models.Device
+			if err := s.db.Where("serial_no = ? OR public_id = ?", identifier, identifier).Limit(1).Find(&found).Error; err != nil {
+				return fmt.Errorf("查询设备失败: %w", err)
+			}
+			if len(found) == 0 {
+				return &ScenarioError{Line: steps[i].Line, Message: fmt.Sprintf("设备 %s 不存在", identifier)}
+			}
+			id = found[0].ID
+			devices[identifier] = id
+		}
+		steps[i].deviceID = &id
+	}
+	return nil
+}
+
+// ParseSimulationScenario 解析模拟场景，场景为扫码列表（YAML或JSON），每条包含 content（扫码内容）、
+// delay（与上一次扫码的间隔，整数表示毫秒，也可为 500ms 等时长）及 device（设备序列号或公开ID，可省略），
+// 如 [{"content": "6901234567892", "delay": "500ms"}]。
+//
+// 解析错误返回 *ScenarioError，带出错的行号。
+func ParseSimulationScenario(data []byte) ([]SimulationStep, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		scenarioErr := &ScenarioError{Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if m := yamlLinePattern.FindStringSubmatch(err.Error()); m != nil {
+			scenarioErr.Line, _ = strconv.Atoi(m[1])
+			scenarioErr.Message = strings.TrimPrefix(err.Error(), m[0])
+		}
+		return nil, scenarioErr
+	}
+	if len(doc.Content) == 0 {
+		return nil, &ScenarioError{Message: "场景为空"}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		return nil, &ScenarioError{Line: root.Line, Message: "场景须为扫码列表"}
+	}
+	if len(root.Content) == 0 {
+		return nil, &ScenarioError{Line: root.Line, Message: "场景中没有扫码"}
+	}
+	if len(root.Content) > maxSimulationSteps {
+		return nil, &ScenarioError{Line: root.Line, Message: fmt.Sprintf("场景最多 %d 条扫码", maxSimulationSteps)}
+	}
+
+	steps := make([]SimulationStep, 0, len(root.Content))
+	for _, item := range root.Content {
+		step, err := parseSimulationStep(item)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// parseSimulationStep 解析场景中的一条扫码
+func parseSimulationStep(item *yaml.Node) (SimulationStep, error) {
+	step := SimulationStep{Line: item.Line}
+	if item.Kind != yaml.MappingNode {
+		return step, &ScenarioError{Line: item.Line, Message: "每条扫码须包含 content、delay、device 字段"}
+	}
+
+	for i := 0; i+1 < len(item.Content); i += 2 {
+		key, value := item.Content[i], item.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return step, &ScenarioError{Line: value.Line, Message: fmt.Sprintf("%s 须为字符串或数字", key.Value)}
+		}
+		switch key.Value {
+		case "content":
+			step.Content = value.Value
+		case "delay":
+			delay, err := parseSimulationDelay(value.Value)
+			if err != nil {
+				return step, &ScenarioError{Line: value.Line, Message: err.Error()}
+			}
+			step.Delay = delay
+			step.DelayMS = delay.Milliseconds()
+		case "device":
+			step.Device = value.Value
+		default:
+			return step, &ScenarioError{Line: key.Line, Message: fmt.Sprintf("未知字段 %s", key.Value)}
+		}
+	}
+
+	if step.Content == "" {
+		return step, &ScenarioError{Line: item.Line, Message: "缺少扫码内容 content"}
+	}
+	return step, nil
+}
+
+// parseSimulationDelay 解析扫码间隔，整数表示毫秒，也可为 500ms、1.5s 等时长
+func parseSimulationDelay(value string) (time.Duration, error) {
+	var delay time.Duration
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		delay = time.Duration(ms) * time.Millisecond
+	} else if delay, err = time.ParseDuration(value); err != nil {
+		return 0, fmt.Errorf("间隔 %q 无效，应为毫秒数或 500ms、1.5s 等时长", value)
+	}
+	if delay < 0 || delay > time.Hour {
+		return 0, fmt.Errorf("间隔 %q 须在0到1h之间", value)
+	}
+	return delay, nil
+}
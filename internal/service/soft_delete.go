@@ -0,0 +1,31 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeletedConflict 唯一键属于已删除（软删除）的记录
+//
+// 软删除的记录仍占用唯一索引，同一序列号或配置键无法直接新建。
+// 统一的处理方式是恢复已删除的记录而不是新建，错误中附带该记录的信息供调用方提示恢复。
+var ErrDeletedConflict = errors.New("与已删除的记录冲突")
+
+// DeletedConflictError 新建的记录与已删除的记录唯一键相同
+type DeletedConflictError struct {
+	Kind      string    // device 或 configuration
+	Field     string    // 冲突的字段
+	Value     string    // 冲突的值
+	ID        uint      // 已删除记录的ID
+	DeletedAt time.Time // 删除时间
+}
+
+func (e *DeletedConflictError) Error() string {
+	return fmt.Sprintf("%s: %s %s='%s' 属于已删除的记录（ID %d，删除于 %s），请恢复该记录",
+		ErrDeletedConflict, e.Kind, e.Field, e.Value, e.ID, e.DeletedAt.Format(time.RFC3339))
+}
+
+func (e *DeletedConflictError) Unwrap() error {
+	return ErrDeletedConflict
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// TestDeviceDeleteRecreateRestore 删除设备后以同一序列号新建返回冲突，恢复后工位已有活跃设备时为非活跃
+func TestDeviceDeleteRecreateRestore(t *testing.T) {
+	_, db := newTestConfigs(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	devices := NewDeviceService(db.DB, "A1", logger)
+
+	original := &models.Device{Name: "1号线扫码枪", SerialNo: "SN-0001"}
+	if err := devices.CreateDevice(original); err != nil {
+		t.Fatal(err)
+	}
+	if !original.IsActive {
+		t.Fatal("first device of the station should be active")
+	}
+	if err := devices.DeleteDevice(original.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一序列号新建：冲突并指出已删除的设备
+	err := devices.CreateDevice(&models.Device{Name: "替换扫码枪", SerialNo: "SN-0001"})
+	var conflict *DeletedConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrDeletedConflict) {
+		t.Fatalf("recreate err = %v, want DeletedConflictError", err)
+	}
+	if conflict.Kind != "device" || conflict.Field != "serial_no" || conflict.Value != "SN-0001" || conflict.ID != original.ID || conflict.DeletedAt.IsZero() {
+		t.Fatalf("conflict = %+v", conflict)
+	}
+
+	// 删除期间工位有了新的活跃设备
+	replacement := &models.Device{Name: "替换扫码枪", SerialNo: "SN-0002"}
+	if err := devices.CreateDevice(replacement); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := devices.RestoreDevice(original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != original.ID || restored.SerialNo != "SN-0001" || restored.IsActive {
+		t.Fatalf("restored = %+v, want inactive original device", restored)
+	}
+	if active, _ := devices.GetDevice(replacement.ID); !active.IsActive {
+		t.Fatal("restore deactivated the station's active device")
+	}
+
+	// 未删除的设备不能恢复；恢复后以同一序列号新建仍失败
+	if _, err := devices.RestoreDevice(original.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("restore of a live device err = %v, want ErrRecordNotFound", err)
+	}
+	if err := devices.CreateDevice(&models.Device{Name: "另一台", SerialNo: "SN-0001"}); err == nil || errors.Is(err, ErrDeletedConflict) {
+		t.Fatalf("recreate after restore err = %v, want unique constraint error", err)
+	}
+}
+
+// TestDeviceRestoreNameTaken 名称已被其他设备使用时拒绝恢复，设备保持删除状态
+func TestDeviceRestoreNameTaken(t *testing.T) {
+	_, db := newTestConfigs(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	devices := NewDeviceService(db.DB, "A1", logger)
+
+	deleted := &models.Device{Name: "出口扫码枪", SerialNo: "SN-0001", Station: "A1"}
+	if err := devices.CreateDevice(deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := devices.DeleteDevice(deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := devices.CreateDevice(&models.Device{Name: "出口扫码枪", SerialNo: "SN-0002", Station: "A2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := devices.RestoreDevice(deleted.ID); !errors.Is(err, ErrDeviceNameTaken) {
+		t.Fatalf("restore err = %v, want ErrDeviceNameTaken", err)
+	}
+	if _, err := devices.GetDevice(deleted.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("device restored despite name conflict: %v", err)
+	}
+}
+
+// TestConfigDeleteRecreateRestore 删除配置后以同一键设置返回冲突，确认恢复或显式恢复后沿用原记录
+func TestConfigDeleteRecreateRestore(t *testing.T) {
+	configs, db := newTestConfigs(t)
+	actor := ConfigActor{Name: "operator"}
+	if _, err := configs.SetConfigurationAs(actor, "dashboard.title", "一号线", "ui", "看板标题"); err != nil {
+		t.Fatal(err)
+	}
+	original, err := configs.GetConfiguration("dashboard.title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := configs.DeleteConfigurationAs(actor, original.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configs.GetConfiguration("dashboard.title"); err == nil {
+		t.Fatal("deleted config still readable")
+	}
+
+	// 未确认恢复
+	_, err = configs.SetConfigurationAs(actor, "dashboard.title", "二号线", "", "")
+	var conflict *DeletedConflictError
+	if !errors.As(err, &conflict) || conflict.Kind != "configuration" || conflict.Value != "dashboard.title" || conflict.ID != original.ID {
+		t.Fatalf("recreate err = %v, want DeletedConflictError for config %d", err, original.ID)
+	}
+
+	// 确认恢复并写入新值
+	actor.Restore = true
+	if _, err := configs.SetConfigurationAs(actor, "dashboard.title", "二号线", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := configs.GetConfiguration("dashboard.title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != original.ID || restored.Value != "二号线" || restored.Category != "ui" {
+		t.Fatalf("restored = %+v, want original record with the new value", restored)
+	}
+
+	// 显式恢复保留删除前的值
+	if err := configs.DeleteConfigurationAs(actor, original.ID); err != nil {
+		t.Fatal(err)
+	}
+	if restored, err = configs.RestoreConfiguration("dashboard.title"); err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != original.ID || restored.Value != "二号线" {
+		t.Fatalf("restored = %+v", restored)
+	}
+	if _, err := configs.RestoreConfiguration("dashboard.title"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("restore of a live config err = %v, want ErrRecordNotFound", err)
+	}
+	var count int64
+	db.Unscoped().Model(&models.Configuration{}).Where("key = ?", "dashboard.title").Count(&count)
+	if count != 1 {
+		t.Fatalf("rows for key = %d, want 1", count)
+	}
+}
+
+// TestConfigBulkWritesRestoreDeleted 内部写入、批量设置及导入遇到已删除的键时直接恢复
+func TestConfigBulkWritesRestoreDeleted(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(configs *ConfigService) error
+	}{
+		{name: "internal", write: func(configs *ConfigService) error {
+			return configs.SetConfiguration("dashboard.title", "新标题", "", "")
+		}},
+		{name: "batch", write: func(configs *ConfigService) error {
+			return configs.BatchSetConfigurations([]models.Configuration{{Key: "dashboard.title", Value: "新标题", Type: "string"}})
+		}},
+		{name: "import without overwrite", write: func(configs *ConfigService) error {
+			return configs.ImportConfigurations([]*models.Configuration{{Key: "dashboard.title", Value: "新标题", Type: "string"}}, false)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs, _ := newTestConfigs(t)
+			if err := configs.SetConfiguration("dashboard.title", "旧标题", "ui", ""); err != nil {
+				t.Fatal(err)
+			}
+			original, _ := configs.GetConfiguration("dashboard.title")
+			if err := configs.DeleteConfiguration(original.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.write(configs); err != nil {
+				t.Fatal(err)
+			}
+			restored, err := configs.GetConfiguration("dashboard.title")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restored.ID != original.ID || restored.Value != "新标题" {
+				t.Fatalf("restored = %+v, want record %d with the new value", restored, original.ID)
+			}
+		})
+	}
+}
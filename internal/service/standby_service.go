@@ -0,0 +1,430 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 热备角色状态
+const (
+	StandbyStatePrimary    = "primary"     // 采集扫码的主机
+	StandbyStateStandby    = "standby"     // 待命，不采集扫码（主机等待采集租约时同样为此状态）
+	StandbyStateTakingOver = "taking_over" // 备机已接管，采集扫码直到主机恢复
+)
+
+// StandbyEvent 热备角色变化
+type StandbyEvent struct {
+	Role     string    `json:"role"`
+	Previous string    `json:"previous"`
+	Instance string    `json:"instance"`
+	Reason   string    `json:"reason"`
+	At       time.Time `json:"at"`
+}
+
+// PrimaryHealth 备机观察到的主机健康状态
+type PrimaryHealth struct {
+	URL            string     `json:"url"`
+	Healthy        bool       `json:"healthy"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastCheckAt    *time.Time `json:"last_check_at,omitempty"`
+	UnhealthySince *time.Time `json:"unhealthy_since,omitempty"`
+	HealthySince   *time.Time `json:"healthy_since,omitempty"`
+}
+
+// LeaseStatus 采集租约状态
+type LeaseStatus struct {
+	Name      string     `json:"name"`
+	Held      bool       `json:"held"`             // 本机是否持有
+	Holder    string     `json:"holder,omitempty"` // 最近一次查询到的持有者
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// StandbyStatus 热备状态
+type StandbyStatus struct {
+	Enabled    bool           `json:"enabled"`
+	Configured string         `json:"configured_role"` // 配置的角色：primary 或 standby
+	Role       string         `json:"role"`            // 当前状态：primary、standby 或 taking_over
+	Instance   string         `json:"instance"`
+	Capturing  bool           `json:"capturing"`
+	Since      time.Time      `json:"since"`
+	Reason     string         `json:"reason,omitempty"`
+	Primary    *PrimaryHealth `json:"primary,omitempty"`
+	Lease      *LeaseStatus   `json:"lease,omitempty"`
+}
+
+// readiness 主机 /readyz 的响应
+type readiness struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons"`
+}
+
+// StandbyService 同一工位主备两台工位机的热备切换
+//
+// 备机启动时不采集扫码，按固定间隔检查主机的 /readyz；主机持续不健康超过 takeover_after 时接管，
+// 主机恢复健康持续 recover_after 后交还并回到待命。主机处于维护模式视为健康，不触发接管。
+// 两台工位机共用数据库时由数据库中的采集租约保证同一时刻只有一方采集：
+// 取得租约才能采集，持有者按检查间隔续租，停机后租约过期另一方才能取得；网络隔离导致备机误判主机停机时，
+// 主机仍在续租，备机取不到租约也不会接管。
+type StandbyService struct {
+	db        *gorm.DB
+	config    config.StandbyConfig
+	instance  string
+	leaseName string
+	client    *http.Client
+	logger    *logrus.Logger
+	listeners []func(StandbyEvent)
+	done      chan struct{}
+	wg        sync.WaitGroup
+
+	mu           sync.Mutex
+	role         string
+	since        time.Time
+	reason       string
+	primary      PrimaryHealth
+	leaseHeld    bool
+	leaseExpires time.Time // 本机持有的租约的失效时间
+	leaseHolder  string
+	holderExpiry time.Time
+	blocked      bool // 已提示主机不健康但租约仍由对方持有
+}
+
+// NewStandbyService 创建热备服务，租约按工位命名
+func NewStandbyService(db *gorm.DB, cfg config.StandbyConfig, station string, logger *logrus.Logger) *StandbyService {
+	instance := cfg.Instance
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	if station == "" {
+		station = "default"
+	}
+
+	// 不使用租约的主机始终采集，其余情况在首次检查后确定
+	role := StandbyStateStandby
+	if cfg.Role == config.StandbyRolePrimary && !cfg.Lease {
+		role = StandbyStatePrimary
+	}
+
+	return &StandbyService{
+		db:        db,
+		config:    cfg,
+		instance:  instance,
+		leaseName: "capture:" + station,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		logger:    logger,
+		done:      make(chan struct{}),
+		role:      role,
+		since:     time.Now(),
+		primary:   PrimaryHealth{URL: cfg.PrimaryURL},
+	}
+}
+
+// OnChange 注册角色变化的回调，须在 Start 前调用
+func (s *StandbyService) OnChange(listener func(StandbyEvent)) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// Capturing 本机是否采集扫码
+func (s *StandbyService) Capturing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.role != StandbyStateStandby
+}
+
+// Start 立即检查一次以确定初始角色，之后按检查间隔定时检查
+func (s *StandbyService) Start() {
+	s.tick(time.Now())
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				s.tick(now)
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止检查并释放租约，另一方无需等待租约过期即可取得
+func (s *StandbyService) Close() {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	held := s.leaseHeld
+	s.mu.Unlock()
+	if held {
+		if err := s.release(time.Now()); err != nil {
+			s.logger.WithError(err).Warn("释放采集租约失败")
+		}
+	}
+}
+
+// Status 热备状态
+func (s *StandbyService) Status() StandbyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := StandbyStatus{
+		Enabled:    true,
+		Configured: s.config.Role,
+		Role:       s.role,
+		Instance:   s.instance,
+		Capturing:  s.role != StandbyStateStandby,
+		Since:      s.since,
+		Reason:     s.reason,
+	}
+	if s.config.Role == config.StandbyRoleStandby {
+		primary := s.primary
+		status.Primary = &primary
+	}
+	if s.config.Lease {
+		lease := &LeaseStatus{Name: s.leaseName, Held: s.leaseHeld, Holder: s.leaseHolder}
+		if s.leaseHeld {
+			expires := s.leaseExpires
+			lease.ExpiresAt = &expires
+		} else if !s.holderExpiry.IsZero() {
+			expires := s.holderExpiry
+			lease.ExpiresAt = &expires
+		}
+		status.Lease = lease
+	}
+	return status
+}
+
+// tick 一次检查
+func (s *StandbyService) tick(now time.Time) {
+	if s.config.Role == config.StandbyRolePrimary {
+		s.tickPrimary(now)
+		return
+	}
+	s.tickStandby(now)
+}
+
+// tickPrimary 主机：不使用租约时始终采集；使用租约时取得或续租成功才采集
+func (s *StandbyService) tickPrimary(now time.Time) {
+	if !s.config.Lease {
+		return
+	}
+	if s.renew(now) {
+		s.transition(StandbyStatePrimary, "已取得采集租约", now)
+		return
+	}
+	s.transition(StandbyStateStandby, s.leaseUnavailable(), now)
+}
+
+// tickStandby 备机：检查主机健康，持续不健康时接管，恢复后交还
+func (s *StandbyService) tickStandby(now time.Time) {
+	err := s.checkPrimary()
+
+	s.mu.Lock()
+	checked := now
+	s.primary.LastCheckAt = &checked
+	s.primary.Healthy = err == nil
+	if err == nil {
+		s.primary.LastError = ""
+		s.primary.UnhealthySince = nil
+		if s.primary.HealthySince == nil {
+			s.primary.HealthySince = &checked
+		}
+	} else {
+		s.primary.LastError = err.Error()
+		s.primary.HealthySince = nil
+		if s.primary.UnhealthySince == nil {
+			s.primary.UnhealthySince = &checked
+		}
+	}
+	primary := s.primary
+	role := s.role
+	s.mu.Unlock()
+
+	switch role {
+	case StandbyStateStandby:
+		if primary.UnhealthySince == nil || now.Sub(*primary.UnhealthySince) < s.config.TakeoverAfter {
+			s.mu.Lock()
+			s.blocked = false
+			s.mu.Unlock()
+			return
+		}
+		reason := fmt.Sprintf("主机持续不健康 %s: %s", now.Sub(*primary.UnhealthySince).Round(time.Second), primary.LastError)
+		if s.config.Lease && !s.renew(now) {
+			s.mu.Lock()
+			blocked := s.blocked
+			s.blocked = true
+			s.mu.Unlock()
+			if !blocked {
+				s.logger.WithField("reason", reason).Warn("主机不健康但无法取得采集租约，暂不接管: " + s.leaseUnavailable())
+			}
+			return
+		}
+		s.transition(StandbyStateTakingOver, reason, now)
+
+	case StandbyStateTakingOver:
+		if primary.HealthySince != nil && now.Sub(*primary.HealthySince) >= s.config.RecoverAfter {
+			if s.config.Lease {
+				if err := s.release(now); err != nil {
+					s.logger.WithError(err).Warn("释放采集租约失败，主机需等待租约过期")
+				}
+			}
+			s.transition(StandbyStateStandby, fmt.Sprintf("主机已恢复健康 %s，交还扫码采集", now.Sub(*primary.HealthySince).Round(time.Second)), now)
+			return
+		}
+		if s.config.Lease && !s.renew(now) {
+			s.transition(StandbyStateStandby, s.leaseUnavailable(), now)
+		}
+	}
+}
+
+// checkPrimary 检查主机的 /readyz，主机就绪或仅因维护模式降级时返回 nil
+func (s *StandbyService) checkPrimary() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(s.config.PrimaryURL, "/")+"/readyz", nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("主机不可达: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body readiness
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("解析主机就绪状态失败（HTTP %d）: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("主机未就绪（HTTP %d）: %s", resp.StatusCode, strings.Join(body.Reasons, "; "))
+	}
+	var problems []string
+	for _, reason := range body.Reasons {
+		if reason != "maintenance" {
+			problems = append(problems, reason)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("主机降级: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// renew 取得或续租采集租约，租约空闲、已过期或本机持有时成功；数据库出错时本机持有的租约在过期前仍视为有效
+func (s *StandbyService) renew(now time.Time) bool {
+	holder, expires, err := s.acquire(now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.logger.WithError(err).Warn("续租采集租约失败")
+		s.leaseHeld = s.leaseHeld && now.Before(s.leaseExpires)
+		return s.leaseHeld
+	}
+	s.leaseHolder, s.holderExpiry = holder, expires
+	s.leaseHeld = holder == s.instance
+	if s.leaseHeld {
+		s.leaseExpires = expires
+	}
+	return s.leaseHeld
+}
+
+// acquire 尝试取得租约，返回当前持有者及失效时间
+func (s *StandbyService) acquire(now time.Time) (string, time.Time, error) {
+	expires := now.Add(s.config.LeaseTTL)
+	result := s.db.Model(&models.LeaderLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", s.leaseName, s.instance, now).
+		Updates(map[string]interface{}{
+			"acquired_at": gorm.Expr("CASE WHEN holder = ? THEN acquired_at ELSE ? END", s.instance, now),
+			"holder":      s.instance,
+			"expires_at":  expires,
+		})
+	if result.Error != nil {
+		return "", time.Time{}, fmt.Errorf("更新采集租约失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return s.instance, expires, nil
+	}
+
+	var lease models.LeaderLease
+	err := s.db.Where("name = ?", s.leaseName).First(&lease).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		lease = models.LeaderLease{Name: s.leaseName, Holder: s.instance, AcquiredAt: now, ExpiresAt: expires}
+		if err := s.db.Create(&lease).Error; err != nil {
+			// 另一方同时创建，下次检查时按其持有处理
+			return "", time.Time{}, fmt.Errorf("创建采集租约失败: %w", err)
+		}
+		return s.instance, expires, nil
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("查询采集租约失败: %w", err)
+	}
+	return lease.Holder, lease.ExpiresAt, nil
+}
+
+// release 释放本机持有的租约
+func (s *StandbyService) release(now time.Time) error {
+	s.mu.Lock()
+	s.leaseHeld = false
+	s.mu.Unlock()
+
+	err := s.db.Model(&models.LeaderLease{}).
+		Where("name = ? AND holder = ?", s.leaseName, s.instance).
+		Update("expires_at", now).Error
+	if err != nil {
+		return fmt.Errorf("释放采集租约失败: %w", err)
+	}
+	return nil
+}
+
+// leaseUnavailable 未取得租约的原因
+func (s *StandbyService) leaseUnavailable() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leaseHolder == "" || s.leaseHolder == s.instance {
+		return "采集租约不可用"
+	}
+	return fmt.Sprintf("采集租约由 %s 持有（有效至 %s）", s.leaseHolder, s.holderExpiry.Local().Format("15:04:05"))
+}
+
+// transition 切换角色，角色变化时通知
+func (s *StandbyService) transition(role, reason string, now time.Time) {
+	s.mu.Lock()
+	if s.role == role {
+		s.mu.Unlock()
+		return
+	}
+	event := StandbyEvent{Role: role, Previous: s.role, Instance: s.instance, Reason: reason, At: now}
+	s.role, s.since, s.reason = role, now, reason
+	s.blocked = false
+	s.mu.Unlock()
+
+	entry := s.logger.WithFields(logrus.Fields{"role": role, "previous": event.Previous, "reason": reason})
+	if role == StandbyStateStandby {
+		entry.Warn("热备：停止采集扫码")
+	} else {
+		entry.Warn("热备：开始采集扫码")
+	}
+	for _, listener := range s.listeners {
+		listener(event)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatsCache 统计结果缓存，按查询参数缓存并在扫码入库时失效
+type StatsCache struct {
+	ttl          time.Duration
+	location     *time.Location // "今日"统计按该时区的零点失效
+	mu           sync.Mutex
+	entries      map[string]*statsCacheEntry
+	calls        map[string]*statsCacheCall
+	generation   uint64
+	lastModified time.Time
+	hits         int64
+	misses       int64
+	shared       int64
+}
+
+// statsCacheEntry 缓存条目
+type statsCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// statsCacheCall 正在进行的计算，同一键的并发请求共享结果
+type statsCacheCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// StatsCacheMetrics 缓存指标
+type StatsCacheMetrics struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	Shared   int64   `json:"shared"`
+	HitRatio float64 `json:"hit_ratio"`
+	Entries  int     `json:"entries"`
+	TTL      string  `json:"ttl"`
+}
+
+// NewStatsCache 创建统计结果缓存，loc 为"今日"统计使用的时区
+func NewStatsCache(ttl time.Duration, loc *time.Location) *StatsCache {
+	if ttl <= 0 {
+		ttl = 5 * time.Second
+	}
+	return &StatsCache{
+		ttl:          ttl,
+		location:     loc,
+		entries:      make(map[string]*statsCacheEntry),
+		calls:        make(map[string]*statsCacheCall),
+		lastModified: time.Now(),
+	}
+}
+
+// Validator 查询参数为 key 的统计结果当前的 ETag 及最后修改时间，条件请求据此在计算统计前判断是否变化
+//
+// ETag 由失效次数、最后修改时间（纳秒）及查询参数生成，同一秒内的扫码及跨天都会使其变化。
+func (c *StatsCache) Validator(key string) (string, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lastModified := c.effectiveLastModified()
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("\"%d-%x-%08x\"", c.generation, lastModified.UnixNano(), h.Sum32()), lastModified
+}
+
+// Get 获取缓存结果，未命中时调用load计算
+func (c *StatsCache) Get(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && time.Now().Before(entry.expiresAt) {
+		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
+		return entry.value, nil
+	}
+
+	// 已有相同键的计算在进行，等待其结果，避免击穿数据库
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		atomic.AddInt64(&c.shared, 1)
+		call.wg.Wait()
+		return call.value, call.err
+	}
+
+	call := &statsCacheCall{}
+	call.wg.Add(1)
+	c.calls[key] = call
+	generation := c.generation
+	c.mu.Unlock()
+
+	atomic.AddInt64(&c.misses, 1)
+	call.value, call.err = load()
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	// 计算期间发生失效则不写入缓存，避免保存过期结果
+	if call.err == nil && generation == c.generation {
+		c.entries[key] = &statsCacheEntry{
+			value:     call.value,
+			expiresAt: time.Now().Add(c.ttl),
+		}
+	}
+	c.mu.Unlock()
+	call.wg.Done()
+
+	return call.value, call.err
+}
+
+// Invalidate 清空缓存并更新最后修改时间
+func (c *StatsCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*statsCacheEntry)
+	c.generation++
+	c.lastModified = time.Now()
+}
+
+// GetMetrics 获取缓存指标
+func (c *StatsCache) GetMetrics() StatsCacheMetrics {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	hits := atomic.LoadInt64(&c.hits)
+	misses := atomic.LoadInt64(&c.misses)
+	shared := atomic.LoadInt64(&c.shared)
+
+	var ratio float64
+	if total := hits + misses + shared; total > 0 {
+		ratio = float64(hits+shared) / float64(total)
+	}
+
+	return StatsCacheMetrics{
+		Hits:     hits,
+		Misses:   misses,
+		Shared:   shared,
+		HitRatio: ratio,
+		Entries:  entries,
+		TTL:      c.ttl.String(),
+	}
+}
+
+// effectiveLastModified 最后修改时间，跨天时以当天零点为准（"今日"统计会变化）
+func (c *StatsCache) effectiveLastModified() time.Time {
+	now := time.Now().In(c.location)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, c.location)
+	if c.lastModified.Before(midnight) {
+		return midnight
+	}
+	return c.lastModified
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsCacheValidatorChangesOnEveryInvalidate(t *testing.T) {
+	cache := NewStatsCache(time.Minute, time.UTC)
+	first, _ := cache.Validator("")
+	again, _ := cache.Validator("")
+	if first != again {
+		t.Fatalf("validator changed without invalidation: %s != %s", first, again)
+	}
+
+	// 同一秒内的多次扫码也须得到不同的 ETag
+	seen := map[string]bool{first: true}
+	for i := 0; i < 5; i++ {
+		cache.Invalidate()
+		etag, _ := cache.Validator("")
+		if seen[etag] {
+			t.Fatalf("invalidation %d reused ETag %s", i, etag)
+		}
+		seen[etag] = true
+	}
+
+	withTZ, _ := cache.Validator("tz=Asia%2FShanghai")
+	plain, _ := cache.Validator("")
+	if withTZ == plain {
+		t.Fatal("different query parameters share an ETag")
+	}
+}
+
+func TestStatsCacheGetCachesUntilInvalidate(t *testing.T) {
+	cache := NewStatsCache(time.Minute, time.UTC)
+	loads := 0
+	load := func() (interface{}, error) {
+		loads++
+		return loads, nil
+	}
+	if v, err := cache.Get("", load); err != nil || v != 1 {
+		t.Fatalf("Get = %v, %v", v, err)
+	}
+	if v, _ := cache.Get("", load); v != 1 || loads != 1 {
+		t.Fatalf("cached Get reloaded: value %v, loads %d", v, loads)
+	}
+	cache.Invalidate()
+	if v, _ := cache.Get("", load); v != 2 {
+		t.Fatalf("Get after Invalidate = %v, want 2", v)
+	}
+}
@@ -0,0 +1,878 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 冲突策略：中心内容变化时，本地修改过的条目的处理方式
+const (
+	SyncPolicyCentral = "central" // 以中心为准，覆盖本地修改
+	SyncPolicyLocal   = "local"   // 保留本地修改
+	SyncPolicyReview  = "review"  // 登记冲突，等待人工处理
+)
+
+// SyncSignatureHeader 同步包签名响应头，值为 sha256=<以 central.token 为密钥对响应体计算的 HMAC-SHA256 十六进制>
+const SyncSignatureHeader = "X-Signature"
+
+// syncMaxBundleSize 同步包大小上限
+const syncMaxBundleSize = 8 << 20
+
+// syncActor 同步以此身份修改系统配置，审计日志中可据此区分
+const syncActor = "central-sync"
+
+// ErrSyncConflictResolved 同步冲突已处理
+var ErrSyncConflictResolved = errors.New("同步冲突已处理")
+
+// ErrInvalidSyncResolution 冲突处理方式无效
+var ErrInvalidSyncResolution = errors.New("冲突处理方式无效，须为 central 或 local")
+
+// SyncBundle 中心服务器下发的同步包，条目格式见 SyncDevice、SyncRule、SyncConfigValue
+//
+// 同步包是完整的期望状态：包中的条目新建或更新，上一版本有而本版本没有的条目从本地移除。
+type SyncBundle struct {
+	Version int64             `json:"version"` // 单调递增，与已应用的版本相同时不重复应用
+	Devices []json.RawMessage `json:"devices"`
+	Rules   []json.RawMessage `json:"rules"`
+	Config  []json.RawMessage `json:"config"`
+}
+
+// SyncDevice 同步包中的设备，按序列号匹配
+type SyncDevice struct {
+	SerialNo    string `json:"serial_no"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Model       string `json:"model"`
+	Description string `json:"description"`
+	Preset      string `json:"preset,omitempty"` // 扫码参数预设名称
+}
+
+// SyncRule 同步包中的分类规则，按名称匹配
+type SyncRule struct {
+	Name     string           `json:"name"`
+	Pattern  string           `json:"pattern"`
+	Type     string           `json:"type"`
+	Fields   models.StringMap `json:"fields,omitempty"`
+	Priority int              `json:"priority"`
+	Enabled  bool             `json:"enabled"`
+}
+
+// SyncConfigValue 同步包中的系统配置，按配置键匹配，只比较取值
+type SyncConfigValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Category    string `json:"category,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SyncResult 一次同步的结果，应用后上报给中心服务器
+type SyncResult struct {
+	Version   int64    `json:"version"`
+	Skipped   bool     `json:"skipped"`   // 版本未变化，未应用
+	Applied   int      `json:"applied"`   // 新建或更新的条目数
+	Removed   int      `json:"removed"`   // 同步包中已移除而在本地删除（设备为停用）的条目数
+	Unchanged int      `json:"unchanged"` // 本地已与中心一致的条目数
+	Kept      int      `json:"kept"`      // 本地修改过而保留的条目数
+	Conflicts int      `json:"conflicts"` // 登记待处理的冲突数
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// SyncStatus 中心同步状态
+type SyncStatus struct {
+	Enabled          bool        `json:"enabled"`
+	ConflictPolicy   string      `json:"conflict_policy"`
+	Version          int64       `json:"version"` // 已完整应用的同步包版本
+	LastCheckAt      *time.Time  `json:"last_check_at,omitempty"`
+	LastAppliedAt    *time.Time  `json:"last_applied_at,omitempty"`
+	LastError        string      `json:"last_error,omitempty"`
+	PendingConflicts int64       `json:"pending_conflicts"`
+	LastResult       *SyncResult `json:"last_result,omitempty"`
+}
+
+// syncLocal 本地条目的规范化内容
+type syncLocal struct {
+	content []byte
+	edited  bool // 创建后修改过，用于判断尚未由同步管理的条目是否为本地修改
+}
+
+// syncTarget 一类同步条目在本地的读写方式
+//
+// canonical 与 local 返回用于比较的规范化内容，两者相同即视为一致，local 在本地不存在时返回nil；
+// apply 与 remove 经由各自的服务修改本地数据，校验、审计及热加载与手动修改相同。
+// remove 为nil表示从同步包中移除的条目保留本地当前内容。
+type syncTarget struct {
+	kind      string
+	canonical func(raw json.RawMessage) (key string, content []byte, err error)
+	local     func(key string) (*syncLocal, error)
+	apply     func(raw json.RawMessage) error
+	remove    func(key string) error
+}
+
+// SyncService 从中心服务器拉取设备、分类规则及系统配置
+//
+// 按间隔拉取签名的同步包，版本号未变化时跳过；每个条目记录最近一次应用的中心内容摘要，
+// 只有中心内容变化的条目才会应用。本地修改过的条目按 conflict_policy 处理。
+// 同步包中所有条目都应用成功后才记录版本号并上报，部分失败时下次拉取重试。
+type SyncService struct {
+	db      *gorm.DB
+	config  config.CentralConfig
+	station string
+	devices *DeviceService
+	rules   *RuleService
+	configs *ConfigService
+	client  *http.Client
+	logger  *logrus.Logger
+	targets []*syncTarget
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	runMu sync.Mutex // 同步与冲突处理互斥
+
+	mu     sync.RWMutex
+	status SyncStatus
+}
+
+// NewSyncService 创建中心同步服务
+func NewSyncService(db *gorm.DB, cfg config.CentralConfig, station string, devices *DeviceService, rules *RuleService, configs *ConfigService, logger *logrus.Logger) *SyncService {
+	if cfg.Sync.Interval <= 0 {
+		cfg.Sync.Interval = 5 * time.Minute
+	}
+	if cfg.Sync.Timeout <= 0 {
+		cfg.Sync.Timeout = 10 * time.Second
+	}
+	if cfg.Sync.ConflictPolicy == "" {
+		cfg.Sync.ConflictPolicy = SyncPolicyReview
+	}
+
+	s := &SyncService{
+		db:      db,
+		config:  cfg,
+		station: station,
+		devices: devices,
+		rules:   rules,
+		configs: configs,
+		client:  &http.Client{Timeout: cfg.Sync.Timeout},
+		logger:  logger,
+		done:    make(chan struct{}),
+		status: SyncStatus{
+			Enabled:        true,
+			ConflictPolicy: cfg.Sync.ConflictPolicy,
+		},
+	}
+	s.targets = []*syncTarget{s.deviceTarget(), s.ruleTarget(), s.configTarget()}
+
+	var state models.SyncState
+	if err := db.First(&state).Error; err == nil {
+		s.status.Version = state.Version
+		appliedAt := state.AppliedAt
+		s.status.LastAppliedAt = &appliedAt
+	}
+	s.refreshPending()
+	return s
+}
+
+// Start 启动定时同步，启动时先同步一次
+func (s *SyncService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.Sync.Interval)
+		defer ticker.Stop()
+
+		s.runLogged()
+		for {
+			select {
+			case <-ticker.C:
+				s.runLogged()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时同步
+func (s *SyncService) Close() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// GetStatus 获取同步状态
+func (s *SyncService) GetStatus() SyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Run 立即拉取并应用同步包
+func (s *SyncService) Run() (*SyncResult, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	checkedAt := time.Now()
+	result, err := s.run()
+
+	s.mu.Lock()
+	s.status.LastCheckAt = &checkedAt
+	s.status.LastError = ""
+	if result != nil {
+		s.status.LastResult = result
+		if len(result.Errors) > 0 {
+			s.status.LastError = fmt.Sprintf("%d 个条目应用失败: %s", len(result.Errors), result.Errors[0])
+		}
+	}
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	s.refreshPending()
+	return result, err
+}
+
+// runLogged 定时同步，失败只记录日志
+func (s *SyncService) runLogged() {
+	result, err := s.Run()
+	if err != nil {
+		s.logger.WithError(err).Warn("中心同步失败")
+		return
+	}
+	if !result.Skipped {
+		s.logger.WithField("version", result.Version).
+			WithField("applied", result.Applied).
+			WithField("removed", result.Removed).
+			WithField("conflicts", result.Conflicts).
+			WithField("errors", len(result.Errors)).
+			Info("已应用中心同步包")
+	}
+}
+
+// run 拉取、应用并上报
+func (s *SyncService) run() (*SyncResult, error) {
+	version := s.GetStatus().Version
+	bundle, err := s.fetch(version)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil || bundle.Version == version {
+		return &SyncResult{Version: version, Skipped: true}, nil
+	}
+	if bundle.Version < version {
+		return nil, fmt.Errorf("同步包版本 %d 低于已应用的版本 %d", bundle.Version, version)
+	}
+
+	result := s.apply(bundle)
+	s.audit(result)
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	now := time.Now()
+	state := models.SyncState{ID: 1, Version: bundle.Version, AppliedAt: now}
+	if err := s.db.Save(&state).Error; err != nil {
+		return result, fmt.Errorf("保存同步版本失败: %w", err)
+	}
+	s.mu.Lock()
+	s.status.Version = bundle.Version
+	s.status.LastAppliedAt = &now
+	s.mu.Unlock()
+
+	if err := s.ack(result); err != nil {
+		return result, fmt.Errorf("上报同步版本失败: %w", err)
+	}
+	return result, nil
+}
+
+// fetch 拉取同步包并校验签名，中心返回304时返回nil
+func (s *SyncService) fetch(version int64) (*SyncBundle, error) {
+	query := url.Values{
+		"station": {s.station},
+		"version": {strconv.FormatInt(version, 10)},
+	}
+	req, err := http.NewRequest(http.MethodGet, s.endpoint("/api/sync/bundle")+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建同步请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.config.Token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("拉取同步包失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("拉取同步包失败: 中心服务器返回 HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, syncMaxBundleSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取同步包失败: %w", err)
+	}
+	if len(body) > syncMaxBundleSize {
+		return nil, fmt.Errorf("同步包超过 %d 字节", syncMaxBundleSize)
+	}
+	if !s.verify(body, resp.Header.Get(SyncSignatureHeader)) {
+		return nil, fmt.Errorf("同步包签名校验失败")
+	}
+
+	var bundle SyncBundle
+	if err := json.Unmarshal(body, &bundle); err != nil {
+		return nil, fmt.Errorf("解析同步包失败: %w", err)
+	}
+	return &bundle, nil
+}
+
+// verify 校验同步包签名
+func (s *SyncService) verify(body []byte, signature string) bool {
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(s.config.Token))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ack 向中心服务器上报已应用的版本
+func (s *SyncService) ack(result *SyncResult) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"station": s.station,
+		"version": result.Version,
+		"result":  result,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint("/api/sync/ack"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.config.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("中心服务器返回 HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// endpoint 中心服务器接口地址
+func (s *SyncService) endpoint(path string) string {
+	return strings.TrimRight(s.config.URL, "/") + path
+}
+
+// apply 逐类应用同步包中的条目并移除已不在包中的条目，单个条目失败不影响其余条目
+func (s *SyncService) apply(bundle *SyncBundle) *SyncResult {
+	result := &SyncResult{Version: bundle.Version}
+	sections := map[string][]json.RawMessage{
+		models.SyncKindDevice: bundle.Devices,
+		models.SyncKindRule:   bundle.Rules,
+		models.SyncKindConfig: bundle.Config,
+	}
+	for _, target := range s.targets {
+		seen := make(map[string]bool)
+		for _, raw := range sections[target.kind] {
+			key, err := s.syncItem(target, raw, bundle.Version, seen, result)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s %s: %v", target.kind, key, err))
+			}
+		}
+		if err := s.removeMissing(target, seen, result); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", target.kind, err))
+		}
+	}
+	return result
+}
+
+// syncItem 比较并应用单个条目，返回条目的键
+func (s *SyncService) syncItem(target *syncTarget, raw json.RawMessage, version int64, seen map[string]bool, result *SyncResult) (string, error) {
+	key, central, err := target.canonical(raw)
+	if err != nil {
+		return key, err
+	}
+	if seen[key] {
+		return key, fmt.Errorf("同步包中重复出现")
+	}
+	seen[key] = true
+	centralHash := syncDigest(central)
+
+	var item models.SyncItem
+	err = s.db.Where("kind = ? AND key = ?", target.kind, key).First(&item).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return key, fmt.Errorf("查询同步记录失败: %w", err)
+	}
+	tracked := err == nil
+
+	local, err := target.local(key)
+	if err != nil {
+		return key, err
+	}
+	localHash := ""
+	if local != nil {
+		localHash = syncDigest(local.content)
+	}
+
+	switch {
+	case local != nil && localHash == centralHash:
+		result.Unchanged++
+		if !tracked || item.Hash != centralHash {
+			return key, s.track(target.kind, key, centralHash, version)
+		}
+		return key, nil
+	case tracked && item.Hash == centralHash:
+		// 中心内容未变化，本地修改过或已删除时保留本地
+		result.Kept++
+		return key, nil
+	}
+
+	// 本地内容与最近一次应用的中心内容不同即为本地修改过；尚未由同步管理的条目以创建后是否修改过判断，
+	// 各工位初始化时写入的默认内容不视为本地修改
+	if local != nil && ((tracked && localHash != item.Hash) || (!tracked && local.edited)) {
+		switch s.config.Sync.ConflictPolicy {
+		case SyncPolicyLocal:
+			result.Kept++
+			s.logger.WithField("kind", target.kind).WithField("key", key).Info("本地修改过，按策略保留本地内容")
+			return key, s.track(target.kind, key, centralHash, version)
+		case SyncPolicyReview:
+			result.Conflicts++
+			return key, s.recordConflict(target.kind, key, version, raw, local.content)
+		}
+		s.logger.WithField("kind", target.kind).WithField("key", key).Warn("本地修改过，按策略以中心内容覆盖")
+	}
+
+	if err := target.apply(raw); err != nil {
+		return key, err
+	}
+	result.Applied++
+	return key, s.track(target.kind, key, centralHash, version)
+}
+
+// removeMissing 移除上一版本由同步管理、本版本已不在同步包中的条目，本地修改过的保留
+func (s *SyncService) removeMissing(target *syncTarget, seen map[string]bool, result *SyncResult) error {
+	var items []*models.SyncItem
+	if err := s.db.Where("kind = ?", target.kind).Find(&items).Error; err != nil {
+		return fmt.Errorf("查询同步记录失败: %w", err)
+	}
+	for _, item := range items {
+		if seen[item.Key] {
+			continue
+		}
+		local, err := target.local(item.Key)
+		if err != nil {
+			return err
+		}
+		if local != nil && syncDigest(local.content) == item.Hash && target.remove != nil {
+			if err := target.remove(item.Key); err != nil {
+				return fmt.Errorf("移除 %s 失败: %w", item.Key, err)
+			}
+			result.Removed++
+		} else if local != nil {
+			result.Kept++
+		}
+		if err := s.db.Delete(item).Error; err != nil {
+			return fmt.Errorf("删除同步记录失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// track 记录条目最近一次应用的中心内容摘要
+func (s *SyncService) track(kind, key, hash string, version int64) error {
+	var item models.SyncItem
+	err := s.db.Where("kind = ? AND key = ?", kind, key).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		item = models.SyncItem{Kind: kind, Key: key}
+	} else if err != nil {
+		return fmt.Errorf("查询同步记录失败: %w", err)
+	}
+	item.Hash = hash
+	item.Version = version
+	if err := s.db.Save(&item).Error; err != nil {
+		return fmt.Errorf("保存同步记录失败: %w", err)
+	}
+	return nil
+}
+
+// recordConflict 登记冲突，同一条目已有待处理的冲突时更新为最新的中心内容
+func (s *SyncService) recordConflict(kind, key string, version int64, central json.RawMessage, local []byte) error {
+	var conflict models.SyncConflict
+	err := s.db.Where("kind = ? AND key = ? AND status = ?", kind, key, models.SyncConflictPending).First(&conflict).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询同步冲突失败: %w", err)
+	}
+	isNew := err != nil
+	if isNew {
+		conflict = models.SyncConflict{Kind: kind, Key: key, Status: models.SyncConflictPending}
+	}
+	conflict.Version = version
+	conflict.Central = string(central)
+	conflict.Local = string(local)
+	if err := s.db.Save(&conflict).Error; err != nil {
+		return fmt.Errorf("保存同步冲突失败: %w", err)
+	}
+
+	if isNew {
+		s.writeLog("warning", "sync:conflict", fmt.Sprintf("同步冲突: %s %s 本地修改过，等待人工处理", kind, key),
+			map[string]interface{}{"kind": kind, "key": key, "version": version, "conflict_id": conflict.ID})
+	}
+	return nil
+}
+
+// GetConflicts 获取同步冲突，status 为空表示全部
+func (s *SyncService) GetConflicts(status string) ([]*models.SyncConflict, error) {
+	var conflicts []*models.SyncConflict
+	query := s.db.Order("id DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&conflicts).Error; err != nil {
+		return nil, fmt.Errorf("查询同步冲突失败: %w", err)
+	}
+	return conflicts, nil
+}
+
+// ResolveConflict 处理同步冲突：central 应用中心内容，local 保留本地内容直到中心内容再次变化
+func (s *SyncService) ResolveConflict(id uint, use, actor string) (*models.SyncConflict, error) {
+	if use != models.SyncConflictCentral && use != models.SyncConflictLocal {
+		return nil, ErrInvalidSyncResolution
+	}
+
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	var conflict models.SyncConflict
+	if err := s.db.First(&conflict, id).Error; err != nil {
+		return nil, err
+	}
+	if conflict.Status != models.SyncConflictPending {
+		return nil, ErrSyncConflictResolved
+	}
+
+	var target *syncTarget
+	for _, t := range s.targets {
+		if t.kind == conflict.Kind {
+			target = t
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("未知的同步条目类型: %s", conflict.Kind)
+	}
+	raw := json.RawMessage(conflict.Central)
+	_, central, err := target.canonical(raw)
+	if err != nil {
+		return nil, err
+	}
+	if use == models.SyncConflictCentral {
+		if err := target.apply(raw); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.track(conflict.Kind, conflict.Key, syncDigest(central), conflict.Version); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	conflict.Status = use
+	conflict.ResolvedBy = actor
+	conflict.ResolvedAt = &now
+	if err := s.db.Save(&conflict).Error; err != nil {
+		return nil, fmt.Errorf("保存同步冲突失败: %w", err)
+	}
+	s.writeLog("info", "sync:resolve", fmt.Sprintf("同步冲突 %s %s 已处理: %s", conflict.Kind, conflict.Key, use),
+		map[string]interface{}{"conflict_id": conflict.ID, "use": use, "actor": actor})
+	s.refreshPending()
+	return &conflict, nil
+}
+
+// refreshPending 刷新待处理的冲突数
+func (s *SyncService) refreshPending() {
+	var pending int64
+	if err := s.db.Model(&models.SyncConflict{}).Where("status = ?", models.SyncConflictPending).Count(&pending).Error; err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.status.PendingConflicts = pending
+	s.mu.Unlock()
+}
+
+// audit 将同步结果写入审计日志，没有变化时不写
+func (s *SyncService) audit(result *SyncResult) {
+	if result.Applied == 0 && result.Removed == 0 && result.Conflicts == 0 && len(result.Errors) == 0 {
+		return
+	}
+	level := "info"
+	if len(result.Errors) > 0 {
+		level = "warning"
+	}
+	s.writeLog(level, "sync:apply", fmt.Sprintf("应用中心同步包版本 %d：更新 %d，移除 %d，冲突 %d，失败 %d",
+		result.Version, result.Applied, result.Removed, result.Conflicts, len(result.Errors)), result)
+}
+
+// writeLog 写入审计日志
+func (s *SyncService) writeLog(level, action, message string, extra interface{}) {
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:   level,
+		Message: message,
+		Module:  "sync",
+		Action:  action,
+		Extra:   string(data),
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入审计日志失败")
+	}
+}
+
+// deviceTarget 设备：新建或更新名称、类型、型号、描述及扫码参数预设，移除时停用（保留扫码记录）
+func (s *SyncService) deviceTarget() *syncTarget {
+	normalize := func(device SyncDevice) SyncDevice {
+		if device.Type == "" {
+			device.Type = "scanner"
+		}
+		return device
+	}
+	find := func(serialNo string) (*models.Device, error) {
+		var device models.Device
+		err := s.db.Where("serial_no = ?", serialNo).First(&device).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("查询设备失败: %w", err)
+		}
+		return &device, nil
+	}
+
+	return &syncTarget{
+		kind: models.SyncKindDevice,
+		canonical: func(raw json.RawMessage) (string, []byte, error) {
+			var device SyncDevice
+			if err := json.Unmarshal(raw, &device); err != nil {
+				return "", nil, fmt.Errorf("解析设备失败: %w", err)
+			}
+			if device.SerialNo == "" || device.Name == "" {
+				return device.SerialNo, nil, fmt.Errorf("设备序列号及名称不能为空")
+			}
+			content, err := json.Marshal(normalize(device))
+			return device.SerialNo, content, err
+		},
+		local: func(serialNo string) (*syncLocal, error) {
+			device, err := find(serialNo)
+			if device == nil || err != nil {
+				return nil, err
+			}
+			content, err := json.Marshal(SyncDevice{
+				SerialNo:    device.SerialNo,
+				Name:        device.Name,
+				Type:        device.Type,
+				Model:       device.Model,
+				Description: device.Description,
+				Preset:      device.Preset,
+			})
+			return &syncLocal{content: content, edited: !device.UpdatedAt.Equal(device.CreatedAt)}, err
+		},
+		apply: func(raw json.RawMessage) error {
+			var central SyncDevice
+			if err := json.Unmarshal(raw, &central); err != nil {
+				return fmt.Errorf("解析设备失败: %w", err)
+			}
+			central = normalize(central)
+			device, err := find(central.SerialNo)
+			if err != nil {
+				return err
+			}
+			if device == nil {
+				err := s.devices.CreateDevice(&models.Device{
+					Name:        central.Name,
+					Type:        central.Type,
+					Model:       central.Model,
+					SerialNo:    central.SerialNo,
+					Description: central.Description,
+					Preset:      central.Preset,
+				})
+				// 中心服务器的设备清单为准，本地已删除的同序列号设备恢复后按中心的内容更新
+				var deleted *DeletedConflictError
+				if !errors.As(err, &deleted) {
+					return err
+				}
+				if device, err = s.devices.RestoreDevice(deleted.ID); err != nil {
+					return err
+				}
+			}
+			return s.devices.UpdateDevice(device.ID, map[string]interface{}{
+				"name":        central.Name,
+				"type":        central.Type,
+				"model":       central.Model,
+				"description": central.Description,
+				"preset":      central.Preset,
+			})
+		},
+		remove: func(serialNo string) error {
+			device, err := find(serialNo)
+			if device == nil || err != nil {
+				return err
+			}
+			return s.devices.DeactivateDevice(device.ID)
+		},
+	}
+}
+
+// ruleTarget 分类规则：经规则服务保存，保存后重新加载到条码处理器
+func (s *SyncService) ruleTarget() *syncTarget {
+	normalize := func(rule SyncRule) SyncRule {
+		if len(rule.Fields) == 0 {
+			rule.Fields = nil
+		}
+		return rule
+	}
+	find := func(name string) (*models.ClassificationRule, error) {
+		var rule models.ClassificationRule
+		err := s.db.Where("name = ?", name).First(&rule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("查询分类规则失败: %w", err)
+		}
+		return &rule, nil
+	}
+
+	return &syncTarget{
+		kind: models.SyncKindRule,
+		canonical: func(raw json.RawMessage) (string, []byte, error) {
+			var rule SyncRule
+			if err := json.Unmarshal(raw, &rule); err != nil {
+				return "", nil, fmt.Errorf("解析分类规则失败: %w", err)
+			}
+			if rule.Name == "" {
+				return "", nil, fmt.Errorf("分类规则名称不能为空")
+			}
+			content, err := json.Marshal(normalize(rule))
+			return rule.Name, content, err
+		},
+		local: func(name string) (*syncLocal, error) {
+			rule, err := find(name)
+			if rule == nil || err != nil {
+				return nil, err
+			}
+			content, err := json.Marshal(normalize(SyncRule{
+				Name:     rule.Name,
+				Pattern:  rule.Pattern,
+				Type:     rule.Type,
+				Fields:   rule.Fields,
+				Priority: rule.Priority,
+				Enabled:  rule.Enabled,
+			}))
+			return &syncLocal{content: content, edited: !rule.UpdatedAt.Equal(rule.CreatedAt)}, err
+		},
+		apply: func(raw json.RawMessage) error {
+			var central SyncRule
+			if err := json.Unmarshal(raw, &central); err != nil {
+				return fmt.Errorf("解析分类规则失败: %w", err)
+			}
+			rule := &models.ClassificationRule{
+				Name:     central.Name,
+				Pattern:  central.Pattern,
+				Type:     central.Type,
+				Fields:   central.Fields,
+				Priority: central.Priority,
+				Enabled:  central.Enabled,
+			}
+			existing, err := find(central.Name)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				return s.rules.CreateRule(rule)
+			}
+			_, err = s.rules.UpdateRule(existing.ID, rule)
+			return err
+		},
+		remove: func(name string) error {
+			rule, err := find(name)
+			if rule == nil || err != nil {
+				return err
+			}
+			return s.rules.DeleteRule(rule.ID)
+		},
+	}
+}
+
+// configTarget 系统配置：以管理员身份经配置服务保存，校验、审计及变更通知与手动修改相同；
+// 从同步包中移除的配置项保留本地当前值
+func (s *SyncService) configTarget() *syncTarget {
+	type digest struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+
+	return &syncTarget{
+		kind: models.SyncKindConfig,
+		canonical: func(raw json.RawMessage) (string, []byte, error) {
+			var value SyncConfigValue
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return "", nil, fmt.Errorf("解析系统配置失败: %w", err)
+			}
+			if value.Key == "" {
+				return "", nil, fmt.Errorf("配置键不能为空")
+			}
+			content, err := json.Marshal(digest{Key: value.Key, Value: value.Value})
+			return value.Key, content, err
+		},
+		local: func(key string) (*syncLocal, error) {
+			current, err := s.configs.GetConfiguration(key)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("查询配置失败: %w", err)
+			}
+			content, err := json.Marshal(digest{Key: current.Key, Value: current.Value})
+			return &syncLocal{content: content, edited: !current.UpdatedAt.Equal(current.CreatedAt)}, err
+		},
+		apply: func(raw json.RawMessage) error {
+			var value SyncConfigValue
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("解析系统配置失败: %w", err)
+			}
+			_, err := s.configs.SetConfigurationAs(ConfigActor{Admin: true, Name: syncActor, Restore: true}, value.Key, value.Value, value.Category, value.Description)
+			return err
+		},
+	}
+}
+
+// syncDigest 规范化内容的摘要
+func syncDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
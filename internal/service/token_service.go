@@ -0,0 +1,166 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/models"
+)
+
+// tokenPrefix 令牌明文前缀，便于识别
+const tokenPrefix = "sct_"
+
+// tokenLastUsedInterval 最后使用时间的更新间隔，同一令牌在间隔内的请求不再写入
+const tokenLastUsedInterval = time.Minute
+
+// CreateTokenRequest 创建令牌参数
+type CreateTokenRequest struct {
+	Name      string        `json:"name"`
+	Scopes    []string      `json:"scopes"`
+	Devices   []uint        `json:"devices"`
+	ExpiresIn time.Duration `json:"-"`
+}
+
+// TokenService API令牌服务
+type TokenService struct {
+	db       *gorm.DB
+	logger   *logrus.Logger
+	readOnly bool // 只读模式下不更新最后使用时间
+}
+
+// NewTokenService 创建API令牌服务
+func NewTokenService(db *gorm.DB, logger *logrus.Logger) *TokenService {
+	return &TokenService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetReadOnly 只读模式下认证不写入数据库
+func (s *TokenService) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+}
+
+// CreateToken 创建令牌，明文仅在创建时返回一次
+func (s *TokenService) CreateToken(req CreateTokenRequest) (string, *models.APIToken, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return "", nil, fmt.Errorf("令牌名称不能为空")
+	}
+	if len(req.Scopes) == 0 {
+		return "", nil, fmt.Errorf("至少需要一个权限范围")
+	}
+	for _, scope := range req.Scopes {
+		switch scope {
+		case models.TokenScopeRead, models.TokenScopeWSSubscribe, models.TokenScopePeerForward, models.TokenScopeIngest, models.TokenScopePreferences:
+		default:
+			return "", nil, fmt.Errorf("不支持的权限范围: %s", scope)
+		}
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("生成令牌失败: %w", err)
+	}
+	plaintext := tokenPrefix + hex.EncodeToString(raw)
+
+	devices := make([]string, 0, len(req.Devices))
+	for _, id := range req.Devices {
+		devices = append(devices, strconv.FormatUint(uint64(id), 10))
+	}
+
+	token := &models.APIToken{
+		Name:      req.Name,
+		TokenHash: hashToken(plaintext),
+		Prefix:    plaintext[:len(tokenPrefix)+6],
+		Scopes:    strings.Join(req.Scopes, ","),
+		Devices:   strings.Join(devices, ","),
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().Add(req.ExpiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(token).Error; err != nil {
+		s.logger.WithError(err).Error("创建令牌失败")
+		return "", nil, fmt.Errorf("创建令牌失败: %w", err)
+	}
+
+	s.logger.WithField("token_id", token.ID).WithField("name", token.Name).Info("令牌创建成功")
+	return plaintext, token, nil
+}
+
+// GetTokens 获取令牌列表
+func (s *TokenService) GetTokens() ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+	if err := s.db.Order("created_at DESC, id DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeToken 吊销令牌
+func (s *TokenService) RevokeToken(id uint) error {
+	now := time.Now()
+	result := s.db.Model(&models.APIToken{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", now)
+	if result.Error != nil {
+		return fmt.Errorf("吊销令牌失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("令牌不存在或已吊销")
+	}
+
+	s.logger.WithField("token_id", id).Info("令牌已吊销")
+	return nil
+}
+
+// Authenticate 校验令牌明文，返回有效的令牌
+func (s *TokenService) Authenticate(plaintext string) (*models.APIToken, error) {
+	if !strings.HasPrefix(plaintext, tokenPrefix) {
+		return nil, fmt.Errorf("令牌格式无效")
+	}
+
+	var token models.APIToken
+	if err := s.db.Where("token_hash = ?", hashToken(plaintext)).First(&token).Error; err != nil {
+		return nil, fmt.Errorf("令牌无效")
+	}
+	if token.RevokedAt != nil {
+		return nil, fmt.Errorf("令牌已吊销")
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, fmt.Errorf("令牌已过期")
+	}
+
+	s.touch(&token, now)
+	return &token, nil
+}
+
+// touch 更新令牌的最后使用时间，距上次更新不足 tokenLastUsedInterval 时跳过；写入失败不影响认证
+func (s *TokenService) touch(token *models.APIToken, now time.Time) {
+	if s.readOnly {
+		return
+	}
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < tokenLastUsedInterval {
+		return
+	}
+	if err := s.db.Model(token).UpdateColumn("last_used_at", now).Error; err != nil {
+		s.logger.WithError(err).WithField("token_id", token.ID).Warn("更新令牌最后使用时间失败")
+		return
+	}
+	token.LastUsedAt = &now
+}
+
+// hashToken 计算令牌哈希，数据库中只保存哈希
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
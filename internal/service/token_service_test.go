@@ -0,0 +1,74 @@
+package service
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/database"
+	"userclient/internal/models"
+)
+
+// lastUsed 读取令牌在数据库中的最后使用时间
+func lastUsed(t *testing.T, db *database.DB, id uint) *time.Time {
+	t.Helper()
+	var token models.APIToken
+	if err := db.First(&token, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return token.LastUsedAt
+}
+
+// TestTokenLastUsedThrottled 最后使用时间每个令牌每 tokenLastUsedInterval 至多写入一次，只读模式下不写入
+func TestTokenLastUsedThrottled(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "tokens.db"))
+	defer db.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	tokens := NewTokenService(db.DB, logger)
+	plaintext, token, err := tokens.CreateToken(CreateTokenRequest{Name: "看板", Scopes: []string{models.TokenScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokens.Authenticate(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	first := lastUsed(t, db, token.ID)
+	if first == nil {
+		t.Fatal("首次使用未记录最后使用时间")
+	}
+	if _, err := tokens.Authenticate(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if second := lastUsed(t, db, token.ID); !second.Equal(*first) {
+		t.Fatalf("间隔内再次使用时写入了最后使用时间: %v -> %v", first, second)
+	}
+
+	// 超过间隔后再次写入
+	stale := time.Now().Add(-2 * tokenLastUsedInterval)
+	if err := db.Model(&models.APIToken{}).Where("id = ?", token.ID).UpdateColumn("last_used_at", stale).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokens.Authenticate(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(t, db, token.ID); !got.After(stale.Add(tokenLastUsedInterval)) {
+		t.Fatalf("超过间隔后最后使用时间 = %v，应已更新", got)
+	}
+
+	// 只读模式下认证不写入
+	if err := db.Model(&models.APIToken{}).Where("id = ?", token.ID).UpdateColumn("last_used_at", stale).Error; err != nil {
+		t.Fatal(err)
+	}
+	tokens.SetReadOnly(true)
+	if _, err := tokens.Authenticate(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(t, db, token.ID); !got.Equal(stale) {
+		t.Fatalf("只读模式下最后使用时间 = %v，want 保持 %v", got, stale)
+	}
+}
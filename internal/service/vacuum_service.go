@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+)
+
+// 维护触发条件
+const (
+	VacuumTriggerQuietWindow = "quiet_window" // 处于安静时段
+	VacuumTriggerIdle        = "idle"         // 距上次扫码超过 idle_after
+)
+
+// vacuumLogAction 维护结果在系统日志中的操作名，重启后据此恢复上次执行时间
+const vacuumLogAction = "database:maintenance"
+
+// vacuumChunkPages incremental_vacuum 每条语句回收的页数，语句之间检查是否有扫码到达
+const vacuumChunkPages = 256
+
+// vacuumAbortWait 扫码到达时等待维护任务中止的最长时间，超时后照常写入
+const vacuumAbortWait = 5 * time.Second
+
+// VacuumResult 一次数据库维护的结果
+type VacuumResult struct {
+	Trigger    string    `json:"trigger"` // quiet_window, idle
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	SizeBefore int64     `json:"size_before"` // 数据库文件与WAL文件的字节数
+	SizeAfter  int64     `json:"size_after"`
+	AutoVacuum string    `json:"auto_vacuum"` // none, full, incremental
+	FreedPages int64     `json:"freed_pages"` // incremental_vacuum 回收的空闲页数
+	FullVacuum bool      `json:"full_vacuum"` // 执行了完整 VACUUM
+	Aborted    bool      `json:"aborted"`     // 扫码到达而中止
+	Error      string    `json:"error,omitempty"`
+}
+
+// VacuumStatus 数据库维护状态
+type VacuumStatus struct {
+	Enabled          bool          `json:"enabled"`
+	Reason           string        `json:"reason,omitempty"` // 未执行的原因，如不是 SQLite 数据库
+	Running          bool          `json:"running"`
+	NextRunAt        *time.Time    `json:"next_run_at,omitempty"` // 下次可执行的时间，配置了 idle_after 时空闲也可提前执行
+	LastFullVacuumAt *time.Time    `json:"last_full_vacuum_at,omitempty"`
+	LastResult       *VacuumResult `json:"last_result,omitempty"`
+}
+
+// VacuumService SQLite 空闲维护
+//
+// 保留期清理删除的数据只留下空闲页，文件不会变小。本服务在安静时段内或长时间无扫码时执行
+// PRAGMA optimize 与 incremental_vacuum，并按配置定期执行完整 VACUUM（同时将 auto_vacuum
+// 设为 INCREMENTAL，此后 incremental_vacuum 才能回收空间）。执行前后的文件大小写入系统日志。
+// 维护占用数据库写锁，扫码到达时中止当前语句并等待释放后再写入。
+type VacuumService struct {
+	db       *gorm.DB
+	config   config.DatabaseMaintenanceConfig
+	path     string
+	reason   string // 不执行维护的原因，为空表示支持
+	windows  []shiftWindow
+	location *time.Location
+	logger   *logrus.Logger
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	lastScan time.Time
+	lastRun  *time.Time
+	lastFull *time.Time
+	last     *VacuumResult
+	cancel   context.CancelFunc
+	running  chan struct{} // 维护执行中，结束时关闭
+}
+
+// NewVacuumService 创建数据库维护服务，非 SQLite 数据库时不执行任何操作
+func NewVacuumService(db *gorm.DB, dbCfg config.DatabaseConfig, loc *time.Location, logger *logrus.Logger) (*VacuumService, error) {
+	windows, err := parseShifts(dbCfg.Maintenance.QuietWindows)
+	if err != nil {
+		return nil, fmt.Errorf("安静时段配置无效: %w", err)
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	s := &VacuumService{
+		db:       db,
+		config:   dbCfg.Maintenance,
+		path:     sqliteFilePath(dbCfg.DSN),
+		windows:  windows,
+		location: loc,
+		logger:   logger,
+		done:     make(chan struct{}),
+		lastScan: time.Now(),
+	}
+	if dbCfg.Type != "sqlite" {
+		s.reason = "仅支持 SQLite 数据库"
+		return s, nil
+	}
+
+	var last models.BarcodeRecord
+	if err := db.Select("created_at").Order("created_at DESC").First(&last).Error; err == nil && !last.CreatedAt.IsZero() {
+		s.lastScan = last.CreatedAt
+	}
+	s.restore()
+	return s, nil
+}
+
+// restore 从系统日志恢复上次维护的结果
+func (s *VacuumService) restore() {
+	var logs []*models.SystemLog
+	if err := s.db.Where("action = ?", vacuumLogAction).Order("id DESC").Limit(1).Find(&logs).Error; err == nil && len(logs) > 0 {
+		var result VacuumResult
+		if json.Unmarshal([]byte(logs[0].Extra), &result) == nil {
+			s.last = &result
+			s.lastRun = &result.StartedAt
+		}
+	}
+
+	logs = nil
+	err := s.db.Where("action = ? AND json_extract(extra, '$.full_vacuum') = 1 AND json_extract(extra, '$.aborted') = 0", vacuumLogAction).
+		Order("id DESC").Limit(1).Find(&logs).Error
+	if err == nil && len(logs) > 0 {
+		var result VacuumResult
+		if json.Unmarshal([]byte(logs[0].Extra), &result) == nil {
+			s.lastFull = &result.StartedAt
+		}
+	}
+}
+
+// Observe 扫码到达：记录时间，维护执行中则中止并等待数据库写锁释放
+func (s *VacuumService) Observe() {
+	s.mu.Lock()
+	s.lastScan = time.Now()
+	cancel, running := s.cancel, s.running
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	select {
+	case <-running:
+	case <-time.After(vacuumAbortWait):
+		s.logger.Warn("等待数据库维护中止超时")
+	}
+}
+
+// GetStatus 获取维护状态
+func (s *VacuumService) GetStatus() VacuumStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := VacuumStatus{
+		Enabled:          s.reason == "",
+		Reason:           s.reason,
+		Running:          s.running != nil,
+		LastFullVacuumAt: s.lastFull,
+		LastResult:       s.last,
+	}
+	if status.Enabled {
+		next := s.nextRun(time.Now())
+		status.NextRunAt = &next
+	}
+	return status
+}
+
+// Start 启动定时检查，不支持时不启动
+func (s *VacuumService) Start() {
+	if s.reason != "" {
+		s.logger.WithField("reason", s.reason).Info("数据库空闲维护不执行")
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if trigger := s.eligible(time.Now()); trigger != "" {
+					s.Run(trigger)
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止定时检查，中止执行中的维护
+func (s *VacuumService) Close() {
+	close(s.done)
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Run 执行一次维护并记录结果
+func (s *VacuumService) Run(trigger string) *VacuumResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.mu.Lock()
+	if s.running != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	running := make(chan struct{})
+	s.cancel, s.running = cancel, running
+	fullDue := s.config.FullVacuum && (s.lastFull == nil || time.Since(*s.lastFull) >= s.config.FullVacuumInterval)
+	s.mu.Unlock()
+
+	result := &VacuumResult{
+		Trigger:    trigger,
+		StartedAt:  time.Now(),
+		SizeBefore: s.fileSize(),
+	}
+	err := s.maintain(ctx, result, fullDue)
+	result.FinishedAt = time.Now()
+	result.SizeAfter = s.fileSize()
+	if err != nil && ctx.Err() != nil {
+		result.Aborted = true
+	} else if err != nil {
+		result.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.cancel, s.running = nil, nil
+	s.last = result
+	s.lastRun = &result.StartedAt
+	if result.FullVacuum && !result.Aborted && result.Error == "" {
+		s.lastFull = &result.StartedAt
+	}
+	s.mu.Unlock()
+	close(running)
+
+	s.record(result)
+	return result
+}
+
+// maintain 在独立连接上依次执行 optimize、incremental_vacuum、可选的完整 VACUUM 及 WAL 截断
+//
+// auto_vacuum 须与 VACUUM 在同一连接上设置才会写入文件头，因此不使用连接池。
+func (s *VacuumService) maintain(ctx context.Context, result *VacuumResult, full bool) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return fmt.Errorf("获取数据库实例失败: %w", err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA optimize"); err != nil {
+		return fmt.Errorf("执行 PRAGMA optimize 失败: %w", err)
+	}
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return fmt.Errorf("读取 auto_vacuum 失败: %w", err)
+	}
+	result.AutoVacuum = autoVacuumNames[mode]
+
+	if mode == 2 {
+		if err := s.incrementalVacuum(ctx, conn, result); err != nil {
+			return err
+		}
+	}
+
+	if full {
+		if mode != 2 {
+			if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+				return fmt.Errorf("设置 auto_vacuum 失败: %w", err)
+			}
+		}
+		result.FullVacuum = true
+		if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+			return fmt.Errorf("执行 VACUUM 失败: %w", err)
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("截断WAL文件失败: %w", err)
+	}
+	return nil
+}
+
+// incrementalVacuum 分批回收空闲页，批次之间检查是否已中止
+func (s *VacuumService) incrementalVacuum(ctx context.Context, conn *sql.Conn, result *VacuumResult) error {
+	budget := int64(s.config.IncrementalPages)
+	for budget == 0 || result.FreedPages < budget {
+		var free int64
+		if err := conn.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&free); err != nil {
+			return fmt.Errorf("读取空闲页数失败: %w", err)
+		}
+		if free == 0 {
+			return nil
+		}
+
+		pages := int64(vacuumChunkPages)
+		if budget > 0 && budget-result.FreedPages < pages {
+			pages = budget - result.FreedPages
+		}
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages)); err != nil {
+			return fmt.Errorf("执行 incremental_vacuum 失败: %w", err)
+		}
+
+		var remaining int64
+		if err := conn.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&remaining); err != nil {
+			return fmt.Errorf("读取空闲页数失败: %w", err)
+		}
+		if remaining >= free {
+			return nil
+		}
+		result.FreedPages += free - remaining
+	}
+	return nil
+}
+
+// eligible 判断当前是否应执行维护，返回触发条件，不满足时返回空
+func (s *VacuumService) eligible(now time.Time) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running != nil || (s.lastRun != nil && now.Sub(*s.lastRun) < s.config.Interval) {
+		return ""
+	}
+	if s.inWindow(now) {
+		return VacuumTriggerQuietWindow
+	}
+	if s.config.IdleAfter > 0 && now.Sub(s.lastScan) >= s.config.IdleAfter {
+		return VacuumTriggerIdle
+	}
+	return ""
+}
+
+// nextRun 下次可执行的时间：间隔到期后的第一个安静时段，未配置安静时段时为空闲条件满足的时间
+func (s *VacuumService) nextRun(now time.Time) time.Time {
+	due := now
+	if s.lastRun != nil && s.lastRun.Add(s.config.Interval).After(due) {
+		due = s.lastRun.Add(s.config.Interval)
+	}
+	if len(s.windows) == 0 {
+		if idle := s.lastScan.Add(s.config.IdleAfter); idle.After(due) {
+			return idle
+		}
+		return due
+	}
+	if s.inWindow(due) {
+		return due
+	}
+
+	// 逐分钟查找下一个安静时段的开始，最多查找两天
+	local := due.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	for i := 0; i < 2*24*60; i++ {
+		if s.inWindow(local) {
+			return local
+		}
+		local = local.Add(time.Minute)
+	}
+	return due
+}
+
+// inWindow 是否处于安静时段
+func (s *VacuumService) inWindow(at time.Time) bool {
+	local := at.In(s.location)
+	minute := local.Hour()*60 + local.Minute()
+	for _, window := range s.windows {
+		if window.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSize 数据库文件与WAL文件的字节数
+func (s *VacuumService) fileSize() int64 {
+	var size int64
+	for _, path := range []string{s.path, s.path + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// record 将维护结果写入系统日志
+func (s *VacuumService) record(result *VacuumResult) {
+	level, message := "info", "数据库维护完成"
+	switch {
+	case result.Aborted:
+		message = "数据库维护因扫码到达中止"
+	case result.Error != "":
+		level, message = "error", "数据库维护失败"
+	}
+	message = fmt.Sprintf("%s：%d → %d 字节", message, result.SizeBefore, result.SizeAfter)
+
+	entry := s.logger.WithField("trigger", result.Trigger).
+		WithField("size_before", result.SizeBefore).
+		WithField("size_after", result.SizeAfter).
+		WithField("freed_pages", result.FreedPages).
+		WithField("full_vacuum", result.FullVacuum)
+	if result.Error != "" {
+		entry.WithField("error", result.Error).Warn(message)
+	} else {
+		entry.Info(message)
+	}
+
+	extra, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	log := &models.SystemLog{
+		Level:   level,
+		Message: message,
+		Module:  "database",
+		Action:  vacuumLogAction,
+		Extra:   string(extra),
+	}
+	if err := s.db.Create(log).Error; err != nil {
+		s.logger.WithError(err).Warn("写入系统日志失败")
+	}
+}
+
+// autoVacuumNames PRAGMA auto_vacuum 的取值
+var autoVacuumNames = map[int]string{0: "none", 1: "full", 2: "incremental"}
+
+// sqliteFilePath 从DSN中取出数据库文件路径
+func sqliteFilePath(dsn string) string {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
@@ -0,0 +1,348 @@
+package sink
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	"userclient/pkg/payload"
+)
+
+// fileSinkPrefix NDJSON文件名前缀
+const fileSinkPrefix = "events-"
+
+// FileSinkStatus 文件输出状态
+type FileSinkStatus struct {
+	Enabled      bool   `json:"enabled"`
+	CurrentFile  string `json:"current_file"`
+	BytesWritten int64  `json:"bytes_written"`
+	EventsTotal  int64  `json:"events_total"`
+	Dropped      int64  `json:"dropped"`
+	Paused       bool   `json:"paused"`
+	LastError    string `json:"last_error,omitempty"`
+}
+
+// fileSinkEvent 待写入的事件
+type fileSinkEvent struct {
+	message interface{}
+	data    []byte // 默认格式的编码
+}
+
+// FileSink 将事件以NDJSON格式追加写入本地文件，按小时/天轮转
+type FileSink struct {
+	config  config.FileSinkConfig
+	logger  *logrus.Logger
+	format  payload.Format
+	types   map[string]bool
+	events  chan fileSinkEvent
+	done    chan struct{}
+	wg      sync.WaitGroup
+	onAlert func(error)
+
+	mu          sync.RWMutex
+	file        *os.File
+	writer      *bufio.Writer
+	currentPath string
+	paused      bool
+	pausedUntil time.Time
+	lastError   string
+
+	bytesWritten int64
+	eventsTotal  int64
+	dropped      int64
+}
+
+// NewFileSink 创建文件输出
+func NewFileSink(cfg config.FileSinkConfig, logger *logrus.Logger) (*FileSink, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("文件输出目录不能为空")
+	}
+	if cfg.Rotation != "hourly" && cfg.Rotation != "daily" {
+		return nil, fmt.Errorf("不支持的轮转周期: %s", cfg.Rotation)
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 2 * time.Second
+	}
+	format, err := payload.ParseFormat(cfg.Format.TimestampFormat, cfg.Format.FieldNaming, cfg.Format.Flatten)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建文件输出目录失败: %w", err)
+	}
+
+	types := make(map[string]bool, len(cfg.EventTypes))
+	for _, t := range cfg.EventTypes {
+		types[t] = true
+	}
+
+	return &FileSink{
+		config: cfg,
+		logger: logger,
+		format: format,
+		types:  types,
+		events: make(chan fileSinkEvent, 1024),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// OnAlert 注册告警回调（如磁盘已满）
+func (s *FileSink) OnAlert(listener func(error)) {
+	s.onAlert = listener
+}
+
+// Start 启动写入协程
+func (s *FileSink) Start() {
+	s.wg.Add(1)
+	go s.run()
+	s.logger.WithField("dir", s.config.Dir).WithField("rotation", s.config.Rotation).Info("文件事件输出已启动")
+}
+
+// Publish 提交事件，data为message的默认格式编码，队列已满或输出暂停时丢弃
+func (s *FileSink) Publish(msgType string, message interface{}, data []byte) {
+	if len(s.types) > 0 && !s.types[msgType] {
+		return
+	}
+
+	select {
+	case s.events <- fileSinkEvent{message: message, data: data}:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// Close 写完剩余事件并关闭文件
+func (s *FileSink) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeFile()
+}
+
+// GetStatus 获取输出状态
+func (s *FileSink) GetStatus() FileSinkStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return FileSinkStatus{
+		Enabled:      true,
+		CurrentFile:  s.currentPath,
+		BytesWritten: atomic.LoadInt64(&s.bytesWritten),
+		EventsTotal:  atomic.LoadInt64(&s.eventsTotal),
+		Dropped:      atomic.LoadInt64(&s.dropped),
+		Paused:       s.paused,
+		LastError:    s.lastError,
+	}
+}
+
+// run 写入循环
+func (s *FileSink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-s.events:
+			s.write(event)
+
+		case <-ticker.C:
+			s.flush()
+
+		case <-s.done:
+			// 关闭前写完队列中的事件
+			for {
+				select {
+				case event := <-s.events:
+					s.write(event)
+				default:
+					s.flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// write 写入单个事件，非默认格式时在写入协程中重新编码
+func (s *FileSink) write(event fileSinkEvent) {
+	data := event.data
+	if !s.format.IsDefault() {
+		var err error
+		if data, err = payload.Marshal(s.format, event.message); err != nil {
+			s.logger.WithError(err).Warn("按输出格式序列化事件失败")
+			atomic.AddInt64(&s.dropped, 1)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writeLocked(data, false); err != nil {
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// Deliver 同步写入默认格式的事件并刷盘，供出站事件日志投递使用
+func (s *FileSink) Deliver(data []byte) error {
+	data, err := payload.Reformat(s.format, data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeLocked(data, true)
+}
+
+// writeLocked 写入单个事件，sync为true时立即刷盘，调用方需持有锁
+func (s *FileSink) writeLocked(data []byte, sync bool) error {
+	now := time.Now()
+	if s.paused {
+		if now.Before(s.pausedUntil) {
+			return fmt.Errorf("文件事件输出已暂停: %s", s.lastError)
+		}
+		s.paused = false
+		s.logger.Info("文件事件输出恢复写入")
+	}
+
+	if err := s.rotate(now); err != nil {
+		s.pause(err)
+		return err
+	}
+
+	n, err := s.writer.Write(data)
+	if err == nil {
+		err = s.writer.WriteByte('\n')
+		n++
+	}
+	if err == nil && sync {
+		err = s.writer.Flush()
+	}
+	atomic.AddInt64(&s.bytesWritten, int64(n))
+	if err != nil {
+		s.pause(err)
+		return err
+	}
+	atomic.AddInt64(&s.eventsTotal, 1)
+	return nil
+}
+
+// flush 将缓冲区写入磁盘
+func (s *FileSink) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer == nil || s.paused {
+		return
+	}
+	if err := s.writer.Flush(); err != nil {
+		s.pause(err)
+	}
+}
+
+// rotate 根据当前时间切换输出文件
+func (s *FileSink) rotate(now time.Time) error {
+	path := filepath.Join(s.config.Dir, fileSinkPrefix+s.periodName(now)+".ndjson")
+	if path == s.currentPath && s.file != nil {
+		return nil
+	}
+
+	if err := s.closeFile(); err != nil {
+		s.logger.WithError(err).Warn("关闭事件文件失败")
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开事件文件失败: %w", err)
+	}
+
+	s.file = file
+	s.writer = bufio.NewWriterSize(file, 64*1024)
+	s.currentPath = path
+	s.cleanup()
+	return nil
+}
+
+// periodName 轮转周期对应的文件名片段
+func (s *FileSink) periodName(t time.Time) string {
+	if s.config.Rotation == "hourly" {
+		return t.Format("2006010215")
+	}
+	return t.Format("20060102")
+}
+
+// cleanup 删除超过保留数量的旧文件
+func (s *FileSink) cleanup() {
+	if s.config.MaxFiles <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(s.config.Dir, fileSinkPrefix+"*.ndjson"))
+	if err != nil || len(matches) <= s.config.MaxFiles {
+		return
+	}
+
+	sort.Strings(matches)
+	for _, path := range matches[:len(matches)-s.config.MaxFiles] {
+		if path == s.currentPath {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			s.logger.WithError(err).WithField("path", path).Warn("删除过期事件文件失败")
+		}
+	}
+}
+
+// pause 写入失败（如磁盘已满）时暂停输出并告警，稍后重试
+func (s *FileSink) pause(err error) {
+	s.paused = true
+	s.pausedUntil = time.Now().Add(30 * time.Second)
+	s.lastError = err.Error()
+
+	// 丢弃未写出的缓冲，重试时重新打开文件
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = nil
+	s.writer = nil
+
+	msg := "文件事件输出写入失败，已暂停"
+	if strings.Contains(strings.ToLower(err.Error()), "no space") || strings.Contains(strings.ToLower(err.Error()), "disk is full") {
+		msg = "磁盘已满，文件事件输出已暂停"
+	}
+	s.logger.WithError(err).Error(msg)
+
+	if s.onAlert != nil {
+		go s.onAlert(fmt.Errorf("%s: %w", msg, err))
+	}
+}
+
+// closeFile 刷新并关闭当前文件
+func (s *FileSink) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	var err error
+	if s.writer != nil {
+		err = s.writer.Flush()
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file = nil
+	s.writer = nil
+	return err
+}
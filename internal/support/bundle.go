@@ -0,0 +1,258 @@
+package support
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"userclient/internal/config"
+	"userclient/internal/models"
+	"userclient/internal/service"
+)
+
+// 诊断包大小限制
+const (
+	maxLogBytes       = 2 << 20 // 日志最多保留末尾2MB
+	maxSystemLogs     = 100
+	maxBarcodeRecords = 100
+)
+
+// barcodeFieldPattern 日志中携带扫码内容的字段
+var barcodeFieldPattern = regexp.MustCompile(`\b(barcode|content|response)=("(?:[^"\\]|\\.)*"|\S+)`)
+
+// Options 诊断包选项
+type Options struct {
+	IncludeBarcodes bool        // 是否包含扫码内容，默认日志中的扫码内容被隐藏
+	Status          interface{} // /api/status 快照，为nil表示服务未运行
+	Logs            []string    // 最近日志，为空时读取日志文件
+	GeneratedBy     string
+	Logger          *logrus.Logger
+}
+
+// Manifest 诊断包说明
+type Manifest struct {
+	GeneratedAt     time.Time         `json:"generated_at"`
+	GeneratedBy     string            `json:"generated_by"`
+	Version         string            `json:"version"`
+	Station         string            `json:"station"`
+	IncludeBarcodes bool              `json:"include_barcodes"`
+	Files           []string          `json:"files"`
+	Truncated       []string          `json:"truncated,omitempty"`
+	Errors          map[string]string `json:"errors,omitempty"` // 生成失败的部分，不影响其余内容
+}
+
+// DatabaseInfo 数据库结构版本及各表行数
+type DatabaseInfo struct {
+	Type          string           `json:"type"`
+	DSN           string           `json:"dsn"`
+	SchemaVersion int              `json:"schema_version"`
+	Tables        map[string]int64 `json:"tables"`
+}
+
+// bundleWriter 逐个写入诊断包文件，单个部分失败时记录到清单中
+type bundleWriter struct {
+	zip      *zip.Writer
+	manifest *Manifest
+}
+
+// Write 生成诊断包zip：与默认值不同的配置（已脱敏）、最近日志、数据库版本及行数、
+// 状态快照、扫码统计及最近的系统日志
+func Write(w io.Writer, cfg *config.Config, db *gorm.DB, opts Options) error {
+	if opts.Logger == nil {
+		opts.Logger = logrus.StandardLogger()
+	}
+
+	bundle := &bundleWriter{
+		zip: zip.NewWriter(w),
+		manifest: &Manifest{
+			GeneratedAt:     time.Now(),
+			GeneratedBy:     opts.GeneratedBy,
+			Version:         cfg.App.Version,
+			Station:         cfg.Peers.Station,
+			IncludeBarcodes: opts.IncludeBarcodes,
+			Errors:          make(map[string]string),
+		},
+	}
+
+	bundle.json("config_diff.json", func() (interface{}, error) {
+		return config.Diff(), nil
+	})
+	bundle.json("database.json", func() (interface{}, error) {
+		return databaseInfo(cfg, db)
+	})
+	bundle.json("status.json", func() (interface{}, error) {
+		if opts.Status == nil {
+			return nil, fmt.Errorf("服务未运行，无法获取状态快照")
+		}
+		return opts.Status, nil
+	})
+	bundle.json("stats.json", func() (interface{}, error) {
+		loc, err := cfg.App.Location()
+		if err != nil {
+			return nil, err
+		}
+		return service.NewBarcodeService(db, opts.Logger).GetBarcodeStats(loc)
+	})
+	bundle.json("system_logs.json", func() (interface{}, error) {
+		return systemLogs(db, opts.IncludeBarcodes)
+	})
+	bundle.logs(cfg, opts)
+	if opts.IncludeBarcodes {
+		bundle.json("barcodes.json", func() (interface{}, error) {
+			var records []*models.BarcodeRecord
+			err := db.Order("id DESC").Limit(maxBarcodeRecords).Find(&records).Error
+			return records, err
+		})
+	}
+
+	if len(bundle.manifest.Errors) == 0 {
+		bundle.manifest.Errors = nil
+	}
+	files := bundle.manifest.Files
+	bundle.manifest.Files = append([]string{"manifest.json"}, files...)
+	if err := bundle.write("manifest.json", bundle.manifest); err != nil {
+		return err
+	}
+	return bundle.zip.Close()
+}
+
+// json 写入JSON文件，生成失败时记录错误并继续
+func (b *bundleWriter) json(name string, load func() (interface{}, error)) {
+	data, err := load()
+	if err != nil {
+		b.manifest.Errors[name] = err.Error()
+		return
+	}
+	if err := b.write(name, data); err != nil {
+		b.manifest.Errors[name] = err.Error()
+		return
+	}
+	b.manifest.Files = append(b.manifest.Files, name)
+}
+
+// write 写入单个JSON文件
+func (b *bundleWriter) write(name string, data interface{}) error {
+	f, err := b.zip.Create(name)
+	if err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// logs 写入最近日志，优先使用内存中的日志缓冲，超出大小时保留末尾
+func (b *bundleWriter) logs(cfg *config.Config, opts Options) {
+	const name = "logs.txt"
+
+	var text string
+	switch {
+	case len(opts.Logs) > 0:
+		text = strings.Join(opts.Logs, "")
+	case cfg.Log.Output == "file" && cfg.Log.FilePath != "":
+		data, truncated, err := readTail(cfg.Log.FilePath, maxLogBytes)
+		if err != nil {
+			b.manifest.Errors[name] = err.Error()
+			return
+		}
+		if truncated {
+			b.manifest.Truncated = append(b.manifest.Truncated, name)
+		}
+		text = string(data)
+	default:
+		b.manifest.Errors[name] = "没有可用的日志"
+		return
+	}
+
+	if len(text) > maxLogBytes {
+		text = text[len(text)-maxLogBytes:]
+		b.manifest.Truncated = append(b.manifest.Truncated, name)
+	}
+	if !opts.IncludeBarcodes {
+		text = barcodeFieldPattern.ReplaceAllString(text, "${1}="+config.RedactedValue)
+	}
+
+	f, err := b.zip.Create(name)
+	if err == nil {
+		_, err = io.WriteString(f, text)
+	}
+	if err != nil {
+		b.manifest.Errors[name] = err.Error()
+		return
+	}
+	b.manifest.Files = append(b.manifest.Files, name)
+}
+
+// databaseInfo 数据库结构版本及各表行数
+func databaseInfo(cfg *config.Config, db *gorm.DB) (*DatabaseInfo, error) {
+	info := &DatabaseInfo{
+		Type:   cfg.Database.Type,
+		DSN:    config.RedactDSN(cfg.Database.DSN),
+		Tables: make(map[string]int64),
+	}
+	if err := db.Raw("PRAGMA user_version").Scan(&info.SchemaVersion).Error; err != nil {
+		return nil, fmt.Errorf("读取数据库结构版本失败: %w", err)
+	}
+
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		return nil, fmt.Errorf("读取数据表失败: %w", err)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		var count int64
+		if err := db.Table(table).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("统计 %s 行数失败: %w", table, err)
+		}
+		info.Tables[table] = count
+	}
+	return info, nil
+}
+
+// systemLogs 最近的系统日志，不包含扫码内容时去掉附加数据
+func systemLogs(db *gorm.DB, includeBarcodes bool) ([]*models.SystemLog, error) {
+	var logs []*models.SystemLog
+	if err := db.Order("id DESC").Limit(maxSystemLogs).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("查询系统日志失败: %w", err)
+	}
+	if !includeBarcodes {
+		for _, log := range logs {
+			log.Extra = ""
+		}
+	}
+	return logs, nil
+}
+
+// readTail 读取文件末尾最多max字节
+func readTail(path string, max int64) ([]byte, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, fmt.Errorf("读取日志文件失败: %w", err)
+	}
+	truncated := info.Size() > max
+	if truncated {
+		if _, err := f.Seek(-max, io.SeekEnd); err != nil {
+			return nil, false, fmt.Errorf("读取日志文件失败: %w", err)
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(f, max))
+	if err != nil {
+		return nil, false, fmt.Errorf("读取日志文件失败: %w", err)
+	}
+	return data, truncated, nil
+}
@@ -0,0 +1,65 @@
+package support
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogBuffer 保留最近日志的 logrus 钩子，用于生成诊断包
+type LogBuffer struct {
+	formatter logrus.Formatter
+	size      int
+
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// NewLogBuffer 创建日志缓冲，最多保留size条
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = 1000
+	}
+	return &LogBuffer{
+		formatter: &logrus.TextFormatter{FullTimestamp: true, DisableColors: true},
+		size:      size,
+		lines:     make([]string, size),
+	}
+}
+
+// Levels 实现 logrus.Hook，记录所有级别
+func (b *LogBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire 实现 logrus.Hook
+func (b *LogBuffer) Fire(entry *logrus.Entry) error {
+	line, err := b.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines[b.next] = string(line)
+	b.next = (b.next + 1) % b.size
+	if b.next == 0 {
+		b.full = true
+	}
+	return nil
+}
+
+// Lines 按时间顺序返回缓冲中的日志
+func (b *LogBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+	lines := make([]string, 0, b.size)
+	lines = append(lines, b.lines[b.next:]...)
+	return append(lines, b.lines[:b.next]...)
+}
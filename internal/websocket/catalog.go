@@ -0,0 +1,221 @@
+package websocket
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"userclient/pkg/barcode"
+)
+
+// EventSchemaVersion 事件消息结构版本，字段发生不兼容变更时递增
+const EventSchemaVersion = "1.0"
+
+// CatalogPath 事件目录接口地址
+const CatalogPath = "/api/events/catalog"
+
+// EventDefinition 事件类型定义
+type EventDefinition struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Schema      interface{} `json:"schema"`
+	Example     interface{} `json:"example"`
+	Droppable   []string    `json:"droppable,omitempty"` // 消息超出客户端大小上限时首先省略的可选字段
+}
+
+// EventCatalog 服务端可能推送的事件类型目录
+type EventCatalog struct {
+	mu     sync.RWMutex
+	events map[string]EventDefinition
+}
+
+// CatalogDocument 事件目录文档
+type CatalogDocument struct {
+	SchemaVersion string            `json:"schema_version"`
+	Envelope      interface{}       `json:"envelope"`
+	Events        []EventDefinition `json:"events"`
+	CloseCodes    []CloseCode       `json:"close_codes"`
+}
+
+// NewEventCatalog 创建事件目录
+func NewEventCatalog() *EventCatalog {
+	return &EventCatalog{
+		events: make(map[string]EventDefinition),
+	}
+}
+
+// Register 登记事件类型，Schema根据示例载荷的Go结构体反射生成
+func (c *EventCatalog) Register(eventType, description string, example interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events[eventType] = EventDefinition{
+		Type:        eventType,
+		Description: description,
+		Schema:      SchemaOf(reflect.TypeOf(example)),
+		Example:     example,
+	}
+}
+
+// SetDroppable 设置事件的可选字段，消息超出客户端大小上限时首先省略
+func (c *EventCatalog) SetDroppable(eventType string, fields ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if def, ok := c.events[eventType]; ok {
+		def.Droppable = fields
+		c.events[eventType] = def
+	}
+}
+
+// Droppable 事件的可选字段
+func (c *EventCatalog) Droppable(eventType string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.events[eventType].Droppable
+}
+
+// Has 事件类型是否已登记
+func (c *EventCatalog) Has(eventType string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.events[eventType]
+	return ok
+}
+
+// Document 生成事件目录文档
+func (c *EventCatalog) Document() CatalogDocument {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	events := make([]EventDefinition, 0, len(c.events))
+	for _, def := range c.events {
+		events = append(events, def)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Type < events[j].Type })
+
+	return CatalogDocument{
+		SchemaVersion: EventSchemaVersion,
+		Envelope:      SchemaOf(reflect.TypeOf(Message{})),
+		Events:        events,
+		CloseCodes:    CloseCodes,
+	}
+}
+
+// SchemaOf 根据Go类型生成JSON Schema
+func SchemaOf(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := SchemaOf(t.Elem())
+		schema["nullable"] = true
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": SchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": SchemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := make([]string, 0)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, omitempty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			properties[name] = SchemaOf(field.Type)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		// interface{} 等任意类型
+		return map[string]interface{}{}
+	}
+}
+
+// jsonFieldName 解析字段的JSON名称
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	omitempty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+// newDefaultCatalog 创建包含Hub自身事件的目录
+func newDefaultCatalog() *EventCatalog {
+	catalog := NewEventCatalog()
+	catalog.Register("welcome", "连接建立后发送的欢迎消息，包含事件目录地址及续传结果", WelcomePayload{
+		Message:       "WebSocket连接成功，等待扫码数据...",
+		SchemaVersion: EventSchemaVersion,
+		Catalog:       CatalogPath,
+		LastSeq:       128,
+		Replayed:      3,
+	})
+	deviceID := uint(1)
+	catalog.Register("barcode", "扫码结果，message 按 messages 配置的模板生成，station、device_name 可用于按工位及设备过滤", barcode.BarcodeData{
+		Content:    "6901234567892",
+		Length:     13,
+		Type:       "EAN-13",
+		Timestamp:  time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+		Status:     "success",
+		Message:    "二号线 出口固定扫码枪：识别为EAN-13条码，正在验证...",
+		RecordID:   1,
+		PublicID:   "01HN0Z8Q8G8Y3W6V2K5T9R4M7C",
+		DeviceID:   &deviceID,
+		DeviceName: "出口固定扫码枪",
+		Station:    "二号线",
+	})
+	catalog.SetDroppable("barcode", "derived")
+	catalog.Register("barcode_increment", "连续相同扫码的计数，仅发送给以 compact=1 连接的客户端，代替与 ref_seq 相同的扫码结果", IncrementPayload{
+		RefSeq:   128,
+		Count:    3,
+		Content:  "6901234567892",
+		RecordID: 3,
+	})
+	catalog.Register("broadcast_error", "事件序列化失败未能广播，type 为丢失的事件类型，客户端应通过REST接口重新加载状态", BroadcastErrorEvent{
+		Type:  "barcode",
+		Error: "json: unsupported value: NaN",
+	})
+	return catalog
+}
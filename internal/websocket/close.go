@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Hub 关闭连接时使用的关闭码
+//
+// 客户端据此决定是否重连：
+//   - CloseShutdown：服务正常停止或重启，客户端应退避后重连并用 since 续传
+//   - CloseTooSlow：客户端接收过慢导致发送缓冲区溢出，可立即重连并续传
+//   - ClosePolicyViolation：请求不符合策略（如订阅无权访问的设备），修正前不应重连
+//   - CloseUnauthorized：认证失败或令牌已失效，不应重连
+//   - CloseMaintenance：服务因维护停止，不应自动重连
+//   - CloseKicked：被管理员断开，不应自动重连
+const (
+	CloseShutdown        = websocket.CloseGoingAway       // 1001
+	ClosePolicyViolation = websocket.ClosePolicyViolation // 1008
+	CloseUnauthorized    = 4001
+	CloseTooSlow         = 4002
+	CloseMaintenance     = 4003
+	CloseKicked          = 4004
+)
+
+// CloseCode 关闭码说明
+type CloseCode struct {
+	Code        int    `json:"code"`
+	Name        string `json:"name"`
+	Retry       bool   `json:"retry"` // 客户端是否应自动重连
+	Description string `json:"description"`
+}
+
+// CloseCodes Hub使用的关闭码列表，随事件目录一同发布
+var CloseCodes = []CloseCode{
+	{CloseShutdown, "shutdown", true, "服务停止或重启"},
+	{ClosePolicyViolation, "policy_violation", false, "请求不符合策略"},
+	{CloseUnauthorized, "unauthorized", false, "认证失败或令牌已失效"},
+	{CloseTooSlow, "too_slow", true, "客户端接收过慢，发送缓冲区已满"},
+	{CloseMaintenance, "maintenance", false, "服务因维护停止"},
+	{CloseKicked, "kicked", false, "被管理员断开"},
+}
+
+// closeFrame 待发送的关闭帧
+type closeFrame struct {
+	code   int
+	reason string
+}
+
+// closeWith 设置关闭码并关闭发送通道，writePump发送关闭帧后断开（调用方持有写锁）
+func (c *Client) closeWith(code int, reason string) {
+	c.closeFrame = &closeFrame{code: code, reason: reason}
+	close(c.send)
+}
+
+// IsUpgrade 是否为WebSocket升级请求
+func IsUpgrade(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
+
+// Reject 升级连接后立即以指定关闭码关闭，用于让浏览器客户端获知拒绝原因
+func (h *Hub) Reject(w http.ResponseWriter, r *http.Request, code int, reason string) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.logger.WithError(err).Error("WebSocket升级失败")
+		return
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(h.config.WriteWait)
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
+
+// CloseClient 以指定关闭码断开客户端
+func (h *Hub) CloseClient(id uint64, code int, reason string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		if client.id == id {
+			delete(h.clients, client)
+			client.closeWith(code, reason)
+			h.logger.WithField("client_id", id).WithField("code", code).Info("已断开客户端")
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package websocket
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"userclient/pkg/barcode"
+)
+
+// IncrementPayload 重复扫码计数，开启合并的客户端收到它而不是与上一条相同的扫码结果
+type IncrementPayload struct {
+	RefSeq   uint64 `json:"ref_seq"` // 本轮重复扫码中第一条扫码结果的序号
+	Count    int    `json:"count"`   // 含第一条在内的累计扫码次数
+	Content  string `json:"content"`
+	DeviceID *uint  `json:"device_id,omitempty"`
+	RecordID uint   `json:"record_id,omitempty"` // 本次扫码的记录ID
+}
+
+// compactor 连续相同扫码的合并状态，仅在Hub的广播协程中访问
+//
+// 内容（忽略首尾空白及大小写）、设备及状态均相同且与上一次间隔不超过窗口的扫码视为重复；
+// 其他扫码或窗口过期后重新开始计数。非扫码事件不影响合并状态。
+type compactor struct {
+	window time.Duration
+	key    string
+	refSeq uint64
+	count  int
+	lastAt time.Time
+}
+
+// newCompactor 创建合并状态
+func newCompactor(window time.Duration) *compactor {
+	if window <= 0 {
+		window = 2 * time.Second
+	}
+	return &compactor{window: window}
+}
+
+// observe 记录一条广播消息，与上一条扫码重复时返回替代它的计数消息
+func (c *compactor) observe(message *Message) *Message {
+	if message.Type != "barcode" {
+		return nil
+	}
+	data, ok := message.Data.(*barcode.BarcodeData)
+	if !ok || message.Origin != "" {
+		// 相邻工位转发的扫码不合并，但同样打断本工位的连续重复
+		c.count = 0
+		return nil
+	}
+
+	key := compactionKey(data)
+	if c.count > 0 && key == c.key && message.Time.Sub(c.lastAt) <= c.window {
+		c.count++
+		c.lastAt = message.Time
+		return &Message{
+			Type: "barcode_increment",
+			Seq:  message.Seq,
+			Time: message.Time,
+			Data: IncrementPayload{
+				RefSeq:   c.refSeq,
+				Count:    c.count,
+				Content:  data.Content,
+				DeviceID: data.DeviceID,
+				RecordID: data.RecordID,
+			},
+		}
+	}
+
+	c.key = key
+	c.refSeq = message.Seq
+	c.count = 1
+	c.lastAt = message.Time
+	return nil
+}
+
+// compactionKey 判断重复扫码的键
+func compactionKey(data *barcode.BarcodeData) string {
+	device := ""
+	if data.DeviceID != nil {
+		device = strconv.FormatUint(uint64(*data.DeviceID), 10)
+	}
+	return strings.ToUpper(strings.TrimSpace(data.Content)) + "\x00" + device + "\x00" + data.Status
+}
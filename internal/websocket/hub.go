@@ -2,8 +2,12 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -11,44 +15,134 @@ import (
 
 	"userclient/internal/config"
 	"userclient/pkg/barcode"
+	"userclient/pkg/payload"
 )
 
 // Client WebSocket客户端
 type Client struct {
-	conn   *websocket.Conn
-	send   chan []byte
-	hub    *Hub
-	logger *logrus.Logger
+	id             uint64
+	conn           *websocket.Conn
+	send           chan []byte
+	hub            *Hub
+	logger         *logrus.Logger
+	devices        map[uint]bool // 允许接收的设备，nil表示不限
+	format         payload.Format
+	connectedAt    time.Time
+	keepalive      keepalive
+	since          *uint64     // 续传起点，连接建立后补发序号大于此值的消息
+	closeFrame     *closeFrame // 关闭发送通道前设置的关闭码
+	compact        bool        // 连续相同扫码以计数消息代替
+	maxMessageSize int         // 单条消息的最大字节数，超出时精简后发送
+}
+
+// ClientOptions 客户端连接选项
+type ClientOptions struct {
+	Devices map[uint]bool  // 允许接收的设备，nil表示不限
+	Format  payload.Format // 客户端协商的载荷格式
+	Since   *uint64        // 断线重连时客户端收到的最后序号
+	Compact bool           // 连续相同扫码以 barcode_increment 计数消息代替，需同时开启功能开关 hub.compaction
+	// MaxMessageSize 客户端可接收的单条消息最大字节数，0 或大于 websocket.max_message_size 时使用配置值
+	MaxMessageSize int
+}
+
+// outboundMessage 待广播的消息
+type outboundMessage struct {
+	deviceID *uint
+	message  *Message
+	data     []byte     // 默认格式的编码
+	reduced  []*Message // 超出大小上限时按精简级别生成的消息
 }
 
 // Hub WebSocket连接管理中心
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan *outboundMessage
 	register   chan *Client
 	unregister chan *Client
 	config     *config.WebSocketConfig
 	logger     *logrus.Logger
 	mu         sync.RWMutex
 	upgrader   websocket.Upgrader
+	listeners  []func(msgType string, message *Message, data []byte)
+	catalog    *EventCatalog
+	seqMu      sync.Mutex
+	seq        uint64
+	resumedAt  uint64             // 启动时的起始序号，之前的消息属于上次运行，无法续传
+	replay     []*outboundMessage // 最近广播的消息，按序号环形存放
+	replayNext int
+	writers    sync.WaitGroup
+	compactor  *compactor  // 连续相同扫码合并状态
+	compaction func() bool // 是否合并，读取功能开关 hub.compaction
+
+	marshalErrors uint64 // 以下为原子计数，见 HubMetrics
+	truncated     uint64
+	dropped       uint64
+	queueFull     uint64
 }
 
 // Message WebSocket消息结构
 type Message struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data,omitempty"`
-	Time time.Time   `json:"time"`
+	Type   string      `json:"type"`
+	Seq    uint64      `json:"seq,omitempty"` // 广播序号，单调递增，用于断线续传；时间戳相同的消息按此排序
+	Data   interface{} `json:"data,omitempty"`
+	Time   time.Time   `json:"time"`
+	Origin string      `json:"origin,omitempty"` // 转发来源工位，本地事件为空
+
+	// 以下字段仅在消息超出客户端的大小上限、被精简后出现
+	Truncated bool     `json:"truncated,omitempty"`
+	Detail    string   `json:"detail,omitempty"`  // 获取完整数据的REST地址
+	Size      int      `json:"size,omitempty"`    // 完整消息的字节数
+	Omitted   []string `json:"omitted,omitempty"` // 被省略的数据字段
+}
+
+// WelcomePayload 连接成功后的欢迎消息
+type WelcomePayload struct {
+	Message        string `json:"message"`
+	SchemaVersion  string `json:"schema_version"`
+	Catalog        string `json:"catalog"`
+	LastSeq        uint64 `json:"last_seq"`         // 当前最新序号
+	Replayed       int    `json:"replayed"`         // 续传补发的消息数
+	Gap            bool   `json:"gap,omitempty"`    // 续传起点早于缓存，部分消息已无法补发，客户端应重新加载状态
+	MaxMessageSize int    `json:"max_message_size"` // 本连接单条消息的最大字节数
+}
+
+// BroadcastErrorEvent 消息序列化失败，未能广播
+type BroadcastErrorEvent struct {
+	Type  string `json:"type"` // 未能广播的事件类型
+	Error string `json:"error"`
 }
 
 // NewHub 创建新的WebSocket Hub
 func NewHub(cfg *config.WebSocketConfig, logger *logrus.Logger) *Hub {
+	// ping间隔须小于pong等待时间，否则连接会在两次ping之间被判定超时
+	if cfg.PongWait <= 0 {
+		cfg.PongWait = 60 * time.Second
+	}
+	if cfg.PingPeriod <= 0 || cfg.PingPeriod >= cfg.PongWait {
+		logger.WithField("ping_period", cfg.PingPeriod.String()).WithField("pong_wait", cfg.PongWait.String()).
+			Warn("ping间隔须小于pong等待时间，已调整为pong等待时间的90%")
+		cfg.PingPeriod = cfg.PongWait * 9 / 10
+	}
+
+	if cfg.ReplaySize <= 0 {
+		cfg.ReplaySize = 1000
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = defaultMaxMessageSize
+	}
+
+	compactionEnabled := cfg.Compaction.Enable
 	return &Hub{
+		compactor:  newCompactor(cfg.Compaction.Window),
+		compaction: func() bool { return compactionEnabled },
+		replay:     make([]*outboundMessage, cfg.ReplaySize),
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		broadcast:  make(chan *outboundMessage, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		config:     cfg,
 		logger:     logger,
+		catalog:    newDefaultCatalog(),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  cfg.ReadBufferSize,
 			WriteBufferSize: cfg.WriteBufferSize,
@@ -63,6 +157,8 @@ func NewHub(cfg *config.WebSocketConfig, logger *logrus.Logger) *Hub {
 func (h *Hub) Run() {
 	h.logger.Info("WebSocket Hub 已启动")
 
+	encoded := make(encodeCache)
+	incrementEncoded := make(encodeCache)
 	for {
 		select {
 		case client := <-h.register:
@@ -72,25 +168,45 @@ func (h *Hub) Run() {
 
 			h.logger.WithField("client_count", len(h.clients)).Info("新客户端连接")
 
-			// 发送欢迎消息
-			welcomeMsg := Message{
+			// 发送欢迎消息，随后补发断线期间的消息
+			welcome := WelcomePayload{
+				Message:        "WebSocket连接成功，等待扫码数据...",
+				SchemaVersion:  EventSchemaVersion,
+				Catalog:        CatalogPath,
+				LastSeq:        h.lastSeq(),
+				MaxMessageSize: client.maxMessageSize,
+			}
+			var missed []*outboundMessage
+			if client.since != nil {
+				missed, welcome.Gap = h.replaySince(*client.since)
+				// 序号早于本次运行的起始序号或大于当前序号说明服务已重启，之前的消息无法续传
+				if *client.since < h.resumedAt || *client.since > welcome.LastSeq {
+					welcome.Gap = true
+				}
+				for _, message := range missed {
+					if client.accepts(message.deviceID) {
+						welcome.Replayed++
+					}
+				}
+			}
+			welcomeMsg := &Message{
 				Type: "welcome",
-				Data: map[string]string{
-					"message": "WebSocket连接成功，等待扫码数据...",
-				},
+				Data: welcome,
 				Time: time.Now(),
 			}
 
-			if data, err := json.Marshal(welcomeMsg); err == nil {
-				select {
-				case client.send <- data:
-				default:
-					close(client.send)
-					h.mu.Lock()
-					delete(h.clients, client)
-					h.mu.Unlock()
+			h.mu.Lock()
+			if data, err := payload.Marshal(client.format, welcomeMsg); err == nil && h.sendLocked(client, data) {
+				for _, message := range missed {
+					if !client.accepts(message.deviceID) {
+						continue
+					}
+					if data, ok := h.encode(client, message, nil); ok && !h.sendLocked(client, data) {
+						break
+					}
 				}
 			}
+			h.mu.Unlock()
 
 		case client := <-h.unregister:
 			h.mu.Lock()
@@ -101,63 +217,314 @@ func (h *Hub) Run() {
 			}
 			h.mu.Unlock()
 
-		case message := <-h.broadcast:
-			h.mu.RLock()
+		case message, ok := <-h.broadcast:
+			if !ok {
+				return
+			}
+			h.remember(message)
+			increment := h.compact(message)
+
+			// 同一格式的客户端共享编码结果，缓存在消息间复用
+			clear(encoded)
+			clear(incrementEncoded)
+			h.mu.Lock()
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+				if !client.accepts(message.deviceID) {
+					continue
+				}
+				out, cache := message, encoded
+				if increment != nil && client.compact {
+					out, cache = increment, incrementEncoded
+				}
+				if data, ok := h.encode(client, out, cache); ok {
+					h.sendLocked(client, data)
 				}
 			}
-			h.mu.RUnlock()
+			h.mu.Unlock()
+		}
+	}
+}
+
+// SetCompaction 设置是否合并连续相同扫码，由功能开关决定，运行时切换立即生效；须在 Run 前调用
+func (h *Hub) SetCompaction(enabled func() bool) {
+	h.compaction = enabled
+}
+
+// compact 更新连续相同扫码的合并状态，重复扫码时返回发给开启合并的客户端的计数消息
+func (h *Hub) compact(message *outboundMessage) *outboundMessage {
+	if !h.compaction() {
+		return nil
+	}
+	increment := h.compactor.observe(message.message)
+	if increment == nil {
+		return nil
+	}
+	data, err := json.Marshal(increment)
+	if err != nil {
+		h.logger.WithError(err).Error("序列化重复扫码计数失败")
+		return nil
+	}
+	return &outboundMessage{deviceID: message.deviceID, message: increment, data: data}
+}
+
+// encode 按客户端格式编码消息，cache不为nil时在同一格式的客户端间共享结果
+//
+// 超出客户端的大小上限时依次丢弃可选字段、只保留摘要、只保留外层，并标记 truncated；
+// 仍超出时不发送该消息。
+func (h *Hub) encode(client *Client, message *outboundMessage, cache encodeCache) ([]byte, bool) {
+	data, ok := h.encodeLevel(client.format, message, -1, cache)
+	if !ok {
+		return nil, false
+	}
+	if len(data) <= client.maxMessageSize {
+		return data, true
+	}
+
+	for level := 0; level < levelCount; level++ {
+		if data, ok := h.encodeLevel(client.format, message, level, cache); ok && len(data) <= client.maxMessageSize {
+			atomic.AddUint64(&h.truncated, 1)
+			return data, true
+		}
+	}
+	atomic.AddUint64(&h.dropped, 1)
+	h.logger.WithField("client_id", client.id).WithField("type", message.message.Type).
+		WithField("size", len(message.data)).WithField("max_message_size", client.maxMessageSize).
+		Warn("消息精简后仍超出客户端的大小上限，未发送")
+	return nil, false
+}
+
+// encodeLevel 按格式编码指定精简级别的消息，level 为 -1 表示完整消息
+func (h *Hub) encodeLevel(format payload.Format, message *outboundMessage, level int, cache encodeCache) ([]byte, bool) {
+	if level < 0 && format.IsDefault() {
+		return message.data, true
+	}
+	key := encodeKey{format: format, level: level}
+	if data, ok := cache[key]; ok {
+		return data, true
+	}
+
+	out := message.message
+	if level >= 0 {
+		out = h.reduced(message, level)
+	}
+	data, err := payload.Marshal(format, out)
+	if err != nil {
+		h.logger.WithError(err).WithField("format", format.String()).WithField("level", level).Error("按客户端格式序列化消息失败")
+		return nil, false
+	}
+	if cache != nil {
+		cache[key] = data
+	}
+	return data, true
+}
+
+// sendLocked 向客户端发送数据，发送缓冲区已满时以 CloseTooSlow 断开（调用方持有写锁）
+func (h *Hub) sendLocked(client *Client, data []byte) bool {
+	if _, ok := h.clients[client]; !ok {
+		return false
+	}
+	select {
+	case client.send <- data:
+		return true
+	default:
+		delete(h.clients, client)
+		client.closeWith(CloseTooSlow, "发送缓冲区已满")
+		h.logger.WithField("client_id", client.id).Warn("客户端接收过慢，已断开")
+		return false
+	}
+}
+
+// remember 将广播消息存入续传缓存
+func (h *Hub) remember(message *outboundMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.replay[h.replayNext] = message
+	h.replayNext = (h.replayNext + 1) % len(h.replay)
+}
+
+// replaySince 获取序号大于since的缓存消息，gap表示since之后有消息已被移出缓存
+func (h *Hub) replaySince(since uint64) (messages []*outboundMessage, gap bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	oldest := uint64(0)
+	for i := 0; i < len(h.replay); i++ {
+		message := h.replay[(h.replayNext+i)%len(h.replay)]
+		if message == nil {
+			continue
+		}
+		if oldest == 0 {
+			oldest = message.message.Seq
+		}
+		if message.message.Seq > since {
+			messages = append(messages, message)
 		}
 	}
+	return messages, oldest > since+1
+}
+
+// ResumeSeq 设置本次运行的起始序号，新消息从 seq+1 开始编号；须在 Run 之前调用
+//
+// 序号跨重启连续时，续传的客户端不会把重启后的新消息误当作已收到的旧消息。
+func (h *Hub) ResumeSeq(seq uint64) {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+	h.seq = seq
+	h.resumedAt = seq
+}
+
+// lastSeq 当前最新的广播序号
+func (h *Hub) lastSeq() uint64 {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+	return h.seq
 }
 
 // HandleWebSocket 处理WebSocket连接
-func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request, opts ClientOptions) {
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.WithError(err).Error("WebSocket升级失败")
 		return
 	}
 
+	maxMessageSize := h.config.MaxMessageSize
+	if opts.MaxMessageSize > 0 && opts.MaxMessageSize < maxMessageSize {
+		maxMessageSize = opts.MaxMessageSize
+	}
+
+	// 续传的消息在连接建立时一次性放入发送缓冲区
+	buffer := 256
+	if opts.Since != nil {
+		buffer += len(h.replay)
+	}
+
 	client := &Client{
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		hub:    h,
-		logger: h.logger,
+		id:             nextClientID(),
+		conn:           conn,
+		send:           make(chan []byte, buffer),
+		hub:            h,
+		logger:         h.logger,
+		devices:        opts.Devices,
+		format:         opts.Format,
+		connectedAt:    time.Now(),
+		since:          opts.Since,
+		compact:        opts.Compact,
+		maxMessageSize: maxMessageSize,
 	}
 
 	client.hub.register <- client
 
 	// 启动客户端的读写协程
+	h.writers.Add(1)
 	go client.writePump()
 	go client.readPump()
 }
 
+// OnBroadcast 注册广播回调，用于将事件流输出到其他目标，data为默认格式的编码
+//
+// 回调按推送序号顺序在序号锁内调用，须非阻塞且不能再广播消息。
+func (h *Hub) OnBroadcast(listener func(msgType string, message *Message, data []byte)) {
+	h.listeners = append(h.listeners, listener)
+}
+
 // BroadcastBarcode 广播条码数据
 func (h *Hub) BroadcastBarcode(barcodeData *barcode.BarcodeData) {
-	message := Message{
-		Type: "barcode",
-		Data: barcodeData,
-		Time: time.Now(),
+	h.BroadcastDeviceMessage("barcode", barcodeData.DeviceID, barcodeData)
+}
+
+// BroadcastMessage 广播指定类型的消息
+func (h *Hub) BroadcastMessage(msgType string, payload interface{}) {
+	h.BroadcastDeviceMessage(msgType, nil, payload)
+}
+
+// BroadcastDeviceMessage 广播与设备相关的消息，只发送给订阅了该设备的客户端
+func (h *Hub) BroadcastDeviceMessage(msgType string, deviceID *uint, payload interface{}) {
+	if !h.catalog.Has(msgType) {
+		h.logger.WithField("type", msgType).Error("广播的事件类型未登记到事件目录")
 	}
 
+	h.broadcastMessage(&Message{
+		Type: msgType,
+		Data: payload,
+		Time: time.Now(),
+	}, deviceID)
+}
+
+// BroadcastForwarded 广播相邻工位转发来的事件，标记来源工位
+func (h *Hub) BroadcastForwarded(msgType, origin string, at time.Time, payload json.RawMessage) {
+	h.broadcastMessage(&Message{
+		Type:   msgType,
+		Data:   payload,
+		Time:   at,
+		Origin: origin,
+	}, nil)
+}
+
+// broadcastMessage 分配序号、编码消息并通知广播回调和客户端
+func (h *Hub) broadcastMessage(message *Message, deviceID *uint) {
+	msgType := message.Type
+
+	// 序号分配与入队在同一把锁内，保证客户端收到的序号有序
+	h.seqMu.Lock()
+	h.seq++
+	message.Seq = h.seq
 	data, err := json.Marshal(message)
 	if err != nil {
-		h.logger.WithError(err).Error("序列化条码数据失败")
+		h.seq--
+		h.seqMu.Unlock()
+		h.marshalFailed(msgType, deviceID, err)
 		return
 	}
-
 	select {
-	case h.broadcast <- data:
-		h.logger.WithField("client_count", len(h.clients)).Debug("条码数据已广播")
+	case h.broadcast <- &outboundMessage{deviceID: deviceID, message: message, data: data}:
+		if h.logger.IsLevelEnabled(logrus.DebugLevel) {
+			h.logger.WithFields(logrus.Fields{"type": msgType, "client_count": h.GetClientCount()}).Debug("消息已广播")
+		}
 	default:
-		h.logger.Warn("广播通道已满，丢弃消息")
+		atomic.AddUint64(&h.queueFull, 1)
+		h.logger.WithField("type", msgType).Warn("广播通道已满，丢弃消息")
+	}
+
+	// 回调也在序号锁内调用，输出端收到的消息与客户端一样按序号排列
+	for _, listener := range h.listeners {
+		listener(msgType, message, data)
 	}
+	h.seqMu.Unlock()
+}
+
+// marshalFailed 记录序列化失败并广播 broadcast_error，让订阅方知道有事件丢失
+func (h *Hub) marshalFailed(msgType string, deviceID *uint, err error) {
+	atomic.AddUint64(&h.marshalErrors, 1)
+	h.logger.WithError(err).WithField("type", msgType).Error("序列化广播消息失败")
+	if msgType == "broadcast_error" {
+		return
+	}
+	h.broadcastMessage(&Message{
+		Type: "broadcast_error",
+		Data: BroadcastErrorEvent{Type: msgType, Error: err.Error()},
+		Time: time.Now(),
+	}, deviceID)
+}
+
+// Metrics 获取广播路径的累计指标
+func (h *Hub) Metrics() HubMetrics {
+	return HubMetrics{
+		MarshalErrors: atomic.LoadUint64(&h.marshalErrors),
+		Truncated:     atomic.LoadUint64(&h.truncated),
+		Dropped:       atomic.LoadUint64(&h.dropped),
+		QueueFull:     atomic.LoadUint64(&h.queueFull),
+	}
+}
+
+// QueueDepth 广播通道中等待分发的消息数
+func (h *Hub) QueueDepth() int {
+	return len(h.broadcast)
+}
+
+// Catalog 获取事件目录
+func (h *Hub) Catalog() *EventCatalog {
+	return h.catalog
 }
 
 // GetClientCount 获取当前连接的客户端数量
@@ -167,21 +534,64 @@ func (h *Hub) GetClientCount() int {
 	return len(h.clients)
 }
 
-// Close 关闭Hub
+// GetClients 获取当前连接的客户端信息，含心跳往返时延
+func (h *Hub) GetClients() []ClientInfo {
+	h.mu.RLock()
+	clients := make([]ClientInfo, 0, len(h.clients))
+	for client := range h.clients {
+		info := ClientInfo{
+			ID:             client.id,
+			RemoteAddr:     client.conn.RemoteAddr().String(),
+			ConnectedAt:    client.connectedAt,
+			Format:         client.format.String(),
+			Compact:        client.compact,
+			MaxMessageSize: client.maxMessageSize,
+		}
+		client.keepalive.info(&info)
+		clients = append(clients, info)
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients
+}
+
+// Close 关闭Hub，以 CloseShutdown 通知客户端
 func (h *Hub) Close() {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	h.Shutdown(CloseShutdown, "服务停止")
+}
 
+// Shutdown 以指定关闭码断开所有客户端并关闭Hub，最多等待WriteWait让关闭帧发出
+func (h *Hub) Shutdown(code int, reason string) {
+	h.mu.Lock()
 	for client := range h.clients {
-		client.conn.Close()
-		close(client.send)
+		delete(h.clients, client)
+		client.closeWith(code, reason)
 	}
 
+	// 仅关闭广播通道使Run退出；register/unregister仍可能被读写协程使用，不关闭以免向已关闭通道发送
 	close(h.broadcast)
-	close(h.register)
-	close(h.unregister)
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(h.config.WriteWait):
+	}
+
+	h.logger.WithField("code", code).Info("WebSocket Hub 已关闭")
+}
 
-	h.logger.Info("WebSocket Hub 已关闭")
+// accepts 客户端是否接收该设备的消息，与设备无关的消息总是接收
+func (c *Client) accepts(deviceID *uint) bool {
+	if c.devices == nil || deviceID == nil {
+		return true
+	}
+	return c.devices[*deviceID]
 }
 
 // readPump 读取客户端消息
@@ -192,15 +602,24 @@ func (c *Client) readPump() {
 	}()
 
 	c.conn.SetReadDeadline(time.Now().Add(c.hub.config.PongWait))
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(c.hub.config.PongWait))
+	c.conn.SetPongHandler(func(data string) error {
+		now := time.Now()
+		c.keepalive.pong(data, now)
+		c.conn.SetReadDeadline(now.Add(c.hub.config.PongWait))
 		return nil
 	})
 
 	for {
 		_, _, err := c.conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// 超过pong等待时间仍未收到任何数据，判定连接已失效
+				c.logger.WithField("client_id", c.id).
+					WithField("remote_addr", c.conn.RemoteAddr().String()).
+					WithField("missed_pongs", c.keepalive.missedPongs()).
+					Warn("客户端未应答心跳，关闭连接")
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				c.logger.WithError(err).Error("WebSocket读取错误")
 			}
 			break
@@ -214,6 +633,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.writers.Done()
 	}()
 
 	for {
@@ -221,7 +641,11 @@ func (c *Client) writePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMessage := []byte{}
+				if c.closeFrame != nil {
+					closeMessage = websocket.FormatCloseMessage(c.closeFrame.code, c.closeFrame.reason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMessage)
 				return
 			}
 
@@ -236,8 +660,13 @@ func (c *Client) writePump() {
 			}
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			now := time.Now()
+			data, missed := c.keepalive.pingPayload(now)
+			if missed > 0 {
+				c.logger.WithField("client_id", c.id).WithField("missed_pongs", missed).Warn("客户端未应答上一次心跳")
+			}
+			c.conn.SetWriteDeadline(now.Add(c.hub.config.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, data); err != nil {
 				return
 			}
 		}
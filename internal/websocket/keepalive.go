@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// clientSeq 客户端编号
+var clientSeq uint64
+
+// ClientInfo 客户端连接信息
+type ClientInfo struct {
+	ID             uint64     `json:"id"`
+	RemoteAddr     string     `json:"remote_addr"`
+	ConnectedAt    time.Time  `json:"connected_at"`
+	Format         string     `json:"format"`
+	Compact        bool       `json:"compact"`          // 是否开启连续相同扫码合并
+	RTTMS          *float64   `json:"rtt_ms,omitempty"` // 最近一次ping/pong往返时延，尚未收到pong时为空
+	LastPongAt     *time.Time `json:"last_pong_at,omitempty"`
+	MissedPongs    int        `json:"missed_pongs"`     // 连续未应答的ping次数
+	MaxMessageSize int        `json:"max_message_size"` // 协商后的单条消息最大字节数
+}
+
+// keepalive 单个连接的心跳状态，ping携带发送时间戳，pong原样返回用于计算往返时延
+type keepalive struct {
+	mu          sync.Mutex
+	pingSentAt  time.Time
+	pongPending bool
+	lastPongAt  *time.Time
+	rtt         *time.Duration
+	missed      int
+}
+
+// nextClientID 分配客户端编号
+func nextClientID() uint64 {
+	return atomic.AddUint64(&clientSeq, 1)
+}
+
+// pingPayload 生成携带发送时间戳的ping数据，返回此前连续未应答的ping次数
+func (k *keepalive) pingPayload(now time.Time) ([]byte, int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.pongPending {
+		k.missed++
+	}
+	k.pingSentAt = now
+	k.pongPending = true
+	return []byte(strconv.FormatInt(now.UnixNano(), 10)), k.missed
+}
+
+// pong 处理pong，根据回传的时间戳计算往返时延
+func (k *keepalive) pong(data string, now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.lastPongAt = &now
+	k.pongPending = false
+	k.missed = 0
+	if sent, err := strconv.ParseInt(data, 10, 64); err == nil {
+		rtt := now.Sub(time.Unix(0, sent))
+		if rtt >= 0 {
+			k.rtt = &rtt
+		}
+	}
+}
+
+// missedPongs 连续未应答的ping次数（含当前未应答的一次）
+func (k *keepalive) missedPongs() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pongPending {
+		return k.missed + 1
+	}
+	return k.missed
+}
+
+// info 填充客户端连接信息中的心跳字段
+func (k *keepalive) info(info *ClientInfo) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	info.LastPongAt = k.lastPongAt
+	info.MissedPongs = k.missed
+	if k.rtt != nil {
+		ms := float64(*k.rtt) / float64(time.Millisecond)
+		info.RTTMS = &ms
+	}
+}
@@ -0,0 +1,176 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"userclient/internal/config"
+)
+
+// newKeepaliveHub 以较短的心跳间隔启动 Hub 及其HTTP服务
+func newKeepaliveHub(t *testing.T, pongWait time.Duration) (*Hub, *test.Hook, string) {
+	t.Helper()
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	hub := NewHub(&config.WebSocketConfig{
+		CheckOrigin: true,
+		PingPeriod:  pongWait / 5,
+		PongWait:    pongWait,
+		WriteWait:   time.Second,
+	}, logger)
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.HandleWebSocket(w, r, ClientOptions{})
+	}))
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+	return hub, hook, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// dial 连接 Hub，在后台读取消息直到连接断开，answerPings 为 false 时收到ping不回pong
+func dial(t *testing.T, url string, answerPings bool) (*websocket.Conn, <-chan struct{}) {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if !answerPings {
+		conn.SetPingHandler(func(string) error { return nil })
+	}
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	return conn, closed
+}
+
+// waitUntil 轮询直到条件满足或超时
+func waitUntil(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超时: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKeepalivePingPong(t *testing.T) {
+	var k keepalive
+	start := time.Unix(1700000000, 0)
+
+	data, missed := k.pingPayload(start)
+	if missed != 0 || k.missedPongs() != 1 {
+		t.Fatalf("first ping: missed = %d, pending = %d", missed, k.missedPongs())
+	}
+	// 两次ping都未应答
+	if _, missed = k.pingPayload(start.Add(time.Second)); missed != 1 {
+		t.Fatalf("second ping: missed = %d, want 1", missed)
+	}
+	if _, missed = k.pingPayload(start.Add(2 * time.Second)); missed != 2 || k.missedPongs() != 3 {
+		t.Fatalf("third ping: missed = %d, pending = %d", missed, k.missedPongs())
+	}
+
+	// 迟到的pong回传第一次ping的时间戳，往返时延按回传的时间计算
+	k.pong(string(data), start.Add(2500*time.Millisecond))
+	var info ClientInfo
+	k.info(&info)
+	if info.MissedPongs != 0 || k.missedPongs() != 0 {
+		t.Fatalf("after pong: missed = %d", info.MissedPongs)
+	}
+	if info.RTTMS == nil || *info.RTTMS != 2500 {
+		t.Fatalf("rtt = %v, want 2500ms", info.RTTMS)
+	}
+
+	// 无法解析或早于发送时间的pong不更新往返时延
+	k.pong("not-a-timestamp", start.Add(3*time.Second))
+	k.pong(strconv.FormatInt(start.Add(time.Hour).UnixNano(), 10), start.Add(3*time.Second))
+	k.info(&info)
+	if *info.RTTMS != 2500 || info.LastPongAt == nil || !info.LastPongAt.Equal(start.Add(3*time.Second)) {
+		t.Fatalf("info = rtt %v, last pong %v", *info.RTTMS, info.LastPongAt)
+	}
+}
+
+// TestKeepaliveAnsweredPongs 应答心跳的客户端超过pong等待时间后仍保持连接，并显示往返时延
+func TestKeepaliveAnsweredPongs(t *testing.T) {
+	pongWait := 150 * time.Millisecond
+	hub, hook, url := newKeepaliveHub(t, pongWait)
+	_, closed := dial(t, url, true)
+
+	waitUntil(t, 2*time.Second, "收到pong", func() bool {
+		clients := hub.GetClients()
+		return len(clients) == 1 && clients[0].RTTMS != nil
+	})
+	time.Sleep(2 * pongWait)
+	select {
+	case <-closed:
+		t.Fatal("应答心跳的连接被关闭")
+	default:
+	}
+	clients := hub.GetClients()
+	if len(clients) != 1 || clients[0].MissedPongs != 0 || clients[0].LastPongAt == nil || *clients[0].RTTMS < 0 {
+		t.Fatalf("clients = %+v", clients)
+	}
+	for _, entry := range hook.AllEntries() {
+		if strings.Contains(entry.Message, "未应答") {
+			t.Fatalf("应答心跳的客户端被记录为未应答: %s", entry.Message)
+		}
+	}
+}
+
+// TestKeepaliveMissedPongs 不应答心跳的客户端在pong等待时间后被断开，日志记录连续未应答的次数
+func TestKeepaliveMissedPongs(t *testing.T) {
+	pongWait := 150 * time.Millisecond
+	hub, hook, url := newKeepaliveHub(t, pongWait)
+	connectedAt := time.Now()
+	_, closed := dial(t, url, false)
+
+	waitUntil(t, 2*time.Second, "连接注册", func() bool { return hub.GetClientCount() == 1 })
+	waitUntil(t, time.Second, "记录未应答", func() bool {
+		clients := hub.GetClients()
+		return len(clients) == 1 && clients[0].MissedPongs > 0
+	})
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("不应答心跳的连接未被关闭")
+	}
+	if elapsed := time.Since(connectedAt); elapsed < pongWait {
+		t.Fatalf("连接在 %v 后被关闭，早于pong等待时间 %v", elapsed, pongWait)
+	}
+	waitUntil(t, time.Second, "注销客户端", func() bool { return hub.GetClientCount() == 0 })
+
+	var missedWarnings, timeout int
+	for _, entry := range hook.AllEntries() {
+		switch entry.Message {
+		case "客户端未应答上一次心跳":
+			missedWarnings++
+		case "客户端未应答心跳，关闭连接":
+			timeout++
+			if missed, _ := entry.Data["missed_pongs"].(int); missed < 2 {
+				t.Errorf("关闭连接时 missed_pongs = %v，want >= 2", entry.Data["missed_pongs"])
+			}
+		}
+	}
+	if missedWarnings == 0 || timeout != 1 {
+		t.Fatalf("未应答告警 %d 次、超时关闭 %d 次", missedWarnings, timeout)
+	}
+}
@@ -0,0 +1,190 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"userclient/pkg/payload"
+)
+
+const (
+	// MinMessageSize 客户端可协商的最小消息大小，须能容纳截断后的摘要
+	MinMessageSize = 1024
+	// defaultMaxMessageSize 未配置时的单条消息大小上限
+	defaultMaxMessageSize = 64 * 1024
+	// maxSummaryString 摘要中保留的字符串字段的最大字节数
+	maxSummaryString = 256
+)
+
+// 消息超出大小上限时依次尝试的精简级别
+const (
+	levelDropOptional = iota // 丢弃事件目录中登记的可选字段
+	levelSummary             // 只保留较短的标量字段
+	levelEnvelope            // 只保留消息外层，不含数据
+	levelCount
+)
+
+// Detailer 载荷实现此接口时，截断的消息带上获取完整数据的REST地址
+type Detailer interface {
+	DetailPath() string
+}
+
+// encodeKey 编码缓存键，同一格式、同一精简级别的客户端共享编码结果
+type encodeKey struct {
+	format payload.Format
+	level  int // -1 表示完整消息
+}
+
+// encodeCache 一条广播消息在各客户端间共享的编码结果
+type encodeCache map[encodeKey][]byte
+
+// HubMetrics 广播路径的累计指标
+type HubMetrics struct {
+	MarshalErrors uint64 `json:"marshal_errors"` // 序列化失败而未能广播的消息数
+	Truncated     uint64 `json:"truncated"`      // 超出客户端大小上限而精简发送的消息数（按客户端计）
+	Dropped       uint64 `json:"dropped"`        // 精简后仍超出上限而未发送的消息数（按客户端计）
+	QueueFull     uint64 `json:"queue_full"`     // 广播通道已满而丢弃的消息数
+}
+
+// reduced 按精简级别生成消息，结果缓存在消息上；只在Hub的Run协程中调用
+func (h *Hub) reduced(message *outboundMessage, level int) *Message {
+	if message.reduced == nil {
+		message.reduced = make([]*Message, levelCount)
+	}
+	if reduced := message.reduced[level]; reduced != nil {
+		return reduced
+	}
+
+	original := message.message
+	reduced := &Message{
+		Type:      original.Type,
+		Seq:       original.Seq,
+		Time:      original.Time,
+		Origin:    original.Origin,
+		Truncated: true,
+		Size:      len(message.data),
+	}
+	if detailer, ok := original.Data.(Detailer); ok {
+		reduced.Detail = detailer.DetailPath()
+	}
+
+	fields, ok := fieldsOf(original.Data)
+	var omitted []string
+	switch {
+	case !ok:
+		// 非对象载荷无法逐字段精简，只保留外层
+		level = levelEnvelope
+	case level == levelDropOptional:
+		for _, name := range h.catalog.Droppable(original.Type) {
+			if _, ok := fields[name]; ok {
+				delete(fields, name)
+				omitted = append(omitted, name)
+			}
+		}
+	case level == levelSummary:
+		for name, value := range fields {
+			if !isSummaryValue(value) {
+				delete(fields, name)
+				omitted = append(omitted, name)
+			}
+		}
+	}
+	if level == levelEnvelope {
+		fields = nil
+	}
+	if fields != nil {
+		reduced.Data = fields
+	}
+	sort.Strings(omitted)
+	reduced.Omitted = omitted
+
+	message.reduced[level] = reduced
+	return reduced
+}
+
+// fieldsOf 将对象载荷展开为字段名到值的映射，值保持原类型以便按客户端格式编码
+func fieldsOf(data interface{}) (map[string]interface{}, bool) {
+	if raw, ok := data.(json.RawMessage); ok {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, false
+		}
+		result := make(map[string]interface{}, len(fields))
+		for name, value := range fields {
+			result[name] = value
+		}
+		return result, true
+	}
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		result := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitempty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			value := v.Field(i)
+			if omitempty && value.IsZero() {
+				continue
+			}
+			result[name] = value.Interface()
+		}
+		return result, true
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result[iter.Key().String()] = iter.Value().Interface()
+		}
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// isSummaryValue 是否为摘要中保留的值：布尔、数字、时间及较短的字符串
+func isSummaryValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, time.Time:
+		return true
+	case json.RawMessage:
+		return len(v) <= maxSummaryString && len(v) > 0 && v[0] != '{' && v[0] != '['
+	case string:
+		return len(v) <= maxSummaryString
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return true
+		}
+		return isSummaryValue(rv.Elem().Interface())
+	}
+	switch rv.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.String:
+		return rv.Len() <= maxSummaryString
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package barcode
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// LinkType 网址条码的类型
+const LinkType = "网址"
+
+// maxLinkLength 网址条码的最大长度
+const maxLinkLength = 2048
+
+// 网址条码提取的派生字段，分类规则已提取同名字段时不覆盖
+const (
+	LinkHostField = "url_host"
+	LinkPathField = "url_path"
+)
+
+// Link 条码中的网址
+type Link struct {
+	URL      string // 规范化后的网址，主机名为 punycode
+	Host     string // 小写 ASCII 主机名，不含端口
+	Path     string
+	Userinfo bool // 网址包含用户名（如 https://good.com@evil.com），不允许点击
+}
+
+// ParseLink 识别并规范化条码中的 http/https 网址，不是网址或主机名无效时返回false
+//
+// 国际化域名按 IDNA 查找规则转为 punycode，显示及白名单匹配都使用 ASCII 形式，
+// 与白名单域名字形相近的其他文字域名（如西里尔字母 а）不会被当作同一域名。
+func ParseLink(content string) (*Link, bool) {
+	content = strings.TrimSpace(content)
+	if content == "" || len(content) > maxLinkLength || !hasLinkPrefix(content) {
+		return nil, false
+	}
+	for _, r := range content {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return nil, false
+		}
+	}
+	if strings.EqualFold(content[:4], "www.") {
+		content = "https://" + content
+	}
+
+	u, err := url.Parse(content)
+	if err != nil || u.Opaque != "" || u.Host == "" {
+		return nil, false
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, false
+	}
+
+	host, err := normalizeHost(u.Hostname())
+	if err != nil {
+		return nil, false
+	}
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	u.Host = host
+	if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	return &Link{
+		URL:      u.String(),
+		Host:     host,
+		Path:     u.Path,
+		Userinfo: u.User != nil,
+	}, true
+}
+
+// hasLinkPrefix 是否以 http://、https:// 或 www. 开头（不区分大小写），普通条码由此跳过URL解析
+func hasLinkPrefix(content string) bool {
+	for _, prefix := range [...]string{"http://", "https://", "www."} {
+		if len(content) >= len(prefix) && strings.EqualFold(content[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost 主机名转为小写 ASCII，IP 地址保持原样
+func normalizeHost(host string) (string, error) {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return "", fmt.Errorf("主机名为空")
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("域名 %s 无效: %w", host, err)
+	}
+	return strings.ToLower(ascii), nil
+}
+
+// LinkAllowList 可点击网址的域名白名单
+type LinkAllowList struct {
+	hosts    map[string]bool
+	suffixes []string // 通配条目 *.example.com 保存为 .example.com
+}
+
+// ParseLinkAllowList 解析域名白名单，条目为域名、IP 地址或 *.域名
+//
+// *.example.com 只匹配子域名，不匹配 example.com 本身；通配至少包含两级域名，不允许 *.com。
+func ParseLinkAllowList(entries []string) (*LinkAllowList, error) {
+	list := &LinkAllowList{hosts: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			return nil, fmt.Errorf("白名单条目不能为空")
+		}
+		if strings.ContainsAny(entry, "/@?#\\ ") {
+			return nil, fmt.Errorf("白名单条目 %q 须为域名，不能包含协议或路径", entry)
+		}
+
+		wildcard := strings.HasPrefix(entry, "*.")
+		name := strings.TrimPrefix(entry, "*.")
+		if strings.Contains(name, "*") {
+			return nil, fmt.Errorf("白名单条目 %q 只支持以 *. 开头的通配", entry)
+		}
+		host, err := normalizeHost(name)
+		if err != nil {
+			return nil, fmt.Errorf("白名单条目 %q 无效: %w", entry, err)
+		}
+
+		if !wildcard {
+			list.hosts[host] = true
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			return nil, fmt.Errorf("白名单条目 %q 无效: IP 地址不支持通配", entry)
+		}
+		if !strings.Contains(host, ".") {
+			return nil, fmt.Errorf("白名单条目 %q 范围过大，通配须至少包含两级域名", entry)
+		}
+		list.suffixes = append(list.suffixes, "."+host)
+	}
+	return list, nil
+}
+
+// Allows 主机名是否在白名单中，host 须为 ParseLink 规范化后的主机名
+func (l *LinkAllowList) Allows(host string) bool {
+	if l == nil || host == "" {
+		return false
+	}
+	if l.hosts[host] {
+		return true
+	}
+	for _, suffix := range l.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLinkAllowList 替换可点击网址的域名白名单，为nil表示不允许任何网址
+func (p *Processor) SetLinkAllowList(list *LinkAllowList) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.links = list
+}
+
+// applyLink 记录网址条码规范化后的网址，提取主机名及路径并检查域名白名单
+func (p *Processor) applyLink(data *BarcodeData, link *Link) {
+	p.mu.RLock()
+	allowed := !link.Userinfo && p.links.Allows(link.Host)
+	p.mu.RUnlock()
+
+	data.Link = link.URL
+	data.LinkAllowed = &allowed
+	if data.Derived == nil {
+		data.Derived = make(map[string]string, 2)
+	}
+	for _, field := range [...][2]string{{LinkHostField, link.Host}, {LinkPathField, link.Path}} {
+		if _, exists := data.Derived[field[0]]; !exists {
+			data.Derived[field[0]] = field[1]
+		}
+	}
+}
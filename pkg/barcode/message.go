@@ -0,0 +1,57 @@
+package barcode
+
+import (
+	"regexp"
+	"strings"
+)
+
+// placeholderPattern 消息模板中的占位符
+var placeholderPattern = regexp.MustCompile(`\{[A-Za-z_]+\}`)
+
+// messagePlaceholders 消息模板支持的占位符
+var messagePlaceholders = map[string]bool{
+	"{message}": true, // 识别结果说明，如“识别为EAN-13条码，正在验证...”
+	"{station}": true, // 扫码设备所属工位，设备未分配工位时为本工位名称
+	"{device}":  true, // 扫码设备名称
+	"{type}":    true, // 条码类型
+	"{content}": true, // 条码内容
+}
+
+// MessageTemplate 推送消息模板，多个工位共用看板时据此区分消息来自哪个工位及设备
+type MessageTemplate struct {
+	Format         string // 为空时只输出识别结果说明
+	UnknownDevice  string // 扫码未归属设备或设备已不存在时 {device} 的取值
+	UnknownStation string // 未配置工位名称时 {station} 的取值
+}
+
+// Render 按模板生成消息，占位符取自已填写工位及设备的扫码数据
+func (t MessageTemplate) Render(d *BarcodeData) string {
+	if t.Format == "" {
+		return d.Message
+	}
+	device := d.DeviceName
+	if device == "" {
+		device = t.UnknownDevice
+	}
+	station := d.Station
+	if station == "" {
+		station = t.UnknownStation
+	}
+	return strings.NewReplacer(
+		"{message}", d.Message,
+		"{station}", station,
+		"{device}", device,
+		"{type}", d.Type,
+		"{content}", d.Content,
+	).Replace(t.Format)
+}
+
+// UnknownPlaceholder 模板中第一个不支持的占位符，全部支持时返回 false
+func UnknownPlaceholder(format string) (string, bool) {
+	for _, placeholder := range placeholderPattern.FindAllString(format, -1) {
+		if !messagePlaceholders[placeholder] {
+			return placeholder, true
+		}
+	}
+	return "", false
+}
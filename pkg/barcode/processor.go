@@ -1,22 +1,60 @@
 package barcode
 
 import (
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // BarcodeData 条码数据结构
 type BarcodeData struct {
-	Content   string    `json:"content"`
-	Length    int       `json:"length"`
-	Type      string    `json:"type"`
-	Timestamp time.Time `json:"timestamp"`
-	Status    string    `json:"status"`
-	Message   string    `json:"message"`
+	Content           string            `json:"content"`
+	Length            int               `json:"length"`
+	Type              string            `json:"type"`
+	Timestamp         time.Time         `json:"timestamp"`
+	Status            string            `json:"status"`
+	Message           string            `json:"message"`
+	RecordID          uint              `json:"record_id,omitempty"` // 扫码记录ID，时间戳相同时按此排序
+	PublicID          string            `json:"public_id,omitempty"` // 扫码记录的公开ID
+	DeviceID          *uint             `json:"device_id,omitempty"`
+	DeviceName        string            `json:"device_name,omitempty"`        // 扫码设备名称，看板按设备过滤时使用
+	Station           string            `json:"station,omitempty"`            // 扫码设备所属工位，设备未分配工位时为本工位名称
+	HasImage          bool              `json:"has_image"`
+	SuspectTruncation bool              `json:"suspect_truncation,omitempty"` // 按键时序异常，条码可能被截断
+	DurationMS        int64             `json:"duration_ms,omitempty"`         // 扫码耗时（首个按键到最后一个按键），未知时为0
+	MinKeyIntervalMS  int64             `json:"min_key_interval_ms,omitempty"` // 最小按键间隔，非键盘来源不输出
+	MaxKeyIntervalMS  int64             `json:"max_key_interval_ms,omitempty"` // 最大按键间隔
+	AvgKeyIntervalMS  float64           `json:"avg_key_interval_ms,omitempty"` // 平均按键间隔
+	Derived           map[string]string `json:"derived,omitempty"`            // 分类规则正则捕获组提取的字段
+	Source            string            `json:"source,omitempty"`             // 扫码来源，外部系统推送时为 ingest
+	Input             string            `json:"input,omitempty"`              // 采集来源标识，如 hook、serial:COM3、network:192.168.1.20
+	Simulation        bool              `json:"simulation,omitempty"`         // 模拟扫码，看板及下游据此与真实扫码区分
+	Link              string            `json:"link,omitempty"`               // 网址条码规范化后的网址
+	LinkAllowed       *bool             `json:"link_allowed,omitempty"`       // 网址域名在白名单中，前端据此显示为可点击链接
 }
 
+// DetailPath 扫码记录的REST地址，推送消息被截断时客户端由此获取完整数据
+func (d BarcodeData) DetailPath() string {
+	switch {
+	case d.PublicID != "":
+		return "/api/barcodes/" + d.PublicID
+	case d.RecordID != 0:
+		return "/api/barcodes/" + strconv.FormatUint(uint64(d.RecordID), 10)
+	}
+	return ""
+}
+
+// GroupSeparator GS1组分隔符（ASCII 29），结束GS1条码中可变长度的应用标识符字段；
+// 条码内容中原样保留，JSON 中输出为 \u001d
+const GroupSeparator = '\x1d'
+
 // Processor 条码处理器
-type Processor struct{}
+type Processor struct {
+	mu    sync.RWMutex
+	rules []*Rule
+	links *LinkAllowList
+}
 
 // NewProcessor 创建新的条码处理器
 func NewProcessor() *Processor {
@@ -25,28 +63,62 @@ func NewProcessor() *Processor {
 
 // ProcessBarcode 处理条码数据
 func (p *Processor) ProcessBarcode(content string) *BarcodeData {
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
+	return p.process(content, rules)
+}
+
+// ProcessWithRules 按给定的分类规则处理条码，不使用已加载的规则，供规则测试使用
+func (p *Processor) ProcessWithRules(content string, rules []*Rule) *BarcodeData {
+	return p.process(content, rules)
+}
+
+// process 按给定的分类规则处理条码数据
+func (p *Processor) process(content string, rules []*Rule) *BarcodeData {
 	timestamp := time.Now()
 	
 	barcodeData := &BarcodeData{
 		Content:   content,
 		Length:    len(content),
-		Type:      p.GetBarcodeType(content),
 		Timestamp: timestamp,
 		Status:    "success",
 	}
 	
+	// 网址只解析一次，类型、消息及派生字段共用解析结果
+	link, isLink := ParseLink(content)
+	barcodeData.Type = p.barcodeType(content, isLink)
+	
 	// 业务逻辑处理
-	barcodeData.Message = p.generateMessage(content)
+	barcodeData.Message = p.generateMessage(content, isLink)
+	
+	// 分类规则（可覆盖类型并提取派生字段）
+	applyRules(barcodeData, rules)
+	
+	// 网址条码（派生主机名、路径，检查域名白名单）
+	if isLink {
+		p.applyLink(barcodeData, link)
+	}
 	
 	return barcodeData
 }
 
 // GetBarcodeType 获取条码类型
 func (p *Processor) GetBarcodeType(barcode string) string {
+	_, isLink := ParseLink(barcode)
+	return p.barcodeType(barcode, isLink)
+}
+
+// barcodeType 按已解析的网址结果判断条码类型
+func (p *Processor) barcodeType(barcode string, isLink bool) string {
 	if barcode == "" {
 		return "未知"
 	}
 	
+	if isLink {
+		return LinkType
+	}
+	
 	switch {
 	case len(barcode) == 8 && p.isAllDigits(barcode):
 		return "EAN-8"
@@ -70,7 +142,11 @@ func (p *Processor) GetBarcodeType(barcode string) string {
 }
 
 // generateMessage 生成处理消息
-func (p *Processor) generateMessage(barcode string) string {
+func (p *Processor) generateMessage(barcode string, isLink bool) string {
+	if isLink {
+		return "识别为网址，仅白名单域名可点击打开"
+	}
+	
 	switch {
 	case strings.HasPrefix(barcode, "PRD"):
 		return "识别为产品条码，正在查询产品信息..."
@@ -121,6 +197,11 @@ func (p *Processor) ValidateBarcode(barcode string) (bool, string) {
 		return false, "条码长度太短"
 	}
 	
+	// 网址条码按URL规则校验，长度及字符集不受普通条码限制
+	if _, ok := ParseLink(barcode); ok {
+		return true, "网址格式有效"
+	}
+	
 	if len(barcode) > 50 {
 		return false, "条码长度太长"
 	}
@@ -129,7 +210,7 @@ func (p *Processor) ValidateBarcode(barcode string) (bool, string) {
 	for _, r := range barcode {
 		if !((r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || 
 			r == '-' || r == '.' || r == '_' || r == '/' || r == '\\' || r == ':' || r == ';' || 
-			r == '[' || r == ']' || r == '(' || r == ')' || r == '+' || r == '=' || r == ' ') {
+			r == '[' || r == ']' || r == '(' || r == ')' || r == '+' || r == '=' || r == ' ' || r == GroupSeparator) {
 			return false, "条码包含非法字符"
 		}
 	}
@@ -137,6 +218,30 @@ func (p *Processor) ValidateBarcode(barcode string) (bool, string) {
 	return true, "条码格式有效"
 }
 
+// ValidateCheckDigit 校验GS1码制（EAN-8、UPC-A、EAN-13、ITF-14）的校验位
+//
+// applicable 为 false 表示条码不是这几种码制（长度不符或含非数字字符），不做校验。
+func (p *Processor) ValidateCheckDigit(barcode string) (valid bool, applicable bool) {
+	switch len(barcode) {
+	case 8, 12, 13, 14:
+	default:
+		return false, false
+	}
+	if !p.isAllDigits(barcode) {
+		return false, false
+	}
+	
+	// 从校验位左侧一位起向左，权重依次为3、1交替
+	sum := 0
+	weight := 3
+	for i := len(barcode) - 2; i >= 0; i-- {
+		sum += int(barcode[i]-'0') * weight
+		weight = 4 - weight
+	}
+	check := (10 - sum%10) % 10
+	return int(barcode[len(barcode)-1]-'0') == check, true
+}
+
 // GetBarcodeInfo 获取条码详细信息
 func (p *Processor) GetBarcodeInfo(barcode string) map[string]interface{} {
 	info := map[string]interface{}{
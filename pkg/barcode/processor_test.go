@@ -0,0 +1,23 @@
+package barcode
+
+import "testing"
+
+// BenchmarkProcessBarcode 识别条码类型，网址仅在带协议或 www. 前缀时解析
+func BenchmarkProcessBarcode(b *testing.B) {
+	for _, bm := range []struct {
+		name    string
+		content string
+	}{
+		{"ean13", "6901234567892"},
+		{"serial", "SN000000000101"},
+		{"url", "https://example.com/p/6901234567892?lot=L01"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			p := NewProcessor()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p.ProcessBarcode(bm.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package barcode
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// fieldNamePattern 派生字段名规则，字段名会用于JSON路径和索引名
+var fieldNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// Rule 已编译的分类规则
+type Rule struct {
+	Name    string
+	Type    string // 匹配后设置的条码类型，为空表示保持自动识别结果
+	Pattern *regexp.Regexp
+	Fields  map[string]string // 捕获组名 -> 派生字段名
+}
+
+// CompileRule 编译分类规则，校验正则及捕获组映射
+func CompileRule(name, barcodeType, pattern string, fields map[string]string) (*Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("正则表达式无效: %w", err)
+	}
+
+	groups := make(map[string]bool)
+	for _, group := range re.SubexpNames() {
+		if group != "" {
+			groups[group] = true
+		}
+	}
+	for group, field := range fields {
+		if !groups[group] {
+			return nil, fmt.Errorf("正则表达式中不存在捕获组 %s", group)
+		}
+		if !ValidFieldName(field) {
+			return nil, fmt.Errorf("派生字段名无效: %s", field)
+		}
+	}
+
+	return &Rule{Name: name, Type: barcodeType, Pattern: re, Fields: fields}, nil
+}
+
+// ValidFieldName 派生字段名是否合法（字母、数字、下划线，且不以数字开头）
+func ValidFieldName(name string) bool {
+	return fieldNamePattern.MatchString(name)
+}
+
+// Match 匹配条码并提取派生字段，未匹配时返回false
+func (r *Rule) Match(content string) (map[string]string, bool) {
+	match := r.Pattern.FindStringSubmatch(content)
+	if match == nil {
+		return nil, false
+	}
+
+	derived := make(map[string]string, len(r.Fields))
+	for i, group := range r.Pattern.SubexpNames() {
+		if field, ok := r.Fields[group]; ok && i < len(match) {
+			derived[field] = match[i]
+		}
+	}
+	return derived, true
+}
+
+// SetRules 替换分类规则，按给定顺序匹配，第一条匹配的规则生效
+func (p *Processor) SetRules(rules []*Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = rules
+}
+
+// applyRules 应用第一条匹配的分类规则
+func applyRules(data *BarcodeData, rules []*Rule) {
+	for _, rule := range rules {
+		derived, ok := rule.Match(data.Content)
+		if !ok {
+			continue
+		}
+		if rule.Type != "" {
+			data.Type = rule.Type
+		}
+		if len(derived) > 0 {
+			data.Derived = derived
+		}
+		return
+	}
+}
@@ -0,0 +1,197 @@
+// Package hll 实现 HyperLogLog 基数估算，用于在固定内存内统计不同条码的数量
+//
+// 估算使用 Ertl 的改进估算器（"New cardinality estimation algorithms for HyperLogLog
+// sketches", 2017），在整个基数范围内无需经验偏差修正表；哈希为 FNV-1a 加 MurmurHash3
+// 的 64 位终结混合，不依赖第三方库。
+package hll
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	// MinPrecision 最小精度
+	MinPrecision = 4
+	// MaxPrecision 最大精度，寄存器占 256KB
+	MaxPrecision = 18
+
+	// encodingVersion 序列化格式版本
+	encodingVersion = 1
+)
+
+// Sketch HyperLogLog 草图，精度 p 时占用 2^p 字节，相对标准误差约 1.04/√(2^p)
+//
+// Sketch 不是并发安全的，由调用方加锁。
+type Sketch struct {
+	precision uint8
+	registers []uint8
+}
+
+// New 创建指定精度的草图
+func New(precision uint8) (*Sketch, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, fmt.Errorf("精度须在 %d 到 %d 之间: %d", MinPrecision, MaxPrecision, precision)
+	}
+	return &Sketch{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}, nil
+}
+
+// Precision 草图精度
+func (s *Sketch) Precision() uint8 {
+	return s.precision
+}
+
+// StandardError 相对标准误差（1σ）
+func (s *Sketch) StandardError() float64 {
+	return StandardError(s.precision)
+}
+
+// StandardError 指定精度下的相对标准误差（1σ）
+func StandardError(precision uint8) float64 {
+	return 1.04 / math.Sqrt(float64(uint64(1)<<precision))
+}
+
+// Add 添加元素，草图发生变化时返回true
+func (s *Sketch) Add(value []byte) bool {
+	return s.addHash(hash(value))
+}
+
+// AddString 添加字符串元素，草图发生变化时返回true
+func (s *Sketch) AddString(value string) bool {
+	return s.Add([]byte(value))
+}
+
+// addHash 按哈希值更新寄存器：高 p 位选择寄存器，其余位的前导零个数加一为寄存器值
+func (s *Sketch) addHash(h uint64) bool {
+	p := s.precision
+	index := h >> (64 - p)
+	rank := uint8(bits.LeadingZeros64(h<<p|1<<(p-1))) + 1
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+		return true
+	}
+	return false
+}
+
+// Merge 合并另一个草图，结果等价于两者元素并集的草图
+func (s *Sketch) Merge(other *Sketch) error {
+	if other.precision != s.precision {
+		return fmt.Errorf("草图精度不同: %d 与 %d", s.precision, other.precision)
+	}
+	for i, value := range other.registers {
+		if value > s.registers[i] {
+			s.registers[i] = value
+		}
+	}
+	return nil
+}
+
+// Estimate 估算不同元素的数量
+func (s *Sketch) Estimate() uint64 {
+	m := float64(len(s.registers))
+	q := 64 - int(s.precision)
+
+	// 寄存器值的直方图，值域为 0..q+1
+	counts := make([]float64, q+2)
+	for _, value := range s.registers {
+		counts[value]++
+	}
+	if counts[0] == m {
+		return 0
+	}
+
+	z := m * tau(1-counts[q+1]/m)
+	for k := q; k >= 1; k-- {
+		z = 0.5 * (z + counts[k])
+	}
+	z += m * sigma(counts[0]/m)
+	return uint64(math.Round(m * m / (2 * math.Ln2) / z))
+}
+
+// sigma Ertl 估算器中处理空寄存器的级数
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		previous := z
+		z += x * y
+		y += y
+		if z == previous {
+			return z
+		}
+	}
+}
+
+// tau Ertl 估算器中处理饱和寄存器的级数
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		previous := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == previous {
+			return z / 3
+		}
+	}
+}
+
+// MarshalBinary 实现 encoding.BinaryMarshaler：版本、精度及寄存器
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 2+len(s.registers))
+	data[0] = encodingVersion
+	data[1] = s.precision
+	copy(data[2:], s.registers)
+	return data, nil
+}
+
+// UnmarshalBinary 实现 encoding.BinaryUnmarshaler
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errors.New("草图数据过短")
+	}
+	if data[0] != encodingVersion {
+		return fmt.Errorf("不支持的草图格式版本: %d", data[0])
+	}
+	precision := data[1]
+	if precision < MinPrecision || precision > MaxPrecision {
+		return fmt.Errorf("草图精度无效: %d", precision)
+	}
+	if len(data)-2 != 1<<precision {
+		return fmt.Errorf("草图数据长度与精度 %d 不符", precision)
+	}
+	s.precision = precision
+	s.registers = make([]uint8, 1<<precision)
+	copy(s.registers, data[2:])
+	return nil
+}
+
+// hash 64 位 FNV-1a 后做 MurmurHash3 终结混合，使各位分布均匀
+func hash(value []byte) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for _, b := range value {
+		h ^= uint64(b)
+		h *= prime64
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
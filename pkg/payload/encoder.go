@@ -0,0 +1,564 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// encoderFunc 单个类型的编码函数
+type encoderFunc func(e *encodeState, v reflect.Value) error
+
+// encoderKey 编码器缓存键，同一类型在不同格式下编码器不同
+type encoderKey struct {
+	t reflect.Type
+	f Format
+}
+
+// encoderCache 已构建的编码器，按类型和格式缓存，避免每次序列化都做反射分析
+var encoderCache sync.Map
+
+// encodeState 编码状态
+type encodeState struct {
+	*bytes.Buffer
+	format Format
+	// reserved 扁平化时外层已占用的字段名，仅作用于下一个对象
+	reserved map[string]bool
+}
+
+// encode 编码任意值
+func (e *encodeState) encode(v interface{}) error {
+	if v == nil {
+		e.WriteString("null")
+		return nil
+	}
+	value := reflect.ValueOf(v)
+	return encoderFor(value.Type(), e.format)(e, value)
+}
+
+// encoderFor 获取类型的编码器
+func encoderFor(t reflect.Type, f Format) encoderFunc {
+	key := encoderKey{t: t, f: f}
+	if cached, ok := encoderCache.Load(key); ok {
+		return cached.(encoderFunc)
+	}
+
+	// 先放入间接编码器以支持递归类型
+	var (
+		wg  sync.WaitGroup
+		enc encoderFunc
+	)
+	wg.Add(1)
+	actual, loaded := encoderCache.LoadOrStore(key, encoderFunc(func(e *encodeState, v reflect.Value) error {
+		wg.Wait()
+		return enc(e, v)
+	}))
+	if loaded {
+		return actual.(encoderFunc)
+	}
+
+	enc = newEncoder(t, f)
+	wg.Done()
+	encoderCache.Store(key, enc)
+	return enc
+}
+
+// newEncoder 构建类型的编码器
+func newEncoder(t reflect.Type, f Format) encoderFunc {
+	if t == timeType {
+		return timeEncoder
+	}
+	if t.Implements(marshalerType) {
+		return marshalerEncoder
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintEncoder
+	case reflect.Float32, reflect.Float64:
+		return floatEncoder(t.Bits())
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Ptr:
+		return ptrEncoder(t, f)
+	case reflect.Struct:
+		return structEncoder(t, f)
+	case reflect.Map:
+		return mapEncoder(t, f)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return bytesEncoder
+		}
+		return arrayEncoder(t, f)
+	case reflect.Array:
+		return arrayEncoder(t, f)
+	default:
+		return func(e *encodeState, v reflect.Value) error {
+			return fmt.Errorf("不支持序列化的类型: %s", t)
+		}
+	}
+}
+
+// timeEncoder 按格式输出时间戳
+func timeEncoder(e *encodeState, v reflect.Value) error {
+	t := v.Interface().(time.Time)
+	var buf [64]byte
+	switch e.format.Timestamp {
+	case TimestampEpochSeconds:
+		e.Write(strconv.AppendInt(buf[:0], t.Unix(), 10))
+	case TimestampEpochMillis:
+		e.Write(strconv.AppendInt(buf[:0], t.UnixMilli(), 10))
+	case TimestampEpochMicros:
+		e.Write(strconv.AppendInt(buf[:0], t.UnixMicro(), 10))
+	case TimestampRFC3339Micro:
+		e.WriteByte('"')
+		e.Write(t.UTC().AppendFormat(buf[:0], rfc3339Micro))
+		e.WriteByte('"')
+	default:
+		e.WriteByte('"')
+		e.Write(t.AppendFormat(buf[:0], time.RFC3339Nano))
+		e.WriteByte('"')
+	}
+	return nil
+}
+
+// marshalerEncoder 自定义序列化的类型保持原样输出
+func marshalerEncoder(e *encodeState, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return nil
+	}
+	data, err := v.Interface().(json.Marshaler).MarshalJSON()
+	if err != nil {
+		return err
+	}
+	e.Write(data)
+	return nil
+}
+
+func boolEncoder(e *encodeState, v reflect.Value) error {
+	if v.Bool() {
+		e.WriteString("true")
+	} else {
+		e.WriteString("false")
+	}
+	return nil
+}
+
+func intEncoder(e *encodeState, v reflect.Value) error {
+	var buf [24]byte
+	e.Write(strconv.AppendInt(buf[:0], v.Int(), 10))
+	return nil
+}
+
+func uintEncoder(e *encodeState, v reflect.Value) error {
+	var buf [24]byte
+	e.Write(strconv.AppendUint(buf[:0], v.Uint(), 10))
+	return nil
+}
+
+// floatEncoder 与 encoding/json 一致的浮点数输出
+func floatEncoder(bits int) encoderFunc {
+	return func(e *encodeState, v reflect.Value) error {
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return fmt.Errorf("不支持序列化的浮点数: %v", f)
+		}
+
+		format := byte('f')
+		if abs := math.Abs(f); abs != 0 && (bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21)) {
+			format = 'e'
+		}
+		var buf [32]byte
+		b := strconv.AppendFloat(buf[:0], f, format, -1, bits)
+		if format == 'e' {
+			// 将 e-09 简化为 e-9
+			if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+				b[n-2] = b[n-1]
+				b = b[:n-1]
+			}
+		}
+		e.Write(b)
+		return nil
+	}
+}
+
+func stringEncoder(e *encodeState, v reflect.Value) error {
+	writeString(e.Buffer, v.String())
+	return nil
+}
+
+func bytesEncoder(e *encodeState, v reflect.Value) error {
+	if v.IsNil() {
+		e.WriteString("null")
+		return nil
+	}
+	e.WriteByte('"')
+	encoder := base64.NewEncoder(base64.StdEncoding, e.Buffer)
+	encoder.Write(v.Bytes())
+	encoder.Close()
+	e.WriteByte('"')
+	return nil
+}
+
+func interfaceEncoder(e *encodeState, v reflect.Value) error {
+	if v.IsNil() {
+		e.WriteString("null")
+		return nil
+	}
+	elem := v.Elem()
+	return encoderFor(elem.Type(), e.format)(e, elem)
+}
+
+func ptrEncoder(t reflect.Type, f Format) encoderFunc {
+	elem := encoderFor(t.Elem(), f)
+	return func(e *encodeState, v reflect.Value) error {
+		if v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		return elem(e, v.Elem())
+	}
+}
+
+func arrayEncoder(t reflect.Type, f Format) encoderFunc {
+	elem := encoderFor(t.Elem(), f)
+	return func(e *encodeState, v reflect.Value) error {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		e.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			if err := elem(e, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.WriteByte(']')
+		return nil
+	}
+}
+
+// mapEncoder 对象按键排序输出，键名按命名方式转换
+func mapEncoder(t reflect.Type, f Format) encoderFunc {
+	switch t.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return func(e *encodeState, v reflect.Value) error {
+			return fmt.Errorf("不支持序列化的对象键类型: %s", t.Key())
+		}
+	}
+
+	elem := encoderFor(t.Elem(), f)
+	return func(e *encodeState, v reflect.Value) error {
+		if v.IsNil() {
+			e.WriteString("null")
+			return nil
+		}
+		reserved := e.reserved
+		e.reserved = nil
+
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := mapKeyString(iter.Key())
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Strings(keys)
+
+		e.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			name := f.fieldName(key)
+			if reserved[name] {
+				name = f.fieldName(flattenField + "_" + key)
+			}
+			writeString(e.Buffer, name)
+			e.WriteByte(':')
+			if err := elem(e, values[key]); err != nil {
+				return err
+			}
+		}
+		e.WriteByte('}')
+		return nil
+	}
+}
+
+// mapKeyString 对象键转为字符串
+func mapKeyString(key reflect.Value) string {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10)
+	default:
+		return strconv.FormatUint(key.Uint(), 10)
+	}
+}
+
+// structField 结构体字段的编码信息
+type structField struct {
+	index     []int
+	rawName   string
+	name      string
+	key       []byte // 预先编码的 "name":
+	omitEmpty bool
+	flatten   bool
+	encoder   encoderFunc
+}
+
+// structEncoder 按json标签输出字段，字段名与键在构建时预先计算
+func structEncoder(t reflect.Type, f Format) encoderFunc {
+	fields := typeFields(t, f)
+	names := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if !field.flatten {
+			names[field.name] = true
+		}
+	}
+
+	return func(e *encodeState, v reflect.Value) error {
+		reserved := e.reserved
+		e.reserved = nil
+
+		e.WriteByte('{')
+		first := true
+		for i := range fields {
+			field := &fields[i]
+			fv, ok := fieldByIndex(v, field.index)
+			if !ok || (field.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+
+			start := e.Len()
+			if !first {
+				e.WriteByte(',')
+			}
+
+			if field.flatten {
+				if ok, err := e.encodeFlattened(field, fv, names, start, first); err != nil {
+					return err
+				} else if ok {
+					first = first && e.Len() == start
+					continue
+				}
+			}
+
+			if reserved[field.name] {
+				writeString(e.Buffer, f.fieldName(flattenField+"_"+field.rawName))
+				e.WriteByte(':')
+			} else {
+				e.Write(field.key)
+			}
+			if err := field.encoder(e, fv); err != nil {
+				return err
+			}
+			first = false
+		}
+		e.WriteByte('}')
+		return nil
+	}
+}
+
+// encodeFlattened 将对象类型的字段展开到外层，不是对象时返回false由调用方按普通字段输出
+func (e *encodeState) encodeFlattened(field *structField, fv reflect.Value, names map[string]bool, start int, first bool) (bool, error) {
+	pos := e.Len()
+	e.reserved = names
+	err := field.encoder(e, fv)
+	e.reserved = nil
+	if err != nil {
+		return false, err
+	}
+
+	b := e.Bytes()
+	if b[pos] != '{' {
+		e.Truncate(start)
+		if !first {
+			e.WriteByte(',')
+		}
+		return false, nil
+	}
+
+	inner := len(b) - pos - 2
+	if inner == 0 {
+		e.Truncate(start)
+		return true, nil
+	}
+	copy(b[pos:], b[pos+1:len(b)-1])
+	e.Truncate(pos + inner)
+	return true, nil
+}
+
+// typeFields 解析结构体字段，匿名嵌入的结构体字段提升到外层
+func typeFields(t reflect.Type, f Format) []structField {
+	var fields []structField
+	seen := make(map[string]bool)
+
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct && ft != timeType {
+					walk(ft, append(append([]int{}, index...), i))
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+
+			if name == "" {
+				name = sf.Name
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			converted := f.fieldName(name)
+			var key bytes.Buffer
+			writeString(&key, converted)
+			key.WriteByte(':')
+
+			fields = append(fields, structField{
+				index:     append(append([]int{}, index...), i),
+				rawName:   name,
+				name:      converted,
+				key:       key.Bytes(),
+				omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+				flatten:   f.Flatten && len(index) == 0 && name == flattenField,
+				encoder:   encoderFor(sf.Type, f),
+			})
+		}
+	}
+	walk(t, nil)
+	return fields
+}
+
+// fieldByIndex 获取嵌套字段，嵌入的空指针返回false
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue 与 encoding/json 的 omitempty 判定一致
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+const hexDigits = "0123456789abcdef"
+
+// writeString 输出JSON字符串，转义规则与 encoding/json 一致（含HTML转义）
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch b {
+			case '\\', '"':
+				buf.WriteByte('\\')
+				buf.WriteByte(b)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			default:
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[b>>4])
+				buf.WriteByte(hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+}
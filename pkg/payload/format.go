@@ -0,0 +1,228 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// 时间戳格式
+const (
+	TimestampRFC3339      = "rfc3339"  // ISO-8601，带时区
+	TimestampEpochSeconds = "epoch_s"  // Unix秒
+	TimestampEpochMillis  = "epoch_ms" // Unix毫秒
+
+	// TimestampRFC3339Micro 规范格式：UTC、固定6位小数，字符串顺序与时间顺序一致；
+	// 同一微秒内的扫码按 record_id（WebSocket 推送按 seq）排序
+	TimestampRFC3339Micro = "rfc3339_us"
+	TimestampEpochMicros  = "epoch_us" // Unix微秒
+)
+
+// rfc3339Micro 规范格式的时间布局
+const rfc3339Micro = "2006-01-02T15:04:05.000000Z07:00"
+
+// 字段命名方式
+const (
+	NamingCurrent = "current" // 保持结构体定义的名称（snake_case）
+	NamingCamel   = "camel"   // camelCase
+)
+
+// flattenField 扁平化时展开到外层的字段名
+const flattenField = "data"
+
+// Format 输出载荷格式
+type Format struct {
+	Timestamp string
+	Naming    string
+	Flatten   bool // 将 data 字段的内容展开到外层
+}
+
+// Default 默认格式，与 encoding/json 的输出一致
+var Default = Format{Timestamp: TimestampRFC3339, Naming: NamingCurrent}
+
+// ParseFormat 解析并校验格式，空值使用默认值
+func ParseFormat(timestamp, naming string, flatten bool) (Format, error) {
+	f := Format{Timestamp: timestamp, Naming: naming, Flatten: flatten}
+	if f.Timestamp == "" {
+		f.Timestamp = TimestampRFC3339
+	}
+	if f.Naming == "" {
+		f.Naming = NamingCurrent
+	}
+
+	switch f.Timestamp {
+	case TimestampRFC3339, TimestampEpochSeconds, TimestampEpochMillis, TimestampRFC3339Micro, TimestampEpochMicros:
+	default:
+		return Default, fmt.Errorf("不支持的时间戳格式: %s", timestamp)
+	}
+	switch f.Naming {
+	case NamingCurrent, NamingCamel:
+	default:
+		return Default, fmt.Errorf("不支持的字段命名方式: %s", naming)
+	}
+	return f, nil
+}
+
+// IsDefault 是否为默认格式
+func (f Format) IsDefault() bool {
+	return f == Default
+}
+
+// String 格式描述
+func (f Format) String() string {
+	return fmt.Sprintf("timestamp=%s,naming=%s,flatten=%t", f.Timestamp, f.Naming, f.Flatten)
+}
+
+// bufferPool 序列化缓冲池
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// Marshal 按指定格式序列化，默认格式直接使用 encoding/json
+func Marshal(f Format, v interface{}) ([]byte, error) {
+	if f.IsDefault() {
+		return json.Marshal(v)
+	}
+
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufferPool.Put(buf)
+
+	e := &encodeState{Buffer: buf, format: f}
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, buf.Len())
+	copy(out, buf.Bytes())
+	return out, nil
+}
+
+// Reformat 将默认格式的JSON转换为指定格式，用于已持久化的载荷
+//
+// 原始类型信息已丢失，可解析为RFC3339的字符串按时间戳处理，对象字段按名称排序输出。
+func Reformat(f Format, data []byte) ([]byte, error) {
+	if f.IsDefault() {
+		return data, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("解析载荷失败: %w", err)
+	}
+
+	value = reformatValue(f, value)
+	if object, ok := value.(map[string]interface{}); ok && f.Flatten {
+		value = flattenObject(f, object)
+	}
+	return json.Marshal(value)
+}
+
+// reformatValue 递归转换字段名和时间戳
+func reformatValue(f Format, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[f.fieldName(key)] = reformatValue(f, item)
+		}
+		return out
+	case []interface{}:
+		for i, item := range v {
+			v[i] = reformatValue(f, item)
+		}
+		return v
+	case string:
+		if t, ok := parseTimestamp(v); ok {
+			return f.timeValue(t)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// flattenObject 将 data 对象展开到外层，与外层重名的字段加 data 前缀
+func flattenObject(f Format, object map[string]interface{}) map[string]interface{} {
+	data, ok := object[flattenField].(map[string]interface{})
+	if !ok {
+		return object
+	}
+	delete(object, flattenField)
+	for key, item := range data {
+		if _, exists := object[key]; exists {
+			key = f.fieldName(flattenField + "_" + snakeName(key))
+		}
+		object[key] = item
+	}
+	return object
+}
+
+// parseTimestamp 识别RFC3339格式的时间字符串
+func parseTimestamp(s string) (time.Time, bool) {
+	if len(s) < len("2006-01-02T15:04:05Z") || s[10] != 'T' {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+// timeValue 按时间戳格式转换（用于 Reformat）
+func (f Format) timeValue(t time.Time) interface{} {
+	switch f.Timestamp {
+	case TimestampEpochSeconds:
+		return t.Unix()
+	case TimestampEpochMillis:
+		return t.UnixMilli()
+	case TimestampEpochMicros:
+		return t.UnixMicro()
+	case TimestampRFC3339Micro:
+		return t.UTC().Format(rfc3339Micro)
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
+
+// fieldName 按命名方式转换字段名
+func (f Format) fieldName(name string) string {
+	if f.Naming != NamingCamel || !strings.Contains(name, "_") {
+		return name
+	}
+
+	var b strings.Builder
+	b.Grow(len(name))
+	upper := false
+	for i, r := range name {
+		if r == '_' {
+			upper = i > 0
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// snakeName 将camelCase还原为snake_case，用于生成前缀字段名
+func snakeName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
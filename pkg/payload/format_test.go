@@ -0,0 +1,128 @@
+package payload_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"userclient/internal/websocket"
+	"userclient/pkg/payload"
+)
+
+// update 重新生成 testdata 下的期望输出：go test ./pkg/payload -update
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenFormats 对接方常用的格式组合，名称即期望输出的文件名前缀
+var goldenFormats = []struct {
+	name   string
+	format payload.Format
+}{
+	{"default", payload.Default},
+	{"epoch_s", payload.Format{Timestamp: payload.TimestampEpochSeconds, Naming: payload.NamingCurrent}},
+	{"epoch_ms_camel", payload.Format{Timestamp: payload.TimestampEpochMillis, Naming: payload.NamingCamel}},
+	{"rfc3339_us_flatten", payload.Format{Timestamp: payload.TimestampRFC3339Micro, Naming: payload.NamingCurrent, Flatten: true}},
+	{"epoch_us_camel_flatten", payload.Format{Timestamp: payload.TimestampEpochMicros, Naming: payload.NamingCamel, Flatten: true}},
+}
+
+// goldenRecord 覆盖各类字段的扫码数据：与外层重名的字段、嵌套对象、空指针、省略的空值、原样输出的JSON
+type goldenRecord struct {
+	ID               uint              `json:"id"`
+	Content          string            `json:"content"`
+	Type             string            `json:"type"`
+	DeviceID         *uint             `json:"device_id"`
+	Device           *goldenDevice     `json:"device,omitempty"`
+	LastSeen         *time.Time        `json:"last_seen"`
+	AvgKeyIntervalMS float64           `json:"avg_key_interval_ms"`
+	Derived          map[string]string `json:"derived,omitempty"`
+	SecondaryDevices []uint            `json:"secondary_devices"`
+	Raw              json.RawMessage   `json:"raw"`
+	Message          string            `json:"message,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
+
+type goldenDevice struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// goldenMessage WebSocket 推送的扫码消息，时间带时区和纳秒以覆盖时间戳转换
+func goldenMessage() websocket.Message {
+	at := time.Date(2026, 10, 16, 16, 30, 0, 123456789, time.FixedZone("CST", 8*3600))
+	deviceID := uint(2)
+	record := &goldenRecord{
+		ID: 1042, Content: "6901234567892", Type: "EAN13",
+		DeviceID: &deviceID, Device: &goldenDevice{ID: deviceID, Name: "1号线扫码枪", CreatedAt: at},
+		AvgKeyIntervalMS: 3.7, Derived: map[string]string{"gtin": "06901234567892", "batch_no": "L01"},
+		SecondaryDevices: []uint{3}, Raw: json.RawMessage(`{"key_count":13}`), CreatedAt: at,
+	}
+	return websocket.Message{Type: "barcode", Seq: 7, Data: record, Time: at}
+}
+
+// checkGolden 与 testdata/<name>.golden 逐字节比较，-update 时覆盖期望输出
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	got = append(got, '\n')
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s 与期望输出不一致，确认变更后以 -update 重新生成\ngot:  %s\nwant: %s", path, got, want)
+	}
+}
+
+// TestMarshalGolden WebSocket 客户端及文件输出按格式直接序列化消息
+func TestMarshalGolden(t *testing.T) {
+	message := goldenMessage()
+	for _, tt := range goldenFormats {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := payload.Marshal(tt.format, message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !json.Valid(got) {
+				t.Fatalf("invalid json: %s", got)
+			}
+			checkGolden(t, "marshal_"+tt.name, got)
+		})
+	}
+
+	// 默认格式与 encoding/json 的输出一致
+	want, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := payload.Marshal(payload.Default, message)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("default format differs from encoding/json:\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+// TestReformatGolden 文件输出补投已持久化的默认格式载荷
+func TestReformatGolden(t *testing.T) {
+	stored, err := json.Marshal(goldenMessage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range goldenFormats {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := payload.Reformat(tt.format, stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "reformat_"+tt.name, got)
+		})
+	}
+}
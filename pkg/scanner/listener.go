@@ -0,0 +1,245 @@
+// Package scanner 嵌入用的扫码采集接口
+//
+// Listener 在调用方自己的程序中采集键盘钩子（Linux下为 evdev）、串口及网络扫码枪的扫码，按分类规则处理后
+// 由 Scans 交出，不需要数据库、WebSocket 及HTTP服务。应用程序本身也经同一种有界扫码缓冲接收采集来源的扫码，
+// 两者的组装、过滤及缓冲行为相同；扫码记录、去重、推送等由应用程序的条码处理器完成，不在此包中。
+//
+//	cfg := scanner.DefaultConfig()
+//	cfg.Serial.Enable = true
+//	cfg.Serial.Ports = []scanner.SerialPortConfig{{Name: "COM3"}}
+//	listener, err := scanner.NewListener(cfg)
+//	...
+//	if err := listener.Start(); err != nil { ... }
+//	defer listener.Stop()
+//	for data := range listener.Scans() { ... }
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"userclient/internal/config"
+	capture "userclient/internal/scanner"
+	"userclient/internal/scanner/network"
+	"userclient/internal/scanner/serial"
+	"userclient/internal/service"
+	"userclient/pkg/barcode"
+)
+
+// 采集参数，与应用程序配置文件中的 scanner、serial、network_scanner 段相同
+type (
+	KeyboardConfig   = config.ScannerConfig
+	SerialConfig     = config.SerialConfig
+	SerialPortConfig = config.SerialPortConfig
+	NetworkConfig    = config.NetworkScannerConfig
+)
+
+// 扫码缓冲满时的处理方式
+const (
+	// OverflowBlock 采集来源等待调用方取走扫码，不丢扫码；键盘钩子在钩子线程中等待，期间前台程序的按键会卡顿
+	OverflowBlock = config.ScanOverflowBlock
+	// OverflowDropOldest 丢弃缓冲中最早的扫码，采集来源不等待；丢弃数见 Dropped
+	OverflowDropOldest = config.ScanOverflowDropOldest
+)
+
+// ErrNoSources 没有启用任何采集来源
+var ErrNoSources = errors.New("没有启用任何采集来源")
+
+// Config 采集配置
+type Config struct {
+	// Keyboard 为nil时不采集键盘（Windows下的键盘钩子、Linux下的 evdev），EnableHook 为 false 时同样不采集
+	Keyboard *KeyboardConfig
+	Serial   SerialConfig
+	Network  NetworkConfig
+
+	// BufferSize 扫码缓冲的大小，不小于1；Scans 本身无缓冲，处理中的一条不计入
+	BufferSize int
+	// Overflow 缓冲满时的处理方式：OverflowBlock 或 OverflowDropOldest
+	Overflow string
+
+	// Processor 按分类规则识别类型及提取派生字段，为nil时使用没有规则的处理器
+	Processor *barcode.Processor
+	// Logger 为nil时不输出日志
+	Logger *logrus.Logger
+}
+
+// DefaultConfig 与应用程序默认配置相同的采集参数，只启用键盘采集
+func DefaultConfig() Config {
+	defaults := config.Defaults()
+	keyboard := defaults.Scanner
+	return Config{
+		Keyboard:   &keyboard,
+		Serial:     defaults.Serial,
+		Network:    defaults.NetworkScanner,
+		BufferSize: defaults.Scanner.BufferSize,
+		Overflow:   defaults.Scanner.Overflow,
+	}
+}
+
+// Listener 扫码采集
+//
+// Start 后各采集来源在独立的 goroutine 中运行，组装出的扫码进入有界缓冲，按分类规则处理后由 Scans 依次交出；
+// 调用方取得慢时按 Overflow 处理。Stop 后缓冲中未取走的扫码丢弃，Scans 随后关闭。
+type Listener struct {
+	queue     *capture.Queue
+	sources   []capture.ScannerSource
+	processor *barcode.Processor
+	scans     chan barcode.BarcodeData
+
+	mu      sync.Mutex
+	started bool
+	wg      sync.WaitGroup
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewListener 按配置创建扫码采集，未启用任何采集来源时返回 ErrNoSources
+func NewListener(cfg Config) (*Listener, error) {
+	if cfg.Overflow == "" {
+		cfg.Overflow = OverflowBlock
+	}
+	if cfg.Overflow != OverflowBlock && cfg.Overflow != OverflowDropOldest {
+		return nil, fmt.Errorf("缓冲满时的处理方式无效: %s", cfg.Overflow)
+	}
+	if cfg.BufferSize < 1 {
+		return nil, fmt.Errorf("扫码缓冲须不小于1: %d", cfg.BufferSize)
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logrus.New()
+		logger.SetOutput(io.Discard)
+	}
+	processor := cfg.Processor
+	if processor == nil {
+		processor = barcode.NewProcessor()
+	}
+
+	l := &Listener{
+		queue:     capture.NewQueue(cfg.BufferSize, cfg.Overflow),
+		processor: processor,
+		scans:     make(chan barcode.BarcodeData),
+		stop:      make(chan struct{}),
+	}
+	if cfg.Keyboard != nil && cfg.Keyboard.EnableHook {
+		if ok, reason := capture.Available(); !ok {
+			return nil, fmt.Errorf("键盘采集不可用: %s", reason)
+		}
+		keyboard := *cfg.Keyboard
+		l.sources = append(l.sources, capture.NewSource(&keyboard, l.queue, logger))
+	}
+	if cfg.Serial.Enable && len(cfg.Serial.Ports) > 0 {
+		l.sources = append(l.sources, serial.New(cfg.Serial, l.queue, logger))
+	}
+	if cfg.Network.Enable {
+		l.sources = append(l.sources, network.New(cfg.Network, l.queue, logger))
+	}
+	if len(l.sources) == 0 {
+		return nil, ErrNoSources
+	}
+	return l, nil
+}
+
+// Scans 处理后的扫码，Stop 后关闭
+func (l *Listener) Scans() <-chan barcode.BarcodeData {
+	return l.scans
+}
+
+// Start 安装并运行全部采集来源，任一来源安装失败时停止已安装的来源并返回错误；只能调用一次
+func (l *Listener) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.started {
+		return errors.New("扫码采集已启动")
+	}
+	l.started = true
+
+	go l.deliver()
+
+	// 键盘钩子的 Install 与 Run 须在同一 goroutine 中调用
+	installed := make(chan error, len(l.sources))
+	for _, source := range l.sources {
+		l.wg.Add(1)
+		go func(source capture.ScannerSource) {
+			defer l.wg.Done()
+			if err := source.Install(); err != nil {
+				installed <- fmt.Errorf("安装采集来源 %s 失败: %w", source.Name(), err)
+				return
+			}
+			installed <- nil
+			source.Run()
+		}(source)
+	}
+	var errs []error
+	for range l.sources {
+		if err := <-installed; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		l.shutdown()
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// Stop 停止全部采集来源并关闭 Scans，可重复调用
+func (l *Listener) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.shutdown()
+}
+
+// Dropped OverflowDropOldest 下因缓冲已满丢弃的扫码数
+func (l *Listener) Dropped() uint64 {
+	return l.queue.Dropped()
+}
+
+// shutdown 关闭缓冲、停止采集来源并等待各来源的 goroutine 结束
+func (l *Listener) shutdown() {
+	l.once.Do(func() {
+		// 先关闭缓冲，block 下正在等待空位的采集来源随即返回
+		close(l.stop)
+		l.queue.Close()
+		for _, source := range l.sources {
+			source.Stop()
+		}
+		l.wg.Wait()
+		if !l.started {
+			close(l.scans)
+		}
+	})
+}
+
+// deliver 按分类规则处理缓冲中的扫码并交给调用方，直到 Stop
+func (l *Listener) deliver() {
+	defer close(l.scans)
+	for scan := range l.queue.Scans() {
+		data := l.process(scan)
+		select {
+		case l.scans <- *data:
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// process 处理一次扫码，带上采集来源、识别出的设备及按键间隔
+func (l *Listener) process(scan capture.Scan) *barcode.BarcodeData {
+	data := l.processor.ProcessBarcode(scan.Barcode)
+	data.Input = scan.Input
+	if scan.DeviceID > 0 {
+		deviceID := scan.DeviceID
+		data.DeviceID = &deviceID
+	}
+	data.DurationMS = scan.Duration.Milliseconds()
+	if intervals, ok := service.MeasureKeyIntervals(scan.Timings); ok {
+		data.MinKeyIntervalMS = intervals.Min.Milliseconds()
+		data.MaxKeyIntervalMS = intervals.Max.Milliseconds()
+		data.AvgKeyIntervalMS = float64(intervals.Avg.Microseconds()) / 1000
+	}
+	return data
+}